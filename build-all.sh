@@ -13,6 +13,9 @@ find "$BUILD_DIR" -mindepth 1 ! -name ".gitkeep" ! -path "$BUILD_DIR/data" ! -pa
 VERSION=$(git describe --tags --always 2>/dev/null || echo "dev")
 echo "Building version: $VERSION"
 
+# Cross-compiled without cgo: these binaries can't use the "sqlite" database driver
+export CGO_ENABLED=0
+
 # Set ldflags for version
 LDFLAGS="-X 'wiki-go/internal/version.Version=$VERSION'"
 
@@ -9,8 +9,23 @@ require (
 )
 
 require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/gosimple/slug v1.15.0
+	github.com/gosimple/unidecode v1.0.1
+	github.com/lib/pq v1.12.3
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/quic-go/quic-go v0.59.1
+	github.com/redis/go-redis/v9 v9.9.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.43.0
 	golang.org/x/text v0.29.0
 )
 
-require github.com/gosimple/unidecode v1.0.1 // indirect
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+)
@@ -0,0 +1,336 @@
+package adr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+
+	"github.com/gosimple/slug"
+	"gopkg.in/yaml.v3"
+)
+
+// ADR statuses
+const (
+	StatusProposed   = "proposed"
+	StatusAccepted   = "accepted"
+	StatusSuperseded = "superseded"
+)
+
+// Record is a single Architecture Decision Record
+type Record struct {
+	Number       int                    `yaml:"adr" json:"number"`
+	Title        string                 `yaml:"title" json:"title"`
+	Status       string                 `yaml:"status" json:"status"`
+	Date         string                 `yaml:"date" json:"date"`
+	Author       string                 `yaml:"author,omitempty" json:"author,omitempty"`
+	Supersedes   int                    `yaml:"supersedes,omitempty" json:"supersedes,omitempty"`
+	SupersededBy int                    `yaml:"superseded_by,omitempty" json:"superseded_by,omitempty"`
+	Extra        map[string]interface{} `yaml:",inline" json:"-"` // Preserve unrelated frontmatter keys
+	Slug         string                 `yaml:"-" json:"slug"`    // Directory name, e.g. 0001-use-postgres
+	URL          string                 `yaml:"-" json:"url"`
+	body         string
+}
+
+var dirPattern = regexp.MustCompile(`^(\d{4})-`)
+
+// Store manages the ADRs kept in one directory of the documents tree
+type Store struct {
+	DocumentsDir string // Filesystem path of the documents directory
+	Dir          string // ADR directory relative to the documents directory
+}
+
+// ValidStatus reports whether status is a known ADR status
+func ValidStatus(status string) bool {
+	return status == StatusProposed || status == StatusAccepted || status == StatusSuperseded
+}
+
+// Label returns the display name of an ADR, e.g. "ADR-0003: Use PostgreSQL"
+func (r *Record) Label() string {
+	return fmt.Sprintf("ADR-%04d: %s", r.Number, r.Title)
+}
+
+// root returns the filesystem path of the ADR directory
+func (s *Store) root() string {
+	return filepath.Join(s.DocumentsDir, filepath.FromSlash(s.Dir))
+}
+
+// DocPath returns the filesystem path of an ADR's document.md
+func (s *Store) DocPath(r *Record) string {
+	return filepath.Join(s.root(), r.Slug, "document.md")
+}
+
+// WikiPath returns the wiki path of an ADR, relative to the documents directory
+func (s *Store) WikiPath(r *Record) string {
+	return s.Dir + "/" + r.Slug
+}
+
+// List returns all ADRs ordered by number
+func (s *Store) List() ([]*Record, error) {
+	entries, err := os.ReadDir(s.root())
+	if os.IsNotExist(err) {
+		return []*Record{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*Record{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !dirPattern.MatchString(entry.Name()) {
+			continue
+		}
+		record, err := s.load(entry.Name())
+		if err != nil {
+			continue // Not an ADR managed by the helper
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Number < records[j].Number
+	})
+
+	return records, nil
+}
+
+// Get returns the ADR with the given number
+func (s *Store) Get(number int) (*Record, error) {
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Number == number {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("ADR %d not found", number)
+}
+
+// load reads an ADR from its directory
+func (s *Store) load(dirName string) (*Record, error) {
+	data, err := encryption.ReadFile(filepath.Join(s.root(), dirName, "document.md"))
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+	if !strings.HasPrefix(content, "---\n") {
+		return nil, errors.New("missing frontmatter")
+	}
+	end := strings.Index(content[4:], "\n---")
+	if end == -1 {
+		return nil, errors.New("unterminated frontmatter")
+	}
+
+	record := &Record{}
+	if err := yaml.Unmarshal([]byte(content[4:4+end]), record); err != nil {
+		return nil, err
+	}
+	if record.Number == 0 {
+		// Fall back to the number in the directory name
+		record.Number, _ = strconv.Atoi(dirPattern.FindStringSubmatch(dirName)[1])
+	}
+	record.Slug = dirName
+	record.URL = "/" + s.Dir + "/" + dirName
+	record.body = strings.TrimLeft(content[4+end+4:], "\n")
+
+	return record, nil
+}
+
+// save writes an ADR back to disk
+func (s *Store) save(r *Record) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(r); err != nil {
+		return err
+	}
+
+	path := s.DocPath(r)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return encryption.WriteFile(path, []byte("---\n"+buf.String()+"---\n\n"+r.body), 0644)
+}
+
+// Create adds a new ADR with the next free number in the proposed state
+func (s *Store) Create(title, author string) (*Record, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	if len(records) > 0 {
+		next = records[len(records)-1].Number + 1
+	}
+
+	record := &Record{
+		Number: next,
+		Title:  title,
+		Status: StatusProposed,
+		Date:   time.Now().Format("2006-01-02"),
+		Author: author,
+		Slug:   fmt.Sprintf("%04d-%s", next, slug.Make(title)),
+	}
+	record.URL = "/" + s.Dir + "/" + record.Slug
+	record.body = "# " + record.Label() + "\n\n" + statusLine(record, nil) + "\n\n" +
+		"## Context\n\nWhat is the issue that motivates this decision?\n\n" +
+		"## Decision\n\nWhat is the change that we are proposing or have agreed to?\n\n" +
+		"## Consequences\n\nWhat becomes easier or more difficult because of this change?\n"
+
+	if err := s.save(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// SetStatus changes the status of an ADR. When superseding, supersededBy names the ADR
+// that replaces it and both records get cross-links to each other.
+// It returns every record that was modified so callers can version them.
+func (s *Store) SetStatus(number int, status string, supersededBy int) ([]*Record, error) {
+	if !ValidStatus(status) {
+		return nil, fmt.Errorf("invalid status %q", status)
+	}
+
+	record, err := s.Get(number)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != StatusSuperseded {
+		record.Status = status
+		record.SupersededBy = 0
+		record.body = replaceManagedLine(record.body, "> **Status:**", statusLine(record, nil))
+		return []*Record{record}, nil
+	}
+
+	if supersededBy == 0 || supersededBy == number {
+		return nil, errors.New("superseded_by must reference another ADR")
+	}
+	replacement, err := s.Get(supersededBy)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Status = StatusSuperseded
+	record.SupersededBy = replacement.Number
+	record.body = replaceManagedLine(record.body, "> **Status:**", statusLine(record, replacement))
+
+	replacement.Supersedes = record.Number
+	replacement.body = replaceManagedLine(replacement.body, "> **Supersedes:**",
+		"> **Supersedes:** ["+record.Label()+"]("+record.URL+")")
+
+	return []*Record{record, replacement}, nil
+}
+
+// Write saves modified records to disk
+func (s *Store) Write(records []*Record) error {
+	for _, record := range records {
+		if err := s.save(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusLine builds the status blockquote shown at the top of an ADR
+func statusLine(r *Record, replacement *Record) string {
+	if r.Status == StatusSuperseded && replacement != nil {
+		return "> **Status:** " + r.Status + " by [" + replacement.Label() + "](" + replacement.URL + ")"
+	}
+	return "> **Status:** " + r.Status
+}
+
+// replaceManagedLine replaces the first line starting with prefix, or inserts
+// the line after the document heading when it does not exist yet
+func replaceManagedLine(body, prefix, line string) string {
+	lines := strings.Split(body, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			lines[i] = line
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	// Insert below the status line, or below the heading when there is none
+	anchor := -1
+	for i, l := range lines {
+		if strings.HasPrefix(l, "> **Status:**") {
+			anchor = i
+			break
+		}
+	}
+	if anchor == -1 {
+		for i, l := range lines {
+			if strings.HasPrefix(l, "# ") {
+				anchor = i
+				break
+			}
+		}
+	}
+	if anchor == -1 {
+		return line + "\n\n" + body
+	}
+
+	result := append([]string{}, lines[:anchor+1]...)
+	result = append(result, "", line)
+	result = append(result, lines[anchor+1:]...)
+	return strings.Join(result, "\n")
+}
+
+// IndexContent generates the markdown of the ADR index page
+func (s *Store) IndexContent(records []*Record) string {
+	var sb strings.Builder
+	sb.WriteString("# Architecture Decision Records\n\n")
+	sb.WriteString("<!-- This page is generated automatically, manual changes will be overwritten -->\n\n")
+
+	if len(records) == 0 {
+		sb.WriteString("No decisions have been recorded yet.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("| ADR | Title | Status | Date |\n")
+	sb.WriteString("|-----|-------|--------|------|\n")
+	for _, r := range records {
+		status := r.Status
+		if r.Status == StatusSuperseded && r.SupersededBy != 0 {
+			status = fmt.Sprintf("superseded by ADR-%04d", r.SupersededBy)
+		}
+		title := strings.ReplaceAll(r.Title, "|", "\\|")
+		sb.WriteString(fmt.Sprintf("| [ADR-%04d](%s) | %s | %s | %s |\n", r.Number, r.URL, title, status, r.Date))
+	}
+
+	return sb.String()
+}
+
+// IndexPath returns the filesystem path of the generated index page
+func (s *Store) IndexPath() string {
+	return filepath.Join(s.root(), "document.md")
+}
+
+// WriteIndex regenerates the ADR index page
+func (s *Store) WriteIndex() error {
+	records, err := s.List()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.root(), 0755); err != nil {
+		return err
+	}
+	return encryption.WriteFile(s.IndexPath(), []byte(s.IndexContent(records)), 0644)
+}
@@ -0,0 +1,231 @@
+// Package ai runs writing actions, such as summarizing a page or fixing its
+// grammar, with a chat model behind an OpenAI-compatible API. Requests are
+// made by the wiki, so the API key never reaches the browser, and each one is
+// recorded in a log.
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"wiki-go/internal/breaker"
+	"wiki-go/internal/config"
+	"wiki-go/internal/safehttp"
+)
+
+// DefaultServerURL is the endpoint used when none is configured
+const DefaultServerURL = "https://api.openai.com/v1"
+
+// maxResponse is the largest response accepted from the API
+const maxResponse = 8 << 20
+
+// ErrUnknownAction is returned for actions that don't exist
+var ErrUnknownAction = errors.New("unknown action")
+
+// Action is a writing action offered in the editor
+type Action struct {
+	Name   string `json:"name"`
+	Prompt string `json:"-"`     // Instructions sent as the system message
+	Title  bool   `json:"title"` // Works from the title of the page instead of its text
+}
+
+// Actions are the writing actions, by name
+var Actions = map[string]Action{
+	"summarize": {
+		Name:   "summarize",
+		Prompt: "Summarize the markdown you are given in one short paragraph, in the language it is written in. Answer with the summary only.",
+	},
+	"fix-grammar": {
+		Name: "fix-grammar",
+		Prompt: "Fix the spelling, grammar and punctuation of the markdown you are given. Keep its language, meaning, tone " +
+			"and formatting, including links, code and HTML. Answer with the corrected markdown only.",
+	},
+	"improve": {
+		Name: "improve",
+		Prompt: "Rewrite the markdown you are given to be clearer and easier to read. Keep its language, meaning and " +
+			"formatting, including links, code and HTML. Answer with the rewritten markdown only.",
+	},
+	"outline": {
+		Name: "outline",
+		Prompt: "Write the outline of a wiki page with the title you are given, as markdown headings with a few bullet " +
+			"points each, in the language of the title. Don't repeat the title. Answer with the markdown only.",
+		Title: true,
+	},
+}
+
+// Completion is the answer of the model
+type Completion struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Client sends chat completion requests to an OpenAI-compatible endpoint
+type Client struct {
+	ServerURL string // Base URL of the API, e.g. "https://api.openai.com/v1"
+	APIKey    string
+	Model     string
+	Timeout   time.Duration
+	Breaker   *breaker.Breaker // Stops calling the API while it keeps failing
+}
+
+var aiBreaker = breaker.New("ai", breaker.Options{Threshold: 3, Cooldown: 30 * time.Second, MaxConcurrent: 8})
+
+// Enabled reports whether the writing actions are enabled
+func Enabled(cfg *config.Config) bool {
+	return cfg.Extensions.AI.Enable
+}
+
+// NewClient returns the client of the configured API
+func NewClient(cfg *config.Config) Client {
+	settings := cfg.Extensions.AI
+	timeout := time.Duration(settings.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return Client{
+		ServerURL: strings.TrimSpace(settings.ServerURL),
+		APIKey:    strings.TrimSpace(settings.APIKey),
+		Model:     strings.TrimSpace(settings.Model),
+		Timeout:   timeout,
+		Breaker:   aiBreaker,
+	}
+}
+
+// NewEmbeddingClient returns the client of the configured embedding model
+func NewEmbeddingClient(cfg *config.Config) Client {
+	client := NewClient(cfg)
+	if serverURL := strings.TrimSpace(cfg.Extensions.AI.EmbeddingServerURL); serverURL != "" {
+		client.ServerURL = serverURL
+	}
+	client.Model = strings.TrimSpace(cfg.Extensions.AI.EmbeddingModel)
+	return client
+}
+
+// Run runs the named action on input, the text or the title of a page
+func (c Client) Run(action, input string) (Completion, error) {
+	a, ok := Actions[action]
+	if !ok {
+		return Completion{}, fmt.Errorf("%w: %s", ErrUnknownAction, action)
+	}
+	return c.Complete(a.Prompt, input)
+}
+
+// Complete asks the model to answer the user message following the system message
+func (c Client) Complete(system, user string) (Completion, error) {
+	request := map[string]any{
+		"model": c.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		"temperature": 0.2,
+	}
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := c.post("/chat/completions", request, &response); err != nil {
+		return Completion{}, err
+	}
+	if len(response.Choices) == 0 {
+		return Completion{}, errors.New("AI server returned no answer")
+	}
+	return Completion{
+		Text:             strings.TrimSpace(response.Choices[0].Message.Content),
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+	}, nil
+}
+
+// Embed returns the embedding vector of each text, in the same order
+func (c Client) Embed(texts []string) ([][]float32, error) {
+	request := map[string]any{
+		"model": c.Model,
+		"input": texts,
+	}
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := c.post("/embeddings", request, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("AI server returned %d embeddings for %d texts", len(response.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("AI server returned an embedding for text %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// post sends a JSON request to path below the server URL and decodes the response
+func (c Client) post(path string, request, response any) error {
+	endpoint := c.ServerURL
+	if endpoint == "" {
+		endpoint = DefaultServerURL
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/") + path
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid AI server URL: %w", err)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// The endpoint is set up by the admin, local models often run next to the wiki
+	header := map[string][]string{"Accept": {"application/json"}}
+	if c.APIKey != "" {
+		header["Authorization"] = []string{"Bearer " + c.APIKey}
+	}
+	policy := safehttp.Policy{
+		AllowedHosts: []string{u.Hostname()},
+		AllowPrivate: true,
+		MaxBytes:     maxResponse,
+		Timeout:      c.Timeout,
+		Header:       header,
+		Breaker:      c.Breaker,
+		Attempts:     2,
+	}
+	status, data, err := policy.Post(endpoint, "application/json", body)
+	if err != nil {
+		return fmt.Errorf("AI request: %w", err)
+	}
+
+	var failure struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if status != 200 {
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			message = failure.Error.Message
+		}
+		return fmt.Errorf("AI server responded with status %d: %s", status, message)
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("AI server sent an invalid response: %w", err)
+	}
+	return nil
+}
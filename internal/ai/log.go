@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// MaxLogEntries is the number of requests kept in the log
+const MaxLogEntries = 1000
+
+// logFile holds the log in the data directory
+const logFile = "ai-log.json"
+
+// Entry records a request made for a user. The text sent and returned isn't
+// kept, only its size.
+type Entry struct {
+	Time             time.Time `json:"time"`
+	User             string    `json:"user"`
+	Action           string    `json:"action"`
+	Page             string    `json:"page,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	InputChars       int       `json:"inputChars"`
+	OutputChars      int       `json:"outputChars"`
+	PromptTokens     int       `json:"promptTokens,omitempty"`
+	CompletionTokens int       `json:"completionTokens,omitempty"`
+	DurationMS       int64     `json:"durationMs"`
+	Error            string    `json:"error,omitempty"`
+}
+
+var logMutex store.DataFile
+
+// Log adds an entry to the log, dropping the oldest entries beyond MaxLogEntries
+func Log(rootDir string, entry Entry) error {
+	unlock := logMutex.Lock(logFile)
+	defer unlock()
+
+	entries, err := loadLog(rootDir)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+	if len(entries) > MaxLogEntries {
+		entries = entries[len(entries)-MaxLogEntries:]
+	}
+	return saveLog(rootDir, entries)
+}
+
+// Recent returns up to limit entries of the log, newest first. A username
+// limits them to the requests of that user.
+func Recent(rootDir, username string, limit int) ([]Entry, error) {
+	entries, err := loadLog(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Entry{}
+	for i := len(entries) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if username == "" || entries[i].User == username {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+// Reattribute credits the logged requests of from to to, returning how many changed
+func Reattribute(rootDir, from, to string) (int, error) {
+	unlock := logMutex.Lock(logFile)
+	defer unlock()
+
+	entries, err := loadLog(rootDir)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range entries {
+		if entries[i].User == from {
+			entries[i].User = to
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, saveLog(rootDir, entries)
+}
+
+// loadLog reads the log
+func loadLog(rootDir string) ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(rootDir, logFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// saveLog writes the log, the caller holds its lock
+func saveLog(rootDir string, entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(rootDir, logFile), data, 0644)
+}
@@ -0,0 +1,317 @@
+// Package announcements manages the banners admins show above the pages of the
+// wiki, such as maintenance notices and policy updates, and which of them each
+// user dismissed
+package announcements
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// announcementsFile is stored in the data directory
+const announcementsFile = "announcements.json"
+
+// MaxMessage is the length of the markdown of an announcement, in bytes
+const MaxMessage = 4000
+
+// Levels of announcements, from the least to the most important
+const (
+	LevelInfo     = "info"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+// Errors returned for announcements that don't exist or can't be dismissed
+var (
+	ErrNotFound       = errors.New("announcement not found")
+	ErrNotDismissible = errors.New("this announcement can't be dismissed")
+)
+
+// Announcement is a banner shown above the pages of the wiki or of one of its
+// directories
+type Announcement struct {
+	ID          string     `json:"id"`
+	Level       string     `json:"level"`
+	Message     string     `json:"message"`         // Markdown
+	Path        string     `json:"path"`            // Directory whose pages show it, without slashes around it, "" for the whole wiki
+	Start       *time.Time `json:"start,omitempty"` // Shown from then on, right away when nil
+	End         *time.Time `json:"end,omitempty"`   // Hidden from then on, never when nil
+	Dismissible bool       `json:"dismissible"`
+	CreatedBy   string     `json:"createdBy"`
+	Created     time.Time  `json:"created"`
+	Updated     time.Time  `json:"updated"`
+}
+
+// Active reports whether the announcement is shown at now
+func (a Announcement) Active(now time.Time) bool {
+	return (a.Start == nil || !now.Before(*a.Start)) && (a.End == nil || now.Before(*a.End))
+}
+
+// AppliesTo reports whether the page at path shows the announcement
+func (a Announcement) AppliesTo(path string) bool {
+	path = strings.Trim(path, "/")
+	return a.Path == "" || path == a.Path || strings.HasPrefix(path, a.Path+"/")
+}
+
+// Normalize checks the announcement and cleans its fields
+func (a *Announcement) Normalize() error {
+	switch a.Level {
+	case "":
+		a.Level = LevelInfo
+	case LevelInfo, LevelWarning, LevelCritical:
+	default:
+		return fmt.Errorf("unknown level %q", a.Level)
+	}
+	a.Message = strings.TrimSpace(a.Message)
+	if a.Message == "" {
+		return errors.New("the message is empty")
+	}
+	if len(a.Message) > MaxMessage {
+		return fmt.Errorf("the message is longer than %d characters", MaxMessage)
+	}
+	a.Path = strings.Trim(strings.TrimSpace(a.Path), "/")
+	if strings.Contains(a.Path, "..") {
+		return fmt.Errorf("invalid path %q", a.Path)
+	}
+	if a.Start != nil && a.End != nil && !a.End.After(*a.Start) {
+		return errors.New("the end is before the start")
+	}
+	return nil
+}
+
+// severity ranks the levels, the most important highest
+func severity(level string) int {
+	switch level {
+	case LevelCritical:
+		return 2
+	case LevelWarning:
+		return 1
+	}
+	return 0
+}
+
+var (
+	mu             store.DataFile // Guards changes; files are read without it, since they are replaced at once
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+)
+
+// List returns all announcements, the most important first, then the newest
+func List(rootDir string) ([]Announcement, error) {
+	all, err := load(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if severity(all[i].Level) != severity(all[j].Level) {
+			return severity(all[i].Level) > severity(all[j].Level)
+		}
+		return all[i].Created.After(all[j].Created)
+	})
+	return all, nil
+}
+
+// Current returns the announcements the page at path shows at now, leaving out
+// those in dismissed, in the order of List
+func Current(rootDir, path string, now time.Time, dismissed []string) ([]Announcement, error) {
+	all, err := List(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	var shown []Announcement
+	for _, a := range all {
+		if !a.Active(now) || !a.AppliesTo(path) {
+			continue
+		}
+		if a.Dismissible && contains(dismissed, a.ID) {
+			continue
+		}
+		shown = append(shown, a)
+	}
+	return shown, nil
+}
+
+// Save stores an announcement. Announcements without an ID are created, the
+// others replace the stored announcement with the same ID.
+func Save(rootDir string, a Announcement, user string) (Announcement, error) {
+	if err := a.Normalize(); err != nil {
+		return Announcement{}, err
+	}
+
+	unlock := mu.Lock(announcementsFile)
+	defer unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return Announcement{}, err
+	}
+	a.Updated = time.Now()
+
+	if a.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return Announcement{}, err
+		}
+		a.ID = hex.EncodeToString(id)
+		a.CreatedBy = user
+		a.Created = a.Updated
+		all = append(all, a)
+		return a, save(rootDir, all)
+	}
+
+	for i := range all {
+		if all[i].ID == a.ID {
+			a.CreatedBy = all[i].CreatedBy
+			a.Created = all[i].Created
+			all[i] = a
+			return a, save(rootDir, all)
+		}
+	}
+	return Announcement{}, ErrNotFound
+}
+
+// Delete removes the announcement with id
+func Delete(rootDir, id string) error {
+	unlock := mu.Lock(announcementsFile)
+	defer unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	for i := range all {
+		if all[i].ID == id {
+			return save(rootDir, append(all[:i], all[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+// dismissedFile returns the file holding the announcements user dismissed
+func dismissedFile(rootDir, user string) string {
+	return filepath.Join(rootDir, "dismissed-announcements", unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// Dismissed returns the IDs of the announcements user dismissed
+func Dismissed(rootDir, user string) ([]string, error) {
+	return loadDismissed(dismissedFile(rootDir, user))
+}
+
+// Dismiss hides the announcement with id from user. Dismissals of removed announcements are
+// forgotten on the way.
+func Dismiss(rootDir, user, id string) error {
+	unlock := mu.Lock("dismissed-announcements/" + user)
+	defer unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(all))
+	found := false
+	for _, a := range all {
+		exists[a.ID] = true
+		if a.ID == id {
+			if !a.Dismissible {
+				return ErrNotDismissible
+			}
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	file := dismissedFile(rootDir, user)
+	dismissed, err := loadDismissed(file)
+	if err != nil {
+		return err
+	}
+	kept := []string{id}
+	for _, other := range dismissed {
+		if other != id && exists[other] {
+			kept = append(kept, other)
+		}
+	}
+	sort.Strings(kept)
+
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(file, data, 0644)
+}
+
+// DeleteUser forgets the announcements user dismissed
+func DeleteUser(rootDir, user string) error {
+	unlock := mu.Lock("dismissed-announcements/" + user)
+	defer unlock()
+
+	if err := os.Remove(dismissedFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func loadDismissed(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dismissed []string
+	if err := json.Unmarshal(data, &dismissed); err != nil {
+		return nil, err
+	}
+	return dismissed, nil
+}
+
+func load(rootDir string) ([]Announcement, error) {
+	data, err := os.ReadFile(filepath.Join(rootDir, announcementsFile))
+	if os.IsNotExist(err) {
+		return []Announcement{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []Announcement
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func save(rootDir string, all []Announcement) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(rootDir, announcementsFile), data, 0644)
+}
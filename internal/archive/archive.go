@@ -0,0 +1,167 @@
+// Package archive keeps the pages marked as archived. Archived pages and the
+// pages below them stay readable, but drop out of navigation and search by
+// default and can't be edited until they are unarchived.
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/filecache"
+	"wiki-go/internal/store"
+)
+
+// archiveFile is stored in the data directory
+const archiveFile = "archive.json"
+
+// Entry is an archived page
+type Entry struct {
+	Path string    `json:"path"` // Page path without slashes around it
+	By   string    `json:"by"`
+	At   time.Time `json:"at"`
+}
+
+// List holds the archived pages
+type List struct {
+	Entries []Entry `json:"entries"`
+}
+
+var (
+	mu    store.DataFile // Guards changes; the list is read without it, since the file is replaced at once
+	cache filecache.Cache[*List]
+)
+
+// Clean returns path the way it is stored in the list
+func Clean(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// Load returns the archived pages, an empty list when none were archived
+func Load(rootDir string) (*List, error) {
+	return load(rootDir)
+}
+
+// Archived returns the entry archiving the page at path: its own, or the one of
+// the closest archived page above it
+func (l *List) Archived(path string) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	path = Clean(path)
+	var found Entry
+	ok := false
+	for _, entry := range l.Entries {
+		if path == entry.Path || strings.HasPrefix(path, entry.Path+"/") {
+			if !ok || len(entry.Path) > len(found.Path) {
+				found, ok = entry, true
+			}
+		}
+	}
+	return found, ok
+}
+
+// Empty reports whether no page is archived
+func (l *List) Empty() bool {
+	return l == nil || len(l.Entries) == 0
+}
+
+// Set archives the page at path and everything below it, or unarchives it when
+// archived is false. Unarchiving only removes the entry of the page itself.
+func Set(rootDir, path, user string, archived bool) error {
+	unlock := mu.Lock(archiveFile)
+	defer unlock()
+
+	cache.Forget(filepath.Join(rootDir, archiveFile))
+	list, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	path = Clean(path)
+
+	entries := make([]Entry, 0, len(list.Entries)+1)
+	for _, entry := range list.Entries {
+		if entry.Path != path {
+			entries = append(entries, entry)
+		}
+	}
+	if archived {
+		entries = append(entries, Entry{Path: path, By: user, At: time.Now()})
+	}
+	if len(entries) == 0 {
+		cache.Forget(filepath.Join(rootDir, archiveFile))
+		if err := os.Remove(filepath.Join(rootDir, archiveFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return save(rootDir, &List{Entries: entries})
+}
+
+// Rename moves the entries of the page at from and below it to to, after the
+// page was moved
+func Rename(rootDir, from, to string) error {
+	unlock := mu.Lock(archiveFile)
+	defer unlock()
+
+	cache.Forget(filepath.Join(rootDir, archiveFile))
+	list, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	from, to = Clean(from), Clean(to)
+
+	changed := false
+	entries := make([]Entry, len(list.Entries))
+	for i, entry := range list.Entries {
+		if entry.Path == from || strings.HasPrefix(entry.Path, from+"/") {
+			entry.Path = to + strings.TrimPrefix(entry.Path, from)
+			changed = true
+		}
+		entries[i] = entry
+	}
+	if !changed {
+		return nil
+	}
+	return save(rootDir, &List{Entries: entries})
+}
+
+// load reads the list, from the cache while the file is unchanged. Changes
+// forget the cache first, another instance may have saved the list within the
+// resolution of the file time.
+func load(rootDir string) (*List, error) {
+	list, err := cache.Load(filepath.Join(rootDir, archiveFile), func(data []byte) (*List, error) {
+		var list List
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		return &list, nil
+	})
+	if os.IsNotExist(err) {
+		return &List{}, nil
+	}
+	return list, err
+}
+
+func save(rootDir string, list *List) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(rootDir, archiveFile)
+	if err := store.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	// Writes within the resolution of the file time would keep the old list
+	cache.Forget(path)
+	return nil
+}
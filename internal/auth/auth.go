@@ -3,24 +3,16 @@ package auth
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"log"
 	"net/http"
-	"sync"
 	"time"
 	"wiki-go/internal/config"
 	"wiki-go/internal/crypto"
+	"wiki-go/internal/store"
 )
 
 // Session represents a user session
-type Session struct {
-	Username  string
-	Role      string  // User role: "admin", "editor", or "viewer"
-	CreatedAt time.Time
-}
-
-var (
-	sessions = make(map[string]Session)
-	mu       sync.RWMutex
-)
+type Session = store.Session
 
 // GenerateSessionToken generates a random session token
 func GenerateSessionToken() (string, error) {
@@ -34,25 +26,39 @@ func GenerateSessionToken() (string, error) {
 
 // CreateSession creates a new session for the user
 func CreateSession(w http.ResponseWriter, username string, role string, keepLoggedIn bool, cfg *config.Config) error {
-	token, err := GenerateSessionToken()
+	// Set cookie expiration time based on keepLoggedIn flag
+	maxAge := 3600 * 24 // 24 hours by default
+	if keepLoggedIn {
+		maxAge = 3600 * 24 * 30 // 30 days for persistent login
+	}
+
+	token, err := storeSession(Session{Username: username, Role: role}, maxAge)
 	if err != nil {
 		return err
 	}
 
-	mu.Lock()
-	sessions[token] = Session{
-		Username:  username,
-		Role:      role,
-		CreatedAt: time.Now(),
+	setSessionCookies(w, token, username, maxAge, cfg)
+	return nil
+}
+
+// storeSession registers a session valid for maxAge seconds and returns its token
+func storeSession(session Session, maxAge int) (string, error) {
+	token, err := GenerateSessionToken()
+	if err != nil {
+		return "", err
 	}
-	mu.Unlock()
 
-	// Set cookie expiration time based on keepLoggedIn flag
-	maxAge := 3600 * 24 // 24 hours by default
-	if keepLoggedIn {
-		maxAge = 3600 * 24 * 30 // 30 days for persistent login
+	session.CreatedAt = time.Now()
+	session.ExpiresAt = session.CreatedAt.Add(time.Duration(maxAge) * time.Second)
+	if err := store.Current.SaveSession(token, session); err != nil {
+		return "", err
 	}
 
+	return token, nil
+}
+
+// setSessionCookies sets the session token and username cookies
+func setSessionCookies(w http.ResponseWriter, token, username string, maxAge int, cfg *config.Config) {
 	// Set the secure HTTP-only session token cookie
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
@@ -74,8 +80,6 @@ func CreateSession(w http.ResponseWriter, username string, role string, keepLogg
 		SameSite: http.SameSiteStrictMode,
 		MaxAge:   maxAge,
 	})
-
-	return nil
 }
 
 // GetSession retrieves the session for the current request
@@ -85,18 +89,13 @@ func GetSession(r *http.Request) *Session {
 		return nil
 	}
 
-	mu.RLock()
-	session, exists := sessions[c.Value]
-	mu.RUnlock()
-
-	if !exists {
+	session, err := store.Current.GetSession(c.Value)
+	if err != nil {
+		log.Printf("Error loading session: %v", err)
 		return nil
 	}
 
-	// Session expiration is now handled by cookie expiration time
-	// which is set in CreateSession based on the keepLoggedIn parameter
-
-	return &session
+	return session
 }
 
 // ClearSession removes the session from the sessions map and clears the cookie
@@ -106,9 +105,9 @@ func ClearSession(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
-	mu.Lock()
-	delete(sessions, c.Value)
-	mu.Unlock()
+	if err := store.Current.DeleteSession(c.Value); err != nil {
+		log.Printf("Error deleting session: %v", err)
+	}
 
 	// Clear the session token cookie
 	http.SetCookie(w, &http.Cookie{
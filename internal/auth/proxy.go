@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/crypto"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/store"
+)
+
+// proxySessionMaxAge is the lifetime of sessions created from proxy headers. The
+// proxy sends the headers with every request, so an expired session is simply renewed.
+const proxySessionMaxAge = 3600 * 24
+
+var (
+	// proxyTokens maps usernames to the session created for them from proxy headers,
+	// so a proxy that drops our cookie doesn't create a new session on every request
+	proxyTokens   = make(map[string]string)
+	proxyTokensMu sync.Mutex
+
+	// configPath is the configuration file provisioned users are saved to
+	configPath = config.ConfigFilePath
+)
+
+// ProxyAuthMiddleware logs in the user named in the headers of a trusted
+// authenticating reverse proxy. Headers from other clients are ignored, so they
+// can't be used to impersonate users.
+func ProxyAuthMiddleware(next http.Handler, cfg *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy := cfg.Security.ProxyAuth
+		if !proxy.Enabled || !isTrustedProxy(r.RemoteAddr, proxy.TrustedProxies) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		username := strings.TrimSpace(r.Header.Get(proxy.UserHeader))
+		if username == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		groups := splitList(r.Header.Get(proxy.GroupsHeader))
+
+		role, ok := proxyUserRole(cfg, username, groups)
+		if !ok {
+			log.Printf("Proxy authentication: unknown user %q and auto-provisioning is disabled", username)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if session := GetSession(r); session != nil && session.Username == username && session.Role == role &&
+			strings.Join(session.Groups, ",") == strings.Join(groups, ",") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := proxySessionToken(username, role, groups)
+		if err != nil {
+			log.Printf("Proxy authentication: error creating session for %s: %v", username, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setSessionCookies(w, token, username, proxySessionMaxAge, cfg)
+		replaceSessionCookie(r, token)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// proxySessionToken returns the session of a proxy user, reusing the previous one
+// while it is still valid for the same role and groups
+func proxySessionToken(username, role string, groups []string) (string, error) {
+	proxyTokensMu.Lock()
+	token, exists := proxyTokens[username]
+	proxyTokensMu.Unlock()
+
+	if exists {
+		session, err := store.Current.GetSession(token)
+		if err != nil {
+			return "", err
+		}
+		if session != nil && session.Role == role && strings.Join(session.Groups, ",") == strings.Join(groups, ",") {
+			return token, nil
+		}
+	}
+
+	token, err := storeSession(Session{Username: username, Role: role, Groups: groups}, proxySessionMaxAge)
+	if err != nil {
+		return "", err
+	}
+
+	proxyTokensMu.Lock()
+	proxyTokens[username] = token
+	proxyTokensMu.Unlock()
+
+	return token, nil
+}
+
+// replaceSessionCookie makes the rest of the request see the new session token
+func replaceSessionCookie(r *http.Request, token string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != "session_token" {
+			r.AddCookie(c)
+		}
+	}
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+}
+
+// proxyUserRole returns the role of a user authenticated by the proxy, creating
+// the account when auto-provisioning is enabled. When the proxy sends groups
+// mapped to a role, they decide the role on every login.
+func proxyUserRole(cfg *config.Config, username string, groups []string) (string, bool) {
+	proxy := cfg.Security.ProxyAuth
+	mapped := mapGroupsToRole(groups, proxy.AdminGroups, proxy.EditorGroups)
+
+	// Known users don't wait for the changes made by other instances
+	config.LockLocal()
+	role, ok := proxyExistingRole(cfg, username, mapped)
+	config.UnlockLocal()
+	if ok || !proxy.AutoProvision {
+		return role, ok
+	}
+
+	config.Lock()
+	defer config.Unlock()
+
+	// Another request may have provisioned the user meanwhile
+	if role, ok := proxyExistingRole(cfg, username, mapped); ok {
+		return role, true
+	}
+
+	role = mapped
+	if role == "" {
+		role = proxy.DefaultRole
+	}
+	if role != roles.RoleAdmin && role != roles.RoleEditor {
+		role = roles.RoleViewer
+	}
+
+	// Provisioned accounts get a random password, they can only log in through the proxy
+	password, err := GenerateSessionToken()
+	if err != nil {
+		log.Printf("Proxy authentication: error generating password for %s: %v", username, err)
+		return "", false
+	}
+	hashedPassword, err := crypto.HashPassword(password)
+	if err != nil {
+		log.Printf("Proxy authentication: error hashing password for %s: %v", username, err)
+		return "", false
+	}
+
+	cfg.Users = append(cfg.Users, config.User{
+		Username: username,
+		Password: hashedPassword,
+		Role:     role,
+	})
+	if err := config.WriteFile(configPath, cfg); err != nil {
+		log.Printf("Proxy authentication: error saving provisioned user %s: %v", username, err)
+	} else {
+		log.Printf("Proxy authentication: provisioned user %s with role %s", username, role)
+	}
+
+	return role, true
+}
+
+// proxyExistingRole returns the role of a user who already has an account, while
+// the configuration is locked
+func proxyExistingRole(cfg *config.Config, username, mapped string) (string, bool) {
+	for _, user := range cfg.Users {
+		if user.Username == username {
+			if mapped != "" {
+				return mapped, true
+			}
+			return user.Role, true
+		}
+	}
+	return "", false
+}
+
+// mapGroupsToRole returns the role mapped to the highest ranked group, or "" if
+// none of the groups is mapped
+func mapGroupsToRole(groups []string, adminGroups, editorGroups string) string {
+	if containsAny(groups, splitList(adminGroups)) {
+		return roles.RoleAdmin
+	}
+	if containsAny(groups, splitList(editorGroups)) {
+		return roles.RoleEditor
+	}
+	return ""
+}
+
+// isTrustedProxy reports whether remoteAddr is one of the trusted IPs or CIDR ranges
+func isTrustedProxy(remoteAddr, trusted string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range splitList(trusted) {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if trustedIP := net.ParseIP(entry); trustedIP != nil && trustedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// containsAny reports whether any of values is in list
+func containsAny(list, values []string) bool {
+	for _, value := range values {
+		for _, item := range list {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/roles"
+)
+
+func TestProxyAuthConcurrentFirstLogins(t *testing.T) {
+	previous := configPath
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	defer func() { configPath = previous }()
+
+	cfg := &config.Config{}
+	cfg.Security.ProxyAuth.Enabled = true
+	cfg.Security.ProxyAuth.TrustedProxies = "127.0.0.1"
+	cfg.Security.ProxyAuth.UserHeader = "Remote-User"
+	cfg.Security.ProxyAuth.GroupsHeader = "Remote-Groups"
+	cfg.Security.ProxyAuth.AutoProvision = true
+	cfg.Security.ProxyAuth.DefaultRole = roles.RoleViewer
+	cfg.Security.ProxyAuth.AdminGroups = "wiki-admins"
+
+	var mu sync.Mutex
+	seen := make(map[string]string)
+	handler := ProxyAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session := GetSession(r); session != nil {
+			mu.Lock()
+			seen[session.Username] = session.Role
+			mu.Unlock()
+		}
+	}), cfg)
+
+	const users = 8
+	const loginsPerUser = 4
+	var wg sync.WaitGroup
+	for i := 0; i < users*loginsPerUser; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "127.0.0.1:40000"
+			r.Header.Set("Remote-User", fmt.Sprintf("user%d", i%users))
+			if i%users == 0 {
+				r.Header.Set("Remote-Groups", "wiki-admins")
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+		}(i)
+	}
+	wg.Wait()
+
+	config.Lock()
+	defer config.Unlock()
+	if len(cfg.Users) != users {
+		t.Fatalf("provisioned %d users, want %d", len(cfg.Users), users)
+	}
+	for i := 0; i < users; i++ {
+		name := fmt.Sprintf("user%d", i)
+		want := roles.RoleViewer
+		if i == 0 {
+			want = roles.RoleAdmin
+		}
+		if seen[name] != want {
+			t.Errorf("session role of %s = %q, want %q", name, seen[name], want)
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < users; i++ {
+		if !strings.Contains(string(data), fmt.Sprintf("username: user%d\n", i)) {
+			t.Errorf("saved configuration misses user%d", i)
+		}
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("configuration permissions = %o, want 600", perm)
+	}
+	if matches, _ := filepath.Glob(configPath + ".*.tmp"); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
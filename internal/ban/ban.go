@@ -53,6 +53,52 @@ type BanList struct {
     mu       sync.Mutex
     entries  map[string]*Attempt
     filePath string
+    backend  Backend
+}
+
+// Backend keeps attempts in a store shared by several instances of the wiki, so a
+// ban applies whichever instance handles the next login.
+type Backend interface {
+    LoadAttempt(key string) (*Attempt, error) // nil when there is none
+    SaveAttempt(key string, at Attempt) error
+    DeleteAttempt(key string) error
+}
+
+// SetBackend makes the list read and write attempts through backend instead of
+// the local file.
+func (b *BanList) SetBackend(backend Backend) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.backend = backend
+}
+
+// lookup returns the attempt for key, refreshed from the backend when one is set.
+// Backend errors fall back to the local copy.
+func (b *BanList) lookup(key string) (*Attempt, bool) {
+    if b.backend != nil {
+        if at, err := b.backend.LoadAttempt(key); err == nil {
+            if at != nil {
+                b.entries[key] = at
+            } else {
+                delete(b.entries, key)
+            }
+        }
+    }
+    at, ok := b.entries[key]
+    return at, ok
+}
+
+// persist saves the attempt for key to the backend, or the whole list to the file.
+func (b *BanList) persist(key string) {
+    if b.backend == nil {
+        b.persistAsync()
+        return
+    }
+    if at, ok := b.entries[key]; ok && at != nil {
+        _ = b.backend.SaveAttempt(key, *at)
+    } else {
+        _ = b.backend.DeleteAttempt(key)
+    }
 }
 
 // NewBanList loads the ban list from disk (if present) and returns a ready-to-use instance.
@@ -93,7 +139,7 @@ func (b *BanList) IsBanned(key string) time.Duration {
     b.mu.Lock()
     defer b.mu.Unlock()
 
-    at, ok := b.entries[key]
+    at, ok := b.lookup(key)
     if !ok || at == nil {
         return 0
     }
@@ -111,7 +157,7 @@ func (b *BanList) IsBanned(key string) time.Duration {
         // Do NOT reset BanLen here – keeps exponential growth.
         at.Fails = 0
         at.FirstFail = 0
-        b.persistAsync()
+        b.persist(key)
     }
     return 0
 }
@@ -123,7 +169,7 @@ func (b *BanList) RegisterFailure(key string) (time.Duration, bool) {
     defer b.mu.Unlock()
 
     now := time.Now()
-    at, ok := b.entries[key]
+    at, ok := b.lookup(key)
     if !ok {
         at = &Attempt{}
         b.entries[key] = at
@@ -163,11 +209,11 @@ func (b *BanList) RegisterFailure(key string) (time.Duration, bool) {
         at.Fails = 0
         at.FirstFail = 0
 
-        b.persistAsync()
+        b.persist(key)
         return time.Duration(at.BanLen) * time.Second, true
     }
 
-    b.persistAsync()
+    b.persist(key)
     return 0, false
 }
 
@@ -177,7 +223,19 @@ func (b *BanList) Clear(key string) {
     defer b.mu.Unlock()
 
     delete(b.entries, key)
-    b.persistAsync()
+    b.persist(key)
+}
+
+// Usage returns the number of keys held in memory and their estimated size.
+func (b *BanList) Usage() (int, int64) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    size := int64(0)
+    for k := range b.entries {
+        size += int64(len(k)) + 64
+    }
+    return len(b.entries), size
 }
 
 // persistAsync serialises the entries map in a goroutine so we don't block callers.
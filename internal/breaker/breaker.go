@@ -0,0 +1,253 @@
+package breaker
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of a breaker
+const (
+	StateClosed   = "closed"    // Calls go through
+	StateOpen     = "open"      // Calls fail right away until the cooldown is over
+	StateHalfOpen = "half-open" // One trial call decides whether to close again
+)
+
+// ErrOpen is returned without calling the service while its breaker is open
+var ErrOpen = errors.New("service is unavailable, retrying later")
+
+// ErrBusy is returned when the service already has the maximum number of calls running
+var ErrBusy = errors.New("too many requests to the service are running")
+
+// Options configures a breaker
+type Options struct {
+	Threshold     int           // Consecutive failures that open the breaker
+	Cooldown      time.Duration // Time an open breaker waits before a trial call
+	MaxConcurrent int           // Calls running at the same time, 0 for no limit
+}
+
+// Status describes a breaker for the diagnostics page
+type Status struct {
+	Name        string    `json:"name"`
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"` // Consecutive failures
+	Calls       int64     `json:"calls"`
+	Errors      int64     `json:"errors"`
+	Running     int       `json:"running"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastFailure time.Time `json:"lastFailure,omitzero"`
+	RetryAt     time.Time `json:"retryAt,omitzero"` // When an open breaker allows a trial call
+}
+
+// Breaker stops calling a failing service for a while, so requests fail fast
+// instead of piling up waiting for timeouts
+type Breaker struct {
+	name    string
+	options Options
+
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	trial       bool // A half-open trial call is running
+	running     int
+	calls       int64
+	errors      int64
+	lastError   string
+	lastFailure time.Time
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Breaker)
+)
+
+// New returns a breaker for the named service and lists it in Statuses. Services
+// are registered once, calling New again with the same name returns the same
+// breaker.
+func New(name string, options Options) *Breaker {
+	if options.Threshold <= 0 {
+		options.Threshold = 5
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = 30 * time.Second
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if b, ok := registry[name]; ok {
+		return b
+	}
+	b := &Breaker{name: name, options: options, state: StateClosed}
+	registry[name] = b
+	return b
+}
+
+// Statuses returns the state of every breaker, sorted by name
+func Statuses() []Status {
+	registryMu.Lock()
+	list := make([]*Breaker, 0, len(registry))
+	for _, b := range registry {
+		list = append(list, b)
+	}
+	registryMu.Unlock()
+
+	statuses := make([]Status, 0, len(list))
+	for _, b := range list {
+		statuses = append(statuses, b.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Status returns the current state of the breaker
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := Status{
+		Name:        b.name,
+		State:       b.state,
+		Failures:    b.failures,
+		Calls:       b.calls,
+		Errors:      b.errors,
+		Running:     b.running,
+		LastError:   b.lastError,
+		LastFailure: b.lastFailure,
+	}
+	if b.state == StateOpen {
+		status.RetryAt = b.openedAt.Add(b.options.Cooldown)
+	}
+	return status
+}
+
+// Allow reports whether the service may be called now. After the cooldown of an
+// open breaker it allows a single trial call. Every allowed call must be followed
+// by Success or Failure.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.options.Cooldown {
+		b.state = StateHalfOpen
+		b.trial = false
+	}
+	switch b.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	b.calls++
+	return true
+}
+
+// Success records a successful call and closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = StateClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call. The breaker opens after Threshold consecutive
+// failures, or right away when a trial call fails.
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errors++
+	b.failures++
+	b.lastFailure = time.Now()
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == StateHalfOpen || b.failures >= b.options.Threshold {
+		b.state = StateOpen
+		b.openedAt = b.lastFailure
+	}
+	b.trial = false
+}
+
+// permanentError marks an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error of a call that reached the service but can't succeed,
+// such as an invalid request. It is neither retried nor counted as a failure.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Do calls fn up to attempts times, waiting a random, growing delay between
+// attempts, as long as the breaker allows it. The last error is returned.
+func (b *Breaker) Do(attempts int, fn func() error) error {
+	if !b.acquire() {
+		return ErrBusy
+	}
+	defer b.release()
+
+	if attempts <= 0 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(attempt))
+		}
+		if !b.Allow() {
+			if err == nil {
+				err = ErrOpen
+			}
+			return err
+		}
+
+		err = fn()
+		var permanent permanentError
+		switch {
+		case err == nil:
+			b.Success()
+			return nil
+		case errors.As(err, &permanent):
+			b.Success()
+			return permanent.err
+		}
+		b.Failure(err)
+	}
+	return err
+}
+
+// acquire reserves a place for a call when the number of running calls is limited
+func (b *Breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.options.MaxConcurrent > 0 && b.running >= b.options.MaxConcurrent {
+		return false
+	}
+	b.running++
+	return true
+}
+
+func (b *Breaker) release() {
+	b.mu.Lock()
+	b.running--
+	b.mu.Unlock()
+}
+
+// backoff returns the delay before a retry: a random duration up to 200 ms,
+// doubling with each attempt, so clients don't retry in lockstep
+func backoff(attempt int) time.Duration {
+	limit := 200 * time.Millisecond << (attempt - 1)
+	if limit > 5*time.Second {
+		limit = 5 * time.Second
+	}
+	return time.Duration(rand.Int63n(int64(limit)) + 1)
+}
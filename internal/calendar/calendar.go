@@ -0,0 +1,131 @@
+// Package calendar writes iCalendar feeds (RFC 5545) of the dates found in
+// pages, like documented events, due dates and review deadlines, for calendar
+// apps to subscribe to.
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DateFormat is the format of dates that last a whole day
+const DateFormat = "2006-01-02"
+
+// dateTimeFormats are the formats accepted for dates with a time, tried in order
+var dateTimeFormats = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// journalRegex matches page names starting with a date, like 2025-03-14 or
+// 2025-03-14-standup
+var journalRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})(\b|_)`)
+
+// Event is a single entry of a feed
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Categories  []string
+	Start       time.Time
+	End         time.Time // Exclusive, the day after the last one for whole days
+	AllDay      bool
+	Modified    time.Time
+}
+
+// ParseDate parses a date written in frontmatter: 2006-01-02 for a whole day,
+// or a date with a time like "2006-01-02 15:04" or RFC 3339. Times without a
+// zone are in loc.
+func ParseDate(value string, loc *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation(DateFormat, value, loc); err == nil {
+		return t, true, nil
+	}
+	for _, format := range dateTimeFormats {
+		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+}
+
+// JournalDate returns the date a journal page is named after, the last part of
+// its path starting with a date
+func JournalDate(path string, loc *time.Location) (time.Time, bool) {
+	name := path[strings.LastIndex(path, "/")+1:]
+	match := journalRegex.FindStringSubmatch(name)
+	if match == nil {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(DateFormat, match[1], loc)
+	return t, err == nil
+}
+
+// Write writes the events as an iCalendar feed called name
+func Write(w io.Writer, name string, events []Event) error {
+	out := bufio.NewWriter(w)
+	line := func(text string) {
+		writeFolded(out, text)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Wiki-Go//Calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeText(name))
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + escapeText(event.UID))
+		line("DTSTAMP:" + event.Modified.UTC().Format("20060102T150405Z"))
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE:" + event.Start.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + event.End.Format("20060102"))
+		} else {
+			line("DTSTART:" + event.Start.UTC().Format("20060102T150405Z"))
+			line("DTEND:" + event.End.UTC().Format("20060102T150405Z"))
+		}
+		line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		if event.URL != "" {
+			line("URL:" + event.URL)
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escapeText(category)
+			}
+			line("CATEGORIES:" + strings.Join(categories, ","))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return out.Flush()
+}
+
+// escapeText escapes the characters with a meaning in iCalendar text values
+func escapeText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(text)
+}
+
+// writeFolded writes a content line ended by CRLF, folded into lines of at
+// most 75 bytes without splitting characters
+func writeFolded(out *bufio.Writer, text string) {
+	limit := 75
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		out.WriteString(text[:cut] + "\r\n ")
+		text = text[cut:]
+		// Continuation lines start with a space, which counts
+		limit = 74
+	}
+	out.WriteString(text + "\r\n")
+}
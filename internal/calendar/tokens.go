@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"wiki-go/internal/store"
+)
+
+// tokensFile in the data directory holds the generation of the feed tokens of
+// each user. Tokens of an older generation than the current one are revoked.
+const tokensFile = "calendar-tokens.json"
+
+// tokensMu guards revocations; generations are read without it, since the
+// file is replaced at once
+var tokensMu store.DataFile
+
+// TokenGeneration returns the current generation of the feed tokens of user
+func TokenGeneration(rootDir, user string) (int, error) {
+	generations, err := loadGenerations(rootDir)
+	return generations[user], err
+}
+
+// RevokeTokens revokes the feed tokens of user issued so far and returns the
+// generation of the tokens issued from now on. Deleted users keep their entry,
+// so a new account of the same name doesn't bring their tokens back.
+func RevokeTokens(rootDir, user string) (int, error) {
+	unlock := tokensMu.Lock(tokensFile)
+	defer unlock()
+	generations, err := loadGenerations(rootDir)
+	if err != nil {
+		return 0, err
+	}
+	generations[user]++
+	data, err := json.MarshalIndent(generations, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return 0, err
+	}
+	return generations[user], store.WriteFile(filepath.Join(rootDir, tokensFile), data, 0600)
+}
+
+func loadGenerations(rootDir string) (map[string]int, error) {
+	generations := make(map[string]int)
+	data, err := os.ReadFile(filepath.Join(rootDir, tokensFile))
+	if os.IsNotExist(err) {
+		return generations, nil
+	}
+	if err != nil {
+		return generations, err
+	}
+	if err := json.Unmarshal(data, &generations); err != nil {
+		return make(map[string]int), err
+	}
+	return generations, nil
+}
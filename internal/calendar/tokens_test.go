@@ -0,0 +1,47 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRevokeTokens(t *testing.T) {
+	rootDir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		revoke string
+		user   string
+		want   int
+	}{
+		{name: "Users who never revoked", user: "alice", want: 0},
+		{name: "Revoking moves to the next generation", revoke: "alice", user: "alice", want: 1},
+		{name: "Again", revoke: "alice", user: "alice", want: 2},
+		{name: "Other users are left alone", revoke: "bob", user: "alice", want: 2},
+		{name: "Of the other user", user: "bob", want: 1},
+	}
+
+	for _, tt := range tests {
+		if tt.revoke != "" {
+			if _, err := RevokeTokens(rootDir, tt.revoke); err != nil {
+				t.Fatalf("%s: RevokeTokens() error = %v", tt.name, err)
+			}
+		}
+		got, err := TokenGeneration(rootDir, tt.user)
+		if err != nil {
+			t.Fatalf("%s: TokenGeneration() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: TokenGeneration(%s) = %d, want %d", tt.name, tt.user, got, tt.want)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(rootDir, tokensFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("%s permissions = %o, want 600", tokensFile, perm)
+	}
+}
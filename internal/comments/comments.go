@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -119,6 +120,97 @@ func DeleteComment(commentID string, documentPath string, userIsAdmin bool) erro
 	return os.Remove(commentPath)
 }
 
+// Authored is a comment along with the document it was written on
+type Authored struct {
+	DocumentPath string
+	Comment
+}
+
+// ByAuthor returns the comments written by username on every document
+func ByAuthor(username string) ([]Authored, error) {
+	var list []Authored
+	err := walkAuthor(username, func(documentPath, id string) error {
+		content, err := os.ReadFile(filepath.Join("data/comments", documentPath, id))
+		if err != nil {
+			return err
+		}
+		timestamp := id[:14]
+		list = append(list, Authored{
+			DocumentPath: documentPath,
+			Comment: Comment{
+				ID:            id,
+				Author:        username,
+				Timestamp:     timestamp,
+				TimestampUnix: parseTimestampToUnix(timestamp),
+				Content:       string(content),
+				FormattedTime: FormatCommentTime(timestamp),
+			},
+		})
+		return nil
+	})
+	return list, err
+}
+
+// Reattribute credits the comments of username to another name, or deletes
+// them when to is empty. It returns the number of changed comments.
+func Reattribute(username, to string) (int, error) {
+	changed := 0
+	err := walkAuthor(username, func(documentPath, id string) error {
+		path := filepath.Join("data/comments", documentPath, id)
+		if to == "" {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			changed++
+			return nil
+		}
+
+		// A comment of the same name posted in the same second moves this one a second later
+		stamp, _ := time.Parse("20060102150405", id[:14])
+		target := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s_%s.md", id[:14], sanitizeUsername(to)))
+		for {
+			if _, err := os.Stat(target); os.IsNotExist(err) {
+				break
+			}
+			stamp = stamp.Add(time.Second)
+			target = filepath.Join(filepath.Dir(path), fmt.Sprintf("%s_%s.md", stamp.Format("20060102150405"), sanitizeUsername(to)))
+		}
+		if err := os.Rename(path, target); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	})
+	return changed, err
+}
+
+// walkAuthor calls fn for every comment written by username
+func walkAuthor(username string, fn func(documentPath, id string) error) error {
+	suffix := "_" + sanitizeUsername(username) + ".md"
+	root := "data/comments"
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		id := entry.Name()
+		if entry.IsDir() || !isValidCommentID(id) || id[14:] != suffix {
+			return nil
+		}
+		documentPath, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if documentPath == "." {
+			documentPath = ""
+		}
+		return fn(filepath.ToSlash(documentPath), id)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 // Helper function to validate comment ID format (timestamp_username.md)
 func isValidCommentID(id string) bool {
 	// Check file extension
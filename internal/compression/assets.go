@@ -0,0 +1,115 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"wiki-go/internal/diagnostics"
+
+	"github.com/andybalholm/brotli"
+)
+
+// largeAsset is the size above which assets are compressed at a lower brotli
+// quality, the best one takes seconds for the biggest libraries
+const largeAsset = 1 << 20
+
+// variant is an embedded file compressed with one encoding
+type variant struct {
+	once sync.Once
+	data []byte
+	etag string
+	err  error
+}
+
+var variants sync.Map // name + "\x00" + encoding -> *variant
+
+func init() {
+	diagnostics.Register("compressed assets", func() (int, int64) {
+		var items int
+		var size int64
+		variants.Range(func(_, value any) bool {
+			v := value.(*variant)
+			items++
+			size += int64(len(v.data))
+			return true
+		})
+		return items, size
+	})
+}
+
+// ServeAsset serves the embedded file name from fsys compressed with the
+// encoding the client prefers. Each file is compressed once, on its first
+// request, and kept in memory. It returns false without writing anything when
+// the file can't be served compressed, e.g. a directory, an image, a range
+// request or a client accepting no encoding.
+func ServeAsset(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if !Compressible(contentType) {
+		return false
+	}
+	encoding := Negotiate(r)
+	if encoding == "" || r.Header.Get("Range") != "" {
+		return false
+	}
+	if info, err := fs.Stat(fsys, name); err != nil || info.IsDir() || info.Size() < minSize {
+		return false
+	}
+
+	value, _ := variants.LoadOrStore(name+"\x00"+encoding, &variant{})
+	v := value.(*variant)
+	v.once.Do(func() {
+		v.data, v.err = compressFile(fsys, name, encoding)
+		if v.err == nil {
+			sum := sha256.Sum256(v.data)
+			v.etag = `"` + hex.EncodeToString(sum[:8]) + "-" + encoding + `"`
+		}
+	})
+	if v.err != nil {
+		return false
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Encoding", encoding)
+	header.Add("Vary", "Accept-Encoding")
+	header.Set("ETag", v.etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(v.data))
+	return true
+}
+
+// compressFile reads and compresses an embedded file
+func compressFile(fsys fs.FS, name, encoding string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var encoder io.WriteCloser
+	switch encoding {
+	case Brotli:
+		quality := brotli.BestCompression
+		if len(data) > largeAsset {
+			quality = 9
+		}
+		encoder = brotli.NewWriterLevel(&buf, quality)
+	default:
+		encoder, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	}
+	if _, err := encoder.Write(data); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,270 @@
+package compression
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodings offered to clients, in order of preference
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// minSize is the body size below which compressing isn't worth it
+const minSize = 1024
+
+// compressibleTypes are the content types worth compressing. Images, fonts and
+// archives are already compressed.
+var compressibleTypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/plain":             true,
+	"text/markdown":          true,
+	"text/xml":               true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"application/json":       true,
+	"application/xml":        true,
+	"application/rss+xml":    true,
+	"application/atom+xml":   true,
+	"image/svg+xml":          true,
+}
+
+// Compressible reports whether responses of the given content type are compressed
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return compressibleTypes[mediaType]
+}
+
+// Negotiate returns the encoding to use for a request, or "" when the client
+// accepts neither brotli nor gzip
+func Negotiate(r *http.Request) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+	switch {
+	case accepted[Brotli]:
+		return Brotli
+	case accepted[Gzip]:
+		return Gzip
+	}
+	return ""
+}
+
+// Encoders are reused between responses, allocating them is expensive
+var (
+	brotliPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 5) }}
+	gzipPool   = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}
+)
+
+// Middleware compresses text responses for clients that accept brotli or gzip.
+// Responses that are small, already encoded, partial or of a type that doesn't
+// compress well are sent as they are.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := Negotiate(r)
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" ||
+			r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the start of the body until it knows whether the
+// response is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status      int
+	wroteHeader bool // The handler called WriteHeader
+	decided     bool // Headers were sent, with or without compression
+	buf         []byte
+	encoder     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	// Informational responses are sent right away, the final one follows
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" {
+		cw.start(false)
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < minSize {
+		cw.start(false)
+		return
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" && !Compressible(contentType) {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= minSize {
+		if err := cw.start(cw.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// compressible decides on the content type once the start of the body is known
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Type") == "" {
+		// Set it like net/http would, the encoded body can't be sniffed
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	return Compressible(header.Get("Content-Type"))
+}
+
+// start sends the headers and the buffered start of the body
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if compress {
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", cw.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		// The encoded body differs from the one the tag was computed for
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		switch cw.encoding {
+		case Brotli:
+			encoder := brotliPool.Get().(*brotli.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		case Gzip:
+			encoder := gzipPool.Get().(*gzip.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		}
+	}
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends what is still buffered and finishes the encoded stream
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// Handlers writing nothing leave the response to net/http
+		if !cw.wroteHeader && len(cw.buf) == 0 {
+			return
+		}
+		cw.start(false)
+	}
+	if cw.encoder == nil {
+		return
+	}
+	cw.encoder.Close()
+	switch encoder := cw.encoder.(type) {
+	case *brotli.Writer:
+		encoder.Reset(nil)
+		brotliPool.Put(encoder)
+	case *gzip.Writer:
+		encoder.Reset(nil)
+		gzipPool.Put(encoder)
+	}
+	cw.encoder = nil
+}
+
+// Flush sends what was written so far, deciding on compression if needed
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.start(len(cw.buf) > 0 && cw.compressible())
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over when nothing was sent yet
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok || cw.decided {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	cw.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -9,8 +9,6 @@ import (
 	"strings"
 	"wiki-go/internal/crypto"
 	"wiki-go/internal/roles"
-
-	"gopkg.in/yaml.v3"
 )
 
 // ConfigFilePath defines the global path to the configuration file
@@ -46,7 +44,29 @@ type Config struct {
 		SSL      bool   `yaml:"ssl"`
 		SSLCert  string `yaml:"ssl_cert"`
 		SSLKey   string `yaml:"ssl_key"`
+		// Protocols besides HTTP/1.1. HTTP/2 is used over TLS and, without TLS,
+		// by clients such as reverse proxies that speak it in cleartext (h2c).
+		// HTTP/3 runs over QUIC on the UDP port and requires TLS.
+		HTTP2     bool `yaml:"http2"`
+		HTTP3     bool `yaml:"http3"`
+		HTTP3Port int  `yaml:"http3_port"` // UDP port for HTTP/3, 0 for the server port
+
+		Pprof                bool `yaml:"pprof"`                  // Serve profiling data to admins under /debug/pprof/
+		RenderWorkers        int  `yaml:"render_workers"`         // Documents rendered at the same time, 0 for one per CPU
+		MaxConcurrentRenders int  `yaml:"max_concurrent_renders"` // Renders running or waiting before requests are refused, 0 for no limit
+		Compression          bool `yaml:"compression"`            // Compress responses with brotli or gzip
+		// Public URL the wiki is reached at, e.g. "https://wiki.example.com".
+		// Passkeys are bound to its host name.
+		URL string `yaml:"url"`
 	} `yaml:"server"`
+	Database struct {
+		Driver string `yaml:"driver"` // Where sessions, login attempts and shared locks are kept: "memory", "sqlite", "postgres" or "redis"
+		URL    string `yaml:"url"`    // SQLite database file, or PostgreSQL or Redis connection URL
+	} `yaml:"database"`
+	Jobs struct {
+		Workers     int `yaml:"workers"`      // Number of background jobs run at the same time
+		MaxAttempts int `yaml:"max_attempts"` // Attempts before a failing job is marked as failed
+	} `yaml:"jobs"`
 	Wiki struct {
 		RootDir                   string `yaml:"root_dir"`
 		DocumentsDir              string `yaml:"documents_dir"`
@@ -63,6 +83,17 @@ type Config struct {
 		MaxVersions               int    `yaml:"max_versions"`
 		MaxUploadSize             int    `yaml:"max_upload_size"` // Maximum upload file size in MB
 		Language                  string `yaml:"language"`        // Default language for the wiki
+		ContentLanguages          string `yaml:"content_languages"` // Comma-separated languages pages are translated into
+		ADRDir                    string `yaml:"adr_dir"`         // Directory inside documents holding Architecture Decision Records
+		OfflineReading            bool   `yaml:"offline_reading"` // Keep visited pages in the browser for reading offline
+		AirGapped                 bool   `yaml:"air_gapped"`      // Load nothing from other sites and report at startup what still does
+		Lint                      string `yaml:"lint"`            // Markdown style checks: "off", "warn" or "block"
+		LinkPreviews              bool   `yaml:"link_previews"`   // Show a preview card when hovering links to other pages
+		SocialImages              bool   `yaml:"social_images"`   // Draw link card images for pages without one
+		SocialImageColor          string `yaml:"social_image_color"` // Background color of the drawn link card images
+		HighContrast              bool   `yaml:"high_contrast"`   // Use the high-contrast theme unless the reader picked another
+		UnderlineLinks            bool   `yaml:"underline_links"` // Always underline links in page content
+		UserDashboards            bool   `yaml:"user_dashboards"` // Let users arrange their own home page dashboard
 	} `yaml:"wiki"`
 	Users []User `yaml:"users"`
 	Security struct {
@@ -73,14 +104,126 @@ type Config struct {
 			InitialBanSeconds int  `yaml:"initial_ban_seconds"`
 			MaxBanSeconds     int  `yaml:"max_ban_seconds"`
 		} `yaml:"login_ban"`
+		ProxyAuth struct {
+			Enabled        bool   `yaml:"enabled"`
+			TrustedProxies string `yaml:"trusted_proxies"` // Comma-separated IPs or CIDR ranges allowed to set the headers
+			UserHeader     string `yaml:"user_header"`
+			GroupsHeader   string `yaml:"groups_header"`
+			AutoProvision  bool   `yaml:"auto_provision"` // Create accounts for unknown users
+			DefaultRole    string `yaml:"default_role"`
+			AdminGroups    string `yaml:"admin_groups"`  // Comma-separated groups mapped to the admin role
+			EditorGroups   string `yaml:"editor_groups"` // Comma-separated groups mapped to the editor role
+		} `yaml:"proxy_auth"`
+		Passkeys struct {
+			RequireForAdmins bool `yaml:"require_for_admins"` // Admins must confirm password logins with a passkey
+		} `yaml:"passkeys"`
+		Headers struct {
+			CSP            string `yaml:"csp"`             // "enforce", "report-only" or "off"
+			ImageSources   string `yaml:"img_sources"`     // Space-separated extra CSP sources for images
+			MediaSources   string `yaml:"media_sources"`   // Space-separated extra CSP sources for audio and video
+			FrameSources   string `yaml:"frame_sources"`   // Space-separated extra CSP sources for embedded frames
+			ConnectSources string `yaml:"connect_sources"` // Space-separated extra CSP sources for fetch requests
+			HSTSMaxAge     int    `yaml:"hsts_max_age"`    // Strict-Transport-Security max-age in seconds, 0 disables it
+			FrameOptions   string `yaml:"frame_options"`   // X-Frame-Options: "SAMEORIGIN" or "DENY"
+			EmbedAncestors string `yaml:"embed_ancestors"` // Space-separated origins that may frame /embed pages, "*" for any
+			ReferrerPolicy string `yaml:"referrer_policy"`
+		} `yaml:"headers"`
+		Sanitizer struct {
+			TrustedEditors bool   `yaml:"trusted_editors"` // Render raw HTML in pages as written, without sanitizing
+			AllowElements  string `yaml:"allow_elements"`  // Extra elements allowed in pages, e.g. "abbr, iframe[src|width|height]"
+		} `yaml:"sanitizer"`
+		Encryption struct {
+			Directories string `yaml:"directories"`  // Comma-separated directories whose pages and attachments are encrypted on disk
+			Key         string `yaml:"key"`          // Base64 encoded 256-bit key, best written as a ${secret:...} reference
+			KeyCommand  string `yaml:"key_command"`  // Command printing the key instead, e.g. to fetch it from a KMS
+		} `yaml:"encryption"`
 	} `yaml:"security"`
 	Extensions struct {
 		PlantUML struct {
-			Enable      bool   `yaml:"enable"`
-			ServerURL   string `yaml:"server_url"`   // Default to "https://www.plantuml.com/plantuml/"
-			ImageFormat string `yaml:"image_format"` // "svg" or "png", default "svg"
+			Enable         bool   `yaml:"enable"`
+			ServerURL      string `yaml:"server_url"`      // Default to "https://www.plantuml.com/plantuml/"
+			ImageFormat    string `yaml:"image_format"`    // "svg" or "png", default "svg"
+			Includes       string `yaml:"includes"`        // How URL !include lines are handled: "server", "proxy" or "block"
+			IncludeHosts   string `yaml:"include_hosts"`   // Comma-separated hosts the wiki may fetch includes from in proxy mode
+			MaxResponseKB  int    `yaml:"max_response_kb"` // Largest diagram or include the wiki accepts
+			TimeoutSeconds int    `yaml:"timeout_seconds"` // Timeout of each request to the server or an include host
 		} `yaml:"plantuml"`
+		Mermaid struct {
+			Script        string `yaml:"script"`         // Mermaid bundle: a file below static, which data/static can hold, or a URL
+			Theme         string `yaml:"theme"`          // Theme of diagrams: "default", "neutral", "forest" or "base"
+			DarkTheme     string `yaml:"dark_theme"`     // Theme of diagrams in dark mode
+			SecurityLevel string `yaml:"security_level"` // "strict", "antiscript", "loose" or "sandbox"
+			MaxTextSize   int    `yaml:"max_text_size"`  // Largest diagram source rendered, in characters
+			Plugins       string `yaml:"plugins"`        // Comma-separated ES modules of external diagrams, like zenuml
+		} `yaml:"mermaid"`
+		Glossary struct {
+			Enable        bool   `yaml:"enable"`
+			Page          string `yaml:"page"`           // Document holding the glossary definitions
+			CaseSensitive bool   `yaml:"case_sensitive"` // Match terms case-sensitively
+		} `yaml:"glossary"`
+		Citations struct {
+			Style string `yaml:"style"` // "numeric" or "author-year"
+		} `yaml:"citations"`
+		Obsidian struct {
+			Enable bool `yaml:"enable"` // Render [[wikilinks]] and ![[embeds]] written for Obsidian
+		} `yaml:"obsidian"`
+		Pandoc struct {
+			Enable         bool   `yaml:"enable"`
+			Command        string `yaml:"command"`         // Path of the pandoc binary, default "pandoc"
+			ServerURL      string `yaml:"server_url"`      // Pandoc server used instead of the binary, e.g. "http://pandoc:3030"
+			TimeoutSeconds int    `yaml:"timeout_seconds"` // Time limit of one conversion
+		} `yaml:"pandoc"`
+		MachineTranslation struct {
+			Provider       string `yaml:"provider"`        // "deepl", "libretranslate" or "openai", empty to disable
+			ServerURL      string `yaml:"server_url"`      // API endpoint, empty for the public service of the provider
+			APIKey         string `yaml:"api_key"`         // Best written as a ${secret:...} reference
+			Model          string `yaml:"model"`           // Model used with OpenAI-compatible endpoints
+			TimeoutSeconds int    `yaml:"timeout_seconds"` // Time limit of each request to the provider
+		} `yaml:"machine_translation"`
+		AI struct {
+			Enable             bool   `yaml:"enable"`
+			ServerURL          string `yaml:"server_url"`           // OpenAI-compatible API, default "https://api.openai.com/v1"
+			APIKey             string `yaml:"api_key"`              // Best written as a ${secret:...} reference
+			Model              string `yaml:"model"`
+			MaxInputKB         int    `yaml:"max_input_kb"`         // Largest text sent in one request
+			TimeoutSeconds     int    `yaml:"timeout_seconds"`      // Time limit of one request
+			SemanticSearch     bool   `yaml:"semantic_search"`      // Index pages with embeddings to answer questions about the wiki
+			EmbeddingServerURL string `yaml:"embedding_server_url"` // Endpoint of the embedding model, default server_url
+			EmbeddingModel     string `yaml:"embedding_model"`
+			Summaries          bool   `yaml:"summaries"`            // Page excerpts are written by the model instead of taken from the first paragraph
+		} `yaml:"ai"`
+		Labels struct {
+			Width    string `yaml:"width"`    // Size of the label cards as a CSS length, default 62mm by 29mm
+			Height   string `yaml:"height"`
+			Fields   string `yaml:"fields"`   // Comma-separated frontmatter fields printed on the cards, empty for all
+			Template string `yaml:"template"` // HTML template of the cards, relative to root_dir, empty for the built-in one
+		} `yaml:"labels"`
+		Diagrams struct {
+			Enable        bool   `yaml:"enable"`         // Edit .drawio and .excalidraw attachments in the page
+			DrawioURL     string `yaml:"drawio_url"`     // Embeddable draw.io editor, default "https://embed.diagrams.net"
+			ExcalidrawCDN string `yaml:"excalidraw_cdn"` // ES module CDN the Excalidraw editor loads from, default "https://esm.sh"
+		} `yaml:"diagrams"`
+		Media struct {
+			Posters bool   `yaml:"posters"` // Generate poster images of uploaded videos with ffmpeg
+			FFmpeg  string `yaml:"ffmpeg"`  // Path of the ffmpeg binary, default "ffmpeg"
+		} `yaml:"media"`
+		Asciinema struct {
+			Hosts     string `yaml:"hosts"`       // Comma-separated sites the wiki fetches terminal recordings from, default "asciinema.org"
+			MaxSizeKB int    `yaml:"max_size_kb"` // Largest recording fetched from them
+		} `yaml:"asciinema"`
+		Maps struct {
+			TileURL     string `yaml:"tile_url"`    // XYZ tile server of map blocks, with {z}, {x}, {y} and {s} placeholders, empty for no tiles
+			Attribution string `yaml:"attribution"` // Credit of the tiles shown in the corner of maps
+			MaxZoom     int    `yaml:"max_zoom"`    // Highest zoom level the tile server serves
+		} `yaml:"maps"`
+		Music struct {
+			Enable bool   `yaml:"enable"` // Render ```abc blocks as scores
+			Script string `yaml:"script"` // abcjs bundle: a file below static, which data/static can hold, or a URL; empty renders no scores
+		} `yaml:"music"`
 	} `yaml:"extensions"`
+
+	// Settings written as ${...} references, by yaml path
+	references map[string]reference
 }
 
 // LoadConfig loads the configuration from a YAML file
@@ -93,6 +236,18 @@ func LoadConfig(path string) (*Config, error) {
 	config.Server.SSL = false
 	config.Server.SSLCert = ""
 	config.Server.SSLKey = ""
+	config.Server.HTTP2 = true
+	config.Server.HTTP3 = false
+	config.Server.HTTP3Port = 0
+	config.Server.Pprof = false
+	config.Server.RenderWorkers = 0
+	config.Server.MaxConcurrentRenders = 0
+	config.Server.Compression = true
+	config.Server.URL = ""
+	config.Database.Driver = "memory"
+	config.Database.URL = ""
+	config.Jobs.Workers = 2
+	config.Jobs.MaxAttempts = 5
 	config.Wiki.RootDir = "data"
 	config.Wiki.DocumentsDir = "documents"
 	config.Wiki.Title = "📚 Wiki-Go"
@@ -108,6 +263,17 @@ func LoadConfig(path string) (*Config, error) {
 	config.Wiki.MaxVersions = 10   // Default value
 	config.Wiki.MaxUploadSize = 10 // Default value
 	config.Wiki.Language = "en"    // Default to English
+	config.Wiki.ContentLanguages = ""
+	config.Wiki.ADRDir = "adr"
+	config.Wiki.OfflineReading = true
+	config.Wiki.AirGapped = false
+	config.Wiki.Lint = "off"
+	config.Wiki.LinkPreviews = true
+	config.Wiki.SocialImages = true
+	config.Wiki.SocialImageColor = "#0066cc"
+	config.Wiki.HighContrast = false
+	config.Wiki.UnderlineLinks = false
+	config.Wiki.UserDashboards = true
 	config.Users = []User{}        // Initialize empty users array
 
 	// Security defaults
@@ -116,11 +282,81 @@ func LoadConfig(path string) (*Config, error) {
 	config.Security.LoginBan.WindowSeconds = 180
 	config.Security.LoginBan.InitialBanSeconds = 60
 	config.Security.LoginBan.MaxBanSeconds = 86400 // 24h
+	config.Security.ProxyAuth.Enabled = false
+	config.Security.ProxyAuth.TrustedProxies = "127.0.0.1,::1"
+	config.Security.ProxyAuth.UserHeader = "Remote-User"
+	config.Security.ProxyAuth.GroupsHeader = "Remote-Groups"
+	config.Security.ProxyAuth.AutoProvision = true
+	config.Security.ProxyAuth.DefaultRole = roles.RoleViewer
+	config.Security.Passkeys.RequireForAdmins = false
+	config.Security.Headers.CSP = "enforce"
+	config.Security.Headers.ImageSources = "https:"
+	config.Security.Headers.MediaSources = ""
+	config.Security.Headers.FrameSources = "https://www.youtube.com https://www.youtube-nocookie.com https://player.vimeo.com"
+	config.Security.Headers.ConnectSources = ""
+	config.Security.Headers.HSTSMaxAge = 31536000 // 1 year
+	config.Security.Headers.FrameOptions = "SAMEORIGIN"
+	config.Security.Headers.EmbedAncestors = "*"
+	config.Security.Headers.ReferrerPolicy = "strict-origin-when-cross-origin"
+	config.Security.Sanitizer.TrustedEditors = false
+	config.Security.Sanitizer.AllowElements = ""
+	config.Security.Encryption.Directories = ""
+	config.Security.Encryption.Key = ""
+	config.Security.Encryption.KeyCommand = ""
 
 	// Extensions defaults
 	config.Extensions.PlantUML.Enable = true
 	config.Extensions.PlantUML.ServerURL = "https://www.plantuml.com/plantuml"
 	config.Extensions.PlantUML.ImageFormat = "svg"
+	config.Extensions.PlantUML.Includes = "server"
+	config.Extensions.PlantUML.IncludeHosts = ""
+	config.Extensions.PlantUML.MaxResponseKB = 2048
+	config.Extensions.PlantUML.TimeoutSeconds = 10
+	config.Extensions.Mermaid.Script = "libs/mermaid-11.8.1/mermaid.min.js"
+	config.Extensions.Mermaid.Theme = "default"
+	config.Extensions.Mermaid.DarkTheme = "dark"
+	config.Extensions.Mermaid.SecurityLevel = "strict"
+	config.Extensions.Mermaid.MaxTextSize = 15000
+	config.Extensions.Mermaid.Plugins = ""
+	config.Extensions.Glossary.Enable = true
+	config.Extensions.Glossary.Page = "glossary"
+	config.Extensions.Glossary.CaseSensitive = false
+	config.Extensions.Citations.Style = "numeric"
+	config.Extensions.Pandoc.Enable = false
+	config.Extensions.Pandoc.Command = "pandoc"
+	config.Extensions.Pandoc.ServerURL = ""
+	config.Extensions.Pandoc.TimeoutSeconds = 60
+	config.Extensions.MachineTranslation.Provider = ""
+	config.Extensions.MachineTranslation.ServerURL = ""
+	config.Extensions.MachineTranslation.APIKey = ""
+	config.Extensions.MachineTranslation.Model = "gpt-4o-mini"
+	config.Extensions.MachineTranslation.TimeoutSeconds = 120
+	config.Extensions.AI.Enable = false
+	config.Extensions.AI.ServerURL = ""
+	config.Extensions.AI.APIKey = ""
+	config.Extensions.AI.Model = "gpt-4o-mini"
+	config.Extensions.AI.MaxInputKB = 64
+	config.Extensions.AI.TimeoutSeconds = 60
+	config.Extensions.AI.SemanticSearch = false
+	config.Extensions.AI.EmbeddingServerURL = ""
+	config.Extensions.AI.EmbeddingModel = "text-embedding-3-small"
+	config.Extensions.AI.Summaries = false
+	config.Extensions.Labels.Width = "62mm"
+	config.Extensions.Labels.Height = "29mm"
+	config.Extensions.Labels.Fields = ""
+	config.Extensions.Labels.Template = ""
+	config.Extensions.Diagrams.Enable = true
+	config.Extensions.Diagrams.DrawioURL = "https://embed.diagrams.net"
+	config.Extensions.Diagrams.ExcalidrawCDN = "https://esm.sh"
+	config.Extensions.Media.Posters = false
+	config.Extensions.Media.FFmpeg = "ffmpeg"
+	config.Extensions.Asciinema.Hosts = "asciinema.org"
+	config.Extensions.Asciinema.MaxSizeKB = 5120
+	config.Extensions.Maps.TileURL = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+	config.Extensions.Maps.Attribution = "© OpenStreetMap contributors"
+	config.Extensions.Maps.MaxZoom = 19
+	config.Extensions.Music.Enable = true
+	config.Extensions.Music.Script = "https://cdn.jsdelivr.net/npm/abcjs@6.4.4/dist/abcjs-basic-min.js"
 
 	// Read config file
 	data, err := os.ReadFile(path)
@@ -163,6 +399,18 @@ func LoadConfig(path string) (*Config, error) {
 				config.Server.SSL,
 				config.Server.SSLCert,
 				config.Server.SSLKey,
+				config.Server.HTTP2,
+				config.Server.HTTP3,
+				config.Server.HTTP3Port,
+				config.Server.Pprof,
+				config.Server.RenderWorkers,
+				config.Server.MaxConcurrentRenders,
+				config.Server.Compression,
+				config.Server.URL,
+				config.Database.Driver,
+				config.Database.URL,
+				config.Jobs.Workers,
+				config.Jobs.MaxAttempts,
 				config.Wiki.RootDir,
 				config.Wiki.DocumentsDir,
 				config.Wiki.Title,
@@ -178,15 +426,99 @@ func LoadConfig(path string) (*Config, error) {
 				config.Wiki.MaxVersions,
 				config.Wiki.MaxUploadSize,
 				config.Wiki.Language,
+				config.Wiki.ContentLanguages,
+				config.Wiki.ADRDir,
+				config.Wiki.OfflineReading,
+				config.Wiki.AirGapped,
+				config.Wiki.Lint,
+				config.Wiki.LinkPreviews,
+				config.Wiki.SocialImages,
+				config.Wiki.SocialImageColor,
+				config.Wiki.HighContrast,
+				config.Wiki.UnderlineLinks,
+				config.Wiki.UserDashboards,
 				config.Security.LoginBan.Enabled,
 				config.Security.LoginBan.MaxFailures,
 				config.Security.LoginBan.WindowSeconds,
 				config.Security.LoginBan.InitialBanSeconds,
 				config.Security.LoginBan.MaxBanSeconds,
+				config.Security.ProxyAuth.Enabled,
+				config.Security.ProxyAuth.TrustedProxies,
+				config.Security.ProxyAuth.UserHeader,
+				config.Security.ProxyAuth.GroupsHeader,
+				config.Security.ProxyAuth.AutoProvision,
+				config.Security.ProxyAuth.DefaultRole,
+				config.Security.ProxyAuth.AdminGroups,
+				config.Security.ProxyAuth.EditorGroups,
+				config.Security.Passkeys.RequireForAdmins,
+				config.Security.Headers.CSP,
+				config.Security.Headers.ImageSources,
+				config.Security.Headers.MediaSources,
+				config.Security.Headers.FrameSources,
+				config.Security.Headers.ConnectSources,
+				config.Security.Headers.HSTSMaxAge,
+				config.Security.Headers.FrameOptions,
+				config.Security.Headers.EmbedAncestors,
+				config.Security.Headers.ReferrerPolicy,
+				config.Security.Sanitizer.TrustedEditors,
+				config.Security.Sanitizer.AllowElements,
+				config.Security.Encryption.Directories,
+				config.Security.Encryption.Key,
+				config.Security.Encryption.KeyCommand,
 				usersStr.String(),
 				config.Extensions.PlantUML.Enable,
 				config.Extensions.PlantUML.ServerURL,
 				config.Extensions.PlantUML.ImageFormat,
+				config.Extensions.PlantUML.Includes,
+				config.Extensions.PlantUML.IncludeHosts,
+				config.Extensions.PlantUML.MaxResponseKB,
+				config.Extensions.PlantUML.TimeoutSeconds,
+				config.Extensions.Mermaid.Script,
+				config.Extensions.Mermaid.Theme,
+				config.Extensions.Mermaid.DarkTheme,
+				config.Extensions.Mermaid.SecurityLevel,
+				config.Extensions.Mermaid.MaxTextSize,
+				config.Extensions.Mermaid.Plugins,
+				config.Extensions.Glossary.Enable,
+				config.Extensions.Glossary.Page,
+				config.Extensions.Glossary.CaseSensitive,
+				config.Extensions.Citations.Style,
+				config.Extensions.Obsidian.Enable,
+				config.Extensions.Pandoc.Enable,
+				config.Extensions.Pandoc.Command,
+				config.Extensions.Pandoc.ServerURL,
+				config.Extensions.Pandoc.TimeoutSeconds,
+				config.Extensions.MachineTranslation.Provider,
+				config.Extensions.MachineTranslation.ServerURL,
+				config.Extensions.MachineTranslation.APIKey,
+				config.Extensions.MachineTranslation.Model,
+				config.Extensions.MachineTranslation.TimeoutSeconds,
+				config.Extensions.AI.Enable,
+				config.Extensions.AI.ServerURL,
+				config.Extensions.AI.APIKey,
+				config.Extensions.AI.Model,
+				config.Extensions.AI.MaxInputKB,
+				config.Extensions.AI.TimeoutSeconds,
+				config.Extensions.AI.SemanticSearch,
+				config.Extensions.AI.EmbeddingServerURL,
+				config.Extensions.AI.EmbeddingModel,
+				config.Extensions.AI.Summaries,
+				config.Extensions.Labels.Width,
+				config.Extensions.Labels.Height,
+				config.Extensions.Labels.Fields,
+				config.Extensions.Labels.Template,
+				config.Extensions.Diagrams.Enable,
+				config.Extensions.Diagrams.DrawioURL,
+				config.Extensions.Diagrams.ExcalidrawCDN,
+				config.Extensions.Media.Posters,
+				config.Extensions.Media.FFmpeg,
+				config.Extensions.Asciinema.Hosts,
+				config.Extensions.Asciinema.MaxSizeKB,
+				config.Extensions.Maps.TileURL,
+				config.Extensions.Maps.Attribution,
+				config.Extensions.Maps.MaxZoom,
+				config.Extensions.Music.Enable,
+				config.Extensions.Music.Script,
 			)
 
 			// Write the config file
@@ -199,11 +531,18 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
-	// Parse YAML
-	err = yaml.Unmarshal(data, config)
+	// Parse YAML, resolving environment variable and secret references
+	root, references, err := resolveReferences(data)
 	if err != nil {
 		return nil, err
 	}
+	if err := root.Decode(config); err != nil {
+		return nil, err
+	}
+	config.references = references
+	if config.Database.Driver == "sqlite" && !sqliteAvailable {
+		return nil, fmt.Errorf("database driver \"sqlite\" needs a build with cgo (CGO_ENABLED=1), like the Docker image; use \"memory\", \"postgres\" or \"redis\" with this binary")
+	}
 
 	// Ensure the on-disk configuration includes every setting present in the current template.
 	// This will rewrite the file ONLY when new settings have been introduced that are not
@@ -234,6 +573,42 @@ func GetConfigTemplate() string {
     ssl: %t
     ssl_cert: "%s"
     ssl_key: "%s"
+    # Serve HTTP/2, over TLS and in cleartext (h2c) for reverse proxies
+    http2: %t
+    # Serve HTTP/3 over QUIC when ssl is enabled, on the UDP port http3_port
+    # (0 for the server port)
+    http3: %t
+    http3_port: %d
+    # Serve CPU, memory and goroutine profiles to admins under /debug/pprof/
+    pprof: %t
+    # Number of documents rendered at the same time, 0 for one per CPU
+    render_workers: %d
+    # Renders running or waiting before new page requests get a 503 response,
+    # 0 for no limit
+    max_concurrent_renders: %d
+    # Compress pages, scripts and stylesheets with brotli or gzip. Disable it when
+    # a reverse proxy already compresses responses.
+    compression: %t
+    # Public URL the wiki is reached at, e.g. "https://wiki.example.com". Passkeys
+    # are bound to its host name and only work once it is set.
+    url: "%s"
+database:
+    # Where sessions and login attempts are kept: "memory" for a single instance,
+    # "sqlite" to keep them across restarts of a single instance (in builds
+    # with cgo, like the Docker image, not the release binaries), "postgres" or
+    # "redis" to share them, and the locks of shared files, between several
+    # instances behind a load balancer
+    driver: "%s"
+    # SQLite database file (empty for wiki.db in the data directory), or
+    # PostgreSQL or Redis connection URL, e.g.
+    # "postgres://wiki:${secret:db_password}@db:5432/wiki?sslmode=disable"
+    # or "redis://:${secret:redis_password}@redis:6379/0"
+    url: "%s"
+jobs:
+    # Number of background jobs, like notifications, run at the same time
+    workers: %d
+    # Attempts, with growing delays, before a failing job is marked as failed
+    max_attempts: %d
 wiki:
     root_dir: "%s"
     documents_dir: "%s"
@@ -252,6 +627,37 @@ wiki:
     max_upload_size: %d
     # Default language for the wiki interface (en, es, etc.)
     language: "%s"
+    # Languages pages can be translated into, comma-separated (e.g. "de, fr").
+    # Translations are kept next to the page as document.<lang>.md, the page
+    # itself is written in the default language above
+    content_languages: "%s"
+    # Directory (inside documents) where Architecture Decision Records are created
+    adr_dir: "%s"
+    # Keep visited pages and assets in the browser so they can be read offline
+    # when the wiki is installed as an app or the connection drops
+    offline_reading: %t
+    # For networks without internet access: the wiki loads nothing from other
+    # sites, like the icons of links, and reports at startup the assets that
+    # would still be, such as a PlantUML server or a Mermaid bundle on a CDN
+    air_gapped: %t
+    # Check pages for style problems (heading level jumps, bare URLs, trailing
+    # whitespace, broken references and missing attachments): "off", "warn" to
+    # show warnings in the editor, or "block" to also refuse saving
+    lint: "%s"
+    # Show the title, excerpt and image of a page when hovering a link to it
+    link_previews: %t
+    # Draw an image with the title of the page for the link cards of pages
+    # without an image of their own, on a background of this color
+    social_images: %t
+    social_image_color: "%s"
+    # Accessibility: show the high-contrast theme by default (readers can
+    # still switch it off from the sidebar), and underline every link in
+    # pages instead of only showing them in color
+    high_contrast: %t
+    underline_links: %t
+    # Let users replace the dashboard of the home page, arranged by admins,
+    # with one of their own
+    user_dashboards: %t
 security:
     login_ban:
         # Enable protection against brute force login attacks
@@ -264,6 +670,56 @@ security:
         initial_ban_seconds: %d
         # Maximum ban duration in seconds (24 hours)
         max_ban_seconds: %d
+    proxy_auth:
+        # Trust the user set by an authenticating reverse proxy (Authelia, oauth2-proxy, ...)
+        enabled: %t
+        # Comma-separated IPs or CIDR ranges of the proxies allowed to set the headers
+        trusted_proxies: "%s"
+        # Headers holding the username and the comma-separated groups of the user
+        user_header: "%s"
+        groups_header: "%s"
+        # Create accounts for users that don't exist yet
+        auto_provision: %t
+        # Role of users that are not in any of the groups below
+        default_role: "%s"
+        # Comma-separated proxy groups mapped to the admin and editor roles
+        admin_groups: "%s"
+        editor_groups: "%s"
+    passkeys:
+        # Require admins to confirm password logins with a passkey
+        require_for_admins: %t
+    headers:
+        # Content Security Policy: "enforce", "report-only" or "off"
+        csp: "%s"
+        # Space-separated extra sources allowed for images, audio/video, embedded frames
+        # and fetch requests, e.g. "https://images.example.com"
+        img_sources: "%s"
+        media_sources: "%s"
+        frame_sources: "%s"
+        connect_sources: "%s"
+        # Strict-Transport-Security max-age in seconds for HTTPS requests, 0 disables it
+        hsts_max_age: %d
+        # X-Frame-Options: "SAMEORIGIN" or "DENY"
+        frame_options: "%s"
+        # Origins that may show pages in frames through /embed, e.g. "https://portal.example.com",
+        # "*" for any site or "'none'" to disable embedding
+        embed_ancestors: "%s"
+        referrer_policy: "%s"
+    sanitizer:
+        # Trust editors with raw HTML: render it as written instead of sanitizing pages
+        trusted_editors: %t
+        # Extra elements allowed in pages, with their |-separated attributes,
+        # e.g. "abbr, iframe[src|width|height]"
+        allow_elements: "%s"
+    encryption:
+        # Comma-separated directories whose pages, attachments and versions are
+        # encrypted on disk, e.g. "/ops/runbooks, /hr"
+        directories: "%s"
+        # Base64 encoded 256-bit key, e.g. "${secret:content_key}" or "${CONTENT_KEY}"
+        key: "%s"
+        # Command printing the key instead, e.g. to decrypt it with a KMS:
+        # "aws kms decrypt --ciphertext-blob fileb:///etc/wiki/key.enc --query Plaintext --output text"
+        key_command: "%s"
 users:
 %s
 extensions:
@@ -275,6 +731,141 @@ extensions:
         server_url: "%s"
         # PlantUML image format: "svg" or "png"
         image_format: "%s"
+        # How !include lines with URLs are handled: "server" leaves them to the
+        # PlantUML server, "proxy" fetches them from include_hosts and inlines them,
+        # "block" removes them
+        includes: "%s"
+        # Comma-separated hosts includes may be fetched from in proxy mode
+        include_hosts: "%s"
+        # Largest diagram or included file accepted, in KB
+        max_response_kb: %d
+        # Timeout of each request to the PlantUML server or an include host
+        timeout_seconds: %d
+    mermaid:
+        # Mermaid bundle: a file below static like the bundled
+        # "libs/mermaid-11.8.1/mermaid.min.js", or another version placed in
+        # data/static for air-gapped sites, or a URL like
+        # "https://cdn.jsdelivr.net/npm/mermaid@11.9.0/dist/mermaid.min.js"
+        script: "%s"
+        # Theme of diagrams: "default", "neutral", "forest" or "base"
+        theme: "%s"
+        # Theme of diagrams in dark mode
+        dark_theme: "%s"
+        # "strict" encodes HTML in labels and disables clicks, "antiscript"
+        # allows HTML without scripts, "loose" allows both and "sandbox" renders
+        # diagrams in sandboxed frames
+        security_level: "%s"
+        # Largest diagram source rendered, in characters
+        max_text_size: %d
+        # Comma-separated ES modules of external diagrams registered with
+        # Mermaid, files below static or URLs, e.g.
+        # "https://cdn.jsdelivr.net/npm/@mermaid-js/mermaid-zenuml@0.2.0/dist/mermaid-zenuml.esm.min.mjs"
+        plugins: "%s"
+    glossary:
+        # Highlight the first occurrence of glossary terms with their definition
+        enable: %t
+        # Document containing the glossary, terms are written as definition lists
+        page: "%s"
+        # Match terms case-sensitively
+        case_sensitive: %t
+    citations:
+        # Citation style for [@key] references: "numeric" or "author-year"
+        style: "%s"
+    obsidian:
+        # Render the [[wikilinks]] and ![[embeds]] of Obsidian notes. Links are
+        # resolved by page name, embedded pages are shown inline
+        enable: %t
+    pandoc:
+        # Import documents in other formats as pages and export pages, with pandoc
+        enable: %t
+        # Path of the pandoc binary
+        command: "%s"
+        # URL of a pandoc server, used instead of the binary when set
+        server_url: "%s"
+        # Time limit of one conversion, in seconds
+        timeout_seconds: %d
+    machine_translation:
+        # Pre-fill translations of pages with "deepl", "libretranslate" or "openai"
+        # (any OpenAI-compatible endpoint), empty to disable
+        provider: "%s"
+        # API endpoint, empty for the public service of the provider
+        server_url: "%s"
+        # API key, best written as a ${secret:...} reference
+        api_key: "%s"
+        # Model used with OpenAI-compatible endpoints
+        model: "%s"
+        # Time limit of each request to the provider, in seconds
+        timeout_seconds: %d
+    ai:
+        # Writing actions in the editor (summarize, fix grammar, improve, outline)
+        # using a chat model behind an OpenAI-compatible API
+        enable: %t
+        # Base URL of the API, empty for "https://api.openai.com/v1"
+        server_url: "%s"
+        # API key, best written as a ${secret:...} reference
+        api_key: "%s"
+        model: "%s"
+        # Largest text sent in one request, in KB
+        max_input_kb: %d
+        # Time limit of one request, in seconds
+        timeout_seconds: %d
+        # Index pages with an embedding model for "Ask the wiki"
+        semantic_search: %t
+        # Endpoint of the embedding model, empty for server_url
+        embedding_server_url: "%s"
+        embedding_model: "%s"
+        # Write the excerpts of pages with the model instead of using their first paragraph
+        summaries: %t
+    labels:
+        # Size of the printable label cards of pages (/label/...), as CSS lengths
+        # like "62mm" or "2.4in", matching the labels of the printer
+        width: "%s"
+        height: "%s"
+        # Comma-separated frontmatter fields printed on the cards, like
+        # "asset_tag, serial, location". Empty prints every field the wiki
+        # doesn't use itself
+        fields: "%s"
+        # HTML template of the cards, relative to root_dir, empty for the
+        # built-in one
+        template: "%s"
+    diagrams:
+        # Edit the .drawio and .excalidraw attachments shown in pages with the
+        # embedded editors. Diagrams are shown from their SVG previews either way
+        enable: %t
+        # Embeddable draw.io editor, "https://embed.diagrams.net" or a
+        # self-hosted jgraph/drawio server
+        drawio_url: "%s"
+        # ES module CDN the Excalidraw editor and React are loaded from, like
+        # "https://esm.sh" or a self-hosted esm.sh
+        excalidraw_cdn: "%s"
+    media:
+        # Generate a poster image of uploaded videos from one of their first
+        # frames with ffmpeg, which the player shows until the video is played
+        posters: %t
+        # Path of the ffmpeg binary
+        ffmpeg: "%s"
+    asciinema:
+        # Comma-separated sites the wiki fetches the terminal recordings of
+        # pages from, like "asciinema.org, casts.example.com". Attached .cast
+        # files are always played; empty fetches no others
+        hosts: "%s"
+        # Largest recording fetched from these sites, in KB
+        max_size_kb: %d
+    maps:
+        # XYZ tile server the map blocks of pages are drawn on, like a
+        # self-hosted one; {z}, {x} and {y} are the tile, {s} a subdomain of
+        # "a", "b" or "c". Empty draws the markers and shapes without tiles
+        tile_url: "%s"
+        # Credit of the tiles, shown in the corner of maps
+        attribution: "%s"
+        # Highest zoom level the tile server serves
+        max_zoom: %d
+    music:
+        # Render abc code blocks as scores with abcjs
+        enable: %t
+        # abcjs bundle, a file below static like "libs/abcjs/abcjs-basic-min.js",
+        # which data/static can hold, or a URL
+        script: "%s"
 `
 }
 
@@ -286,6 +877,9 @@ func FormatUserEntry(user User) string {
 
 // SaveConfig saves the configuration to a writer
 func SaveConfig(cfg *Config, w io.Writer) error {
+	// Keep environment variable and secret references instead of writing their values
+	cfg = cfg.withReferences()
+
 	// Format all users
 	var usersStr strings.Builder
 	for _, user := range cfg.Users {
@@ -304,6 +898,18 @@ func SaveConfig(cfg *Config, w io.Writer) error {
 		cfg.Server.SSL,
 		cfg.Server.SSLCert,
 		cfg.Server.SSLKey,
+		cfg.Server.HTTP2,
+		cfg.Server.HTTP3,
+		cfg.Server.HTTP3Port,
+		cfg.Server.Pprof,
+		cfg.Server.RenderWorkers,
+		cfg.Server.MaxConcurrentRenders,
+		cfg.Server.Compression,
+		cfg.Server.URL,
+		cfg.Database.Driver,
+		cfg.Database.URL,
+		cfg.Jobs.Workers,
+		cfg.Jobs.MaxAttempts,
 		cfg.Wiki.RootDir,
 		cfg.Wiki.DocumentsDir,
 		cfg.Wiki.Title,
@@ -319,15 +925,99 @@ func SaveConfig(cfg *Config, w io.Writer) error {
 		cfg.Wiki.MaxVersions,
 		cfg.Wiki.MaxUploadSize,
 		cfg.Wiki.Language,
+		cfg.Wiki.ContentLanguages,
+		cfg.Wiki.ADRDir,
+		cfg.Wiki.OfflineReading,
+		cfg.Wiki.AirGapped,
+		cfg.Wiki.Lint,
+		cfg.Wiki.LinkPreviews,
+		cfg.Wiki.SocialImages,
+		cfg.Wiki.SocialImageColor,
+		cfg.Wiki.HighContrast,
+		cfg.Wiki.UnderlineLinks,
+		cfg.Wiki.UserDashboards,
 		cfg.Security.LoginBan.Enabled,
 		cfg.Security.LoginBan.MaxFailures,
 		cfg.Security.LoginBan.WindowSeconds,
 		cfg.Security.LoginBan.InitialBanSeconds,
 		cfg.Security.LoginBan.MaxBanSeconds,
+		cfg.Security.ProxyAuth.Enabled,
+		cfg.Security.ProxyAuth.TrustedProxies,
+		cfg.Security.ProxyAuth.UserHeader,
+		cfg.Security.ProxyAuth.GroupsHeader,
+		cfg.Security.ProxyAuth.AutoProvision,
+		cfg.Security.ProxyAuth.DefaultRole,
+		cfg.Security.ProxyAuth.AdminGroups,
+		cfg.Security.ProxyAuth.EditorGroups,
+		cfg.Security.Passkeys.RequireForAdmins,
+		cfg.Security.Headers.CSP,
+		cfg.Security.Headers.ImageSources,
+		cfg.Security.Headers.MediaSources,
+		cfg.Security.Headers.FrameSources,
+		cfg.Security.Headers.ConnectSources,
+		cfg.Security.Headers.HSTSMaxAge,
+		cfg.Security.Headers.FrameOptions,
+		cfg.Security.Headers.EmbedAncestors,
+		cfg.Security.Headers.ReferrerPolicy,
+		cfg.Security.Sanitizer.TrustedEditors,
+		cfg.Security.Sanitizer.AllowElements,
+		cfg.Security.Encryption.Directories,
+		cfg.Security.Encryption.Key,
+		cfg.Security.Encryption.KeyCommand,
 		usersStr.String(),
 		cfg.Extensions.PlantUML.Enable,
 		cfg.Extensions.PlantUML.ServerURL,
 		cfg.Extensions.PlantUML.ImageFormat,
+		cfg.Extensions.PlantUML.Includes,
+		cfg.Extensions.PlantUML.IncludeHosts,
+		cfg.Extensions.PlantUML.MaxResponseKB,
+		cfg.Extensions.PlantUML.TimeoutSeconds,
+		cfg.Extensions.Mermaid.Script,
+		cfg.Extensions.Mermaid.Theme,
+		cfg.Extensions.Mermaid.DarkTheme,
+		cfg.Extensions.Mermaid.SecurityLevel,
+		cfg.Extensions.Mermaid.MaxTextSize,
+		cfg.Extensions.Mermaid.Plugins,
+		cfg.Extensions.Glossary.Enable,
+		cfg.Extensions.Glossary.Page,
+		cfg.Extensions.Glossary.CaseSensitive,
+		cfg.Extensions.Citations.Style,
+		cfg.Extensions.Obsidian.Enable,
+		cfg.Extensions.Pandoc.Enable,
+		cfg.Extensions.Pandoc.Command,
+		cfg.Extensions.Pandoc.ServerURL,
+		cfg.Extensions.Pandoc.TimeoutSeconds,
+		cfg.Extensions.MachineTranslation.Provider,
+		cfg.Extensions.MachineTranslation.ServerURL,
+		cfg.Extensions.MachineTranslation.APIKey,
+		cfg.Extensions.MachineTranslation.Model,
+		cfg.Extensions.MachineTranslation.TimeoutSeconds,
+		cfg.Extensions.AI.Enable,
+		cfg.Extensions.AI.ServerURL,
+		cfg.Extensions.AI.APIKey,
+		cfg.Extensions.AI.Model,
+		cfg.Extensions.AI.MaxInputKB,
+		cfg.Extensions.AI.TimeoutSeconds,
+		cfg.Extensions.AI.SemanticSearch,
+		cfg.Extensions.AI.EmbeddingServerURL,
+		cfg.Extensions.AI.EmbeddingModel,
+		cfg.Extensions.AI.Summaries,
+		cfg.Extensions.Labels.Width,
+		cfg.Extensions.Labels.Height,
+		cfg.Extensions.Labels.Fields,
+		cfg.Extensions.Labels.Template,
+		cfg.Extensions.Diagrams.Enable,
+		cfg.Extensions.Diagrams.DrawioURL,
+		cfg.Extensions.Diagrams.ExcalidrawCDN,
+		cfg.Extensions.Media.Posters,
+		cfg.Extensions.Media.FFmpeg,
+		cfg.Extensions.Asciinema.Hosts,
+		cfg.Extensions.Asciinema.MaxSizeKB,
+		cfg.Extensions.Maps.TileURL,
+		cfg.Extensions.Maps.Attribution,
+		cfg.Extensions.Maps.MaxZoom,
+		cfg.Extensions.Music.Enable,
+		cfg.Extensions.Music.Script,
 	)
 
 	_, err := w.Write([]byte(configData))
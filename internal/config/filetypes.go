@@ -30,7 +30,21 @@ var AllowedFileTypes = []FileTypeConfig{
 	{Extension: "docx", MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", DisplayName: "Word Document", VerifyContentType: true},
 	{Extension: "xlsx", MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", DisplayName: "Excel Spreadsheet", VerifyContentType: true},
 	{Extension: "pptx", MimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation", DisplayName: "PowerPoint Presentation", VerifyContentType: true},
+	{Extension: "bib", MimeType: "text/plain", DisplayName: "BibTeX Bibliography", VerifyContentType: true},
+	{Extension: "yaml", MimeType: "text/plain", DisplayName: "YAML File", VerifyContentType: true},
+	{Extension: "yml", MimeType: "text/plain", DisplayName: "YAML File", VerifyContentType: true},
 	{Extension: "mp4", MimeType: "video/mp4", DisplayName: "MP4 Video", VerifyContentType: true},
+	{Extension: "webm", MimeType: "video/webm", DisplayName: "WebM Video", VerifyContentType: true},
+	{Extension: "mp3", MimeType: "audio/mpeg", DisplayName: "MP3 Audio", VerifyContentType: true},
+	{Extension: "m4a", MimeType: "audio/mp4", DisplayName: "M4A Audio", VerifyContentType: true},
+	{Extension: "ogg", MimeType: "audio/ogg", DisplayName: "Ogg Audio", VerifyContentType: true},
+	{Extension: "wav", MimeType: "audio/wav", DisplayName: "WAV Audio", VerifyContentType: true},
+	{Extension: "drawio", MimeType: "text/plain", DisplayName: "draw.io Diagram", VerifyContentType: true},
+	{Extension: "excalidraw", MimeType: "text/plain", DisplayName: "Excalidraw Drawing", VerifyContentType: true},
+	{Extension: "cast", MimeType: "text/plain", DisplayName: "Terminal Recording", VerifyContentType: true},
+	{Extension: "geojson", MimeType: "text/plain", DisplayName: "GeoJSON File", VerifyContentType: true},
+	{Extension: "ipynb", MimeType: "text/plain", DisplayName: "Jupyter Notebook", VerifyContentType: true},
+	{Extension: "json", MimeType: "text/plain", DisplayName: "JSON File", VerifyContentType: true},
 }
 
 // GetAllowedExtensions returns a slice of all allowed file extensions
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/secrets"
+
+	"gopkg.in/yaml.v3"
+)
+
+// referencePattern matches ${NAME}, ${NAME:-default} and ${secret:NAME} in string settings
+var referencePattern = regexp.MustCompile(`\$\{(secret:)?([A-Za-z_][A-Za-z0-9_.-]*)(?::-([^}]*))?\}`)
+
+// reference remembers a setting written with references, so saving the
+// configuration writes the references back instead of the resolved values
+type reference struct {
+	raw      string
+	resolved string
+}
+
+// resolveReferences parses data and replaces environment variable and secret
+// references in string settings with their values. It returns the setting paths
+// that contained references.
+func resolveReferences(data []byte) (*yaml.Node, map[string]reference, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+
+	refs := make(map[string]reference)
+	var secretValues map[string]string
+	var walkErr error
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				walk(node.Content[i+1], key)
+			}
+		case yaml.ScalarNode:
+			if node.Tag != "!!str" || !strings.Contains(node.Value, "${") || walkErr != nil {
+				return
+			}
+			raw := node.Value
+			node.Value = referencePattern.ReplaceAllStringFunc(raw, func(match string) string {
+				parts := referencePattern.FindStringSubmatch(match)
+				if parts[1] != "" {
+					if secretValues == nil {
+						values, err := secrets.Load()
+						if err != nil {
+							walkErr = fmt.Errorf("%s: %w", path, err)
+							return match
+						}
+						secretValues = values
+					}
+					value, ok := secretValues[parts[2]]
+					if !ok {
+						walkErr = fmt.Errorf("%s: secret %q doesn't exist", path, parts[2])
+					}
+					return value
+				}
+				value, ok := os.LookupEnv(parts[2])
+				if !ok || value == "" {
+					if strings.Contains(match, ":-") {
+						return parts[3]
+					}
+					if !ok {
+						walkErr = fmt.Errorf("%s: environment variable %s is not set", path, parts[2])
+					}
+				}
+				return value
+			})
+			refs[path] = reference{raw: raw, resolved: node.Value}
+		}
+	}
+	walk(&root, "")
+
+	if walkErr != nil {
+		return nil, nil, walkErr
+	}
+	return &root, refs, nil
+}
+
+// withReferences returns a copy of cfg with the settings that were loaded from
+// references set back to them, unless they have been changed since
+func (cfg *Config) withReferences() *Config {
+	if len(cfg.references) == 0 {
+		return cfg
+	}
+	restored := *cfg
+	for path, ref := range cfg.references {
+		field, ok := fieldByPath(reflect.ValueOf(&restored).Elem(), strings.Split(path, "."))
+		if ok && field.Kind() == reflect.String && field.String() == ref.resolved {
+			field.SetString(ref.raw)
+		}
+	}
+	return &restored
+}
+
+// fieldByPath finds the struct field with the given yaml key path
+func fieldByPath(v reflect.Value, path []string) (reflect.Value, bool) {
+	if len(path) == 0 {
+		return v, true
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == path[0] && t.Field(i).IsExported() {
+			return fieldByPath(v.Field(i), path[1:])
+		}
+	}
+	return reflect.Value{}, false
+}
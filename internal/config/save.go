@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// mu serializes the changes of the configuration, made by admins in the
+// settings and by the proxy authentication provisioning users
+var mu sync.Mutex
+
+// Shared is where instances of the wiki serving the same data directory take
+// their locks and count the changes of shared state, like the store
+type Shared interface {
+	Lock(name string) (func(), error)
+	Generation(name string) (int64, error)
+	Bump(name string) (int64, error)
+}
+
+// sharedName names the lock and generation of the configuration file
+const sharedName = "config"
+
+var (
+	shared       Shared   // Set by Share when several instances serve the wiki
+	sharedUnlock func()   // Releases the shared lock held by Lock
+	generation   int64    // Generation of the configuration last loaded or saved
+	reloaded     []func() // Called after the changes of another instance are loaded
+)
+
+// Share makes Lock exclude the changes made by other instances and keeps the
+// configuration up to date with them. It is called once, before serving.
+func Share(s Shared) {
+	mu.Lock()
+	defer mu.Unlock()
+	shared = s
+	if g, err := s.Generation(sharedName); err == nil {
+		generation = g
+	} else {
+		log.Printf("Warning: failed to read the configuration generation: %v", err)
+	}
+}
+
+// OnReload registers f to be called after the configuration was reloaded with
+// the changes of another instance, while the configuration is locked
+func OnReload(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	reloaded = append(reloaded, f)
+}
+
+// Lock must be held while a change of the configuration is made and saved. When
+// it is shared, Lock also waits for the changes of the other instances and
+// reloads the configuration if one of them saved it since.
+func Lock() {
+	mu.Lock()
+	if shared == nil {
+		return
+	}
+	unlock, err := shared.Lock(sharedName)
+	if err != nil {
+		// Changes are still serialized on this instance
+		log.Printf("Warning: failed to lock the configuration: %v", err)
+		return
+	}
+	sharedUnlock = unlock
+	refresh()
+}
+
+// Unlock releases the lock taken by Lock
+func Unlock() {
+	if sharedUnlock != nil {
+		sharedUnlock()
+		sharedUnlock = nil
+	}
+	mu.Unlock()
+}
+
+// LockLocal and UnlockLocal guard reading the configuration on this instance,
+// without waiting for the other instances
+func LockLocal() {
+	mu.Lock()
+}
+
+// UnlockLocal releases the lock taken by LockLocal
+func UnlockLocal() {
+	mu.Unlock()
+}
+
+// Refresh reloads the configuration when another instance saved it since it was
+// last loaded, and reports whether it did
+func Refresh() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if shared == nil {
+		return false
+	}
+	return refresh()
+}
+
+// refresh reloads Cfg from ConfigFilePath when its generation changed, while mu is held
+func refresh() bool {
+	g, err := shared.Generation(sharedName)
+	if err != nil {
+		log.Printf("Warning: failed to read the configuration generation: %v", err)
+		return false
+	}
+	if g == generation || Cfg == nil {
+		return false
+	}
+	loaded, err := LoadConfig(ConfigFilePath)
+	if err != nil {
+		// Try again on the next refresh
+		log.Printf("Warning: failed to reload the configuration changed by another instance: %v", err)
+		return false
+	}
+	*Cfg = *loaded
+	generation = g
+	for _, f := range reloaded {
+		f()
+	}
+	return true
+}
+
+// WriteFile saves the configuration to path. It is written to a temporary file
+// renamed over the old one, so the file is never left half written. The
+// configuration holds password hashes, so only its owner may read it.
+func WriteFile(path string, cfg *Config) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFile := file.Name()
+	defer os.Remove(tempFile)
+
+	if err := SaveConfig(cfg, file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to set the permissions of the temporary file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to write configuration file: %w", err)
+	}
+
+	// Tell the other instances to reload it
+	if shared != nil && path == ConfigFilePath {
+		if g, err := shared.Bump(sharedName); err != nil {
+			log.Printf("Warning: failed to announce the configuration change: %v", err)
+		} else {
+			generation = g
+		}
+	}
+	return nil
+}
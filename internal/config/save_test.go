@@ -0,0 +1,145 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeShared is the state several instances share, kept in memory
+type fakeShared struct {
+	mu          sync.Mutex
+	locks       map[string]*sync.Mutex
+	generations map[string]int64
+}
+
+func newFakeShared() *fakeShared {
+	return &fakeShared{locks: make(map[string]*sync.Mutex), generations: make(map[string]int64)}
+}
+
+func (f *fakeShared) Lock(name string) (func(), error) {
+	f.mu.Lock()
+	lock, ok := f.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.locks[name] = lock
+	}
+	f.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock, nil
+}
+
+func (f *fakeShared) Generation(name string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.generations[name], nil
+}
+
+func (f *fakeShared) Bump(name string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generations[name]++
+	return f.generations[name], nil
+}
+
+// setupShared loads a configuration from ConfigFilePath in a temporary directory
+// and shares it through a fake store
+func setupShared(t *testing.T) *fakeShared {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(ConfigFilePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(ConfigFilePath, &Config{Users: []User{{Username: "ann", Role: RoleAdmin}}}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(ConfigFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := Cfg
+	Cfg = loaded
+	f := newFakeShared()
+	Share(f)
+	t.Cleanup(func() {
+		Cfg = previous
+		shared, generation, reloaded = nil, 0, nil
+	})
+	return f
+}
+
+// changeElsewhere saves a configuration like another instance would
+func changeElsewhere(t *testing.T, f *fakeShared, users ...User) {
+	t.Helper()
+	unlock, _ := f.Lock(sharedName)
+	defer unlock()
+	changed := *Cfg
+	changed.Users = users
+	// Written without WriteFile, which would record the generation as this instance's
+	file, err := os.Create(ConfigFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := SaveConfig(&changed, file); err != nil {
+		t.Fatal(err)
+	}
+	f.Bump(sharedName)
+}
+
+func TestRefreshLoadsChangesOfOtherInstances(t *testing.T) {
+	f := setupShared(t)
+	calls := 0
+	OnReload(func() { calls++ })
+
+	if Refresh() {
+		t.Fatal("Refresh() reloaded a configuration nobody changed")
+	}
+
+	changeElsewhere(t, f, User{Username: "ann", Role: RoleAdmin}, User{Username: "bob", Role: RoleEditor})
+	if !Refresh() {
+		t.Fatal("Refresh() didn't reload the configuration changed by another instance")
+	}
+	if len(Cfg.Users) != 2 || Cfg.Users[1].Username != "bob" {
+		t.Errorf("users = %+v after the reload, want ann and bob", Cfg.Users)
+	}
+	if calls != 1 {
+		t.Errorf("OnReload functions called %d times, want 1", calls)
+	}
+	if Refresh() {
+		t.Error("Refresh() reloaded the configuration twice for one change")
+	}
+}
+
+func TestLockStartsFromTheLatestChange(t *testing.T) {
+	f := setupShared(t)
+	changeElsewhere(t, f, User{Username: "carol", Role: RoleViewer})
+
+	// A change made here doesn't overwrite the one made by the other instance
+	Lock()
+	updated := *Cfg
+	updated.Users = append(updated.Users, User{Username: "dave", Role: RoleViewer})
+	err := WriteFile(ConfigFilePath, &updated)
+	*Cfg = updated
+	Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(Cfg.Users) != 2 || Cfg.Users[0].Username != "carol" {
+		t.Errorf("users = %+v, want carol and dave", Cfg.Users)
+	}
+
+	// Saving announced the change, and this instance doesn't reload its own changes
+	if g, _ := f.Generation(sharedName); g != 2 {
+		t.Errorf("generation = %d after two changes, want 2", g)
+	}
+	if Refresh() {
+		t.Error("Refresh() reloaded a change made by this instance")
+	}
+
+	// The shared lock is released
+	unlock, _ := f.Lock(sharedName)
+	unlock()
+}
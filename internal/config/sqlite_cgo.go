@@ -0,0 +1,6 @@
+//go:build cgo
+
+package config
+
+// sqliteAvailable reports whether the SQLite driver, which needs cgo, is built in
+const sqliteAvailable = true
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigChecksTheSQLiteDriver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := &Config{Users: []User{{Username: "ann", Role: RoleAdmin}}}
+	cfg.Database.Driver = "sqlite"
+	if err := WriteFile(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(path)
+	if sqliteAvailable && err != nil {
+		t.Errorf("LoadConfig() = %v in a build with cgo", err)
+	}
+	if !sqliteAvailable && err == nil {
+		t.Error("LoadConfig() accepted the sqlite driver in a build without cgo")
+	}
+}
@@ -0,0 +1,237 @@
+// Package dashboard stores the layout of the home page: the widgets shown and
+// their order. Admins set the layout everybody sees, users may replace it with
+// a layout of their own.
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"wiki-go/internal/store"
+)
+
+// Widget types
+const (
+	WidgetContent   = "content"   // The home page document
+	WidgetRecent    = "recent"    // Recently changed pages
+	WidgetDrafts    = "drafts"    // Draft pages the reader edited or owns
+	WidgetWatched   = "watched"   // Pages the reader watches
+	WidgetFavorites = "favorites" // Pages the reader starred
+	WidgetPinned    = "pinned"    // Pages chosen in the layout, the pinned pages when none are
+	WidgetSearch    = "search"    // Search box
+	WidgetTags      = "tags"      // Tags of the pages, sized by use
+)
+
+// Widget widths
+const (
+	WidthFull = "full"
+	WidthHalf = "half"
+)
+
+// Limits of a layout
+const (
+	MaxWidgets   = 20
+	MaxLimit     = 50 // Entries listed by a widget
+	DefaultLimit = 10
+	MaxPages     = 50 // Pages of a pinned widget
+)
+
+// Widget is a box of the dashboard
+type Widget struct {
+	Type  string   `json:"type"`
+	Title string   `json:"title,omitempty"` // Replaces the default title of the type
+	Width string   `json:"width,omitempty"` // full or half, full when empty
+	Limit int      `json:"limit,omitempty"` // Entries listed, DefaultLimit when 0
+	Pages []string `json:"pages,omitempty"` // Paths of the pages of a pinned widget
+}
+
+// Layout is the list of widgets of a dashboard, top to bottom
+type Layout struct {
+	Widgets []Widget `json:"widgets"`
+}
+
+var (
+	mutex          sync.Mutex
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+	widgetTypes    = []string{WidgetContent, WidgetRecent, WidgetDrafts, WidgetWatched, WidgetFavorites, WidgetPinned, WidgetSearch, WidgetTags}
+)
+
+// Types returns the widget types, in the order they are offered
+func Types() []string {
+	return append([]string{}, widgetTypes...)
+}
+
+// Default returns the layout used until an admin arranges the dashboard: the
+// home page followed by the recent changes and the tags
+func Default() Layout {
+	return Layout{Widgets: []Widget{
+		{Type: WidgetContent, Width: WidthFull},
+		{Type: WidgetRecent, Width: WidthHalf, Limit: DefaultLimit},
+		{Type: WidgetTags, Width: WidthHalf},
+	}}
+}
+
+// globalFile returns the file holding the layout everybody sees
+func globalFile(rootDir string) string {
+	return filepath.Join(rootDir, "dashboard.json")
+}
+
+// userFile returns the file holding the own layout of a user
+func userFile(rootDir, user string) string {
+	return filepath.Join(rootDir, "dashboards", unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// LoadGlobal returns the layout set by admins, the default layout when none was saved
+func LoadGlobal(rootDir string) (Layout, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	layout, found, err := load(globalFile(rootDir))
+	if err != nil || !found {
+		return Default(), err
+	}
+	return layout, nil
+}
+
+// SaveGlobal stores the layout everybody sees
+func SaveGlobal(rootDir string, layout Layout) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return save(globalFile(rootDir), layout)
+}
+
+// LoadUser returns the own layout of user and whether they have one
+func LoadUser(rootDir, user string) (Layout, bool, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return load(userFile(rootDir, user))
+}
+
+// SaveUser stores the own layout of user
+func SaveUser(rootDir, user string, layout Layout) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return save(userFile(rootDir, user), layout)
+}
+
+// DeleteUser removes the own layout of user, who sees the global layout again
+func DeleteUser(rootDir, user string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := os.Remove(userFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Normalize checks the widgets of the layout and fills in their defaults
+func (l *Layout) Normalize() error {
+	if len(l.Widgets) > MaxWidgets {
+		return fmt.Errorf("a dashboard holds at most %d widgets", MaxWidgets)
+	}
+	content := false
+	for i := range l.Widgets {
+		w := &l.Widgets[i]
+		if !validType(w.Type) {
+			return fmt.Errorf("unknown widget type %q", w.Type)
+		}
+		if w.Type == WidgetContent {
+			if content {
+				return fmt.Errorf("the home page can be shown once")
+			}
+			content = true
+		}
+
+		w.Title = strings.TrimSpace(w.Title)
+		switch w.Width {
+		case "":
+			w.Width = WidthFull
+		case WidthFull, WidthHalf:
+		default:
+			return fmt.Errorf("unknown widget width %q", w.Width)
+		}
+		if w.Limit < 0 || w.Limit > MaxLimit {
+			return fmt.Errorf("widgets list between 1 and %d entries", MaxLimit)
+		}
+
+		if w.Type != WidgetPinned {
+			w.Pages = nil
+			continue
+		}
+		var pages []string
+		for _, page := range w.Pages {
+			page = strings.Trim(strings.TrimSpace(page), "/")
+			if strings.Contains(page, "..") {
+				return fmt.Errorf("invalid page path %q", page)
+			}
+			pages = append(pages, page)
+		}
+		if len(pages) > MaxPages {
+			return fmt.Errorf("a widget pins at most %d pages", MaxPages)
+		}
+		w.Pages = pages
+	}
+	return nil
+}
+
+// HasContent reports whether the layout shows the home page document
+func (l Layout) HasContent() bool {
+	for _, w := range l.Widgets {
+		if w.Type == WidgetContent {
+			return true
+		}
+	}
+	return false
+}
+
+func validType(t string) bool {
+	for _, known := range widgetTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads a stored layout and reports whether there was one
+func load(file string) (Layout, bool, error) {
+	var layout Layout
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return layout, false, nil
+	}
+	if err != nil {
+		return layout, false, err
+	}
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return layout, false, err
+	}
+	if err := layout.Normalize(); err != nil {
+		return layout, false, err
+	}
+	return layout, true, nil
+}
+
+// save stores a layout
+func save(file string, layout Layout) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if layout.Widgets == nil {
+		layout.Widgets = []Widget{}
+	}
+	data, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(file, data, 0644)
+}
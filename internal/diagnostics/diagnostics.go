@@ -0,0 +1,81 @@
+package diagnostics
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage is the memory held by one part of the wiki
+type Usage struct {
+	Name  string `json:"name"`
+	Items int    `json:"items"`
+	Bytes int64  `json:"bytes"` // Estimated from the size of the stored values
+}
+
+// Runtime describes the memory and goroutines of the process
+type Runtime struct {
+	GoVersion  string `json:"goVersion"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heapAlloc"`
+	HeapSys    uint64 `json:"heapSys"`
+	StackInuse uint64 `json:"stackInuse"`
+	Sys        uint64 `json:"sys"`
+	NumGC      uint32 `json:"numGC"`
+	LastGC     string `json:"lastGC,omitempty"`
+	Uptime     string `json:"uptime"`
+}
+
+// Report is what the diagnostics page shows
+type Report struct {
+	Runtime    Runtime `json:"runtime"`
+	Subsystems []Usage `json:"subsystems"`
+}
+
+var (
+	mu        sync.Mutex
+	reporters = make(map[string]func() (items int, bytes int64))
+	started   = time.Now()
+)
+
+// Register adds a part of the wiki to the report. usage returns the number of
+// items it holds and their estimated size in bytes.
+func Register(name string, usage func() (items int, bytes int64)) {
+	mu.Lock()
+	defer mu.Unlock()
+	reporters[name] = usage
+}
+
+// Collect reads the runtime statistics and the usage of every registered part
+func Collect() Report {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	report := Report{
+		Runtime: Runtime{
+			GoVersion:  runtime.Version(),
+			GOMAXPROCS: runtime.GOMAXPROCS(0),
+			Goroutines: runtime.NumGoroutine(),
+			HeapAlloc:  stats.HeapAlloc,
+			HeapSys:    stats.HeapSys,
+			StackInuse: stats.StackInuse,
+			Sys:        stats.Sys,
+			NumGC:      stats.NumGC,
+			Uptime:     time.Since(started).Round(time.Second).String(),
+		},
+	}
+	if stats.LastGC > 0 {
+		report.Runtime.LastGC = time.Unix(0, int64(stats.LastGC)).Format(time.RFC3339)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, usage := range reporters {
+		items, bytes := usage()
+		report.Subsystems = append(report.Subsystems, Usage{Name: name, Items: items, Bytes: bytes})
+	}
+	sort.Slice(report.Subsystems, func(i, j int) bool { return report.Subsystems[i].Name < report.Subsystems[j].Name })
+	return report
+}
@@ -0,0 +1,345 @@
+// Package encryption keeps the pages, attachments and versions of selected
+// directories encrypted on disk with AES-256-GCM, so backups and disk images
+// don't expose them in plain text. Files are decrypted when the wiki reads them.
+//
+// Encrypted files start with a header naming the format, followed by the nonce
+// and the sealed content. Files without the header are read as they are, so
+// code reading pages can use ReadFile whether or not their directory is
+// encrypted.
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/config"
+)
+
+// header marks encrypted files
+const header = "WIKIGO-ENC1\n"
+
+// ErrNoKey is returned when an encrypted file is read without a configured key
+var ErrNoKey = errors.New("file is encrypted but no encryption key is configured")
+
+var (
+	mu       sync.RWMutex
+	aead     cipher.AEAD
+	dirs     []string // Absolute directories whose files are encrypted
+	docsRoot string   // Absolute documents directory
+)
+
+// Configure loads the key and the encrypted directories from cfg. The key is
+// loaded whenever one is set, so files encrypted earlier stay readable after
+// their directory is no longer listed.
+func Configure(cfg *config.Config) error {
+	settings := cfg.Security.Encryption
+	var list []string
+	for _, dir := range strings.Split(settings.Directories, ",") {
+		if strings.TrimSpace(dir) == "" {
+			continue
+		}
+		dir = strings.Trim(filepath.ToSlash(filepath.Clean("/"+strings.TrimSpace(dir))), "/")
+		docs, err := filepath.Abs(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, dir))
+		if err != nil {
+			return err
+		}
+		versions, err := filepath.Abs(filepath.Join(cfg.Wiki.RootDir, "versions", "documents", dir))
+		if err != nil {
+			return err
+		}
+		list = append(list, docs, versions)
+	}
+
+	key, err := loadKey(settings.Key, settings.KeyCommand)
+	if err != nil {
+		return err
+	}
+	if key == nil && len(list) > 0 {
+		return errors.New("security.encryption.directories is set but neither key nor key_command is")
+	}
+
+	var gcm cipher.AEAD
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if gcm, err = cipher.NewGCM(block); err != nil {
+			return err
+		}
+	}
+
+	root, err := filepath.Abs(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir))
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	aead = gcm
+	dirs = list
+	docsRoot = root
+	mu.Unlock()
+	return nil
+}
+
+// loadKey decodes the configured key or runs the key command
+func loadKey(encoded, command string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" && strings.TrimSpace(command) != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		args := strings.Fields(command)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("encryption key command failed: %v %s", err, strings.TrimSpace(stderr.String()))
+		}
+		encoded = strings.TrimSpace(string(out))
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("the encryption key must be 32 bytes, base64 encoded (e.g. openssl rand -base64 32)")
+	}
+	return key, nil
+}
+
+// Migrate brings the pages, attachments and versions of the wiki in line with
+// the configured directories, encrypting files of newly listed directories and
+// decrypting those of directories no longer listed. It returns the number of
+// changed files.
+func Migrate(cfg *config.Config) (int, error) {
+	mu.RLock()
+	keyed := aead != nil
+	mu.RUnlock()
+	if !keyed {
+		return 0, nil
+	}
+
+	total := 0
+	for _, root := range []string{
+		filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir),
+		filepath.Join(cfg.Wiki.RootDir, "versions", "documents"),
+	} {
+		changed, err := SyncTree(root)
+		total += changed
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Enabled reports whether any directory is encrypted
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(dirs) > 0
+}
+
+// Encrypted reports whether files at path are kept encrypted
+func Encrypted(path string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(dirs) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		if abs == dir || strings.HasPrefix(abs, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// PageEncrypted reports whether the page at docPath, a path below the
+// documents directory, is kept encrypted
+func PageEncrypted(docPath string) bool {
+	mu.RLock()
+	root := docsRoot
+	mu.RUnlock()
+	if root == "" {
+		return false
+	}
+	return Encrypted(filepath.Join(root, filepath.FromSlash(strings.Trim(docPath, "/")), "document.md"))
+}
+
+// Sealed reports whether data is the content of an encrypted file
+func Sealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header))
+}
+
+// ReadFile reads a file, decrypting it when it is encrypted
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !Sealed(data) {
+		return data, err
+	}
+	return open(data)
+}
+
+// Open returns a reader for the content of a file, decrypted when it is encrypted
+func Open(path string) (io.ReadSeeker, error) {
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// WriteFile writes a file, encrypting it when it lies in an encrypted directory
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if Encrypted(path) {
+		sealed, err := seal(data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// WriteSealedFile writes a file encrypted wherever it lies, for content of
+// encrypted pages kept outside of their directories, like suggested changes
+func WriteSealedFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, perm)
+}
+
+// Sync encrypts or decrypts a file in place so it matches its directory, after
+// it was written, copied or moved without going through WriteFile
+func Sync(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	want := Encrypted(path)
+	if Sealed(data) == want {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if want {
+		data, err = seal(data)
+	} else {
+		data, err = open(data)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, info.Mode().Perm())
+}
+
+// records are the metadata files kept next to page versions, which are never encrypted
+var records = map[string]bool{"history.json": true, "uploads.json": true, "translations.json": true}
+
+// SyncTree runs Sync on the files below root. Hidden files and the records kept
+// next to page versions are left as they are. It returns the number of changed files.
+func SyncTree(root string) (int, error) {
+	changed := 0
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != root {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || (records[info.Name()] && strings.Contains(path, string(filepath.Separator)+"versions"+string(filepath.Separator))) {
+			return nil
+		}
+		before, err := sealedFile(path)
+		if err != nil {
+			return err
+		}
+		if before == Encrypted(path) {
+			return nil
+		}
+		if err := Sync(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		changed++
+		return nil
+	})
+	return changed, err
+}
+
+// sealedFile reports whether the file at path is encrypted, reading only its header
+func sealedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	buf := make([]byte, len(header))
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return Sealed(buf[:n]), nil
+}
+
+func seal(data []byte) ([]byte, error) {
+	mu.RLock()
+	gcm := aead
+	mu.RUnlock()
+	if gcm == nil {
+		return nil, ErrNoKey
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(header), nonce...)
+	return gcm.Seal(out, nonce, data, []byte(header)), nil
+}
+
+func open(data []byte) ([]byte, error) {
+	mu.RLock()
+	gcm := aead
+	mu.RUnlock()
+	if gcm == nil {
+		return nil, ErrNoKey
+	}
+	data = data[len(header):]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(header))
+	if err != nil {
+		return nil, errors.New("encrypted file can't be decrypted with the configured key")
+	}
+	return plain, nil
+}
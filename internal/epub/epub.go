@@ -0,0 +1,286 @@
+// Package epub writes e-books in the EPUB 3 format.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Chapter is a part of a book
+type Chapter struct {
+	Title string
+	Depth int    // Nesting in the table of contents, 0 for the top level
+	Body  string // XHTML content of the body element
+}
+
+// Image is a file embedded in a book, referred to as images/Name by chapters
+type Image struct {
+	Name      string
+	MediaType string
+	Data      []byte
+}
+
+// Book is an e-book with its metadata
+type Book struct {
+	Title      string
+	Author     string
+	Publisher  string
+	Language   string
+	Identifier string // Unique id of the book, a random urn:uuid when empty
+	Modified   time.Time
+	Cover      *Image // Cover image, a page with the title is generated when nil
+	Chapters   []Chapter
+	Images     []Image
+	Stylesheet string
+}
+
+// ValidBody reports whether a chapter body is well-formed XHTML
+func ValidBody(body string) error {
+	decoder := xml.NewDecoder(strings.NewReader("<body>" + body + "</body>"))
+	decoder.Strict = true
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Write writes the book as an EPUB file
+func (b Book) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	// The media type comes first and uncompressed so readers can identify the file
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	identifier := b.Identifier
+	if identifier == "" {
+		identifier = newUUID()
+	}
+	language := b.Language
+	if language == "" {
+		language = "en"
+	}
+	cover := b.Cover
+	if cover == nil {
+		cover = &Image{Name: "cover.svg", MediaType: "image/svg+xml", Data: coverSVG(b.Title, b.Author)}
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{"OEBPS/content.opf", b.packageDocument(identifier, language, cover)},
+		{"OEBPS/nav.xhtml", b.navigation(language)},
+		{"OEBPS/style.css", []byte(b.Stylesheet)},
+		{"OEBPS/cover.xhtml", page(language, b.Title, fmt.Sprintf(`<section epub:type="cover" class="cover"><img src="images/%s" alt="%s"/></section>`, cover.Name, escape(b.Title)))},
+		{"OEBPS/images/" + cover.Name, cover.Data},
+	}
+	for i, chapter := range b.Chapters {
+		files = append(files, struct {
+			name    string
+			content []byte
+		}{fmt.Sprintf("OEBPS/%s", chapterFile(i)), page(language, chapter.Title, chapter.Body)})
+	}
+	for _, image := range b.Images {
+		files = append(files, struct {
+			name    string
+			content []byte
+		}{"OEBPS/images/" + image.Name, image.Data})
+	}
+
+	for _, file := range files {
+		out, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ChapterFile returns the file name of the chapter at index, for links between chapters
+func ChapterFile(index int) string {
+	return chapterFile(index)
+}
+
+func chapterFile(index int) string {
+	return fmt.Sprintf("chapter-%03d.xhtml", index+1)
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument lists the metadata, files and reading order of the book
+func (b Book) packageDocument(identifier, language string, cover *Image) []byte {
+	var buf bytes.Buffer
+	modified := b.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + escape(language) + `">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&buf, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", escape(identifier))
+	fmt.Fprintf(&buf, "    <dc:title>%s</dc:title>\n", escape(b.Title))
+	fmt.Fprintf(&buf, "    <dc:language>%s</dc:language>\n", escape(language))
+	if b.Author != "" {
+		fmt.Fprintf(&buf, "    <dc:creator>%s</dc:creator>\n", escape(b.Author))
+	}
+	if b.Publisher != "" {
+		fmt.Fprintf(&buf, "    <dc:publisher>%s</dc:publisher>\n", escape(b.Publisher))
+	}
+	fmt.Fprintf(&buf, "    <meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	buf.WriteString("    <meta name=\"cover\" content=\"cover-image\"/>\n  </metadata>\n  <manifest>\n")
+
+	buf.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+`)
+	fmt.Fprintf(&buf, "    <item id=\"cover-image\" href=\"images/%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", escape(cover.Name), escape(cover.MediaType))
+	for i, chapter := range b.Chapters {
+		// Readers need to know which chapters contain inline SVG and MathML
+		var properties []string
+		if strings.Contains(chapter.Body, "<svg") {
+			properties = append(properties, "svg")
+		}
+		if strings.Contains(chapter.Body, "<math") {
+			properties = append(properties, "mathml")
+		}
+		attr := ""
+		if len(properties) > 0 {
+			attr = fmt.Sprintf(" properties=\"%s\"", strings.Join(properties, " "))
+		}
+		fmt.Fprintf(&buf, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"%s/>\n", i+1, chapterFile(i), attr)
+	}
+	for i, image := range b.Images {
+		fmt.Fprintf(&buf, "    <item id=\"image-%d\" href=\"images/%s\" media-type=\"%s\"/>\n", i+1, escape(image.Name), escape(image.MediaType))
+	}
+
+	buf.WriteString("  </manifest>\n  <spine>\n    <itemref idref=\"cover\" linear=\"no\"/>\n")
+	for i := range b.Chapters {
+		fmt.Fprintf(&buf, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	buf.WriteString("  </spine>\n</package>\n")
+	return buf.Bytes()
+}
+
+// navigation is the table of contents, nested by the depth of the chapters
+func (b Book) navigation(language string) []byte {
+	var buf strings.Builder
+	buf.WriteString(`<nav epub:type="toc" id="toc"><h1>` + escape(b.Title) + "</h1>\n")
+	depth := -1
+	for i, chapter := range b.Chapters {
+		target := max(chapter.Depth, 0)
+		if target > depth+1 {
+			target = depth + 1
+		}
+		switch {
+		case target > depth:
+			buf.WriteString("<ol>\n")
+		case target == depth:
+			buf.WriteString("</li>\n")
+		default:
+			for ; depth > target; depth-- {
+				buf.WriteString("</li>\n</ol>\n")
+			}
+			buf.WriteString("</li>\n")
+		}
+		depth = target
+		fmt.Fprintf(&buf, `<li><a href="%s">%s</a>`, chapterFile(i), escape(chapter.Title))
+	}
+	for ; depth >= 0; depth-- {
+		buf.WriteString("</li>\n</ol>\n")
+	}
+	buf.WriteString("</nav>")
+	return page(language, b.Title, buf.String())
+}
+
+// page wraps a body in an XHTML document
+func page(language, title, body string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + escape(language) + `" lang="` + escape(language) + `">
+<head>
+<meta charset="UTF-8"/>
+<title>` + escape(title) + `</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+` + body + `
+</body>
+</html>
+`)
+}
+
+// coverSVG draws a cover with the title and author
+func coverSVG(title, author string) []byte {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(title) {
+		if line != "" && len([]rune(line+" "+word)) > 18 {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = strings.TrimSpace(line + " " + word)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	var buf strings.Builder
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="1200" height="1600" viewBox="0 0 1200 1600">
+<rect width="1200" height="1600" fill="#2c3e50"/>
+<rect x="80" y="80" width="1040" height="1440" fill="none" stroke="#ecf0f1" stroke-width="4"/>
+`)
+	for i, l := range lines {
+		fmt.Fprintf(&buf, `<text x="600" y="%d" font-family="serif" font-size="96" fill="#ecf0f1" text-anchor="middle">%s</text>`+"\n", 600+i*120, escape(l))
+	}
+	if author != "" {
+		fmt.Fprintf(&buf, `<text x="600" y="1400" font-family="sans-serif" font-size="48" fill="#bdc3c7" text-anchor="middle">%s</text>`+"\n", escape(author))
+	}
+	buf.WriteString("</svg>\n")
+	return []byte(buf.String())
+}
+
+// escape escapes text for XML
+func escape(text string) string {
+	return html.EscapeString(text)
+}
+
+// newUUID returns a random identifier for a book
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,192 @@
+package excerpt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/diagnostics"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+)
+
+// cacheFile holds the cached excerpts in the data directory
+const cacheFile = "excerpts.json"
+
+// saveDelay groups the excerpts made while listing a directory into one write
+const saveDelay = 2 * time.Second
+
+// entry is the cached excerpt of a page file
+type entry struct {
+	Modified time.Time `json:"modified"`
+	Size     int64     `json:"size"`
+	Hash     string    `json:"hash"`
+	Text     string    `json:"text"`
+	Source   string    `json:"source"`
+	Image    string    `json:"image,omitempty"` // First image, as written in the page
+}
+
+// Page is the excerpt and thumbnail of a page
+type Page struct {
+	Text  string
+	Image string // URL of the first image of the page
+}
+
+var (
+	mu        sync.Mutex
+	entries   map[string]*entry // By file path relative to the wiki root, nil until loaded
+	rootDir   string
+	saveTimer *time.Timer
+)
+
+func init() {
+	diagnostics.Register("excerpts", func() (int, int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		size := int64(0)
+		for file, e := range entries {
+			size += int64(len(file) + len(e.Hash) + len(e.Text) + len(e.Source) + len(e.Image) + 64)
+		}
+		return len(entries), size
+	})
+}
+
+// Get returns the excerpt of a page file, such as documents/guide/document.md
+func Get(cfg *config.Config, file string) string {
+	return Lookup(cfg, file).Text
+}
+
+// Lookup returns the excerpt and thumbnail of a page file. Those of encrypted
+// pages are made each time and never cached or summarized.
+func Lookup(cfg *config.Config, file string) Page {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Page{}
+	}
+	rel, err := filepath.Rel(cfg.Wiki.RootDir, file)
+	if err != nil {
+		return Page{}
+	}
+	key := filepath.ToSlash(rel)
+
+	if encryption.Encrypted(file) {
+		content, err := encryption.ReadFile(file)
+		if err != nil {
+			return Page{}
+		}
+		e := newEntry(string(content))
+		return e.page(cfg, key)
+	}
+
+	mu.Lock()
+	load(cfg.Wiki.RootDir)
+	cached := entries[key]
+	mu.Unlock()
+	if cached != nil && cached.Modified.Equal(info.ModTime()) && cached.Size == info.Size() {
+		if cached.Source == SourceParagraph && SummariesEnabled(cfg) {
+			enqueueSummary(key)
+		}
+		return cached.page(cfg, key)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return Page{}
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	var e *entry
+	if cached != nil && cached.Hash == hash {
+		// Touched but not changed, an AI summary is still valid
+		updated := *cached
+		e = &updated
+	} else {
+		e = newEntry(string(content))
+		e.Hash = hash
+	}
+	e.Modified, e.Size = info.ModTime(), info.Size()
+
+	mu.Lock()
+	entries[key] = e
+	scheduleSave()
+	mu.Unlock()
+
+	if e.Source == SourceParagraph && SummariesEnabled(cfg) {
+		enqueueSummary(key)
+	}
+	return e.page(cfg, key)
+}
+
+// newEntry makes the excerpt of a page from its content
+func newEntry(content string) *entry {
+	e := &entry{Image: FirstImage(publicBody(content))}
+	e.Text, e.Source = Generate(content)
+	return e
+}
+
+// page returns the excerpt of the page file key, with the URL of its image
+func (e *entry) page(cfg *config.Config, key string) Page {
+	result := Page{Text: e.Text}
+	if e.Image != "" {
+		result.Image = goldext.FileURL(e.Image, pagePath(cfg, key))
+	}
+	return result
+}
+
+// load reads the cache the first time it is used. The caller holds mu.
+func load(root string) {
+	if entries != nil {
+		return
+	}
+	rootDir = root
+	entries = make(map[string]*entry)
+	data, err := os.ReadFile(filepath.Join(root, cacheFile))
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Warning: excerpt cache is damaged, excerpts are made again: %v", err)
+		entries = make(map[string]*entry)
+	}
+}
+
+// scheduleSave writes the cache shortly. The caller holds mu.
+func scheduleSave() {
+	if saveTimer != nil {
+		return
+	}
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		mu.Lock()
+		saveTimer = nil
+		data, err := json.Marshal(entries)
+		root := rootDir
+		mu.Unlock()
+		if err == nil {
+			err = os.WriteFile(filepath.Join(root, cacheFile), data, 0644)
+		}
+		if err != nil {
+			log.Printf("Error saving excerpt cache: %v", err)
+		}
+	})
+}
+
+// setSummary replaces the excerpt of key with an AI summary, unless the page
+// changed since hash was read
+func setSummary(key, hash, summary string) {
+	mu.Lock()
+	defer mu.Unlock()
+	e := entries[key]
+	if e == nil || e.Hash != hash {
+		return
+	}
+	updated := *e
+	updated.Text, updated.Source = summary, SourceAI
+	entries[key] = &updated
+	scheduleSave()
+}
@@ -0,0 +1,141 @@
+// Package excerpt makes the short summary of a page shown in search results,
+// directory listings, link previews and the description meta tags. The
+// excerpt is the description set in the frontmatter, the first paragraph of
+// the page or, when enabled, a summary written by the AI model. Excerpts are
+// cached and made again when the page changes.
+package excerpt
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/permissions"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// MaxLength is the longest excerpt, in characters
+const MaxLength = 200
+
+// Sources of an excerpt
+const (
+	SourceFrontmatter = "frontmatter"
+	SourceParagraph   = "paragraph"
+	SourceAI          = "ai"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var (
+	wikiLinkPattern  = regexp.MustCompile(`\[\[([^\]|]+)(?:\|([^\]]+))?\]\]`)
+	wikiBlockPattern = regexp.MustCompile(`^(:::|\[toc\]|\{\{)`)
+	spacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// Generate returns the excerpt of a page and where it came from: the
+// frontmatter description or the text of the first paragraph. Content only
+// some readers may see is left out.
+func Generate(content string) (string, string) {
+	metadata, _, ok := frontmatter.Parse(content)
+	if ok && strings.TrimSpace(metadata.Description) != "" {
+		return Truncate(strings.TrimSpace(metadata.Description), MaxLength), SourceFrontmatter
+	}
+	return FirstParagraph(publicBody(content)), SourceParagraph
+}
+
+// publicBody returns the markdown of a page every reader sees, without its
+// frontmatter
+func publicBody(content string) string {
+	if _, body, ok := frontmatter.Parse(content); ok {
+		content = body
+	}
+	return goldext.FilterAudience(content, permissions.Subject{})
+}
+
+// FirstImage returns the destination of the first image of markdown, as
+// written in the page
+func FirstImage(body string) string {
+	source := []byte(body)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+	image := ""
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n, ok := node.(*ast.Image); ok && entering {
+			image = string(n.Destination)
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return image
+}
+
+// FirstParagraph returns the plain text of the first paragraph of markdown,
+// skipping headings, code, tables, images and wiki blocks
+func FirstParagraph(body string) string {
+	body = wikiLinkPattern.ReplaceAllStringFunc(body, func(link string) string {
+		m := wikiLinkPattern.FindStringSubmatch(link)
+		if m[2] != "" {
+			return m[2]
+		}
+		return m[1]
+	})
+	source := []byte(body)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		paragraph, ok := node.(*ast.Paragraph)
+		if !ok {
+			continue
+		}
+		raw := string(paragraph.Lines().Value(source))
+		if wikiBlockPattern.MatchString(strings.TrimSpace(raw)) {
+			continue
+		}
+		var b strings.Builder
+		plainText(&b, paragraph, source)
+		result := strings.TrimSpace(spacePattern.ReplaceAllString(b.String(), " "))
+		if result != "" {
+			return Truncate(result, MaxLength)
+		}
+	}
+	return ""
+}
+
+// plainText writes the text of the inline nodes below node, without images
+// and HTML
+func plainText(b *strings.Builder, node ast.Node, source []byte) {
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.AutoLink:
+			b.Write(n.Label(source))
+		case *ast.Image, *ast.RawHTML:
+		default:
+			plainText(b, child, source)
+		}
+	}
+}
+
+// Truncate shortens text to at most max characters, cutting at a word
+func Truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:max-1])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-") + "…"
+}
@@ -0,0 +1,145 @@
+package excerpt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/config"
+	"wiki-go/internal/jobs"
+)
+
+// JobType is the background job writing the AI summary of a page
+const JobType = "page-summary"
+
+// summaryPrompt asks for an excerpt fitting MaxLength
+const summaryPrompt = "Write a one or two sentence description of the wiki page you are given, at most 160 characters long, " +
+	"in the language of the page, as plain text without markdown. Describe what the page is about rather than " +
+	"starting with \"This page\". Answer with the description only."
+
+// retryDelay is how long a page whose summary failed keeps its first paragraph
+// before the summary is tried again
+const retryDelay = time.Hour
+
+// SummaryJob is the payload of a JobType job
+type SummaryJob struct {
+	File string `json:"file"` // Page file relative to the wiki root
+}
+
+var (
+	pendingMu sync.Mutex
+	pending   = make(map[string]time.Time) // Files with a queued or failed summary
+)
+
+// SummariesEnabled reports whether excerpts are written by the AI model
+func SummariesEnabled(cfg *config.Config) bool {
+	return cfg.Extensions.AI.Enable && cfg.Extensions.AI.Summaries
+}
+
+// enqueueSummary queues the AI summary of a page file once
+func enqueueSummary(key string) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	if queued, ok := pending[key]; ok && time.Since(queued) < retryDelay {
+		return
+	}
+	if _, err := jobs.Enqueue(JobType, SummaryJob{File: key}); err != nil {
+		log.Printf("Error queueing summary of %s: %v", key, err)
+		return
+	}
+	pending[key] = time.Now()
+}
+
+// RunSummaryJob writes the AI summary of the page of a JobType job
+func RunSummaryJob(cfg *config.Config, payload json.RawMessage) error {
+	var job SummaryJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	if !SummariesEnabled(cfg) {
+		return nil
+	}
+	if err := runSummary(cfg, job.File); err != nil {
+		// The page keeps its first paragraph until retryDelay has passed
+		pendingMu.Lock()
+		pending[job.File] = time.Now()
+		pendingMu.Unlock()
+		return err
+	}
+	pendingMu.Lock()
+	delete(pending, job.File)
+	pendingMu.Unlock()
+	return nil
+}
+
+// runSummary asks the model for the summary of a page file and caches it
+func runSummary(cfg *config.Config, key string) error {
+
+	file := filepath.Join(cfg.Wiki.RootDir, filepath.FromSlash(key))
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// Only the content every reader sees is sent
+	body := publicBody(string(content))
+	if maxInput := cfg.Extensions.AI.MaxInputKB * 1024; maxInput > 0 && len(body) > maxInput {
+		body = Truncate(body, maxInput)
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil
+	}
+
+	client := ai.NewClient(cfg)
+	start := time.Now()
+	entry := ai.Entry{
+		Time:   start,
+		Action: "excerpt",
+		Page:   pagePath(cfg, key),
+		Model:  client.Model,
+	}
+	completion, err := client.Complete(summaryPrompt, body)
+	entry.InputChars = utf8.RuneCountInString(body)
+	entry.OutputChars = utf8.RuneCountInString(completion.Text)
+	entry.PromptTokens = completion.PromptTokens
+	entry.CompletionTokens = completion.CompletionTokens
+	entry.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if logErr := ai.Log(cfg.Wiki.RootDir, entry); logErr != nil {
+		log.Printf("Error logging AI request: %v", logErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	summary := strings.Trim(spacePattern.ReplaceAllString(strings.TrimSpace(completion.Text), " "), `"`)
+	if summary != "" {
+		setSummary(key, hash, Truncate(summary, MaxLength))
+	}
+	return nil
+}
+
+// pagePath returns the URL path of the page a file belongs to
+func pagePath(cfg *config.Config, file string) string {
+	dir := path.Dir(file)
+	if dir == "pages/home" {
+		return "/"
+	}
+	return "/" + strings.TrimPrefix(dir, filepath.ToSlash(cfg.Wiki.DocumentsDir)+"/")
+}
@@ -0,0 +1,185 @@
+// Package favorites keeps the pages each user starred, listed in the sidebar
+// and on the dashboard, and the pages admins pinned to the top of directory
+// listings for everybody.
+package favorites
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/store"
+)
+
+// MaxPages is the number of pages a favorites list or the pins hold
+const MaxPages = 100
+
+// ErrTooMany is returned when a list would hold more than MaxPages pages
+var ErrTooMany = errors.New("too many pages in the list")
+
+// List is the favorites list of a user
+type List struct {
+	User  string   `json:"user"`
+	Pages []string `json:"pages"` // Page paths without slashes around them, in the order they were starred
+}
+
+// Pins are the pages pinned to the top of their directory
+type Pins struct {
+	Pages []string `json:"pages"` // Page paths without slashes around them, in the order they were pinned
+}
+
+var (
+	mutex          store.DataFile // Guards changes; lists are read without it, since files are replaced at once
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+)
+
+// userFile returns the file holding the favorites of a user
+func userFile(rootDir, user string) string {
+	return filepath.Join(rootDir, "favorites", unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// pinsFile returns the file holding the pinned pages
+func pinsFile(rootDir string) string {
+	return filepath.Join(rootDir, "pins.json")
+}
+
+// Clean returns path the way it is stored in the lists
+func Clean(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// Pages returns the favorites of user, in the order they were starred
+func Pages(rootDir, user string) ([]string, error) {
+	list := List{Pages: []string{}}
+	if err := load(userFile(rootDir, user), &list); err != nil {
+		return nil, err
+	}
+	return list.Pages, nil
+}
+
+// Set adds the page at path to the favorites of user, or removes it when
+// favorite is false
+func Set(rootDir, user, path string, favorite bool) error {
+	unlock := mutex.Lock("favorites/" + user)
+	defer unlock()
+
+	file := userFile(rootDir, user)
+	var list List
+	if err := load(file, &list); err != nil {
+		return err
+	}
+	list.User = user
+	list.Pages = toggle(list.Pages, Clean(path), favorite)
+
+	if len(list.Pages) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if len(list.Pages) > MaxPages {
+		return ErrTooMany
+	}
+	return save(file, list)
+}
+
+// Delete removes the favorites of user
+func Delete(rootDir, user string) error {
+	unlock := mutex.Lock("favorites/" + user)
+	defer unlock()
+
+	if err := os.Remove(userFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Pinned returns the pinned pages, in the order they were pinned
+func Pinned(rootDir string) ([]string, error) {
+	pins := Pins{Pages: []string{}}
+	if err := load(pinsFile(rootDir), &pins); err != nil {
+		return nil, err
+	}
+	return pins.Pages, nil
+}
+
+// SetPinned pins the page at path to the top of its directory, or unpins it
+// when pinned is false
+func SetPinned(rootDir, path string, pinned bool) error {
+	unlock := mutex.Lock("pins.json")
+	defer unlock()
+
+	file := pinsFile(rootDir)
+	var pins Pins
+	if err := load(file, &pins); err != nil {
+		return err
+	}
+	pins.Pages = toggle(pins.Pages, Clean(path), pinned)
+
+	if len(pins.Pages) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if len(pins.Pages) > MaxPages {
+		return ErrTooMany
+	}
+	return save(file, pins)
+}
+
+// Contains reports whether pages holds the page at path
+func Contains(pages []string, path string) bool {
+	path = Clean(path)
+	for _, page := range pages {
+		if page == path {
+			return true
+		}
+	}
+	return false
+}
+
+// toggle appends path to pages when add is true and removes it otherwise, so
+// pages keep the order they were added in
+func toggle(pages []string, path string, add bool) []string {
+	if add && Contains(pages, path) {
+		return pages
+	}
+	kept := make([]string, 0, len(pages)+1)
+	for _, page := range pages {
+		if page != path {
+			kept = append(kept, page)
+		}
+	}
+	if add {
+		kept = append(kept, path)
+	}
+	return kept
+}
+
+// load reads a stored list into v, leaving it empty when there is none
+func load(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// save stores a list
+func save(file string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(file, data, 0644)
+}
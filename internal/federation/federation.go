@@ -0,0 +1,319 @@
+package federation
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+	"wiki-go/internal/jobs"
+	"wiki-go/internal/store"
+)
+
+// JobType is the background job syncing one source
+const JobType = "federation-sync"
+
+// Interval between syncs of sources that don't set one
+const defaultInterval = 24 * time.Hour
+
+// Source is a directory pulled from another wiki-go instance or a git
+// repository into this wiki. The local copy is read-only and replaced by each sync.
+type Source struct {
+	Name        string `yaml:"name"`
+	URL         string `yaml:"url,omitempty"`          // Base URL of another wiki-go instance
+	Git         string `yaml:"git,omitempty"`          // URL of a git repository
+	Branch      string `yaml:"branch,omitempty"`       // Git branch, the default branch when empty
+	Path        string `yaml:"path,omitempty"`         // Remote directory to copy, everything when empty
+	Target      string `yaml:"target"`                 // Local directory holding the copy
+	Every       string `yaml:"every,omitempty"`        // Interval between syncs, e.g. 30m, 6h or 7d
+	TokenSecret string `yaml:"token_secret,omitempty"` // Secret sent as bearer token to the remote wiki
+
+	interval time.Duration
+}
+
+// Grant lets other wikis pull a directory of this wiki with a token
+type Grant struct {
+	Path        string `yaml:"path"`
+	TokenSecret string `yaml:"token_secret"` // Secret the pulling wiki sends as bearer token
+}
+
+// File holds the sources pulled into this wiki and the directories it publishes
+type File struct {
+	Sources []Source `yaml:"sources,omitempty"`
+	Publish []Grant  `yaml:"publish,omitempty"`
+}
+
+// State is the outcome of the syncs of a source
+type State struct {
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastSync    time.Time `json:"lastSync,omitempty"` // Last successful sync
+	Pages       int       `json:"pages"`
+	Files       int       `json:"files"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// cache avoids re-reading the federation file on every request
+var cache filecache.Cache[*File]
+
+// stateMu guards the state file
+var stateMu store.DataFile
+
+// FilePath returns the location of the federation settings
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "federation.yaml")
+}
+
+// statePath returns the location of the sync state of the sources
+func statePath(rootDir string) string {
+	return filepath.Join(rootDir, "federation-state.json")
+}
+
+// Load returns the current settings, empty ones when the file does not exist
+func Load(rootDir string) (*File, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML federation settings
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Sources {
+		source := &file.Sources[i]
+		if source.Name == "" || names[source.Name] {
+			return nil, fmt.Errorf("source %d: set a unique name", i+1)
+		}
+		names[source.Name] = true
+		if (source.URL == "") == (source.Git == "") {
+			return nil, fmt.Errorf("source %q: set either url or git", source.Name)
+		}
+		if source.URL != "" {
+			u, err := url.Parse(source.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("source %q: url must be an http or https address", source.Name)
+			}
+		}
+		if strings.HasPrefix(source.Git, "-") || strings.HasPrefix(source.Branch, "-") {
+			return nil, fmt.Errorf("source %q: invalid git repository", source.Name)
+		}
+		source.Path = normalize(source.Path)
+		source.Target = normalize(source.Target)
+		if source.Target == "" {
+			return nil, fmt.Errorf("source %q: target is required", source.Name)
+		}
+		source.interval = defaultInterval
+		if source.Every != "" {
+			interval, err := parseInterval(source.Every)
+			if err != nil {
+				return nil, fmt.Errorf("source %q: %w", source.Name, err)
+			}
+			source.interval = interval
+		}
+	}
+	for i, source := range file.Sources {
+		for _, other := range file.Sources[i+1:] {
+			if within(source.Target, other.Target) || within(other.Target, source.Target) {
+				return nil, fmt.Errorf("sources %q and %q have overlapping targets", source.Name, other.Name)
+			}
+		}
+	}
+
+	for i := range file.Publish {
+		grant := &file.Publish[i]
+		grant.Path = normalize(grant.Path)
+		if grant.TokenSecret == "" {
+			return nil, fmt.Errorf("publish %d: token_secret is required", i+1)
+		}
+	}
+
+	return &file, nil
+}
+
+// ReadRaw returns the federation file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the federation file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// Source returns the source with the given name
+func (f *File) Source(name string) (Source, bool) {
+	for _, source := range f.Sources {
+		if source.Name == name {
+			return source, true
+		}
+	}
+	return Source{}, false
+}
+
+// SourceFor returns the source whose copy contains the page at path
+func (f *File) SourceFor(path string) (Source, bool) {
+	path = normalize(path)
+	for _, source := range f.Sources {
+		if within(path, source.Target) {
+			return source, true
+		}
+	}
+	return Source{}, false
+}
+
+// GrantFor returns the grant publishing path whose token is token
+func (f *File) GrantFor(path, token string, secretValues map[string]string) (Grant, bool) {
+	path = normalize(path)
+	for _, grant := range f.Publish {
+		secret, ok := secretValues[grant.TokenSecret]
+		if ok && secret != "" && equalTokens(secret, token) && within(path, grant.Path) {
+			return grant, true
+		}
+	}
+	return Grant{}, false
+}
+
+// Remote describes where the pages of the source come from
+func (s Source) Remote() string {
+	remote := strings.TrimSuffix(s.URL, "/")
+	if s.Git != "" {
+		remote = s.Git
+		if s.Branch != "" {
+			remote += "#" + s.Branch
+		}
+	}
+	if s.Path != "" {
+		remote += " /" + s.Path
+	}
+	return remote
+}
+
+// States returns the sync state of every source by name
+func States(rootDir string) map[string]State {
+	return readStates(rootDir)
+}
+
+func readStates(rootDir string) map[string]State {
+	states := make(map[string]State)
+	data, err := os.ReadFile(statePath(rootDir))
+	if err == nil {
+		if err := json.Unmarshal(data, &states); err != nil {
+			log.Printf("Error reading federation state: %v", err)
+		}
+	}
+	return states
+}
+
+// updateState changes the state of a source
+func updateState(rootDir, name string, change func(*State)) {
+	unlock := stateMu.Lock("federation-state.json")
+	defer unlock()
+
+	states := readStates(rootDir)
+	state := states[name]
+	change(&state)
+	states[name] = state
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err == nil {
+		err = store.WriteFile(statePath(rootDir), data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error saving federation state: %v", err)
+	}
+}
+
+// Enqueue queues a sync of the source
+func Enqueue(rootDir, name string) error {
+	if _, err := jobs.Enqueue(JobType, map[string]string{"source": name}); err != nil {
+		return err
+	}
+	updateState(rootDir, name, func(state *State) { state.LastAttempt = time.Now() })
+	return nil
+}
+
+// StartScheduler queues the syncs of the sources that are due, once at startup
+// and then every interval
+func StartScheduler(rootDir string, interval time.Duration) {
+	go func() {
+		for {
+			queueDue(rootDir, time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// queueDue queues the sources not synced for their interval
+func queueDue(rootDir string, now time.Time) {
+	file, err := Load(rootDir)
+	if err != nil {
+		log.Printf("Error loading federation settings: %v", err)
+		return
+	}
+	states := States(rootDir)
+	for _, source := range file.Sources {
+		if now.Sub(states[source.Name].LastAttempt) < source.interval {
+			continue
+		}
+		if err := Enqueue(rootDir, source.Name); err != nil {
+			log.Printf("Error queueing sync of %s: %v", source.Name, err)
+		}
+	}
+}
+
+// parseInterval reads a duration like 30m or 6h, or a number of days like 7d
+func parseInterval(interval string) (time.Duration, error) {
+	var duration time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(interval, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(interval)
+	}
+	if err != nil || duration < 5*time.Minute {
+		return 0, fmt.Errorf("invalid interval %q, use at least 5m", interval)
+	}
+	return duration, nil
+}
+
+// equalTokens compares tokens in constant time
+func equalTokens(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// within reports whether path is dir or below it. Every path is within "".
+func within(path, dir string) bool {
+	return dir == "" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// normalize turns "/guides/api/" into "guides/api"
+func normalize(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
@@ -0,0 +1,333 @@
+package federation
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/safehttp"
+	"wiki-go/internal/secrets"
+)
+
+// Limits of a single sync
+const (
+	maxArchiveSize = 256 << 20 // Size of the archive downloaded from a wiki
+	maxFiles       = 10000     // Pages and attachments copied
+	syncTimeout    = 5 * time.Minute
+)
+
+// ExportPath is the endpoint of a wiki serving a directory as a zip archive
+const ExportPath = "/api/federation/export"
+
+// Sync pulls the pages of a source and replaces its local copy with them. The
+// copy is only replaced when the whole pull succeeded.
+func Sync(rootDir, documentsDir, name string) error {
+	file, err := Load(rootDir)
+	if err != nil {
+		return err
+	}
+	source, ok := file.Source(name)
+	if !ok {
+		return fmt.Errorf("source %q is not configured", name)
+	}
+
+	pages, files, err := pull(rootDir, documentsDir, source)
+	updateState(rootDir, name, func(state *State) {
+		state.LastAttempt = time.Now()
+		if err != nil {
+			state.Error = err.Error()
+			return
+		}
+		state.LastSync = state.LastAttempt
+		state.Pages = pages
+		state.Files = files
+		state.Error = ""
+	})
+	return err
+}
+
+// pull downloads the source into a staging directory and swaps it with the copy
+func pull(rootDir, documentsDir string, source Source) (int, int, error) {
+	staging, err := os.MkdirTemp(rootDir, ".federation-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(staging)
+
+	fetched := filepath.Join(staging, "fetched")
+	if source.Git != "" {
+		err = fetchGit(source, fetched)
+		fetched = filepath.Join(fetched, filepath.FromSlash(source.Path))
+	} else {
+		err = fetchWiki(source, fetched)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	content := filepath.Join(staging, "content")
+	pages, files, err := collect(fetched, content)
+	if err != nil {
+		return 0, 0, err
+	}
+	if pages == 0 {
+		return 0, 0, errors.New("the source has no pages")
+	}
+
+	target := filepath.Join(rootDir, documentsDir, filepath.FromSlash(source.Target))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, 0, err
+	}
+	previous := filepath.Join(staging, "previous")
+	if err := os.Rename(target, previous); err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	if err := os.Rename(content, target); err != nil {
+		// Put the previous copy back
+		os.Rename(previous, target)
+		return 0, 0, err
+	}
+	if _, err := encryption.SyncTree(target); err != nil {
+		return 0, 0, err
+	}
+	return pages, files, nil
+}
+
+// fetchWiki downloads the directory exported by another wiki-go instance
+func fetchWiki(source Source, dest string) error {
+	base, err := url.Parse(strings.TrimSuffix(source.URL, "/") + ExportPath)
+	if err != nil {
+		return err
+	}
+	base.RawQuery = url.Values{"path": {source.Path}}.Encode()
+
+	policy := safehttp.Policy{
+		AllowedHosts: []string{base.Hostname()},
+		AllowPrivate: true, // Sources are set by admins, and often on the internal network
+		MaxBytes:     maxArchiveSize,
+		Timeout:      syncTimeout,
+		Header:       http.Header{},
+	}
+	if source.TokenSecret != "" {
+		values, err := secrets.Load()
+		if err != nil {
+			return err
+		}
+		token, ok := values[source.TokenSecret]
+		if !ok {
+			return fmt.Errorf("secret %q doesn't exist", source.TokenSecret)
+		}
+		policy.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	status, body, err := policy.Get(base.String())
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", base.Host, status)
+	}
+	return extractZip(body, dest)
+}
+
+// fetchGit makes a shallow clone of the repository
+func fetchGit(source Source, dest string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if source.Branch != "" {
+		args = append(args, "--branch", source.Branch)
+	}
+	args = append(args, "--", source.Git, dest)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git clone failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return os.RemoveAll(filepath.Join(dest, ".git"))
+}
+
+// extractZip unpacks an archive, refusing entries that leave dest
+func extractZip(data []byte, dest string) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+	if len(archive.File) > maxFiles {
+		return fmt.Errorf("the archive has more than %d files", maxFiles)
+	}
+
+	for _, entry := range archive.File {
+		name := filepath.FromSlash(entry.Name)
+		path := filepath.Join(dest, name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid file name %q in archive", entry.Name)
+		}
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(entry, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(entry *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	in, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.LimitReader(in, maxArchiveSize)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// collect copies the pages and attachments found in src into the layout of the
+// wiki. Markdown files other than document.md become pages of their own, and a
+// README.md or index.md becomes the page of its directory.
+func collect(src, dest string) (int, int, error) {
+	var pages, files int
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() {
+			return nil
+		}
+		if pages+files >= maxFiles {
+			return fmt.Errorf("the source has more than %d files", maxFiles)
+		}
+
+		dir, name := filepath.Dir(rel), entry.Name()
+		destination := filepath.Join(dest, rel)
+		if strings.EqualFold(filepath.Ext(name), ".md") {
+			switch base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))); {
+			case name == "document.md":
+			case base == "readme" || base == "index":
+				if _, err := os.Stat(filepath.Join(filepath.Dir(path), "document.md")); err == nil {
+					// The directory already has its page
+					destination = filepath.Join(dest, dir, base, "document.md")
+				} else {
+					destination = filepath.Join(dest, dir, "document.md")
+				}
+			default:
+				destination = filepath.Join(dest, dir, strings.TrimSuffix(name, filepath.Ext(name)), "document.md")
+			}
+			pages++
+		} else {
+			files++
+		}
+		return copyFile(path, destination)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, errors.New("the source directory doesn't exist")
+	}
+	return pages, files, err
+}
+
+// copyFile copies a single file, creating its directory
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// WriteArchive writes the pages and attachments of dir as a zip archive.
+// Directories for which include returns false are left out.
+func WriteArchive(w io.Writer, dir string, include func(rel string) bool) error {
+	archive := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if !include(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		out, err := archive.Create(rel)
+		if err != nil {
+			return err
+		}
+		// Pages of encrypted directories are exported decrypted
+		in, err := encryption.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
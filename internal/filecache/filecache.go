@@ -0,0 +1,63 @@
+// Package filecache keeps what was parsed from the data files admins edit,
+// such as permissions.yaml, until the files change.
+package filecache
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache holds the value parsed from each file it loaded. The zero value is
+// ready to use.
+type Cache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[T]
+}
+
+type entry[T any] struct {
+	modTime time.Time
+	size    int64
+	value   T
+}
+
+// Load returns the value parse made of the file at path, reading the file
+// again only when its modification time or size changed. The errors of
+// os.Stat are returned as they are, so callers can check os.IsNotExist.
+// Values parse fails on aren't kept.
+func (c *Cache[T]) Load(path string, parse func(data []byte) (T, error)) (T, error) {
+	var zero T
+	info, err := os.Stat(path)
+	if err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.entries[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zero, err
+	}
+	value, err := parse(data)
+	if err != nil {
+		return zero, err
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]entry[T])
+	}
+	c.entries[path] = entry[T]{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value, nil
+}
+
+// Forget drops the value of a file, which is read again by the next Load
+func (c *Cache[T]) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
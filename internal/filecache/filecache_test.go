@@ -0,0 +1,66 @@
+package filecache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	var cache Cache[string]
+	parses := 0
+	parse := func(data []byte) (string, error) {
+		parses++
+		if string(data) == "bad" {
+			return "", errors.New("bad file")
+		}
+		return string(data), nil
+	}
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		change  func()
+		want    string
+		wantErr bool
+		parses  int
+	}{
+		{name: "Missing files", change: func() {}, wantErr: true, parses: 0},
+		{name: "First load", change: func() { write("one", modTime) }, want: "one", parses: 1},
+		{name: "Unchanged files are kept", change: func() {}, want: "one", parses: 1},
+		{name: "Changed modification time", change: func() { write("two", modTime.Add(time.Second)) }, want: "two", parses: 2},
+		{name: "Changed size in the same second", change: func() { write("three", modTime.Add(time.Second)) }, want: "three", parses: 3},
+		{name: "Parse errors", change: func() { write("bad", modTime.Add(2*time.Second)) }, wantErr: true, parses: 4},
+		{name: "Parse errors aren't kept", change: func() {}, wantErr: true, parses: 5},
+		{name: "Forgotten files are read again", change: func() { write("four", modTime); cache.Forget(path) }, want: "four", parses: 6},
+	}
+
+	for _, tt := range tests {
+		tt.change()
+		got, err := cache.Load(path, parse)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Load() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Load() = %q, want %q", tt.name, got, tt.want)
+		}
+		if parses != tt.parses {
+			t.Errorf("%s: parsed %d times, want %d", tt.name, parses, tt.parses)
+		}
+	}
+	if _, err := (&Cache[string]{}).Load(filepath.Join(t.TempDir(), "none"), parse); !os.IsNotExist(err) {
+		t.Errorf("Load() of a missing file = %v, want a not exist error", err)
+	}
+}
@@ -0,0 +1,347 @@
+package forms
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Field types supported by structured forms
+const (
+	FieldText     = "text"
+	FieldTextarea = "textarea"
+	FieldNumber   = "number"
+	FieldDate     = "date"
+	FieldSelect   = "select"
+	FieldCheckbox = "checkbox"
+)
+
+// Field describes a single input of a form
+type Field struct {
+	Name     string   `yaml:"name" json:"name"`
+	Label    string   `yaml:"label" json:"label"`
+	Type     string   `yaml:"type" json:"type"` // text, textarea, number, date, select, checkbox
+	Required bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Options  []string `yaml:"options,omitempty" json:"options,omitempty"` // Allowed values for select fields
+	Pattern  string   `yaml:"pattern,omitempty" json:"pattern,omitempty"` // Optional regular expression for text fields
+	Default  string   `yaml:"default,omitempty" json:"default,omitempty"` // Pre-filled value
+	Help     string   `yaml:"help,omitempty" json:"help,omitempty"`       // Hint displayed under the input
+	InBody   bool     `yaml:"in_body,omitempty" json:"in_body,omitempty"` // Keep the value out of frontmatter (e.g. long text)
+}
+
+// Form describes a structured page creation form
+type Form struct {
+	ID          string   `yaml:"id" json:"id"`
+	Title       string   `yaml:"title" json:"title"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	TargetDir   string   `yaml:"target_dir" json:"target_dir"`                       // Directory new pages are created in
+	TitleField  string   `yaml:"title_field,omitempty" json:"title_field,omitempty"` // Field used for the page title and slug
+	Roles       []string `yaml:"roles,omitempty" json:"roles,omitempty"`             // Roles allowed to submit, defaults to admin and editor
+	Template    string   `yaml:"template,omitempty" json:"template,omitempty"`       // Markdown body with {{field}} placeholders
+	Fields      []Field  `yaml:"fields" json:"fields"`
+}
+
+var (
+	idPattern          = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
+)
+
+// formsDir returns the directory holding form definitions
+func formsDir(rootDir string) string {
+	return filepath.Join(rootDir, "forms")
+}
+
+// ValidID reports whether id is safe to use as a form identifier and filename
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// List returns all form definitions sorted by filename
+func List(rootDir string) ([]Form, error) {
+	entries, err := os.ReadDir(formsDir(rootDir))
+	if os.IsNotExist(err) {
+		return []Form{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read forms directory: %w", err)
+	}
+
+	forms := []Form{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		form, err := Get(rootDir, strings.TrimSuffix(entry.Name(), ".yaml"))
+		if err != nil {
+			continue // Skip broken definitions
+		}
+		forms = append(forms, *form)
+	}
+
+	return forms, nil
+}
+
+// Get loads a single form definition by id
+func Get(rootDir, id string) (*Form, error) {
+	if !ValidID(id) {
+		return nil, errors.New("invalid form id")
+	}
+
+	data, err := os.ReadFile(filepath.Join(formsDir(rootDir), id+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var form Form
+	if err := yaml.Unmarshal(data, &form); err != nil {
+		return nil, fmt.Errorf("failed to parse form %s: %w", id, err)
+	}
+	form.ID = id
+
+	return &form, nil
+}
+
+// Save validates and writes a form definition to disk
+func Save(rootDir string, form *Form) error {
+	if err := form.Check(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(formsDir(rootDir), 0755); err != nil {
+		return fmt.Errorf("failed to create forms directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(form); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(formsDir(rootDir), form.ID+".yaml"), buf.Bytes(), 0644)
+}
+
+// Delete removes a form definition
+func Delete(rootDir, id string) error {
+	if !ValidID(id) {
+		return errors.New("invalid form id")
+	}
+	return os.Remove(filepath.Join(formsDir(rootDir), id+".yaml"))
+}
+
+// Check verifies that a form definition is internally consistent
+func (f *Form) Check() error {
+	if !ValidID(f.ID) {
+		return errors.New("form id must contain only lowercase letters, digits, dashes and underscores")
+	}
+	if strings.TrimSpace(f.Title) == "" {
+		return errors.New("form title is required")
+	}
+	if strings.Trim(f.TargetDir, "/ ") == "" {
+		return errors.New("target directory is required")
+	}
+	if len(f.Fields) == 0 {
+		return errors.New("form must define at least one field")
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range f.Fields {
+		if field.Name == "" || !placeholderPattern.MatchString("{{"+field.Name+"}}") {
+			return fmt.Errorf("invalid field name %q", field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("duplicate field name %q", field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case "", FieldText, FieldTextarea, FieldNumber, FieldDate, FieldCheckbox:
+		case FieldSelect:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("select field %q needs options", field.Name)
+			}
+		default:
+			return fmt.Errorf("unknown type %q for field %q", field.Type, field.Name)
+		}
+
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern for field %q: %w", field.Name, err)
+			}
+		}
+	}
+
+	if !seen[f.titleField()] {
+		return fmt.Errorf("title field %q is not defined", f.titleField())
+	}
+
+	return nil
+}
+
+// titleField returns the name of the field used as page title
+func (f *Form) titleField() string {
+	if f.TitleField != "" {
+		return f.TitleField
+	}
+	return "title"
+}
+
+// CanSubmit reports whether a user with the given role may submit the form
+func (f *Form) CanSubmit(role string) bool {
+	if role == "" {
+		return false
+	}
+	if len(f.Roles) == 0 {
+		return role == "admin" || role == "editor"
+	}
+	for _, allowed := range f.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return role == "admin"
+}
+
+// Validate checks submitted values against the field definitions.
+// It returns the normalized values and a map of field name to error message.
+func (f *Form) Validate(values map[string]string) (map[string]string, map[string]string) {
+	clean := make(map[string]string)
+	problems := make(map[string]string)
+
+	for _, field := range f.Fields {
+		value := strings.TrimSpace(values[field.Name])
+		if field.Type == FieldCheckbox {
+			if value == "on" || value == "true" || value == "1" {
+				value = "true"
+			} else {
+				value = "false"
+			}
+		}
+
+		if value == "" {
+			if field.Required {
+				problems[field.Name] = "This field is required"
+			}
+			clean[field.Name] = ""
+			continue
+		}
+
+		switch field.Type {
+		case FieldNumber:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				problems[field.Name] = "Must be a number"
+			}
+		case FieldDate:
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				problems[field.Name] = "Must be a date (YYYY-MM-DD)"
+			}
+		case FieldSelect:
+			valid := false
+			for _, option := range field.Options {
+				if option == value {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				problems[field.Name] = "Not an allowed option"
+			}
+		}
+
+		if field.Pattern != "" {
+			if re, err := regexp.Compile(field.Pattern); err == nil && !re.MatchString(value) {
+				problems[field.Name] = "Invalid format"
+			}
+		}
+
+		clean[field.Name] = value
+	}
+
+	return clean, problems
+}
+
+// PageTitle returns the page title for a set of validated values
+func (f *Form) PageTitle(values map[string]string) string {
+	return values[f.titleField()]
+}
+
+// Render builds the markdown document for a submission, with the field values
+// placed in frontmatter and substituted into the body template
+func (f *Form) Render(values map[string]string, submittedBy string, submittedAt time.Time) (string, error) {
+	// Build the frontmatter as an ordered mapping so fields keep their form order
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	addScalar := func(key, value, tag string) {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value, Tag: tag},
+		)
+	}
+
+	addScalar("form", f.ID, "!!str")
+	for _, field := range f.Fields {
+		if field.InBody || values[field.Name] == "" {
+			continue
+		}
+		tag := "!!str"
+		switch field.Type {
+		case FieldNumber:
+			tag = ""
+		case FieldCheckbox:
+			tag = "!!bool"
+		}
+		addScalar(field.Name, values[field.Name], tag)
+	}
+	addScalar("submitted_by", submittedBy, "!!str")
+	addScalar("submitted_at", submittedAt.Format(time.RFC3339), "!!str")
+
+	var fm bytes.Buffer
+	encoder := yaml.NewEncoder(&fm)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mapping); err != nil {
+		return "", err
+	}
+
+	// Substitute placeholders in the body template
+	body := f.Template
+	if strings.TrimSpace(body) == "" {
+		body = f.defaultTemplate()
+	}
+	body = placeholderPattern.ReplaceAllStringFunc(body, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match // Leave unknown placeholders untouched
+	})
+
+	return "---\n" + fm.String() + "---\n\n" + strings.TrimLeft(body, "\n"), nil
+}
+
+// defaultTemplate creates a body listing every field when no template is configured
+func (f *Form) defaultTemplate() string {
+	var sb strings.Builder
+	sb.WriteString("# {{" + f.titleField() + "}}\n")
+	for _, field := range f.Fields {
+		if field.Name == f.titleField() {
+			continue
+		}
+		label := field.Label
+		if label == "" {
+			label = field.Name
+		}
+		if field.Type == FieldTextarea {
+			sb.WriteString("\n## " + label + "\n\n{{" + field.Name + "}}\n")
+		} else {
+			sb.WriteString("\n**" + label + ":** {{" + field.Name + "}}\n")
+		}
+	}
+	return sb.String()
+}
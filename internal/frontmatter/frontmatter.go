@@ -2,6 +2,8 @@ package frontmatter
 
 import (
 	"bytes"
+	"errors"
+	"reflect"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -10,10 +12,70 @@ import (
 // Metadata represents the frontmatter data structure
 // This can be expanded with additional fields in the future
 type Metadata struct {
-	Layout string `yaml:"layout,omitempty"`
+	Layout       string     `yaml:"layout,omitempty"`
+	Glossary     *bool      `yaml:"glossary,omitempty"`      // Set to false to disable glossary term highlighting
+	ReviewEvery  string     `yaml:"review_every,omitempty"`  // Review interval, e.g. 90d, 12w, 6m or 1y
+	LastReviewed string     `yaml:"last_reviewed,omitempty"` // Date of the last review (YYYY-MM-DD)
+	Owners       StringList `yaml:"owners,omitempty"`        // Users responsible for the page
+	Lang         string     `yaml:"lang,omitempty"`          // Language of the page, e.g. en or de_DE
+	Dir          string     `yaml:"dir,omitempty"`           // Text direction of the page: rtl, ltr or auto
+	Tags         StringList `yaml:"tags,omitempty"`          // Labels grouping related pages
+	Description  string     `yaml:"description,omitempty"`   // Short summary shown in listings, previews and link cards
+	Weight       *int       `yaml:"weight,omitempty"`        // Position among sibling pages in e-books, lower first
+	Social       Social     `yaml:"social,omitempty"`        // Overrides of the link card shown by chat apps and social sites
+	Draft        bool       `yaml:"draft,omitempty"`         // Work in progress, listed on the dashboards of its authors
+	Redirect     string     `yaml:"redirect,omitempty"`      // Page readers are sent to, like /it/network/vpn-setup
+	EventDate    string     `yaml:"event_date,omitempty"`    // Date of the event the page documents, with an optional time
+	EventEnd     string     `yaml:"event_end,omitempty"`     // Last day or end time of the event
+	DueDate      string     `yaml:"due_date,omitempty"`      // Deadline of the page, e.g. 2025-06-30
+	Numbering    *bool      `yaml:"numbering,omitempty"`     // Number the headings: 1., 1.1, 1.1.1, overriding the directory settings
+	Format       string     `yaml:"format,omitempty"`        // Markup the page is written in: asciidoc or rst, markdown otherwise
 	// Add additional fields here as needed
 }
 
+// Social overrides the Open Graph and Twitter card tags of a page
+type Social struct {
+	Title       string `yaml:"title,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Image       string `yaml:"image,omitempty"` // Attachment name or URL of the card image
+}
+
+// StringList is a list of strings that can also be written as a single value in YAML
+type StringList []string
+
+// UnmarshalYAML accepts both "owners: alice" and "owners: [alice, bob]"
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		if value.Value != "" {
+			*l = StringList{value.Value}
+		}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// NormalizeTags splits tags written as "a, b" or "#a #b", as Obsidian allows,
+// and removes their leading # and duplicates
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, value := range tags {
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			tag = strings.TrimPrefix(tag, "#")
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				normalized = append(normalized, tag)
+			}
+		}
+	}
+	return normalized
+}
+
 // Parse extracts and parses frontmatter from markdown content
 // Returns the parsed metadata and the content without frontmatter
 func Parse(content string) (Metadata, string, bool) {
@@ -89,4 +151,107 @@ func Add(content string, metadata Metadata) (string, error) {
 
 	// Construct new content with frontmatter
 	return "---\n" + buf.String() + "---\n\n" + contentWithoutFM, nil
-}
\ No newline at end of file
+}
+
+// SetField sets a single top-level frontmatter key while keeping all other keys and
+// their order. Frontmatter is added when the content has none.
+func SetField(content, key string, value interface{}) (string, error) {
+	var doc yaml.Node
+	body := content
+	if HasFrontmatter(content) {
+		if err := yaml.Unmarshal([]byte(Extract(content)), &doc); err != nil {
+			return content, err
+		}
+		endDelimIndex := strings.Index(content[4:], "\n---")
+		body = strings.TrimLeft(content[4+endDelimIndex+4:], "\n")
+	}
+
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return content, errors.New("frontmatter is not a mapping")
+	}
+
+	var valueNode yaml.Node
+	if err := valueNode.Encode(value); err != nil {
+		return content, err
+	}
+
+	replaced := false
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = &valueNode
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &valueNode)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return content, err
+	}
+
+	return "---\n" + buf.String() + "---\n\n" + body, nil
+}
+
+// Field is a top-level frontmatter key with its value written as text
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Fields returns the top-level keys of the frontmatter of content in the order
+// they are written. Lists of plain values are joined with commas, nested
+// mappings are left out.
+func Fields(content string) []Field {
+	var doc yaml.Node
+	if !HasFrontmatter(content) || yaml.Unmarshal([]byte(Extract(content)), &doc) != nil {
+		return nil
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var fields []Field
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		value := mapping.Content[i+1]
+		switch value.Kind {
+		case yaml.ScalarNode:
+			if value.Value != "" {
+				fields = append(fields, Field{Key: mapping.Content[i].Value, Value: value.Value})
+			}
+		case yaml.SequenceNode:
+			var values []string
+			for _, item := range value.Content {
+				if item.Kind == yaml.ScalarNode && item.Value != "" {
+					values = append(values, item.Value)
+				}
+			}
+			if len(values) > 0 {
+				fields = append(fields, Field{Key: mapping.Content[i].Value, Value: strings.Join(values, ", ")})
+			}
+		}
+	}
+	return fields
+}
+
+// IsMetadataKey reports whether key is one of the frontmatter keys the wiki
+// itself reads, like tags or layout
+func IsMetadataKey(key string) bool {
+	metadataType := reflect.TypeOf(Metadata{})
+	for i := 0; i < metadataType.NumField(); i++ {
+		name, _, _ := strings.Cut(metadataType.Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"wiki-go/internal/config"
 	"wiki-go/internal/i18n"
 )
 
@@ -429,7 +430,7 @@ func RenderLinks(content string) (string, error) {
                 <div class="link-content">
                     <div class="link-title-row">
                         <div class="link-favicon">
-                            <img src="{{getFaviconURL .URL}}" alt="{{.Title}}">
+                            {{if getFaviconURL .URL}}<img src="{{getFaviconURL .URL}}" alt="{{.Title}}">{{else}}<i class="fa fa-globe"></i>{{end}}
                         </div>
                         <div class="link-title">
                             <a href="{{.URL}}" target="_blank" rel="noopener noreferrer" title="{{.URL}}">{{.Title}}</a>
@@ -457,7 +458,7 @@ func RenderLinks(content string) (string, error) {
 
     <!-- Floating Add Link button for admin/editor users -->
     <div class="floating-add-link-container editor-admin-only">
-        <button class="floating-add-link-btn" onclick="showAddLinkDialog()" title="` + i18n.Translate("links.add_new_link") + `">
+        <button class="floating-add-link-btn" title="` + i18n.Translate("links.add_new_link") + `">
             <i class="fa fa-plus"></i>
         </button>
     </div>
@@ -480,8 +481,12 @@ func RenderLinks(content string) (string, error) {
 
 // Helper functions for template rendering
 
-// getFaviconURL returns the favicon URL for a given domain
+// getFaviconURL returns the favicon URL for a given domain, none for
+// air-gapped wikis as the icons come from another site
 func getFaviconURL(url string) string {
+	if config.Cfg != nil && config.Cfg.Wiki.AirGapped {
+		return ""
+	}
 	// Try to extract domain from URL
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
 		url = "https://" + url
@@ -0,0 +1,131 @@
+package goldext
+
+import (
+	"strings"
+
+	"wiki-go/internal/permissions"
+)
+
+// AudienceGuest is the role used for visitors who are not logged in
+const AudienceGuest = "guest"
+
+// FilterAudience evaluates :::if blocks for a viewer and removes the content they may
+// not see, so it never reaches the client. A viewer without a username is a guest.
+//
+//	:::if role=admin,editor
+//	Only visible to admins and editors
+//	:::else
+//	Visible to everybody else
+//	:::
+//
+// Supported conditions are role=<roles> (including "guest"), group=<groups> and
+// audience=internal (any logged-in user) or audience=public (anyone). Several
+// conditions must all match, and != negates a condition. Blocks may be nested,
+// a block that is never closed runs to the end of the page.
+func FilterAudience(markdown string, viewer permissions.Subject) string {
+	if !strings.Contains(markdown, ":::if") {
+		return markdown
+	}
+	if viewer.Username == "" && viewer.Role == "" {
+		viewer.Role = AudienceGuest
+	}
+
+	lines := strings.Split(markdown, "\n")
+	result := make([]string, 0, len(lines))
+
+	// Each open block records whether its current branch is visible
+	type block struct {
+		matched       bool
+		parentVisible bool
+	}
+	var stack []block
+	visible := true
+
+	inBacktickBlock := false
+	inTildeBlock := false
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if !inTildeBlock && strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+		} else if !inBacktickBlock && strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+		} else if !inBacktickBlock && !inTildeBlock {
+			switch {
+			case strings.HasPrefix(trimmedLine, ":::if ") || trimmedLine == ":::if":
+				matched := audienceMatches(strings.TrimSpace(strings.TrimPrefix(trimmedLine, ":::if")), viewer)
+				stack = append(stack, block{matched: matched, parentVisible: visible})
+				visible = visible && matched
+				continue
+			case trimmedLine == ":::else" && len(stack) > 0:
+				top := stack[len(stack)-1]
+				visible = top.parentVisible && !top.matched
+				continue
+			case (trimmedLine == ":::" || trimmedLine == ":::endif") && len(stack) > 0:
+				visible = stack[len(stack)-1].parentVisible
+				stack = stack[:len(stack)-1]
+				continue
+			}
+		}
+
+		if visible {
+			result = append(result, line)
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// audienceMatches reports whether all conditions of an :::if line match the viewer
+func audienceMatches(conditions string, viewer permissions.Subject) bool {
+	fields := strings.Fields(conditions)
+	if len(fields) == 0 {
+		return false
+	}
+
+	for _, condition := range fields {
+		negate := false
+		key, values, ok := strings.Cut(condition, "!=")
+		if ok {
+			negate = true
+		} else if key, values, ok = strings.Cut(condition, "="); !ok {
+			return false // Unknown syntax hides the block
+		}
+
+		matched := false
+		for _, value := range strings.Split(values, ",") {
+			value = strings.ToLower(strings.TrimSpace(value))
+			switch strings.ToLower(key) {
+			case "role":
+				matched = matched || value == viewer.Role
+			case "group":
+				for _, group := range viewer.Groups {
+					matched = matched || value == strings.ToLower(group)
+				}
+			case "audience":
+				switch value {
+				case "public":
+					matched = true
+				case "internal":
+					matched = matched || viewer.Role != AudienceGuest
+				}
+			default:
+				return false // Unknown conditions hide the block
+			}
+		}
+
+		if matched == negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AudiencePreprocessor hides conditional content that was not evaluated for a specific
+// viewer. Handlers call FilterAudience with the viewer before rendering, so this
+// only applies to render paths without a viewer, which are treated as guests.
+func AudiencePreprocessor(markdown string, _ string) string {
+	return FilterAudience(markdown, permissions.Subject{})
+}
@@ -0,0 +1,170 @@
+package goldext
+
+import (
+	"testing"
+
+	"wiki-go/internal/permissions"
+)
+
+func TestFilterAudience(t *testing.T) {
+	input := "Intro\n:::if role=admin,editor\nStaff only\n:::else\nEveryone else\n:::\n:::if audience=internal\nLogged in\n:::if role!=viewer\nNot viewers\n:::\n:::\nEnd"
+	admin := permissions.Subject{Username: "ann", Role: "admin"}
+	viewer := permissions.Subject{Username: "vic", Role: "viewer"}
+	support := permissions.Subject{Username: "sue", Role: "viewer", Groups: []string{"Support", "oncall"}}
+	guest := permissions.Subject{}
+
+	tests := []struct {
+		name     string
+		viewer   permissions.Subject
+		input    string
+		expected string
+	}{
+		{
+			name:     "Admin",
+			viewer:   admin,
+			input:    input,
+			expected: "Intro\nStaff only\nLogged in\nNot viewers\nEnd",
+		},
+		{
+			name:     "Viewer",
+			viewer:   viewer,
+			input:    input,
+			expected: "Intro\nEveryone else\nLogged in\nEnd",
+		},
+		{
+			name:     "Guest",
+			viewer:   guest,
+			input:    input,
+			expected: "Intro\nEveryone else\nEnd",
+		},
+		{
+			name:     "Guest role",
+			viewer:   guest,
+			input:    ":::if role=guest\nSign in for more\n:::",
+			expected: "Sign in for more",
+		},
+		{
+			name:     "Code blocks are not evaluated",
+			viewer:   guest,
+			input:    "```\n:::if role=admin\nsecret\n:::\n```",
+			expected: "```\n:::if role=admin\nsecret\n:::\n```",
+		},
+		{
+			name:     "Unknown condition hides the block",
+			viewer:   admin,
+			input:    ":::if team\nhidden\n:::\nshown",
+			expected: "shown",
+		},
+		{
+			name:     "Unknown negated condition hides the block",
+			viewer:   admin,
+			input:    ":::if team!=sales\nhidden\n:::\nshown",
+			expected: "shown",
+		},
+
+		// Groups
+		{
+			name:     "Group members",
+			viewer:   support,
+			input:    ":::if group=support,sales\nTicket queue\n:::else\nAsk support\n:::",
+			expected: "Ticket queue",
+		},
+		{
+			name:     "Users outside the group",
+			viewer:   viewer,
+			input:    ":::if group=support,sales\nTicket queue\n:::else\nAsk support\n:::",
+			expected: "Ask support",
+		},
+		{
+			name:     "Guests are in no group",
+			viewer:   guest,
+			input:    ":::if group=support\nTicket queue\n:::",
+			expected: "",
+		},
+		{
+			name:     "Negated groups",
+			viewer:   support,
+			input:    ":::if group!=oncall\nOff duty\n:::\nEnd",
+			expected: "End",
+		},
+		{
+			name:     "Group and role conditions must all match",
+			viewer:   support,
+			input:    ":::if group=support role=editor\nEdit the runbook\n:::\n:::if group=support role=viewer\nRead the runbook\n:::",
+			expected: "Read the runbook",
+		},
+
+		// Nesting
+		{
+			name:     "Hidden parents hide matching children",
+			viewer:   support,
+			input:    ":::if role=admin\nA\n:::if group=support\nB\n:::\nC\n:::\nD",
+			expected: "D",
+		},
+		{
+			name:     "Else branches of nested blocks",
+			viewer:   support,
+			input:    ":::if audience=internal\n:::if group=sales\nSales\n:::else\nNot sales\n:::\nInternal\n:::else\nPublic\n:::",
+			expected: "Not sales\nInternal",
+		},
+		{
+			name:     "Else of a hidden parent stays hidden",
+			viewer:   guest,
+			input:    ":::if audience=internal\n:::if group=sales\nSales\n:::else\nNot sales\n:::\n:::\nEnd",
+			expected: "End",
+		},
+		{
+			name:     "endif closes blocks",
+			viewer:   guest,
+			input:    ":::if role=admin\nsecret\n:::endif\nshown",
+			expected: "shown",
+		},
+
+		// Unclosed and mismatched blocks
+		{
+			name:     "Unclosed blocks run to the end of the page",
+			viewer:   guest,
+			input:    "Intro\n:::if role=admin\nsecret\n\n## More secrets",
+			expected: "Intro",
+		},
+		{
+			name:     "Unclosed nested blocks",
+			viewer:   viewer,
+			input:    ":::if audience=internal\nLogged in\n:::if role=admin\nsecret\n:::\nStill logged in",
+			expected: "Logged in\nStill logged in",
+		},
+		{
+			name:     "Closing markers without a block are kept",
+			viewer:   guest,
+			input:    "Text\n:::\n:::else\n:::endif\nEnd",
+			expected: "Text\n:::\n:::else\n:::endif\nEnd",
+		},
+		{
+			name:     "Extra closing markers don't reopen hidden content",
+			viewer:   guest,
+			input:    ":::if role=admin\nsecret\n:::\n:::\nshown",
+			expected: ":::\nshown",
+		},
+		{
+			name:     "Repeated else branches",
+			viewer:   admin,
+			input:    ":::if role=admin\nA\n:::else\nB\n:::else\nC\n:::",
+			expected: "A",
+		},
+		{
+			name:     "Code fences left open in hidden blocks",
+			viewer:   guest,
+			input:    ":::if role=admin\n```\nsecret\n:::\nstill code",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterAudience(tt.input, tt.viewer)
+			if result != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package goldext
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/encryption"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BibEntry is a single bibliography entry loaded from BibTeX or YAML
+type BibEntry struct {
+	Key       string
+	Type      string
+	Authors   []string
+	Title     string
+	Year      string
+	Container string // Journal, book title or publisher
+	Volume    string
+	Pages     string
+	URL       string
+	DOI       string
+}
+
+// Bibliography file names looked up in the page directory and its parents
+var bibliographyFiles = []string{"references.bib", "references.yaml", "references.yml"}
+
+// LoadBibliography collects bibliography entries for a document. Files in the page
+// directory take precedence over files in parent directories (spaces).
+func LoadBibliography(documentsDir, docPath string) map[string]*BibEntry {
+	entries := make(map[string]*BibEntry)
+
+	dir := strings.Trim(filepath.ToSlash(docPath), "/")
+	for {
+		for _, name := range bibliographyFiles {
+			data, err := encryption.ReadFile(filepath.Join(documentsDir, filepath.FromSlash(dir), name))
+			if err != nil {
+				continue
+			}
+
+			var parsed []*BibEntry
+			if strings.HasSuffix(name, ".bib") {
+				parsed = ParseBibTeX(string(data))
+			} else {
+				parsed, _ = ParseYAMLBibliography(data)
+			}
+			for _, entry := range parsed {
+				if _, exists := entries[entry.Key]; !exists {
+					entries[entry.Key] = entry
+				}
+			}
+		}
+
+		if dir == "" || dir == "." {
+			break
+		}
+		dir = filepath.ToSlash(filepath.Dir(dir))
+		if dir == "." {
+			dir = ""
+		}
+	}
+
+	return entries
+}
+
+// ParseBibTeX parses the entries of a BibTeX file. Unsupported constructs such as
+// @string and @comment are skipped.
+func ParseBibTeX(content string) []*BibEntry {
+	var entries []*BibEntry
+
+	for pos := 0; pos < len(content); {
+		at := strings.IndexByte(content[pos:], '@')
+		if at == -1 {
+			break
+		}
+		pos += at + 1
+
+		open := strings.IndexAny(content[pos:], "{(")
+		if open == -1 {
+			break
+		}
+		entryType := strings.ToLower(strings.TrimSpace(content[pos : pos+open]))
+		pos += open + 1
+
+		body, end := bibBalanced(content, pos)
+		pos = end
+		if entryType == "string" || entryType == "comment" || entryType == "preamble" {
+			continue
+		}
+
+		comma := strings.IndexByte(body, ',')
+		if comma == -1 {
+			continue
+		}
+		entry := &BibEntry{Key: strings.TrimSpace(body[:comma]), Type: entryType}
+		if entry.Key == "" {
+			continue
+		}
+
+		for field, value := range bibFields(body[comma+1:]) {
+			entry.set(field, value)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// bibBalanced returns the text up to the brace closing the entry opened before pos
+func bibBalanced(content string, pos int) (string, int) {
+	depth := 1
+	for i := pos; i < len(content); i++ {
+		switch content[i] {
+		case '{', '(':
+			depth++
+		case '}', ')':
+			depth--
+			if depth == 0 {
+				return content[pos:i], i + 1
+			}
+		}
+	}
+	return content[pos:], len(content)
+}
+
+// bibFields splits the body of a BibTeX entry into field names and values
+func bibFields(body string) map[string]string {
+	fields := make(map[string]string)
+
+	for pos := 0; pos < len(body); {
+		eq := strings.IndexByte(body[pos:], '=')
+		if eq == -1 {
+			break
+		}
+		name := strings.ToLower(strings.Trim(strings.TrimSpace(body[pos:pos+eq]), ","))
+		name = strings.TrimSpace(name)
+		pos += eq + 1
+
+		// Skip whitespace before the value
+		for pos < len(body) && (body[pos] == ' ' || body[pos] == '\t' || body[pos] == '\n' || body[pos] == '\r') {
+			pos++
+		}
+		if pos >= len(body) {
+			break
+		}
+
+		var value string
+		switch body[pos] {
+		case '{':
+			depth := 0
+			start := pos + 1
+			for ; pos < len(body); pos++ {
+				if body[pos] == '{' {
+					depth++
+				} else if body[pos] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			value = body[start:min(pos, len(body))]
+			pos++
+		case '"':
+			start := pos + 1
+			pos = start
+			for pos < len(body) && body[pos] != '"' {
+				pos++
+			}
+			value = body[start:min(pos, len(body))]
+			pos++
+		default:
+			end := strings.IndexByte(body[pos:], ',')
+			if end == -1 {
+				end = len(body) - pos
+			}
+			value = body[pos : pos+end]
+			pos += end
+		}
+
+		// Remove the protective braces used for capitalization
+		value = strings.NewReplacer("{", "", "}", "").Replace(value)
+		fields[name] = strings.Join(strings.Fields(value), " ")
+	}
+
+	return fields
+}
+
+// set assigns a BibTeX or YAML field to the entry
+func (e *BibEntry) set(field, value string) {
+	switch field {
+	case "author", "editor":
+		if len(e.Authors) == 0 || field == "author" {
+			e.Authors = splitAuthors(value)
+		}
+	case "title":
+		e.Title = value
+	case "year":
+		e.Year = value
+	case "date":
+		if e.Year == "" && len(value) >= 4 {
+			e.Year = value[:4]
+		}
+	case "journal", "booktitle", "container", "publisher", "howpublished", "school", "institution":
+		if e.Container == "" {
+			e.Container = value
+		}
+	case "volume":
+		e.Volume = value
+	case "pages":
+		e.Pages = strings.ReplaceAll(value, "--", "–")
+	case "url":
+		e.URL = value
+	case "doi":
+		e.DOI = value
+	case "type":
+		e.Type = value
+	}
+}
+
+// splitAuthors splits a BibTeX author list ("A and B and C")
+func splitAuthors(value string) []string {
+	var authors []string
+	for _, author := range strings.Split(value, " and ") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// ParseYAMLBibliography parses a YAML bibliography keyed by citation key:
+//
+//	smith2020:
+//	  title: A Study
+//	  author: [Jane Smith, John Doe]
+//	  year: 2020
+func ParseYAMLBibliography(data []byte) ([]*BibEntry, error) {
+	var raw map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	var entries []*BibEntry
+	for key, fields := range raw {
+		entry := &BibEntry{Key: key}
+		for field, value := range fields {
+			switch v := value.(type) {
+			case []interface{}:
+				var parts []string
+				for _, item := range v {
+					parts = append(parts, fmt.Sprint(item))
+				}
+				entry.set(strings.ToLower(field), strings.Join(parts, " and "))
+			default:
+				entry.set(strings.ToLower(field), fmt.Sprint(v))
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// lastName returns the family name of an author written as "Last, First" or "First Last"
+func lastName(author string) string {
+	if comma := strings.IndexByte(author, ','); comma != -1 {
+		return strings.TrimSpace(author[:comma])
+	}
+	parts := strings.Fields(author)
+	if len(parts) == 0 {
+		return author
+	}
+	return parts[len(parts)-1]
+}
+
+// ShortAuthors returns the author part of an author-year citation, e.g. "Smith et al."
+func (e *BibEntry) ShortAuthors() string {
+	switch len(e.Authors) {
+	case 0:
+		if e.Title != "" {
+			return e.Title
+		}
+		return e.Key
+	case 1:
+		return lastName(e.Authors[0])
+	case 2:
+		return lastName(e.Authors[0]) + " & " + lastName(e.Authors[1])
+	default:
+		return lastName(e.Authors[0]) + " et al."
+	}
+}
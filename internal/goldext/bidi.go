@@ -0,0 +1,112 @@
+package goldext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/i18n"
+)
+
+// Bidi gives paragraphs, headings, list items, quotes and table cells
+// dir="auto", so each block takes the direction of its own text. A line of
+// English in a Hebrew list, or an Arabic cell in an English table, is then
+// aligned and ordered correctly.
+var Bidi goldmark.Extender = &bidi{}
+
+type bidi struct{}
+
+// Extend adds the transformer setting the direction of blocks
+func (b *bidi) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(bidiTransformer{}, 999)))
+}
+
+type bidiTransformer struct{}
+
+// Transform marks the blocks that don't set their direction with dir="auto"
+func (bidiTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *ast.Paragraph, *ast.Heading, *ast.ListItem, *ast.Blockquote, *east.TableCell:
+			if _, ok := n.AttributeString("dir"); !ok {
+				n.SetAttributeString("dir", []byte("auto"))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// Markup skipped when detecting the direction of a page
+var (
+	fencedCodePattern = regexp.MustCompile("(?ms)^[ \t]*(```|~~~).*?^[ \t]*(```|~~~)[ \t]*$")
+	inlineCodePattern = regexp.MustCompile("`[^`\n]*`")
+	linkTargetPattern = regexp.MustCompile(`\]\([^)]*\)|https?://\S+|<[^>\n]*>`)
+)
+
+// DetectDirection guesses the direction of the text of a page from its
+// letters: "rtl" when most are from right-to-left scripts such as Arabic or
+// Hebrew, "ltr" when most are not and "" for pages without letters. Code,
+// link targets and HTML tags are ignored.
+func DetectDirection(markdown string) string {
+	markdown = fencedCodePattern.ReplaceAllString(markdown, "")
+	markdown = inlineCodePattern.ReplaceAllString(markdown, "")
+	markdown = linkTargetPattern.ReplaceAllString(markdown, "")
+
+	rtl, ltr := 0, 0
+	for _, r := range markdown {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if rtlLetter(r) {
+			rtl++
+		} else {
+			ltr++
+		}
+	}
+	switch {
+	case rtl == 0 && ltr == 0:
+		return ""
+	case rtl > ltr:
+		return "rtl"
+	default:
+		return "ltr"
+	}
+}
+
+// rtlLetter reports whether r belongs to a script written from right to left
+func rtlLetter(r rune) bool {
+	return unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko, unicode.Samaritan, unicode.Mandaic, unicode.Adlam)
+}
+
+// PageDirection returns the direction of a page, "rtl" or "ltr". The dir set
+// in its frontmatter wins, then the direction of its frontmatter lang, then
+// that of its text. Pages without letters take the direction of lang, the
+// language they're shown in.
+func PageDirection(markdown, lang string) string {
+	metadata, body, ok := frontmatter.Parse(markdown)
+	if !ok {
+		body = markdown
+	}
+	switch dir := strings.ToLower(strings.TrimSpace(metadata.Dir)); dir {
+	case "rtl", "ltr":
+		return dir
+	}
+	if metadata.Lang != "" {
+		return i18n.Direction(metadata.Lang)
+	}
+	if dir := DetectDirection(body); dir != "" {
+		return dir
+	}
+	return i18n.Direction(lang)
+}
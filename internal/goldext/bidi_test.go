@@ -0,0 +1,142 @@
+package goldext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+func TestDetectDirection(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "English",
+			input:    "# Welcome\n\nThis page is written in English.",
+			expected: "ltr",
+		},
+		{
+			name:     "Hebrew",
+			input:    "# ברוכים הבאים\n\nהדף הזה כתוב בעברית.",
+			expected: "rtl",
+		},
+		{
+			name:     "Arabic with an English word",
+			input:    "مرحبا بكم في ويكي Wiki",
+			expected: "rtl",
+		},
+		{
+			name:     "Code and links are ignored",
+			input:    "שלום עולם\n\n```go\nfunc main() { fmt.Println(\"hello world\") }\n```\n\n[קישור](https://example.com/some/long/english/path)",
+			expected: "rtl",
+		},
+		{
+			name:     "No letters",
+			input:    "123 - 456",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DetectDirection(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestPageDirection(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		lang     string
+		expected string
+	}{
+		{
+			name:     "Frontmatter dir wins",
+			input:    "---\ndir: rtl\n---\nEnglish text",
+			lang:     "en",
+			expected: "rtl",
+		},
+		{
+			name:     "Frontmatter lang",
+			input:    "---\nlang: fa\n---\nIran",
+			lang:     "en",
+			expected: "rtl",
+		},
+		{
+			name:     "Detected from the text",
+			input:    "---\ntitle: Page\n---\nשלום עולם",
+			lang:     "en",
+			expected: "rtl",
+		},
+		{
+			name:     "Auto detects from the text",
+			input:    "---\ndir: auto\n---\nHello",
+			lang:     "ar",
+			expected: "ltr",
+		},
+		{
+			name:     "Empty page follows the language",
+			input:    "",
+			lang:     "he",
+			expected: "rtl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PageDirection(tt.input, tt.lang)
+			if result != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestBidiExtension(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(extension.Table, Bidi))
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "List items",
+			input:    "- one\n- שתיים",
+			expected: []string{`<li dir="auto">one</li>`, `<li dir="auto">שתיים</li>`},
+		},
+		{
+			name:     "Table cells",
+			input:    "| a | b |\n|---|---|\n| x | مرحبا |",
+			expected: []string{`<th dir="auto">a</th>`, `<td dir="auto">مرحبا</td>`},
+		},
+		{
+			name:     "Paragraphs and headings",
+			input:    "# Title\n\nText",
+			expected: []string{`<h1 dir="auto">Title</h1>`, `<p dir="auto">Text</p>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := md.Convert([]byte(tt.input), &buf); err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Expected %q in: %q", want, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package goldext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// calloutRegex matches the first line of a callout: > [!type] Title, with +
+// or - after the type for callouts that can be folded
+var calloutRegex = regexp.MustCompile(`^>\s*\[!([A-Za-z-]+)\]([+-]?)\s*(.*)$`)
+
+// calloutKinds maps the callout types of Obsidian and GitHub to the style and
+// icon they are shown with
+var calloutKinds = map[string][2]string{
+	"note":      {"info", "fa-pencil"},
+	"info":      {"info", "fa-info-circle"},
+	"abstract":  {"info", "fa-clipboard"},
+	"summary":   {"info", "fa-clipboard"},
+	"tldr":      {"info", "fa-clipboard"},
+	"todo":      {"info", "fa-check-square-o"},
+	"tip":       {"tip", "fa-lightbulb-o"},
+	"hint":      {"tip", "fa-lightbulb-o"},
+	"important": {"tip", "fa-star"},
+	"success":   {"success", "fa-check"},
+	"check":     {"success", "fa-check"},
+	"done":      {"success", "fa-check"},
+	"question":  {"warning", "fa-question-circle"},
+	"help":      {"warning", "fa-question-circle"},
+	"faq":       {"warning", "fa-question-circle"},
+	"warning":   {"warning", "fa-exclamation-triangle"},
+	"caution":   {"danger", "fa-exclamation-triangle"},
+	"attention": {"warning", "fa-exclamation-triangle"},
+	"failure":   {"danger", "fa-times-circle"},
+	"fail":      {"danger", "fa-times-circle"},
+	"missing":   {"danger", "fa-times-circle"},
+	"danger":    {"danger", "fa-bolt"},
+	"error":     {"danger", "fa-bolt"},
+	"bug":       {"danger", "fa-bug"},
+	"example":   {"neutral", "fa-list"},
+	"quote":     {"neutral", "fa-quote-left"},
+	"cite":      {"neutral", "fa-quote-left"},
+}
+
+// CalloutPreprocessor renders block quotes starting with [!type] as callouts,
+// the syntax of Obsidian and of GitHub alerts:
+//
+//	> [!warning] Optional title
+//	> Content of the callout
+//
+// A + or - after the type makes the callout foldable, open or closed.
+func CalloutPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, "[!") {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	result := make([]string, 0, len(lines))
+	inCodeBlock := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+		}
+		m := calloutRegex.FindStringSubmatch(line)
+		if inCodeBlock || m == nil {
+			result = append(result, line)
+			continue
+		}
+
+		// The callout continues as long as lines are quoted
+		var body []string
+		for i+1 < len(lines) && strings.HasPrefix(strings.TrimLeft(lines[i+1], " "), ">") {
+			i++
+			quoted := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+			body = append(body, strings.TrimPrefix(quoted, " "))
+		}
+
+		result = append(result, renderCallout(strings.ToLower(m[1]), m[2], m[3], body))
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// renderCallout builds the HTML around the body, which stays markdown
+func renderCallout(kind, fold, title string, body []string) string {
+	style, ok := calloutKinds[kind]
+	if !ok {
+		style = calloutKinds["note"]
+	}
+	if title == "" {
+		title = strings.ToUpper(kind[:1]) + kind[1:]
+	}
+	titleHTML := `<i class="fa ` + style[1] + `" aria-hidden="true"></i> ` + html.EscapeString(title)
+	class := "callout callout-" + style[0]
+	content := CalloutPreprocessor(strings.TrimSpace(strings.Join(body, "\n")), "")
+
+	var b strings.Builder
+	if fold != "" {
+		open := ""
+		if fold == "+" {
+			open = " open"
+		}
+		b.WriteString(`<details class="` + class + `"` + open + `><summary class="callout-title">` + titleHTML + `</summary>`)
+	} else {
+		b.WriteString(`<div class="` + class + `"><div class="callout-title">` + titleHTML + `</div>`)
+	}
+	if content != "" {
+		b.WriteString("\n<div class=\"callout-content\">\n\n" + content + "\n\n</div>")
+	}
+	if fold != "" {
+		b.WriteString("</details>")
+	} else {
+		b.WriteString("</div>")
+	}
+	return b.String()
+}
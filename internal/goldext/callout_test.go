@@ -0,0 +1,54 @@
+package goldext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalloutPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+	}{
+		{
+			name:  "Callout with title",
+			input: "> [!warning] Back up first\n> Upgrading **rewrites** data.\n\nAfter",
+			contains: []string{
+				`<div class="callout callout-warning"><div class="callout-title"><i class="fa fa-exclamation-triangle" aria-hidden="true"></i> Back up first</div>`,
+				"<div class=\"callout-content\">\n\nUpgrading **rewrites** data.\n\n</div></div>\n\nAfter",
+			},
+		},
+		{
+			name:     "Default title and unknown type",
+			input:    "> [!custom]\n> Text",
+			contains: []string{`<div class="callout callout-info"><div class="callout-title"><i class="fa fa-pencil" aria-hidden="true"></i> Custom</div>`},
+		},
+		{
+			name:     "Foldable",
+			input:    "> [!tip]- Closed\n> Hidden\n\n> [!tip]+ Open\n> Shown",
+			contains: []string{`<details class="callout callout-tip"><summary class="callout-title">`, `<details class="callout callout-tip" open>`, "</details>"},
+		},
+		{
+			name:     "Nested",
+			input:    "> [!note] Outer\n> > [!danger] Inner\n> > Deep",
+			contains: []string{`<div class="callout callout-danger">`, "Deep\n\n</div></div>\n\n</div></div>"},
+		},
+		{
+			name:     "Code blocks and plain quotes are ignored",
+			input:    "```\n> [!note] Code\n```\n> Quote",
+			contains: []string{"```\n> [!note] Code\n```\n> Quote"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CalloutPreprocessor(tt.input, "")
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, result)
+				}
+			}
+		})
+	}
+}
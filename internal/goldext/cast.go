@@ -0,0 +1,53 @@
+package goldext
+
+import (
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// castImageRegex matches images of terminal recordings: asciinema .cast
+// attachments and URLs, and the pages of recordings on asciinema.org
+var castImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+\.(?i:cast)|https://asciinema\.org/a/[A-Za-z0-9]+)\)`)
+
+// CastProxyPath serves recordings of other sites, which browsers may not load
+// themselves, through the wiki
+const CastProxyPath = "/api/cast"
+
+// CastPreprocessor shows images of terminal recordings in the player of
+// js/cast-player.js. Without scripts they are links to the recording.
+func CastPreprocessor(markdown string, docPath string) string {
+	sections := splitCodeSections(markdown)
+	for i := range sections {
+		if sections[i].isCode {
+			continue
+		}
+		sections[i].content = castImageRegex.ReplaceAllStringFunc(sections[i].content, func(match string) string {
+			parts := castImageRegex.FindStringSubmatch(match)
+			link, source := castSource(parts[2], docPath)
+			title := parts[1]
+			if title == "" {
+				title = strings.TrimSuffix(path.Base(link), path.Ext(link))
+			}
+			return `<span class="cast-player" data-src="` + html.EscapeString(source) + `" data-title="` +
+				html.EscapeString(title) + `"><a href="` + html.EscapeString(link) + `">` + html.EscapeString(title) + `</a></span>`
+		})
+	}
+	return joinSections(sections)
+}
+
+// castSource returns the address of a recording for links and the one the
+// player loads it from: attachments directly, other sites through the proxy
+func castSource(address, docPath string) (link, source string) {
+	if !strings.Contains(address, "://") {
+		link = FileURL(address, docPath)
+		return link, link
+	}
+	source = address
+	if strings.HasPrefix(address, "https://asciinema.org/a/") {
+		source += ".cast"
+	}
+	return address, CastProxyPath + "?url=" + url.QueryEscape(source)
+}
@@ -0,0 +1,62 @@
+package goldext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCastPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		contains []string
+	}{
+		{
+			name:    "Attachment",
+			input:   "Run it: ![Deploy](deploy.cast)",
+			docPath: "ops/release",
+			contains: []string{
+				`Run it: <span class="cast-player" data-src="/api/files/ops/release/deploy.cast" data-title="Deploy">`,
+				`<a href="/api/files/ops/release/deploy.cast">Deploy</a></span>`,
+			},
+		},
+		{
+			name:     "Attachment of the homepage without a title",
+			input:    "![](setup.CAST)",
+			contains: []string{`data-src="/api/files/pages/home/setup.CAST" data-title="setup">`},
+		},
+		{
+			name:    "Recording on asciinema.org",
+			input:   "![Demo](https://asciinema.org/a/335480)",
+			docPath: "docs",
+			contains: []string{
+				`data-src="/api/cast?url=https%3A%2F%2Fasciinema.org%2Fa%2F335480.cast" data-title="Demo">`,
+				`<a href="https://asciinema.org/a/335480">Demo</a>`,
+			},
+		},
+		{
+			name:     "Recording file on another site",
+			input:    "![Build](https://casts.example.com/build.cast)",
+			docPath:  "docs",
+			contains: []string{`data-src="/api/cast?url=https%3A%2F%2Fcasts.example.com%2Fbuild.cast"`},
+		},
+		{
+			name:     "Other images and code are left alone",
+			input:    "![a](a.png) ![b](https://example.com/a/123)\n```\n![c](c.cast)\n```",
+			docPath:  "docs",
+			contains: []string{"![a](a.png) ![b](https://example.com/a/123)\n```\n![c](c.cast)\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CastPreprocessor(tt.input, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("CastPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+		})
+	}
+}
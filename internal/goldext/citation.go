@@ -0,0 +1,280 @@
+package goldext
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/config"
+)
+
+// Citation styles
+const (
+	CitationStyleNumeric    = "numeric"
+	CitationStyleAuthorYear = "author-year"
+)
+
+var (
+	citationRegex   = regexp.MustCompile(`\[(@[^\[\]]+)\]`)
+	citationKeyChar = regexp.MustCompile(`^@([A-Za-z0-9_:.\-/]+)$`)
+)
+
+// citationRef is a single key inside a [@key, locator] group
+type citationRef struct {
+	key     string
+	locator string
+}
+
+// citationState tracks the citations of one document while rendering
+type citationState struct {
+	style   string
+	entries map[string]*BibEntry
+	order   []string       // Cited keys in order of first appearance
+	numbers map[string]int // Key -> citation number
+}
+
+// CitationPreprocessor replaces [@key] citations with links to an auto-generated
+// references section. Entries are read from references.bib or references.yaml
+// attached to the page or one of its parent directories. The references list is
+// placed at a :::references::: marker, or appended to the end of the page.
+func CitationPreprocessor(markdown string, docPath string) string {
+	if !strings.Contains(markdown, "[@") {
+		return markdown
+	}
+
+	state := &citationState{
+		style:   config.Cfg.Extensions.Citations.Style,
+		entries: LoadBibliography(filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir), docPath),
+		numbers: make(map[string]int),
+	}
+	if state.style != CitationStyleAuthorYear {
+		state.style = CitationStyleNumeric
+	}
+
+	lines := strings.Split(markdown, "\n")
+	processedLines := make([]string, 0, len(lines))
+
+	inBacktickBlock := false
+	inTildeBlock := false
+	markerIndex := -1
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+			processedLines = append(processedLines, line)
+			continue
+		}
+		if strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+			processedLines = append(processedLines, line)
+			continue
+		}
+		if inBacktickBlock || inTildeBlock {
+			processedLines = append(processedLines, line)
+			continue
+		}
+
+		if trimmedLine == ":::references:::" && markerIndex == -1 {
+			markerIndex = len(processedLines)
+			processedLines = append(processedLines, "")
+			continue
+		}
+
+		// Leave inline code untouched
+		segments := strings.Split(line, "`")
+		for i := 0; i < len(segments); i += 2 {
+			segments[i] = state.replaceCitations(segments[i])
+		}
+		processedLines = append(processedLines, strings.Join(segments, "`"))
+	}
+
+	if len(state.order) == 0 {
+		if markerIndex != -1 {
+			processedLines = append(processedLines[:markerIndex], processedLines[markerIndex+1:]...)
+		}
+		return strings.Join(processedLines, "\n")
+	}
+
+	references := state.renderReferences()
+	if markerIndex != -1 {
+		processedLines[markerIndex] = references
+	} else {
+		processedLines = append(processedLines, "", references)
+	}
+
+	return strings.Join(processedLines, "\n")
+}
+
+// replaceCitations replaces all citation groups in a piece of text
+func (s *citationState) replaceCitations(text string) string {
+	matches := citationRegex.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		// [@key](url) is a regular markdown link
+		if m[1] < len(text) && text[m[1]] == '(' {
+			continue
+		}
+
+		refs, ok := parseCitationGroup(text[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(s.renderCitation(refs))
+		last = m[1]
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+// parseCitationGroup parses "@a, p. 4; @b" into its references
+func parseCitationGroup(group string) ([]citationRef, bool) {
+	var refs []citationRef
+	for _, part := range strings.Split(group, ";") {
+		part = strings.TrimSpace(part)
+		key, locator := part, ""
+		if comma := strings.IndexByte(part, ','); comma != -1 {
+			key = strings.TrimSpace(part[:comma])
+			locator = strings.TrimSpace(part[comma+1:])
+		}
+		m := citationKeyChar.FindStringSubmatch(key)
+		if m == nil || isCrossRefKey(m[1]) {
+			return nil, false
+		}
+		refs = append(refs, citationRef{key: m[1], locator: locator})
+	}
+	return refs, len(refs) > 0
+}
+
+// renderCitation builds the inline HTML for a citation group
+func (s *citationState) renderCitation(refs []citationRef) string {
+	var parts []string
+	for _, ref := range refs {
+		entry, ok := s.entries[ref.key]
+		if !ok {
+			parts = append(parts, `<span class="citation-missing" title="Unknown citation">`+html.EscapeString(ref.key)+`</span>`)
+			continue
+		}
+
+		if _, seen := s.numbers[ref.key]; !seen {
+			s.order = append(s.order, ref.key)
+			s.numbers[ref.key] = len(s.order)
+		}
+
+		label := fmt.Sprintf("%d", s.numbers[ref.key])
+		if s.style == CitationStyleAuthorYear {
+			label = html.EscapeString(entry.ShortAuthors())
+			if entry.Year != "" {
+				label += ", " + html.EscapeString(entry.Year)
+			}
+		}
+		if ref.locator != "" {
+			label += ", " + html.EscapeString(ref.locator)
+		}
+
+		parts = append(parts, `<a href="#ref-`+html.EscapeString(ref.key)+`">`+label+`</a>`)
+	}
+
+	separator := ", "
+	open, close := "[", "]"
+	if s.style == CitationStyleAuthorYear {
+		separator = "; "
+		open, close = "(", ")"
+	}
+
+	return `<span class="citation">` + open + strings.Join(parts, separator) + close + `</span>`
+}
+
+// renderReferences builds the references section for all cited entries.
+// The list contains no blank lines so Goldmark treats it as a single HTML block.
+func (s *citationState) renderReferences() string {
+	keys := append([]string{}, s.order...)
+	listTag := "ol"
+	if s.style == CitationStyleAuthorYear {
+		listTag = "ul"
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, b := s.entries[keys[i]], s.entries[keys[j]]
+			if a.ShortAuthors() != b.ShortAuthors() {
+				return a.ShortAuthors() < b.ShortAuthors()
+			}
+			return a.Year < b.Year
+		})
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## References\n\n")
+	sb.WriteString("<" + listTag + " class=\"references\">\n")
+	for _, key := range keys {
+		sb.WriteString("<li id=\"ref-" + html.EscapeString(key) + "\">" + s.formatEntry(s.entries[key]) + "</li>\n")
+	}
+	sb.WriteString("</" + listTag + ">")
+
+	return sb.String()
+}
+
+// formatEntry renders a bibliography entry in the configured style
+func (s *citationState) formatEntry(e *BibEntry) string {
+	esc := html.EscapeString
+	var parts []string
+
+	authors := strings.Join(e.Authors, ", ")
+	if len(e.Authors) > 1 {
+		authors = strings.Join(e.Authors[:len(e.Authors)-1], ", ") + " and " + e.Authors[len(e.Authors)-1]
+	}
+
+	if s.style == CitationStyleAuthorYear {
+		head := esc(authors)
+		if e.Year != "" {
+			head += " (" + esc(e.Year) + ")"
+		}
+		if head != "" {
+			parts = append(parts, head)
+		}
+		if e.Title != "" {
+			parts = append(parts, esc(e.Title))
+		}
+	} else {
+		if authors != "" {
+			parts = append(parts, esc(authors))
+		}
+		if e.Title != "" {
+			parts = append(parts, "“"+esc(e.Title)+"”")
+		}
+	}
+
+	if e.Container != "" {
+		container := "<em>" + esc(e.Container) + "</em>"
+		if e.Volume != "" {
+			container += ", vol. " + esc(e.Volume)
+		}
+		parts = append(parts, container)
+	}
+	if e.Pages != "" {
+		parts = append(parts, "pp. "+esc(e.Pages))
+	}
+	if s.style != CitationStyleAuthorYear && e.Year != "" {
+		parts = append(parts, esc(e.Year))
+	}
+
+	result := strings.Join(parts, ". ") + "."
+	if e.DOI != "" {
+		doi := "https://doi.org/" + strings.TrimPrefix(e.DOI, "https://doi.org/")
+		result += ` <a href="` + esc(doi) + `">` + esc(doi) + `</a>`
+	} else if e.URL != "" {
+		result += ` <a href="` + esc(e.URL) + `">` + esc(e.URL) + `</a>`
+	}
+
+	return result
+}
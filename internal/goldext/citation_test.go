@@ -0,0 +1,101 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestParseBibTeX(t *testing.T) {
+	bib := `@article{smith2020,
+  author = {Smith, Jane and Doe, John},
+  title = {A {Study} of Wikis},
+  journal = "Journal of Docs",
+  year = 2020,
+  pages = {1--10}
+}
+@comment{ignored}`
+
+	entries := ParseBibTeX(bib)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Key != "smith2020" || e.Title != "A Study of Wikis" || e.Year != "2020" || e.Container != "Journal of Docs" || e.Pages != "1–10" {
+		t.Errorf("Unexpected entry: %+v", e)
+	}
+	if e.ShortAuthors() != "Smith & Doe" {
+		t.Errorf("Expected short authors %q, got %q", "Smith & Doe", e.ShortAuthors())
+	}
+}
+
+func TestCitationPreprocessor(t *testing.T) {
+	root := t.TempDir()
+	spaceDir := filepath.Join(root, "documents", "research")
+	pageDir := filepath.Join(spaceDir, "paper")
+	if err := os.MkdirAll(pageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(spaceDir, "references.bib"), []byte("@book{knuth1984, author={Donald Knuth}, title={Literate Programming}, year={1984}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "references.yaml"), []byte("doe2021:\n  author: [John Doe]\n  title: Docs\n  year: 2021\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		style    string
+		input    string
+		contains []string
+	}{
+		{
+			name:  "Numeric",
+			style: CitationStyleNumeric,
+			input: "See [@doe2021; @knuth1984, p. 5] and [@doe2021].",
+			contains: []string{
+				`<span class="citation">[<a href="#ref-doe2021">1</a>, <a href="#ref-knuth1984">2, p. 5</a>]</span>`,
+				`<span class="citation">[<a href="#ref-doe2021">1</a>]</span>`,
+				"## References",
+				`<li id="ref-knuth1984">Donald Knuth. “Literate Programming”. 1984.</li>`,
+			},
+		},
+		{
+			name:  "Author-year",
+			style: CitationStyleAuthorYear,
+			input: "See [@knuth1984].\n\n:::references:::\n\nEnd",
+			contains: []string{
+				`<span class="citation">(<a href="#ref-knuth1984">Knuth, 1984</a>)</span>`,
+				"## References\n\n<ul class=\"references\">",
+				"</ul>\n\nEnd",
+			},
+		},
+		{
+			name:     "Code and links are ignored",
+			style:    CitationStyleNumeric,
+			input:    "`[@doe2021]` and [@doe2021](http://example.com)",
+			contains: []string{"`[@doe2021]` and [@doe2021](http://example.com)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Cfg.Extensions.Citations.Style = tt.style
+			result := CitationPreprocessor(tt.input, "research/paper")
+			for _, expected := range tt.contains {
+				if !strings.Contains(result, expected) {
+					t.Errorf("Expected output to contain %q, got: %q", expected, result)
+				}
+			}
+		})
+	}
+}
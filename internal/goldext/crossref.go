@@ -0,0 +1,230 @@
+package goldext
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of cross-reference targets and the words their numbers are shown with
+var crossRefNames = map[string]string{
+	"fig": "Figure",
+	"tbl": "Table",
+	"sec": "Section",
+}
+
+var (
+	numberedHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*$`)
+	headingNumberRegex   = regexp.MustCompile(`^<span class="heading-number">([0-9.]+)</span>\s*`)
+	figureLineRegex      = regexp.MustCompile(`^!\[(.*)\]\((.+)\)\s*\{#fig:([A-Za-z0-9_-]+)\}$`)
+	tableCaptionRegex    = regexp.MustCompile(`^Table:\s*(.+?)\s*\{#tbl:([A-Za-z0-9_-]+)\}$`)
+	crossRefHeadingRegex = regexp.MustCompile(`^#{1,6}\s+(.+?)\s+\{#([a-zA-Z0-9-]+)\}$`)
+	crossRefRegex        = regexp.MustCompile(`(^|[^A-Za-z0-9_@])(\[?)@(fig|tbl|sec):([A-Za-z0-9_-]+)(\]?)`)
+	crossRefTagRegex     = regexp.MustCompile(`<[^>]+>`)
+)
+
+// crossRefTarget is a numbered figure, table or heading of a page
+type crossRefTarget struct {
+	id       string // Id of the element
+	label    string // Number, or the text of an unnumbered heading
+	numbered bool
+}
+
+// isCrossRefKey reports whether a [@key] is a cross-reference rather than a citation
+func isCrossRefKey(key string) bool {
+	kind, _, ok := strings.Cut(key, ":")
+	return ok && crossRefNames[kind] != ""
+}
+
+// NumberHeadings numbers the headings of a page with numbering: true in its
+// frontmatter: 1., 1.1, 1.1.1 and so on. Numbering starts at the highest level
+// used, unless the page has a single heading at that level before all others,
+// which is taken as the title of the page. The numbers are left out of the
+// heading ids, so links to the headings keep working.
+func NumberHeadings(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+
+	type heading struct {
+		line  int
+		level int
+	}
+	var headings []heading
+	inCodeBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock || !strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := numberedHeadingRegex.FindStringSubmatch(line); m != nil {
+			headings = append(headings, heading{line: i, level: len(m[1])})
+		}
+	}
+	if len(headings) == 0 {
+		return markdown
+	}
+
+	// The highest level, and how many headings use it
+	top, count := 7, 0
+	for _, h := range headings {
+		if h.level < top {
+			top, count = h.level, 0
+		}
+		if h.level == top {
+			count++
+		}
+	}
+	if count == 1 && headings[0].level == top && len(headings) > 1 {
+		headings = headings[1:]
+		top = 7
+		for _, h := range headings {
+			top = min(top, h.level)
+		}
+	}
+
+	counters := make([]int, 6)
+	for _, h := range headings {
+		depth := h.level - top
+		counters[depth]++
+		for j := depth + 1; j < len(counters); j++ {
+			counters[j] = 0
+		}
+		parts := make([]string, depth+1)
+		for j := range parts {
+			parts[j] = strconv.Itoa(counters[j])
+		}
+		number := strings.Join(parts, ".")
+		if depth == 0 {
+			number += "."
+		}
+
+		m := numberedHeadingRegex.FindStringSubmatch(lines[h.line])
+		lines[h.line] = m[1] + ` <span class="heading-number">` + number + `</span> ` + m[2]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// CrossRefPreprocessor numbers figures and tables with a caption and a label,
+// and replaces references to them with links showing their number:
+//
+//	![Request flow](flow.png){#fig:flow}
+//
+//	Table: Supported versions {#tbl:versions}
+//
+//	As @fig:flow and @tbl:versions show, ... see @sec:limits.
+//
+// @sec: refers to a heading by its id and shows its number on pages with
+// numbered headings, its text otherwise. It runs after TocPreprocessor, which
+// gives every heading an id.
+func CrossRefPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, "{#fig:") && !strings.Contains(markdown, "{#tbl:") && !crossRefRegex.MatchString(markdown) {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	targets := make(map[string]crossRefTarget)
+	figures, tables := 0, 0
+
+	// First pass: number the figures and tables, and collect the headings
+	inCodeBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+
+		if m := figureLineRegex.FindStringSubmatch(trimmed); m != nil {
+			key := "fig:" + m[3]
+			if _, ok := targets[key]; ok {
+				continue
+			}
+			figures++
+			target := crossRefTarget{id: "fig-" + m[3], label: strconv.Itoa(figures), numbered: true}
+			targets[key] = target
+			lines[i] = "\n" + `<figure class="numbered-figure" id="` + target.id + `">` + "\n\n" +
+				"![" + m[1] + "](" + m[2] + ")\n\n" +
+				"<figcaption>\n\n**Figure " + target.label + ":** " + m[1] + "\n\n</figcaption>\n</figure>"
+			continue
+		}
+
+		if m := tableCaptionRegex.FindStringSubmatch(trimmed); m != nil {
+			key := "tbl:" + m[2]
+			if _, ok := targets[key]; ok {
+				continue
+			}
+			tables++
+			target := crossRefTarget{id: "tbl-" + m[2], label: strconv.Itoa(tables), numbered: true}
+			targets[key] = target
+			lines[i] = "\n" + `<div class="table-caption" id="` + target.id + `">` + "\n\n" +
+				"**Table " + target.label + ":** " + m[1] + "\n\n</div>\n"
+			continue
+		}
+
+		if m := crossRefHeadingRegex.FindStringSubmatch(trimmed); m != nil {
+			key := "sec:" + m[2]
+			if _, ok := targets[key]; ok {
+				continue
+			}
+			target := crossRefTarget{id: m[2]}
+			if number := headingNumberRegex.FindStringSubmatch(m[1]); number != nil {
+				target.label = strings.TrimSuffix(number[1], ".")
+				target.numbered = true
+			} else {
+				target.label = strings.TrimSpace(html.UnescapeString(crossRefTagRegex.ReplaceAllString(m[1], "")))
+			}
+			targets[key] = target
+		}
+	}
+
+	// Second pass: replace the references, outside code
+	inCodeBlock = false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock || !strings.Contains(line, "@") {
+			continue
+		}
+
+		segments := strings.Split(line, "`")
+		for j := 0; j < len(segments); j += 2 {
+			segments[j] = crossRefRegex.ReplaceAllStringFunc(segments[j], func(match string) string {
+				m := crossRefRegex.FindStringSubmatch(match)
+				open, close := m[2], m[5]
+				// [@fig:flow], as Pandoc writes them, lose their brackets
+				if open != "" && close != "" {
+					open, close = "", ""
+				}
+				return m[1] + open + renderCrossRef(targets, m[3], m[4]) + close
+			})
+		}
+		lines[i] = strings.Join(segments, "`")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderCrossRef returns the link to a figure, table or heading, or marks a
+// reference to a label the page doesn't have
+func renderCrossRef(targets map[string]crossRefTarget, kind, name string) string {
+	target, ok := targets[kind+":"+name]
+	if !ok {
+		return `<span class="crossref-missing" title="Unknown reference">@` + kind + ":" + html.EscapeString(name) + `</span>`
+	}
+	text := target.label
+	if target.numbered {
+		text = crossRefNames[kind] + " " + target.label
+	}
+	return "[" + escapeWikiLinkText(text) + "](#" + target.id + ")"
+}
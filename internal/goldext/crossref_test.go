@@ -0,0 +1,83 @@
+package goldext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumberHeadings(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Nested levels",
+			input:    "## Scope\n### Goals\n### Limits\n## Design",
+			expected: "## <span class=\"heading-number\">1.</span> Scope\n### <span class=\"heading-number\">1.1</span> Goals\n### <span class=\"heading-number\">1.2</span> Limits\n## <span class=\"heading-number\">2.</span> Design",
+		},
+		{
+			name:     "Single top heading is the title",
+			input:    "# Spec\n## Scope\n## Design",
+			expected: "# Spec\n## <span class=\"heading-number\">1.</span> Scope\n## <span class=\"heading-number\">2.</span> Design",
+		},
+		{
+			name:     "Code blocks are skipped",
+			input:    "## One\n```\n## Not a heading\n```\n#hashtag",
+			expected: "## <span class=\"heading-number\">1.</span> One\n```\n## Not a heading\n```\n#hashtag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NumberHeadings(tt.input); got != tt.expected {
+				t.Errorf("NumberHeadings() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCrossRefPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+	}{
+		{
+			name:  "Figures and tables are numbered",
+			input: "![One](a.png){#fig:one}\n\n![Two](b.png){#fig:two}\n\nTable: Versions {#tbl:versions}\n\nSee @fig:two and [@tbl:versions].",
+			contains: []string{
+				`<figure class="numbered-figure" id="fig-one">`,
+				"<figcaption>\n\n**Figure 2:** Two\n\n</figcaption>",
+				"<div class=\"table-caption\" id=\"tbl-versions\">\n\n**Table 1:** Versions",
+				"See [Figure 2](#fig-two) and [Table 1](#tbl-versions).",
+			},
+		},
+		{
+			name:     "Sections",
+			input:    "## <span class=\"heading-number\">2.1</span> Limits {#limits}\n## Other [draft] {#other}\n\n@sec:limits, @sec:other",
+			contains: []string{"[Section 2.1](#limits), [Other \\[draft\\]](#other)"},
+		},
+		{
+			name:     "Unknown label",
+			input:    "See @fig:missing.",
+			contains: []string{`See <span class="crossref-missing" title="Unknown reference">@fig:missing</span>.`},
+		},
+		{
+			name:     "Code and e-mail addresses are left alone",
+			input:    "![One](a.png){#fig:one}\n\n`@fig:one` and me@fig:one\n```\n@fig:one\n```",
+			contains: []string{"`@fig:one` and me@fig:one\n```\n@fig:one\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CrossRefPreprocessor(tt.input, "")
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("CrossRefPreprocessor() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package goldext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// DiagramPreviewSuffix is appended to the name of a diagram attachment for the
+// SVG its editor exports along with it, which is what pages show
+const DiagramPreviewSuffix = ".svg"
+
+// diagramImageRegex matches images of draw.io and Excalidraw attachments:
+// ![alt](network.drawio) or ![alt](sketch.excalidraw)
+var diagramImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+\.((?i:drawio|excalidraw)))\)`)
+
+// DiagramFormat returns the editor of a diagram attachment, "drawio" or
+// "excalidraw", or "" for other files
+func DiagramFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".drawio"):
+		return "drawio"
+	case strings.HasSuffix(lower, ".excalidraw"):
+		return "excalidraw"
+	}
+	return ""
+}
+
+// DiagramPreprocessor shows images of .drawio and .excalidraw attachments as
+// their SVG previews, in an element holding the address of the diagram for
+// the "Edit diagram" button
+func DiagramPreprocessor(markdown string, docPath string) string {
+	sections := splitCodeSections(markdown)
+	for i := range sections {
+		if sections[i].isCode {
+			continue
+		}
+		sections[i].content = diagramImageRegex.ReplaceAllStringFunc(sections[i].content, func(match string) string {
+			parts := diagramImageRegex.FindStringSubmatch(match)
+			path := parts[2]
+			if strings.Contains(path, "://") {
+				return match
+			}
+			source := FileURL(path, docPath)
+			return `<span class="diagram-attachment" data-diagram="` + html.EscapeString(source) +
+				`" data-format="` + strings.ToLower(parts[3]) + `"><img src="` + html.EscapeString(source+DiagramPreviewSuffix) +
+				`" alt="` + html.EscapeString(parts[1]) + `" loading="lazy"></span>`
+		})
+	}
+	return joinSections(sections)
+}
@@ -0,0 +1,47 @@
+package goldext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagramPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		contains []string
+	}{
+		{
+			name:    "draw.io attachment",
+			input:   "See ![Network](network.drawio) here",
+			docPath: "ops/lan",
+			contains: []string{
+				`See <span class="diagram-attachment" data-diagram="/api/files/ops/lan/network.drawio" data-format="drawio">`,
+				`<img src="/api/files/ops/lan/network.drawio.svg" alt="Network" loading="lazy"></span> here`,
+			},
+		},
+		{
+			name:     "Excalidraw attachment of the homepage",
+			input:    `![A "sketch"](my_sketch.Excalidraw)`,
+			contains: []string{`data-diagram="/api/files/pages/home/my_sketch.Excalidraw" data-format="excalidraw"`, `alt="A &#34;sketch&#34;"`},
+		},
+		{
+			name:     "Remote diagrams, other images and code are left alone",
+			input:    "![a](https://example.com/a.drawio) ![b](b.png)\n```\n![c](c.drawio)\n```",
+			docPath:  "docs",
+			contains: []string{"![a](https://example.com/a.drawio) ![b](b.png)\n```\n![c](c.drawio)\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DiagramPreprocessor(tt.input, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("DiagramPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+		})
+	}
+}
@@ -160,6 +160,7 @@ func RestoreDirectionBlocks(htmlContent string) string {
 			extension.Footnote,
 			extension.DefinitionList,
 			extension.GFM,
+			Bidi,
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
@@ -190,10 +191,10 @@ func RestoreDirectionBlocks(htmlContent string) string {
 		var buf bytes.Buffer
 		if err := md.Convert([]byte(content), &buf); err != nil {
 			// If error, just use unprocessed content
-			result = strings.Replace(result, placeholder, fmt.Sprintf("<div class=\"%s\">%s</div>", dirType, content), 1)
+			result = strings.Replace(result, placeholder, fmt.Sprintf("<div class=\"%s\" dir=\"%s\">%s</div>", dirType, dirType, content), 1)
 		} else {
 			// Use the rendered HTML inside the direction div
-			result = strings.Replace(result, placeholder, fmt.Sprintf("<div class=\"%s\">%s</div>", dirType, buf.String()), 1)
+			result = strings.Replace(result, placeholder, fmt.Sprintf("<div class=\"%s\" dir=\"%s\">%s</div>", dirType, dirType, buf.String()), 1)
 		}
 	}
 
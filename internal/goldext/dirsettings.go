@@ -0,0 +1,31 @@
+package goldext
+
+import (
+	"path/filepath"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/rendersettings"
+)
+
+// DirectorySettings returns the rendering options the _settings.yaml files of
+// the directories of the page at docPath set
+func DirectorySettings(docPath string) *rendersettings.Settings {
+	if config.Cfg == nil {
+		return &rendersettings.Settings{}
+	}
+	return rendersettings.For(filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir), docPath)
+}
+
+// ExtensionEnabled reports whether an extension directories can turn off is
+// on for the page at docPath
+func ExtensionEnabled(docPath, name string) bool {
+	return DirectorySettings(docPath).Enabled(name, true)
+}
+
+// wikiLinksEnabled reports whether the [[wikilinks]] of Obsidian are rendered
+// on the page at docPath: when enabled in the configuration, unless its
+// directory turns them off, or when its directory turns them on
+func wikiLinksEnabled(docPath string) bool {
+	enabled := config.Cfg != nil && config.Cfg.Extensions.Obsidian.Enable
+	return DirectorySettings(docPath).Enabled("wikilinks", enabled)
+}
@@ -0,0 +1,77 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestDirectorySettings(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"documents/_settings.yaml":              "extensions:\n  emoji: false\ntoc:\n  max_level: 2\n",
+		"documents/specs/_settings.yaml":        "extensions:\n  emoji: true\n  wikilinks: true\ntoc:\n  auto: true\n",
+		"documents/specs/legacy/_settings.yaml": "toc:\n  auto: false\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	defer func() { config.Cfg = previous }()
+
+	page := "# Spec\n\n## Scope\n\n### Goals\n\n## Design\n\n## Limits\n\n:smile: [[Other]]"
+
+	tests := []struct {
+		name        string
+		docPath     string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "Top directory turns an extension off",
+			docPath:     "notes",
+			contains:    []string{":smile:", "[[Other]]"},
+			notContains: []string{"wiki-toc"},
+		},
+		{
+			name:        "Subdirectory turns extensions on and adds a table of contents",
+			docPath:     "specs/protocol",
+			contains:    []string{"{#spec}\n\n<nav class=\"wiki-toc", `<li><a href="#design">`, "[Other](/specs/other)"},
+			notContains: []string{":smile:", `<li><a href="#goals">`},
+		},
+		{
+			name:        "Deeper directory overrides",
+			docPath:     "specs/legacy/v1",
+			notContains: []string{"wiki-toc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProcessMarkdown(page, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("ProcessMarkdown() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.notContains {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("ProcessMarkdown() = %q, don't want %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package goldext
+
+import "strings"
+
+// replaceFencedBlocks replaces the code blocks whose info string is one of
+// languages with what render returns for their lines. Code blocks of other
+// languages are left alone, with the blocks written inside them. Unclosed
+// blocks run to the end of the page, like code blocks.
+func replaceFencedBlocks(markdown string, languages []string, render func(language string, content []string) string) string {
+	lines := strings.Split(markdown, "\n")
+	result := make([]string, 0, len(lines))
+	fence := ""      // Fence of the code block being skipped
+	blockFence := "" // Fence of the block being collected
+	language := ""
+	var content []string
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if blockFence != "" {
+			if trimmed == blockFence {
+				result = append(result, render(language, content))
+				blockFence = ""
+				continue
+			}
+			content = append(content, line)
+			continue
+		}
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+				fence = ""
+			}
+			result = append(result, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			opening := trimmed[:3]
+			info := strings.TrimSpace(trimmed[3:])
+			if !strings.HasPrefix(info, opening[:1]) && containsString(languages, info) {
+				blockFence, language = opening, info
+				content = nil
+				continue
+			}
+			fence = opening
+		}
+		result = append(result, line)
+	}
+
+	if blockFence != "" {
+		result = append(result, render(language, content))
+	}
+	return strings.Join(result, "\n")
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package goldext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/forms"
+)
+
+var formShortcodeRegex = regexp.MustCompile(`:::form\s+([a-z0-9][a-z0-9_-]*)\s*:::`)
+
+// formPlaceholderRegex matches the placeholders of forms in the sanitized HTML
+var formPlaceholderRegex = regexp.MustCompile(`<div class="wiki-form-placeholder" data-form-id="([a-z0-9][a-z0-9_-]*)"></div>`)
+
+// FormPreprocessor replaces :::form id::: shortcodes with placeholders for the
+// forms. The sanitizer drops form controls, so the forms are only rendered by
+// RestoreForms once the page has been sanitized. Submission is handled
+// client-side by forms.js which posts to /api/forms/{id}/submit.
+func FormPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, ":::form") {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	processedLines := make([]string, 0, len(lines))
+
+	inBacktickBlock := false
+	inTildeBlock := false
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+			processedLines = append(processedLines, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+			processedLines = append(processedLines, line)
+			continue
+		}
+
+		// Only whole-line shortcodes are replaced, forms are block elements
+		if inBacktickBlock || inTildeBlock || !formShortcodeRegex.MatchString(trimmedLine) {
+			processedLines = append(processedLines, line)
+			continue
+		}
+
+		id := formShortcodeRegex.FindStringSubmatch(trimmedLine)[1]
+		processedLines = append(processedLines, "<div class=\"wiki-form-placeholder\" data-form-id=\""+id+"\"></div>")
+	}
+
+	return strings.Join(processedLines, "\n")
+}
+
+// RestoreForms replaces the placeholders of FormPreprocessor with the HTML forms
+// of the matching structured form definitions. It is called on sanitized HTML,
+// the forms are defined by admins and may hold form controls.
+func RestoreForms(rendered string) string {
+	if !strings.Contains(rendered, "wiki-form-placeholder") {
+		return rendered
+	}
+	return formPlaceholderRegex.ReplaceAllStringFunc(rendered, func(placeholder string) string {
+		id := formPlaceholderRegex.FindStringSubmatch(placeholder)[1]
+		form, err := forms.Get(config.Cfg.Wiki.RootDir, id)
+		if err != nil {
+			return "<div class=\"wiki-form-error\">Form not found: " + html.EscapeString(id) + "</div>"
+		}
+		return renderFormHTML(form)
+	})
+}
+
+// renderFormHTML builds the HTML markup for a structured form.
+// The output contains no blank lines so Goldmark treats it as a single HTML block.
+func renderFormHTML(form *forms.Form) string {
+	var sb strings.Builder
+	esc := html.EscapeString
+
+	sb.WriteString("<form class=\"wiki-form\" data-form-id=\"" + esc(form.ID) + "\">\n")
+	sb.WriteString("<h3 class=\"wiki-form-title\">" + esc(form.Title) + "</h3>\n")
+	if form.Description != "" {
+		sb.WriteString("<p class=\"wiki-form-description\">" + esc(form.Description) + "</p>\n")
+	}
+
+	for _, field := range form.Fields {
+		id := "wiki-form-" + form.ID + "-" + field.Name
+		label := field.Label
+		if label == "" {
+			label = field.Name
+		}
+		required := ""
+		if field.Required {
+			required = " required"
+		}
+
+		sb.WriteString("<div class=\"form-group\" data-field=\"" + esc(field.Name) + "\">\n")
+		if field.Type == forms.FieldCheckbox {
+			checked := ""
+			if field.Default == "true" {
+				checked = " checked"
+			}
+			sb.WriteString("<label><input type=\"checkbox\" id=\"" + esc(id) + "\" name=\"" + esc(field.Name) + "\"" + checked + "> " + esc(label) + "</label>\n")
+		} else {
+			sb.WriteString("<label for=\"" + esc(id) + "\">" + esc(label))
+			if field.Required {
+				sb.WriteString(" <span class=\"required\">*</span>")
+			}
+			sb.WriteString("</label>\n")
+
+			switch field.Type {
+			case forms.FieldTextarea:
+				sb.WriteString("<textarea id=\"" + esc(id) + "\" name=\"" + esc(field.Name) + "\" rows=\"5\"" + required + ">" + esc(field.Default) + "</textarea>\n")
+			case forms.FieldSelect:
+				sb.WriteString("<select id=\"" + esc(id) + "\" name=\"" + esc(field.Name) + "\"" + required + ">\n")
+				if !field.Required {
+					sb.WriteString("<option value=\"\"></option>\n")
+				}
+				for _, option := range field.Options {
+					selected := ""
+					if option == field.Default {
+						selected = " selected"
+					}
+					sb.WriteString("<option value=\"" + esc(option) + "\"" + selected + ">" + esc(option) + "</option>\n")
+				}
+				sb.WriteString("</select>\n")
+			default:
+				inputType := "text"
+				if field.Type == forms.FieldNumber || field.Type == forms.FieldDate {
+					inputType = field.Type
+				}
+				// Patterns are validated server-side only, later preprocessors
+				// (superscript, typography) would mangle them inside attributes
+				sb.WriteString("<input type=\"" + inputType + "\" id=\"" + esc(id) + "\" name=\"" + esc(field.Name) + "\" value=\"" + esc(field.Default) + "\"" + required + ">\n")
+			}
+		}
+		if field.Help != "" {
+			sb.WriteString("<small class=\"form-help\">" + esc(field.Help) + "</small>\n")
+		}
+		sb.WriteString("<div class=\"field-error\"></div>\n")
+		sb.WriteString("</div>\n")
+	}
+
+	sb.WriteString("<div class=\"wiki-form-status\"></div>\n")
+	sb.WriteString("<button type=\"submit\" class=\"dialog-button primary\">Submit</button>\n")
+	sb.WriteString("</form>")
+
+	return sb.String()
+}
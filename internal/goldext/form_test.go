@@ -0,0 +1,80 @@
+package goldext
+
+import (
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/forms"
+	"wiki-go/internal/sanitize"
+)
+
+func TestFormsSurviveSanitizing(t *testing.T) {
+	root := t.TempDir()
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	defer func() { config.Cfg = previous }()
+
+	form := &forms.Form{
+		ID:        "request",
+		Title:     "Request",
+		TargetDir: "requests",
+		Fields: []forms.Field{
+			{Name: "title", Label: "Title", Type: forms.FieldText, Required: true},
+			{Name: "details", Type: forms.FieldTextarea},
+			{Name: "team", Type: forms.FieldSelect, Options: []string{"a", "b"}},
+		},
+	}
+	if err := forms.Save(root, form); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		markdown string
+		contains []string
+		absent   []string
+	}{
+		{
+			name:     "Forms",
+			markdown: ":::form request:::",
+			contains: []string{`<form class="wiki-form" data-form-id="request">`, `<input type="text"`, "<textarea", "<select", `<button type="submit"`},
+			absent:   []string{"wiki-form-placeholder"},
+		},
+		{
+			name:     "Missing forms",
+			markdown: ":::form missing:::",
+			contains: []string{"Form not found: missing"},
+			absent:   []string{"<form"},
+		},
+		{
+			name:     "Form controls written in the page",
+			markdown: `<form><input type="password" name="password"><button>Log in</button></form>`,
+			absent:   []string{"<form", "<input", "<button"},
+		},
+		{
+			name:     "Placeholders written in the page only restore defined forms",
+			markdown: `<div class="wiki-form-placeholder" data-form-id="request"><input type="password"></div>`,
+			contains: []string{`<form class="wiki-form" data-form-id="request">`},
+			absent:   []string{`type="password"`},
+		},
+	}
+
+	policy := sanitize.DefaultPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RestoreForms(policy.Sanitize(FormPreprocessor(tt.markdown, "")))
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("rendered %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("rendered %q, don't want %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
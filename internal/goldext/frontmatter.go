@@ -1,6 +1,8 @@
 package goldext
 
 import (
+	"strings"
+
 	"wiki-go/internal/frontmatter"
 )
 
@@ -11,7 +13,11 @@ func FrontmatterPreprocessor(markdown string, _ string) string {
 		return markdown
 	}
 
-	// Parse frontmatter and get content without it
-	_, contentWithoutFrontmatter, _ := frontmatter.Parse(markdown)
+	// Parse frontmatter and get content without it. Frontmatter written for other
+	// tools, with fields the wiki can't read, is hidden all the same.
+	_, contentWithoutFrontmatter, ok := frontmatter.Parse(markdown)
+	if !ok {
+		contentWithoutFrontmatter = strings.TrimLeft(markdown[4+len(frontmatter.Extract(markdown))+4:], "\n")
+	}
 	return contentWithoutFrontmatter
 }
\ No newline at end of file
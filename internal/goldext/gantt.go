@@ -0,0 +1,288 @@
+package goldext
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ganttDateFormat is the format of the dates of gantt and timeline blocks
+const ganttDateFormat = "2006-01-02"
+
+// ganttDurationRegex matches durations like "5", "5d" and "3w"
+var ganttDurationRegex = regexp.MustCompile(`^(\d+)\s*([dw]?)$`)
+
+// GanttChart is what js/gantt.js draws for a gantt or timeline block, with
+// the start and end of every task worked out from their dependencies
+type GanttChart struct {
+	Title string      `json:"title,omitempty"`
+	Tasks []GanttTask `json:"tasks"`
+}
+
+// GanttTask is a scheduled task. End is the day after the last one, the start
+// of milestones.
+type GanttTask struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Group     string   `json:"group,omitempty"`
+	Start     string   `json:"start"`
+	End       string   `json:"end"`
+	Milestone bool     `json:"milestone,omitempty"`
+	Progress  int      `json:"progress,omitempty"` // Percent done
+	Depends   []string `json:"depends,omitempty"`  // IDs of the tasks that finish before it starts
+}
+
+// ganttList is a list written as a single value or a YAML sequence
+type ganttList []string
+
+func (l *ganttList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		for _, item := range strings.Split(value.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*l = append(*l, item)
+			}
+		}
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// ganttInput is a task as written in a block
+type ganttInput struct {
+	ID        string    `yaml:"id"`
+	Name      string    `yaml:"name"`
+	Group     string    `yaml:"group"`
+	Start     string    `yaml:"start"`
+	End       string    `yaml:"end"` // Last day of the task
+	Duration  string    `yaml:"duration"`
+	Milestone bool      `yaml:"milestone"`
+	Progress  int       `yaml:"progress"`
+	Depends   ganttList `yaml:"depends"`
+}
+
+// GanttPreprocessor turns ```gantt and ```timeline blocks, YAML lists of tasks,
+// into the charts drawn by js/gantt.js:
+//
+//	title: Migration
+//	tasks:
+//	  - name: Design
+//	    start: 2026-03-02
+//	    duration: 2w
+//	  - name: Build
+//	    depends: Design
+//	    duration: 10d
+//
+// Tasks start on their start date, or when the tasks they depend on are done,
+// or when the task before them is done.
+func GanttPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, "gantt") && !strings.Contains(markdown, "timeline") {
+		return markdown
+	}
+	return replaceFencedBlocks(markdown, []string{"gantt", "timeline"}, func(_ string, content []string) string {
+		return renderGantt(strings.Join(content, "\n"))
+	})
+}
+
+// renderGantt returns the element of a gantt block, or the errors found in it
+func renderGantt(content string) string {
+	chart, errs := ParseGantt(content)
+	if len(errs) > 0 {
+		return `<div class="gantt-error"><strong>Gantt:</strong> ` + html.EscapeString(strings.Join(errs, "; ")) + `</div>`
+	}
+	data, err := json.Marshal(chart)
+	if err != nil {
+		return `<div class="gantt-error"><strong>Gantt:</strong> ` + html.EscapeString(err.Error()) + `</div>`
+	}
+
+	// Without scripts, and until the chart is drawn, the tasks are listed
+	var rows strings.Builder
+	for _, task := range chart.Tasks {
+		end := task.Start
+		if !task.Milestone {
+			last, _ := time.Parse(ganttDateFormat, task.End)
+			end = last.AddDate(0, 0, -1).Format(ganttDateFormat)
+		}
+		rows.WriteString(`<tr><td>` + html.EscapeString(task.Name) + `</td><td>` + task.Start + `</td><td>` + end + `</td><td>` + html.EscapeString(strings.Join(task.Depends, ", ")) + `</td></tr>`)
+	}
+	table := `<table class="gantt-fallback"><thead><tr><th>Task</th><th>Start</th><th>End</th><th>Depends on</th></tr></thead><tbody>` + rows.String() + `</tbody></table>`
+	return `<div class="gantt-chart" data-gantt="` + base64.RawURLEncoding.EncodeToString(data) + `">` + table + `</div>`
+}
+
+// parseGanttDuration returns the days of a duration like "5", "5d" or "3w"
+func parseGanttDuration(value string) (int, bool) {
+	match := ganttDurationRegex.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, false
+	}
+	days, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	if match[2] == "w" {
+		days *= 7
+	}
+	return days, true
+}
+
+// ParseGantt reads the YAML of a gantt or timeline block, either a list of
+// tasks or a title and its tasks, and schedules them. It returns the problems
+// found in it.
+func ParseGantt(content string) (GanttChart, []string) {
+	var chart GanttChart
+	var inputs []ganttInput
+
+	var document struct {
+		Title string       `yaml:"title"`
+		Tasks []ganttInput `yaml:"tasks"`
+	}
+	if strings.HasPrefix(strings.TrimSpace(content), "-") {
+		if err := yaml.Unmarshal([]byte(content), &inputs); err != nil {
+			return chart, []string{"invalid YAML: " + err.Error()}
+		}
+	} else {
+		if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+			return chart, []string{"invalid YAML: " + err.Error()}
+		}
+		chart.Title = document.Title
+		inputs = document.Tasks
+	}
+	if len(inputs) == 0 {
+		return chart, []string{"no tasks"}
+	}
+
+	var errs []string
+	index := map[string]int{}
+	for i := range inputs {
+		input := &inputs[i]
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			errs = append(errs, fmt.Sprintf("task %d has no name", i+1))
+			continue
+		}
+		if input.ID = strings.TrimSpace(input.ID); input.ID == "" {
+			input.ID = input.Name
+		}
+		if _, ok := index[input.ID]; ok {
+			errs = append(errs, fmt.Sprintf("%q is used by two tasks", input.ID))
+			continue
+		}
+		index[input.ID] = i
+	}
+	for _, input := range inputs {
+		for _, dependency := range input.Depends {
+			if _, ok := index[dependency]; !ok {
+				errs = append(errs, fmt.Sprintf("%q depends on unknown task %q", input.Name, dependency))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return chart, errs
+	}
+
+	// Tasks are scheduled once the tasks they depend on are, which finds
+	// dependency cycles on the way
+	starts := make([]time.Time, len(inputs))
+	ends := make([]time.Time, len(inputs))
+	state := make([]int, len(inputs)) // 0 not scheduled, 1 being scheduled, 2 scheduled
+	var schedule func(i int) error
+	schedule = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("%q depends on itself through its dependencies", inputs[i].Name)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		input := inputs[i]
+
+		var start time.Time
+		if input.Start != "" {
+			date, err := time.Parse(ganttDateFormat, strings.TrimSpace(input.Start))
+			if err != nil {
+				return fmt.Errorf("%q starts on %q, dates are written as YYYY-MM-DD", input.Name, input.Start)
+			}
+			start = date
+		}
+		for _, dependency := range input.Depends {
+			j := index[dependency]
+			if err := schedule(j); err != nil {
+				return err
+			}
+			if ends[j].After(start) {
+				start = ends[j]
+			}
+		}
+		if start.IsZero() && i > 0 && len(input.Depends) == 0 {
+			if err := schedule(i - 1); err != nil {
+				return err
+			}
+			start = ends[i-1]
+		}
+		if start.IsZero() {
+			return fmt.Errorf("%q needs a start date or a task it depends on", input.Name)
+		}
+
+		var end time.Time
+		switch {
+		case input.Milestone:
+			end = start
+		case input.End != "":
+			last, err := time.Parse(ganttDateFormat, strings.TrimSpace(input.End))
+			if err != nil {
+				return fmt.Errorf("%q ends on %q, dates are written as YYYY-MM-DD", input.Name, input.End)
+			}
+			end = last.AddDate(0, 0, 1)
+			if !end.After(start) {
+				return fmt.Errorf("%q ends before it starts", input.Name)
+			}
+		case input.Duration != "":
+			days, ok := parseGanttDuration(input.Duration)
+			if !ok {
+				return fmt.Errorf("%q lasts %q, durations are written like 5d or 3w", input.Name, input.Duration)
+			}
+			end = start.AddDate(0, 0, days)
+		default:
+			// Timelines of events are written with their dates only
+			end = start
+		}
+
+		starts[i], ends[i] = start, end
+		state[i] = 2
+		return nil
+	}
+
+	for i, input := range inputs {
+		if err := schedule(i); err != nil {
+			return chart, []string{err.Error()}
+		}
+		progress := input.Progress
+		if progress < 0 {
+			progress = 0
+		} else if progress > 100 {
+			progress = 100
+		}
+		chart.Tasks = append(chart.Tasks, GanttTask{
+			ID:        input.ID,
+			Name:      input.Name,
+			Group:     strings.TrimSpace(input.Group),
+			Start:     starts[i].Format(ganttDateFormat),
+			End:       ends[i].Format(ganttDateFormat),
+			Milestone: ends[i].Equal(starts[i]),
+			Progress:  progress,
+			Depends:   []string(input.Depends),
+		})
+	}
+	return chart, nil
+}
@@ -0,0 +1,158 @@
+package goldext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGantt(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    GanttChart
+		errs    []string
+	}{
+		{
+			name: "Dependencies and durations",
+			content: `title: Migration
+tasks:
+  - name: Design
+    start: 2026-03-02
+    duration: 2w
+    progress: 150
+  - name: Build
+    depends: Design
+    duration: 10d
+  - name: Docs
+    start: 2026-03-04
+    end: 2026-03-06
+  - name: Launch
+    depends: [Build, Docs]
+    milestone: true`,
+			want: GanttChart{Title: "Migration", Tasks: []GanttTask{
+				{ID: "Design", Name: "Design", Start: "2026-03-02", End: "2026-03-16", Progress: 100},
+				{ID: "Build", Name: "Build", Start: "2026-03-16", End: "2026-03-26", Depends: []string{"Design"}},
+				{ID: "Docs", Name: "Docs", Start: "2026-03-04", End: "2026-03-07"},
+				{ID: "Launch", Name: "Launch", Start: "2026-03-26", End: "2026-03-26", Milestone: true, Depends: []string{"Build", "Docs"}},
+			}},
+		},
+		{
+			name: "List of tasks following each other, dependencies written later",
+			content: `- name: Order parts
+  id: order
+  start: 2026-01-05
+  duration: 3
+- name: Assemble
+  group: Workshop
+  duration: 1w
+- name: Test
+  depends: order, later
+  duration: 1d
+- name: Later thing
+  id: later
+  start: 2026-02-01`,
+			want: GanttChart{Tasks: []GanttTask{
+				{ID: "order", Name: "Order parts", Start: "2026-01-05", End: "2026-01-08"},
+				{ID: "Assemble", Name: "Assemble", Group: "Workshop", Start: "2026-01-08", End: "2026-01-15"},
+				{ID: "Test", Name: "Test", Start: "2026-02-01", End: "2026-02-02", Depends: []string{"order", "later"}},
+				{ID: "later", Name: "Later thing", Start: "2026-02-01", End: "2026-02-01", Milestone: true},
+			}},
+		},
+		{
+			name:    "Unknown dependency and duplicate",
+			content: "- name: A\n  start: 2026-01-01\n  depends: B\n- name: A",
+			errs:    []string{`"A" is used by two tasks`, `"A" depends on unknown task "B"`},
+		},
+		{
+			name:    "Cycle",
+			content: "- name: A\n  depends: B\n- name: B\n  depends: A",
+			errs:    []string{`"A" depends on itself through its dependencies`},
+		},
+		{
+			name:    "No start",
+			content: "- name: A\n  duration: 2d",
+			errs:    []string{`"A" needs a start date or a task it depends on`},
+		},
+		{
+			name:    "Invalid date",
+			content: "- name: A\n  start: 03/02/2026",
+			errs:    []string{`"A" starts on "03/02/2026", dates are written as YYYY-MM-DD`},
+		},
+		{
+			name:    "Invalid duration",
+			content: "- name: A\n  start: 2026-03-02\n  duration: two weeks",
+			errs:    []string{`"A" lasts "two weeks", durations are written like 5d or 3w`},
+		},
+		{
+			name:    "End before start",
+			content: "- name: A\n  start: 2026-03-02\n  end: 2026-03-01",
+			errs:    []string{`"A" ends before it starts`},
+		},
+		{
+			name:    "No tasks",
+			content: "title: Empty",
+			errs:    []string{"no tasks"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart, errs := ParseGantt(tt.content)
+			if !reflect.DeepEqual(errs, tt.errs) {
+				t.Fatalf("ParseGantt() errors = %q, want %q", errs, tt.errs)
+			}
+			if tt.errs == nil && !reflect.DeepEqual(chart, tt.want) {
+				t.Errorf("ParseGantt() = %+v, want %+v", chart, tt.want)
+			}
+		})
+	}
+}
+
+func TestGanttPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Gantt block",
+			input:    "Plan:\n\n```gantt\n- name: Design <draft>\n  start: 2026-03-02\n  duration: 2d\n```\n\nEnd",
+			contains: []string{"Plan:\n\n<div class=\"gantt-chart\" data-gantt=\"", "<tr><td>Design &lt;draft&gt;</td><td>2026-03-02</td><td>2026-03-03</td><td></td></tr>", "</table></div>\n\nEnd"},
+			excludes: []string{"```"},
+		},
+		{
+			name:     "Timeline block with tildes",
+			input:    "~~~timeline\n- name: Release\n  start: 2026-05-01\n~~~",
+			contains: []string{"<tr><td>Release</td><td>2026-05-01</td><td>2026-05-01</td><td></td></tr>"},
+		},
+		{
+			name:     "Errors are shown",
+			input:    "```gantt\n- name: A\n```",
+			contains: []string{`<div class="gantt-error"><strong>Gantt:</strong> &#34;A&#34; needs a start date or a task it depends on</div>`},
+		},
+		{
+			name:     "Mermaid gantt charts are left alone",
+			input:    "```mermaid\ngantt\n    title A\n```",
+			contains: []string{"```mermaid\ngantt\n    title A\n```"},
+			excludes: []string{"gantt-chart"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := GanttPreprocessor(tt.input, "")
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("GanttPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("GanttPreprocessor() = %q, want it not to contain %q", result, unwanted)
+				}
+			}
+		})
+	}
+}
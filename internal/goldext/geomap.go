@@ -0,0 +1,153 @@
+package goldext
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MapBlock is what js/geo-map.js draws for a map block: GeoJSON written in
+// the block or attached to the page, and markers written as coordinates
+type MapBlock struct {
+	GeoJSON []json.RawMessage `json:"geojson,omitempty"`
+	Sources []string          `json:"sources,omitempty"` // Addresses of attached GeoJSON files
+	Markers []MapMarker       `json:"markers,omitempty"`
+	Center  []float64         `json:"center,omitempty"` // Latitude and longitude, the bounds of the content otherwise
+	Zoom    int               `json:"zoom,omitempty"`
+	Height  int               `json:"height,omitempty"` // In pixels
+}
+
+// MapMarker is a marker written as "latitude, longitude label"
+type MapMarker struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Label string  `json:"label,omitempty"`
+}
+
+// mapCoordinatesRegex matches "latitude, longitude" and what follows
+var mapCoordinatesRegex = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)\s*(.*)$`)
+
+// MapPreprocessor turns ```map and ```geojson blocks into the maps drawn by
+// js/geo-map.js. A geojson block holds a GeoJSON object; a map block holds one
+// too, or lines of options, attached files and markers:
+//
+//	file: substations.geojson
+//	zoom: 12
+//	52.5200, 13.4050 Berlin office
+func MapPreprocessor(markdown string, docPath string) string {
+	if !strings.Contains(markdown, "map") && !strings.Contains(markdown, "geojson") {
+		return markdown
+	}
+	return replaceFencedBlocks(markdown, []string{"map", "geojson"}, func(kind string, content []string) string {
+		return renderMap(kind, content, docPath)
+	})
+}
+
+// renderMap returns the element of a map or geojson block, or the errors
+// found in it
+func renderMap(kind string, content []string, docPath string) string {
+	block, errs := ParseMapBlock(kind, strings.Join(content, "\n"), docPath)
+	if len(errs) > 0 {
+		return `<div class="geo-map-error"><strong>Map:</strong> ` + html.EscapeString(strings.Join(errs, "; ")) + `</div>`
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return `<div class="geo-map-error"><strong>Map:</strong> ` + html.EscapeString(err.Error()) + `</div>`
+	}
+
+	// The settings are encoded so the preprocessors that run later, like
+	// emoji and highlighting, leave them alone. Without scripts, and until
+	// the map is drawn, the markers and files are listed.
+	var fallback strings.Builder
+	for _, marker := range block.Markers {
+		label := marker.Label
+		if label == "" {
+			label = strconv.FormatFloat(marker.Lat, 'f', -1, 64) + ", " + strconv.FormatFloat(marker.Lon, 'f', -1, 64)
+		}
+		fallback.WriteString(`<li>` + html.EscapeString(label) + `</li>`)
+	}
+	for _, source := range block.Sources {
+		fallback.WriteString(`<li><a href="` + html.EscapeString(source) + `">` + html.EscapeString(source[strings.LastIndex(source, "/")+1:]) + `</a></li>`)
+	}
+	list := ""
+	if fallback.Len() > 0 {
+		list = `<ul class="geo-map-fallback">` + fallback.String() + `</ul>`
+	}
+	return `<div class="geo-map" data-map="` + base64.RawURLEncoding.EncodeToString(data) + `">` + list + `</div>`
+}
+
+// ParseMapBlock reads the content of a map or geojson block, returning the
+// problems found in it
+func ParseMapBlock(kind, content, docPath string) (MapBlock, []string) {
+	var block MapBlock
+	var errs []string
+
+	trimmed := strings.TrimSpace(content)
+	if kind == "geojson" || strings.HasPrefix(trimmed, "{") {
+		if !json.Valid([]byte(trimmed)) {
+			return block, []string{"invalid GeoJSON"}
+		}
+		block.GeoJSON = append(block.GeoJSON, json.RawMessage(trimmed))
+		return block, nil
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if match := mapCoordinatesRegex.FindStringSubmatch(line); match != nil {
+			lat, _ := strconv.ParseFloat(match[1], 64)
+			lon, _ := strconv.ParseFloat(match[2], 64)
+			if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+				errs = append(errs, fmt.Sprintf("line %d: coordinates out of range", i+1))
+				continue
+			}
+			block.Markers = append(block.Markers, MapMarker{Lat: lat, Lon: lon, Label: strings.TrimSpace(match[3])})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "file":
+			if value == "" {
+				ok = false
+				break
+			}
+			block.Sources = append(block.Sources, FileURL(value, docPath))
+		case "center":
+			match := mapCoordinatesRegex.FindStringSubmatch(value)
+			if match == nil || strings.TrimSpace(match[3]) != "" {
+				ok = false
+				break
+			}
+			lat, _ := strconv.ParseFloat(match[1], 64)
+			lon, _ := strconv.ParseFloat(match[2], 64)
+			block.Center = []float64{lat, lon}
+		case "zoom":
+			zoom, err := strconv.Atoi(value)
+			ok = err == nil && zoom >= 1 && zoom <= 22
+			block.Zoom = zoom
+		case "height":
+			height, err := strconv.Atoi(strings.TrimSuffix(value, "px"))
+			ok = err == nil && height >= 100 && height <= 2000
+			block.Height = height
+		default:
+			ok = false
+		}
+		if !ok {
+			errs = append(errs, fmt.Sprintf("line %d: %q is not a marker or option", i+1, line))
+		}
+	}
+
+	if len(errs) == 0 && len(block.Markers) == 0 && len(block.Sources) == 0 {
+		errs = append(errs, "no markers or files")
+	}
+	return block, errs
+}
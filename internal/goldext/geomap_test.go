@@ -0,0 +1,135 @@
+package goldext
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMapBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		content string
+		docPath string
+		want    MapBlock
+		errs    int
+	}{
+		{
+			name:    "Markers and options",
+			kind:    "map",
+			content: "# Sites\nzoom: 11\nheight: 300px\ncenter: 52.5, 13.4\n52.5200, 13.4050 Berlin office\n-33.86,151.21",
+			want: MapBlock{
+				Markers: []MapMarker{{Lat: 52.52, Lon: 13.405, Label: "Berlin office"}, {Lat: -33.86, Lon: 151.21}},
+				Center:  []float64{52.5, 13.4},
+				Zoom:    11,
+				Height:  300,
+			},
+		},
+		{
+			name:    "Attached file",
+			kind:    "map",
+			content: "file: substations.geojson",
+			docPath: "grid/north",
+			want:    MapBlock{Sources: []string{"/api/files/grid/north/substations.geojson"}},
+		},
+		{
+			name:    "GeoJSON in a map block",
+			kind:    "map",
+			content: ` {"type": "Point", "coordinates": [13.4, 52.5]}`,
+			want:    MapBlock{GeoJSON: []json.RawMessage{json.RawMessage(`{"type": "Point", "coordinates": [13.4, 52.5]}`)}},
+		},
+		{
+			name:    "Invalid GeoJSON",
+			kind:    "geojson",
+			content: `{"type": "Point",`,
+			errs:    1,
+		},
+		{
+			name:    "Unknown lines and coordinates out of range",
+			kind:    "map",
+			content: "91, 10 North of the pole\nstyle: dark\n10, 10",
+			want:    MapBlock{Markers: []MapMarker{{Lat: 10, Lon: 10}}},
+			errs:    2,
+		},
+		{
+			name: "Empty block",
+			kind: "map",
+			errs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, errs := ParseMapBlock(tt.kind, tt.content, tt.docPath)
+			if len(errs) != tt.errs {
+				t.Fatalf("ParseMapBlock() errors = %v, want %d", errs, tt.errs)
+			}
+			if tt.errs == 0 && !reflect.DeepEqual(block, tt.want) {
+				t.Errorf("ParseMapBlock() = %+v, want %+v", block, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Map block",
+			input:    "Sites:\n\n```map\n52.52, 13.405 Berlin :office: ==HQ==\n```\n\nEnd",
+			contains: []string{"Sites:\n\n<div class=\"geo-map\" data-map=\"", `<ul class="geo-map-fallback"><li>Berlin :office: ==HQ==</li></ul></div>`, "\n\nEnd"},
+			excludes: []string{"```"},
+		},
+		{
+			name:     "GeoJSON block with tildes",
+			input:    "~~~geojson\n{\"type\": \"Point\", \"coordinates\": [1, 2]}\n~~~",
+			contains: []string{`<div class="geo-map" data-map="`},
+		},
+		{
+			name:     "Errors are shown",
+			input:    "```map\nnowhere\n```",
+			contains: []string{`<div class="geo-map-error"><strong>Map:</strong> line 1: &#34;nowhere&#34; is not a marker or option</div>`},
+		},
+		{
+			name:     "Map blocks inside other code blocks are left alone",
+			input:    "````markdown\n```map\n1, 2\n```\n````",
+			contains: []string{"````markdown\n```map\n1, 2\n```\n````"},
+			excludes: []string{"geo-map"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MapPreprocessor(tt.input, "")
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("MapPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("MapPreprocessor() = %q, want it not to contain %q", result, unwanted)
+				}
+			}
+		})
+	}
+
+	// The settings survive as JSON
+	result := MapPreprocessor("```map\n52.52, 13.405 Berlin\n```", "")
+	encoded := strings.TrimSuffix(strings.SplitN(result, `data-map="`, 2)[1], `"><ul class="geo-map-fallback"><li>Berlin</li></ul></div>`)
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block MapBlock
+	if err := json.Unmarshal(data, &block); err != nil || len(block.Markers) != 1 || block.Markers[0].Label != "Berlin" {
+		t.Errorf("MapPreprocessor() settings = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,288 @@
+package goldext
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+)
+
+// glossaryCache holds the parsed glossary, reloaded when the glossary document changes
+var glossaryCache struct {
+	sync.Mutex
+	path          string
+	modTime       time.Time
+	caseSensitive bool
+	definitions   map[string]string // normalized term -> definition
+	pattern       *regexp.Regexp
+}
+
+// Tags whose content must never receive glossary markup
+var glossarySkipTags = map[string]bool{
+	"a": true, "abbr": true, "code": true, "pre": true, "script": true, "style": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"textarea": true, "button": true, "select": true, "svg": true, "math": true,
+}
+
+var (
+	glossaryLinkRegex   = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	glossaryFormatRegex = regexp.MustCompile("[*_`]+")
+)
+
+// ParseGlossary extracts terms and definitions from a markdown definition list:
+//
+//	Term
+//	: Definition
+//
+// Several terms may share one definition by placing them on consecutive lines.
+func ParseGlossary(markdown string) map[string]string {
+	_, content, _ := frontmatter.Parse(markdown)
+	definitions := make(map[string]string)
+
+	var terms []string
+	inCodeBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			terms = nil
+			continue
+		}
+		if inCodeBlock {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, ": "):
+			definition := plainGlossaryText(strings.TrimSpace(trimmed[2:]))
+			for _, term := range terms {
+				if _, exists := definitions[term]; !exists && definition != "" {
+					definitions[term] = definition
+				}
+			}
+			terms = nil
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "<"):
+			terms = nil
+		default:
+			if term := plainGlossaryText(trimmed); term != "" {
+				terms = append(terms, term)
+			}
+		}
+	}
+
+	return definitions
+}
+
+// plainGlossaryText strips inline markdown so text can be used in attributes and matching
+func plainGlossaryText(text string) string {
+	text = glossaryLinkRegex.ReplaceAllString(text, "$1")
+	text = glossaryFormatRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// loadGlossary returns the current glossary definitions and the combined term pattern
+func loadGlossary() (map[string]string, *regexp.Regexp, bool) {
+	glossaryPage := strings.Trim(config.Cfg.Extensions.Glossary.Page, "/")
+	if glossaryPage == "" {
+		return nil, nil, false
+	}
+
+	path := filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir, filepath.FromSlash(glossaryPage), "document.md")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	caseSensitive := config.Cfg.Extensions.Glossary.CaseSensitive
+
+	glossaryCache.Lock()
+	defer glossaryCache.Unlock()
+
+	if glossaryCache.path == path && glossaryCache.modTime.Equal(info.ModTime()) && glossaryCache.caseSensitive == caseSensitive {
+		return glossaryCache.definitions, glossaryCache.pattern, caseSensitive
+	}
+
+	data, err := encryption.ReadFile(path)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	definitions := make(map[string]string)
+	var terms []string
+	for term, definition := range ParseGlossary(string(data)) {
+		key := html.EscapeString(term)
+		if !caseSensitive {
+			key = strings.ToLower(key)
+		}
+		if _, exists := definitions[key]; exists {
+			continue
+		}
+		definitions[key] = definition
+		terms = append(terms, html.EscapeString(term))
+	}
+
+	var pattern *regexp.Regexp
+	if len(terms) > 0 {
+		// Longest terms first so "API Gateway" wins over "API"
+		sort.Slice(terms, func(i, j int) bool {
+			if len(terms[i]) != len(terms[j]) {
+				return len(terms[i]) > len(terms[j])
+			}
+			return terms[i] < terms[j]
+		})
+
+		alternatives := make([]string, len(terms))
+		for i, term := range terms {
+			alternatives[i] = glossaryTermPattern(term)
+		}
+		expr := "(?:" + strings.Join(alternatives, "|") + ")"
+		if !caseSensitive {
+			expr = "(?i)" + expr
+		}
+		pattern = regexp.MustCompile(expr)
+	}
+
+	glossaryCache.path = path
+	glossaryCache.modTime = info.ModTime()
+	glossaryCache.caseSensitive = caseSensitive
+	glossaryCache.definitions = definitions
+	glossaryCache.pattern = pattern
+
+	return definitions, pattern, caseSensitive
+}
+
+// glossaryTermPattern builds a regular expression matching a whole term
+func glossaryTermPattern(term string) string {
+	isWordChar := func(b byte) bool {
+		return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+	}
+
+	expr := regexp.QuoteMeta(term)
+	if isWordChar(term[0]) {
+		expr = `\b` + expr
+	}
+	if isWordChar(term[len(term)-1]) {
+		expr += `\b`
+	}
+	return expr
+}
+
+// ApplyGlossary wraps the first occurrence of each glossary term in the rendered HTML
+// with an <abbr> element carrying the definition. It works on the final HTML so the
+// definitions are not touched by the markdown preprocessors.
+func ApplyGlossary(htmlContent string, docPath string) string {
+	if !DirectorySettings(docPath).Enabled("glossary", config.Cfg.Extensions.Glossary.Enable) {
+		return htmlContent
+	}
+
+	// Never highlight terms on the glossary itself
+	if strings.Trim(docPath, "/") == strings.Trim(config.Cfg.Extensions.Glossary.Page, "/") {
+		return htmlContent
+	}
+
+	definitions, pattern, caseSensitive := loadGlossary()
+	if pattern == nil {
+		return htmlContent
+	}
+
+	used := make(map[string]bool)
+	return walkTextNodes(htmlContent, glossarySkipTags, func(text string) string {
+		if len(used) < len(definitions) {
+			text = highlightGlossaryTerms(text, pattern, definitions, used, caseSensitive)
+		}
+		return text
+	})
+}
+
+// walkTextNodes rewrites the text nodes of HTML with fn, leaving tags, comments
+// and the content of skipTags elements as they are
+func walkTextNodes(htmlContent string, skipTags map[string]bool, fn func(string) string) string {
+	var sb strings.Builder
+	sb.Grow(len(htmlContent))
+
+	skipDepth := 0
+	pos := 0
+	for pos < len(htmlContent) {
+		tagStart := strings.IndexByte(htmlContent[pos:], '<')
+		if tagStart == -1 {
+			tagStart = len(htmlContent) - pos
+		}
+
+		// Text node
+		if tagStart > 0 {
+			text := htmlContent[pos : pos+tagStart]
+			if skipDepth == 0 {
+				text = fn(text)
+			}
+			sb.WriteString(text)
+			pos += tagStart
+			continue
+		}
+
+		// Tag or comment
+		tagEnd := strings.IndexByte(htmlContent[pos:], '>')
+		if strings.HasPrefix(htmlContent[pos:], "<!--") {
+			if end := strings.Index(htmlContent[pos:], "-->"); end != -1 {
+				tagEnd = end + 2
+			}
+		}
+		if tagEnd == -1 {
+			sb.WriteString(htmlContent[pos:])
+			break
+		}
+
+		tag := htmlContent[pos : pos+tagEnd+1]
+		sb.WriteString(tag)
+		pos += tagEnd + 1
+
+		name, closing := glossaryTagName(tag)
+		if skipTags[name] && !strings.HasSuffix(tag, "/>") {
+			if closing {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			} else {
+				skipDepth++
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// glossaryTagName returns the lowercase name of an HTML tag and whether it is a closing tag
+func glossaryTagName(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "<")
+	closing := strings.HasPrefix(tag, "/")
+	tag = strings.TrimPrefix(tag, "/")
+
+	end := strings.IndexAny(tag, " \t\n/>")
+	if end == -1 {
+		end = len(tag)
+	}
+	return strings.ToLower(tag[:end]), closing
+}
+
+// highlightGlossaryTerms wraps unused terms within a single text node
+func highlightGlossaryTerms(text string, pattern *regexp.Regexp, definitions map[string]string, used map[string]bool, caseSensitive bool) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		key := match
+		if !caseSensitive {
+			key = strings.ToLower(key)
+		}
+		definition, ok := definitions[key]
+		if !ok || used[key] {
+			return match
+		}
+		used[key] = true
+		return `<abbr class="glossary-term" title="` + html.EscapeString(definition) + `">` + match + `</abbr>`
+	})
+}
@@ -0,0 +1,72 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestApplyGlossary(t *testing.T) {
+	root := t.TempDir()
+	glossaryDir := filepath.Join(root, "documents", "glossary")
+	if err := os.MkdirAll(glossaryDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	glossary := "# Glossary\n\nAPI\n: Application Programming Interface\n\nAPI Gateway\n: Entry point for **all** requests\n"
+	if err := os.WriteFile(filepath.Join(glossaryDir, "document.md"), []byte(glossary), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	config.Cfg.Extensions.Glossary.Enable = true
+	config.Cfg.Extensions.Glossary.Page = "glossary"
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		expected string
+	}{
+		{
+			name:     "First occurrence only",
+			input:    "<p>The api and the API.</p>",
+			expected: `<p>The <abbr class="glossary-term" title="Application Programming Interface">api</abbr> and the API.</p>`,
+		},
+		{
+			name:     "Longest term wins",
+			input:    "<p>Call the API Gateway.</p>",
+			expected: `<p>Call the <abbr class="glossary-term" title="Entry point for all requests">API Gateway</abbr>.</p>`,
+		},
+		{
+			name:     "Skip code and links",
+			input:    `<p><code>API</code> <a href="/api">API</a></p>`,
+			expected: `<p><code>API</code> <a href="/api">API</a></p>`,
+		},
+		{
+			name:     "Partial words are not matched",
+			input:    "<p>Rapid APIs</p>",
+			expected: "<p>Rapid APIs</p>",
+		},
+		{
+			name:     "Glossary page itself",
+			input:    "<p>API</p>",
+			docPath:  "glossary",
+			expected: "<p>API</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ApplyGlossary(tt.input, tt.docPath)
+			if result != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -3,8 +3,13 @@ package goldext
 import (
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
 )
 
 // Preprocessor defines a function that transforms markdown before rendering
@@ -13,15 +18,32 @@ type Preprocessor func(markdown string, docPath string) string
 // RegisteredPreprocessors holds all registered preprocessors
 var RegisteredPreprocessors []Preprocessor
 
+// preprocessorExtensions holds the extension each registered preprocessor
+// belongs to, "" for those that always run
+var preprocessorExtensions []string
+
 // RegisterPreprocessor adds a preprocessor to the list
 func RegisterPreprocessor(pp Preprocessor) {
 	RegisteredPreprocessors = append(RegisteredPreprocessors, pp)
+	preprocessorExtensions = append(preprocessorExtensions, "")
+}
+
+// RegisterExtension adds the preprocessor of an extension to the list, which
+// directories can turn off in their _settings.yaml
+func RegisterExtension(name string, pp Preprocessor) {
+	RegisterPreprocessor(pp)
+	preprocessorExtensions[len(preprocessorExtensions)-1] = name
 }
 
-// ProcessMarkdown applies all registered preprocessors to the markdown
+// ProcessMarkdown applies all registered preprocessors to the markdown, but
+// those of the extensions the directory of the document turned off
 func ProcessMarkdown(markdown string, docPath string) string {
+	settings := DirectorySettings(docPath)
 	result := markdown
-	for _, preprocessor := range RegisteredPreprocessors {
+	for i, preprocessor := range RegisteredPreprocessors {
+		if i < len(preprocessorExtensions) && !settings.Enabled(preprocessorExtensions[i], true) {
+			continue
+		}
 		result = preprocessor(result, docPath)
 	}
 	return result
@@ -189,6 +211,32 @@ func isLocalPath(path string) bool {
 	return true
 }
 
+// FileURL returns the URL of a link or image destination written in the page at
+// docPath, resolving the names of its attachments
+func FileURL(path, docPath string) string {
+	if !isLocalPath(path) {
+		return path
+	}
+	return resolveLocalPath(path, docPath)
+}
+
+// readAttachment returns the content of a file attached to the page at
+// docPath, named as the page writes it
+func readAttachment(name, docPath string) ([]byte, error) {
+	if config.Cfg == nil {
+		return nil, os.ErrNotExist
+	}
+	name, err := url.PathUnescape(name)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir, filepath.FromSlash(strings.Trim(docPath, "/")))
+	if strings.Trim(docPath, "/") == "" {
+		dir = filepath.Join(config.Cfg.Wiki.RootDir, "pages", "home")
+	}
+	return encryption.ReadFile(filepath.Join(dir, filepath.Clean("/"+name)))
+}
+
 // resolveLocalPath resolves a local path relative to the document path
 func resolveLocalPath(path, docPath string) string {
 	// Remove any leading slashes from docPath
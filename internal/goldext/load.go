@@ -6,6 +6,9 @@ package goldext
 // These variables ensure the preprocessors are available for registration
 // We don't actually use them directly, but they're needed for the compiler to include the preprocessors
 var (
+	_ = VariablesPreprocessor
+	_ = SectionRefPreprocessor
+	_ = WikiLinkPreprocessor
 	_ = LinkPreprocessor
 	_ = MermaidPreprocessor
 	_ = PlantUMLPreprocessor
@@ -14,52 +17,78 @@ var (
 	_ = YouTubePreprocessor
 	_ = VimeoPreprocessor
 	_ = StatsPreprocessor
+	_ = ReviewReportPreprocessor
+	_ = FormPreprocessor
+	_ = CitationPreprocessor
 	_ = HighlightPreprocessor
 	_ = TypographyPreprocessor
 	_ = EmojiPreprocessor
 	_ = DetailsPreprocessor
+	_ = CalloutPreprocessor
 	// _ = TaskListPreprocessor
 	_ = TocPreprocessor
+	_ = CrossRefPreprocessor
 	_ = HeadingAnchorPreprocessor
 	_ = SuperscriptPreprocessor
 	_ = SubscriptPreprocessor
 	_ = ScriptSanitizePreprocessor
 	_ = FrontmatterPreprocessor
+	_ = AudiencePreprocessor
+	_ = NotebookPreprocessor
+	_ = OpenAPIPreprocessor
 )
 
 func init() {
 	// Clear any previously registered preprocessors to ensure consistent ordering
 	RegisteredPreprocessors = nil
+	preprocessorExtensions = nil
 
 	// Step 0: Process frontmatter FIRST, before any other processors
 	RegisterPreprocessor(FrontmatterPreprocessor) // Process frontmatter
+	RegisterPreprocessor(AudiencePreprocessor)    // Drop conditional content not evaluated for a viewer
 
 	// Step 1: Process Mermaid/PlantUML FIRST, before any other processors can touch the content
-	RegisterPreprocessor(MermaidPreprocessor)  // Process mermaid diagrams first
-	RegisterPreprocessor(PlantUMLPreprocessor) // Process PlantUML diagrams first
+	RegisterExtension("mermaid", MermaidPreprocessor)  // Process mermaid diagrams first
+	RegisterExtension("plantuml", PlantUMLPreprocessor) // Process PlantUML diagrams first
 
 	// Step 2: Security-related preprocessing (add this early to sanitize content before other processors)
 	RegisterPreprocessor(ScriptSanitizePreprocessor) // Sanitize script tags
 
 	// Step 3: Register preprocessors that handle code blocks
+	RegisterExtension("variables", VariablesPreprocessor) // Substitute {{variables}} before anything else interprets them
+	RegisterPreprocessor(SectionRefPreprocessor) // Link [[/page#heading]] cross-references to the heading they name
+	RegisterPreprocessor(WikiLinkPreprocessor)  // Turn Obsidian [[wikilinks]] into links before they are resolved
+	RegisterPreprocessor(DiagramPreprocessor)   // Show draw.io and Excalidraw attachments from their SVG previews
+	RegisterPreprocessor(MediaPreprocessor)     // Show audio and video attachments in the players of the browser
+	RegisterPreprocessor(CastPreprocessor)      // Show asciinema terminal recordings in the built-in player
+	RegisterPreprocessor(NotebookPreprocessor)  // Show Jupyter notebook attachments with their code and outputs
+	RegisterExtension("maps", MapPreprocessor)       // Draw map and GeoJSON blocks as interactive maps
+	RegisterExtension("gantt", GanttPreprocessor)     // Draw gantt and timeline blocks of YAML tasks
+	RegisterExtension("openapi", OpenAPIPreprocessor) // Draw openapi blocks as interactive API documentation
+	RegisterPreprocessor(PagesQueryPreprocessor) // List the pages matching pages blocks
 	RegisterPreprocessor(LinkPreprocessor)      // Process links and images
 	RegisterPreprocessor(DirectionPreprocessor) // Process RTL/LTR blocks
 	RegisterPreprocessor(MP4Preprocessor)       // Process MP4 video blocks
 	RegisterPreprocessor(YouTubePreprocessor)   // Process YouTube video blocks
 	RegisterPreprocessor(VimeoPreprocessor)     // Process Vimeo video blocks
 	RegisterPreprocessor(StatsPreprocessor)     // Process stats shortcodes
-	RegisterPreprocessor(DetailsPreprocessor)   // Process details blocks
+	RegisterPreprocessor(ReviewReportPreprocessor) // Process the needs-review report shortcode
+	RegisterPreprocessor(FormPreprocessor)      // Process structured form shortcodes
+	RegisterExtension("citations", CitationPreprocessor)  // Process [@key] citations and the references list
+	RegisterExtension("details", DetailsPreprocessor)   // Process details blocks
+	RegisterExtension("callouts", CalloutPreprocessor)   // Process > [!note] callouts
 	// RegisterPreprocessor(TaskListPreprocessor)  // Process task lists before rendering
 	RegisterPreprocessor(TocPreprocessor)       // Process table of contents markers
-	RegisterPreprocessor(HeadingAnchorPreprocessor) // Add ¶ anchors to headings
+	RegisterExtension("crossrefs", CrossRefPreprocessor)  // Number figures and tables, resolve @fig: cross-references
+	RegisterExtension("heading_anchors", HeadingAnchorPreprocessor) // Add ¶ anchors to headings
 
 	// Step 4: Register text formatting preprocessors
-	RegisterPreprocessor(HighlightPreprocessor)  // Process highlighting
-	RegisterPreprocessor(TypographyPreprocessor) // Process typography replacements
-	RegisterPreprocessor(EmojiPreprocessor)      // Process emoji shortcodes
+	RegisterExtension("highlight", HighlightPreprocessor)  // Process highlighting
+	RegisterExtension("typography", TypographyPreprocessor) // Process typography replacements
+	RegisterExtension("emoji", EmojiPreprocessor)      // Process emoji shortcodes
 
 	// Step 5: Register these last to avoid interference with other syntax
 	// These preprocessors will skip content inside MathJax blocks ($ and $$)
-	RegisterPreprocessor(SuperscriptPreprocessor) // Process superscript (avoids MathJax content)
-	RegisterPreprocessor(SubscriptPreprocessor)   // Process subscript (avoids MathJax content)
+	RegisterExtension("superscript", SuperscriptPreprocessor) // Process superscript (avoids MathJax content)
+	RegisterExtension("subscript", SubscriptPreprocessor)   // Process subscript (avoids MathJax content)
 }
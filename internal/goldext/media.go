@@ -0,0 +1,89 @@
+package goldext
+
+import (
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/config"
+)
+
+// VideoPosterSuffix is appended to the name of a video attachment for the
+// poster image generated from one of its first frames
+const VideoPosterSuffix = ".poster.jpg"
+
+// mediaImageRegex matches images of audio and video attachments:
+// ![Demo](demo.mp4) or ![Call](call.mp3)
+var mediaImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+\.((?i:mp4|webm|mp3|m4a|ogg|wav)))\)`)
+
+// MediaKind returns the player of a media attachment, "video" or "audio", or
+// "" for other files
+func MediaKind(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp4", ".webm":
+		return "video"
+	case ".mp3", ".m4a", ".ogg", ".wav":
+		return "audio"
+	}
+	return ""
+}
+
+// MediaPreprocessor shows images of audio and video attachments as the
+// players of the browser. They only load the start of the file up front and
+// fetch the parts played with range requests, so long recordings can be
+// scrubbed without downloading them whole.
+func MediaPreprocessor(markdown string, docPath string) string {
+	sections := splitCodeSections(markdown)
+	for i := range sections {
+		if sections[i].isCode {
+			continue
+		}
+		sections[i].content = mediaImageRegex.ReplaceAllStringFunc(sections[i].content, func(match string) string {
+			parts := mediaImageRegex.FindStringSubmatch(match)
+			path := parts[2]
+			if strings.Contains(path, "://") {
+				return match
+			}
+			source := FileURL(path, docPath)
+			title := html.EscapeString(parts[1])
+			kind := MediaKind(path)
+
+			attrs := ` class="media-player media-` + kind + `" controls preload="metadata"`
+			if title != "" {
+				attrs += ` title="` + title + `"`
+			}
+			if kind == "video" && posterExists(path, docPath) {
+				attrs += ` poster="` + html.EscapeString(source+VideoPosterSuffix) + `"`
+			}
+			fallback := title
+			if fallback == "" {
+				fallback = html.EscapeString(filepath.Base(path))
+			}
+			return `<` + kind + attrs + `><source src="` + html.EscapeString(source) + `" type="` +
+				config.GetMimeTypeForExtension(strings.ToLower(filepath.Ext(path))) + `"><a href="` +
+				html.EscapeString(source) + `">` + fallback + `</a></` + kind + `>`
+		})
+	}
+	return joinSections(sections)
+}
+
+// posterExists reports whether the video at path, relative to the page at
+// docPath, has a poster image
+func posterExists(path, docPath string) bool {
+	if config.Cfg == nil || strings.HasPrefix(path, "/") {
+		return false
+	}
+	name, err := url.PathUnescape(path)
+	if err != nil {
+		return false
+	}
+	dir := filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir, filepath.FromSlash(strings.Trim(docPath, "/")))
+	if strings.Trim(docPath, "/") == "" {
+		dir = filepath.Join(config.Cfg.Wiki.RootDir, "pages", "home")
+	}
+	_, err = os.Stat(filepath.Join(dir, filepath.Clean("/"+name)+VideoPosterSuffix))
+	return err == nil
+}
@@ -0,0 +1,79 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestMediaPreprocessor(t *testing.T) {
+	root := t.TempDir()
+	pageDir := filepath.Join(root, "documents", "runbooks", "deploy")
+	if err := os.MkdirAll(pageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "rollout.mp4"+VideoPosterSuffix), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:    "Video with a poster",
+			input:   "![Rollout](rollout.mp4)",
+			docPath: "runbooks/deploy",
+			contains: []string{
+				`<video class="media-player media-video" controls preload="metadata" title="Rollout" poster="/api/files/runbooks/deploy/rollout.mp4.poster.jpg">`,
+				`<source src="/api/files/runbooks/deploy/rollout.mp4" type="video/mp4"><a href="/api/files/runbooks/deploy/rollout.mp4">Rollout</a></video>`,
+			},
+		},
+		{
+			name:     "Video without a poster",
+			input:    "![](demo.WEBM)",
+			docPath:  "runbooks/deploy",
+			contains: []string{`<video class="media-player media-video" controls preload="metadata"><source src="/api/files/runbooks/deploy/demo.WEBM" type="video/webm">`, `>demo.WEBM</a>`},
+			excludes: []string{"poster="},
+		},
+		{
+			name:     "Audio of the homepage",
+			input:    "Listen: ![Standup](standup.mp3)",
+			contains: []string{`Listen: <audio class="media-player media-audio" controls preload="metadata" title="Standup"><source src="/api/files/pages/home/standup.mp3" type="audio/mpeg">`},
+		},
+		{
+			name:     "Remote media, other images and code are left alone",
+			input:    "![a](https://example.com/a.mp4) ![b](b.png)\n```\n![c](c.mp3)\n```",
+			docPath:  "docs",
+			contains: []string{"![a](https://example.com/a.mp4) ![b](b.png)\n```\n![c](c.mp3)\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MediaPreprocessor(tt.input, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("MediaPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("MediaPreprocessor() = %q, want it not to contain %q", result, unwanted)
+				}
+			}
+		})
+	}
+}
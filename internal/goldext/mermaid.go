@@ -2,6 +2,7 @@ package goldext
 
 import (
 	"fmt"
+	"html"
 	"strings"
 	"sync"
 )
@@ -46,7 +47,7 @@ func MermaidPreprocessor(markdown string, _ string) string {
 			blockID := fmt.Sprintf("MERMAID_BLOCK_%d", mermaidBlockCount)
 			mermaidBlockCount++
 			// Store the actual mermaid div
-			mermaidDiv := "<div class=\"mermaid\">" + strings.Join(mermaidContent, "\n") + "</div>"
+			mermaidDiv := mermaidElement(mermaidContent)
 			mermaidBlocks[blockID] = mermaidDiv
 			// Add placeholder to output - this will pass through Goldmark untouched
 			result = append(result, "<!-- "+blockID+" -->")
@@ -61,7 +62,7 @@ func MermaidPreprocessor(markdown string, _ string) string {
 			blockID := fmt.Sprintf("MERMAID_BLOCK_%d", mermaidBlockCount)
 			mermaidBlockCount++
 			// Store the actual mermaid div
-			mermaidDiv := "<div class=\"mermaid\">" + strings.Join(mermaidContent, "\n") + "</div>"
+			mermaidDiv := mermaidElement(mermaidContent)
 			mermaidBlocks[blockID] = mermaidDiv
 			// Add placeholder to output - this will pass through Goldmark untouched
 			result = append(result, "<!-- "+blockID+" -->")
@@ -80,7 +81,7 @@ func MermaidPreprocessor(markdown string, _ string) string {
 	if inMermaidBacktick || inMermaidTilde {
 		blockID := fmt.Sprintf("MERMAID_BLOCK_%d", mermaidBlockCount)
 		mermaidBlockCount++
-		mermaidDiv := "<div class=\"mermaid\">" + strings.Join(mermaidContent, "\n") + "</div>"
+		mermaidDiv := mermaidElement(mermaidContent)
 		mermaidBlocks[blockID] = mermaidDiv
 		result = append(result, "<!-- "+blockID+" -->")
 	}
@@ -102,3 +103,9 @@ func RestoreMermaidBlocks(html string) string {
 
 	return result
 }
+
+// mermaidElement wraps diagram source in the element rendered by Mermaid. The source
+// is escaped so it stays text, Mermaid reads it back with textContent.
+func mermaidElement(lines []string) string {
+	return "<div class=\"mermaid\">" + html.EscapeString(strings.Join(lines, "\n")) + "</div>"
+}
@@ -87,7 +87,7 @@ func MP4Preprocessor(markdown string, docPath string) string {
 
 						// Create replacement HTML
 						replacement := fmt.Sprintf(`<div class="video-container">
-<video class="local-video-player" style="max-width: 100%%; height: auto;" controls>
+<video class="local-video-player" style="max-width: 100%%; height: auto;" controls preload="metadata">
 <source src="%s" type="video/mp4">
 Your browser does not support the video tag.
 </video>
@@ -143,7 +143,7 @@ Your browser does not support the video tag.
 
 						// Create replacement HTML
 						replacement := fmt.Sprintf(`<div class="video-container">
-<video class="local-video-player" style="max-width: 100%%; height: auto;" controls>
+<video class="local-video-player" style="max-width: 100%%; height: auto;" controls preload="metadata">
 <source src="%s" type="video/mp4">
 Your browser does not support the video tag.
 </video>
@@ -190,7 +190,7 @@ Your browser does not support the video tag.
 
 			// Create replacement HTML
 			replacement := fmt.Sprintf(`<div class="video-container">
-<video class="local-video-player" style="max-width: 100%%; height: auto;" controls>
+<video class="local-video-player" style="max-width: 100%%; height: auto;" controls preload="metadata">
 <source src="%s" type="video/mp4">
 Your browser does not support the video tag.
 </video>
@@ -0,0 +1,58 @@
+package goldext
+
+import (
+	"html"
+	"path"
+	"regexp"
+
+	"wiki-go/internal/notebook"
+)
+
+// notebookImageRegex matches images of Jupyter notebook attachments:
+// ![Analysis](analysis.ipynb)
+var notebookImageRegex = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+\.(?i:ipynb))\)`)
+
+// NotebookPreprocessor shows images of .ipynb attachments of the page as the
+// notebook: its markdown cells, highlighted code cells and saved outputs.
+// Notebooks elsewhere or that can't be read stay a link to the file.
+func NotebookPreprocessor(markdown string, docPath string) string {
+	sections := splitCodeSections(markdown)
+	for i := range sections {
+		if sections[i].isCode {
+			continue
+		}
+		sections[i].content = notebookImageRegex.ReplaceAllStringFunc(sections[i].content, func(match string) string {
+			parts := notebookImageRegex.FindStringSubmatch(match)
+			link := FileURL(parts[2], docPath)
+			title := parts[1]
+			if title == "" {
+				title = path.Base(parts[2])
+			}
+			fallback := `<a href="` + html.EscapeString(link) + `">` + html.EscapeString(title) + `</a>`
+			if !isLocalPath(parts[2]) {
+				return fallback
+			}
+
+			nb, err := loadNotebook(parts[2], docPath)
+			if err != nil {
+				return fallback
+			}
+			header := `<a href="` + html.EscapeString(link) + `" download>` + html.EscapeString(title) + `</a>`
+			if kernel := nb.Kernel(); kernel != "" {
+				header += ` <span class="notebook-kernel">` + html.EscapeString(kernel) + `</span>`
+			}
+			return "<div class=\"notebook\">\n<div class=\"notebook-header\">" + header + "</div>\n\n" +
+				nb.Markdown() + "\n</div>"
+		})
+	}
+	return joinSections(sections)
+}
+
+// loadNotebook reads a notebook attached to the page at docPath
+func loadNotebook(name, docPath string) (*notebook.Notebook, error) {
+	data, err := readAttachment(name, docPath)
+	if err != nil {
+		return nil, err
+	}
+	return notebook.Parse(data)
+}
@@ -0,0 +1,113 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+const testNotebook = `{
+ "nbformat": 4,
+ "nbformat_minor": 5,
+ "metadata": {
+  "kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"},
+  "language_info": {"name": "python"}
+ },
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Churn analysis\n", "\n", "Logo: ![logo](attachment:logo.png)"],
+   "attachments": {"logo.png": {"image/png": "iVBORw0KGgo=\n"}}},
+  {"cell_type": "code", "execution_count": 3, "metadata": {}, "source": "print('a < b')\nx = 1",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["a < b\n", "\n", "done\n"]},
+    {"output_type": "execute_result", "execution_count": 3, "metadata": {},
+     "data": {"text/html": ["<table>\n", "\n", "<tr><td>1</td></tr></table><script>alert(1)</script>"], "text/plain": ["x"]}},
+    {"output_type": "display_data", "metadata": {}, "data": {"image/png": "iVBORw0K\nGgo=", "text/plain": ["<Figure>"]}},
+    {"output_type": "error", "ename": "ValueError", "evalue": "bad", "traceback": ["\u001b[0;31mValueError\u001b[0m: bad"]}
+   ]},
+  {"cell_type": "code", "execution_count": null, "metadata": {}, "source": [], "outputs": []},
+  {"cell_type": "raw", "metadata": {}, "source": "raw text"}
+ ]
+}`
+
+func TestNotebookPreprocessor(t *testing.T) {
+	root := t.TempDir()
+	pageDir := filepath.Join(root, "documents", "data", "churn")
+	if err := os.MkdirAll(pageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "churn.ipynb"), []byte(testNotebook), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "broken.ipynb"), []byte(`{"nbformat": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:    "Markdown cells and the header",
+			input:   "![Churn](churn.ipynb)",
+			docPath: "data/churn",
+			contains: []string{
+				`<div class="notebook">` + "\n" + `<div class="notebook-header"><a href="/api/files/data/churn/churn.ipynb" download>Churn</a> <span class="notebook-kernel">Python 3</span></div>` + "\n\n# Churn analysis\n",
+				"Logo: ![logo](data:image/png;base64,iVBORw0KGgo=)",
+			},
+			excludes: []string{"raw text"},
+		},
+		{
+			name:    "Code cells and their outputs",
+			input:   "![Churn](churn.ipynb)",
+			docPath: "data/churn",
+			contains: []string{
+				"<div class=\"notebook-prompt\">In [3]:</div>\n\n```python\nprint('a < b')\nx = 1\n```",
+				`<pre class="notebook-output notebook-stream">a &lt; b` + "\n\ndone</pre>",
+				"<div class=\"notebook-output notebook-html\"><table>\n<tr><td>1</td></tr></table></div>",
+				`<img src="data:image/png;base64,iVBORw0KGgo=" alt="Output" loading="lazy">`,
+				`<pre class="notebook-output notebook-error">ValueError: bad</pre>`,
+			},
+			excludes: []string{"<script>", "In [ ]:", "&lt;Figure&gt;"},
+		},
+		{
+			name:     "Notebooks that can't be read are links",
+			input:    "![](broken.ipynb) ![Old](missing.ipynb)",
+			docPath:  "data/churn",
+			contains: []string{`<a href="/api/files/data/churn/broken.ipynb">broken.ipynb</a> <a href="/api/files/data/churn/missing.ipynb">Old</a>`},
+		},
+		{
+			name:     "Remote notebooks and code are left alone",
+			input:    "![r](https://example.com/r.ipynb)\n```\n![c](churn.ipynb)\n```",
+			docPath:  "data/churn",
+			contains: []string{`<a href="https://example.com/r.ipynb">r</a>` + "\n```\n![c](churn.ipynb)\n```"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NotebookPreprocessor(tt.input, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("NotebookPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("NotebookPreprocessor() = %q, want it not to contain %q", result, unwanted)
+				}
+			}
+		})
+	}
+}
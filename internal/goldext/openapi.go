@@ -0,0 +1,78 @@
+package goldext
+
+import (
+	"encoding/base64"
+	"html"
+	"strings"
+
+	"wiki-go/internal/openapi"
+)
+
+// OpenAPIPreprocessor turns ```openapi blocks into the API documentation drawn
+// by js/openapi-viewer.js. A block holds an OpenAPI 3 or Swagger 2 document in
+// YAML or JSON, or names an attachment of the page holding one:
+//
+//	file: orders-api.yaml
+func OpenAPIPreprocessor(markdown string, docPath string) string {
+	if !strings.Contains(markdown, "openapi") && !strings.Contains(markdown, "swagger") {
+		return markdown
+	}
+	return replaceFencedBlocks(markdown, []string{"openapi", "swagger"}, func(_ string, content []string) string {
+		return renderOpenAPI(content, docPath)
+	})
+}
+
+// renderOpenAPI returns the element of an openapi block, or the error found
+// in it
+func renderOpenAPI(content []string, docPath string) string {
+	source := strings.Join(content, "\n")
+	link := ""
+	if key, value, ok := strings.Cut(strings.TrimSpace(source), ":"); ok && strings.TrimSpace(key) == "file" && !strings.Contains(value, "\n") {
+		name := strings.TrimSpace(value)
+		if !isLocalPath(name) {
+			return openAPIError("only attachments of the page can be shown")
+		}
+		data, err := readAttachment(name, docPath)
+		if err != nil {
+			return openAPIError("can't read " + name)
+		}
+		source = string(data)
+		link = FileURL(name, docPath)
+	}
+
+	document, err := openapi.Parse([]byte(source))
+	if err != nil {
+		return openAPIError(err.Error())
+	}
+
+	// The document is encoded so the preprocessors that run later leave it
+	// alone. Without scripts, and until it is drawn, the operations are listed.
+	var fallback strings.Builder
+	fallback.WriteString(`<div class="openapi-fallback"><strong>` + html.EscapeString(orDefault(document.Title, "API")) + `</strong>`)
+	if document.Version != "" {
+		fallback.WriteString(` <span class="openapi-version">` + html.EscapeString(document.Version) + `</span>`)
+	}
+	if len(document.Operations) > 0 {
+		fallback.WriteString(`<ul>`)
+		for _, operation := range document.Operations {
+			fallback.WriteString(`<li><code>` + strings.ToUpper(operation.Method) + ` ` + html.EscapeString(operation.Path) + `</code>`)
+			if operation.Summary != "" {
+				fallback.WriteString(` ` + html.EscapeString(operation.Summary))
+			}
+			fallback.WriteString(`</li>`)
+		}
+		fallback.WriteString(`</ul>`)
+	}
+	fallback.WriteString(`</div>`)
+
+	attrs := ` data-spec="` + base64.RawURLEncoding.EncodeToString(document.JSON) + `"`
+	if link != "" {
+		attrs += ` data-source="` + html.EscapeString(link) + `"`
+	}
+	return `<div class="openapi"` + attrs + `>` + fallback.String() + `</div>`
+}
+
+// openAPIError returns the message shown in place of an openapi block
+func openAPIError(message string) string {
+	return `<div class="openapi-error"><strong>OpenAPI:</strong> ` + html.EscapeString(message) + `</div>`
+}
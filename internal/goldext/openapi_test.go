@@ -0,0 +1,142 @@
+package goldext
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+const testOpenAPI = `openapi: 3.0.3
+info:
+  title: Orders <API>
+  version: 1.2.0
+paths:
+  /orders/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema: {type: string}
+    get:
+      summary: Get an order
+      responses:
+        200:
+          description: The order
+    delete:
+      deprecated: true
+      responses:
+        204:
+          description: Deleted`
+
+func TestOpenAPIPreprocessor(t *testing.T) {
+	root := t.TempDir()
+	pageDir := filepath.Join(root, "documents", "services", "orders")
+	if err := os.MkdirAll(pageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pageDir, "orders.yaml"), []byte(testOpenAPI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	swagger := `{"swagger": "2.0", "info": {"title": "Legacy"}, "paths": {"/ping": {"post": {"summary": "Ping"}}}}`
+	if err := os.WriteFile(filepath.Join(pageDir, "legacy.json"), []byte(swagger), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		contains []string
+		excludes []string
+		spec     []string
+	}{
+		{
+			name:    "Inline document",
+			input:   "```openapi\n" + testOpenAPI + "\n```",
+			docPath: "services/orders",
+			contains: []string{
+				`<div class="openapi-fallback"><strong>Orders &lt;API&gt;</strong> <span class="openapi-version">1.2.0</span><ul>`,
+				`<li><code>GET /orders/{id}</code> Get an order</li><li><code>DELETE /orders/{id}</code></li>`,
+			},
+			excludes: []string{"data-source", "```"},
+			spec: []string{
+				`"openapi":"3.0.3","info":{"title":"Orders \u003cAPI\u003e","version":"1.2.0"},"paths":{"/orders/{id}":{"parameters":`,
+				`"required":true`,
+				`"responses":{"200":{"description":"The order"}}`,
+				`"deprecated":true`,
+			},
+		},
+		{
+			name:     "Attachments of the page",
+			input:    "```swagger\nfile: legacy.json\n```",
+			docPath:  "services/orders",
+			contains: []string{`data-source="/api/files/services/orders/legacy.json"`, `<strong>Legacy</strong><ul><li><code>POST /ping</code> Ping</li></ul>`},
+			spec:     []string{`{"swagger":"2.0","info":{"title":"Legacy"},"paths":{"/ping":{"post":{"summary":"Ping"}}}}`},
+		},
+		{
+			name:     "Documents that can't be read",
+			input:    "```openapi\nfile: missing.yaml\n```\n```openapi\nfile: ../../../etc/passwd\n```\n```openapi\nfile: https://example.com/api.yaml\n```",
+			docPath:  "services/orders",
+			contains: []string{"can&#39;t read missing.yaml", "can&#39;t read ../../../etc/passwd", "only attachments of the page can be shown"},
+			excludes: []string{"data-spec"},
+		},
+		{
+			name:     "Documents that aren't OpenAPI",
+			input:    "```openapi\ntitle: Orders\n```\n```openapi\nopenapi: [3\n```",
+			docPath:  "services/orders",
+			contains: []string{"<strong>OpenAPI:</strong> not an OpenAPI 3 or Swagger 2 document", "<strong>OpenAPI:</strong> invalid YAML or JSON"},
+			excludes: []string{"data-spec"},
+		},
+		{
+			name:     "Other code blocks are left alone",
+			input:    "```yaml\nopenapi: 3.0.0\n```",
+			docPath:  "services/orders",
+			contains: []string{"```yaml\nopenapi: 3.0.0\n```"},
+			excludes: []string{`class="openapi"`},
+		},
+	}
+
+	specRe := regexp.MustCompile(`data-spec="([^"]*)"`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := OpenAPIPreprocessor(tt.input, tt.docPath)
+			for _, want := range tt.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("OpenAPIPreprocessor() = %q, want it to contain %q", result, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("OpenAPIPreprocessor() = %q, want it not to contain %q", result, unwanted)
+				}
+			}
+			if len(tt.spec) == 0 {
+				return
+			}
+			match := specRe.FindStringSubmatch(result)
+			if match == nil {
+				t.Fatalf("OpenAPIPreprocessor() = %q, want a data-spec attribute", result)
+			}
+			spec, err := base64.RawURLEncoding.DecodeString(match[1])
+			if err != nil {
+				t.Fatalf("data-spec isn't base64url: %v", err)
+			}
+			for _, want := range tt.spec {
+				if !strings.Contains(string(spec), want) {
+					t.Errorf("data-spec = %s, want it to contain %s", spec, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package goldext
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// inlineLinkRegex matches the destination of [text](destination) links and
+	// ![alt](destination) images
+	inlineLinkRegex = regexp.MustCompile(`(!?)\[[^\]]*\]\(\s*(?:<([^>\n]+)>|([^)\s]+))`)
+	// referenceLinkRegex matches the destination of [label]: destination
+	// definitions
+	referenceLinkRegex = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:[ \t]*(?:<([^>\n]+)>|(\S+))`)
+)
+
+// PageLinks returns the paths of the wiki pages the page at docPath links to,
+// in order and without repeats: absolute links like [text](/docs/setup), their
+// reference definitions, [[/page#heading]] cross-references and, when
+// Obsidian support is on, [[wikilinks]].
+// The homepage is the empty path. Whether the pages exist is left to the
+// caller.
+func PageLinks(markdown, docPath string) []string {
+	docPath = strings.Trim(docPath, "/")
+	var links []string
+	seen := map[string]bool{docPath: true}
+	add := func(target string) {
+		if pagePath, ok := pageLinkPath(target); ok && !seen[pagePath] {
+			seen[pagePath] = true
+			links = append(links, pagePath)
+		}
+	}
+
+	for _, section := range splitCodeSections(markdown) {
+		if section.isCode {
+			continue
+		}
+		for _, match := range inlineLinkRegex.FindAllStringSubmatch(section.content, -1) {
+			if match[1] == "" {
+				add(match[2] + match[3])
+			}
+		}
+		for _, match := range referenceLinkRegex.FindAllStringSubmatch(section.content, -1) {
+			add(match[1] + match[2])
+		}
+	}
+
+	for _, ref := range SectionRefs(markdown) {
+		add("/" + ref.Page)
+	}
+	if wikiLinksEnabled(docPath) {
+		ReplaceWikiLinks(markdown, func(link WikiLink) string {
+			if link.Target != "" && !link.IsFile() && !isSectionRef(link) {
+				pagePath, _ := ResolveWikiLink(link.Target, docPath)
+				add("/" + pagePath)
+			}
+			return link.Raw
+		})
+	}
+	return links
+}
+
+// pageLinkPath returns the page path of a link destination when it is an
+// absolute link within the wiki
+func pageLinkPath(target string) (string, bool) {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return "", false
+	}
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	return strings.Trim(target, "/"), true
+}
@@ -0,0 +1,51 @@
+package goldext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPageLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		docPath  string
+		want     []string
+	}{
+		{
+			name:     "Absolute links",
+			markdown: "See [setup](/docs/setup#install), [again](/docs/setup/) and [home](/).",
+			docPath:  "docs/intro",
+			want:     []string{"docs/setup", ""},
+		},
+		{
+			name:     "Escaped paths and queries",
+			markdown: "[Release notes](</docs/release notes>) and [v2](/docs/release%20notes?version=2) and [x](/it/vpn \"VPN\")",
+			want:     []string{"docs/release notes", "it/vpn"},
+		},
+		{
+			name:     "Reference definitions",
+			markdown: "Read the [guide][g].\n\n[g]: /guides/start\n  [h]: <https://example.com>",
+			want:     []string{"guides/start"},
+		},
+		{
+			name:     "Attachments, images, other sites and the page itself are skipped",
+			markdown: "[file](report.pdf) ![logo](/static/logo.png) [site](https://example.com) [proto](//cdn.example.com/x) [self](/docs/intro) [top](#top)",
+			docPath:  "/docs/intro/",
+			want:     nil,
+		},
+		{
+			name:     "Links in code are skipped",
+			markdown: "`[a](/a)`\n\n```\n[b](/b)\n```\n\n[c](/c)",
+			want:     []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PageLinks(tt.markdown, tt.docPath); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PageLinks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package goldext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/pagequery"
+	"wiki-go/internal/review"
+)
+
+// Formats of pages blocks
+const (
+	PagesTable = "table"
+	PagesList  = "list"
+)
+
+// PagesQuery is what js/pages-query.js needs for a pages block: the query it
+// sends to the page metadata API and how it shows the pages found. The pages
+// are looked up when the page is viewed, so the list is always current and
+// holds only pages the reader may see.
+type PagesQuery struct {
+	Query   string   // Query string of /api/pages/metadata
+	Columns []string // title, path, modified, last_editor, owners, tags, review_due, last_reviewed, frontmatter keys or labels of computed columns
+	Format  string   // PagesTable or PagesList
+	Grouped bool     // Pages are shown under the value of the group expression
+}
+
+// pagesQueryInput is a pages block as written in a page
+type pagesQueryInput struct {
+	Path              string    `yaml:"path"`
+	Tags              ganttList `yaml:"tags"`
+	Tag               ganttList `yaml:"tag"`
+	Owner             string    `yaml:"owner"`
+	Where             yaml.Node `yaml:"where"` // Frontmatter fields and their values
+	ReviewedWithin    string    `yaml:"reviewed_within"`
+	NotReviewedWithin string    `yaml:"not_reviewed_within"`
+	ModifiedWithin    string    `yaml:"modified_within"`
+	Overdue           *bool     `yaml:"overdue"`
+	Archived          bool      `yaml:"archived"`
+	Sort              string    `yaml:"sort"`
+	Filter            string    `yaml:"filter"` // Expression pages must meet
+	Group             string    `yaml:"group"`  // Expression pages are grouped by
+	Limit             int       `yaml:"limit"`
+	Columns           yaml.Node `yaml:"columns"` // Names, and labels of computed columns with their expression
+	Format            string    `yaml:"format"`
+}
+
+// PagesQueryPreprocessor replaces ```pages blocks with a table or list of the
+// pages matching their query:
+//
+//	```pages
+//	path: services
+//	tag: tier-1
+//	where:
+//	  status: active
+//	filter: days_since(last_reviewed) > 90
+//	group: tier
+//	sort: title
+//	columns:
+//	  - title
+//	  - owner
+//	  - Days since review: days_since(last_reviewed)
+//	```
+//
+// Computed columns, filter and group are expressions of package pagequery,
+// evaluated by the server.
+func PagesQueryPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, "pages") {
+		return markdown
+	}
+	return replaceFencedBlocks(markdown, []string{"pages"}, func(_ string, content []string) string {
+		return renderPagesQuery(strings.Join(content, "\n"))
+	})
+}
+
+// renderPagesQuery returns the element of a pages block, or the errors found in it
+func renderPagesQuery(content string) string {
+	query, err := ParsePagesQuery(content)
+	if err != nil {
+		return `<div class="pages-query-error"><strong>Pages:</strong> ` + html.EscapeString(err.Error()) + `</div>`
+	}
+	columns, _ := json.Marshal(query.Columns)
+	grouped := ""
+	if query.Grouped {
+		grouped = ` data-grouped="true"`
+	}
+	return `<div class="pages-query" data-query="` + html.EscapeString(query.Query) +
+		`" data-columns="` + html.EscapeString(string(columns)) +
+		`" data-format="` + query.Format + `"` + grouped + `><p class="pages-query-loading">Loading pages…</p></div>`
+}
+
+// ParsePagesQuery reads the YAML of a pages block
+func ParsePagesQuery(content string) (PagesQuery, error) {
+	var query PagesQuery
+	var input pagesQueryInput
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		return query, fmt.Errorf("invalid YAML: %v", err)
+	}
+
+	values := url.Values{}
+	if path := strings.Trim(strings.TrimSpace(input.Path), "/"); path != "" {
+		values.Set("path", path)
+	}
+	for _, tag := range append(input.Tags, input.Tag...) {
+		values.Add("tag", tag)
+	}
+	if owner := strings.TrimSpace(input.Owner); owner != "" {
+		values.Set("owner", owner)
+	}
+
+	if input.Where.Kind != 0 {
+		if input.Where.Kind != yaml.MappingNode {
+			return query, fmt.Errorf("where must list frontmatter fields and their values")
+		}
+		for i := 0; i+1 < len(input.Where.Content); i += 2 {
+			key, value := input.Where.Content[i].Value, input.Where.Content[i+1]
+			switch {
+			case value.Kind != yaml.ScalarNode:
+				return query, fmt.Errorf("field %q must have a single value", key)
+			case value.ShortTag() == "!!null" || value.Value == "":
+				values.Add("field", key)
+			default:
+				values.Add("field", key+":"+value.Value)
+			}
+		}
+	}
+
+	intervals := []struct{ name, value string }{
+		{"reviewed_within", input.ReviewedWithin},
+		{"not_reviewed_within", input.NotReviewedWithin},
+		{"modified_within", input.ModifiedWithin},
+	}
+	for _, interval := range intervals {
+		if interval.value == "" {
+			continue
+		}
+		if _, _, _, err := review.ParseInterval(interval.value); err != nil {
+			return query, fmt.Errorf("%s: %v", interval.name, err)
+		}
+		values.Set(interval.name, interval.value)
+	}
+	if input.Overdue != nil {
+		values.Set("overdue", map[bool]string{true: "1", false: "0"}[*input.Overdue])
+	}
+	if input.Archived {
+		values.Set("archived", "1")
+	}
+
+	expressions := []struct{ name, value string }{
+		{"filter", input.Filter},
+		{"group", input.Group},
+	}
+	for _, expression := range expressions {
+		if strings.TrimSpace(expression.value) == "" {
+			continue
+		}
+		if _, err := pagequery.Compile(expression.value); err != nil {
+			return query, fmt.Errorf("%s: %v", expression.name, err)
+		}
+		values.Set(expression.name, expression.value)
+	}
+	query.Grouped = values.Has("group")
+
+	if sort := strings.TrimSpace(input.Sort); sort != "" {
+		if strings.TrimPrefix(sort, "-") == "" {
+			return query, fmt.Errorf("sort needs a field")
+		}
+		values.Set("sort", sort)
+	}
+	if input.Limit < 0 {
+		return query, fmt.Errorf("limit must be a positive number")
+	}
+	if input.Limit > 0 {
+		values.Set("limit", strconv.Itoa(input.Limit))
+	}
+
+	query.Format = strings.ToLower(strings.TrimSpace(input.Format))
+	switch query.Format {
+	case "":
+		query.Format = PagesTable
+	case PagesTable, PagesList:
+	default:
+		return query, fmt.Errorf("format must be table or list")
+	}
+
+	if err := parsePagesColumns(input.Columns, &query, values); err != nil {
+		return query, err
+	}
+	if len(query.Columns) == 0 {
+		query.Columns = []string{"title"}
+		if query.Format == PagesTable {
+			query.Columns = append(query.Columns, "modified")
+		}
+	}
+
+	query.Query = values.Encode()
+	return query, nil
+}
+
+// parsePagesColumns reads the columns of a pages block: names, a list of them
+// separated by commas, or computed columns written as "label: expression",
+// which are added to the query
+func parsePagesColumns(node yaml.Node, query *PagesQuery, values url.Values) error {
+	var items []*yaml.Node
+	switch node.Kind {
+	case 0:
+		return nil
+	case yaml.ScalarNode:
+		items = []*yaml.Node{&node}
+	case yaml.SequenceNode:
+		items = node.Content
+	default:
+		return fmt.Errorf("columns must be a list")
+	}
+
+	for _, item := range items {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			var names ganttList
+			if err := item.Decode(&names); err != nil {
+				return err
+			}
+			query.Columns = append(query.Columns, names...)
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				label, source := strings.TrimSpace(item.Content[i].Value), item.Content[i+1].Value
+				if label == "" || strings.Contains(label, "=") {
+					return fmt.Errorf("invalid column label %q", label)
+				}
+				if _, err := pagequery.Compile(source); err != nil {
+					return fmt.Errorf("column %q: %v", label, err)
+				}
+				values.Add("compute", label+"="+source)
+				query.Columns = append(query.Columns, label)
+			}
+		default:
+			return fmt.Errorf("columns must be names or label: expression")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package goldext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePagesQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    PagesQuery
+		err     string
+	}{
+		{
+			name:    "Empty block lists every page",
+			content: "",
+			want:    PagesQuery{Columns: []string{"title", "modified"}, Format: PagesTable},
+		},
+		{
+			name: "Filters, sort and columns",
+			content: `path: /services/
+tag: tier-1
+where:
+  status: active
+  tier: 1
+  oncall_link:
+sort: -modified
+limit: 10
+columns: title, owner, tier`,
+			want: PagesQuery{
+				Query:   "field=status%3Aactive&field=tier%3A1&field=oncall_link&limit=10&path=services&sort=-modified&tag=tier-1",
+				Columns: []string{"title", "owner", "tier"},
+				Format:  PagesTable,
+			},
+		},
+		{
+			name: "List of pages due for review",
+			content: `tags: [runbook, ops]
+owner: me
+not_reviewed_within: 90d
+overdue: true
+archived: true
+format: list`,
+			want: PagesQuery{
+				Query:   "archived=1&not_reviewed_within=90d&overdue=1&owner=me&tag=runbook&tag=ops",
+				Columns: []string{"title"},
+				Format:  PagesList,
+			},
+		},
+		{
+			name: "Computed columns, filter and group",
+			content: `filter: days_since(last_reviewed) > 90
+group: tier
+columns:
+  - title
+  - Age: days_since(last_reviewed)`,
+			want: PagesQuery{
+				Query:   "compute=Age%3Ddays_since%28last_reviewed%29&filter=days_since%28last_reviewed%29+%3E+90&group=tier",
+				Columns: []string{"title", "Age"},
+				Format:  PagesTable,
+				Grouped: true,
+			},
+		},
+		{
+			name:    "Invalid filter",
+			content: "filter: tier >",
+			err:     "filter:",
+		},
+		{
+			name:    "Invalid computed column",
+			content: "columns:\n  - Due: days_until(",
+			err:     `column "Due"`,
+		},
+		{
+			name:    "Column label with equals sign",
+			content: "columns:\n  - a=b: tier",
+			err:     "invalid column label",
+		},
+		{
+			name:    "Unknown option",
+			content: "paths: services",
+			err:     "invalid YAML",
+		},
+		{
+			name:    "Invalid interval",
+			content: "modified_within: soon",
+			err:     "modified_within",
+		},
+		{
+			name:    "Field with several values",
+			content: "where:\n  tier: [1, 2]",
+			err:     `field "tier" must have a single value`,
+		},
+		{
+			name:    "Unknown format",
+			content: "format: cards",
+			err:     "format must be table or list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePagesQuery(tt.content)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ParsePagesQuery() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePagesQuery() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePagesQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPagesQueryPreprocessor(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			name:     "Block",
+			markdown: "# Services\n\n```pages\npath: services\nformat: list\n```\n",
+			want:     "# Services\n\n<div class=\"pages-query\" data-query=\"path=services\" data-columns=\"[&#34;title&#34;]\" data-format=\"list\"><p class=\"pages-query-loading\">Loading pages…</p></div>\n",
+		},
+		{
+			name:     "Grouped block",
+			markdown: "```pages\ngroup: tier\ncolumns: title, tier\n```",
+			want:     "<div class=\"pages-query\" data-query=\"group=tier\" data-columns=\"[&#34;title&#34;,&#34;tier&#34;]\" data-format=\"table\" data-grouped=\"true\"><p class=\"pages-query-loading\">Loading pages…</p></div>",
+		},
+		{
+			name:     "Error",
+			markdown: "```pages\nlimit: -1\n```",
+			want:     "<div class=\"pages-query-error\"><strong>Pages:</strong> limit must be a positive number</div>",
+		},
+		{
+			name:     "Inside another code block",
+			markdown: "````markdown\n```pages\npath: x\n```\n````",
+			want:     "````markdown\n```pages\npath: x\n```\n````",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PagesQueryPreprocessor(tt.markdown, ""); got != tt.want {
+				t.Errorf("PagesQueryPreprocessor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"compress/zlib"
 	"encoding/base64"
 	"fmt"
-	"io"
+	"html"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"wiki-go/internal/breaker"
 	"wiki-go/internal/config"
+	"wiki-go/internal/safehttp"
 )
 
 // Store extracted PlantUML blocks until after Goldmark processing
@@ -101,6 +106,22 @@ func GetRemoteDiagram(code string, cfg *config.Config, dark bool) string {
 		return fmt.Sprintf("<p>%v</p>", code)
 	}
 
+	// Only the configured server may be contacted, redirects elsewhere are refused
+	server, err := url.Parse(cfg.Extensions.PlantUML.ServerURL)
+	if err != nil || (server.Scheme != "http" && server.Scheme != "https") || server.Host == "" {
+		return "<p>Error fetching PlantUML diagram: invalid server URL</p>"
+	}
+	policy := plantumlPolicy(cfg)
+	policy.AllowedHosts = []string{server.Hostname()}
+	policy.Breaker = plantumlBreaker
+	// The server is chosen by the administrator and is often self-hosted on the local network
+	policy.AllowPrivate = true
+
+	code, err = resolveIncludes(code, cfg)
+	if err != nil {
+		return fmt.Sprintf("<p>Error including PlantUML file: %s</p>", html.EscapeString(err.Error()))
+	}
+
 	// Encode the PlantUML code to a URL-safe format
 	encodedCode := EncodeCode(code)
 
@@ -113,27 +134,154 @@ func GetRemoteDiagram(code string, cfg *config.Config, dark bool) string {
 	}
 
 	// Construct the full URL for the PlantUML server
-	url := fmt.Sprintf(
+	diagramURL := fmt.Sprintf(
 		"%s/%s%s/%s",
-		cfg.Extensions.PlantUML.ServerURL,
+		strings.TrimSuffix(cfg.Extensions.PlantUML.ServerURL, "/"),
 		darkPrefix,
 		cfg.Extensions.PlantUML.ImageFormat,
 		encodedCode,
 	)
 
 	// Do request to fetch the content
-	contentRequest, err := http.Get(url)
+	_, content, err := policy.Get(diagramURL)
 	if err != nil {
-		return fmt.Sprintf("<p>Error fetching PlantUML diagram: %v</p>", err)
+		return fmt.Sprintf("<p>Error fetching PlantUML diagram: %s</p>", html.EscapeString(err.Error()))
 	}
-	defer contentRequest.Body.Close()
 
-	content, err := io.ReadAll(contentRequest.Body)
-	if err != nil {
-		return fmt.Sprintf("<p>Error reading PlantUML diagram: %v</p>", err)
+	return string(content)
+}
+
+// Breakers of the PlantUML server and of the hosts of proxied includes. While the
+// server is down, diagrams fail right away instead of each waiting for the timeout.
+var (
+	plantumlBreaker = breaker.New("plantuml", breaker.Options{Threshold: 3, Cooldown: 30 * time.Second, MaxConcurrent: 16})
+	includeBreaker  = breaker.New("plantuml includes", breaker.Options{Threshold: 3, Cooldown: 30 * time.Second, MaxConcurrent: 16})
+)
+
+// plantumlPolicy returns the size and time limits for requests made while rendering diagrams
+func plantumlPolicy(cfg *config.Config) safehttp.Policy {
+	maxKB := cfg.Extensions.PlantUML.MaxResponseKB
+	if maxKB <= 0 {
+		maxKB = 2048
 	}
+	timeout := cfg.Extensions.PlantUML.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return safehttp.Policy{
+		MaxBytes: int64(maxKB) * 1024,
+		Timeout:  time.Duration(timeout) * time.Second,
+		Attempts: 2,
+	}
+}
 
-	return string(content)
+// maxIncludeDepth limits how deeply proxied includes may include other files
+const maxIncludeDepth = 5
+
+// includeLine matches preprocessor includes of remote files, e.g. "!include https://host/file.puml"
+// or "!includesub https://host/file.puml!PART". Standard library includes like
+// "!include <C4/C4_Container>" are resolved by the server itself.
+var includeLine = regexp.MustCompile(`^\s*!(include|includeurl|include_many|include_once|includesub)\s+(https?://\S+?)\s*$`)
+
+// resolveIncludes applies the configured include mode to the remote includes of a diagram.
+// In "proxy" mode the files are fetched by the wiki from the allowed hosts and inlined,
+// in "block" mode they are removed, and in "server" mode they are left to the server.
+func resolveIncludes(code string, cfg *config.Config) (string, error) {
+	switch cfg.Extensions.PlantUML.Includes {
+	case "proxy":
+		policy := plantumlPolicy(cfg)
+		policy.Breaker = includeBreaker
+		for _, host := range strings.Split(cfg.Extensions.PlantUML.IncludeHosts, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				policy.AllowedHosts = append(policy.AllowedHosts, host)
+			}
+		}
+		return inlineIncludes(code, policy, make(map[string]bool), 0)
+	case "block":
+		lines := strings.Split(code, "\n")
+		for i, line := range lines {
+			if match := includeLine.FindStringSubmatch(line); match != nil {
+				lines[i] = "' include of " + match[2] + " removed"
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	default:
+		return code, nil
+	}
+}
+
+// inlineIncludes replaces remote includes with the content of the included files
+func inlineIncludes(code string, policy safehttp.Policy, included map[string]bool, depth int) (string, error) {
+	if depth > maxIncludeDepth {
+		return "", fmt.Errorf("includes nested more than %d levels deep", maxIncludeDepth)
+	}
+
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		match := includeLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		directive, target := match[1], match[2]
+
+		// !includesub names the part of the file to include after a final "!"
+		var sub string
+		if directive == "includesub" {
+			idx := strings.LastIndex(target, "!")
+			if idx < 0 {
+				return "", fmt.Errorf("%s has no sub-part name", target)
+			}
+			target, sub = target[:idx], target[idx+1:]
+		}
+
+		if directive == "include_once" && included[target] {
+			lines[i] = ""
+			continue
+		}
+		included[target] = true
+
+		status, body, err := policy.Get(target)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", target, err)
+		}
+		if status != http.StatusOK {
+			return "", fmt.Errorf("%s: server returned status %d", target, status)
+		}
+
+		content := includedContent(string(body), sub)
+		content, err = inlineIncludes(content, policy, included, depth+1)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = content
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// includedContent returns the part of an included file that belongs in the diagram,
+// without its @startuml/@enduml lines, or only the named !startsub part
+func includedContent(body, sub string) string {
+	var kept []string
+	inSub := false
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if sub != "" {
+			switch {
+			case trimmed == "!startsub "+sub:
+				inSub = true
+			case trimmed == "!endsub" && inSub:
+				inSub = false
+			case inSub:
+				kept = append(kept, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "@startuml") || strings.HasPrefix(trimmed, "@enduml") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
 }
 
 func Replace(data string) string {
@@ -0,0 +1,58 @@
+package goldext
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestResolveIncludesBlock(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Extensions.PlantUML.Includes = "block"
+
+	code := "@startuml\n!include https://example.com/style.puml\n!include <C4/C4_Container>\nA -> B\n@enduml"
+	got, err := resolveIncludes(code, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@startuml\n' include of https://example.com/style.puml removed\n!include <C4/C4_Container>\nA -> B\n@enduml"
+	if got != expected {
+		t.Errorf("resolveIncludes() = %q, want %q", got, expected)
+	}
+}
+
+func TestResolveIncludesProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("@startuml\nskinparam monochrome true\n@enduml\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.Extensions.PlantUML.Includes = "proxy"
+	code := "!include " + server.URL + "/style.puml\nA -> B"
+
+	// The test server isn't an allowed host
+	if _, err := resolveIncludes(code, cfg); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected host to be rejected, got %v", err)
+	}
+
+	// Allowed, but loopback addresses are refused when connecting
+	cfg.Extensions.PlantUML.IncludeHosts = "127.0.0.1"
+	if _, err := resolveIncludes(code, cfg); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected loopback address to be rejected, got %v", err)
+	}
+}
+
+func TestIncludedContent(t *testing.T) {
+	body := "@startuml\r\nskinparam monochrome true\r\n!startsub COLORS\r\nskinparam backgroundColor #EEE\r\n!endsub\r\n@enduml\r\n"
+
+	if got, expected := includedContent(body, ""), "skinparam monochrome true\n!startsub COLORS\nskinparam backgroundColor #EEE\n!endsub\n"; got != expected {
+		t.Errorf("includedContent() = %q, want %q", got, expected)
+	}
+	if got, expected := includedContent(body, "COLORS"), "skinparam backgroundColor #EEE"; got != expected {
+		t.Errorf("includedContent(COLORS) = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,81 @@
+package goldext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/review"
+)
+
+var reviewReportRegex = regexp.MustCompile(`^:::needs-review(\s+all)?:::$`)
+
+// ReviewReportPreprocessor replaces a :::needs-review::: line with a table of the
+// pages that are overdue for review. :::needs-review all::: lists every page with
+// a review interval, overdue pages first.
+func ReviewReportPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, ":::needs-review") {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	processedLines := make([]string, 0, len(lines))
+
+	inBacktickBlock := false
+	inTildeBlock := false
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if !inTildeBlock && strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+		} else if !inBacktickBlock && strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+		} else if !inBacktickBlock && !inTildeBlock {
+			if m := reviewReportRegex.FindStringSubmatch(trimmedLine); m != nil {
+				processedLines = append(processedLines, renderReviewReport(m[1] != ""))
+				continue
+			}
+		}
+
+		processedLines = append(processedLines, line)
+	}
+
+	return strings.Join(processedLines, "\n")
+}
+
+// renderReviewReport builds the report table. It contains no blank lines so
+// Goldmark treats it as a single HTML block.
+func renderReviewReport(all bool) string {
+	statuses, err := review.Scan(config.Cfg, time.Now())
+	if err != nil {
+		return `<div class="review-report">Error: ` + html.EscapeString(err.Error()) + `</div>`
+	}
+
+	var rows []string
+	for _, status := range statuses {
+		if !all && !status.Overdue {
+			continue
+		}
+
+		due := status.Due.Format(review.DateFormat)
+		if status.Overdue {
+			due = `<span class="review-overdue">` + due + `</span>`
+		}
+		rows = append(rows, "<tr><td><a href=\""+html.EscapeString(status.Path)+"\">"+html.EscapeString(status.Title)+"</a></td>"+
+			"<td>"+html.EscapeString(strings.Join(status.Owners, ", "))+"</td>"+
+			"<td>"+status.LastReviewed.Format(review.DateFormat)+"</td>"+
+			"<td>"+html.EscapeString(status.ReviewEvery)+"</td>"+
+			"<td>"+due+"</td></tr>")
+	}
+
+	if len(rows) == 0 {
+		return `<div class="review-report review-report-empty">No pages need review.</div>`
+	}
+
+	return "<table class=\"review-report\">\n" +
+		"<thead><tr><th>Page</th><th>Owners</th><th>Last reviewed</th><th>Interval</th><th>Due</th></tr></thead>\n" +
+		"<tbody>\n" + strings.Join(rows, "\n") + "\n</tbody>\n</table>"
+}
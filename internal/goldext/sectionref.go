@@ -0,0 +1,290 @@
+package goldext
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+)
+
+var pageHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+?)(?:\s+\{#([a-zA-Z0-9-]+)\})?$`)
+
+// PageHeading is a heading of a page with the id it gets when the page is
+// rendered, the same as in its table of contents
+type PageHeading struct {
+	Level int
+	Text  string
+	ID    string
+	Body  string // Text up to the next heading
+}
+
+// SectionRef is a [[/page#heading]] cross-reference to a section of another
+// page, or a [[/page]] link to the page itself
+type SectionRef struct {
+	Page    string // Path of the page, empty for the homepage
+	Heading string // Heading as written, its text or its id
+	Alias   string // Text after |
+	Line    int    // 1-based line in the markdown
+}
+
+// isSectionRef reports whether a [[link]] names a page by its absolute path,
+// which makes it a cross-reference. These work without Obsidian support.
+func isSectionRef(link WikiLink) bool {
+	return !link.Embed && strings.HasPrefix(link.Target, "/") && !link.IsFile()
+}
+
+// sectionRef returns the cross-reference of a [[link]]
+func sectionRef(link WikiLink) SectionRef {
+	return SectionRef{
+		Page:    strings.Trim(strings.TrimSuffix(link.Target, ".md"), "/"),
+		Heading: link.Heading,
+		Alias:   link.Alias,
+	}
+}
+
+// SectionRefs returns the cross-references of markdown, outside of code
+func SectionRefs(markdown string) []SectionRef {
+	var refs []SectionRef
+	inCodeBlock := false
+	for i, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock || !strings.Contains(line, "[[/") {
+			continue
+		}
+		ReplaceWikiLinks(line, func(link WikiLink) string {
+			if isSectionRef(link) {
+				ref := sectionRef(link)
+				ref.Line = i + 1
+				refs = append(refs, ref)
+			}
+			return link.Raw
+		})
+	}
+	return refs
+}
+
+// PageHeadings returns the headings of markdown in order
+func PageHeadings(markdown string) []PageHeading {
+	var headings []PageHeading
+	var body []string
+	usedIDs := make(map[string]bool)
+	inCodeBlock := false
+
+	endSection := func() {
+		if len(headings) > 0 {
+			headings[len(headings)-1].Body = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+		}
+		m := pageHeadingRegex.FindStringSubmatch(trimmed)
+		if inCodeBlock || m == nil {
+			body = append(body, line)
+			continue
+		}
+		endSection()
+
+		// Ids are made the way TocPreprocessor makes them
+		text := strings.TrimSpace(m[2])
+		id := m[3]
+		if id == "" {
+			id = makeSlug(headingIDText(text))
+		}
+		base := id
+		for counter := 1; usedIDs[id]; counter++ {
+			id = fmt.Sprintf("%s-%d", base, counter)
+		}
+		usedIDs[id] = true
+		headings = append(headings, PageHeading{Level: len(m[1]), Text: text, ID: id})
+	}
+	endSection()
+	return headings
+}
+
+// FindHeading returns the heading a cross-reference names, by its id or its text
+func FindHeading(headings []PageHeading, name string) (PageHeading, bool) {
+	slug := makeSlug(name)
+	for _, heading := range headings {
+		if heading.ID == name || heading.ID == slug {
+			return heading, true
+		}
+	}
+	for _, heading := range headings {
+		if strings.EqualFold(heading.Text, name) {
+			return heading, true
+		}
+	}
+	return PageHeading{}, false
+}
+
+// readPageHeadings returns the headings of the page at pagePath, false when
+// there is no such page
+func readPageHeadings(pagePath string) ([]PageHeading, bool) {
+	file := filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir, filepath.FromSlash(pagePath), "document.md")
+	if pagePath == "" {
+		file = filepath.Join(config.Cfg.Wiki.RootDir, "pages", "home", "document.md")
+	}
+	content, err := encryption.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	_, body, _ := frontmatter.Parse(string(content))
+	return PageHeadings(body), true
+}
+
+// CheckSectionRef returns the id of the heading a cross-reference points at,
+// "" for links to a page, or why it doesn't resolve
+func CheckSectionRef(ref SectionRef) (string, string) {
+	if strings.Contains(ref.Page, "..") {
+		return "", "Invalid page path /" + ref.Page
+	}
+	headings, ok := readPageHeadings(ref.Page)
+	if !ok {
+		return "", "Page /" + ref.Page + " does not exist"
+	}
+	if ref.Heading == "" {
+		return "", ""
+	}
+	heading, ok := FindHeading(headings, ref.Heading)
+	if !ok {
+		return "", "Section " + ref.Heading + " does not exist on /" + ref.Page
+	}
+	return heading.ID, ""
+}
+
+// SectionRefPreprocessor turns [[/page#heading]] cross-references into links
+// to the heading, and [[/page]] into links to the page. References to missing
+// pages or headings are marked as broken.
+func SectionRefPreprocessor(markdown string, _ string) string {
+	if !strings.Contains(markdown, "[[/") {
+		return markdown
+	}
+	return ReplaceWikiLinks(markdown, func(link WikiLink) string {
+		if !isSectionRef(link) {
+			return link.Raw
+		}
+		ref := sectionRef(link)
+		text := orDefault(ref.Alias, orDefault(ref.Heading, ref.Page))
+		id, problem := CheckSectionRef(ref)
+		if problem != "" {
+			return `<a class="section-ref-broken" href="/` + html.EscapeString(ref.Page) + `" title="` + html.EscapeString(problem) + `">` +
+				html.EscapeString(text) + `</a>`
+		}
+		href := "/" + ref.Page
+		if id != "" {
+			href += "#" + id
+		}
+		return "[" + escapeWikiLinkText(text) + "](" + href + ")"
+	})
+}
+
+// SectionRenames compares two versions of a page and returns the headings
+// that were renamed or moved within it: the old id and the new name of each.
+// A heading is matched to a new one with the same section text, or, when a
+// single heading changed, to the one that took its place.
+func SectionRenames(before, after string) map[string]string {
+	_, before, _ = frontmatter.Parse(before)
+	_, after, _ = frontmatter.Parse(after)
+	old := PageHeadings(before)
+	current := PageHeadings(after)
+
+	kept := make(map[string]bool)
+	for _, heading := range current {
+		kept[heading.ID] = true
+	}
+	var removed, added []PageHeading
+	for _, heading := range old {
+		if !kept[heading.ID] {
+			removed = append(removed, heading)
+		}
+	}
+	was := make(map[string]bool)
+	for _, heading := range old {
+		was[heading.ID] = true
+	}
+	for _, heading := range current {
+		if !was[heading.ID] {
+			added = append(added, heading)
+		}
+	}
+	if len(removed) == 0 || len(added) == 0 {
+		return nil
+	}
+
+	renames := make(map[string]string)
+	if len(removed) == 1 && len(added) == 1 {
+		renames[removed[0].ID] = refHeading(added[0])
+		return renames
+	}
+	taken := make(map[string]bool)
+	for _, heading := range removed {
+		if heading.Body == "" {
+			continue
+		}
+		for _, candidate := range added {
+			if !taken[candidate.ID] && candidate.Body == heading.Body {
+				renames[heading.ID] = refHeading(candidate)
+				taken[candidate.ID] = true
+				break
+			}
+		}
+	}
+	return renames
+}
+
+// refHeading returns how a cross-reference names a heading: by its text, or
+// by its id when the text has formatting or characters links can't hold
+func refHeading(heading PageHeading) string {
+	if makeSlug(heading.Text) == heading.ID && !strings.ContainsAny(heading.Text, "[]|#") {
+		return heading.Text
+	}
+	return heading.ID
+}
+
+// RenameSectionRefs points the cross-references of markdown to sections of
+// the page at pagePath that were renamed at their new headings, returning the
+// updated markdown and the number of references changed
+func RenameSectionRefs(markdown, pagePath string, renames map[string]string) (string, int) {
+	pagePath = strings.Trim(pagePath, "/")
+	changed := 0
+	updated := ReplaceWikiLinks(markdown, func(link WikiLink) string {
+		if !isSectionRef(link) || link.Heading == "" {
+			return link.Raw
+		}
+		ref := sectionRef(link)
+		if ref.Page != pagePath {
+			return link.Raw
+		}
+		heading, ok := renames[ref.Heading]
+		if !ok {
+			heading, ok = renames[makeSlug(ref.Heading)]
+		}
+		if !ok {
+			return link.Raw
+		}
+		changed++
+		raw := "[[" + link.Target + "#" + heading
+		if link.Alias != "" {
+			// Inside tables the pipe is escaped
+			if strings.Contains(link.Raw, `\|`) {
+				raw += `\`
+			}
+			raw += "|" + link.Alias
+		}
+		return raw + "]]"
+	})
+	return updated, changed
+}
@@ -0,0 +1,86 @@
+package goldext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPageHeadings(t *testing.T) {
+	markdown := "# Protocol\nIntro\n## Handshake\nHello\n## Handshake\n```\n## Not a heading\n```\n## Errors {#codes}"
+	want := []PageHeading{
+		{Level: 1, Text: "Protocol", ID: "protocol", Body: "Intro"},
+		{Level: 2, Text: "Handshake", ID: "handshake", Body: "Hello"},
+		{Level: 2, Text: "Handshake", ID: "handshake-1", Body: "```\n## Not a heading\n```"},
+		{Level: 2, Text: "Errors", ID: "codes"},
+	}
+	if got := PageHeadings(markdown); !reflect.DeepEqual(got, want) {
+		t.Errorf("PageHeadings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSectionRefs(t *testing.T) {
+	markdown := "See [[/specs/protocol#Handshake|the handshake]] and [[/specs/errors]].\n`[[/code#x]]` [[Name]]\n```\n[[/specs/protocol#y]]\n```"
+	want := []SectionRef{
+		{Page: "specs/protocol", Heading: "Handshake", Alias: "the handshake", Line: 1},
+		{Page: "specs/errors", Line: 1},
+	}
+	if got := SectionRefs(markdown); !reflect.DeepEqual(got, want) {
+		t.Errorf("SectionRefs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSectionRenames(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   map[string]string
+	}{
+		{
+			name:   "Single rename",
+			before: "## Handshake\nHello",
+			after:  "## Opening handshake\nHello, changed",
+			want:   map[string]string{"handshake": "Opening handshake"},
+		},
+		{
+			name:   "Renames matched by section text",
+			before: "## One\nFirst\n## Two\nSecond",
+			after:  "## Deux\nSecond\n## Un\nFirst",
+			want:   map[string]string{"one": "Un", "two": "Deux"},
+		},
+		{
+			name:   "Formatted heading is named by its id",
+			before: "## Limits\nText",
+			after:  "## Limits [draft]\nText",
+			want:   map[string]string{"limits": "limits-draft"},
+		},
+		{
+			name:   "Removed heading",
+			before: "## One\nFirst\n## Two\nSecond",
+			after:  "## One\nFirst",
+		},
+		{
+			name:   "Unchanged",
+			before: "---\ntitle: x\n---\n## One",
+			after:  "## One",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SectionRenames(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SectionRenames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameSectionRefs(t *testing.T) {
+	markdown := "[[/specs/protocol#Handshake]], [[/specs/protocol/#handshake|start]], [[/other#handshake]]\n| [[/specs/protocol#handshake\\|cell]] |"
+	want := "[[/specs/protocol#Opening handshake]], [[/specs/protocol/#Opening handshake|start]], [[/other#handshake]]\n| [[/specs/protocol#Opening handshake\\|cell]] |"
+
+	got, changed := RenameSectionRefs(markdown, "/specs/protocol", map[string]string{"handshake": "Opening handshake"})
+	if got != want || changed != 3 {
+		t.Errorf("RenameSectionRefs() = %q, %d, want %q, 3", got, changed, want)
+	}
+}
@@ -0,0 +1,103 @@
+package goldext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	sectionHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)(?:\s+\{#([a-zA-Z0-9-]+)\})?$`)
+	sectionCode    = regexp.MustCompile("`[^`]+`")
+	sectionLink    = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+)
+
+// MarkdownSection is a heading of a page with everything below it up to the
+// next heading of the same or a higher level, subsections included
+type MarkdownSection struct {
+	Level   int    `json:"level"`
+	Heading string `json:"heading"`
+	ID      string `json:"id"` // Id of the rendered heading
+	Start   int    `json:"-"`  // Byte offset of the heading line in the markdown
+	End     int    `json:"-"`  // Byte offset where the next section starts
+}
+
+// Sections returns the sections of markdown in the order of their headings.
+// Ids are given like TocPreprocessor does when the page is rendered; headings
+// in the frontmatter and in fenced code are skipped.
+func Sections(markdown string) []MarkdownSection {
+	offset := 0
+	if strings.HasPrefix(markdown, "---\n") {
+		if end := strings.Index(markdown[4:], "\n---"); end >= 0 {
+			offset = 4 + end + 4
+		}
+	}
+
+	var sections []MarkdownSection
+	used := make(map[string]bool)
+	inCode := false
+	for _, line := range strings.SplitAfter(markdown[offset:], "\n") {
+		start := offset
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		m := sectionHeading.FindStringSubmatch(trimmed)
+		if m == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(m[2])
+		id := m[3]
+		if id == "" {
+			id = makeSlug(sectionLink.ReplaceAllString(sectionCode.ReplaceAllString(text, ""), "$1"))
+		}
+		base := id
+		for counter := 1; used[id]; counter++ {
+			id = fmt.Sprintf("%s-%d", base, counter)
+		}
+		used[id] = true
+		sections = append(sections, MarkdownSection{Level: len(m[1]), Heading: text, ID: id, Start: start})
+	}
+
+	// A section ends where the next heading of its level or above starts
+	for i := range sections {
+		sections[i].End = len(markdown)
+		for _, next := range sections[i+1:] {
+			if next.Level <= sections[i].Level {
+				sections[i].End = next.Start
+				break
+			}
+		}
+	}
+	return sections
+}
+
+// FindSection returns the section of markdown whose heading has the given id
+func FindSection(markdown, id string) (MarkdownSection, bool) {
+	for _, section := range Sections(markdown) {
+		if section.ID == id {
+			return section, true
+		}
+	}
+	return MarkdownSection{}, false
+}
+
+// ReplaceSection replaces a section of markdown with text, keeping the blank
+// lines that separated it from the next section
+func ReplaceSection(markdown string, section MarkdownSection, text string) string {
+	old := markdown[section.Start:section.End]
+	separator := old[len(strings.TrimRight(old, "\n")):]
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		separator = ""
+	} else if separator == "" && section.End < len(markdown) {
+		separator = "\n"
+	}
+	return markdown[:section.Start] + text + separator + markdown[section.End:]
+}
@@ -0,0 +1,103 @@
+package goldext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []MarkdownSection
+	}{
+		{
+			name:     "Nested sections end at the next heading of their level",
+			markdown: "# Title\n\nIntro\n\n## Install\n\nSteps\n\n### Linux\n\napt\n\n## Usage\n\nRun it\n",
+			want: []MarkdownSection{
+				{Level: 1, Heading: "Title", ID: "title", Start: 0, End: 68},
+				{Level: 2, Heading: "Install", ID: "install", Start: 16, End: 51},
+				{Level: 3, Heading: "Linux", ID: "linux", Start: 35, End: 51},
+				{Level: 2, Heading: "Usage", ID: "usage", Start: 51, End: 68},
+			},
+		},
+		{
+			name:     "Frontmatter and code are skipped",
+			markdown: "---\ntitle: x\n---\n# A\n\n```\n# not a heading\n```\n",
+			want: []MarkdownSection{
+				{Level: 1, Heading: "A", ID: "a", Start: 17, End: 46},
+			},
+		},
+		{
+			name:     "Repeated headings and custom ids",
+			markdown: "## Notes\n## Notes\n## Setup `v2` {#setup}\n## [Links](/x)\n",
+			want: []MarkdownSection{
+				{Level: 2, Heading: "Notes", ID: "notes", Start: 0, End: 9},
+				{Level: 2, Heading: "Notes", ID: "notes-1", Start: 9, End: 18},
+				{Level: 2, Heading: "Setup `v2`", ID: "setup", Start: 18, End: 41},
+				{Level: 2, Heading: "[Links](/x)", ID: "links", Start: 41, End: 56},
+			},
+		},
+		{
+			name:     "No headings",
+			markdown: "Just text\n#hashtag\n",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sections(tt.markdown)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sections() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceSection(t *testing.T) {
+	page := "# Title\n\nIntro\n\n## Install\n\nSteps\n\n## Usage\n\nRun it\n"
+	tests := []struct {
+		name string
+		id   string
+		text string
+		want string
+	}{
+		{
+			name: "Blank lines before the next heading are kept",
+			id:   "install",
+			text: "## Install\n\nNew steps",
+			want: "# Title\n\nIntro\n\n## Install\n\nNew steps\n\n## Usage\n\nRun it\n",
+		},
+		{
+			name: "Last section",
+			id:   "usage",
+			text: "## Usage\n\nRun it twice\n",
+			want: "# Title\n\nIntro\n\n## Install\n\nSteps\n\n## Usage\n\nRun it twice\n",
+		},
+		{
+			name: "Removed section",
+			id:   "install",
+			text: "",
+			want: "# Title\n\nIntro\n\n## Usage\n\nRun it\n",
+		},
+		{
+			name: "Windows line endings",
+			id:   "install",
+			text: "## Setup\r\n\r\nSteps\r\n",
+			want: "# Title\n\nIntro\n\n## Setup\n\nSteps\n\n## Usage\n\nRun it\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section, ok := FindSection(page, tt.id)
+			if !ok {
+				t.Fatalf("FindSection(%q) found nothing", tt.id)
+			}
+			if got := ReplaceSection(page, section, tt.text); got != tt.want {
+				t.Errorf("ReplaceSection() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package goldext
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Slide is one slide of a page in presentation mode
+type Slide struct {
+	Content string // Markdown content of the slide
+	Notes   string // Speaker notes taken from HTML comments
+}
+
+var (
+	slideCommentRegex = regexp.MustCompile(`(?s)<!--(.*?)-->`)
+	slideNotesPrefix  = regexp.MustCompile(`(?i)^\s*notes?:\s*`)
+)
+
+// SplitSlides splits markdown (without frontmatter) into slides. Slides are separated
+// by a --- line preceded by a blank line. Pages without separators get a new slide
+// for every H2 heading. HTML comments are removed from the slides and used as
+// speaker notes, an optional "Notes:" prefix is dropped.
+func SplitSlides(markdown string) []Slide {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	var chunks [][]string
+	if slides := splitSlideLines(lines, isRuleSeparator); len(slides) > 1 {
+		chunks = slides
+	} else {
+		chunks = splitSlideLines(lines, isHeadingSeparator)
+	}
+
+	slides := make([]Slide, 0, len(chunks))
+	for _, chunk := range chunks {
+		content := strings.TrimSpace(strings.Join(chunk, "\n"))
+
+		var notes []string
+		content = replaceOutsideCode(content, func(text string) string {
+			return slideCommentRegex.ReplaceAllStringFunc(text, func(comment string) string {
+				note := strings.TrimSpace(slideCommentRegex.FindStringSubmatch(comment)[1])
+				if note != "" {
+					notes = append(notes, slideNotesPrefix.ReplaceAllString(note, ""))
+				}
+				return ""
+			})
+		})
+
+		content = strings.TrimSpace(content)
+		if content == "" && len(notes) == 0 {
+			continue
+		}
+		slides = append(slides, Slide{Content: content, Notes: strings.Join(notes, "\n\n")})
+	}
+
+	return slides
+}
+
+// splitSlideLines splits lines into chunks wherever isSeparator reports a separator.
+// Lines inside fenced code blocks are never treated as separators.
+func splitSlideLines(lines []string, isSeparator func(line, previous string) (bool, bool)) [][]string {
+	var chunks [][]string
+	var current []string
+
+	inBacktickBlock := false
+	inTildeBlock := false
+	previous := ""
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if !inTildeBlock && strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+		} else if !inBacktickBlock && strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+		} else if !inBacktickBlock && !inTildeBlock {
+			if separator, keep := isSeparator(line, previous); separator {
+				if len(current) > 0 {
+					chunks = append(chunks, current)
+				}
+				current = nil
+				if keep {
+					current = append(current, line)
+				}
+				previous = line
+				continue
+			}
+		}
+
+		current = append(current, line)
+		previous = line
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// isRuleSeparator matches --- lines. The previous line must be blank, otherwise
+// the line is a setext heading underline.
+func isRuleSeparator(line, previous string) (bool, bool) {
+	return strings.TrimSpace(line) == "---" && strings.TrimSpace(previous) == "", false
+}
+
+// isHeadingSeparator starts a new slide at every H2 heading, keeping the heading
+func isHeadingSeparator(line, _ string) (bool, bool) {
+	return strings.HasPrefix(line, "## "), true
+}
+
+// replaceOutsideCode applies fn to the parts of markdown outside fenced code blocks
+func replaceOutsideCode(markdown string, fn func(string) string) string {
+	lines := strings.Split(markdown, "\n")
+
+	var result, pending []string
+	flush := func() {
+		if len(pending) > 0 {
+			result = append(result, strings.Split(fn(strings.Join(pending, "\n")), "\n")...)
+			pending = nil
+		}
+	}
+
+	inBacktickBlock := false
+	inTildeBlock := false
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		if !inTildeBlock && strings.HasPrefix(trimmedLine, "```") {
+			if !inBacktickBlock {
+				flush()
+			}
+			inBacktickBlock = !inBacktickBlock
+			result = append(result, line)
+			continue
+		}
+		if !inBacktickBlock && strings.HasPrefix(trimmedLine, "~~~") {
+			if !inTildeBlock {
+				flush()
+			}
+			inTildeBlock = !inTildeBlock
+			result = append(result, line)
+			continue
+		}
+		if inBacktickBlock || inTildeBlock {
+			result = append(result, line)
+			continue
+		}
+
+		pending = append(pending, line)
+	}
+	flush()
+
+	return strings.Join(result, "\n")
+}
@@ -0,0 +1,63 @@
+package goldext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSlides(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Slide
+	}{
+		{
+			name:  "Rule separators",
+			input: "# Title\n\nIntro\n\n---\n\n## Second\n\nText\n<!-- Notes: say hello -->",
+			expected: []Slide{
+				{Content: "# Title\n\nIntro"},
+				{Content: "## Second\n\nText", Notes: "say hello"},
+			},
+		},
+		{
+			name:  "Heading separators",
+			input: "# Title\n\n## One\n\nA\n\n## Two\n\nB",
+			expected: []Slide{
+				{Content: "# Title"},
+				{Content: "## One\n\nA"},
+				{Content: "## Two\n\nB"},
+			},
+		},
+		{
+			name:  "Setext heading is not a separator",
+			input: "Heading\n---\n\nText",
+			expected: []Slide{
+				{Content: "Heading\n---\n\nText"},
+			},
+		},
+		{
+			name:  "Code blocks are kept intact",
+			input: "A\n\n```\n\n---\n<!-- not a note -->\n## Not a heading\n```",
+			expected: []Slide{
+				{Content: "A\n\n```\n\n---\n<!-- not a note -->\n## Not a heading\n```"},
+			},
+		},
+		{
+			name:  "Multi-line notes",
+			input: "One\n<!--\nfirst\nsecond\n-->\n\n---\n\nTwo",
+			expected: []Slide{
+				{Content: "One", Notes: "first\nsecond"},
+				{Content: "Two"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SplitSlides(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("SplitSlides() = %#v, want %#v", result, tt.expected)
+			}
+		})
+	}
+}
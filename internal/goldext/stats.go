@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"wiki-go/internal/encryption"
 )
 
 // StatsPreprocessor processes stats shortcodes in markdown text
@@ -260,11 +262,10 @@ func getRecentDocuments(dirPath string, count int) []Document {
 
 // extractDocumentTitle extracts the first H1 title from a markdown file
 func extractDocumentTitle(filePath string) string {
-	file, err := os.Open(filePath)
+	file, err := encryption.Open(filePath)
 	if err != nil {
 		return ""
 	}
-	defer file.Close()
 
 	// Read the file line by line
 	scanner := bufio.NewScanner(file)
@@ -0,0 +1,34 @@
+package goldext
+
+import (
+	"log"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/typography"
+)
+
+// Tags whose content keeps the text as written: code, and markup that isn't prose
+var substitutionSkipTags = map[string]bool{
+	"code": true, "pre": true, "kbd": true, "samp": true, "script": true, "style": true,
+	"textarea": true, "svg": true, "math": true,
+}
+
+// ApplySubstitutions makes the text substitutions admins set up in the
+// typography settings, such as (c) to © or fixing the casing of product names,
+// in the rendered HTML. Code blocks and inline code are left as written.
+func ApplySubstitutions(htmlContent string) string {
+	settings, err := typography.Load(config.Cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading typography settings: %v", err)
+		return htmlContent
+	}
+	return applySubstitutions(htmlContent, settings)
+}
+
+// applySubstitutions makes the substitutions of settings in the text of HTML
+func applySubstitutions(htmlContent string, settings *typography.Settings) string {
+	if !settings.HasSubstitutions() {
+		return htmlContent
+	}
+	return walkTextNodes(htmlContent, substitutionSkipTags, settings.Apply)
+}
@@ -0,0 +1,64 @@
+package goldext
+
+import (
+	"testing"
+
+	"wiki-go/internal/typography"
+)
+
+func TestApplySubstitutions(t *testing.T) {
+	settings, err := typography.Parse([]byte(`
+substitutions:
+  - find: "(c)"
+    replace: "©"
+  - find: github
+    replace: GitHub
+    word: true
+    ignore_case: true
+  - find: "R&D"
+    replace: "Research & Development"
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Text is replaced",
+			input:    "<p>(c) 2024, hosted on Github and GITHUB</p>",
+			expected: "<p>© 2024, hosted on GitHub and GitHub</p>",
+		},
+		{
+			name:     "Whole words only",
+			input:    "<p>githubusercontent</p>",
+			expected: "<p>githubusercontent</p>",
+		},
+		{
+			name:     "Escaped text",
+			input:    "<p>R&amp;D</p>",
+			expected: "<p>Research &amp; Development</p>",
+		},
+		{
+			name:     "Code is left alone",
+			input:    "<p>Run <code>github (c)</code></p><pre><code class=\"language-sh\">github\n</code></pre>",
+			expected: "<p>Run <code>github (c)</code></p><pre><code class=\"language-sh\">github\n</code></pre>",
+		},
+		{
+			name:     "Attributes are left alone",
+			input:    `<a href="https://github.com/">github</a>`,
+			expected: `<a href="https://github.com/">GitHub</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applySubstitutions(tt.input, settings); got != tt.expected {
+				t.Errorf("applySubstitutions() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
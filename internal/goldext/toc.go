@@ -8,8 +8,10 @@ import (
 
 // TocPreprocessor adds support for [toc] markers
 // This generates the complete table of contents during markdown processing
-// by scanning for headings in the document and building the TOC HTML structure
-func TocPreprocessor(markdown string, _ string) string {
+// by scanning for headings in the document and building the TOC HTML structure.
+// The _settings.yaml of the directory of the page can limit the levels listed,
+// and add a table of contents to pages without a marker.
+func TocPreprocessor(markdown string, docPath string) string {
 	// Process line by line to handle code blocks properly
 	lines := strings.Split(markdown, "\n")
 	var result []string
@@ -55,11 +57,7 @@ func TocPreprocessor(markdown string, _ string) string {
 			}
 
 			// Remove any inline code or formatting from heading text for ID generation
-			idText := text
-			// Remove inline code
-			idText = regexp.MustCompile("`[^`]+`").ReplaceAllString(idText, "")
-			// Remove links
-			idText = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`).ReplaceAllString(idText, "$1")
+			idText := headingIDText(text)
 
 			// Use existing ID or generate a new one
 			var id string
@@ -94,8 +92,21 @@ func TocPreprocessor(markdown string, _ string) string {
 		}
 	}
 
+	// Leave out the levels deeper than the directory lists
+	settings := DirectorySettings(docPath)
+	listed := headings
+	if settings.TOC.MaxLevel > 0 {
+		listed = listed[:0:0]
+		for _, heading := range headings {
+			if heading.Level <= settings.TOC.MaxLevel {
+				listed = append(listed, heading)
+			}
+		}
+	}
+
 	// Second pass: Replace [toc] markers with generated TOC, but use the updated lines
 	inCodeBlock = false
+	hasMarker := false
 	for _, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 
@@ -115,8 +126,9 @@ func TocPreprocessor(markdown string, _ string) string {
 		// Process [toc] markers outside of code blocks
 		if tocMarker.MatchString(trimmedLine) {
 			// Generate TOC HTML
-			tocHTML := generateTOCHTML(headings)
+			tocHTML := generateTOCHTML(listed)
 			result = append(result, tocHTML)
+			hasMarker = true
 		} else {
 			// Check for inline code sections and preserve them
 			var processedLine string
@@ -127,7 +139,8 @@ func TocPreprocessor(markdown string, _ string) string {
 					// Outside inline code
 					if strings.Contains(segment, "[toc]") {
 						// Replace [toc] with generated TOC HTML
-						segment = strings.ReplaceAll(segment, "[toc]", generateTOCHTML(headings))
+						segment = strings.ReplaceAll(segment, "[toc]", generateTOCHTML(listed))
+						hasMarker = true
 					}
 					processedLine += segment
 				} else {
@@ -144,9 +157,36 @@ func TocPreprocessor(markdown string, _ string) string {
 		}
 	}
 
+	// Pages without a marker get one at the top, below a leading title
+	if auto, minHeadings := settings.AutoTOC(); auto && !hasMarker && len(listed) >= minHeadings {
+		at := 0
+		for at < len(result) && strings.TrimSpace(result[at]) == "" {
+			at++
+		}
+		if at < len(result) && strings.HasPrefix(result[at], "# ") {
+			at++
+		}
+		result = append(result[:at], append([]string{"", generateTOCHTML(listed), ""}, result[at:]...)...)
+	}
+
 	return strings.Join(result, "\n")
 }
 
+// headingIDText returns the text of a heading its id is made from
+func headingIDText(text string) string {
+	// Remove inline code
+	text = regexp.MustCompile("`[^`]+`").ReplaceAllString(text, "")
+	// Remove links
+	text = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`).ReplaceAllString(text, "$1")
+	// Remove the numbers of numbered headings, so their ids stay the same
+	return headingNumberRegex.ReplaceAllString(text, "")
+}
+
+// HeadingID returns the id of a heading with the given text, for links to it
+func HeadingID(text string) string {
+	return makeSlug(text)
+}
+
 // makeSlug creates a URL-friendly slug from text
 func makeSlug(text string) string {
 	// Convert to lowercase
@@ -20,7 +20,6 @@ func TypographyPreprocessor(markdown string, _ string) string {
 		"(tm)": "™", // Trademark symbol
 		"(p)":  "¶", // Paragraph symbol
 		"+-":   "±", // Plus-minus symbol
-		"...":  "…", // Ellipsis
 		"(1/2)":  "½", // One-half
 		"(1/4)":  "¼", // One-quarter
 		"(3/4)":  "¾", // Three-quarters
@@ -0,0 +1,59 @@
+package goldext
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/variables"
+)
+
+var variableRegex = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// VariablesPreprocessor substitutes {{name}} placeholders with the site-wide and
+// per-space variables from the registry. Unknown names and code are left untouched.
+func VariablesPreprocessor(markdown string, docPath string) string {
+	if !strings.Contains(markdown, "{{") {
+		return markdown
+	}
+
+	registry, err := variables.Load(config.Cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading variables: %v", err)
+		return markdown
+	}
+	values := registry.Resolve(docPath)
+	if len(values) == 0 {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	inCodeBlock := false
+
+	for i, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmedLine, "```") || strings.HasPrefix(trimmedLine, "~~~") {
+			inCodeBlock = !inCodeBlock
+			continue
+		}
+		if inCodeBlock || !strings.Contains(line, "{{") {
+			continue
+		}
+
+		// Even segments are outside inline code
+		segments := strings.Split(line, "`")
+		for j := 0; j < len(segments); j += 2 {
+			segments[j] = variableRegex.ReplaceAllStringFunc(segments[j], func(match string) string {
+				name := variableRegex.FindStringSubmatch(match)[1]
+				if value, ok := values[name]; ok {
+					return value
+				}
+				return match
+			})
+		}
+		lines[i] = strings.Join(segments, "`")
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,56 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestVariablesPreprocessor(t *testing.T) {
+	root := t.TempDir()
+	registry := "global:\n  product_version: \"2.4\"\n  support_email: help@example.com\nspaces:\n  guides/api:\n    product_version: \"3.0\"\n"
+	if err := os.WriteFile(filepath.Join(root, "variables.yaml"), []byte(registry), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		name     string
+		input    string
+		docPath  string
+		expected string
+	}{
+		{
+			name:     "Global variable",
+			input:    "Version {{product_version}}, mail {{ support_email }}.",
+			docPath:  "guides/intro",
+			expected: "Version 2.4, mail help@example.com.",
+		},
+		{
+			name:     "Space override",
+			input:    "Version {{product_version}}",
+			docPath:  "guides/api/auth",
+			expected: "Version 3.0",
+		},
+		{
+			name:     "Unknown variable and code",
+			input:    "{{unknown}} `{{product_version}}`\n```\n{{product_version}}\n```",
+			expected: "{{unknown}} `{{product_version}}`\n```\n{{product_version}}\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VariablesPreprocessor(tt.input, tt.docPath)
+			if result != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package goldext
+
+import (
+	"html"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosimple/slug"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/frontmatter"
+)
+
+// wikiLinkRegex matches [[target]], [[target#heading|alias]] and the embeds
+// ![[target]]. Inside tables the pipe is written as \|.
+var wikiLinkRegex = regexp.MustCompile(`(!?)\[\[([^\[\]\n|]*?)\\?(?:\|([^\[\]\n]*))?\]\]`)
+
+// How long the list of pages used to resolve links by name is kept
+const wikiPageIndexTTL = 30 * time.Second
+
+// wikiPageIndex lists the pages of the wiki by the slug of their name
+var wikiPageIndex struct {
+	sync.Mutex
+	dir    string
+	built  time.Time
+	byName map[string][]string
+}
+
+// imageExtensions are the files embedded as images by ![[file]]
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".bmp": true,
+}
+
+// WikiLink is a parsed [[link]] or ![[embed]]
+type WikiLink struct {
+	Embed   bool
+	Target  string // Page name or path, or file name; empty for links within the page
+	Heading string // Heading after #, without a leading ^ block reference
+	Alias   string // Text after |, the size of embedded images
+	Raw     string // The link as written
+}
+
+// Text returns the text shown for the link
+func (l WikiLink) Text() string {
+	if l.Alias != "" && !l.Embed {
+		return l.Alias
+	}
+	if l.Target == "" {
+		return l.Heading
+	}
+	if l.Heading != "" {
+		return l.Target + " > " + l.Heading
+	}
+	return l.Target
+}
+
+// IsFile reports whether the link points at a file rather than a page
+func (l WikiLink) IsFile() bool {
+	ext := strings.ToLower(path.Ext(l.Target))
+	return ext != "" && ext != ".md" && !strings.Contains(ext, " ")
+}
+
+// Anchor returns the fragment of the heading, "" without heading
+func (l WikiLink) Anchor() string {
+	if l.Heading == "" || strings.HasPrefix(l.Heading, "^") {
+		return ""
+	}
+	return "#" + makeSlug(l.Heading)
+}
+
+// ReplaceWikiLinks calls replace for every wikilink outside of code and
+// substitutes its result
+func ReplaceWikiLinks(markdown string, replace func(link WikiLink) string) string {
+	if !strings.Contains(markdown, "[[") {
+		return markdown
+	}
+	sections := splitCodeSections(markdown)
+	for i := range sections {
+		if sections[i].isCode {
+			continue
+		}
+		sections[i].content = wikiLinkRegex.ReplaceAllStringFunc(sections[i].content, func(match string) string {
+			m := wikiLinkRegex.FindStringSubmatch(match)
+			target, heading, _ := strings.Cut(m[2], "#")
+			return replace(WikiLink{
+				Embed:   m[1] == "!",
+				Target:  strings.TrimSpace(target),
+				Heading: strings.TrimSpace(heading),
+				Alias:   strings.TrimSpace(m[3]),
+				Raw:     match,
+			})
+		})
+	}
+	return joinSections(sections)
+}
+
+// WikiLinkPreprocessor turns the [[wikilinks]] and ![[embeds]] of Obsidian
+// into links to the pages and attachments of the wiki, when enabled
+func WikiLinkPreprocessor(markdown string, docPath string) string {
+	if !wikiLinksEnabled(docPath) {
+		return markdown
+	}
+	docPath = strings.Trim(docPath, "/")
+
+	return ReplaceWikiLinks(markdown, func(link WikiLink) string {
+		text := escapeWikiLinkText(link.Text())
+		if link.Target == "" {
+			return "[" + text + "](" + link.Anchor() + ")"
+		}
+		if link.IsFile() {
+			name := path.Base(link.Target)
+			if !link.Embed {
+				return "[" + escapeWikiLinkText(orDefault(link.Alias, name)) + "](" + name + ")"
+			}
+			if !imageExtensions[strings.ToLower(path.Ext(name))] {
+				return "[" + escapeWikiLinkText(name) + "](" + name + ")"
+			}
+			if width, _, _ := strings.Cut(link.Alias, "x"); width != "" && strings.Trim(width, "0123456789") == "" {
+				return `<img src="` + html.EscapeString(resolveLocalPath(name, docPath)) + `" alt="` + html.EscapeString(name) + `" width="` + width + `">`
+			}
+			return "![" + escapeWikiLinkText(name) + "](" + name + ")"
+		}
+
+		pagePath, _ := ResolveWikiLink(link.Target, docPath)
+		return "[" + text + "](/" + pagePath + link.Anchor() + ")"
+	})
+}
+
+// ResolveWikiLink returns the path of the page a [[link]] on the page at
+// docPath points at. Pages are matched by name: a subpage of the current page
+// is preferred, then a sibling, then the page closest to the top. When no page
+// matches, the path a new sibling page with that name would have is returned.
+func ResolveWikiLink(target, docPath string) (string, bool) {
+	segments := strings.Split(strings.Trim(strings.TrimSuffix(target, ".md"), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = wikiSlug(segment)
+	}
+	wanted := strings.Join(segments, "/")
+	name := segments[len(segments)-1]
+
+	parent := path.Dir(docPath)
+	if parent == "." {
+		parent = ""
+	}
+	var best string
+	bestRank := -1
+	for _, candidate := range wikiPagesNamed(name) {
+		if candidate != wanted && !strings.HasSuffix(candidate, "/"+wanted) {
+			continue
+		}
+		candidateParent := path.Dir(candidate)
+		if candidateParent == "." {
+			candidateParent = ""
+		}
+		rank := 2 + strings.Count(candidate, "/")
+		switch {
+		case docPath != "" && candidateParent == docPath:
+			rank = 0
+		case candidateParent == parent:
+			rank = 1
+		}
+		if bestRank == -1 || rank < bestRank || (rank == bestRank && candidate < best) {
+			best, bestRank = candidate, rank
+		}
+	}
+	if bestRank >= 0 {
+		return best, true
+	}
+	if len(segments) > 1 {
+		return wanted, false
+	}
+	return strings.TrimPrefix(parent+"/"+name, "/"), false
+}
+
+// wikiPagesNamed returns the paths of the pages whose name has the given slug
+func wikiPagesNamed(name string) []string {
+	dir := filepath.Join(config.Cfg.Wiki.RootDir, config.Cfg.Wiki.DocumentsDir)
+
+	wikiPageIndex.Lock()
+	defer wikiPageIndex.Unlock()
+
+	if wikiPageIndex.dir != dir || time.Since(wikiPageIndex.built) > wikiPageIndexTTL {
+		byName := make(map[string][]string)
+		filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || entry.Name() != "document.md" {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, filepath.Dir(p))
+			if err != nil || rel == "." {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			key := wikiSlug(path.Base(rel))
+			byName[key] = append(byName[key], rel)
+			return nil
+		})
+		for _, paths := range byName {
+			sort.Strings(paths)
+		}
+		wikiPageIndex.dir = dir
+		wikiPageIndex.built = time.Now()
+		wikiPageIndex.byName = byName
+	}
+	return wikiPageIndex.byName[name]
+}
+
+// ExpandEmbeds replaces the ![[page]] embeds of markdown with the content of
+// the embedded page, or of one of its sections with ![[page#heading]]. load
+// returns the markdown of a page, false when the viewer may not read it.
+// Embeds inside embedded pages stay links.
+func ExpandEmbeds(markdown, docPath string, load func(pagePath string) (string, bool)) string {
+	if !wikiLinksEnabled(docPath) {
+		return markdown
+	}
+	docPath = strings.Trim(docPath, "/")
+
+	return ReplaceWikiLinks(markdown, func(link WikiLink) string {
+		if !link.Embed || link.IsFile() || link.Target == "" {
+			return link.Raw
+		}
+		pagePath, found := ResolveWikiLink(link.Target, docPath)
+		if !found || pagePath == docPath {
+			return link.Raw
+		}
+		content, ok := load(pagePath)
+		if !ok {
+			return link.Raw
+		}
+		_, content, _ = frontmatter.Parse(content)
+		if link.Heading != "" {
+			content = markdownSection(content, link.Heading)
+		}
+		// Nested embeds are shown as links
+		content = strings.ReplaceAll(content, "![[", "[[")
+
+		return "<div class=\"wiki-embed\">\n<div class=\"wiki-embed-source\"><a href=\"/" + html.EscapeString(pagePath+link.Anchor()) + "\">" +
+			html.EscapeString(link.Text()) + "</a></div>\n\n" + strings.TrimSpace(content) + "\n\n</div>"
+	})
+}
+
+// markdownSection returns the part of markdown below the heading, up to the
+// next heading of the same or a higher level
+func markdownSection(markdown, heading string) string {
+	want := makeSlug(heading)
+	lines := strings.Split(markdown, "\n")
+	start, level := -1, 0
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		hashes := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		text := strings.TrimSpace(trimmed[hashes:])
+		if start >= 0 && hashes <= level {
+			return strings.Join(lines[start:i], "\n")
+		}
+		if start < 0 && makeSlug(text) == want {
+			start, level = i, hashes
+		}
+	}
+	if start < 0 {
+		return ""
+	}
+	return strings.Join(lines[start:], "\n")
+}
+
+// wikiSlug turns a page name into the name of its directory
+func wikiSlug(name string) string {
+	if s := slug.Make(name); s != "" {
+		return s
+	}
+	return strings.ToLower(name)
+}
+
+// escapeWikiLinkText escapes the brackets of the text of a markdown link
+func escapeWikiLinkText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
+
+func orDefault(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,85 @@
+package goldext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wiki-go/internal/config"
+)
+
+func TestReplaceWikiLinks(t *testing.T) {
+	var links []WikiLink
+	ReplaceWikiLinks("[[Page#Some Heading|the text]] ![[image.png|200]] [[#Local]] `[[code]]`", func(link WikiLink) string {
+		links = append(links, link)
+		return ""
+	})
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links, got %d: %+v", len(links), links)
+	}
+	if l := links[0]; l.Target != "Page" || l.Heading != "Some Heading" || l.Text() != "the text" || l.Anchor() != "#some-heading" {
+		t.Errorf("Unexpected link: %+v", l)
+	}
+	if l := links[1]; !l.Embed || !l.IsFile() || l.Alias != "200" {
+		t.Errorf("Unexpected embed: %+v", l)
+	}
+	if l := links[2]; l.Target != "" || l.Text() != "Local" {
+		t.Errorf("Unexpected local link: %+v", l)
+	}
+}
+
+func TestWikiLinks(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"guide", "guide/setup", "setup", "notes/deep/setup"} {
+		pageDir := filepath.Join(root, "documents", dir)
+		if err := os.MkdirAll(pageDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := "# Title\n\n## Install\n\nRun it.\n\n## Other\n\nMore."
+		if err := os.WriteFile(filepath.Join(pageDir, "document.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = root
+	config.Cfg.Wiki.DocumentsDir = "documents"
+	config.Cfg.Extensions.Obsidian.Enable = true
+	wikiPageIndex.built = time.Time{}
+	defer func() { config.Cfg = previous }()
+
+	tests := []struct {
+		docPath  string
+		target   string
+		expected string
+		found    bool
+	}{
+		{"guide", "Setup", "guide/setup", true},
+		{"notes/deep/page", "Setup", "notes/deep/setup", true},
+		{"other", "Setup", "setup", true},
+		{"guide/page", "Missing Page", "guide/missing-page", false},
+		{"other", "notes/deep/setup", "notes/deep/setup", true},
+	}
+	for _, tt := range tests {
+		result, found := ResolveWikiLink(tt.target, tt.docPath)
+		if result != tt.expected || found != tt.found {
+			t.Errorf("ResolveWikiLink(%q, %q) = %q, %v; expected %q, %v", tt.target, tt.docPath, result, found, tt.expected, tt.found)
+		}
+	}
+
+	if result := WikiLinkPreprocessor("See [[Setup#Install|install]].", "guide"); result != "See [install](/guide/setup#install)." {
+		t.Errorf("Unexpected link: %s", result)
+	}
+
+	load := func(pagePath string) (string, bool) {
+		data, err := os.ReadFile(filepath.Join(root, "documents", pagePath, "document.md"))
+		return string(data), err == nil
+	}
+	result := ExpandEmbeds("![[Setup#Install]]", "other", load)
+	expected := "<div class=\"wiki-embed\">\n<div class=\"wiki-embed-source\"><a href=\"/setup#install\">Setup &gt; Install</a></div>\n\n## Install\n\nRun it.\n\n</div>"
+	if result != expected {
+		t.Errorf("Unexpected embed:\n%s", result)
+	}
+}
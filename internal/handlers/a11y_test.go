@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/types"
+
+	"golang.org/x/net/html"
+)
+
+// setupPageTest sets up a wiki rendering pages in English
+func setupPageTest(t *testing.T) {
+	t.Helper()
+	previous := cfg
+	cfg = &config.Config{}
+	cfg.Wiki.RootDir = t.TempDir()
+	cfg.Wiki.Title = "Wiki"
+	cfg.Wiki.Language = "en"
+	t.Cleanup(func() { cfg = previous })
+	if err := i18n.Initialize(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// renderPage renders a page with the base template and parses it
+func renderPage(t *testing.T, data *types.PageData) *html.Node {
+	t.Helper()
+	data.Config = cfg
+	if data.Navigation == nil {
+		data.Navigation = &types.NavItem{Title: "Home", Path: "/", IsDir: true, Children: []*types.NavItem{
+			{Title: "Guides", Path: "/guides", IsDir: true},
+		}}
+	}
+	if data.CurrentDir == nil {
+		data.CurrentDir = &types.NavItem{Title: "Setup", Path: "/guides/setup"}
+	}
+	data.Breadcrumbs = []types.BreadcrumbItem{
+		{Title: "Home", Path: "/"},
+		{Title: "Guides", Path: "/guides"},
+		{Title: "Setup", Path: "/guides/setup", IsLast: true},
+	}
+
+	w := httptest.NewRecorder()
+	renderTemplate(w, httptest.NewRequest(http.MethodGet, "/guides/setup", nil), data)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rendering failed with status %d: %s", w.Code, w.Body)
+	}
+	doc, err := html.Parse(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func attribute(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// textContent returns the text below n
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+// describe identifies an element in test failures
+func describe(n *html.Node) string {
+	for _, key := range []string{"id", "class", "name", "href"} {
+		if v, ok := attribute(n, key); ok && v != "" {
+			return fmt.Sprintf("<%s %s=%q>", n.Data, key, v)
+		}
+	}
+	return "<" + n.Data + ">"
+}
+
+// hasName reports whether an element has an accessible name of its own
+func hasName(n *html.Node) bool {
+	for _, key := range []string{"aria-label", "aria-labelledby", "title"} {
+		if v, ok := attribute(n, key); ok && strings.TrimSpace(v) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleElements returns the elements of the document that assistive technology
+// reaches, leaving out hidden subtrees like axe does
+func visibleElements(doc *html.Node) []*html.Node {
+	var elements []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if _, hidden := attribute(n, "hidden"); hidden {
+				return
+			}
+			if v, _ := attribute(n, "aria-hidden"); v == "true" {
+				return
+			}
+			switch n.Data {
+			case "script", "style", "template", "head":
+				return
+			}
+			elements = append(elements, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return elements
+}
+
+// checkAccessibility applies the structural rules of axe that don't need a
+// browser to the document, and returns the violations
+func checkAccessibility(doc *html.Node) []string {
+	var violations []string
+	report := func(rule string, n *html.Node, format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf("%s: %s %s", rule, describe(n), fmt.Sprintf(format, args...)))
+	}
+
+	// Identifiers and labels are counted in hidden parts too, they're shown later
+	ids := make(map[string]int)
+	labelled := make(map[string]bool)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id, ok := attribute(n, "id"); ok {
+				ids[id]++
+			}
+			if n.Data == "label" {
+				if target, ok := attribute(n, "for"); ok {
+					labelled[target] = true
+				}
+			}
+			if n.Data == "html" {
+				if lang, _ := attribute(n, "lang"); strings.TrimSpace(lang) == "" {
+					report("html-has-lang", n, "has no language")
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	for id, count := range ids {
+		if count > 1 {
+			violations = append(violations, fmt.Sprintf("duplicate-id: id %q is used %d times", id, count))
+		}
+	}
+
+	mains := 0
+	for _, n := range visibleElements(doc) {
+		role, _ := attribute(n, "role")
+		if n.Data == "main" || role == "main" {
+			mains++
+		}
+
+		switch {
+		case n.Data == "button" || role == "button":
+			if !hasName(n) && textContent(n) == "" {
+				report("button-name", n, "has no text or label")
+			}
+		case n.Data == "a":
+			if _, ok := attribute(n, "href"); ok && !hasName(n) && textContent(n) == "" {
+				report("link-name", n, "has no text or label")
+			}
+		case n.Data == "img":
+			if _, ok := attribute(n, "alt"); !ok && !hasName(n) {
+				report("image-alt", n, "has no alternative text")
+			}
+		case n.Data == "input" || n.Data == "select" || n.Data == "textarea":
+			switch kind, _ := attribute(n, "type"); kind {
+			case "hidden", "submit", "button", "reset", "image":
+				continue
+			}
+			id, _ := attribute(n, "id")
+			inLabel := false
+			for p := n.Parent; p != nil; p = p.Parent {
+				if p.Type == html.ElementNode && p.Data == "label" {
+					inLabel = true
+				}
+			}
+			if !hasName(n) && !inLabel && (id == "" || !labelled[id]) {
+				report("label", n, "has no label")
+			}
+		case role == "dialog" || role == "alertdialog":
+			if !hasName(n) {
+				report("aria-dialog-name", n, "has no label")
+			}
+		case role == "toolbar" || role == "region" || n.Data == "nav" || role == "navigation" || role == "complementary":
+			if !hasName(n) {
+				report("landmark-unique", n, "isn't labelled, so it can't be told apart from other landmarks")
+			}
+		}
+
+		for _, key := range []string{"aria-controls", "aria-labelledby", "aria-describedby", "for"} {
+			value, ok := attribute(n, key)
+			if !ok {
+				continue
+			}
+			for _, id := range strings.Fields(value) {
+				if ids[id] == 0 {
+					report("aria-valid-attr-value", n, "%s refers to the missing element %q", key, id)
+				}
+			}
+		}
+	}
+	if mains != 1 {
+		violations = append(violations, fmt.Sprintf("landmark-one-main: %d main landmarks, want 1", mains))
+	}
+	return violations
+}
+
+// findElement returns the first element matching match
+func findElement(n *html.Node, match func(n *html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// withClass matches the elements of a class
+func withClass(class string) func(n *html.Node) bool {
+	return func(n *html.Node) bool {
+		classes, _ := attribute(n, "class")
+		for _, c := range strings.Fields(classes) {
+			if c == class {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestPagesPassAccessibilityChecks(t *testing.T) {
+	setupPageTest(t)
+
+	tests := []struct {
+		name string
+		data *types.PageData
+	}{
+		{name: "Guest", data: &types.PageData{Content: "<h1>Setup</h1><p>Install the wiki.</p>"}},
+		{name: "Admin", data: &types.PageData{Content: "<h1>Setup</h1>", IsAuthenticated: true, UserRole: "admin", DocPath: "guides/setup"}},
+		{name: "Directory", data: &types.PageData{DirContent: "<ul><li><a href=\"/guides/setup\">Setup</a></li></ul>", IsAuthenticated: true, UserRole: "editor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, violation := range checkAccessibility(renderPage(t, tt.data)) {
+				t.Error(violation)
+			}
+		})
+	}
+}
+
+func TestLoginPagePassesAccessibilityChecks(t *testing.T) {
+	setupPageTest(t)
+
+	w := httptest.NewRecorder()
+	LoginPageHandler(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	doc, err := html.Parse(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, violation := range checkAccessibility(doc) {
+		t.Error(violation)
+	}
+}
+
+func TestPageLandmarks(t *testing.T) {
+	setupPageTest(t)
+	doc := renderPage(t, &types.PageData{Content: "<h1>Setup</h1>", IsAuthenticated: true, UserRole: "admin"})
+
+	// The skip link comes first and leads to the main content
+	body := findElement(doc, func(n *html.Node) bool { return n.Data == "body" })
+	first := findElement(body, func(n *html.Node) bool { return n.Data == "a" || n.Data == "button" })
+	if first == nil || !withClass("skip-link")(first) {
+		t.Fatalf("first control of the page = %v, want the skip link", first)
+	}
+	href, _ := attribute(first, "href")
+	main := findElement(doc, func(n *html.Node) bool { role, _ := attribute(n, "role"); return role == "main" })
+	if main == nil {
+		t.Fatal("the page has no main landmark")
+	}
+	if id, _ := attribute(main, "id"); href != "#"+id {
+		t.Errorf("skip link leads to %q, want the main landmark #%s", href, id)
+	}
+	if tabindex, _ := attribute(main, "tabindex"); tabindex != "-1" {
+		t.Errorf("main landmark tabindex = %q, want -1 so the skip link can focus it", tabindex)
+	}
+
+	landmarks := []struct {
+		name  string
+		match func(n *html.Node) bool
+		label string
+	}{
+		{name: "sidebar", match: func(n *html.Node) bool { role, _ := attribute(n, "role"); return role == "complementary" }, label: "Sidebar"},
+		{name: "page navigation", match: withClass("nav-items"), label: "Pages"},
+		{name: "breadcrumbs", match: withClass("breadcrumbs-path"), label: "Breadcrumb"},
+		{name: "page toolbar", match: withClass("view-toolbar"), label: "Page actions"},
+		{name: "editing toolbar", match: withClass("edit-toolbar"), label: "Editing actions"},
+		{name: "editor", match: withClass("editor-container"), label: "Markdown editor"},
+		{name: "search", match: func(n *html.Node) bool { role, _ := attribute(n, "role"); return role == "search" }},
+	}
+	for _, landmark := range landmarks {
+		n := findElement(doc, landmark.match)
+		if n == nil {
+			t.Errorf("the page has no %s landmark", landmark.name)
+			continue
+		}
+		if label, _ := attribute(n, "aria-label"); label != landmark.label {
+			t.Errorf("%s label = %q, want %q", landmark.name, label, landmark.label)
+		}
+	}
+
+	// The current page is announced in the breadcrumbs, separators aren't
+	current := findElement(doc, func(n *html.Node) bool { v, _ := attribute(n, "aria-current"); return v == "page" })
+	if current == nil || textContent(current) != "Setup" {
+		t.Errorf("current breadcrumb = %v, want Setup", current)
+	}
+	if separator := findElement(doc, withClass("separator")); separator != nil {
+		if hidden, _ := attribute(separator, "aria-hidden"); hidden != "true" {
+			t.Error("breadcrumb separators are read out")
+		}
+	}
+
+	hamburger := findElement(doc, withClass("hamburger"))
+	if controls, _ := attribute(hamburger, "aria-controls"); controls != "sidebar" {
+		t.Errorf("menu button controls %q, want the sidebar", controls)
+	}
+}
+
+func TestContrastSettingsReachThePage(t *testing.T) {
+	setupPageTest(t)
+
+	tests := []struct {
+		name           string
+		highContrast   bool
+		underlineLinks bool
+	}{
+		{name: "Defaults"},
+		{name: "High contrast", highContrast: true},
+		{name: "Underlined links", underlineLinks: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Wiki.HighContrast = tt.highContrast
+			cfg.Wiki.UnderlineLinks = tt.underlineLinks
+			doc := renderPage(t, &types.PageData{Content: "<p><a href=\"/guides\">Guides</a></p>"})
+
+			meta := findElement(doc, func(n *html.Node) bool {
+				name, _ := attribute(n, "name")
+				return n.Data == "meta" && name == "high-contrast"
+			})
+			if content, _ := attribute(meta, "content"); content != strconv.FormatBool(tt.highContrast) {
+				t.Errorf("high-contrast meta = %q, want %t", content, tt.highContrast)
+			}
+			root := findElement(doc, func(n *html.Node) bool { return n.Data == "html" })
+			if _, underlined := attribute(root, "data-underline-links"); underlined != tt.underlineLinks {
+				t.Errorf("data-underline-links set = %t, want %t", underlined, tt.underlineLinks)
+			}
+
+			stylesheet := findElement(doc, func(n *html.Node) bool {
+				href, _ := attribute(n, "href")
+				return n.Data == "link" && strings.Contains(href, "css/a11y.")
+			})
+			if stylesheet == nil {
+				t.Error("the accessibility stylesheet isn't loaded")
+			}
+			toggle := findElement(doc, withClass("contrast-toggle"))
+			if toggle == nil {
+				t.Fatal("the page has no high contrast toggle")
+			}
+			if pressed, _ := attribute(toggle, "aria-pressed"); pressed != "false" {
+				t.Errorf("contrast toggle aria-pressed = %q, want false until the script applies the setting", pressed)
+			}
+		})
+	}
+}
+
+// cssVariables returns the custom properties set by the rule with the given selector
+func cssVariables(t *testing.T, css, selector string) map[string]string {
+	t.Helper()
+	start := strings.Index(css, selector+" {")
+	if start < 0 {
+		t.Fatalf("no rule for %s", selector)
+	}
+	block := css[start+len(selector)+2:]
+	block = block[:strings.Index(block, "}")]
+
+	variables := make(map[string]string)
+	for _, match := range regexp.MustCompile(`(--[a-z-]+)\s*:\s*([^;]+);`).FindAllStringSubmatch(block, -1) {
+		variables[match[1]] = strings.TrimSpace(match[2])
+	}
+	return variables
+}
+
+// luminance returns the relative luminance of a #rgb or #rrggbb color, as defined by WCAG
+func luminance(t *testing.T, color string) float64 {
+	t.Helper()
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		t.Fatalf("%q isn't a hex color", color)
+	}
+	channel := func(shift uint) float64 {
+		c := float64(value>>shift&0xff) / 255
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(16) + 0.7152*channel(8) + 0.0722*channel(0)
+}
+
+// contrastRatio returns the WCAG contrast ratio of two colors
+func contrastRatio(t *testing.T, a, b string) float64 {
+	t.Helper()
+	la, lb := luminance(t, a), luminance(t, b)
+	return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
+
+func TestHighContrastColors(t *testing.T) {
+	data, err := fs.ReadFile(resources.GetStaticFS(), "css/a11y.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := string(data)
+
+	// Text meets WCAG AAA (7:1) and other visible parts WCAG AA for
+	// non-text contrast (3:1), in light and dark mode
+	pairs := []struct {
+		fg, bg string
+		min    float64
+	}{
+		{"--text-color", "--bg-color", 7},
+		{"--text-color", "--sidebar-bg", 7},
+		{"--text-color", "--hover-bg", 7},
+		{"--text-color", "--code-bg", 7},
+		{"--text-color", "--blockquote-bg", 7},
+		{"--text-muted", "--bg-color", 7},
+		{"--breadcrumb-color", "--bg-color", 7},
+		{"--primary-color", "--bg-color", 7},
+		{"--accent-color", "--bg-color", 7},
+		{"--danger-color", "--bg-color", 7},
+		{"--success-color", "--bg-color", 7},
+		{"--warning-color", "--bg-color", 7},
+		{"--theme-toggle-color", "--theme-toggle-bg", 7},
+		{"--border-color", "--bg-color", 3},
+		{"--focus-color", "--bg-color", 3},
+	}
+	themes := []struct {
+		name     string
+		selector string
+		skipLink string // Text color of the skip link, shown on the primary color
+	}{
+		{name: "Light", selector: `:root[data-contrast="high"]`, skipLink: "#fff"},
+		{name: "Dark", selector: `:root[data-theme="dark"][data-contrast="high"]`, skipLink: "#000"},
+	}
+	for _, theme := range themes {
+		t.Run(theme.name, func(t *testing.T) {
+			variables := cssVariables(t, css, theme.selector)
+			for _, pair := range pairs {
+				fg, bg := variables[pair.fg], variables[pair.bg]
+				if fg == "" || bg == "" {
+					t.Errorf("%s or %s isn't set", pair.fg, pair.bg)
+					continue
+				}
+				if ratio := contrastRatio(t, fg, bg); ratio < pair.min {
+					t.Errorf("%s %s on %s %s has a contrast of %.2f:1, want at least %.0f:1", pair.fg, fg, pair.bg, bg, ratio, pair.min)
+				}
+			}
+			if ratio := contrastRatio(t, theme.skipLink, variables["--primary-color"]); ratio < 7 {
+				t.Errorf("skip link text has a contrast of %.2f:1, want at least 7:1", ratio)
+			}
+		})
+	}
+}
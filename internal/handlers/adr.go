@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"wiki-go/internal/adr"
+	"wiki-go/internal/auth"
+	"wiki-go/internal/utils"
+)
+
+// ADRCreateRequest represents the payload for creating an ADR
+type ADRCreateRequest struct {
+	Title      string `json:"title"`
+	Supersedes int    `json:"supersedes,omitempty"` // Optional ADR number replaced by the new one
+}
+
+// ADRStatusRequest represents the payload for changing an ADR status
+type ADRStatusRequest struct {
+	Status       string `json:"status"`
+	SupersededBy int    `json:"superseded_by,omitempty"`
+}
+
+// adrStore returns the ADR store for the configured directory
+func adrStore() *adr.Store {
+	dir := utils.SanitizePath(cfg.Wiki.ADRDir)
+	if dir == "" {
+		dir = "adr"
+	}
+	return &adr.Store{
+		DocumentsDir: filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir),
+		Dir:          dir,
+	}
+}
+
+// writeADRs versions and saves modified ADRs, then refreshes the index page
+func writeADRs(store *adr.Store, records []*adr.Record) error {
+	for _, record := range records {
+		utils.SaveVersion(cfg.Wiki.RootDir, "documents/"+store.WikiPath(record), store.DocPath(record), cfg.Wiki.MaxVersions)
+	}
+	if err := store.Write(records); err != nil {
+		return err
+	}
+	return store.WriteIndex()
+}
+
+// ADRHandler lists ADRs (GET) and creates new ones (POST) on /api/adr
+func ADRHandler(w http.ResponseWriter, r *http.Request) {
+	store := adrStore()
+
+	switch r.Method {
+	case http.MethodGet:
+		records, err := store.List()
+		if err != nil {
+			sendJSONError(w, "Failed to list ADRs", http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"adrs":    records,
+		})
+
+	case http.MethodPost:
+		var req ADRCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		author := ""
+		if session := auth.GetSession(r); session != nil {
+			author = session.Username
+		}
+
+		record, err := store.Create(req.Title, author)
+		if err != nil {
+			sendJSONError(w, "Failed to create ADR", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Mark the previous decision as superseded and cross-link both records
+		if req.Supersedes != 0 {
+			changed, err := store.SetStatus(req.Supersedes, adr.StatusSuperseded, record.Number)
+			if err != nil {
+				sendJSONError(w, "ADR created but superseding failed", http.StatusBadRequest, err.Error())
+				return
+			}
+			if err := writeADRs(store, changed); err != nil {
+				sendJSONError(w, "Failed to save ADRs", http.StatusInternalServerError, err.Error())
+				return
+			}
+		} else if err := store.WriteIndex(); err != nil {
+			log.Printf("Error updating ADR index: %v", err)
+		}
+
+		log.Printf("Created %s", record.Label())
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "ADR created successfully",
+			"adr":     record,
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// ADRStatusHandler changes the status of an ADR (/api/adr/{number}/status)
+func ADRStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/adr/"), "/")
+	if !strings.HasSuffix(rest, "/status") {
+		sendJSONError(w, "Not found", http.StatusNotFound, "")
+		return
+	}
+	number, err := strconv.Atoi(strings.TrimSuffix(rest, "/status"))
+	if err != nil {
+		sendJSONError(w, "Invalid ADR number", http.StatusBadRequest, "")
+		return
+	}
+
+	var req ADRStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	store := adrStore()
+	changed, err := store.SetStatus(number, req.Status, req.SupersededBy)
+	if err != nil {
+		sendJSONError(w, "Failed to update ADR status", http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := writeADRs(store, changed); err != nil {
+		sendJSONError(w, "Failed to save ADRs", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "ADR status updated",
+		"adr":     changed[0],
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/semantic"
+)
+
+// Number of requests shown in the AI log of the admin panel
+const aiLogLimit = 200
+
+// AIRequest asks for a writing action on the text or the title of a page
+type AIRequest struct {
+	Action string `json:"action"`
+	Text   string `json:"text"`
+	Title  string `json:"title"`
+	Path   string `json:"path"`
+}
+
+// AIHandler handles POST /api/ai, running a writing action with the
+// configured model and returning its answer for the editor to insert
+func AIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !ai.Enabled(cfg) {
+		sendJSONError(w, "AI writing actions are not enabled", http.StatusNotFound, "")
+		return
+	}
+
+	maxInput := int64(cfg.Extensions.AI.MaxInputKB) * 1024
+	if maxInput <= 0 {
+		maxInput = 64 * 1024
+	}
+	var req AIRequest
+	// The limit leaves room for the JSON around the text
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxInput+4096)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	action, ok := ai.Actions[req.Action]
+	if !ok {
+		sendJSONError(w, "Unknown action", http.StatusBadRequest, req.Action)
+		return
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !canEditPath(r, "/"+docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return
+	}
+	// Encrypted pages are kept from leaving the wiki
+	if encryption.Encrypted(documentFile(docPath)) {
+		sendJSONError(w, "Pages of encrypted directories can't be sent to the AI service", http.StatusForbidden, "")
+		return
+	}
+
+	input := strings.TrimSpace(req.Text)
+	if action.Title {
+		input = strings.TrimSpace(req.Title)
+	}
+	if input == "" {
+		sendJSONError(w, "Nothing to send", http.StatusBadRequest, "")
+		return
+	}
+	if int64(len(input)) > maxInput {
+		sendJSONError(w, "The text is too long", http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Select a part of the page of up to %d KB", maxInput/1024))
+		return
+	}
+
+	client := ai.NewClient(cfg)
+	entry := ai.Entry{
+		Time:       time.Now(),
+		Action:     action.Name,
+		Page:       "/" + docPath,
+		Model:      client.Model,
+		InputChars: utf8.RuneCountInString(input),
+	}
+	if session := auth.GetSession(r); session != nil {
+		entry.User = session.Username
+	}
+
+	completion, err := client.Run(action.Name, input)
+	entry.DurationMS = time.Since(entry.Time).Milliseconds()
+	entry.OutputChars = utf8.RuneCountInString(completion.Text)
+	entry.PromptTokens = completion.PromptTokens
+	entry.CompletionTokens = completion.CompletionTokens
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if logErr := ai.Log(cfg.Wiki.RootDir, entry); logErr != nil {
+		log.Printf("Error logging AI request: %v", logErr)
+	}
+	if err != nil {
+		log.Printf("Error running AI action %s for %s: %v", action.Name, entry.User, err)
+		sendJSONError(w, "The AI service failed", http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"action":  action.Name,
+		"result":  completion.Text,
+	})
+}
+
+// AILogHandler handles GET /api/ai/log, returning the latest AI requests
+func AILogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	entries, err := ai.Recent(cfg.Wiki.RootDir, r.URL.Query().Get("user"), aiLogLimit)
+	if err != nil {
+		sendJSONError(w, "Failed to read the AI log", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"entries": entries,
+	})
+}
+
+// AskRequest is a question about the wiki
+type AskRequest struct {
+	Question string `json:"question"`
+}
+
+// Longest question accepted, in characters
+const maxQuestion = 1000
+
+// AskHandler handles POST /api/ask, answering a question from the pages the
+// user may read, with the sections the answer cites
+func AskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !semantic.Enabled(cfg) {
+		sendJSONError(w, "Asking the wiki is not enabled", http.StatusNotFound, "")
+		return
+	}
+	// Every question is paid for with the AI service, so visitors log in first
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "Log in to ask the wiki")
+		return
+	}
+
+	var req AskRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+	question := strings.TrimSpace(req.Question)
+	if question == "" || utf8.RuneCountInString(question) > maxQuestion {
+		sendJSONError(w, "Invalid question", http.StatusBadRequest, fmt.Sprintf("Ask a question of up to %d characters", maxQuestion))
+		return
+	}
+
+	start := time.Now()
+	answer, err := semantic.Ask(cfg, question, func(path string) bool {
+		return listedPath(r, path)
+	})
+	entry := ai.Entry{
+		Time:             start,
+		User:             session.Username,
+		Action:           "ask",
+		Model:            cfg.Extensions.AI.Model,
+		InputChars:       utf8.RuneCountInString(question),
+		OutputChars:      utf8.RuneCountInString(answer.Text),
+		PromptTokens:     answer.Completion.PromptTokens,
+		CompletionTokens: answer.Completion.CompletionTokens,
+		DurationMS:       time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if logErr := ai.Log(cfg.Wiki.RootDir, entry); logErr != nil {
+		log.Printf("Error logging AI request: %v", logErr)
+	}
+	if err != nil {
+		log.Printf("Error answering a question for %s: %v", session.Username, err)
+		sendJSONError(w, "The AI service failed", http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"answer":  answer.Text,
+		"sources": answer.Sources,
+	})
+}
+
+// SemanticIndexHandler handles GET /api/ai/index, the state of the semantic
+// index, and POST to update it right away
+func SemanticIndexHandler(w http.ResponseWriter, r *http.Request) {
+	if !semantic.Enabled(cfg) {
+		sendJSONError(w, "Semantic search is not enabled", http.StatusNotFound, "")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if _, err := semantic.Sync(cfg); err != nil {
+			sendJSONError(w, "Failed to update the index", http.StatusBadGateway, err.Error())
+			return
+		}
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"index":   semantic.CurrentStatus(cfg),
+	})
+}
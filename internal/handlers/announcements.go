@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"wiki-go/internal/announcements"
+	"wiki-go/internal/auth"
+	"wiki-go/internal/types"
+	"wiki-go/internal/utils"
+)
+
+// DismissRequest is the body of POST /api/announcements/dismiss
+type DismissRequest struct {
+	ID string `json:"id"`
+}
+
+// pageAnnouncements returns the banners shown above the page at path, without
+// those the current user dismissed. Visitors without an account dismiss them
+// in their browser.
+func pageAnnouncements(r *http.Request, path string) []types.Announcement {
+	var dismissed []string
+	if session := auth.GetSession(r); session != nil {
+		var err error
+		if dismissed, err = announcements.Dismissed(cfg.Wiki.RootDir, session.Username); err != nil {
+			log.Printf("Error loading the announcements %s dismissed: %v", session.Username, err)
+		}
+	}
+	current, err := announcements.Current(cfg.Wiki.RootDir, path, time.Now(), dismissed)
+	if err != nil {
+		log.Printf("Error loading the announcements: %v", err)
+		return nil
+	}
+
+	banners := make([]types.Announcement, 0, len(current))
+	for _, a := range current {
+		banners = append(banners, types.Announcement{
+			ID:          a.ID,
+			Level:       a.Level,
+			HTML:        template.HTML(utils.RenderMarkdown(a.Message)),
+			Dismissible: a.Dismissible,
+		})
+	}
+	return banners
+}
+
+// AnnouncementsHandler handles /api/settings/announcements: GET lists the
+// announcements, POST creates or updates one and DELETE ?id= removes one
+func AnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		all, err := announcements.List(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load announcements", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":       true,
+			"announcements": all,
+		})
+
+	case http.MethodPost:
+		var req announcements.Announcement
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		user := ""
+		if session := auth.GetSession(r); session != nil {
+			user = session.Username
+		}
+		saved, err := announcements.Save(cfg.Wiki.RootDir, req, user)
+		if errors.Is(err, announcements.ErrNotFound) {
+			sendJSONError(w, "Announcement not found", http.StatusNotFound, "")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to save the announcement", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":      true,
+			"announcement": saved,
+		})
+
+	case http.MethodDelete:
+		err := announcements.Delete(cfg.Wiki.RootDir, r.URL.Query().Get("id"))
+		if errors.Is(err, announcements.ErrNotFound) {
+			sendJSONError(w, "Announcement not found", http.StatusNotFound, "")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to delete the announcement", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// DismissAnnouncementHandler handles POST /api/announcements/dismiss, which
+// hides an announcement from the current user on all their devices
+func DismissAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	var req DismissRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+	err := announcements.Dismiss(cfg.Wiki.RootDir, session.Username, req.ID)
+	switch {
+	case errors.Is(err, announcements.ErrNotFound):
+		sendJSONError(w, "Announcement not found", http.StatusNotFound, "")
+		return
+	case errors.Is(err, announcements.ErrNotDismissible):
+		sendJSONError(w, "This announcement can't be dismissed", http.StatusBadRequest, "")
+		return
+	case err != nil:
+		sendJSONError(w, "Failed to dismiss the announcement", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/archive"
+	"wiki-go/internal/auth"
+	"wiki-go/internal/types"
+)
+
+// includeArchivedCookie is set by the sidebar toggle listing archived pages
+const includeArchivedCookie = "include_archived"
+
+// ArchiveRequest is the body of POST /api/archive
+type ArchiveRequest struct {
+	Path     string `json:"path"`
+	Archived bool   `json:"archived"`
+}
+
+// includeArchived reports whether the current user asked to list archived pages
+// in navigation and search
+func includeArchived(r *http.Request) bool {
+	cookie, err := r.Cookie(includeArchivedCookie)
+	return err == nil && cookie.Value == "1"
+}
+
+// archivedEntry returns the entry archiving the page at path, if it is archived
+func archivedEntry(path string) (archive.Entry, bool) {
+	list, err := archive.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading the archived pages: %v", err)
+		return archive.Entry{}, false
+	}
+	return list.Archived(path)
+}
+
+// pageArchive returns the archive banner of the page at path, nil when it isn't
+// archived
+func pageArchive(path string) *types.ArchiveInfo {
+	entry, archived := archivedEntry(path)
+	if !archived {
+		return nil
+	}
+	return &types.ArchiveInfo{
+		Path:      "/" + entry.Path,
+		By:        entry.By,
+		At:        entry.At,
+		Inherited: entry.Path != archive.Clean(path),
+	}
+}
+
+// listingFilter returns navigationFilter, leaving out archived pages as well
+// unless the user included them. Pages archived along with the page at current
+// stay listed, so the insides of an archived directory can still be browsed.
+// It returns nil when everything is listed.
+func listingFilter(r *http.Request, current string) func(path string) bool {
+	allowed := navigationFilter(r)
+	if includeArchived(r) {
+		return allowed
+	}
+	list, err := archive.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading the archived pages: %v", err)
+		return allowed
+	}
+	if list.Empty() {
+		return allowed
+	}
+
+	current = archive.Clean(current)
+	return func(path string) bool {
+		if allowed != nil && !allowed(path) {
+			return false
+		}
+		entry, archived := list.Archived(path)
+		return !archived || current == entry.Path || strings.HasPrefix(current, entry.Path+"/")
+	}
+}
+
+// ArchiveHandler handles /api/archive. GET tells whether the page at ?path= is
+// archived, POST archives or unarchives a page. Archiving a page archives the
+// pages below it as well.
+func ArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		docPath := archive.Clean(r.URL.Query().Get("path"))
+		if !canViewPath(r, docPath) {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		info := pageArchive(docPath)
+		response := map[string]interface{}{
+			"success":   true,
+			"archived":  info != nil,
+			"canChange": docPath != "" && editAllowed(r, docPath) && (info == nil || !info.Inherited),
+		}
+		if info != nil {
+			response["path"] = info.Path
+			response["by"] = info.By
+			response["at"] = info.At.Format(time.RFC3339)
+			response["inherited"] = info.Inherited
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		var req ArchiveRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		docPath := archive.Clean(req.Path)
+		if docPath == "" {
+			sendJSONError(w, "The home page cannot be archived", http.StatusBadRequest, "")
+			return
+		}
+		if !canViewPath(r, docPath) {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		info, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(docPath)))
+		if err != nil || !info.IsDir() {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		if !editAllowed(r, docPath) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+			return
+		}
+		if current := pageArchive(docPath); current != nil && current.Inherited {
+			sendJSONError(w, "The page is archived along with "+current.Path, http.StatusConflict, "Unarchive that page instead")
+			return
+		}
+
+		user := ""
+		if session := auth.GetSession(r); session != nil {
+			user = session.Username
+		}
+		if err := archive.Set(cfg.Wiki.RootDir, docPath, user, req.Archived); err != nil {
+			sendJSONError(w, "Failed to save the archived pages", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"path":     "/" + docPath,
+			"archived": req.Archived,
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
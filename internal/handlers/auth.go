@@ -15,7 +15,10 @@ import (
 	"wiki-go/internal/crypto"
 	"wiki-go/internal/resources"
 	"wiki-go/internal/i18n"
+	"wiki-go/internal/netaccess"
 	"wiki-go/internal/roles"
+	"wiki-go/internal/secheaders"
+	"wiki-go/internal/store"
 	"wiki-go/internal/version"
 )
 
@@ -30,6 +33,13 @@ var loginBan *ban.BanList
 
 // clientIP extracts the real client IP address, considering proxy headers.
 func clientIP(r *http.Request) string {
+	// With trusted proxies configured, only they may report the client address
+	if rules, err := netaccess.Load(cfg.Wiki.RootDir); err == nil && rules.HasTrustedProxies() {
+		if client := rules.Client(r); client.IP != nil {
+			return client.IP.String()
+		}
+	}
+
 	// Prioritise common proxy headers
 	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
 		// X-Forwarded-For may contain multiple IPs, the first is the client
@@ -115,6 +125,46 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		loginBan.Clear(ip) // successful login resets failures / ban
 	}
 
+	// Users with passkeys confirm password logins with one of them
+	passkeyOptions, passkeySetupRequired, err := requirePasskey(req.Username, role, req.KeepLoggedIn)
+	if err != nil {
+		log.Printf("Error checking the passkeys of %s: %v", req.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to check passkeys",
+		})
+		return
+	}
+	if passkeyOptions != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":         false,
+			"passkeyRequired": true,
+			"options":         passkeyOptions,
+			"message":         "Confirm the login with your passkey",
+		})
+		return
+	}
+	if passkeySetupRequired {
+		// The admin only gets a session once they registered a passkey
+		if err := startPasskeySetup(w, req.Username, req.KeepLoggedIn); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "Failed to start the passkey setup",
+			})
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":              false,
+			"passkeySetupRequired": true,
+			"message":              "Admin accounts must use a passkey, register one to finish logging in",
+		})
+		return
+	}
+
 	// Create session
 	if err := auth.CreateSession(w, req.Username, role, req.KeepLoggedIn, cfg); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -177,11 +227,13 @@ func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare the data for the template
 	data := struct {
-		Config *config.Config
-		Theme  string
+		Config   *config.Config
+		Theme    string
+		CSPNonce string
 	}{
-		Config: cfg,
-		Theme:  "light", // Default theme
+		Config:   cfg,
+		Theme:    "light", // Default theme
+		CSPNonce: secheaders.Nonce(r),
 	}
 
 	// Get theme from cookie if available
@@ -197,6 +249,8 @@ func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 		"getVersion": func() string {
 			return version.Version
 		},
+		"asset":   assetURL,
+		"textDir": i18n.Direction,
 	}
 
 	// Get and execute login template with translation function
@@ -264,6 +318,10 @@ func InitLoginBan(cfg *config.Config) {
 	if err != nil {
 		log.Printf("Warning: failed to initialise login ban list: %v", err)
 	} else {
+		// Share attempts between instances when the store supports it
+		if backend, ok := store.Current.(ban.Backend); ok {
+			bl.SetBackend(backend)
+		}
 		loginBan = bl
 	}
 }
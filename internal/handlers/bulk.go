@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/archive"
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/jobs"
+	"wiki-go/internal/permalinks"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/semantic"
+	"wiki-go/internal/utils"
+)
+
+// Most pages changed by a single bulk operation
+const maxBulkPages = 1000
+
+// Bulk operations on pages
+const (
+	bulkMove        = "move"
+	bulkDelete      = "delete"
+	bulkTag         = "tag"
+	bulkPermissions = "permissions"
+)
+
+// BulkRequest is an operation applied to many pages in a background job
+type BulkRequest struct {
+	Action     string   `json:"action"` // move, delete, tag or permissions
+	Paths      []string `json:"paths"`
+	Target     string   `json:"target"`     // Directory the pages are moved into
+	AddTags    []string `json:"addTags"`    // Tags added to the pages
+	RemoveTags []string `json:"removeTags"` // Tags removed from the pages
+	View       []string `json:"view"`       // Permission lists set on the pages, nil to inherit
+	Edit       []string `json:"edit"`
+}
+
+// bulkJob is the payload of a jobBulkPages job
+type bulkJob struct {
+	BulkRequest
+	Actor string `json:"actor"`
+}
+
+// BulkResult reports the outcome of a bulk operation for one page
+type BulkResult struct {
+	Path    string `json:"path"`
+	NewPath string `json:"newPath,omitempty"` // Location of a moved page
+	Error   string `json:"error,omitempty"`
+}
+
+// runBulkJob applies a bulk operation page by page. Failures are recorded in
+// the report instead of failing the job, so the pages already changed are not
+// changed again by a retry.
+func runBulkJob(payload json.RawMessage, report jobs.Reporter) error {
+	var job bulkJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	results := make([]BulkResult, 0, len(job.Paths))
+	for i, docPath := range job.Paths {
+		result := BulkResult{Path: "/" + docPath}
+		var err error
+		switch job.Action {
+		case bulkMove:
+			var newPath string
+			newPath, err = movePageFiles(docPath, job.Target)
+			result.NewPath = "/" + newPath
+		case bulkDelete:
+			err = deletePageFiles(docPath)
+		case bulkTag:
+			err = tagPage(docPath, job.AddTags, job.RemoveTags)
+		case bulkPermissions:
+			err = permissions.SetRule(cfg.Wiki.RootDir, permissions.Rule{Path: docPath, View: job.View, Edit: job.Edit})
+		default:
+			err = fmt.Errorf("unknown action %q", job.Action)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.NewPath = ""
+		}
+		results = append(results, result)
+		report.Progress(i+1, len(job.Paths))
+	}
+
+	if job.Action == bulkMove || job.Action == bulkDelete {
+		utils.InvalidateNavigation()
+	}
+	semantic.Changed()
+	log.Printf("Bulk %s of %d pages by %s finished", job.Action, len(job.Paths), job.Actor)
+	return report.Result(results)
+}
+
+// movePageFiles moves a page with its versions and comments into the target
+// directory and returns its new path
+func movePageFiles(docPath, target string) (string, error) {
+	newPath := filepath.ToSlash(filepath.Join(target, filepath.Base(docPath)))
+	if newPath == docPath {
+		return "", errors.New("the page is already in this directory")
+	}
+	if strings.HasPrefix(target+"/", docPath+"/") {
+		return "", errors.New("a page cannot be moved into itself")
+	}
+
+	documentDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	source := filepath.Join(documentDir, docPath)
+	destination := filepath.Join(documentDir, newPath)
+	if _, err := os.Stat(source); err != nil {
+		return "", errors.New("page not found")
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return "", errors.New("a page already exists at the target location")
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(source, destination); err != nil {
+		return "", err
+	}
+
+	for _, dir := range []string{filepath.Join("versions", "documents"), "comments"} {
+		from := filepath.Join(cfg.Wiki.RootDir, dir, docPath)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		to := filepath.Join(cfg.Wiki.RootDir, dir, newPath)
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			log.Printf("Warning: Failed to create %s: %v", filepath.Dir(to), err)
+			continue
+		}
+		if err := os.Rename(from, to); err != nil {
+			log.Printf("Warning: Failed to move %s: %v", from, err)
+		}
+	}
+	syncEncryption(newPath)
+	movePageState(docPath, newPath)
+	return newPath, nil
+}
+
+// movePageState keeps the archived pages and permalinks of a moved page and of
+// its subpages, pointing them at their new place
+func movePageState(from, to string) {
+	if err := archive.Rename(cfg.Wiki.RootDir, from, to); err != nil {
+		log.Printf("Warning: Failed to move archived pages: %v", err)
+	}
+	if err := permalinks.Rename(cfg.Wiki.RootDir, from, to); err != nil {
+		log.Printf("Warning: Failed to move permalinks: %v", err)
+	}
+}
+
+// deletePageFiles deletes a page, its subpages, versions and comments
+func deletePageFiles(docPath string) error {
+	fullPath := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath)
+	if _, err := os.Stat(fullPath); err != nil {
+		return errors.New("page not found")
+	}
+	if err := os.RemoveAll(fullPath); err != nil {
+		return err
+	}
+
+	for _, dir := range []string{filepath.Join("versions", "documents"), "comments"} {
+		path := filepath.Join(cfg.Wiki.RootDir, dir, docPath)
+		if err := os.RemoveAll(path); err != nil {
+			log.Printf("Warning: Failed to delete %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// tagPage adds and removes tags in the frontmatter of a page
+func tagPage(docPath string, add, remove []string) error {
+	docFile := filepath.Join(pageDir(cfg, docPath), "document.md")
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return errors.New("page not found")
+	}
+
+	metadata, _, _ := frontmatter.Parse(string(content))
+	removed := make(map[string]bool, len(remove))
+	for _, tag := range remove {
+		removed[tag] = true
+	}
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range append(append([]string{}, metadata.Tags...), add...) {
+		if !removed[tag] && !seen[tag] {
+			tags = append(tags, tag)
+			seen[tag] = true
+		}
+	}
+
+	updated, err := frontmatter.SetField(string(content), "tags", tags)
+	if err != nil {
+		return err
+	}
+	if updated == string(content) {
+		return nil
+	}
+	return encryption.WriteFile(docFile, []byte(updated), 0644)
+}
+
+// cleanTags trims the tags and drops empty ones
+func cleanTags(tags []string) []string {
+	cleaned := []string{}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+// BulkHandler handles POST /api/bulk, checking an operation on many pages and
+// queueing it as a background job
+func BulkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	session := auth.GetSession(r)
+
+	switch req.Action {
+	case bulkMove:
+		req.Target = cleanPath(req.Target)
+		if req.Target == "." {
+			req.Target = ""
+		}
+		if !canEditPath(r, req.Target) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to move pages there")
+			return
+		}
+	case bulkDelete:
+	case bulkTag:
+		req.AddTags = cleanTags(req.AddTags)
+		req.RemoveTags = cleanTags(req.RemoveTags)
+		if len(req.AddTags) == 0 && len(req.RemoveTags) == 0 {
+			sendJSONError(w, "No tags given", http.StatusBadRequest, "")
+			return
+		}
+	case bulkPermissions:
+		if session.Role != roles.RoleAdmin {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "Only admins can change permissions")
+			return
+		}
+	default:
+		sendJSONError(w, "Unknown action", http.StatusBadRequest, req.Action)
+		return
+	}
+
+	if len(req.Paths) == 0 || len(req.Paths) > maxBulkPages {
+		sendJSONError(w, "Invalid page selection", http.StatusBadRequest, fmt.Sprintf("Select between 1 and %d pages", maxBulkPages))
+		return
+	}
+	paths := make([]string, 0, len(req.Paths))
+	seen := make(map[string]bool)
+	for _, path := range req.Paths {
+		docPath := cleanPath(path)
+		if docPath == "" || docPath == "." {
+			sendJSONError(w, "The home page cannot be changed in bulk", http.StatusBadRequest, "")
+			return
+		}
+		if !canEditPath(r, docPath) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to change /"+docPath)
+			return
+		}
+		if !seen[docPath] {
+			paths = append(paths, docPath)
+			seen[docPath] = true
+		}
+	}
+	req.Paths = paths
+
+	id, err := jobs.Enqueue(jobBulkPages, bulkJob{BulkRequest: req, Actor: session.Username})
+	if err != nil {
+		sendJSONError(w, "Failed to queue bulk operation", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"total":   len(paths),
+	})
+}
+
+// BulkStatusHandler handles GET /api/bulk/status?id=, the progress and report
+// of a bulk operation. Only the user who started it and admins can read it.
+func BulkStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	job, ok := jobs.Get(r.URL.Query().Get("id"))
+	var payload bulkJob
+	if ok && job.Type == jobBulkPages {
+		ok = json.Unmarshal(job.Payload, &payload) == nil
+	} else {
+		ok = false
+	}
+	session := auth.GetSession(r)
+	if !ok || (payload.Actor != session.Username && session.Role != roles.RoleAdmin) {
+		sendJSONError(w, "Bulk operation not found", http.StatusNotFound, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"status":    job.Status,
+		"done":      job.Done,
+		"total":     len(payload.Paths),
+		"results":   job.Result,
+		"lastError": job.LastError,
+	})
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/calendar"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/review"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/signing"
+	"wiki-go/internal/utils"
+)
+
+// calendarTokenPurpose tells calendar tokens apart from other signed tokens
+const calendarTokenPurpose = "calendar"
+
+// calendarTokenLifetime is how long calendar tokens work, after which the
+// link has to be copied again
+const calendarTokenLifetime = 365 * 24 * time.Hour
+
+// calendarClaims let the calendar app of a user read the feed without a login.
+// The role of the user is looked up on each request, so removed users lose it,
+// and tokens of an older generation were revoked by the user.
+type calendarClaims struct {
+	signing.Claims
+	User       string `json:"n"`
+	Generation int    `json:"g,omitempty"`
+}
+
+// calendarSubject returns the user whose pages a feed request lists: the owner
+// of its ?token=, or the logged-in user. Guests read the feeds of public wikis.
+func calendarSubject(r *http.Request) (permissions.Subject, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		var claims calendarClaims
+		if err := signing.Verify(cfg.Wiki.RootDir, token, calendarTokenPurpose, &claims); err != nil {
+			return permissions.Subject{}, false
+		}
+		if generation, err := calendar.TokenGeneration(cfg.Wiki.RootDir, claims.User); err != nil || generation != claims.Generation {
+			return permissions.Subject{}, false
+		}
+		for _, user := range cfg.Users {
+			if user.Username == claims.User {
+				return subjectFor(user.Username, user.Role), true
+			}
+		}
+		return permissions.Subject{}, false
+	}
+	if auth.GetSession(r) == nil && cfg.Wiki.Private {
+		return permissions.Subject{}, false
+	}
+	return requestSubject(r), true
+}
+
+// calendarTags returns the tags of the ?tag= parameters, which may list several
+// separated by commas
+func calendarTags(r *http.Request) []string {
+	return frontmatter.NormalizeTags(r.URL.Query()["tag"])
+}
+
+// calendarEvents returns the events of the pages the subject may list that
+// have one of tags, or of every page when tags is empty: the events and due
+// dates of their frontmatter, their review deadlines and the dates journal
+// pages are named after
+func calendarEvents(r *http.Request, subject permissions.Subject, tags []string) []calendar.Event {
+	loc, err := time.LoadLocation(cfg.Wiki.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	rules := loadPermissions()
+	listed := func(path string) bool {
+		if rules == nil {
+			return subject.Role == roles.RoleAdmin
+		}
+		return rules.Listed(path, subject)
+	}
+	baseURL := getBaseURL(r, cfg)
+	uidHost := r.Host
+	if parsed, err := url.Parse(baseURL); err == nil {
+		uidHost = parsed.Hostname()
+	}
+
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	var events []calendar.Event
+	err = filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != docsDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() != "document.md" {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		pagePath := filepath.ToSlash(rel)
+		if !listed(pagePath) {
+			return nil
+		}
+		if _, archived := archivedEntry(pagePath); archived {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		content, err := encryption.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		metadata, _, _ := frontmatter.Parse(string(content))
+		pageTags := frontmatter.NormalizeTags(metadata.Tags)
+		if len(tags) > 0 && !sharesTag(pageTags, tags) {
+			return nil
+		}
+
+		sum := sha1.Sum([]byte(pagePath))
+		pageID := hex.EncodeToString(sum[:8])
+		title := utils.GetDocumentTitle(filepath.Dir(path))
+		base := calendar.Event{
+			Description: metadata.Description,
+			URL:         baseURL + (&url.URL{Path: "/" + pagePath}).EscapedPath(),
+			Categories:  pageTags,
+			Modified:    info.ModTime(),
+		}
+		add := func(kind, summary string, start, end time.Time, allDay bool) {
+			event := base
+			event.UID = kind + "-" + pageID + "@" + uidHost
+			event.Summary = summary
+			event.Start, event.End, event.AllDay = start, end, allDay
+			events = append(events, event)
+		}
+
+		if metadata.EventDate != "" {
+			if start, allDay, err := calendar.ParseDate(metadata.EventDate, loc); err == nil {
+				end := start.Add(time.Hour)
+				if allDay {
+					end = start.AddDate(0, 0, 1)
+				}
+				if last, _, err := calendar.ParseDate(metadata.EventEnd, loc); metadata.EventEnd != "" && err == nil && !last.Before(start) {
+					end = last
+					if allDay {
+						// Whole-day events end the day after their last one
+						end = time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, loc)
+					}
+				}
+				add("event", title, start, end, allDay)
+			} else {
+				log.Printf("Warning: Invalid event_date of /%s: %v", pagePath, err)
+			}
+		} else if day, ok := calendar.JournalDate(pagePath, loc); ok {
+			add("journal", title, day, day.AddDate(0, 0, 1), true)
+		}
+
+		if metadata.DueDate != "" {
+			if due, allDay, err := calendar.ParseDate(metadata.DueDate, loc); err == nil {
+				end := due.Add(time.Hour)
+				if allDay {
+					end = due.AddDate(0, 0, 1)
+				}
+				add("due", i18n.Translate("calendar.due")+": "+title, due, end, allDay)
+			} else {
+				log.Printf("Warning: Invalid due_date of /%s: %v", pagePath, err)
+			}
+		}
+
+		if status := review.Check(string(content), info.ModTime(), time.Now().In(loc)); status != nil {
+			day := time.Date(status.Due.Year(), status.Due.Month(), status.Due.Day(), 0, 0, 0, 0, loc)
+			add("review", i18n.Translate("calendar.review")+": "+title, day, day.AddDate(0, 0, 1), true)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error scanning pages for the calendar: %v", err)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events
+}
+
+// sharesTag reports whether the page tags include one of tags
+func sharesTag(pageTags, tags []string) bool {
+	for _, tag := range tags {
+		for _, pageTag := range pageTags {
+			if strings.EqualFold(tag, pageTag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CalendarFeedHandler serves /calendar.ics, an iCalendar feed of the events,
+// due dates and review deadlines of the pages the user may list. ?tag= limits
+// it to pages with one of the tags and ?token= carries a calendar token for
+// calendar apps, which can't log in.
+func CalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	subject, ok := calendarSubject(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags := calendarTags(r)
+	name := cfg.Wiki.Title
+	if len(tags) > 0 {
+		name += " - #" + strings.Join(tags, ", #")
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if err := calendar.Write(w, name, calendarEvents(r, subject, tags)); err != nil {
+		log.Printf("Error writing the calendar feed: %v", err)
+	}
+}
+
+// CalendarLinkHandler handles GET /api/calendar/link, which returns the address
+// of the calendar feed to subscribe to, with a calendar token of the user and
+// the tags of ?tag=. POST revokes the tokens of the user issued so far first.
+func CalendarLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	query := url.Values{}
+	session := auth.GetSession(r)
+	if r.Method == http.MethodPost && session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+	if session != nil {
+		var generation int
+		var err error
+		if r.Method == http.MethodPost {
+			generation, err = calendar.RevokeTokens(cfg.Wiki.RootDir, session.Username)
+		} else {
+			generation, err = calendar.TokenGeneration(cfg.Wiki.RootDir, session.Username)
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to read the calendar tokens", http.StatusInternalServerError, err.Error())
+			return
+		}
+		token, err := signing.Sign(cfg.Wiki.RootDir, calendarClaims{
+			Claims:     signing.Claims{Purpose: calendarTokenPurpose, Expires: time.Now().Add(calendarTokenLifetime).Unix()},
+			User:       session.Username,
+			Generation: generation,
+		})
+		if err != nil {
+			sendJSONError(w, "Failed to create the calendar token", http.StatusInternalServerError, err.Error())
+			return
+		}
+		query.Set("token", token)
+	} else if cfg.Wiki.Private {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+	if tags := calendarTags(r); len(tags) > 0 {
+		query.Set("tag", strings.Join(tags, ","))
+	}
+
+	link := getBaseURL(r, cfg) + "/calendar.ics"
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"url":     link,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/breaker"
+	"wiki-go/internal/safehttp"
+)
+
+// castBreaker stops fetching recordings while the sites serving them fail
+var castBreaker = breaker.New("asciinema", breaker.Options{Threshold: 3, Cooldown: 30 * time.Second, MaxConcurrent: 8})
+
+// castPolicy returns the sites and size limit of recordings fetched for pages
+func castPolicy() safehttp.Policy {
+	maxKB := cfg.Extensions.Asciinema.MaxSizeKB
+	if maxKB <= 0 {
+		maxKB = 5120
+	}
+	policy := safehttp.Policy{
+		MaxBytes: int64(maxKB) * 1024,
+		Timeout:  15 * time.Second,
+		Breaker:  castBreaker,
+		Attempts: 2,
+	}
+	for _, host := range strings.Split(cfg.Extensions.Asciinema.Hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			policy.AllowedHosts = append(policy.AllowedHosts, host)
+		}
+	}
+	return policy
+}
+
+// isCast reports whether data is an asciinema recording: the header of the
+// version 2 and 3 formats on its first line, or the single object of version 1
+func isCast(data []byte) bool {
+	line, err := bufio.NewReader(bytes.NewReader(data)).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return false
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if json.Unmarshal(line, &header) != nil {
+		// Version 1 recordings may be formatted over several lines
+		if json.Unmarshal(data, &header) != nil {
+			return false
+		}
+	}
+	return header.Version >= 1 && header.Version <= 3
+}
+
+// CastProxyHandler handles GET /api/cast?url=..., which fetches a terminal
+// recording of one of the configured sites for the player of a page. Browsers
+// can't load them directly, the Content Security Policy only allows the wiki.
+func CastProxyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !auth.RequireAuth(r, cfg) {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	address := strings.TrimSpace(r.URL.Query().Get("url"))
+	if address == "" {
+		sendJSONError(w, "Missing url", http.StatusBadRequest, "")
+		return
+	}
+	status, body, err := castPolicy().Get(address)
+	if errors.Is(err, safehttp.ErrNotAllowed) {
+		sendJSONError(w, "Recordings of this site are not allowed", http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to fetch the recording", http.StatusBadGateway, err.Error())
+		return
+	}
+	if status != http.StatusOK {
+		sendJSONError(w, "Failed to fetch the recording", http.StatusBadGateway, http.StatusText(status))
+		return
+	}
+	if !isCast(body) {
+		sendJSONError(w, "Not an asciinema recording", http.StatusBadGateway, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(body)
+}
@@ -10,6 +10,7 @@ import (
 
 	"wiki-go/internal/auth"
 	"wiki-go/internal/comments"
+	"wiki-go/internal/encryption"
 	"wiki-go/internal/roles"
 	"wiki-go/internal/utils"
 )
@@ -71,7 +72,7 @@ func AddCommentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read document content to check if comments are allowed
-	content, err := os.ReadFile(fullDocPath)
+	content, err := encryption.ReadFile(fullDocPath)
 	if err != nil {
 		sendJSONError(w, "Failed to read document", http.StatusInternalServerError, err.Error())
 		return
@@ -161,7 +162,7 @@ func GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	// Process comments for rendering
 	for i := range commentsList {
 		// Render markdown content with template.HTML
-		commentsList[i].RenderedHTML = template.HTML(utils.RenderMarkdown(commentsList[i].Content))
+		commentsList[i].RenderedHTML = template.HTML(utils.RenderUntrustedMarkdown(commentsList[i].Content))
 		// Format timestamp
 		commentsList[i].FormattedTime = comments.FormatCommentTime(commentsList[i].Timestamp)
 	}
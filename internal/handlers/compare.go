@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/textdiff"
+	"wiki-go/internal/utils"
+)
+
+// Unchanged lines shown around the changes of the source comparison
+const compareContext = 3
+
+// ComparedPage is one of the two pages of a comparison
+type ComparedPage struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+}
+
+// CompareResponse is the comparison of two pages: the changed lines of their
+// markdown and their rendered blocks marked as kept, removed or added
+type CompareResponse struct {
+	Old      ComparedPage    `json:"old"`
+	New      ComparedPage    `json:"new"`
+	Hunks    []textdiff.Hunk `json:"hunks"`
+	Inserted int             `json:"inserted"`
+	Deleted  int             `json:"deleted"`
+	Rendered string          `json:"rendered"`
+}
+
+// comparedSource returns the title and markdown of a page as the user sees it
+func comparedSource(r *http.Request, path string) (ComparedPage, string, bool) {
+	if !canViewPath(r, path) {
+		return ComparedPage{}, "", false
+	}
+	file := filepath.Join(pageDir(cfg, path), "document.md")
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return ComparedPage{}, "", false
+	}
+	content, err := encryption.ReadFile(file)
+	if err != nil {
+		return ComparedPage{}, "", false
+	}
+	page := ComparedPage{Path: "/" + path, Title: cfg.Wiki.Title}
+	if path != "" {
+		page.Title = utils.GetDocumentTitle(pageDir(cfg, path))
+	}
+	return page, goldext.FilterAudience(string(content), requestSubject(r)), true
+}
+
+// markdownBlocks splits markdown into its blocks, separated by blank lines
+// outside of fenced code
+func markdownBlocks(markdown string) []string {
+	var blocks []string
+	var block []string
+	fence := ""
+	for _, line := range textdiff.SplitLines(markdown) {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && trimmed == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, strings.Join(block, "\n"))
+				block = nil
+			}
+			continue
+		}
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+		} else if fence != "" && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			fence = ""
+		}
+		block = append(block, line)
+	}
+	if len(block) > 0 {
+		blocks = append(blocks, strings.Join(block, "\n"))
+	}
+	return blocks
+}
+
+// renderComparison renders the blocks of two pages one by one, marked with
+// whether they are in both, only the old or only the new page
+func renderComparison(oldPath, oldBody, newPath, newBody string) string {
+	var out strings.Builder
+	for _, block := range textdiff.Lines(markdownBlocks(oldBody), markdownBlocks(newBody)) {
+		path := newPath
+		if block.Kind == textdiff.Delete {
+			path = oldPath
+		}
+		out.WriteString(`<div class="compare-block compare-` + string(block.Kind) + `">`)
+		out.Write(utils.RenderMarkdownWithPath(block.Text, path))
+		out.WriteString("</div>\n")
+	}
+	return out.String()
+}
+
+// CompareHandler handles GET /api/compare?old=&new=, the differences between
+// any two pages the user may read, as changed lines of their markdown and as
+// rendered blocks
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	if !query.Has("old") || !query.Has("new") {
+		sendJSONError(w, "Two pages are required", http.StatusBadRequest, "")
+		return
+	}
+	oldPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+query.Get("old"))), "/")
+	newPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+query.Get("new"))), "/")
+	oldPage, oldSource, ok := comparedSource(r, oldPath)
+	if !ok {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "/"+oldPath)
+		return
+	}
+	newPage, newSource, ok := comparedSource(r, newPath)
+	if !ok {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "/"+newPath)
+		return
+	}
+
+	lines := textdiff.Lines(textdiff.SplitLines(oldSource), textdiff.SplitLines(newSource))
+	response := CompareResponse{
+		Old:   oldPage,
+		New:   newPage,
+		Hunks: textdiff.Hunks(lines, compareContext),
+	}
+	if response.Hunks == nil {
+		response.Hunks = []textdiff.Hunk{}
+	}
+	response.Inserted, response.Deleted = textdiff.Changes(lines)
+
+	_, oldBody, _ := frontmatter.Parse(oldSource)
+	_, newBody, _ := frontmatter.Parse(newSource)
+	if !renderLimited(w, func() {
+		response.Rendered = renderComparison(oldPath, oldBody, newPath, newBody)
+	}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
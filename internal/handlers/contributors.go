@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/comments"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/utils"
+)
+
+const (
+	// maxProfileEntries is the number of recent edits, created pages and
+	// comments listed on the profile of a user
+	maxProfileEntries = 50
+	// Months in the contributors report unless asked otherwise, and at most
+	defaultReportMonths = 12
+	maxReportMonths     = 60
+	// Length of the excerpts of comments on profiles
+	commentExcerptLength = 160
+)
+
+// ProfileEdit is an edit listed on the profile of a user
+type ProfileEdit struct {
+	Path    string    `json:"path"`
+	Title   string    `json:"title"`
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary,omitempty"`
+	Minor   bool      `json:"minor,omitempty"`
+}
+
+// ProfileComment is a comment listed on the profile of a user
+type ProfileComment struct {
+	Path    string    `json:"path"`
+	Title   string    `json:"title"`
+	Time    time.Time `json:"time"`
+	Excerpt string    `json:"excerpt"`
+}
+
+// ProfileTotals counts the activity of a user on the pages the viewer may read
+type ProfileTotals struct {
+	Edits    int `json:"edits"`
+	Pages    int `json:"pages"` // Distinct pages edited
+	Created  int `json:"created"`
+	Comments int `json:"comments"`
+}
+
+// UserProfile is the activity of a user: their recent edits, the pages they
+// created and their comments, newest first
+type UserProfile struct {
+	User     string           `json:"user"`
+	Totals   ProfileTotals    `json:"totals"`
+	First    *time.Time       `json:"first,omitempty"` // Their first recorded edit
+	Last     *time.Time       `json:"last,omitempty"`  // Their last recorded edit
+	Edits    []ProfileEdit    `json:"edits"`
+	Created  []ProfileEdit    `json:"created"` // The first edit of each page they created
+	Comments []ProfileComment `json:"comments"`
+}
+
+// profilePage returns the path and title of a page with a versioning path such
+// as "documents/guide/intro", unless the page is gone or hidden from the user
+func profilePage(r *http.Request, relativePath string) (string, string, bool) {
+	path := ""
+	if relativePath != "pages/home" {
+		if !strings.HasPrefix(relativePath, "documents/") {
+			return "", "", false
+		}
+		path = strings.TrimPrefix(relativePath, "documents/")
+	}
+	if !canViewPath(r, path) {
+		return "", "", false
+	}
+	if _, err := os.Stat(documentFile(path)); err != nil {
+		return "", "", false
+	}
+	title := cfg.Wiki.Title
+	if path != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, path))
+	}
+	return "/" + path, title, true
+}
+
+// commentExcerpt shortens a comment to a single line
+func commentExcerpt(content string) string {
+	text := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(text); len(runes) > commentExcerptLength {
+		text = strings.TrimSpace(string(runes[:commentExcerptLength])) + "…"
+	}
+	return text
+}
+
+// ProfileHandler handles GET /api/profile/{user}, the activity of a user on
+// the pages the viewer may read. Without a name it is the activity of the
+// logged in user.
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	user, err := url.PathUnescape(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/profile"), "/"))
+	if err != nil || strings.Contains(user, "/") {
+		sendJSONError(w, "Invalid user name", http.StatusBadRequest, "")
+		return
+	}
+	if user == "" {
+		session := auth.GetSession(r)
+		if session == nil {
+			sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+			return
+		}
+		user = session.Username
+	}
+
+	activity, err := pagestats.UserActivity(cfg.Wiki.RootDir, user)
+	if err != nil {
+		sendJSONError(w, "Failed to load the activity", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	profile := UserProfile{User: user, Edits: []ProfileEdit{}, Created: []ProfileEdit{}, Comments: []ProfileComment{}}
+	for _, a := range activity {
+		if len(a.Edits) == 0 {
+			continue
+		}
+		path, title, ok := profilePage(r, a.Path)
+		if !ok {
+			continue
+		}
+		profile.Totals.Pages++
+		for _, edit := range a.Edits {
+			profile.Edits = append(profile.Edits, ProfileEdit{Path: path, Title: title, Time: edit.Time, Summary: edit.Summary, Minor: edit.Minor})
+		}
+		if a.Created {
+			first := a.Edits[0]
+			profile.Created = append(profile.Created, ProfileEdit{Path: path, Title: title, Time: first.Time, Summary: first.Summary})
+		}
+	}
+	sort.Slice(profile.Edits, func(i, j int) bool { return profile.Edits[i].Time.After(profile.Edits[j].Time) })
+	sort.Slice(profile.Created, func(i, j int) bool { return profile.Created[i].Time.After(profile.Created[j].Time) })
+	profile.Totals.Edits = len(profile.Edits)
+	profile.Totals.Created = len(profile.Created)
+	if n := len(profile.Edits); n > 0 {
+		profile.Last = &profile.Edits[0].Time
+		profile.First = &profile.Edits[n-1].Time
+	}
+
+	authored, err := comments.ByAuthor(user)
+	if err != nil {
+		sendJSONError(w, "Failed to load the comments", http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, comment := range authored {
+		path, title, ok := profilePage(r, "documents/"+comment.DocumentPath)
+		if !ok {
+			continue
+		}
+		profile.Comments = append(profile.Comments, ProfileComment{
+			Path:    path,
+			Title:   title,
+			Time:    time.Unix(comment.TimestampUnix, 0),
+			Excerpt: commentExcerpt(comment.Content),
+		})
+	}
+	sort.Slice(profile.Comments, func(i, j int) bool { return profile.Comments[i].Time.After(profile.Comments[j].Time) })
+	profile.Totals.Comments = len(profile.Comments)
+
+	if len(profile.Edits) > maxProfileEntries {
+		profile.Edits = profile.Edits[:maxProfileEntries]
+	}
+	if len(profile.Created) > maxProfileEntries {
+		profile.Created = profile.Created[:maxProfileEntries]
+	}
+	if len(profile.Comments) > maxProfileEntries {
+		profile.Comments = profile.Comments[:maxProfileEntries]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"profile": profile,
+	})
+}
+
+// ContributorsHandler handles GET /api/settings/contributors?months=, the
+// edits of every user per month over the last months
+func ContributorsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	months := defaultReportMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxReportMonths {
+			sendJSONError(w, "Invalid number of months", http.StatusBadRequest, "Between 1 and "+strconv.Itoa(maxReportMonths))
+			return
+		}
+		months = n
+	}
+
+	report, err := pagestats.Contributors(cfg.Wiki.RootDir, months, time.Now())
+	if err != nil {
+		sendJSONError(w, "Failed to build the report", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
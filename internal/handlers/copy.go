@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/translations"
+)
+
+// CopyRequest duplicates a page, or a directory with its subpages, to a new path
+type CopyRequest struct {
+	SourcePath  string `json:"sourcePath"`
+	TargetPath  string `json:"targetPath"`
+	Subpages    bool   `json:"subpages"`    // Copy the whole directory below the page
+	Attachments bool   `json:"attachments"` // Copy the attached files too
+}
+
+// copyLinkRegex matches the targets of markdown links, reference definitions
+// and HTML href and src attributes that are absolute wiki paths
+var copyLinkRegex = regexp.MustCompile(`(\]\(\s*<?|(?m:^\s{0,3}\[[^\]]+\]:\s*)|\b(?:href|src)=["'])(/[^\s)"'<>#?]*)`)
+
+// copyLinkRewriter returns a function that points the links to copied pages
+// and attachments at the copies. Links to anything that was not copied are kept.
+func copyLinkRewriter(source, target string, req CopyRequest) func(string) string {
+	rewritePath := func(path string) (string, bool) {
+		if rest, ok := strings.CutPrefix(path, "/api/files/"+source+"/"); ok {
+			// Attachments of subpages are only copied with the subpages
+			if req.Attachments && (req.Subpages || !strings.Contains(rest, "/")) {
+				return "/api/files/" + target + "/" + rest, true
+			}
+			return "", false
+		}
+		if path == "/"+source {
+			return "/" + target, true
+		}
+		if rest, ok := strings.CutPrefix(path, "/"+source+"/"); ok && req.Subpages {
+			return "/" + target + "/" + rest, true
+		}
+		return "", false
+	}
+
+	return func(content string) string {
+		return copyLinkRegex.ReplaceAllStringFunc(content, func(match string) string {
+			parts := copyLinkRegex.FindStringSubmatch(match)
+			if newPath, ok := rewritePath(parts[2]); ok {
+				return parts[1] + newPath
+			}
+			return match
+		})
+	}
+}
+
+// copyFile copies a single file, creating its directory
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return encryption.Sync(to)
+}
+
+// CopyDocumentHandler handles POST /api/document/copy, cloning a page and
+// optionally its subpages and attachments. Links between the copied pages are
+// rewritten to point at the copies. Subpages the user may not read are skipped.
+func CopyDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req CopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	source := cleanPath(req.SourcePath)
+	target := cleanPath(req.TargetPath)
+	if source == "" || source == "." {
+		sendJSONError(w, "Cannot copy the home page", http.StatusBadRequest, "")
+		return
+	}
+	if target == "" || target == "." {
+		sendJSONError(w, "Target path is required", http.StatusBadRequest, "")
+		return
+	}
+	if target == source || strings.HasPrefix(target, source+"/") {
+		sendJSONError(w, "A page cannot be copied into itself", http.StatusBadRequest, "")
+		return
+	}
+	if !canViewPath(r, source) || !canEditPath(r, target) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to copy this page there")
+		return
+	}
+
+	documentDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	sourceDir := filepath.Join(documentDir, source)
+	targetDir := filepath.Join(documentDir, target)
+	if _, err := os.Stat(filepath.Join(sourceDir, "document.md")); err != nil {
+		sendJSONError(w, "Source document not found", http.StatusNotFound, "")
+		return
+	}
+	if _, err := os.Stat(targetDir); err == nil {
+		sendJSONError(w, "A document already exists at the target location", http.StatusConflict, "")
+		return
+	}
+
+	rewrite := copyLinkRewriter(source, target, req)
+	session := auth.GetSession(r)
+	summary := "Copied from /" + source
+	var pages, files int
+	err := filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			if !req.Subpages || strings.HasPrefix(entry.Name(), ".") || !canViewPath(r, source+"/"+rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		destination := filepath.Join(targetDir, filepath.FromSlash(rel))
+		_, translation := translations.LanguageOf(entry.Name())
+		if entry.Name() != "document.md" && !translation {
+			if !req.Attachments {
+				return nil
+			}
+			files++
+			return copyFile(path, destination)
+		}
+
+		content, err := encryption.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+			return err
+		}
+		if err := encryption.WriteFile(destination, []byte(rewrite(string(content))), 0644); err != nil {
+			return err
+		}
+		if translation {
+			// Translations are copied with their page, which records the edit
+			return nil
+		}
+		pages++
+
+		docPath := strings.TrimSuffix(target+"/"+strings.TrimSuffix(rel, "document.md"), "/")
+		if err := pagestats.RecordEditSummary(cfg.Wiki.RootDir, pageVersionPath(docPath), session.Username, summary); err != nil {
+			log.Printf("Error recording edit history for %s: %v", docPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		// Leave no half-made copy behind
+		if removeErr := os.RemoveAll(targetDir); removeErr != nil {
+			log.Printf("Warning: Failed to remove partial copy %s: %v", targetDir, removeErr)
+		}
+		sendJSONError(w, "Failed to copy document", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Document copied successfully",
+		"newPath": target,
+		"pages":   pages,
+		"files":   files,
+	})
+}
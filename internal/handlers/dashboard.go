@@ -0,0 +1,467 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/dashboard"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/favorites"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/types"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/watch"
+)
+
+// maxDashboardTags is the number of tags shown by a tag cloud without a limit
+const maxDashboardTags = 50
+
+// DashboardRequest is the body of PUT /api/dashboard
+type DashboardRequest struct {
+	Scope   string             `json:"scope"` // "global" for the layout everybody sees, "user" for the own layout of the user
+	Widgets []dashboard.Widget `json:"widgets"`
+}
+
+// dashboardPage is a page found while scanning the documents for the dashboard
+type dashboardPage struct {
+	path     string // Page path without slashes around it
+	modified time.Time
+	tags     []string
+	draft    bool
+	owners   []string
+	redirect string // Target of redirect pages
+}
+
+// homeLayout returns the dashboard layout shown to the current user and whether
+// it is their own
+func homeLayout(r *http.Request) (dashboard.Layout, bool) {
+	if session := auth.GetSession(r); session != nil && cfg.Wiki.UserDashboards {
+		layout, found, err := dashboard.LoadUser(cfg.Wiki.RootDir, session.Username)
+		if err != nil {
+			log.Printf("Error loading the dashboard of %s: %v", session.Username, err)
+		} else if found {
+			return layout, true
+		}
+	}
+	layout, err := dashboard.LoadGlobal(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading the dashboard: %v", err)
+	}
+	return layout, false
+}
+
+// buildDashboard fills the widgets of layout with the pages the current user may see
+func buildDashboard(r *http.Request, layout dashboard.Layout, personal bool) *types.Dashboard {
+	user := ""
+	if session := auth.GetSession(r); session != nil {
+		user = session.Username
+	}
+	board := &types.Dashboard{
+		HasContent:   layout.HasContent(),
+		Personal:     personal,
+		CanCustomize: user != "" && cfg.Wiki.UserDashboards,
+	}
+
+	// The documents are only scanned when a widget lists them
+	var pages []dashboardPage
+	scanned := false
+	scan := func() []dashboardPage {
+		if !scanned {
+			pages = scanDashboardPages(navigationFilter(r))
+			scanned = true
+		}
+		return pages
+	}
+
+	for _, w := range layout.Widgets {
+		widget := types.DashboardWidget{Type: w.Type, Title: w.Title, Width: w.Width}
+		limit := w.Limit
+		if limit == 0 {
+			limit = dashboard.DefaultLimit
+		}
+		switch w.Type {
+		case dashboard.WidgetRecent:
+			widget.Pages = recentPages(scan(), limit)
+		case dashboard.WidgetDrafts:
+			if user == "" {
+				continue
+			}
+			widget.Pages = draftPages(scan(), user, limit)
+		case dashboard.WidgetWatched:
+			if user == "" {
+				continue
+			}
+			watched, err := watch.Pages(cfg.Wiki.RootDir, user)
+			if err != nil {
+				log.Printf("Error loading the watch list of %s: %v", user, err)
+			}
+			widget.Pages = chosenPages(r, watched, limit)
+		case dashboard.WidgetFavorites:
+			if user == "" {
+				continue
+			}
+			starred, err := favorites.Pages(cfg.Wiki.RootDir, user)
+			if err != nil {
+				log.Printf("Error loading the favorites of %s: %v", user, err)
+			}
+			widget.Pages = chosenPages(r, starred, limit)
+		case dashboard.WidgetPinned:
+			pinned := w.Pages
+			if len(pinned) == 0 {
+				var err error
+				if pinned, err = favorites.Pinned(cfg.Wiki.RootDir); err != nil {
+					log.Printf("Error loading the pinned pages: %v", err)
+				}
+			}
+			widget.Pages = chosenPages(r, pinned, len(pinned))
+		case dashboard.WidgetTags:
+			if w.Limit == 0 {
+				limit = maxDashboardTags
+			}
+			widget.Tags = tagCloud(scan(), limit)
+		}
+		board.Widgets = append(board.Widgets, widget)
+	}
+	return board
+}
+
+// scanDashboardPages lists the pages of the documents directory allowed by the
+// navigation filter, with their frontmatter
+func scanDashboardPages(allowed func(path string) bool) []dashboardPage {
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	var pages []dashboardPage
+	err := filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != docsDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() != "document.md" {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		pagePath := filepath.ToSlash(rel)
+		if allowed != nil && !allowed(pagePath) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		page := dashboardPage{path: pagePath, modified: info.ModTime()}
+		if content, err := encryption.ReadFile(path); err == nil {
+			metadata, _, _ := frontmatter.Parse(string(content))
+			page.tags = frontmatter.NormalizeTags(metadata.Tags)
+			page.draft = metadata.Draft
+			page.owners = metadata.Owners
+			page.redirect = metadata.Redirect
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error scanning pages for the dashboard: %v", err)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].modified.After(pages[j].modified)
+	})
+	return pages
+}
+
+// dashboardEntry describes a page for a widget, with its last edit
+func dashboardEntry(page dashboardPage) types.DashboardPage {
+	entry := types.DashboardPage{
+		Title:    utils.GetDocumentTitle(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(page.path))),
+		Path:     "/" + page.path,
+		Modified: page.modified,
+	}
+	if history, err := pagestats.LoadHistory(cfg.Wiki.RootDir, "documents/"+page.path); err == nil && len(history) > 0 {
+		last := history[len(history)-1]
+		entry.User = last.User
+		entry.Summary = last.Summary
+		entry.Minor = last.Minor
+	}
+	return entry
+}
+
+// recentPages returns the most recently changed pages, newest first
+func recentPages(pages []dashboardPage, limit int) []types.DashboardPage {
+	var list []types.DashboardPage
+	for _, page := range pages {
+		if len(list) == limit {
+			break
+		}
+		list = append(list, dashboardEntry(page))
+	}
+	return list
+}
+
+// draftPages returns the drafts user owns or edited, newest first
+func draftPages(pages []dashboardPage, user string, limit int) []types.DashboardPage {
+	var list []types.DashboardPage
+	for _, page := range pages {
+		if len(list) == limit {
+			break
+		}
+		if !page.draft || !draftBy(page, user) {
+			continue
+		}
+		list = append(list, dashboardEntry(page))
+	}
+	return list
+}
+
+// draftBy reports whether user owns the page or edited it
+func draftBy(page dashboardPage, user string) bool {
+	for _, owner := range page.owners {
+		if owner == user {
+			return true
+		}
+	}
+	history, err := pagestats.LoadHistory(cfg.Wiki.RootDir, "documents/"+page.path)
+	if err != nil {
+		return false
+	}
+	for _, edit := range history {
+		if edit.User == user {
+			return true
+		}
+	}
+	return false
+}
+
+// chosenPages describes the pages at paths the current user may read, in the
+// given order. Pages that were removed are skipped.
+func chosenPages(r *http.Request, paths []string, limit int) []types.DashboardPage {
+	var list []types.DashboardPage
+	for _, path := range paths {
+		if len(list) == limit {
+			break
+		}
+		path = strings.Trim(path, "/")
+		if !canViewPath(r, path) {
+			continue
+		}
+		if path == "" {
+			list = append(list, types.DashboardPage{Title: cfg.Wiki.Title, Path: "/"})
+			continue
+		}
+		dir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(path))
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		entry := types.DashboardPage{Title: utils.GetDocumentTitle(dir), Path: "/" + path}
+		if docInfo, err := os.Stat(filepath.Join(dir, "document.md")); err == nil {
+			entry.Modified = docInfo.ModTime()
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+// tagCloud counts the tags of the pages and returns the limit most used ones,
+// sorted by name
+func tagCloud(pages []dashboardPage, limit int) []types.DashboardTag {
+	counts := make(map[string]int)
+	for _, page := range pages {
+		for _, tag := range page.tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]types.DashboardTag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, types.DashboardTag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	if len(tags) == 0 {
+		return tags
+	}
+
+	// Sizes grow with the use of a tag, from the least to the most used shown
+	most, least := tags[0].Count, tags[len(tags)-1].Count
+	for i := range tags {
+		tags[i].Size = 3
+		if most > least {
+			tags[i].Size = 1 + (tags[i].Count-least)*4/(most-least)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+	return tags
+}
+
+// DashboardHandler handles /api/dashboard. GET returns the global layout and
+// the own layout of the user, PUT saves one of them and DELETE removes the own
+// layout of the user. Only admins change the global layout.
+func DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+	isAdmin := session.Role == roles.RoleAdmin
+
+	switch r.Method {
+	case http.MethodGet:
+		global, err := dashboard.LoadGlobal(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load the dashboard", http.StatusInternalServerError, err.Error())
+			return
+		}
+		response := map[string]interface{}{
+			"success":       true,
+			"global":        global,
+			"types":         dashboard.Types(),
+			"canEditGlobal": isAdmin,
+			"canCustomize":  cfg.Wiki.UserDashboards,
+		}
+		if cfg.Wiki.UserDashboards {
+			own, found, err := dashboard.LoadUser(cfg.Wiki.RootDir, session.Username)
+			if err != nil {
+				sendJSONError(w, "Failed to load the dashboard", http.StatusInternalServerError, err.Error())
+				return
+			}
+			if found {
+				response["user"] = own
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case http.MethodPut:
+		var req DashboardRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		layout := dashboard.Layout{Widgets: req.Widgets}
+		if err := layout.Normalize(); err != nil {
+			sendJSONError(w, "Invalid dashboard", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var err error
+		switch req.Scope {
+		case "global":
+			if !isAdmin {
+				sendJSONError(w, "Only admins can change the dashboard of everybody", http.StatusForbidden, "")
+				return
+			}
+			err = dashboard.SaveGlobal(cfg.Wiki.RootDir, layout)
+		case "user":
+			if !cfg.Wiki.UserDashboards {
+				sendJSONError(w, "Personal dashboards are disabled", http.StatusForbidden, "")
+				return
+			}
+			err = dashboard.SaveUser(cfg.Wiki.RootDir, session.Username, layout)
+		default:
+			sendJSONError(w, "Invalid scope", http.StatusBadRequest, "")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to save the dashboard", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"layout":  layout,
+		})
+	case http.MethodDelete:
+		if err := dashboard.DeleteUser(cfg.Wiki.RootDir, session.Username); err != nil {
+			sendJSONError(w, "Failed to reset the dashboard", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// WatchRequest is the body of POST /api/watch
+type WatchRequest struct {
+	Path  string `json:"path"`
+	Watch bool   `json:"watch"`
+}
+
+// WatchHandler handles /api/watch. GET ?path= tells whether the user watches
+// a page, POST starts or stops watching it.
+func WatchHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	var req WatchRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Path = r.URL.Query().Get("path")
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	path := watch.Clean(req.Path)
+	if strings.Contains(path, "..") || !canViewPath(r, path) {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := watch.Set(cfg.Wiki.RootDir, session.Username, path, req.Watch); err != nil {
+			sendJSONError(w, "Failed to save the watch list", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	watching, err := watch.Watching(cfg.Wiki.RootDir, session.Username, path)
+	if err != nil {
+		sendJSONError(w, "Failed to load the watch list", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"path":     "/" + path,
+		"watching": watching,
+	})
+}
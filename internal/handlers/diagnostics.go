@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/breaker"
+	"wiki-go/internal/diagnostics"
+	"wiki-go/internal/utils"
+)
+
+// maxProfileSeconds bounds the duration of CPU profiles and traces
+const maxProfileSeconds = 60
+
+func init() {
+	diagnostics.Register("login attempts", func() (int, int64) {
+		if loginBan == nil {
+			return 0, 0
+		}
+		return loginBan.Usage()
+	})
+}
+
+// DiagnosticsHandler returns the runtime statistics, the memory used by each part
+// of the wiki, the state of the render pool and of the external services
+func DiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"diagnostics": diagnostics.Collect(),
+		"renders":     utils.RenderPoolStats(),
+		"services":    breaker.Statuses(),
+		"pprof":       cfg.Server.Pprof,
+	})
+}
+
+// renderLimited runs render on a render worker. When the render pool is full it
+// answers with 503 instead and returns false.
+func renderLimited(w http.ResponseWriter, render func()) bool {
+	release, err := utils.AcquireRender()
+	if err != nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "The wiki is busy, please try again in a moment", http.StatusServiceUnavailable)
+		return false
+	}
+	defer release()
+	render()
+	return true
+}
+
+// PprofHandler serves the runtime profiles in the format read by "go tool pprof".
+// It is only available when server.pprof is enabled.
+func PprofHandler(w http.ResponseWriter, r *http.Request) {
+	if !cfg.Server.Pprof {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	switch name {
+	case "":
+		pprofIndex(w)
+	case "profile":
+		seconds := profileSeconds(r, 30)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, "Could not start CPU profile: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, seconds)
+		pprof.StopCPUProfile()
+	case "trace":
+		seconds := profileSeconds(r, 1)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+		if err := trace.Start(w); err != nil {
+			http.Error(w, "Could not start trace: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, seconds)
+		trace.Stop()
+	default:
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			http.NotFound(w, r)
+			return
+		}
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		}
+		profile.WriteTo(w, debug)
+	}
+}
+
+// pprofIndex lists the available profiles
+func pprofIndex(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Profiles</title></head><body><h1>Profiles</h1><ul>")
+	for _, profile := range pprof.Profiles() {
+		name := html.EscapeString(profile.Name())
+		fmt.Fprintf(w, `<li><a href="%s?debug=1">%s</a> (%d)</li>`, name, name, profile.Count())
+	}
+	fmt.Fprint(w, `<li><a href="profile?seconds=30">profile</a>: 30 s CPU profile</li>`)
+	fmt.Fprint(w, `<li><a href="trace?seconds=1">trace</a>: 1 s execution trace</li>`)
+	fmt.Fprint(w, "</ul></body></html>")
+}
+
+// profileSeconds returns the "seconds" query parameter, limited to maxProfileSeconds
+func profileSeconds(r *http.Request, fallback int) int {
+	seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	if seconds > maxProfileSeconds {
+		return maxProfileSeconds
+	}
+	return seconds
+}
+
+// sleep waits for the given number of seconds or until the client goes away
+func sleep(r *http.Request, seconds int) {
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/secheaders"
+	"wiki-go/internal/semantic"
+	"wiki-go/internal/utils"
+)
+
+// Editors used when the configured addresses are empty
+const (
+	defaultDrawioURL     = "https://embed.diagrams.net"
+	defaultExcalidrawCDN = "https://esm.sh"
+)
+
+var (
+	// diagramScriptRegex matches script elements, which previews never need
+	diagramScriptRegex = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	// diagramHandlerRegex matches event handler attributes
+	diagramHandlerRegex = regexp.MustCompile(`(?i)\s+on\w+\s*=\s*("[^"]*"|'[^']*')`)
+	// diagramHrefRegex matches links, of which those to scripts and to data
+	// other than images are removed
+	diagramHrefRegex = regexp.MustCompile(`(?i)((?:xlink:)?href)\s*=\s*("[^"]*"|'[^']*')`)
+	// diagramEmbedRegex matches elements that load other documents
+	diagramEmbedRegex = regexp.MustCompile(`(?i)</?(?:iframe|embed|object)\b[^>]*>`)
+)
+
+// DiagramSettings is the configuration of the diagram editors passed to
+// js/diagram-editor.js
+type DiagramSettings struct {
+	Enabled    bool   `json:"enabled"`
+	DrawioURL  string `json:"drawioUrl"`
+	Excalidraw string `json:"excalidrawUrl"` // Address of the page hosting the Excalidraw editor
+}
+
+// diagramDrawioURL returns the address of the embeddable draw.io editor
+func diagramDrawioURL() string {
+	if address := strings.TrimRight(strings.TrimSpace(cfg.Extensions.Diagrams.DrawioURL), "/"); address != "" {
+		return address
+	}
+	return defaultDrawioURL
+}
+
+// diagramExcalidrawCDN returns the address of the CDN the Excalidraw editor
+// loads its modules from
+func diagramExcalidrawCDN() string {
+	if address := strings.TrimRight(strings.TrimSpace(cfg.Extensions.Diagrams.ExcalidrawCDN), "/"); address != "" {
+		return address
+	}
+	return defaultExcalidrawCDN
+}
+
+// diagramConfig returns the settings of the diagram editors as JSON
+func diagramConfig() string {
+	data, err := json.Marshal(DiagramSettings{
+		Enabled:    cfg.Extensions.Diagrams.Enable,
+		DrawioURL:  diagramDrawioURL(),
+		Excalidraw: "/diagram/excalidraw",
+	})
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// sanitizeDiagramSVG removes scripts, event handlers and links to scripts from
+// the preview of a diagram. Unlike uploaded images, previews keep their
+// foreignObject elements, which hold the labels of draw.io diagrams, and their
+// embedded images; they are only shown as images or with a policy that blocks
+// everything else.
+func sanitizeDiagramSVG(content []byte) []byte {
+	svg := diagramScriptRegex.ReplaceAll(content, nil)
+	svg = diagramHandlerRegex.ReplaceAll(svg, nil)
+	svg = diagramHrefRegex.ReplaceAllFunc(svg, func(match []byte) []byte {
+		parts := diagramHrefRegex.FindSubmatch(match)
+		value := strings.ToLower(strings.TrimSpace(string(parts[2][1 : len(parts[2])-1])))
+		if strings.HasPrefix(value, "javascript:") || (strings.HasPrefix(value, "data:") && !strings.HasPrefix(value, "data:image/")) {
+			return []byte(string(parts[1]) + `=""`)
+		}
+		return match
+	})
+	return diagramEmbedRegex.ReplaceAll(svg, nil)
+}
+
+// validDiagramSource reports whether source looks like a diagram of format:
+// the XML of a draw.io file or the JSON of an Excalidraw scene
+func validDiagramSource(format, source string) bool {
+	switch format {
+	case "drawio":
+		source = strings.TrimSpace(source)
+		return strings.HasPrefix(source, "<mxfile") || strings.HasPrefix(source, "<mxGraphModel")
+	case "excalidraw":
+		var scene struct {
+			Type string `json:"type"`
+		}
+		return json.Unmarshal([]byte(source), &scene) == nil && scene.Type == "excalidraw"
+	}
+	return false
+}
+
+// DiagramSaveHandler handles POST /api/diagram/save, which stores a diagram
+// drawn in the embedded editor as an attachment of a page along with its SVG
+// preview. The files it replaces are kept as attachment versions. With
+// reference set, a new diagram is also added to the end of the page.
+func DiagramSaveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !cfg.Extensions.Diagrams.Enable {
+		sendJSONError(w, "Diagram editing is disabled", http.StatusNotFound, "")
+		return
+	}
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.GetMaxUploadSizeBytes(cfg))
+	var req struct {
+		Path      string `json:"path"`
+		Name      string `json:"name"`
+		Source    string `json:"source"`
+		SVG       string `json:"svg"`
+		Reference bool   `json:"reference"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body or diagram too large. Maximum size is "+config.GetMaxUploadSizeFormatted(cfg)+".", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !editAllowed(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	dir := pageDir(cfg, docPath)
+	docFile := filepath.Join(dir, "document.md")
+	if _, err := os.Stat(docFile); err != nil {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+
+	name := sanitizeFilename(req.Name)
+	format := goldext.DiagramFormat(name)
+	if format == "" || strings.HasPrefix(name, ".") {
+		sendJSONError(w, i18n.Translate("diagram.error_name"), http.StatusBadRequest, "")
+		return
+	}
+	if !validDiagramSource(format, req.Source) {
+		sendJSONError(w, i18n.Translate("diagram.error_source"), http.StatusBadRequest, "")
+		return
+	}
+	if !isSVGContent([]byte(req.SVG)) {
+		sendJSONError(w, i18n.Translate("diagram.error_preview"), http.StatusBadRequest, "")
+		return
+	}
+
+	relativePath, uploadPath := "documents/"+docPath, docPath
+	if docPath == "" {
+		relativePath, uploadPath = "pages/home", "pages/home"
+	}
+	sourceFile := filepath.Join(dir, name)
+	previewFile := sourceFile + goldext.DiagramPreviewSuffix
+	_, err := os.Stat(sourceFile)
+	created := os.IsNotExist(err)
+
+	utils.SaveAttachmentVersion(cfg.Wiki.RootDir, relativePath, sourceFile, cfg.Wiki.MaxVersions)
+	utils.SaveAttachmentVersion(cfg.Wiki.RootDir, relativePath, previewFile, cfg.Wiki.MaxVersions)
+	if err := encryption.WriteFile(sourceFile, []byte(req.Source), 0644); err != nil {
+		sendJSONError(w, "Failed to save the diagram", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := encryption.WriteFile(previewFile, sanitizeDiagramSVG([]byte(req.SVG)), 0644); err != nil {
+		sendJSONError(w, "Failed to save the diagram preview", http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordUpload(cfg, uploadPath, session.Username, name)
+
+	referenced := false
+	if req.Reference && created {
+		if err := appendDiagramReference(docFile, relativePath, name, session.Username); err != nil {
+			log.Printf("Error adding diagram %s to %s: %v", name, relativePath, err)
+		} else {
+			referenced = true
+		}
+	}
+
+	fileURL := "/api/files/" + uploadPath + "/" + name
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"url":        fileURL,
+		"preview":    fileURL + goldext.DiagramPreviewSuffix,
+		"referenced": referenced,
+	})
+}
+
+// appendDiagramReference adds the image of a new diagram to the end of the page
+// in docFile, keeping the previous content as a version
+func appendDiagramReference(docFile, relativePath, name, username string) error {
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(content), "("+name+")") {
+		return nil
+	}
+
+	title := strings.TrimSuffix(name, filepath.Ext(name))
+	updated := strings.TrimRight(string(content), "\n") + "\n\n![" + title + "](" + name + ")\n"
+	utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+	if err := encryption.WriteFile(docFile, []byte(updated), 0644); err != nil {
+		return err
+	}
+	if err := pagestats.RecordEdit(cfg.Wiki.RootDir, relativePath, username); err != nil {
+		log.Printf("Error recording edit history for %s: %v", relativePath, err)
+	}
+	semantic.Changed()
+	return nil
+}
+
+// ExcalidrawEditorHandler serves /diagram/excalidraw, the page the diagram
+// dialog shows in a frame to edit Excalidraw drawings. It loads Excalidraw from
+// the configured CDN and talks to the dialog like the draw.io editor does.
+func ExcalidrawEditorHandler(w http.ResponseWriter, r *http.Request) {
+	if !cfg.Extensions.Diagrams.Enable {
+		http.NotFound(w, r)
+		return
+	}
+	if !auth.RequireRole(r, "editor") {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"asset": assetURL,
+	}
+	tmpl, err := template.New("excalidraw.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/excalidraw.html")
+	if err != nil {
+		http.Error(w, "Error loading Excalidraw template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Language string
+		CDN      string
+	}{
+		Language: cfg.Wiki.Language,
+		CDN:      diagramExcalidrawCDN(),
+	}
+	// Only this page runs the modules of the CDN
+	if origin := secheaders.Origin(data.CDN); origin != "" {
+		secheaders.AllowSources(w, []string{"script-src", "font-src", "connect-src", "img-src"}, origin)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering Excalidraw template: %v", err)
+	}
+}
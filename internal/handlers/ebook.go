@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/epub"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// maxEbookChapters limits the number of pages compiled into one e-book
+const maxEbookChapters = 500
+
+// ebookChapter is a page of the directory tree compiled into an e-book
+type ebookChapter struct {
+	Path    string // Document path, empty for the home page
+	Title   string
+	Depth   int
+	Content template.HTML
+}
+
+// EbookPage holds the data for the e-book view of a directory
+type EbookPage struct {
+	Title    string
+	Config   *config.Config
+	Path     string
+	Chapters []ebookChapter
+}
+
+// ebookRequest is the book assembled by the browser once diagrams and math are rendered
+type ebookRequest struct {
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Chapters []struct {
+		Path  string `json:"path"`
+		XHTML string `json:"xhtml"`
+	} `json:"chapters"`
+}
+
+// ebookMediaTypes are the image formats EPUB readers display
+var ebookMediaTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+var (
+	ebookScriptRegex = regexp.MustCompile(`(?is)<script\b.*?</script>|<script\b[^>]*/>`)
+	ebookImageRegex  = regexp.MustCompile(`<img\b[^>]*>`)
+	ebookSrcRegex    = regexp.MustCompile(`\ssrc="([^"]*)"`)
+	ebookAltRegex    = regexp.MustCompile(`\salt="([^"]*)"`)
+	ebookHrefRegex   = regexp.MustCompile(`(<a\b[^>]*?\shref=")([^"]*)(")`)
+)
+
+// ebookChapters lists the page at root and the pages below it the user may read,
+// in reading order. Siblings with a weight in their frontmatter come first,
+// lowest weight first, followed by the others in alphabetical order.
+func ebookChapters(r *http.Request, root string) []ebookChapter {
+	title := cfg.Wiki.Title
+	if root != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, root))
+	}
+	chapters := []ebookChapter{{Path: root, Title: title}}
+
+	var walk func(docPath string, depth int)
+	walk = func(docPath string, depth int) {
+		dir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+
+		type sibling struct {
+			path   string
+			weight *int
+		}
+		var siblings []sibling
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			childPath := path.Join(docPath, entry.Name())
+			if !canViewPath(r, childPath) {
+				continue
+			}
+			s := sibling{path: childPath}
+			if content, err := encryption.ReadFile(filepath.Join(dir, entry.Name(), "document.md")); err == nil {
+				if meta, _, ok := frontmatter.Parse(string(content)); ok {
+					s.weight = meta.Weight
+				}
+			}
+			siblings = append(siblings, s)
+		}
+		sort.SliceStable(siblings, func(i, j int) bool {
+			a, b := siblings[i].weight, siblings[j].weight
+			if a != nil && b != nil && *a != *b {
+				return *a < *b
+			}
+			if (a == nil) != (b == nil) {
+				return a != nil
+			}
+			return siblings[i].path < siblings[j].path
+		})
+
+		for _, s := range siblings {
+			if len(chapters) >= maxEbookChapters {
+				return
+			}
+			childDir := pageDir(cfg, s.path)
+			if _, err := os.Stat(filepath.Join(childDir, "document.md")); err == nil {
+				chapters = append(chapters, ebookChapter{Path: s.path, Title: utils.GetDocumentTitle(childDir), Depth: depth})
+			}
+			walk(s.path, depth+1)
+		}
+	}
+	walk(root, 1)
+	return chapters
+}
+
+// EbookHandler serves /ebook/{path}, the pages of a directory tree on one page.
+// The browser renders their diagrams and math, then sends them to
+// EbookExportHandler to be bound into an EPUB file.
+func EbookHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	doc, status := loadViewDocument(r, "/ebook", cfg)
+	if status == http.StatusNotFound {
+		NotFoundHandler(w, r, cfg)
+		return
+	} else if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	chapters := ebookChapters(r, doc.Path)
+	if !renderLimited(w, func() {
+		for i := range chapters {
+			content, err := encryption.ReadFile(filepath.Join(pageDir(cfg, chapters[i].Path), "document.md"))
+			if err != nil {
+				continue
+			}
+			visibleContent := goldext.FilterAudience(string(content), requestSubject(r))
+			visibleContent = goldext.ExpandEmbeds(visibleContent, chapters[i].Path, embeddedPageLoader(r))
+			rendered := string(utils.RenderMarkdownWithPath(visibleContent, chapters[i].Path))
+			chapters[i].Content = template.HTML(expandDetails(rendered))
+		}
+	}) {
+		return
+	}
+
+	data := EbookPage{
+		Title:    doc.Title,
+		Config:   cfg,
+		Path:     "/" + doc.Path,
+		Chapters: chapters,
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset":         assetURL,
+		"mermaidScript": mermaidScript,
+		"mermaidConfig": mermaidConfig,
+	}
+
+	tmpl, err := template.New("ebook.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/ebook.html")
+	if err != nil {
+		http.Error(w, "Error loading e-book template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering e-book template: %v", err)
+	}
+}
+
+// EbookExportHandler handles POST /api/ebook?path=, binding the chapters
+// rendered by the e-book view of path into an EPUB file. Only the pages of the
+// tree the user may read are accepted, and only images attached to them are
+// embedded.
+func EbookExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	root := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !auth.RequireAuth(r, cfg) || !canViewPath(r, root) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.GetMaxUploadSizeBytes(cfg))
+	var req ebookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	submitted := make(map[string]string)
+	for _, chapter := range req.Chapters {
+		submitted[chapter.Path] = chapter.XHTML
+	}
+	var chapters []ebookChapter
+	for _, chapter := range ebookChapters(r, root) {
+		if _, ok := submitted[chapter.Path]; ok {
+			chapters = append(chapters, chapter)
+		}
+	}
+	if len(chapters) == 0 {
+		sendJSONError(w, "No chapters", http.StatusBadRequest, "")
+		return
+	}
+
+	book := epub.Book{
+		Title:      strings.TrimSpace(req.Title),
+		Author:     strings.TrimSpace(req.Author),
+		Publisher:  cfg.Wiki.Title,
+		Language:   strings.ReplaceAll(cfg.Wiki.Language, "_", "-"),
+		Stylesheet: ebookStylesheet,
+	}
+	if book.Title == "" {
+		book.Title = chapters[0].Title
+	}
+
+	files := &ebookFiles{r: r, embedded: make(map[string]string)}
+	chapterIndex := make(map[string]int)
+	for i, chapter := range chapters {
+		chapterIndex[chapter.Path] = i
+	}
+	for _, chapter := range chapters {
+		body := ebookScriptRegex.ReplaceAllString(submitted[chapter.Path], "")
+		if err := epub.ValidBody(body); err != nil {
+			sendJSONError(w, "Chapter is not valid XHTML", http.StatusBadRequest, chapter.Path+": "+err.Error())
+			return
+		}
+		body = files.embedImages(body)
+		body = ebookLinks(body, chapterIndex, getBaseURL(r, cfg))
+		book.Chapters = append(book.Chapters, epub.Chapter{Title: chapter.Title, Depth: chapter.Depth, Body: body})
+
+		if info, err := os.Stat(filepath.Join(pageDir(cfg, chapter.Path), "document.md")); err == nil && info.ModTime().After(book.Modified) {
+			book.Modified = info.ModTime()
+		}
+	}
+	book.Images = files.images
+
+	// A cover image attached to the top page is used as the cover of the book
+	for _, name := range []string{"cover.jpg", "cover.jpeg", "cover.png", "cover.svg"} {
+		if data, err := encryption.ReadFile(filepath.Join(pageDir(cfg, root), name)); err == nil {
+			book.Cover = &epub.Image{Name: name, MediaType: ebookMediaTypes[filepath.Ext(name)], Data: data}
+			break
+		}
+	}
+
+	name := path.Base(root)
+	if root == "" {
+		name = "home"
+	}
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.epub"`, name))
+	if err := book.Write(w); err != nil {
+		log.Printf("Error writing e-book of %s: %v", root, err)
+	}
+}
+
+// ebookFiles collects the attachments embedded in an e-book
+type ebookFiles struct {
+	r        *http.Request
+	embedded map[string]string // Address of an attachment to its name in the book
+	images   []epub.Image
+}
+
+// embedImages embeds the attachments shown by images of body. Images from
+// elsewhere become links, as e-books are read offline.
+func (f *ebookFiles) embedImages(body string) string {
+	return ebookImageRegex.ReplaceAllStringFunc(body, func(tag string) string {
+		match := ebookSrcRegex.FindStringSubmatch(tag)
+		if match == nil {
+			return tag
+		}
+		src := html.UnescapeString(match[1])
+		if strings.HasPrefix(src, "data:") {
+			return tag
+		}
+		if name, ok := f.attachment(src); ok {
+			return strings.Replace(tag, match[0], ` src="images/`+name+`"`, 1)
+		}
+
+		alt := src
+		if altMatch := ebookAltRegex.FindStringSubmatch(tag); altMatch != nil && altMatch[1] != "" {
+			alt = html.UnescapeString(altMatch[1])
+		}
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			return `<a href="` + html.EscapeString(src) + `">` + html.EscapeString(alt) + `</a>`
+		}
+		return html.EscapeString(alt)
+	})
+}
+
+// attachment embeds the attachment at a /api/files/ address and returns its
+// name in the book
+func (f *ebookFiles) attachment(src string) (string, bool) {
+	if name, ok := f.embedded[src]; ok {
+		return name, true
+	}
+	if !strings.HasPrefix(src, "/api/files/") {
+		return "", false
+	}
+	filePath, err := url.PathUnescape(strings.SplitN(strings.TrimPrefix(src, "/api/files/"), "?", 2)[0])
+	if err != nil {
+		return "", false
+	}
+	filePath = strings.Trim(path.Clean("/"+filePath), "/")
+	docPath, fileName := path.Split(filePath)
+	docPath = strings.Trim(docPath, "/")
+	if docPath == "pages/home" {
+		docPath = ""
+	}
+	mediaType, ok := ebookMediaTypes[strings.ToLower(path.Ext(fileName))]
+	if !ok || !canViewPath(f.r, docPath) {
+		return "", false
+	}
+	data, err := encryption.ReadFile(filepath.Join(pageDir(cfg, docPath), fileName))
+	if err != nil {
+		return "", false
+	}
+
+	name := fmt.Sprintf("image-%d%s", len(f.images)+1, strings.ToLower(path.Ext(fileName)))
+	f.images = append(f.images, epub.Image{Name: name, MediaType: mediaType, Data: data})
+	f.embedded[src] = name
+	return name, true
+}
+
+// ebookLinks points links to pages of the book to their chapter, and links to
+// other pages of the wiki to the wiki itself
+func ebookLinks(body string, chapterIndex map[string]int, baseURL string) string {
+	return ebookHrefRegex.ReplaceAllStringFunc(body, func(attr string) string {
+		parts := ebookHrefRegex.FindStringSubmatch(attr)
+		href := html.UnescapeString(parts[2])
+		if !strings.HasPrefix(href, "/") || strings.HasPrefix(href, "//") {
+			return attr
+		}
+
+		target, fragment, _ := strings.Cut(href, "#")
+		target = strings.SplitN(target, "?", 2)[0]
+		if decoded, err := url.PathUnescape(target); err == nil {
+			target = decoded
+		}
+		if index, ok := chapterIndex[strings.Trim(target, "/")]; ok {
+			href = epub.ChapterFile(index)
+			if fragment != "" {
+				href += "#" + fragment
+			}
+		} else {
+			href = baseURL + href
+		}
+		return parts[1] + html.EscapeString(href) + parts[3]
+	})
+}
+
+// ebookStylesheet is the style of the chapters, kept simple so readers can
+// apply their own fonts and colors
+const ebookStylesheet = `body { line-height: 1.5; }
+h1, h2, h3, h4 { page-break-after: avoid; }
+pre { white-space: pre-wrap; font-size: 0.85em; }
+code { font-family: monospace; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.2em 0.4em; }
+img, svg { max-width: 100%; height: auto; }
+blockquote { margin-left: 1em; padding-left: 1em; border-left: 3px solid #ccc; }
+.mermaid, .plantuml { text-align: center; page-break-inside: avoid; }
+.heading-anchor, .copy-button, .wiki-form { display: none; }
+.cover { text-align: center; }
+.cover img { max-height: 100%; }
+`
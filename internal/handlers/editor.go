@@ -11,10 +11,18 @@ import (
 	"strings"
 	"time"
 	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/lint"
+	"wiki-go/internal/pagestats"
 	"wiki-go/internal/roles"
+	"wiki-go/internal/semantic"
+	"wiki-go/internal/translations"
 	"wiki-go/internal/utils"
 )
 
+// Longest edit summary kept, in characters
+const maxEditSummary = 500
+
 // SourceHandler handles requests to get the raw markdown content of a page
 func SourceHandler(w http.ResponseWriter, r *http.Request) {
 	// Add cache control headers to prevent caching
@@ -45,6 +53,14 @@ func SourceHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get the path from the URL, removing the /api/source prefix
 	path := strings.TrimPrefix(r.URL.Path, "/api/source")
+	if _, archived := archivedEntry(path); archived {
+		sendJSONError(w, "This page is archived", http.StatusConflict, "Unarchive it to edit it")
+		return
+	}
+	if !canEditPath(r, path) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return
+	}
 
 	var docPath string
 	var dirPath string
@@ -65,8 +81,18 @@ func SourceHandler(w http.ResponseWriter, r *http.Request) {
 		docPath = filepath.Join(dirPath, "document.md")
 	}
 
+	// Translations are edited in their own file, new ones start from the page itself
+	file, lang, ok := variantFile(r, docPath)
+	if !ok {
+		sendJSONError(w, "Invalid language", http.StatusBadRequest, "")
+		return
+	}
+	if lang != "" && variantExists(file) {
+		docPath = file
+	}
+
 	// Read the markdown file
-	content, err := os.ReadFile(docPath)
+	content, err := encryption.ReadFile(docPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Check if the directory exists
@@ -106,6 +132,12 @@ func SourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The revision of the file that will be saved, to notice changes saved by
+	// others meanwhile
+	if docPath == file {
+		w.Header().Set("X-Revision", contentRevision(string(content)))
+	}
+
 	// Reset content type for plain text response
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write(content)
@@ -137,6 +169,14 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get the path from the URL, removing the /api/save prefix
 	path := strings.TrimPrefix(r.URL.Path, "/api/save")
+	if _, archived := archivedEntry(path); archived {
+		sendJSONError(w, "This page is archived", http.StatusConflict, "Unarchive it to edit it")
+		return
+	}
+	if !canEditPath(r, path) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return
+	}
 
 	var docPath string
 	var relativePath string // To store path relative to the documents dir
@@ -159,6 +199,13 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 		docPath = filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, path, "document.md")
 	}
 
+	// Translations are saved to their own file, next to the page
+	file, lang, ok := variantFile(r, docPath)
+	if !ok {
+		sendJSONError(w, "Invalid language", http.StatusBadRequest, "")
+		return
+	}
+
 	// Read the request body (new content)
 	content, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -170,34 +217,61 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
+	edit := editDetails(r, session.Username)
+
+	// Style checks, which refuse the save in block mode
+	var warnings []lint.Issue
+	if mode := lintMode(); mode != lint.ModeOff {
+		warnings = lintPage(filepath.Dir(docPath), string(content))
+		if mode == lint.ModeBlock && len(warnings) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "The document has style problems that must be fixed before saving",
+				"issues":  warnings,
+			})
+			return
+		}
+	}
 
-	// VERSION CONTROL: Save current version before overwriting
-	// Check if the document already exists
-	if _, err := os.Stat(docPath); err == nil && cfg.Wiki.MaxVersions > 0 {
-		// Document exists, read its current content
-		currentContent, err := os.ReadFile(docPath)
-		if err == nil && len(currentContent) > 0 {
-			// Create timestamp for version filename
-			timestamp := time.Now().Format("20060102150405") // Format: yyyymmddhhmmss
-
-			// Create versions directory path that mirrors the document path
-			versionDir := filepath.Join(cfg.Wiki.RootDir, "versions", relativePath)
-
-			// Ensure versions directory exists
-			if err := os.MkdirAll(versionDir, 0755); err == nil {
-				// Create version file path with timestamp
-				versionPath := filepath.Join(versionDir, timestamp+".md")
+	// The frontmatter of the page must match its schemas
+	if lang == "" {
+		if problems := schemaIssues(strings.Trim(path, "/"), string(content)); len(problems) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "The frontmatter doesn't match the schema of this page",
+				"issues":  append(problems, warnings...),
+			})
+			return
+		}
+	}
 
-				// Save the current content as a version
-				_ = os.WriteFile(versionPath, currentContent, 0644) // Ignore error for now
+	// Changes saved by someone else since the editor was opened aren't
+	// overwritten: the editor merges them and saves again
+	unlock, err := lockPage(path)
+	if err != nil {
+		sendJSONError(w, "The page is being saved by someone else", http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer unlock()
+	current, err := encryption.ReadFile(file)
+	if base := r.Header.Get("X-Base-Revision"); base != "" {
+		if err == nil && contentRevision(string(current)) != base {
+			sendSaveConflict(w, string(current), relativePath)
+			return
+		}
+	} else if err == nil {
+		w.Header().Set("Warning", uncheckedSaveWarning)
+	}
 
-				// Log the versioning
-				log.Printf("Created version: %s", versionPath)
+	// The page as it was, to follow the headings the save renames
+	previous, _ := encryption.ReadFile(docPath)
 
-				// Clean up old versions if needed
-				utils.CleanupOldVersions(versionDir, cfg.Wiki.MaxVersions)
-			}
-		}
+	// VERSION CONTROL: Save current version before overwriting. Versions are
+	// kept for the page itself, not for its translations.
+	if lang == "" {
+		utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docPath, cfg.Wiki.MaxVersions)
 	}
 
 	// Create directory if it doesn't exist
@@ -212,7 +286,7 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write the content to the file
-	if err := os.WriteFile(docPath, content, 0644); err != nil {
+	if err := encryption.WriteFile(file, content, 0644); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -221,13 +295,64 @@ func SaveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if lang != "" {
+		// The translation is now up to date with the page it was made from.
+		// Pre-filled by machine translation, it stays marked until confirmed.
+		source, _ := encryption.ReadFile(docPath)
+		machine := r.URL.Query().Get("machine") == "1"
+		if err := translations.Record(cfg.Wiki.RootDir, relativePath, lang, session.Username, source, machine); err != nil {
+			log.Printf("Error recording translation status for %s: %v", relativePath, err)
+		}
+		summary := "Translation (" + lang + ")"
+		if edit.Summary != "" {
+			summary += ": " + edit.Summary
+		}
+		edit.Summary = summary
+	}
+	if err := pagestats.Record(cfg.Wiki.RootDir, relativePath, edit); err != nil {
+		log.Printf("Error recording edit history for %s: %v", relativePath, err)
+	}
+	// Owners and watchers aren't told about minor edits
+	if !edit.Minor {
+		if relativePath == "pages/home" {
+			enqueueOwnerNotification("", session.Username)
+		} else {
+			enqueueOwnerNotification(strings.TrimPrefix(relativePath, "documents/"), session.Username)
+		}
+	}
+	// Other pages are updated under their own locks
+	unlock()
+	var refs SectionRefs
+	if lang == "" {
+		refs = updateSectionRefs(r, path, string(previous), string(content), session.Username)
+	}
+	semantic.Changed()
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Document saved successfully",
+		"success":     true,
+		"message":     "Document saved successfully",
+		"warnings":    warnings,
+		"sectionRefs": refs,
 	})
 }
 
+// editDetails describes a save of the page by user, with the summary of the
+// change and whether it is a minor one from the query parameters of the save
+func editDetails(r *http.Request, user string) pagestats.Edit {
+	query := r.URL.Query()
+	return pagestats.Edit{User: user, Summary: editSummary(query.Get("summary")), Minor: query.Get("minor") == "1"}
+}
+
+// editSummary trims the summary of an edit to the length kept
+func editSummary(summary string) string {
+	summary = strings.TrimSpace(summary)
+	if runes := []rune(summary); len(runes) > maxEditSummary {
+		summary = strings.TrimSpace(string(runes[:maxEditSummary]))
+	}
+	return summary
+}
+
 // CreateDocumentRequest represents the JSON payload for creating a new document
 type CreateDocumentRequest struct {
 	Title string `json:"title"`
@@ -292,6 +417,11 @@ func CreateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEditPath(r, cleanPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to create pages here")
+		return
+	}
+
 	log.Printf("Creating document: Title=%s, Path=%s, CleanPath=%s", req.Title, req.Path, cleanPath)
 
 	// Get the config from the package variable
@@ -336,13 +466,17 @@ func CreateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write to the file
-	err = os.WriteFile(docFile, []byte(content), 0644)
+	err = encryption.WriteFile(docFile, []byte(content), 0644)
 	if err != nil {
 		log.Printf("Error creating document: %v", err)
 		sendJSONError(w, "Failed to create document", http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if err := pagestats.RecordEdit(cfg.Wiki.RootDir, "documents/"+cleanPath, session.Username); err != nil {
+		log.Printf("Error recording edit history for %s: %v", cleanPath, err)
+	}
+
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
@@ -430,6 +564,11 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canEditPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to delete this page")
+		return
+	}
+
 	// Build the file path
 	docPath = filepath.Clean(docPath)
 	documentDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
@@ -508,6 +647,8 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	semantic.Changed()
+
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+)
+
+// embeddedPageLoader returns the loader of the pages embedded with ![[page]],
+// which only returns the pages and content the viewer may read
+func embeddedPageLoader(r *http.Request) func(pagePath string) (string, bool) {
+	return func(pagePath string) (string, bool) {
+		if !canViewPath(r, pagePath) {
+			return "", false
+		}
+		content, err := encryption.ReadFile(filepath.Join(pageDir(cfg, pagePath), "document.md"))
+		if err != nil {
+			return "", false
+		}
+		return goldext.FilterAudience(string(content), requestSubject(r)), true
+	}
+}
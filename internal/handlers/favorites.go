@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/favorites"
+	"wiki-go/internal/types"
+)
+
+// FavoriteRequest is the body of POST /api/favorites
+type FavoriteRequest struct {
+	Path     string `json:"path"`
+	Favorite bool   `json:"favorite"`
+}
+
+// FavoriteEntry is a favorite page listed by GET /api/favorites
+type FavoriteEntry struct {
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+// PinRequest is the body of POST /api/pins
+type PinRequest struct {
+	Path   string `json:"path"`
+	Pinned bool   `json:"pinned"`
+}
+
+// sidebarFavorites returns the favorites of the current user shown in the
+// sidebar, leaving out the pages they may no longer read
+func sidebarFavorites(r *http.Request) []*types.NavItem {
+	session := auth.GetSession(r)
+	if session == nil {
+		return nil
+	}
+	pages, err := favorites.Pages(cfg.Wiki.RootDir, session.Username)
+	if err != nil {
+		log.Printf("Error loading the favorites of %s: %v", session.Username, err)
+		return nil
+	}
+
+	current := strings.Trim(r.URL.Path, "/")
+	var items []*types.NavItem
+	for _, page := range chosenPages(r, pages, len(pages)) {
+		items = append(items, &types.NavItem{
+			Title:    page.Title,
+			Path:     page.Path,
+			IsActive: strings.Trim(page.Path, "/") == current,
+		})
+	}
+	return items
+}
+
+// pinRanks returns the position of every pinned page, keyed by its path
+// without slashes around it
+func pinRanks() map[string]int {
+	pinned, err := favorites.Pinned(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading the pinned pages: %v", err)
+		return nil
+	}
+	ranks := make(map[string]int, len(pinned))
+	for i, page := range pinned {
+		ranks[page] = i
+	}
+	return ranks
+}
+
+// pinnedBefore reports whether the page at a is listed before the one at b:
+// pinned pages come first, in the order they were pinned
+func pinnedBefore(ranks map[string]int, a, b string) bool {
+	rankA, pinnedA := ranks[strings.Trim(a, "/")]
+	rankB, pinnedB := ranks[strings.Trim(b, "/")]
+	if pinnedA && pinnedB {
+		return rankA < rankB
+	}
+	return pinnedA
+}
+
+// sortPinnedItems moves the pinned pages of items to the top, keeping the
+// order of the others. items is sorted in place.
+func sortPinnedItems(items []*types.NavItem, ranks map[string]int) {
+	if len(ranks) == 0 {
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return pinnedBefore(ranks, items[i].Path, items[j].Path)
+	})
+}
+
+// pinNavigation moves the pinned pages to the top of every directory of a
+// sidebar tree returned by utils.NavigationFor
+func pinNavigation(item *types.NavItem, ranks map[string]int) {
+	if item == nil || len(ranks) == 0 {
+		return
+	}
+	sortPinnedItems(item.Children, ranks)
+	for _, child := range item.Children {
+		pinNavigation(child, ranks)
+	}
+}
+
+// FavoritesHandler handles /api/favorites. GET lists the favorites of the user
+// and the pinned pages, POST stars or unstars a page.
+func FavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req FavoriteRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		path := favorites.Clean(req.Path)
+		if strings.Contains(path, "..") || !canViewPath(r, path) {
+			sendJSONError(w, "Page not found", http.StatusNotFound, "")
+			return
+		}
+		if err := favorites.Set(cfg.Wiki.RootDir, session.Username, path, req.Favorite); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, favorites.ErrTooMany) {
+				status = http.StatusBadRequest
+			}
+			sendJSONError(w, "Failed to save the favorites", status, err.Error())
+			return
+		}
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	pages, err := favorites.Pages(cfg.Wiki.RootDir, session.Username)
+	if err != nil {
+		sendJSONError(w, "Failed to load the favorites", http.StatusInternalServerError, err.Error())
+		return
+	}
+	pinned, err := favorites.Pinned(cfg.Wiki.RootDir)
+	if err != nil {
+		sendJSONError(w, "Failed to load the pinned pages", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	list := []FavoriteEntry{}
+	for _, page := range chosenPages(r, pages, len(pages)) {
+		list = append(list, FavoriteEntry{Title: page.Title, Path: page.Path})
+	}
+	visiblePins := []string{}
+	for _, page := range pinned {
+		if canViewPath(r, page) {
+			visiblePins = append(visiblePins, "/"+page)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"favorites": list,
+		"pinned":    visiblePins,
+	})
+}
+
+// PinsHandler handles POST /api/pins, which pins a page to the top of its
+// directory for everybody or unpins it. It is only routed for admins.
+func PinsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	var req PinRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+	path := favorites.Clean(req.Path)
+	if path == "" {
+		sendJSONError(w, "The home page cannot be pinned", http.StatusBadRequest, "")
+		return
+	}
+	if strings.Contains(path, "..") {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return
+	}
+	if req.Pinned {
+		info, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(path)))
+		if err != nil || !info.IsDir() {
+			sendJSONError(w, "Page not found", http.StatusNotFound, "")
+			return
+		}
+	}
+	if err := favorites.SetPinned(cfg.Wiki.RootDir, path, req.Pinned); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, favorites.ErrTooMany) {
+			status = http.StatusBadRequest
+		}
+		sendJSONError(w, "Failed to save the pinned pages", status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"path":    "/" + path,
+		"pinned":  req.Pinned,
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/federation"
+	"wiki-go/internal/secrets"
+)
+
+// FederationPayload is the raw YAML federation settings, or the source to sync now
+type FederationPayload struct {
+	Content string `json:"content"`
+	Source  string `json:"source"`
+}
+
+// FederationSource is a configured source with the outcome of its syncs
+type FederationSource struct {
+	Name   string           `json:"name"`
+	Remote string           `json:"remote"`
+	Target string           `json:"target"`
+	State  federation.State `json:"state"`
+}
+
+// federationJob is the payload of a federation.JobType job
+type federationJob struct {
+	Source string `json:"source"`
+}
+
+// syncFederationSource runs the sync of one source in the background
+func syncFederationSource(payload json.RawMessage) error {
+	var job federationJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	return federation.Sync(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, job.Source)
+}
+
+// syncedSource returns the source a page is pulled from, if any
+func syncedSource(docPath string) (federation.Source, bool) {
+	file, err := federation.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading federation settings: %v", err)
+		return federation.Source{}, false
+	}
+	return file.SourceFor(docPath)
+}
+
+// syncedFrom returns the remote of a synced page, "" for local pages
+func syncedFrom(docPath string) string {
+	if source, ok := syncedSource(docPath); ok {
+		return source.Remote()
+	}
+	return ""
+}
+
+// FederationExportHandler handles GET /api/federation/export?path=, a zip
+// archive of a directory pulled by other wikis. Requests with a bearer token
+// get the directories published with that token, others get what their user
+// may read.
+func FederationExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	docPath := cleanPath(r.URL.Query().Get("path"))
+	if docPath == "." {
+		docPath = ""
+	}
+
+	byToken := false
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		file, err := federation.Load(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load federation settings", http.StatusInternalServerError, err.Error())
+			return
+		}
+		values, err := secrets.Load()
+		if err != nil {
+			sendJSONError(w, "Failed to load secrets", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if _, byToken = file.GrantFor(docPath, token, values); !byToken {
+			sendJSONError(w, "Invalid token", http.StatusUnauthorized, "")
+			return
+		}
+	} else if !auth.RequireAuth(r, cfg) || !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+
+	dir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(docPath))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		sendJSONError(w, "Directory not found", http.StatusNotFound, "")
+		return
+	}
+
+	include := func(rel string) bool {
+		return byToken || canViewPath(r, path.Join(docPath, rel))
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="export.zip"`)
+	if err := federation.WriteArchive(w, dir, include); err != nil {
+		log.Printf("Error exporting /%s: %v", docPath, err)
+	}
+}
+
+// FederationSettingsHandler handles the federation settings: GET reads them with
+// the state of each source, PUT replaces them and POST syncs a source now
+func FederationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := federation.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read federation settings", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		sources := []FederationSource{}
+		if file, err := federation.Load(cfg.Wiki.RootDir); err == nil {
+			states := federation.States(cfg.Wiki.RootDir)
+			for _, source := range file.Sources {
+				sources = append(sources, FederationSource{
+					Name:   source.Name,
+					Remote: source.Remote(),
+					Target: "/" + source.Target,
+					State:  states[source.Name],
+				})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"content": content,
+			"sources": sources,
+		})
+
+	case http.MethodPut, http.MethodPost:
+		var req FederationPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if r.Method == http.MethodPut {
+			if err := federation.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+				sendJSONError(w, "Invalid federation settings", http.StatusBadRequest, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"message": "Federation settings saved successfully",
+			})
+			return
+		}
+
+		file, err := federation.Load(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load federation settings", http.StatusInternalServerError, err.Error())
+			return
+		}
+		if _, ok := file.Source(req.Source); !ok {
+			sendJSONError(w, "Unknown source", http.StatusNotFound, req.Source)
+			return
+		}
+		if err := federation.Enqueue(cfg.Wiki.RootDir, req.Source); err != nil {
+			sendJSONError(w, "Failed to queue sync", http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Sync queued",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
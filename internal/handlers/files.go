@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,7 +15,11 @@ import (
 	"strings"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
 	"wiki-go/internal/i18n"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/translations"
 )
 
 // FileResponse represents the response for file operations
@@ -236,7 +241,7 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Confi
 		savePath := filepath.Join(uploadDir, filename)
 
 		// Write the sanitized SVG directly to the file
-		if err := os.WriteFile(savePath, sanitizedSVG, 0644); err != nil {
+		if err := encryption.WriteFile(savePath, sanitizedSVG, 0644); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(FileResponse{
 				Success: false,
@@ -245,6 +250,8 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Confi
 			return
 		}
 
+		recordUpload(cfg, docPath, session.Username, filename)
+
 		// Create URL path for the file
 		urlPath := filepath.Join("/api/files", docPath, filename)
 		// Replace backslashes with forward slashes for URLs
@@ -277,6 +284,12 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Confi
 
 	// Copy the uploaded file to the destination file
 	_, err = io.Copy(dst, file)
+	if err == nil {
+		err = dst.Close()
+	}
+	if err == nil {
+		err = encryption.Sync(savePath)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(FileResponse{
@@ -286,6 +299,9 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Confi
 		return
 	}
 
+	recordUpload(cfg, docPath, session.Username, filename)
+	enqueueVideoPoster(savePath)
+
 	// Create URL path for the file
 	urlPath := filepath.Join("/api/files", docPath, filename)
 	// Replace backslashes with forward slashes for URLs
@@ -379,8 +395,8 @@ func ListFilesHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 			continue
 		}
 
-		// Skip the document.md file
-		if file.Name() == "document.md" {
+		// Skip the document.md file and its translations
+		if file.Name() == "document.md" || translations.IsVariant(file.Name()) {
 			continue
 		}
 
@@ -501,8 +517,8 @@ func DeleteFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Confi
 		return
 	}
 
-	// Don't allow deleting document.md
-	if filepath.Base(filePath) == "document.md" {
+	// Don't allow deleting document.md or its translations
+	if filepath.Base(filePath) == "document.md" || translations.IsVariant(filepath.Base(filePath)) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(FileResponse{
 			Success: false,
@@ -538,12 +554,6 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 		return
 	}
 
-	// Authentication: Require login if the wiki is private
-	if !auth.RequireAuth(r, cfg) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	// Get the file path from the URL
 	path := strings.TrimPrefix(r.URL.Path, "/api/files/")
 
@@ -554,6 +564,27 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 	path = filepath.Clean(path)
 	path = strings.ReplaceAll(path, "\\", "/")
 
+	// Pages shown with an embed token show their attachments without a login
+	if !embedTokenAllowsFile(r, path) {
+		// Authentication: Require login if the wiki is private
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Files attached to a page are only served to users who can read that page
+		if !strings.HasPrefix(path, "pages/") && !canViewPath(r, filepath.Dir(path)) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	serveAttachment(w, r, cfg, path)
+}
+
+// serveAttachment serves the file at path below /api/files/ once access to it
+// has been checked
+func serveAttachment(w http.ResponseWriter, r *http.Request, cfg *config.Config, path string) {
 	// Determine the full filesystem path to the file
 	var filePath string
 	if strings.HasPrefix(path, "pages/") {
@@ -577,6 +608,17 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 		return
 	}
 
+	// Files of encrypted directories are decrypted in memory
+	var decrypted []byte
+	size := fileInfo.Size()
+	if encryption.Encrypted(filePath) {
+		if decrypted, err = encryption.ReadFile(filePath); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		size = int64(len(decrypted))
+	}
+
 	// Get file extension
 	ext := strings.ToLower(filepath.Ext(filePath))
 
@@ -605,9 +647,14 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 	// This helps prevent serving files that have been tampered with after upload
 	if !cfg.Wiki.DisableFileUploadChecking && config.ShouldVerifyContentType(ext[1:]) { // Remove leading dot with ext[1:]
 		// Open the file to check its content
-		f, err := os.Open(filePath)
-		if err == nil {
-			defer f.Close()
+		var f io.ReadSeeker
+		if decrypted != nil {
+			f = bytes.NewReader(decrypted)
+		} else if file, err := os.Open(filePath); err == nil {
+			defer file.Close()
+			f = file
+		}
+		if f != nil {
 
 			// Read a larger buffer to detect content type
 			buffer := make([]byte, 8192) // Increased buffer size for better detection
@@ -638,7 +685,7 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 
 	// Set content type and other headers
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
 
 	// For SVG files, add security headers to prevent script execution
 	if ext == ".svg" {
@@ -648,15 +695,34 @@ func ServeFileHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 	}
 
-	// For binary files, set content disposition header for download
-	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" && contentType != "text/plain" {
+	// For binary files, set content disposition header for download. Audio and
+	// video are played in the page, which fetches the parts it plays with range
+	// requests
+	if goldext.MediaKind(filePath) != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filepath.Base(filePath)))
+	} else if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" && contentType != "text/plain" {
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(filePath)))
 	}
 
 	// Serve the file
+	if decrypted != nil {
+		http.ServeContent(w, r, filepath.Base(filePath), fileInfo.ModTime(), bytes.NewReader(decrypted))
+		return
+	}
 	http.ServeFile(w, r, filePath)
 }
 
+// recordUpload remembers who uploaded an attachment, for exports of their data
+func recordUpload(cfg *config.Config, docPath, username, filename string) {
+	relativePath := docPath
+	if !strings.HasPrefix(docPath, "pages/") {
+		relativePath = "documents/" + docPath
+	}
+	if err := pagestats.RecordUpload(cfg.Wiki.RootDir, relativePath, username, filename); err != nil {
+		log.Printf("Error recording upload of %s: %v", filename, err)
+	}
+}
+
 // Helper function to sanitize filenames
 func sanitizeFilename(filename string) string {
 	// Remove path information
@@ -729,6 +795,17 @@ func isContentTypeCompatible(detected, expected string, fileContent []byte, file
 		return detected == "application/zip" || detected == "application/x-zip-compressed"
 	case "video/mp4":
 		return detected == "video/mp4" || strings.HasPrefix(detected, "video/")
+	case "video/webm":
+		return detected == "video/webm"
+	case "audio/mpeg":
+		return detected == "audio/mpeg"
+	case "audio/mp4":
+		// M4A files are MP4 containers
+		return detected == "audio/mp4" || detected == "video/mp4"
+	case "audio/ogg":
+		return detected == "audio/ogg" || detected == "application/ogg"
+	case "audio/wav":
+		return detected == "audio/wav" || detected == "audio/wave"
 	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
 		// Word documents - check specific Office file structure
 		return detected == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" ||
@@ -866,6 +943,15 @@ func detectFileContentType(fileContent []byte, filename string) (string, error)
 		}
 	}
 
+	// MP3 files without ID3 tags start with the header of a frame, M4A files
+	// with the ftyp box of MP4 containers
+	if ext == ".mp3" && len(fileContent) >= 2 && fileContent[0] == 0xFF && fileContent[1]&0xE0 == 0xE0 {
+		return "audio/mpeg", nil
+	}
+	if ext == ".m4a" && len(fileContent) >= 8 && string(fileContent[4:8]) == "ftyp" {
+		return "audio/mp4", nil
+	}
+
 	return basicMimeType, nil
 }
 
@@ -1005,6 +1091,26 @@ func ListDocumentsHandler(w http.ResponseWriter, r *http.Request, cfg *config.Co
 		return
 	}
 
+	documents, err := listDocuments(r, cfg)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(DocumentsResponse{
+			Success: false,
+			Message: "Failed to list documents: " + err.Error(),
+		})
+		return
+	}
+
+	// Return the documents list
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DocumentsResponse{
+		Success:   true,
+		Documents: documents,
+	})
+}
+
+// listDocuments returns the title and path of every document the user may read
+func listDocuments(r *http.Request, cfg *config.Config) ([]Document, error) {
 	// Paths to scan for documents
 	documentsPath := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
 
@@ -1044,6 +1150,11 @@ func ListDocumentsHandler(w http.ResponseWriter, r *http.Request, cfg *config.Co
 				relPath = strings.TrimPrefix(relPath, "/")
 			}
 
+			// Skip documents the user may not read
+			if !canViewPath(r, relPath) {
+				return nil
+			}
+
 			// Add to documents list
 			documents = append(documents, Document{
 				Title: title,
@@ -1053,27 +1164,12 @@ func ListDocumentsHandler(w http.ResponseWriter, r *http.Request, cfg *config.Co
 
 		return nil
 	})
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(DocumentsResponse{
-			Success: false,
-			Message: "Failed to list documents: " + err.Error(),
-		})
-		return
-	}
-
-	// Return the documents list
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(DocumentsResponse{
-		Success:   true,
-		Documents: documents,
-	})
+	return documents, err
 }
 
 // extractTitleFromMarkdown reads a markdown file and extracts the first h1 heading
 func extractTitleFromMarkdown(filePath string) string {
-	content, err := os.ReadFile(filePath)
+	content, err := encryption.ReadFile(filePath)
 	if err != nil {
 		return ""
 	}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/forms"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/utils"
+
+	"github.com/gosimple/slug"
+)
+
+// FormSubmitResponse represents the JSON response after submitting a form
+type FormSubmitResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	URL     string            `json:"url,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// FormsHandler handles listing and saving form definitions (/api/forms)
+func FormsHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := forms.List(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load forms", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Only return the forms this user is allowed to submit (admins see everything)
+		visible := []forms.Form{}
+		for _, form := range list {
+			if session.Role == roles.RoleAdmin || form.CanSubmit(session.Role) {
+				visible = append(visible, form)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"forms":   visible,
+		})
+
+	case http.MethodPost:
+		if session.Role != roles.RoleAdmin {
+			sendJSONError(w, "Admin access required", http.StatusForbidden, "")
+			return
+		}
+
+		var form forms.Form
+		if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := forms.Save(cfg.Wiki.RootDir, &form); err != nil {
+			sendJSONError(w, "Invalid form definition", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Form saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// FormHandler handles a single form (/api/forms/{id} and /api/forms/{id}/submit)
+func FormHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/")
+	if strings.HasSuffix(rest, "/submit") {
+		SubmitFormHandler(w, r, strings.TrimSuffix(rest, "/submit"))
+		return
+	}
+
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+
+	form, err := forms.Get(cfg.Wiki.RootDir, rest)
+	if err != nil {
+		sendJSONError(w, "Form not found", http.StatusNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if session.Role != roles.RoleAdmin && !form.CanSubmit(session.Role) {
+			sendJSONError(w, "Form not found", http.StatusNotFound, "")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"form":    form,
+		})
+
+	case http.MethodDelete:
+		if session.Role != roles.RoleAdmin {
+			sendJSONError(w, "Admin access required", http.StatusForbidden, "")
+			return
+		}
+		if err := forms.Delete(cfg.Wiki.RootDir, form.ID); err != nil {
+			sendJSONError(w, "Failed to delete form", http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Form deleted successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// SubmitFormHandler validates a submission and creates a new page from the form template
+func SubmitFormHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+
+	form, err := forms.Get(cfg.Wiki.RootDir, id)
+	if err != nil {
+		sendJSONError(w, "Form not found", http.StatusNotFound, "")
+		return
+	}
+
+	if !form.CanSubmit(session.Role) {
+		sendJSONError(w, "You are not allowed to submit this form", http.StatusForbidden, "")
+		return
+	}
+
+	// Accept both JSON and regular form posts
+	values := make(map[string]string)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+	} else {
+		if err := r.ParseForm(); err != nil {
+			sendJSONError(w, "Invalid form data", http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, field := range form.Fields {
+			values[field.Name] = r.PostForm.Get(field.Name)
+		}
+	}
+
+	clean, problems := form.Validate(values)
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(FormSubmitResponse{
+			Success: false,
+			Message: "Please correct the highlighted fields",
+			Errors:  problems,
+		})
+		return
+	}
+
+	// Build a unique page path under the form's target directory
+	targetDir := utils.SanitizePath(form.TargetDir)
+	baseSlug := slug.Make(form.PageTitle(clean))
+	if baseSlug == "" {
+		baseSlug = time.Now().Format("20060102-150405")
+	}
+
+	documentsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	pagePath := targetDir + "/" + baseSlug
+	for i := 2; dirExists(filepath.Join(documentsDir, pagePath)); i++ {
+		pagePath = fmt.Sprintf("%s/%s-%d", targetDir, baseSlug, i)
+	}
+
+	content, err := form.Render(clean, session.Username, time.Now())
+	if err != nil {
+		sendJSONError(w, "Failed to render page", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	fullPath := filepath.Join(documentsDir, pagePath)
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		sendJSONError(w, "Failed to create directories", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := encryption.WriteFile(filepath.Join(fullPath, "document.md"), []byte(content), 0644); err != nil {
+		sendJSONError(w, "Failed to create document", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("Form %s submitted by %s, created /%s", form.ID, session.Username, pagePath)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FormSubmitResponse{
+		Success: true,
+		Message: "Page created successfully",
+		URL:     "/" + pagePath,
+	})
+}
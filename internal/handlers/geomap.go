@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MapSettings is the configuration of the maps passed to js/geo-map.js
+type MapSettings struct {
+	TileURL     string `json:"tileUrl"` // Empty to draw maps without tiles
+	Attribution string `json:"attribution"`
+	MaxZoom     int    `json:"maxZoom"`
+}
+
+// mapConfig returns the settings of the maps of pages as JSON
+func mapConfig() string {
+	maxZoom := cfg.Extensions.Maps.MaxZoom
+	if maxZoom <= 0 || maxZoom > 22 {
+		maxZoom = 19
+	}
+	data, err := json.Marshal(MapSettings{
+		TileURL:     strings.TrimSpace(cfg.Extensions.Maps.TileURL),
+		Attribution: cfg.Extensions.Maps.Attribution,
+		MaxZoom:     maxZoom,
+	})
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
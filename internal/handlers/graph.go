@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/utils"
+)
+
+// GraphNode is a page of the link graph
+type GraphNode struct {
+	ID    string   `json:"id"` // Path of the page, empty for the homepage
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+	In    int      `json:"in"`  // Links from other pages
+	Out   int      `json:"out"` // Links to other pages
+}
+
+// GraphLink is a link from one page to another
+type GraphLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// GraphResponse is the link graph of the pages a user may list, with the
+// tags and top directories they can be filtered by
+type GraphResponse struct {
+	Nodes []GraphNode `json:"nodes"`
+	Links []GraphLink `json:"links"`
+	Tags  []string    `json:"tags"`
+	Dirs  []string    `json:"dirs"`
+}
+
+// graphPage is what the graph keeps of a page file
+type graphPage struct {
+	modTime time.Time
+	title   string
+	tags    []string
+	links   []string
+}
+
+// graphCache keeps the links of every page until its file changes
+var graphCache = struct {
+	sync.Mutex
+	pages map[string]graphPage // By file
+}{pages: map[string]graphPage{}}
+
+// readGraphPage returns the title, tags and links of the page at pagePath
+// stored in file, read again only when the file changed
+func readGraphPage(file, pagePath string) (graphPage, bool) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return graphPage{}, false
+	}
+	graphCache.Lock()
+	cached, ok := graphCache.pages[file]
+	graphCache.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		return cached, true
+	}
+
+	content, err := encryption.ReadFile(file)
+	if err != nil {
+		return graphPage{}, false
+	}
+	metadata, body, _ := frontmatter.Parse(string(content))
+	page := graphPage{
+		modTime: info.ModTime(),
+		title:   utils.GetDocumentTitle(filepath.Dir(file)),
+		tags:    frontmatter.NormalizeTags(metadata.Tags),
+		links:   goldext.PageLinks(body, pagePath),
+	}
+	if page.title == "" {
+		page.title = filepath.Base(pagePath)
+	}
+
+	graphCache.Lock()
+	graphCache.pages[file] = page
+	graphCache.Unlock()
+	return page, true
+}
+
+// linkGraph returns the pages listed returns true for, and the links between
+// them. Archived pages are left out.
+func linkGraph(listed func(path string) bool) GraphResponse {
+	pages := map[string]graphPage{}
+	if home, ok := readGraphPage(filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md"), ""); ok {
+		home.title = "Home"
+		pages[""] = home
+	}
+
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != docsDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() != "document.md" {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		pagePath := filepath.ToSlash(rel)
+		if !listed(pagePath) {
+			return nil
+		}
+		if _, archived := archivedEntry(pagePath); archived {
+			return nil
+		}
+		if page, ok := readGraphPage(path, pagePath); ok {
+			pages[pagePath] = page
+		}
+		return nil
+	})
+
+	graph := GraphResponse{Nodes: []GraphNode{}, Links: []GraphLink{}, Tags: []string{}, Dirs: []string{}}
+	in := map[string]int{}
+	out := map[string]int{}
+	for source, page := range pages {
+		for _, target := range page.links {
+			if _, ok := pages[target]; !ok {
+				continue
+			}
+			graph.Links = append(graph.Links, GraphLink{Source: source, Target: target})
+			in[target]++
+			out[source]++
+		}
+	}
+
+	tags := map[string]bool{}
+	dirs := map[string]bool{}
+	for pagePath, page := range pages {
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: pagePath, Title: page.title, Tags: page.tags, In: in[pagePath], Out: out[pagePath]})
+		for _, tag := range page.tags {
+			tags[tag] = true
+		}
+		if dir, _, nested := strings.Cut(pagePath, "/"); nested {
+			dirs[dir] = true
+		}
+	}
+	for tag := range tags {
+		graph.Tags = append(graph.Tags, tag)
+	}
+	for dir := range dirs {
+		graph.Dirs = append(graph.Dirs, dir)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Links, func(i, j int) bool {
+		if graph.Links[i].Source != graph.Links[j].Source {
+			return graph.Links[i].Source < graph.Links[j].Source
+		}
+		return graph.Links[i].Target < graph.Links[j].Target
+	})
+	sort.Strings(graph.Tags)
+	sort.Strings(graph.Dirs)
+	return graph
+}
+
+// filterGraph keeps the pages below dir and with tag, and with a center page
+// the pages at most depth links away from it, in either direction
+func filterGraph(graph GraphResponse, dir, tag, center string, depth int) GraphResponse {
+	keep := map[string]bool{}
+	for _, node := range graph.Nodes {
+		if dir != "" && node.ID != dir && !strings.HasPrefix(node.ID, dir+"/") {
+			continue
+		}
+		if tag != "" && !sharesTag(node.Tags, []string{tag}) {
+			continue
+		}
+		keep[node.ID] = true
+	}
+
+	if center != "" || depth > 0 {
+		neighbours := map[string][]string{}
+		for _, link := range graph.Links {
+			neighbours[link.Source] = append(neighbours[link.Source], link.Target)
+			neighbours[link.Target] = append(neighbours[link.Target], link.Source)
+		}
+		distance := map[string]int{center: 0}
+		queue := []string{center}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if distance[current] >= depth {
+				continue
+			}
+			for _, next := range neighbours[current] {
+				if _, seen := distance[next]; !seen {
+					distance[next] = distance[current] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+		for id := range keep {
+			if _, near := distance[id]; !near {
+				delete(keep, id)
+			}
+		}
+		// The center stays even when the other filters leave it out
+		for _, node := range graph.Nodes {
+			if node.ID == center {
+				keep[center] = true
+			}
+		}
+	}
+
+	filtered := graph
+	filtered.Nodes = []GraphNode{}
+	filtered.Links = []GraphLink{}
+	for _, node := range graph.Nodes {
+		if keep[node.ID] {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	for _, link := range graph.Links {
+		if keep[link.Source] && keep[link.Target] {
+			filtered.Links = append(filtered.Links, link)
+		}
+	}
+	return filtered
+}
+
+// GraphHandler handles GET /api/graph, the links between the pages the user
+// may list for the graph view. The pages can be narrowed with ?dir=, ?tag=,
+// and ?center= with ?depth=, the number of links away from the center page.
+func GraphHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	query := r.URL.Query()
+	dir := strings.Trim(query.Get("dir"), "/")
+	tag := strings.TrimSpace(query.Get("tag"))
+	center := strings.Trim(query.Get("center"), "/")
+	depth := 0
+	if value := query.Get("depth"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			sendJSONError(w, "Invalid depth", http.StatusBadRequest, "")
+			return
+		}
+		depth = parsed
+	}
+	if query.Has("center") && depth == 0 {
+		depth = 2
+	}
+
+	subject := requestSubject(r)
+	rules := loadPermissions()
+	graph := linkGraph(func(path string) bool {
+		if rules == nil {
+			return subject.Role == roles.RoleAdmin
+		}
+		return rules.Listed(path, subject)
+	})
+	if dir != "" || tag != "" || query.Has("center") {
+		graph = filterGraph(graph, dir, tag, center, depth)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(graph)
+}
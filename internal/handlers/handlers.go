@@ -20,6 +20,9 @@ func InitHandlers(config *config.Config) {
 	// Initialise IP-based ban list for login attempts
 	InitLoginBan(cfg)
 
+	// Register the handlers of background jobs
+	registerJobs()
+
 	// Routes are now managed in the routes package
 }
 
@@ -10,7 +10,11 @@ import (
 	"time"
 
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/goldext"
 	"wiki-go/internal/i18n"
+	"wiki-go/internal/review"
 	"wiki-go/internal/types"
 	"wiki-go/internal/utils"
 	"wiki-go/internal/auth"
@@ -36,7 +40,7 @@ If you're running LeoMoon Wiki-Go without SSL/HTTPS and experiencing login issue
 ### Content Management
 - **Markdown Support**: Write content using Markdown syntax for rich formatting
 - **Emoji Shortcodes**: Use emoji shortcodes like ` + "`:::smile:::`" + ` in your Markdown content
-- **File Attachments**: Upload and manage images and documents (supports jpg, jpeg, png, gif, svg, txt, log, csv, zip, pdf, docx, xlsx, pptx, mp4)
+- **File Attachments**: Upload and manage images and documents (supports jpg, jpeg, png, gif, svg, txt, log, csv, zip, pdf, docx, xlsx, pptx, mp4, webm, mp3, m4a, ogg, wav)
 - **Hierarchical Organization**: Organize content in nested directories
 - **Version History**: Track changes with full revision history and restore previous versions
 - **Document Management**: Create, edit, and delete documents with a user-friendly interface
@@ -446,6 +450,14 @@ your-video-filename.mp4
 ` + "```" + `
 ~~~
 
+#### Audio and Video Attachments:
+
+Attached mp4, webm, mp3, m4a, ogg and wav files written as images are shown in players that can be scrubbed without downloading the whole file:
+~~~
+![Deployment walkthrough](walkthrough.mp4)
+![Incident call](call.mp3)
+~~~
+
 #### Forced RTL/LTR
 You can force a specific direction for a section of text by adding the direction shortcode:
 ` + "```rtl" + `
@@ -480,7 +492,7 @@ func EnsureHomepageExists(cfg *config.Config) error {
 
 	// Check if homepage file exists, if not create it
 	if _, err := os.Stat(homepagePath); os.IsNotExist(err) {
-		if err := os.WriteFile(homepagePath, []byte(defaultHomepageContent), 0644); err != nil {
+		if err := encryption.WriteFile(homepagePath, []byte(defaultHomepageContent), 0644); err != nil {
 			return fmt.Errorf("failed to create homepage file: %w", err)
 		}
 		fmt.Println("Created default homepage at", homepagePath)
@@ -501,23 +513,26 @@ func HomeHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
+	if !canViewPath(r, "") {
+		denyView(w, r)
+		return
+	}
+
 	// Get navigation items
-	nav, err := utils.BuildNavigation(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	nav, err := pageNavigation(r, "/")
 	if err != nil {
 		log.Printf("Error building navigation: %v", err)
 		http.Error(w, "Failed to build navigation", http.StatusInternalServerError)
 		return
 	}
 
-	// Mark active navigation item
-	utils.MarkActiveNavItem(nav, "/")
-
 	// Get the homepage path from the pages directory
 	homepagePath := filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md")
 
 	// Always read the content from disk on each request to ensure
-	// we display the most up-to-date version
-	content, err := os.ReadFile(homepagePath)
+	// we display the most up-to-date version, in the language of the reader
+	variant := choosePageLanguage(w, r, filepath.Dir(homepagePath), "pages/home")
+	content, err := encryption.ReadFile(variant.File)
 	if err != nil {
 		log.Printf("Error reading homepage: %v", err)
 		// Fallback to a simple default if there's an error
@@ -525,7 +540,7 @@ func HomeHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	}
 
 	// Get file information for last modified date
-	docInfo, err := os.Stat(homepagePath)
+	docInfo, err := os.Stat(variant.File)
 	var lastModified time.Time
 	if err == nil {
 		lastModified = docInfo.ModTime()
@@ -544,9 +559,18 @@ func HomeHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	}
 
 	// Render the page
+	visibleContent := goldext.FilterAudience(string(content), requestSubject(r))
+	variant.Dir = goldext.PageDirection(visibleContent, variant.Language)
+	var rendered template.HTML
+	if !renderLimited(w, func() {
+		rendered = template.HTML(utils.RenderMarkdown(visibleContent))
+	}) {
+		return
+	}
+
 	data := &types.PageData{
 		Navigation:         nav,
-		Content:            template.HTML(utils.RenderMarkdown(string(content))),
+		Content:            rendered,
 		Breadcrumbs:        []types.BreadcrumbItem{{Title: "Home", Path: "/", IsLast: true}},
 		Config:             cfg,
 		LastModified:       lastModified,
@@ -554,7 +578,20 @@ func HomeHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		AvailableLanguages: i18n.GetAvailableLanguages(),
 		IsAuthenticated:    isAuthenticated,
 		UserRole:           userRole,
+		Stats:              loadPageStats("pages/home", homepagePath),
+		Review:             review.Check(string(content), lastModified, time.Now()),
+		Visibility:         pageVisibility(""),
+		Description:        excerpt.Get(cfg, variant.File),
+	}
+	variant.apply(data)
+	applyRenderSettings(data, "")
+	data.Social = socialMeta(r, "/", variant.File, cfg.Wiki.Title, data.Description, lastModified)
+	data.Owners = resolvePageOwners("", pageOwners(homepagePath))
+	if data.Review != nil {
+		data.Review.Owners = data.Owners
 	}
+	layout, personal := homeLayout(r)
+	data.Dashboard = buildDashboard(r, layout, personal)
 
-	renderTemplate(w, data)
+	renderTemplate(w, r, data)
 }
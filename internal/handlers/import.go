@@ -15,6 +15,10 @@ import (
 	"time"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/importer"
+	"wiki-go/internal/markup"
+	"wiki-go/internal/notebook"
 )
 
 // ImportResponse represents the response for the import API
@@ -88,6 +92,17 @@ func ImportHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
+	// Markdown files are imported as they are, other formats are converted first
+	format := r.FormValue("format")
+	if format != "" && format != "markdown" && !importer.Supported(format) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ImportResponse{
+			Success: false,
+			Message: "Unknown import format.",
+		})
+		return
+	}
+
 	// Get the uploaded file
 	file, fileHeader, err := r.FormFile("zipFile")
 	if err != nil {
@@ -100,8 +115,10 @@ func ImportHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	}
 	defer file.Close()
 
-	// Validate file extension
-	if !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".zip") {
+	// Validate file extension, formats exported as a single file accept it without archive
+	isMarkdown := format == "" || format == "markdown"
+	if (isMarkdown && !strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".zip")) ||
+		(!isMarkdown && !importer.Accepts(format, fileHeader.Filename)) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(ImportResponse{
 			Success: false,
@@ -137,7 +154,11 @@ func ImportHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	importJobsMutex.Unlock()
 
 	// Start the import process in a goroutine
-	go processImportFromBytes(fileBytes, jobID, cfg)
+	if isMarkdown {
+		go processImportFromBytes(fileBytes, jobID, cfg)
+	} else {
+		go processConvertedImport(fileBytes, fileHeader.Filename, format, jobID, cfg)
+	}
 
 	// Return success response with job ID
 	w.WriteHeader(http.StatusOK)
@@ -213,14 +234,14 @@ func processImportFromBytes(zipFileBytes []byte, jobID string, cfg *config.Confi
 	for _, file := range zipReader.File {
 		if !file.FileInfo().IsDir() {
 			totalFiles++
-			if strings.HasSuffix(strings.ToLower(file.Name), ".md") {
+			if isPageFile(file.Name) {
 				markdownFiles++
 			}
 		}
 	}
 
 	if markdownFiles == 0 {
-		updateImportStatus(jobID, "failed", 0, "", "No markdown files found in the ZIP archive.")
+		updateImportStatus(jobID, "failed", 0, "", "No markdown, AsciiDoc, reStructuredText or notebook files found in the ZIP archive.")
 		return
 	}
 
@@ -235,8 +256,8 @@ func processImportFromBytes(zipFileBytes []byte, jobID string, cfg *config.Confi
 		// Update current file in status
 		updateImportStatusFile(jobID, file.Name)
 
-		// Only process markdown files
-		if !strings.HasSuffix(strings.ToLower(file.Name), ".md") {
+		// Only process the files of pages
+		if !isPageFile(file.Name) {
 			processedFiles++
 			progress := int((float64(processedFiles) / float64(totalFiles)) * 100)
 			updateImportStatusProgress(jobID, progress)
@@ -270,6 +291,93 @@ func processImportFromBytes(zipFileBytes []byte, jobID string, cfg *config.Confi
 	}
 }
 
+// processConvertedImport converts an export of another tool and writes its
+// pages and attachments
+func processConvertedImport(fileBytes []byte, filename, format, jobID string, cfg *config.Config) {
+	site, err := importer.Convert(format, filename, fileBytes)
+	if err != nil {
+		updateImportStatus(jobID, "failed", 0, "", err.Error())
+		return
+	}
+	for _, warning := range site.Warnings {
+		addImportError(jobID, "Skipped "+warning)
+	}
+
+	documentsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	total := len(site.Pages) + len(site.Attachments)
+	processed := 0
+	step := func() {
+		processed++
+		updateImportStatusProgress(jobID, int(float64(processed)/float64(total)*100))
+	}
+
+	for _, page := range site.Pages {
+		updateImportStatusFile(jobID, page.Source)
+		docDir := filepath.Join(documentsDir, filepath.FromSlash(page.Path))
+		if err := os.MkdirAll(docDir, 0755); err != nil {
+			addImportError(jobID, fmt.Sprintf("Error processing %s: %v", page.Source, err))
+		} else if err := encryption.WriteFile(filepath.Join(docDir, "document.md"), []byte(page.Content), 0644); err != nil {
+			addImportError(jobID, fmt.Sprintf("Error processing %s: %v", page.Source, err))
+		} else {
+			addImportedFile(jobID, page.Source, "/"+page.Path)
+		}
+		step()
+	}
+
+	for _, attachment := range site.Attachments {
+		updateImportStatusFile(jobID, attachment.Source)
+		if err := writeImportedAttachment(documentsDir, attachment, cfg); err != nil {
+			addImportError(jobID, fmt.Sprintf("Error processing %s: %v", attachment.Source, err))
+		}
+		step()
+	}
+
+	importJobsMutex.RLock()
+	status := importJobs[jobID]
+	importJobsMutex.RUnlock()
+
+	if status.ErrorCount == 0 {
+		updateImportStatus(jobID, "completed", 100, "", "Import completed successfully.")
+	} else if status.SuccessCount == 0 {
+		updateImportStatus(jobID, "failed", 100, "", "Import failed. No files were imported successfully.")
+	} else {
+		updateImportStatus(jobID, "completed", 100, "", fmt.Sprintf("Import completed with %d errors.", status.ErrorCount))
+	}
+}
+
+// writeImportedAttachment stores an attachment with the checks of uploaded files
+func writeImportedAttachment(documentsDir string, attachment importer.Attachment, cfg *config.Config) error {
+	dir := filepath.Join(documentsDir, filepath.FromSlash(attachment.Page))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeAttachment(dir, attachment.Name, attachment.Data, cfg)
+}
+
+// writeAttachment saves a file next to the page kept in dir, applying the
+// checks of uploads: the extension allowlist and SVG sanitizing
+func writeAttachment(dir, name string, data []byte, cfg *config.Config) error {
+	ext := strings.ToLower(filepath.Ext(name))
+	if !cfg.Wiki.DisableFileUploadChecking && !config.IsAllowedExtension(ext) {
+		return fmt.Errorf("file type %s is not allowed", ext)
+	}
+	if ext == ".svg" && !cfg.Wiki.DisableFileUploadChecking {
+		sanitized, err := sanitizeSVG(data)
+		if err != nil {
+			return err
+		}
+		data = sanitized
+	}
+	return encryption.WriteFile(filepath.Join(dir, sanitizeFilename(name)), data, 0644)
+}
+
+// isPageFile reports whether a file of a ZIP archive is imported as a page:
+// markdown, a markup format such as AsciiDoc rendered as it is, or a notebook
+func isPageFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".md") || markup.FormatOf(name) != "" ||
+		strings.EqualFold(filepath.Ext(name), notebook.Extension)
+}
+
 // processMarkdownFile processes a single markdown file from the ZIP
 func processMarkdownFile(file *zip.File, jobID string, cfg *config.Config) error {
 	// Open the file from the ZIP
@@ -287,6 +395,11 @@ func processMarkdownFile(file *zip.File, jobID string, cfg *config.Config) error
 
 	// Determine the target path based on the file's path in the ZIP
 	originalPath := file.Name
+
+	// AsciiDoc and reStructuredText pages keep their source, marked with its format
+	if format := markup.FormatOf(originalPath); format != "" {
+		content = append([]byte("---\nformat: "+format+"\n---\n"), content...)
+	}
 	targetPath, err := determineTargetPath(originalPath)
 	if err != nil {
 		return fmt.Errorf("failed to determine target path: %v", err)
@@ -301,11 +414,23 @@ func processMarkdownFile(file *zip.File, jobID string, cfg *config.Config) error
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
 
+	// Notebooks are attached to their page, which shows them
+	if strings.EqualFold(filepath.Ext(originalPath), notebook.Extension) {
+		if _, err := notebook.Parse(content); err != nil {
+			return err
+		}
+		name := sanitizeFilename(originalPath)
+		if err := encryption.WriteFile(filepath.Join(docDir, name), content, 0644); err != nil {
+			return fmt.Errorf("failed to write notebook: %v", err)
+		}
+		content = []byte("![" + strings.TrimSuffix(filepath.Base(originalPath), filepath.Ext(originalPath)) + "](" + name + ")\n")
+	}
+
 	// Write the content to document.md in the target directory
 	docPath := filepath.Join(docDir, "document.md")
 	
 	// Ensure the content has proper permissions
-	err = os.WriteFile(docPath, content, 0644)
+	err = encryption.WriteFile(docPath, content, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/federation"
+	"wiki-go/internal/jobs"
+)
+
+// Background job types
+const (
+	jobNotifyOwners = "notify-owners"
+	jobBulkPages    = "bulk-pages"
+	jobVideoPoster  = "video-poster"
+)
+
+// ownerChangeJob is the payload of a jobNotifyOwners job
+type ownerChangeJob struct {
+	Path  string `json:"path"`
+	Actor string `json:"actor"`
+}
+
+// registerJobs sets up the handlers of the background job types
+func registerJobs() {
+	jobs.Register(jobNotifyOwners, func(payload json.RawMessage) error {
+		var change ownerChangeJob
+		if err := json.Unmarshal(payload, &change); err != nil {
+			return err
+		}
+		return notifyOwnersOfChange(change.Path, change.Actor)
+	})
+	jobs.RegisterReporting(jobBulkPages, runBulkJob)
+	jobs.Register(federation.JobType, syncFederationSource)
+	jobs.Register(jobVideoPoster, generateVideoPoster)
+	jobs.Register(excerpt.JobType, func(payload json.RawMessage) error {
+		return excerpt.RunSummaryJob(cfg, payload)
+	})
+}
+
+// enqueueOwnerNotification notifies the owners of a page about a change in the background
+func enqueueOwnerNotification(path, actor string) {
+	if _, err := jobs.Enqueue(jobNotifyOwners, ownerChangeJob{Path: path, Actor: actor}); err != nil {
+		log.Printf("Error queueing owner notification for %s: %v", path, err)
+	}
+}
+
+// JobActionRequest is the body of POST /api/settings/jobs
+type JobActionRequest struct {
+	Action string `json:"action"` // "retry" or "delete"
+	ID     string `json:"id"`
+}
+
+// JobsHandler handles GET /api/settings/jobs, the list of background jobs, and
+// POST to retry or delete one of them
+func JobsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"jobs":    jobs.List(),
+		})
+
+	case http.MethodPost:
+		var req JobActionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		var err error
+		switch req.Action {
+		case "retry":
+			err = jobs.Retry(req.ID)
+		case "delete":
+			err = jobs.Delete(req.ID)
+		default:
+			sendJSONError(w, "Unknown action", http.StatusBadRequest, req.Action)
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to update job", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Job updated successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/permalinks"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// Size of the label cards when the configured one isn't a CSS length
+const (
+	labelDefaultWidth  = "62mm"
+	labelDefaultHeight = "29mm"
+)
+
+// labelLengthRegex matches the CSS lengths accepted as label sizes, like 62mm or 2.4in
+var labelLengthRegex = regexp.MustCompile(`^\d+(\.\d+)?(mm|cm|in|pt|px)$`)
+
+// LabelCard holds the data of the printable label card of a page
+type LabelCard struct {
+	Title        string
+	Path         string // Page path starting with a slash
+	URL          string // Permanent link of the page when it has one, its URL otherwise
+	QRCode       template.URL
+	Fields       []frontmatter.Field
+	Width        string
+	Height       string
+	Config       *config.Config
+	LastModified time.Time
+	AutoPrint    bool
+}
+
+// Field returns the value of the frontmatter field key printed on the card,
+// for templates that place fields one by one
+func (c LabelCard) Field(key string) string {
+	for _, field := range c.Fields {
+		if field.Key == key {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// labelLength returns value when it is a CSS length a label can have, fallback otherwise
+func labelLength(value, fallback string) string {
+	value = strings.TrimSpace(value)
+	if labelLengthRegex.MatchString(value) {
+		return value
+	}
+	return fallback
+}
+
+// labelFields picks the frontmatter fields printed on a card: those named in
+// the comma-separated names, in that order, or every field the wiki doesn't
+// use itself when names is empty
+func labelFields(fields []frontmatter.Field, names string) []frontmatter.Field {
+	var picked []frontmatter.Field
+	if strings.TrimSpace(names) == "" {
+		for _, field := range fields {
+			if !frontmatter.IsMetadataKey(field.Key) {
+				picked = append(picked, field)
+			}
+		}
+		return picked
+	}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		for _, field := range fields {
+			if field.Key == name {
+				picked = append(picked, field)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+// labelLink returns the link encoded in the QR code of a card. Pages keep the
+// permanent link they have, and get one when the user may edit them, so that
+// labels stuck on things still work after the page moves.
+func labelLink(r *http.Request, path string) (string, error) {
+	if path == "" {
+		return pageLink(r, path, false)
+	}
+	_, found, err := permalinks.Find(cfg.Wiki.RootDir, path)
+	if err != nil {
+		return "", err
+	}
+	return pageLink(r, path, found || editAllowed(r, path))
+}
+
+// LabelHandler serves /label/{path}, a compact card of a page sized for label
+// printers: its title, a QR code of its permanent link and key frontmatter
+// fields, for pages documenting physical assets. The size, the fields and the
+// template of the card are set in the labels section of the configuration,
+// ?width=, ?height= and ?fields= override them and ?autoprint=1 opens the
+// print dialog.
+func LabelHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	doc, status := loadViewDocument(r, "/label", cfg)
+	if status == http.StatusNotFound {
+		NotFoundHandler(w, r, cfg)
+		return
+	} else if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	query := r.URL.Query()
+
+	link, err := labelLink(r, doc.Path)
+	if err != nil {
+		http.Error(w, "Error creating the permalink: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	png, err := qrcode.Encode(link, qrcode.Medium, qrDefaultSize)
+	if err != nil {
+		http.Error(w, "Error drawing the QR code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	names := cfg.Extensions.Labels.Fields
+	if query.Has("fields") {
+		names = query.Get("fields")
+	}
+	width := labelLength(cfg.Extensions.Labels.Width, labelDefaultWidth)
+	height := labelLength(cfg.Extensions.Labels.Height, labelDefaultHeight)
+
+	data := LabelCard{
+		Title:        doc.Title,
+		Path:         "/" + doc.Path,
+		URL:          link,
+		QRCode:       template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		Fields:       labelFields(frontmatter.Fields(doc.Content), names),
+		Width:        labelLength(query.Get("width"), width),
+		Height:       labelLength(query.Get("height"), height),
+		Config:       cfg,
+		LastModified: doc.LastModified,
+		AutoPrint:    query.Get("autoprint") == "1",
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset": assetURL,
+		"formatTime": func(t time.Time, timezone string, format string) string {
+			return utils.FormatTimeInTimezone(t, timezone, format)
+		},
+	}
+
+	// A template of the configuration replaces the built-in card
+	var tmpl *template.Template
+	if custom := cfg.Extensions.Labels.Template; custom != "" {
+		file := filepath.Join(cfg.Wiki.RootDir, filepath.Clean("/"+custom))
+		tmpl, err = template.New(filepath.Base(file)).Funcs(funcMap).ParseFiles(file)
+	} else {
+		tmpl, err = template.New("label.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/label.html")
+	}
+	if err != nil {
+		http.Error(w, "Error loading label template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering label template: %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"wiki-go/internal/encryption"
 	"wiki-go/internal/frontmatter"
 	"wiki-go/internal/utils"
 )
@@ -72,7 +73,7 @@ func AddLinkHandler(w http.ResponseWriter, r *http.Request) {
 	docPath := getDocumentPath(decodedPath)
 	
 	// Read current document
-	content, err := os.ReadFile(docPath)
+	content, err := encryption.ReadFile(docPath)
 	if err != nil {
 		sendLinkError(w, "Failed to read document", http.StatusInternalServerError, err.Error())
 		return
@@ -166,7 +167,7 @@ func EditLinkHandler(w http.ResponseWriter, r *http.Request) {
 	docPath := getDocumentPath(decodedPath)
 	
 	// Read current document
-	content, err := os.ReadFile(docPath)
+	content, err := encryption.ReadFile(docPath)
 	if err != nil {
 		sendLinkError(w, "Failed to read document", http.StatusInternalServerError, err.Error())
 		return
@@ -287,7 +288,7 @@ func DeleteLinkHandler(w http.ResponseWriter, r *http.Request) {
 	docPath := getDocumentPath(decodedPath)
 	
 	// Read current document
-	content, err := os.ReadFile(docPath)
+	content, err := encryption.ReadFile(docPath)
 	if err != nil {
 		sendLinkError(w, "Failed to read document", http.StatusInternalServerError, err.Error())
 		return
@@ -509,7 +510,7 @@ func saveDocumentWithVersioning(docPath, relativePath string, content []byte) er
 	// VERSION CONTROL: Save current version before overwriting (same logic as SaveHandler)
 	if _, err := os.Stat(docPath); err == nil && cfg.Wiki.MaxVersions > 0 {
 		// Document exists, read its current content
-		currentContent, err := os.ReadFile(docPath)
+		currentContent, err := encryption.ReadFile(docPath)
 		if err == nil && len(currentContent) > 0 {
 			// Create timestamp for version filename
 			timestamp := time.Now().Format("20060102150405")
@@ -523,7 +524,7 @@ func saveDocumentWithVersioning(docPath, relativePath string, content []byte) er
 				versionPath := filepath.Join(versionDir, timestamp+".md")
 
 				// Save the current content as a version
-				_ = os.WriteFile(versionPath, currentContent, 0644)
+				_ = encryption.WriteFile(versionPath, currentContent, 0644)
 
 				// Log the versioning
 				log.Printf("Created version: %s", versionPath)
@@ -541,7 +542,7 @@ func saveDocumentWithVersioning(docPath, relativePath string, content []byte) er
 	}
 
 	// Write the content to the file
-	if err := os.WriteFile(docPath, content, 0644); err != nil {
+	if err := encryption.WriteFile(docPath, content, 0644); err != nil {
 		return fmt.Errorf("failed to save document: %v", err)
 	}
 
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/lint"
+	"wiki-go/internal/markup"
+)
+
+// lintMode returns the configured linter mode, off when it isn't valid
+func lintMode() string {
+	if lint.ValidMode(cfg.Wiki.Lint) {
+		return cfg.Wiki.Lint
+	}
+	return lint.ModeOff
+}
+
+// lintPage checks the markdown of the page kept in dir. Pages written in
+// AsciiDoc or reStructuredText aren't markdown, and aren't checked.
+func lintPage(dir string, markdown string) []lint.Issue {
+	if metadata, _, ok := frontmatter.Parse(markdown); ok && markup.Format(metadata.Format) != "" {
+		return nil
+	}
+	issues := lint.Check(markdown, func(name string) bool {
+		if name != filepath.Base(name) {
+			return false
+		}
+		info, err := os.Stat(filepath.Join(dir, name))
+		return err == nil && !info.IsDir()
+	})
+
+	// [[/page#heading]] cross-references must point at an existing section
+	for _, ref := range goldext.SectionRefs(markdown) {
+		if _, problem := goldext.CheckSectionRef(ref); problem != "" {
+			issues = append(issues, lint.Issue{Rule: lint.RuleBrokenSectionRef, Line: ref.Line, Message: problem})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// LintHandler handles POST /api/lint?path=, checking the markdown in the
+// request body as a draft of the page at path, for style problems and against
+// the schema of the page
+func LintHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	markdown, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		sendJSONError(w, "Failed to read request body", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The schema of the page is checked even when style checks are off
+	mode := lintMode()
+	issues := schemaIssues(docPath, string(markdown))
+	if mode != lint.ModeOff {
+		issues = append(issues, lintPage(pageDir(cfg, docPath), string(markdown))...)
+	}
+	if issues == nil {
+		issues = []lint.Issue{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"mode":    mode,
+		"schema":  hasSchema(docPath),
+		"issues":  issues,
+	})
+}
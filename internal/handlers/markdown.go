@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"wiki-go/internal/auth"
+	"wiki-go/internal/goldext"
 	"wiki-go/internal/utils"
 )
 
@@ -36,15 +37,22 @@ func RenderMarkdownHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	// Preview conditional content as the current user would see it
+	markdown = []byte(goldext.FilterAudience(string(markdown), requestSubject(r)))
+
 	// Get the document path from the query parameter
 	docPath := r.URL.Query().Get("path")
 
 	// Use the utility function to render markdown to HTML with the document path
 	var html []byte
-	if docPath != "" {
-		html = utils.RenderMarkdownWithPath(string(markdown), docPath)
-	} else {
-		html = utils.RenderMarkdown(string(markdown))
+	if !renderLimited(w, func() {
+		if docPath != "" {
+			html = utils.RenderMarkdownWithPath(string(markdown), docPath)
+		} else {
+			html = utils.RenderMarkdown(string(markdown))
+		}
+	}) {
+		return
 	}
 
 	// Set content type to HTML
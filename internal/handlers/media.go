@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/jobs"
+)
+
+// posterTimeout is the time limit of ffmpeg for one poster
+const posterTimeout = 2 * time.Minute
+
+// videoPosterJob is the payload of a jobVideoPoster job
+type videoPosterJob struct {
+	File string `json:"file"` // Path of the video, relative to the root directory
+}
+
+// enqueueVideoPoster generates the poster image of the video at filePath in
+// the background, when posters are turned on. Videos of encrypted directories
+// get none, ffmpeg can't read them.
+func enqueueVideoPoster(filePath string) {
+	if !cfg.Extensions.Media.Posters || goldext.MediaKind(filePath) != "video" || encryption.Encrypted(filePath) {
+		return
+	}
+	relativePath, err := filepath.Rel(cfg.Wiki.RootDir, filePath)
+	if err != nil {
+		return
+	}
+	if _, err := jobs.Enqueue(jobVideoPoster, videoPosterJob{File: filepath.ToSlash(relativePath)}); err != nil {
+		log.Printf("Error queueing poster of %s: %v", relativePath, err)
+	}
+}
+
+// generateVideoPoster saves a frame of a video as its poster, next to it. The
+// frame a second in skips the black start of most screen recordings; shorter
+// clips get their first frame.
+func generateVideoPoster(payload json.RawMessage) error {
+	var job videoPosterJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	video := filepath.Join(cfg.Wiki.RootDir, filepath.FromSlash(filepath.Clean("/"+job.File)))
+	if _, err := os.Stat(video); err != nil {
+		// Deleted since it was uploaded
+		return nil
+	}
+
+	poster := video + goldext.VideoPosterSuffix
+	partial := poster + ".part.jpg"
+	defer os.Remove(partial)
+
+	var err error
+	for _, offset := range []string{"1", "0"} {
+		if err = runFFmpeg(video, partial, offset); err != nil {
+			continue
+		}
+		if info, statErr := os.Stat(partial); statErr == nil && info.Size() > 0 {
+			return os.Rename(partial, poster)
+		}
+		err = errors.New("ffmpeg found no frame")
+	}
+	return fmt.Errorf("poster of %s: %w", job.File, err)
+}
+
+// runFFmpeg writes the frame of video at offset seconds to output as a JPEG
+// image at most 1280 pixels wide
+func runFFmpeg(video, output, offset string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), posterTimeout)
+	defer cancel()
+
+	command := cfg.Extensions.Media.FFmpeg
+	if command == "" {
+		command = "ffmpeg"
+	}
+	cmd := exec.CommandContext(ctx, command, "-hide_banner", "-loglevel", "error", "-nostdin", "-y",
+		"-ss", offset, "-i", video, "-frames:v", "1", "-vf", "scale='min(1280,iw)':-2", "-q:v", "4", output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("ffmpeg took longer than %s", posterTimeout)
+		}
+		return fmt.Errorf("ffmpeg failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
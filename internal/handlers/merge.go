@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/store"
+	"wiki-go/internal/textdiff"
+)
+
+// uncheckedSaveWarning is the Warning header of saves replacing a page without
+// telling which revision they were made to, which may overwrite changes
+const uncheckedSaveWarning = `299 - "Saved without X-Base-Revision: changes saved meanwhile may have been overwritten"`
+
+// lockPage takes the lock of the page at path, held while a save checks for
+// changes saved meanwhile and writes the page, so two editors saving at once
+// can't both pass the check. Instances sharing a store take turns too. The
+// returned function releases the lock and may be called more than once.
+func lockPage(path string) (func(), error) {
+	unlock, err := store.Current.Lock("page:" + strings.Trim(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return sync.OnceFunc(unlock), nil
+}
+
+// MergeRequest holds the three versions of a page to merge: the one the editor
+// was opened on, the one saved meanwhile by someone else and the edited one
+type MergeRequest struct {
+	Base   string `json:"base"`
+	Theirs string `json:"theirs"`
+	Mine   string `json:"mine"`
+}
+
+// contentRevision identifies a version of a page or section, to notice changes
+// saved by others while it was edited
+func contentRevision(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// lastEditor returns who saved the page at relativePath last, if known
+func lastEditor(relativePath string) string {
+	history, err := pagestats.LoadHistory(cfg.Wiki.RootDir, relativePath)
+	if err != nil || len(history) == 0 {
+		return ""
+	}
+	return history[len(history)-1].User
+}
+
+// sendSaveConflict answers a save made on an outdated version of a page with
+// the version saved meanwhile, for the editor to merge with
+func sendSaveConflict(w http.ResponseWriter, current, relativePath string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  false,
+		"message":  "The page was changed by someone else while you were editing",
+		"current":  current,
+		"revision": contentRevision(current),
+		"editor":   lastEditor(relativePath),
+	})
+}
+
+// MergeHandler handles POST /api/merge, the three-way merge of the changes
+// saved meanwhile into the edited page. Changes to different parts of the page
+// are merged; the others are returned as conflicts to resolve.
+func MergeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	var req MergeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 32<<20)).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chunks := textdiff.Merge(textdiff.SplitLines(req.Base), textdiff.SplitLines(req.Theirs), textdiff.SplitLines(req.Mine))
+	conflicts := 0
+	for _, chunk := range chunks {
+		if chunk.Conflict {
+			conflicts++
+		}
+	}
+	response := map[string]interface{}{
+		"success":   true,
+		"chunks":    chunks,
+		"conflicts": conflicts,
+	}
+	if lines, ok := textdiff.Merged(chunks); ok {
+		merged := strings.Join(lines, "\n")
+		if len(lines) > 0 {
+			merged += "\n"
+		}
+		response["merged"] = merged
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+)
+
+// setupSaveTest sets up a wiki with the page guide and returns the cookies of
+// an editor's session
+func setupSaveTest(t *testing.T) []*http.Cookie {
+	t.Helper()
+	previous := cfg
+	cfg = &config.Config{}
+	cfg.Wiki.RootDir = t.TempDir()
+	cfg.Wiki.DocumentsDir = "documents"
+	t.Cleanup(func() { cfg = previous })
+
+	dir := filepath.Join(cfg.Wiki.RootDir, "documents", "guide")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "document.md"), []byte("# Guide\n\nText\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := auth.CreateSession(w, "ann", config.RoleEditor, false, cfg); err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies()
+}
+
+func savePage(cookies []*http.Cookie, content, base string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/save/guide", strings.NewReader(content))
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	if base != "" {
+		r.Header.Set("X-Base-Revision", base)
+	}
+	w := httptest.NewRecorder()
+	SaveHandler(w, r)
+	return w
+}
+
+func TestSavesCheckTheRevisionUnderThePageLock(t *testing.T) {
+	cookies := setupSaveTest(t)
+	base := contentRevision("# Guide\n\nText\n")
+
+	// Another save holds the page while this one is made
+	unlock, err := lockPage("/guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- savePage(cookies, "# Guide\n\nMine\n", base) }()
+	select {
+	case w := <-done:
+		t.Fatalf("save finished with status %d while the page was locked", w.Code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	file := filepath.Join(cfg.Wiki.RootDir, "documents", "guide", "document.md")
+	if err := os.WriteFile(file, []byte("# Guide\n\nTheirs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	// The page it was made to was replaced meanwhile
+	if w := <-done; w.Code != http.StatusConflict {
+		t.Errorf("save status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "# Guide\n\nTheirs\n" {
+		t.Errorf("page = %q, want the changes saved meanwhile", content)
+	}
+}
+
+func TestSavesWithoutABaseRevisionAreWarned(t *testing.T) {
+	cookies := setupSaveTest(t)
+
+	w := savePage(cookies, "# Guide\n\nOverwritten\n", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+	if w.Header().Get("Warning") == "" {
+		t.Error("a save without X-Base-Revision got no warning")
+	}
+
+	w = savePage(cookies, "# Guide\n\nChecked\n", contentRevision("# Guide\n\nOverwritten\n"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+	if warning := w.Header().Get("Warning"); warning != "" {
+		t.Errorf("a checked save got the warning %q", warning)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Mermaid settings used when the configured ones are missing or unknown
+const (
+	defaultMermaidScript   = "libs/mermaid-11.8.1/mermaid.min.js"
+	defaultMermaidTheme    = "default"
+	defaultMermaidSecurity = "strict"
+	defaultMermaidMaxText  = 15000
+)
+
+// mermaidThemes and mermaidSecurityLevels are the values Mermaid accepts
+var (
+	mermaidThemes         = []string{"default", "neutral", "dark", "forest", "base"}
+	mermaidSecurityLevels = []string{"strict", "antiscript", "loose", "sandbox"}
+)
+
+// MermaidSettings is the configuration of Mermaid passed to the scripts of the
+// pages that render diagrams
+type MermaidSettings struct {
+	Theme         string   `json:"theme"`
+	DarkTheme     string   `json:"darkTheme"`
+	SecurityLevel string   `json:"securityLevel"`
+	MaxTextSize   int      `json:"maxTextSize"`
+	Plugins       []string `json:"plugins"` // Addresses of the ES modules of external diagrams
+}
+
+// mermaidAddress returns the address of a Mermaid script: URLs as they are and
+// files below static, which data/static can replace, with their fingerprint
+func mermaidAddress(script string) string {
+	if strings.HasPrefix(script, "https://") || strings.HasPrefix(script, "http://") {
+		return script
+	}
+	return assetURL(strings.TrimPrefix(strings.TrimPrefix(script, "/"), "static/"))
+}
+
+// mermaidScript returns the address of the Mermaid bundle loaded by the pages
+func mermaidScript() string {
+	script := strings.TrimSpace(cfg.Extensions.Mermaid.Script)
+	if script == "" {
+		script = defaultMermaidScript
+	}
+	return mermaidAddress(script)
+}
+
+// mermaidChoice returns value when it is one of choices, fallback otherwise
+func mermaidChoice(value string, choices []string, fallback string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, choice := range choices {
+		if value == choice {
+			return value
+		}
+	}
+	return fallback
+}
+
+// mermaidConfig returns the Mermaid settings as JSON, read by js/mermaid-config.js
+func mermaidConfig() string {
+	settings := MermaidSettings{
+		Theme:         mermaidChoice(cfg.Extensions.Mermaid.Theme, mermaidThemes, defaultMermaidTheme),
+		DarkTheme:     mermaidChoice(cfg.Extensions.Mermaid.DarkTheme, mermaidThemes, "dark"),
+		SecurityLevel: mermaidChoice(cfg.Extensions.Mermaid.SecurityLevel, mermaidSecurityLevels, defaultMermaidSecurity),
+		MaxTextSize:   cfg.Extensions.Mermaid.MaxTextSize,
+		Plugins:       []string{},
+	}
+	if settings.MaxTextSize <= 0 {
+		settings.MaxTextSize = defaultMermaidMaxText
+	}
+	for _, plugin := range strings.Split(cfg.Extensions.Mermaid.Plugins, ",") {
+		if plugin = strings.TrimSpace(plugin); plugin != "" {
+			settings.Plugins = append(settings.Plugins, mermaidAddress(plugin))
+		}
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/semantic"
 )
 
 // MoveRequest represents the request to move or rename a document or category
@@ -86,6 +88,22 @@ func MoveDocumentHandler(w http.ResponseWriter, r *http.Request, cfg *config.Con
 		return
 	}
 
+	// Archived pages are moved once unarchived, and nothing is moved into them
+	if _, archived := archivedEntry(moveReq.SourcePath); archived {
+		sendJSONResponse(w, false, "This page is archived; unarchive it to move it", http.StatusConflict, "", "")
+		return
+	}
+	if _, archived := archivedEntry(moveReq.TargetPath); archived && moveReq.TargetPath != "" {
+		sendJSONResponse(w, false, "The destination is archived", http.StatusConflict, "", "")
+		return
+	}
+
+	// Both the page and its destination must be editable by the user
+	if !canEditPath(r, moveReq.SourcePath) || !canEditPath(r, moveReq.TargetPath) {
+		sendJSONResponse(w, false, "You are not allowed to move this page there", http.StatusForbidden, "", "")
+		return
+	}
+
 	// Determine if this is a rename or move operation
 	isRename := moveReq.NewSlug != ""
 	
@@ -278,10 +296,28 @@ func MoveDocumentHandler(w http.ResponseWriter, r *http.Request, cfg *config.Con
 		}
 	}
 
+	syncEncryption(strings.TrimPrefix(newPath, "documents/"))
+
+	movePageState(moveReq.SourcePath, strings.TrimPrefix(newPath, "documents/"))
+
 	// Return success response with both old and new paths
+	semantic.Changed()
 	sendJSONResponse(w, true, "Document moved successfully", http.StatusOK, newPath, moveReq.SourcePath)
 }
 
+// syncEncryption encrypts or decrypts a moved page and its versions to match
+// the directory they were moved to
+func syncEncryption(docPath string) {
+	for _, dir := range []string{
+		filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath),
+		filepath.Join(cfg.Wiki.RootDir, "versions", "documents", docPath),
+	} {
+		if _, err := encryption.SyncTree(dir); err != nil {
+			log.Printf("Warning: Failed to update encryption of %s: %v", dir, err)
+		}
+	}
+}
+
 // Helper function to clean and normalize a path
 func cleanPath(path string) string {
 	if path == "" {
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MusicSettings is the configuration of the scores passed to js/abc-notation.js
+type MusicSettings struct {
+	Enabled bool   `json:"enabled"`
+	Script  string `json:"script"` // Address of the abcjs bundle, loaded by pages with scores
+}
+
+// musicConfig returns the settings of music notation as JSON. Without a
+// bundle, abc blocks stay code.
+func musicConfig() string {
+	settings := MusicSettings{}
+	if script := strings.TrimSpace(cfg.Extensions.Music.Script); script != "" && cfg.Extensions.Music.Enable {
+		settings.Enabled = true
+		settings.Script = mermaidAddress(script)
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"wiki-go/internal/types"
+	"wiki-go/internal/utils"
+)
+
+// TreeItem is a sidebar entry returned by the tree API
+type TreeItem struct {
+	Title       string `json:"title"`
+	Path        string `json:"path"`
+	HasChildren bool   `json:"hasChildren"`
+}
+
+// pageNavigation returns the sidebar tree of the page at path, expanded along
+// that path and limited to what the current user may read. Archived pages are
+// left out unless the user included them, pinned pages come first in their
+// directory.
+func pageNavigation(r *http.Request, path string) (*types.NavItem, error) {
+	root, err := utils.CachedNavigation(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	if err != nil {
+		return nil, err
+	}
+	nav := utils.NavigationFor(root, path, listingFilter(r, path))
+	pinNavigation(nav, pinRanks())
+	return nav, nil
+}
+
+// TreeHandler returns the children of a sidebar directory, so the sidebar can
+// expand directories without loading the whole tree with every page
+func TreeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	path := "/" + strings.Trim(r.URL.Query().Get("path"), "/")
+	if !canViewPath(r, path) {
+		sendJSONError(w, "Directory not found", http.StatusNotFound, "")
+		return
+	}
+
+	root, err := utils.CachedNavigation(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	if err != nil {
+		sendJSONError(w, "Failed to build navigation", http.StatusInternalServerError, err.Error())
+		return
+	}
+	item := utils.FindNavItem(root, path)
+	if item == nil {
+		sendJSONError(w, "Directory not found", http.StatusNotFound, "")
+		return
+	}
+
+	allowed := listingFilter(r, path)
+	// Copied, as the children may be those of the cached tree
+	children := append([]*types.NavItem{}, utils.NavChildren(item, allowed)...)
+	sortPinnedItems(children, pinRanks())
+	items := make([]TreeItem, 0, len(children))
+	for _, child := range children {
+		items = append(items, TreeItem{
+			Title:       child.Title,
+			Path:        child.Path,
+			HasChildren: len(utils.NavChildren(child, allowed)) > 0,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wiki-go/internal/netaccess"
+)
+
+// NetworkSettingsHandler handles GET and POST /api/settings/network, the raw YAML
+// network access rules
+func NetworkSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := netaccess.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read network rules", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rules, err := netaccess.Load(cfg.Wiki.RootDir)
+		clientIP := ""
+		if err == nil {
+			if client := rules.Client(r); client.IP != nil {
+				clientIP = client.IP.String()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"content":  content,
+			"clientIP": clientIP,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req PermissionsPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		rules, err := netaccess.Parse([]byte(req.Content))
+		if err != nil {
+			sendJSONError(w, "Invalid network rules", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Refuse rules that would lock the admin saving them out of the settings
+		client := rules.Client(r)
+		if ok, group := rules.Allowed(client, netaccess.Groups(r)); !ok {
+			sendJSONError(w, "These rules would block your own access", http.StatusBadRequest,
+				"the "+group+" rules don't allow "+client.IP.String())
+			return
+		}
+
+		if err := netaccess.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Failed to save network rules", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Network rules saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
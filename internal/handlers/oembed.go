@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/secheaders"
+	"wiki-go/internal/signing"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// embedTokenPurpose tells embed tokens apart from other signed tokens
+const embedTokenPurpose = "embed"
+
+// embedClaims grant read access to one page, or one section of it, without a login
+type embedClaims struct {
+	signing.Claims
+	Path    string `json:"p"`
+	Section string `json:"s,omitempty"`
+}
+
+// EmbedPage holds the data for the chrome-less view of a page shown in frames
+type EmbedPage struct {
+	Title   string
+	Content template.HTML
+	Config  *config.Config
+	Path    string
+	Theme   string
+}
+
+// headingTagRegex matches the opening tag of a heading
+var headingTagRegex = regexp.MustCompile(`<h([1-6])(\s[^>]*)?>`)
+
+// embedToken returns the claims of the embed token of a request, when it is
+// valid for the page at docPath
+func embedToken(r *http.Request, docPath string) (embedClaims, bool) {
+	var claims embedClaims
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return claims, false
+	}
+	if err := signing.Verify(cfg.Wiki.RootDir, token, embedTokenPurpose, &claims); err != nil {
+		return claims, false
+	}
+	return claims, claims.Path == docPath
+}
+
+// embedTokenAllowsFile reports whether the embed token of a request grants
+// access to an attachment, given by its path below /api/files/
+func embedTokenAllowsFile(r *http.Request, filePath string) bool {
+	docPath := filepath.ToSlash(filepath.Dir(filePath))
+	if docPath == "pages/home" {
+		docPath = ""
+	}
+	_, ok := embedToken(r, docPath)
+	return ok
+}
+
+// embedPath returns the decoded page path of a request below prefix
+func embedPath(r *http.Request, prefix string) (string, bool) {
+	decoded, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, prefix))
+	if err != nil {
+		return "", false
+	}
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+decoded)), "/"), true
+}
+
+// canEmbed reports whether a request may show the page at docPath in a frame,
+// with an embed token or the permissions of the viewer, and returns the section
+// the view is limited to
+func canEmbed(r *http.Request, docPath string) (string, bool) {
+	section := r.URL.Query().Get("section")
+	if claims, ok := embedToken(r, docPath); ok {
+		if claims.Section != "" {
+			section = claims.Section
+		}
+		return section, true
+	}
+	return section, auth.RequireAuth(r, cfg) && canViewPath(r, docPath)
+}
+
+// EmbedHandler serves /embed/{path}, a page without navigation or controls to be
+// shown in frames on other sites. ?section= limits it to the section below a
+// heading, ?theme=dark switches to the dark theme and ?token= carries an embed
+// token for pages the viewer could not read otherwise.
+func EmbedHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	docPath, ok := embedPath(r, "/embed")
+	if !ok {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	section, allowed := canEmbed(r, docPath)
+	if !allowed {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	content, err := encryption.ReadFile(filepath.Join(pageDir(cfg, docPath), "document.md"))
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	visibleContent := goldext.FilterAudience(string(content), requestSubject(r))
+	visibleContent = goldext.ExpandEmbeds(visibleContent, docPath, embeddedPageLoader(r))
+	var rendered string
+	if !renderLimited(w, func() {
+		rendered = string(utils.RenderMarkdownWithPath(visibleContent, docPath))
+	}) {
+		return
+	}
+	if section != "" {
+		if rendered, ok = htmlSection(rendered, section); !ok {
+			http.Error(w, "Section not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// Attachments need the token as well when the viewer can't read the page
+	if token := r.URL.Query().Get("token"); token != "" {
+		prefix := `src="/api/files/` + docPath + `/`
+		if docPath == "" {
+			prefix = `src="/api/files/pages/home/`
+		}
+		rendered = regexp.MustCompile(regexp.QuoteMeta(prefix)+`([^"?]*)"`).ReplaceAllString(rendered, prefix+`$1?token=`+url.QueryEscape(token)+`"`)
+	}
+
+	title := cfg.Wiki.Title
+	if docPath != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, docPath))
+	}
+	theme := "light"
+	if r.URL.Query().Get("theme") == "dark" {
+		theme = "dark"
+	}
+	data := EmbedPage{
+		Title:   title,
+		Content: template.HTML(rendered),
+		Config:  cfg,
+		Path:    "/" + docPath,
+		Theme:   theme,
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset":         assetURL,
+		"mermaidScript": mermaidScript,
+		"mermaidConfig": mermaidConfig,
+	}
+
+	tmpl, err := template.New("embed.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/embed.html")
+	if err != nil {
+		http.Error(w, "Error loading embed template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	secheaders.AllowFraming(w, cfg)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering embed template: %v", err)
+	}
+}
+
+// htmlSection returns the part of rendered HTML from the heading with id up to
+// the next heading of the same or a higher level
+func htmlSection(rendered, id string) (string, bool) {
+	headings := headingTagRegex.FindAllStringSubmatchIndex(rendered, -1)
+	for i, loc := range headings {
+		if loc[4] < 0 || !strings.Contains(rendered[loc[4]:loc[5]], ` id="`+html.EscapeString(id)+`"`) {
+			continue
+		}
+		level := rendered[loc[2]]
+		end := len(rendered)
+		for _, next := range headings[i+1:] {
+			if rendered[next[2]] <= level {
+				end = next[0]
+				break
+			}
+		}
+		return rendered[loc[0]:end], true
+	}
+	return "", false
+}
+
+// OEmbedHandler handles GET /api/oembed?url=, the oEmbed endpoint for pages of
+// the wiki. The answer is a frame showing the /embed view of the page, its
+// section and token taken from the url.
+func OEmbedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		http.Error(w, "Only JSON is supported", http.StatusNotImplemented)
+		return
+	}
+
+	target, err := url.Parse(r.URL.Query().Get("url"))
+	if err != nil || (target.Host != "" && target.Host != r.Host) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	// The url names a page or its embed view; its query is checked like a request for the view
+	page := &http.Request{URL: target, Header: r.Header}
+	page = page.WithContext(r.Context())
+	prefix := ""
+	if strings.HasPrefix(target.Path, "/embed/") || target.Path == "/embed" {
+		prefix = "/embed"
+	}
+	docPath, ok := embedPath(page, prefix)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	section, allowed := canEmbed(page, docPath)
+	if !allowed {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(pageDir(cfg, docPath), "document.md")); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	query := url.Values{}
+	if section != "" {
+		query.Set("section", section)
+	}
+	if token := target.Query().Get("token"); token != "" {
+		query.Set("token", token)
+	}
+	src := getBaseURL(r, cfg) + "/embed/" + docPath
+	if len(query) > 0 {
+		src += "?" + query.Encode()
+	}
+
+	width := oembedSize(r.URL.Query().Get("maxwidth"), 800)
+	height := oembedSize(r.URL.Query().Get("maxheight"), 600)
+	title := cfg.Wiki.Title
+	if docPath != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, docPath))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":       "1.0",
+		"type":          "rich",
+		"title":         title,
+		"provider_name": cfg.Wiki.Title,
+		"provider_url":  getBaseURL(r, cfg) + "/",
+		"width":         width,
+		"height":        height,
+		"html":          embedFrame(src, title, width, height),
+	})
+}
+
+// oembedSize returns the size requested by a maxwidth or maxheight parameter,
+// limited to the default
+func oembedSize(value string, fallback int) int {
+	if size, err := strconv.Atoi(value); err == nil && size > 0 && size < fallback {
+		return size
+	}
+	return fallback
+}
+
+// embedFrame returns the HTML of a frame showing an embed view
+func embedFrame(src, title string, width, height int) string {
+	return fmt.Sprintf(`<iframe src="%s" title="%s" width="%d" height="%d" style="border:0" loading="lazy"></iframe>`,
+		html.EscapeString(src), html.EscapeString(title), width, height)
+}
+
+// EmbedTokenHandler handles POST /api/embed/token, issuing a token that lets
+// anybody with the frame code see a page, or one section of it. Only users who
+// can read the page get tokens for it.
+func EmbedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req struct {
+		Path    string `json:"path"`
+		Section string `json:"section"`
+		Days    int    `json:"days"` // 0 for a token that doesn't expire
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(pageDir(cfg, docPath), "document.md")); err != nil {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+
+	claims := embedClaims{
+		Claims:  signing.Claims{Purpose: embedTokenPurpose},
+		Path:    docPath,
+		Section: req.Section,
+	}
+	if req.Days > 0 {
+		claims.Expires = time.Now().AddDate(0, 0, req.Days).Unix()
+	}
+	token, err := signing.Sign(cfg.Wiki.RootDir, claims)
+	if err != nil {
+		sendJSONError(w, "Failed to create token", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := url.Values{"token": {token}}
+	if req.Section != "" {
+		query.Set("section", req.Section)
+	}
+	src := getBaseURL(r, cfg) + "/embed/" + docPath + "?" + query.Encode()
+	title := cfg.Wiki.Title
+	if docPath != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, docPath))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"token":   token,
+		"url":     src,
+		"html":    embedFrame(src, title, 800, 600),
+	})
+}
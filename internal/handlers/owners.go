@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/watch"
+)
+
+// OwnersPayload represents the raw YAML ownership rules
+type OwnersPayload struct {
+	Content string `json:"content"`
+}
+
+// OwnersSettingsHandler handles GET (read) and POST/PUT (update) of the ownership rules
+func OwnersSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := ownership.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read owners", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"content": content,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req OwnersPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := ownership.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Invalid owners", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Owners saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// OwnersHandler handles GET /api/owners/{path} and returns the owners of a page
+// together with the users they expand to. An empty path refers to the home page.
+func OwnersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	path, err := url.PathUnescape(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/owners"), "/"))
+	if err != nil || strings.Contains(path, "..") {
+		sendJSONError(w, "Invalid path", http.StatusBadRequest, "")
+		return
+	}
+
+	file, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		sendJSONError(w, "Failed to load owners", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	owners := file.Owners(path, pageOwners(documentFile(path)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"path":    "/" + path,
+		"owners":  owners,
+		"users":   file.Users(owners, cfg.Users),
+	})
+}
+
+// documentFile returns the document.md of a page path, the home page for ""
+func documentFile(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md")
+	}
+	return filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, path, "document.md")
+}
+
+// pageOwners returns the owners set in the frontmatter of a document
+func pageOwners(docFile string) []string {
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return nil
+	}
+	metadata, _, _ := frontmatter.Parse(string(content))
+	return metadata.Owners
+}
+
+// resolvePageOwners returns the effective owners of a page, logging load errors
+func resolvePageOwners(path string, frontmatterOwners []string) []string {
+	file, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading owners: %v", err)
+		return frontmatterOwners
+	}
+	return file.Owners(path, frontmatterOwners)
+}
+
+// notifyOwnersOfChange notifies the owners of a page, and the users watching it,
+// that actor changed it
+func notifyOwnersOfChange(path, actor string) error {
+	file, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		return err
+	}
+
+	path = strings.Trim(path, "/")
+	docFile := documentFile(path)
+	recipients := file.Users(file.Owners(path, pageOwners(docFile)), cfg.Users)
+	watchers, err := watch.Watchers(cfg.Wiki.RootDir, path)
+	if err != nil {
+		return err
+	}
+	rules := loadPermissions()
+	for _, user := range watchers {
+		// Watchers who lost access to the page are not told about it
+		if rules == nil || !rules.CanView(path, subjectFor(user, permissions.UserRole(cfg, user))) {
+			continue
+		}
+		if !containsString(recipients, user) {
+			recipients = append(recipients, user)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	title := cfg.Wiki.Title
+	if path != "" {
+		title = utils.GetDocumentTitle(filepath.Dir(docFile))
+	}
+	message := fmt.Sprintf("%s edited %s", actor, title)
+
+	for _, user := range recipients {
+		if user == actor {
+			continue
+		}
+		if err := notifications.Add(cfg.Wiki.RootDir, user, message, "/"+path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -7,13 +7,20 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"wiki-go/internal/auth"
 	"wiki-go/internal/comments"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/goldext"
 	"wiki-go/internal/i18n"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/review"
 	"wiki-go/internal/types"
 	"wiki-go/internal/utils"
 	"wiki-go/internal/frontmatter"
@@ -43,22 +50,29 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
-	// Build navigation
-	nav, err := utils.BuildNavigation(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	// Check per-directory permissions before revealing anything about the page
+	if !canViewPath(r, decodedPath) {
+		denyView(w, r)
+		return
+	}
+
+	// Build navigation, expanded along the current path
+	nav, err := pageNavigation(r, path)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Mark active navigation item
-	utils.MarkActiveNavItem(nav, path)
-
 	// Get the full filesystem path - adjust to use documents subdirectory
 	fsPath := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, decodedPath)
 
 	// Check if path exists
 	info, err := os.Stat(fsPath)
 	if err != nil || !info.IsDir() {
+		// Missing pages may be permalinks or have been replaced by a redirect
+		if followPermalink(w, r, decodedPath) || followRedirect(w, r, decodedPath) {
+			return
+		}
 		NotFoundHandler(w, r, cfg)
 		return
 	}
@@ -79,13 +93,20 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	var content template.HTML
 	var lastModified time.Time
 	var dirContent template.HTML
-
-	// Look for document.md in the directory
+	var stats *pagestats.Stats
+	var reviewStatus *review.Status
+	var owners []string
+	var tags []string
+	var draft bool
+	var redirectsTo string
+
+	// Look for document.md in the directory, the reader may be shown one of its translations
+	variant := choosePageLanguage(w, r, fsPath, "documents/"+strings.Trim(decodedPath, "/"))
 	docPath := filepath.Join(fsPath, "document.md")
 	docInfo, err := os.Stat(docPath)
 	if err == nil {
 		// Read and render document.md if it exists
-		mdContent, err := os.ReadFile(docPath)
+		mdContent, err := encryption.ReadFile(variant.File)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -98,12 +119,41 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 			documentLayout = metadata.Layout
 		}
 
+		// Redirect pages send their readers on, ?redirect=no shows them instead
+		if metadata.Redirect != "" {
+			if followRedirect(w, r, decodedPath) {
+				return
+			}
+			redirectsTo = metadata.Redirect
+		}
+
+		// Remove conditional content the viewer is not allowed to see
+		visibleContent := goldext.FilterAudience(string(mdContent), requestSubject(r))
+		visibleContent = goldext.ExpandEmbeds(visibleContent, decodedPath, embeddedPageLoader(r))
+		variant.Dir = goldext.PageDirection(visibleContent, variant.Language)
+
 		// Use the document path for rendering to handle local file references
-		content = template.HTML(utils.RenderMarkdownWithPath(string(mdContent), decodedPath))
+		if !renderLimited(w, func() {
+			content = template.HTML(utils.RenderMarkdownWithPath(visibleContent, decodedPath))
+		}) {
+			return
+		}
 		lastModified = docInfo.ModTime()
+		if variantInfo, err := os.Stat(variant.File); err == nil {
+			lastModified = variantInfo.ModTime()
+		}
 
 		// Update the document layout in the page data
 		navItem.DocumentLayout = documentLayout
+
+		stats = loadPageStats("documents/"+strings.Trim(decodedPath, "/"), docPath)
+		reviewStatus = review.Check(string(mdContent), docInfo.ModTime(), time.Now())
+		owners = resolvePageOwners(decodedPath, metadata.Owners)
+		tags = frontmatter.NormalizeTags(metadata.Tags)
+		draft = metadata.Draft
+		if reviewStatus != nil {
+			reviewStatus.Owners = owners
+		}
 	}
 
 	// List directory contents
@@ -113,30 +163,55 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
-	// Build directory listing HTML
-	var dirItems []string
+	// Keep subdirectories the user may read, hidden ones and document.md are skipped
+	var dirNames []string
+	allowed := listingFilter(r, decodedPath)
 	for _, f := range files {
 		if !f.IsDir() || strings.HasPrefix(f.Name(), ".") || f.Name() == "document.md" {
-			continue // Skip non-directories, hidden files, and document.md
+			continue
+		}
+		if allowed != nil && !allowed(filepath.ToSlash(filepath.Join(path, f.Name()))) {
+			continue
 		}
+		dirNames = append(dirNames, f.Name())
+	}
+
+	// Pinned pages come first, then the others in name order
+	ranks := pinRanks()
+	sort.SliceStable(dirNames, func(i, j int) bool {
+		return pinnedBefore(ranks, decodedPath+"/"+dirNames[i], decodedPath+"/"+dirNames[j])
+	})
 
-		dirName := f.Name()
+	// Only the titles of the requested page of the listing are read
+	dirNames, dirPagination := paginate(dirNames, r)
+
+	// Build directory listing HTML
+	var dirItems []string
+	for _, dirName := range dirNames {
 		urlPath := filepath.Join(path, dirName)
+		pinClass := ""
+		if _, pinned := ranks[strings.Trim(decodedPath+"/"+dirName, "/")]; pinned {
+			pinClass = " pinned"
+		}
 
 		// Check if subdirectory has a document.md
 		subDocPath := filepath.Join(fsPath, dirName, "document.md")
 		if _, err := os.Stat(subDocPath); err == nil {
 			// Use the GetDocumentTitle function which includes emoji processing
 			dirTitle := utils.GetDocumentTitle(filepath.Join(fsPath, dirName))
-			dirItems = append(dirItems, fmt.Sprintf(`<div class="directory-item is-dir"><a href="%s">%s</a></div>`,
-				urlPath, dirTitle))
+			summary := ""
+			if text := excerpt.Get(cfg, subDocPath); text != "" {
+				summary = fmt.Sprintf(`<p class="directory-excerpt" dir="auto">%s</p>`, template.HTMLEscapeString(text))
+			}
+			dirItems = append(dirItems, fmt.Sprintf(`<div class="directory-item is-dir%s" data-path="%s"><a href="%s">%s</a>%s</div>`,
+				pinClass, template.HTMLEscapeString(urlPath), urlPath, dirTitle, summary))
 			continue
 		}
 
 		// Fallback to formatted directory name if no document.md or no title found
 		dirTitle := utils.FormatDirName(dirName)
-		dirItems = append(dirItems, fmt.Sprintf(`<div class="directory-item is-dir"><a href="%s">%s</a></div>`,
-			urlPath, dirTitle))
+		dirItems = append(dirItems, fmt.Sprintf(`<div class="directory-item is-dir%s" data-path="%s"><a href="%s">%s</a></div>`,
+			pinClass, template.HTMLEscapeString(urlPath), urlPath, dirTitle))
 	}
 
 	if len(dirItems) > 0 {
@@ -175,7 +250,7 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 			commentsAllowed = false
 		} else {
 			// Only check document-specific settings if system allows comments
-			mdContent, _ := os.ReadFile(docPath)
+			mdContent, _ := encryption.ReadFile(docPath)
 			commentsAllowed = comments.AreCommentsAllowed(string(mdContent))
 
 			// Only load comments if they're allowed
@@ -185,7 +260,7 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 				// Process comments (render markdown, format timestamps)
 				for i := range commentsList {
 					// Use template.HTML to properly render the HTML without escaping
-					commentsList[i].RenderedHTML = template.HTML(utils.RenderMarkdown(commentsList[i].Content))
+					commentsList[i].RenderedHTML = template.HTML(utils.RenderUntrustedMarkdown(commentsList[i].Content))
 					commentsList[i].FormattedTime = comments.FormatCommentTime(commentsList[i].Timestamp)
 				}
 			}
@@ -197,6 +272,7 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		Navigation:         nav,
 		Content:            content,
 		DirContent:         dirContent,
+		DirPagination:      dirPagination,
 		Breadcrumbs:        breadcrumbs,
 		Config:             cfg,
 		LastModified:       lastModified,
@@ -208,9 +284,62 @@ func PageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		UserRole:           userRole,
 		DocPath:            decodedPath,
 		DocumentLayout:     navItem.DocumentLayout,
+		Stats:              stats,
+		Review:             reviewStatus,
+		Owners:             owners,
+		SyncedFrom:         syncedFrom(decodedPath),
+		Tags:               tags,
+		Draft:              draft,
+		Archived:           pageArchive(decodedPath),
+		RedirectsTo:        redirectsTo,
+		RedirectedFrom:     redirectedFrom(r, decodedPath),
+		Visibility:         pageVisibility(decodedPath),
+		Description:        excerpt.Get(cfg, variant.File),
 	}
+	variant.apply(data)
+	applyRenderSettings(data, decodedPath)
+	data.Social = socialMeta(r, decodedPath, variant.File, navItem.Title, data.Description, lastModified)
+
+	renderTemplate(w, r, data)
+}
+
+// dirPageSize is the number of subdirectories listed per page of a directory
+const dirPageSize = 100
 
-	renderTemplate(w, data)
+// paginate returns the names on the page requested by the "page" query parameter
+// and the pagination of the listing, nil when it fits on one page
+func paginate(names []string, r *http.Request) ([]string, *types.Pagination) {
+	if len(names) <= dirPageSize {
+		return names, nil
+	}
+
+	pages := (len(names) + dirPageSize - 1) / dirPageSize
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+
+	pageURL := func(n int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(n))
+		return r.URL.Path + "?" + query.Encode()
+	}
+	pagination := &types.Pagination{Page: page, Pages: pages}
+	if page > 1 {
+		pagination.PrevURL = pageURL(page - 1)
+	}
+	if page < pages {
+		pagination.NextURL = pageURL(page + 1)
+	}
+
+	end := page * dirPageSize
+	if end > len(names) {
+		end = len(names)
+	}
+	return names[(page-1)*dirPageSize : end], pagination
 }
 
 // generateBreadcrumbs creates a breadcrumb trail from a path
@@ -0,0 +1,612 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/pagequery"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/review"
+	"wiki-go/internal/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PageMetadata is the metadata of a page for dashboards and reports
+type PageMetadata struct {
+	Path        string                 `json:"path"` // URL path of the page, "/" for the home page
+	Title       string                 `json:"title"`
+	Frontmatter map[string]interface{} `json:"frontmatter"`
+	Tags        []string               `json:"tags"`
+	Modified    time.Time              `json:"modified"`
+	LastEditor  string                 `json:"lastEditor,omitempty"`
+	Owners      []string               `json:"owners"` // From the frontmatter, or else the ownership rules
+	Review      *PageReview            `json:"review,omitempty"`
+	Archived    bool                   `json:"archived,omitempty"`
+	Computed    map[string]interface{} `json:"computed,omitempty"` // Values of the computed columns of the query
+	Group       string                 `json:"group,omitempty"`    // Value of the group expression of the query
+}
+
+// PageReview is the review state of a page
+type PageReview struct {
+	Every        string     `json:"every,omitempty"`        // Review interval, e.g. 90d
+	LastReviewed *time.Time `json:"lastReviewed,omitempty"` // Unset when the page was never marked reviewed
+	Due          *time.Time `json:"due,omitempty"`
+	Overdue      bool       `json:"overdue"`
+}
+
+// metadataFilter holds the conditions of GET /api/pages/metadata
+type metadataFilter struct {
+	dir              string   // Pages at or below this path
+	tags             []string // Pages with all these tags
+	owner            string
+	fields           [][2]string // Frontmatter keys with a value, or just set when the value is empty
+	reviewedSince    time.Time   // Marked reviewed on or after
+	notReviewedSince time.Time   // Not marked reviewed since
+	modifiedSince    time.Time
+	overdue          string // "1" for overdue pages, "0" for pages with a review interval that aren't
+	includeArchived  bool
+	condition        *pagequery.Expr // Pages for which this expression is true
+}
+
+// computedColumn is a column of a query whose values an expression computes
+type computedColumn struct {
+	label string
+	expr  *pagequery.Expr
+}
+
+// sinceInterval returns the time an interval like 90d, 12w, 6m or 1y before now
+func sinceInterval(value string, now time.Time) (time.Time, error) {
+	years, months, days, err := review.ParseInterval(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.AddDate(-years, -months, -days), nil
+}
+
+// parseMetadataFilter reads the conditions from the query of a request
+func parseMetadataFilter(r *http.Request, now time.Time) (metadataFilter, error) {
+	query := r.URL.Query()
+	filter := metadataFilter{
+		dir:             strings.Trim(filepath.ToSlash(filepath.Clean("/"+query.Get("path"))), "/"),
+		owner:           query.Get("owner"),
+		overdue:         query.Get("overdue"),
+		includeArchived: query.Get("archived") == "1",
+	}
+	for _, tag := range query["tag"] {
+		filter.tags = append(filter.tags, frontmatter.NormalizeTags([]string{tag})...)
+	}
+	if filter.owner == "me" {
+		filter.owner = ""
+		if session := auth.GetSession(r); session != nil {
+			filter.owner = session.Username
+		}
+	}
+	for _, field := range query["field"] {
+		key, value, _ := strings.Cut(field, ":")
+		if key = strings.TrimSpace(key); key == "" {
+			return filter, fmt.Errorf("invalid field condition %q, use key or key:value", field)
+		}
+		filter.fields = append(filter.fields, [2]string{key, strings.TrimSpace(value)})
+	}
+
+	intervals := []struct {
+		name   string
+		target *time.Time
+	}{
+		{"reviewed_within", &filter.reviewedSince},
+		{"not_reviewed_within", &filter.notReviewedSince},
+		{"modified_within", &filter.modifiedSince},
+	}
+	for _, interval := range intervals {
+		if value := query.Get(interval.name); value != "" {
+			since, err := sinceInterval(value, now)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %v", interval.name, err)
+			}
+			*interval.target = since
+		}
+	}
+	if filter.overdue != "" && filter.overdue != "0" && filter.overdue != "1" {
+		return filter, fmt.Errorf("overdue must be 0 or 1")
+	}
+	if value := query.Get("filter"); value != "" {
+		expr, err := pagequery.Compile(value)
+		if err != nil {
+			return filter, fmt.Errorf("filter: %v", err)
+		}
+		filter.condition = expr
+	}
+	return filter, nil
+}
+
+// parseComputedColumns reads the compute=label=expression columns of a query
+func parseComputedColumns(r *http.Request) ([]computedColumn, error) {
+	var columns []computedColumn
+	for _, value := range r.URL.Query()["compute"] {
+		label, source, ok := strings.Cut(value, "=")
+		label = strings.TrimSpace(label)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("invalid computed column %q, use label=expression", value)
+		}
+		expr, err := pagequery.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("computed column %q: %v", label, err)
+		}
+		columns = append(columns, computedColumn{label: label, expr: expr})
+	}
+	return columns, nil
+}
+
+// metadataLookup returns the values the expressions of a query see for a
+// page: its computed columns, its metadata and its frontmatter fields
+func metadataLookup(page *PageMetadata) pagequery.Lookup {
+	return func(name string) interface{} {
+		if value, ok := page.Computed[name]; ok {
+			return value
+		}
+		switch name {
+		case "path":
+			return page.Path
+		case "title":
+			return page.Title
+		case "modified":
+			return page.Modified
+		case "last_editor":
+			if page.LastEditor == "" {
+				return nil
+			}
+			return page.LastEditor
+		case "owners":
+			return page.Owners
+		case "tags":
+			return page.Tags
+		case "archived":
+			return page.Archived
+		case "review_every", "last_reviewed", "review_due", "overdue":
+			if page.Review == nil {
+				return nil
+			}
+			switch name {
+			case "review_every":
+				return page.Review.Every
+			case "last_reviewed":
+				return page.Review.LastReviewed
+			case "review_due":
+				return page.Review.Due
+			}
+			return page.Review.Overdue
+		}
+		return page.Frontmatter[name]
+	}
+}
+
+// fieldMatches reports whether a frontmatter value is value, or contains it when
+// it is a list. An empty value matches any value that is set.
+func fieldMatches(current interface{}, value string) bool {
+	if current == nil {
+		return false
+	}
+	if list, ok := current.([]interface{}); ok {
+		for _, item := range list {
+			if fieldMatches(item, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return value == "" || strings.EqualFold(frontmatterText(current), value)
+}
+
+// ownedBy reports whether owner is among the owners of a page, by name or as
+// a member of an owning group or role
+func ownedBy(owners *ownership.File, pageOwners []string, owner string) bool {
+	if containsString(pageOwners, owner) {
+		return true
+	}
+	return owners != nil && containsString(owners.Users(pageOwners, cfg.Users), owner)
+}
+
+// matches reports whether a page meets the conditions of the filter
+func (f metadataFilter) matches(page PageMetadata, owners *ownership.File) bool {
+	path := strings.Trim(page.Path, "/")
+	if f.dir != "" && path != f.dir && !strings.HasPrefix(path, f.dir+"/") {
+		return false
+	}
+	if page.Archived && !f.includeArchived {
+		return false
+	}
+	for _, tag := range f.tags {
+		if !containsString(page.Tags, tag) {
+			return false
+		}
+	}
+	if f.owner != "" && !ownedBy(owners, page.Owners, f.owner) {
+		return false
+	}
+	for _, field := range f.fields {
+		if !fieldMatches(page.Frontmatter[field[0]], field[1]) {
+			return false
+		}
+	}
+	if !f.modifiedSince.IsZero() && page.Modified.Before(f.modifiedSince) {
+		return false
+	}
+
+	var lastReviewed *time.Time
+	if page.Review != nil {
+		lastReviewed = page.Review.LastReviewed
+	}
+	if !f.reviewedSince.IsZero() && (lastReviewed == nil || lastReviewed.Before(f.reviewedSince)) {
+		return false
+	}
+	if !f.notReviewedSince.IsZero() && lastReviewed != nil && !lastReviewed.Before(f.notReviewedSince) {
+		return false
+	}
+	if f.overdue != "" {
+		if page.Review == nil || page.Review.Every == "" || page.Review.Overdue != (f.overdue == "1") {
+			return false
+		}
+	}
+	return true
+}
+
+// readPageMetadata reads the metadata of the page at path from its document
+func readPageMetadata(r *http.Request, owners *ownership.File, path, docFile string, now time.Time) (PageMetadata, bool) {
+	info, err := os.Stat(docFile)
+	if err != nil || info.IsDir() {
+		return PageMetadata{}, false
+	}
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return PageMetadata{}, false
+	}
+	source := goldext.FilterAudience(string(content), requestSubject(r))
+
+	page := PageMetadata{
+		Path:        "/" + path,
+		Title:       cfg.Wiki.Title,
+		Frontmatter: map[string]interface{}{},
+		Tags:        []string{},
+		Modified:    info.ModTime(),
+	}
+	if path != "" {
+		page.Title = utils.GetDocumentTitle(filepath.Dir(docFile))
+	}
+
+	meta, _, ok := frontmatter.Parse(source)
+	if ok {
+		if tags := frontmatter.NormalizeTags(meta.Tags); tags != nil {
+			page.Tags = tags
+		}
+		yaml.Unmarshal([]byte(frontmatter.Extract(source)), &page.Frontmatter)
+	}
+	page.Owners = meta.Owners
+	if owners != nil {
+		page.Owners = owners.Owners(page.Path, meta.Owners)
+	}
+	if page.Owners == nil {
+		page.Owners = []string{}
+	}
+
+	if meta.ReviewEvery != "" || meta.LastReviewed != "" {
+		page.Review = &PageReview{Every: meta.ReviewEvery}
+		if t, err := time.ParseInLocation(review.DateFormat, meta.LastReviewed, now.Location()); err == nil {
+			page.Review.LastReviewed = &t
+		}
+		if status := review.Check(source, info.ModTime(), now); status != nil {
+			page.Review.Due = &status.Due
+			page.Review.Overdue = status.Overdue
+		}
+	}
+
+	relativePath := pageVersionPath(path)
+	if history, err := pagestats.LoadHistory(cfg.Wiki.RootDir, relativePath); err == nil && len(history) > 0 {
+		page.LastEditor = history[len(history)-1].User
+	}
+	if path != "" {
+		_, page.Archived = archivedEntry(path)
+	}
+	return page, true
+}
+
+// PagesMetadataHandler handles GET /api/pages/metadata, the frontmatter,
+// modification time, last editor, owners, tags and review state of the pages
+// the user may see, for dashboards and compliance reports. The query narrows
+// the pages down:
+//   - path=dir: pages at or below dir
+//   - tag=x: pages with the tag, repeated for several tags
+//   - owner=name: pages owned by name, directly or through a group or role,
+//     "me" for the current user
+//   - field=key or field=key:value: pages with the frontmatter key set, or set
+//     to value (or to a list containing it), repeated for several keys
+//   - reviewed_within=90d, not_reviewed_within=90d: pages marked reviewed, or
+//     not, within the interval
+//   - modified_within=30d: pages changed within the interval
+//   - overdue=1 or overdue=0: pages with a review interval that are overdue, or not
+//   - archived=1: include archived pages
+//
+// Expressions add to the conditions, see package pagequery:
+//   - compute=label=expression: a computed column, in computed of each page
+//   - filter=expression: pages for which the expression is true
+//   - group=expression: pages grouped by the value, in group of each page
+//
+// Pages are sorted by path, or by the key given in sort=, "-key" for descending
+// order, then by group, and limit= keeps the first ones.
+//
+// format=csv returns a spreadsheet, with the frontmatter keys given in columns=
+// as extra columns.
+func PagesMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	now := time.Now()
+	filter, err := parseMetadataFilter(r, now)
+	if err != nil {
+		sendJSONError(w, "Invalid filter", http.StatusBadRequest, err.Error())
+		return
+	}
+	computed, err := parseComputedColumns(r)
+	if err != nil {
+		sendJSONError(w, "Invalid filter", http.StatusBadRequest, err.Error())
+		return
+	}
+	var group *pagequery.Expr
+	if value := r.URL.Query().Get("group"); value != "" {
+		if group, err = pagequery.Compile(value); err != nil {
+			sendJSONError(w, "Invalid filter", http.StatusBadRequest, "group: "+err.Error())
+			return
+		}
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			sendJSONError(w, "Invalid limit", http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+
+	owners, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading owners: %v", err)
+		owners = nil
+	}
+
+	pages := []PageMetadata{}
+	groups := make(map[string]interface{}) // Group of each page by path
+	add := func(path, docFile string) {
+		if !canViewPath(r, path) {
+			return
+		}
+		page, ok := readPageMetadata(r, owners, path, docFile, now)
+		if !ok || !filter.matches(page, owners) {
+			return
+		}
+		// Computed columns can use the columns before them
+		lookup := metadataLookup(&page)
+		for _, column := range computed {
+			if page.Computed == nil {
+				page.Computed = make(map[string]interface{})
+			}
+			page.Computed[column.label] = column.expr.Eval(lookup, now)
+		}
+		if filter.condition != nil && !pagequery.Truthy(filter.condition.Eval(lookup, now)) {
+			return
+		}
+		if group != nil {
+			value := group.Eval(lookup, now)
+			groups[page.Path] = value
+			page.Group = pagequery.Text(value)
+		}
+		pages = append(pages, page)
+	}
+	add("", documentFile(""))
+
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != docsDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() != "document.md" {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		add(filepath.ToSlash(rel), path)
+		return nil
+	})
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
+	if key := r.URL.Query().Get("sort"); key != "" {
+		sortMetadata(pages, key)
+	}
+	// Groups follow each other, their pages in the order they are sorted
+	if group != nil {
+		sort.SliceStable(pages, func(i, j int) bool {
+			return lessValue(groups[pages[i].Path], groups[pages[j].Path], false)
+		})
+	}
+	total := len(pages)
+	if limit > 0 && len(pages) > limit {
+		pages = pages[:limit]
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Query().Get("format") == "csv" {
+		writeMetadataCSV(w, pages, r.URL.Query()["columns"], computed, group != nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"count":   len(pages),
+		"total":   total,
+		"pages":   pages,
+	})
+}
+
+// lessValue orders the values of pages for sorting, pages without a value
+// last in either order
+func lessValue(a, b interface{}, descending bool) bool {
+	a, b = emptyValue(a), emptyValue(b)
+	if a == nil || b == nil {
+		return a != nil && b == nil
+	}
+	if descending {
+		return pagequery.Compare(a, b) > 0
+	}
+	return pagequery.Compare(a, b) < 0
+}
+
+// emptyValue returns nil for values that aren't set
+func emptyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case string:
+		if v == "" {
+			return nil
+		}
+	}
+	return value
+}
+
+// sortMetadata sorts pages by title, modified, last_editor, review_due,
+// last_reviewed, a computed column or a frontmatter key, in descending order
+// when the key starts with "-". Numbers are compared as numbers, pages without
+// a value come last and pages with the same value keep their order.
+func sortMetadata(pages []PageMetadata, key string) {
+	descending := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	values := make([]interface{}, len(pages))
+	for i := range pages {
+		values[i] = metadataLookup(&pages[i])(key)
+	}
+	index := make([]int, len(pages))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		return lessValue(values[index[i]], values[index[j]], descending)
+	})
+	sorted := make([]PageMetadata, len(pages))
+	for i, from := range index {
+		sorted[i] = pages[from]
+	}
+	copy(pages, sorted)
+}
+
+// writeMetadataCSV writes pages as CSV, one row each, with the frontmatter keys
+// in columns, the computed columns and the group as extra columns
+func writeMetadataCSV(w http.ResponseWriter, pages []PageMetadata, columns []string, computed []computedColumn, grouped bool) {
+	var keys []string
+	for _, value := range columns {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="pages.csv"`)
+	out := csv.NewWriter(w)
+	header := []string{"path", "title", "modified", "last_editor", "owners", "tags", "review_every", "last_reviewed", "review_due", "overdue", "archived"}
+	header = append(header, keys...)
+	for _, column := range computed {
+		header = append(header, column.label)
+	}
+	if grouped {
+		header = append(header, "group")
+	}
+	out.Write(header)
+
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(review.DateFormat)
+	}
+	for _, page := range pages {
+		row := []string{
+			page.Path,
+			page.Title,
+			page.Modified.Format(time.RFC3339),
+			page.LastEditor,
+			strings.Join(page.Owners, ", "),
+			strings.Join(page.Tags, ", "),
+			"", "", "", "",
+			fmt.Sprint(page.Archived),
+		}
+		if page.Review != nil {
+			row[6] = page.Review.Every
+			row[7] = date(page.Review.LastReviewed)
+			row[8] = date(page.Review.Due)
+			if page.Review.Every != "" {
+				row[9] = fmt.Sprint(page.Review.Overdue)
+			}
+		}
+		for _, key := range keys {
+			row = append(row, frontmatterText(page.Frontmatter[key]))
+		}
+		for _, column := range computed {
+			row = append(row, pagequery.Text(page.Computed[column.label]))
+		}
+		if grouped {
+			row = append(row, page.Group)
+		}
+		out.Write(row)
+	}
+	out.Flush()
+}
+
+// frontmatterText writes a frontmatter value as text, lists joined with commas
+func frontmatterText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = frontmatterText(item)
+		}
+		return strings.Join(items, ", ")
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	case time.Time:
+		// Dates are written as they are in the frontmatter
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format(review.DateFormat)
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/pagestats"
+)
+
+// maxPageContributors is the number of recent contributors shown for a page
+const maxPageContributors = 5
+
+// loadPageStats computes the statistics of a document, logging instead of failing
+// so a page can always be rendered
+func loadPageStats(relativePath, docFile string) *pagestats.Stats {
+	stats, err := pagestats.Compute(cfg.Wiki.RootDir, relativePath, docFile, maxPageContributors)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error computing page stats for %s: %v", relativePath, err)
+		}
+		return nil
+	}
+	return stats
+}
+
+// PageStatsHandler handles GET /api/stats/{path} and returns the word count, reading
+// time, revision count and recent contributors of a document. An empty path
+// returns the statistics of the home page.
+func PageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	path, err := url.PathUnescape(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/stats"), "/"))
+	if err != nil || strings.Contains(path, "..") {
+		sendJSONError(w, "Invalid path", http.StatusBadRequest, "")
+		return
+	}
+
+	relativePath := "pages/home"
+	docFile := filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md")
+	if path != "" {
+		relativePath = "documents/" + path
+		docFile = filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, path, "document.md")
+	}
+
+	stats, err := pagestats.Compute(cfg.Wiki.RootDir, relativePath, docFile, maxPageContributors)
+	if err != nil {
+		if os.IsNotExist(err) {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		sendJSONError(w, "Failed to compute page statistics", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"path":    "/" + path,
+		"stats":   stats,
+	})
+}
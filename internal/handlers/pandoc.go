@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/pandoc"
+	"wiki-go/internal/utils"
+)
+
+// pandocConverter returns the converter of the configured binary or server
+func pandocConverter() pandoc.Converter {
+	timeout := cfg.Extensions.Pandoc.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 60
+	}
+	return pandoc.Converter{
+		Command:   cfg.Extensions.Pandoc.Command,
+		ServerURL: cfg.Extensions.Pandoc.ServerURL,
+		Timeout:   time.Duration(timeout) * time.Second,
+	}
+}
+
+// PandocImportHandler handles POST /api/pandoc/import, creating the page at
+// "path" from an uploaded document in a format pandoc reads. Images of the
+// document become attachments of the page.
+func PandocImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !cfg.Extensions.Pandoc.Enable {
+		sendJSONError(w, "Pandoc is not enabled", http.StatusNotFound, "")
+		return
+	}
+	if err := r.ParseMultipartForm(config.GetMaxUploadSizeBytes(cfg)); err != nil {
+		sendJSONError(w, "Failed to parse form or file too large", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	docPath := utils.SanitizePath(r.FormValue("path"))
+	if docPath == "" {
+		sendJSONError(w, "Path is required", http.StatusBadRequest, "")
+		return
+	}
+	if !canEditPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to create pages here")
+		return
+	}
+	dir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath)
+	if _, err := os.Stat(filepath.Join(dir, "document.md")); err == nil {
+		sendJSONError(w, "Document already exists", http.StatusConflict, "")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		sendJSONError(w, "No file provided", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		sendJSONError(w, "Failed to read file", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	markdown, media, err := pandocConverter().ToMarkdown(header.Filename, data)
+	if errors.Is(err, pandoc.ErrUnsupported) {
+		sendJSONError(w, "Unsupported file type", http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to convert document", http.StatusBadGateway, err.Error())
+		return
+	}
+
+	// Pages start with their title
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		title = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	}
+	if !strings.HasPrefix(strings.TrimLeft(markdown, "\n"), "# ") {
+		markdown = "# " + title + "\n\n" + strings.TrimLeft(markdown, "\n")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		sendJSONError(w, "Failed to create directories", http.StatusInternalServerError, err.Error())
+		return
+	}
+	warnings := make([]string, 0)
+	for _, m := range media {
+		name := sanitizeFilename(m.Name)
+		if err := writeAttachment(dir, name, m.Data, cfg); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", m.Name, err))
+			continue
+		}
+		if name != m.Name {
+			markdown = strings.ReplaceAll(markdown, "("+m.Name, "("+name)
+			markdown = strings.ReplaceAll(markdown, `"`+m.Name+`"`, `"`+name+`"`)
+		}
+	}
+	if err := encryption.WriteFile(filepath.Join(dir, "document.md"), []byte(markdown), 0644); err != nil {
+		sendJSONError(w, "Failed to create document", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if session := auth.GetSession(r); session != nil {
+		if err := pagestats.RecordEdit(cfg.Wiki.RootDir, "documents/"+docPath, session.Username); err != nil {
+			log.Printf("Error recording edit history for %s: %v", docPath, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"url":      "/" + docPath,
+		"warnings": warnings,
+	})
+}
+
+// PandocExportHandler handles GET /api/pandoc/export?path=&format=, sending
+// the page at path converted to a format of pandoc.ExportFormats
+func PandocExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	if !cfg.Extensions.Pandoc.Enable {
+		sendJSONError(w, "Pandoc is not enabled", http.StatusNotFound, "")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	export, ok := pandoc.ExportFormats[format]
+	if !ok {
+		sendJSONError(w, "Unsupported format", http.StatusBadRequest, format)
+		return
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !auth.RequireAuth(r, cfg) || !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+
+	dir := pageDir(cfg, docPath)
+	content, err := encryption.ReadFile(filepath.Join(dir, "document.md"))
+	if err != nil {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+	_, markdown, _ := frontmatter.Parse(string(content))
+
+	output, err := pandocConverter().FromMarkdown(markdown, format, utils.GetDocumentTitle(dir), dir)
+	if err != nil {
+		sendJSONError(w, "Failed to convert document", http.StatusBadGateway, err.Error())
+		return
+	}
+
+	name := path.Base(docPath)
+	if docPath == "" {
+		name = "home"
+	}
+	w.Header().Set("Content-Type", export.MimeType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, name, export.Extension))
+	w.Write(output)
+}
@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/signing"
+	"wiki-go/internal/webauthn"
+)
+
+// passkeyTimeout is the time the browser gives the user to use their authenticator
+const passkeyTimeout = int(webauthn.ChallengeTimeout / time.Millisecond)
+
+// An admin who must use a passkey but has none gets no session after their
+// password login, only a setup token in a cookie that the passkey registration
+// alone accepts. Registering the passkey logs them in.
+const (
+	passkeySetupCookie   = "passkey_setup"
+	passkeySetupPurpose  = "passkey-setup"
+	passkeySetupLifetime = 10 * time.Minute
+)
+
+// passkeySetupClaims are the claims of a passkey setup token
+type passkeySetupClaims struct {
+	signing.Claims
+	Username     string `json:"n"`
+	KeepLoggedIn bool   `json:"k,omitempty"`
+}
+
+// PasskeyInfo is a registered passkey as shown to its owner
+type PasskeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// PasskeyRegisterRequest finishes the registration of a passkey
+type PasskeyRegisterRequest struct {
+	Name     string                       `json:"name"`
+	Response webauthn.AttestationResponse `json:"response"`
+}
+
+// PasskeyLoginRequest starts a passkey login, optionally for a given user
+type PasskeyLoginRequest struct {
+	Username     string `json:"username"`
+	KeepLoggedIn bool   `json:"keepLoggedIn"`
+}
+
+// credentialDescriptor refers to a passkey in WebAuthn options
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// relyingParty returns the relying party of the wiki, from its configured public
+// URL rather than from the request
+func relyingParty() (webauthn.RelyingParty, error) {
+	return webauthn.NewRelyingParty(cfg.Server.URL)
+}
+
+// userHandle returns the opaque WebAuthn user ID of a username
+func userHandle(username string) string {
+	sum := sha256.Sum256([]byte("wiki-go:" + username))
+	return webauthn.Encoding.EncodeToString(sum[:16])
+}
+
+// credentialDescriptors lists the passkeys of a user for WebAuthn options
+func credentialDescriptors(username string) []credentialDescriptor {
+	descriptors := []credentialDescriptor{}
+	credentials, err := webauthn.List(cfg.Wiki.RootDir, username)
+	if err != nil {
+		log.Printf("Error loading passkeys of %s: %v", username, err)
+		return descriptors
+	}
+	for _, credential := range credentials {
+		descriptors = append(descriptors, credentialDescriptor{Type: "public-key", ID: credential.ID})
+	}
+	return descriptors
+}
+
+// passkeyLoginOptions starts a login ceremony and returns the options for
+// navigator.credentials.get(). An empty username allows any passkey.
+func passkeyLoginOptions(username string, keepLoggedIn bool) (map[string]interface{}, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := webauthn.StartCeremony(cfg.Wiki.RootDir, challenge, webauthn.Ceremony{Username: username, KeepLoggedIn: keepLoggedIn}); err != nil {
+		return nil, err
+	}
+
+	options := map[string]interface{}{
+		"challenge":        challenge,
+		"rpId":             rp.ID,
+		"timeout":          passkeyTimeout,
+		"userVerification": "preferred",
+	}
+	if username != "" {
+		options["allowCredentials"] = credentialDescriptors(username)
+	}
+	return map[string]interface{}{"publicKey": options}, nil
+}
+
+// requirePasskey checks whether a password login must be confirmed with a passkey.
+// It returns the login options when it must, and whether an admin still has to
+// register a passkey to comply with the admin policy before getting a session.
+func requirePasskey(username, role string, keepLoggedIn bool) (map[string]interface{}, bool, error) {
+	credentials, err := webauthn.List(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(credentials) == 0 {
+		if !passkeyRequired(role) {
+			return nil, false, nil
+		}
+		// The admin couldn't register the passkey they need
+		if _, err := relyingParty(); err != nil {
+			return nil, false, err
+		}
+		return nil, true, nil
+	}
+
+	options, err := passkeyLoginOptions(username, keepLoggedIn)
+	return options, false, err
+}
+
+// passkeyRequired reports whether users of role must log in with a passkey
+func passkeyRequired(role string) bool {
+	return role == roles.RoleAdmin && cfg.Security.Passkeys.RequireForAdmins
+}
+
+// startPasskeySetup sets the setup token letting an admin who has to register a
+// passkey reach the passkey registration, and nothing else, for a few minutes
+func startPasskeySetup(w http.ResponseWriter, username string, keepLoggedIn bool) error {
+	token, err := signing.Sign(cfg.Wiki.RootDir, passkeySetupClaims{
+		Claims:       signing.Claims{Purpose: passkeySetupPurpose, Expires: time.Now().Add(passkeySetupLifetime).Unix()},
+		Username:     username,
+		KeepLoggedIn: keepLoggedIn,
+	})
+	if err != nil {
+		return err
+	}
+	setPasskeySetupCookie(w, token, int(passkeySetupLifetime/time.Second))
+	return nil
+}
+
+// setPasskeySetupCookie sets the setup token cookie, a negative maxAge removes it
+func setPasskeySetupCookie(w http.ResponseWriter, token string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     passkeySetupCookie,
+		Value:    token,
+		Path:     "/api/passkeys/register/",
+		HttpOnly: true,
+		Secure:   !cfg.Server.AllowInsecureCookies,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   maxAge,
+	})
+}
+
+// passkeySetup returns the claims of the setup token of the request, nil unless
+// it is valid and its user still exists and has no passkey yet
+func passkeySetup(r *http.Request) *passkeySetupClaims {
+	cookie, err := r.Cookie(passkeySetupCookie)
+	if err != nil {
+		return nil
+	}
+	var claims passkeySetupClaims
+	if err := signing.Verify(cfg.Wiki.RootDir, cookie.Value, passkeySetupPurpose, &claims); err != nil {
+		return nil
+	}
+	if permissions.UserRole(cfg, claims.Username) == "" {
+		return nil
+	}
+	credentials, err := webauthn.List(cfg.Wiki.RootDir, claims.Username)
+	if err != nil || len(credentials) > 0 {
+		return nil
+	}
+	return &claims
+}
+
+// registeringUser returns the user registering a passkey: the logged-in user, or
+// an admin finishing their login with a setup token, whose claims are returned
+func registeringUser(r *http.Request) (string, *passkeySetupClaims) {
+	if session := auth.GetSession(r); session != nil {
+		return session.Username, nil
+	}
+	if setup := passkeySetup(r); setup != nil {
+		return setup.Username, setup
+	}
+	return "", nil
+}
+
+// PasskeysHandler handles GET /api/passkeys (list the passkeys of the current
+// user) and DELETE /api/passkeys/{id}
+func PasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		credentials, err := webauthn.List(cfg.Wiki.RootDir, session.Username)
+		if err != nil {
+			sendJSONError(w, "Failed to load passkeys", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		passkeys := []PasskeyInfo{}
+		for _, credential := range credentials {
+			info := PasskeyInfo{
+				ID:        credential.ID,
+				Name:      credential.Name,
+				CreatedAt: credential.CreatedAt,
+			}
+			if !credential.LastUsedAt.IsZero() {
+				lastUsed := credential.LastUsedAt
+				info.LastUsedAt = &lastUsed
+			}
+			passkeys = append(passkeys, info)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"passkeys": passkeys,
+		})
+
+	case http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, "/api/passkeys/")
+		if id == "" || strings.Contains(id, "/") {
+			sendJSONError(w, "Invalid passkey", http.StatusBadRequest, "")
+			return
+		}
+
+		// Admins who must use a passkey can't remove their last one
+		if passkeyRequired(session.Role) {
+			credentials, err := webauthn.List(cfg.Wiki.RootDir, session.Username)
+			if err == nil && len(credentials) == 1 && credentials[0].ID == id {
+				sendJSONError(w, "Admin accounts must keep a passkey, add another one first", http.StatusConflict, "")
+				return
+			}
+		}
+
+		if err := webauthn.Remove(cfg.Wiki.RootDir, session.Username, id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, webauthn.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			sendJSONError(w, "Failed to remove passkey", status, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Passkey removed",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// PasskeyRegisterBeginHandler handles POST /api/passkeys/register/begin and returns
+// the options for navigator.credentials.create()
+func PasskeyRegisterBeginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	username, _ := registeringUser(r)
+	if username == "" {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		sendJSONError(w, "Passkeys are not available", http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		sendJSONError(w, "Failed to start registration", http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := webauthn.StartCeremony(cfg.Wiki.RootDir, challenge, webauthn.Ceremony{Username: username}); err != nil {
+		sendJSONError(w, "Failed to start registration", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"publicKey": map[string]interface{}{
+			"challenge": challenge,
+			"rp":        map[string]string{"id": rp.ID, "name": cfg.Wiki.Title},
+			"user": map[string]string{
+				"id":          userHandle(username),
+				"name":        username,
+				"displayName": username,
+			},
+			"pubKeyCredParams": []map[string]interface{}{
+				{"type": "public-key", "alg": webauthn.AlgES256},
+				{"type": "public-key", "alg": webauthn.AlgEdDSA},
+				{"type": "public-key", "alg": webauthn.AlgRS256},
+			},
+			"timeout":            passkeyTimeout,
+			"attestation":        "none",
+			"excludeCredentials": credentialDescriptors(username),
+			"authenticatorSelection": map[string]string{
+				"residentKey":      "preferred",
+				"userVerification": "preferred",
+			},
+		},
+	})
+}
+
+// PasskeyRegisterFinishHandler handles POST /api/passkeys/register/finish and
+// stores the new passkey of the current user. Admins registering the passkey
+// they must use are logged in.
+func PasskeyRegisterFinishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	username, setup := registeringUser(r)
+	if username == "" {
+		sendJSONError(w, "Authentication required", http.StatusUnauthorized, "")
+		return
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		sendJSONError(w, "Passkeys are not available", http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	var req PasskeyRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	challenge, err := webauthn.ResponseChallenge(req.Response.ClientDataJSON)
+	if err != nil {
+		sendJSONError(w, "Invalid passkey response", http.StatusBadRequest, err.Error())
+		return
+	}
+	ceremony, ok := webauthn.FinishCeremony(cfg.Wiki.RootDir, challenge)
+	if !ok || ceremony.Username != username {
+		sendJSONError(w, "Registration expired, please try again", http.StatusBadRequest, "")
+		return
+	}
+
+	credential, err := webauthn.VerifyRegistration(rp, challenge, req.Response)
+	if err != nil {
+		sendJSONError(w, "Passkey verification failed", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	credential.Name = strings.TrimSpace(req.Name)
+	if credential.Name == "" {
+		credential.Name = "Passkey"
+	}
+	if err := webauthn.Add(cfg.Wiki.RootDir, username, *credential); err != nil {
+		sendJSONError(w, "Failed to save passkey", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if setup != nil {
+		setPasskeySetupCookie(w, "", -1)
+		if err := auth.CreateSession(w, username, permissions.UserRole(cfg, username), setup.KeepLoggedIn, cfg); err != nil {
+			sendJSONError(w, "Failed to create session", http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Passkey registered",
+	})
+}
+
+// PasskeyLoginBeginHandler handles POST /api/passkeys/login/begin and returns the
+// options for navigator.credentials.get()
+func PasskeyLoginBeginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req PasskeyLoginRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, err := relyingParty(); err != nil {
+		sendJSONError(w, "Passkeys are not available", http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	options, err := passkeyLoginOptions(strings.TrimSpace(req.Username), req.KeepLoggedIn)
+	if err != nil {
+		sendJSONError(w, "Failed to start login", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// PasskeyLoginFinishHandler handles POST /api/passkeys/login/finish. It verifies
+// the passkey response and logs its owner in, both for passwordless logins and
+// to confirm a password login.
+func PasskeyLoginFinishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	ip := clientIP(r)
+	if loginBan != nil && loginBan.IsBanned(ip) > 0 {
+		sendJSONError(w, "Too many failed logins; try again later", http.StatusTooManyRequests, "")
+		return
+	}
+
+	var resp webauthn.AssertionResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	challenge, err := webauthn.ResponseChallenge(resp.ClientDataJSON)
+	if err != nil {
+		sendJSONError(w, "Invalid passkey response", http.StatusBadRequest, err.Error())
+		return
+	}
+	ceremony, ok := webauthn.FinishCeremony(cfg.Wiki.RootDir, challenge)
+	if !ok {
+		sendJSONError(w, "Login expired, please try again", http.StatusBadRequest, "")
+		return
+	}
+
+	fail := func(details string) {
+		if loginBan != nil {
+			loginBan.RegisterFailure(ip)
+		}
+		sendJSONError(w, "Passkey login failed", http.StatusUnauthorized, details)
+	}
+
+	username, credential, err := webauthn.Find(cfg.Wiki.RootDir, resp.ID)
+	if err != nil || (ceremony.Username != "" && username != ceremony.Username) {
+		fail("Unknown passkey")
+		return
+	}
+	role := permissions.UserRole(cfg, username)
+	if role == "" {
+		fail("The account of this passkey no longer exists")
+		return
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		sendJSONError(w, "Passkeys are not available", http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	signCount, err := webauthn.VerifyAssertion(rp, challenge, *credential, resp)
+	if err != nil {
+		log.Printf("Passkey login of %s failed: %v", username, err)
+		fail(err.Error())
+		return
+	}
+	if err := webauthn.MarkUsed(cfg.Wiki.RootDir, username, credential.ID, signCount); err != nil {
+		log.Printf("Error updating passkey of %s: %v", username, err)
+	}
+
+	if loginBan != nil {
+		loginBan.Clear(ip)
+	}
+	if err := auth.CreateSession(w, username, role, ceremony.KeepLoggedIn, cfg); err != nil {
+		sendJSONError(w, "Failed to create session", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Login successful",
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/crypto"
+	"wiki-go/internal/webauthn"
+)
+
+// setupPasskeyTest sets up a wiki with an admin that must use a passkey
+func setupPasskeyTest(t *testing.T) {
+	t.Helper()
+	hash, err := crypto.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, previousBan := cfg, loginBan
+	cfg = &config.Config{}
+	cfg.Wiki.RootDir = t.TempDir()
+	cfg.Server.URL = "https://wiki.example.com"
+	cfg.Security.Passkeys.RequireForAdmins = true
+	cfg.Users = []config.User{{Username: "ann", Password: hash, Role: config.RoleAdmin}}
+	loginBan = nil
+	t.Cleanup(func() { cfg, loginBan = previous, previousBan })
+}
+
+func postJSON(t *testing.T, handler http.HandlerFunc, body interface{}, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func cookieNamed(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name && cookie.MaxAge >= 0 {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// createPasskey answers a registration challenge like a browser with a P-256 authenticator
+func createPasskey(t *testing.T, challenge string) webauthn.AttestationResponse {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coseKey := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	coseKey = append(coseKey, key.X.FillBytes(make([]byte, 32))...)
+	coseKey = append(coseKey, 0x22, 0x58, 0x20)
+	coseKey = append(coseKey, key.Y.FillBytes(make([]byte, 32))...)
+
+	rpIDHash := sha256.Sum256([]byte("wiki.example.com"))
+	authData := append(rpIDHash[:], 0x41, 0, 0, 0, 0)
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, 0, 4, 'k', 'e', 'y', '1')
+	authData = append(authData, coseKey...)
+
+	object := []byte{0xa3, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0}
+	object = append(object, 0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59)
+	object = binary.BigEndian.AppendUint16(object, uint16(len(authData)))
+	object = append(object, authData...)
+
+	clientData, _ := json.Marshal(map[string]string{"type": "webauthn.create", "challenge": challenge, "origin": "https://wiki.example.com"})
+	return webauthn.AttestationResponse{
+		ID:                webauthn.Encoding.EncodeToString([]byte("key1")),
+		ClientDataJSON:    webauthn.Encoding.EncodeToString(clientData),
+		AttestationObject: webauthn.Encoding.EncodeToString(object),
+	}
+}
+
+func TestAdminsWithoutPasskeyGetNoSession(t *testing.T) {
+	setupPasskeyTest(t)
+
+	w := postJSON(t, LoginHandler, LoginRequest{Username: "ann", Password: "secret"}, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("login status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	var login struct {
+		Success              bool `json:"success"`
+		PasskeySetupRequired bool `json:"passkeySetupRequired"`
+	}
+	json.NewDecoder(w.Body).Decode(&login)
+	if login.Success || !login.PasskeySetupRequired {
+		t.Errorf("login response = %+v, want a required passkey setup", login)
+	}
+	if cookieNamed(w, "session_token") != nil {
+		t.Fatal("login created a session before the admin registered a passkey")
+	}
+	setup := cookieNamed(w, passkeySetupCookie)
+	if setup == nil {
+		t.Fatal("login set no passkey setup cookie")
+	}
+
+	// The setup token isn't a session
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: setup.Value})
+	r.AddCookie(setup)
+	if auth.GetSession(r) != nil {
+		t.Fatal("the setup token was accepted as a session")
+	}
+
+	// A forged setup token is refused
+	forged := &http.Cookie{Name: passkeySetupCookie, Value: setup.Value + "x"}
+	if w := postJSON(t, PasskeyRegisterBeginHandler, nil, []*http.Cookie{forged}); w.Code != http.StatusUnauthorized {
+		t.Errorf("registration with a forged setup token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// Registering the passkey finishes the login
+	w = postJSON(t, PasskeyRegisterBeginHandler, nil, []*http.Cookie{setup})
+	if w.Code != http.StatusOK {
+		t.Fatalf("registration begin status = %d: %s", w.Code, w.Body)
+	}
+	var options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+		} `json:"publicKey"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&options); err != nil {
+		t.Fatal(err)
+	}
+	if options.PublicKey.RP.ID != "wiki.example.com" {
+		t.Errorf("rp.id = %q, want the host of the server URL", options.PublicKey.RP.ID)
+	}
+
+	request := PasskeyRegisterRequest{Name: "Laptop", Response: createPasskey(t, options.PublicKey.Challenge)}
+	w = postJSON(t, PasskeyRegisterFinishHandler, request, []*http.Cookie{setup})
+	if w.Code != http.StatusOK {
+		t.Fatalf("registration finish status = %d: %s", w.Code, w.Body)
+	}
+	if cookieNamed(w, "session_token") == nil {
+		t.Error("registering the passkey created no session")
+	}
+
+	// The setup token is spent once the admin has a passkey
+	if w := postJSON(t, PasskeyRegisterBeginHandler, nil, []*http.Cookie{setup}); w.Code != http.StatusUnauthorized {
+		t.Errorf("registration with a spent setup token status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// Password logins now have to be confirmed with the passkey
+	w = postJSON(t, LoginHandler, LoginRequest{Username: "ann", Password: "secret"}, nil)
+	if w.Code != http.StatusUnauthorized || cookieNamed(w, "session_token") != nil {
+		t.Errorf("login status = %d, want the passkey to be asked for and no session", w.Code)
+	}
+}
+
+func TestPasskeysNeedTheServerURL(t *testing.T) {
+	setupPasskeyTest(t)
+	cfg.Server.URL = ""
+
+	w := postJSON(t, LoginHandler, LoginRequest{Username: "ann", Password: "secret"}, nil)
+	if w.Code == http.StatusOK || cookieNamed(w, "session_token") != nil {
+		t.Errorf("login status = %d, want no session while the admin can't register a passkey", w.Code)
+	}
+
+	// The relying party never comes from the request
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Host = "evil.example.com"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	PasskeyLoginBeginHandler(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("login begin status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/htmlmd"
+)
+
+// pasteResult is the markdown of converted rich text
+type pasteResult struct {
+	Success  bool     `json:"success"`
+	Markdown string   `json:"markdown"`
+	Images   []string `json:"images"`
+	Missing  int      `json:"missing"`
+}
+
+// PasteHandler handles POST /api/paste, converting the HTML of the clipboard
+// to markdown. The images of the clipboard, sent as "images", stand in for
+// the local files office suites refer to.
+func PasteHandler(w http.ResponseWriter, r *http.Request) {
+	dir, ok := pasteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var fallback [][]byte
+	for _, header := range r.MultipartForm.File["images"] {
+		file, err := header.Open()
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err == nil {
+			fallback = append(fallback, data)
+		}
+	}
+
+	result := htmlmd.ConvertHTML(r.FormValue("html"), htmlmd.Options{
+		ImagePrefix: pastePrefix(),
+		Fallback:    fallback,
+	})
+	sendPasteResult(w, dir, result)
+}
+
+// PasteDocxHandler handles POST /api/paste/docx, converting an uploaded Word
+// document to markdown
+func PasteDocxHandler(w http.ResponseWriter, r *http.Request) {
+	dir, ok := pasteTarget(w, r)
+	if !ok {
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		sendJSONError(w, "No file provided", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	if !strings.EqualFold(filepath.Ext(header.Filename), ".docx") {
+		sendJSONError(w, "Only .docx files can be converted", http.StatusBadRequest, "")
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		sendJSONError(w, "Failed to read file", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := htmlmd.ConvertDocx(data, htmlmd.Options{ImagePrefix: pastePrefix()})
+	if err != nil {
+		sendJSONError(w, "Failed to convert document", http.StatusBadRequest, err.Error())
+		return
+	}
+	sendPasteResult(w, dir, result)
+}
+
+// pasteTarget parses a paste request and returns the directory of the page
+// being edited, where images are saved
+func pasteTarget(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return "", false
+	}
+	if err := r.ParseMultipartForm(config.GetMaxUploadSizeBytes(cfg)); err != nil {
+		sendJSONError(w, "Failed to parse form or file too large. Maximum size is "+config.GetMaxUploadSizeFormatted(cfg)+".", http.StatusBadRequest, err.Error())
+		return "", false
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.FormValue("docPath"))), "/")
+	if !canEditPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return "", false
+	}
+	dir := pageDir(cfg, docPath)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		sendJSONError(w, "Document directory does not exist", http.StatusBadRequest, "")
+		return "", false
+	}
+	return dir, true
+}
+
+// pastePrefix names the images of one paste
+func pastePrefix() string {
+	return fmt.Sprintf("pasted-%d", time.Now().Unix())
+}
+
+// sendPasteResult saves the images of converted rich text and responds
+// with its markdown
+func sendPasteResult(w http.ResponseWriter, dir string, result htmlmd.Result) {
+	images := make([]string, 0, len(result.Images))
+	for _, image := range result.Images {
+		if err := writeAttachment(dir, image.Name, image.Data, cfg); err != nil {
+			sendJSONError(w, "Failed to save image", http.StatusInternalServerError, err.Error())
+			return
+		}
+		images = append(images, image.Name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pasteResult{
+		Success:  true,
+		Markdown: result.Markdown,
+		Images:   images,
+		Missing:  result.Missing,
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/permalinks"
+)
+
+// followPermalink sends the reader of a permalink like /p/ab12cd to its page.
+// Permalinks are only looked up for paths without a page, so pages under /p
+// keep working. It returns false when path is not a known permalink.
+func followPermalink(w http.ResponseWriter, r *http.Request, path string) bool {
+	id := strings.TrimPrefix("/"+strings.Trim(path, "/"), permalinks.Prefix)
+	if id == path || id == "" || strings.Contains(id, "/") {
+		return false
+	}
+	page, ok, err := permalinks.Lookup(cfg.Wiki.RootDir, id)
+	if err != nil {
+		log.Printf("Error loading permalinks: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	http.Redirect(w, r, "/"+page, http.StatusFound)
+	return true
+}
+
+// PermalinkHandler handles GET /api/permalink?path=, which returns the short
+// link of a page, giving the page an ID the first time
+func PermalinkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	docPath := permalinks.Clean(r.URL.Query().Get("path"))
+	if docPath == "" {
+		sendJSONError(w, "The home page has no permalink", http.StatusBadRequest, "")
+		return
+	}
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+	if info, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(docPath))); err != nil || !info.IsDir() {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+
+	id, err := permalinks.ID(cfg.Wiki.RootDir, docPath)
+	if err != nil {
+		sendJSONError(w, "Failed to create the permalink", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"url":     getBaseURL(r, cfg) + permalinks.Prefix + id,
+	})
+}
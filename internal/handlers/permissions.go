@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/roles"
+)
+
+// PermissionsPayload represents the raw YAML permission rules
+type PermissionsPayload struct {
+	Content string `json:"content"`
+}
+
+// loadPermissions returns the permission rules, nil when they can't be loaded
+func loadPermissions() *permissions.File {
+	file, err := permissions.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading permissions: %v", err)
+		return nil
+	}
+	return file
+}
+
+// subjectFor builds the permission subject of a user, including their groups
+func subjectFor(username, role string) permissions.Subject {
+	var groups map[string][]string
+	if owners, err := ownership.Load(cfg.Wiki.RootDir); err == nil {
+		groups = owners.Groups
+	}
+	return permissions.SubjectFor(username, role, groups)
+}
+
+// requestSubject returns the permission subject of the current request
+func requestSubject(r *http.Request) permissions.Subject {
+	if session := auth.GetSession(r); session != nil {
+		subject := subjectFor(session.Username, session.Role)
+		// Groups reported by an authenticating proxy count as well
+		subject.Groups = append(subject.Groups, session.Groups...)
+		return subject
+	}
+	return subjectFor("", "")
+}
+
+// canViewPath reports whether the current user may read the page at path.
+// Broken rules deny access to everybody but admins.
+func canViewPath(r *http.Request, path string) bool {
+	subject := requestSubject(r)
+	file := loadPermissions()
+	if file == nil {
+		return subject.Role == roles.RoleAdmin
+	}
+	return file.CanView(path, subject)
+}
+
+// canEditPath reports whether the current user may change the page at path.
+// Pages pulled from another wiki are replaced by each sync, so nobody edits them,
+// and archived pages have to be unarchived first.
+func canEditPath(r *http.Request, path string) bool {
+	if _, synced := syncedSource(path); synced {
+		return false
+	}
+	if _, archived := archivedEntry(path); archived {
+		return false
+	}
+	return editAllowed(r, path)
+}
+
+// editAllowed reports whether the permission rules let the current user change
+// the page at path
+func editAllowed(r *http.Request, path string) bool {
+	subject := requestSubject(r)
+	file := loadPermissions()
+	if file == nil {
+		return subject.Role == roles.RoleAdmin
+	}
+	return file.CanEdit(path, subject)
+}
+
+// listedPath reports whether the page at path shows up for the current user in
+// navigation, search and the sitemap. Unlisted pages can be read, but only by link.
+func listedPath(r *http.Request, path string) bool {
+	subject := requestSubject(r)
+	file := loadPermissions()
+	if file == nil {
+		return subject.Role == roles.RoleAdmin
+	}
+	return file.Listed(path, subject)
+}
+
+// pageVisibility returns the visibility level of the page at path, public when
+// the rules can't be loaded
+func pageVisibility(path string) string {
+	file := loadPermissions()
+	if file == nil {
+		return permissions.Public
+	}
+	return file.Access(path).Visibility()
+}
+
+// denyView answers a request for a page the user may not read. Guests are sent to
+// the login page, logged-in users get a 404 so hidden pages are not revealed.
+func denyView(w http.ResponseWriter, r *http.Request) {
+	if auth.GetSession(r) == nil {
+		http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+	NotFoundHandler(w, r, cfg)
+}
+
+// navigationFilter returns a check of the navigation items the current user may
+// read and that are not unlisted, nil when everything is listed
+func navigationFilter(r *http.Request) func(path string) bool {
+	file := loadPermissions()
+	if file != nil && !file.HasRules() {
+		return nil
+	}
+	subject := requestSubject(r)
+	return func(path string) bool {
+		if file == nil {
+			return subject.Role == roles.RoleAdmin
+		}
+		return file.Listed(path, subject)
+	}
+}
+
+// PermissionsSettingsHandler handles GET (read) and POST/PUT (update) of the permission rules
+func PermissionsSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := permissions.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read permissions", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"content": content,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req PermissionsPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := permissions.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Invalid permissions", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Permissions saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// PermissionsTreeHandler handles GET /api/settings/permissions/tree and returns the
+// effective permission tree. ?user=<name> simulates what that user can view and
+// edit, ?user=guest simulates a visitor who is not logged in.
+func PermissionsTreeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	file, err := permissions.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		sendJSONError(w, "Failed to load permissions", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var subject *permissions.Subject
+	if username := strings.TrimSpace(r.URL.Query().Get("user")); username != "" {
+		s := subjectFor("", "")
+		if username != "guest" {
+			role := permissions.UserRole(cfg, username)
+			if role == "" {
+				sendJSONError(w, "Unknown user", http.StatusNotFound, username)
+				return
+			}
+			s = subjectFor(username, role)
+		}
+		subject = &s
+	}
+
+	tree, err := file.Tree(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir), subject)
+	if err != nil {
+		sendJSONError(w, "Failed to build permission tree", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tree":    tree,
+		"subject": subject,
+	})
+}
+
+// VisibilityHandler handles GET and POST /api/visibility, which read and change
+// the visibility level of a page: public, internal, restricted or unlisted.
+// Levels are stored as permission rules of the page, so they apply to its
+// subpages as well. Editors change the pages they may edit.
+func VisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+		if !canViewPath(r, docPath) {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		file, err := permissions.Load(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load permissions", http.StatusInternalServerError, err.Error())
+			return
+		}
+		access := file.Access(docPath)
+		_, own := file.Rule(docPath)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"visibility": access.Visibility(),
+			"view":       access.View,
+			"inherited":  !own,
+			"canChange":  docPath != "" && canEditPath(r, docPath),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Path       string   `json:"path"`
+			Visibility string   `json:"visibility"`
+			View       []string `json:"view"` // Users and @groups of restricted pages
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+		if docPath == "" {
+			sendJSONError(w, "The home page sets the visibility of the whole wiki; change it in the permission settings", http.StatusBadRequest, "")
+			return
+		}
+		if !canEditPath(r, docPath) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+			return
+		}
+
+		var view []string
+		for _, principal := range req.View {
+			if principal = strings.TrimSpace(principal); principal != "" {
+				view = append(view, principal)
+			}
+		}
+		// Editors can't lock themselves out of a page
+		if req.Visibility == permissions.Restricted && !permissions.Allows(view, requestSubject(r)) {
+			sendJSONError(w, "You would no longer be able to read this page; add yourself or one of your groups", http.StatusBadRequest, "")
+			return
+		}
+		if err := permissions.SetVisibility(cfg.Wiki.RootDir, docPath, req.Visibility, view); err != nil {
+			sendJSONError(w, "Failed to change visibility", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"visibility": pageVisibility(docPath),
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/preferences"
+)
+
+// PreferencesHandler handles /api/preferences for the current user. GET returns
+// the preferences, PUT replaces them.
+func PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := preferences.Load(cfg.Wiki.RootDir, session.Username)
+		if err != nil {
+			sendJSONError(w, "Failed to load preferences", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"preferences": prefs,
+		})
+	case http.MethodPut:
+		prefs := preferences.Defaults()
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&prefs); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		if !prefs.Valid() {
+			sendJSONError(w, "Invalid preferences", http.StatusBadRequest, "")
+			return
+		}
+		if err := preferences.Save(cfg.Wiki.RootDir, session.Username, prefs); err != nil {
+			sendJSONError(w, "Failed to save preferences", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"preferences": prefs,
+		})
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// PresentSlide is a rendered slide with its speaker notes
+type PresentSlide struct {
+	Content template.HTML
+	Notes   string
+}
+
+// PresentPage holds the data for the presentation view of a document
+type PresentPage struct {
+	Title   string
+	Slides  []PresentSlide
+	Config  *config.Config
+	Path    string
+	Speaker bool
+}
+
+// PresentHandler serves /present/{path}, which shows a document as slides. Slides are
+// split on --- separators or H2 headings and HTML comments become speaker notes.
+// Adding ?speaker=1 opens the speaker view that follows the main presentation.
+func PresentHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	doc, status := loadViewDocument(r, "/present", cfg)
+	if status == http.StatusNotFound {
+		NotFoundHandler(w, r, cfg)
+		return
+	} else if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	visibleContent := goldext.FilterAudience(doc.Content, requestSubject(r))
+	_, body, _ := frontmatter.Parse(visibleContent)
+
+	var slides []PresentSlide
+	if !renderLimited(w, func() {
+		for _, slide := range goldext.SplitSlides(body) {
+			slides = append(slides, PresentSlide{
+				Content: template.HTML(utils.RenderMarkdownWithPath(slide.Content, doc.Path)),
+				Notes:   slide.Notes,
+			})
+		}
+	}) {
+		return
+	}
+
+	data := PresentPage{
+		Title:   doc.Title,
+		Slides:  slides,
+		Config:  cfg,
+		Path:    "/" + doc.Path,
+		Speaker: r.URL.Query().Get("speaker") == "1",
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset":         assetURL,
+		"mermaidScript": mermaidScript,
+		"mermaidConfig": mermaidConfig,
+	}
+
+	tmpl, err := template.New("present.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/present.html")
+	if err != nil {
+		http.Error(w, "Error loading presentation template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering presentation template: %v", err)
+	}
+}
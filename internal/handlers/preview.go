@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/utils"
+)
+
+// PagePreview is the summary of a page shown when hovering a link to it
+type PagePreview struct {
+	Path     string    `json:"path"`
+	Title    string    `json:"title"`
+	Excerpt  string    `json:"excerpt"`
+	Image    string    `json:"image,omitempty"`
+	Modified time.Time `json:"modified"`
+}
+
+// PreviewHandler handles GET /api/preview?path=, returning the title, excerpt,
+// first image and modification time of a page for link previews
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !canViewPath(r, "/"+docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	file := documentFile(docPath)
+	info, err := os.Stat(file)
+	if err != nil {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return
+	}
+
+	page := excerpt.Lookup(cfg, file)
+	body, err := json.Marshal(PagePreview{
+		Path:     "/" + docPath,
+		Title:    utils.GetDocumentTitle(filepath.Dir(file)),
+		Excerpt:  page.Text,
+		Image:    page.Image,
+		Modified: info.ModTime(),
+	})
+	if err != nil {
+		sendJSONError(w, "Failed to encode preview", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Browsers keep previews for a minute and then only check they are current
+	sum := sha1.Sum(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	http.ServeContent(w, r, "preview.json", info.ModTime(), bytes.NewReader(body))
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// PrintPage holds the data for the print-optimized view of a document
+type PrintPage struct {
+	Title        string
+	Content      template.HTML
+	Config       *config.Config
+	Path         string
+	LastModified time.Time
+	AutoPrint    bool
+}
+
+// viewDocument is a document loaded for one of the standalone views (print, present)
+type viewDocument struct {
+	Path         string // Decoded document path, empty for the home page
+	Title        string
+	Content      string // Raw markdown
+	LastModified time.Time
+}
+
+// loadViewDocument reads the document addressed by the request path below prefix.
+// It returns the HTTP status to respond with when the document can't be loaded,
+// pages the user may not read are reported as not found.
+func loadViewDocument(r *http.Request, prefix string, cfg *config.Config) (*viewDocument, int) {
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	path = strings.ReplaceAll(filepath.Clean("/"+path), "\\", "/")
+	decodedPath, err := url.QueryUnescape(strings.Trim(path, "/"))
+	if err != nil {
+		return nil, http.StatusBadRequest
+	}
+	if !canViewPath(r, decodedPath) {
+		return nil, http.StatusNotFound
+	}
+
+	doc := &viewDocument{Path: decodedPath}
+	var docFile string
+	if decodedPath == "" {
+		docFile = filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md")
+		doc.Title = cfg.Wiki.Title
+	} else {
+		docDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, decodedPath)
+		docFile = filepath.Join(docDir, "document.md")
+		doc.Title = utils.GetDocumentTitle(docDir)
+	}
+
+	info, err := os.Stat(docFile)
+	if err != nil || info.IsDir() {
+		return nil, http.StatusNotFound
+	}
+
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return nil, http.StatusInternalServerError
+	}
+
+	doc.Content = string(content)
+	doc.LastModified = info.ModTime()
+	return doc, http.StatusOK
+}
+
+// closedDetailsRegex matches <details> tags that are not already open
+var closedDetailsRegex = regexp.MustCompile(`<details(\s[^>]*)?>`)
+
+// expandDetails opens every collapsible section of rendered HTML
+func expandDetails(rendered string) string {
+	return closedDetailsRegex.ReplaceAllStringFunc(rendered, func(tag string) string {
+		if strings.Contains(tag, " open") {
+			return tag
+		}
+		return "<details open" + strings.TrimPrefix(tag, "<details")
+	})
+}
+
+// PrintHandler serves /print/{path}, a standalone print-friendly rendering of a
+// document without navigation, with collapsibles expanded and diagrams inlined.
+// Adding ?autoprint=1 opens the browser print dialog once rendering is done,
+// which makes the page usable as the source for PDF export.
+func PrintHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	doc, status := loadViewDocument(r, "/print", cfg)
+	if status == http.StatusNotFound {
+		NotFoundHandler(w, r, cfg)
+		return
+	} else if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	visibleContent := goldext.FilterAudience(doc.Content, requestSubject(r))
+	visibleContent = goldext.ExpandEmbeds(visibleContent, doc.Path, embeddedPageLoader(r))
+	var rendered string
+	if !renderLimited(w, func() {
+		rendered = string(utils.RenderMarkdownWithPath(visibleContent, doc.Path))
+	}) {
+		return
+	}
+
+	// Expand every collapsible section so nothing is hidden on paper
+	rendered = expandDetails(rendered)
+
+	data := PrintPage{
+		Title:        doc.Title,
+		Content:      template.HTML(rendered),
+		Config:       cfg,
+		Path:         "/" + doc.Path,
+		LastModified: doc.LastModified,
+		AutoPrint:    r.URL.Query().Get("autoprint") == "1",
+	}
+
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset":         assetURL,
+		"mermaidScript": mermaidScript,
+		"mermaidConfig": mermaidConfig,
+		"formatTime": func(t time.Time, timezone string, format string) string {
+			return utils.FormatTimeInTimezone(t, timezone, format)
+		},
+	}
+
+	tmpl, err := template.New("print.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/print.html")
+	if err != nil {
+		http.Error(w, "Error loading print template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering print template: %v", err)
+	}
+}
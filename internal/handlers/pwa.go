@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/version"
+)
+
+// manifestIcon is an icon of the web app manifest
+type manifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// ManifestHandler serves the web app manifest that lets browsers install the wiki
+func ManifestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	icons := make([]manifestIcon, 0, 2)
+	if file, err := openFavicon("svg"); err == nil {
+		file.Close()
+		icons = append(icons, manifestIcon{Src: assetURL("favicon.svg"), Sizes: "any", Type: "image/svg+xml", Purpose: "any"})
+	}
+	if file, err := openFavicon("png"); err == nil {
+		// Browsers pick icons by size, so report the real one
+		if config, err := png.DecodeConfig(file); err == nil {
+			icons = append(icons, manifestIcon{
+				Src:   assetURL("favicon.png"),
+				Sizes: fmt.Sprintf("%dx%d", config.Width, config.Height),
+				Type:  "image/png",
+			})
+		}
+		file.Close()
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":             cfg.Wiki.Title,
+		"short_name":       cfg.Wiki.Title,
+		"description":      cfg.Wiki.Owner,
+		"lang":             cfg.Wiki.Language,
+		"start_url":        "/",
+		"scope":            "/",
+		"display":          "standalone",
+		"background_color": "#ffffff",
+		"theme_color":      "#0066cc",
+		"icons":            icons,
+	})
+}
+
+// openFavicon opens the custom favicon with the given extension, or the
+// built-in one when no custom favicon exists
+func openFavicon(ext string) (io.ReadCloser, error) {
+	if file, err := os.Open(filepath.Join(cfg.Wiki.RootDir, "static", "favicon."+ext)); err == nil {
+		return file, nil
+	}
+	if anyCustomFavicon() {
+		return nil, os.ErrNotExist
+	}
+	return resources.GetStaticFS().Open("favicon." + ext)
+}
+
+// anyCustomFavicon reports whether a favicon was uploaded, in which case the
+// built-in ones are not used
+func anyCustomFavicon() bool {
+	for _, ext := range []string{"ico", "png", "svg"} {
+		if _, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, "static", "favicon."+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceWorkerHandler serves the service worker that keeps visited pages for
+// offline reading. It is served from the root so it controls every page, and
+// tagged with the wiki version so updates replace the cached assets.
+func ServiceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	script, err := fs.ReadFile(resources.GetStaticFS(), "js/service-worker.js")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	// Browsers check for a new worker on every navigation
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "const WIKI_VERSION = %q;\nconst OFFLINE_READING = %t;\n", version.Version, cfg.Wiki.OfflineReading)
+	w.Write(script)
+}
+
+// OfflineHandler renders the page the service worker shows for pages that
+// weren't saved. It lists the saved pages, so it holds nothing private itself.
+func OfflineHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("offline.html").Funcs(template.FuncMap{
+		"asset": assetURL,
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+	}).ParseFS(resources.GetTemplatesFS(), "templates/offline.html")
+	if err != nil {
+		http.Error(w, "Error parsing offline template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.Execute(w, map[string]interface{}{
+		"Config": cfg,
+	})
+}
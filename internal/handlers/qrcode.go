@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/permalinks"
+	"wiki-go/internal/shares"
+)
+
+// Widths of the QR code images, in pixels
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 1024
+)
+
+// pageLink returns the URL of the page at path, its permanent link when
+// permanent is set
+func pageLink(r *http.Request, path string, permanent bool) (string, error) {
+	if permanent {
+		id, err := permalinks.ID(cfg.Wiki.RootDir, path)
+		if err != nil {
+			return "", err
+		}
+		return getBaseURL(r, cfg) + permalinks.Prefix + id, nil
+	}
+	return getBaseURL(r, cfg) + (&url.URL{Path: "/" + path}).EscapedPath(), nil
+}
+
+// QRCodeHandler handles GET /api/qr, which draws a QR code of a link as a PNG
+// image: ?path= encodes the URL of a page, with &permalink=1 its permanent
+// link, and ?share= the URL of a share link. &size= sets the width in pixels
+// and &download=1 saves the image instead of showing it.
+func QRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	query := r.URL.Query()
+
+	var link, name string
+	if id := query.Get("share"); id != "" {
+		share, err := shares.Get(cfg.Wiki.RootDir, id)
+		if err != nil || !share.Active() {
+			sendJSONError(w, "Share link not found", http.StatusNotFound, "")
+			return
+		}
+		// Share links are only shown to those who may manage them
+		if !editAllowed(r, share.Path) && !auth.RequireRole(r, "admin") {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+			return
+		}
+		link = getBaseURL(r, cfg) + "/s/" + share.ID
+		name = "share-" + share.ID
+	} else {
+		docPath := permalinks.Clean(query.Get("path"))
+		if !canViewPath(r, docPath) {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		if info, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(docPath))); err != nil || !info.IsDir() {
+			sendJSONError(w, "Document not found", http.StatusNotFound, "")
+			return
+		}
+		var err error
+		link, err = pageLink(r, docPath, query.Get("permalink") == "1" && docPath != "")
+		if err != nil {
+			sendJSONError(w, "Failed to create the permalink", http.StatusInternalServerError, err.Error())
+			return
+		}
+		name = "home"
+		if docPath != "" {
+			name = sanitizeFilename(strings.ReplaceAll(docPath, "/", "-"))
+		}
+	}
+
+	size := qrDefaultSize
+	if value, err := strconv.Atoi(query.Get("size")); err == nil {
+		size = min(max(value, qrMinSize), qrMaxSize)
+	}
+	png, err := qrcode.Encode(link, qrcode.Medium, size)
+	if err != nil {
+		sendJSONError(w, "Failed to draw the QR code", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if query.Get("download") == "1" {
+		w.Header().Set("Content-Disposition", `attachment; filename="qr-`+name+`.png"`)
+	}
+	w.Write(png)
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a page can be requested in besides HTML
+const (
+	rawMarkdown = "markdown"
+	rawJSON     = "json"
+)
+
+// RawPage is the JSON representation of a page for external tools
+type RawPage struct {
+	Path        string                 `json:"path"`
+	URL         string                 `json:"url"`
+	Title       string                 `json:"title"`
+	Frontmatter map[string]interface{} `json:"frontmatter"`
+	Tags        []string               `json:"tags"`
+	Markdown    string                 `json:"markdown"` // Content without frontmatter
+	Modified    time.Time              `json:"modified"`
+	Render      RawRender              `json:"render"`
+}
+
+// RawRender describes the rendered page
+type RawRender struct {
+	HTML     string       `json:"html"`
+	Headings []RawHeading `json:"headings"`
+	Links    []string     `json:"links"`  // Addresses of the links of the page
+	Images   []string     `json:"images"` // Addresses of the images of the page
+	Words    int          `json:"words"`
+}
+
+// RawHeading is a heading of a rendered page
+type RawHeading struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+var (
+	rawHeadingRegex = regexp.MustCompile(`(?s)<h([1-6])(\s[^>]*)?>(.*?)</h[1-6]>`)
+	rawIDRegex      = regexp.MustCompile(`\sid="([^"]*)"`)
+	rawLinkRegex    = regexp.MustCompile(`<a\s[^>]*?href="([^"]*)"`)
+	rawImageRegex   = regexp.MustCompile(`<img\s[^>]*?src="([^"]*)"`)
+	rawTagRegex     = regexp.MustCompile(`<[^>]*>`)
+	rawAnchorRegex  = regexp.MustCompile(`<a class="heading-anchor"[^>]*>[^<]*</a>`)
+)
+
+// RawRequest reports whether a page request asks for the source of the page
+// instead of HTML, either with a .md or .json suffix or with an Accept header
+// preferring text/markdown or application/json over text/html
+func RawRequest(r *http.Request, cfg *config.Config) bool {
+	_, format := rawTarget(r, cfg)
+	return format != ""
+}
+
+// RawPageHandler serves the source of a page, as markdown or as JSON with its
+// frontmatter and render metadata. It answers /api/raw/{path} (JSON with
+// ?format=json) and page addresses for which RawRequest holds. Content the
+// viewer may not see is removed, as on the page itself.
+func RawPageHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	docPath, format := rawTarget(r, cfg)
+	if format == "" {
+		format = rawMarkdown
+	}
+	if !canViewPath(r, docPath) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	file := filepath.Join(pageDir(cfg, docPath), "document.md")
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	content, err := encryption.ReadFile(file)
+	if err != nil {
+		http.Error(w, "Failed to read document", http.StatusInternalServerError)
+		return
+	}
+	source := goldext.FilterAudience(string(content), requestSubject(r))
+
+	var body []byte
+	name := "document.md"
+	if format == rawJSON {
+		page, ok := rawPage(w, r, cfg, docPath, source, info.ModTime())
+		if !ok {
+			return
+		}
+		if body, err = json.MarshalIndent(page, "", "  "); err != nil {
+			http.Error(w, "Failed to encode document", http.StatusInternalServerError)
+			return
+		}
+		name = "document.json"
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		body = []byte(source)
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	}
+
+	// Pipelines can poll cheaply with If-None-Match or If-Modified-Since
+	sum := sha1.Sum(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Vary", "Accept, Cookie")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(body))
+}
+
+// rawTarget returns the page and format a request for the source of a page asks
+// for. The format is empty when the request is for the HTML page.
+func rawTarget(r *http.Request, cfg *config.Config) (string, string) {
+	requestPath := r.URL.Path
+	api := strings.HasPrefix(requestPath, "/api/raw/") || requestPath == "/api/raw"
+	if api {
+		requestPath = strings.TrimPrefix(strings.TrimPrefix(requestPath, "/api/raw"), "/")
+	}
+	decoded, err := url.PathUnescape(requestPath)
+	if err != nil {
+		return "", ""
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+decoded)), "/")
+
+	if api {
+		if r.URL.Query().Get("format") == rawJSON || acceptedFormat(r) == rawJSON {
+			return docPath, rawJSON
+		}
+		return docPath, rawMarkdown
+	}
+
+	// A suffix selects the format, unless a directory is named like that
+	for suffix, format := range map[string]string{".md": rawMarkdown, ".json": rawJSON} {
+		if !strings.HasSuffix(docPath, suffix) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath)); err == nil {
+			break
+		}
+		return strings.TrimSuffix(docPath, suffix), format
+	}
+	return docPath, acceptedFormat(r)
+}
+
+// acceptedFormat returns the source format the Accept header of a request
+// prefers over HTML, or an empty string
+func acceptedFormat(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return ""
+	}
+
+	type choice struct {
+		format  string
+		quality float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			choices = append(choices, choice{"", quality})
+		case "text/markdown", "text/x-markdown":
+			choices = append(choices, choice{rawMarkdown, quality})
+		case "application/json":
+			choices = append(choices, choice{rawJSON, quality})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].quality > choices[j].quality
+	})
+	if len(choices) == 0 || choices[0].quality <= 0 {
+		return ""
+	}
+	return choices[0].format
+}
+
+// rawPage builds the JSON representation of a page from its visible source
+func rawPage(w http.ResponseWriter, r *http.Request, cfg *config.Config, docPath, source string, modified time.Time) (RawPage, bool) {
+	page := RawPage{
+		Path:        docPath,
+		URL:         getBaseURL(r, cfg) + "/" + docPath,
+		Title:       cfg.Wiki.Title,
+		Frontmatter: map[string]interface{}{},
+		Tags:        []string{},
+		Markdown:    source,
+		Modified:    modified,
+	}
+	if docPath != "" {
+		page.Title = utils.GetDocumentTitle(pageDir(cfg, docPath))
+	}
+	if meta, body, ok := frontmatter.Parse(source); ok {
+		page.Markdown = body
+		if tags := frontmatter.NormalizeTags(meta.Tags); tags != nil {
+			page.Tags = tags
+		}
+		yaml.Unmarshal([]byte(frontmatter.Extract(source)), &page.Frontmatter)
+	}
+
+	var rendered string
+	if !renderLimited(w, func() {
+		content := goldext.ExpandEmbeds(source, docPath, embeddedPageLoader(r))
+		rendered = string(utils.RenderMarkdownWithPath(content, docPath))
+	}) {
+		return page, false
+	}
+
+	// Permalinks of headings are not part of the content
+	plain := rawAnchorRegex.ReplaceAllString(rendered, "")
+	page.Render = RawRender{
+		HTML:     rendered,
+		Headings: []RawHeading{},
+		Links:    []string{},
+		Images:   []string{},
+		Words:    len(strings.Fields(html.UnescapeString(rawTagRegex.ReplaceAllString(plain, " ")))),
+	}
+	for _, match := range rawHeadingRegex.FindAllStringSubmatch(plain, -1) {
+		level, _ := strconv.Atoi(match[1])
+		text := strings.TrimSpace(html.UnescapeString(rawTagRegex.ReplaceAllString(match[3], "")))
+		heading := RawHeading{Level: level, Text: text}
+		if id := rawIDRegex.FindStringSubmatch(match[2]); id != nil {
+			heading.ID = id[1]
+		}
+		page.Render.Headings = append(page.Render.Headings, heading)
+	}
+	for _, match := range rawLinkRegex.FindAllStringSubmatch(plain, -1) {
+		page.Render.Links = append(page.Render.Links, html.UnescapeString(match[1]))
+	}
+	for _, match := range rawImageRegex.FindAllStringSubmatch(plain, -1) {
+		page.Render.Images = append(page.Render.Images, html.UnescapeString(match[1]))
+	}
+	return page, true
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/redirects"
+)
+
+// RedirectRequest is the body of POST /api/settings/redirects
+type RedirectRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RedirectEntry is a redirect listed by GET /api/settings/redirects
+type RedirectEntry struct {
+	From      string     `json:"from"` // Path starting with a slash
+	To        string     `json:"to"`
+	Page      bool       `json:"page"` // Set by the frontmatter of a page rather than by an admin
+	Hits      int        `json:"hits"`
+	LastHit   *time.Time `json:"lastHit,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	Final     string     `json:"final,omitempty"` // Page reached after following every redirect
+	Error     string     `json:"error,omitempty"` // Why following it never reaches a page, like a loop
+}
+
+// pageRedirect returns the target of the page at path when its frontmatter
+// redirects, "" otherwise
+func pageRedirect(path string) string {
+	file := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(redirects.Clean(path)), "document.md")
+	content, err := encryption.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	metadata, _, _ := frontmatter.Parse(string(content))
+	return metadata.Redirect
+}
+
+// redirectNext returns the lookup followed by redirects.Resolve. Existing pages
+// redirect with their frontmatter, missing pages with the redirects of file.
+func redirectNext(file *redirects.File) func(path string) (string, bool) {
+	return func(path string) (string, bool) {
+		dir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(path))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if to := pageRedirect(path); to != "" {
+				if target, err := redirects.Target(to); err == nil {
+					return target, true
+				}
+			}
+			return "", false
+		}
+		if redirect, ok := file.Find(path); ok {
+			return redirect.To, true
+		}
+		return "", false
+	}
+}
+
+// followRedirect sends the reader of the page at path to the page it redirects
+// to and counts the hit. It returns false when the page doesn't redirect, when
+// the redirects loop and when ?redirect=no asks for the page itself.
+func followRedirect(w http.ResponseWriter, r *http.Request, path string) bool {
+	if r.URL.Query().Get("redirect") == "no" {
+		return false
+	}
+	file, err := redirects.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading redirects: %v", err)
+		return false
+	}
+	from := redirects.Clean(path)
+	target, hops, err := redirects.Resolve(from, redirectNext(file))
+	if err != nil {
+		log.Printf("Warning: Redirect from /%s not followed: %v", from, err)
+		return false
+	}
+	if hops == 0 {
+		return false
+	}
+	if err := redirects.RecordHit(cfg.Wiki.RootDir, from); err != nil {
+		log.Printf("Error counting the hit of the redirect from /%s: %v", from, err)
+	}
+
+	targetPath, fragment, _ := strings.Cut(target, "#")
+	location := url.URL{Path: targetPath, RawQuery: "redirectedfrom=" + url.QueryEscape("/"+from), Fragment: fragment}
+	http.Redirect(w, r, location.String(), http.StatusFound)
+	return true
+}
+
+// redirectedFrom returns the page the reader of the page at path was redirected
+// from, "" unless ?redirectedfrom= names a page that does redirect there
+func redirectedFrom(r *http.Request, path string) string {
+	from := r.URL.Query().Get("redirectedfrom")
+	if from == "" {
+		return ""
+	}
+	file, err := redirects.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		return ""
+	}
+	target, hops, err := redirects.Resolve(from, redirectNext(file))
+	if err != nil || hops == 0 || redirects.TargetPath(target) != redirects.Clean(path) {
+		return ""
+	}
+	return "/" + redirects.Clean(from)
+}
+
+// RedirectsHandler handles /api/settings/redirects: GET lists the redirects
+// created by admins and those of redirect pages with their hits, POST creates
+// or replaces a redirect and DELETE ?from= removes one
+func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		file, err := redirects.Load(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load redirects", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		entries := []RedirectEntry{}
+		for _, redirect := range file.Redirects {
+			entries = append(entries, RedirectEntry{From: "/" + redirect.From, To: redirect.To, CreatedBy: redirect.CreatedBy})
+		}
+		for _, page := range scanDashboardPages(nil) {
+			if page.redirect != "" {
+				entries = append(entries, RedirectEntry{From: "/" + page.path, To: page.redirect, Page: true})
+			}
+		}
+		next := redirectNext(file)
+		for i := range entries {
+			entry := &entries[i]
+			if hit, ok := file.Hits[redirects.Clean(entry.From)]; ok {
+				entry.Hits = hit.Count
+				entry.LastHit = &hit.Last
+			}
+			if entry.Page {
+				if _, err := redirects.Target(entry.To); err != nil {
+					entry.Error = err.Error()
+					continue
+				}
+			}
+			final, hops, err := redirects.Resolve(entry.From, next)
+			switch {
+			case err != nil:
+				entry.Error = err.Error()
+			case hops == 0:
+				entry.Error = "a page exists at this path"
+			default:
+				entry.Final = final
+			}
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].From < entries[j].From
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"redirects": entries,
+		})
+
+	case http.MethodPost:
+		var req RedirectRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		from := redirects.Clean(req.From)
+		if from == "" {
+			sendJSONError(w, "The home page cannot redirect", http.StatusBadRequest, "")
+			return
+		}
+		if info, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(from))); err == nil && info.IsDir() {
+			sendJSONError(w, "A page exists at this path", http.StatusConflict, "Add redirect: to the frontmatter of the page instead")
+			return
+		}
+		to, err := redirects.Target(req.To)
+		if err != nil {
+			sendJSONError(w, "Invalid target", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// The redirect is checked along with the others before it is stored
+		file, err := redirects.Load(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to load redirects", http.StatusInternalServerError, err.Error())
+			return
+		}
+		lookup := redirectNext(file)
+		next := func(path string) (string, bool) {
+			if path == from {
+				return to, true
+			}
+			return lookup(path)
+		}
+		if _, _, err := redirects.Resolve(from, next); err != nil {
+			sendJSONError(w, "The redirect never reaches a page", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		user := ""
+		if session := auth.GetSession(r); session != nil {
+			user = session.Username
+		}
+		saved, err := redirects.Save(cfg.Wiki.RootDir, redirects.Redirect{From: from, To: to}, user)
+		if err != nil {
+			sendJSONError(w, "Failed to save the redirect", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"redirect": saved,
+		})
+
+	case http.MethodDelete:
+		err := redirects.Delete(cfg.Wiki.RootDir, r.URL.Query().Get("from"))
+		if errors.Is(err, redirects.ErrNotFound) {
+			sendJSONError(w, "Redirect not found", http.StatusNotFound, "")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to delete the redirect", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
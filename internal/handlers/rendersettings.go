@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"strings"
+
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/rendersettings"
+	"wiki-go/internal/types"
+)
+
+// Stylesheets of the bundled syntax highlighting themes
+var syntaxThemes = map[string]string{
+	rendersettings.ThemeLight: "libs/prism-1.30.0/prism.min.css",
+	rendersettings.ThemeDark:  "libs/prism-1.30.0/prism-tomorrow.min.css",
+}
+
+// applyRenderSettings fills the page data with the syntax highlighting theme
+// and the math option the directories of the page at docPath set
+func applyRenderSettings(data *types.PageData, docPath string) {
+	settings := goldext.DirectorySettings(docPath)
+	data.SyntaxTheme = syntaxThemeStylesheet(settings.SyntaxTheme)
+	data.NoMath = !settings.MathEnabled()
+}
+
+// syntaxThemeStylesheet returns the address of the stylesheet of a syntax
+// highlighting theme: a bundled one or a file below static, which data/static
+// can hold. It is empty when code follows the light or dark page theme.
+func syntaxThemeStylesheet(theme string) string {
+	theme = strings.TrimSpace(theme)
+	if theme == "" {
+		return ""
+	}
+	if stylesheet, ok := syntaxThemes[strings.ToLower(theme)]; ok {
+		return assetURL(stylesheet)
+	}
+	return assetURL(strings.TrimPrefix(strings.TrimPrefix(theme, "/"), "static/"))
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/replace"
+	"wiki-go/internal/utils"
+)
+
+// Most occurrences returned by a search, larger searches are truncated
+const maxReplaceOccurrences = 500
+
+// ReplaceSearchRequest is a search of the wiki, optionally limited to the
+// pages below Path
+type ReplaceSearchRequest struct {
+	replace.Query
+	Path string `json:"path"`
+}
+
+// ReplacePage lists the occurrences found in a page
+type ReplacePage struct {
+	Path        string               `json:"path"`
+	Title       string               `json:"title"`
+	Occurrences []replace.Occurrence `json:"occurrences"`
+}
+
+// ReplaceSelection is the occurrences of a page chosen for replacement
+type ReplaceSelection struct {
+	Path    string `json:"path"`
+	Offsets []int  `json:"offsets"`
+}
+
+// ReplaceApplyRequest applies a replacement to the selected occurrences
+type ReplaceApplyRequest struct {
+	replace.Query
+	Summary    string             `json:"summary"`
+	Selections []ReplaceSelection `json:"selections"`
+}
+
+// ReplaceResult reports the replacements made in a page
+type ReplaceResult struct {
+	Path     string `json:"path"`
+	Replaced int    `json:"replaced"`
+	Skipped  int    `json:"skipped"` // Occurrences that changed since the search
+	Error    string `json:"error,omitempty"`
+}
+
+// replacePages returns the pages the user may edit, below scope when set,
+// with the home page first
+func replacePages(r *http.Request, scope string) ([]Document, error) {
+	documents, err := listDocuments(r, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(documents, func(i, j int) bool { return documents[i].Path < documents[j].Path })
+	if canViewPath(r, "") {
+		documents = append([]Document{{Title: "Home", Path: "/"}}, documents...)
+	}
+
+	scope = strings.Trim(scope, "/")
+	pages := documents[:0]
+	for _, doc := range documents {
+		docPath := strings.Trim(doc.Path, "/")
+		if scope != "" && docPath != scope && !strings.HasPrefix(docPath, scope+"/") {
+			continue
+		}
+		if canEditPath(r, docPath) {
+			pages = append(pages, doc)
+		}
+	}
+	return pages, nil
+}
+
+// pageVersionPath returns the path used for the versions and edit history of a page
+func pageVersionPath(docPath string) string {
+	if docPath == "" {
+		return "pages/home"
+	}
+	return "documents/" + docPath
+}
+
+// ReplaceSearchHandler handles POST /api/replace/search, listing the matches of
+// a string or regular expression in the pages the user may edit
+func ReplaceSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req ReplaceSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	re, err := req.Compile()
+	if err != nil {
+		sendJSONError(w, "Invalid search", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	documents, err := replacePages(r, req.Path)
+	if err != nil {
+		sendJSONError(w, "Failed to list documents", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	results := []ReplacePage{}
+	total, truncated := 0, false
+	for _, doc := range documents {
+		docPath := strings.Trim(doc.Path, "/")
+		content, err := encryption.ReadFile(filepath.Join(pageDir(cfg, docPath), "document.md"))
+		if err != nil {
+			continue
+		}
+		occurrences := replace.Find(req.Query, re, string(content), maxReplaceOccurrences-total+1)
+		if total+len(occurrences) > maxReplaceOccurrences {
+			occurrences = occurrences[:maxReplaceOccurrences-total]
+			truncated = true
+		}
+		if len(occurrences) > 0 {
+			results = append(results, ReplacePage{Path: doc.Path, Title: doc.Title, Occurrences: occurrences})
+			total += len(occurrences)
+		}
+		if truncated {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"pages":     results,
+		"total":     total,
+		"truncated": truncated,
+	})
+}
+
+// ReplaceApplyHandler handles POST /api/replace/apply, replacing the selected
+// occurrences. Each changed page gets its own revision, all recorded with the
+// same summary.
+func ReplaceApplyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req ReplaceApplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	re, err := req.Compile()
+	if err != nil {
+		sendJSONError(w, "Invalid search", http.StatusBadRequest, err.Error())
+		return
+	}
+	summary := strings.TrimSpace(req.Summary)
+	if summary == "" {
+		summary = fmt.Sprintf("Replace %q with %q", req.Find, req.Replace)
+	}
+	session := auth.GetSession(r)
+
+	results := []ReplaceResult{}
+	replaced := 0
+	for _, selection := range req.Selections {
+		docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+selection.Path)), "/")
+		result := ReplaceResult{Path: "/" + docPath}
+		if !canEditPath(r, docPath) {
+			result.Error = "You are not allowed to edit this page"
+			results = append(results, result)
+			continue
+		}
+
+		docFile := filepath.Join(pageDir(cfg, docPath), "document.md")
+		content, err := encryption.ReadFile(docFile)
+		if err != nil {
+			result.Error = "Page not found"
+			results = append(results, result)
+			continue
+		}
+
+		offsets := make(map[int]bool, len(selection.Offsets))
+		for _, offset := range selection.Offsets {
+			offsets[offset] = true
+		}
+		updated, count := replace.Apply(req.Query, re, string(content), offsets)
+		result.Replaced = count
+		result.Skipped = len(offsets) - count
+		if count > 0 {
+			relativePath := pageVersionPath(docPath)
+			utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+			if err := encryption.WriteFile(docFile, []byte(updated), 0644); err != nil {
+				result.Error = "Failed to save document"
+				result.Replaced = 0
+				results = append(results, result)
+				continue
+			}
+			if err := pagestats.RecordEditSummary(cfg.Wiki.RootDir, relativePath, session.Username, summary); err != nil {
+				log.Printf("Error recording edit history for %s: %v", relativePath, err)
+			}
+			enqueueOwnerNotification(docPath, session.Username)
+			replaced += count
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"replaced": replaced,
+		"summary":  summary,
+		"pages":    results,
+	})
+}
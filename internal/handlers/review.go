@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/review"
+	"wiki-go/internal/utils"
+)
+
+// ReviewHandler handles GET /api/review, which lists all pages with a review
+// interval. ?overdue=1 limits the list to overdue pages and ?owner=me to pages
+// owned by the current user.
+func ReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	statuses, err := review.Scan(cfg, time.Now())
+	if err != nil {
+		sendJSONError(w, "Failed to scan pages", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	owner := r.URL.Query().Get("owner")
+	if owner == "me" {
+		owner = ""
+		if session := auth.GetSession(r); session != nil {
+			owner = session.Username
+		}
+	}
+
+	pages := []review.Status{}
+	for _, status := range statuses {
+		if r.URL.Query().Get("overdue") == "1" && !status.Overdue {
+			continue
+		}
+		if owner != "" && !containsString(status.Owners, owner) {
+			continue
+		}
+		pages = append(pages, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pages":   pages,
+	})
+}
+
+// MarkReviewedHandler handles POST /api/review/{path} and sets last_reviewed of the
+// page to today. An empty path marks the home page as reviewed.
+func MarkReviewedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	path, err := url.PathUnescape(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/review"), "/"))
+	if err != nil || strings.Contains(path, "..") {
+		sendJSONError(w, "Invalid path", http.StatusBadRequest, "")
+		return
+	}
+
+	relativePath := "pages/home"
+	docFile := filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md")
+	if path != "" {
+		relativePath = "documents/" + path
+		docFile = filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, path, "document.md")
+	}
+
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+
+	updated, err := review.MarkReviewed(string(content), time.Now())
+	if err != nil {
+		sendJSONError(w, "Failed to update frontmatter", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+	if err := encryption.WriteFile(docFile, []byte(updated), 0644); err != nil {
+		sendJSONError(w, "Failed to save document", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if session := auth.GetSession(r); session != nil {
+		if err := pagestats.RecordEdit(cfg.Wiki.RootDir, relativePath, session.Username); err != nil {
+			log.Printf("Error recording edit history for %s: %v", relativePath, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Page marked as reviewed",
+	})
+}
+
+// NotificationsHandler handles /api/notifications for the current user. GET lists
+// the notifications, POST marks all of them as read.
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := notifications.List(cfg.Wiki.RootDir, session.Username)
+		if err != nil {
+			sendJSONError(w, "Failed to load notifications", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		unread := 0
+		for _, n := range list {
+			if !n.Read {
+				unread++
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":       true,
+			"notifications": list,
+			"unread":        unread,
+		})
+	case http.MethodPost:
+		if err := notifications.MarkAllRead(cfg.Wiki.RootDir, session.Username); err != nil {
+			sendJSONError(w, "Failed to update notifications", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Notifications marked as read",
+		})
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
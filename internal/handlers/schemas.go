@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/lint"
+	"wiki-go/internal/schemas"
+	"wiki-go/internal/utils"
+)
+
+// SchemasPayload represents the raw YAML metadata schemas
+type SchemasPayload struct {
+	Content string `json:"content"`
+}
+
+// SchemaReportPage is a page whose frontmatter doesn't match its schemas
+type SchemaReportPage struct {
+	Path     string            `json:"path"`
+	Title    string            `json:"title"`
+	Problems []schemas.Problem `json:"problems"`
+}
+
+// schemaIssues checks the frontmatter of the page at path against its
+// schemas, returning the problems as lint issues to show them in the editor
+func schemaIssues(path, content string) []lint.Issue {
+	file, err := schemas.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading schemas: %v", err)
+		return nil
+	}
+	var issues []lint.Issue
+	for _, problem := range schemas.Validate(file.Fields(path), content) {
+		issues = append(issues, lint.Issue{Rule: "schema", Line: problem.Line, Message: problem.Message})
+	}
+	return issues
+}
+
+// hasSchema reports whether schemas set fields for the page at path
+func hasSchema(path string) bool {
+	file, err := schemas.Load(cfg.Wiki.RootDir)
+	return err == nil && len(file.Fields(path)) > 0
+}
+
+// SchemasSettingsHandler handles GET (read) and POST/PUT (update) of the
+// metadata schemas
+func SchemasSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := schemas.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read schemas", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"content": content,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req SchemasPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := schemas.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Invalid schemas", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Schemas saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// SchemaReportHandler handles GET /api/settings/schemas/report, the pages
+// whose frontmatter doesn't match their schemas, such as pages written before
+// a schema was added
+func SchemaReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	file, err := schemas.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		sendJSONError(w, "Failed to load schemas", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	pages := []SchemaReportPage{}
+	check := func(path, docFile string) {
+		fields := file.Fields(path)
+		if len(fields) == 0 {
+			return
+		}
+		content, err := encryption.ReadFile(docFile)
+		if err != nil {
+			return
+		}
+		if problems := schemas.Validate(fields, string(content)); len(problems) > 0 {
+			title := cfg.Wiki.Title
+			if path != "" {
+				title = utils.GetDocumentTitle(filepath.Dir(docFile))
+			}
+			pages = append(pages, SchemaReportPage{Path: "/" + path, Title: title, Problems: problems})
+		}
+	}
+	check("", documentFile(""))
+
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != docsDir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() != "document.md" {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		check(filepath.ToSlash(rel), path)
+		return nil
+	})
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Path < pages[j].Path })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pages":   pages,
+	})
+}
@@ -7,17 +7,25 @@ import (
 	"path/filepath"
 	"strings"
 
+	"wiki-go/internal/archive"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/permissions"
 )
 
 type SearchRequest struct {
-	Query string `json:"query"`
+	Query           string `json:"query"`
+	IncludeArchived bool   `json:"includeArchived"` // Also find archived pages
 }
 
 type SearchResult struct {
 	Title   string `json:"title"`
 	Path    string `json:"path"`
 	Excerpt string `json:"excerpt"`
+	Summary string `json:"summary,omitempty"` // Excerpt of the page, shown under its title
+	file    string // Markdown file the result was found in
 }
 
 func SearchHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
@@ -32,13 +40,33 @@ func SearchHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 		return
 	}
 
-	results := performSearch(req.Query, cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	results := performSearch(req.Query, cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, requestSubject(r))
+
+	// Drop results in directories the user may not read, unlisted pages and,
+	// unless asked for, archived pages
+	var archived *archive.List
+	if !req.IncludeArchived && !includeArchived(r) {
+		if list, err := archive.Load(cfg.Wiki.RootDir); err == nil {
+			archived = list
+		}
+	}
+	visible := results[:0]
+	for _, result := range results {
+		if _, isArchived := archived.Archived(result.Path); isArchived {
+			continue
+		}
+		if listedPath(r, result.Path) {
+			result.Summary = excerpt.Get(cfg, result.file)
+			visible = append(visible, result)
+		}
+	}
+	results = visible
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
 
-func performSearch(query string, rootDir string, documentsDir string) []SearchResult {
+func performSearch(query string, rootDir string, documentsDir string, viewer permissions.Subject) []SearchResult {
 	var results []SearchResult
 	searchTerms := parseSearchQuery(query)
 
@@ -52,14 +80,17 @@ func performSearch(query string, rootDir string, documentsDir string) []SearchRe
 
 		// Only process markdown files
 		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
-			content, err := os.ReadFile(path)
+			rawContent, err := encryption.ReadFile(path)
 			if err != nil {
 				return nil
 			}
 
-			if matches := matchContent(string(content), searchTerms); matches {
-				title := extractTitle(string(content))
-				excerpt := extractExcerpt(string(content), searchTerms)
+			// Never match or excerpt content hidden from this viewer
+			content := goldext.FilterAudience(string(rawContent), viewer)
+
+			if matches := matchContent(content, searchTerms); matches {
+				title := extractTitle(content)
+				excerpt := extractExcerpt(content, searchTerms)
 
 				// Clean up the path
 				cleanPath := path
@@ -77,6 +108,7 @@ func performSearch(query string, rootDir string, documentsDir string) []SearchRe
 					Title:   title,
 					Path:    "/" + cleanPath,
 					Excerpt: excerpt,
+					file:    path,
 				})
 			}
 		}
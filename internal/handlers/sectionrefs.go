@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/utils"
+)
+
+// SectionRefs tells what became of the links to the headings a save renamed
+type SectionRefs struct {
+	Updated int      `json:"updated"`           // Pages pointed at the new headings
+	Skipped []string `json:"skipped,omitempty"` // Pages the user may read but not change, left as they were
+}
+
+// updateSectionRefs keeps [[/page#heading]] cross-references working when a
+// save of the page at path renamed or moved some of its headings: the pages
+// the link graph shows linking to it are pointed at the new headings. Only the
+// pages the user making the save may edit are changed, each under its lock.
+func updateSectionRefs(r *http.Request, path, before, after, user string) SectionRefs {
+	var refs SectionRefs
+	path = strings.Trim(path, "/")
+	renames := goldext.SectionRenames(before, after)
+	if len(renames) == 0 {
+		return refs
+	}
+
+	graph := linkGraph(func(string) bool { return true })
+	for _, link := range graph.Links {
+		if link.Target != path {
+			continue
+		}
+		if !canEditPath(r, link.Source) {
+			// Pages the user can't read aren't named
+			if canViewPath(r, link.Source) {
+				refs.Skipped = append(refs.Skipped, "/"+link.Source)
+			}
+			continue
+		}
+		if updateSectionRefsOf(link.Source, path, renames, user) {
+			refs.Updated++
+		}
+	}
+	return refs
+}
+
+// updateSectionRefsOf points the links of the page at source to the renamed
+// headings of the page at target, and reports whether the page changed
+func updateSectionRefsOf(source, target string, renames map[string]string, user string) bool {
+	unlock, err := lockPage(source)
+	if err != nil {
+		log.Printf("Error updating cross-references in %s: %v", source, err)
+		return false
+	}
+	defer unlock()
+
+	docFile := documentFile(source)
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return false
+	}
+	markdown, changed := goldext.RenameSectionRefs(string(content), target, renames)
+	if changed == 0 {
+		return false
+	}
+
+	relativePath := pageVersionPath(source)
+	utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+	if err := encryption.WriteFile(docFile, []byte(markdown), 0644); err != nil {
+		log.Printf("Error updating cross-references in %s: %v", relativePath, err)
+		return false
+	}
+	edit := pagestats.Edit{User: user, Summary: "Update links to renamed sections of /" + target, Minor: true}
+	if err := pagestats.Record(cfg.Wiki.RootDir, relativePath, edit); err != nil {
+		log.Printf("Error recording edit history for %s: %v", relativePath, err)
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writePage writes the markdown of the page at path
+func writePage(t *testing.T, path, markdown string) {
+	t.Helper()
+	dir := filepath.Join(cfg.Wiki.RootDir, "documents", path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "document.md"), []byte(markdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSectionRefsAreOnlyUpdatedInEditablePages(t *testing.T) {
+	cookies := setupSaveTest(t)
+	guide := "# Guide\n\n## Install\n\nText\n"
+	writePage(t, "guide", guide)
+	link := "See [[/guide#Install]].\n"
+	writePage(t, "docs/a", link)
+	writePage(t, "private/b", link)
+	writePage(t, "secret/c", link)
+	rules := "rules:\n  - path: /private\n    edit: [bob]\n  - path: /secret\n    view: [bob]\n    edit: [bob]\n"
+	if err := os.WriteFile(filepath.Join(cfg.Wiki.RootDir, "permissions.yaml"), []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := savePage(cookies, "# Guide\n\n## Installation\n\nText\n", contentRevision(guide))
+	if w.Code != 200 {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		SectionRefs SectionRefs `json:"sectionRefs"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	// Pages the editor can't read aren't named
+	expected := SectionRefs{Updated: 1, Skipped: []string{"/private/b"}}
+	if !reflect.DeepEqual(response.SectionRefs, expected) {
+		t.Errorf("sectionRefs = %+v, want %+v", response.SectionRefs, expected)
+	}
+
+	pages := map[string]string{
+		"docs/a":    "See [[/guide#Installation]].\n",
+		"private/b": link,
+		"secret/c":  link,
+	}
+	for path, want := range pages {
+		content, err := os.ReadFile(filepath.Join(cfg.Wiki.RootDir, "documents", path, "document.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/lint"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/semantic"
+	"wiki-go/internal/utils"
+)
+
+// SectionSaveRequest is a section edited on its own
+type SectionSaveRequest struct {
+	ID      string `json:"id"`      // Id of the heading of the section when it was opened
+	Hash    string `json:"hash"`    // Hash of the section when it was opened
+	Content string `json:"content"` // Markdown of the section, heading included
+	Summary string `json:"summary"` // What was changed, for the history of the page
+	Minor   bool   `json:"minor"`   // Minor edits aren't notified
+}
+
+// SectionHandler handles /api/section/{path}, editing one section of a page:
+//   - GET lists the sections of the page, or with ?id= returns the markdown of
+//     the section under that heading
+//   - POST saves a section into the page, unless it changed since it was opened
+func SectionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	session := auth.GetSession(r)
+	if session == nil || (session.Role != roles.RoleAdmin && session.Role != roles.RoleEditor) {
+		sendJSONError(w, "Unauthorized. Admin or editor access required.", http.StatusUnauthorized, "")
+		return
+	}
+
+	path := strings.Trim(filepath.ToSlash(filepath.Clean("/"+strings.TrimPrefix(r.URL.Path, "/api/section"))), "/")
+	if _, archived := archivedEntry(path); archived {
+		sendJSONError(w, "This page is archived", http.StatusConflict, "Unarchive it to edit it")
+		return
+	}
+	if !canEditPath(r, path) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return
+	}
+
+	docFile := documentFile(path)
+	current, err := encryption.ReadFile(docFile)
+	if os.IsNotExist(err) {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to read document", http.StatusInternalServerError, err.Error())
+		return
+	}
+	markdown := string(current)
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			sections := goldext.Sections(markdown)
+			if sections == nil {
+				sections = []goldext.MarkdownSection{}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success":  true,
+				"sections": sections,
+			})
+			return
+		}
+		section, ok := goldext.FindSection(markdown, id)
+		if !ok {
+			sendJSONError(w, "Section not found", http.StatusNotFound, id)
+			return
+		}
+		text := markdown[section.Start:section.End]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"section": section,
+			"content": text,
+			"hash":    contentRevision(text),
+		})
+
+	case http.MethodPost:
+		var req SectionSaveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		content, ok := replaceSection(w, markdown, req)
+		if !ok {
+			return
+		}
+
+		var warnings []lint.Issue
+		if mode := lintMode(); mode != lint.ModeOff {
+			warnings = lintPage(filepath.Dir(docFile), content)
+			if mode == lint.ModeBlock && len(warnings) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": false,
+					"message": "The document has style problems that must be fixed before saving",
+					"issues":  warnings,
+				})
+				return
+			}
+		}
+		if problems := schemaIssues(path, content); len(problems) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"message": "The frontmatter doesn't match the schema of this page",
+				"issues":  append(problems, warnings...),
+			})
+			return
+		}
+
+		// The page is read again under its lock, in case it was saved since
+		unlock, err := lockPage(path)
+		if err != nil {
+			sendJSONError(w, "The page is being saved by someone else", http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer unlock()
+		if latest, err := encryption.ReadFile(docFile); err == nil && string(latest) != markdown {
+			markdown = string(latest)
+			if content, ok = replaceSection(w, markdown, req); !ok {
+				return
+			}
+		}
+
+		relativePath := "documents/" + path
+		if path == "" {
+			relativePath = "pages/home"
+		}
+		utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+		if err := encryption.WriteFile(docFile, []byte(content), 0644); err != nil {
+			sendJSONError(w, "Failed to save document", http.StatusInternalServerError, err.Error())
+			return
+		}
+		edit := pagestats.Edit{User: session.Username, Summary: editSummary(req.Summary), Minor: req.Minor}
+		if err := pagestats.Record(cfg.Wiki.RootDir, relativePath, edit); err != nil {
+			log.Printf("Error recording edit history for %s: %v", relativePath, err)
+		}
+		if !edit.Minor {
+			enqueueOwnerNotification(path, session.Username)
+		}
+		// Other pages are updated under their own locks
+		unlock()
+		refs := updateSectionRefs(r, path, markdown, content, session.Username)
+		semantic.Changed()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"message":     "Section saved successfully",
+			"warnings":    warnings,
+			"sectionRefs": refs,
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// replaceSection returns the page with the section req edited replaced, when
+// nobody changed the section in the meantime; edits made elsewhere in the page
+// are kept. Otherwise it answers with the conflict.
+func replaceSection(w http.ResponseWriter, markdown string, req SectionSaveRequest) (string, bool) {
+	section, ok := goldext.FindSection(markdown, req.ID)
+	if !ok {
+		sendJSONError(w, "The section is no longer in the page", http.StatusConflict, "Someone removed or renamed it while you were editing")
+		return "", false
+	}
+	text := markdown[section.Start:section.End]
+	if contentRevision(text) != req.Hash {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "The section was changed by someone else while you were editing",
+			"current": text,
+		})
+		return "", false
+	}
+	return goldext.ReplaceSection(markdown, section, req.Content), true
+}
@@ -3,9 +3,9 @@ package handlers
 import (
     "encoding/json"
     "net/http"
-    "os"
     "sync"
     "wiki-go/internal/config"
+    "wiki-go/internal/sanitize"
 )
 
 var securityMu sync.Mutex
@@ -19,6 +19,13 @@ type SecuritySettings struct {
         InitialBanSeconds int  `json:"initial_ban_seconds"`
         MaxBanSeconds     int  `json:"max_ban_seconds"`
     } `json:"login_ban"`
+    Passkeys struct {
+        RequireForAdmins bool `json:"require_for_admins"`
+    } `json:"passkeys"`
+    Sanitizer struct {
+        TrustedEditors bool   `json:"trusted_editors"`
+        AllowElements  string `json:"allow_elements"`
+    } `json:"sanitizer"`
 }
 
 // SecuritySettingsHandler handles GET (read) and POST (update) of security settings.
@@ -42,6 +49,9 @@ func handleGetSecurity(w http.ResponseWriter, r *http.Request) {
     resp.LoginBan.WindowSeconds = cfg.Security.LoginBan.WindowSeconds
     resp.LoginBan.InitialBanSeconds = cfg.Security.LoginBan.InitialBanSeconds
     resp.LoginBan.MaxBanSeconds = cfg.Security.LoginBan.MaxBanSeconds
+    resp.Passkeys.RequireForAdmins = cfg.Security.Passkeys.RequireForAdmins
+    resp.Sanitizer.TrustedEditors = cfg.Security.Sanitizer.TrustedEditors
+    resp.Sanitizer.AllowElements = cfg.Security.Sanitizer.AllowElements
 
     json.NewEncoder(w).Encode(resp)
 }
@@ -58,9 +68,22 @@ func handleUpdateSecurity(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Invalid values", http.StatusBadRequest)
         return
     }
+    if err := sanitize.DefaultPolicy().AllowElements(req.Sanitizer.AllowElements); err != nil {
+        http.Error(w, "Invalid allowed elements: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    // Admins couldn't register the passkey they need and would be locked out
+    if req.Passkeys.RequireForAdmins {
+        if _, err := relyingParty(); err != nil {
+            http.Error(w, "Passkeys can't be required: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+    }
 
     securityMu.Lock()
     defer securityMu.Unlock()
+    config.Lock()
+    defer config.Unlock()
 
     // Update cfg in-memory
     cfg.Security.LoginBan.Enabled = req.LoginBan.Enabled
@@ -68,13 +91,14 @@ func handleUpdateSecurity(w http.ResponseWriter, r *http.Request) {
     cfg.Security.LoginBan.WindowSeconds = req.LoginBan.WindowSeconds
     cfg.Security.LoginBan.InitialBanSeconds = req.LoginBan.InitialBanSeconds
     cfg.Security.LoginBan.MaxBanSeconds = req.LoginBan.MaxBanSeconds
+    cfg.Security.Passkeys.RequireForAdmins = req.Passkeys.RequireForAdmins
+    cfg.Security.Sanitizer.TrustedEditors = req.Sanitizer.TrustedEditors
+    cfg.Security.Sanitizer.AllowElements = req.Sanitizer.AllowElements
 
     // Persist to disk
-    // Reuse SaveConfig with config.ConfigFilePath
-    f, err := os.Create(config.ConfigFilePath)
-    if err == nil {
-        _ = config.SaveConfig(cfg, f)
-        f.Close()
+    if err := config.WriteFile(config.ConfigFilePath, cfg); err != nil {
+        http.Error(w, "Failed to save configuration: "+err.Error(), http.StatusInternalServerError)
+        return
     }
 
     // Reinitialise ban list with new policy
@@ -2,10 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
-	"time"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
 	"wiki-go/internal/i18n"
@@ -23,6 +20,9 @@ type WikiSettingsRequest struct {
 	EnableLinkEmbedding       bool   `json:"enable_link_embedding"`
 	HideAttachments           bool   `json:"hide_attachments"`
 	DisableContentMaxWidth    bool   `json:"disable_content_max_width"`
+	HighContrast              bool   `json:"high_contrast"`
+	UnderlineLinks            bool   `json:"underline_links"`
+	UserDashboards            bool   `json:"user_dashboards"`
 	MaxVersions               int    `json:"max_versions"`
 	MaxUploadSize             int    `json:"max_upload_size"`
 	Language                  string `json:"language"`
@@ -40,6 +40,9 @@ type WikiSettingsResponse struct {
 	EnableLinkEmbedding       bool     `json:"enable_link_embedding"`
 	HideAttachments           bool     `json:"hide_attachments"`
 	DisableContentMaxWidth    bool     `json:"disable_content_max_width"`
+	HighContrast              bool     `json:"high_contrast"`
+	UnderlineLinks            bool     `json:"underline_links"`
+	UserDashboards            bool     `json:"user_dashboards"`
 	MaxVersions               int      `json:"max_versions"`
 	MaxUploadSize             int      `json:"max_upload_size"`
 	Language                  string   `json:"language"`
@@ -79,6 +82,9 @@ func GetWikiSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		EnableLinkEmbedding:       cfg.Wiki.EnableLinkEmbedding,
 		HideAttachments:           cfg.Wiki.HideAttachments,
 		DisableContentMaxWidth:    cfg.Wiki.DisableContentMaxWidth,
+		HighContrast:              cfg.Wiki.HighContrast,
+		UnderlineLinks:            cfg.Wiki.UnderlineLinks,
+		UserDashboards:            cfg.Wiki.UserDashboards,
 		MaxVersions:               cfg.Wiki.MaxVersions,
 		MaxUploadSize:             cfg.Wiki.MaxUploadSize,
 		Language:                  cfg.Wiki.Language,
@@ -119,6 +125,8 @@ func UpdateWikiSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a copy of the current config
+	config.Lock()
+	defer config.Unlock()
 	updatedConfig := *cfg
 
 	// Update the wiki settings
@@ -132,6 +140,9 @@ func UpdateWikiSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	updatedConfig.Wiki.EnableLinkEmbedding = req.EnableLinkEmbedding
 	updatedConfig.Wiki.HideAttachments = req.HideAttachments
 	updatedConfig.Wiki.DisableContentMaxWidth = req.DisableContentMaxWidth
+	updatedConfig.Wiki.HighContrast = req.HighContrast
+	updatedConfig.Wiki.UnderlineLinks = req.UnderlineLinks
+	updatedConfig.Wiki.UserDashboards = req.UserDashboards
 	updatedConfig.Wiki.MaxVersions = req.MaxVersions
 	updatedConfig.Wiki.MaxUploadSize = req.MaxUploadSize
 	updatedConfig.Wiki.Language = req.Language
@@ -155,49 +166,7 @@ func UpdateWikiSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// saveConfig saves the configuration to a file
+// saveConfig saves the configuration to a file, while config.Lock is held
 func saveConfig(path string, cfg *config.Config) error {
-	// Create a temporary file with a unique name using timestamp
-	tempFile := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
-	file, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
-	}
-
-	// Close the file properly regardless of what happens later
-	defer func() {
-		file.Close()
-		// Attempt to clean up the temporary file
-		os.Remove(tempFile)
-	}()
-
-	// Save to the temporary file
-	if err := config.SaveConfig(cfg, file); err != nil {
-		return fmt.Errorf("failed to save configuration: %w", err)
-	}
-
-	// Make sure data is written to disk
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to sync temporary file: %w", err)
-	}
-
-	// Close the file explicitly to ensure it's fully written
-	if err := file.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary file: %w", err)
-	}
-
-	// Read the content of the temporary file
-	content, err := os.ReadFile(tempFile)
-	if err != nil {
-		return fmt.Errorf("failed to read temporary file: %w", err)
-	}
-
-	// Write directly to the target file
-	// This approach avoids the rename operation that can cause locking issues
-	err = os.WriteFile(path, content, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write configuration file: %w", err)
-	}
-
-	return nil
+	return config.WriteFile(path, cfg)
 }
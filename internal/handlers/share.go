@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/resources"
+	"wiki-go/internal/shares"
+	"wiki-go/internal/signing"
+	"wiki-go/internal/utils"
+	"wiki-go/internal/version"
+)
+
+// shareTokenPurpose tells the tokens of opened share links apart from other signed tokens
+const shareTokenPurpose = "share"
+
+// shareSessionHours is how long a share link stays open after its password was entered
+const shareSessionHours = 12
+
+// shareClaims record that the password of a share link was entered
+type shareClaims struct {
+	signing.Claims
+	ID string `json:"i"`
+}
+
+// SharePage holds the data for the read-only view of a page opened with a share link
+type SharePage struct {
+	Title    string
+	Content  template.HTML
+	Config   *config.Config
+	ID       string
+	Password bool // The password form is shown instead of the content
+	Error    string
+}
+
+// ShareInfo is a share link as listed for editors, without the password hash
+type ShareInfo struct {
+	ID          string          `json:"id"`
+	URL         string          `json:"url"`
+	Path        string          `json:"path"`
+	Attachments bool            `json:"attachments"`
+	Password    bool            `json:"password"`
+	CreatedBy   string          `json:"createdBy"`
+	Created     time.Time       `json:"created"`
+	Expires     *time.Time      `json:"expires,omitempty"`
+	Revoked     *time.Time      `json:"revoked,omitempty"`
+	Active      bool            `json:"active"`
+	Views       int             `json:"views"`
+	Accesses    []shares.Access `json:"accesses"`
+}
+
+func shareInfo(r *http.Request, share shares.Share) ShareInfo {
+	return ShareInfo{
+		ID:          share.ID,
+		URL:         getBaseURL(r, cfg) + "/s/" + share.ID,
+		Path:        share.Path,
+		Attachments: share.Attachments,
+		Password:    share.HasPassword(),
+		CreatedBy:   share.CreatedBy,
+		Created:     share.Created,
+		Expires:     share.Expires,
+		Revoked:     share.Revoked,
+		Active:      share.Active(),
+		Views:       share.Views,
+		Accesses:    share.Accesses,
+	}
+}
+
+// SharesHandler handles /api/shares: GET lists the share links of ?path= (all
+// links for admins when it is missing) with their access logs, POST creates a
+// link and DELETE /api/shares/{id} revokes one
+func SharesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listShares(w, r)
+	case http.MethodPost:
+		createShare(w, r)
+	case http.MethodDelete:
+		revokeShare(w, r)
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+func listShares(w http.ResponseWriter, r *http.Request) {
+	var path *string
+	if r.URL.Query().Has("path") {
+		docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+		if !canViewPath(r, docPath) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+			return
+		}
+		path = &docPath
+	} else if !auth.RequireRole(r, "admin") {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+
+	list, err := shares.List(cfg.Wiki.RootDir, path)
+	if err != nil {
+		sendJSONError(w, "Failed to load share links", http.StatusInternalServerError, err.Error())
+		return
+	}
+	infos := make([]ShareInfo, 0, len(list))
+	for _, share := range list {
+		infos = append(infos, shareInfo(r, share))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"shares":  infos,
+	})
+}
+
+func createShare(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path        string `json:"path"`
+		Days        int    `json:"days"` // 0 for a link that doesn't expire
+		Password    string `json:"password"`
+		Attachments bool   `json:"attachments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+		return
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	if _, err := os.Stat(filepath.Join(pageDir(cfg, docPath), "document.md")); err != nil {
+		sendJSONError(w, "Document not found", http.StatusNotFound, "")
+		return
+	}
+
+	var expires time.Time
+	if req.Days > 0 {
+		expires = time.Now().AddDate(0, 0, req.Days)
+	}
+	createdBy := ""
+	if session := auth.GetSession(r); session != nil {
+		createdBy = session.Username
+	}
+	share, err := shares.Create(cfg.Wiki.RootDir, docPath, createdBy, req.Password, req.Attachments, expires)
+	if err != nil {
+		sendJSONError(w, "Failed to create share link", http.StatusInternalServerError, err.Error())
+		return
+	}
+	info := shareInfo(r, share)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"share":   info,
+		"url":     info.URL,
+	})
+}
+
+func revokeShare(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/shares"), "/")
+	share, err := shares.Get(cfg.Wiki.RootDir, id)
+	if err != nil {
+		sendJSONError(w, "Share link not found", http.StatusNotFound, "")
+		return
+	}
+	// Links can be revoked by whoever may change the page they share
+	if !canEditPath(r, share.Path) && !auth.RequireRole(r, "admin") {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	if err := shares.Revoke(cfg.Wiki.RootDir, id); err != nil {
+		sendJSONError(w, "Failed to revoke share link", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Share link revoked",
+	})
+}
+
+// ShareViewHandler serves /s/{id}, the read-only view of a page opened with a
+// share link, and /s/{id}/files/{name}, the attachments of the page when the
+// link includes them. No login is needed, even on private wikis. Links with a
+// password ask for it once; a signed cookie keeps the link open afterwards.
+func ShareViewHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	rest := strings.TrimPrefix(r.URL.Path, "/s/")
+	id, file, _ := strings.Cut(rest, "/")
+
+	share, err := shares.Get(cfg.Wiki.RootDir, id)
+	if err != nil || !share.Active() {
+		http.Error(w, "This link does not exist or has expired", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	if r.Method == http.MethodPost && file == "" {
+		openShare(w, r, cfg, share)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opened := !share.HasPassword() || shareOpened(r, cfg, share.ID)
+	if file != "" {
+		file, ok := strings.CutPrefix(file, "files/")
+		if !ok || !opened || !share.Attachments {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		serveSharedFile(w, r, cfg, share, file)
+		return
+	}
+	if !opened {
+		renderShare(w, SharePage{Title: cfg.Wiki.Title, Config: cfg, ID: share.ID, Password: true})
+		return
+	}
+
+	content, err := encryption.ReadFile(filepath.Join(pageDir(cfg, share.Path), "document.md"))
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	// The page is shown as to a visitor without an account
+	visibleContent := goldext.FilterAudience(string(content), permissions.Subject{})
+	var rendered string
+	if !renderLimited(w, func() {
+		rendered = string(utils.RenderMarkdownWithPath(visibleContent, share.Path))
+	}) {
+		return
+	}
+	if share.Attachments {
+		prefix := "/api/files/" + share.Path + "/"
+		if share.Path == "" {
+			prefix = "/api/files/pages/home/"
+		}
+		rendered = regexp.MustCompile(`(src|href)="`+regexp.QuoteMeta(prefix)+`([^"/?]*)"`).ReplaceAllString(rendered, `$1="/s/`+share.ID+`/files/$2"`)
+	}
+
+	if err := shares.RecordAccess(cfg.Wiki.RootDir, share.ID, shares.Access{
+		Time:      time.Now(),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}); err != nil {
+		log.Printf("Error recording access to share link %s: %v", share.ID, err)
+	}
+
+	title := cfg.Wiki.Title
+	if share.Path != "" {
+		title = utils.GetDocumentTitle(pageDir(cfg, share.Path))
+	}
+	renderShare(w, SharePage{Title: title, Content: template.HTML(rendered), Config: cfg, ID: share.ID})
+}
+
+// openShare checks the password entered for a share link and sets the cookie
+// keeping the link open
+func openShare(w http.ResponseWriter, r *http.Request, cfg *config.Config, share shares.Share) {
+	ip := clientIP(r)
+	if loginBan != nil && loginBan.IsBanned(ip) > 0 {
+		renderShare(w, SharePage{Title: cfg.Wiki.Title, Config: cfg, ID: share.ID, Password: true, Error: i18n.Translate("share.too_many")})
+		return
+	}
+	if !share.CheckPassword(r.FormValue("password")) {
+		if loginBan != nil {
+			loginBan.RegisterFailure(ip)
+		}
+		renderShare(w, SharePage{Title: cfg.Wiki.Title, Config: cfg, ID: share.ID, Password: true, Error: i18n.Translate("share.wrong_password")})
+		return
+	}
+
+	claims := shareClaims{
+		Claims: signing.Claims{Purpose: shareTokenPurpose, Expires: time.Now().Add(shareSessionHours * time.Hour).Unix()},
+		ID:     share.ID,
+	}
+	token, err := signing.Sign(cfg.Wiki.RootDir, claims)
+	if err != nil {
+		http.Error(w, "Failed to open share link", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "share_token",
+		Value:    token,
+		Path:     "/s/" + share.ID,
+		MaxAge:   shareSessionHours * 3600,
+		HttpOnly: true,
+		Secure:   !cfg.Server.AllowInsecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/s/"+share.ID, http.StatusSeeOther)
+}
+
+// shareOpened reports whether the password of the share link with id was entered
+func shareOpened(r *http.Request, cfg *config.Config, id string) bool {
+	cookie, err := r.Cookie("share_token")
+	if err != nil {
+		return false
+	}
+	var claims shareClaims
+	if err := signing.Verify(cfg.Wiki.RootDir, cookie.Value, shareTokenPurpose, &claims); err != nil {
+		return false
+	}
+	return claims.ID == id
+}
+
+// serveSharedFile serves an attachment of a shared page
+func serveSharedFile(w http.ResponseWriter, r *http.Request, cfg *config.Config, share shares.Share, name string) {
+	if name == "" || name == "document.md" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	path := share.Path + "/" + name
+	if share.Path == "" {
+		path = "pages/home/" + name
+	}
+	serveAttachment(w, r, cfg, path)
+}
+
+// renderShare writes the share view template
+func renderShare(w http.ResponseWriter, data SharePage) {
+	funcMap := template.FuncMap{
+		"t": func(key string) string {
+			return i18n.Translate(key)
+		},
+		"getVersion": func() string {
+			return version.Version
+		},
+		"asset":         assetURL,
+		"mermaidScript": mermaidScript,
+		"mermaidConfig": mermaidConfig,
+	}
+
+	tmpl, err := template.New("share.html").Funcs(funcMap).ParseFS(resources.GetTemplatesFS(), "templates/share.html")
+	if err != nil {
+		http.Error(w, "Error loading share template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering share template: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
 	"wiki-go/internal/resources"
 )
 
@@ -73,7 +74,9 @@ func SitemapHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config)
 	}
 
 	// Gather all pages
-	urls, pageEntries, err := gatherPages(baseURL, cfg)
+	urls, pageEntries, err := gatherPages(baseURL, cfg, func(path string) bool {
+		return listedPath(r, path)
+	})
 	if err != nil {
 		http.Error(w, "Error generating sitemap: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -97,8 +100,10 @@ func renderXMLSitemap(w http.ResponseWriter, urls []SitemapURL) {
 	// Set correct content type for XML
 	w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
 
-	// Add cache control headers - sitemaps can be cached for a day
+	// Add cache control headers - sitemaps can be cached for a day. They list
+	// what the requesting user may read, so caches keep one per login.
 	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Vary", "Cookie")
 
 	// Write XML header
 	w.Write([]byte(xml.Header))
@@ -157,7 +162,7 @@ func renderHTMLSitemap(w http.ResponseWriter, r *http.Request, pages []SitemapPa
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 
 	// Parse and execute the template using the embedded filesystem
-	tmpl, err := template.ParseFS(resources.GetTemplatesFS(), "templates/sitemap.html")
+	tmpl, err := template.New("sitemap.html").Funcs(template.FuncMap{"asset": assetURL}).ParseFS(resources.GetTemplatesFS(), "templates/sitemap.html")
 	if err != nil {
 		http.Error(w, "Error parsing sitemap template: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -168,8 +173,8 @@ func renderHTMLSitemap(w http.ResponseWriter, r *http.Request, pages []SitemapPa
 	}
 }
 
-// gatherPages collects all pages for the sitemap
-func gatherPages(baseURL string, cfg *config.Config) ([]SitemapURL, []SitemapPageEntry, error) {
+// gatherPages collects all pages for the sitemap that listed accepts
+func gatherPages(baseURL string, cfg *config.Config, listed func(path string) bool) ([]SitemapURL, []SitemapPageEntry, error) {
 	urls := []SitemapURL{}
 	pageEntries := []SitemapPageEntry{}
 
@@ -213,8 +218,8 @@ func gatherPages(baseURL string, cfg *config.Config) ([]SitemapURL, []SitemapPag
 			// Convert path separators to forward slashes
 			relDirPath = filepath.ToSlash(relDirPath)
 
-			// Skip hidden directories
-			if strings.HasPrefix(filepath.Base(relDirPath), ".") {
+			// Skip hidden directories and pages the user may not see listed
+			if strings.HasPrefix(filepath.Base(relDirPath), ".") || !listed(relDirPath) {
 				return nil
 			}
 
@@ -268,7 +273,7 @@ func gatherPages(baseURL string, cfg *config.Config) ([]SitemapURL, []SitemapPag
 
 // getDocumentTitle extracts the title from a markdown file's first heading
 func getDocumentTitle(filePath string) string {
-	content, err := os.ReadFile(filePath)
+	content, err := encryption.ReadFile(filePath)
 	if err != nil {
 		return ""
 	}
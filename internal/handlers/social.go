@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/excerpt"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/socialimage"
+	"wiki-go/internal/types"
+	"wiki-go/internal/utils"
+)
+
+// publicPath reports whether guests may read the page at path
+func publicPath(path string) bool {
+	if cfg.Wiki.Private {
+		return false
+	}
+	file := loadPermissions()
+	return file != nil && file.CanView(path, subjectFor("", ""))
+}
+
+// socialMeta returns the link card tags of the page at docPath, read from
+// file, or nil when guests may not read it. Chat apps and social sites fetch
+// pages without logging in, so only public pages get a card.
+func socialMeta(r *http.Request, docPath, file, title, description string, modified time.Time) *types.SocialMeta {
+	docPath = "/" + strings.Trim(docPath, "/")
+	if !publicPath(docPath) {
+		return nil
+	}
+
+	baseURL := getBaseURL(r, cfg)
+	meta := &types.SocialMeta{
+		Title:       title,
+		Description: description,
+		URL:         baseURL + docPath,
+		Type:        "article",
+		SiteName:    cfg.Wiki.Title,
+		Card:        "summary",
+	}
+	if docPath == "/" {
+		meta.Type = "website"
+		meta.Title = cfg.Wiki.Title
+	}
+	if !modified.IsZero() {
+		meta.Modified = modified.UTC().Format(time.RFC3339)
+	}
+
+	image := excerpt.Lookup(cfg, file).Image
+	if content, err := encryption.ReadFile(file); err == nil {
+		if metadata, _, ok := frontmatter.Parse(string(content)); ok {
+			social := metadata.Social
+			if social.Title != "" {
+				meta.Title = social.Title
+			}
+			if social.Description != "" {
+				meta.Description = excerpt.Truncate(social.Description, excerpt.MaxLength)
+			}
+			if social.Image != "" {
+				image = goldext.FileURL(social.Image, docPath)
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(image, "https://") || strings.HasPrefix(image, "http://"):
+		meta.Image = image
+	case strings.HasPrefix(image, "/"):
+		meta.Image = baseURL + image
+	}
+	switch {
+	case meta.Image != "":
+		meta.Card = "summary_large_image"
+	case cfg.Wiki.SocialImages:
+		// Pages without images get one drawn with their title
+		meta.Image = baseURL + socialImagePrefix + strings.TrimPrefix(docPath, "/")
+		meta.Card = "summary_large_image"
+	default:
+		// Otherwise the logo of the wiki, when it has its own
+		if _, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, "static", "logo.png")); err == nil {
+			meta.Image = baseURL + "/logo.png"
+		}
+	}
+	return meta
+}
+
+// socialImagePrefix is the path the drawn link card images are served under
+const socialImagePrefix = "/api/social-image/"
+
+// SocialImageHandler handles GET /api/social-image/{path}, returning the PNG
+// drawn with the title and breadcrumb of a public page for its link card
+func SocialImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+strings.TrimPrefix(r.URL.Path, socialImagePrefix))), "/")
+	dir := pageDir(cfg, docPath)
+	if info, err := os.Stat(dir); !cfg.Wiki.SocialImages || !publicPath("/"+docPath) || err != nil || !info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	card := socialimage.Card{
+		Title:    cfg.Wiki.Title,
+		SiteName: cfg.Wiki.Title,
+		Color:    cfg.Wiki.SocialImageColor,
+	}
+	if docPath != "" {
+		card.Title = utils.GetDocumentTitle(dir)
+		parts := strings.Split(docPath, "/")
+		for i := 1; i < len(parts); i++ {
+			card.Breadcrumb = append(card.Breadcrumb, utils.GetDocumentTitle(pageDir(cfg, strings.Join(parts[:i], "/"))))
+		}
+	}
+	if content, err := encryption.ReadFile(filepath.Join(dir, "document.md")); err == nil {
+		if metadata, _, ok := frontmatter.Parse(string(content)); ok && metadata.Social.Title != "" {
+			card.Title = metadata.Social.Title
+		}
+	}
+
+	data, modified, err := socialimage.Get(cfg.Wiki.RootDir, "/"+docPath, card)
+	if err != nil {
+		log.Printf("Error drawing link card image of /%s: %v", docPath, err)
+		http.Error(w, "Failed to draw image", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "card.png", modified, bytes.NewReader(data))
+}
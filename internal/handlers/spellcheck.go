@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/spell"
+	"wiki-go/internal/translations"
+)
+
+// Number of suggestions offered for a misspelled word
+const spellSuggestionLimit = 8
+
+// DictionaryPayload is the custom dictionary, or a word added to it
+type DictionaryPayload struct {
+	Content string `json:"content"`
+	Word    string `json:"word"`
+}
+
+// pageLanguage returns the language set in the page frontmatter, or the wiki
+// language
+func pageLanguage(markdown string) string {
+	if metadata, _, ok := frontmatter.Parse(markdown); ok && metadata.Lang != "" {
+		return metadata.Lang
+	}
+	return cfg.Wiki.Language
+}
+
+// SpellcheckHandler handles POST /api/spellcheck?path=, returning the
+// misspelled words of the markdown in the request body
+func SpellcheckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+	markdown, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		sendJSONError(w, "Failed to read request body", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	language := pageLanguage(string(markdown))
+	if lang := r.URL.Query().Get("lang"); translations.Valid(lang) && language == cfg.Wiki.Language {
+		// Translations are checked in their own language unless their frontmatter says otherwise
+		language = lang
+	}
+	misspellings := []spell.Misspelling{}
+	checker, err := spell.NewChecker(cfg.Wiki.RootDir, language)
+	switch {
+	case errors.Is(err, spell.ErrNoDictionary):
+		// The editor stops checking the page
+	case err != nil:
+		sendJSONError(w, "Failed to load dictionary", http.StatusInternalServerError, err.Error())
+		return
+	default:
+		misspellings = checker.Check(string(markdown))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"language":     language,
+		"available":    checker != nil,
+		"misspellings": misspellings,
+	})
+}
+
+// SpellSuggestionsHandler handles GET /api/spellcheck/suggestions?lang=&word=,
+// returning correct words close to word
+func SpellSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	word := strings.TrimSpace(r.URL.Query().Get("word"))
+	if word == "" || len(word) > 100 {
+		sendJSONError(w, "A word is required", http.StatusBadRequest, "")
+		return
+	}
+	language := r.URL.Query().Get("lang")
+	if language == "" {
+		language = cfg.Wiki.Language
+	}
+
+	checker, err := spell.NewChecker(cfg.Wiki.RootDir, language)
+	if errors.Is(err, spell.ErrNoDictionary) {
+		sendJSONError(w, "No dictionary for this language", http.StatusNotFound, language)
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to load dictionary", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"suggestions": checker.Suggest(word, spellSuggestionLimit),
+	})
+}
+
+// DictionaryHandler handles the custom dictionary of project terms: GET reads
+// it, PUT replaces it and POST adds a single word
+func DictionaryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := spell.ReadCustom(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read dictionary", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"content":   content,
+			"languages": spell.Languages(cfg.Wiki.RootDir),
+		})
+
+	case http.MethodPut, http.MethodPost:
+		var req DictionaryPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		var err error
+		if r.Method == http.MethodPost {
+			err = spell.AddCustom(cfg.Wiki.RootDir, req.Word)
+		} else {
+			err = spell.SaveCustom(cfg.Wiki.RootDir, req.Content)
+		}
+		if err != nil {
+			sendJSONError(w, "Invalid dictionary", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Dictionary saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/translations"
+)
+
+// Number of suggestions returned when the request doesn't set a limit
+const defaultSuggestLimit = 20
+
+// Snippet is a block the editor offers after typing / at the start of a line.
+// $0 marks where the cursor goes after inserting it.
+type Snippet struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Text        string `json:"text"`
+}
+
+// AttachmentSuggestion is a file attached to the page being edited
+type AttachmentSuggestion struct {
+	Name  string `json:"name"`
+	Image bool   `json:"image"`
+}
+
+// blockSnippets are the blocks offered by the editor, in menu order
+var blockSnippets = []Snippet{
+	{Name: "table", Description: "Table with two columns", Text: "| $0Column | Column |\n| ------ | ------ |\n|        |        |\n"},
+	{Name: "code", Description: "Code block", Text: "```$0\n\n```\n"},
+	{Name: "details", Description: "Collapsible section", Text: "```details Summary\n$0\n```\n"},
+	{Name: "note", Description: "Highlighted note", Text: "> **Note**\n> $0\n"},
+	{Name: "mermaid", Description: "Mermaid diagram", Text: "```mermaid\ngraph TD\n    A[$0Start] --> B[End]\n```\n"},
+	{Name: "plantuml", Description: "PlantUML diagram", Text: "```plantuml\n@startuml\n$0\n@enduml\n```\n"},
+	{Name: "math", Description: "Math formula", Text: "$$\n$0\n$$\n"},
+	{Name: "tasks", Description: "Task list", Text: "- [ ] $0\n- [ ] \n"},
+	{Name: "toc", Description: "Table of contents", Text: "[toc]\n$0"},
+	{Name: "recent", Description: "Recently edited pages", Text: ":::stats recent=5:::\n$0"},
+}
+
+// suggestLimit returns the number of suggestions asked for
+func suggestLimit(r *http.Request) int {
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit <= 100 {
+		return limit
+	}
+	return defaultSuggestLimit
+}
+
+// matchScore ranks how well text matches query, lower is better and -1 means no match
+func matchScore(text, query string) int {
+	text = strings.ToLower(text)
+	switch index := strings.Index(text, query); {
+	case index == 0:
+		return 0
+	case index > 0 && !isWordChar(text[index-1]):
+		return 1
+	case index > 0:
+		return 2
+	}
+	return -1
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// SuggestPagesHandler handles GET /api/suggest/pages?q=, returning the pages
+// whose title or path match, best matches first
+func SuggestPagesHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	documents, err := listDocuments(r, cfg)
+	if err != nil {
+		sendJSONError(w, "Failed to list documents", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	type ranked struct {
+		Document
+		score int
+	}
+	var matches []ranked
+	for _, doc := range documents {
+		score := matchScore(doc.Title, query)
+		if score < 0 {
+			// Path matches rank below title matches
+			if score = matchScore(doc.Path, query); score >= 0 {
+				score += 3
+			}
+		}
+		if score >= 0 {
+			matches = append(matches, ranked{doc, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score < matches[j].score
+		}
+		return strings.ToLower(matches[i].Title) < strings.ToLower(matches[j].Title)
+	})
+
+	pages := make([]Document, 0, len(matches))
+	for _, match := range matches {
+		if len(pages) == suggestLimit(r) {
+			break
+		}
+		pages = append(pages, match.Document)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pages":   pages,
+	})
+}
+
+// pageDir returns the directory holding the page at docPath and its attachments
+func pageDir(cfg *config.Config, docPath string) string {
+	// The home page is kept in pages/home
+	if docPath == "" {
+		return filepath.Join(cfg.Wiki.RootDir, "pages", "home")
+	}
+	return filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, docPath)
+}
+
+// SuggestAttachmentsHandler handles GET /api/suggest/attachments?path=&q=,
+// returning the files attached to the page at path
+func SuggestAttachmentsHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+r.URL.Query().Get("path"))), "/")
+	if !canViewPath(r, docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+		return
+	}
+
+	entries, err := os.ReadDir(pageDir(cfg, docPath))
+	if err != nil && !os.IsNotExist(err) {
+		sendJSONError(w, "Failed to list attachments", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	attachments := make([]AttachmentSuggestion, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == "document.md" || translations.IsVariant(entry.Name()) || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !cfg.Wiki.DisableFileUploadChecking && !config.IsAllowedExtension(ext) {
+			continue
+		}
+		if matchScore(entry.Name(), query) < 0 {
+			continue
+		}
+		attachments = append(attachments, AttachmentSuggestion{
+			Name:  entry.Name(),
+			Image: strings.HasPrefix(config.GetMimeTypeForExtension(ext), "image/"),
+		})
+		if len(attachments) == suggestLimit(r) {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"attachments": attachments,
+	})
+}
+
+// SuggestSnippetsHandler handles GET /api/suggest/snippets, returning the
+// blocks the editor offers after typing /
+func SuggestSnippetsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"snippets": blockSnippets,
+	})
+}
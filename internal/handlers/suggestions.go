@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/semantic"
+	"wiki-go/internal/suggestions"
+	"wiki-go/internal/textdiff"
+	"wiki-go/internal/utils"
+)
+
+// Longest accepted summary of a suggestion
+const maxSuggestionSummary = 500
+
+// SuggestionRequest is the body of POST /api/suggestions
+type SuggestionRequest struct {
+	Path    string `json:"path"`
+	Base    string `json:"base"` // Markdown the changes were made to, the page as it is now when empty
+	Content string `json:"content"`
+	Summary string `json:"summary"`
+}
+
+// SuggestionReviewRequest is the body of POST /api/suggestions/{id}
+type SuggestionReviewRequest struct {
+	Action string `json:"action"` // "accept", "reject" or "withdraw"
+	Hunks  []int  `json:"hunks"`  // Indexes of the hunks to accept
+}
+
+// SuggestionSummary describes a pending suggestion in the list of a page
+type SuggestionSummary struct {
+	ID       string    `json:"id"`
+	Author   string    `json:"author"`
+	Summary  string    `json:"summary"`
+	Created  time.Time `json:"created"`
+	Inserted int       `json:"inserted"`
+	Deleted  int       `json:"deleted"`
+}
+
+// SuggestionHunk is a change of a suggestion, with whether it still applies to
+// the page as it is now
+type SuggestionHunk struct {
+	textdiff.Hunk
+	Applies bool `json:"applies"`
+}
+
+// canReviewSuggestions reports whether the current user may accept or reject
+// the suggestions for the page at path: whoever may edit it
+func canReviewSuggestions(r *http.Request, path string) bool {
+	session := auth.GetSession(r)
+	if session == nil || (session.Role != roles.RoleAdmin && session.Role != roles.RoleEditor) {
+		return false
+	}
+	return canEditPath(r, path)
+}
+
+// canSuggest reports whether the current user may suggest changes to the page
+// at path: logged-in readers who may not edit it themselves. Archived and
+// synced pages don't take changes at all.
+func canSuggest(r *http.Request, path string) bool {
+	if auth.GetSession(r) == nil || !canViewPath(r, path) {
+		return false
+	}
+	if _, synced := syncedSource(path); synced {
+		return false
+	}
+	if _, archived := archivedEntry(path); archived {
+		return false
+	}
+	return !canReviewSuggestions(r, path)
+}
+
+// suggestionSummary describes a suggestion without its content
+func suggestionSummary(suggestion suggestions.Suggestion) SuggestionSummary {
+	lines := textdiff.Lines(textdiff.SplitLines(suggestion.Base), textdiff.SplitLines(suggestion.Proposed))
+	summary := SuggestionSummary{
+		ID:      suggestion.ID,
+		Author:  suggestion.Author,
+		Summary: suggestion.Summary,
+		Created: suggestion.Created,
+	}
+	summary.Inserted, summary.Deleted = textdiff.Changes(lines)
+	return summary
+}
+
+// suggestionReviewers returns the users told about a new suggestion for the
+// page at path: its owners, or the admins and editors who may edit it when it
+// has none
+func suggestionReviewers(path string) []string {
+	if file, err := ownership.Load(cfg.Wiki.RootDir); err != nil {
+		log.Printf("Error loading owners: %v", err)
+	} else if owners := file.Users(file.Owners(path, pageOwners(documentFile(path))), cfg.Users); len(owners) > 0 {
+		return owners
+	}
+
+	rules := loadPermissions()
+	var reviewers []string
+	for _, user := range cfg.Users {
+		if user.Role != roles.RoleAdmin && user.Role != roles.RoleEditor {
+			continue
+		}
+		if rules == nil && user.Role != roles.RoleAdmin {
+			continue
+		}
+		if rules != nil && !rules.CanEdit(path, subjectFor(user.Username, user.Role)) {
+			continue
+		}
+		reviewers = append(reviewers, user.Username)
+	}
+	return reviewers
+}
+
+// notifySuggestion tells users about a suggestion, linking to its review
+func notifySuggestion(users []string, actor, message string, suggestion suggestions.Suggestion) {
+	link := "/" + suggestion.Path + "?suggestion=" + suggestion.ID
+	for _, user := range users {
+		if user == actor {
+			continue
+		}
+		if err := notifications.Add(cfg.Wiki.RootDir, user, message, link); err != nil {
+			log.Printf("Error notifying %s of suggestion %s: %v", user, suggestion.ID, err)
+		}
+	}
+}
+
+// suggestionPageTitle returns the title of the page at path for notifications
+func suggestionPageTitle(path string) string {
+	if path == "" {
+		return cfg.Wiki.Title
+	}
+	return utils.GetDocumentTitle(filepath.Dir(documentFile(path)))
+}
+
+// SuggestionsHandler handles /api/suggestions. GET ?path= lists the pending
+// suggestions for a page: all of them for reviewers, their own for the others.
+// POST stores a new suggestion.
+func SuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		path := strings.Trim(r.URL.Query().Get("path"), "/")
+		if strings.Contains(path, "..") || !canViewPath(r, path) {
+			sendJSONError(w, "Page not found", http.StatusNotFound, "")
+			return
+		}
+		list, err := suggestions.List(cfg.Wiki.RootDir, path)
+		if err != nil {
+			sendJSONError(w, "Failed to load the suggestions", http.StatusInternalServerError, err.Error())
+			return
+		}
+		review := canReviewSuggestions(r, path)
+		summaries := []SuggestionSummary{}
+		for _, suggestion := range list {
+			if review || suggestion.Author == session.Username {
+				summaries = append(summaries, suggestionSummary(suggestion))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"suggestions": summaries,
+			"canReview":   review,
+			"canSuggest":  canSuggest(r, path),
+		})
+
+	case http.MethodPost:
+		var req SuggestionRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		path := strings.Trim(req.Path, "/")
+		if strings.Contains(path, "..") || !canSuggest(r, path) {
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "You can't suggest changes to this page")
+			return
+		}
+		req.Summary = strings.TrimSpace(req.Summary)
+		if len(req.Summary) > maxSuggestionSummary {
+			sendJSONError(w, "The summary is too long", http.StatusBadRequest, "")
+			return
+		}
+		// Changes are kept against the markdown they were made to, so the page
+		// changing in the meantime isn't taken for part of the suggestion. The
+		// hunks still have to be found in the page to be applied.
+		base, ok := suggestionSource(r, path)
+		if !ok {
+			sendJSONError(w, "Page not found", http.StatusNotFound, "")
+			return
+		}
+		if req.Base != "" {
+			base = req.Base
+		}
+		if strings.ReplaceAll(req.Content, "\r\n", "\n") == strings.ReplaceAll(base, "\r\n", "\n") {
+			sendJSONError(w, "Nothing was changed", http.StatusBadRequest, "")
+			return
+		}
+
+		suggestion, err := suggestions.Create(cfg.Wiki.RootDir, path, session.Username, req.Summary, base, req.Content)
+		if err != nil {
+			sendJSONError(w, "Failed to save the suggestion", http.StatusInternalServerError, err.Error())
+			return
+		}
+		message := fmt.Sprintf("%s suggested changes to %s", session.Username, suggestionPageTitle(path))
+		notifySuggestion(suggestionReviewers(path), session.Username, message, suggestion)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"suggestion": suggestionSummary(suggestion),
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// suggestionSource returns the markdown of the page at path that the current
+// user starts a suggestion from, without the content they may not see
+func suggestionSource(r *http.Request, path string) (string, bool) {
+	content, err := encryption.ReadFile(documentFile(path))
+	if err != nil {
+		return "", false
+	}
+	return goldext.FilterAudience(string(content), requestSubject(r)), true
+}
+
+// SuggestionSourceHandler handles GET /api/suggestions/source?path=, the
+// markdown a suggestion for the page starts from
+func SuggestionSourceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	path := strings.Trim(r.URL.Query().Get("path"), "/")
+	if strings.Contains(path, "..") || !canSuggest(r, path) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You can't suggest changes to this page")
+		return
+	}
+	source, ok := suggestionSource(r, path)
+	if !ok {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(source))
+}
+
+// SuggestionHandler handles /api/suggestions/{id}. GET returns the changes of
+// a suggestion, to its author and the reviewers of the page. POST accepts some
+// of its hunks or rejects it, for reviewers, or withdraws it, for its author.
+func SuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/suggestions/")
+	suggestion, err := suggestions.Get(cfg.Wiki.RootDir, id)
+	if errors.Is(err, suggestions.ErrNotFound) {
+		sendJSONError(w, "Suggestion not found", http.StatusNotFound, "")
+		return
+	}
+	if err != nil {
+		sendJSONError(w, "Failed to load the suggestion", http.StatusInternalServerError, err.Error())
+		return
+	}
+	review := canReviewSuggestions(r, suggestion.Path)
+	own := suggestion.Author == session.Username
+	if !canViewPath(r, suggestion.Path) || (!review && !own) {
+		sendJSONError(w, "Suggestion not found", http.StatusNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		hunks := suggestion.Hunks()
+		current, _ := encryption.ReadFile(documentFile(suggestion.Path))
+		detailed := make([]SuggestionHunk, len(hunks))
+		for i, hunk := range hunks {
+			_, err := suggestions.Apply(string(current), hunks, []int{i})
+			detailed[i] = SuggestionHunk{Hunk: hunk, Applies: err == nil}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"suggestion":  suggestionSummary(suggestion),
+			"path":        "/" + suggestion.Path,
+			"status":      suggestion.Status,
+			"reviewer":    suggestion.Reviewer,
+			"hunks":       detailed,
+			"canReview":   review && suggestion.Status == suggestions.Pending,
+			"canWithdraw": own && suggestion.Status == suggestions.Pending,
+		})
+
+	case http.MethodPost:
+		var req SuggestionReviewRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest, err.Error())
+			return
+		}
+		switch {
+		case req.Action == "withdraw" && own:
+			suggestion, err = suggestions.Close(cfg.Wiki.RootDir, suggestion.ID, suggestions.Withdrawn, session.Username, nil)
+		case req.Action == "reject" && review:
+			suggestion, err = suggestions.Close(cfg.Wiki.RootDir, suggestion.ID, suggestions.Rejected, session.Username, nil)
+			if err == nil {
+				message := fmt.Sprintf("%s rejected your suggestion for %s", session.Username, suggestionPageTitle(suggestion.Path))
+				notifySuggestion([]string{suggestion.Author}, session.Username, message, suggestion)
+			}
+		case req.Action == "accept" && review:
+			if len(req.Hunks) == 0 {
+				sendJSONError(w, "No changes were selected", http.StatusBadRequest, "")
+				return
+			}
+			if suggestion.Status != suggestions.Pending {
+				err = suggestions.ErrClosed
+				break
+			}
+			if err = applySuggestion(suggestion, req.Hunks, session.Username); err != nil {
+				sendJSONError(w, "The suggestion could not be applied", http.StatusConflict, err.Error())
+				return
+			}
+			suggestion, err = suggestions.Close(cfg.Wiki.RootDir, suggestion.ID, suggestions.Accepted, session.Username, req.Hunks)
+			if err == nil {
+				message := fmt.Sprintf("%s accepted your suggestion for %s", session.Username, suggestionPageTitle(suggestion.Path))
+				notifySuggestion([]string{suggestion.Author}, session.Username, message, suggestion)
+			}
+		default:
+			sendJSONError(w, "Forbidden", http.StatusForbidden, "")
+			return
+		}
+		if errors.Is(err, suggestions.ErrClosed) {
+			sendJSONError(w, "The suggestion is no longer pending", http.StatusConflict, "")
+			return
+		}
+		if err != nil {
+			sendJSONError(w, "Failed to save the suggestion", http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"status":  suggestion.Status,
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
+
+// applySuggestion applies the accepted hunks of a suggestion to its page and
+// saves it like an edit of its author, keeping the previous version
+func applySuggestion(suggestion suggestions.Suggestion, accepted []int, reviewer string) error {
+	docFile := documentFile(suggestion.Path)
+	current, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return errors.New("the page no longer exists")
+	}
+	content, err := suggestions.Apply(string(current), suggestion.Hunks(), accepted)
+	if err != nil {
+		return err
+	}
+
+	relativePath := "documents/" + suggestion.Path
+	if suggestion.Path == "" {
+		relativePath = "pages/home"
+	}
+	utils.SaveVersion(cfg.Wiki.RootDir, relativePath, docFile, cfg.Wiki.MaxVersions)
+	info, err := os.Stat(docFile)
+	if err != nil {
+		return err
+	}
+	if err := encryption.WriteFile(docFile, []byte(content), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	summary := "Suggestion accepted by " + reviewer
+	if suggestion.Summary != "" {
+		summary += ": " + suggestion.Summary
+	}
+	if err := pagestats.RecordEditSummary(cfg.Wiki.RootDir, relativePath, suggestion.Author, summary); err != nil {
+		log.Printf("Error recording edit history for %s: %v", relativePath, err)
+	}
+	enqueueOwnerNotification(suggestion.Path, reviewer)
+	semantic.Changed()
+	return nil
+}
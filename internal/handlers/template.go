@@ -11,13 +11,21 @@ import (
 
 	"wiki-go/internal/i18n"
 	"wiki-go/internal/resources"
+	"wiki-go/internal/secheaders"
+	"wiki-go/internal/static"
 	"wiki-go/internal/types"
 	"wiki-go/internal/utils"
 	"wiki-go/internal/version"
 )
 
 // renderTemplate renders the base template with the given data
-func renderTemplate(w http.ResponseWriter, data *types.PageData) {
+func renderTemplate(w http.ResponseWriter, r *http.Request, data *types.PageData) {
+	data.CSPNonce = secheaders.Nonce(r)
+	if data.Navigation != nil {
+		data.Favorites = sidebarFavorites(r)
+	}
+	data.Announcements = pageAnnouncements(r, r.URL.Path)
+
 	// Get the template from cache or load it
 	tmpl, err := getTemplate()
 	if err != nil {
@@ -41,6 +49,12 @@ func renderTemplate(w http.ResponseWriter, data *types.PageData) {
 var templateCache *template.Template
 var templateOnce sync.Once
 
+// assetURL returns the fingerprinted address of a static file, used by the
+// templates as {{asset "js/search.js"}}
+func assetURL(name string) string {
+	return static.AssetURL(cfg.Wiki.RootDir, name)
+}
+
 // getTemplate returns the cached template or loads it if not cached
 func getTemplate() (*template.Template, error) {
 	var templateErr error
@@ -54,6 +68,13 @@ func getTemplate() (*template.Template, error) {
 			"getVersion": func() string {
 				return version.Version
 			},
+			"asset":   assetURL,
+			"mermaidScript": mermaidScript,
+			"mermaidConfig": mermaidConfig,
+			"diagramConfig": diagramConfig,
+			"mapConfig": mapConfig,
+			"musicConfig": musicConfig,
+			"textDir": i18n.Direction,
 			"hasFavicon": func(rootDir string, extension string) bool {
 				// Check if a specific favicon exists
 				path := filepath.Join(rootDir, "static", "favicon."+extension)
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/i18n"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/translations"
+	"wiki-go/internal/types"
+	"wiki-go/internal/utils"
+)
+
+// contentLanguageCookie remembers the language a reader picked for pages
+const contentLanguageCookie = "content_lang"
+
+// pageVariant is the variant of a page shown to a reader
+type pageVariant struct {
+	File     string                 // Markdown file to render
+	Language string                 // Language of the rendered file
+	Dir      string                 // Direction of the rendered file, empty to follow its language
+	Missing  string                 // Language the reader asked for when the page has no such translation
+	Variants []translations.Variant // Translations of the page
+}
+
+// choosePageLanguage picks the variant of the page in dir to show. The
+// language comes from the lang query parameter, which is remembered in a
+// cookie, then from that cookie, then from the Accept-Language header. Pages
+// fall back to the source language when they have no matching translation.
+func choosePageLanguage(w http.ResponseWriter, r *http.Request, dir, relativePath string) pageVariant {
+	source := translations.Source(cfg)
+	page := pageVariant{File: filepath.Join(dir, "document.md"), Language: source}
+
+	variants, err := translations.Status(cfg.Wiki.RootDir, relativePath, dir)
+	if err != nil {
+		// A broken status file shouldn't hide the translations themselves
+		variants = nil
+		for _, lang := range translations.List(dir) {
+			variants = append(variants, translations.Variant{Language: lang, Outdated: true})
+		}
+	}
+	page.Variants = variants
+
+	requested := r.URL.Query().Get("lang")
+	if requested != "" && (requested == source || translations.Valid(requested)) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     contentLanguageCookie,
+			Value:    requested,
+			Path:     "/",
+			MaxAge:   365 * 24 * 60 * 60,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	} else if cookie, err := r.Cookie(contentLanguageCookie); err == nil && translations.Valid(cookie.Value) {
+		requested = cookie.Value
+	} else {
+		available := []string{source}
+		for _, variant := range variants {
+			available = append(available, variant.Language)
+		}
+		requested = translations.Match(r.Header.Get("Accept-Language"), available)
+	}
+
+	if requested == "" || requested == source {
+		return page
+	}
+	for _, variant := range variants {
+		if variant.Language == requested {
+			page.File = filepath.Join(dir, translations.FileName(cfg, requested))
+			page.Language = requested
+			return page
+		}
+	}
+	page.Missing = requested
+	return page
+}
+
+// apply fills the translation fields of the page data. Editors are offered
+// the configured languages the page isn't translated into yet.
+func (page pageVariant) apply(data *types.PageData) {
+	data.SourceLanguage = translations.Source(cfg)
+	data.ContentLanguage = page.Language
+	data.ContentDirection = page.Dir
+	if data.ContentDirection == "" {
+		data.ContentDirection = i18n.Direction(page.Language)
+	}
+	data.MissingLanguage = page.Missing
+	data.Translations = page.Variants
+	for _, variant := range page.Variants {
+		if variant.Language == page.Language {
+			data.TranslationOutdated = variant.Outdated
+			data.TranslationMachine = variant.Machine
+		}
+	}
+
+	if data.UserRole != roles.RoleAdmin && data.UserRole != roles.RoleEditor {
+		return
+	}
+	data.MachineTranslation = translations.MachineEnabled(cfg)
+	translated := make(map[string]bool)
+	for _, variant := range page.Variants {
+		translated[variant.Language] = true
+	}
+	for _, lang := range translations.Configured(cfg) {
+		if !translated[lang] {
+			data.UntranslatedLanguages = append(data.UntranslatedLanguages, lang)
+		}
+	}
+}
+
+// variantFile returns the file the editor reads and writes for the lang query
+// parameter of a request to the page at docPath, which names document.md.
+// ok is false when lang isn't a valid language.
+func variantFile(r *http.Request, docPath string) (file, lang string, ok bool) {
+	lang = r.URL.Query().Get("lang")
+	if lang == "" || lang == translations.Source(cfg) {
+		return docPath, "", true
+	}
+	if !translations.Valid(lang) {
+		return "", "", false
+	}
+	return filepath.Join(filepath.Dir(docPath), translations.FileName(cfg, lang)), lang, true
+}
+
+// variantExists reports whether a translation file exists
+func variantExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// TranslationsHandler handles GET /api/translations, the missing and outdated
+// translations of the pages the user may edit, for the translation dashboard
+func TranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	pending, err := translations.Pending(cfg)
+	if err != nil {
+		sendJSONError(w, "Failed to list translations", http.StatusInternalServerError, err.Error())
+		return
+	}
+	tasks := []translations.Task{}
+	titles := make(map[string]string)
+	for _, task := range pending {
+		if !canEditPath(r, task.Path) {
+			continue
+		}
+		if _, ok := titles[task.Path]; !ok {
+			titles[task.Path] = utils.GetDocumentTitle(pageDir(cfg, strings.Trim(task.Path, "/")))
+		}
+		task.Title = titles[task.Path]
+		tasks = append(tasks, task)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"tasks":       tasks,
+		"source":      translations.Source(cfg),
+		"languages":   translations.Configured(cfg),
+		"translators": translators(),
+		"user":        session.Username,
+	})
+}
+
+// AssignTranslationRequest is the body of POST /api/translations/assign
+type AssignTranslationRequest struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Assignee string `json:"assignee"` // Empty to remove the assignment
+}
+
+// AssignTranslationHandler handles POST /api/translations/assign, asking a
+// translator to create or update the translation of a page
+func AssignTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+
+	var req AssignTranslationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	docPath := strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !translations.Valid(req.Language) || req.Language == translations.Source(cfg) {
+		sendJSONError(w, "Invalid language", http.StatusBadRequest, req.Language)
+		return
+	}
+	if !canEditPath(r, "/"+docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return
+	}
+	if _, err := os.Stat(documentFile(docPath)); err != nil {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return
+	}
+	if req.Assignee != "" && !containsString(translators(), req.Assignee) {
+		sendJSONError(w, "Translators must be editors or admins", http.StatusBadRequest, req.Assignee)
+		return
+	}
+
+	if err := translations.Assign(cfg.Wiki.RootDir, pageVersionPath(docPath), req.Language, req.Assignee); err != nil {
+		sendJSONError(w, "Failed to assign the translation", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if req.Assignee != "" && req.Assignee != session.Username {
+		title := cfg.Wiki.Title
+		if docPath != "" {
+			title = utils.GetDocumentTitle(pageDir(cfg, docPath))
+		}
+		message := fmt.Sprintf("%s asked you to translate %s into %s", session.Username, title, req.Language)
+		if err := notifications.Add(cfg.Wiki.RootDir, req.Assignee, message, "/"+docPath+"?lang="+url.QueryEscape(req.Language)); err != nil {
+			log.Printf("Error notifying translator %s: %v", req.Assignee, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// TranslationRequest is the body of POST /api/translations/machine and
+// /api/translations/confirm
+type TranslationRequest struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+}
+
+// translationTarget validates the page and language of a request about a
+// translation, writing the error response when they aren't acceptable
+func translationTarget(w http.ResponseWriter, r *http.Request) (docPath, lang string, ok bool) {
+	var req TranslationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	defer r.Body.Close()
+
+	docPath = strings.Trim(filepath.ToSlash(filepath.Clean("/"+req.Path)), "/")
+	if !translations.Valid(req.Language) || req.Language == translations.Source(cfg) {
+		sendJSONError(w, "Invalid language", http.StatusBadRequest, req.Language)
+		return "", "", false
+	}
+	if !canEditPath(r, "/"+docPath) {
+		sendJSONError(w, "Forbidden", http.StatusForbidden, "You are not allowed to edit this page")
+		return "", "", false
+	}
+	if _, err := os.Stat(documentFile(docPath)); err != nil {
+		sendJSONError(w, "Page not found", http.StatusNotFound, "")
+		return "", "", false
+	}
+	return docPath, req.Language, true
+}
+
+// MachineTranslateHandler handles POST /api/translations/machine, returning the
+// page machine translated into a language for the editor to review and save
+func MachineTranslateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	translator, err := translations.NewTranslator(cfg)
+	if err != nil {
+		sendJSONError(w, "Machine translation is not enabled", http.StatusNotFound, err.Error())
+		return
+	}
+	docPath, lang, ok := translationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	// Encrypted pages are kept from leaving the wiki
+	file := documentFile(docPath)
+	if encryption.Encrypted(file) {
+		sendJSONError(w, "Pages of encrypted directories can't be machine translated", http.StatusForbidden, "")
+		return
+	}
+	source, err := encryption.ReadFile(file)
+	if err != nil {
+		sendJSONError(w, "Failed to read the page", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	content, err := translations.TranslatePage(translator, string(source), translations.Source(cfg), lang)
+	if err != nil {
+		log.Printf("Error machine translating %s into %s: %v", file, lang, err)
+		sendJSONError(w, "Machine translation failed", http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"content":  content,
+		"provider": cfg.Extensions.MachineTranslation.Provider,
+	})
+}
+
+// ConfirmTranslationHandler handles POST /api/translations/confirm, recording
+// that an editor reviewed a machine translated page
+func ConfirmTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+	docPath, lang, ok := translationTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := translations.Confirm(cfg.Wiki.RootDir, pageVersionPath(docPath), lang); err != nil {
+		sendJSONError(w, "Failed to confirm the translation", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
+}
+
+// translators returns the users who may be assigned translations, sorted
+func translators() []string {
+	var list []string
+	for _, user := range cfg.Users {
+		if user.Role == roles.RoleAdmin || user.Role == roles.RoleEditor {
+			list = append(list, user.Username)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wiki-go/internal/typography"
+)
+
+// TypographyPayload represents the raw YAML typography settings
+type TypographyPayload struct {
+	Content string `json:"content"`
+}
+
+// TypographySettingsHandler handles GET (read) and POST/PUT (update) of the
+// typographer options and text substitutions
+func TypographySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := typography.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read typography settings", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"content": content,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req TypographyPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := typography.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Invalid typography settings", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Typography settings saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
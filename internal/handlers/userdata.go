@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"wiki-go/internal/auth"
+	"wiki-go/internal/userdata"
+)
+
+// unsafeNameChars are replaced in the names of downloaded archives
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// AccountExportHandler handles GET /api/account/export, downloading everything
+// the wiki stores about the current user as a zip archive
+func AccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetSession(r)
+	if session == nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized, "")
+		return
+	}
+	sendUserExport(w, r, session.Username)
+}
+
+// UserExportHandler handles GET /api/users/export?username=, letting admins
+// download the data of any user to answer an access request
+func UserExportHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		sendJSONError(w, "Username is required", http.StatusBadRequest, "")
+		return
+	}
+	sendUserExport(w, r, username)
+}
+
+// sendUserExport writes the data archive of username
+func sendUserExport(w http.ResponseWriter, r *http.Request, username string) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	// The archive is built first, so failures can still be reported as JSON
+	var buf bytes.Buffer
+	if _, err := userdata.Export(cfg, username, &buf); err != nil {
+		sendJSONError(w, "Failed to export user data", http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := fmt.Sprintf("%s-data-%s.zip", unsafeNameChars.ReplaceAllString(username, "_"), time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
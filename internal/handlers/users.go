@@ -3,10 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"wiki-go/internal/auth"
 	"wiki-go/internal/config"
 	"wiki-go/internal/crypto"
+	"wiki-go/internal/store"
+	"wiki-go/internal/userdata"
 )
 
 // User represents a user in the response
@@ -122,6 +125,8 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a copy of the current config
+	config.Lock()
+	defer config.Unlock()
 	updatedConfig := *cfg
 
 	// Validate role
@@ -179,6 +184,8 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a copy of the current config
+	config.Lock()
+	defer config.Unlock()
 	updatedConfig := *cfg
 
 	// Validate role
@@ -231,7 +238,9 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// DeleteUserHandler deletes a user
+// DeleteUserHandler deletes a user and erases their personal data. Their
+// revisions, uploads and comments stay, credited to a deleted user, unless
+// ?comments=delete removes the comments as well.
 func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if user is authenticated and has admin role
 	session := auth.GetSession(r)
@@ -254,6 +263,8 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a copy of the current config
+	config.Lock()
+	defer config.Unlock()
 	updatedConfig := *cfg
 
 	// Find and remove the user
@@ -295,12 +306,24 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Update the global config
 	*cfg = updatedConfig
 
+	// Log the user out everywhere
+	if err := store.Current.DeleteUserSessions(username); err != nil {
+		log.Printf("Error ending sessions of deleted user %s: %v", username, err)
+	}
+
+	report, err := userdata.Erase(cfg, username, r.URL.Query().Get("comments") == "delete")
+	if err != nil {
+		sendJSONError(w, "User deleted, but erasing their data failed", http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Send success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": "User deleted successfully",
+		"erased":  report,
 	})
 }
 
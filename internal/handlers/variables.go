@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wiki-go/internal/variables"
+)
+
+// VariablesPayload represents the raw YAML variables registry
+type VariablesPayload struct {
+	Content string `json:"content"`
+}
+
+// VariablesHandler handles GET (read) and POST/PUT (update) of the variables registry
+func VariablesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		content, err := variables.ReadRaw(cfg.Wiki.RootDir)
+		if err != nil {
+			sendJSONError(w, "Failed to read variables", http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		registry, _ := variables.Load(cfg.Wiki.RootDir)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"content":  content,
+			"registry": registry,
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req VariablesPayload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request payload", http.StatusBadRequest, err.Error())
+			return
+		}
+		defer r.Body.Close()
+
+		if err := variables.SaveRaw(cfg.Wiki.RootDir, req.Content); err != nil {
+			sendJSONError(w, "Invalid variables", http.StatusBadRequest, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Variables saved successfully",
+		})
+
+	default:
+		sendJSONError(w, "Method not allowed", http.StatusMethodNotAllowed, "")
+	}
+}
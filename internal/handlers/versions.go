@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/pagestats"
 	"wiki-go/internal/utils"
 )
 
@@ -17,15 +19,35 @@ import (
 type VersionInfo struct {
 	Timestamp string `json:"timestamp"`
 	Path      string `json:"path"`
+	Author    string `json:"author,omitempty"`  // Who saved the content of the version, when recorded
+	Summary   string `json:"summary,omitempty"` // Summary of the edit that made the version
+	Minor     bool   `json:"minor,omitempty"`
 }
 
 // VersionsListResponse is the JSON response for listing versions
 type VersionsListResponse struct {
 	Success  bool          `json:"success"`
 	Versions []VersionInfo `json:"versions"`
+	Current  *VersionInfo  `json:"current,omitempty"` // Last edit of the page as it is now
 	Message  string        `json:"message,omitempty"`
 }
 
+// editBefore returns the last edit in history made before the version saved
+// at timestamp; the version holds the content of that edit, as versions are
+// kept when the page is saved again
+func editBefore(history []pagestats.Edit, timestamp string) (pagestats.Edit, bool) {
+	saved, err := time.ParseInLocation("20060102150405", timestamp, time.Local)
+	if err != nil {
+		return pagestats.Edit{}, false
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Time.Before(saved) {
+			return history[i], true
+		}
+	}
+	return pagestats.Edit{}, false
+}
+
 // VersionResponse is the JSON response for retrieving a specific version
 type VersionResponse struct {
 	Success bool   `json:"success"`
@@ -185,6 +207,28 @@ func handleListVersions(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 		Versions: versions,
 	}
 
+	// Versions are described by the edits that made them
+	relativePath := docPath
+	if docPath != "pages/home" && !strings.HasPrefix(docPath, "documents/") {
+		relativePath = "documents/" + docPath
+	}
+	if history, err := pagestats.LoadHistory(cfg.Wiki.RootDir, relativePath); err == nil && len(history) > 0 {
+		for i := range versions {
+			if edit, ok := editBefore(history, versions[i].Timestamp); ok {
+				versions[i].Author = edit.User
+				versions[i].Summary = edit.Summary
+				versions[i].Minor = edit.Minor
+			}
+		}
+		last := history[len(history)-1]
+		response.Current = &VersionInfo{
+			Timestamp: last.Time.Local().Format("20060102150405"),
+			Author:    last.User,
+			Summary:   last.Summary,
+			Minor:     last.Minor,
+		}
+	}
+
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -210,7 +254,7 @@ func handleGetVersion(w http.ResponseWriter, _ *http.Request, cfg *config.Config
 	}
 
 	// Read the version file content
-	content, err := os.ReadFile(versionPath)
+	content, err := encryption.ReadFile(versionPath)
 	if err != nil {
 		sendJSONErrorVersion(w, "Failed to read version file", http.StatusInternalServerError)
 		return
@@ -284,7 +328,7 @@ func handleVersionRestore(w http.ResponseWriter, r *http.Request, cfg *config.Co
 	}
 
 	// Read the version file content
-	versionContent, err := os.ReadFile(versionFilePath)
+	versionContent, err := encryption.ReadFile(versionFilePath)
 	if err != nil {
 		fmt.Printf("Error reading version file: %v\n", err)
 		sendJSONErrorVersion(w, "Failed to read version file", http.StatusInternalServerError)
@@ -294,7 +338,7 @@ func handleVersionRestore(w http.ResponseWriter, r *http.Request, cfg *config.Co
 	// Before overwriting current document, save it as a version
 	if _, err := os.Stat(documentPath); err == nil && cfg.Wiki.MaxVersions > 0 {
 		// Document exists, read its current content
-		currentContent, err := os.ReadFile(documentPath)
+		currentContent, err := encryption.ReadFile(documentPath)
 		if err == nil && len(currentContent) > 0 {
 			// Create timestamp for version filename
 			newTimestamp := time.Now().Format("20060102150405") // Format: yyyymmddhhmmss
@@ -308,7 +352,7 @@ func handleVersionRestore(w http.ResponseWriter, r *http.Request, cfg *config.Co
 				newVersionPath := filepath.Join(versionDir, newTimestamp+".md")
 
 				// Save the current content as a version
-				_ = os.WriteFile(newVersionPath, currentContent, 0644) // Ignore error for now
+				_ = encryption.WriteFile(newVersionPath, currentContent, 0644) // Ignore error for now
 
 				// Clean up old versions if needed
 				utils.CleanupOldVersions(versionDir, cfg.Wiki.MaxVersions)
@@ -317,7 +361,7 @@ func handleVersionRestore(w http.ResponseWriter, r *http.Request, cfg *config.Co
 	}
 
 	// Write the version content to the document file
-	if err := os.WriteFile(documentPath, versionContent, 0644); err != nil {
+	if err := encryption.WriteFile(documentPath, versionContent, 0644); err != nil {
 		fmt.Printf("Error writing to document file: %v\n", err)
 		sendJSONErrorVersion(w, "Failed to restore document", http.StatusInternalServerError)
 		return
@@ -0,0 +1,480 @@
+package htmlmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDocxPart is the largest uncompressed size of a part of a Word document
+const maxDocxPart = 64 << 20
+
+// headingStyleRegex matches the names of the heading styles of Word
+var headingStyleRegex = regexp.MustCompile(`^heading ([1-6])$`)
+
+// xmlNode is an element of a part of a Word document, by local names
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     string
+}
+
+// child returns the first child element with the name, nil without one
+func (n *xmlNode) child(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// val returns the w:val attribute of the child element with the name
+func (n *xmlNode) val(name string) (string, bool) {
+	c := n.child(name)
+	if c == nil {
+		return "", false
+	}
+	return c.attrs["val"], true
+}
+
+// on reports whether a toggle property such as bold is set
+func (n *xmlNode) on(name string) bool {
+	value, found := n.val(name)
+	return found && value != "0" && value != "false" && value != "none"
+}
+
+// docx holds the parts of a Word document needed to convert its body
+type docx struct {
+	files     map[string]*zip.File
+	rels      map[string]string          // Relationship id to target, relative to word/ for internal ones
+	styles    map[string]string          // Style id to lowercase name
+	numbering map[string]map[string]bool // List id to the levels that are numbered
+}
+
+// ConvertDocx converts a Word document to markdown. Images embedded in the
+// document become attachments.
+func ConvertDocx(data []byte, opts Options) (Result, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Result{}, fmt.Errorf("not a Word document: %w", err)
+	}
+	d := &docx{
+		files:     make(map[string]*zip.File),
+		rels:      make(map[string]string),
+		styles:    make(map[string]string),
+		numbering: make(map[string]map[string]bool),
+	}
+	for _, file := range archive.File {
+		d.files[file.Name] = file
+	}
+
+	document, err := d.part("word/document.xml")
+	if err != nil || document == nil {
+		return Result{}, fmt.Errorf("not a Word document: word/document.xml is missing")
+	}
+	d.readRelationships()
+	d.readStyles()
+	d.readNumbering()
+
+	root := &Node{Tag: "body"}
+	if body := document.child("document").child("body"); body != nil {
+		d.blocks(body, root)
+	}
+	opts.images = func(src string) ([]byte, bool) {
+		file, ok := d.files[src]
+		if !ok {
+			return nil, false
+		}
+		content, err := readPart(file)
+		return content, err == nil
+	}
+	return convert(root, opts), nil
+}
+
+// part parses a part of the document, nil when the document hasn't got it
+func (d *docx) part(name string) (*xmlNode, error) {
+	file, ok := d.files[name]
+	if !ok {
+		return nil, nil
+	}
+	content, err := readPart(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseXML(content)
+}
+
+func readPart(file *zip.File) ([]byte, error) {
+	in, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	content, err := io.ReadAll(io.LimitReader(in, maxDocxPart+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxDocxPart {
+		return nil, fmt.Errorf("%s is larger than %d MB", file.Name, maxDocxPart>>20)
+	}
+	return content, nil
+}
+
+// parseXML reads an XML part into a tree of elements with their local names,
+// keeping the text of w:t and w:instrText elements only
+func parseXML(content []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		current := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string)}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if current.name == "t" {
+				current.text += string(t)
+			}
+		}
+	}
+}
+
+func (d *docx) readRelationships() {
+	rels, err := d.part("word/_rels/document.xml.rels")
+	if err != nil || rels == nil {
+		return
+	}
+	for _, rel := range rels.child("Relationships").children {
+		target := rel.attrs["Target"]
+		if rel.attrs["TargetMode"] != "External" {
+			target = path.Clean(path.Join("word", target))
+		}
+		d.rels[rel.attrs["Id"]] = target
+	}
+}
+
+func (d *docx) readStyles() {
+	styles, err := d.part("word/styles.xml")
+	if err != nil || styles == nil {
+		return
+	}
+	for _, style := range styles.child("styles").children {
+		if name, ok := style.val("name"); ok {
+			d.styles[style.attrs["styleId"]] = strings.ToLower(name)
+		}
+	}
+}
+
+func (d *docx) readNumbering() {
+	numbering, err := d.part("word/numbering.xml")
+	if err != nil || numbering == nil {
+		return
+	}
+	abstract := make(map[string]map[string]bool)
+	for _, n := range numbering.child("numbering").children {
+		if n.name != "abstractNum" {
+			continue
+		}
+		levels := make(map[string]bool)
+		for _, level := range n.children {
+			if level.name == "lvl" {
+				format, _ := level.val("numFmt")
+				levels[level.attrs["ilvl"]] = format != "" && format != "bullet" && format != "none"
+			}
+		}
+		abstract[n.attrs["abstractNumId"]] = levels
+	}
+	for _, n := range numbering.child("numbering").children {
+		if n.name == "num" {
+			id, _ := n.val("abstractNumId")
+			d.numbering[n.attrs["numId"]] = abstract[id]
+		}
+	}
+}
+
+// blocks converts the paragraphs and tables of body into HTML nodes of parent
+func (d *docx) blocks(body *xmlNode, parent *Node) {
+	var lists []*Node // Open lists, by level
+	for _, child := range body.children {
+		switch child.name {
+		case "p":
+			numID, level, isItem := d.listItem(child)
+			if !isItem {
+				lists = nil
+				d.paragraph(child, parent)
+				continue
+			}
+			tag := "ul"
+			if d.numbering[numID][strconv.Itoa(level)] {
+				tag = "ol"
+			}
+			if len(lists) > level+1 {
+				lists = lists[:level+1]
+			}
+			if len(lists) == level+1 && lists[level].Tag != tag {
+				lists = lists[:level]
+			}
+			for len(lists) < level+1 {
+				container := parent
+				if len(lists) > 0 {
+					open := lists[len(lists)-1]
+					if len(open.Children) == 0 {
+						open.Children = append(open.Children, &Node{Tag: "li", Parent: open})
+					}
+					container = open.Children[len(open.Children)-1]
+				}
+				list := &Node{Tag: tag, Attrs: map[string]string{}, Parent: container}
+				container.Children = append(container.Children, list)
+				lists = append(lists, list)
+			}
+			item := &Node{Tag: "li", Parent: lists[level]}
+			lists[level].Children = append(lists[level].Children, item)
+			d.inline(child, item)
+		case "tbl":
+			lists = nil
+			d.table(child, parent)
+		case "sdt":
+			lists = nil
+			if content := child.child("sdtContent"); content != nil {
+				d.blocks(content, parent)
+			}
+		}
+	}
+}
+
+// listItem returns the list and level of a paragraph in a list
+func (d *docx) listItem(p *xmlNode) (string, int, bool) {
+	numPr := p.child("pPr").child("numPr")
+	if numPr == nil {
+		return "", 0, false
+	}
+	numID, _ := numPr.val("numId")
+	if numID == "" || numID == "0" {
+		return "", 0, false
+	}
+	levelValue, _ := numPr.val("ilvl")
+	level, _ := strconv.Atoi(levelValue)
+	return numID, min(max(level, 0), 8), true
+}
+
+// paragraph converts a paragraph to a heading, quote or paragraph
+func (d *docx) paragraph(p *xmlNode, parent *Node) {
+	styleID, _ := p.child("pPr").val("pStyle")
+	style := d.styles[styleID]
+	if style == "" {
+		style = strings.ToLower(styleID)
+	}
+
+	tag := "p"
+	switch {
+	case headingStyleRegex.MatchString(style):
+		tag = "h" + headingStyleRegex.FindStringSubmatch(style)[1]
+	case style == "title":
+		tag = "h1"
+	case style == "subtitle":
+		tag = "h2"
+	case style == "quote" || style == "intense quote":
+		quote := &Node{Tag: "blockquote", Parent: parent}
+		parent.Children = append(parent.Children, quote)
+		parent = quote
+	}
+	node := &Node{Tag: tag, Attrs: map[string]string{}, Parent: parent}
+	parent.Children = append(parent.Children, node)
+	d.inline(p, node)
+}
+
+// inline converts the runs and links of a paragraph. Neighbouring runs with
+// the same formatting, which Word splits text into freely, are merged.
+func (d *docx) inline(p *xmlNode, parent *Node) {
+	var lastKey string
+	var last *Node
+	var add func(n *xmlNode, into *Node)
+	add = func(n *xmlNode, into *Node) {
+		for _, child := range n.children {
+			switch child.name {
+			case "r":
+				key, wrap := runFormat(child.child("rPr"))
+				content := d.run(child)
+				if len(content) == 0 {
+					continue
+				}
+				if last != nil && key == lastKey && last.Parent == into {
+					innermost(last).Children = append(innermost(last).Children, content...)
+					continue
+				}
+				node := wrap(content)
+				node.Parent = into
+				into.Children = append(into.Children, node)
+				last, lastKey = node, key
+			case "hyperlink":
+				href := d.rels[child.attrs["id"]]
+				if anchor := child.attrs["anchor"]; href == "" && anchor != "" {
+					href = "#" + anchor
+				}
+				link := &Node{Tag: "a", Attrs: map[string]string{"href": href}, Parent: into}
+				into.Children = append(into.Children, link)
+				last = nil
+				add(child, link)
+				last = nil
+			case "ins", "smartTag", "fldSimple", "customXml":
+				add(child, into)
+			case "sdt":
+				add(child.child("sdtContent"), into)
+			}
+		}
+	}
+	add(p, parent)
+}
+
+// run returns the text, line breaks and images of a run
+func (d *docx) run(r *xmlNode) []*Node {
+	var nodes []*Node
+	for _, child := range r.children {
+		switch child.name {
+		case "t":
+			nodes = append(nodes, &Node{Text: child.text})
+		case "tab":
+			nodes = append(nodes, &Node{Text: " "})
+		case "noBreakHyphen":
+			nodes = append(nodes, &Node{Text: "-"})
+		case "br", "cr":
+			if child.attrs["type"] != "page" {
+				nodes = append(nodes, &Node{Tag: "br", Attrs: map[string]string{}})
+			}
+		case "drawing", "pict", "object":
+			for _, blip := range findXML(child, func(n *xmlNode) bool { return n.name == "blip" || n.name == "imagedata" }) {
+				id := blip.attrs["embed"]
+				if id == "" {
+					id = blip.attrs["id"]
+				}
+				alt := ""
+				if docPr := findXML(child, func(n *xmlNode) bool { return n.name == "docPr" }); len(docPr) > 0 {
+					alt = docPr[0].attrs["descr"]
+				}
+				nodes = append(nodes, &Node{Tag: "img", Attrs: map[string]string{"src": d.rels[id], "alt": alt}})
+			}
+		}
+	}
+	return nodes
+}
+
+// runFormat returns a key identifying the formatting of a run and a function
+// wrapping content in the elements for it
+func runFormat(rPr *xmlNode) (string, func([]*Node) *Node) {
+	var tags []string
+	if rPr.on("b") {
+		tags = append(tags, "strong")
+	}
+	if rPr.on("i") {
+		tags = append(tags, "em")
+	}
+	if rPr.on("strike") || rPr.on("dstrike") {
+		tags = append(tags, "s")
+	}
+	switch align, _ := rPr.val("vertAlign"); align {
+	case "superscript":
+		tags = append(tags, "sup")
+	case "subscript":
+		tags = append(tags, "sub")
+	}
+	if fonts := rPr.child("rFonts"); fonts != nil && isMonospace(fonts.attrs["ascii"]) {
+		tags = append(tags, "code")
+	}
+
+	return strings.Join(tags, "+"), func(content []*Node) *Node {
+		outer := &Node{Tag: "span", Attrs: map[string]string{}}
+		current := outer
+		for _, tag := range tags {
+			node := &Node{Tag: tag, Attrs: map[string]string{}, Parent: current}
+			current.Children = []*Node{node}
+			current = node
+		}
+		for _, n := range content {
+			n.Parent = current
+		}
+		current.Children = content
+		return outer
+	}
+}
+
+// innermost returns the element the content of a wrapped run is in
+func innermost(n *Node) *Node {
+	for len(n.Children) == 1 && n.Children[0].Tag != "" && n.Children[0].Tag != "br" && n.Children[0].Tag != "img" {
+		n = n.Children[0]
+	}
+	return n
+}
+
+// isMonospace reports whether a font is one code is usually set in
+func isMonospace(font string) bool {
+	font = strings.ToLower(font)
+	for _, mono := range []string{"courier", "consolas", "mono", "menlo", "monaco"} {
+		if strings.Contains(font, mono) {
+			return true
+		}
+	}
+	return false
+}
+
+// table converts a table, merged cells become empty cells
+func (d *docx) table(tbl *xmlNode, parent *Node) {
+	table := &Node{Tag: "table", Attrs: map[string]string{}, Parent: parent}
+	parent.Children = append(parent.Children, table)
+	for _, tr := range tbl.children {
+		if tr.name != "tr" {
+			continue
+		}
+		row := &Node{Tag: "tr", Attrs: map[string]string{}, Parent: table}
+		table.Children = append(table.Children, row)
+		for _, tc := range tr.children {
+			if tc.name != "tc" {
+				continue
+			}
+			cell := &Node{Tag: "td", Attrs: map[string]string{}, Parent: row}
+			if span, ok := tc.child("tcPr").val("gridSpan"); ok {
+				cell.Attrs["colspan"] = span
+			}
+			row.Children = append(row.Children, cell)
+			d.blocks(tc, cell)
+		}
+	}
+}
+
+// findXML returns the descendants of n matching
+func findXML(n *xmlNode, match func(*xmlNode) bool) []*xmlNode {
+	var found []*xmlNode
+	for _, child := range n.children {
+		if match(child) {
+			found = append(found, child)
+		}
+		found = append(found, findXML(child, match)...)
+	}
+	return found
+}
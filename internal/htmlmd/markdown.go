@@ -0,0 +1,494 @@
+package htmlmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Image is an image of the converted content that is saved as an attachment
+type Image struct {
+	Name string // File name the markdown refers to
+	Data []byte
+}
+
+// Options controls a conversion
+type Options struct {
+	// ImagePrefix starts the names of the images saved as attachments
+	ImagePrefix string
+	// Fallback images are used in order for images whose source can't be
+	// read, such as the local files Word refers to when copying
+	Fallback [][]byte
+	// images resolves the sources of images that aren't URLs, such as the
+	// media of Word documents
+	images func(src string) ([]byte, bool)
+}
+
+// Result is the markdown of converted content with its images
+type Result struct {
+	Markdown string
+	Images   []Image
+	Missing  int // Images left out because their source couldn't be read
+}
+
+// blockElements start a block of their own
+var blockElements = map[string]bool{
+	"p": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "table": true, "pre": true, "blockquote": true, "hr": true,
+	"dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true, "section": true, "article": true,
+	"header": true, "footer": true, "main": true, "nav": true, "aside": true, "center": true,
+	"details": true, "summary": true, "body": true, "form": true, "fieldset": true,
+}
+
+// imageExtensions maps the detected type of image data to a file extension
+var imageExtensions = map[string]string{
+	"image/png": ".png", "image/jpeg": ".jpg", "image/gif": ".gif", "image/webp": ".webp", "image/bmp": ".bmp",
+}
+
+var (
+	spaceRegex      = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+	wordLevelRegex  = regexp.MustCompile(`level(\d+)`)
+	orderedRegex    = regexp.MustCompile(`^\(?[0-9a-zA-Z]{1,3}[.)]$`)
+	languageRegex   = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+	listMarkerRegex = regexp.MustCompile(`^(?:[-*+]|\d+[.)]) `)
+	escapeRegex     = regexp.MustCompile("[\\\\`*\\[\\]<]|(?:^|\\W)_|_(?:\\W|$)")
+)
+
+// ConvertHTML converts an HTML fragment to markdown
+func ConvertHTML(input string, opts Options) Result {
+	return convert(Parse(input), opts)
+}
+
+// converter keeps the state of one conversion
+type converter struct {
+	opts     Options
+	result   Result
+	fallback int
+}
+
+func convert(root *Node, opts Options) Result {
+	c := &converter{opts: opts}
+	markdown := strings.Join(c.blocks(root), "\n\n")
+	markdown = strings.ReplaceAll(markdown, "\u00a0", " ")
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	c.result.Markdown = strings.TrimSpace(blankLinesRegex.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+	return c.result
+}
+
+// blocks renders the children of n as markdown blocks. Inline content between
+// blocks becomes paragraphs.
+func (c *converter) blocks(n *Node) []string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(inline.String()); text != "" {
+			out = append(out, text)
+		}
+		inline.Reset()
+	}
+
+	for i := 0; i < len(n.Children); i++ {
+		child := n.Children[i]
+		switch {
+		case isWordListItem(child):
+			// Word exports list items as paragraphs with the level in their style
+			flush()
+			var items []*Node
+			for ; i < len(n.Children); i++ {
+				if isWordListItem(n.Children[i]) {
+					items = append(items, n.Children[i])
+				} else if n.Children[i].Tag != "" || strings.TrimSpace(n.Children[i].Text) != "" {
+					break
+				}
+			}
+			i--
+			out = append(out, c.wordList(items))
+		case blockElements[child.Tag]:
+			flush()
+			out = append(out, c.block(child)...)
+		case child.Tag != "" && hasBlock(child):
+			// Inline elements wrapping blocks, such as the <b> around Google Docs content
+			flush()
+			out = append(out, c.blocks(child)...)
+		default:
+			inline.WriteString(c.inline(child))
+		}
+	}
+	flush()
+	return out
+}
+
+// block renders a block element
+func (c *converter) block(n *Node) []string {
+	switch n.Tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := c.inlineText(n, " ")
+		if text == "" {
+			return nil
+		}
+		// Office suites set headings in bold, which headings already are
+		if inner := strings.TrimSuffix(strings.TrimPrefix(text, "**"), "**"); len(inner) == len(text)-4 && !strings.Contains(inner, "**") {
+			text = inner
+		}
+		level, _ := strconv.Atoi(n.Tag[1:])
+		return []string{strings.Repeat("#", level) + " " + text}
+	case "ul", "ol":
+		if list := c.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case "pre":
+		return []string{codeBlock(n)}
+	case "blockquote":
+		inner := strings.Join(c.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "> ")}
+	case "hr":
+		return []string{"---"}
+	case "table":
+		return c.table(n)
+	case "dt":
+		return []string{c.inlineText(n, " ")}
+	case "dd":
+		return []string{": " + c.inlineText(n, " ")}
+	case "figcaption":
+		if text := c.inlineText(n, " "); text != "" {
+			return []string{"*" + text + "*"}
+		}
+		return nil
+	default:
+		return c.blocks(n)
+	}
+}
+
+// list renders a list with its nested lists
+func (c *converter) list(n *Node) string {
+	var items []string
+	number := 1
+	if start, err := strconv.Atoi(n.Attrs["start"]); err == nil {
+		number = start
+	}
+	for _, child := range n.Children {
+		if child.Tag == "ul" || child.Tag == "ol" {
+			// Lists nested without an item, as some editors produce them
+			if nested := c.list(child); nested != "" {
+				items = append(items, prefixLines(nested, "    "))
+			}
+			continue
+		}
+		if child.Tag != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Tag == "ol" {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		// Nested lists follow the text of the item directly, paragraphs are separated
+		var content strings.Builder
+		for i, block := range c.blocks(child) {
+			if i > 0 && listMarkerRegex.MatchString(block) {
+				content.WriteString("\n")
+			} else if i > 0 {
+				content.WriteString("\n\n")
+			}
+			content.WriteString(block)
+		}
+		items = append(items, marker+indentRest(content.String(), strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// isWordListItem reports whether n is a list paragraph of Word
+func isWordListItem(n *Node) bool {
+	return n.Tag == "p" && n.style("mso-list") != "" && n.style("mso-list") != "ignore"
+}
+
+// wordList renders the list paragraphs of Word as a markdown list
+func (c *converter) wordList(items []*Node) string {
+	var lines []string
+	for _, item := range items {
+		level := 1
+		if m := wordLevelRegex.FindStringSubmatch(item.Attrs["style"]); m != nil {
+			level, _ = strconv.Atoi(m[1])
+		}
+		// The bullet or number is in a span marked to be ignored
+		marker := "- "
+		for _, span := range findAll(item, func(n *Node) bool { return n.style("mso-list") == "ignore" }) {
+			if orderedRegex.MatchString(strings.TrimSpace(strings.ReplaceAll(span.TextContent(), "\u00a0", " "))) {
+				marker = "1. "
+			}
+			span.Children = nil
+		}
+		lines = append(lines, strings.Repeat("    ", max(level-1, 0))+marker+c.inlineText(item, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// table renders a table as a markdown table with the first row as header.
+// Tables with a single cell, used for layout, are rendered as their content.
+func (c *converter) table(n *Node) []string {
+	rows := findAll(n, func(child *Node) bool { return child.Tag == "tr" })
+	if len(rows) == 0 {
+		return c.blocks(n)
+	}
+	var cells [][]string
+	width := 0
+	for _, row := range rows {
+		var rowCells []string
+		for _, cell := range row.Children {
+			if cell.Tag != "td" && cell.Tag != "th" {
+				continue
+			}
+			text := strings.Join(c.blocks(cell), "<br>")
+			text = strings.ReplaceAll(strings.ReplaceAll(text, "\\\n", "<br>"), "\n", "<br>")
+			rowCells = append(rowCells, strings.ReplaceAll(text, "|", `\|`))
+			if span, err := strconv.Atoi(cell.Attrs["colspan"]); err == nil {
+				for i := 1; i < span && i < 50; i++ {
+					rowCells = append(rowCells, "")
+				}
+			}
+		}
+		width = max(width, len(rowCells))
+		cells = append(cells, rowCells)
+	}
+	if len(cells) == 1 && width == 1 {
+		return c.blocks(findAll(rows[0], func(cell *Node) bool { return cell.Tag == "td" || cell.Tag == "th" })[0])
+	}
+	if width == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for i, row := range cells {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	return []string{strings.TrimRight(b.String(), "\n")}
+}
+
+// inlineText renders the content of n on one line, blocks separated by sep
+func (c *converter) inlineText(n *Node, sep string) string {
+	var parts []string
+	for _, block := range c.blocks(n) {
+		parts = append(parts, strings.ReplaceAll(block, "\\\n", sep))
+	}
+	return strings.TrimSpace(strings.ReplaceAll(strings.Join(parts, sep), "\n", sep))
+}
+
+// inline renders inline content
+func (c *converter) inline(n *Node) string {
+	if n.Tag == "" {
+		return escapeText(spaceRegex.ReplaceAllString(n.Text, " "))
+	}
+
+	switch n.Tag {
+	case "br":
+		return "\\\n"
+	case "img":
+		return c.image(n)
+	case "input":
+		if n.Attrs["type"] == "checkbox" {
+			if _, checked := n.Attrs["checked"]; checked {
+				return "[x] "
+			}
+			return "[ ] "
+		}
+		return ""
+	case "code", "kbd", "samp", "tt":
+		return codeSpan(n.TextContent())
+	}
+
+	inner := c.children(n)
+	switch n.Tag {
+	case "a":
+		href := n.Attrs["href"]
+		text := strings.TrimSpace(inner)
+		switch {
+		case href == "" || strings.HasPrefix(href, "#_"):
+			// Anchors and the bookmarks of Word have no target worth keeping
+			return inner
+		case text == "":
+			return inner
+		case text == escapeText(href):
+			return "<" + href + ">"
+		}
+		return surround(inner, "[", "]("+strings.ReplaceAll(href, " ", "%20")+")")
+	case "strong", "b":
+		if weight := n.style("font-weight"); weight == "normal" || weight == "400" {
+			return inner
+		}
+		return surround(inner, "**", "**")
+	case "em", "i", "cite", "dfn", "var":
+		return surround(inner, "*", "*")
+	case "s", "strike", "del":
+		return surround(inner, "~~", "~~")
+	case "sup", "sub":
+		return surround(inner, "<"+n.Tag+">", "</"+n.Tag+">")
+	case "span", "font", "mark", "u", "ins", "small", "abbr", "label", "q":
+		// Office suites mark formatting with styles rather than elements
+		if weight := n.style("font-weight"); weight == "bold" || weight == "bolder" || (len(weight) == 3 && weight >= "600") {
+			inner = surround(inner, "**", "**")
+		}
+		if n.style("font-style") == "italic" {
+			inner = surround(inner, "*", "*")
+		}
+		if strings.Contains(n.style("text-decoration"), "line-through") {
+			inner = surround(inner, "~~", "~~")
+		}
+		return inner
+	}
+	return inner
+}
+
+// children renders the inline content of the children of n
+func (c *converter) children(n *Node) string {
+	var b strings.Builder
+	for _, child := range n.Children {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+// image renders an image. Embedded images become attachments, images whose
+// source can't be read take the next fallback image or are left out.
+func (c *converter) image(n *Node) string {
+	src, alt := n.Attrs["src"], strings.TrimSpace(n.Attrs["alt"])
+	var data []byte
+	switch {
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		return "![" + escapeText(alt) + "](" + strings.ReplaceAll(src, " ", "%20") + ")"
+	case strings.HasPrefix(src, "data:"):
+		if _, encoded, found := strings.Cut(src, ";base64,"); found {
+			data, _ = base64.StdEncoding.DecodeString(encoded)
+		}
+	case c.opts.images != nil:
+		data, _ = c.opts.images(src)
+	}
+	if len(data) == 0 && c.fallback < len(c.opts.Fallback) {
+		data = c.opts.Fallback[c.fallback]
+		c.fallback++
+	}
+	ext := imageExtensions[http.DetectContentType(data)]
+	if len(data) == 0 || ext == "" {
+		c.result.Missing++
+		return ""
+	}
+
+	name := fmt.Sprintf("%s-%d%s", c.opts.ImagePrefix, len(c.result.Images)+1, ext)
+	c.result.Images = append(c.result.Images, Image{Name: name, Data: data})
+	return "![" + escapeText(alt) + "](" + name + ")"
+}
+
+// codeBlock renders a pre element as a fenced code block
+func codeBlock(n *Node) string {
+	language := ""
+	for _, candidate := range append([]*Node{n}, n.Children...) {
+		if m := languageRegex.FindStringSubmatch(candidate.Attrs["class"]); m != nil {
+			language = m[1]
+			break
+		}
+	}
+	code := strings.Trim(strings.ReplaceAll(n.TextContent(), "\u00a0", " "), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+// codeSpan renders text as inline code
+func codeSpan(text string) string {
+	text = spaceRegex.ReplaceAllString(text, " ")
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// surround wraps text in markers, keeping the spaces around it outside, as
+// markdown doesn't allow them inside emphasis
+func surround(text, open, close string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + open + trimmed + close + text[start+len(trimmed):]
+}
+
+// escapeText escapes the characters of text markdown would interpret
+func escapeText(text string) string {
+	return escapeRegex.ReplaceAllStringFunc(text, func(match string) string {
+		i := strings.IndexAny(match, "\\`*[]<_")
+		return match[:i] + `\` + match[i:]
+	})
+}
+
+// prefixLines starts every line of text with prefix
+func prefixLines(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentRest indents all lines of text but the first
+func indentRest(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hasBlock reports whether a block element is among the descendants of n
+func hasBlock(n *Node) bool {
+	for _, child := range n.Children {
+		if blockElements[child.Tag] || hasBlock(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll returns the descendants of n matching, without descending into
+// the matches
+func findAll(n *Node, match func(*Node) bool) []*Node {
+	var found []*Node
+	for _, child := range n.Children {
+		if child.Tag == "" {
+			continue
+		}
+		if match(child) {
+			found = append(found, child)
+			continue
+		}
+		found = append(found, findAll(child, match)...)
+	}
+	return found
+}
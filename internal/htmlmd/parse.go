@@ -0,0 +1,139 @@
+// Package htmlmd converts rich text to markdown: the HTML browsers put on the
+// clipboard when copying from office suites and web pages, and Word documents.
+package htmlmd
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/sanitize"
+)
+
+// Node is an element or a text of a parsed document
+type Node struct {
+	Tag      string // Lowercase element name, "" for text
+	Attrs    map[string]string
+	Text     string
+	Children []*Node
+	Parent   *Node
+}
+
+// tagRegex matches the tags of sanitized HTML, whose attributes are always quoted
+var tagRegex = regexp.MustCompile(`<(/?)([a-z][a-z0-9:-]*)((?:\s+[^\s=>/]+(?:="[^"]*")?)*)\s*(/?)>`)
+
+// attrRegex matches an attribute of a sanitized tag
+var attrRegex = regexp.MustCompile(`([^\s=>/]+)(?:="([^"]*)")?`)
+
+// voidElements have no content and no end tag
+var voidElements = map[string]bool{
+	"br": true, "hr": true, "img": true, "input": true, "col": true, "wbr": true, "source": true, "track": true,
+}
+
+// closedBy lists, for elements whose end tag may be left out, the start tags
+// that end them
+var closedBy = map[string][]string{
+	"p":  {"p", "div", "ul", "ol", "dl", "table", "pre", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "section", "figure"},
+	"li": {"li"},
+	"dt": {"dt", "dd"},
+	"dd": {"dt", "dd"},
+	"tr": {"tr"},
+	"td": {"td", "th", "tr"},
+	"th": {"td", "th", "tr"},
+}
+
+// scopes stop the search for an element to close at the element containing it
+var scopes = map[string]bool{"ul": true, "ol": true, "dl": true, "table": true}
+
+// Parse builds the tree of an HTML fragment. Scripts, styles, comments and
+// unsafe URLs are removed first with the sanitizer of page HTML.
+func Parse(input string) *Node {
+	root := &Node{Tag: "body"}
+	current := root
+	clean := sanitize.DefaultPolicy().Sanitize(input)
+
+	addText := func(text string) {
+		if text != "" {
+			current.Children = append(current.Children, &Node{Text: html.UnescapeString(text), Parent: current})
+		}
+	}
+
+	last := 0
+	for _, m := range tagRegex.FindAllStringSubmatchIndex(clean, -1) {
+		addText(clean[last:m[0]])
+		last = m[1]
+		closing := m[3] > m[2]
+		name := clean[m[4]:m[5]]
+
+		if closing {
+			// Close the element and everything left open inside it
+			for n := current; n != root; n = n.Parent {
+				if n.Tag == name {
+					current = n.Parent
+					break
+				}
+				if scopes[n.Tag] {
+					break
+				}
+			}
+			continue
+		}
+
+		for n := current; n != root; n = n.Parent {
+			if contains(closedBy[n.Tag], name) {
+				current = n.Parent
+				break
+			}
+			if scopes[n.Tag] {
+				break
+			}
+		}
+
+		node := &Node{Tag: name, Attrs: make(map[string]string), Parent: current}
+		for _, attr := range attrRegex.FindAllStringSubmatch(clean[m[6]:m[7]], -1) {
+			node.Attrs[attr[1]] = html.UnescapeString(attr[2])
+		}
+		current.Children = append(current.Children, node)
+		if !voidElements[name] && m[9] == m[8] {
+			current = node
+		}
+	}
+	addText(clean[last:])
+	return root
+}
+
+// TextContent returns the text of a node and its descendants
+func (n *Node) TextContent() string {
+	if n.Tag == "" {
+		return n.Text
+	}
+	var b strings.Builder
+	for _, child := range n.Children {
+		if child.Tag == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(child.TextContent())
+	}
+	return b.String()
+}
+
+// style returns the value of a property of the inline style of a node
+func (n *Node) style(property string) string {
+	for _, declaration := range strings.Split(n.Attrs["style"], ";") {
+		name, value, found := strings.Cut(declaration, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), property) {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package i18n
+
+import "strings"
+
+// rtlLanguages are the languages written from right to left
+var rtlLanguages = map[string]bool{
+	"ar": true, "arc": true, "ckb": true, "dv": true, "fa": true, "he": true, "iw": true,
+	"ks": true, "ps": true, "sd": true, "syr": true, "ug": true, "ur": true, "yi": true,
+}
+
+// IsRTL reports whether lang, such as "ar", "fa-IR" or "he_IL", is written
+// from right to left
+func IsRTL(lang string) bool {
+	base := strings.ToLower(lang)
+	if i := strings.IndexAny(base, "-_"); i >= 0 {
+		base = base[:i]
+	}
+	return rtlLanguages[base]
+}
+
+// Direction returns the direction text in lang is written in, "rtl" or "ltr"
+func Direction(lang string) string {
+	if IsRTL(lang) {
+		return "rtl"
+	}
+	return "ltr"
+}
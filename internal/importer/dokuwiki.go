@@ -0,0 +1,469 @@
+package importer
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/goldext"
+)
+
+// Syntax of DokuWiki pages
+var (
+	dokuBlockRegex     = regexp.MustCompile(`(?s)<(?:code|file)(?:[ \t]+([^\s>]*)[^>]*)?>\n?(.*?)</(?:code|file)>`)
+	dokuHTMLRegex      = regexp.MustCompile(`(?is)<html>(.*?)</html>`)
+	dokuNowikiRegex    = regexp.MustCompile(`(?s)<nowiki>(.*?)</nowiki>|%%(.*?)%%`)
+	dokuTagRegex       = regexp.MustCompile(`\{\{tag>([^}]*)\}\}`)
+	dokuMacroRegex     = regexp.MustCompile(`~~[A-Z_]+(?::[^~]*)?~~`)
+	dokuFootnoteRegex  = regexp.MustCompile(`(?s)\(\((.*?)\)\)`)
+	dokuMediaRegex     = regexp.MustCompile(`\{\{\s*([^}|?]*?)(?:\?([^}|]*?))?\s*(?:\|([^}]*))?\}\}`)
+	dokuLinkRegex      = regexp.MustCompile(`\[\[([^\]|]*?)(?:\|((?:[^\]]|\][^\]])*))?\]\]`)
+	dokuInterwikiRegex = regexp.MustCompile(`^([\w.-]+)>(.*)$`)
+	dokuURLRegex       = regexp.MustCompile(`\b(?:https?|ftp)://[^\s<>\[\]{}"|]+`)
+	dokuMonospaceRegex = regexp.MustCompile(`''(.+?)''`)
+	dokuHeadingRegex   = regexp.MustCompile(`^\s*(={2,6})\s*(.*?)\s*={2,6}\s*$`)
+	dokuListRegex      = regexp.MustCompile(`^((?: {2}|\t)+)([*-])\s?(.*)$`)
+	dokuCellRegex      = regexp.MustCompile(`[|^]`)
+	dokuQuoteRegex     = regexp.MustCompile(`^(>+)\s?(.*)$`)
+	dokuItalicRegex    = regexp.MustCompile(`//(.+?)//`)
+	dokuUnderlineRegex = regexp.MustCompile(`__(.+?)__`)
+	dokuBreakRegex     = regexp.MustCompile(`\\\\(?:[ \t]+|$)`)
+)
+
+// dokuInterwiki maps the common interwiki shortcuts of DokuWiki to their sites
+var dokuInterwiki = map[string]string{
+	"wp":     "https://en.wikipedia.org/wiki/",
+	"wpde":   "https://de.wikipedia.org/wiki/",
+	"doku":   "https://www.dokuwiki.org/",
+	"google": "https://www.google.com/search?q=",
+}
+
+// DokuWiki converts the data directory of a DokuWiki, or its pages directory.
+// Namespaces become directories and their start page the page of the
+// directory, media files are attached to the page of their namespace, tags of
+// the tag plugin go to the frontmatter and the wiki syntax becomes markdown.
+func DokuWiki(files []File) (*Site, error) {
+	root, found := dokuWikiRoot(files)
+	pageFiles := make(map[string]File) // Page id, slash separated, to page
+	mediaFiles := make(map[string]File)
+	for _, file := range files {
+		name := file.Name
+		if !found {
+			// An archive of the pages directory itself
+			if strings.HasSuffix(name, ".txt") {
+				pageFiles[dokuFileID(strings.TrimSuffix(name, ".txt"))] = file
+			}
+			continue
+		}
+		if root != "" {
+			if !strings.HasPrefix(name, root+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(name, root+"/")
+		}
+		switch dir, rest, _ := strings.Cut(name, "/"); {
+		case dir == "pages" && strings.HasSuffix(rest, ".txt"):
+			pageFiles[dokuFileID(strings.TrimSuffix(rest, ".txt"))] = file
+		case dir == "media" && rest != "":
+			mediaFiles[dokuFileID(rest)] = file
+		}
+	}
+	if len(pageFiles) == 0 {
+		return nil, fmt.Errorf("no DokuWiki pages found in the archive")
+	}
+
+	// The start page of a namespace, or the page named like it, is the page of its directory
+	keyOf := make(map[string]string)
+	for id := range pageFiles {
+		keyOf[id] = id
+	}
+	for id := range pageFiles {
+		ns := parentKey(id)
+		if ns == "" {
+			continue
+		}
+		_, hasPage := pageFiles[ns]
+		_, hasStart := pageFiles[ns+"/start"]
+		switch path.Base(id) {
+		case "start":
+			if !hasPage {
+				keyOf[id] = ns
+			}
+		case path.Base(ns):
+			if !hasPage && !hasStart {
+				keyOf[id] = ns
+			}
+		}
+	}
+	pageKeys := make(map[string]bool)
+	var keys []string
+	for _, key := range keyOf {
+		pageKeys[key] = true
+		keys = append(keys, key)
+	}
+
+	paths := newPaths(parentKey, func(key string) (string, string) {
+		return strings.ReplaceAll(path.Base(key), "_", " "), ""
+	})
+	paths.assignAll(keys)
+
+	pageURL := func(id string) string {
+		if key, ok := keyOf[id]; ok {
+			return "/" + paths.of(key)
+		}
+		if pageKeys[id] {
+			return "/" + paths.of(id)
+		}
+		// Links to missing pages point at where the page would be created
+		segments := strings.Split(id, "/")
+		for i, segment := range segments {
+			segments[i] = Slug(strings.ReplaceAll(segment, "_", " "))
+		}
+		return "/" + strings.Join(segments, "/")
+	}
+
+	site := &Site{}
+	attached := make(map[string]string)
+	names := make(map[string]bool)
+	attach := func(id, page string) string {
+		if attachmentURL, ok := attached[id]; ok {
+			return attachmentURL
+		}
+		if ns := parentKey(id); ns != "" {
+			page = paths.of(ns)
+		}
+		name := uniqueName(names, page, AttachmentName(id))
+		attached[id] = AttachmentURL(page, name)
+		site.Attachments = append(site.Attachments, Attachment{Page: page, Name: name, Source: mediaFiles[id].Name, Data: mediaFiles[id].Data})
+		return attached[id]
+	}
+
+	ids := make([]string, 0, len(pageFiles))
+	for id := range pageFiles {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		page := paths.of(keyOf[id])
+		content, tags := convertDokuWiki(string(pageFiles[id].Data), parentKey(id), pageURL, func(mediaID string) (string, bool) {
+			if _, ok := mediaFiles[mediaID]; !ok {
+				return "", false
+			}
+			return attach(mediaID, page), true
+		})
+		site.Pages = append(site.Pages, Page{Path: page, Source: pageFiles[id].Name, Content: withTags(content, tags)})
+	}
+
+	// Media no page uses are attached to the page of their namespace
+	var unused []string
+	for id := range mediaFiles {
+		if _, ok := attached[id]; !ok {
+			unused = append(unused, id)
+		}
+	}
+	sort.Strings(unused)
+	for _, id := range unused {
+		if parentKey(id) == "" {
+			site.Warnings = append(site.Warnings, mediaFiles[id].Name+": not used by any page")
+			continue
+		}
+		attach(id, "")
+	}
+
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].Path < site.Pages[j].Path })
+	return site, nil
+}
+
+// dokuWikiRoot returns the directory of the archive holding the pages and
+// media directories of DokuWiki, not found when the archive holds the pages only
+func dokuWikiRoot(files []File) (string, bool) {
+	root, found := "", false
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name, ".txt") {
+			continue
+		}
+		segments := strings.Split(file.Name, "/")
+		for i, segment := range segments[:len(segments)-1] {
+			if segment == "pages" {
+				if prefix := strings.Join(segments[:i], "/"); !found || len(prefix) < len(root) {
+					root, found = prefix, true
+				}
+				break
+			}
+		}
+	}
+	return root, found
+}
+
+// dokuFileID turns the path of a page or media file into its id, decoding
+// the names DokuWiki url-encodes
+func dokuFileID(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segments[i] = decoded
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// resolveDokuID resolves the id of a link or media in the namespace ns the
+// way DokuWiki does: ids without namespace are relative to ns, ids starting
+// with . are relative paths and those starting with : are absolute
+func resolveDokuID(ns, id string) string {
+	id = strings.TrimSpace(strings.ReplaceAll(id, "/", ":"))
+	var segments []string
+	switch {
+	case strings.HasPrefix(id, "."):
+		if ns != "" {
+			segments = strings.Split(ns, "/")
+		}
+		for _, segment := range strings.Split(id, ":") {
+			switch segment {
+			case ".", "":
+			case "..":
+				if len(segments) > 0 {
+					segments = segments[:len(segments)-1]
+				}
+			default:
+				segments = append(segments, segment)
+			}
+		}
+	case strings.HasPrefix(id, ":"):
+		segments = strings.Split(id[1:], ":")
+	case !strings.Contains(id, ":") && ns != "":
+		segments = append(strings.Split(ns, "/"), id)
+	default:
+		segments = strings.Split(id, ":")
+	}
+
+	var cleaned []string
+	for _, segment := range segments {
+		segment = strings.Join(strings.Fields(strings.ToLower(segment)), "_")
+		if segment != "" {
+			cleaned = append(cleaned, segment)
+		}
+	}
+	return strings.Join(cleaned, "/")
+}
+
+// convertDokuWiki converts a page in the namespace ns to markdown and returns
+// it with the tags of the page. pageURL returns the address of a page id,
+// media the address of a media id and false when the file doesn't exist.
+func convertDokuWiki(text, ns string, pageURL func(id string) string, media func(id string) (string, bool)) (string, []string) {
+	p := &placeholders{}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	// Code, raw HTML and text excluded from formatting are kept as they are
+	text = dokuBlockRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := dokuBlockRegex.FindStringSubmatch(match)
+		lang := m[1]
+		if lang == "-" {
+			lang = ""
+		}
+		return "\n" + p.add("```"+lang+"\n"+strings.TrimRight(m[2], "\n")+"\n```", match) + "\n"
+	})
+	text = dokuHTMLRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return p.add(dokuHTMLRegex.FindStringSubmatch(match)[1], match)
+	})
+	text = dokuNowikiRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := dokuNowikiRegex.FindStringSubmatch(match)
+		return p.add(escapeMarkdown(m[1]+m[2]), match)
+	})
+
+	var tags []string
+	text = dokuTagRegex.ReplaceAllStringFunc(text, func(match string) string {
+		tags = append(tags, strings.Fields(dokuTagRegex.FindStringSubmatch(match)[1])...)
+		return ""
+	})
+	text = dokuMacroRegex.ReplaceAllString(text, "")
+
+	protect := func(s string) string {
+		s = dokuMediaRegex.ReplaceAllStringFunc(s, func(match string) string {
+			return p.add(dokuMedia(dokuMediaRegex.FindStringSubmatch(match), ns, media), match)
+		})
+		s = dokuLinkRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := dokuLinkRegex.FindStringSubmatch(match)
+			return p.add(dokuLink(strings.TrimSpace(m[1]), strings.TrimSpace(m[2]), ns, pageURL), match)
+		})
+		s = dokuURLRegex.ReplaceAllStringFunc(s, func(match string) string {
+			return p.add(match, match)
+		})
+		return dokuMonospaceRegex.ReplaceAllStringFunc(s, func(match string) string {
+			return p.add("`"+dokuMonospaceRegex.FindStringSubmatch(match)[1]+"`", match)
+		})
+	}
+	format := func(s string) string {
+		s = dokuItalicRegex.ReplaceAllString(s, "*$1*")
+		s = dokuUnderlineRegex.ReplaceAllString(s, "<u>$1</u>")
+		return dokuBreakRegex.ReplaceAllString(s, "<br>")
+	}
+
+	var footnotes []string
+	text = dokuFootnoteRegex.ReplaceAllStringFunc(text, func(match string) string {
+		footnotes = append(footnotes, format(protect(strings.TrimSpace(match[2:len(match)-2]))))
+		return fmt.Sprintf("[^%d]", len(footnotes))
+	})
+	text = protect(text)
+
+	lines := strings.Split(text, "\n")
+	var out []string
+	blankBefore := func() {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+	}
+	inList := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		wasList := inList
+		inList = dokuListRegex.MatchString(line) || dokuQuoteRegex.MatchString(line)
+		if m := dokuHeadingRegex.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("#", 7-len(m[1]))+" "+format(m[2]))
+			continue
+		}
+		if m := dokuListRegex.FindStringSubmatch(line); m != nil {
+			level := len(strings.ReplaceAll(m[1], "\t", "  ")) / 2
+			marker := "- "
+			if m[2] == "-" {
+				marker = "1. "
+			}
+			out = append(out, strings.Repeat("    ", level-1)+marker+format(m[3]))
+			continue
+		}
+		if strings.HasPrefix(line, "^") || strings.HasPrefix(line, "|") {
+			var rows [][]string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "^") || strings.HasPrefix(lines[i], "|")); i++ {
+				row := strings.TrimRight(lines[i], " \t")[1:]
+				if strings.HasSuffix(row, "|") || strings.HasSuffix(row, "^") {
+					row = row[:len(row)-1]
+				}
+				cells := dokuCellRegex.Split(row, -1)
+				for j, cell := range cells {
+					if cell = strings.TrimSpace(cell); cell == ":::" {
+						cell = ""
+					}
+					cells[j] = format(cell)
+				}
+				rows = append(rows, cells)
+			}
+			i--
+			blankBefore()
+			out = append(out, strings.TrimRight(markdownTable(padRows(rows)), "\n"), "")
+			continue
+		}
+		if m := dokuQuoteRegex.FindStringSubmatch(line); m != nil {
+			out = append(out, strings.Repeat("> ", len(m[1]))+format(m[2]))
+			continue
+		}
+		if strings.HasPrefix(line, "----") && strings.Trim(line, "- \t") == "" {
+			blankBefore()
+			out = append(out, "---")
+			continue
+		}
+		if (strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")) && strings.TrimSpace(line) != "" {
+			// Indented lines are preformatted text
+			var code []string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "  ") || strings.HasPrefix(lines[i], "\t")) && !dokuListRegex.MatchString(lines[i]); i++ {
+				code = append(code, p.restoreRaw(strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "  ")))
+			}
+			i--
+			blankBefore()
+			out = append(out, "```\n"+strings.Join(code, "\n")+"\n```", "")
+			continue
+		}
+		if wasList && strings.TrimSpace(line) != "" {
+			// Markdown would continue the list or quote above with the paragraph
+			blankBefore()
+		}
+		out = append(out, format(line))
+	}
+
+	content := strings.TrimSpace(strings.Join(out, "\n")) + "\n"
+	if len(footnotes) > 0 {
+		content += "\n"
+		for i, footnote := range footnotes {
+			content += fmt.Sprintf("[^%d]: %s\n", i+1, footnote)
+		}
+	}
+	return p.restore(content), tags
+}
+
+// dokuMedia converts {{media}} from the submatches of dokuMediaRegex: the
+// id, the parameters such as the size and the caption
+func dokuMedia(m []string, ns string, media func(id string) (string, bool)) string {
+	id, params, caption := strings.TrimSpace(m[1]), m[2], strings.TrimSpace(m[3])
+	mediaURL := id
+	if !strings.Contains(id, "://") {
+		resolved := resolveDokuID(ns, id)
+		var ok bool
+		if mediaURL, ok = media(resolved); !ok {
+			return m[0]
+		}
+		id = resolved
+	}
+
+	width, linkOnly := "", false
+	for _, param := range strings.Split(params, "&") {
+		if param == "linkonly" {
+			linkOnly = true
+		} else if w, _, _ := strings.Cut(param, "x"); w != "" && strings.Trim(w, "0123456789") == "" {
+			width = w
+		}
+	}
+
+	name := orName(caption, path.Base(id))
+	ext := strings.ToLower(path.Ext(strings.SplitN(id, "?", 2)[0]))
+	switch {
+	case !imageExtensions[ext] || linkOnly:
+		return "[" + escapeLinkText(name) + "](" + mediaURL + ")"
+	case width != "":
+		return `<img src="` + html.EscapeString(mediaURL) + `" alt="` + html.EscapeString(name) + `" width="` + width + `">`
+	default:
+		return "![" + escapeLinkText(caption) + "](" + mediaURL + ")"
+	}
+}
+
+// dokuLink converts [[target|text]] in the namespace ns
+func dokuLink(target, text, ns string, pageURL func(id string) string) string {
+	var linkURL string
+	switch {
+	case strings.Contains(target, "://"):
+		linkURL = target
+	case dokuInterwikiRegex.MatchString(target):
+		m := dokuInterwikiRegex.FindStringSubmatch(target)
+		base, ok := dokuInterwiki[strings.ToLower(m[1])]
+		if !ok {
+			return orName(text, m[2])
+		}
+		linkURL = base + url.PathEscape(m[2])
+		text = orName(text, m[2])
+	case strings.Contains(target, "@") && !strings.ContainsAny(target, " :"):
+		linkURL = "mailto:" + target
+	case strings.HasPrefix(target, `\\`):
+		// Windows shares can't be linked from the browser
+		return orName(text, target)
+	default:
+		id, fragment := splitFragment(target)
+		if fragment != "" {
+			fragment = "#" + goldext.HeadingID(fragment[1:])
+		}
+		if id == "" {
+			linkURL = fragment
+		} else {
+			resolved := resolveDokuID(ns, id)
+			linkURL = pageURL(resolved) + fragment
+			if text == "" {
+				text = strings.ReplaceAll(path.Base(resolved), "_", " ")
+			}
+		}
+	}
+	if text == "" {
+		text = target
+	}
+	return "[" + escapeLinkText(text) + "](" + linkURL + ")"
+}
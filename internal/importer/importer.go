@@ -0,0 +1,369 @@
+// Package importer converts the exports of other wikis and note-taking tools
+// into pages and attachments laid out the way the wiki stores them.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits of a single import
+const (
+	maxEntrySize = 64 << 20 // Uncompressed size of one file of the archive
+	maxEntries   = 20000    // Files in the archive, nested archives included
+)
+
+// Page is a converted page
+type Page struct {
+	Path    string // Wiki path without leading slash, e.g. "guides/setup"
+	Source  string // File of the archive the page was made from
+	Content string
+}
+
+// Attachment is a file attached to a converted page
+type Attachment struct {
+	Page   string // Wiki path of the page the file belongs to
+	Name   string
+	Source string
+	Data   []byte
+}
+
+// Site is the content of an export converted to the layout of the wiki
+type Site struct {
+	Pages       []Page
+	Attachments []Attachment
+	Warnings    []string // Files that were left out, and why
+}
+
+// converters maps the name of a format to the function converting its archives
+var converters = map[string]func(files []File) (*Site, error){
+	"notion":     Notion,
+	"obsidian":   Obsidian,
+	"dokuwiki":   DokuWiki,
+	"tiddlywiki": TiddlyWiki,
+}
+
+// singleFiles lists the extensions of the files that formats exported as a
+// single file accept besides zip archives
+var singleFiles = map[string][]string{
+	"tiddlywiki": {".html", ".htm", ".json"},
+}
+
+// imageExtensions are the attachments shown as images rather than linked
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".bmp": true,
+}
+
+// Supported reports whether the format has a converter
+func Supported(format string) bool {
+	_, ok := converters[format]
+	return ok
+}
+
+// Accepts reports whether an uploaded file with the given name can be
+// imported in the format
+func Accepts(format, name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == ".zip" {
+		return true
+	}
+	for _, accepted := range singleFiles[format] {
+		if ext == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// File is a file of an export
+type File struct {
+	Name string // Slash separated path in the export
+	Data []byte
+}
+
+// Convert reads an export in the given format and converts it. The export is
+// a zip archive, or a single file for the formats accepting one.
+func Convert(format, name string, data []byte) (*Site, error) {
+	convert, ok := converters[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	if !Accepts(format, name) {
+		return nil, fmt.Errorf("%s exports can't be imported from %s files", format, path.Ext(name))
+	}
+	if !strings.EqualFold(path.Ext(name), ".zip") {
+		return convert([]File{{Name: path.Base(name), Data: data}})
+	}
+	files, err := ReadZip(data)
+	if err != nil {
+		return nil, err
+	}
+	return convert(files)
+}
+
+// ReadZip returns the files of a zip archive. Archives nested in it, which
+// tools use to split large exports, are read as if their files were at the top.
+func ReadZip(data []byte) ([]File, error) {
+	var files []File
+	if err := readZip(data, &files, 0); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func readZip(data []byte, files *[]File, depth int) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid ZIP archive: %w", err)
+	}
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(strings.ReplaceAll(entry.Name, "\\", "/"))
+		if name == "." || strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+		if len(*files) >= maxEntries {
+			return fmt.Errorf("the archive has more than %d files", maxEntries)
+		}
+
+		content, err := readEntry(entry)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		if strings.EqualFold(path.Ext(name), ".zip") && depth == 0 {
+			if err := readZip(content, files, depth+1); err != nil {
+				return fmt.Errorf("%s: %w", entry.Name, err)
+			}
+			continue
+		}
+		*files = append(*files, File{Name: name, Data: content})
+	}
+	return nil
+}
+
+func readEntry(entry *zip.File) ([]byte, error) {
+	in, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	content, err := io.ReadAll(io.LimitReader(in, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxEntrySize {
+		return nil, fmt.Errorf("file is larger than %d MB", maxEntrySize>>20)
+	}
+	return content, nil
+}
+
+// paths assigns unique wiki paths to the pages and directories of an export,
+// identified by keys such as their path in the archive without extension
+type paths struct {
+	byKey  map[string]string
+	owners map[string]string // Wiki path to the key it was assigned to
+	parent func(key string) string
+	name   func(key string) (name, disambiguation string)
+}
+
+func newPaths(parent func(string) string, name func(string) (string, string)) *paths {
+	return &paths{
+		byKey:  map[string]string{"": ""},
+		owners: map[string]string{"": ""},
+		parent: parent,
+		name:   name,
+	}
+}
+
+// of returns the wiki path of key, assigning one on first use. A name taken
+// by another key gets the disambiguation, or a number, appended.
+func (p *paths) of(key string) string {
+	if wikiPath, ok := p.byKey[key]; ok {
+		return wikiPath
+	}
+	parent := p.of(p.parent(key))
+	name, disambiguation := p.name(key)
+	name = Slug(name)
+
+	candidate := path.Join(parent, name)
+	for i := 2; ; i++ {
+		if owner, taken := p.owners[candidate]; !taken || owner == key {
+			break
+		}
+		if disambiguation != "" {
+			candidate = path.Join(parent, name+"-"+Slug(disambiguation))
+			disambiguation = ""
+			continue
+		}
+		candidate = path.Join(parent, fmt.Sprintf("%s-%d", name, i))
+	}
+	p.byKey[key] = candidate
+	p.owners[candidate] = key
+	return candidate
+}
+
+// assignAll assigns paths in sorted key order, so names that collide are
+// resolved the same way on every import of an export
+func (p *paths) assignAll(keys []string) {
+	sort.Strings(keys)
+	for _, key := range keys {
+		p.of(key)
+	}
+}
+
+// Slug turns a title into a path segment of the wiki
+func Slug(title string) string {
+	if s := slug.Make(title); s != "" {
+		return s
+	}
+	return "untitled"
+}
+
+// attachmentNameRegex matches the characters left out of attachment names
+var attachmentNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// AttachmentName turns a file name into the name of an attachment of the wiki
+func AttachmentName(name string) string {
+	name = path.Base(name)
+	ext := strings.ToLower(path.Ext(name))
+	base := strings.Trim(attachmentNameRegex.ReplaceAllString(strings.TrimSuffix(name, path.Ext(name)), "_"), "_.")
+	if base == "" {
+		base = "file"
+	}
+	return base + attachmentNameRegex.ReplaceAllString(ext, "")
+}
+
+// AttachmentURL returns the address of an attachment of a page
+func AttachmentURL(page, name string) string {
+	return "/api/files/" + page + "/" + name
+}
+
+// linkRegex matches the targets of markdown links and images
+var linkRegex = regexp.MustCompile(`(!?\[(?:[^\[\]]|\[[^\[\]]*\])*\]\()(<[^>\n]*>|[^)\s]+)`)
+
+// rewriteLinks replaces the target of every markdown link and image for which
+// rewrite returns true
+func rewriteLinks(content string, rewrite func(target string) (string, bool)) string {
+	return linkRegex.ReplaceAllStringFunc(content, func(match string) string {
+		parts := linkRegex.FindStringSubmatch(match)
+		target := strings.TrimSuffix(strings.TrimPrefix(parts[2], "<"), ">")
+		if newTarget, ok := rewrite(target); ok {
+			if strings.ContainsAny(newTarget, " ()") {
+				newTarget = "<" + newTarget + ">"
+			}
+			return parts[1] + newTarget
+		}
+		return match
+	})
+}
+
+// isExternal reports whether a link target leaves the export
+func isExternal(target string) bool {
+	return target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") ||
+		strings.Contains(strings.SplitN(target, "/", 2)[0], ":")
+}
+
+// splitFragment splits "page.md#section" into "page.md" and "#section"
+func splitFragment(target string) (string, string) {
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		return target[:i], target[i:]
+	}
+	return target, ""
+}
+
+// withTags adds the tags of a converted page to its frontmatter. Spaces in
+// tags become dashes, as the wiki separates tags with spaces.
+func withTags(content string, tags []string) string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.TrimPrefix(tag, "#")), "-")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			cleaned = append(cleaned, tag)
+		}
+	}
+	if len(cleaned) == 0 {
+		return content
+	}
+	data, err := yaml.Marshal(struct {
+		Tags []string `yaml:"tags,flow"`
+	}{cleaned})
+	if err != nil {
+		return content
+	}
+	return "---\n" + string(data) + "---\n\n" + content
+}
+
+// placeholderRegex matches the placeholders of a page being converted
+var placeholderRegex = regexp.MustCompile("\x00(\\d+)\x00")
+
+// placeholders keeps converted parts of a page, such as code and links, out
+// of the reach of the rest of the syntax conversion
+type placeholders struct {
+	markdown []string
+	raw      []string
+}
+
+// add stores the markdown of a part and the text it was converted from, and
+// returns the placeholder standing in for it
+func (p *placeholders) add(markdown, raw string) string {
+	p.markdown = append(p.markdown, markdown)
+	p.raw = append(p.raw, raw)
+	return "\x00" + strconv.Itoa(len(p.markdown)-1) + "\x00"
+}
+
+// restore replaces the placeholders with their markdown, including those
+// nested in the markdown of other parts
+func (p *placeholders) restore(text string) string {
+	return p.replace(text, p.markdown)
+}
+
+// restoreRaw replaces the placeholders with the text they were converted from
+func (p *placeholders) restoreRaw(text string) string {
+	return p.replace(text, p.raw)
+}
+
+func (p *placeholders) replace(text string, parts []string) string {
+	for depth := 0; depth < 10 && strings.Contains(text, "\x00"); depth++ {
+		text = placeholderRegex.ReplaceAllStringFunc(text, func(match string) string {
+			i, _ := strconv.Atoi(placeholderRegex.FindStringSubmatch(match)[1])
+			return parts[i]
+		})
+	}
+	return text
+}
+
+// markdownSpecialRegex matches the characters markdown gives a meaning to
+var markdownSpecialRegex = regexp.MustCompile("([\\\\`*_{}\\[\\]<>#|~])")
+
+// escapeMarkdown escapes text to show it as it is
+func escapeMarkdown(text string) string {
+	return markdownSpecialRegex.ReplaceAllString(text, `\$1`)
+}
+
+// padRows makes the rows of a table the same length
+func padRows(rows [][]string) [][]string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	for i := range rows {
+		for len(rows[i]) < width {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	return rows
+}
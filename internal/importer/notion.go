@@ -0,0 +1,282 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// notionHashRegex matches the id Notion appends to the names of exported pages
+var notionHashRegex = regexp.MustCompile(`^(.*?)\s*([0-9a-f]{32})$`)
+
+// notionIDRegex finds a page id in a link to notion.so
+var notionIDRegex = regexp.MustCompile(`[0-9a-f]{32}`)
+
+// notionAsideRegex matches callouts, which Notion exports as HTML asides
+var notionAsideRegex = regexp.MustCompile(`(?s)<aside>\s*(.*?)\s*</aside>`)
+
+// notionIconRegex matches the icon images of callouts
+var notionIconRegex = regexp.MustCompile(`<img [^>]*/?>\s*`)
+
+// splitNotionName splits "Getting Started 0123…cdef" into the title and the id
+func splitNotionName(name string) (string, string) {
+	if m := notionHashRegex.FindStringSubmatch(name); m != nil {
+		if m[1] == "" {
+			return "Untitled", m[2]
+		}
+		return m[1], m[2]
+	}
+	return name, ""
+}
+
+// Notion converts a Notion workspace export in "Markdown & CSV" format. Pages
+// keep their hierarchy, databases become pages with a table linking to their
+// rows, callouts become block quotes and links between pages and to files
+// point at the imported copies.
+func Notion(files []File) (*Site, error) {
+	files = stripContainer(files)
+
+	pageFiles := make(map[string]File)
+	databases := make(map[string]File)
+	var assets []File
+	for _, file := range files {
+		switch ext := strings.ToLower(path.Ext(file.Name)); ext {
+		case ".md":
+			pageFiles[strings.TrimSuffix(file.Name, path.Ext(file.Name))] = file
+		case ".csv":
+			// Exports have "Name.csv" with the rows of the view and "Name_all.csv" with all rows
+			key := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+			all := strings.HasSuffix(key, "_all")
+			key = strings.TrimSuffix(key, "_all")
+			if _, seen := databases[key]; !seen || all {
+				databases[key] = file
+			}
+		default:
+			assets = append(assets, file)
+		}
+	}
+	if len(pageFiles) == 0 && len(databases) == 0 {
+		return nil, fmt.Errorf("no Notion pages found in the archive")
+	}
+
+	paths := newPaths(parentKey, func(key string) (string, string) {
+		title, id := splitNotionName(path.Base(key))
+		if len(id) > 6 {
+			id = id[:6]
+		}
+		return title, id
+	})
+	var keys []string
+	byID := make(map[string]string)
+	for key := range pageFiles {
+		keys = append(keys, key)
+	}
+	for key := range databases {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if _, id := splitNotionName(path.Base(key)); id != "" {
+			byID[id] = key
+		}
+	}
+	paths.assignAll(keys)
+
+	site := &Site{}
+
+	// Attachments belong to the page of the directory they are in
+	assetURLs := make(map[string]string)
+	names := make(map[string]bool)
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Name < assets[j].Name })
+	for _, asset := range assets {
+		dir := parentKey(asset.Name)
+		if dir == "" {
+			site.Warnings = append(site.Warnings, asset.Name+": not attached to a page")
+			continue
+		}
+		page := paths.of(dir)
+		name := uniqueName(names, page, AttachmentName(asset.Name))
+		assetURLs[asset.Name] = AttachmentURL(page, name)
+		site.Attachments = append(site.Attachments, Attachment{Page: page, Name: name, Source: asset.Name, Data: asset.Data})
+	}
+
+	resolve := func(source, target string) (string, bool) {
+		if isExternal(target) {
+			if u, err := url.Parse(target); err == nil && strings.HasSuffix(u.Hostname(), "notion.so") {
+				ids := notionIDRegex.FindAllString(u.Path, -1)
+				if len(ids) > 0 {
+					if key, ok := byID[ids[len(ids)-1]]; ok {
+						return "/" + paths.of(key), true
+					}
+				}
+			}
+			return "", false
+		}
+		unescaped, err := url.PathUnescape(target)
+		if err != nil {
+			unescaped = target
+		}
+		file, fragment := splitFragment(unescaped)
+		rel := path.Clean(path.Join(path.Dir(source), file))
+
+		switch strings.ToLower(path.Ext(rel)) {
+		case ".md", ".csv":
+			key := strings.TrimSuffix(strings.TrimSuffix(rel, path.Ext(rel)), "_all")
+			_, isPage := pageFiles[key]
+			_, isDatabase := databases[key]
+			if isPage || isDatabase {
+				return "/" + paths.of(key) + fragment, true
+			}
+		default:
+			if assetURL, ok := assetURLs[rel]; ok {
+				return assetURL, true
+			}
+		}
+		return "", false
+	}
+
+	for _, key := range keys {
+		var content, source string
+		if file, ok := pageFiles[key]; ok {
+			source = file.Name
+			content = convertNotionPage(string(file.Data), func(target string) (string, bool) {
+				return resolve(file.Name, target)
+			})
+		}
+		if file, ok := databases[key]; ok {
+			if source == "" {
+				source = file.Name
+				title, _ := splitNotionName(path.Base(key))
+				content = "# " + title + "\n"
+			}
+			table, err := notionTable(file.Data, key, pageFiles, paths)
+			if err != nil {
+				site.Warnings = append(site.Warnings, fmt.Sprintf("%s: %v", file.Name, err))
+			} else {
+				content = strings.TrimRight(content, "\n") + "\n\n" + table
+			}
+		}
+		site.Pages = append(site.Pages, Page{Path: paths.of(key), Source: source, Content: content})
+	}
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].Path < site.Pages[j].Path })
+
+	return site, nil
+}
+
+// convertNotionPage turns callouts into block quotes and rewrites the links
+func convertNotionPage(content string, resolve func(string) (string, bool)) string {
+	content = notionAsideRegex.ReplaceAllStringFunc(content, func(aside string) string {
+		body := notionIconRegex.ReplaceAllString(notionAsideRegex.FindStringSubmatch(aside)[1], "")
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+strings.TrimSpace(line), " ")
+		}
+		return strings.Join(lines, "\n")
+	})
+	return rewriteLinks(content, resolve)
+}
+
+// notionTable renders the rows of a database as a markdown table, with the
+// first column linking to the page of each row
+func notionTable(data []byte, key string, pageFiles map[string]File, paths *paths) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+
+	// Rows are exported as pages in the directory of the database
+	rowPages := make(map[string]string)
+	for pageKey := range pageFiles {
+		if parentKey(pageKey) == key {
+			title, _ := splitNotionName(path.Base(pageKey))
+			rowPages[title] = "/" + paths.of(pageKey)
+		}
+	}
+
+	var rows [][]string
+	for i, record := range records {
+		row := make([]string, len(records[0]))
+		copy(row, record)
+		if i > 0 && row[0] != "" {
+			if rowPath, ok := rowPages[row[0]]; ok {
+				row[0] = "[" + escapeLinkText(row[0]) + "](" + rowPath + ")"
+			}
+		}
+		rows = append(rows, row)
+	}
+	return markdownTable(rows), nil
+}
+
+// markdownTable renders rows as a markdown table with the first row as header
+func markdownTable(rows [][]string) string {
+	var b strings.Builder
+	for i, row := range rows {
+		b.WriteString("|")
+		for _, cell := range row {
+			cell = strings.ReplaceAll(strings.TrimSpace(cell), "|", `\|`)
+			cell = strings.ReplaceAll(strings.ReplaceAll(cell, "\r\n", "\n"), "\n", "<br>")
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// escapeLinkText escapes the brackets of the text of a markdown link
+func escapeLinkText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
+
+// parentKey returns the key of the directory holding key, "" at the top
+func parentKey(key string) string {
+	if dir := path.Dir(key); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// uniqueName returns name, numbered when the page already has a file with that name
+func uniqueName(names map[string]bool, page, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; names[page+"/"+candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	names[page+"/"+candidate] = true
+	return candidate
+}
+
+// stripContainer removes a directory holding the whole export, which some
+// tools wrap exports in
+func stripContainer(files []File) []File {
+	if len(files) == 0 {
+		return files
+	}
+	top, _, found := strings.Cut(files[0].Name, "/")
+	if !found {
+		return files
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name, top+"/") {
+			return files
+		}
+	}
+	stripped := make([]File, len(files))
+	for i, file := range files {
+		stripped[i] = File{Name: strings.TrimPrefix(file.Name, top+"/"), Data: file.Data}
+	}
+	return stripped
+}
@@ -0,0 +1,253 @@
+package importer
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/goldext"
+)
+
+// vault indexes the notes and files of an Obsidian vault by name, the way
+// Obsidian resolves links
+type vault struct {
+	notes     map[string]File     // Page key to note
+	noteNames map[string][]string // Lower-case note name to page keys
+	notePaths map[string]string   // Lower-case path in the vault without .md to page key
+	files     map[string]File     // Path in the vault to attachment
+	fileNames map[string][]string // Lower-case file name to paths in the vault
+}
+
+// Obsidian converts a zipped Obsidian vault. Folders become directories and
+// notes pages, a note named like its folder becomes the page of the folder.
+// [[Wikilinks]] and markdown links between notes become links to the pages,
+// embedded files are attached to the first page using them, and embedded
+// notes stay ![[embeds]] rendered by the Obsidian extension.
+func Obsidian(files []File) (*Site, error) {
+	files = stripContainer(files)
+
+	v := &vault{
+		notes:     make(map[string]File),
+		noteNames: make(map[string][]string),
+		notePaths: make(map[string]string),
+		files:     make(map[string]File),
+		fileNames: make(map[string][]string),
+	}
+	for _, file := range files {
+		if isHiddenPath(file.Name) {
+			continue
+		}
+		if strings.EqualFold(path.Ext(file.Name), ".md") {
+			v.notes[strings.TrimSuffix(file.Name, path.Ext(file.Name))] = file
+			continue
+		}
+		v.files[file.Name] = file
+		name := strings.ToLower(path.Base(file.Name))
+		v.fileNames[name] = append(v.fileNames[name], file.Name)
+	}
+	if len(v.notes) == 0 {
+		return nil, fmt.Errorf("no notes found in the archive")
+	}
+
+	// A note named like its folder, without a note next to the folder, is the page of the folder
+	for key, file := range v.notes {
+		dir := parentKey(key)
+		if dir != "" && path.Base(dir) == path.Base(key) {
+			if _, sibling := v.notes[dir]; !sibling {
+				delete(v.notes, key)
+				v.notes[dir] = file
+			}
+		}
+	}
+
+	var keys []string
+	for key, file := range v.notes {
+		keys = append(keys, key)
+		noteName := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+		name := strings.ToLower(path.Base(noteName))
+		v.noteNames[name] = append(v.noteNames[name], key)
+		v.notePaths[strings.ToLower(noteName)] = key
+	}
+	paths := newPaths(parentKey, func(key string) (string, string) {
+		return path.Base(key), ""
+	})
+	paths.assignAll(keys)
+
+	site := &Site{}
+	attached := make(map[string]string) // Path in the vault to the URL of the attachment
+	names := make(map[string]bool)
+	attach := func(file, page string) string {
+		if attachmentURL, ok := attached[file]; ok {
+			return attachmentURL
+		}
+		name := uniqueName(names, page, AttachmentName(file))
+		attached[file] = AttachmentURL(page, name)
+		site.Attachments = append(site.Attachments, Attachment{Page: page, Name: name, Source: file, Data: v.files[file].Data})
+		return attached[file]
+	}
+
+	for _, key := range keys {
+		note := v.notes[key]
+		page := paths.of(key)
+		from := path.Dir(note.Name)
+
+		content := goldext.ReplaceWikiLinks(string(note.Data), func(link goldext.WikiLink) string {
+			if link.Target == "" {
+				return "[" + escapeLinkText(link.Text()) + "](" + link.Anchor() + ")"
+			}
+			if link.IsFile() {
+				file, ok := v.resolveFile(from, link.Target)
+				if !ok {
+					return link.Raw
+				}
+				fileURL := attach(file, page)
+				name := path.Base(file)
+				switch {
+				case !link.Embed:
+					return "[" + escapeLinkText(orName(link.Alias, name)) + "](" + fileURL + ")"
+				case !imageExtensions[strings.ToLower(path.Ext(file))]:
+					return "[" + escapeLinkText(name) + "](" + fileURL + ")"
+				case link.Alias != "" && strings.Trim(strings.SplitN(link.Alias, "x", 2)[0], "0123456789") == "":
+					return `<img src="` + html.EscapeString(fileURL) + `" alt="` + html.EscapeString(name) + `" width="` + strings.SplitN(link.Alias, "x", 2)[0] + `">`
+				default:
+					return "![" + escapeLinkText(name) + "](" + fileURL + ")"
+				}
+			}
+
+			target, ok := v.resolveNote(from, link.Target)
+			if !ok {
+				return link.Raw
+			}
+			if link.Embed {
+				// Embedded notes are shown by the Obsidian extension, the full path resolves them
+				embed := "![[" + paths.of(target)
+				if link.Heading != "" {
+					embed += "#" + link.Heading
+				}
+				return embed + "]]"
+			}
+			return "[" + escapeLinkText(link.Text()) + "](/" + paths.of(target) + link.Anchor() + ")"
+		})
+
+		content = rewriteLinks(content, func(target string) (string, bool) {
+			if isExternal(target) {
+				return "", false
+			}
+			unescaped, err := url.PathUnescape(target)
+			if err != nil {
+				unescaped = target
+			}
+			file, fragment := splitFragment(unescaped)
+			if strings.EqualFold(path.Ext(file), ".md") {
+				if noteKey, ok := v.resolveNote(from, file); ok {
+					return "/" + paths.of(noteKey) + fragment, true
+				}
+				return "", false
+			}
+			if vaultFile, ok := v.resolveFile(from, file); ok {
+				return attach(vaultFile, page), true
+			}
+			return "", false
+		})
+
+		site.Pages = append(site.Pages, Page{Path: page, Source: note.Name, Content: content})
+	}
+
+	// Files no note uses are attached to the page of their folder
+	var unused []string
+	for file := range v.files {
+		if _, ok := attached[file]; !ok {
+			unused = append(unused, file)
+		}
+	}
+	sort.Strings(unused)
+	for _, file := range unused {
+		dir := parentKey(file)
+		if dir == "" {
+			site.Warnings = append(site.Warnings, file+": not used by any note")
+			continue
+		}
+		attach(file, paths.of(dir))
+	}
+
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].Path < site.Pages[j].Path })
+	return site, nil
+}
+
+// resolveNote returns the page key of the note a link in the folder from
+// points at: by path when it has one, otherwise by name, preferring the note
+// in the same folder and then the one closest to the top of the vault
+func (v *vault) resolveNote(from, target string) (string, bool) {
+	target = strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(target, ".md"), "/"))
+	if strings.Contains(target, "/") {
+		if key, ok := v.notePaths[strings.ToLower(path.Join(from, target))]; ok {
+			return key, true
+		}
+		key, ok := v.notePaths[path.Clean(target)]
+		return key, ok
+	}
+	var candidates []string
+	for _, key := range v.noteNames[target] {
+		candidates = append(candidates, strings.TrimSuffix(v.notes[key].Name, path.Ext(v.notes[key].Name)))
+	}
+	best, ok := closest(from, candidates)
+	if !ok {
+		return "", false
+	}
+	return v.notePaths[strings.ToLower(best)], true
+}
+
+// resolveFile returns the path in the vault of the file a link points at
+func (v *vault) resolveFile(from, target string) (string, bool) {
+	target = strings.TrimPrefix(target, "/")
+	if strings.Contains(target, "/") {
+		for _, candidate := range []string{path.Join(from, target), path.Clean(target)} {
+			if _, ok := v.files[candidate]; ok {
+				return candidate, true
+			}
+		}
+		return "", false
+	}
+	return closest(from, v.fileNames[strings.ToLower(target)])
+}
+
+// closest picks the candidate in the folder from, or else the one with the
+// shortest path
+func closest(from string, candidates []string) (string, bool) {
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sorted := append([]string{}, candidates...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (path.Dir(a) == from) != (path.Dir(b) == from) {
+			return path.Dir(a) == from
+		}
+		if strings.Count(a, "/") != strings.Count(b, "/") {
+			return strings.Count(a, "/") < strings.Count(b, "/")
+		}
+		return a < b
+	})
+	return sorted[0], true
+}
+
+// isHiddenPath reports whether a file is in a hidden folder, such as the
+// .obsidian settings or the .trash of a vault
+func isHiddenPath(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func orName(alias, name string) string {
+	if alias != "" {
+		return alias
+	}
+	return name
+}
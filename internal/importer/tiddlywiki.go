@@ -0,0 +1,445 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Stores of the tiddlers in a TiddlyWiki file: the JSON of TiddlyWiki 5.2
+// and later, and the HTML store area of earlier versions
+var (
+	tiddlerStoreRegex = regexp.MustCompile(`(?s)<script class="tiddlywiki-tiddler-store" type="application/json">(.*?)</script>`)
+	tiddlerDivRegex   = regexp.MustCompile(`(?s)<div\s+([^>]*\btitle="[^"]*"[^>]*)>\s*<pre>(.*?)</pre>\s*</div>`)
+	tiddlerAttrRegex  = regexp.MustCompile(`([\w.:-]+)="([^"]*)"`)
+)
+
+// Syntax of TiddlyWiki wikitext
+var (
+	tiddlyCodeBlockRegex    = regexp.MustCompile("(?ms)^```.*?^```[ \\t]*$")
+	tiddlyCodeRegex         = regexp.MustCompile("``.+?``|`[^`\\n]+`")
+	tiddlyImageRegex        = regexp.MustCompile(`\[img((?:\s+[\w-]+=(?:"[^"]*"|'[^']*'|[^\s\[]+))*)\s*\[([^\]|]*)(?:\|([^\]]*))?\]\]`)
+	tiddlyWidthRegex        = regexp.MustCompile(`width=["']?(\d+)`)
+	tiddlyExtLinkRegex      = regexp.MustCompile(`\[ext\[([^\]|]*)(?:\|([^\]]*))?\]\]`)
+	tiddlyLinkRegex         = regexp.MustCompile(`\[\[([^\]|]*)(?:\|([^\]]*))?\]\]`)
+	tiddlyTransclusionRegex = regexp.MustCompile(`\{\{([^{}|!]+)\}\}`)
+	tiddlyURLRegex          = regexp.MustCompile(`\b(?:https?|ftp)://[^\s<>\[\]{}"|]+`)
+	tiddlyHeadingRegex      = regexp.MustCompile(`^(!{1,6})\s*(.*)$`)
+	tiddlyListRegex         = regexp.MustCompile(`^([*#;:>]+)\s*(.*)$`)
+	tiddlyTableRowRegex     = regexp.MustCompile(`^\|(.*)\|([hkcf]?)\s*$`)
+	tiddlyBoldRegex         = regexp.MustCompile(`''(.+?)''`)
+	tiddlyItalicRegex       = regexp.MustCompile(`//(.+?)//`)
+	tiddlyUnderlineRegex    = regexp.MustCompile(`__(.+?)__`)
+	tiddlySuperRegex        = regexp.MustCompile(`\^\^(.+?)\^\^`)
+	tiddlySubscriptRegex    = regexp.MustCompile(`,,(.+?),,`)
+	tiddlyBlockQuoteRegex   = regexp.MustCompile(`^<<<(.*)$`)
+)
+
+// tiddlerExtensions are the extensions of the files in binary tiddlers
+var tiddlerExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/svg+xml":   ".svg",
+	"image/x-icon":    ".ico",
+	"application/pdf": ".pdf",
+}
+
+// tiddler holds the fields of a tiddler
+type tiddler map[string]string
+
+// TiddlyWiki converts a TiddlyWiki saved as a single HTML file, or tiddlers
+// exported as JSON or .tid files. Every tiddler becomes a page titled like it,
+// with / in titles making subpages, its tags go to the frontmatter and the
+// wikitext becomes markdown. Image tiddlers are attached to the first page
+// showing them. System tiddlers and drafts are left out.
+func TiddlyWiki(files []File) (*Site, error) {
+	var tiddlers []tiddler
+	for _, file := range files {
+		found, err := readTiddlers(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		tiddlers = append(tiddlers, found...)
+	}
+
+	pages := make(map[string]tiddler) // Page key, the title split at /, to tiddler
+	binaries := make(map[string]tiddler)
+	site := &Site{}
+	for _, t := range tiddlers {
+		title := t["title"]
+		if title == "" || strings.HasPrefix(title, "$:/") || t["draft.of"] != "" {
+			continue
+		}
+		switch kind := t["type"]; {
+		case kind == "" || kind == "text/vnd.tiddlywiki" || kind == "text/x-tiddlywiki" ||
+			kind == "text/x-markdown" || kind == "text/markdown" || kind == "text/plain":
+			key := tiddlerKey(title)
+			if _, taken := pages[key]; taken {
+				site.Warnings = append(site.Warnings, title+": another tiddler has the same path")
+				continue
+			}
+			pages[key] = t
+		case tiddlerExtensions[kind] != "" || t["_canonical_uri"] != "":
+			binaries[title] = t
+		default:
+			site.Warnings = append(site.Warnings, fmt.Sprintf("%s: tiddlers of type %s are not imported", title, kind))
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no tiddlers found in the file")
+	}
+
+	var keys []string
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	paths := newPaths(parentKey, func(key string) (string, string) {
+		return path.Base(key), ""
+	})
+	paths.assignAll(keys)
+
+	pageURL := func(title string) string {
+		key := tiddlerKey(title)
+		if _, ok := pages[key]; ok {
+			return "/" + paths.of(key)
+		}
+		// Links to missing tiddlers point at where the page would be created
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			segments[i] = Slug(segment)
+		}
+		return "/" + strings.Join(segments, "/")
+	}
+
+	attached := make(map[string]string)
+	names := make(map[string]bool)
+	attach := func(title, page string) (string, bool) {
+		t, ok := binaries[title]
+		if !ok {
+			return "", false
+		}
+		if uri := t["_canonical_uri"]; uri != "" {
+			return uri, true
+		}
+		if attachmentURL, ok := attached[title]; ok {
+			return attachmentURL, true
+		}
+		data := []byte(t["text"])
+		if t["type"] != "image/svg+xml" {
+			decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(t["text"]), ""))
+			if err != nil {
+				return "", false
+			}
+			data = decoded
+		}
+		name := AttachmentName(title)
+		if ext := tiddlerExtensions[t["type"]]; !strings.EqualFold(path.Ext(name), ext) {
+			name += ext
+		}
+		name = uniqueName(names, page, name)
+		attached[title] = AttachmentURL(page, name)
+		site.Attachments = append(site.Attachments, Attachment{Page: page, Name: name, Source: title, Data: data})
+		return attached[title], true
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		t := pages[key]
+		page := paths.of(key)
+		content := t["text"]
+		switch t["type"] {
+		case "text/plain":
+			content = "```\n" + strings.TrimRight(content, "\n") + "\n```\n"
+		case "", "text/vnd.tiddlywiki", "text/x-tiddlywiki":
+			content = convertTiddlyWiki(content, pageURL, func(title string) (string, bool) {
+				return attach(title, page)
+			})
+		}
+		content = "# " + path.Base(key) + "\n\n" + strings.TrimSpace(content) + "\n"
+		site.Pages = append(site.Pages, Page{Path: page, Source: t["title"], Content: withTags(content, tiddlerList(t["tags"]))})
+	}
+
+	var unused []string
+	for title, t := range binaries {
+		if _, ok := attached[title]; !ok && t["_canonical_uri"] == "" {
+			unused = append(unused, title)
+		}
+	}
+	sort.Strings(unused)
+	for _, title := range unused {
+		site.Warnings = append(site.Warnings, title+": not shown by any tiddler")
+	}
+
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].Path < site.Pages[j].Path })
+	return site, nil
+}
+
+// readTiddlers reads the tiddlers of a TiddlyWiki file, a JSON export or a
+// .tid file. Other files have none.
+func readTiddlers(file File) ([]tiddler, error) {
+	switch strings.ToLower(path.Ext(file.Name)) {
+	case ".json":
+		return parseTiddlerJSON(file.Data)
+	case ".tid":
+		return []tiddler{parseTid(string(file.Data))}, nil
+	case ".html", ".htm":
+	default:
+		return nil, nil
+	}
+
+	content := string(file.Data)
+	var tiddlers []tiddler
+	for _, m := range tiddlerStoreRegex.FindAllStringSubmatch(content, -1) {
+		found, err := parseTiddlerJSON([]byte(m[1]))
+		if err != nil {
+			return nil, err
+		}
+		tiddlers = append(tiddlers, found...)
+	}
+	if i := strings.Index(content, `id="storeArea"`); i >= 0 {
+		for _, m := range tiddlerDivRegex.FindAllStringSubmatch(content[i:], -1) {
+			t := tiddler{"text": html.UnescapeString(m[2])}
+			for _, attr := range tiddlerAttrRegex.FindAllStringSubmatch(m[1], -1) {
+				t[attr[1]] = html.UnescapeString(attr[2])
+			}
+			tiddlers = append(tiddlers, t)
+		}
+	}
+	if len(tiddlers) == 0 {
+		return nil, fmt.Errorf("not a TiddlyWiki file")
+	}
+	return tiddlers, nil
+}
+
+// parseTiddlerJSON reads an array of tiddlers, whose fields are strings or,
+// in some exports, lists
+func parseTiddlerJSON(data []byte) ([]tiddler, error) {
+	var raw []map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid tiddler JSON: %w", err)
+	}
+	tiddlers := make([]tiddler, 0, len(raw))
+	for _, fields := range raw {
+		t := make(tiddler)
+		for name, value := range fields {
+			switch v := value.(type) {
+			case string:
+				t[name] = v
+			case []any:
+				var items []string
+				for _, item := range v {
+					items = append(items, "[["+fmt.Sprint(item)+"]]")
+				}
+				t[name] = strings.Join(items, " ")
+			default:
+				t[name] = fmt.Sprint(v)
+			}
+		}
+		tiddlers = append(tiddlers, t)
+	}
+	return tiddlers, nil
+}
+
+// parseTid reads a .tid file: fields as "name: value" lines, a blank line and
+// the text
+func parseTid(content string) tiddler {
+	t := make(tiddler)
+	header, text, _ := strings.Cut(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n")
+	for _, line := range strings.Split(header, "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			t[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	t["text"] = text
+	return t
+}
+
+// tiddlerKey turns a title into a page key, titles with / making subpages
+func tiddlerKey(title string) string {
+	var segments []string
+	for _, segment := range strings.Split(title, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// tiddlerList splits a TiddlyWiki list such as the tags "[[Multi word]] one two"
+func tiddlerList(list string) []string {
+	var items []string
+	for list = strings.TrimSpace(list); list != ""; list = strings.TrimSpace(list) {
+		if strings.HasPrefix(list, "[[") {
+			item, rest, found := strings.Cut(list[2:], "]]")
+			items = append(items, item)
+			if !found {
+				break
+			}
+			list = rest
+			continue
+		}
+		item, rest, _ := strings.Cut(list, " ")
+		items = append(items, item)
+		list = rest
+	}
+	return items
+}
+
+// convertTiddlyWiki converts wikitext to markdown. pageURL returns the address
+// of the page of a tiddler, file the address of a binary tiddler and false
+// for other tiddlers.
+func convertTiddlyWiki(text string, pageURL func(title string) string, file func(title string) (string, bool)) string {
+	p := &placeholders{}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	text = tiddlyCodeBlockRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return p.add(match, match)
+	})
+	text = tiddlyCodeRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if strings.HasPrefix(match, "``") {
+			return p.add("`"+strings.Trim(match, "`")+"`", match)
+		}
+		return p.add(match, match)
+	})
+
+	image := func(source, alt, width string) string {
+		imageURL := source
+		if fileURL, ok := file(source); ok {
+			imageURL = fileURL
+		}
+		if width != "" {
+			return `<img src="` + html.EscapeString(imageURL) + `" alt="` + html.EscapeString(alt) + `" width="` + width + `">`
+		}
+		return "![" + escapeLinkText(alt) + "](" + imageURL + ")"
+	}
+	text = tiddlyImageRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := tiddlyImageRegex.FindStringSubmatch(match)
+		alt, source := "", m[2]
+		if m[3] != "" {
+			alt, source = m[2], m[3]
+		}
+		width := ""
+		if w := tiddlyWidthRegex.FindStringSubmatch(m[1]); w != nil {
+			width = w[1]
+		}
+		return p.add(image(strings.TrimSpace(source), alt, width), match)
+	})
+	text = tiddlyExtLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := tiddlyExtLinkRegex.FindStringSubmatch(match)
+		label, target := m[1], m[1]
+		if m[2] != "" {
+			target = m[2]
+		}
+		return p.add("["+escapeLinkText(label)+"]("+strings.TrimSpace(target)+")", match)
+	})
+	text = tiddlyLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := tiddlyLinkRegex.FindStringSubmatch(match)
+		label, target := m[1], m[1]
+		if m[2] != "" {
+			target = strings.TrimSpace(m[2])
+		}
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") {
+			return p.add("["+escapeLinkText(label)+"]("+target+")", match)
+		}
+		return p.add("["+escapeLinkText(label)+"]("+pageURL(target)+")", match)
+	})
+	text = tiddlyTransclusionRegex.ReplaceAllStringFunc(text, func(match string) string {
+		title := strings.TrimSpace(tiddlyTransclusionRegex.FindStringSubmatch(match)[1])
+		if _, ok := file(title); ok {
+			return p.add(image(title, title, ""), match)
+		}
+		return p.add("["+escapeLinkText(title)+"]("+pageURL(title)+")", match)
+	})
+	text = tiddlyURLRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return p.add(match, match)
+	})
+
+	format := func(s string) string {
+		s = tiddlyBoldRegex.ReplaceAllString(s, "**$1**")
+		s = tiddlyItalicRegex.ReplaceAllString(s, "*$1*")
+		s = tiddlyUnderlineRegex.ReplaceAllString(s, "<u>$1</u>")
+		s = tiddlySuperRegex.ReplaceAllString(s, "<sup>$1</sup>")
+		return tiddlySubscriptRegex.ReplaceAllString(s, "<sub>$1</sub>")
+	}
+
+	lines := strings.Split(text, "\n")
+	var out []string
+	inQuote, inList := false, false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		wasList := inList
+		inList = tiddlyListRegex.MatchString(line)
+		if m := tiddlyBlockQuoteRegex.FindStringSubmatch(line); m != nil {
+			// <<< opens and closes a block quote, the closing one may name the source
+			if inQuote && strings.TrimSpace(m[1]) != "" {
+				out = append(out, ">", "> — "+format(strings.TrimSpace(m[1])))
+			}
+			inQuote = !inQuote
+			if !inQuote {
+				out = append(out, "")
+			}
+			continue
+		}
+		prefix := ""
+		if inQuote {
+			prefix = "> "
+		}
+		switch {
+		case tiddlyHeadingRegex.MatchString(line):
+			m := tiddlyHeadingRegex.FindStringSubmatch(line)
+			out = append(out, prefix+strings.Repeat("#", len(m[1]))+" "+format(m[2]))
+		case tiddlyTableRowRegex.MatchString(line):
+			var rows [][]string
+			for ; i < len(lines) && tiddlyTableRowRegex.MatchString(lines[i]); i++ {
+				m := tiddlyTableRowRegex.FindStringSubmatch(lines[i])
+				if m[2] == "k" || m[2] == "c" {
+					continue
+				}
+				cells := strings.Split(m[1], "|")
+				for j, cell := range cells {
+					cells[j] = format(strings.TrimPrefix(strings.TrimSpace(cell), "!"))
+				}
+				rows = append(rows, cells)
+			}
+			i--
+			if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+				out = append(out, "")
+			}
+			out = append(out, strings.TrimRight(markdownTable(padRows(rows)), "\n"), "")
+		case tiddlyListRegex.MatchString(line):
+			m := tiddlyListRegex.FindStringSubmatch(line)
+			markers, content := m[1], format(m[2])
+			switch last := markers[len(markers)-1]; {
+			case strings.Trim(markers, ">") == "":
+				out = append(out, prefix+strings.Repeat("> ", len(markers))+content)
+			case last == ';':
+				out = append(out, prefix+content)
+			case last == ':':
+				out = append(out, prefix+": "+content)
+			default:
+				marker := "- "
+				if last == '#' {
+					marker = "1. "
+				}
+				out = append(out, prefix+strings.Repeat("    ", len(markers)-1)+marker+content)
+			}
+		case line == "":
+			out = append(out, strings.TrimSpace(prefix))
+		default:
+			if wasList {
+				// Markdown would continue the list above with the paragraph
+				out = append(out, strings.TrimSpace(prefix))
+			}
+			out = append(out, prefix+format(line))
+		}
+	}
+
+	return p.restore(strings.Join(out, "\n"))
+}
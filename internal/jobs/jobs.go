@@ -0,0 +1,540 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"wiki-go/internal/diagnostics"
+	"wiki-go/internal/store"
+)
+
+// Job statuses
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// fileName is stored in the wiki root directory
+const fileName = "jobs.json"
+
+// keepDone is how long finished jobs stay listed
+const keepDone = 24 * time.Hour
+
+// Backoff limits for retries, doubling after each failed attempt
+const (
+	firstRetry = 30 * time.Second
+	maxRetry   = time.Hour
+)
+
+// lease is how long a job stays claimed by an instance serving the same data
+// directory after it last reported running it. Jobs of instances that stopped
+// are run again by another one when their lease expired.
+var lease = 2 * time.Minute
+
+// Job is a unit of background work
+type Job struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	RunAt     time.Time       `json:"runAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Done      int             `json:"done,omitempty"`   // Steps completed, for jobs that report progress
+	Total     int             `json:"total,omitempty"`  // Steps of the job, 0 when unknown
+	Result    json.RawMessage `json:"result,omitempty"` // Report of the last run
+}
+
+// Handler runs a job of one type. Returning an error schedules a retry.
+type Handler func(payload json.RawMessage) error
+
+// ReportingHandler runs a job that publishes its progress and result
+type ReportingHandler func(payload json.RawMessage, report Reporter) error
+
+// Reporter updates the progress and result of a running job
+type Reporter struct {
+	id string
+}
+
+var (
+	mu          sync.Mutex
+	handlers    = make(map[string]ReportingHandler)
+	jobs        = make(map[string]*Job)
+	path        string
+	maxAttempts = 5
+	wake        = make(chan struct{}, 1)
+	started     bool
+	running     = make(map[string]bool) // Jobs run by this instance
+	generation  int64                   // Generation of the jobs last loaded or saved
+)
+
+func init() {
+	diagnostics.Register("jobs", func() (int, int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		size := int64(0)
+		for _, job := range jobs {
+			size += int64(len(job.ID) + len(job.Type) + len(job.Payload) + len(job.Status) + len(job.LastError) + len(job.Result) + 128)
+		}
+		return len(jobs), size
+	})
+}
+
+// Register sets the handler of a job type. Handlers are registered before Start.
+func Register(jobType string, handler Handler) {
+	RegisterReporting(jobType, func(payload json.RawMessage, _ Reporter) error {
+		return handler(payload)
+	})
+}
+
+// RegisterReporting sets the handler of a job type that reports its progress
+func RegisterReporting(jobType string, handler ReportingHandler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Progress records that done of total steps are completed. It is kept in
+// memory only, the job is saved when it finishes or its lease is renewed.
+func (r Reporter) Progress(done, total int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if job, ok := jobs[r.id]; ok {
+		job.Done = done
+		job.Total = total
+		job.UpdatedAt = time.Now()
+	}
+}
+
+// Result stores the JSON encoded report of the job
+func (r Reporter) Result(result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if job, ok := jobs[r.id]; ok {
+		job.Result = data
+	}
+	return nil
+}
+
+// Start loads the persisted jobs from rootDir and runs them with the given number
+// of workers. Jobs that were running when the wiki stopped are run again, at
+// once or, when other instances share the queue, after their lease expired.
+func Start(rootDir string, workers, attempts int) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if started {
+		return errors.New("job queue already started")
+	}
+
+	path = filepath.Join(rootDir, fileName)
+	if attempts > 0 {
+		maxAttempts = attempts
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if store.Shared() {
+		generation, _ = store.Current.Generation(fileName)
+	}
+	loaded, err := load()
+	if err != nil {
+		return err
+	}
+	for id, job := range loaded {
+		if job.Status == StatusRunning && !store.Shared() {
+			job.Status = StatusPending
+		}
+		jobs[id] = job
+	}
+
+	started = true
+	for i := 0; i < workers; i++ {
+		go work()
+	}
+	return nil
+}
+
+// Enqueue adds a job with a JSON encoded payload and returns its ID
+func Enqueue(jobType string, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:        fmt.Sprintf("%d", now.UnixNano()),
+		Type:      jobType,
+		Payload:   data,
+		Status:    StatusPending,
+		CreatedAt: now,
+		RunAt:     now,
+		UpdatedAt: now,
+	}
+
+	unlock := lock()
+	for jobs[job.ID] != nil {
+		job.ID += "0"
+	}
+	jobs[job.ID] = job
+	err = save()
+	unlock()
+
+	notify()
+	return job.ID, err
+}
+
+// List returns all jobs, newest first
+func List() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	refresh()
+
+	list := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Get returns a copy of the job with the given ID
+func Get(id string) (Job, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	refresh()
+	job, ok := jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Retry runs a failed job again with a fresh set of attempts
+func Retry(id string) error {
+	unlock := lock()
+	job, ok := jobs[id]
+	if !ok {
+		unlock()
+		return errors.New("job not found")
+	}
+	if job.Status != StatusFailed {
+		unlock()
+		return errors.New("only failed jobs can be retried")
+	}
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	job.UpdatedAt = job.RunAt
+	err := save()
+	unlock()
+
+	notify()
+	return err
+}
+
+// Delete removes a job that isn't running
+func Delete(id string) error {
+	unlock := lock()
+	defer unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return errors.New("job not found")
+	}
+	if job.Status == StatusRunning {
+		return errors.New("running jobs can't be deleted")
+	}
+	delete(jobs, id)
+	return save()
+}
+
+// notify wakes a worker without blocking when all of them are busy
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs due jobs until the process exits
+func work() {
+	for {
+		job, handler := claim()
+		if job == nil {
+			select {
+			case <-wake:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		stop := renew(job.ID)
+		err := run(handler, job.ID, job.Payload)
+		stop()
+		finish(job, err)
+	}
+}
+
+// claim marks the oldest due pending job as running and returns a copy of it
+func claim() (*Job, ReportingHandler) {
+	// Look for work before waiting for the other instances sharing the queue
+	mu.Lock()
+	refresh()
+	idle := nextJob(time.Now()) == nil && !expired(time.Now())
+	mu.Unlock()
+	if idle {
+		return nil, nil
+	}
+
+	unlock := lock()
+	defer unlock()
+
+	now := time.Now()
+	next := nextJob(now)
+	if next == nil {
+		pruneDone(now)
+		return nil, nil
+	}
+
+	next.Status = StatusRunning
+	next.Attempts++
+	next.Done = 0
+	next.UpdatedAt = now
+	running[next.ID] = true
+	if err := save(); err != nil {
+		log.Printf("Error saving jobs: %v", err)
+	}
+	claimed := *next
+	return &claimed, handlers[next.Type]
+}
+
+// nextJob returns the oldest due pending job, or a job run by an instance
+// whose lease expired. The caller holds mu.
+func nextJob(now time.Time) *Job {
+	var next *Job
+	for _, job := range jobs {
+		due := job.Status == StatusPending && !job.RunAt.After(now)
+		abandoned := job.Status == StatusRunning && !running[job.ID] && now.Sub(job.UpdatedAt) > lease
+		if (due || abandoned) && (next == nil || job.RunAt.Before(next.RunAt)) {
+			next = job
+		}
+	}
+	return next
+}
+
+// renew keeps the lease of a job run by this instance until the returned
+// function is called. Only instances sharing the queue need it.
+func renew(id string) func() {
+	if !store.Shared() {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			unlock := lock()
+			if job, ok := jobs[id]; ok && job.Status == StatusRunning {
+				job.UpdatedAt = time.Now()
+				if err := save(); err != nil {
+					log.Printf("Error saving jobs: %v", err)
+				}
+			}
+			unlock()
+		}
+	}()
+	return func() { close(done) }
+}
+
+// run calls the handler, turning a panic into an error
+func run(handler ReportingHandler, id string, payload json.RawMessage) (err error) {
+	if handler == nil {
+		return errors.New("no handler for this job type")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(payload, Reporter{id: id})
+}
+
+// finish records the result of a job and schedules a retry after a failure
+func finish(claimed *Job, err error) {
+	unlock := lock()
+	defer unlock()
+
+	delete(running, claimed.ID)
+	job, ok := jobs[claimed.ID]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	job.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		job.Status = StatusDone
+		job.LastError = ""
+	case job.Attempts >= maxAttempts:
+		job.Status = StatusFailed
+		job.LastError = err.Error()
+		log.Printf("Job %s (%s) failed after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+	default:
+		delay := firstRetry << (job.Attempts - 1)
+		if delay > maxRetry || delay <= 0 {
+			delay = maxRetry
+		}
+		job.Status = StatusPending
+		job.LastError = err.Error()
+		job.RunAt = now.Add(delay)
+	}
+
+	if err := save(); err != nil {
+		log.Printf("Error saving jobs: %v", err)
+	}
+}
+
+// expired reports whether finished jobs older than keepDone are listed. The
+// caller holds mu.
+func expired(now time.Time) bool {
+	for _, job := range jobs {
+		if job.Status == StatusDone && now.Sub(job.UpdatedAt) > keepDone {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneDone drops finished jobs older than keepDone. The caller holds the lock.
+func pruneDone(now time.Time) {
+	if !expired(now) {
+		return
+	}
+	for id, job := range jobs {
+		if job.Status == StatusDone && now.Sub(job.UpdatedAt) > keepDone {
+			delete(jobs, id)
+		}
+	}
+	if err := save(); err != nil {
+		log.Printf("Error saving jobs: %v", err)
+	}
+}
+
+// lock must be held while the jobs are changed and saved. When other
+// instances share the queue, it also waits for their changes and loads them.
+func lock() func() {
+	mu.Lock()
+	if path == "" || !store.Shared() {
+		return mu.Unlock
+	}
+	unlock, err := store.Current.Lock(fileName)
+	if err != nil {
+		// Changes are still serialized on this instance
+		log.Printf("Warning: failed to lock the jobs: %v", err)
+		return mu.Unlock
+	}
+	refresh()
+	return func() {
+		unlock()
+		mu.Unlock()
+	}
+}
+
+// refresh loads the jobs saved by other instances sharing the queue since
+// they were last loaded. The progress of the jobs run by this instance, kept
+// in memory, is kept. The caller holds mu.
+func refresh() {
+	if path == "" || !store.Shared() {
+		return
+	}
+	current, err := store.Current.Generation(fileName)
+	if err != nil || current == generation {
+		return
+	}
+	loaded, err := load()
+	if err != nil {
+		log.Printf("Error loading jobs: %v", err)
+		return
+	}
+	for id, job := range loaded {
+		if old, ok := jobs[id]; ok && running[id] && job.Status == StatusRunning {
+			job.Done, job.Total, job.Result = old.Done, old.Total, old.Result
+			if old.UpdatedAt.After(job.UpdatedAt) {
+				job.UpdatedAt = old.UpdatedAt
+			}
+		}
+	}
+	jobs = loaded
+	generation = current
+}
+
+// load reads the saved jobs
+func load() (map[string]*Job, error) {
+	loaded := make(map[string]*Job)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return loaded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*Job
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", fileName, err)
+	}
+	for _, job := range list {
+		loaded[job.ID] = job
+	}
+	return loaded, nil
+}
+
+// save writes all jobs to disk. The caller holds the lock. Before Start the
+// queue only lives in memory.
+func save() error {
+	if path == "" {
+		return nil
+	}
+	list := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := store.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if store.Shared() {
+		if g, err := store.Current.Bump(fileName); err == nil {
+			generation = g
+		} else {
+			log.Printf("Warning: failed to record the change of the jobs: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// shareQueue saves the queue in a temporary directory shared with other
+// instances through a store that counts as shared: Redis, falling back to
+// memory as nothing listens on its port
+func shareQueue(t *testing.T) {
+	t.Helper()
+	redis, err := store.OpenRedis("redis://127.0.0.1:1/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := store.Current
+	store.Current = redis
+
+	mu.Lock()
+	path = filepath.Join(t.TempDir(), fileName)
+	jobs = make(map[string]*Job)
+	running = make(map[string]bool)
+	generation = 0
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		store.Current = previous
+		redis.Close()
+		mu.Lock()
+		path = ""
+		jobs = make(map[string]*Job)
+		running = make(map[string]bool)
+		mu.Unlock()
+	})
+}
+
+// changeElsewhere changes the saved jobs the way another instance does
+func changeElsewhere(t *testing.T, change func(list []*Job) []*Job) {
+	t.Helper()
+	unlock, err := store.Current.Lock(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	var list []*Job
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(change(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Current.Bump(fileName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedQueue(t *testing.T) {
+	shareQueue(t)
+
+	id, err := Enqueue("test", map[string]string{"page": "guide"})
+	if err != nil {
+		t.Fatalf("Enqueue() = %v", err)
+	}
+
+	// Jobs queued by another instance are listed
+	changeElsewhere(t, func(list []*Job) []*Job {
+		now := time.Now()
+		return append(list, &Job{ID: "elsewhere", Type: "test", Status: StatusPending, CreatedAt: now, RunAt: now.Add(time.Hour), UpdatedAt: now})
+	})
+	if got := List(); len(got) != 2 {
+		t.Fatalf("List() = %d jobs, want the 2 queued by both instances", len(got))
+	}
+
+	// A job another instance runs isn't claimed while its lease holds
+	changeElsewhere(t, func(list []*Job) []*Job {
+		for _, job := range list {
+			if job.ID == id {
+				job.Status, job.Attempts, job.UpdatedAt = StatusRunning, 1, time.Now()
+			}
+		}
+		return list
+	})
+	if job, _ := claim(); job != nil {
+		t.Fatalf("claim() = %s, running on another instance", job.ID)
+	}
+
+	// Once the lease expired, the instance is taken as stopped
+	changeElsewhere(t, func(list []*Job) []*Job {
+		for _, job := range list {
+			if job.ID == id {
+				job.UpdatedAt = time.Now().Add(-2 * lease)
+			}
+		}
+		return list
+	})
+	job, _ := claim()
+	if job == nil || job.ID != id || job.Attempts != 2 {
+		t.Fatalf("claim() = %+v, want job %s on its second attempt", job, id)
+	}
+	if again, _ := claim(); again != nil {
+		t.Fatalf("claim() = %s, the job is already run by this instance", again.ID)
+	}
+
+	// Progress is kept while other instances save the queue
+	Reporter{id: id}.Progress(1, 3)
+	changeElsewhere(t, func(list []*Job) []*Job { return list })
+	if got, _ := Get(id); got.Done != 1 || got.Total != 3 {
+		t.Errorf("progress = %d/%d after another instance saved, want 1/3", got.Done, got.Total)
+	}
+
+	finish(job, nil)
+	var saved []*Job
+	data, _ := os.ReadFile(path)
+	json.Unmarshal(data, &saved)
+	for _, job := range saved {
+		if job.ID == id && job.Status != StatusDone {
+			t.Errorf("saved status = %q, want %q", job.Status, StatusDone)
+		}
+		if job.ID == "elsewhere" && job.Status != StatusPending {
+			t.Errorf("job of the other instance = %q, want it kept pending", job.Status)
+		}
+	}
+}
+
+func TestLocalQueueReloadsNothing(t *testing.T) {
+	mu.Lock()
+	path = filepath.Join(t.TempDir(), fileName)
+	jobs = make(map[string]*Job)
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		path = ""
+		jobs = make(map[string]*Job)
+		mu.Unlock()
+	})
+
+	id, err := Enqueue("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Get(id); !ok {
+		t.Error("Get() reloaded the jobs, though no other instance shares them")
+	}
+}
@@ -0,0 +1,215 @@
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Modes of the linter, set with wiki.lint
+const (
+	ModeOff   = "off"   // Pages are not checked
+	ModeWarn  = "warn"  // Problems are shown in the editor
+	ModeBlock = "block" // Pages with problems can't be saved
+)
+
+// Rules reported by Check
+const (
+	RuleHeadingIncrement   = "heading-increment"
+	RuleBareURL            = "bare-url"
+	RuleTrailingWhitespace = "trailing-whitespace"
+	RuleBrokenReference    = "broken-reference"
+	RuleMissingAttachment  = "missing-attachment"
+	RuleBrokenSectionRef   = "broken-section-ref"
+)
+
+// Issue is a problem found in a page
+type Issue struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"` // 1-based line in the markdown source
+	Message string `json:"message"`
+}
+
+// ValidMode reports whether mode is a known linter mode
+func ValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeWarn || mode == ModeBlock
+}
+
+var (
+	fenceRegex      = regexp.MustCompile("^\\s{0,3}(`{3,}|~{3,})")
+	headingRegex    = regexp.MustCompile(`^\s{0,3}(#{1,6})(\s|$)`)
+	inlineCodeRegex = regexp.MustCompile("`+[^`]*`+")
+	urlRegex        = regexp.MustCompile(`https?://[^\s<>()\[\]"']+`)
+	definitionRegex = regexp.MustCompile(`^\s{0,3}\[([^\]]+)\]:\s*\S`)
+	fullRefRegex    = regexp.MustCompile(`\[([^\]]*)\]\[([^\]]*)\]`)
+	footnoteRegex   = regexp.MustCompile(`\[\^([^\]]+)\]`)
+	inlineLinkRegex = regexp.MustCompile(`(!?)\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+)
+
+// line is a line of the page outside frontmatter and code blocks
+type line struct {
+	number int
+	text   string // Inline code is blanked out
+}
+
+// contentLines returns the lines that hold markdown, leaving out frontmatter
+// and code blocks, whose content isn't formatted
+func contentLines(markdown string) []line {
+	source := strings.Split(markdown, "\n")
+	var lines []line
+	fence := ""
+	start := 0
+
+	if len(source) > 0 && strings.TrimSpace(source[0]) == "---" {
+		for i := 1; i < len(source); i++ {
+			if strings.TrimSpace(source[i]) == "---" {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	for i := start; i < len(source); i++ {
+		text := source[i]
+		if match := fenceRegex.FindStringSubmatch(text); match != nil {
+			switch {
+			case fence == "":
+				fence = match[1]
+			case strings.HasPrefix(match[1], fence):
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		// Keep positions, so columns still match the source
+		text = inlineCodeRegex.ReplaceAllStringFunc(text, func(code string) string {
+			return strings.Repeat(" ", len(code))
+		})
+		lines = append(lines, line{number: i + 1, text: text})
+	}
+	return lines
+}
+
+// Check returns the problems found in markdown. attachmentExists reports
+// whether a relative link target is a file attached to the page; when nil,
+// attachments are not checked.
+func Check(markdown string, attachmentExists func(name string) bool) []Issue {
+	issues := []Issue{}
+	lines := contentLines(markdown)
+
+	// Trailing whitespace is checked on every line, code included
+	for i, text := range strings.Split(markdown, "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		if text != strings.TrimRight(text, " \t") {
+			issues = append(issues, Issue{RuleTrailingWhitespace, i + 1, "Trailing whitespace"})
+		}
+	}
+
+	definitions := map[string]bool{}
+	for _, l := range lines {
+		if match := definitionRegex.FindStringSubmatch(l.text); match != nil {
+			definitions[normalizeLabel(match[1])] = true
+		}
+	}
+
+	level := 0
+	for _, l := range lines {
+		if definitionRegex.MatchString(l.text) {
+			continue
+		}
+
+		if match := headingRegex.FindStringSubmatch(l.text); match != nil {
+			next := len(match[1])
+			if level > 0 && next > level+1 {
+				issues = append(issues, Issue{RuleHeadingIncrement, l.number,
+					fmt.Sprintf("Heading level jumps from %d to %d", level, next)})
+			}
+			level = next
+		}
+
+		for _, loc := range urlRegex.FindAllStringIndex(l.text, -1) {
+			if !linkedURL(l.text, loc[0]) {
+				link := l.text[loc[0]:loc[1]]
+				issues = append(issues, Issue{RuleBareURL, l.number,
+					fmt.Sprintf("Bare URL %s, write it as <%s> or as a link", link, link)})
+			}
+		}
+
+		for _, match := range fullRefRegex.FindAllStringSubmatch(l.text, -1) {
+			label := match[2]
+			if label == "" {
+				label = match[1] // Collapsed reference [label][]
+			}
+			if !definitions[normalizeLabel(label)] {
+				issues = append(issues, Issue{RuleBrokenReference, l.number,
+					fmt.Sprintf("Reference [%s] is not defined", label)})
+			}
+		}
+		for _, match := range footnoteRegex.FindAllStringSubmatch(l.text, -1) {
+			if !definitions[normalizeLabel("^"+match[1])] {
+				issues = append(issues, Issue{RuleBrokenReference, l.number,
+					fmt.Sprintf("Footnote [^%s] is not defined", match[1])})
+			}
+		}
+
+		if attachmentExists == nil {
+			continue
+		}
+		for _, match := range inlineLinkRegex.FindAllStringSubmatch(l.text, -1) {
+			name, ok := attachmentName(match[2])
+			if ok && !attachmentExists(name) {
+				issues = append(issues, Issue{RuleMissingAttachment, l.number,
+					fmt.Sprintf("Attachment %s does not exist", name)})
+			}
+		}
+	}
+
+	// Trailing whitespace was checked first, order everything by line
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
+
+// linkedURL reports whether the URL starting at index is already part of a link
+func linkedURL(text string, index int) bool {
+	if index == 0 {
+		return false
+	}
+	switch text[index-1] {
+	case '<', '(', '"', '\'', '=':
+		return true
+	case '[':
+		return strings.Contains(text[index:], "](")
+	}
+	return false
+}
+
+// attachmentName returns the file a link target refers to when it is a file
+// next to the page, rather than another page or site
+func attachmentName(target string) (string, bool) {
+	if strings.HasPrefix(target, "/") || strings.HasPrefix(target, "#") || strings.Contains(target, "://") ||
+		strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "tel:") || strings.HasPrefix(target, "data:") {
+		return "", false
+	}
+	name := target
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	// Only files in the page directory are attachments, not sibling pages
+	if name == "" || strings.Contains(name, "/") || !strings.Contains(name, ".") {
+		return "", false
+	}
+	if decoded, err := url.PathUnescape(name); err == nil {
+		name = decoded
+	}
+	return name, true
+}
+
+// normalizeLabel matches reference labels the way markdown does, ignoring case
+// and repeated whitespace
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
@@ -0,0 +1,513 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	adocAttributeRegex   = regexp.MustCompile(`^:(!?[\w-]+!?):\s*(.*)$`)
+	adocAttrRefRegex     = regexp.MustCompile(`\{([\w-]+)\}`)
+	adocBlockAttrRegex   = regexp.MustCompile(`^\[([^\[\]]*)\]$`)
+	adocAnchorLineRegex  = regexp.MustCompile(`^\[\[([\w:.-]+)(?:,[^\]]*)?\]\]$`)
+	adocTitleRegex       = regexp.MustCompile(`^(={1,6})\s+(.+?)\s*$`)
+	adocBlockTitleRegex  = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocAdmonitionRegex  = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocBlockImageRegex  = regexp.MustCompile(`^image::([^\[\s]+)\[([^\]]*)\]$`)
+	adocBulletRegex      = regexp.MustCompile(`^(\*+|-)\s+(.*)$`)
+	adocNumberedRegex    = regexp.MustCompile(`^(\.+)\s+(.*)$`)
+	adocDescriptionRegex = regexp.MustCompile(`^(.+?)(::|;;)(?:\s+(.*))?$`)
+
+	adocLinkMacroRegex = regexp.MustCompile(`link:([^\[\s]+)\[([^\]]*)\]`)
+	adocURLRegex       = regexp.MustCompile(`(^|[\s(<])((?:https?|ftp|mailto):[^\s\[\]<>]+)\[([^\]]*)\]`)
+	adocXrefMacroRegex = regexp.MustCompile(`xref:([^\[\s]+)\[([^\]]*)\]`)
+	adocXrefRegex      = regexp.MustCompile(`<<([^,>]+)(?:,\s*([^>]*))?>>`)
+	adocInlineImgRegex = regexp.MustCompile(`image:([^:\[\s][^\[\s]*)\[([^\]]*)\]`)
+	adocFootnoteRegex  = regexp.MustCompile(`footnote:(?:[\w-]*)\[([^\]]*)\]`)
+	adocInlineAnchor   = regexp.MustCompile(`\[\[([\w:.-]+)\]\]`)
+	adocStrongRegex    = regexp.MustCompile(`(^|[^\w*\\])\*([^*\s](?:[^*]*[^*\s])?)\*($|[^\w*])`)
+	adocEmphasisRegex  = regexp.MustCompile(`__([^_]+)__`)
+	adocHighlightRegex = regexp.MustCompile(`(^|[^\w#])#([^#\s](?:[^#]*[^#\s])?)#($|[^\w#])`)
+	adocLiteralRegex   = regexp.MustCompile("`\\+([^`]*)\\+`")
+)
+
+// adocDelimiters are the lines opening and closing delimited blocks
+var adocDelimiters = map[string]bool{
+	"----": true, "....": true, "++++": true, "====": true, "____": true,
+	"****": true, "|===": true, "--": true, "////": true,
+}
+
+// asciiDoc converts AsciiDoc to markdown. Document attributes and footnotes
+// are shared by the nested blocks of a document.
+type asciiDoc struct {
+	attributes map[string]string
+	footnotes  []string
+}
+
+// asciiDocToMarkdown converts an AsciiDoc document to markdown
+func asciiDocToMarkdown(source string) string {
+	doc := &asciiDoc{attributes: make(map[string]string)}
+	lines := doc.convert(strings.Split(source, "\n"))
+	if len(doc.footnotes) > 0 {
+		lines = append(lines, "")
+		for i, footnote := range doc.footnotes {
+			lines = append(lines, fmt.Sprintf("[^%d]: %s", i+1, footnote))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convert converts the lines of a document or of a delimited block
+func (d *asciiDoc) convert(lines []string) []string {
+	var out []string
+	blockAttr := ""  // Attribute line of the next block, like source,go or NOTE
+	blockTitle := "" // .Title of the next block
+	anchorID := ""   // [[id]] of the next block
+	paragraph := -1  // Line of out the next line of text continues
+
+	flushTitle := func() {
+		if blockTitle != "" {
+			out = append(out, "**"+d.inline(blockTitle)+"**", "")
+			blockTitle = ""
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		// Delimited blocks
+		if adocDelimiters[trimmed] || (strings.HasPrefix(trimmed, "----") && strings.Trim(trimmed, "-") == "") {
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != trimmed {
+				end++
+			}
+			body := lines[i+1 : min(end, len(lines))]
+			if trimmed != "////" {
+				if trimmed != "====" || !isAdmonition(blockAttr) {
+					flushTitle()
+				}
+				out = append(out, d.block(trimmed, blockAttr, &blockTitle, body)...)
+				out = append(out, "")
+			}
+			blockAttr, anchorID = "", ""
+			i = end
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			out = append(out, "")
+			blockAttr = ""
+			continue
+		case strings.HasPrefix(trimmed, "//"):
+			continue
+		case trimmed == "+":
+			// List continuations join the next block to the item
+			continue
+		}
+
+		if m := adocAttributeRegex.FindStringSubmatch(trimmed); m != nil {
+			name := strings.Trim(m[1], "!")
+			if strings.Contains(m[1], "!") {
+				delete(d.attributes, name)
+			} else {
+				d.attributes[name] = m[2]
+			}
+			continue
+		}
+		if m := adocAnchorLineRegex.FindStringSubmatch(trimmed); m != nil {
+			anchorID = m[1]
+			continue
+		}
+		if m := adocBlockAttrRegex.FindStringSubmatch(trimmed); m != nil && !strings.HasPrefix(trimmed, "[[") {
+			blockAttr = m[1]
+			if id := attrID(blockAttr); id != "" {
+				anchorID = id
+			}
+			continue
+		}
+		if m := adocBlockTitleRegex.FindStringSubmatch(trimmed); m != nil && !adocNumberedRegex.MatchString(trimmed) {
+			blockTitle = m[1]
+			continue
+		}
+
+		if m := adocTitleRegex.FindStringSubmatch(line); m != nil {
+			heading := strings.Repeat("#", len(m[1])) + " " + d.inline(m[2])
+			if anchorID != "" {
+				heading += " {#" + anchorID + "}"
+				anchorID = ""
+			}
+			out = append(out, heading, "")
+			continue
+		}
+		if anchorID != "" {
+			out = append(out, `<a id="`+anchorID+`"></a>`)
+			anchorID = ""
+		}
+
+		if trimmed == "'''" || trimmed == "---" || trimmed == "***" {
+			out = append(out, "---")
+			continue
+		}
+		if trimmed == "<<<" {
+			continue
+		}
+
+		if m := adocAdmonitionRegex.FindStringSubmatch(trimmed); m != nil {
+			// The admonition goes on to the end of its paragraph
+			body := []string{m[2]}
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+				body = append(body, strings.TrimSpace(lines[i]))
+			}
+			out = append(out, d.callout(m[1], blockTitle, d.convert(body))...)
+			blockTitle = ""
+			continue
+		}
+		if isAdmonition(blockAttr) {
+			body := []string{trimmed}
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+				body = append(body, strings.TrimSpace(lines[i]))
+			}
+			out = append(out, d.callout(blockAttr, blockTitle, d.convert(body))...)
+			blockAttr, blockTitle = "", ""
+			continue
+		}
+
+		if m := adocBlockImageRegex.FindStringSubmatch(trimmed); m != nil {
+			alt, _, _ := strings.Cut(m[2], ",")
+			if alt == "" {
+				alt = blockTitle
+			}
+			out = append(out, "!["+alt+"]("+d.attribute(m[1])+")")
+			if blockTitle != "" {
+				out = append(out, "", "*"+d.inline(blockTitle)+"*")
+				blockTitle = ""
+			}
+			continue
+		}
+
+		if strings.HasPrefix(blockAttr, "source") || blockAttr == "listing" || blockAttr == "literal" {
+			// A paragraph marked as source is code up to the next blank line
+			body := []string{line}
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+				body = append(body, lines[i])
+			}
+			flushTitle()
+			out = append(out, codeBlock(sourceLanguage(blockAttr), body)...)
+			blockAttr = ""
+			continue
+		}
+
+		flushTitle()
+		if m := adocBulletRegex.FindStringSubmatch(trimmed); m != nil {
+			depth := len(m[1])
+			if m[1] == "-" {
+				depth = 1
+			}
+			out = append(out, strings.Repeat("  ", depth-1)+"- "+d.inline(m[2]))
+			paragraph = len(out) - 1
+			continue
+		}
+		if m := adocNumberedRegex.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, strings.Repeat("   ", len(m[1])-1)+"1. "+d.inline(m[2]))
+			paragraph = len(out) - 1
+			continue
+		}
+		if m := adocDescriptionRegex.FindStringSubmatch(trimmed); m != nil && !strings.Contains(m[1], "://") && !strings.HasSuffix(m[1], ":") {
+			definition := m[3]
+			if definition == "" && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+				definition = strings.TrimSpace(lines[i])
+			}
+			out = append(out, d.inline(m[1]), ": "+d.inline(definition), "")
+			continue
+		}
+		if strings.HasPrefix(line, " ") && (len(out) == 0 || out[len(out)-1] == "") {
+			// Indented paragraphs are literal
+			body := []string{line}
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], " ") {
+				i++
+				body = append(body, lines[i])
+			}
+			out = append(out, codeBlock("", dedent(body))...)
+			continue
+		}
+
+		// Lines of a paragraph are joined, since markdown pages break lines at
+		// every newline, unless they end with " +"
+		text := d.inline(strings.TrimSuffix(trimmed, " +"))
+		if paragraph >= 0 && paragraph == len(out)-1 {
+			out[paragraph] += " " + text
+		} else {
+			out = append(out, text)
+		}
+		paragraph = len(out) - 1
+		if strings.HasSuffix(trimmed, " +") {
+			paragraph = -1
+		}
+	}
+	return out
+}
+
+// block converts a delimited block
+func (d *asciiDoc) block(delimiter, attr string, title *string, body []string) []string {
+	switch delimiter {
+	case "....":
+		return codeBlock("", body)
+	case "++++":
+		return body
+	case "|===":
+		return d.table(attr, body)
+	case "____":
+		lines := d.convert(body)
+		if parts := strings.Split(attr, ","); len(parts) > 1 && strings.TrimSpace(parts[0]) == "quote" {
+			lines = append(lines, "", "— "+strings.TrimSpace(strings.Join(parts[1:], ", ")))
+		}
+		return quote(lines)
+	case "====":
+		if isAdmonition(attr) {
+			lines := d.callout(attr, *title, d.convert(body))
+			*title = ""
+			return lines
+		}
+		return d.convert(body)
+	case "****":
+		return quote(d.convert(body))
+	case "--":
+		return d.convert(body)
+	}
+	// ---- listing blocks
+	for i, line := range body {
+		body[i] = d.attribute(line)
+	}
+	return codeBlock(sourceLanguage(attr), body)
+}
+
+// callout returns an admonition as a callout
+func (d *asciiDoc) callout(kind, title string, body []string) []string {
+	header := "[!" + calloutKind(kind) + "]"
+	if title != "" {
+		header += " " + d.inline(title)
+	}
+	return append(quote(append([]string{header}, trimBlank(body)...)), "")
+}
+
+// table converts a |=== table. Cells start with |, and rows hold as many cells
+// as the first line, or as the cols attribute gives.
+func (d *asciiDoc) table(attr string, body []string) []string {
+	var cells []string
+	columns, cellLines := 0, 0
+	header := strings.Contains(attr, "header")
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			if cellLines == 1 && len(cells) > 0 {
+				// A first line of cells followed by a blank line is the header
+				columns = len(cells)
+				header = true
+			}
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "|") {
+			// Continuation of the previous cell
+			if len(cells) > 0 {
+				cells[len(cells)-1] += " " + trimmed
+			}
+			continue
+		}
+		cellLines++
+		lineCells := strings.Split(trimmed[1:], "|")
+		if columns == 0 && len(cells) == 0 && len(lineCells) > 1 {
+			columns = len(lineCells)
+		}
+		for _, cell := range lineCells {
+			cells = append(cells, d.inline(strings.TrimSpace(cell)))
+		}
+	}
+	if cols := attrValue(attr, "cols"); cols != "" {
+		columns = len(strings.Split(cols, ","))
+	}
+	if columns == 0 {
+		columns = 1
+	}
+
+	var rows [][]string
+	for start := 0; start < len(cells); start += columns {
+		rows = append(rows, cells[start:min(start+columns, len(cells))])
+	}
+	var headerRow []string
+	if header && len(rows) > 0 {
+		headerRow, rows = rows[0], rows[1:]
+	}
+	return table(headerRow, rows, columns)
+}
+
+// inline converts the inline markup of a line of text
+func (d *asciiDoc) inline(text string) string {
+	text = d.attribute(text)
+	// `+literal+` is shown as it is written
+	text = adocLiteralRegex.ReplaceAllString(text, "`$1`")
+	return replaceOutsideCode(text, func(s string) string {
+		s = adocFootnoteRegex.ReplaceAllStringFunc(s, func(match string) string {
+			d.footnotes = append(d.footnotes, adocFootnoteRegex.FindStringSubmatch(match)[1])
+			return fmt.Sprintf("[^%d]", len(d.footnotes))
+		})
+		s = adocInlineImgRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := adocInlineImgRegex.FindStringSubmatch(match)
+			alt, _, _ := strings.Cut(m[2], ",")
+			return "![" + alt + "](" + m[1] + ")"
+		})
+		s = adocLinkMacroRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := adocLinkMacroRegex.FindStringSubmatch(match)
+			return "[" + orText(m[2], m[1]) + "](" + m[1] + ")"
+		})
+		s = adocURLRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := adocURLRegex.FindStringSubmatch(match)
+			return m[1] + "[" + orText(m[3], strings.TrimPrefix(m[2], "mailto:")) + "](" + m[2] + ")"
+		})
+		s = adocXrefMacroRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := adocXrefMacroRegex.FindStringSubmatch(match)
+			return "[" + orText(m[2], m[1]) + "](" + xrefTarget(m[1]) + ")"
+		})
+		s = adocXrefRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := adocXrefRegex.FindStringSubmatch(match)
+			target := strings.TrimSpace(m[1])
+			return "[" + orText(strings.TrimSpace(m[2]), target) + "](" + xrefTarget(target) + ")"
+		})
+		s = adocInlineAnchor.ReplaceAllString(s, `<a id="$1"></a>`)
+		// Strong comes first, or it would take the *emphasis* made of __emphasis__
+		s = replaceAll(adocStrongRegex, s, "$1**$2**$3")
+		s = adocEmphasisRegex.ReplaceAllString(s, "*$1*")
+		s = replaceAll(adocHighlightRegex, s, "$1==$2==$3")
+		return s
+	})
+}
+
+// attribute replaces the {name} references to document attributes
+func (d *asciiDoc) attribute(text string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	return adocAttrRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		name := match[1 : len(match)-1]
+		if value, ok := d.attributes[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// replaceAll replaces the matches of patterns whose surrounding characters
+// may overlap, such as *a* *b*
+func replaceAll(pattern *regexp.Regexp, s, replacement string) string {
+	for i := 0; i < 4; i++ {
+		replaced := pattern.ReplaceAllString(s, replacement)
+		if replaced == s {
+			break
+		}
+		s = replaced
+	}
+	return s
+}
+
+// xrefTarget returns the link destination of a cross-reference: a section of
+// the page, or another page imported from the same place
+func xrefTarget(target string) string {
+	file, id, hasID := strings.Cut(target, "#")
+	if !hasID && FormatOf(file) == "" {
+		return "#" + file
+	}
+	if ext := FormatOf(file); ext != "" {
+		file = strings.TrimSuffix(file, file[strings.LastIndex(file, "."):])
+	}
+	if id != "" {
+		return file + "#" + id
+	}
+	return file
+}
+
+// isAdmonition reports whether a block attribute makes an admonition
+func isAdmonition(attr string) bool {
+	switch strings.TrimSpace(strings.Split(attr, ",")[0]) {
+	case "NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION":
+		return true
+	}
+	return false
+}
+
+// sourceLanguage returns the language of a [source,go] block
+func sourceLanguage(attr string) string {
+	parts := strings.Split(attr, ",")
+	if len(parts) > 1 && strings.TrimSpace(parts[0]) == "source" {
+		return strings.TrimSpace(parts[1])
+	}
+	return ""
+}
+
+// attrID returns the id of a [#id] or [id=name] block attribute
+func attrID(attr string) string {
+	if strings.HasPrefix(attr, "#") {
+		id, _, _ := strings.Cut(attr[1:], ".")
+		id, _, _ = strings.Cut(id, "%")
+		return id
+	}
+	return attrValue(attr, "id")
+}
+
+// attrValue returns the value of a name=value block attribute
+func attrValue(attr, name string) string {
+	for _, part := range strings.Split(attr, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.TrimSpace(key) == name {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// orText returns text, or fallback when it is empty
+func orText(text, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	return text
+}
+
+// trimBlank removes the blank lines at the start and end of lines
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// dedent removes the indentation lines share
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == -1 || n < indent {
+			indent = n
+		}
+	}
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			dedented[i] = line[indent:]
+		} else {
+			dedented[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return dedented
+}
@@ -0,0 +1,181 @@
+package markup
+
+import "testing"
+
+func TestAsciiDocToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// Headings
+		{
+			name:     "Headings",
+			input:    "= Title\n\n== Section\n\n=== Subsection",
+			expected: "# Title\n\n\n## Section\n\n\n### Subsection\n",
+		},
+		{
+			name:     "Heading with an anchor",
+			input:    "[[intro]]\n== Introduction",
+			expected: "## Introduction {#intro}\n",
+		},
+		{
+			name:     "Windows line endings",
+			input:    "== Section\r\n\r\nText",
+			expected: "## Section\n\n\nText",
+		},
+
+		// Lists
+		{
+			name:     "Nested bullets",
+			input:    "* one\n** two\n* three",
+			expected: "- one\n  - two\n- three",
+		},
+		{
+			name:     "Dash bullets",
+			input:    "- one\n- two",
+			expected: "- one\n- two",
+		},
+		{
+			name:     "Numbered list",
+			input:    ". first\n. second\n.. nested",
+			expected: "1. first\n1. second\n   1. nested",
+		},
+		{
+			name:     "Description list",
+			input:    "CPU:: The processor\nRAM:: Memory",
+			expected: "CPU\n: The processor\n\nRAM\n: Memory\n",
+		},
+
+		// Code and literal blocks
+		{
+			name:     "Source block",
+			input:    "[source,go]\n----\nfunc main() {}\n----",
+			expected: "```go\nfunc main() {}\n```\n",
+		},
+		{
+			name:     "Listing block",
+			input:    "----\nplain\n----",
+			expected: "```\nplain\n```\n",
+		},
+		{
+			name:     "Literal block keeps markup as written",
+			input:    "....\nliteral *text*\n....",
+			expected: "```\nliteral *text*\n```\n",
+		},
+		{
+			name:     "Indented literal paragraph",
+			input:    "  indented literal\n  second line",
+			expected: "```\nindented literal\nsecond line\n```",
+		},
+		{
+			name:     "Backticks in code get a longer fence",
+			input:    "----\nuse ```go\n----",
+			expected: "````\nuse ```go\n````\n",
+		},
+
+		// Links
+		{
+			name:     "Link macro and URL with text",
+			input:    "See link:https://example.com[Example] and https://go.dev[Go].",
+			expected: "See [Example](https://example.com) and [Go](https://go.dev).",
+		},
+		{
+			name:     "Cross-references",
+			input:    "See <<intro,the introduction>> and xref:other.adoc[Other].",
+			expected: "See [the introduction](#intro) and [Other](other).",
+		},
+		{
+			name:     "Image and footnote",
+			input:    "image:logo.png[Logo] and footnote:[A note]",
+			expected: "![Logo](logo.png) and [^1]\n\n[^1]: A note",
+		},
+
+		// Inline markup
+		{
+			name:     "Strong, emphasis, highlight and literals",
+			input:    "*bold* and __emph__ and _also_ and #mark# and `code` and `+*lit*+`",
+			expected: "**bold** and *emph* and _also_ and ==mark== and `code` and `*lit*`",
+		},
+		{
+			name:     "Markup inside code is kept",
+			input:    "Run `*glob*` now",
+			expected: "Run `*glob*` now",
+		},
+		{
+			name:     "Attributes",
+			input:    ":product: Wiki\n\nWelcome to {product}, {unknown}.",
+			expected: "\nWelcome to Wiki, {unknown}.",
+		},
+
+		// Blocks
+		{
+			name:     "Admonition paragraph",
+			input:    "NOTE: Take care",
+			expected: "> [!note]\n> Take care\n",
+		},
+		{
+			name:     "Admonition block",
+			input:    "[WARNING]\n====\nHot\n====",
+			expected: "> [!warning]\n> Hot\n\n",
+		},
+		{
+			name:     "Table",
+			input:    "|===\n|A |B\n\n|1 |2\n|===",
+			expected: "| A | B |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			name:     "Passthrough block",
+			input:    "++++\n<b>raw</b>\n++++",
+			expected: "<b>raw</b>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(AsciiDoc, tt.input); got != tt.expected {
+				t.Errorf("ToMarkdown(%q) =\n%q\nwant\n%q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAsciiDocMalformedInput(t *testing.T) {
+	tests := []string{
+		"",
+		"=",
+		"= ",
+		"====== ======= Too deep",
+		"----\nnever closed",
+		"....\n",
+		"++++",
+		"|===",
+		"|===\n|",
+		"|===\n||||\n|===",
+		"[cols=\"\"]\n|===\n|a\n|===",
+		"[source,]\n----\n----",
+		"[]\n[[]]\n[[x,]]",
+		".\n..\n...",
+		"*\n**\n* ",
+		"::\n;;\na::",
+		"link:[]\nimage:[]\nxref:[]\n<<>>\n<<,>>\nfootnote:[",
+		"`+\n+`\n``",
+		":attr:\n{attr}{attr}\n:!attr:\n{",
+		"NOTE:\nNOTE: ",
+		"+\n+\n+",
+		"////\ncomment never closed",
+		"====\n====\n====",
+		"\xff\xfe= Title\n== \xc3",
+	}
+	for _, input := range tests {
+		convertsWithoutPanic(t, AsciiDoc, input)
+	}
+}
+
+func TestAsciiDocNeverPanics(t *testing.T) {
+	testNeverPanics(t, AsciiDoc, []string{
+		"= Title\n:product: Wiki\n\n[[intro]]\n== Introduction\n\n.Example\n[source,go]\n----\nfunc main() {}\n----\n\n* one\n** two\n. first\n.. second\nCPU:: The processor\n\nNOTE: Take care\n\n[WARNING]\n====\nHot\n====\n",
+		"|===\n|A |B\n\n|1 |2\n|3\n|===\n\n____\nQuoted\n____\n\n****\nSidebar\n****\n\n--\nOpen\n--\n\n++++\n<b>raw</b>\n++++\n\n....\nliteral\n....\n",
+		"See link:https://example.com[Example], https://go.dev[Go], <<intro,the intro>>, xref:other.adoc#part[Other], image:logo.png[Logo,40] and footnote:[A note].\n*bold* __emph__ #mark# `+lit+` {product}",
+	})
+}
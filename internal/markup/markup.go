@@ -0,0 +1,179 @@
+// Package markup converts pages written in AsciiDoc or reStructuredText, such
+// as those imported from other documentation systems, to markdown, so they are
+// rendered like other pages while their source stays as it was written.
+package markup
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Markup formats pages can be written in besides markdown
+const (
+	AsciiDoc = "asciidoc"
+	RST      = "rst"
+)
+
+// Extensions maps the extensions of source files to their markup format
+var Extensions = map[string]string{
+	".adoc":     AsciiDoc,
+	".asciidoc": AsciiDoc,
+	".asc":      AsciiDoc,
+	".rst":      RST,
+	".rest":     RST,
+}
+
+// formatNames maps the names a page frontmatter may give its format by
+var formatNames = map[string]string{
+	"asciidoc":         AsciiDoc,
+	"adoc":             AsciiDoc,
+	"rst":              RST,
+	"restructuredtext": RST,
+}
+
+// Format returns the markup format of a frontmatter format: value, an
+// extension like .adoc or a name, "" for markdown and unknown formats
+func Format(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if format, ok := Extensions[name]; ok {
+		return format
+	}
+	return formatNames[strings.TrimPrefix(name, ".")]
+}
+
+// FormatOf returns the markup format of a file by its extension, "" for
+// markdown and other files
+func FormatOf(filename string) string {
+	return Extensions[strings.ToLower(path.Ext(filename))]
+}
+
+// Extension returns the extension files of a format are written with
+func Extension(format string) string {
+	switch format {
+	case AsciiDoc:
+		return ".adoc"
+	case RST:
+		return ".rst"
+	}
+	return ".md"
+}
+
+// ToMarkdown converts a page written in a markup format to markdown
+func ToMarkdown(format, source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	switch format {
+	case AsciiDoc:
+		return asciiDocToMarkdown(source)
+	case RST:
+		return rstToMarkdown(source)
+	}
+	return source
+}
+
+// calloutKind returns the callout a markup admonition is shown as
+func calloutKind(name string) string {
+	name = strings.ToLower(name)
+	switch name {
+	case "note", "tip", "hint", "important", "warning", "caution", "attention", "danger", "error":
+		return name
+	}
+	return "note"
+}
+
+// fence returns the line opening or closing a fenced code block of markdown
+// that holds the given lines
+func fence(lines []string) string {
+	longest := 2
+	for _, line := range lines {
+		if run := longestRun(line, '`'); run > longest {
+			longest = run
+		}
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// longestRun returns the length of the longest run of c in s
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// codeBlock returns the lines of a fenced code block
+func codeBlock(language string, lines []string) []string {
+	marker := fence(lines)
+	block := []string{marker + language}
+	block = append(block, lines...)
+	return append(block, marker)
+}
+
+// quote prefixes lines with > to put them in a block quote or callout
+func quote(lines []string) []string {
+	quoted := make([]string, len(lines))
+	for i, line := range lines {
+		if line == "" {
+			quoted[i] = ">"
+		} else {
+			quoted[i] = "> " + line
+		}
+	}
+	return quoted
+}
+
+// tableRow returns a row of a markdown table
+func tableRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(strings.TrimSpace(cell), "|", `\|`)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+// table returns the lines of a markdown table. Tables without a header get an
+// empty one, which markdown requires.
+func table(header []string, rows [][]string, columns int) []string {
+	if header == nil {
+		header = make([]string, columns)
+	}
+	separator := make([]string, columns)
+	for i := range separator {
+		separator[i] = "---"
+	}
+	lines := []string{tableRow(header), "| " + strings.Join(separator, " | ") + " |"}
+	for _, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, tableRow(row[:columns]))
+	}
+	return lines
+}
+
+// replaceOutsideCode applies fn to the parts of a line of markdown outside of
+// `code` spans
+func replaceOutsideCode(line string, fn func(string) string) string {
+	segments := strings.Split(line, "`")
+	for i := 0; i < len(segments); i += 2 {
+		// An unpaired backtick leaves the rest of the line as it is
+		if i == len(segments)-1 && len(segments)%2 == 0 {
+			break
+		}
+		segments[i] = fn(segments[i])
+	}
+	return strings.Join(segments, "`")
+}
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// anchor returns the id markdown gives a heading, for links to sections
+func anchor(text string) string {
+	return strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
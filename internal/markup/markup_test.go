@@ -0,0 +1,60 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+)
+
+// convertsWithoutPanic converts source, reporting a panic as a failure that
+// shows the input causing it
+func convertsWithoutPanic(t *testing.T, format, source string) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("ToMarkdown(%q, %q) panicked: %v", format, source, r)
+		}
+	}()
+	ToMarkdown(format, source)
+}
+
+// testNeverPanics converts every prefix of each document, and each document
+// missing one of its lines, which leaves blocks unclosed and tables ragged
+func testNeverPanics(t *testing.T, format string, documents []string) {
+	t.Helper()
+	for _, document := range documents {
+		for i := 0; i <= len(document); i++ {
+			convertsWithoutPanic(t, format, document[:i])
+		}
+		lines := strings.Split(document, "\n")
+		for i := range lines {
+			without := append(append([]string(nil), lines[:i]...), lines[i+1:]...)
+			convertsWithoutPanic(t, format, strings.Join(without, "\n"))
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "asciidoc", expected: AsciiDoc},
+		{name: ".adoc", expected: AsciiDoc},
+		{name: " AsciiDoc ", expected: AsciiDoc},
+		{name: "reStructuredText", expected: RST},
+		{name: ".rst", expected: RST},
+		{name: "markdown", expected: ""},
+		{name: "", expected: ""},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.name); got != tt.expected {
+			t.Errorf("Format(%q) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestToMarkdownLeavesMarkdownAlone(t *testing.T) {
+	if got := ToMarkdown("", "# Title\r\n\r\ntext"); got != "# Title\n\ntext" {
+		t.Errorf("ToMarkdown() = %q, want the source with its line endings normalized", got)
+	}
+}
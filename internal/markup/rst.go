@@ -0,0 +1,729 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	rstTargetRegex       = regexp.MustCompile(`^\.\.\s+_([^:]+|` + "`[^`]+`" + `):\s*(.*)$`)
+	rstSubstitutionRegex = regexp.MustCompile(`^\.\.\s+\|([^|]+)\|\s+([\w-]+)::\s*(.*)$`)
+	rstFootnoteRegex     = regexp.MustCompile(`^\.\.\s+\[(#?[\w-]*)\]\s*(.*)$`)
+	rstDirectiveRegex    = regexp.MustCompile(`^\.\.\s+([\w:-]+)::\s*(.*)$`)
+	rstOptionRegex       = regexp.MustCompile(`^:([\w-]+):\s*(.*)$`)
+	rstBulletRegex       = regexp.MustCompile(`^([-*+•])\s+(.*)$`)
+	rstEnumRegex         = regexp.MustCompile(`^\(?(\d+|#|[a-zA-Z])[.)]\s+(.*)$`)
+	rstFieldRegex        = regexp.MustCompile(`^:([^:]+):\s*(.*)$`)
+	rstGridBorderRegex   = regexp.MustCompile(`^\+([-=]+\+)+$`)
+	rstSimpleBorderRegex = regexp.MustCompile(`^=+( +=+)+$`)
+
+	rstLiteralRegex    = regexp.MustCompile("``(.+?)``")
+	rstRoleRegex       = regexp.MustCompile(":([\\w:-]+):`([^`]+)`")
+	rstLinkRegex       = regexp.MustCompile("`([^`<]*?)\\s*<([^>]+)>`__?")
+	rstRefRegex        = regexp.MustCompile("`([^`]+)`__?")
+	rstWordRefRegex    = regexp.MustCompile(`(^|[\s(])([A-Za-z0-9][\w.-]*[A-Za-z0-9]|[A-Za-z0-9])__?($|[\s).,;:!?])`)
+	rstInterpretRegex  = regexp.MustCompile("`([^`]+)`")
+	rstSubstituteRegex = regexp.MustCompile(`\|([^|\s][^|]*)\|`)
+	rstFootRefRegex    = regexp.MustCompile(`\s?\[(#?[\w-]*)\]_`)
+	rstPlaceholder     = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// rstAdmonitions are the directives shown as callouts
+var rstAdmonitions = map[string]bool{
+	"note": true, "tip": true, "hint": true, "important": true, "warning": true, "caution": true,
+	"attention": true, "danger": true, "error": true, "admonition": true, "seealso": true,
+}
+
+// rst converts reStructuredText to markdown. Targets and substitutions are
+// collected from the whole document first, since they may be defined after
+// their references.
+type rst struct {
+	targets       map[string]string // Hyperlink targets by normalized name
+	substitutions map[string]string
+	styles        []string // Title adornments in the order they define levels
+	autoRefs      int      // [#]_ footnote references seen
+	autoNotes     int      // .. [#] footnotes seen
+}
+
+// listItem is an open list item: the column of its marker and its text in the
+// source, and the column of its text in the markdown
+type listItem struct {
+	marker, content, md int
+}
+
+// rstToMarkdown converts a reStructuredText document to markdown
+func rstToMarkdown(source string) string {
+	r := &rst{targets: make(map[string]string), substitutions: make(map[string]string)}
+	lines := strings.Split(source, "\n")
+	r.collect(lines)
+	return strings.Join(r.convert(lines), "\n")
+}
+
+// collect reads the hyperlink targets and substitution definitions
+func (r *rst) collect(lines []string) {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if m := rstTargetRegex.FindStringSubmatch(trimmed); m != nil && m[2] != "" {
+			r.targets[rstName(m[1])] = m[2]
+		}
+		if m := rstSubstitutionRegex.FindStringSubmatch(trimmed); m != nil {
+			switch m[2] {
+			case "replace":
+				r.substitutions[m[1]] = m[3]
+			case "image":
+				r.substitutions[m[1]] = "![" + m[1] + "](" + m[3] + ")"
+			}
+		}
+	}
+	// Targets pointing at other targets, like .. _a: b_
+	for name, target := range r.targets {
+		if strings.HasSuffix(target, "_") && !strings.Contains(target, "/") {
+			if resolved, ok := r.targets[rstName(strings.TrimSuffix(target, "_"))]; ok {
+				r.targets[name] = resolved
+			}
+		}
+	}
+}
+
+// convert converts the lines of a document or of the body of a directive
+func (r *rst) convert(lines []string) []string {
+	var out []string
+	var list []listItem
+	literal := false // The previous paragraph ended with ::
+	paragraph := -1  // Line of out the next line of text continues
+
+	blankBefore := func() bool {
+		return len(out) == 0 || out[len(out)-1] == ""
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if trimmed == "" {
+			out = append(out, "")
+			continue
+		}
+
+		// Literal blocks: the indented lines after a paragraph ending with ::
+		if literal && indent > 0 {
+			end := indentedEnd(lines, i, indent)
+			out = append(out, codeBlock("", dedent(trimBlank(lines[i:end])))...)
+			out = append(out, "")
+			literal = false
+			i = end - 1
+			continue
+		}
+		literal = false
+
+		// Titles with an overline, then with an underline
+		if isAdornment(trimmed) && i+2 < len(lines) && strings.TrimSpace(lines[i+1]) != "" &&
+			strings.TrimSpace(lines[i+2]) == trimmed {
+			out = append(out, r.heading("over"+trimmed[:1], strings.TrimSpace(lines[i+1])), "")
+			list = nil
+			i += 2
+			continue
+		}
+		if indent == 0 && i+1 < len(lines) && blankBefore() && !isAdornment(trimmed) {
+			next := strings.TrimSpace(lines[i+1])
+			if isAdornment(next) && utf8.RuneCountInString(next) >= min(utf8.RuneCountInString(trimmed), 4) {
+				out = append(out, r.heading(next[:1], trimmed), "")
+				list = nil
+				i++
+				continue
+			}
+		}
+		if isAdornment(trimmed) && len(trimmed) >= 4 && blankBefore() {
+			out = append(out, "---")
+			continue
+		}
+
+		// Explicit markup: directives, targets, footnotes and comments
+		if strings.HasPrefix(trimmed, "..") && (trimmed == ".." || strings.HasPrefix(trimmed, ".. ")) {
+			end := indentedEnd(lines, i+1, indent+1)
+			out = append(out, r.explicit(trimmed, lines[i+1:end])...)
+			list = nil
+			i = end - 1
+			continue
+		}
+
+		// Tables
+		if rstGridBorderRegex.MatchString(trimmed) {
+			end := i + 1
+			for end < len(lines) && (strings.HasPrefix(strings.TrimSpace(lines[end]), "|") || rstGridBorderRegex.MatchString(strings.TrimSpace(lines[end]))) {
+				end++
+			}
+			out = append(out, r.gridTable(lines[i:end])...)
+			out = append(out, "")
+			i = end - 1
+			continue
+		}
+		if rstSimpleBorderRegex.MatchString(trimmed) {
+			rows, end := simpleTableRows(lines, i)
+			out = append(out, r.simpleTable(trimmed, rows)...)
+			out = append(out, "")
+			i = end
+			continue
+		}
+
+		// Lists
+		marker, text := "", ""
+		if m := rstBulletRegex.FindStringSubmatch(trimmed); m != nil {
+			marker, text = "-", m[2]
+		} else if m := rstEnumRegex.FindStringSubmatch(trimmed); m != nil && (blankBefore() || len(list) > 0) {
+			marker, text = "1.", m[2]
+		}
+		if marker != "" {
+			for len(list) > 0 && list[len(list)-1].content > indent {
+				list = list[:len(list)-1]
+			}
+			mdIndent := 0
+			if len(list) > 0 {
+				mdIndent = list[len(list)-1].md
+			}
+			content := indent + len(trimmed) - len(text)
+			list = append(list, listItem{marker: indent, content: content, md: mdIndent + len(marker) + 1})
+			out = append(out, strings.Repeat(" ", mdIndent)+marker+" "+r.inline(r.literalMarker(text, &literal)))
+			paragraph = len(out) - 1
+			continue
+		}
+		if len(list) > 0 && indent > 0 {
+			// Text of an open list item
+			for len(list) > 1 && list[len(list)-1].content > indent {
+				list = list[:len(list)-1]
+			}
+			text = r.inline(r.literalMarker(trimmed, &literal))
+			if paragraph >= 0 && paragraph == len(out)-1 {
+				out[paragraph] += " " + text
+			} else {
+				out = append(out, strings.Repeat(" ", list[len(list)-1].md)+text)
+				paragraph = len(out) - 1
+			}
+			continue
+		}
+		if indent == 0 {
+			list = nil
+		}
+
+		// Field lists, like the :Author: of the document
+		if m := rstFieldRegex.FindStringSubmatch(trimmed); m != nil && indent == 0 && !rstRoleRegex.MatchString(trimmed) {
+			out = append(out, "**"+strings.TrimSpace(m[1])+":** "+r.inline(m[2]), "")
+			continue
+		}
+
+		// Definition lists: a term with its definition indented below it
+		if indent == 0 && blankBefore() && i+1 < len(lines) {
+			next := lines[i+1]
+			if nextIndent := len(next) - len(strings.TrimLeft(next, " ")); strings.TrimSpace(next) != "" && nextIndent > 0 {
+				end := indentedEnd(lines, i+1, 1)
+				definition := r.convert(dedent(trimBlank(lines[i+1 : end])))
+				out = append(out, r.inline(trimmed))
+				for j, def := range trimBlank(definition) {
+					if j == 0 {
+						out = append(out, ": "+def)
+					} else if def != "" {
+						out = append(out, "  "+def)
+					}
+				}
+				out = append(out, "")
+				i = end - 1
+				continue
+			}
+		}
+
+		// Block quotes: indented paragraphs
+		if indent > 0 && blankBefore() {
+			end := indentedEnd(lines, i, 1)
+			out = append(out, quote(trimBlank(r.convert(dedent(lines[i:end]))))...)
+			out = append(out, "")
+			i = end - 1
+			continue
+		}
+
+		// Lines of a paragraph are joined, since markdown pages break lines
+		// at every newline
+		text = r.inline(r.literalMarker(trimmed, &literal))
+		if paragraph >= 0 && paragraph == len(out)-1 {
+			out[paragraph] += " " + text
+		} else {
+			out = append(out, text)
+		}
+		paragraph = len(out) - 1
+	}
+	return out
+}
+
+// literalMarker removes the :: ending a paragraph that introduces a literal
+// block, keeping one colon after text
+func (r *rst) literalMarker(text string, literal *bool) string {
+	if !strings.HasSuffix(text, "::") {
+		return text
+	}
+	*literal = true
+	switch {
+	case text == "::":
+		return ""
+	case strings.HasSuffix(text, " ::"):
+		return strings.TrimSuffix(text, " ::")
+	}
+	return strings.TrimSuffix(text, ":")
+}
+
+// heading returns a section title as a markdown heading. Levels follow the
+// order in which the document first uses each adornment style.
+func (r *rst) heading(style, title string) string {
+	level := 0
+	for i, known := range r.styles {
+		if known == style {
+			level = i + 1
+		}
+	}
+	if level == 0 {
+		r.styles = append(r.styles, style)
+		level = len(r.styles)
+	}
+	return strings.Repeat("#", min(level, 6)) + " " + r.inline(title)
+}
+
+// explicit converts explicit markup starting with .. and its indented body
+func (r *rst) explicit(first string, body []string) []string {
+	if m := rstTargetRegex.FindStringSubmatch(first); m != nil {
+		if m[2] == "" {
+			// Internal targets name the element after them
+			return []string{`<a id="` + anchor(rstName(m[1])) + `"></a>`}
+		}
+		return nil
+	}
+	if rstSubstitutionRegex.MatchString(first) {
+		return nil
+	}
+	if m := rstFootnoteRegex.FindStringSubmatch(first); m != nil {
+		label := m[1]
+		if label == "#" || label == "" {
+			r.autoNotes++
+			label = strconv.Itoa(r.autoNotes)
+		}
+		text := strings.TrimSpace(m[2] + " " + strings.Join(trimBlank(dedent(body)), " "))
+		return []string{"[^" + strings.TrimPrefix(label, "#") + "]: " + r.inline(text), ""}
+	}
+
+	m := rstDirectiveRegex.FindStringSubmatch(first)
+	if m == nil {
+		// Comment
+		return nil
+	}
+	name, argument := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+	options := make(map[string]string)
+	content := dedent(body)
+	for len(content) > 0 {
+		option := rstOptionRegex.FindStringSubmatch(strings.TrimSpace(content[0]))
+		if option == nil {
+			break
+		}
+		options[option[1]] = option[2]
+		content = content[1:]
+	}
+	content = trimBlank(content)
+
+	switch {
+	case name == "code-block" || name == "code" || name == "sourcecode" || name == "highlight":
+		if name == "highlight" {
+			return nil
+		}
+		return append(codeBlock(argument, content), "")
+	case name == "math":
+		lines := []string{"$$"}
+		if argument != "" {
+			lines = append(lines, argument)
+		}
+		return append(append(lines, content...), "$$", "")
+	case rstAdmonitions[name]:
+		title := ""
+		if name == "admonition" {
+			title, argument = argument, ""
+		} else if name == "seealso" {
+			title = "See also"
+		}
+		text := content
+		if argument != "" && len(content) > 0 {
+			text = append([]string{argument, ""}, content...)
+		} else if argument != "" {
+			text = []string{argument}
+		}
+		header := "[!" + calloutKind(name) + "]"
+		if title != "" {
+			header += " " + r.inline(title)
+		}
+		return append(quote(append([]string{header}, trimBlank(r.convert(text))...)), "")
+	case name == "image" || name == "figure":
+		lines := []string{"![" + options["alt"] + "](" + argument + ")"}
+		if name == "figure" && len(content) > 0 {
+			lines = append(lines, "", "*"+r.inline(strings.Join(content, " "))+"*")
+		}
+		return append(lines, "")
+	case name == "contents":
+		return []string{"[toc]", ""}
+	case name == "raw":
+		if strings.TrimSpace(argument) == "html" {
+			return append(content, "")
+		}
+		return nil
+	case name == "rubric" || name == "topic" || name == "sidebar":
+		lines := []string{"**" + r.inline(argument) + "**", ""}
+		return append(append(lines, r.convert(content)...), "")
+	case name == "epigraph" || name == "pull-quote" || name == "highlights":
+		return append(quote(trimBlank(r.convert(content))), "")
+	case name == "csv-table" || name == "list-table":
+		lines := []string{}
+		if argument != "" {
+			lines = append(lines, "**"+r.inline(argument)+"**", "")
+		}
+		if name == "list-table" {
+			lines = append(lines, r.listTable(content, options)...)
+		} else {
+			lines = append(lines, r.csvTable(content, options)...)
+		}
+		return append(lines, "")
+	}
+	// Other directives, such as toctree, index or include, are left out
+	return nil
+}
+
+// gridTable converts a table drawn with +, - and | characters. Cells spanning
+// several columns are split at the column borders.
+func (r *rst) gridTable(lines []string) []string {
+	border := strings.TrimSpace(lines[0])
+	var columns []int
+	for i, c := range border {
+		if c == '+' {
+			columns = append(columns, i)
+		}
+	}
+	if len(columns) < 2 {
+		return codeBlock("", lines)
+	}
+
+	var header []string
+	var rows [][]string
+	var cells []string
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if rstGridBorderRegex.MatchString(line) {
+			if cells != nil {
+				if strings.Contains(line, "=") && header == nil && rows == nil {
+					header = cells
+				} else {
+					rows = append(rows, cells)
+				}
+			}
+			cells = nil
+			continue
+		}
+		if cells == nil {
+			cells = make([]string, len(columns)-1)
+		}
+		for c := 0; c < len(columns)-1; c++ {
+			start, end := columns[c]+1, columns[c+1]
+			if start >= len(line) {
+				continue
+			}
+			text := strings.TrimSpace(strings.Trim(line[start:min(end, len(line))], "|"))
+			if text != "" {
+				cells[c] = strings.TrimSpace(cells[c] + " " + text)
+			}
+		}
+	}
+	for _, row := range append([][]string{header}, rows...) {
+		for c := range row {
+			row[c] = r.inline(row[c])
+		}
+	}
+	return table(header, rows, len(columns)-1)
+}
+
+// simpleTableRows returns the lines of a table bordered by === lines starting
+// at lines[start], with the borders, and the index of its last border
+func simpleTableRows(lines []string, start int) ([]string, int) {
+	borders := 0
+	end := start
+	for end < len(lines) {
+		trimmed := strings.TrimSpace(lines[end])
+		if rstSimpleBorderRegex.MatchString(trimmed) {
+			borders++
+			// The second border ends tables without a header
+			if borders == 3 || (borders == 2 && (end+1 >= len(lines) || strings.TrimSpace(lines[end+1]) == "")) {
+				break
+			}
+		}
+		end++
+	}
+	return lines[start:min(end+1, len(lines))], min(end, len(lines)-1)
+}
+
+// simpleTable converts a table whose columns are given by a border of = runs
+func (r *rst) simpleTable(border string, lines []string) []string {
+	var starts []int
+	for i := 0; i < len(border); i++ {
+		if border[i] == '=' && (i == 0 || border[i-1] == ' ') {
+			starts = append(starts, i)
+		}
+	}
+
+	// With three borders, the rows between the first two are the header
+	hasHeader := countBorders(lines) == 3
+	var header []string
+	var rows [][]string
+	borders := 0
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if rstSimpleBorderRegex.MatchString(trimmed) {
+			borders++
+			if borders == 2 && hasHeader && len(rows) > 0 {
+				header, rows = rows[0], nil
+			}
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		line = strings.TrimRight(line, " ")
+		cells := make([]string, len(starts))
+		for c, start := range starts {
+			if start >= len(line) {
+				continue
+			}
+			end := len(line)
+			if c+1 < len(starts) {
+				end = min(starts[c+1], len(line))
+			}
+			cells[c] = r.inline(strings.TrimSpace(line[start:end]))
+		}
+		rows = append(rows, cells)
+	}
+	return table(header, rows, len(starts))
+}
+
+// countBorders returns the number of === borders of a simple table
+func countBorders(lines []string) int {
+	count := 0
+	for _, line := range lines {
+		if rstSimpleBorderRegex.MatchString(strings.TrimSpace(line)) {
+			count++
+		}
+	}
+	return count
+}
+
+// listTable converts a list-table directive: a list of rows, each a list of cells
+func (r *rst) listTable(content []string, options map[string]string) []string {
+	var rows [][]string
+	for _, line := range content {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "* -"), strings.HasPrefix(trimmed, "- -"):
+			rows = append(rows, []string{r.inline(strings.TrimSpace(trimmed[3:]))})
+		case strings.HasPrefix(trimmed, "- ") && len(rows) > 0:
+			rows[len(rows)-1] = append(rows[len(rows)-1], r.inline(strings.TrimSpace(trimmed[2:])))
+		case trimmed != "" && len(rows) > 0:
+			row := rows[len(rows)-1]
+			row[len(row)-1] += " " + r.inline(trimmed)
+		}
+	}
+	return r.tableWithHeader(rows, options)
+}
+
+// csvTable converts a csv-table directive
+func (r *rst) csvTable(content []string, options map[string]string) []string {
+	var rows [][]string
+	if header := options["header"]; header != "" {
+		rows = append(rows, splitCSV(header))
+		options["header-rows"] = "1"
+	}
+	for _, line := range content {
+		if strings.TrimSpace(line) != "" {
+			rows = append(rows, splitCSV(line))
+		}
+	}
+	for _, row := range rows {
+		for c := range row {
+			row[c] = r.inline(row[c])
+		}
+	}
+	return r.tableWithHeader(rows, options)
+}
+
+// tableWithHeader returns rows as a table, the first one being the header
+// when the header-rows option asks for it
+func (r *rst) tableWithHeader(rows [][]string, options map[string]string) []string {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return nil
+	}
+	var header []string
+	if n, _ := strconv.Atoi(options["header-rows"]); n > 0 && len(rows) > 0 {
+		header, rows = rows[0], rows[1:]
+		for len(header) < columns {
+			header = append(header, "")
+		}
+	}
+	return table(header, rows, columns)
+}
+
+// splitCSV splits a line of comma-separated values, which may be quoted
+func splitCSV(line string) []string {
+	var cells []string
+	var cell strings.Builder
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(line) && line[i+1] == '"':
+			cell.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// inline converts the inline markup of a line of text
+func (r *rst) inline(text string) string {
+	// Literals and the markdown made from roles are kept aside, so the
+	// other conversions leave them alone
+	var kept []string
+	keep := func(s string) string {
+		kept = append(kept, s)
+		return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+	}
+
+	text = rstLiteralRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return keep("`" + rstLiteralRegex.FindStringSubmatch(match)[1] + "`")
+	})
+	text = rstRoleRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := rstRoleRegex.FindStringSubmatch(match)
+		return keep(r.role(m[1], m[2]))
+	})
+	text = rstLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := rstLinkRegex.FindStringSubmatch(match)
+		target := m[2]
+		if strings.HasSuffix(target, "_") {
+			target = r.reference(strings.TrimSuffix(target, "_"))
+		}
+		return keep("[" + orText(m[1], m[2]) + "](" + target + ")")
+	})
+	text = rstRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := rstRefRegex.FindStringSubmatch(match)
+		return keep("[" + m[1] + "](" + r.reference(m[1]) + ")")
+	})
+	text = rstWordRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := rstWordRefRegex.FindStringSubmatch(match)
+		target, ok := r.targets[rstName(m[2])]
+		if !ok {
+			return match
+		}
+		return m[1] + keep("["+m[2]+"]("+target+")") + m[3]
+	})
+	text = rstFootRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		label := rstFootRefRegex.FindStringSubmatch(match)[1]
+		if label == "#" || label == "" {
+			r.autoRefs++
+			label = strconv.Itoa(r.autoRefs)
+		}
+		return keep("[^" + strings.TrimPrefix(label, "#") + "]")
+	})
+	text = rstInterpretRegex.ReplaceAllString(text, "*$1*")
+	text = rstSubstituteRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := r.substitutions[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+	// A backslash and a space separate markup from the text next to it
+	text = strings.ReplaceAll(text, `\ `, "")
+
+	return rstPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		n, _ := strconv.Atoi(strings.Trim(match, "\x00"))
+		return kept[n]
+	})
+}
+
+// role converts interpreted text with a role, like :code:`x` or :doc:`page`
+func (r *rst) role(name, text string) string {
+	title, target := text, text
+	if m := regexp.MustCompile(`^(.*?)\s*<([^>]+)>$`).FindStringSubmatch(text); m != nil {
+		title, target = orText(m[1], m[2]), m[2]
+	}
+	switch name {
+	case "code", "literal", "file", "samp", "command", "kbd", "envvar", "option", "program":
+		return "`" + text + "`"
+	case "math":
+		return "$" + text + "$"
+	case "sup", "superscript":
+		return "<sup>" + text + "</sup>"
+	case "sub", "subscript":
+		return "<sub>" + text + "</sub>"
+	case "emphasis", "title-reference", "title", "t", "dfn":
+		return "*" + text + "*"
+	case "strong":
+		return "**" + text + "**"
+	case "doc":
+		return "[" + strings.TrimPrefix(title, "~") + "](" + strings.TrimSuffix(target, ".rst") + ")"
+	case "ref":
+		return "[" + title + "](#" + anchor(target) + ")"
+	case "abbr":
+		if abbr, expansion, ok := strings.Cut(text, " ("); ok {
+			return fmt.Sprintf(`<abbr title="%s">%s</abbr>`, strings.TrimSuffix(expansion, ")"), abbr)
+		}
+	}
+	return "`" + strings.TrimPrefix(title, "~") + "`"
+}
+
+// reference returns the destination of a named reference: a hyperlink
+// target, or the section with that title
+func (r *rst) reference(name string) string {
+	if target, ok := r.targets[rstName(name)]; ok {
+		return target
+	}
+	return "#" + anchor(name)
+}
+
+// isAdornment reports whether a line is a run of one punctuation character,
+// underlining a title or making a transition
+func isAdornment(line string) bool {
+	if len(line) < 3 || !strings.ContainsRune(`!"#$%&'()*+,-./:;<=>?@[\]^_`+"`"+`{|}~`, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// rstName normalizes a reference name, which ignores case and whitespace
+func rstName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Trim(name, "`")), " "))
+}
+
+// indentedEnd returns the index of the first line from start on that is
+// indented less than indent and not blank
+func indentedEnd(lines []string, start, indent int) int {
+	end := start
+	for end < len(lines) {
+		line := lines[end]
+		if strings.TrimSpace(line) != "" && len(line)-len(strings.TrimLeft(line, " ")) < indent {
+			break
+		}
+		end++
+	}
+	return end
+}
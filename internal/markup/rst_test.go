@@ -0,0 +1,203 @@
+package markup
+
+import "testing"
+
+func TestRSTToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// Headings
+		{
+			name:     "Underlined titles take levels in the order they appear",
+			input:    "Title\n=====\n\nSection\n-------\n\nSub\n~~~",
+			expected: "# Title\n\n\n## Section\n\n\n### Sub\n",
+		},
+		{
+			name:     "Overlined title",
+			input:    "=====\nTitle\n=====\n\nSection\n=======",
+			expected: "# Title\n\n\n## Section\n",
+		},
+		{
+			name:     "Short underline is text",
+			input:    "A long title\n==",
+			expected: "A long title ==",
+		},
+		{
+			name:     "Transition",
+			input:    "One\n\n----\n\nTwo",
+			expected: "One\n\n---\n\nTwo",
+		},
+
+		// Lists
+		{
+			name:     "Bullets",
+			input:    "- one\n- two\n\n  - nested",
+			expected: "- one\n- two\n\n  - nested",
+		},
+		{
+			name:     "Enumerated list",
+			input:    "1. first\n2. second",
+			expected: "1. first\n1. second",
+		},
+		{
+			name:     "Auto-numbered list",
+			input:    "#. auto\n#. auto",
+			expected: "1. auto\n1. auto",
+		},
+		{
+			name:     "Definition list",
+			input:    "Term\n   Definition",
+			expected: "Term\n: Definition\n",
+		},
+		{
+			name:     "Field list",
+			input:    ":Author: Ann",
+			expected: "**Author:** Ann\n",
+		},
+
+		// Code and literal blocks
+		{
+			name:     "Literal block",
+			input:    "Example::\n\n    code here\n    more",
+			expected: "Example:\n\n```\ncode here\nmore\n```\n",
+		},
+		{
+			name:     "Literal block after a spaced marker",
+			input:    "Paragraph ::\n\n    code",
+			expected: "Paragraph\n\n```\ncode\n```\n",
+		},
+		{
+			name:     "Code block directive",
+			input:    ".. code-block:: python\n\n   print(1)",
+			expected: "```python\nprint(1)\n```\n",
+		},
+
+		// Links
+		{
+			name:     "Embedded URI and named target",
+			input:    "`Example <https://example.com>`_ and Go_\n\n.. _Go: https://go.dev",
+			expected: "[Example](https://example.com) and [Go](https://go.dev)\n",
+		},
+		{
+			name:     "Section reference and roles",
+			input:    "See `Section`_ and :doc:`other.rst` and :ref:`Title <intro>`",
+			expected: "See [Section](#section) and [other.rst](other) and [Title](#intro)",
+		},
+		{
+			name:     "Image",
+			input:    ".. image:: a.png\n   :alt: A",
+			expected: "![A](a.png)\n",
+		},
+		{
+			name:     "Footnote",
+			input:    "Text [#]_\n\n.. [#] Note",
+			expected: "Text[^1]\n\n[^1]: Note\n",
+		},
+
+		// Inline markup
+		{
+			name:     "Strong, emphasis, literals and roles",
+			input:    "**bold** *emph* ``code`` :sup:`2` `interp`",
+			expected: "**bold** *emph* `code` <sup>2</sup> *interp*",
+		},
+		{
+			name:     "Markup inside literals is kept",
+			input:    "Run ``Go_ `x`_`` now",
+			expected: "Run `Go_ `x`_` now",
+		},
+		{
+			name:     "Substitution",
+			input:    "Use |name|.\n\n.. |name| replace:: the wiki",
+			expected: "Use the wiki.\n",
+		},
+
+		// Blocks
+		{
+			name:     "Admonition",
+			input:    ".. note:: Take care",
+			expected: "> [!note]\n> Take care\n",
+		},
+		{
+			name:     "Grid table",
+			input:    "+---+---+\n| A | B |\n+===+===+\n| 1 | 2 |\n+---+---+",
+			expected: "| A | B |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			name:     "Simple table",
+			input:    "=== ===\nA   B\n=== ===\n1   2\n=== ===",
+			expected: "| A | B |\n| --- | --- |\n| 1 | 2 |\n",
+		},
+		{
+			name:     "Raw HTML",
+			input:    ".. raw:: html\n\n   <b>raw</b>",
+			expected: "<b>raw</b>\n",
+		},
+		{
+			name:     "Raw output for other writers is left out",
+			input:    ".. raw:: latex\n\n   \\newpage",
+			expected: "",
+		},
+		{
+			name:     "Comment",
+			input:    ".. a comment\n   over two lines",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(RST, tt.input); got != tt.expected {
+				t.Errorf("ToMarkdown(%q) =\n%q\nwant\n%q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRSTMalformedInput(t *testing.T) {
+	tests := []string{
+		"",
+		"::",
+		"Text::",
+		"===",
+		"===\n===",
+		"===\nTitle",
+		"Title\n===\nTitle\n===",
+		"..",
+		".. ::",
+		".. _:",
+		".. _`unclosed: x",
+		".. |x| replace::",
+		".. [",
+		".. image::",
+		".. code-block::",
+		".. list-table::\n\n   - orphan cell",
+		".. csv-table::\n   :header: \"a,b\n\n   \"unclosed,1",
+		"+",
+		"+-+",
+		"+---+---+\n|",
+		"+---+---+\n| a | b | c | d |\n+---+",
+		"+===+\n+===+",
+		"=== ===",
+		"=== ===\nA   B   C   D   E",
+		"= =\na b\n= =\n= =\n= =",
+		"``\n``unclosed",
+		":role:`\n:`x`\n`<>`_\n`_\n__",
+		"[#]_ [#]_ [#]_\n\n.. [#]",
+		"- \n-\n1.\n#.",
+		"  indented\nback\n    deeper\n  - list\n  text",
+		"\xff\xfeTitle\n=====\n\xc3",
+	}
+	for _, input := range tests {
+		convertsWithoutPanic(t, RST, input)
+	}
+}
+
+func TestRSTNeverPanics(t *testing.T) {
+	testNeverPanics(t, RST, []string{
+		"=====\nTitle\n=====\n\n:Author: Ann\n\nSection\n-------\n\nText with `a link <https://example.com>`_, Go_, ``code`` and |name| [#]_.\n\n.. _Go: https://go.dev\n.. |name| replace:: the wiki\n.. [#] A note\n\n- one\n- two\n\n  #. nested\n\nTerm\n   Definition\n\nExample::\n\n    code\n",
+		".. note:: Take care\n\n   More text\n\n.. code-block:: go\n   :linenos:\n\n   func main() {}\n\n.. image:: a.png\n   :alt: A\n\n.. list-table:: Title\n   :header-rows: 1\n\n   * - A\n     - B\n   * - 1\n     - 2\n\n.. csv-table::\n   :header: \"a\", \"b\"\n\n   1, \"two, three\"\n",
+		"+---+-------+\n| A | B     |\n+===+=======+\n| 1 | two   |\n|   | lines |\n+---+-------+\n\n=== ===\nA   B\n=== ===\n1   2\n=== ===\n\n.. raw:: html\n\n   <b>raw</b>\n",
+	})
+}
@@ -0,0 +1,46 @@
+package netaccess
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Middleware blocks requests from clients the network access rules in rootDir
+// don't allow. Rules that can't be loaded block every request rather than
+// silently letting everyone in.
+func Middleware(next http.Handler, rootDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := Load(rootDir)
+		if err != nil {
+			log.Printf("Network access: error loading rules, blocking request: %v", err)
+			forbid(w, r)
+			return
+		}
+
+		client := file.Client(r)
+		if ok, group := file.Allowed(client, Groups(r)); !ok {
+			log.Printf("Network access: blocked %s (country %q) from %s %s by %s rules",
+				client.IP, client.Country, r.Method, r.URL.Path, group)
+			forbid(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forbid answers a blocked request, in JSON for API requests
+func forbid(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"message": "Access from your network is not allowed",
+	})
+}
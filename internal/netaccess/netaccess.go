@@ -0,0 +1,313 @@
+package netaccess
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+)
+
+// Route groups, each request belongs to one or more of them
+const (
+	GroupView  = "view"  // Every request
+	GroupAPI   = "api"   // Requests to /api/
+	GroupEdit  = "edit"  // Requests changing content
+	GroupAdmin = "admin" // Routes registered with RegisterAdmin: settings, user management, imports, profiles
+)
+
+// adminRoutes are the patterns of the routes only admins reach
+var adminRoutes struct {
+	sync.RWMutex
+	patterns []string
+}
+
+// RegisterAdmin adds the route of a ServeMux pattern to the admin group. It is
+// called where the admin routes are set up, so new ones can't be forgotten.
+// Patterns ending in a slash cover the paths below them.
+func RegisterAdmin(pattern string) {
+	adminRoutes.Lock()
+	defer adminRoutes.Unlock()
+	adminRoutes.patterns = append(adminRoutes.patterns, pattern)
+}
+
+// isAdmin reports whether a path belongs to a registered admin route
+func isAdmin(path string) bool {
+	adminRoutes.RLock()
+	defer adminRoutes.RUnlock()
+	for _, pattern := range adminRoutes.patterns {
+		if path == pattern || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule restricts the clients allowed in a route group. Deny entries win over allow
+// entries; when an allow list is set, clients not on it are blocked.
+type Rule struct {
+	Allow          []string `yaml:"allow,omitempty"`           // IPs or CIDR ranges
+	Deny           []string `yaml:"deny,omitempty"`            // IPs or CIDR ranges
+	AllowCountries []string `yaml:"allow_countries,omitempty"` // ISO country codes
+	DenyCountries  []string `yaml:"deny_countries,omitempty"`  // ISO country codes
+
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// File holds the network access rules
+type File struct {
+	// Proxies allowed to report the client address in X-Forwarded-For and X-Real-IP.
+	// When empty, the forwarded headers are ignored by the access rules.
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+	// Header carrying the client country set by a trusted proxy, e.g. CF-IPCountry
+	CountryHeader string          `yaml:"country_header,omitempty"`
+	Groups        map[string]Rule `yaml:"groups,omitempty"`
+
+	trusted []*net.IPNet
+}
+
+// Client is the resolved origin of a request
+type Client struct {
+	IP      net.IP
+	Country string // Upper-case ISO code, empty when unknown
+}
+
+// cache avoids re-reading the rules file on every request
+var cache filecache.Cache[*File]
+
+// FilePath returns the location of the network access rules
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "network.yaml")
+}
+
+// Load returns the current rules, empty ones when the file does not exist
+func Load(rootDir string) (*File, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML network access rules
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	var err error
+	if file.trusted, err = parseNetworks(file.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	for name, rule := range file.Groups {
+		switch name {
+		case GroupView, GroupAPI, GroupEdit, GroupAdmin:
+		default:
+			return nil, fmt.Errorf("unknown route group %q (use view, api, edit or admin)", name)
+		}
+		if rule.allow, err = parseNetworks(rule.Allow); err != nil {
+			return nil, fmt.Errorf("%s.allow: %w", name, err)
+		}
+		if rule.deny, err = parseNetworks(rule.Deny); err != nil {
+			return nil, fmt.Errorf("%s.deny: %w", name, err)
+		}
+		rule.AllowCountries = upper(rule.AllowCountries)
+		rule.DenyCountries = upper(rule.DenyCountries)
+		file.Groups[name] = rule
+	}
+
+	return &file, nil
+}
+
+// ReadRaw returns the rules file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates and writes the YAML content of the rules file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// HasTrustedProxies reports whether forwarded headers are restricted to trusted proxies
+func (f *File) HasTrustedProxies() bool {
+	return len(f.trusted) > 0
+}
+
+// Client resolves the client of a request. Forwarded headers are only honored
+// when the connection comes from a trusted proxy; X-Forwarded-For is read from
+// the right, skipping trusted proxies, so clients can't spoof earlier entries.
+func (f *File) Client(r *http.Request) Client {
+	ip := remoteIP(r.RemoteAddr)
+	if ip == nil || !contains(f.trusted, ip) {
+		return Client{IP: ip}
+	}
+
+	client := Client{IP: ip}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+			client.IP = hop
+			if !contains(f.trusted, hop) {
+				break
+			}
+		}
+	} else if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		client.IP = realIP
+	}
+
+	if f.CountryHeader != "" {
+		client.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(f.CountryHeader)))
+	}
+	return client
+}
+
+// Allowed reports whether client may make a request in every one of groups.
+// It returns the name of the group that blocked it otherwise.
+func (f *File) Allowed(client Client, groups []string) (bool, string) {
+	for _, name := range groups {
+		rule, ok := f.Groups[name]
+		if ok && !rule.allows(client) {
+			return false, name
+		}
+	}
+	return true, ""
+}
+
+// allows reports whether the rule lets client through
+func (rule Rule) allows(client Client) bool {
+	if client.IP == nil {
+		return len(rule.allow) == 0 && len(rule.AllowCountries) == 0
+	}
+	if contains(rule.deny, client.IP) || containsString(rule.DenyCountries, client.Country) {
+		return false
+	}
+	if len(rule.allow) > 0 && !contains(rule.allow, client.IP) {
+		return false
+	}
+	if len(rule.AllowCountries) > 0 && !containsString(rule.AllowCountries, client.Country) {
+		return false
+	}
+	return true
+}
+
+// Groups returns the route groups of a request, from the broadest to the narrowest
+func Groups(r *http.Request) []string {
+	path := r.URL.Path
+	groups := []string{GroupView}
+	if strings.HasPrefix(path, "/api/") {
+		groups = append(groups, GroupAPI)
+	}
+	if isAdmin(path) {
+		return append(groups, GroupAdmin)
+	}
+	if !strings.HasPrefix(path, "/api/") {
+		return groups
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/api/source/"), strings.HasPrefix(path, "/api/versions/"):
+		return append(groups, GroupEdit)
+	}
+
+	// Other requests changing data edit content, except logging in and out and the
+	// read-only endpoints that use POST for their payload
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return groups
+	}
+	switch {
+	case path == "/api/login", path == "/api/logout", path == "/api/search", path == "/api/render-markdown",
+		strings.HasPrefix(path, "/api/passkeys/login/"), path == "/api/notifications",
+		path == "/api/calendar/link":
+		return groups
+	}
+	return append(groups, GroupEdit)
+}
+
+// parseNetworks parses IPs and CIDR ranges
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// remoteIP returns the IP of the connection
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func upper(list []string) []string {
+	for i, item := range list {
+		list[i] = strings.ToUpper(strings.TrimSpace(item))
+	}
+	return list
+}
@@ -0,0 +1,239 @@
+// Package notebook renders Jupyter notebooks as the markdown of a page:
+// markdown cells as they are written, code cells as highlighted code blocks
+// and their saved outputs as static text, tables and images.
+package notebook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Extension is the extension of notebook files
+const Extension = ".ipynb"
+
+// ErrFormat is returned for files that aren't notebooks of a supported version
+var ErrFormat = errors.New("not a Jupyter notebook in nbformat 4")
+
+var (
+	ansiRegex       = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	scriptRegex     = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	attachmentRegex = regexp.MustCompile(`\]\(attachment:([^)\s]+)\)`)
+	blankLinesRegex = regexp.MustCompile(`\n[ \t]*\n+`)
+)
+
+// imageTypes are the output images shown, in order of preference
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// Notebook is a notebook in nbformat 4
+type Notebook struct {
+	Cells    []Cell   `json:"cells"`
+	Metadata Metadata `json:"metadata"`
+	Format   int      `json:"nbformat"`
+}
+
+// Metadata holds the language of the code cells of a notebook
+type Metadata struct {
+	KernelSpec struct {
+		Language    string `json:"language"`
+		DisplayName string `json:"display_name"`
+	} `json:"kernelspec"`
+	LanguageInfo struct {
+		Name string `json:"name"`
+	} `json:"language_info"`
+}
+
+// Cell is a markdown, code or raw cell
+type Cell struct {
+	Type           string                     `json:"cell_type"`
+	Source         Text                       `json:"source"`
+	ExecutionCount *int                       `json:"execution_count"`
+	Outputs        []Output                   `json:"outputs"`
+	Attachments    map[string]map[string]Text `json:"attachments"` // Images pasted in markdown cells, by name and MIME type
+}
+
+// Output is a saved output of a code cell
+type Output struct {
+	Type      string          `json:"output_type"` // stream, display_data, execute_result or error
+	Name      string          `json:"name"`        // stdout or stderr, for streams
+	Text      Text            `json:"text"`
+	Data      map[string]Text `json:"data"` // Representations of a result by MIME type
+	ErrorName string          `json:"ename"`
+	Value     string          `json:"evalue"`
+	Traceback []string        `json:"traceback"`
+}
+
+// Text is a multiline string, which notebooks store as a string or as a list
+// of lines. Other JSON values, such as application/json outputs, are kept as
+// their JSON.
+type Text string
+
+// UnmarshalJSON reads a string, a list of lines or any other value
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*t = Text(s)
+		return nil
+	}
+	var lines []string
+	if json.Unmarshal(data, &lines) == nil {
+		*t = Text(strings.Join(lines, ""))
+		return nil
+	}
+	*t = Text(data)
+	return nil
+}
+
+// Parse reads a notebook file
+func Parse(data []byte) (*Notebook, error) {
+	var notebook Notebook
+	if err := json.Unmarshal(data, &notebook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	if notebook.Format < 4 {
+		return nil, ErrFormat
+	}
+	return &notebook, nil
+}
+
+// Language returns the programming language of the code cells
+func (n *Notebook) Language() string {
+	if n.Metadata.LanguageInfo.Name != "" {
+		return strings.ToLower(n.Metadata.LanguageInfo.Name)
+	}
+	if n.Metadata.KernelSpec.Language != "" {
+		return strings.ToLower(n.Metadata.KernelSpec.Language)
+	}
+	return "python"
+}
+
+// Kernel returns the name of the kernel the notebook was run with
+func (n *Notebook) Kernel() string {
+	return n.Metadata.KernelSpec.DisplayName
+}
+
+// Markdown returns the notebook as markdown. Outputs are blocks of HTML,
+// separated by blank lines so the markdown around them is still read.
+// Scripts of outputs are left out, interactive outputs show their static form.
+func (n *Notebook) Markdown() string {
+	var b strings.Builder
+	language := n.Language()
+	for _, cell := range n.Cells {
+		switch cell.Type {
+		case "markdown":
+			b.WriteString(cell.markdown())
+			b.WriteString("\n\n")
+		case "code":
+			if strings.TrimSpace(string(cell.Source)) == "" && len(cell.Outputs) == 0 {
+				continue
+			}
+			b.WriteString(cell.code(language))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// markdown returns the source of a markdown cell, with its attached images
+// as data URLs
+func (c Cell) markdown() string {
+	source := strings.TrimRight(string(c.Source), "\n")
+	return attachmentRegex.ReplaceAllStringFunc(source, func(match string) string {
+		name := attachmentRegex.FindStringSubmatch(match)[1]
+		for mime, data := range c.Attachments[name] {
+			if strings.HasPrefix(mime, "image/") && mime != "image/svg+xml" {
+				return "](data:" + mime + ";base64," + compact(string(data)) + ")"
+			}
+		}
+		return match
+	})
+}
+
+// code returns a code cell with its prompt and outputs
+func (c Cell) code(language string) string {
+	var b strings.Builder
+	prompt := "In [ ]:"
+	if c.ExecutionCount != nil {
+		prompt = "In [" + strconv.Itoa(*c.ExecutionCount) + "]:"
+	}
+	source := strings.TrimRight(string(c.Source), "\n")
+	fence := "```"
+	for strings.Contains(source, fence) {
+		fence += "`"
+	}
+	b.WriteString(`<div class="notebook-prompt">` + prompt + "</div>\n\n")
+	b.WriteString(fence + language + "\n" + source + "\n" + fence + "\n\n")
+	for _, output := range c.Outputs {
+		if rendered := output.html(); rendered != "" {
+			b.WriteString(rendered)
+			b.WriteString("\n\n")
+		}
+	}
+	return b.String()
+}
+
+// html returns an output as a block of HTML, or markdown for text/markdown
+// results. Its richest representation the page can show is used.
+func (o Output) html() string {
+	switch o.Type {
+	case "stream":
+		class := "notebook-output notebook-stream"
+		if o.Name == "stderr" {
+			class += " notebook-stderr"
+		}
+		return preformatted(class, string(o.Text))
+	case "error":
+		text := strings.Join(o.Traceback, "\n")
+		if text == "" {
+			text = o.ErrorName + ": " + o.Value
+		}
+		return preformatted("notebook-output notebook-error", text)
+	case "display_data", "execute_result":
+		for _, mime := range imageTypes {
+			if data, ok := o.Data[mime]; ok {
+				return `<div class="notebook-output"><img src="data:` + mime + ";base64," + compact(string(data)) +
+					`" alt="Output" loading="lazy"></div>`
+			}
+		}
+		if svg, ok := o.Data["image/svg+xml"]; ok {
+			return `<div class="notebook-output">` + oneBlock(string(svg)) + `</div>`
+		}
+		if markup, ok := o.Data["text/html"]; ok {
+			return `<div class="notebook-output notebook-html">` + oneBlock(scriptRegex.ReplaceAllString(string(markup), "")) + `</div>`
+		}
+		if md, ok := o.Data["text/markdown"]; ok {
+			return strings.TrimSpace(string(md))
+		}
+		if latex, ok := o.Data["text/latex"]; ok {
+			return strings.TrimSpace(string(latex))
+		}
+		if text, ok := o.Data["text/plain"]; ok {
+			return preformatted("notebook-output", string(text))
+		}
+	}
+	return ""
+}
+
+// preformatted returns text in a pre element. Pre blocks of markdown run to
+// their closing tag, so the blank lines of the text are kept.
+func preformatted(class, text string) string {
+	text = strings.TrimRight(ansiRegex.ReplaceAllString(text, ""), "\n")
+	if text == "" {
+		return ""
+	}
+	return `<pre class="` + class + `">` + html.EscapeString(text) + `</pre>`
+}
+
+// oneBlock removes the blank lines of HTML, which would end its block in the
+// markdown and turn the rest into text
+func oneBlock(markup string) string {
+	return blankLinesRegex.ReplaceAllString(strings.TrimSpace(markup), "\n")
+}
+
+// compact removes the line breaks notebooks may store in base64 data
+func compact(data string) string {
+	return strings.Join(strings.Fields(data), "")
+}
@@ -0,0 +1,135 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// MaxPerUser is the number of notifications kept for each user
+const MaxPerUser = 100
+
+// Notification is a message shown to a user in the wiki
+type Notification struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Link    string    `json:"link,omitempty"`
+	Read    bool      `json:"read"`
+}
+
+var (
+	mutex          store.DataFile // Guards changes; lists are read without it, since files are replaced at once
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+)
+
+// userFile returns the file holding the notifications of a user
+func userFile(rootDir, user string) string {
+	return filepath.Join(rootDir, "notifications", unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// Add stores a new unread notification for user. The oldest notifications are
+// dropped once the user has more than MaxPerUser.
+func Add(rootDir, user, message, link string) error {
+	if user == "" {
+		return nil
+	}
+
+	unlock := mutex.Lock("notifications/" + user)
+	defer unlock()
+
+	list, err := load(rootDir, user)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	list = append(list, Notification{
+		ID:      fmt.Sprintf("%d", now.UnixNano()),
+		Time:    now,
+		Message: message,
+		Link:    link,
+	})
+	if len(list) > MaxPerUser {
+		list = list[len(list)-MaxPerUser:]
+	}
+
+	return save(rootDir, user, list)
+}
+
+// List returns the notifications of user, newest first
+func List(rootDir, user string) ([]Notification, error) {
+	list, err := load(rootDir, user)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Notification, len(list))
+	for i, n := range list {
+		result[len(list)-1-i] = n
+	}
+	return result, nil
+}
+
+// MarkAllRead marks every notification of user as read
+func MarkAllRead(rootDir, user string) error {
+	unlock := mutex.Lock("notifications/" + user)
+	defer unlock()
+
+	list, err := load(rootDir, user)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+
+	for i := range list {
+		list[i].Read = true
+	}
+	return save(rootDir, user, list)
+}
+
+// Delete removes the notifications of user
+func Delete(rootDir, user string) error {
+	unlock := mutex.Lock("notifications/" + user)
+	defer unlock()
+
+	if err := os.Remove(userFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load reads the stored notifications of user, oldest first
+func load(rootDir, user string) ([]Notification, error) {
+	data, err := os.ReadFile(userFile(rootDir, user))
+	if os.IsNotExist(err) {
+		return []Notification{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Notification
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// save writes the notifications of user
+func save(rootDir, user string, list []Notification) error {
+	path := userFile(rootDir, user)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(path, data, 0644)
+}
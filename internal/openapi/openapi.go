@@ -0,0 +1,199 @@
+// Package openapi reads OpenAPI 3 and Swagger 2 documents, written in YAML or
+// JSON, for the API documentation shown in pages.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxSize is the largest document read
+const MaxSize = 10 << 20
+
+// ErrFormat is returned for documents that aren't OpenAPI 3 or Swagger 2
+var ErrFormat = errors.New("not an OpenAPI 3 or Swagger 2 document")
+
+// Methods are the HTTP methods of operations, in the order they are listed
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is an API description: the operations listed where scripts
+// don't run, and the whole document as JSON for the viewer
+type Document struct {
+	Title      string
+	Version    string // Version of the API
+	Operations []Operation
+	JSON       []byte // The document, with its keys in the order they are written
+}
+
+// Operation is a method of a path
+type Operation struct {
+	Method     string
+	Path       string
+	Summary    string
+	Deprecated bool
+}
+
+// Parse reads an OpenAPI or Swagger document
+func Parse(data []byte) (*Document, error) {
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("document larger than %d MB", MaxSize>>20)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML or JSON: %w", err)
+	}
+	spec := resolve(&root)
+	if spec == nil || spec.Kind != yaml.MappingNode {
+		return nil, ErrFormat
+	}
+	openapi, swagger := scalar(spec, "openapi"), scalar(spec, "swagger")
+	if !strings.HasPrefix(openapi, "3.") && swagger != "2.0" {
+		return nil, ErrFormat
+	}
+
+	var buf bytes.Buffer
+	writeJSON(&buf, spec, 0)
+	document := &Document{JSON: buf.Bytes()}
+	if info := field(spec, "info"); info != nil {
+		document.Title = scalar(info, "title")
+		document.Version = scalar(info, "version")
+	}
+	if paths := field(spec, "paths"); paths != nil && paths.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(paths.Content); i += 2 {
+			item := resolve(paths.Content[i+1])
+			for _, method := range Methods {
+				operation := field(item, method)
+				if operation == nil {
+					continue
+				}
+				document.Operations = append(document.Operations, Operation{
+					Method:     method,
+					Path:       paths.Content[i].Value,
+					Summary:    scalar(operation, "summary"),
+					Deprecated: scalar(operation, "deprecated") == "true",
+				})
+			}
+		}
+	}
+	return document, nil
+}
+
+// resolve returns the node a document or alias node stands for
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// field returns the value of a key of a mapping, nil when it has none
+func field(mapping *yaml.Node, key string) *yaml.Node {
+	mapping = resolve(mapping)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return resolve(mapping.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// scalar returns the text of a scalar value of a mapping
+func scalar(mapping *yaml.Node, key string) string {
+	if value := field(mapping, key); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
+
+// maxDepth limits the nesting of documents, which aliases could make endless
+const maxDepth = 64
+
+// writeJSON writes a node as JSON. Keys keep their order, so the viewer lists
+// paths and properties as they are written.
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, depth int) {
+	node = resolve(node)
+	if node == nil || depth > maxDepth {
+		buf.WriteString("null")
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if len(seen) > 1 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, key)
+			buf.WriteByte(':')
+			writeJSON(buf, node.Content[i+1], depth+1)
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item, depth+1)
+		}
+		buf.WriteByte(']')
+	default:
+		writeScalar(buf, node)
+	}
+}
+
+// writeScalar writes a scalar as the JSON value of its YAML type
+func writeScalar(buf *bytes.Buffer, node *yaml.Node) {
+	switch node.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+		return
+	case "!!bool":
+		if value, err := strconv.ParseBool(strings.ToLower(node.Value)); err == nil {
+			buf.WriteString(strconv.FormatBool(value))
+			return
+		}
+	case "!!int":
+		if value, err := strconv.ParseInt(strings.ReplaceAll(node.Value, "_", ""), 0, 64); err == nil {
+			buf.WriteString(strconv.FormatInt(value, 10))
+			return
+		}
+	case "!!float":
+		if value, err := strconv.ParseFloat(strings.ReplaceAll(node.Value, "_", ""), 64); err == nil && !math.IsInf(value, 0) && !math.IsNaN(value) {
+			buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+			return
+		}
+	}
+	writeString(buf, node.Value)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
@@ -0,0 +1,176 @@
+package ownership
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/filecache"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/store"
+)
+
+// Rule assigns owners to the pages matching a path. A path covers the page itself
+// and everything below it; * and ? wildcards match within a path segment.
+type Rule struct {
+	Path   string   `yaml:"path"`
+	Owners []string `yaml:"owners"`
+}
+
+// File holds the ownership rules and the named groups they can refer to.
+// Like CODEOWNERS, the last matching rule wins.
+type File struct {
+	Groups map[string][]string `yaml:"groups"`
+	Rules  []Rule              `yaml:"rules"`
+}
+
+// cache avoids re-reading the ownership file on every request
+var cache filecache.Cache[*File]
+
+// FilePath returns the location of the ownership rules
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "owners.yaml")
+}
+
+// Load returns the current ownership rules, empty ones when the file does not exist
+func Load(rootDir string) (*File, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML ownership rules
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	for name := range file.Groups {
+		if isRoleGroup(name) {
+			return nil, fmt.Errorf("group %q conflicts with a role name", name)
+		}
+	}
+	for i, rule := range file.Rules {
+		if strings.TrimSpace(rule.Path) == "" {
+			return nil, fmt.Errorf("rule %d has no path", i+1)
+		}
+		if _, err := path.Match(strings.Trim(rule.Path, "/"), ""); err != nil {
+			return nil, fmt.Errorf("rule %d has an invalid path pattern %q", i+1, rule.Path)
+		}
+		for _, owner := range rule.Owners {
+			if group, ok := strings.CutPrefix(owner, "@"); ok && !isRoleGroup(group) {
+				if _, exists := file.Groups[group]; !exists {
+					return nil, fmt.Errorf("rule %d refers to unknown group %q", i+1, owner)
+				}
+			}
+		}
+	}
+
+	return &file, nil
+}
+
+// ReadRaw returns the ownership file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the ownership file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// Owners returns the owners of a page as written in the rules (usernames and
+// @groups). Owners set in the page frontmatter take precedence over the rules.
+func (f *File) Owners(docPath string, pageOwners []string) []string {
+	if len(pageOwners) > 0 {
+		return pageOwners
+	}
+
+	docPath = strings.Trim(docPath, "/")
+	var owners []string
+	for _, rule := range f.Rules {
+		if matchRule(strings.Trim(rule.Path, "/"), docPath) {
+			owners = rule.Owners
+		}
+	}
+	return owners
+}
+
+// Users expands owners into usernames. @admin, @editor and @viewer refer to all
+// users with that role, other @names to the groups of the ownership file.
+func (f *File) Users(owners []string, users []config.User) []string {
+	seen := make(map[string]bool)
+	var result []string
+	add := func(username string) {
+		if username != "" && !seen[username] {
+			seen[username] = true
+			result = append(result, username)
+		}
+	}
+
+	for _, owner := range owners {
+		group, isGroup := strings.CutPrefix(owner, "@")
+		switch {
+		case !isGroup:
+			add(owner)
+		case isRoleGroup(group):
+			for _, user := range users {
+				if user.Role == group {
+					add(user.Username)
+				}
+			}
+		default:
+			for _, member := range f.Groups[group] {
+				add(member)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// matchRule reports whether a rule path covers docPath. The pattern must match
+// the leading segments of the path, so a directory rule covers its whole subtree.
+func matchRule(pattern, docPath string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(docPath, "/")
+	if docPath == "" || len(pathParts) < len(patternParts) {
+		return false
+	}
+
+	for i, part := range patternParts {
+		if ok, _ := path.Match(part, pathParts[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isRoleGroup reports whether name is a role usable as an owner group
+func isRoleGroup(name string) bool {
+	return name == roles.RoleAdmin || name == roles.RoleEditor || name == roles.RoleViewer
+}
@@ -0,0 +1,678 @@
+// Package pagequery evaluates the expressions of page queries: computed
+// columns, filters and groups over the metadata of a page, like
+//
+//	days_since(last_reviewed) > 90 and tier <= 2
+//
+// Names refer to the metadata of the page and its frontmatter fields. Values
+// are nil, numbers (float64), strings, booleans, times and lists.
+package pagequery
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Lookup returns the value of a name for the page an expression is evaluated
+// for, nil when it has none
+type Lookup func(name string) interface{}
+
+// Expr is a compiled expression
+type Expr struct {
+	source string
+	root   node
+}
+
+// node is a part of an expression
+type node interface {
+	eval(lookup Lookup, now time.Time) interface{}
+}
+
+// maxExpressionLength keeps expressions written in pages and queries short
+const maxExpressionLength = 500
+
+// Compile parses an expression
+func Compile(source string) (*Expr, error) {
+	if len(source) > maxExpressionLength {
+		return nil, fmt.Errorf("expression longer than %d characters", maxExpressionLength)
+	}
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// String returns the expression as written
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression. Operations on values of the wrong type give
+// nil rather than failing.
+func (e *Expr) Eval(lookup Lookup, now time.Time) interface{} {
+	return e.root.eval(lookup, now)
+}
+
+// Truthy reports whether a value counts as true: true, numbers other than
+// zero, and strings and lists that aren't empty
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// Text writes a value as text: numbers without needless decimals, dates of
+// midnight as dates and lists joined with commas
+func Text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = Text(item)
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// Compare orders two values: numbers as numbers, times as times and
+// anything else as text, ignoring case
+func Compare(a, b interface{}) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := date(a); ok {
+		if y, ok := date(b); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(strings.ToLower(Text(a)), strings.ToLower(Text(b)))
+}
+
+// number returns a value as a number, also when it is written as text
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// date returns a value as a time, also when it is a date written as text
+func date(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if t, err := time.Parse("2006-01-02", strings.TrimSpace(v)); err == nil {
+			return t, true
+		}
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(v)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Tokens
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenString
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/", "%", "(", ")", ","}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i])})
+		case r == '"' || r == '\'':
+			var text strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{tokenString, text.String()})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenName, string(runes[start:i])})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokenOperator, op})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q", string(r))
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// Parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token when it is one of the operators or words
+func (p *parser) accept(texts ...string) (string, bool) {
+	next := p.peek()
+	if next.kind != tokenOperator && next.kind != tokenName {
+		return "", false
+	}
+	for _, text := range texts {
+		if next.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expression() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{or: true, left: left, right: right}
+	}
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = logical{left: left, right: right}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "=", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) product() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic{op: "-", left: literal{0.0}, right: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	next := p.tokens[p.pos]
+	p.pos++
+	switch next.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(next.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", next.text)
+		}
+		return literal{n}, nil
+	case tokenString:
+		return literal{next.text}, nil
+	case tokenName:
+		switch next.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null", "nil":
+			return literal{nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.call(next.text)
+		}
+		return name{next.text}, nil
+	}
+	if next.text == "(" {
+		inner, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", next.text)
+}
+
+func (p *parser) call(function string) (node, error) {
+	spec, ok := functions[function]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", function)
+	}
+	var args []node
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(")"); ok {
+				break
+			}
+			if _, ok := p.accept(","); !ok {
+				return nil, fmt.Errorf("missing ) after the arguments of %s()", function)
+			}
+		}
+	}
+	if len(args) < spec.min || spec.max >= 0 && len(args) > spec.max {
+		return nil, fmt.Errorf("wrong number of arguments for %s()", function)
+	}
+	return call{spec: spec, args: args}, nil
+}
+
+// Nodes
+
+type literal struct{ value interface{} }
+
+func (l literal) eval(Lookup, time.Time) interface{} { return l.value }
+
+type name struct{ name string }
+
+func (n name) eval(lookup Lookup, _ time.Time) interface{} { return normalize(lookup(n.name)) }
+
+// normalize turns the values of frontmatter into the values of expressions
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+type negation struct{ operand node }
+
+func (n negation) eval(lookup Lookup, now time.Time) interface{} {
+	return !Truthy(n.operand.eval(lookup, now))
+}
+
+type logical struct {
+	or          bool
+	left, right node
+}
+
+func (l logical) eval(lookup Lookup, now time.Time) interface{} {
+	left := Truthy(l.left.eval(lookup, now))
+	if l.or {
+		return left || Truthy(l.right.eval(lookup, now))
+	}
+	return left && Truthy(l.right.eval(lookup, now))
+}
+
+type comparison struct {
+	op          string
+	left, right node
+}
+
+func (c comparison) eval(lookup Lookup, now time.Time) interface{} {
+	a, b := c.left.eval(lookup, now), c.right.eval(lookup, now)
+	switch c.op {
+	case "==", "=":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	}
+	// Missing values aren't smaller or greater than anything
+	if a == nil || b == nil {
+		return false
+	}
+	n := Compare(a, b)
+	switch c.op {
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	}
+	return n >= 0
+}
+
+// equal compares values, a list equals a value it contains
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if list, ok := a.([]interface{}); ok {
+		if _, isList := b.([]interface{}); !isList {
+			for _, item := range list {
+				if equal(item, b) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return Compare(a, b) == 0
+}
+
+type arithmetic struct {
+	op          string
+	left, right node
+}
+
+func (a arithmetic) eval(lookup Lookup, now time.Time) interface{} {
+	left, right := a.left.eval(lookup, now), a.right.eval(lookup, now)
+	x, okX := number(left)
+	y, okY := number(right)
+	if !okX || !okY {
+		// + joins text
+		if a.op == "+" && left != nil && right != nil {
+			return Text(left) + Text(right)
+		}
+		return nil
+	}
+	var result float64
+	switch a.op {
+	case "+":
+		result = x + y
+	case "-":
+		result = x - y
+	case "*":
+		result = x * y
+	case "/":
+		result = x / y
+	case "%":
+		result = math.Mod(x, y)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return nil
+	}
+	return result
+}
+
+// Functions
+
+type function struct {
+	min, max int // Number of arguments, max -1 for any
+	call     func(args []interface{}, lookup Lookup, now time.Time) interface{}
+}
+
+type call struct {
+	spec function
+	args []node
+}
+
+func (c call) eval(lookup Lookup, now time.Time) interface{} {
+	args := make([]interface{}, len(c.args))
+	for i, arg := range c.args {
+		args[i] = arg.eval(lookup, now)
+	}
+	return c.spec.call(args, lookup, now)
+}
+
+// days returns the whole days from one time to another, by their calendar
+// dates where they are
+func days(from, to time.Time) float64 {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return math.Round(to.Sub(from).Hours() / 24)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		// days_since(date) is the number of days from a date until today
+		"days_since": {1, 1, func(args []interface{}, _ Lookup, now time.Time) interface{} {
+			if t, ok := date(args[0]); ok {
+				return days(t, now)
+			}
+			return nil
+		}},
+		// days_until(date) is the number of days from today until a date
+		"days_until": {1, 1, func(args []interface{}, _ Lookup, now time.Time) interface{} {
+			if t, ok := date(args[0]); ok {
+				return days(now, t)
+			}
+			return nil
+		}},
+		// len(value) is the number of items of a list or characters of text
+		"len": {1, 1, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			switch v := args[0].(type) {
+			case nil:
+				return 0.0
+			case []interface{}:
+				return float64(len(v))
+			}
+			return float64(len([]rune(Text(args[0]))))
+		}},
+		// contains(list or text, value)
+		"contains": {2, 2, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			if list, ok := args[0].([]interface{}); ok {
+				return equal(list, args[1])
+			}
+			return args[0] != nil && strings.Contains(strings.ToLower(Text(args[0])), strings.ToLower(Text(args[1])))
+		}},
+		"lower": {1, 1, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			return strings.ToLower(Text(args[0]))
+		}},
+		"upper": {1, 1, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			return strings.ToUpper(Text(args[0]))
+		}},
+		"round": {1, 1, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			if n, ok := number(args[0]); ok {
+				return math.Round(n)
+			}
+			return nil
+		}},
+		// default(value, ...) is the first value that is set
+		"default": {1, -1, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			for _, arg := range args {
+				if arg != nil && Text(arg) != "" {
+					return arg
+				}
+			}
+			return nil
+		}},
+		// if(condition, then, else)
+		"if": {2, 3, func(args []interface{}, _ Lookup, _ time.Time) interface{} {
+			if Truthy(args[0]) {
+				return args[1]
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+			return nil
+		}},
+		// field(name) is the value of a field whose name isn't a valid name
+		// in expressions, like "on-call"
+		"field": {1, 1, func(args []interface{}, lookup Lookup, _ time.Time) interface{} {
+			return normalize(lookup(Text(args[0])))
+		}},
+	}
+}
@@ -0,0 +1,119 @@
+package pagestats
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Contributor is what a user did in the months of a contributors report
+type Contributor struct {
+	User     string    `json:"user"`
+	Edits    int       `json:"edits"`    // Edits in the months of the report
+	Minor    int       `json:"minor"`    // Minor edits among them
+	Pages    int       `json:"pages"`    // Distinct documents edited in the months of the report
+	Created  int       `json:"created"`  // Documents created in the months of the report
+	PerMonth []int     `json:"perMonth"` // Edits in each month of the report, oldest first
+	Total    int       `json:"total"`    // Every recorded edit
+	Last     time.Time `json:"last"`     // Time of their last edit
+}
+
+// ContributorsReport counts the edits of every user per month
+type ContributorsReport struct {
+	Months       []string      `json:"months"` // Months of the report as "2006-01", oldest first
+	Contributors []Contributor `json:"contributors"`
+}
+
+// Contributors reports the edits of every user in the last months before
+// now, the current month included. Users who only edited before are listed
+// too, with their total, after those who edited in the months of the report.
+func Contributors(rootDir string, months int, now time.Time) (*ContributorsReport, error) {
+	now = now.Local()
+	first := time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.Local)
+	report := &ContributorsReport{Months: make([]string, months)}
+	for i := range report.Months {
+		report.Months[i] = first.AddDate(0, i, 0).Format("2006-01")
+	}
+
+	byUser := make(map[string]*Contributor)
+	err := walkRecords(rootDir, func(relativePath, name string) error {
+		if name != historyFile {
+			return nil
+		}
+		history, err := LoadHistory(rootDir, relativePath)
+		if err != nil || len(history) == 0 {
+			return err
+		}
+
+		edited := make(map[string]bool)
+		for i, edit := range history {
+			c, ok := byUser[edit.User]
+			if !ok {
+				c = &Contributor{User: edit.User, PerMonth: make([]int, months)}
+				byUser[edit.User] = c
+			}
+			c.Total++
+			if edit.Time.After(c.Last) {
+				c.Last = edit.Time
+			}
+
+			t := edit.Time.Local()
+			month := (t.Year()-first.Year())*12 + int(t.Month()) - int(first.Month())
+			if month < 0 || month >= months {
+				continue
+			}
+			c.Edits++
+			c.PerMonth[month]++
+			if edit.Minor {
+				c.Minor++
+			}
+			if !edited[edit.User] {
+				edited[edit.User] = true
+				c.Pages++
+			}
+			if i == 0 && created(rootDir, relativePath, edit) {
+				c.Created++
+			}
+		}
+		return nil
+	})
+
+	report.Contributors = make([]Contributor, 0, len(byUser))
+	for _, c := range byUser {
+		report.Contributors = append(report.Contributors, *c)
+	}
+	sort.Slice(report.Contributors, func(i, j int) bool {
+		a, b := report.Contributors[i], report.Contributors[j]
+		if a.Edits != b.Edits {
+			return a.Edits > b.Edits
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.User < b.User
+	})
+	return report, err
+}
+
+// created reports whether the first recorded edit of a document created it.
+// Documents edited before their history was recorded have versions stored
+// from before that edit.
+func created(rootDir, relativePath string, first Edit) bool {
+	entries, err := os.ReadDir(filepath.Join(rootDir, "versions", relativePath))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".md") || len(name) != len("20060102150405.md") {
+			continue
+		}
+		stamp, err := time.ParseInLocation("20060102150405", strings.TrimSuffix(name, ".md"), time.Local)
+		if err == nil && !stamp.After(first.Time) {
+			return false
+		}
+	}
+	return true
+}
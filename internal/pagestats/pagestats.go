@@ -0,0 +1,205 @@
+package pagestats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/store"
+)
+
+// WordsPerMinute is the reading speed used for reading time estimates
+const WordsPerMinute = 200
+
+// historyFile is stored next to the versions of a document
+const historyFile = "history.json"
+
+// Edit is a single recorded change to a document
+type Edit struct {
+	User    string    `json:"user"`
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary,omitempty"` // Description of the change, when one was given
+	Minor   bool      `json:"minor,omitempty"`   // Small change, like a typo fix, owners and watchers weren't told about
+}
+
+// Stats holds the statistics of a single page
+type Stats struct {
+	Words          int       `json:"words"`
+	ReadingMinutes int       `json:"readingMinutes"`
+	Revisions      int       `json:"revisions"`
+	Contributors   []string  `json:"contributors"`
+	LastModified   time.Time `json:"lastModified"`
+}
+
+var (
+	records       store.DataFile // Guards the history and uploads files
+	htmlTagRegex  = regexp.MustCompile(`<[^>]+>`)
+	linkURLRegex  = regexp.MustCompile(`\]\([^)]*\)`)
+	shortcodeLine = regexp.MustCompile(`^:::.*$`)
+)
+
+// CountWords counts the words of a markdown document, ignoring frontmatter,
+// code blocks, HTML tags, link targets and shortcode lines.
+func CountWords(markdown string) int {
+	_, body, _ := frontmatter.Parse(markdown)
+
+	count := 0
+	inBacktickBlock := false
+	inTildeBlock := false
+
+	for _, line := range strings.Split(body, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+
+		if !inTildeBlock && strings.HasPrefix(trimmedLine, "```") {
+			inBacktickBlock = !inBacktickBlock
+			continue
+		}
+		if !inBacktickBlock && strings.HasPrefix(trimmedLine, "~~~") {
+			inTildeBlock = !inTildeBlock
+			continue
+		}
+		if inBacktickBlock || inTildeBlock || shortcodeLine.MatchString(trimmedLine) {
+			continue
+		}
+
+		line = linkURLRegex.ReplaceAllString(line, "]")
+		line = htmlTagRegex.ReplaceAllString(line, " ")
+
+		for _, field := range strings.Fields(line) {
+			if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) != -1 {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// ReadingMinutes estimates the reading time of a number of words, rounded up
+func ReadingMinutes(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// RecordEdit appends an edit by user to the history of a document. relativePath
+// is the same path used for versioning (e.g. "documents/guide/intro" or "pages/home").
+func RecordEdit(rootDir, relativePath, user string) error {
+	return RecordEditSummary(rootDir, relativePath, user, "")
+}
+
+// RecordEditSummary records an edit like RecordEdit, along with a summary of
+// the change
+func RecordEditSummary(rootDir, relativePath, user, summary string) error {
+	return Record(rootDir, relativePath, Edit{User: user, Summary: summary})
+}
+
+// Record records an edit of a document, made now when its time isn't set
+func Record(rootDir, relativePath string, edit Edit) error {
+	unlock := records.Lock(filepath.Join(relativePath, historyFile))
+	defer unlock()
+
+	history, err := LoadHistory(rootDir, relativePath)
+	if err != nil {
+		return err
+	}
+	if edit.Time.IsZero() {
+		edit.Time = time.Now()
+	}
+	history = append(history, edit)
+
+	dir := filepath.Join(rootDir, "versions", relativePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(dir, historyFile), data, 0644)
+}
+
+// LoadHistory returns the recorded edits of a document, oldest first
+func LoadHistory(rootDir, relativePath string) ([]Edit, error) {
+	data, err := os.ReadFile(filepath.Join(rootDir, "versions", relativePath, historyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []Edit
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Compute collects the statistics of the document stored at docFile. At most
+// maxContributors distinct contributors are returned, most recent first.
+func Compute(rootDir, relativePath, docFile string, maxContributors int) (*Stats, error) {
+	info, err := os.Stat(docFile)
+	if err != nil {
+		return nil, err
+	}
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Words:        CountWords(string(content)),
+		LastModified: info.ModTime(),
+		Contributors: []string{},
+	}
+	stats.ReadingMinutes = ReadingMinutes(stats.Words)
+
+	history, err := LoadHistory(rootDir, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := len(history) - 1; i >= 0 && len(stats.Contributors) < maxContributors; i-- {
+		user := history[i].User
+		if user == "" || seen[user] {
+			continue
+		}
+		seen[user] = true
+		stats.Contributors = append(stats.Contributors, user)
+	}
+
+	// Pages edited before history was recorded only have their stored versions
+	stats.Revisions = len(history)
+	if versions := countVersions(filepath.Join(rootDir, "versions", relativePath)) + 1; versions > stats.Revisions {
+		stats.Revisions = versions
+	}
+
+	return stats, nil
+}
+
+// countVersions counts the stored versions of a document
+func countVersions(versionDir string) int {
+	entries, err := os.ReadDir(versionDir)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".md") && len(name) == len("20060102150405.md") {
+			count++
+		}
+	}
+	return count
+}
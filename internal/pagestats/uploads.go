@@ -0,0 +1,194 @@
+package pagestats
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// uploadsFile is stored next to the history of a document
+const uploadsFile = "uploads.json"
+
+// Upload is an attachment added to a document
+type Upload struct {
+	User string    `json:"user"`
+	Time time.Time `json:"time"`
+	File string    `json:"file"`
+}
+
+// RecordUpload appends an attachment uploaded by user to the uploads of a
+// document. relativePath is the same path used for versioning.
+func RecordUpload(rootDir, relativePath, user, file string) error {
+	unlock := records.Lock(filepath.Join(relativePath, uploadsFile))
+	defer unlock()
+
+	uploads, err := LoadUploads(rootDir, relativePath)
+	if err != nil {
+		return err
+	}
+	uploads = append(uploads, Upload{User: user, Time: time.Now(), File: file})
+	return writeJSON(filepath.Join(rootDir, "versions", relativePath, uploadsFile), uploads)
+}
+
+// LoadUploads returns the recorded uploads of a document, oldest first
+func LoadUploads(rootDir, relativePath string) ([]Upload, error) {
+	var uploads []Upload
+	err := readJSON(filepath.Join(rootDir, "versions", relativePath, uploadsFile), &uploads)
+	return uploads, err
+}
+
+// Activity is what a user did to a single document
+type Activity struct {
+	Path    string   `json:"path"` // Versioning path of the document, e.g. "documents/guide/intro"
+	Edits   []Edit   `json:"edits,omitempty"`
+	Uploads []Upload `json:"uploads,omitempty"`
+	Created bool     `json:"created,omitempty"` // The user created the document
+}
+
+// UserActivity returns the recorded edits and uploads of user on every document
+func UserActivity(rootDir, user string) ([]Activity, error) {
+	byPath := make(map[string]*Activity)
+	var list []*Activity
+	activity := func(relativePath string) *Activity {
+		if a, ok := byPath[relativePath]; ok {
+			return a
+		}
+		a := &Activity{Path: relativePath}
+		byPath[relativePath] = a
+		list = append(list, a)
+		return a
+	}
+
+	err := walkRecords(rootDir, func(relativePath, name string) error {
+		if name == historyFile {
+			history, err := LoadHistory(rootDir, relativePath)
+			if err != nil {
+				return err
+			}
+			for i, edit := range history {
+				if edit.User == user {
+					a := activity(relativePath)
+					a.Edits = append(a.Edits, edit)
+					if i == 0 && created(rootDir, relativePath, edit) {
+						a.Created = true
+					}
+				}
+			}
+			return nil
+		}
+		uploads, err := LoadUploads(rootDir, relativePath)
+		if err != nil {
+			return err
+		}
+		for _, upload := range uploads {
+			if upload.User == user {
+				a := activity(relativePath)
+				a.Uploads = append(a.Uploads, upload)
+			}
+		}
+		return nil
+	})
+
+	result := make([]Activity, 0, len(list))
+	for _, a := range list {
+		result = append(result, *a)
+	}
+	return result, err
+}
+
+// Reattribute credits the edits and uploads of user on every document to
+// another name, keeping their times and summaries. It returns the number of
+// changed edits and uploads.
+func Reattribute(rootDir, user, to string) (int, int, error) {
+	edits, uploads := 0, 0
+	err := walkRecords(rootDir, func(relativePath, name string) error {
+		unlock := records.Lock(filepath.Join(relativePath, name))
+		defer unlock()
+
+		path := filepath.Join(rootDir, "versions", relativePath, name)
+		if name == historyFile {
+			history, err := LoadHistory(rootDir, relativePath)
+			if err != nil {
+				return err
+			}
+			changed := 0
+			for i := range history {
+				if history[i].User == user {
+					history[i].User = to
+					changed++
+				}
+			}
+			edits += changed
+			if changed == 0 {
+				return nil
+			}
+			return writeJSON(path, history)
+		}
+
+		list, err := LoadUploads(rootDir, relativePath)
+		if err != nil {
+			return err
+		}
+		changed := 0
+		for i := range list {
+			if list[i].User == user {
+				list[i].User = to
+				changed++
+			}
+		}
+		uploads += changed
+		if changed == 0 {
+			return nil
+		}
+		return writeJSON(path, list)
+	})
+	return edits, uploads, err
+}
+
+// walkRecords calls fn for every history and uploads file below the versions directory
+func walkRecords(rootDir string, fn func(relativePath, name string) error) error {
+	versionsDir := filepath.Join(rootDir, "versions")
+	err := filepath.WalkDir(versionsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || (entry.Name() != historyFile && entry.Name() != uploadsFile) {
+			return nil
+		}
+		relativePath, err := filepath.Rel(versionsDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(relativePath), entry.Name())
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,429 @@
+// Package pandoc converts documents between markdown and other formats with
+// pandoc, run as a local binary or reached as a pandoc server.
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/safehttp"
+)
+
+// Markdown is the pandoc format pages are read and written as
+const Markdown = "gfm"
+
+// maxOutput is the largest document or media file a conversion may produce
+const maxOutput = 64 << 20
+
+// ErrUnsupported is returned for formats that can't be converted
+var ErrUnsupported = errors.New("unsupported format")
+
+// ImportFormats maps the extensions of importable files to pandoc input formats
+var ImportFormats = map[string]string{
+	".docx":      "docx",
+	".odt":       "odt",
+	".rtf":       "rtf",
+	".epub":      "epub",
+	".rst":       "rst",
+	".adoc":      "asciidoc",
+	".asciidoc":  "asciidoc",
+	".org":       "org",
+	".tex":       "latex",
+	".textile":   "textile",
+	".mediawiki": "mediawiki",
+	".html":      "html",
+	".htm":       "html",
+}
+
+// ExportFormat is a format pages can be exported to
+type ExportFormat struct {
+	Name      string // Pandoc output format
+	Extension string
+	MimeType  string
+	Binary    bool // Output is not text
+}
+
+// ExportFormats are the formats pages can be exported to, by name
+var ExportFormats = map[string]ExportFormat{
+	"docx":     {"docx", ".docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", true},
+	"odt":      {"odt", ".odt", "application/vnd.oasis.opendocument.text", true},
+	"epub":     {"epub3", ".epub", "application/epub+zip", true},
+	"asciidoc": {"asciidoc", ".adoc", "text/plain; charset=utf-8", false},
+	"rst":      {"rst", ".rst", "text/plain; charset=utf-8", false},
+	"latex":    {"latex", ".tex", "text/plain; charset=utf-8", false},
+	"html":     {"html5", ".html", "text/html; charset=utf-8", false},
+}
+
+// File is a media file of a document
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Converter runs conversions with the binary at Command, or with the pandoc
+// server at ServerURL when it is set
+type Converter struct {
+	Command   string
+	ServerURL string
+	Timeout   time.Duration
+}
+
+// ToMarkdown converts a document to markdown. Images extracted from the
+// document are returned with the names the markdown refers to them by.
+// Images are only extracted by the binary, the server can't return them.
+func (c Converter) ToMarkdown(name string, data []byte) (string, []File, error) {
+	from, ok := ImportFormats[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(name))
+	}
+	if c.ServerURL != "" {
+		output, err := c.serve(serverRequest{From: from, To: Markdown, Text: inputText(from, data)})
+		return string(output), nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "wiki-pandoc-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := "input" + strings.ToLower(filepath.Ext(name))
+	if err := os.WriteFile(filepath.Join(dir, input), data, 0600); err != nil {
+		return "", nil, err
+	}
+	// Readers such as rst may include other files, which the sandbox refuses
+	output, err := c.run(dir, nil, "--sandbox", "--from", from, "--to", Markdown, "--wrap=none", "--extract-media=media", input)
+	if err != nil {
+		return "", nil, err
+	}
+	markdown, media, err := collectMedia(string(output), dir)
+	return markdown, media, err
+}
+
+// FromMarkdown converts markdown to a format of ExportFormats. The markdown
+// is read into pandoc's document tree first, where images are limited to the
+// files of resourceDir and remote images become links, so a conversion never
+// reads other files or makes requests.
+func (c Converter) FromMarkdown(markdown, format, title, resourceDir string) ([]byte, error) {
+	export, ok := ExportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, format)
+	}
+	tree, err := c.convert(Markdown, "json", []byte(markdown), nil)
+	if err != nil {
+		return nil, err
+	}
+	files := resourceFiles(markdown, resourceDir)
+	tree, err = prepareTree(tree, export, title, files)
+	if err != nil {
+		return nil, err
+	}
+	return c.convert("json", export.Name, tree, files)
+}
+
+// convert runs one conversion. Files are resources the document refers to
+// by name.
+func (c Converter) convert(from, to string, input []byte, files map[string][]byte) ([]byte, error) {
+	if c.ServerURL != "" {
+		encoded := make(map[string]string, len(files))
+		for name, data := range files {
+			encoded[name] = base64.StdEncoding.EncodeToString(data)
+		}
+		return c.serve(serverRequest{From: from, To: to, Text: inputText(from, input), Standalone: to != "json", Files: encoded})
+	}
+
+	dir, err := os.MkdirTemp("", "wiki-pandoc-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"--from", from, "--to", to, "--output", "output"}
+	if to != "json" {
+		args = append(args, "--standalone", "--resource-path", ".")
+	}
+	if to == "json" {
+		// Reading markdown needs no files. Writers aren't sandboxed, as some
+		// builds of pandoc keep the data files they need on disk, and the
+		// trees they write are prepared not to refer to other files.
+		args = append(args, "--sandbox")
+	}
+	if _, err := c.run(dir, input, args...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(dir, "output"))
+}
+
+// run starts the binary in dir with the arguments, returning what it wrote
+func (c Converter) run(dir string, stdin []byte, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	command := c.Command
+	if command == "" {
+		command = "pandoc"
+	}
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("pandoc took longer than %s", c.Timeout)
+		}
+		return nil, fmt.Errorf("pandoc failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() > maxOutput {
+		return nil, errors.New("pandoc output is too large")
+	}
+	return stdout.Bytes(), nil
+}
+
+// serverRequest is a conversion sent to a pandoc server
+type serverRequest struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Text       string            `json:"text"`
+	Standalone bool              `json:"standalone,omitempty"`
+	Files      map[string]string `json:"files,omitempty"` // Base64 content of resources, by name
+}
+
+// serverResponse is the result of a conversion by a pandoc server
+type serverResponse struct {
+	Output   string `json:"output"`
+	Base64   bool   `json:"base64"`
+	Error    string `json:"error"`
+	Messages []struct {
+		Verbosity string `json:"verbosity"`
+		Message   string `json:"message"`
+	} `json:"messages"`
+}
+
+// serve runs a conversion on the pandoc server
+func (c Converter) serve(request serverRequest) ([]byte, error) {
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pandoc server URL: %w", err)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server is set up by the admin, usually next to the wiki
+	policy := safehttp.Policy{
+		AllowedHosts: []string{u.Hostname()},
+		AllowPrivate: true,
+		MaxBytes:     maxOutput * 2,
+		Timeout:      c.Timeout,
+		Header:       map[string][]string{"Accept": {"application/json"}},
+	}
+	status, data, err := policy.Post(c.ServerURL, "application/json", body)
+	if err != nil {
+		return nil, fmt.Errorf("pandoc server: %w", err)
+	}
+
+	var response serverResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("pandoc server responded with status %d: %s", status, strings.TrimSpace(string(data)))
+	}
+	if status != 200 || response.Error != "" {
+		message := response.Error
+		for _, m := range response.Messages {
+			if m.Verbosity == "ERROR" {
+				message = m.Message
+			}
+		}
+		return nil, fmt.Errorf("pandoc server responded with status %d: %s", status, message)
+	}
+	if response.Base64 {
+		return base64.StdEncoding.DecodeString(response.Output)
+	}
+	return []byte(response.Output), nil
+}
+
+// inputText returns a document the way the server reads it, binary formats
+// encoded as base64
+func inputText(format string, data []byte) string {
+	switch format {
+	case "docx", "odt", "epub":
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return string(data)
+}
+
+// collectMedia reads the media pandoc extracted below dir. Pandoc keeps the
+// folders of the document, which are flattened as pages keep their files
+// next to them. The markdown is changed to refer to the new names.
+func collectMedia(markdown, dir string) (string, []File, error) {
+	var paths []string
+	err := filepath.WalkDir(filepath.Join(dir, "media"), func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(paths)
+
+	var files []File
+	used := make(map[string]bool)
+	var replacements []string
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(data) > maxOutput {
+			continue
+		}
+		rel, _ := filepath.Rel(dir, p)
+		name := path.Base(filepath.ToSlash(rel))
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			ext := path.Ext(name)
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path.Base(filepath.ToSlash(rel)), ext), i, ext)
+		}
+		used[strings.ToLower(name)] = true
+		files = append(files, File{Name: name, Data: data})
+		replacements = append(replacements, filepath.ToSlash(rel), name)
+	}
+	return strings.NewReplacer(replacements...).Replace(markdown), files, nil
+}
+
+// resourceFiles returns the files of resourceDir the markdown mentions
+func resourceFiles(markdown, resourceDir string) map[string][]byte {
+	entries, err := os.ReadDir(resourceDir)
+	if err != nil {
+		return nil
+	}
+	files := make(map[string][]byte)
+	total := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == "document.md" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !strings.Contains(markdown, name) && !strings.Contains(markdown, url.PathEscape(name)) {
+			continue
+		}
+		data, err := encryption.ReadFile(filepath.Join(resourceDir, name))
+		if err != nil || total+len(data) > maxOutput {
+			continue
+		}
+		total += len(data)
+		files[name] = data
+	}
+	return files
+}
+
+// prepareTree limits the images of a document tree in pandoc's JSON format
+// to files, turns remote images into links and sets the title metadata the
+// format needs
+func prepareTree(tree []byte, export ExportFormat, title string, files map[string][]byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(tree))
+	decoder.UseNumber()
+	var document map[string]any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid document tree: %w", err)
+	}
+
+	document["blocks"] = walkTree(document["blocks"], func(element map[string]any) map[string]any {
+		content, _ := element["c"].([]any)
+		switch element["t"] {
+		case "Image":
+			if len(content) != 3 {
+				return element
+			}
+			target, _ := content[2].([]any)
+			if len(target) != 2 {
+				return element
+			}
+			src, _ := target[0].(string)
+			if name, err := url.PathUnescape(src); err == nil && files[name] != nil {
+				target[0] = name
+				return element
+			}
+			if u, err := url.Parse(src); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				return map[string]any{"t": "Link", "c": content}
+			}
+			return map[string]any{"t": "Span", "c": content[:2]}
+		case "RawInline", "RawBlock":
+			// HTML in pages may load files as well, only HTML output keeps it
+			if len(content) != 2 {
+				return element
+			}
+			if format, _ := content[0].(string); format == "html" && export.Name != "html5" {
+				if element["t"] == "RawBlock" {
+					return map[string]any{"t": "Div", "c": []any{emptyAttr(), []any{}}}
+				}
+				return map[string]any{"t": "Span", "c": []any{emptyAttr(), []any{}}}
+			}
+		}
+		return element
+	})
+
+	meta, _ := document["meta"].(map[string]any)
+	if meta == nil {
+		meta = make(map[string]any)
+		document["meta"] = meta
+	}
+	switch export.Name {
+	case "epub3":
+		meta["title"] = map[string]any{"t": "MetaString", "c": title}
+	case "html5":
+		meta["pagetitle"] = map[string]any{"t": "MetaString", "c": title}
+	}
+	return json.Marshal(document)
+}
+
+// walkTree replaces every element of a document tree, children after their parent
+func walkTree(node any, replace func(map[string]any) map[string]any) any {
+	switch n := node.(type) {
+	case []any:
+		for i := range n {
+			n[i] = walkTree(n[i], replace)
+		}
+	case map[string]any:
+		if _, ok := n["t"]; ok {
+			n = replace(n)
+		}
+		for key, value := range n {
+			n[key] = walkTree(value, replace)
+		}
+		return n
+	}
+	return node
+}
+
+// emptyAttr is the attributes of an element without id, classes or attributes
+func emptyAttr() []any {
+	return []any{"", []any{}, []any{}}
+}
@@ -0,0 +1,162 @@
+// Package permalinks gives pages short IDs, like the ab12cd of /p/ab12cd, that
+// keep pointing at a page when it is renamed or moved.
+package permalinks
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wiki-go/internal/store"
+)
+
+// permalinksFile is stored in the data directory
+const permalinksFile = "permalinks.json"
+
+// Prefix starts the URLs of the permalinks
+const Prefix = "/p/"
+
+// idLength and idChars make IDs short enough to type, with over two billion of them
+const (
+	idLength = 6
+	idChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// ErrNoID is returned when no free ID could be drawn
+var ErrNoID = errors.New("no free permalink ID")
+
+// File maps the IDs to the paths of their pages, without slashes around them
+type File struct {
+	Pages map[string]string `json:"pages"`
+}
+
+var mu store.DataFile
+
+// Clean returns path the way it is stored, without slashes around it
+func Clean(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// ID returns the ID of the page at path, drawing one the first time
+func ID(rootDir, path string) (string, error) {
+	unlock := mu.Lock(permalinksFile)
+	defer unlock()
+
+	file, err := load(rootDir)
+	if err != nil {
+		return "", err
+	}
+	path = Clean(path)
+	for id, page := range file.Pages {
+		if page == path {
+			return id, nil
+		}
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		id, err := newID()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := file.Pages[id]; !taken {
+			file.Pages[id] = path
+			return id, save(rootDir, file)
+		}
+	}
+	return "", ErrNoID
+}
+
+// Find returns the ID of the page at path, if it has one
+func Find(rootDir, path string) (string, bool, error) {
+	file, err := load(rootDir)
+	if err != nil {
+		return "", false, err
+	}
+	path = Clean(path)
+	for id, page := range file.Pages {
+		if page == path {
+			return id, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Lookup returns the path of the page with id
+func Lookup(rootDir, id string) (string, bool, error) {
+	file, err := load(rootDir)
+	if err != nil {
+		return "", false, err
+	}
+	path, ok := file.Pages[strings.ToLower(id)]
+	return path, ok, nil
+}
+
+// Rename moves the IDs of the page at from and of the pages below it to to,
+// after the pages were moved
+func Rename(rootDir, from, to string) error {
+	unlock := mu.Lock(permalinksFile)
+	defer unlock()
+
+	file, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	from, to = Clean(from), Clean(to)
+
+	changed := false
+	for id, page := range file.Pages {
+		if page == from || strings.HasPrefix(page, from+"/") {
+			file.Pages[id] = to + strings.TrimPrefix(page, from)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return save(rootDir, file)
+}
+
+// newID draws a random ID
+func newID() (string, error) {
+	random := make([]byte, idLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	id := make([]byte, idLength)
+	for i, b := range random {
+		id[i] = idChars[int(b)%len(idChars)]
+	}
+	return string(id), nil
+}
+
+func load(rootDir string) (*File, error) {
+	file := &File{Pages: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(rootDir, permalinksFile))
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	if file.Pages == nil {
+		file.Pages = map[string]string{}
+	}
+	return file, nil
+}
+
+func save(rootDir string, file *File) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(rootDir, permalinksFile), data, 0644)
+}
@@ -0,0 +1,328 @@
+package permissions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/filecache"
+	"wiki-go/internal/roles"
+	"wiki-go/internal/store"
+)
+
+// Special principals usable in view and edit lists
+const (
+	Everyone = "*"      // Everybody, including visitors who are not logged in
+	Users    = "@users" // Every logged-in user
+	Guest    = "@guest" // Visitors who are not logged in
+)
+
+// Rule sets the default permissions of a directory and everything below it.
+// Settings that are not set are inherited from the parent directory.
+type Rule struct {
+	Path     string   `yaml:"path"`
+	View     []string `yaml:"view,omitempty"`     // Principals allowed to read pages
+	Edit     []string `yaml:"edit,omitempty"`     // Principals allowed to change pages (editors and admins only)
+	Unlisted *bool    `yaml:"unlisted,omitempty"` // Keeps pages out of navigation, search and the sitemap
+}
+
+// File holds the per-directory permission rules
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Subject is the user whose access is checked. An empty Username is a guest.
+type Subject struct {
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Groups   []string `json:"groups"` // Names of the groups the user belongs to
+}
+
+// Access is the effective access to a path and where it comes from
+type Access struct {
+	View     []string `json:"view"`     // Effective view list, nil when unrestricted
+	ViewFrom string   `json:"viewFrom"` // Path of the rule that set the view list
+	Edit     []string `json:"edit"`     // Effective edit list, nil when unrestricted
+	EditFrom string   `json:"editFrom"` // Path of the rule that set the edit list
+
+	Unlisted     bool   `json:"unlisted"`     // Whether pages are left out of listings
+	UnlistedFrom string `json:"unlistedFrom"` // Path of the rule that set unlisted
+}
+
+// cache avoids re-reading the permissions file on every request
+var cache filecache.Cache[*File]
+
+// FilePath returns the location of the permission rules
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "permissions.yaml")
+}
+
+// Load returns the current rules, empty ones when the file does not exist
+func Load(rootDir string) (*File, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML permission rules
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, rule := range file.Rules {
+		path := normalize(rule.Path)
+		if strings.ContainsAny(path, "*?[") {
+			return nil, fmt.Errorf("rule %d: wildcards are not supported in %q", i+1, rule.Path)
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("rule %d: duplicate rule for %q", i+1, "/"+path)
+		}
+		seen[path] = true
+		if rule.View == nil && rule.Edit == nil && rule.Unlisted == nil {
+			return nil, fmt.Errorf("rule %d: set view, edit or unlisted", i+1)
+		}
+	}
+
+	return &file, nil
+}
+
+// ReadRaw returns the permissions file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the permissions file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// saveMu serializes changes made through SetRule
+var saveMu store.DataFile
+
+// SetRule replaces the rule of rule.Path, adds it when the path has none yet
+// and removes it when it sets nothing. The file is rewritten, so comments
+// in it are not kept.
+func SetRule(rootDir string, rule Rule) error {
+	unlock := saveMu.Lock("permissions.yaml")
+	defer unlock()
+
+	raw, err := ReadRaw(rootDir)
+	if err != nil {
+		return err
+	}
+	file, err := Parse([]byte(raw))
+	if err != nil {
+		return err
+	}
+
+	path := normalize(rule.Path)
+	rule.Path = "/" + path
+	rules := file.Rules[:0]
+	found := false
+	for _, existing := range file.Rules {
+		if normalize(existing.Path) != path {
+			rules = append(rules, existing)
+			continue
+		}
+		found = true
+		if !rule.empty() {
+			rules = append(rules, rule)
+		}
+	}
+	if !found && !rule.empty() {
+		rules = append(rules, rule)
+	}
+	file.Rules = rules
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	return SaveRaw(rootDir, buf.String())
+}
+
+// empty reports whether the rule sets nothing
+func (r Rule) empty() bool {
+	return r.View == nil && r.Edit == nil && r.Unlisted == nil
+}
+
+// Rule returns the rule of path itself, if it has one
+func (f *File) Rule(path string) (Rule, bool) {
+	path = normalize(path)
+	for _, rule := range f.Rules {
+		if normalize(rule.Path) == path {
+			return rule, true
+		}
+	}
+	return Rule{Path: "/" + path}, false
+}
+
+// Access returns the effective access to a path. Each setting comes from the rule
+// of the closest directory that sets it, so rules override their parents per setting.
+func (f *File) Access(path string) Access {
+	rules := make(map[string]Rule, len(f.Rules))
+	for _, rule := range f.Rules {
+		rules[normalize(rule.Path)] = rule
+	}
+
+	var access Access
+	path = normalize(path)
+	for _, ancestor := range ancestors(path) {
+		rule, ok := rules[ancestor]
+		if !ok {
+			continue
+		}
+		if rule.View != nil {
+			access.View = rule.View
+			access.ViewFrom = "/" + ancestor
+		}
+		if rule.Edit != nil {
+			access.Edit = rule.Edit
+			access.EditFrom = "/" + ancestor
+		}
+		if rule.Unlisted != nil {
+			access.Unlisted = *rule.Unlisted
+			access.UnlistedFrom = "/" + ancestor
+		}
+	}
+
+	return access
+}
+
+// CanView reports whether subject may read the page at path. Admins can read everything.
+func (f *File) CanView(path string, subject Subject) bool {
+	if subject.Role == roles.RoleAdmin {
+		return true
+	}
+	access := f.Access(path)
+	return access.View == nil || matches(access.View, subject)
+}
+
+// CanEdit reports whether subject may change the page at path. Only admins and
+// editors can edit, edit lists narrow this down further.
+func (f *File) CanEdit(path string, subject Subject) bool {
+	if subject.Role == roles.RoleAdmin {
+		return true
+	}
+	if subject.Role != roles.RoleEditor || !f.CanView(path, subject) {
+		return false
+	}
+	access := f.Access(path)
+	return access.Edit == nil || matches(access.Edit, subject)
+}
+
+// Listed reports whether the page at path shows up for subject in navigation,
+// search and the sitemap. Admins see unlisted pages as well.
+func (f *File) Listed(path string, subject Subject) bool {
+	if !f.CanView(path, subject) {
+		return false
+	}
+	return subject.Role == roles.RoleAdmin || !f.Access(path).Unlisted
+}
+
+// HasRules reports whether any permission rule is configured
+func (f *File) HasRules() bool {
+	return len(f.Rules) > 0
+}
+
+// matches reports whether subject is one of the principals
+func matches(principals []string, subject Subject) bool {
+	for _, principal := range principals {
+		switch {
+		case principal == Everyone:
+			return true
+		case principal == Users:
+			if subject.Username != "" {
+				return true
+			}
+		case principal == Guest:
+			if subject.Username == "" {
+				return true
+			}
+		case strings.HasPrefix(principal, "@"):
+			name := principal[1:]
+			if subject.Username != "" && name == subject.Role {
+				return true
+			}
+			for _, group := range subject.Groups {
+				if group == name {
+					return true
+				}
+			}
+		case subject.Username != "" && principal == subject.Username:
+			return true
+		}
+	}
+	return false
+}
+
+// ancestors returns "" (the root) and every parent directory of path, ending with path
+func ancestors(path string) []string {
+	result := []string{""}
+	if path == "" {
+		return result
+	}
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		result = append(result, strings.Join(parts[:i+1], "/"))
+	}
+	return result
+}
+
+// normalize turns "/guides/api/" into "guides/api"
+func normalize(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// SubjectFor builds the subject for a user, looking up their groups. An empty
+// username is a guest.
+func SubjectFor(username, role string, groups map[string][]string) Subject {
+	subject := Subject{Username: username, Role: role}
+	if username == "" {
+		subject.Role = ""
+		return subject
+	}
+	for name, members := range groups {
+		for _, member := range members {
+			if member == username {
+				subject.Groups = append(subject.Groups, name)
+				break
+			}
+		}
+	}
+	sort.Strings(subject.Groups)
+	return subject
+}
+
+// UserRole returns the role of a configured user, or "" if the user does not exist
+func UserRole(cfg *config.Config, username string) string {
+	for _, user := range cfg.Users {
+		if user.Username == username {
+			return user.Role
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package permissions
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Node is a directory in the effective permission tree
+type Node struct {
+	Path     string  `json:"path"`
+	Name     string  `json:"name"`
+	HasRule  bool    `json:"hasRule"` // Whether the directory has its own rule
+	Access   Access  `json:"access"`
+	CanView  *bool   `json:"canView,omitempty"` // Set when simulating a user
+	CanEdit  *bool   `json:"canEdit,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Tree builds the effective permission tree of the documents directory. When
+// subject is not nil, every node also reports whether that user can view and edit it.
+func (f *File) Tree(documentsDir string, subject *Subject) (*Node, error) {
+	ruled := make(map[string]bool, len(f.Rules))
+	for _, rule := range f.Rules {
+		ruled[normalize(rule.Path)] = true
+	}
+
+	var build func(dir, path string) (*Node, error)
+	build = func(dir, path string) (*Node, error) {
+		name := filepath.Base(dir)
+		if path == "" {
+			name = "/"
+		}
+
+		node := &Node{
+			Path:    "/" + path,
+			Name:    name,
+			HasRule: ruled[path],
+			Access:  f.Access(path),
+		}
+		if subject != nil {
+			canView := f.CanView(path, *subject)
+			canEdit := f.CanEdit(path, *subject)
+			node.CanView = &canView
+			node.CanEdit = &canEdit
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return node, nil
+			}
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			childPath := entry.Name()
+			if path != "" {
+				childPath = path + "/" + entry.Name()
+			}
+			child, err := build(filepath.Join(dir, entry.Name()), childPath)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Name < node.Children[j].Name
+		})
+		return node, nil
+	}
+
+	return build(documentsDir, "")
+}
@@ -0,0 +1,104 @@
+package permissions
+
+import (
+	"fmt"
+	"path"
+
+	"wiki-go/internal/roles"
+)
+
+// Visibility levels of a page, a simpler view of its rules
+const (
+	Public     = "public"     // Everybody may read the page
+	Internal   = "internal"   // Logged-in users may read the page
+	Restricted = "restricted" // Only the principals of the view list may read the page
+	Unlisted   = "unlisted"   // Everybody with the link may read the page, it is not listed anywhere
+)
+
+// Visibility returns the visibility level of the access to a page
+func (a Access) Visibility() string {
+	switch {
+	case a.Unlisted:
+		return Unlisted
+	case a.View == nil || contains(a.View, Everyone):
+		return Public
+	case len(a.View) == 1 && a.View[0] == Users:
+		return Internal
+	default:
+		return Restricted
+	}
+}
+
+// SetVisibility changes the rule of the page at pagePath to give it a visibility
+// level. view lists the principals of restricted pages. The edit list of the
+// rule is kept, and settings the parent directory already has are inherited
+// instead of repeated.
+func SetVisibility(rootDir, pagePath, level string, view []string) error {
+	file, err := Load(rootDir)
+	if err != nil {
+		return err
+	}
+	pagePath = normalize(pagePath)
+	rule, _ := file.Rule(pagePath)
+	var parent Access
+	if pagePath != "" {
+		parent = file.Access(path.Dir("/" + pagePath))
+	}
+
+	unlisted := false
+	switch level {
+	case Public:
+		rule.View = []string{Everyone}
+	case Internal:
+		rule.View = []string{Users}
+	case Restricted:
+		if len(view) == 0 {
+			return fmt.Errorf("restricted pages need at least one user or group who may read them")
+		}
+		rule.View = view
+	case Unlisted:
+		rule.View = []string{Everyone}
+		unlisted = true
+	default:
+		return fmt.Errorf("unknown visibility %q", level)
+	}
+
+	if sameView(parent.View, rule.View) {
+		rule.View = nil
+	}
+	rule.Unlisted = nil
+	if unlisted != parent.Unlisted {
+		rule.Unlisted = &unlisted
+	}
+	return SetRule(rootDir, rule)
+}
+
+// sameView reports whether two view lists let the same principals read
+func sameView(a, b []string) bool {
+	if a == nil || contains(a, Everyone) {
+		return b == nil || contains(b, Everyone)
+	}
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a view list lets subject read a page. Admins can read everything.
+func Allows(view []string, subject Subject) bool {
+	return subject.Role == roles.RoleAdmin || view == nil || matches(view, subject)
+}
@@ -0,0 +1,88 @@
+package preferences
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"wiki-go/internal/store"
+)
+
+// Editor modes
+const (
+	EditorMarkdown = "markdown" // Markdown source with syntax highlighting
+	EditorRich     = "rich"     // Rich text, converted to markdown when saved
+)
+
+// Preferences are the settings each user chooses for themselves
+type Preferences struct {
+	EditorMode string `json:"editorMode"`
+}
+
+var (
+	mutex          sync.Mutex
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+)
+
+// Defaults returns the preferences of users who haven't changed anything
+func Defaults() Preferences {
+	return Preferences{EditorMode: EditorMarkdown}
+}
+
+// userFile returns the file holding the preferences of a user
+func userFile(rootDir, user string) string {
+	return filepath.Join(rootDir, "preferences", unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// Load returns the preferences of user, the defaults when none were saved
+func Load(rootDir, user string) (Preferences, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	prefs := Defaults()
+	data, err := os.ReadFile(userFile(rootDir, user))
+	if os.IsNotExist(err) {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, err
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return Defaults(), err
+	}
+	return prefs, nil
+}
+
+// Save stores the preferences of user
+func Save(rootDir, user string, prefs Preferences) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	path := userFile(rootDir, user)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(path, data, 0644)
+}
+
+// Delete removes the stored preferences of user
+func Delete(rootDir, user string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := os.Remove(userFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Valid reports whether the preferences hold known values
+func (p Preferences) Valid() bool {
+	return p.EditorMode == EditorMarkdown || p.EditorMode == EditorRich
+}
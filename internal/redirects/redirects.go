@@ -0,0 +1,225 @@
+// Package redirects keeps the redirects admins create for vanity URLs and
+// removed pages, like /vpn to /it/network/vpn-setup, and counts how often each
+// redirect, including those of redirect pages, is followed.
+package redirects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// redirectsFile is stored in the data directory
+const redirectsFile = "redirects.json"
+
+// MaxHops is the number of redirects followed before giving up
+const MaxHops = 10
+
+// Errors returned for missing redirects and redirects that never reach a page
+var (
+	ErrNotFound = errors.New("redirect not found")
+	ErrLoop     = errors.New("the redirect leads back to itself")
+)
+
+// Redirect sends the readers of a path to a page
+type Redirect struct {
+	From      string    `json:"from"` // Path without slashes around it
+	To        string    `json:"to"`   // Page path starting with a slash, optionally with a #fragment
+	CreatedBy string    `json:"createdBy"`
+	Created   time.Time `json:"created"`
+}
+
+// Hit counts how often a redirect was followed
+type Hit struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// File holds the redirects created by admins and the hits of all redirects,
+// keyed by the path they redirect from
+type File struct {
+	Redirects []Redirect     `json:"redirects"`
+	Hits      map[string]Hit `json:"hits"`
+}
+
+// mu guards changes of the redirects. They are read without it, since the
+// file is replaced at once.
+var mu store.DataFile
+
+// Clean returns path the way it is stored, without slashes around it
+func Clean(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean("/"+path)), "/")
+}
+
+// Target checks the target of a redirect and cleans it. Targets are pages of
+// the wiki, links to other sites are refused.
+func Target(to string) (string, error) {
+	to = strings.TrimSpace(to)
+	path, fragment, _ := strings.Cut(to, "#")
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "..") || strings.ContainsAny(path, "?\\") {
+		return "", fmt.Errorf("%q is not a page of the wiki", to)
+	}
+	target := "/" + Clean(path)
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	return target, nil
+}
+
+// TargetPath returns the page path of a target, without slashes around it and
+// without its fragment
+func TargetPath(to string) string {
+	path, _, _ := strings.Cut(to, "#")
+	return Clean(path)
+}
+
+// Resolve follows the redirects from the page at from until a page that
+// doesn't redirect. next returns the target of the redirect of a path, if it
+// has one. The target of the last redirect is returned along with the number
+// of redirects followed, 0 when from doesn't redirect.
+func Resolve(from string, next func(path string) (string, bool)) (string, int, error) {
+	seen := map[string]bool{Clean(from): true}
+	current := Clean(from)
+	target := "/" + current
+	for hops := 0; ; hops++ {
+		to, ok := next(current)
+		if !ok {
+			return target, hops, nil
+		}
+		if hops == MaxHops {
+			return "", hops, fmt.Errorf("more than %d redirects in a row", MaxHops)
+		}
+		current = TargetPath(to)
+		if seen[current] {
+			return "", hops + 1, ErrLoop
+		}
+		seen[current] = true
+		target = to
+	}
+}
+
+// Load returns the redirects and their hits
+func Load(rootDir string) (*File, error) {
+	return load(rootDir)
+}
+
+// Find returns the redirect created for the path from
+func (f *File) Find(from string) (Redirect, bool) {
+	from = Clean(from)
+	for _, redirect := range f.Redirects {
+		if redirect.From == from {
+			return redirect, true
+		}
+	}
+	return Redirect{}, false
+}
+
+// Save stores a redirect, replacing the one with the same source
+func Save(rootDir string, redirect Redirect, user string) (Redirect, error) {
+	redirect.From = Clean(redirect.From)
+	if redirect.From == "" || strings.Contains(redirect.From, "..") {
+		return Redirect{}, fmt.Errorf("invalid path %q", redirect.From)
+	}
+	to, err := Target(redirect.To)
+	if err != nil {
+		return Redirect{}, err
+	}
+	if TargetPath(to) == redirect.From {
+		return Redirect{}, ErrLoop
+	}
+	redirect.To = to
+
+	unlock := mu.Lock(redirectsFile)
+	defer unlock()
+
+	file, err := load(rootDir)
+	if err != nil {
+		return Redirect{}, err
+	}
+	redirect.CreatedBy = user
+	redirect.Created = time.Now()
+	kept := file.Redirects[:0]
+	for _, other := range file.Redirects {
+		if other.From != redirect.From {
+			kept = append(kept, other)
+		}
+	}
+	file.Redirects = append(kept, redirect)
+	sort.Slice(file.Redirects, func(i, j int) bool {
+		return file.Redirects[i].From < file.Redirects[j].From
+	})
+	return redirect, save(rootDir, file)
+}
+
+// Delete removes the redirect from the path from, along with its hits
+func Delete(rootDir, from string) error {
+	unlock := mu.Lock(redirectsFile)
+	defer unlock()
+
+	file, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	from = Clean(from)
+	for i, redirect := range file.Redirects {
+		if redirect.From == from {
+			file.Redirects = append(file.Redirects[:i], file.Redirects[i+1:]...)
+			delete(file.Hits, from)
+			return save(rootDir, file)
+		}
+	}
+	return ErrNotFound
+}
+
+// RecordHit counts that the redirect from the path from was followed
+func RecordHit(rootDir, from string) error {
+	unlock := mu.Lock(redirectsFile)
+	defer unlock()
+
+	file, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	from = Clean(from)
+	hit := file.Hits[from]
+	hit.Count++
+	hit.Last = time.Now()
+	file.Hits[from] = hit
+	return save(rootDir, file)
+}
+
+func load(rootDir string) (*File, error) {
+	file := &File{Redirects: []Redirect{}, Hits: map[string]Hit{}}
+	data, err := os.ReadFile(filepath.Join(rootDir, redirectsFile))
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	if file.Hits == nil {
+		file.Hits = map[string]Hit{}
+	}
+	return file, nil
+}
+
+func save(rootDir string, file *File) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(rootDir, redirectsFile), data, 0644)
+}
@@ -0,0 +1,167 @@
+package rendersettings
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+)
+
+// FileName is the name of the file holding the rendering options of a
+// directory, which apply to its pages and all pages below it
+const FileName = "_settings.yaml"
+
+// Extensions are the markdown extensions directories can turn on or off
+var Extensions = []string{
+	"callouts", "citations", "crossrefs", "details", "emoji", "gantt", "glossary",
+	"heading_anchors", "highlight", "maps", "mermaid", "openapi", "plantuml",
+	"subscript", "superscript", "typography", "variables", "wikilinks",
+}
+
+// Syntax highlighting themes besides stylesheets below static
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// Settings are the rendering options of a directory. Options left out are
+// taken from the parent directory, and from the wiki configuration at the top.
+type Settings struct {
+	Extensions  map[string]bool `yaml:"extensions"`   // Extensions turned on or off by name
+	SyntaxTheme string          `yaml:"syntax_theme"` // "light", "dark" or a stylesheet below static, empty to follow the page theme
+	Math        *bool           `yaml:"math"`         // Typeset $math$, on unless set to false
+	Numbering   *bool           `yaml:"numbering"`    // Number the headings, as numbering: true in a page frontmatter
+	TOC         TOC             `yaml:"toc"`
+}
+
+// TOC are the options of the tables of contents of pages
+type TOC struct {
+	Auto        *bool `yaml:"auto"`         // Add a table of contents to the top of pages without a [toc] marker
+	MinHeadings int   `yaml:"min_headings"` // Headings a page needs to get one automatically, default 3
+	MaxLevel    int   `yaml:"max_level"`    // Deepest heading level listed, 0 for all
+}
+
+// cache avoids re-reading the settings files on every render
+var cache filecache.Cache[*Settings]
+
+// For returns the settings of the page at docPath, below documentsDir: those
+// of the directories from the top down to the page, the deepest ones winning
+func For(documentsDir, docPath string) *Settings {
+	settings := &Settings{}
+	dir := documentsDir
+	settings.merge(load(filepath.Join(dir, FileName)))
+	for _, part := range strings.Split(strings.Trim(docPath, "/"), "/") {
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		settings.merge(load(filepath.Join(dir, FileName)))
+	}
+	return settings
+}
+
+// load returns the settings of a file, nil when there is no valid one
+func load(path string) *Settings {
+	settings, err := cache.Load(path, func(data []byte) (*Settings, error) {
+		settings, err := Parse(data)
+		if err != nil {
+			// Kept as no settings, so a mistake is reported once per change
+			log.Printf("Ignoring %s: %v", path, err)
+		}
+		return settings, nil
+	})
+	if err != nil {
+		return nil
+	}
+	return settings
+}
+
+// Parse parses and validates the YAML settings of a directory
+func Parse(data []byte) (*Settings, error) {
+	var settings Settings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range Extensions {
+		known[name] = true
+	}
+	var unknown []string
+	for name := range settings.Extensions {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown extensions: %s", strings.Join(unknown, ", "))
+	}
+	if settings.TOC.MinHeadings < 0 {
+		return nil, fmt.Errorf("toc: min_headings can't be negative")
+	}
+	if settings.TOC.MaxLevel < 0 || settings.TOC.MaxLevel > 6 {
+		return nil, fmt.Errorf("toc: max_level must be between 1 and 6")
+	}
+
+	return &settings, nil
+}
+
+// merge overrides the settings with the options set in other
+func (s *Settings) merge(other *Settings) {
+	if other == nil {
+		return
+	}
+	for name, enabled := range other.Extensions {
+		if s.Extensions == nil {
+			s.Extensions = make(map[string]bool)
+		}
+		s.Extensions[name] = enabled
+	}
+	if other.SyntaxTheme != "" {
+		s.SyntaxTheme = other.SyntaxTheme
+	}
+	if other.Math != nil {
+		s.Math = other.Math
+	}
+	if other.Numbering != nil {
+		s.Numbering = other.Numbering
+	}
+	if other.TOC.Auto != nil {
+		s.TOC.Auto = other.TOC.Auto
+	}
+	if other.TOC.MinHeadings != 0 {
+		s.TOC.MinHeadings = other.TOC.MinHeadings
+	}
+	if other.TOC.MaxLevel != 0 {
+		s.TOC.MaxLevel = other.TOC.MaxLevel
+	}
+}
+
+// Enabled reports whether an extension is on, fallback when no directory set it
+func (s *Settings) Enabled(name string, fallback bool) bool {
+	if enabled, ok := s.Extensions[name]; ok {
+		return enabled
+	}
+	return fallback
+}
+
+// MathEnabled reports whether math is typeset
+func (s *Settings) MathEnabled() bool {
+	return s.Math == nil || *s.Math
+}
+
+// AutoTOC reports whether pages without a [toc] marker get a table of
+// contents, and how many headings they need for it
+func (s *Settings) AutoTOC() (bool, int) {
+	minHeadings := s.TOC.MinHeadings
+	if minHeadings == 0 {
+		minHeadings = 3
+	}
+	return s.TOC.Auto != nil && *s.TOC.Auto, minHeadings
+}
@@ -0,0 +1,130 @@
+package replace
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters of context shown on each side of a match
+const contextLength = 60
+
+// Longest accepted search pattern
+const maxPatternLength = 1000
+
+// Query is a search of the wiki and its replacement
+type Query struct {
+	Find          string `json:"find"`
+	Replace       string `json:"replace"`
+	Regex         bool   `json:"regex"` // Find is a regular expression and Replace may use $1 or ${name}
+	CaseSensitive bool   `json:"caseSensitive"`
+}
+
+// Occurrence is a match in a page, identified by its byte offset
+type Occurrence struct {
+	Offset      int    `json:"offset"`
+	Line        int    `json:"line"` // 1-based line of the start of the match
+	Before      string `json:"before"`
+	Match       string `json:"match"`
+	After       string `json:"after"`
+	Replacement string `json:"replacement"`
+}
+
+// Compile returns the regular expression matching the query
+func (q Query) Compile() (*regexp.Regexp, error) {
+	if q.Find == "" {
+		return nil, errors.New("the text to find is required")
+	}
+	if len(q.Find) > maxPatternLength {
+		return nil, errors.New("the text to find is too long")
+	}
+	pattern := q.Find
+	if !q.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !q.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, errors.New("the pattern must not match empty text")
+	}
+	return re, nil
+}
+
+// replacement returns the text replacing the match at loc
+func (q Query) replacement(re *regexp.Regexp, content string, loc []int) string {
+	if !q.Regex {
+		return q.Replace
+	}
+	return string(re.ExpandString(nil, q.Replace, content, loc))
+}
+
+// Find returns the matches of the query in content, at most limit of them
+func Find(q Query, re *regexp.Regexp, content string, limit int) []Occurrence {
+	var occurrences []Occurrence
+	line, counted := 1, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(content, limit) {
+		line += strings.Count(content[counted:loc[0]], "\n")
+		counted = loc[0]
+
+		lineStart := strings.LastIndexByte(content[:loc[0]], '\n') + 1
+		lineEnd := strings.IndexByte(content[loc[1]:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += loc[1]
+		}
+
+		occurrences = append(occurrences, Occurrence{
+			Offset:      loc[0],
+			Line:        line,
+			Before:      lastRunes(content[lineStart:loc[0]], contextLength),
+			Match:       content[loc[0]:loc[1]],
+			After:       firstRunes(content[loc[1]:lineEnd], contextLength),
+			Replacement: q.replacement(re, content, loc),
+		})
+	}
+	return occurrences
+}
+
+// Apply replaces the matches starting at the selected offsets and returns the
+// new content with the number of replacements. Offsets that no longer start a
+// match, because the page changed since the search, are left alone.
+func Apply(q Query, re *regexp.Regexp, content string, offsets map[int]bool) (string, int) {
+	var b strings.Builder
+	last, count := 0, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		if !offsets[loc[0]] {
+			continue
+		}
+		b.WriteString(content[last:loc[0]])
+		b.WriteString(q.replacement(re, content, loc))
+		last = loc[1]
+		count++
+	}
+	b.WriteString(content[last:])
+	return b.String(), count
+}
+
+func firstRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i] + "…"
+		}
+		n--
+	}
+	return s
+}
+
+func lastRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return "…" + string(runes[len(runes)-n:])
+}
@@ -21,11 +21,16 @@ var dataFiles embed.FS
 
 // GetFileSystem returns an http.FileSystem for the embedded static files
 func GetFileSystem() http.FileSystem {
+	return http.FS(GetStaticFS())
+}
+
+// GetStaticFS returns an fs.FS for the embedded static files
+func GetStaticFS() fs.FS {
 	fsys, err := fs.Sub(staticFiles, "static")
 	if err != nil {
 		panic(err)
 	}
-	return http.FS(fsys)
+	return fsys
 }
 
 // LoadTemplates loads and parses the embedded HTML templates
@@ -0,0 +1,306 @@
+// Accessibility: dialog roles and focus management, keyboard operation of the
+// toolbar menus and dialog tabs, and labels for buttons showing only an icon
+(function() {
+    'use strict';
+
+    const focusableSelector = [
+        'a[href]', 'button:not([disabled])', 'input:not([disabled]):not([type="hidden"])',
+        'select:not([disabled])', 'textarea:not([disabled])', '[tabindex]:not([tabindex="-1"])'
+    ].join(',');
+
+    // Menus opened from the page toolbar: menu, button and dropdown
+    const menus = [
+        ['.export-menu', '.export-button', '.export-dropdown'],
+        ['.language-menu', '.language-button', '.language-dropdown'],
+        ['.notifications-menu', '.notifications-button', '.notifications-dropdown']
+    ];
+
+    let nextId = 0;
+    const openDialogs = []; // Open dialogs, the last one on top, with the element focused before
+    const dialogFocusDelay = 150; // Dialogs focus their first field after 100ms
+
+    function ensureId(element, prefix) {
+        if (!element.id) element.id = prefix + '-' + (++nextId);
+        return element.id;
+    }
+
+    function isVisible(element) {
+        return !!(element.offsetWidth || element.offsetHeight || element.getClientRects().length);
+    }
+
+    function focusables(container) {
+        return Array.from(container.querySelectorAll(focusableSelector)).filter(isVisible);
+    }
+
+    // ===== DIALOGS =====
+
+    // A dialog is an overlay holding a .dialog-container, shown with the active class
+    function isDialog(element) {
+        return element.nodeType === 1 && !!element.querySelector(':scope > .dialog-container');
+    }
+
+    function labelDialog(dialog) {
+        const container = dialog.querySelector(':scope > .dialog-container');
+        if (!container || container.hasAttribute('role')) return;
+        container.setAttribute('role', dialog.classList.contains('message-dialog') || dialog.classList.contains('confirmation-dialog') ? 'alertdialog' : 'dialog');
+        container.setAttribute('aria-modal', 'true');
+        const title = container.querySelector('.dialog-title, h2, h3');
+        if (title) {
+            container.setAttribute('aria-labelledby', ensureId(title, 'dialog-title'));
+        }
+    }
+
+    function dialogOpened(dialog) {
+        if (openDialogs.some(entry => entry.dialog === dialog)) return;
+        labelDialog(dialog);
+        openDialogs.push({ dialog, returnFocus: document.activeElement });
+        labelIcons(dialog);
+
+        // Move the focus into the dialog unless its script already did
+        setTimeout(() => {
+            if (!dialog.classList.contains('active') || dialog.contains(document.activeElement)) return;
+            const first = focusables(dialog).find(element => !element.classList.contains('close-dialog')) || focusables(dialog)[0];
+            if (first) first.focus();
+        }, dialogFocusDelay);
+    }
+
+    function dialogClosed(dialog) {
+        const index = openDialogs.findIndex(entry => entry.dialog === dialog);
+        if (index === -1) return;
+        const entry = openDialogs.splice(index, 1)[0];
+
+        // Give the focus back to what opened the dialog, unless the reader moved on
+        const active = document.activeElement;
+        if (!active || active === document.body || dialog.contains(active)) {
+            const target = entry.returnFocus;
+            if (target && document.contains(target) && isVisible(target)) {
+                target.focus();
+            }
+        }
+    }
+
+    function watchDialogs() {
+        document.querySelectorAll('.dialog-container').forEach(container => labelDialog(container.parentElement));
+
+        const observer = new MutationObserver(mutations => {
+            mutations.forEach(mutation => {
+                if (mutation.type === 'attributes') {
+                    const target = mutation.target;
+                    if (!isDialog(target)) return;
+                    if (target.classList.contains('active')) {
+                        dialogOpened(target);
+                    } else {
+                        dialogClosed(target);
+                    }
+                    return;
+                }
+                mutation.addedNodes.forEach(node => {
+                    if (node.nodeType !== 1) return;
+                    labelIcons(node);
+                    if (isDialog(node)) {
+                        labelDialog(node);
+                        if (node.classList.contains('active')) dialogOpened(node);
+                    }
+                });
+                mutation.removedNodes.forEach(node => {
+                    if (node.nodeType === 1 && isDialog(node)) dialogClosed(node);
+                });
+            });
+        });
+        observer.observe(document.body, { attributes: true, attributeFilter: ['class'], childList: true, subtree: true });
+    }
+
+    // Tab and Shift+Tab stay inside the dialog on top
+    function trapFocus(event) {
+        if (event.key !== 'Tab' || event.defaultPrevented || !openDialogs.length) return;
+        const dialog = openDialogs[openDialogs.length - 1].dialog;
+        const elements = focusables(dialog);
+        if (!elements.length) return;
+
+        const first = elements[0];
+        const last = elements[elements.length - 1];
+        if (!dialog.contains(document.activeElement)) {
+            event.preventDefault();
+            first.focus();
+        } else if (event.shiftKey && document.activeElement === first) {
+            event.preventDefault();
+            last.focus();
+        } else if (!event.shiftKey && document.activeElement === last) {
+            event.preventDefault();
+            first.focus();
+        }
+    }
+
+    // ===== MENUS =====
+
+    function setupMenu(menu, button, dropdown) {
+        button.setAttribute('aria-haspopup', 'true');
+        button.setAttribute('aria-controls', ensureId(dropdown, 'menu'));
+        const update = () => button.setAttribute('aria-expanded', dropdown.hidden ? 'false' : 'true');
+        update();
+        new MutationObserver(update).observe(dropdown, { attributes: true, attributeFilter: ['hidden'] });
+
+        const items = () => focusables(dropdown);
+        const close = () => {
+            if (!dropdown.hidden) button.click();
+        };
+
+        button.addEventListener('keydown', event => {
+            if (event.key === 'Escape' && !dropdown.hidden) {
+                event.preventDefault();
+                event.stopPropagation();
+                close();
+                return;
+            }
+            if (event.key !== 'ArrowDown' && event.key !== 'ArrowUp') return;
+            event.preventDefault();
+            if (dropdown.hidden) button.click();
+            const list = items();
+            if (list.length) list[event.key === 'ArrowDown' ? 0 : list.length - 1].focus();
+        });
+
+        dropdown.addEventListener('keydown', event => {
+            const list = items();
+            const index = list.indexOf(document.activeElement);
+            switch (event.key) {
+                case 'ArrowDown':
+                    event.preventDefault();
+                    if (list.length) list[(index + 1) % list.length].focus();
+                    break;
+                case 'ArrowUp':
+                    event.preventDefault();
+                    if (list.length) list[(index - 1 + list.length) % list.length].focus();
+                    break;
+                case 'Home':
+                    event.preventDefault();
+                    if (list.length) list[0].focus();
+                    break;
+                case 'End':
+                    event.preventDefault();
+                    if (list.length) list[list.length - 1].focus();
+                    break;
+                case 'Escape':
+                    event.preventDefault();
+                    event.stopPropagation();
+                    close();
+                    button.focus();
+                    break;
+            }
+        });
+
+        // Leaving the menu with Tab closes it
+        menu.addEventListener('focusout', event => {
+            if (event.relatedTarget && !menu.contains(event.relatedTarget)) close();
+        });
+    }
+
+    // ===== TABS =====
+
+    function setupTabs(tablist) {
+        const tabs = Array.from(tablist.querySelectorAll('.tab-button[data-tab]'));
+        if (!tabs.length) return;
+        tablist.setAttribute('role', 'tablist');
+
+        const update = () => {
+            tabs.forEach(tab => {
+                const selected = tab.classList.contains('active');
+                tab.setAttribute('aria-selected', selected ? 'true' : 'false');
+                tab.setAttribute('tabindex', selected ? '0' : '-1');
+            });
+        };
+
+        tabs.forEach(tab => {
+            tab.setAttribute('role', 'tab');
+            tab.setAttribute('type', 'button');
+            const pane = document.getElementById(tab.dataset.tab);
+            if (pane) {
+                tab.setAttribute('aria-controls', pane.id);
+                pane.setAttribute('role', 'tabpanel');
+                pane.setAttribute('aria-labelledby', ensureId(tab, 'tab'));
+            }
+            new MutationObserver(update).observe(tab, { attributes: true, attributeFilter: ['class'] });
+        });
+        update();
+
+        tablist.addEventListener('keydown', event => {
+            const index = tabs.indexOf(document.activeElement);
+            if (index === -1) return;
+            const rtl = getComputedStyle(tablist).direction === 'rtl';
+            let next = -1;
+            switch (event.key) {
+                case 'ArrowRight':
+                    next = rtl ? index - 1 : index + 1;
+                    break;
+                case 'ArrowLeft':
+                    next = rtl ? index + 1 : index - 1;
+                    break;
+                case 'Home':
+                    next = 0;
+                    break;
+                case 'End':
+                    next = tabs.length - 1;
+                    break;
+                default:
+                    return;
+            }
+            event.preventDefault();
+            const tab = tabs[(next + tabs.length) % tabs.length];
+            tab.click();
+            tab.focus();
+        });
+    }
+
+    // ===== ICONS =====
+
+    // labelIcons hides Font Awesome icons from screen readers and names the
+    // buttons and links showing nothing else after their tooltip
+    function labelIcons(root) {
+        root.querySelectorAll('i.fa:not([aria-hidden])').forEach(icon => {
+            icon.setAttribute('aria-hidden', 'true');
+        });
+        root.querySelectorAll('button[title]:not([aria-label]), a[title]:not([aria-label])').forEach(element => {
+            if (!element.textContent.trim()) {
+                element.setAttribute('aria-label', element.getAttribute('title'));
+            }
+        });
+    }
+
+    // ===== SKIP LINK =====
+
+    function setupSkipLink() {
+        const link = document.querySelector('.skip-link');
+        const main = document.getElementById('main-content');
+        if (!link || !main) return;
+        link.addEventListener('click', event => {
+            event.preventDefault();
+            main.focus();
+            main.scrollIntoView();
+        });
+    }
+
+    function init() {
+        labelIcons(document);
+        watchDialogs();
+        document.addEventListener('keydown', trapFocus);
+
+        menus.forEach(([menuSelector, buttonSelector, dropdownSelector]) => {
+            const menu = document.querySelector(menuSelector);
+            const button = menu && menu.querySelector(buttonSelector);
+            const dropdown = menu && menu.querySelector(dropdownSelector);
+            if (button && dropdown) setupMenu(menu, button, dropdown);
+        });
+        document.querySelectorAll('.settings-tabs, .file-upload-tabs').forEach(setupTabs);
+        setupSkipLink();
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', init);
+    } else {
+        init();
+    }
+
+    window.A11y = {
+        labelIcons,
+        focusables
+    };
+})();
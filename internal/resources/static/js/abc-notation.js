@@ -0,0 +1,112 @@
+/**
+ * ABC music notation
+ * Renders ```abc code blocks as scores with abcjs, which is only loaded by
+ * pages that have one. The source stays below the score, folded, so it can
+ * still be read and copied.
+ */
+
+(function() {
+    'use strict';
+
+    const script = document.currentScript;
+    let settings = { enabled: false, script: '' };
+    try {
+        settings = Object.assign(settings, JSON.parse(script.dataset.config || '{}'));
+    } catch (error) {
+        console.error('Invalid music configuration:', error);
+    }
+
+    let library = null;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // Loads abcjs once, for all the scores of the page
+    function loadLibrary() {
+        if (window.ABCJS) return Promise.resolve(window.ABCJS);
+        if (!library) {
+            library = new Promise((resolve, reject) => {
+                const element = document.createElement('script');
+                element.src = settings.script;
+                element.async = true;
+                element.onload = () => window.ABCJS ? resolve(window.ABCJS) : reject(new Error('abcjs did not load'));
+                element.onerror = () => reject(new Error('Failed to load ' + settings.script));
+                document.head.appendChild(element);
+            });
+        }
+        return library;
+    }
+
+    function showError(container, message) {
+        const error = document.createElement('div');
+        error.className = 'abc-error';
+        error.textContent = t('music.render_failed', 'The score could not be rendered') + ': ' + message;
+        container.insertBefore(error, container.firstChild);
+        container.querySelector('.abc-source').open = true;
+    }
+
+    // Puts the score above the code block, which is folded into a details
+    // element so its copy button keeps working
+    function wrap(code) {
+        const pre = code.closest('pre');
+        const container = document.createElement('div');
+        container.className = 'abc-notation';
+        const score = document.createElement('div');
+        score.className = 'abc-score';
+        const source = document.createElement('details');
+        source.className = 'abc-source';
+        const summary = document.createElement('summary');
+        summary.textContent = t('music.source', 'ABC source');
+
+        pre.parentNode.insertBefore(container, pre);
+        source.append(summary, pre);
+        container.append(score, source);
+        return { container, score, text: code.textContent };
+    }
+
+    function render(root) {
+        if (!settings.enabled || !settings.script) return;
+        const blocks = Array.from((root || document).querySelectorAll('pre > code.language-abc'))
+            .filter(code => !code.closest('.abc-notation'));
+        if (blocks.length === 0) return;
+
+        const scores = blocks.map(wrap);
+        loadLibrary().then(ABCJS => {
+            scores.forEach(({ container, score, text }) => {
+                try {
+                    const tunes = ABCJS.renderAbc(score, text, {
+                        responsive: 'resize',
+                        add_classes: true,
+                        foregroundColor: 'currentColor'
+                    });
+                    const warnings = tunes.flatMap(tune => tune.warnings || []);
+                    if (tunes.length === 0) {
+                        showError(container, t('music.no_tune', 'no tune found, tunes start with an X: line'));
+                    } else if (warnings.length > 0) {
+                        // Warnings are HTML built by abcjs from the source, parsed
+                        // without running anything for their text
+                        const parsed = new DOMParser().parseFromString(warnings.join('; '), 'text/html');
+                        showError(container, parsed.body.textContent);
+                    }
+                } catch (error) {
+                    showError(container, error.message);
+                }
+            });
+        }).catch(error => {
+            console.error('Failed to load abcjs:', error);
+            scores.forEach(({ container }) => showError(container, error.message));
+        });
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', () => render(document));
+    } else {
+        render(document);
+    }
+
+    window.ABCNotation = {
+        render: render
+    };
+})();
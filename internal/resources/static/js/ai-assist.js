@@ -0,0 +1,156 @@
+// AI writing actions: sends the selection or the whole page being edited to
+// the wiki, which asks the configured model, and shows the answer for review
+// before it is inserted into the editor
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.ai-dialog');
+    if (!dialog || !document.querySelector('meta[name="ai-writing"]')) return;
+
+    const form = dialog.querySelector('.ai-form');
+    const actionSelect = document.getElementById('aiAction');
+    const scope = dialog.querySelector('.ai-scope');
+    const result = dialog.querySelector('.ai-result');
+    const output = document.getElementById('aiResult');
+    let cm = null;
+    let selection = null; // Range the action works on, null for the whole page
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    // pageTitle returns the title of the page: from its frontmatter, its first
+    // heading or its path
+    function pageTitle() {
+        const content = cm.getValue();
+        const frontmatter = content.match(/^---\r?\n([\s\S]*?)\r?\n---/);
+        if (frontmatter) {
+            const title = frontmatter[1].match(/^title:\s*["']?(.+?)["']?\s*$/m);
+            if (title) return title[1];
+        }
+        const heading = content.match(/^#\s+(.+)$/m);
+        if (heading) return heading[1].trim();
+        const segments = window.location.pathname.split('/').filter(Boolean);
+        if (segments.length === 0) return '';
+        return decodeURIComponent(segments[segments.length - 1]).replace(/[-_]+/g, ' ');
+    }
+
+    function isTitleAction() {
+        return actionSelect.value === 'outline';
+    }
+
+    function updateScope() {
+        if (isTitleAction()) {
+            scope.textContent = t('ai.scope_title', 'Works from the title: {{title}}', { title: pageTitle() });
+        } else if (selection) {
+            scope.textContent = t('ai.scope_selection', 'Works on the selected text.');
+        } else {
+            scope.textContent = t('ai.scope_page', 'Works on the whole page, select text to work on a part of it.');
+        }
+    }
+
+    function open(editor) {
+        cm = editor;
+        selection = cm.somethingSelected()
+            ? { from: cm.getCursor('from'), to: cm.getCursor('to') }
+            : null;
+        if (cm.getValue().trim() === '') {
+            // Only an outline can be made for an empty page
+            actionSelect.value = 'outline';
+        }
+        showError('');
+        output.value = '';
+        result.hidden = true;
+        updateScope();
+        dialog.classList.add('active');
+        setTimeout(() => actionSelect.focus(), 100);
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+        if (cm) cm.focus();
+    }
+
+    async function run(e) {
+        e.preventDefault();
+        showError('');
+        const title = pageTitle();
+        if (isTitleAction() && !title) {
+            showError(t('ai.no_title', 'The page has no title to work from'));
+            return;
+        }
+
+        const submit = form.querySelector('button[type="submit"]');
+        submit.disabled = true;
+        scope.textContent = t('ai.running', 'Waiting for the answer...');
+        try {
+            const resp = await fetch('/api/ai', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    action: actionSelect.value,
+                    text: selection ? cm.getRange(selection.from, selection.to) : cm.getValue(),
+                    title: title,
+                    path: window.location.pathname
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error ? `${data.message}: ${data.error}` : data.message);
+                return;
+            }
+            output.value = data.result;
+            result.hidden = false;
+            // Corrections replace the text they were made from, the rest is added to it
+            const replaces = actionSelect.value === 'fix-grammar' || actionSelect.value === 'improve';
+            dialog.querySelector('.ai-replace').classList.toggle('primary', replaces);
+            dialog.querySelector('.ai-insert').classList.toggle('primary', !replaces);
+        } catch (err) {
+            showError(`${t('ai.failed', 'The AI request failed')}: ${err.message}`);
+        } finally {
+            submit.disabled = false;
+            updateScope();
+        }
+    }
+
+    function replace() {
+        if (selection) {
+            cm.replaceRange(output.value, selection.from, selection.to);
+        } else {
+            cm.setValue(output.value);
+        }
+        closeDialog();
+    }
+
+    function insert() {
+        const end = selection ? selection.to : { line: cm.lastLine(), ch: cm.getLine(cm.lastLine()).length };
+        cm.replaceRange(`\n\n${output.value}\n`, end);
+        cm.setCursor(cm.posFromIndex(cm.indexFromPos(end) + output.value.length + 3));
+        closeDialog();
+    }
+
+    form.addEventListener('submit', run);
+    actionSelect.addEventListener('change', updateScope);
+    dialog.querySelector('.ai-replace').addEventListener('click', replace);
+    dialog.querySelector('.ai-insert').addEventListener('click', insert);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('click', e => {
+        if (e.target === dialog) closeDialog();
+    });
+    document.addEventListener('keydown', e => {
+        if (e.key === 'Escape' && dialog.classList.contains('active')) closeDialog();
+    });
+
+    window.AIAssist = { open };
+})();
@@ -0,0 +1,63 @@
+// Announcement banners: dismissing one hides it for good, on every device for
+// signed-in users and in this browser for visitors without an account
+(function() {
+    'use strict';
+
+    const container = document.querySelector('.announcements');
+    if (!container) return;
+
+    const signedIn = container.dataset.signedIn === 'true';
+    const storageKey = 'dismissedAnnouncements';
+
+    function localDismissed() {
+        try {
+            return JSON.parse(localStorage.getItem(storageKey)) || [];
+        } catch (e) {
+            return [];
+        }
+    }
+
+    function hide(banner) {
+        banner.remove();
+        if (!container.querySelector('.announcement')) {
+            container.remove();
+        }
+    }
+
+    async function dismiss(banner) {
+        const id = banner.dataset.id;
+        if (!signedIn) {
+            const dismissed = localDismissed().filter(other => other !== id);
+            dismissed.push(id);
+            localStorage.setItem(storageKey, JSON.stringify(dismissed.slice(-100)));
+            hide(banner);
+            return;
+        }
+        try {
+            const resp = await fetch('/api/announcements/dismiss', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ id: id })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+            hide(banner);
+        } catch (error) {
+            console.error('Error dismissing the announcement:', error);
+        }
+    }
+
+    // Visitors without an account get every banner from the server
+    if (!signedIn) {
+        const dismissed = localDismissed();
+        container.querySelectorAll('.announcement').forEach(banner => {
+            if (banner.querySelector('.announcement-dismiss') && dismissed.includes(banner.dataset.id)) {
+                hide(banner);
+            }
+        });
+    }
+
+    container.querySelectorAll('.announcement-dismiss').forEach(button => {
+        button.addEventListener('click', () => dismiss(button.closest('.announcement')));
+    });
+})();
@@ -12,6 +12,14 @@
 document.addEventListener('DOMContentLoaded', function() {
     'use strict';
 
+    // Buttons opening a page; the Content Security Policy doesn't allow inline onclick handlers
+    document.querySelectorAll('button[data-href]').forEach(button => {
+        button.addEventListener('click', () => { window.location.href = button.dataset.href; });
+    });
+    document.querySelectorAll('button[data-open]').forEach(button => {
+        button.addEventListener('click', () => { window.open(button.dataset.open, '_blank'); });
+    });
+
     // Initialize Wiki configuration
     window.WikiConfig = {
         // Get config values from meta tags
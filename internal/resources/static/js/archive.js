@@ -0,0 +1,81 @@
+// Archive buttons: editors archive pages so they drop out of navigation and
+// search, and everybody can list archived pages again from the sidebar
+(function() {
+    'use strict';
+
+    const archiveButton = document.querySelector('.page-archive');
+    const undoButton = document.querySelector('.archive-undo');
+    const toggle = document.querySelector('.archived-toggle');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function currentPath() {
+        return '/' + decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    // The cookie is read by the server, which leaves archived pages out of
+    // navigation and search unless it is set
+    function includeArchived() {
+        return document.cookie.split(';').some(cookie => cookie.trim() === 'include_archived=1');
+    }
+
+    function updateToggle() {
+        if (!toggle) return;
+        const included = includeArchived();
+        const label = included ? t('archive.hide', 'Hide archived pages') : t('archive.show', 'Show archived pages');
+        toggle.setAttribute('aria-pressed', included ? 'true' : 'false');
+        toggle.setAttribute('aria-label', label);
+        toggle.title = label;
+    }
+
+    function toggleIncluded() {
+        const maxAge = includeArchived() ? 0 : 60 * 60 * 24 * 365;
+        document.cookie = 'include_archived=1; path=/; max-age=' + maxAge + '; SameSite=Lax';
+        window.location.reload();
+    }
+
+    async function setArchived(path, archived, button) {
+        button.disabled = true;
+        try {
+            const resp = await fetch('/api/archive', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: path, archived: archived })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.error || data.message || resp.statusText);
+            if (window.CommandPalette) {
+                window.CommandPalette.notify(archived ? t('archive.archived', 'Page archived') : t('archive.unarchived', 'Page unarchived'));
+            }
+            window.location.reload();
+        } catch (error) {
+            console.error('Error changing the archive state:', error);
+            button.disabled = false;
+            if (window.DialogSystem) {
+                window.DialogSystem.showMessageDialog(t('archive.title', 'Archive'), t('archive.failed', 'Failed to change the archive state') + ': ' + error.message);
+            }
+        }
+    }
+
+    function toggleArchived() {
+        if (archiveButton.getAttribute('aria-pressed') === 'true') {
+            setArchived(currentPath(), false, archiveButton);
+            return;
+        }
+        const confirmMessage = t('archive.confirm', 'Archive this page and all pages below it?');
+        if (window.DialogSystem && window.DialogSystem.showConfirmDialog) {
+            window.DialogSystem.showConfirmDialog(t('archive.title', 'Archive'), confirmMessage, confirmed => {
+                if (confirmed) setArchived(currentPath(), true, archiveButton);
+            });
+        } else if (window.confirm(confirmMessage)) {
+            setArchived(currentPath(), true, archiveButton);
+        }
+    }
+
+    if (archiveButton) archiveButton.addEventListener('click', toggleArchived);
+    if (undoButton) undoButton.addEventListener('click', () => setArchived(undoButton.dataset.path, false, undoButton));
+    if (toggle) toggle.addEventListener('click', toggleIncluded);
+    updateToggle();
+})();
@@ -0,0 +1,134 @@
+// Ask the wiki: answers questions from the pages the user may read, citing
+// the sections the answer is based on
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.ask-dialog');
+    const button = document.querySelector('.ask-wiki-button');
+    if (!dialog || !button) return;
+
+    const form = dialog.querySelector('.ask-form');
+    const question = document.getElementById('askQuestion');
+    const result = dialog.querySelector('.ask-result');
+    const answer = dialog.querySelector('.ask-answer');
+    const sourcesList = dialog.querySelector('.ask-sources');
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function openDialog() {
+        showError('');
+        dialog.classList.add('active');
+        setTimeout(() => question.focus(), 100);
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    // appendText adds a line of the answer, turning citations like [2] into
+    // links to the cited sections
+    function appendText(parent, text, sources) {
+        let last = 0;
+        text.replace(/\[(\d+)\]/g, (match, number, offset) => {
+            const source = sources.find(s => s.number === Number(number));
+            if (!source) return match;
+            parent.append(text.slice(last, offset));
+            const link = document.createElement('a');
+            link.href = source.url;
+            link.className = 'ask-citation';
+            link.textContent = match;
+            link.title = source.heading ? `${source.title} › ${source.heading}` : source.title;
+            parent.appendChild(link);
+            last = offset + match.length;
+            return match;
+        });
+        parent.append(text.slice(last));
+    }
+
+    // The answer is shown as text, the model's markdown isn't rendered as HTML
+    function showAnswer(data) {
+        answer.innerHTML = '';
+        sourcesList.innerHTML = '';
+        if (data.sources.length === 0) {
+            answer.textContent = t('ask.nothing_found', 'No page of the wiki seems to cover this question.');
+        }
+        (data.answer || '').split(/\n{2,}/).forEach(block => {
+            const paragraph = document.createElement('p');
+            block.split('\n').forEach((line, i) => {
+                if (i > 0) paragraph.appendChild(document.createElement('br'));
+                appendText(paragraph, line, data.sources);
+            });
+            answer.appendChild(paragraph);
+        });
+
+        data.sources.forEach(source => {
+            const item = document.createElement('li');
+            item.value = source.number;
+            const link = document.createElement('a');
+            link.href = source.url;
+            link.textContent = source.title;
+            item.appendChild(link);
+            if (source.heading && source.heading !== source.title) {
+                item.append(` › ${source.heading}`);
+            }
+            sourcesList.appendChild(item);
+        });
+        dialog.querySelector('.ask-sources-title').hidden = data.sources.length === 0;
+        result.hidden = false;
+    }
+
+    async function ask(e) {
+        e.preventDefault();
+        showError('');
+        const submit = form.querySelector('button[type="submit"]');
+        submit.disabled = true;
+        submit.textContent = t('ask.thinking', 'Searching the wiki...');
+        try {
+            const resp = await fetch('/api/ask', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ question: question.value })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error ? `${data.message}: ${data.error}` : data.message);
+                return;
+            }
+            showAnswer(data);
+        } catch (err) {
+            showError(`${t('ask.failed', 'The question could not be answered')}: ${err.message}`);
+        } finally {
+            submit.disabled = false;
+            submit.textContent = t('ask.submit', 'Ask');
+        }
+    }
+
+    button.addEventListener('click', openDialog);
+    form.addEventListener('submit', ask);
+    question.addEventListener('keydown', e => {
+        // Enter asks, Shift+Enter starts a new line
+        if (e.key === 'Enter' && !e.shiftKey) {
+            e.preventDefault();
+            form.requestSubmit();
+        }
+    });
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('click', e => {
+        if (e.target === dialog) closeDialog();
+    });
+    document.addEventListener('keydown', e => {
+        if (e.key === 'Escape' && dialog.classList.contains('active')) closeDialog();
+    });
+})();
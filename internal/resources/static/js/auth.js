@@ -101,7 +101,7 @@
         const keepLoggedIn = document.getElementById('keepLoggedIn')?.checked || false;
 
         try {
-            const response = await fetch('/api/login', {
+            let response = await fetch('/api/login', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
@@ -112,6 +112,9 @@
                     keepLoggedIn
                 })
             });
+            if (window.Passkeys) {
+                response = await window.Passkeys.completeLogin(response);
+            }
 
             if (response.ok) {
                 hideLoginDialog();
@@ -129,7 +132,7 @@
             } else {
                 let msg = window.i18n ? window.i18n.t('login.error') : 'Invalid username or password';
 
-                if (response.status === 429) {
+                if (response.status !== 401) {
                     try {
                         const data = await response.json();
                         if (data && data.message) {
@@ -160,6 +163,11 @@
             });
 
             if (response.ok) {
+                // Saved pages may be private, don't leave them for the next user
+                if (window.OfflinePages) {
+                    await window.OfflinePages.clear();
+                }
+
                 // Update toolbar buttons after logout
                 updateToolbarButtons();
 
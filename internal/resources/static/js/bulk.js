@@ -0,0 +1,228 @@
+// Bulk operations: selects pages in a directory listing and moves, deletes,
+// tags or changes the permissions of all of them in a background job
+(function() {
+    'use strict';
+
+    const bar = document.querySelector('.bulk-bar');
+    const dialog = document.querySelector('.bulk-dialog');
+    if (!bar || !dialog) return;
+
+    const form = dialog.querySelector('.bulk-form');
+    const progress = dialog.querySelector('.bulk-progress');
+    const report = dialog.querySelector('.bulk-report');
+    const doneButton = dialog.querySelector('.bulk-done');
+    const actionSelect = document.getElementById('bulkAction');
+    const selectAll = bar.querySelector('.bulk-select-all');
+    const openButton = bar.querySelector('.bulk-open');
+
+    // Delay between two progress requests
+    const POLL_INTERVAL = 1000;
+
+    let pollTimer = null;
+    let changedPages = false; // The listing is reloaded after pages moved or were deleted
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    // splitList turns "a, b c" into ["a", "b", "c"]
+    function splitList(value) {
+        return value.split(/[\s,]+/).filter(Boolean);
+    }
+
+    function selectedPaths() {
+        return Array.from(document.querySelectorAll('.directory-item .bulk-select:checked'))
+            .map(box => box.closest('.directory-item').dataset.path);
+    }
+
+    function updateSelection() {
+        const boxes = document.querySelectorAll('.directory-item .bulk-select');
+        const count = selectedPaths().length;
+        bar.querySelector('.bulk-count').textContent = count > 0
+            ? t('bulk.selected', '{{count}} selected', { count })
+            : '';
+        openButton.disabled = count === 0;
+        selectAll.checked = count > 0 && count === boxes.length;
+    }
+
+    function showFields() {
+        dialog.querySelectorAll('.bulk-fields').forEach(fields => {
+            fields.hidden = fields.dataset.action !== actionSelect.value;
+        });
+    }
+
+    function openDialog() {
+        const count = selectedPaths().length;
+        if (count === 0) return;
+        showError('');
+        dialog.querySelector('.bulk-selection').textContent = t('bulk.selected', '{{count}} selected', { count });
+        form.hidden = false;
+        progress.hidden = true;
+        showFields();
+        dialog.classList.add('active');
+    }
+
+    function closeDialog() {
+        clearTimeout(pollTimer);
+        dialog.classList.remove('active');
+        if (changedPages) {
+            window.location.reload();
+        }
+    }
+
+    function readRequest() {
+        const request = { action: actionSelect.value, paths: selectedPaths() };
+        switch (request.action) {
+        case 'move':
+            request.target = document.getElementById('bulkTarget').value.trim();
+            break;
+        case 'tag':
+            request.addTags = splitList(document.getElementById('bulkAddTags').value);
+            request.removeTags = splitList(document.getElementById('bulkRemoveTags').value);
+            break;
+        case 'permissions': {
+            // An empty list is inherited from the parent directory
+            const view = splitList(document.getElementById('bulkView').value);
+            const edit = splitList(document.getElementById('bulkEdit').value);
+            request.view = view.length > 0 ? view : null;
+            request.edit = edit.length > 0 ? edit : null;
+            break;
+        }
+        }
+        return request;
+    }
+
+    function renderReport(results) {
+        report.textContent = '';
+        results.forEach(result => {
+            const item = document.createElement('li');
+            if (result.error) {
+                item.className = 'bulk-failed';
+                item.textContent = `${result.path}: ${result.error}`;
+            } else if (result.newPath) {
+                item.textContent = `${result.path} → ${result.newPath}`;
+            } else {
+                item.textContent = result.path;
+            }
+            report.appendChild(item);
+        });
+    }
+
+    function setProgress(done, total, status) {
+        document.getElementById('bulkProgressBar').style.width = total > 0 ? `${Math.round(done / total * 100)}%` : '0%';
+        dialog.querySelector('.bulk-progress-status').textContent = status;
+        dialog.querySelector('.bulk-progress-count').textContent = `${done} / ${total}`;
+    }
+
+    async function poll(id) {
+        try {
+            const resp = await fetch(`/api/bulk/status?id=${encodeURIComponent(id)}`);
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error || data.message || 'Bulk operation failed');
+                doneButton.hidden = false;
+                return;
+            }
+
+            if (data.status === 'done') {
+                const results = data.results || [];
+                const failed = results.filter(result => result.error).length;
+                setProgress(data.total, data.total, t('bulk.finished', '{{count}} pages changed, {{failed}} failed', {
+                    count: results.length - failed,
+                    failed
+                }));
+                renderReport(results);
+                doneButton.hidden = false;
+                return;
+            }
+            if (data.status === 'failed') {
+                setProgress(data.done, data.total, data.lastError);
+                doneButton.hidden = false;
+                return;
+            }
+            setProgress(data.done, data.total, t('bulk.running', 'Working…'));
+        } catch (error) {
+            console.error('Bulk operation error:', error);
+        }
+        pollTimer = setTimeout(() => poll(id), POLL_INTERVAL);
+    }
+
+    async function start(request) {
+        try {
+            const resp = await fetch('/api/bulk', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(request)
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error || data.message || 'Bulk operation failed');
+                return;
+            }
+
+            changedPages = request.action === 'move' || request.action === 'delete';
+            form.hidden = true;
+            progress.hidden = false;
+            doneButton.hidden = true;
+            report.textContent = '';
+            setProgress(0, data.total, t('bulk.queued', 'Waiting to start…'));
+            poll(data.id);
+        } catch (error) {
+            console.error('Bulk operation error:', error);
+            showError('Bulk operation failed');
+        }
+    }
+
+    function run(e) {
+        e.preventDefault();
+        showError('');
+        const request = readRequest();
+        if (request.action !== 'delete') {
+            start(request);
+            return;
+        }
+        window.DialogSystem.showConfirmDialog(
+            t('bulk.delete', 'Delete'),
+            t('bulk.confirm_delete', 'Delete {{count}} pages and all their subpages?', { count: request.paths.length }),
+            confirmed => {
+                if (confirmed) start(request);
+            }
+        );
+    }
+
+    // Add a checkbox to every listed page
+    document.querySelectorAll('.directory-item[data-path]').forEach(item => {
+        const box = document.createElement('input');
+        box.type = 'checkbox';
+        box.className = 'bulk-select';
+        box.setAttribute('aria-label', item.textContent.trim());
+        box.addEventListener('change', updateSelection);
+        item.prepend(box);
+    });
+
+    selectAll.addEventListener('change', () => {
+        document.querySelectorAll('.directory-item .bulk-select').forEach(box => {
+            box.checked = selectAll.checked;
+        });
+        updateSelection();
+    });
+    openButton.addEventListener('click', openDialog);
+    actionSelect.addEventListener('change', showFields);
+    form.addEventListener('submit', run);
+    doneButton.addEventListener('click', closeDialog);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('click', e => {
+        if (e.target === dialog) closeDialog();
+    });
+})();
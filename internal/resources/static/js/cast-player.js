@@ -0,0 +1,702 @@
+/**
+ * Terminal recordings
+ * Plays asciinema recordings (.cast files, versions 1 to 3) in pages with a
+ * small terminal emulator, so their text can be selected and copied. Before
+ * playing, and on paper, the player shows the last screen of the recording.
+ */
+
+(function() {
+    'use strict';
+
+    const speeds = [0.5, 1, 2, 4];
+    const tabWidth = 8;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // Parses a recording into its size and its output events, with times in
+    // seconds from the start and pauses cut to the idle time limit
+    function parseCast(text) {
+        const lines = text.split('\n').filter(line => line.trim() !== '');
+        let header;
+        try {
+            header = JSON.parse(lines[0]);
+        } catch (error) {
+            header = JSON.parse(text);
+        }
+
+        const cast = { cols: 80, rows: 24, events: [], title: header.title || '' };
+        let raw = [];
+        if (header.version === 1) {
+            cast.cols = header.width || cast.cols;
+            cast.rows = header.height || cast.rows;
+            let time = 0;
+            (header.stdout || []).forEach(frame => {
+                time += frame[0];
+                raw.push([time, 'o', frame[1]]);
+            });
+        } else {
+            const term = header.term || {};
+            cast.cols = header.width || term.cols || cast.cols;
+            cast.rows = header.height || term.rows || cast.rows;
+            // Version 3 stores the time since the previous event
+            let time = 0;
+            lines.slice(1).forEach(line => {
+                try {
+                    const event = JSON.parse(line);
+                    time = header.version === 3 ? time + event[0] : event[0];
+                    raw.push([time, event[1], event[2]]);
+                } catch (error) {
+                    // Skip damaged lines rather than the whole recording
+                }
+            });
+        }
+
+        const limit = header.idle_time_limit || header.idleTimeLimit || 0;
+        let previous = 0;
+        let shift = 0;
+        raw.forEach(event => {
+            if (limit > 0 && event[0] - previous > limit) {
+                shift += event[0] - previous - limit;
+            }
+            previous = event[0];
+            if (event[1] === 'o' || event[1] === 'r') {
+                cast.events.push({ time: event[0] - shift, type: event[1], data: event[2] });
+            }
+        });
+        cast.duration = cast.events.length ? cast.events[cast.events.length - 1].time : 0;
+        return cast;
+    }
+
+    /**
+     * Terminal keeps the screen of a recording: a grid of characters with
+     * their colors, changed by the output and escape sequences written to it
+     */
+    class Terminal {
+        constructor(cols, rows) {
+            this.resize(cols, rows);
+        }
+
+        resize(cols, rows) {
+            this.cols = cols;
+            this.rows = rows;
+            this.reset();
+        }
+
+        reset() {
+            this.style = {};
+            this.x = 0;
+            this.y = 0;
+            this.saved = { x: 0, y: 0, style: {} };
+            this.top = 0;
+            this.bottom = this.rows - 1;
+            this.cursorVisible = true;
+            this.alternate = null;
+            this.state = 'ground';
+            this.params = '';
+            this.lines = [];
+            for (let i = 0; i < this.rows; i++) this.lines.push(this.blankLine());
+        }
+
+        blankLine() {
+            const line = [];
+            for (let i = 0; i < this.cols; i++) line.push({ ch: ' ', style: this.style });
+            return line;
+        }
+
+        write(data) {
+            for (const ch of data) {
+                switch (this.state) {
+                case 'escape':
+                    this.escape(ch);
+                    break;
+                case 'csi':
+                    if (ch >= '@' && ch <= '~') {
+                        this.state = 'ground';
+                        this.csi(ch, this.params);
+                    } else {
+                        this.params += ch;
+                    }
+                    break;
+                case 'osc':
+                    // Window titles and the like end with BEL or ESC \
+                    if (ch === '\x07') this.state = 'ground';
+                    else if (ch === '\x1b') this.state = 'escape';
+                    break;
+                case 'charset':
+                    this.state = 'ground';
+                    break;
+                default:
+                    this.ground(ch);
+                }
+            }
+        }
+
+        ground(ch) {
+            switch (ch) {
+            case '\x1b':
+                this.state = 'escape';
+                return;
+            case '\r':
+                this.x = 0;
+                return;
+            case '\n':
+            case '\x0b':
+            case '\x0c':
+                this.lineFeed();
+                return;
+            case '\b':
+                this.x = Math.max(0, Math.min(this.x, this.cols - 1) - 1);
+                return;
+            case '\t':
+                this.x = Math.min(this.cols - 1, (Math.floor(this.x / tabWidth) + 1) * tabWidth);
+                return;
+            }
+            if (ch < ' ' || ch === '\x7f') return;
+            if (this.x >= this.cols) {
+                this.x = 0;
+                this.lineFeed();
+            }
+            this.lines[this.y][this.x] = { ch: ch, style: this.style };
+            this.x++;
+        }
+
+        escape(ch) {
+            this.state = 'ground';
+            switch (ch) {
+            case '[':
+                this.state = 'csi';
+                this.params = '';
+                break;
+            case ']':
+                this.state = 'osc';
+                break;
+            case '(':
+            case ')':
+                this.state = 'charset';
+                break;
+            case '7':
+                this.saveCursor();
+                break;
+            case '8':
+                this.restoreCursor();
+                break;
+            case 'D':
+                this.lineFeed();
+                break;
+            case 'E':
+                this.x = 0;
+                this.lineFeed();
+                break;
+            case 'M':
+                if (this.y === this.top) this.scrollDown(1);
+                else this.y = Math.max(0, this.y - 1);
+                break;
+            case 'c':
+                this.reset();
+                break;
+            }
+        }
+
+        csi(final, raw) {
+            const priv = raw.startsWith('?');
+            const params = (priv ? raw.slice(1) : raw).split(';').map(p => parseInt(p, 10));
+            const n = isNaN(params[0]) || params[0] === 0 ? 1 : params[0];
+            switch (final) {
+            case 'A': this.y = Math.max(this.y >= this.top ? this.top : 0, this.y - n); break;
+            case 'B': this.y = Math.min(this.y <= this.bottom ? this.bottom : this.rows - 1, this.y + n); break;
+            case 'C': this.x = Math.min(this.cols - 1, this.x + n); break;
+            case 'D': this.x = Math.max(0, Math.min(this.x, this.cols - 1) - n); break;
+            case 'E': this.x = 0; this.y = Math.min(this.rows - 1, this.y + n); break;
+            case 'F': this.x = 0; this.y = Math.max(0, this.y - n); break;
+            case 'G':
+            case '`':
+                this.x = Math.min(this.cols - 1, n - 1);
+                break;
+            case 'd': this.y = Math.min(this.rows - 1, n - 1); break;
+            case 'H':
+            case 'f':
+                this.y = Math.min(this.rows - 1, (params[0] || 1) - 1);
+                this.x = Math.min(this.cols - 1, (params[1] || 1) - 1);
+                break;
+            case 'J': this.eraseDisplay(params[0] || 0); break;
+            case 'K': this.eraseLine(params[0] || 0); break;
+            case 'X': this.fill(this.y, this.x, Math.min(this.cols, this.x + n)); break;
+            case 'P': {
+                const line = this.lines[this.y];
+                line.splice(this.x, n);
+                while (line.length < this.cols) line.push({ ch: ' ', style: this.style });
+                break;
+            }
+            case '@': {
+                const line = this.lines[this.y];
+                for (let i = 0; i < n; i++) line.splice(this.x, 0, { ch: ' ', style: this.style });
+                line.length = this.cols;
+                break;
+            }
+            case 'L': this.insertLines(n); break;
+            case 'M': this.deleteLines(n); break;
+            case 'S': this.scrollUp(n); break;
+            case 'T': this.scrollDown(n); break;
+            case 'r':
+                this.top = Math.max(0, (params[0] || 1) - 1);
+                this.bottom = Math.min(this.rows - 1, (params[1] || this.rows) - 1);
+                if (this.top >= this.bottom) {
+                    this.top = 0;
+                    this.bottom = this.rows - 1;
+                }
+                this.x = 0;
+                this.y = 0;
+                break;
+            case 's': this.saveCursor(); break;
+            case 'u': this.restoreCursor(); break;
+            case 'm': this.sgr(raw === '' ? [0] : params); break;
+            case 'h':
+            case 'l':
+                if (priv) this.mode(params, final === 'h');
+                break;
+            }
+        }
+
+        mode(params, on) {
+            params.forEach(param => {
+                if (param === 25) {
+                    this.cursorVisible = on;
+                } else if (param === 47 || param === 1047 || param === 1049) {
+                    // Full-screen programs draw on a screen of their own and
+                    // leave the shell as it was when they quit
+                    if (on && !this.alternate) {
+                        this.alternate = { lines: this.lines, x: this.x, y: this.y };
+                        this.lines = [];
+                        for (let i = 0; i < this.rows; i++) this.lines.push(this.blankLine());
+                    } else if (!on && this.alternate) {
+                        this.lines = this.alternate.lines;
+                        this.x = this.alternate.x;
+                        this.y = this.alternate.y;
+                        this.alternate = null;
+                    }
+                }
+            });
+        }
+
+        sgr(params) {
+            const style = Object.assign({}, this.style);
+            for (let i = 0; i < params.length; i++) {
+                const p = isNaN(params[i]) ? 0 : params[i];
+                if (p === 0) {
+                    Object.keys(style).forEach(key => delete style[key]);
+                } else if (p === 1) style.bold = true;
+                else if (p === 2) style.dim = true;
+                else if (p === 3) style.italic = true;
+                else if (p === 4) style.underline = true;
+                else if (p === 7) style.inverse = true;
+                else if (p === 9) style.strike = true;
+                else if (p === 22) { delete style.bold; delete style.dim; }
+                else if (p === 23) delete style.italic;
+                else if (p === 24) delete style.underline;
+                else if (p === 27) delete style.inverse;
+                else if (p === 29) delete style.strike;
+                else if (p >= 30 && p <= 37) style.fg = p - 30;
+                else if (p === 39) delete style.fg;
+                else if (p >= 40 && p <= 47) style.bg = p - 40;
+                else if (p === 49) delete style.bg;
+                else if (p >= 90 && p <= 97) style.fg = p - 90 + 8;
+                else if (p >= 100 && p <= 107) style.bg = p - 100 + 8;
+                else if (p === 38 || p === 48) {
+                    const key = p === 38 ? 'fg' : 'bg';
+                    if (params[i + 1] === 5) {
+                        style[key] = params[i + 2];
+                        i += 2;
+                    } else if (params[i + 1] === 2) {
+                        style[key] = 'rgb(' + [params[i + 2], params[i + 3], params[i + 4]].map(v => v || 0).join(',') + ')';
+                        i += 4;
+                    }
+                }
+            }
+            this.style = style;
+        }
+
+        saveCursor() {
+            this.saved = { x: this.x, y: this.y, style: this.style };
+        }
+
+        restoreCursor() {
+            this.x = this.saved.x;
+            this.y = this.saved.y;
+            this.style = this.saved.style;
+        }
+
+        lineFeed() {
+            if (this.y === this.bottom) this.scrollUp(1);
+            else if (this.y < this.rows - 1) this.y++;
+        }
+
+        scrollUp(n) {
+            for (let i = 0; i < n; i++) {
+                this.lines.splice(this.top, 1);
+                this.lines.splice(this.bottom, 0, this.blankLine());
+            }
+        }
+
+        scrollDown(n) {
+            for (let i = 0; i < n; i++) {
+                this.lines.splice(this.bottom, 1);
+                this.lines.splice(this.top, 0, this.blankLine());
+            }
+        }
+
+        insertLines(n) {
+            if (this.y < this.top || this.y > this.bottom) return;
+            for (let i = 0; i < n; i++) {
+                this.lines.splice(this.bottom, 1);
+                this.lines.splice(this.y, 0, this.blankLine());
+            }
+        }
+
+        deleteLines(n) {
+            if (this.y < this.top || this.y > this.bottom) return;
+            for (let i = 0; i < n; i++) {
+                this.lines.splice(this.y, 1);
+                this.lines.splice(this.bottom, 0, this.blankLine());
+            }
+        }
+
+        fill(row, from, to) {
+            for (let x = from; x < to; x++) this.lines[row][x] = { ch: ' ', style: this.style };
+        }
+
+        eraseLine(mode) {
+            const x = Math.min(this.x, this.cols - 1);
+            if (mode === 0) this.fill(this.y, x, this.cols);
+            else if (mode === 1) this.fill(this.y, 0, x + 1);
+            else this.fill(this.y, 0, this.cols);
+        }
+
+        eraseDisplay(mode) {
+            if (mode === 0) {
+                this.eraseLine(0);
+                for (let y = this.y + 1; y < this.rows; y++) this.fill(y, 0, this.cols);
+            } else if (mode === 1) {
+                this.eraseLine(1);
+                for (let y = 0; y < this.y; y++) this.fill(y, 0, this.cols);
+            } else {
+                for (let y = 0; y < this.rows; y++) this.fill(y, 0, this.cols);
+            }
+        }
+    }
+
+    // Returns the class names and inline style of a cell
+    function cellStyle(style) {
+        let fg = style.fg;
+        let bg = style.bg;
+        if (style.inverse) {
+            [fg, bg] = [bg === undefined ? 'default-bg' : bg, fg === undefined ? 'default-fg' : fg];
+        }
+        if (style.bold && typeof fg === 'number' && fg < 8) fg += 8;
+
+        const classes = [];
+        const css = [];
+        const color = (value, prefix, property) => {
+            if (value === undefined) return;
+            if (typeof value === 'string' && value.startsWith('default')) {
+                classes.push(prefix + '-' + value);
+            } else if (typeof value === 'number' && value < 16) {
+                classes.push(prefix + '-' + value);
+            } else if (typeof value === 'number') {
+                css.push(property + ':' + palette256(value));
+            } else {
+                css.push(property + ':' + value);
+            }
+        };
+        color(fg, 'cast-fg', 'color');
+        color(bg, 'cast-bg', 'background-color');
+        ['bold', 'dim', 'italic', 'underline', 'strike'].forEach(name => {
+            if (style[name]) classes.push('cast-' + name);
+        });
+        return { classes: classes.join(' '), css: css.join(';') };
+    }
+
+    // Returns the color of an entry of the 256-color palette above the first 16
+    function palette256(index) {
+        if (index >= 232) {
+            const level = 8 + (index - 232) * 10;
+            return 'rgb(' + level + ',' + level + ',' + level + ')';
+        }
+        const cube = index - 16;
+        const level = v => (v === 0 ? 0 : 55 + v * 40);
+        return 'rgb(' + level(Math.floor(cube / 36)) + ',' + level(Math.floor(cube / 6) % 6) + ',' + level(cube % 6) + ')';
+    }
+
+    // Draws the screen of term into screen as runs of equally styled text
+    function draw(term, screen, showCursor) {
+        const fragment = document.createDocumentFragment();
+        term.lines.forEach((line, y) => {
+            const row = document.createElement('div');
+            row.className = 'cast-line';
+            let run = null;
+            let key = null;
+            line.forEach((cell, x) => {
+                const cursor = showCursor && term.cursorVisible && y === term.y && x === Math.min(term.x, term.cols - 1);
+                const style = cellStyle(cell.style);
+                const cellKey = cursor ? null : style.classes + '|' + style.css;
+                if (!run || cellKey === null || cellKey !== key) {
+                    run = document.createElement('span');
+                    if (style.classes) run.className = style.classes;
+                    if (style.css) run.style.cssText = style.css;
+                    if (cursor) run.classList.add('cast-cursor');
+                    row.appendChild(run);
+                    key = cellKey;
+                }
+                run.textContent += cell.ch;
+                if (cursor) run = null;
+            });
+            // Copied text keeps no trailing blanks
+            const last = row.lastChild;
+            if (last && !last.className && !last.style.cssText) last.textContent = last.textContent.replace(/ +$/, '');
+            fragment.appendChild(row);
+        });
+        screen.replaceChildren(fragment);
+    }
+
+    function formatTime(seconds) {
+        seconds = Math.max(0, Math.floor(seconds));
+        return Math.floor(seconds / 60) + ':' + String(seconds % 60).padStart(2, '0');
+    }
+
+    /**
+     * Player shows one recording with its play button, timeline and speed
+     */
+    class Player {
+        constructor(container, cast) {
+            this.container = container;
+            this.cast = cast;
+            this.term = new Terminal(cast.cols, cast.rows);
+            this.index = 0;
+            this.time = 0;
+            this.speed = 1;
+            this.playing = false;
+            this.build();
+            this.seek(cast.duration);
+            this.setPosition(cast.duration);
+            this.showCursor = false;
+            draw(this.term, this.screen, false);
+        }
+
+        build() {
+            const title = this.container.dataset.title || this.cast.title;
+            this.container.replaceChildren();
+            this.container.classList.add('cast-ready');
+            this.container.setAttribute('role', 'group');
+            this.container.setAttribute('aria-label', t('cast.recording', 'Terminal recording') + (title ? ': ' + title : ''));
+
+            this.screen = document.createElement('pre');
+            this.screen.className = 'cast-screen';
+            this.screen.style.setProperty('--cast-cols', this.cast.cols);
+            this.screen.tabIndex = 0;
+
+            const controls = document.createElement('div');
+            controls.className = 'cast-controls';
+
+            this.button = document.createElement('button');
+            this.button.type = 'button';
+            this.button.className = 'cast-play';
+            this.button.addEventListener('click', () => this.toggle());
+
+            this.timeline = document.createElement('input');
+            this.timeline.type = 'range';
+            this.timeline.className = 'cast-timeline';
+            this.timeline.min = 0;
+            this.timeline.max = this.cast.duration;
+            this.timeline.step = 'any';
+            this.timeline.setAttribute('aria-label', t('cast.position', 'Position'));
+            this.timeline.addEventListener('input', () => {
+                this.seek(parseFloat(this.timeline.value));
+                this.showCursor = true;
+                draw(this.term, this.screen, true);
+                this.updateClock();
+            });
+
+            this.clock = document.createElement('span');
+            this.clock.className = 'cast-time';
+
+            this.speedSelect = document.createElement('select');
+            this.speedSelect.className = 'cast-speed';
+            this.speedSelect.setAttribute('aria-label', t('cast.speed', 'Speed'));
+            speeds.forEach(speed => {
+                const option = document.createElement('option');
+                option.value = speed;
+                option.textContent = speed + '×';
+                option.selected = speed === 1;
+                this.speedSelect.appendChild(option);
+            });
+            this.speedSelect.addEventListener('change', () => {
+                this.speed = parseFloat(this.speedSelect.value);
+                if (this.playing) this.startClock();
+            });
+
+            controls.append(this.button, this.timeline, this.clock, this.speedSelect);
+            if (title) {
+                const caption = document.createElement('span');
+                caption.className = 'cast-title';
+                caption.textContent = title;
+                controls.appendChild(caption);
+            }
+            this.container.append(this.screen, controls);
+
+            this.screen.addEventListener('keydown', event => {
+                if (event.key === ' ' || event.key === 'k') {
+                    event.preventDefault();
+                    this.toggle();
+                } else if (event.key === 'ArrowLeft' || event.key === 'ArrowRight') {
+                    event.preventDefault();
+                    const time = this.time + (event.key === 'ArrowLeft' ? -5 : 5);
+                    this.seek(Math.max(0, Math.min(this.cast.duration, time)));
+                    if (this.playing) this.startClock();
+                    draw(this.term, this.screen, true);
+                    this.setPosition(this.time);
+                }
+            });
+            this.updateButton();
+        }
+
+        // Replays the recording up to time, from the start when going back
+        seek(time) {
+            if (time < this.time || this.index === 0) {
+                this.term.resize(this.cast.cols, this.cast.rows);
+                this.screen.style.setProperty('--cast-cols', this.cast.cols);
+                this.index = 0;
+            }
+            const events = this.cast.events;
+            while (this.index < events.length && events[this.index].time <= time) {
+                this.apply(events[this.index]);
+                this.index++;
+            }
+            this.time = time;
+        }
+
+        apply(event) {
+            if (event.type === 'o') {
+                this.term.write(event.data);
+                return;
+            }
+            const size = /^(\d+)x(\d+)$/.exec(event.data || '');
+            if (size) {
+                const lines = this.term.lines;
+                this.term.resize(parseInt(size[1], 10), parseInt(size[2], 10));
+                this.screen.style.setProperty('--cast-cols', this.term.cols);
+                lines.slice(-this.term.rows).forEach((line, y) => {
+                    line.slice(0, this.term.cols).forEach((cell, x) => { this.term.lines[y][x] = cell; });
+                });
+            }
+        }
+
+        toggle() {
+            if (this.playing) {
+                this.pause();
+            } else {
+                this.play();
+            }
+        }
+
+        play() {
+            if (this.time >= this.cast.duration) this.seek(0);
+            this.playing = true;
+            this.showCursor = true;
+            this.startClock();
+            this.updateButton();
+            this.tick();
+        }
+
+        pause() {
+            this.playing = false;
+            cancelAnimationFrame(this.frame);
+            this.updateButton();
+        }
+
+        startClock() {
+            this.startedAt = performance.now();
+            this.startTime = this.time;
+        }
+
+        tick() {
+            if (!this.playing) return;
+            const time = Math.min(this.cast.duration, this.startTime + (performance.now() - this.startedAt) / 1000 * this.speed);
+            const before = this.index;
+            this.seek(time);
+            if (this.index !== before) draw(this.term, this.screen, true);
+            this.setPosition(time);
+            if (time >= this.cast.duration) {
+                this.pause();
+                draw(this.term, this.screen, false);
+                return;
+            }
+            this.frame = requestAnimationFrame(() => this.tick());
+        }
+
+        setPosition(time) {
+            this.timeline.value = time;
+            this.updateClock();
+        }
+
+        updateClock() {
+            this.clock.textContent = formatTime(this.time) + ' / ' + formatTime(this.cast.duration);
+        }
+
+        updateButton() {
+            const label = this.playing ? t('cast.pause', 'Pause') : t('cast.play', 'Play');
+            this.button.innerHTML = '<i class="fa ' + (this.playing ? 'fa-pause' : 'fa-play') + '" aria-hidden="true"></i>';
+            this.button.title = label;
+            this.button.setAttribute('aria-label', label);
+        }
+    }
+
+    async function load(container) {
+        if (container.dataset.loaded) return;
+        container.dataset.loaded = 'true';
+        try {
+            const resp = await fetch(container.dataset.src);
+            if (!resp.ok) throw new Error(resp.statusText);
+            new Player(container, parseCast(await resp.text()));
+        } catch (error) {
+            console.error('Error loading terminal recording ' + container.dataset.src + ':', error);
+            container.classList.add('cast-failed');
+            const note = document.createElement('span');
+            note.className = 'cast-error';
+            note.textContent = t('cast.load_failed', 'The recording could not be loaded');
+            container.appendChild(note);
+        }
+    }
+
+    function init() {
+        const containers = document.querySelectorAll('.cast-player[data-src]');
+        if (!containers.length) return;
+        // Recordings are loaded when they are scrolled into view
+        if (!('IntersectionObserver' in window)) {
+            containers.forEach(load);
+            return;
+        }
+        const observer = new IntersectionObserver(entries => {
+            entries.forEach(entry => {
+                if (!entry.isIntersecting) return;
+                observer.unobserve(entry.target);
+                load(entry.target);
+            });
+        }, { rootMargin: '200px' });
+        containers.forEach(container => observer.observe(container));
+        // Printing shows the last screen of every recording
+        window.addEventListener('beforeprint', () => containers.forEach(load));
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', init);
+    } else {
+        init();
+    }
+
+    window.CastPlayer = {
+        parse: parseCast,
+        Terminal: Terminal
+    };
+})();
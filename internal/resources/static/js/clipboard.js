@@ -1,8 +1,12 @@
 /**
  * Clipboard handling module for Wiki-Go
- * Enables pasting images from clipboard directly into the editor and URL link creation
+ * Enables pasting images from clipboard directly into the editor, URL link creation
+ * and converting rich text (Word, Google Docs, web pages) to markdown
  */
 
+// Elements whose presence makes pasted HTML worth converting to markdown
+const RICH_PASTE_SELECTOR = 'table, ul, ol, h1, h2, h3, h4, h5, h6, img, a[href], b, strong, em, i, li, blockquote, pre';
+
 // Main initialization function for clipboard handling
 function initClipboardHandling() {
     console.log('Initializing clipboard handler');
@@ -150,10 +154,110 @@ async function documentPasteHandler(event) {
         return; // Not pasting in the editor, allow default behavior
     }
 
-    // Handle paste event for images
+    // Convert formatted text to markdown, or handle images
+    if (await handleRichPaste(event)) {
+        return;
+    }
     await handleImagePaste(event);
 }
 
+/**
+ * Convert pasted formatted text to markdown on the server and insert it.
+ * Plain text pastes (Ctrl+Shift+V) carry no HTML and are left alone, as are
+ * images copied on their own.
+ * @param {ClipboardEvent} event - The paste event
+ * @returns {Promise<boolean>} Whether the paste was handled
+ */
+async function handleRichPaste(event) {
+    if (!event.clipboardData) {
+        return false;
+    }
+    const html = event.clipboardData.getData('text/html');
+    if (!html) {
+        return false;
+    }
+
+    const doc = new DOMParser().parseFromString(html, 'text/html');
+    if (!doc.body.querySelector(RICH_PASTE_SELECTOR)) {
+        return false;
+    }
+
+    const images = Array.from(event.clipboardData.items)
+        .filter(item => item.kind === 'file' && item.type.startsWith('image/'))
+        .map(item => item.getAsFile())
+        .filter(Boolean);
+
+    // A copied image comes with HTML holding just the image, upload the file
+    if (images.length && !doc.body.textContent.trim() && doc.body.querySelectorAll('img').length <= 1) {
+        return false;
+    }
+
+    const cmElement = document.querySelector('.CodeMirror');
+    if (!cmElement || !cmElement.CodeMirror) {
+        return false;
+    }
+    const editor = cmElement.CodeMirror;
+
+    event.preventDefault();
+    await insertConvertedMarkdown(editor, '/api/paste', formData => {
+        formData.append('html', html);
+        images.forEach((image, i) => formData.append('images', image, `image-${i}.${image.type.split('/')[1] || 'png'}`));
+    }, event.clipboardData.getData('text/plain'));
+    return true;
+}
+
+/**
+ * Convert a Word document to markdown on the server and insert it at the cursor
+ * @param {CodeMirror} editor - The editor instance
+ * @param {File} file - The .docx file
+ */
+async function insertDocx(editor, file) {
+    await insertConvertedMarkdown(editor, '/api/paste/docx', formData => {
+        formData.append('file', file, file.name);
+    }, '');
+}
+
+/**
+ * Send rich text to a conversion endpoint and replace the selection with the
+ * markdown returned. Images of the rich text are saved as attachments of the
+ * current document by the server.
+ * @param {CodeMirror} editor - The editor instance
+ * @param {string} url - The conversion endpoint
+ * @param {Function} fill - Adds the content to convert to the form data
+ * @param {string} fallbackText - Inserted instead when the conversion fails
+ */
+async function insertConvertedMarkdown(editor, url, fill, fallbackText) {
+    const from = editor.getCursor('from');
+    const to = editor.getCursor('to');
+    const t = (key, fallback) => (window.i18n ? window.i18n.t(key) : fallback);
+
+    const formData = new FormData();
+    formData.append('docPath', getCurrentDocPath());
+    fill(formData);
+
+    try {
+        const response = await fetch(url, { method: 'POST', body: formData });
+        const data = await response.json();
+        if (!response.ok || !data.success) {
+            throw new Error(data.message || response.statusText);
+        }
+
+        editor.replaceRange(data.markdown, from, to);
+        editor.focus();
+        if (data.missing > 0) {
+            window.DialogSystem.showMessageDialog('Warning',
+                t('editor.paste_images_missing', 'Some images could not be pasted, copy them on their own to add them'));
+        }
+    } catch (error) {
+        console.error('Failed to convert pasted content:', error);
+        if (fallbackText) {
+            editor.replaceRange(fallbackText, from, to);
+        }
+        window.DialogSystem.showMessageDialog('Error',
+            t('editor.paste_convert_failed', 'Failed to convert to markdown') + ': ' + error.message);
+    }
+}
+
 /**
  * Handle image paste events in the editor
  * @param {ClipboardEvent} event - The paste event
@@ -191,6 +295,16 @@ async function handleImagePaste(event) {
     // Prevent default paste behavior for images
     event.preventDefault();
 
+    await uploadImage(editor, imageFile);
+}
+
+/**
+ * Upload an image as an attachment of the current document and insert it at
+ * the cursor, showing a placeholder while it uploads
+ * @param {CodeMirror} editor - The editor instance
+ * @param {File} imageFile - The image to upload
+ */
+async function uploadImage(editor, imageFile) {
     try {
         // Get the current document path
         const docPath = getCurrentDocPath();
@@ -273,5 +387,8 @@ document.addEventListener('DOMContentLoaded', initClipboardHandling);
 window.ClipboardHandler = {
     init: initClipboardHandling,
     handleUrlPaste: handleUrlPaste,
-    handleImagePaste: handleImagePaste
+    handleImagePaste: handleImagePaste,
+    handleRichPaste: handleRichPaste,
+    insertDocx: insertDocx,
+    uploadImage: uploadImage
 };
\ No newline at end of file
@@ -0,0 +1,802 @@
+// Command palette: Ctrl/Cmd+K opens a searchable list of the pages of the wiki
+// and the actions available on the current page. Other scripts add their own
+// commands with window.CommandPalette.register.
+(function() {
+    'use strict';
+
+    const palette = document.querySelector('.command-palette');
+    if (!palette) return;
+
+    const container = palette.querySelector('.command-palette-container');
+    const input = palette.querySelector('.command-palette-input');
+    const list = palette.querySelector('.command-palette-list');
+    const empty = palette.querySelector('.command-palette-empty');
+    const toast = document.querySelector('.command-palette-toast');
+
+    const maxResults = 50;
+    const searchDelay = 200;
+    const toastDuration = 2000;
+
+    const commands = new Map(); // Registered commands by id, in registration order
+    let items = [];             // Commands available since the palette opened
+    let results = [];
+    let selected = 0;
+    let searchTimer = null;
+    let searchQuery = '';
+    let searchPages = [];       // Pages found by the full-text search for searchQuery
+    let lastFocus = null;
+    let toastTimer = null;
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        if (!text || text === key) text = fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    // register adds a command to the palette and returns a function removing it.
+    // A command has an id, a title (or a function returning it) and a run
+    // function, and optionally a section, a Font Awesome icon, keywords also
+    // matched by the query, a shortcut (an action of keyboard-shortcuts.js) and
+    // a when function telling whether it applies to the current page.
+    function register(command) {
+        if (!command || !command.id || !command.title || typeof command.run !== 'function') {
+            console.warn('Command palette: commands need an id, a title and a run function', command);
+            return () => {};
+        }
+        commands.set(command.id, command);
+        return () => unregister(command.id);
+    }
+
+    function unregister(id) {
+        commands.delete(id);
+    }
+
+    function resolve(value) {
+        return typeof value === 'function' ? value() : value;
+    }
+
+    // availableCommands returns the commands applying to the current page,
+    // with their titles in the current language
+    function availableCommands() {
+        const available = [];
+        commands.forEach(command => {
+            try {
+                if (command.when && !command.when()) return;
+                available.push({
+                    command,
+                    title: resolve(command.title),
+                    detail: resolve(command.section) || '',
+                    keywords: resolve(command.keywords) || '',
+                    icon: command.icon || 'fa-bolt',
+                    shortcut: command.shortcut && window.KeyboardShortcuts ? window.KeyboardShortcuts.shortcutLabel(command.shortcut) : ''
+                });
+            } catch (error) {
+                console.error(`Command palette: command ${command.id} failed`, error);
+            }
+        });
+        return available;
+    }
+
+    // sidebarPages returns the pages linked from the sidebar
+    function sidebarPages() {
+        const pages = [];
+        document.querySelectorAll('.nav-items .nav-item > a[href]').forEach(link => {
+            const title = link.textContent.trim();
+            if (title) pages.push({ title, path: link.getAttribute('href') });
+        });
+        return pages;
+    }
+
+    function pageItem(page) {
+        return {
+            title: page.title,
+            detail: page.path,
+            keywords: page.path,
+            icon: 'fa-file-o',
+            path: page.path,
+            run: () => { window.location.href = page.path; }
+        };
+    }
+
+    // score rates how well text matches a word of the query: whole words
+    // first, then substrings, then letters in order. 0 means no match.
+    function score(word, text) {
+        text = text.toLowerCase();
+        const index = text.indexOf(word);
+        if (index === 0) return 100;
+        if (index > 0) return /[\s\-_/:›]/.test(text[index - 1]) ? 80 : 60;
+
+        let position = -1;
+        let gaps = 0;
+        for (const char of word) {
+            const next = text.indexOf(char, position + 1);
+            if (next === -1) return 0;
+            gaps += next - position - 1;
+            position = next;
+        }
+        return Math.max(1, 40 - gaps);
+    }
+
+    function match(item, words) {
+        let total = 0;
+        for (const word of words) {
+            const best = Math.max(score(word, item.title), score(word, item.keywords) * 0.8);
+            if (!best) return 0;
+            total += best;
+        }
+        return total;
+    }
+
+    function filter(query) {
+        const words = query.toLowerCase().split(/\s+/).filter(Boolean);
+        if (!words.length) return items.slice(0, maxResults);
+
+        const candidates = items.slice();
+        const seen = new Set();
+        sidebarPages().forEach(page => {
+            if (seen.has(page.path)) return;
+            seen.add(page.path);
+            candidates.push(pageItem(page));
+        });
+
+        const ranked = [];
+        candidates.forEach((item, index) => {
+            const rating = match(item, words);
+            if (rating) ranked.push({ item, rating, index });
+        });
+        // Pages whose content matched, after the ones whose title did
+        if (searchQuery === query) {
+            searchPages.forEach((page, index) => {
+                if (seen.has(page.path)) return;
+                seen.add(page.path);
+                ranked.push({ item: pageItem(page), rating: 0, index: candidates.length + index });
+            });
+        }
+        ranked.sort((a, b) => b.rating - a.rating || a.index - b.index);
+        return ranked.slice(0, maxResults).map(entry => entry.item);
+    }
+
+    function render() {
+        list.textContent = '';
+        results.forEach((item, index) => {
+            const element = document.createElement('li');
+            element.className = 'command-palette-item';
+            element.id = 'command-palette-item-' + index;
+            element.setAttribute('role', 'option');
+            element.setAttribute('aria-selected', index === selected ? 'true' : 'false');
+
+            const icon = document.createElement('i');
+            icon.className = 'fa ' + item.icon;
+            icon.setAttribute('aria-hidden', 'true');
+            element.appendChild(icon);
+
+            const title = document.createElement('span');
+            title.className = 'command-palette-title';
+            title.textContent = item.title;
+            element.appendChild(title);
+
+            if (item.detail) {
+                const detail = document.createElement('span');
+                detail.className = 'command-palette-detail';
+                detail.textContent = item.detail;
+                element.appendChild(detail);
+            }
+            if (item.shortcut) {
+                const shortcut = document.createElement('kbd');
+                shortcut.className = 'command-palette-shortcut';
+                shortcut.textContent = item.shortcut;
+                element.appendChild(shortcut);
+            }
+
+            element.addEventListener('mousemove', () => {
+                if (selected !== index) select(index);
+            });
+            element.addEventListener('click', () => runItem(item));
+            list.appendChild(element);
+        });
+        empty.hidden = results.length > 0;
+        updateSelection();
+    }
+
+    function updateSelection() {
+        list.querySelectorAll('.command-palette-item').forEach((element, index) => {
+            element.setAttribute('aria-selected', index === selected ? 'true' : 'false');
+        });
+        const current = list.children[selected];
+        if (current) {
+            input.setAttribute('aria-activedescendant', current.id);
+            current.scrollIntoView({ block: 'nearest' });
+        } else {
+            input.removeAttribute('aria-activedescendant');
+        }
+    }
+
+    function select(index) {
+        if (!results.length) return;
+        selected = (index + results.length) % results.length;
+        updateSelection();
+    }
+
+    function refresh() {
+        results = filter(input.value.trim());
+        selected = 0;
+        render();
+    }
+
+    // searchContent looks the query up in the contents of the pages, so pages
+    // not in the sidebar can be opened too
+    function searchContent() {
+        clearTimeout(searchTimer);
+        const query = input.value.trim();
+        if (query.length < 2) return;
+        searchTimer = setTimeout(async () => {
+            try {
+                const response = await fetch('/api/search', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ query })
+                });
+                if (!response.ok) return;
+                const found = await response.json();
+                if (input.value.trim() !== query || !isOpen()) return;
+                searchQuery = query;
+                searchPages = (Array.isArray(found) ? found : []).map(result => ({ title: result.title, path: result.path }));
+                const current = results[selected];
+                results = filter(query);
+                selected = Math.max(0, results.indexOf(current));
+                render();
+            } catch (error) {
+                console.error('Command palette search failed:', error);
+            }
+        }, searchDelay);
+    }
+
+    function runItem(item) {
+        close();
+        try {
+            const result = item.command ? item.command.run() : item.run();
+            if (result && typeof result.catch === 'function') {
+                result.catch(error => console.error('Command failed:', error));
+            }
+        } catch (error) {
+            console.error('Command failed:', error);
+        }
+    }
+
+    function isOpen() {
+        return palette.classList.contains('active');
+    }
+
+    function open() {
+        if (isOpen()) return;
+        lastFocus = document.activeElement;
+        items = availableCommands();
+        searchQuery = '';
+        searchPages = [];
+        input.value = '';
+        palette.classList.add('active');
+        refresh();
+        input.focus();
+    }
+
+    function close() {
+        if (!isOpen()) return;
+        clearTimeout(searchTimer);
+        palette.classList.remove('active');
+        if (lastFocus && typeof lastFocus.focus === 'function' && document.contains(lastFocus)) {
+            lastFocus.focus();
+        }
+        lastFocus = null;
+    }
+
+    function toggle() {
+        if (isOpen()) {
+            close();
+        } else {
+            open();
+        }
+    }
+
+    // notify briefly shows the outcome of a command that has no dialog
+    function notify(message) {
+        if (!toast) return;
+        clearTimeout(toastTimer);
+        toast.textContent = message;
+        toast.hidden = false;
+        toastTimer = setTimeout(() => { toast.hidden = true; }, toastDuration);
+    }
+
+    // copyCalendarLink copies the address of the calendar feed, which carries a
+    // token for calendar apps, optionally limited to comma-separated tags. A
+    // reset revokes the links copied before.
+    async function copyCalendarLink(tags, reset) {
+        if (reset && !confirm(t('calendar.reset_confirm', 'Calendar apps subscribed with your earlier links will stop receiving updates. Continue?'))) return;
+        try {
+            const resp = await fetch('/api/calendar/link' + (tags ? '?tag=' + encodeURIComponent(tags) : ''), { method: reset ? 'POST' : 'GET' });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+            await navigator.clipboard.writeText(data.url);
+            notify(reset ? t('calendar.reset_done', 'Earlier calendar feed links revoked, new link copied') : t('calendar.copied', 'Calendar feed link copied'));
+        } catch (error) {
+            console.error('Error getting the calendar feed link:', error);
+            notify(t('calendar.failed', 'Failed to get the calendar feed link'));
+        }
+    }
+
+    input.addEventListener('input', () => {
+        refresh();
+        searchContent();
+    });
+
+    input.addEventListener('keydown', event => {
+        switch (event.key) {
+            case 'ArrowDown':
+                event.preventDefault();
+                select(selected + 1);
+                break;
+            case 'ArrowUp':
+                event.preventDefault();
+                select(selected - 1);
+                break;
+            case 'PageDown':
+                event.preventDefault();
+                select(Math.min(selected + 10, results.length - 1));
+                break;
+            case 'PageUp':
+                event.preventDefault();
+                select(Math.max(selected - 10, 0));
+                break;
+            case 'Enter':
+                event.preventDefault();
+                if (results[selected]) runItem(results[selected]);
+                break;
+            case 'Escape':
+                // Keep edit mode and other dialogs open behind the palette
+                event.preventDefault();
+                event.stopPropagation();
+                close();
+                break;
+            case 'Tab':
+                event.preventDefault();
+                break;
+        }
+    });
+
+    palette.addEventListener('mousedown', event => {
+        if (!container.contains(event.target)) close();
+    });
+
+    // ===== BUILT-IN COMMANDS =====
+
+    // available returns the element matching selector unless the user's role
+    // hides it
+    function available(selector) {
+        const element = document.querySelector(selector);
+        return element && element.style.display !== 'none' ? element : null;
+    }
+
+    function click(selector) {
+        const element = document.querySelector(selector);
+        if (element) element.click();
+    }
+
+    function isEditing() {
+        return !!document.querySelector('.content.editing');
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/\/+$/, '') || '/';
+    }
+
+    const navigation = () => t('palette.section_navigation', 'Navigation');
+    const pageSection = () => t('palette.section_page', 'Page');
+    const editorSection = () => t('palette.section_editor', 'Editor');
+    const accountSection = () => t('palette.section_account', 'Account');
+    const adminSection = () => t('palette.section_admin', 'Administration');
+    const settingsSection = () => t('palette.section_settings', 'Settings');
+
+    [
+        {
+            id: 'go-home',
+            title: () => t('palette.go_home', 'Go to home page'),
+            section: navigation,
+            icon: 'fa-home',
+            when: () => currentPath() !== '/',
+            run: () => { window.location.href = '/'; }
+        },
+        {
+            id: 'go-parent',
+            title: () => t('palette.go_parent', 'Go to parent page'),
+            section: navigation,
+            icon: 'fa-level-up',
+            when: () => currentPath().split('/').length > 2,
+            run: () => { window.location.href = currentPath().replace(/\/[^/]+$/, ''); }
+        },
+        {
+            id: 'search',
+            title: () => t('palette.search', 'Search page contents'),
+            section: navigation,
+            icon: 'fa-search',
+            shortcut: 'focusSearch',
+            when: () => !!document.querySelector('.search-box'),
+            run: () => document.querySelector('.search-box').focus()
+        },
+        {
+            id: 'sitemap',
+            title: () => t('palette.sitemap', 'Open sitemap'),
+            section: navigation,
+            icon: 'fa-sitemap',
+            run: () => { window.open('/sitemap/', '_blank'); }
+        },
+        {
+            id: 'ask-wiki',
+            title: () => t('ask.title', 'Ask the wiki'),
+            section: navigation,
+            icon: 'fa-comments-o',
+            when: () => !!available('.ask-wiki-button'),
+            run: () => click('.ask-wiki-button')
+        },
+        {
+            id: 'new-page',
+            title: () => t('palette.new_page', 'New page here'),
+            section: pageSection,
+            keywords: 'create add document',
+            icon: 'fa-file-text-o',
+            when: () => !!available('.new-document') && !!window.DocumentManager,
+            run: () => window.DocumentManager.showNewDocDialog()
+        },
+        {
+            id: 'edit-page',
+            title: () => t('palette.edit_page', 'Edit page'),
+            section: pageSection,
+            icon: 'fa-pencil',
+            shortcut: 'enterEditMode',
+            when: () => !!available('.edit-page') && !isEditing(),
+            run: () => click('.edit-page')
+        },
+        {
+            id: 'save-page',
+            title: () => t('palette.save_page', 'Save changes'),
+            section: pageSection,
+            icon: 'fa-floppy-o',
+            shortcut: 'saveDocument',
+            when: isEditing,
+            run: () => click('.save-changes')
+        },
+        {
+            id: 'cancel-edit',
+            title: () => t('palette.cancel_edit', 'Stop editing'),
+            section: pageSection,
+            icon: 'fa-times',
+            when: isEditing,
+            run: () => click('.cancel-edit')
+        },
+        {
+            id: 'history',
+            title: () => t('palette.history', 'Page history'),
+            section: pageSection,
+            keywords: 'versions revisions restore',
+            icon: 'fa-history',
+            when: () => !!available('.edit-page') && !!window.VersionHistory,
+            run: () => window.VersionHistory.showVersionHistoryDialog()
+        },
+        {
+            id: 'attachments',
+            title: () => t('toolbar.attachments', 'Attachments'),
+            section: pageSection,
+            keywords: 'upload files',
+            icon: 'fa-paperclip',
+            when: () => !!available('.edit-page') && !!document.querySelector('.upload-file'),
+            run: () => click('.upload-file')
+        },
+        {
+            id: 'copy-link',
+            title: () => t('palette.copy_link', 'Copy link to page'),
+            section: pageSection,
+            keywords: 'url clipboard',
+            icon: 'fa-link',
+            run: async () => {
+                const url = window.location.origin + window.location.pathname;
+                try {
+                    await navigator.clipboard.writeText(url);
+                    notify(t('palette.link_copied', 'Link copied'));
+                } catch (error) {
+                    notify(t('palette.copy_failed', 'Failed to copy the link'));
+                }
+            }
+        },
+        {
+            id: 'move-page',
+            title: () => t('palette.move_page', 'Move or rename page'),
+            section: pageSection,
+            icon: 'fa-arrows',
+            when: () => !!available('.move-document'),
+            run: () => click('.move-document')
+        },
+        {
+            id: 'copy-page',
+            title: () => t('palette.duplicate_page', 'Duplicate page'),
+            section: pageSection,
+            keywords: 'duplicate clone',
+            icon: 'fa-clone',
+            when: () => !!available('.copy-document'),
+            run: () => click('.copy-document')
+        },
+        {
+            id: 'delete-page',
+            title: () => t('palette.delete_page', 'Delete page'),
+            section: pageSection,
+            keywords: 'remove',
+            icon: 'fa-trash',
+            when: () => !!available('.edit-page') && !!document.querySelector('.delete-document'),
+            run: () => click('.delete-document')
+        },
+        {
+            id: 'share-page',
+            title: () => t('palette.share_page', 'Share page'),
+            section: pageSection,
+            icon: 'fa-share-alt',
+            when: () => !!available('.share-page'),
+            run: () => click('.share-page')
+        },
+        {
+            id: 'watch-page',
+            title: () => document.querySelector('.page-watch[aria-pressed="true"]')
+                ? t('palette.unwatch_page', 'Stop watching page')
+                : t('palette.watch_page', 'Watch page'),
+            section: pageSection,
+            keywords: 'notify follow subscribe',
+            icon: 'fa-bell-o',
+            when: () => !!available('.page-watch'),
+            run: () => click('.page-watch')
+        },
+        {
+            id: 'favorite-page',
+            title: () => document.querySelector('.page-favorite[aria-pressed="true"]')
+                ? t('palette.unfavorite_page', 'Remove page from favorites')
+                : t('palette.favorite_page', 'Add page to favorites'),
+            section: pageSection,
+            keywords: 'star favourite bookmark',
+            icon: 'fa-star-o',
+            when: () => !!available('.page-favorite'),
+            run: () => click('.page-favorite')
+        },
+        {
+            id: 'pin-page',
+            title: () => document.querySelector('.page-pin[aria-pressed="true"]')
+                ? t('palette.unpin_page', 'Unpin page')
+                : t('palette.pin_page', 'Pin page to the top of its directory'),
+            section: pageSection,
+            keywords: 'pin sticky top order',
+            icon: 'fa-thumb-tack',
+            when: () => !!available('.page-pin'),
+            run: () => click('.page-pin')
+        },
+        {
+            id: 'archive-page',
+            title: () => document.querySelector('.page-archive[aria-pressed="true"]')
+                ? t('palette.unarchive_page', 'Unarchive page')
+                : t('palette.archive_page', 'Archive page'),
+            section: pageSection,
+            keywords: 'archive retire obsolete read-only',
+            icon: 'fa-archive',
+            when: () => !!available('.page-archive'),
+            run: () => click('.page-archive')
+        },
+        {
+            id: 'show-archived',
+            title: () => document.querySelector('.archived-toggle[aria-pressed="true"]')
+                ? t('palette.hide_archived', 'Hide archived pages')
+                : t('palette.show_archived', 'Show archived pages'),
+            section: pageSection,
+            keywords: 'archive include navigation search',
+            icon: 'fa-archive',
+            when: () => !!available('.archived-toggle'),
+            run: () => click('.archived-toggle')
+        },
+        {
+            id: 'embed-page',
+            title: () => t('palette.embed_page', 'Embed page'),
+            section: pageSection,
+            icon: 'fa-code',
+            when: () => !!available('.embed-page'),
+            run: () => click('.embed-page')
+        },
+        {
+            id: 'page-visibility',
+            title: () => t('palette.visibility', 'Change page visibility'),
+            section: pageSection,
+            keywords: 'permissions private public',
+            icon: 'fa-eye',
+            when: () => !!available('.page-visibility'),
+            run: () => click('.page-visibility')
+        },
+        {
+            id: 'find-replace',
+            title: () => t('palette.find_replace', 'Find and replace'),
+            section: pageSection,
+            icon: 'fa-exchange',
+            when: () => !!available('.replace-button'),
+            run: () => click('.replace-button')
+        },
+        {
+            id: 'translations',
+            title: () => t('translations.dashboard_button', 'Translations'),
+            section: pageSection,
+            icon: 'fa-globe',
+            when: () => !!available('.translations-button'),
+            run: () => click('.translations-button')
+        },
+        {
+            id: 'present',
+            title: () => t('palette.present', 'Present as slides'),
+            section: pageSection,
+            keywords: 'slides',
+            icon: 'fa-television',
+            when: () => !!document.querySelector('.view-toolbar [data-href^="/present"]'),
+            run: () => click('.view-toolbar [data-href^="/present"]')
+        },
+        {
+            id: 'print',
+            title: () => t('palette.print', 'Print page'),
+            section: pageSection,
+            keywords: 'pdf',
+            icon: 'fa-print',
+            when: () => !!document.querySelector('.view-toolbar [data-open^="/print"]'),
+            run: () => click('.view-toolbar [data-open^="/print"]')
+        },
+        {
+            id: 'label',
+            title: () => t('palette.label', 'Print label card'),
+            section: pageSection,
+            keywords: 'asset qr sticker',
+            icon: 'fa-tag',
+            when: () => !!document.querySelector('.view-toolbar [data-open^="/label"]'),
+            run: () => click('.view-toolbar [data-open^="/label"]')
+        },
+        {
+            id: 'ebook',
+            title: () => t('palette.ebook', 'Download as e-book'),
+            section: pageSection,
+            keywords: 'epub',
+            icon: 'fa-book',
+            when: () => !!document.querySelector('.view-toolbar [data-open^="/ebook"]'),
+            run: () => click('.view-toolbar [data-open^="/ebook"]')
+        },
+        {
+            id: 'toggle-preview',
+            title: () => t('palette.toggle_preview', 'Toggle preview'),
+            section: editorSection,
+            icon: 'fa-columns',
+            shortcut: 'togglePreview',
+            when: () => isEditing() && !!window.EditorPreview,
+            run: () => window.EditorPreview.togglePreview()
+        },
+        {
+            id: 'toggle-word-wrap',
+            title: () => t('palette.toggle_word_wrap', 'Toggle word wrap'),
+            section: editorSection,
+            icon: 'fa-align-left',
+            shortcut: 'toggleWordWrap',
+            when: () => isEditing() && !!window.EditorCore,
+            run: () => window.EditorCore.toggleWordWrap()
+        },
+        {
+            id: 'toggle-line-numbers',
+            title: () => t('palette.toggle_line_numbers', 'Toggle line numbers'),
+            section: editorSection,
+            icon: 'fa-list-ol',
+            shortcut: 'toggleLineNumbers',
+            when: () => isEditing() && !!window.EditorCore,
+            run: () => window.EditorCore.toggleLineNumbers()
+        },
+        {
+            id: 'toggle-theme',
+            title: () => window.ThemeManager && window.ThemeManager.getCurrentTheme() === 'dark'
+                ? t('palette.light_theme', 'Switch to light theme')
+                : t('palette.dark_theme', 'Switch to dark theme'),
+            section: settingsSection,
+            keywords: 'dark light mode appearance',
+            icon: 'fa-adjust',
+            when: () => !!window.ThemeManager,
+            run: () => window.ThemeManager.toggleTheme()
+        },
+        {
+            id: 'toggle-contrast',
+            title: () => window.ThemeManager && window.ThemeManager.isHighContrast()
+                ? t('palette.normal_contrast', 'Turn off high contrast')
+                : t('palette.high_contrast', 'Turn on high contrast'),
+            section: settingsSection,
+            keywords: 'accessibility a11y colors',
+            icon: 'fa-adjust',
+            when: () => !!window.ThemeManager,
+            run: () => window.ThemeManager.toggleContrast()
+        },
+        {
+            id: 'calendar-feed',
+            title: () => t('palette.calendar_feed', 'Copy calendar feed link'),
+            section: accountSection,
+            keywords: 'ical ics subscribe events due dates',
+            icon: 'fa-calendar',
+            run: () => copyCalendarLink('')
+        },
+        {
+            id: 'calendar-feed-tags',
+            title: () => t('palette.calendar_feed_tags', 'Copy calendar feed link of the tags of this page'),
+            section: accountSection,
+            keywords: 'ical ics subscribe events due dates',
+            icon: 'fa-calendar',
+            when: () => !!document.querySelector('.page-tags[data-tags]'),
+            run: () => copyCalendarLink(document.querySelector('.page-tags[data-tags]').dataset.tags)
+        },
+        {
+            id: 'calendar-feed-reset',
+            title: () => t('palette.calendar_feed_reset', 'Reset calendar feed links'),
+            section: accountSection,
+            keywords: 'ical ics revoke token leaked',
+            icon: 'fa-calendar-times',
+            when: () => !!available('.logout-button'),
+            run: () => copyCalendarLink('', true)
+        },
+        {
+            id: 'notifications',
+            title: () => t('notifications.title', 'Notifications'),
+            section: accountSection,
+            icon: 'fa-bell',
+            when: () => !!available('.notifications-button'),
+            run: () => click('.notifications-button')
+        },
+        {
+            id: 'passkeys',
+            title: () => t('passkeys.title', 'Passkeys'),
+            section: accountSection,
+            icon: 'fa-key',
+            when: () => !!available('.passkeys-button'),
+            run: () => click('.passkeys-button')
+        },
+        {
+            id: 'login',
+            title: () => t('common.login', 'Login'),
+            section: accountSection,
+            keywords: 'sign in',
+            icon: 'fa-user',
+            when: () => !!available('.auth-button:not(.logout-button)'),
+            run: () => click('.auth-button:not(.logout-button)')
+        },
+        {
+            id: 'logout',
+            title: () => t('common.logout', 'Logout'),
+            section: accountSection,
+            keywords: 'sign out',
+            icon: 'fa-sign-out',
+            when: () => !!available('.logout-button'),
+            run: () => click('.logout-button')
+        }
+    ].forEach(register);
+
+    // Every tab of the settings dialog, for admins
+    document.querySelectorAll('.settings-tabs .tab-button[data-tab]').forEach(tab => {
+        const tabId = tab.dataset.tab;
+        register({
+            id: 'settings-' + tabId,
+            title: () => t('common.settings', 'Settings') + ': ' + tab.textContent.trim(),
+            section: adminSection,
+            keywords: 'admin configuration',
+            icon: 'fa-cog',
+            when: () => !!available('.settings-button') && !!window.SettingsManager,
+            run: () => window.SettingsManager.openSettingsTab(tabId)
+        });
+    });
+
+    window.CommandPalette = {
+        register,
+        unregister,
+        open,
+        close,
+        toggle,
+        notify
+    };
+})();
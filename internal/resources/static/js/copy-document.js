@@ -0,0 +1,77 @@
+// Copy document: duplicates the current page, or its whole directory, to a
+// new path
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.copy-document-dialog');
+    const button = document.querySelector('.copy-document');
+    if (!dialog || !button) return;
+
+    const form = document.getElementById('copyDocumentForm');
+    const sourceInput = document.getElementById('copySourcePath');
+    const targetInput = document.getElementById('copyTargetPath');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function openDialog() {
+        window.Auth.checkUserRole('editor').then(canEdit => {
+            if (!canEdit) {
+                window.Auth.showPermissionError('editor');
+                return;
+            }
+            const currentPath = decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+            form.reset();
+            showError('');
+            sourceInput.value = currentPath;
+            targetInput.value = currentPath + '-copy';
+            dialog.classList.add('active');
+            setTimeout(() => {
+                targetInput.focus();
+                targetInput.select();
+            }, 100);
+        });
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    async function copyDocument(e) {
+        e.preventDefault();
+        showError('');
+        try {
+            const resp = await fetch('/api/document/copy', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    sourcePath: sourceInput.value,
+                    targetPath: targetInput.value.trim(),
+                    subpages: document.getElementById('copySubpages').checked,
+                    attachments: document.getElementById('copyAttachments').checked
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('copy.failed', 'Failed to copy document'));
+                return;
+            }
+            window.location.href = '/' + data.newPath;
+        } catch (error) {
+            console.error('Error copying document:', error);
+            showError(t('move.error', 'An error occurred. Please try again.'));
+        }
+    }
+
+    button.addEventListener('click', openDialog);
+    form.addEventListener('submit', copyDocument);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+})();
@@ -0,0 +1,303 @@
+// Dashboard of the home page: the search and tag widgets, and the dialog
+// arranging the widgets for the reader or, for admins, for everybody
+(function() {
+    'use strict';
+
+    const board = document.querySelector('.dashboard');
+    if (!board) return;
+
+    // Widgets listing pages, which can limit the number of entries
+    const listTypes = ['recent', 'drafts', 'watched', 'favorites', 'tags'];
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    // ===== SEARCH AND TAGS =====
+
+    // search shows the results of query in the search panel of the sidebar
+    function search(query) {
+        query = query.trim();
+        if (!query || typeof WikiSearch === 'undefined') return;
+        const box = document.querySelector('.search-box');
+        if (box) box.value = query;
+        WikiSearch.performSearch(query);
+    }
+
+    board.querySelectorAll('.dashboard-search').forEach(form => {
+        form.addEventListener('submit', event => {
+            event.preventDefault();
+            search(form.elements.query.value);
+        });
+    });
+
+    board.querySelectorAll('.dashboard-tag').forEach(tag => {
+        tag.addEventListener('click', () => search(tag.dataset.tag));
+    });
+
+    // ===== ARRANGING =====
+
+    const dialog = document.querySelector('.dashboard-dialog');
+    const button = board.querySelector('.dashboard-customize');
+    if (!dialog || !button) return;
+
+    const list = dialog.querySelector('.dashboard-editor-list');
+    const scopeBox = dialog.querySelector('.dashboard-scope');
+    const scopeHelp = dialog.querySelector('.dashboard-scope-help');
+    const addType = document.getElementById('dashboardAddType');
+    const resetButton = dialog.querySelector('.dashboard-reset');
+
+    let state = null;   // Layouts and rights returned by the API
+    let scope = 'user'; // Layout being arranged: "user" or "global"
+    let widgets = [];   // Widgets of the layout being arranged
+    let dragged = -1;
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function typeName(type) {
+        return t('dashboard.widget_' + type, type);
+    }
+
+    function copy(layout) {
+        return (layout && layout.widgets ? layout.widgets : []).map(widget => Object.assign({}, widget));
+    }
+
+    function setScope(value) {
+        scope = value;
+        dialog.querySelectorAll('input[name="dashboardScope"]').forEach(input => {
+            input.checked = input.value === scope;
+        });
+        widgets = copy(scope === 'user' && state.user ? state.user : state.global);
+        scopeHelp.textContent = scope === 'user'
+            ? t('dashboard.scope_user_help', 'Only you see this arrangement. Other users keep the default dashboard.')
+            : t('dashboard.scope_global_help', 'Everybody without a dashboard of their own sees this arrangement.');
+        resetButton.hidden = scope !== 'user' || !state.user;
+        render();
+    }
+
+    function field(labelText, input) {
+        const label = document.createElement('label');
+        label.textContent = labelText;
+        label.appendChild(input);
+        return label;
+    }
+
+    function headerButton(icon, title, onClick, disabled) {
+        const element = document.createElement('button');
+        element.type = 'button';
+        element.title = title;
+        element.setAttribute('aria-label', title);
+        element.innerHTML = '<i class="fa ' + icon + '" aria-hidden="true"></i>';
+        element.disabled = !!disabled;
+        element.addEventListener('click', onClick);
+        return element;
+    }
+
+    function move(from, to) {
+        if (to < 0 || to >= widgets.length || from === to) return;
+        const [widget] = widgets.splice(from, 1);
+        widgets.splice(to, 0, widget);
+        render();
+        const item = list.children[to];
+        if (item) item.querySelector('.dashboard-editor-header').focus();
+    }
+
+    function widgetItem(widget, index) {
+        const item = document.createElement('li');
+        item.className = 'dashboard-editor-item';
+        item.draggable = true;
+
+        const header = document.createElement('div');
+        header.className = 'dashboard-editor-header';
+        header.tabIndex = 0;
+        const name = document.createElement('strong');
+        name.textContent = typeName(widget.type);
+        header.appendChild(name);
+        header.setAttribute('aria-label', name.textContent + ' (' + (index + 1) + '/' + widgets.length + ')');
+        header.appendChild(headerButton('fa-arrow-up', t('dashboard.move_up', 'Move up'), () => move(index, index - 1), index === 0));
+        header.appendChild(headerButton('fa-arrow-down', t('dashboard.move_down', 'Move down'), () => move(index, index + 1), index === widgets.length - 1));
+        header.appendChild(headerButton('fa-trash', t('dashboard.remove', 'Remove'), () => {
+            widgets.splice(index, 1);
+            render();
+        }));
+        // Alt+Up and Alt+Down move the focused widget
+        header.addEventListener('keydown', event => {
+            if (!event.altKey || (event.key !== 'ArrowUp' && event.key !== 'ArrowDown')) return;
+            event.preventDefault();
+            move(index, event.key === 'ArrowUp' ? index - 1 : index + 1);
+        });
+        item.appendChild(header);
+
+        const fields = document.createElement('div');
+        fields.className = 'dashboard-editor-fields';
+
+        const title = document.createElement('input');
+        title.type = 'text';
+        title.value = widget.title || '';
+        title.placeholder = widget.type === 'content' ? '' : typeName(widget.type);
+        title.addEventListener('input', () => { widget.title = title.value; });
+        fields.appendChild(field(t('dashboard.widget_title', 'Title'), title));
+
+        const width = document.createElement('select');
+        [['full', t('dashboard.width_full', 'Full width')], ['half', t('dashboard.width_half', 'Half width')]].forEach(([value, text]) => {
+            width.appendChild(new Option(text, value, false, (widget.width || 'full') === value));
+        });
+        width.addEventListener('change', () => { widget.width = width.value; });
+        fields.appendChild(field(t('dashboard.width', 'Width'), width));
+
+        if (listTypes.includes(widget.type)) {
+            const limit = document.createElement('input');
+            limit.type = 'number';
+            limit.min = 1;
+            limit.max = 50;
+            limit.value = widget.limit || '';
+            limit.placeholder = widget.type === 'tags' ? '50' : '10';
+            limit.addEventListener('input', () => { widget.limit = parseInt(limit.value, 10) || 0; });
+            fields.appendChild(field(t('dashboard.limit', 'Entries'), limit));
+        }
+
+        if (widget.type === 'pinned') {
+            const pages = document.createElement('textarea');
+            pages.value = (widget.pages || []).map(page => '/' + page).join('\n');
+            pages.placeholder = '/guide/intro';
+            pages.addEventListener('input', () => {
+                widget.pages = pages.value.split('\n').map(page => page.trim()).filter(Boolean);
+            });
+            const label = field(t('dashboard.pages', 'Pages, one path per line'), pages);
+            label.classList.add('dashboard-editor-pages');
+            fields.appendChild(label);
+        }
+        item.appendChild(fields);
+
+        item.addEventListener('dragstart', event => {
+            if (event.target !== item) return;
+            dragged = index;
+            item.classList.add('dragging');
+            event.dataTransfer.effectAllowed = 'move';
+        });
+        item.addEventListener('dragend', () => item.classList.remove('dragging'));
+        item.addEventListener('dragover', event => {
+            if (dragged !== -1) event.preventDefault();
+        });
+        item.addEventListener('drop', event => {
+            event.preventDefault();
+            const from = dragged;
+            dragged = -1;
+            move(from, index);
+        });
+        return item;
+    }
+
+    function render() {
+        list.innerHTML = '';
+        widgets.forEach((widget, index) => list.appendChild(widgetItem(widget, index)));
+
+        // The home page document can be shown once
+        const hasContent = widgets.some(widget => widget.type === 'content');
+        Array.from(addType.options).forEach(option => {
+            option.disabled = option.value === 'content' && hasContent;
+        });
+        if (addType.selectedOptions[0] && addType.selectedOptions[0].disabled) {
+            const first = Array.from(addType.options).find(option => !option.disabled);
+            if (first) addType.value = first.value;
+        }
+    }
+
+    async function openDialog() {
+        showError('');
+        try {
+            const resp = await fetch('/api/dashboard');
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('dashboard.load_failed', 'Failed to load the dashboard'));
+                return;
+            }
+            state = data;
+        } catch (error) {
+            console.error('Error loading the dashboard:', error);
+            return;
+        }
+
+        addType.innerHTML = '';
+        state.types.forEach(type => addType.appendChild(new Option(typeName(type), type)));
+
+        // Admins choose between their own dashboard and the one of everybody
+        scopeBox.hidden = !(state.canCustomize && state.canEditGlobal);
+        setScope(state.canCustomize && !(state.canEditGlobal && !state.user) ? 'user' : 'global');
+        dialog.classList.add('active');
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    async function save() {
+        showError('');
+        try {
+            const resp = await fetch('/api/dashboard', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ scope, widgets })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error || data.message || t('dashboard.save_failed', 'Failed to save the dashboard'));
+                return;
+            }
+            window.location.reload();
+        } catch (error) {
+            console.error('Error saving the dashboard:', error);
+            showError(t('dashboard.save_failed', 'Failed to save the dashboard'));
+        }
+    }
+
+    async function reset() {
+        showError('');
+        try {
+            const resp = await fetch('/api/dashboard', { method: 'DELETE' });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('dashboard.save_failed', 'Failed to save the dashboard'));
+                return;
+            }
+            window.location.reload();
+        } catch (error) {
+            console.error('Error resetting the dashboard:', error);
+            showError(t('dashboard.save_failed', 'Failed to save the dashboard'));
+        }
+    }
+
+    button.addEventListener('click', openDialog);
+    dialog.querySelector('.dashboard-add-button').addEventListener('click', () => {
+        if (!addType.value || addType.selectedOptions[0].disabled) return;
+        widgets.push({ type: addType.value, width: 'full' });
+        render();
+    });
+    scopeBox.addEventListener('change', event => {
+        if (event.target.name === 'dashboardScope') setScope(event.target.value);
+    });
+    dialog.querySelector('.dashboard-save').addEventListener('click', save);
+    resetButton.addEventListener('click', reset);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('keydown', event => {
+        if (event.key !== 'Escape') return;
+        event.stopPropagation();
+        closeDialog();
+    });
+
+    if (window.CommandPalette) {
+        window.CommandPalette.register({
+            id: 'customize-dashboard',
+            title: () => t('dashboard.customize', 'Customize dashboard'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'home widgets layout arrange',
+            icon: 'fa-th-large',
+            run: openDialog
+        });
+    }
+})();
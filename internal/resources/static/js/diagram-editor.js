@@ -0,0 +1,306 @@
+/**
+ * Diagram attachments
+ * Shows the .drawio and .excalidraw attachments of pages from their SVG
+ * previews and edits them in a dialog: draw.io from its embed server and
+ * Excalidraw from /diagram/excalidraw, which speaks the same JSON protocol.
+ * Saving stores the diagram and its preview as attachments of the page.
+ */
+
+(function() {
+    'use strict';
+
+    let settings = {};
+    try {
+        settings = JSON.parse((document.currentScript && document.currentScript.dataset.config) || '{}');
+    } catch (error) {
+        console.error('Invalid diagram settings:', error);
+    }
+
+    const roleMeta = document.querySelector('meta[name="user-role"]');
+    const role = roleMeta ? roleMeta.content : '';
+    const canEdit = !!settings.enabled && (role === 'admin' || role === 'editor');
+
+    const extensions = { drawio: '.drawio', excalidraw: '.excalidraw' };
+
+    let dialog = null;
+    let frame = null;
+    let current = null;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function notify(message) {
+        if (window.CommandPalette) {
+            window.CommandPalette.notify(message);
+        } else if (window.showMessageDialog) {
+            window.showMessageDialog(t('diagram.title', 'Diagram'), message);
+        }
+    }
+
+    // The path of the current page, empty for the homepage
+    function pagePath() {
+        const meta = document.querySelector('meta[name="doc-path"]');
+        return (meta ? meta.content : '').replace(/^\/+|\/+$/g, '');
+    }
+
+    function fileURL(name) {
+        const path = pagePath() || 'pages/home';
+        return '/api/files/' + path.split('/').map(encodeURIComponent).join('/') + '/' + encodeURIComponent(name);
+    }
+
+    function isDark() {
+        return document.documentElement.getAttribute('data-theme') === 'dark';
+    }
+
+    // The origin the editor of a format runs on, which messages are sent to
+    function editorOrigin(format) {
+        if (format !== 'drawio') return window.location.origin;
+        try {
+            return new URL(settings.drawioUrl).origin;
+        } catch (error) {
+            return window.location.origin;
+        }
+    }
+
+    function editorURL(format) {
+        if (format !== 'drawio') return settings.excalidrawUrl;
+        const params = new URLSearchParams({
+            embed: '1',
+            proto: 'json',
+            spin: '1',
+            saveAndExit: '1',
+            libraries: '1',
+            lang: document.documentElement.lang || 'en'
+        });
+        if (isDark()) params.set('ui', 'dark');
+        return settings.drawioUrl.replace(/\/+$/, '') + '/?' + params.toString();
+    }
+
+    // Adds the placeholder of missing previews and the edit button to a
+    // diagram shown in the page
+    function decorate(container) {
+        const image = container.querySelector('img');
+        const missing = () => {
+            if (container.classList.contains('diagram-missing')) return;
+            container.classList.add('diagram-missing');
+            const note = document.createElement('span');
+            note.className = 'diagram-placeholder';
+            note.textContent = t('diagram.no_preview', 'This diagram has no preview yet');
+            container.appendChild(note);
+        };
+        if (image) {
+            image.addEventListener('error', missing);
+            if (image.complete && image.naturalWidth === 0 && image.getAttribute('loading') !== 'lazy') missing();
+        }
+
+        if (!canEdit || container.querySelector('.diagram-edit')) return;
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'diagram-edit';
+        button.innerHTML = '<i class="fa fa-pencil" aria-hidden="true"></i> ';
+        button.appendChild(document.createTextNode(t('diagram.edit', 'Edit diagram')));
+        button.addEventListener('click', () => {
+            const url = container.dataset.diagram;
+            openEditor({
+                name: decodeURIComponent(url.split('/').pop()),
+                url: url,
+                format: container.dataset.format,
+                created: false
+            });
+        });
+        container.appendChild(button);
+    }
+
+    async function openEditor(diagram) {
+        if (current) return;
+        let source = '';
+        if (!diagram.created) {
+            try {
+                const resp = await fetch(diagram.url, { cache: 'no-store' });
+                if (!resp.ok) throw new Error(resp.statusText);
+                source = await resp.text();
+            } catch (error) {
+                console.error('Error loading diagram:', error);
+                notify(t('diagram.load_failed', 'Failed to load the diagram'));
+                return;
+            }
+        }
+
+        current = { diagram: diagram, source: source, origin: editorOrigin(diagram.format), saved: false, exit: false };
+
+        dialog = document.createElement('div');
+        dialog.className = 'diagram-editor-dialog';
+        dialog.setAttribute('role', 'dialog');
+        dialog.setAttribute('aria-label', t('diagram.title', 'Diagram') + ': ' + diagram.name);
+        frame = document.createElement('iframe');
+        frame.className = 'diagram-editor-frame';
+        frame.title = diagram.name;
+        frame.src = editorURL(diagram.format);
+        dialog.appendChild(frame);
+        document.body.appendChild(dialog);
+        document.body.classList.add('diagram-editing');
+    }
+
+    function closeEditor() {
+        if (!current) return;
+        const reload = current.saved && current.referenced;
+        dialog.remove();
+        dialog = frame = current = null;
+        document.body.classList.remove('diagram-editing');
+        if (reload) window.location.reload();
+    }
+
+    function post(message) {
+        if (!current) return;
+        frame.contentWindow.postMessage(JSON.stringify(message), current.origin);
+    }
+
+    // Decodes the data URI of the SVG exported by draw.io
+    function decodeSVG(data) {
+        const comma = data.indexOf(',');
+        const body = data.slice(comma + 1);
+        if (data.slice(0, comma).endsWith(';base64')) {
+            return new TextDecoder().decode(Uint8Array.from(atob(body), c => c.charCodeAt(0)));
+        }
+        return decodeURIComponent(body);
+    }
+
+    async function store(source, svg, exit) {
+        // The dialog may be closed while the diagram is being saved
+        const editing = current;
+        const diagram = editing.diagram;
+        try {
+            const resp = await fetch('/api/diagram/save', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    path: pagePath(),
+                    name: diagram.name,
+                    source: source,
+                    svg: svg,
+                    reference: diagram.created
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+
+            editing.saved = true;
+            editing.referenced = editing.referenced || data.referenced;
+            diagram.created = false;
+            document.querySelectorAll('.diagram-attachment').forEach(container => {
+                if (container.dataset.diagram !== diagram.url) return;
+                container.classList.remove('diagram-missing');
+                container.querySelectorAll('.diagram-placeholder').forEach(note => note.remove());
+                const image = container.querySelector('img');
+                if (image) image.src = data.preview + '?v=' + Date.now();
+            });
+            if (diagram.format === 'drawio' && current === editing) {
+                post({ action: 'status', message: t('diagram.saved', 'Diagram saved'), modified: false });
+            }
+            if (current !== editing) {
+                if (editing.referenced) window.location.reload();
+            } else if (exit) {
+                closeEditor();
+            } else {
+                notify(t('diagram.saved', 'Diagram saved'));
+            }
+        } catch (error) {
+            console.error('Error saving diagram:', error);
+            const message = t('diagram.save_failed', 'Failed to save the diagram') + ': ' + error.message;
+            if (diagram.format === 'drawio' && current === editing) {
+                post({ action: 'status', message: message, modified: true });
+            }
+            notify(message);
+        }
+    }
+
+    window.addEventListener('message', function(event) {
+        if (!current || !frame || event.source !== frame.contentWindow || event.origin !== current.origin) return;
+        let message;
+        try {
+            message = typeof event.data === 'string' ? JSON.parse(event.data) : event.data;
+        } catch (error) {
+            return;
+        }
+        if (!message) return;
+
+        switch (message.event) {
+        case 'init':
+            post({ action: 'load', xml: current.source, autosave: 0, title: current.diagram.name, dark: isDark() });
+            break;
+        case 'save':
+            if (current.diagram.format === 'drawio') {
+                // draw.io sends the diagram, its preview is requested separately
+                current.exit = !!message.exit;
+                current.pending = message.xml;
+                post({ action: 'export', format: 'svg', xml: message.xml, spinKey: 'saving' });
+            } else {
+                store(message.xml, message.svg, !!message.exit);
+            }
+            break;
+        case 'export':
+            store(message.xml || current.pending, decodeSVG(message.data), current.exit);
+            break;
+        case 'exit':
+            closeEditor();
+            break;
+        }
+    });
+
+    // newDiagram asks for the name of a diagram attached to the current page
+    // and opens it, a new one unless it exists already
+    async function newDiagram(format) {
+        let name = prompt(t('diagram.name_prompt', 'Name of the diagram'));
+        if (!name) return;
+        name = name.trim().replace(/\s+/g, '_');
+        if (!name.toLowerCase().endsWith(extensions[format])) name += extensions[format];
+
+        const url = fileURL(name);
+        let exists = false;
+        try {
+            exists = (await fetch(url, { cache: 'no-store' })).ok;
+        } catch (error) {
+            exists = false;
+        }
+        openEditor({ name: name, url: url, format: format, created: !exists });
+    }
+
+    function init() {
+        document.querySelectorAll('.diagram-attachment').forEach(decorate);
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', init);
+    } else {
+        init();
+    }
+
+    if (canEdit && window.CommandPalette) {
+        const onPage = () => !!document.querySelector('.markdown-content') && !document.querySelector('.content.editing');
+        window.CommandPalette.register({
+            id: 'new-drawio-diagram',
+            title: () => t('palette.new_drawio', 'New draw.io diagram'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'diagram drawio flowchart network attachment',
+            icon: 'fa-sitemap',
+            when: onPage,
+            run: () => newDiagram('drawio')
+        });
+        window.CommandPalette.register({
+            id: 'new-excalidraw-drawing',
+            title: () => t('palette.new_excalidraw', 'New Excalidraw drawing'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'diagram sketch whiteboard attachment',
+            icon: 'fa-pencil-square-o',
+            when: onPage,
+            run: () => newDiagram('excalidraw')
+        });
+    }
+
+    window.DiagramEditor = {
+        open: openEditor,
+        create: newDiagram
+    };
+})();
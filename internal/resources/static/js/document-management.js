@@ -88,6 +88,18 @@
             });
         }
 
+        // Name the page after an imported file unless it has a title
+        const importFileInput = document.getElementById('docImportFile');
+        if (importFileInput) {
+            importFileInput.addEventListener('change', function() {
+                const file = importFileInput.files[0];
+                if (file && !docTitleInput.value.trim()) {
+                    docTitleInput.value = file.name.replace(/\.[^.]+$/, '');
+                    docTitleInput.dispatchEvent(new Event('input'));
+                }
+            });
+        }
+
         // Close dialog when clicking close button or cancel
         if (closeNewDocDialog) {
             closeNewDocDialog.addEventListener('click', hideNewDocDialog);
@@ -131,18 +143,34 @@
                 // If path is empty, just use the slug (creates document at root level)
                 const fullPath = path ? `${path}/${slug}` : slug;
 
+                // A chosen file is converted into the new page
+                const importFile = document.getElementById('docImportFile');
+                const file = importFile && importFile.files[0];
+
                 try {
-                    const response = await fetch('/api/document/create', {
-                        method: 'POST',
-                        headers: {
-                            'Content-Type': 'application/json',
-                        },
-                        body: JSON.stringify({
-                            title: title,
-                            path: fullPath,
-                            type: type
-                        })
-                    });
+                    let response;
+                    if (file) {
+                        const formData = new FormData();
+                        formData.append('file', file);
+                        formData.append('title', title);
+                        formData.append('path', fullPath);
+                        response = await fetch('/api/pandoc/import', {
+                            method: 'POST',
+                            body: formData
+                        });
+                    } else {
+                        response = await fetch('/api/document/create', {
+                            method: 'POST',
+                            headers: {
+                                'Content-Type': 'application/json',
+                            },
+                            body: JSON.stringify({
+                                title: title,
+                                path: fullPath,
+                                type: type
+                            })
+                        });
+                    }
 
                     if (response.ok) {
                         const result = await response.json();
@@ -0,0 +1,147 @@
+/**
+ * E-book View
+ * Renders diagrams and math of the chapters, then sends them to the server to
+ * be bound into an EPUB file
+ */
+
+(function() {
+    'use strict';
+
+    const MATHML_NS = 'http://www.w3.org/1998/Math/MathML';
+
+    document.addEventListener('DOMContentLoaded', async function() {
+        const form = document.querySelector('.ebook-form');
+        const button = form.querySelector('.ebook-download');
+        const status = document.querySelector('.ebook-status');
+
+        // Render mermaid diagrams with the light theme so they read well on e-ink
+        if (typeof mermaid !== 'undefined' && document.querySelector('.mermaid')) {
+            try {
+                mermaid.initialize(window.MermaidConfig.options(false, {
+                    startOnLoad: false,
+                    htmlLabels: false,
+                    flowchart: { htmlLabels: false },
+                    fontFamily: 'serif'
+                }));
+                await window.MermaidConfig.ready();
+                await mermaid.run({ querySelector: '.mermaid' });
+            } catch (error) {
+                console.error('Failed to render Mermaid diagrams:', error);
+            }
+        }
+
+        if (window.MathJax && MathJax.startup && MathJax.startup.promise) {
+            try {
+                await MathJax.startup.promise;
+            } catch (error) {
+                console.error('MathJax failed:', error);
+            }
+        }
+
+        status.textContent = status.dataset.ready;
+        button.disabled = false;
+
+        form.addEventListener('submit', async function(e) {
+            e.preventDefault();
+            button.disabled = true;
+            status.textContent = status.dataset.building;
+
+            try {
+                const chapters = Array.from(document.querySelectorAll('.ebook-chapter')).map(section => ({
+                    path: section.dataset.path,
+                    xhtml: chapterXHTML(section)
+                }));
+
+                const response = await fetch('/api/ebook?path=' + encodeURIComponent(document.body.dataset.path), {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        title: form.elements.title.value,
+                        author: form.elements.author.value,
+                        chapters: chapters
+                    })
+                });
+                if (!response.ok) {
+                    const data = await response.json().catch(() => ({}));
+                    throw new Error(data.message || response.statusText);
+                }
+
+                const blob = await response.blob();
+                const link = document.createElement('a');
+                link.href = URL.createObjectURL(blob);
+                link.download = fileName(response) || 'book.epub';
+                document.body.appendChild(link);
+                link.click();
+                link.remove();
+                URL.revokeObjectURL(link.href);
+                status.textContent = status.dataset.ready;
+            } catch (error) {
+                console.error('Failed to create e-book:', error);
+                status.textContent = status.dataset.failed + ' ' + error.message;
+            } finally {
+                button.disabled = false;
+            }
+        });
+    });
+
+    /**
+     * Serializes a chapter as XHTML. Typeset math is replaced by MathML, which
+     * e-book readers display without the web fonts of the page.
+     */
+    function chapterXHTML(section) {
+        const clone = section.cloneNode(true);
+        const math = mathItems(section);
+
+        clone.querySelectorAll('mjx-container').forEach((container, i) => {
+            const item = math[i];
+            if (item && item.mathml) {
+                const parsed = new DOMParser().parseFromString(item.mathml, 'application/xml');
+                if (parsed.documentElement.namespaceURI === MATHML_NS) {
+                    container.replaceWith(document.importNode(parsed.documentElement, true));
+                    return;
+                }
+            }
+            const code = document.createElement('code');
+            code.textContent = item ? item.tex : container.textContent;
+            container.replaceWith(code);
+        });
+
+        // Controls of the page are of no use in a book
+        clone.querySelectorAll('.heading-anchor, .copy-button, button, form').forEach(el => el.remove());
+
+        return new XMLSerializer().serializeToString(clone);
+    }
+
+    /**
+     * Returns the MathML and source of the math typeset in an element, in
+     * document order
+     */
+    function mathItems(element) {
+        if (!window.MathJax || !MathJax.startup || !MathJax.startup.document) {
+            return [];
+        }
+        const items = Array.from(MathJax.startup.document.getMathItemsWithin(element));
+        return items.map(item => {
+            let mathml = '';
+            try {
+                if (MathJax.startup.toMML) {
+                    mathml = MathJax.startup.toMML(item.root);
+                }
+            } catch (error) {
+                console.error('Failed to convert math to MathML:', error);
+            }
+            return { mathml: mathml, tex: item.math, node: item.typesetRoot };
+        }).sort((a, b) => {
+            return a.node.compareDocumentPosition(b.node) & Node.DOCUMENT_POSITION_FOLLOWING ? -1 : 1;
+        });
+    }
+
+    /**
+     * Returns the file name sent by the server
+     */
+    function fileName(response) {
+        const disposition = response.headers.get('Content-Disposition') || '';
+        const match = disposition.match(/filename="([^"]+)"/);
+        return match ? match[1] : '';
+    }
+})();
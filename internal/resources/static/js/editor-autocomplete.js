@@ -0,0 +1,269 @@
+/**
+ * Editor Autocomplete Module
+ * Suggests pages after [[ or ](/, attachments of the page after !( and blocks
+ * after / at the start of a line
+ */
+
+let autocompleteMenu = null;
+let autocompleteState = null; // { kind, query, from, items, selected }
+let autocompleteTimer = null;
+let autocompleteRequest = 0;  // Responses to older requests are ignored
+let snippetsCache = null;
+
+// Delay before asking the server, so typing doesn't send a request per key
+const AUTOCOMPLETE_DELAY = 120;
+
+// Contexts that open the menu, matched against the text before the cursor
+const autocompleteTriggers = [
+    { kind: 'wikilink', regex: /\[\[([^[\]\n]*)$/ },
+    { kind: 'path', regex: /\]\((\/[^()\s]*)$/ },
+    { kind: 'image-path', regex: /!\[[^\]\n]*\]\(([^()\s/]*)$/ },
+    { kind: 'attachment', regex: /!\(([^()\s]*)$/ },
+    { kind: 'snippet', regex: /^\/([\w-]*)$/ }
+];
+
+// Path of the page being edited, empty for the home page
+function currentDocPath() {
+    const path = decodeURIComponent(window.location.pathname);
+    return path === '/' ? '' : path;
+}
+
+// findContext returns the trigger around the cursor, with the typed query and
+// where the text replaced by a suggestion starts
+function findContext(editor) {
+    if (editor.somethingSelected()) return null;
+    const cursor = editor.getCursor();
+    const before = editor.getLine(cursor.line).slice(0, cursor.ch);
+
+    for (const trigger of autocompleteTriggers) {
+        const match = before.match(trigger.regex);
+        if (!match) continue;
+        let query = match[1];
+        let fromCh = match.index;
+        if (trigger.kind === 'path' || trigger.kind === 'image-path') {
+            // Only the address is replaced
+            fromCh = cursor.ch - match[1].length;
+            query = query.replace(/^\//, '');
+        }
+        return { kind: trigger.kind, query, from: { line: cursor.line, ch: fromCh } };
+    }
+    return null;
+}
+
+async function fetchSuggestions(url, key) {
+    const response = await fetch(url);
+    if (!response.ok) return [];
+    const data = await response.json();
+    return data[key] || [];
+}
+
+// loadItems returns the menu entries for a context
+async function loadItems(context) {
+    const query = encodeURIComponent(context.query);
+    switch (context.kind) {
+        case 'wikilink':
+        case 'path': {
+            const pages = await fetchSuggestions(`/api/suggest/pages?q=${query}`, 'pages');
+            return pages.map(page => ({ label: page.title, detail: page.path, value: page }));
+        }
+        case 'image-path':
+        case 'attachment': {
+            const path = encodeURIComponent(currentDocPath());
+            const files = await fetchSuggestions(`/api/suggest/attachments?path=${path}&q=${query}`, 'attachments');
+            return files.map(file => ({
+                label: file.name,
+                detail: file.image ? 'image' : 'file',
+                icon: file.image ? 'fa-picture-o' : 'fa-paperclip',
+                value: file
+            }));
+        }
+        case 'snippet': {
+            if (!snippetsCache) {
+                snippetsCache = await fetchSuggestions('/api/suggest/snippets', 'snippets');
+            }
+            const typed = context.query.toLowerCase();
+            return snippetsCache
+                .filter(snippet => snippet.name.startsWith(typed))
+                .map(snippet => ({ label: '/' + snippet.name, detail: snippet.description, value: snippet }));
+        }
+    }
+    return [];
+}
+
+// accept replaces the typed trigger with the selected suggestion
+function accept(editor) {
+    const state = autocompleteState;
+    const item = state && state.items[state.selected];
+    closeMenu();
+    if (!item) return;
+
+    const cursor = editor.getCursor();
+    const after = editor.getLine(cursor.line).slice(cursor.ch);
+    const closing = after.startsWith(')') ? '' : ')';
+    let text;
+    let caret = null;
+
+    switch (state.kind) {
+        case 'wikilink':
+            text = `[${item.value.title}](${item.value.path})`;
+            break;
+        case 'path':
+            text = item.value.path + closing;
+            break;
+        case 'image-path':
+            text = item.value.name + closing;
+            break;
+        case 'attachment':
+            text = `${item.value.image ? '!' : ''}[${item.value.name}](${item.value.name})`;
+            break;
+        case 'snippet':
+            caret = item.value.text.indexOf('$0');
+            text = item.value.text.replace('$0', '');
+            break;
+    }
+
+    editor.replaceRange(text, state.from, cursor, '+autocomplete');
+    if (caret !== null && caret >= 0) {
+        editor.setCursor(editor.posFromIndex(editor.indexFromPos(state.from) + caret));
+    }
+    editor.focus();
+}
+
+function renderMenu(editor) {
+    const state = autocompleteState;
+    if (!autocompleteMenu) {
+        autocompleteMenu = document.createElement('ul');
+        autocompleteMenu.className = 'editor-autocomplete';
+        autocompleteMenu.setAttribute('role', 'listbox');
+        // Keep the focus in the editor when an entry is clicked
+        autocompleteMenu.addEventListener('mousedown', (e) => {
+            e.preventDefault();
+            const entry = e.target.closest('li');
+            if (!entry) return;
+            autocompleteState.selected = parseInt(entry.dataset.index, 10);
+            accept(editor);
+        });
+        document.body.appendChild(autocompleteMenu);
+    }
+
+    autocompleteMenu.textContent = '';
+    state.items.forEach((item, index) => {
+        const entry = document.createElement('li');
+        entry.dataset.index = index;
+        entry.setAttribute('role', 'option');
+        entry.classList.toggle('selected', index === state.selected);
+
+        if (item.icon) {
+            const icon = document.createElement('i');
+            icon.className = `fa ${item.icon}`;
+            entry.appendChild(icon);
+        }
+        const label = document.createElement('span');
+        label.className = 'autocomplete-label';
+        label.textContent = item.label;
+        entry.appendChild(label);
+        if (item.detail) {
+            const detail = document.createElement('span');
+            detail.className = 'autocomplete-detail';
+            detail.textContent = item.detail;
+            entry.appendChild(detail);
+        }
+        autocompleteMenu.appendChild(entry);
+    });
+
+    const coords = editor.cursorCoords(state.from, 'window');
+    autocompleteMenu.style.left = `${Math.min(coords.left, window.innerWidth - 320)}px`;
+    autocompleteMenu.style.top = `${coords.bottom + 4}px`;
+    autocompleteMenu.style.display = 'block';
+
+    const selected = autocompleteMenu.querySelector('.selected');
+    if (selected) selected.scrollIntoView({ block: 'nearest' });
+}
+
+function closeMenu() {
+    clearTimeout(autocompleteTimer);
+    autocompleteTimer = null;
+    autocompleteRequest++;
+    autocompleteState = null;
+    if (autocompleteMenu) {
+        autocompleteMenu.style.display = 'none';
+    }
+}
+
+// update opens, refreshes or closes the menu for the text before the cursor
+function update(editor) {
+    const context = findContext(editor);
+    if (!context) {
+        closeMenu();
+        return;
+    }
+    if (autocompleteState && autocompleteState.kind === context.kind && autocompleteState.query === context.query) {
+        return;
+    }
+
+    clearTimeout(autocompleteTimer);
+    const request = ++autocompleteRequest;
+    autocompleteTimer = setTimeout(async () => {
+        autocompleteTimer = null;
+        try {
+            const items = await loadItems(context);
+            if (request !== autocompleteRequest) return;
+            if (items.length === 0) {
+                closeMenu();
+                return;
+            }
+            autocompleteState = Object.assign(context, { items, selected: 0 });
+            renderMenu(editor);
+        } catch (error) {
+            console.error('Autocomplete error:', error);
+        }
+    }, AUTOCOMPLETE_DELAY);
+}
+
+function moveSelection(editor, step) {
+    const state = autocompleteState;
+    state.selected = (state.selected + step + state.items.length) % state.items.length;
+    renderMenu(editor);
+}
+
+// setup listens to typing in a new editor
+function setup(editor) {
+    if (!editor || editor._autocompleteSetup) return;
+
+    editor.on('inputRead', () => update(editor));
+    editor.on('cursorActivity', () => {
+        if (autocompleteState || autocompleteTimer) update(editor);
+    });
+    editor.on('blur', closeMenu);
+
+    // Keys handled here are not seen by CodeMirror or the page shortcuts
+    editor.on('keydown', (cm, e) => {
+        if (!autocompleteState) return;
+        switch (e.key) {
+            case 'ArrowDown': moveSelection(cm, 1); break;
+            case 'ArrowUp': moveSelection(cm, -1); break;
+            case 'Enter':
+            case 'Tab': accept(cm); break;
+            case 'Escape': closeMenu(); break;
+            default: return;
+        }
+        e.preventDefault();
+        e.stopPropagation();
+    });
+
+    editor._autocompleteSetup = true;
+}
+
+function cleanup() {
+    closeMenu();
+    if (autocompleteMenu) {
+        autocompleteMenu.remove();
+        autocompleteMenu = null;
+    }
+}
+
+// Export the module
+window.EditorAutocomplete = {
+    setup,
+    cleanup
+};
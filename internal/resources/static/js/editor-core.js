@@ -6,6 +6,9 @@
 // Global editor variables
 let editor = null;
 let originalContent = '';
+let machineTranslated = false; // The translation being edited was pre-filled by machine translation
+let editSection = null; // Id and hash of the section being edited, null when editing the whole page
+let originalRevision = ''; // Revision of the page when it was opened, to notice changes saved meanwhile
 
 // Define custom CodeMirror modes
 if (typeof CodeMirror !== 'undefined') {
@@ -155,19 +158,109 @@ function wrapText(cm, prefix = '', suffix = '', placeholder = '') {
     cm.focus();
 }
 
-// Main editor loading function
-async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar) {
+// Language of the translation being edited, empty for the page itself
+function getEditLanguage() {
+    const meta = document.querySelector('meta[name="edit-language"]');
+    return meta ? meta.content : '';
+}
+
+// Query string selecting the translation being edited
+function editLanguageQuery() {
+    const lang = getEditLanguage();
+    return lang ? `?lang=${encodeURIComponent(lang)}` : '';
+}
+
+// Query string saving the translation being edited, marking machine translations
+function saveLanguageQuery() {
+    const query = editLanguageQuery();
+    return query && machineTranslated ? `${query}&machine=1` : query;
+}
+
+// Whether translations can be pre-filled by machine translation
+function canMachineTranslate() {
+    return getEditLanguage() !== '' && document.querySelector('meta[name="machine-translation"]') !== null;
+}
+
+// Replace the translation being edited with a machine translation of the original page
+function machineTranslate(cm) {
+    const t = (key, fallback) => window.i18n ? window.i18n.t(key) : fallback;
+    window.DialogSystem.showConfirmDialog(
+        t('translations.machine_title', 'Machine Translation'),
+        t('translations.machine_confirm', 'Replace the content of the editor with a machine translation of the original page?'),
+        async confirmed => {
+            if (!confirmed) return;
+            try {
+                const resp = await fetch('/api/translations/machine', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ path: window.location.pathname, language: getEditLanguage() })
+                });
+                const result = await resp.json();
+                if (!resp.ok || !result.success) {
+                    window.DialogSystem.showMessageDialog(
+                        t('translations.machine_title', 'Machine Translation'),
+                        result.error ? `${result.message}: ${result.error}` : result.message
+                    );
+                    return;
+                }
+                // Replaced as one change, so it can be undone
+                cm.setValue(result.content);
+                machineTranslated = true;
+                cm.focus();
+            } catch (error) {
+                console.error('Machine translation error:', error);
+                window.DialogSystem.showMessageDialog(
+                    t('translations.machine_title', 'Machine Translation'),
+                    t('translations.machine_failed', 'Machine translation failed')
+                );
+            }
+        }
+    );
+}
+
+// Direction of the page being edited, so right-to-left pages are written right to left
+function getEditDirection() {
+    const content = document.querySelector('.markdown-content');
+    return content && content.getAttribute('dir') === 'rtl' ? 'rtl' : 'ltr';
+}
+
+// Path of the section API for the current page
+function sectionApiPath() {
+    return window.location.pathname === '/' ? '/api/section/' : `/api/section${window.location.pathname}`;
+}
+
+// Fetch the markdown of the section under a heading, remembering its hash so
+// changes made by others meanwhile are noticed when saving
+async function fetchSection(sectionId) {
+    const response = await fetch(`${sectionApiPath()}?id=${encodeURIComponent(sectionId)}`);
+    if (!response.ok) throw new Error('Failed to fetch section');
+    const data = await response.json();
+    editSection = { id: sectionId, hash: data.hash, heading: data.section.heading };
+    return data.content;
+}
+
+// Main editor loading function. With a section id, only the section under
+// that heading is edited.
+async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar, sectionId) {
     try {
-        const isHomepage = window.location.pathname === '/';
-        const apiPath = isHomepage ? '/api/source/' : `/api/source${window.location.pathname}`;
+        let markdown;
+        if (sectionId) {
+            markdown = await fetchSection(sectionId);
+        } else {
+            const isHomepage = window.location.pathname === '/';
+            const apiPath = (isHomepage ? '/api/source/' : `/api/source${window.location.pathname}`) + editLanguageQuery();
 
-        const response = await fetch(apiPath);
-        if (!response.ok) throw new Error('Failed to fetch content');
+            const response = await fetch(apiPath);
+            if (!response.ok) throw new Error('Failed to fetch content');
 
-        const markdown = await response.text();
+            markdown = await response.text();
+            originalRevision = response.headers.get('X-Revision') || '';
+            editSection = null;
+        }
 
         // Store original content for change detection
         originalContent = markdown;
+        machineTranslated = false;
 
         // Show editor and switch toolbars
         mainContent.classList.add('editing');
@@ -204,9 +297,18 @@ async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar
         // 5. Create preview element inside the editor area
         const previewElement = window.EditorPreview.createPreview(editorArea);
 
+        // Rich text editing, shown instead of CodeMirror when chosen by the user
+        window.EditorRich.createRichEditor(editorArea);
+
         // 6. Create statusbar at the bottom
         const statusbar = createStatusbar(editorLayout);
 
+        // 7. Summary of the edit and the minor edit flag, saved with it
+        createSaveBar(editorLayout);
+
+        // Compact toolbar and swiping to the preview on touch devices
+        window.EditorMobile.setupEditor(toolbar, editorArea);
+
         // Reset the preview mode
         previewElement.classList.remove('editor-preview-active');
 
@@ -220,6 +322,8 @@ async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar
                 theme: 'default', // Always use default theme and customize it
                 lineNumbers: false, // Line numbers off by default
                 lineWrapping: true,
+                direction: getEditDirection(),
+                screenReaderLabel: window.i18n ? window.i18n.t('a11y.editor') : 'Markdown editor',
                 autofocus: true,
                 tabSize: 2,
                 indentWithTabs: false,
@@ -250,12 +354,15 @@ async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar
                 window.EditorThemes.updateCodeMirrorTheme(isDarkMode ? 'dark' : 'light');
             }
 
-            // Set editor height
-            editor.setSize(null, "calc(100vh - 380px)");
+            // Set editor height, following the on-screen keyboard on touch devices
+            editor.setSize(null, window.EditorMobile.editorHeight());
 
             // Set up events for toolbar interactions
             window.EditorToolbar.setupToolbarActions(toolbar);
 
+            // Suggest pages, attachments and blocks while typing
+            window.EditorAutocomplete.setup(editor);
+
             // Set up events for statusbar updates
             editor.on('cursorActivity', () => updateStatusbar(statusbar));
             editor.on('change', () => {
@@ -265,6 +372,10 @@ async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar
                 if (previewElement.classList.contains('editor-preview-active')) {
                     window.EditorPreview.updatePreview(editor.getValue());
                 }
+                window.EditorPreview.scheduleSplitRender();
+
+                // Follow changes made to the source while editing rich text
+                window.EditorRich.onEditorChange(editor);
 
                 // Set up beforeunload handler when changes occur
                 setupBeforeUnloadHandler();
@@ -297,6 +408,15 @@ async function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar
         // Force a refresh with multiple attempts to ensure editor renders properly
         refreshEditor(statusbar);
 
+        // Open in the editing mode the user chose last
+        window.EditorRich.applyPreference();
+
+        // Style warnings, when the wiki checks pages
+        window.EditorLint.setup(editor, statusbar);
+
+        // Spelling mistakes, when a dictionary is installed
+        window.EditorSpell.setup(editor);
+
     } catch (error) {
         console.error('Error:', error);
         alert('Failed to load content for editing');
@@ -325,10 +445,57 @@ function createStatusbar(container) {
     statusbar.appendChild(wordsStatus);
     statusbar.appendChild(cursorStatus);
 
+    // Name of the section being edited
+    if (editSection) {
+        const sectionStatus = document.createElement('span');
+        sectionStatus.className = 'section';
+        const label = window.i18n ? window.i18n.t('editor.editing_section') : 'Section';
+        sectionStatus.textContent = `${label}: ${editSection.heading}`;
+        statusbar.appendChild(sectionStatus);
+    }
+
     container.appendChild(statusbar);
     return statusbar;
 }
 
+// Summary of the edit, kept in the history of the page, and whether it's a
+// minor edit that owners and watchers aren't told about
+function createSaveBar(container) {
+    const saveBar = document.createElement('div');
+    saveBar.className = 'editor-savebar';
+
+    const summary = document.createElement('input');
+    summary.type = 'text';
+    summary.className = 'edit-summary';
+    summary.maxLength = 500;
+    summary.dir = 'auto';
+    summary.placeholder = window.i18n ? window.i18n.t('editor.summary_placeholder') : 'Summary of your changes (optional)';
+    summary.setAttribute('aria-label', window.i18n ? window.i18n.t('editor.summary') : 'Edit summary');
+
+    const minorLabel = document.createElement('label');
+    minorLabel.className = 'minor-edit';
+    const minor = document.createElement('input');
+    minor.type = 'checkbox';
+    minorLabel.appendChild(minor);
+    minorLabel.appendChild(document.createTextNode(' ' + (window.i18n ? window.i18n.t('editor.minor_edit') : 'Minor edit')));
+    minorLabel.title = window.i18n ? window.i18n.t('editor.minor_edit_help') : 'A small change, like a typo fix; owners and watchers are not notified';
+
+    saveBar.appendChild(summary);
+    saveBar.appendChild(minorLabel);
+    container.appendChild(saveBar);
+    return saveBar;
+}
+
+// Summary and minor edit flag to save the edit with
+function getSaveOptions() {
+    const saveBar = document.querySelector('.editor-savebar');
+    if (!saveBar) return { summary: '', minor: false };
+    return {
+        summary: saveBar.querySelector('.edit-summary').value.trim(),
+        minor: saveBar.querySelector('.minor-edit input').checked
+    };
+}
+
 // Function to update statusbar
 function updateStatusbar(statusbar) {
     if (!editor || !statusbar) return;
@@ -377,6 +544,8 @@ function exitEditMode(mainContent, editorContainer, viewToolbar, editToolbar) {
 
     // Reset original content
     originalContent = '';
+    originalRevision = '';
+    editSection = null;
 
     // Completely destroy the editor instance
     if (editor) {
@@ -388,8 +557,12 @@ function exitEditMode(mainContent, editorContainer, viewToolbar, editToolbar) {
         editor = null;
     }
 
-    // Remove preview element
+    // Remove preview and rich text elements
     window.EditorPreview.cleanup();
+    window.EditorRich.cleanup();
+    window.EditorAutocomplete.cleanup();
+    window.EditorLint.cleanup();
+    window.EditorSpell.cleanup();
 
     // Remove pickers
     window.EditorPickers.cleanup();
@@ -397,6 +570,8 @@ function exitEditMode(mainContent, editorContainer, viewToolbar, editToolbar) {
 
 // Content management functions
 function getEditorContent() {
+    // Copy rich text edits that are still waiting for the sync delay
+    window.EditorRich.flush();
     return editor ? editor.getValue() : '';
 }
 
@@ -406,19 +581,16 @@ function insertIntoEditor(url, isImage, name) {
         return false;
     }
 
-    // Check if it's an MP4 file
-    const isVideo = name.toLowerCase().endsWith('.mp4');
+    // Audio and video files are shown in players when written as images
+    const isMedia = /\.(mp4|webm|mp3|m4a|ogg|wav)$/i.test(name);
 
     // Extract just the filename from the URL
     const filename = name;
 
     // Create markdown code based on file type
     let markdown = '';
-    if (isVideo) {
-        // For MP4 files, use code block syntax with just the filename
-        markdown = "```mp4\n" + filename + "\n```\n\n";
-    } else if (isImage) {
-        // For images, use image markdown with just the filename
+    if (isImage || isMedia) {
+        // For images and media, use image markdown with just the filename
         markdown = `![${name}](${filename})\n\n`;
     } else {
         // For other files, use link markdown with just the filename
@@ -456,6 +628,7 @@ function isEditorActive() {
 // Function to check if document has unsaved changes
 function hasUnsavedChanges() {
     if (!editor) return false;
+    window.EditorRich.flush();
 
     // Compare current content with original content
     const currentContent = editor.getValue();
@@ -650,6 +823,7 @@ window.EditorCore = {
     insertRawContent,
     isEditorActive,
     hasUnsavedChanges,
+    getSaveOptions,
 
         // Utility functions
     wrapText,
@@ -660,5 +834,8 @@ window.EditorCore = {
     // Getters
     getEditor: () => editor,
     getOriginalContent: () => originalContent,
+    getEditSection: () => editSection,
+    getRevision: () => originalRevision,
+    setRevision: (revision) => { originalRevision = revision; },
     setOriginalContent: (content) => { originalContent = content; }
 };
\ No newline at end of file
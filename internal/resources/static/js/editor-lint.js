@@ -0,0 +1,149 @@
+/**
+ * Editor Lint Module
+ * Checks the document for style problems and its frontmatter against the
+ * schema of the page while editing, marks the lines and lists the warnings
+ * above the status bar
+ */
+
+let lintPanel = null;
+let lintStatus = null;
+let lintTimer = null;
+let lintRequest = 0;     // Responses to older requests are ignored
+let lintEnabled = true;  // Turned off when the wiki doesn't lint pages
+let markedLines = [];
+
+// Delay before checking the document after an edit
+const LINT_DELAY = 1000;
+
+// Path of the page being edited, empty for the home page
+function lintDocPath() {
+    const path = decodeURIComponent(window.location.pathname);
+    return path === '/' ? '' : path;
+}
+
+// Mark the lines with problems in the editor
+function markLines(editor, issues) {
+    markedLines.forEach(handle => editor.removeLineClass(handle, 'wrap', 'cm-lint-warning'));
+    markedLines = [];
+    issues.forEach(issue => {
+        const handle = editor.getLineHandle(issue.line - 1);
+        if (handle) {
+            markedLines.push(editor.addLineClass(handle, 'wrap', 'cm-lint-warning'));
+        }
+    });
+}
+
+// showIssues lists problems found by the server, either while editing or when
+// a save was refused, in which case the list is opened
+function showIssues(issues, open = false) {
+    const editor = window.EditorCore.getEditor();
+    if (!editor || !lintPanel) return;
+
+    markLines(editor, issues);
+
+    lintStatus.hidden = issues.length === 0;
+    lintStatus.textContent = issues.length;
+    lintStatus.title = window.i18n ? window.i18n.t('editor.lint_warnings') : 'Style warnings';
+
+    const list = lintPanel.querySelector('ul');
+    list.textContent = '';
+    issues.forEach(issue => {
+        const entry = document.createElement('li');
+        const line = document.createElement('button');
+        line.type = 'button';
+        line.className = 'lint-line';
+        line.textContent = issue.line;
+        line.addEventListener('click', () => {
+            // Leave rich text mode to show the line
+            if (window.EditorRich.isActive()) window.EditorRich.toggle();
+            editor.setCursor({ line: issue.line - 1, ch: 0 });
+            editor.scrollIntoView({ line: issue.line - 1, ch: 0 }, 100);
+            editor.focus();
+        });
+        const message = document.createElement('span');
+        message.textContent = issue.message;
+        const rule = document.createElement('span');
+        rule.className = 'lint-rule';
+        rule.textContent = issue.rule;
+        entry.append(line, message, rule);
+        list.appendChild(entry);
+    });
+    lintPanel.hidden = issues.length === 0 || (lintPanel.hidden && !open);
+}
+
+// check asks the server for the problems of the current content
+async function check() {
+    const editor = window.EditorCore.getEditor();
+    if (!editor || !lintEnabled) return;
+
+    const request = ++lintRequest;
+    try {
+        const response = await fetch(`/api/lint?path=${encodeURIComponent(lintDocPath())}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'text/plain' },
+            body: editor.getValue()
+        });
+        if (!response.ok) return;
+        const data = await response.json();
+        if (request !== lintRequest) return;
+        if (data.mode === 'off' && !data.schema) {
+            lintEnabled = false;
+            return;
+        }
+        showIssues(data.issues || []);
+    } catch (error) {
+        console.error('Lint error:', error);
+    }
+}
+
+function scheduleCheck() {
+    if (!lintEnabled) return;
+    clearTimeout(lintTimer);
+    lintTimer = setTimeout(check, LINT_DELAY);
+}
+
+// setup adds the warning list and counter to a new editor and checks the page
+function setup(editor, statusbar) {
+    if (!editor || !statusbar) return;
+    lintEnabled = true;
+
+    lintPanel = document.createElement('div');
+    lintPanel.className = 'editor-lint';
+    lintPanel.hidden = true;
+    lintPanel.appendChild(document.createElement('ul'));
+    statusbar.before(lintPanel);
+
+    // The counter shows and hides the list
+    lintStatus = document.createElement('button');
+    lintStatus.type = 'button';
+    lintStatus.className = 'lint';
+    lintStatus.hidden = true;
+    lintStatus.addEventListener('click', () => {
+        lintPanel.hidden = !lintPanel.hidden;
+    });
+    statusbar.prepend(lintStatus);
+
+    if (!editor._lintSetup) {
+        editor.on('change', scheduleCheck);
+        editor._lintSetup = true;
+    }
+    check();
+}
+
+function cleanup() {
+    clearTimeout(lintTimer);
+    lintRequest++;
+    markedLines = [];
+    if (lintPanel) {
+        lintPanel.remove();
+        lintPanel = null;
+    }
+    lintStatus = null;
+}
+
+// Export the module
+window.EditorLint = {
+    setup,
+    showIssues,
+    cleanup
+};
@@ -0,0 +1,193 @@
+/**
+ * Editor Merge Module
+ * When someone else saved the page while it was being edited, their changes
+ * are merged with the changes of the editor instead of being overwritten.
+ * Changes to different lines are merged and saved at once; changes to the same
+ * lines are shown side by side, with the original, to choose from.
+ */
+
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.merge-dialog');
+    let pending = null; // Chunks of the merge and the version saved meanwhile
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function joinLines(lines) {
+        return lines.length ? lines.join('\n') + '\n' : '';
+    }
+
+    // Put the merged page in the editor and save it again, now based on the
+    // version saved meanwhile
+    function saveMerged(text, conflict) {
+        const editor = window.EditorCore.getEditor();
+        if (!editor) return;
+        editor.setValue(text);
+        window.EditorCore.setOriginalContent(conflict.current);
+        window.EditorCore.setRevision(conflict.revision);
+        document.querySelector('.save-changes').click();
+    }
+
+    function column(title, lines) {
+        const box = document.createElement('div');
+        box.className = 'merge-side';
+        const heading = document.createElement('h4');
+        heading.textContent = title;
+        const pre = document.createElement('pre');
+        pre.textContent = lines.join('\n');
+        box.append(heading, pre);
+        return box;
+    }
+
+    function renderConflict(chunk, index, editorName) {
+        const base = chunk.base || [];
+        const theirs = chunk.theirs || [];
+        const mine = chunk.mine || [];
+
+        const box = document.createElement('div');
+        box.className = 'merge-conflict';
+
+        const heading = document.createElement('h3');
+        heading.textContent = t('merge.conflict', 'Conflict {n}, line {line}')
+            .replace('{n}', index + 1)
+            .replace('{line}', chunk.start);
+        box.appendChild(heading);
+
+        const sides = document.createElement('div');
+        sides.className = 'merge-sides';
+        const theirTitle = editorName
+            ? t('merge.theirs_by', 'Saved by {user}').replace('{user}', editorName)
+            : t('merge.theirs', 'Saved meanwhile');
+        sides.append(
+            column(t('merge.base', 'Original'), base),
+            column(theirTitle, theirs),
+            column(t('merge.mine', 'Yours'), mine)
+        );
+        box.appendChild(sides);
+
+        const result = document.createElement('textarea');
+        result.className = 'merge-result';
+        result.rows = Math.min(Math.max(theirs.length, mine.length, 2) + 1, 15);
+        result.value = mine.join('\n');
+        result.setAttribute('aria-label', t('merge.result', 'Result'));
+
+        const choices = document.createElement('div');
+        choices.className = 'merge-choices';
+        const options = [
+            ['theirs', t('merge.keep_theirs', 'Keep theirs'), theirs],
+            ['mine', t('merge.keep_mine', 'Keep yours'), mine],
+            ['both', t('merge.keep_both', 'Keep both'), theirs.concat(mine)]
+        ];
+        options.forEach(([value, label, lines]) => {
+            const option = document.createElement('label');
+            const radio = document.createElement('input');
+            radio.type = 'radio';
+            radio.name = `merge-choice-${index}`;
+            radio.value = value;
+            radio.checked = value === 'mine';
+            radio.addEventListener('change', () => {
+                result.value = lines.join('\n');
+            });
+            option.append(radio, ' ' + label);
+            choices.appendChild(option);
+        });
+        // Editing the result by hand leaves the choices
+        result.addEventListener('input', () => {
+            choices.querySelectorAll('input').forEach(radio => { radio.checked = false; });
+        });
+
+        box.append(choices, result);
+        return box;
+    }
+
+    function showConflicts(data, conflict) {
+        pending = { chunks: data.chunks, conflict: conflict };
+
+        dialog.querySelector('.merge-help').textContent = t(
+            'merge.help',
+            'Someone else saved this page while you were editing it. Their changes to other lines were merged with yours; choose what to keep where you both changed the same lines.'
+        );
+        const list = dialog.querySelector('.merge-conflicts');
+        list.innerHTML = '';
+        let index = 0;
+        data.chunks.forEach(chunk => {
+            if (chunk.conflict) list.appendChild(renderConflict(chunk, index++, conflict.editor));
+        });
+
+        dialog.querySelector('.error-message').style.display = 'none';
+        dialog.classList.add('active');
+        const first = list.querySelector('input');
+        if (first) first.focus();
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+        pending = null;
+        const editor = window.EditorCore.getEditor();
+        if (editor) editor.focus();
+    }
+
+    // Save the page with the resolved conflicts
+    function saveResolved() {
+        if (!pending) return;
+        const results = Array.from(dialog.querySelectorAll('.merge-result'));
+        let lines = [];
+        let index = 0;
+        pending.chunks.forEach(chunk => {
+            if (!chunk.conflict) {
+                lines = lines.concat(chunk.lines || []);
+                return;
+            }
+            // An empty result removes the lines
+            const value = results[index++].value.replace(/\r\n/g, '\n').replace(/\n$/, '');
+            if (value !== '') lines = lines.concat(value.split('\n'));
+        });
+        const conflict = pending.conflict;
+        dialog.classList.remove('active');
+        pending = null;
+        saveMerged(joinLines(lines), conflict);
+    }
+
+    // Merge the page as it was opened, the version saved meanwhile and the
+    // edited page, saving the result or showing the conflicts
+    async function resolve(base, conflict, mine) {
+        try {
+            const response = await fetch('/api/merge', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ base: base, theirs: conflict.current, mine: mine })
+            });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.message);
+
+            if (data.conflicts === 0) {
+                saveMerged(data.merged, conflict);
+                return;
+            }
+            showConflicts(data, conflict);
+        } catch (error) {
+            console.error('Merge error:', error);
+            window.DialogSystem.showMessageDialog(
+                t('editor.save_error', 'Error saving document'),
+                conflict.message
+            );
+        }
+    }
+
+    if (dialog) {
+        dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+        dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+        dialog.querySelector('.merge-save').addEventListener('click', saveResolved);
+        dialog.addEventListener('keydown', event => {
+            if (event.key !== 'Escape') return;
+            event.stopPropagation();
+            closeDialog();
+        });
+    }
+
+    window.EditorMerge = { resolve };
+})();
@@ -0,0 +1,135 @@
+/**
+ * Editor Mobile Module
+ * Adapts the editor to touch devices: compact toolbar, swiping between edit and
+ * preview, photos from the camera and sizing to the visible viewport
+ */
+
+// Phones and tablets, where the toolbar is compact and swiping is enabled
+const touchLayoutQuery = window.matchMedia('(max-width: 768px), (pointer: coarse)');
+
+// Minimum horizontal distance and maximum duration of a swipe
+const SWIPE_DISTANCE = 80;
+const SWIPE_DURATION = 600;
+
+function isTouchLayout() {
+    return touchLayoutQuery.matches;
+}
+
+// Keep --visible-height at the height left by the on-screen keyboard and browser
+// bars, so dialogs and the editor fit in what the user actually sees
+function trackVisibleHeight() {
+    const viewport = window.visualViewport;
+    const update = () => {
+        const height = viewport ? viewport.height : window.innerHeight;
+        document.documentElement.style.setProperty('--visible-height', `${Math.round(height)}px`);
+
+        const editor = window.EditorCore && window.EditorCore.getEditor();
+        if (editor && isTouchLayout()) {
+            editor.refresh();
+        }
+    };
+    update();
+    (viewport || window).addEventListener('resize', update);
+}
+
+// Height of the editor: the desktop layout leaves room for the page header,
+// on touch devices it follows the visible viewport
+function editorHeight() {
+    return isTouchLayout() ? 'calc(var(--visible-height, 100vh) - 180px)' : 'calc(100vh - 380px)';
+}
+
+// Collapse the toolbar to the common buttons on small screens, the others are
+// shown with the "more" button
+function toggleMoreButtons(toolbar, button) {
+    const expanded = toolbar.classList.toggle('expanded');
+    if (button) {
+        button.classList.toggle('active', expanded);
+        button.setAttribute('aria-expanded', expanded ? 'true' : 'false');
+    }
+}
+
+// Swipe left on the editor to show the preview and right to return to editing
+function setupSwipe(area) {
+    if (!area || area._swipeSetup) return;
+    let start = null;
+
+    area.addEventListener('touchstart', (e) => {
+        start = e.touches.length === 1 ? {
+            x: e.touches[0].clientX,
+            y: e.touches[0].clientY,
+            time: Date.now()
+        } : null;
+    }, { passive: true });
+
+    area.addEventListener('touchend', (e) => {
+        if (!start || !isTouchLayout()) return;
+        const touch = e.changedTouches[0];
+        const dx = touch.clientX - start.x;
+        const dy = touch.clientY - start.y;
+        const elapsed = Date.now() - start.time;
+        start = null;
+
+        if (Math.abs(dx) < SWIPE_DISTANCE || Math.abs(dy) > Math.abs(dx) / 2 || elapsed > SWIPE_DURATION) {
+            return;
+        }
+        // Don't take over text selection by dragging
+        const editor = window.EditorCore.getEditor();
+        if (editor && editor.somethingSelected()) return;
+
+        const preview = window.EditorPreview.getPreviewElement();
+        const previewActive = preview && preview.classList.contains('editor-preview-active');
+        if ((dx < 0 && !previewActive) || (dx > 0 && previewActive)) {
+            window.EditorPreview.togglePreview();
+        }
+    }, { passive: true });
+
+    area._swipeSetup = true;
+}
+
+// Open the camera, upload the photo as an attachment and insert it at the cursor
+function captureImage(editor) {
+    const input = document.createElement('input');
+    input.type = 'file';
+    input.accept = 'image/*';
+    input.setAttribute('capture', 'environment');
+    input.style.display = 'none';
+
+    input.addEventListener('change', async () => {
+        const file = input.files && input.files[0];
+        input.remove();
+        if (!file) return;
+
+        await window.SettingsManager.fetchMaxUploadSize();
+        if (file.size > window.SettingsManager.maxFileUploadSizeBytes()) {
+            const size = `${window.SettingsManager.maxFileUploadSizeMB()}MB`;
+            window.DialogSystem.showMessageDialog(
+                'Error',
+                window.i18n ? window.i18n.t('attachments.error_file_size').replace('{{maxFileSize}}', size) : `File size exceeds the ${size} limit`
+            );
+            return;
+        }
+        window.ClipboardHandler.uploadImage(editor, file);
+    });
+
+    document.body.appendChild(input);
+    input.click();
+}
+
+// Prepare the toolbar and editor area of a new editor
+function setupEditor(toolbar, area) {
+    if (toolbar) {
+        toolbar.classList.toggle('touch-toolbar', isTouchLayout());
+    }
+    setupSwipe(area);
+}
+
+document.addEventListener('DOMContentLoaded', trackVisibleHeight);
+
+// Export the module
+window.EditorMobile = {
+    isTouchLayout,
+    editorHeight,
+    toggleMoreButtons,
+    captureImage,
+    setupEditor
+};
@@ -4,6 +4,13 @@
  */
 
 let previewElement = null;
+let splitActive = false;
+let splitTimer = null;
+let scrollLock = null;  // The pane being scrolled by the user, the other one follows
+let scrollAnchors = []; // Pairs of editor and preview offsets of the same heading
+
+// Delay before the side-by-side preview renders the latest changes
+const SPLIT_RENDER_DELAY = 400;
 
 // Function to create preview panel
 function createPreview(container) {
@@ -18,6 +25,10 @@ function createPreview(container) {
 async function togglePreview() {
     const editor = window.EditorCore.getEditor();
     if (!editor || !previewElement) return;
+    // Rich text already shows the formatting
+    if (window.EditorRich && window.EditorRich.isActive()) return;
+    // The full preview replaces the side-by-side one
+    if (splitActive) toggleSplit();
 
     const isPreviewActive = previewElement.classList.contains('editor-preview-active');
     const editorElement = document.querySelector('.CodeMirror');
@@ -86,13 +97,16 @@ async function togglePreview() {
     }
 }
 
-// Function to update preview content
+// Function to update preview content. The side-by-side preview keeps showing
+// the previous rendering while loading, so it doesn't jump while typing.
 async function updatePreview(content) {
     if (!previewElement) return;
 
     try {
         // Show loading indicator
-        previewElement.innerHTML = '<div class="preview-loading">Loading preview...</div>';
+        if (!splitActive) {
+            previewElement.innerHTML = '<div class="preview-loading">Loading preview...</div>';
+        }
 
         // Get current path for handling relative links correctly
         const isHomepage = window.location.pathname === '/';
@@ -158,14 +172,181 @@ async function updatePreview(content) {
             mermaid.init(undefined, previewElement.querySelectorAll('.mermaid'));
         }
 
+        if (window.PagesQuery) {
+            window.PagesQuery.render(previewElement);
+        }
+
+        if (splitActive) {
+            // Diagrams and images change the height of the preview once drawn
+            previewElement.querySelectorAll('img').forEach(img => {
+                img.addEventListener('load', updateScrollAnchors, { once: true });
+            });
+            updateScrollAnchors();
+            syncScroll('editor');
+        }
+
     } catch (error) {
         console.error('Preview error:', error);
         previewElement.innerHTML = '<p>Error rendering preview</p>';
     }
 }
 
+// ---------- Side-by-side preview ----------
+
+// Normalise heading text so markdown and rendered headings can be compared
+function headingKey(text) {
+    return text.toLowerCase().replace(/\{[^}]*\}\s*$/, '').replace(/[^\p{L}\p{N}]+/gu, '');
+}
+
+// sourceHeadings returns the line and text of the headings of the markdown,
+// skipping frontmatter and code blocks
+function sourceHeadings(editor) {
+    const headings = [];
+    let fence = null;
+    let inFrontmatter = false;
+    editor.eachLine(handle => {
+        const text = handle.text;
+        const line = editor.getLineNumber(handle);
+        if (line === 0 && text === '---') {
+            inFrontmatter = true;
+            return;
+        }
+        if (inFrontmatter) {
+            if (text === '---') inFrontmatter = false;
+            return;
+        }
+        const fenceMatch = text.match(/^\s*(`{3,}|~{3,})/);
+        if (fenceMatch) {
+            if (!fence) fence = fenceMatch[1];
+            else if (fenceMatch[1].startsWith(fence)) fence = null;
+            return;
+        }
+        const match = !fence && text.match(/^#{1,6}\s+(.*?)\s*#*\s*$/);
+        if (match) {
+            headings.push({ line, key: headingKey(match[1]) });
+        }
+    });
+    return headings;
+}
+
+// updateScrollAnchors pairs each heading of the source with the same heading in
+// the preview. Headings are matched by text in order, so extensions that add or
+// hide headings only lose their own anchor.
+function updateScrollAnchors() {
+    const editor = window.EditorCore.getEditor();
+    if (!editor || !previewElement) return;
+
+    const previewTop = previewElement.getBoundingClientRect().top - previewElement.scrollTop;
+    const rendered = Array.from(previewElement.querySelectorAll('h1, h2, h3, h4, h5, h6'))
+        .filter(heading => heading.offsetParent !== null)
+        .map(heading => ({ key: headingKey(heading.textContent), top: heading.getBoundingClientRect().top - previewTop }));
+
+    const anchors = [{ editor: 0, preview: 0 }];
+    let next = 0;
+    sourceHeadings(editor).forEach(heading => {
+        for (let i = next; i < rendered.length; i++) {
+            if (rendered[i].key === heading.key) {
+                const last = anchors[anchors.length - 1];
+                const anchor = { editor: editor.heightAtLine(heading.line, 'local'), preview: rendered[i].top };
+                // Keep the anchors in order in both panes
+                if (anchor.editor > last.editor && anchor.preview > last.preview) {
+                    anchors.push(anchor);
+                }
+                next = i + 1;
+                break;
+            }
+        }
+    });
+
+    const info = editor.getScrollInfo();
+    anchors.push({
+        editor: Math.max(info.height - info.clientHeight, anchors[anchors.length - 1].editor + 1),
+        preview: Math.max(previewElement.scrollHeight - previewElement.clientHeight, anchors[anchors.length - 1].preview + 1)
+    });
+    scrollAnchors = anchors;
+}
+
+// mapOffset converts a scroll offset of one pane to the other by interpolating
+// between the anchors around it
+function mapOffset(offset, from, to) {
+    for (let i = 1; i < scrollAnchors.length; i++) {
+        const start = scrollAnchors[i - 1];
+        const end = scrollAnchors[i];
+        if (offset <= end[from] || i === scrollAnchors.length - 1) {
+            const ratio = Math.min(1, Math.max(0, (offset - start[from]) / (end[from] - start[from])));
+            return start[to] + ratio * (end[to] - start[to]);
+        }
+    }
+    return 0;
+}
+
+// syncScroll scrolls the other pane to follow the pane scrolled by the user
+function syncScroll(source) {
+    const editor = window.EditorCore.getEditor();
+    if (!splitActive || !editor || scrollAnchors.length < 2) return;
+    if (scrollLock && scrollLock !== source) return;
+
+    scrollLock = source;
+    if (source === 'editor') {
+        previewElement.scrollTop = mapOffset(editor.getScrollInfo().top, 'editor', 'preview');
+    } else {
+        editor.scrollTo(null, mapOffset(previewElement.scrollTop, 'preview', 'editor'));
+    }
+    // The scroll event of the following pane arrives in the next frame
+    clearTimeout(syncScroll.timer);
+    syncScroll.timer = setTimeout(() => { scrollLock = null; }, 100);
+}
+
+// scheduleSplitRender renders the side-by-side preview once typing pauses
+function scheduleSplitRender() {
+    if (!splitActive) return;
+    clearTimeout(splitTimer);
+    splitTimer = setTimeout(() => {
+        const editor = window.EditorCore.getEditor();
+        if (editor) updatePreview(editor.getValue());
+    }, SPLIT_RENDER_DELAY);
+}
+
+// toggleSplit shows the preview next to the editor, following its scrolling
+function toggleSplit() {
+    const editor = window.EditorCore.getEditor();
+    if (!editor || !previewElement) return;
+
+    const area = previewElement.parentElement;
+    const button = document.querySelector('.custom-toolbar .toggle-split-button');
+    splitActive = !splitActive;
+    area.classList.toggle('editor-split', splitActive);
+    if (button) button.classList.toggle('active', splitActive);
+
+    if (splitActive) {
+        previewElement.style.height = window.EditorMobile.editorHeight();
+        if (!previewElement._splitSetup) {
+            editor.on('scroll', () => syncScroll('editor'));
+            previewElement.addEventListener('scroll', () => syncScroll('preview'));
+            window.addEventListener('resize', () => {
+                if (splitActive) updateScrollAnchors();
+            });
+            previewElement._splitSetup = true;
+        }
+        updatePreview(editor.getValue());
+    } else {
+        clearTimeout(splitTimer);
+        previewElement.style.height = '';
+        previewElement.innerHTML = '';
+        scrollAnchors = [];
+    }
+
+    setTimeout(() => {
+        editor.refresh();
+        editor.focus();
+    }, 50);
+}
+
 // Cleanup function
 function cleanup() {
+    clearTimeout(splitTimer);
+    splitActive = false;
+    scrollAnchors = [];
     if (previewElement) {
         previewElement.remove();
         previewElement = null;
@@ -177,8 +358,11 @@ window.EditorPreview = {
     createPreview,
     togglePreview,
     updatePreview,
+    toggleSplit,
+    scheduleSplitRender,
     cleanup,
 
     // Getters
-    getPreviewElement: () => previewElement
+    getPreviewElement: () => previewElement,
+    isSplitActive: () => splitActive
 };
\ No newline at end of file
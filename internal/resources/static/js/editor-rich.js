@@ -0,0 +1,676 @@
+/**
+ * Editor Rich Text Module
+ * Edits the document as formatted text and writes it back as markdown. Parts the
+ * rich text mode can't represent (frontmatter, code, tables, wiki blocks, HTML)
+ * are shown as source and kept exactly as they are. Blocks that weren't edited
+ * are written back unchanged, so switching modes never reformats a document.
+ */
+
+let richElement = null;
+let richActive = false;
+let syncing = false;  // CodeMirror is being updated from the rich text
+let syncTimer = null;
+let leadingText = '';  // Blank lines before the first block
+let trailingText = ''; // Line breaks after the last block
+
+// Delay before edits are copied into the markdown source
+const RICH_SYNC_DELAY = 300;
+
+// Toolbar buttons that work on rich text, the others are disabled
+const RICH_ACTIONS = ['heading', 'bold', 'italic', 'strikethrough', 'highlight', 'subscript', 'superscript',
+    'code', 'quote', 'unordered-list', 'ordered-list', 'link', 'horizontal-rule', 'undo', 'redo',
+    'toggle-rich', 'toggle-more'];
+
+// ---------- Markdown to blocks ----------
+
+const fenceRegex = /^(\s{0,3})(`{3,}|~{3,})/;
+const headingRegex = /^(#{1,6})\s+(.*?)\s*#*\s*$/;
+const listItemRegex = /^([-*+]|(\d+)[.)])\s+(.*)$/;
+const ruleRegex = /^\s{0,3}([-*_])(\s*\1){2,}\s*$/;
+
+// startsBlock reports whether a line interrupts a paragraph
+function startsBlock(line) {
+    return fenceRegex.test(line) || headingRegex.test(line) || listItemRegex.test(line) ||
+        ruleRegex.test(line) || /^\s*(>|\||<|:::)/.test(line);
+}
+
+// splitBlocks cuts markdown into blocks. Each block keeps its source and the line
+// breaks after it, so joining them gives back the document.
+function splitBlocks(markdown) {
+    const lines = markdown.split('\n');
+    const blocks = [];
+    let i = 0;
+
+    while (i < lines.length && lines[i].trim() === '') i++;
+    leadingText = lines.slice(0, i).map(l => l + '\n').join('');
+
+    const add = (kind, start, end, extra = {}) => {
+        let next = end;
+        while (next < lines.length && lines[next].trim() === '') next++;
+        blocks.push(Object.assign({
+            kind,
+            source: lines.slice(start, end).join('\n'),
+            separator: lines.slice(end, next).map(l => '\n' + l).join('') + (next < lines.length ? '\n' : '')
+        }, extra));
+        return next;
+    };
+
+    // untilBlank returns the first blank line at or after start
+    const untilBlank = (start) => {
+        let end = start;
+        while (end < lines.length && lines[end].trim() !== '') end++;
+        return end;
+    };
+
+    while (i < lines.length) {
+        const line = lines[i];
+        let end;
+
+        if (i === 0 && line === '---') {
+            // Frontmatter
+            end = 1;
+            while (end < lines.length && lines[end] !== '---') end++;
+            i = add('raw', 0, Math.min(end + 1, lines.length), { label: 'frontmatter' });
+        } else if (fenceRegex.test(line)) {
+            const fence = line.match(fenceRegex)[2];
+            end = i + 1;
+            while (end < lines.length && !lines[end].trim().startsWith(fence)) end++;
+            i = add('raw', i, Math.min(end + 1, lines.length), { label: 'code' });
+        } else if (/^:::/.test(line)) {
+            // Wiki blocks either fit on one line (:::stats recent=5:::) or end with :::
+            end = i + 1;
+            if (!/^:::.+:::\s*$/.test(line)) {
+                while (end < lines.length && lines[end].trim() !== ':::') end++;
+                end = Math.min(end + 1, lines.length);
+            }
+            i = add('raw', i, end, { label: 'block' });
+        } else if (/^( {4}|\t)/.test(line)) {
+            i = add('raw', i, untilBlank(i), { label: 'code' });
+        } else if (/^\s*\|/.test(line)) {
+            i = add('raw', i, untilBlank(i), { label: 'table' });
+        } else if (/^\s*</.test(line)) {
+            i = add('raw', i, untilBlank(i), { label: 'html' });
+        } else if (ruleRegex.test(line)) {
+            i = add('rule', i, i + 1);
+        } else if (headingRegex.test(line)) {
+            const match = line.match(headingRegex);
+            i = add('heading', i, i + 1, { level: match[1].length, text: match[2] });
+        } else if (listItemRegex.test(line)) {
+            end = untilBlank(i);
+            const items = lines.slice(i, end).map(l => l.match(listItemRegex));
+            const ordered = !!items[0][2];
+            // Nested lists, task lists, continuation lines and mixed markers stay as source
+            const simple = items.every(m => m && !!m[2] === ordered && !/^\[[ xX]\]\s/.test(m[3]));
+            if (simple) {
+                i = add('list', i, end, {
+                    ordered,
+                    start: ordered ? parseInt(items[0][2], 10) : 1,
+                    items: items.map(m => m[3])
+                });
+            } else {
+                i = add('raw', i, end, { label: 'list' });
+            }
+        } else if (/^\s{0,3}>/.test(line)) {
+            end = untilBlank(i);
+            const quoted = lines.slice(i, end);
+            // Nested quotes and quotes holding other blocks stay as source
+            const simple = quoted.every(l => /^\s{0,3}>/.test(l) && !startsBlock(l.replace(/^\s{0,3}>\s?/, '')));
+            if (simple) {
+                i = add('quote', i, end, { text: quoted.map(l => l.replace(/^\s{0,3}>\s?/, '')).join('\n') });
+            } else {
+                i = add('raw', i, end, { label: 'quote' });
+            }
+        } else {
+            end = i + 1;
+            while (end < lines.length && lines[end].trim() !== '' && !startsBlock(lines[end])) end++;
+            const text = lines.slice(i, end).join('\n');
+            // Shortcodes such as [toc] render as something else entirely
+            if (/^\[[a-z]+\]$/.test(text.trim())) {
+                i = add('raw', i, end, { label: 'shortcode' });
+            } else {
+                i = add('paragraph', i, end, { text });
+            }
+        }
+    }
+    trailingText = blocks.length ? blocks[blocks.length - 1].separator : '';
+    return blocks;
+}
+
+// ---------- Inline markdown to HTML ----------
+
+function escapeHTML(text) {
+    return text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+}
+
+// rawInline shows markdown the rich text mode can't edit, such as images,
+// footnotes and wiki links, as an atomic chip holding its source
+function rawInline(source, label) {
+    return `<span class="rich-raw-inline" contenteditable="false" data-source="${escapeHTML(source)}">${escapeHTML(label || source)}</span>`;
+}
+
+// Inline patterns, tried in order at each position
+const inlinePatterns = [
+    { regex: /^\\([\\`*_{}[\]()#+\-.!~=|<>^])/, html: m => escapeHTML(m[1]) },
+    { regex: /^(`+)([\s\S]*?[^`])\1(?!`)/, html: m => `<code>${escapeHTML(m[2].replace(/^ (.*) $/, '$1'))}</code>` },
+    { regex: /^!\[[^\]]*\]\([^)]*\)/, html: m => rawInline(m[0]) },
+    { regex: /^\[\^[^\]]+\]|^\[\[[^\]]+\]\]/, html: m => rawInline(m[0]) },
+    { regex: /^\[([^\]]+)\]\(\s*<?([^)\s>]+)>?(?:\s+"([^"]*)")?\s*\)/, html: m => {
+        const title = m[3] ? ` title="${escapeHTML(m[3])}"` : '';
+        return `<a href="${escapeHTML(m[2])}"${title}>${inlineHTML(m[1])}</a>`;
+    } },
+    { regex: /^<[^>\s]+[^>]*>/, html: m => rawInline(m[0]) },
+    { regex: /^\*\*(?=\S)([\s\S]*?\S)\*\*/, html: m => `<strong>${inlineHTML(m[1])}</strong>` },
+    { regex: /^__(?=\S)([\s\S]*?\S)__(?!\w)/, html: m => `<strong>${inlineHTML(m[1])}</strong>` },
+    { regex: /^~~(?=\S)([\s\S]*?\S)~~/, html: m => `<del>${inlineHTML(m[1])}</del>` },
+    { regex: /^==(?=\S)([\s\S]*?\S)==/, html: m => `<mark>${inlineHTML(m[1])}</mark>` },
+    { regex: /^\*(?=\S)([\s\S]*?\S)\*/, html: m => `<em>${inlineHTML(m[1])}</em>` },
+    { regex: /^_(?=\S)([\s\S]*?\S)_(?!\w)/, html: m => `<em>${inlineHTML(m[1])}</em>` },
+    { regex: /^\^(?=\S)([^\s^]+)\^/, html: m => `<sup>${inlineHTML(m[1])}</sup>` },
+    { regex: /^~(?=\S)([^\s~]+)~/, html: m => `<sub>${inlineHTML(m[1])}</sub>` }
+];
+
+// inlineHTML converts the inline markdown of a block to HTML
+function inlineHTML(text) {
+    let html = '';
+    let plain = '';
+    let pos = 0;
+
+    while (pos < text.length) {
+        const rest = text.slice(pos);
+        // Underscores inside words don't start emphasis
+        const wordInner = rest[0] === '_' && pos > 0 && /\w/.test(text[pos - 1]);
+        let matched = null;
+        if (!wordInner) {
+            for (const pattern of inlinePatterns) {
+                const m = rest.match(pattern.regex);
+                if (m) {
+                    matched = { length: m[0].length, html: pattern.html(m) };
+                    break;
+                }
+            }
+        }
+        if (matched) {
+            html += escapeHTML(plain) + matched.html;
+            plain = '';
+            pos += matched.length;
+        } else {
+            plain += text[pos];
+            pos++;
+        }
+    }
+    return html + escapeHTML(plain);
+}
+
+// ---------- Blocks to HTML ----------
+
+function blockElement(block) {
+    let el;
+    switch (block.kind) {
+        case 'heading':
+            el = document.createElement('h' + block.level);
+            el.innerHTML = inlineHTML(block.text);
+            break;
+        case 'paragraph':
+            el = document.createElement('p');
+            el.innerHTML = inlineHTML(block.text);
+            break;
+        case 'quote':
+            el = document.createElement('blockquote');
+            el.innerHTML = inlineHTML(block.text);
+            break;
+        case 'list':
+            el = document.createElement(block.ordered ? 'ol' : 'ul');
+            if (block.ordered && block.start !== 1) {
+                el.setAttribute('start', block.start);
+            }
+            block.items.forEach(item => {
+                const li = document.createElement('li');
+                li.innerHTML = inlineHTML(item);
+                el.appendChild(li);
+            });
+            break;
+        case 'rule':
+            el = document.createElement('hr');
+            break;
+        default: {
+            el = document.createElement('div');
+            el.className = 'rich-raw-block';
+            el.contentEditable = 'false';
+            el.title = window.i18n ? window.i18n.t('editor.rich_edit_source') : 'Double-click to edit as markdown';
+            const label = document.createElement('span');
+            label.className = 'rich-raw-label';
+            label.textContent = block.label;
+            const pre = document.createElement('pre');
+            pre.textContent = block.source;
+            el.appendChild(label);
+            el.appendChild(pre);
+        }
+    }
+    // Expando properties aren't copied when the browser splits an element, so a
+    // block split by Enter counts as new
+    el._source = block.source;
+    el._separator = block.separator;
+    el._raw = block.kind === 'raw';
+    el._html = el.innerHTML;
+    return el;
+}
+
+// render replaces the rich text with the blocks of markdown
+function render(markdown) {
+    if (!richElement) return;
+    richElement.innerHTML = '';
+    splitBlocks(markdown).forEach(block => richElement.appendChild(blockElement(block)));
+    if (!richElement.firstChild) {
+        richElement.appendChild(document.createElement('p'));
+    }
+}
+
+// ---------- HTML to markdown ----------
+
+// escapeText escapes the characters of typed text that markdown would read as formatting
+function escapeText(text) {
+    return text
+        .replace(/\\/g, '\\\\')
+        .replace(/([`*[\]<])/g, '\\$1')
+        .replace(/(^|\W)_|_(?=\W|$)/g, m => m.replace('_', '\\_'))
+        .replace(/~/g, '\\~')
+        .replace(/==/g, '\\=\\=')
+        .replace(/\^/g, '\\^')
+        .replace(/ /g, ' ');
+}
+
+// escapeLineStart escapes text at the start of a line that would begin another block
+function escapeLineStart(line) {
+    if (/^\s*-{2,}\s*$/.test(line)) {
+        // A line of dashes would turn the line above into a heading
+        return line.replace('-', '\\-');
+    }
+    return line
+        .replace(/^(\s*)(#{1,6}\s|>|[-+]\s|\||:::)/, '$1\\$2')
+        .replace(/^(\s*\d+)([.)]\s)/, '$1\\$2');
+}
+
+// wrap puts markers around formatted text, keeping surrounding spaces outside
+function wrap(marker, text, closing = marker) {
+    const match = text.match(/^(\s*)([\s\S]*?)(\s*)$/);
+    if (!match[2]) return text;
+    return match[1] + marker + match[2] + closing + match[3];
+}
+
+function codeSpan(text) {
+    const longest = Math.max(0, ...(text.match(/`+/g) || []).map(run => run.length));
+    const ticks = '`'.repeat(longest + 1);
+    const pad = text.startsWith('`') || text.endsWith('`') ? ' ' : '';
+    return ticks + pad + text + pad + ticks;
+}
+
+// inlineMarkdown converts the content of an element back to inline markdown
+function inlineMarkdown(node) {
+    let out = '';
+    node.childNodes.forEach(child => {
+        if (child.nodeType === Node.TEXT_NODE) {
+            out += escapeText(child.textContent);
+            return;
+        }
+        if (child.nodeType !== Node.ELEMENT_NODE) return;
+        if (child.classList.contains('rich-raw-inline')) {
+            out += child.dataset.source;
+            return;
+        }
+        const inner = () => inlineMarkdown(child);
+        switch (child.tagName) {
+            case 'STRONG': case 'B': out += wrap('**', inner()); break;
+            case 'EM': case 'I': out += wrap('*', inner()); break;
+            case 'DEL': case 'S': case 'STRIKE': out += wrap('~~', inner()); break;
+            case 'MARK': out += wrap('==', inner()); break;
+            case 'SUP': out += wrap('^', inner()); break;
+            case 'SUB': out += wrap('~', inner()); break;
+            case 'CODE': out += codeSpan(child.textContent); break;
+            case 'BR': out += '\n'; break;
+            case 'A': {
+                const title = child.getAttribute('title');
+                out += `[${inner()}](${child.getAttribute('href') || ''}${title ? ` "${title}"` : ''})`;
+                break;
+            }
+            case 'IMG':
+                out += `![${child.getAttribute('alt') || ''}](${child.getAttribute('src') || ''})`;
+                break;
+            default:
+                out += inner();
+        }
+    });
+    return out;
+}
+
+// blockText converts the content of a block, escaping line starts
+function blockText(el) {
+    return inlineMarkdown(el).replace(/\n+$/, '').split('\n').map(escapeLineStart).join('\n');
+}
+
+function listMarkdown(list, indent) {
+    const ordered = list.tagName === 'OL';
+    let number = parseInt(list.getAttribute('start') || '1', 10);
+    const lines = [];
+    list.querySelectorAll(':scope > li').forEach(li => {
+        const marker = ordered ? `${number++}. ` : '- ';
+        const nested = [];
+        const clone = li.cloneNode(true);
+        clone.querySelectorAll(':scope > ul, :scope > ol').forEach(sub => {
+            sub.remove();
+        });
+        li.querySelectorAll(':scope > ul, :scope > ol').forEach(sub => {
+            nested.push(listMarkdown(sub, indent + ' '.repeat(marker.length)));
+        });
+        const text = inlineMarkdown(clone).replace(/\n+$/, '').split('\n')
+            .join('\n' + indent + ' '.repeat(marker.length));
+        lines.push(indent + marker + text);
+        nested.forEach(sub => lines.push(sub));
+    });
+    return lines.join('\n');
+}
+
+// blockMarkdown converts a block element back to markdown, returning the original
+// source when it wasn't changed
+function blockMarkdown(el) {
+    if (el.nodeType === Node.TEXT_NODE) {
+        return el.textContent.trim() ? escapeLineStart(escapeText(el.textContent)) : '';
+    }
+    if (el.nodeType !== Node.ELEMENT_NODE) return '';
+    if (el._source !== undefined && (el._raw || el.innerHTML === el._html)) {
+        return el._source;
+    }
+
+    const heading = el.tagName.match(/^H([1-6])$/);
+    if (heading) {
+        return '#'.repeat(parseInt(heading[1], 10)) + ' ' + inlineMarkdown(el).replace(/\n/g, ' ').trim();
+    }
+    switch (el.tagName) {
+        case 'UL':
+        case 'OL':
+            return listMarkdown(el, '');
+        case 'BLOCKQUOTE':
+            return blockText(el).split('\n').map(line => '> ' + line).join('\n');
+        case 'HR':
+            return '---';
+        case 'PRE':
+            return '```\n' + el.textContent.replace(/\n$/, '') + '\n```';
+        default:
+            return blockText(el);
+    }
+}
+
+// serialize converts the rich text back to markdown, up to stopAt when given
+function serialize(stopAt) {
+    const parts = [];
+    for (let node = richElement.firstChild; node && node !== stopAt; node = node.nextSibling) {
+        const markdown = blockMarkdown(node);
+        if (markdown.trim() === '') continue;
+        parts.push({ markdown, separator: node._source !== undefined ? node._separator : undefined });
+    }
+
+    let out = leadingText;
+    parts.forEach((part, i) => {
+        let separator = part.separator;
+        if (i === parts.length - 1 && !stopAt) {
+            separator = trailingText || '\n';
+        } else if (separator === undefined || !/\n\s*\n/.test(separator)) {
+            // New blocks, and the block that was last before more were added
+            separator = '\n\n';
+        }
+        out += part.markdown + separator;
+    });
+    return out;
+}
+
+// ---------- Synchronising with CodeMirror ----------
+
+// flush copies pending rich text edits into the markdown source
+function flush() {
+    clearTimeout(syncTimer);
+    syncTimer = null;
+    const editor = window.EditorCore.getEditor();
+    if (!richActive || !editor) return;
+
+    const markdown = serialize();
+    if (markdown !== editor.getValue()) {
+        syncing = true;
+        editor.setValue(markdown);
+        syncing = false;
+    }
+}
+
+function scheduleSync() {
+    clearTimeout(syncTimer);
+    syncTimer = setTimeout(flush, RICH_SYNC_DELAY);
+}
+
+// onEditorChange re-renders the rich text when the source was changed by
+// something else, such as an upload or a picker
+function onEditorChange(editor) {
+    if (richActive && !syncing) {
+        render(editor.getValue());
+    }
+}
+
+// ---------- Mode switching ----------
+
+function setToolbarState(toolbar, active) {
+    if (!toolbar) return;
+    toolbar.querySelectorAll('button').forEach(button => {
+        const supported = RICH_ACTIONS.includes(button.dataset.action);
+        button.disabled = active && !supported;
+        button.classList.toggle('disabled', active && !supported);
+    });
+    const toggle = toolbar.querySelector('.toggle-rich-button');
+    if (toggle) {
+        toggle.classList.toggle('active', active);
+        toggle.title = active ? 'Edit as Markdown' : 'Edit as Rich Text';
+    }
+}
+
+function activate() {
+    const editor = window.EditorCore.getEditor();
+    if (!richElement || !editor || richActive) return;
+
+    // The preview has nothing to add to rich text
+    const preview = window.EditorPreview.getPreviewElement();
+    if (preview && preview.classList.contains('editor-preview-active')) {
+        window.EditorPreview.togglePreview();
+    }
+    if (window.EditorPreview.isSplitActive()) {
+        window.EditorPreview.toggleSplit();
+    }
+
+    render(editor.getValue());
+    richActive = true;
+    richElement.style.height = window.EditorMobile.editorHeight();
+    richElement.classList.add('editor-rich-active');
+    const codeMirror = document.querySelector('.CodeMirror');
+    if (codeMirror) codeMirror.style.display = 'none';
+    setToolbarState(document.querySelector('.custom-toolbar'), true);
+    richElement.focus();
+}
+
+// deactivate returns to the markdown source, placing the cursor on line when given
+function deactivate(line) {
+    const editor = window.EditorCore.getEditor();
+    if (!richActive) return;
+
+    flush();
+    richActive = false;
+    richElement.classList.remove('editor-rich-active');
+    const codeMirror = document.querySelector('.CodeMirror');
+    if (codeMirror) codeMirror.style.display = 'block';
+    setToolbarState(document.querySelector('.custom-toolbar'), false);
+
+    if (editor) {
+        setTimeout(() => {
+            editor.refresh();
+            if (line !== undefined) {
+                editor.setCursor({ line, ch: 0 });
+                editor.scrollIntoView({ line, ch: 0 }, 100);
+            }
+            editor.focus();
+        }, 50);
+    }
+}
+
+// savePreference stores the mode so the editor opens in it next time
+function savePreference(mode) {
+    fetch('/api/preferences', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ editorMode: mode })
+    }).catch(error => console.error('Failed to save editor mode:', error));
+}
+
+function toggle() {
+    if (richActive) {
+        deactivate();
+        savePreference('markdown');
+    } else {
+        activate();
+        savePreference('rich');
+    }
+}
+
+// applyPreference opens the editor in the mode the user chose last
+async function applyPreference() {
+    try {
+        const response = await fetch('/api/preferences');
+        if (!response.ok) return;
+        const data = await response.json();
+        if (data.preferences && data.preferences.editorMode === 'rich') {
+            activate();
+        }
+    } catch (error) {
+        console.error('Failed to load editor mode:', error);
+    }
+}
+
+// ---------- Toolbar actions ----------
+
+function currentBlock() {
+    const selection = window.getSelection();
+    let node = selection.rangeCount ? selection.getRangeAt(0).startContainer : null;
+    while (node && node.parentNode !== richElement) node = node.parentNode;
+    return node;
+}
+
+function wrapSelection(tag) {
+    const selection = window.getSelection();
+    const text = selection.toString() || tag;
+    document.execCommand('insertHTML', false, `<${tag}>${escapeHTML(text)}</${tag}>`);
+}
+
+// handleAction runs a toolbar action on the rich text, returning false when
+// the action isn't handled here
+function handleAction(action) {
+    if (action === 'toggle-rich') {
+        toggle();
+        return true;
+    }
+    if (!richActive) return false;
+
+    richElement.focus();
+    switch (action) {
+        case 'bold': document.execCommand('bold'); break;
+        case 'italic': document.execCommand('italic'); break;
+        case 'strikethrough': document.execCommand('strikeThrough'); break;
+        case 'subscript': document.execCommand('subscript'); break;
+        case 'superscript': document.execCommand('superscript'); break;
+        case 'highlight': wrapSelection('mark'); break;
+        case 'code': wrapSelection('code'); break;
+        case 'unordered-list': document.execCommand('insertUnorderedList'); break;
+        case 'ordered-list': document.execCommand('insertOrderedList'); break;
+        case 'horizontal-rule': document.execCommand('insertHorizontalRule'); break;
+        case 'undo': document.execCommand('undo'); break;
+        case 'redo': document.execCommand('redo'); break;
+        case 'quote': {
+            const block = currentBlock();
+            document.execCommand('formatBlock', false, block && block.tagName === 'BLOCKQUOTE' ? 'p' : 'blockquote');
+            break;
+        }
+        case 'heading': {
+            // Cycle through the heading levels like the markdown editor does
+            const block = currentBlock();
+            const match = block && block.tagName ? block.tagName.match(/^H([1-6])$/) : null;
+            const level = match ? parseInt(match[1], 10) + 1 : 1;
+            document.execCommand('formatBlock', false, level > 6 ? 'p' : 'h' + level);
+            break;
+        }
+        case 'link': {
+            const url = prompt(window.i18n ? window.i18n.t('editor.rich_link_url') : 'Link address');
+            if (url) document.execCommand('createLink', false, url);
+            break;
+        }
+        default:
+            return false;
+    }
+    scheduleSync();
+    return true;
+}
+
+// ---------- Setup ----------
+
+// createRichEditor adds the rich text element to the editor area
+function createRichEditor(container) {
+    const el = document.createElement('div');
+    el.className = 'editor-rich markdown-content';
+    el.contentEditable = 'true';
+    el.spellcheck = true;
+    container.appendChild(el);
+    richElement = el;
+    richActive = false;
+    leadingText = '';
+    trailingText = '';
+
+    document.execCommand('defaultParagraphSeparator', false, 'p');
+
+    el.addEventListener('input', scheduleSync);
+    el.addEventListener('blur', flush);
+
+    // Paste as plain text, formatting from other pages can't be kept as markdown
+    el.addEventListener('paste', (e) => {
+        const text = e.clipboardData && e.clipboardData.getData('text/plain');
+        if (text === undefined || text === null) return;
+        e.preventDefault();
+        document.execCommand('insertText', false, text);
+    });
+
+    // Blocks shown as source are edited in the markdown editor
+    el.addEventListener('dblclick', (e) => {
+        const block = e.target.closest('.rich-raw-block');
+        if (!block) return;
+        flush();
+        const line = serialize(block).split('\n').length - 1;
+        deactivate(line);
+        savePreference('markdown');
+    });
+
+    return el;
+}
+
+function cleanup() {
+    clearTimeout(syncTimer);
+    syncTimer = null;
+    if (richElement) {
+        richElement.remove();
+        richElement = null;
+    }
+    richActive = false;
+}
+
+// Export the module
+window.EditorRich = {
+    createRichEditor,
+    applyPreference,
+    handleAction,
+    onEditorChange,
+    flush,
+    toggle,
+    cleanup,
+
+    // Getters
+    isActive: () => richActive
+};
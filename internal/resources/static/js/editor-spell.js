@@ -0,0 +1,198 @@
+/**
+ * Editor Spellcheck Module
+ * Underlines the words the server dictionary doesn't know and offers
+ * corrections from the context menu
+ */
+
+let spellTimer = null;
+let spellRequest = 0;     // Responses to older requests are ignored
+let spellEnabled = true;  // Turned off when no dictionary is installed
+let spellLanguage = '';
+let spellMarks = [];
+let spellMenu = null;
+let isSpellAdmin = false;
+const ignoredWords = new Set(); // Words ignored until the page is reloaded
+
+// Delay before checking the document after an edit
+const SPELL_DELAY = 1500;
+
+// Path of the page being edited, empty for the home page
+function spellDocPath() {
+    const path = decodeURIComponent(window.location.pathname);
+    return path === '/' ? '' : path;
+}
+
+function spellText(key, fallback) {
+    return window.i18n ? window.i18n.t(key) : fallback;
+}
+
+// Underline the misspelled words in the editor
+function markMisspellings(editor, misspellings) {
+    spellMarks.forEach(mark => mark.clear());
+    spellMarks = misspellings
+        .filter(word => !ignoredWords.has(word.word))
+        .map(word => editor.markText(
+            { line: word.line - 1, ch: word.ch },
+            { line: word.line - 1, ch: word.ch + word.length },
+            { className: 'cm-spell-error', attributes: { 'data-word': word.word } }
+        ));
+}
+
+// spellCheck asks the server for the misspelled words of the current content
+async function spellCheck() {
+    const editor = window.EditorCore.getEditor();
+    if (!editor || !spellEnabled) return;
+
+    const request = ++spellRequest;
+    try {
+        const response = await fetch(`/api/spellcheck?path=${encodeURIComponent(spellDocPath())}&lang=${encodeURIComponent(getEditLanguage())}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'text/plain' },
+            body: editor.getValue()
+        });
+        if (!response.ok) return;
+        const data = await response.json();
+        if (request !== spellRequest) return;
+        if (!data.available) {
+            spellEnabled = false;
+            return;
+        }
+        spellLanguage = data.language;
+        markMisspellings(editor, data.misspellings || []);
+    } catch (error) {
+        console.error('Spellcheck error:', error);
+    }
+}
+
+function scheduleSpellCheck() {
+    if (!spellEnabled) return;
+    clearTimeout(spellTimer);
+    spellTimer = setTimeout(spellCheck, SPELL_DELAY);
+}
+
+function closeSpellMenu() {
+    if (spellMenu) {
+        spellMenu.remove();
+        spellMenu = null;
+    }
+}
+
+// addSpellMenuEntry adds an entry running action to the context menu
+function addSpellMenuEntry(label, detail, action) {
+    const entry = document.createElement('li');
+    entry.setAttribute('role', 'option');
+    const text = document.createElement('span');
+    text.className = 'autocomplete-label';
+    text.textContent = label;
+    entry.appendChild(text);
+    if (detail) {
+        const note = document.createElement('span');
+        note.className = 'autocomplete-detail';
+        note.textContent = detail;
+        entry.appendChild(note);
+    }
+    entry.addEventListener('mousedown', (e) => {
+        e.preventDefault();
+        closeSpellMenu();
+        action();
+    });
+    spellMenu.appendChild(entry);
+}
+
+// openSpellMenu lists corrections for the misspelled word under mark
+async function openSpellMenu(editor, mark, x, y) {
+    closeSpellMenu();
+    const range = mark.find();
+    if (!range) return;
+    const word = editor.getRange(range.from, range.to);
+
+    spellMenu = document.createElement('ul');
+    spellMenu.className = 'editor-autocomplete editor-spell-menu';
+    spellMenu.setAttribute('role', 'listbox');
+    spellMenu.style.left = `${Math.min(x, window.innerWidth - 240)}px`;
+    spellMenu.style.top = `${y}px`;
+    spellMenu.style.display = 'block';
+    document.body.appendChild(spellMenu);
+    const menu = spellMenu;
+
+    let suggestions = [];
+    try {
+        const response = await fetch(`/api/spellcheck/suggestions?lang=${encodeURIComponent(spellLanguage)}&word=${encodeURIComponent(word)}`);
+        if (response.ok) {
+            suggestions = (await response.json()).suggestions || [];
+        }
+    } catch (error) {
+        console.error('Spellcheck error:', error);
+    }
+    if (menu !== spellMenu) return;
+
+    suggestions.forEach(suggestion => {
+        addSpellMenuEntry(suggestion, '', () => {
+            const current = mark.find();
+            if (!current) return;
+            editor.replaceRange(suggestion, current.from, current.to, '+spellcheck');
+            editor.focus();
+        });
+    });
+    if (suggestions.length === 0) {
+        addSpellMenuEntry(spellText('editor.spell_no_suggestions', 'No suggestions'), '', () => editor.focus());
+    }
+    addSpellMenuEntry(spellText('editor.spell_ignore', 'Ignore'), word, () => {
+        ignoredWords.add(word);
+        spellMarks = spellMarks.filter(other => {
+            const found = other.find();
+            if (found && editor.getRange(found.from, found.to) === word) {
+                other.clear();
+                return false;
+            }
+            return true;
+        });
+    });
+    if (isSpellAdmin) {
+        addSpellMenuEntry(spellText('editor.spell_add', 'Add to dictionary'), word, async () => {
+            const response = await fetch('/api/settings/dictionary', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ word })
+            });
+            if (response.ok) spellCheck();
+        });
+    }
+}
+
+// setup underlines misspellings in a new editor, when a dictionary is installed
+function setup(editor) {
+    if (!editor) return;
+    spellEnabled = true;
+
+    if (!editor._spellSetup) {
+        editor.on('change', scheduleSpellCheck);
+        editor.on('blur', closeSpellMenu);
+        editor.getWrapperElement().addEventListener('contextmenu', (e) => {
+            const pos = editor.coordsChar({ left: e.clientX, top: e.clientY }, 'window');
+            const mark = editor.findMarksAt(pos).find(found => found.className === 'cm-spell-error');
+            if (!mark) return;
+            e.preventDefault();
+            openSpellMenu(editor, mark, e.clientX, e.clientY);
+        });
+        editor._spellSetup = true;
+    }
+
+    if (window.Auth && window.Auth.checkIfUserIsAdmin) {
+        window.Auth.checkIfUserIsAdmin().then(isAdmin => { isSpellAdmin = isAdmin; });
+    }
+    spellCheck();
+}
+
+function cleanup() {
+    clearTimeout(spellTimer);
+    spellRequest++;
+    spellMarks = [];
+    closeSpellMenu();
+}
+
+// Export the module
+window.EditorSpell = {
+    setup,
+    cleanup
+};
@@ -126,6 +126,25 @@ layout: kanban
 }
 
 // Create custom toolbar
+// Pick a Word document and insert it converted to markdown
+function insertWordDocument(cm) {
+    const input = document.createElement('input');
+    input.type = 'file';
+    input.accept = '.docx,application/vnd.openxmlformats-officedocument.wordprocessingml.document';
+    input.style.display = 'none';
+
+    input.addEventListener('change', async () => {
+        const file = input.files && input.files[0];
+        input.remove();
+        if (file) {
+            await window.ClipboardHandler.insertDocx(cm, file);
+        }
+    });
+
+    document.body.appendChild(input);
+    input.click();
+}
+
 function createToolbar(container) {
     const toolbar = document.createElement('div');
     toolbar.className = 'editor-toolbar custom-toolbar';
@@ -136,9 +155,9 @@ function createToolbar(container) {
 
     // Define toolbar buttons with dynamic shortcuts
     const buttons = [
-        { icon: 'fa-header', action: 'heading', title: `Heading (${getShortcut('Cmd+H', 'Ctrl+H')})` },
-        { icon: 'fa-bold', action: 'bold', title: `Bold (${getShortcut('Cmd+B', 'Ctrl+B')})` },
-        { icon: 'fa-italic', action: 'italic', title: `Italic (${getShortcut('Cmd+I', 'Ctrl+I')})` },
+        { icon: 'fa-header', action: 'heading', title: `Heading (${getShortcut('Cmd+H', 'Ctrl+H')})`, primary: true },
+        { icon: 'fa-bold', action: 'bold', title: `Bold (${getShortcut('Cmd+B', 'Ctrl+B')})`, primary: true },
+        { icon: 'fa-italic', action: 'italic', title: `Italic (${getShortcut('Cmd+I', 'Ctrl+I')})`, primary: true },
         { icon: 'fa-paint-brush', action: 'highlight', title: 'Highlight Text' },
         { icon: 'fa-strikethrough', action: 'strikethrough', title: 'Strikethrough' },
         { icon: 'fa-subscript', action: 'subscript', title: 'Add Subscript' },
@@ -146,14 +165,16 @@ function createToolbar(container) {
         { type: 'separator' },
         { icon: 'fa-code', action: 'code', title: `Code (${getShortcut('Cmd+/', 'Ctrl+/')})` },
         { icon: 'fa-quote-left', action: 'quote', title: `Quote (${getShortcut('Cmd+K', 'Ctrl+K')})` },
-        { icon: 'fa-list-ul', action: 'unordered-list', title: 'Unordered List' },
-        { icon: 'fa-list-ol', action: 'ordered-list', title: 'Ordered List' },
+        { icon: 'fa-list-ul', action: 'unordered-list', title: 'Unordered List', primary: true },
+        { icon: 'fa-list-ol', action: 'ordered-list', title: 'Ordered List', primary: true },
         { type: 'separator' },
-        { icon: 'fa-picture-o', action: 'image', title: 'Image' },
-        { icon: 'fa-link', action: 'link', title: 'Link' },
+        { icon: 'fa-picture-o', action: 'image', title: 'Image', primary: true },
+        { icon: 'fa-camera', action: 'camera', title: 'Take Photo', primary: true, touchOnly: true },
+        { icon: 'fa-link', action: 'link', title: 'Link', primary: true },
         { icon: 'fa-anchor', action: 'anchor-link', title: 'Link to Heading' },
         { icon: 'fa-file-text-o', action: 'doc-link', title: 'Link to Document' },
         { icon: 'fa-smile-o', action: 'emoji', title: 'Insert Emoji' },
+        { icon: 'fa-file-word-o', action: 'insert-docx', title: 'Insert Word Document' },
         { type: 'separator' },
         { icon: 'fa-th', action: 'table', title: 'Insert Custom Table', id: 'insert-table' },
         { icon: 'fa-align-center', action: 'format-table', title: 'Format Markdown Table' },
@@ -181,16 +202,35 @@ function createToolbar(container) {
         { icon: 'fa-book', action: 'total', title: 'Insert Total Number of Documents' },
         { icon: 'fa-tasks', action: 'kanban', title: 'Insert Kanban Board Template' },
         { type: 'separator' },
-        { icon: 'fa-undo', action: 'undo', title: 'Undo' },
-        { icon: 'fa-repeat', action: 'redo', title: 'Redo' },
+        { icon: 'fa-undo', action: 'undo', title: 'Undo', primary: true },
+        { icon: 'fa-repeat', action: 'redo', title: 'Redo', primary: true },
         { type: 'separator' },
-        { icon: 'fa-eye', action: 'preview', title: `Toggle Preview (${getShortcut('Cmd+Shift+P', 'Ctrl+Shift+P')})`, id: 'toggle-preview' }
+        { icon: 'fa-pencil-square-o', action: 'toggle-rich', title: 'Edit as Rich Text', id: 'toggle-rich', primary: true },
+        { icon: 'fa-columns', action: 'toggle-split', title: 'Side-by-Side Preview', id: 'toggle-split' },
+        { icon: 'fa-eye', action: 'preview', title: `Toggle Preview (${getShortcut('Cmd+Shift+P', 'Ctrl+Shift+P')})`, id: 'toggle-preview', primary: true },
+        { icon: 'fa-ellipsis-v', action: 'toggle-more', title: 'More Formatting', primary: true, touchOnly: true }
     ];
 
+    // Translations can be pre-filled by machine translation
+    if (canMachineTranslate()) {
+        const undo = buttons.findIndex(button => button.action === 'undo');
+        buttons.splice(undo, 0,
+            { icon: 'fa-language', action: 'machine-translate', title: 'Machine Translate from the Original' },
+            { type: 'separator' });
+    }
+
+    // Writing actions of the configured AI service
+    if (document.querySelector('meta[name="ai-writing"]')) {
+        const undo = buttons.findIndex(button => button.action === 'undo');
+        buttons.splice(undo, 0,
+            { icon: 'fa-magic', action: 'ai-assist', title: 'AI Writing (Summarize, Fix Grammar, Outline)' },
+            { type: 'separator' });
+    }
+
     buttons.forEach(button => {
         if (button.type === 'separator') {
             const separator = document.createElement('i');
-            separator.className = 'separator';
+            separator.className = 'separator toolbar-secondary';
             toolbar.appendChild(separator);
         } else {
             const btn = document.createElement('button');
@@ -198,6 +238,13 @@ function createToolbar(container) {
                 btn.id = button.id;
             }
             btn.className = `toolbar-button ${button.action}-button`;
+            // On touch devices only the primary buttons show until "more" is pressed
+            if (!button.primary) {
+                btn.classList.add('toolbar-secondary');
+            }
+            if (button.touchOnly) {
+                btn.classList.add('toolbar-touch-only');
+            }
             btn.dataset.action = button.action;
             btn.title = button.title;
 
@@ -227,6 +274,9 @@ function setupToolbarActions(toolbar) {
 
     // Executes the requested action.
     const exec = (action, button) => {
+        // Rich text mode runs the formatting actions on its own content
+        if (window.EditorRich.handleAction(action)) return;
+
         switch (action) {
             case 'bold':
                 window.EditorCore.wrapText(editor, '**', '**');
@@ -460,6 +510,9 @@ function setupToolbarActions(toolbar) {
 
                 window.EditorPreview.togglePreview();
                 break;
+            case 'toggle-split':
+                window.EditorPreview.toggleSplit();
+                break;
             case 'emoji':
                 window.EditorPickers.showEmojiPicker(button);
                 break;
@@ -500,6 +553,21 @@ function setupToolbarActions(toolbar) {
             case 'kanban':
                 insertKanbanFrontmatter(editor);
                 break;
+            case 'camera':
+                window.EditorMobile.captureImage(editor);
+                break;
+            case 'insert-docx':
+                insertWordDocument(editor);
+                break;
+            case 'machine-translate':
+                machineTranslate(editor);
+                break;
+            case 'ai-assist':
+                if (window.AIAssist) window.AIAssist.open(editor);
+                break;
+            case 'toggle-more':
+                window.EditorMobile.toggleMoreButtons(toolbar, button);
+                break;
             default:
                 break;
         }
@@ -8,11 +8,12 @@
  * - editor-pickers.js: Emoji, document, table, and anchor pickers
  * - editor-preview.js: Preview functionality
  * - editor-themes.js: Theme management and mobile handling
+ * - editor-merge.js: Merging changes saved by others while editing
  */
 
 // Ensure modules are loaded before using them
 function ensureModulesLoaded() {
-    const modules = ['EditorCore', 'EditorToolbar', 'EditorPickers', 'EditorPreview', 'EditorThemes'];
+    const modules = ['EditorCore', 'EditorToolbar', 'EditorPickers', 'EditorPreview', 'EditorThemes', 'EditorMerge'];
     for (const module of modules) {
         if (!window[module]) {
             console.warn(`Editor module ${module} not loaded yet`);
@@ -23,12 +24,12 @@ function ensureModulesLoaded() {
 }
 
 // Legacy compatibility functions - delegate to new modules
-function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar) {
+function loadEditor(mainContent, editorContainer, viewToolbar, editToolbar, sectionId) {
     if (!ensureModulesLoaded()) {
         console.error('Editor modules not loaded');
         return;
     }
-    return window.EditorCore.loadEditor(mainContent, editorContainer, viewToolbar, editToolbar);
+    return window.EditorCore.loadEditor(mainContent, editorContainer, viewToolbar, editToolbar, sectionId);
 }
 
 function exitEditMode(mainContent, editorContainer, viewToolbar, editToolbar) {
@@ -245,6 +246,78 @@ function hideUnsavedChangesDialog() {
     }
 }
 
+// Add an edit button to every heading of the page that starts a section of
+// its markdown, opening the editor on that section only
+async function addSectionButtons(markdownContent, editPageButton, startEditing) {
+    // Translations are edited as a whole
+    if (!markdownContent || getComputedStyle(editPageButton).display === 'none' || getEditLanguage()) return;
+
+    const isHomepage = window.location.pathname === '/';
+    let sections;
+    try {
+        const response = await fetch(isHomepage ? '/api/section/' : `/api/section${window.location.pathname}`);
+        if (!response.ok) return;
+        sections = (await response.json()).sections || [];
+    } catch (error) {
+        return;
+    }
+
+    const ids = new Set(sections.map(section => section.id));
+    const title = window.i18n ? window.i18n.t('editor.edit_section') : 'Edit this section';
+    markdownContent.querySelectorAll('h1[id], h2[id], h3[id], h4[id], h5[id], h6[id]').forEach(heading => {
+        // Headings of embedded pages belong to those pages
+        if (!ids.has(heading.id) || heading.closest('.wiki-embed')) return;
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'section-edit';
+        button.title = title;
+        button.setAttribute('aria-label', title);
+        button.innerHTML = '<i class="fa fa-pencil"></i>';
+        button.addEventListener('click', event => {
+            event.preventDefault();
+            startEditing(heading.id);
+        });
+        heading.appendChild(button);
+    });
+}
+
+// Save the section being edited into the page. It is refused when someone
+// changed the section meanwhile, so their changes aren't overwritten.
+async function saveSection(section, content, options) {
+    const t = (key, fallback) => window.i18n ? window.i18n.t(key) : fallback;
+    const isHomepage = window.location.pathname === '/';
+    const response = await fetch(isHomepage ? '/api/section/' : `/api/section${window.location.pathname}`, {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+            id: section.id,
+            hash: section.hash,
+            content: content,
+            summary: options.summary,
+            minor: options.minor
+        })
+    });
+    const data = await response.json().catch(() => ({}));
+
+    if (response.status === 422 || response.status === 409) {
+        // Style problems, or someone else changed the section
+        const message = response.status === 409
+            ? `${data.message}. ${t('editor.section_conflict', 'Copy your changes, then reload the page and edit the section again.')}`
+            : [data.message].concat((data.issues || []).map(issue => issue.message)).join('\n');
+        window.DialogSystem.showMessageDialog(t('editor.save_error', 'Error saving document'), message);
+        return;
+    }
+    if (!response.ok) throw new Error(data.message || 'Failed to save content');
+
+    window.EditorCore.setOriginalContent(content);
+
+    // Back to the section that was edited
+    history.replaceState(null, '', `#${section.id}`);
+    window.location.reload();
+}
+
 // Function to initialize edit controls
 function initializeEditControls() {
     const editPageButton = document.querySelector('.edit-page');
@@ -267,41 +340,45 @@ function initializeEditControls() {
         }
     }
 
-    // Update edit button functionality
-    if (editPageButton) {
-        editPageButton.addEventListener('click', async function() {
-            try {
-                // Check if user is authenticated
-                const authResponse = await fetch('/api/check-auth');
-                if (authResponse.status === 401) {
-                    // Show login dialog
-                    window.Auth.showLoginDialog(() => {
-                        // After login, check if user has editor or admin role
-                        window.Auth.checkUserRole('editor').then(canEdit => {
-                            if (canEdit) {
-                                loadEditor(mainContent, editorContainer, viewToolbar, editToolbar);
-                                // Update toolbar buttons after login
-                                window.Auth.updateToolbarButtons();
-                            } else {
-                                window.Auth.showPermissionError('editor');
-                            }
-                        });
+    // Open the editor on the whole page, or on the section under a heading
+    async function startEditing(sectionId) {
+        try {
+            // Check if user is authenticated
+            const authResponse = await fetch('/api/check-auth');
+            if (authResponse.status === 401) {
+                // Show login dialog
+                window.Auth.showLoginDialog(() => {
+                    // After login, check if user has editor or admin role
+                    window.Auth.checkUserRole('editor').then(canEdit => {
+                        if (canEdit) {
+                            loadEditor(mainContent, editorContainer, viewToolbar, editToolbar, sectionId);
+                            // Update toolbar buttons after login
+                            window.Auth.updateToolbarButtons();
+                        } else {
+                            window.Auth.showPermissionError('editor');
+                        }
                     });
-                    return;
-                }
+                });
+                return;
+            }
 
-                // User is authenticated, check if user has editor or admin role
-                const canEdit = await window.Auth.checkUserRole('editor');
-                if (canEdit) {
-                    loadEditor(mainContent, editorContainer, viewToolbar, editToolbar);
-                } else {
-                    window.Auth.showPermissionError('editor');
-                }
-            } catch (error) {
-                console.error('Error:', error);
-                alert('Failed to check authentication status');
+            // User is authenticated, check if user has editor or admin role
+            const canEdit = await window.Auth.checkUserRole('editor');
+            if (canEdit) {
+                loadEditor(mainContent, editorContainer, viewToolbar, editToolbar, sectionId);
+            } else {
+                window.Auth.showPermissionError('editor');
             }
-        });
+        } catch (error) {
+            console.error('Error:', error);
+            alert('Failed to check authentication status');
+        }
+    }
+
+    // Update edit button functionality
+    if (editPageButton) {
+        editPageButton.addEventListener('click', () => startEditing());
+        addSectionButtons(markdownContent, editPageButton, startEditing);
     }
 
     // Save button functionality
@@ -309,18 +386,56 @@ function initializeEditControls() {
         saveButton.addEventListener('click', async function() {
             try {
                 const isHomepage = window.location.pathname === '/';
-                const apiPath = isHomepage ? '/api/save/' : `/api/save${window.location.pathname}`;
+                // The summary and minor edit flag are kept with the edit
+                const options = window.EditorCore.getSaveOptions();
+                let query = saveLanguageQuery();
+                if (options.summary) query += (query ? '&' : '?') + 'summary=' + encodeURIComponent(options.summary);
+                if (options.minor) query += (query ? '&' : '?') + 'minor=1';
+                const apiPath = (isHomepage ? '/api/save/' : `/api/save${window.location.pathname}`) + query;
 
                 const content = getEditorContent();
 
+                const section = window.EditorCore.getEditSection();
+                if (section) {
+                    await saveSection(section, content, options);
+                    return;
+                }
+
+                // The revision the changes were made to, so changes saved by
+                // someone else meanwhile are merged instead of overwritten
+                const headers = { 'Content-Type': 'text/plain' };
+                const revision = window.EditorCore.getRevision();
+                if (revision) headers['X-Base-Revision'] = revision;
+
                 const response = await fetch(apiPath, {
                     method: 'POST',
-                    headers: {
-                        'Content-Type': 'text/plain',
-                    },
+                    headers: headers,
                     body: content
                 });
 
+                if (response.status === 409) {
+                    const conflict = await response.json();
+                    if (conflict.current !== undefined) {
+                        window.EditorMerge.resolve(window.EditorCore.getOriginalContent(), conflict, content);
+                        return;
+                    }
+                    window.DialogSystem.showMessageDialog(
+                        window.i18n ? window.i18n.t('editor.save_error') : 'Error saving document',
+                        conflict.message
+                    );
+                    return;
+                }
+
+                if (response.status === 422) {
+                    // Style problems must be fixed first
+                    const data = await response.json();
+                    window.EditorLint.showIssues(data.issues || [], true);
+                    window.DialogSystem.showMessageDialog(
+                        window.i18n ? window.i18n.t('editor.save_error') : 'Error saving document',
+                        data.message
+                    );
+                    return;
+                }
                 if (!response.ok) throw new Error('Failed to save content');
 
                 // Update originalContent to match what was just saved
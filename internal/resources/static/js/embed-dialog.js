@@ -0,0 +1,83 @@
+// Embed dialog: creates the frame code that shows the current page, or one of
+// its sections, on other sites
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.embed-dialog');
+    const button = document.querySelector('.embed-page');
+    if (!dialog || !button) return;
+
+    const form = document.getElementById('embedForm');
+    const sectionSelect = document.getElementById('embedSection');
+    const codeArea = document.getElementById('embedCode');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function openDialog() {
+        showError('');
+        codeArea.value = '';
+
+        // Offer the headings of the page as sections
+        sectionSelect.length = 1;
+        document.querySelectorAll('.markdown-content h1[id], .markdown-content h2[id], .markdown-content h3[id]').forEach(heading => {
+            const option = document.createElement('option');
+            option.value = heading.id;
+            option.textContent = heading.textContent.replace(/¶\s*$/, '').trim();
+            sectionSelect.appendChild(option);
+        });
+
+        dialog.classList.add('active');
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    async function createCode(e) {
+        e.preventDefault();
+        showError('');
+        try {
+            const resp = await fetch('/api/embed/token', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    path: decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, ''),
+                    section: sectionSelect.value,
+                    days: parseInt(document.getElementById('embedExpiry').value, 10)
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('embed.failed', 'Failed to create the embed code'));
+                return;
+            }
+            codeArea.value = data.html;
+            codeArea.select();
+        } catch (error) {
+            console.error('Error creating embed code:', error);
+            showError(t('embed.failed', 'Failed to create the embed code'));
+        }
+    }
+
+    function copyCode() {
+        if (!codeArea.value) return;
+        navigator.clipboard.writeText(codeArea.value).catch(() => {
+            codeArea.select();
+            document.execCommand('copy');
+        });
+    }
+
+    button.addEventListener('click', openDialog);
+    form.addEventListener('submit', createCode);
+    dialog.querySelector('.copy-embed-code').addEventListener('click', copyCode);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+})();
@@ -0,0 +1,44 @@
+/**
+ * Embed View
+ * Renders diagrams of a page shown in a frame on another site and tells the
+ * parent page how tall the content is, so the frame can be sized to fit
+ */
+
+(function() {
+    'use strict';
+
+    function reportHeight() {
+        if (window.parent === window) return;
+        window.parent.postMessage({
+            type: 'wiki-go:embed-height',
+            height: document.documentElement.scrollHeight
+        }, '*');
+    }
+
+    document.addEventListener('DOMContentLoaded', async function() {
+        const theme = document.documentElement.dataset.theme;
+
+        if (typeof mermaid !== 'undefined' && document.querySelector('.mermaid')) {
+            try {
+                mermaid.initialize(window.MermaidConfig.options(theme === 'dark', { startOnLoad: false }));
+                await window.MermaidConfig.ready();
+                await mermaid.run({ querySelector: '.mermaid' });
+            } catch (error) {
+                console.error('Failed to render Mermaid diagrams:', error);
+            }
+        }
+
+        if (window.MathJax && MathJax.startup && MathJax.startup.promise) {
+            try {
+                await MathJax.startup.promise;
+            } catch (error) {
+                console.error('MathJax failed:', error);
+            }
+        }
+
+        reportHeight();
+        if (typeof ResizeObserver !== 'undefined') {
+            new ResizeObserver(reportHeight).observe(document.body);
+        }
+    });
+})();
@@ -0,0 +1,113 @@
+/**
+ * Excalidraw editor
+ * Runs in the frame of the diagram dialog and talks to it with the messages of
+ * the draw.io embed protocol: "init" when ready, "load" with the scene, "save"
+ * with the scene and its SVG preview, and "exit".
+ */
+
+const EXCALIDRAW_VERSION = '0.17.6';
+const REACT_VERSION = '18.2.0';
+
+const root = document.getElementById('excalidraw-root');
+const cdn = root.dataset.cdn.replace(/\/+$/, '');
+
+// Fonts are loaded from the same CDN as the editor
+window.EXCALIDRAW_ASSET_PATH = cdn + '/@excalidraw/excalidraw@' + EXCALIDRAW_VERSION + '/dist/';
+
+function send(message) {
+    window.parent.postMessage(JSON.stringify(message), window.location.origin);
+}
+
+function showStatus(text) {
+    root.innerHTML = '';
+    const status = document.createElement('p');
+    status.className = 'excalidraw-status';
+    status.textContent = text;
+    root.appendChild(status);
+}
+
+async function loadModules() {
+    const deps = '?deps=react@' + REACT_VERSION + ',react-dom@' + REACT_VERSION;
+    const [react, reactDom, excalidraw] = await Promise.all([
+        import(cdn + '/react@' + REACT_VERSION),
+        import(cdn + '/react-dom@' + REACT_VERSION + '/client'),
+        import(cdn + '/@excalidraw/excalidraw@' + EXCALIDRAW_VERSION + deps)
+    ]);
+    return { React: react.default || react, ReactDOM: reactDom.default || reactDom, Excalidraw: excalidraw };
+}
+
+// Parses the scene of a file, an empty one for new drawings
+function parseScene(source) {
+    if (!source) return { elements: [], appState: {}, files: {} };
+    const scene = JSON.parse(source);
+    return {
+        elements: scene.elements || [],
+        appState: Object.assign({}, scene.appState, { collaborators: new Map() }),
+        files: scene.files || {}
+    };
+}
+
+function start(lib, scene, dark) {
+    const { React, ReactDOM, Excalidraw } = lib;
+    const h = React.createElement;
+    let api = null;
+
+    async function save(exit) {
+        if (!api) return;
+        const elements = api.getSceneElements();
+        const appState = api.getAppState();
+        const files = api.getFiles();
+        const svg = await Excalidraw.exportToSvg({
+            elements: elements,
+            appState: Object.assign({}, appState, { exportBackground: true }),
+            files: files
+        });
+        send({
+            event: 'save',
+            xml: Excalidraw.serializeAsJSON(elements, appState, files, 'local'),
+            svg: new XMLSerializer().serializeToString(svg),
+            exit: exit
+        });
+    }
+
+    function buttons() {
+        return h('div', { className: 'excalidraw-actions' },
+            h('button', { type: 'button', className: 'primary', onClick: () => save(false) }, root.dataset.save),
+            h('button', { type: 'button', onClick: () => save(true) }, root.dataset.exit),
+            h('button', { type: 'button', onClick: () => send({ event: 'exit' }) }, root.dataset.close));
+    }
+
+    root.innerHTML = '';
+    ReactDOM.createRoot(root).render(h(Excalidraw.Excalidraw, {
+        initialData: scene,
+        excalidrawAPI: instance => { api = instance; },
+        theme: dark ? 'dark' : 'light',
+        langCode: root.dataset.language || 'en',
+        renderTopRightUI: buttons
+    }));
+}
+
+const modules = loadModules();
+
+window.addEventListener('message', async function(event) {
+    if (event.source !== window.parent || event.origin !== window.location.origin) return;
+    let message;
+    try {
+        message = JSON.parse(event.data);
+    } catch (error) {
+        return;
+    }
+    if (message.action !== 'load') return;
+
+    try {
+        start(await modules, parseScene(message.xml), message.dark);
+    } catch (error) {
+        console.error('Failed to start Excalidraw:', error);
+        showStatus(root.dataset.failed);
+    }
+});
+
+modules.then(() => send({ event: 'init' })).catch(error => {
+    console.error('Failed to load Excalidraw from ' + cdn + ':', error);
+    showStatus(root.dataset.failed);
+});
@@ -0,0 +1,31 @@
+/**
+ * Export Menu
+ * Opens the list of formats the page can be downloaded in
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const menu = document.querySelector('.export-menu');
+        if (!menu) return;
+
+        const button = menu.querySelector('.export-button');
+        const dropdown = menu.querySelector('.export-dropdown');
+
+        button.addEventListener('click', function(e) {
+            e.stopPropagation();
+            dropdown.hidden = !dropdown.hidden;
+        });
+
+        dropdown.addEventListener('click', function() {
+            dropdown.hidden = true;
+        });
+
+        document.addEventListener('click', function(e) {
+            if (!menu.contains(e.target)) {
+                dropdown.hidden = true;
+            }
+        });
+    });
+})();
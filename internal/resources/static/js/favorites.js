@@ -0,0 +1,115 @@
+// Favorite and pin buttons: users star pages into the favorites list of the
+// sidebar, admins pin pages to the top of their directory for everybody
+(function() {
+    'use strict';
+
+    const favoriteButton = document.querySelector('.page-favorite');
+    const pinButton = document.querySelector('.page-pin');
+    const sidebarList = document.querySelector('.sidebar-favorites');
+    if (!favoriteButton && !pinButton) return;
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function currentPath() {
+        return '/' + decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function updateFavorite(favorite) {
+        if (!favoriteButton) return;
+        favoriteButton.setAttribute('aria-pressed', favorite ? 'true' : 'false');
+        favoriteButton.classList.toggle('active', favorite);
+        favoriteButton.querySelector('.fa').className = 'fa ' + (favorite ? 'fa-star' : 'fa-star-o');
+        favoriteButton.querySelector('.button-text').textContent = favorite ? t('favorites.remove', 'Unstar') : t('favorites.button', 'Star');
+        favoriteButton.title = favorite ? t('favorites.remove_tooltip', 'Remove this page from your favorites') : t('favorites.tooltip', 'Add this page to your favorites');
+    }
+
+    function updatePin(pinned) {
+        if (!pinButton) return;
+        pinButton.setAttribute('aria-pressed', pinned ? 'true' : 'false');
+        pinButton.classList.toggle('active', pinned);
+        pinButton.querySelector('.button-text').textContent = pinned ? t('favorites.unpin', 'Unpin') : t('favorites.pin', 'Pin');
+        pinButton.title = pinned ? t('favorites.unpin_tooltip', 'Stop listing this page first') : t('favorites.pin_tooltip', 'List this page first in its directory, for everybody');
+    }
+
+    // renderSidebar lists the favorites in the sidebar, hidden when there are none
+    function renderSidebar(favorites) {
+        if (!sidebarList) return;
+        const list = sidebarList.querySelector('.sidebar-favorites-list');
+        list.innerHTML = '';
+        favorites.forEach(page => {
+            const item = document.createElement('div');
+            item.className = 'nav-item' + (page.path === currentPath() ? ' active' : '');
+            item.dataset.path = page.path;
+            const link = document.createElement('a');
+            link.href = page.path;
+            link.textContent = page.title;
+            item.appendChild(link);
+            list.appendChild(item);
+        });
+        sidebarList.hidden = favorites.length === 0;
+    }
+
+    function show(data) {
+        const path = currentPath();
+        updateFavorite(data.favorites.some(page => page.path === path));
+        updatePin(data.pinned.includes(path));
+    }
+
+    async function request(url, body) {
+        const options = body ? {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        } : undefined;
+        const resp = await fetch(url, options);
+        const data = await resp.json();
+        if (!resp.ok || !data.success) throw new Error(data.error || data.message || resp.statusText);
+        return data;
+    }
+
+    function failed(error) {
+        console.error('Error changing the favorites:', error);
+        if (window.DialogSystem) {
+            window.DialogSystem.showMessageDialog(t('favorites.title', 'Favorites'), t('favorites.failed', 'Failed to change the favorites') + ': ' + error.message);
+        }
+    }
+
+    async function toggleFavorite() {
+        const favorite = favoriteButton.getAttribute('aria-pressed') !== 'true';
+        favoriteButton.disabled = true;
+        try {
+            const data = await request('/api/favorites', { path: currentPath(), favorite: favorite });
+            show(data);
+            renderSidebar(data.favorites);
+            if (window.CommandPalette) {
+                window.CommandPalette.notify(favorite ? t('favorites.added', 'Page added to your favorites') : t('favorites.removed', 'Page removed from your favorites'));
+            }
+        } catch (error) {
+            failed(error);
+        } finally {
+            favoriteButton.disabled = false;
+        }
+    }
+
+    async function togglePin() {
+        const pinned = pinButton.getAttribute('aria-pressed') !== 'true';
+        pinButton.disabled = true;
+        try {
+            const data = await request('/api/pins', { path: currentPath(), pinned: pinned });
+            updatePin(data.pinned);
+            if (window.CommandPalette) {
+                window.CommandPalette.notify(pinned ? t('favorites.pinned', 'Page pinned to the top of its directory') : t('favorites.unpinned', 'Page unpinned'));
+            }
+        } catch (error) {
+            failed(error);
+        } finally {
+            pinButton.disabled = false;
+        }
+    }
+
+    if (favoriteButton) favoriteButton.addEventListener('click', toggleFavorite);
+    if (pinButton) pinButton.addEventListener('click', togglePin);
+    request('/api/favorites').then(show).catch(error => console.error('Error loading the favorites:', error));
+})();
@@ -43,6 +43,8 @@ function getFileIcon(fileType) {
         icon = '<i class="fa fa-file-powerpoint-o"></i>';
     } else if (fileType === 'video/mp4' || fileType === 'video/quicktime' || fileType === 'video/webm' || fileType === 'video/avi') {
         icon = '<i class="fa fa-file-video-o"></i>';
+    } else if (fileType.startsWith('audio/')) {
+        icon = '<i class="fa fa-file-audio-o"></i>';
     } else {
         icon = '<i class="fa fa-file-o"></i>';
     }
@@ -129,29 +131,33 @@ function renderFilesList(files, mentionedFiles) {
         }
 
         return `
-            <div class="file-item${highlightClass}" data-file-url="${safeFile.URL}">
+            <div class="file-item${highlightClass}" data-file-url="${safeFile.URL}" role="listitem">
                 <div class="file-info">
                     <div class="file-icon">${getFileIcon(safeFile.Type)}</div>
                     <div class="file-name" data-path="${filePath}" data-current-name="${safeFile.Name}">
                         <span class="name-text">${safeFile.Name}</span>
-                        <input type="text" class="name-edit" value="${safeFile.Name}" style="display: none;">
+                        <input type="text" class="name-edit" value="${safeFile.Name}" aria-label="${window.i18n ? window.i18n.t('common.rename') : 'Rename'} ${safeFile.Name}" style="display: none;">
                     </div>
                     <div class="file-size">${formatFileSize(safeFile.Size)}</div>
                 </div>
                 <div class="file-actions">
-                    <button class="insert-file-btn" title="${window.i18n ? window.i18n.t('common.insert') : 'Insert into editor'}" data-url="${safeFile.URL}" data-is-image="${isImage}" data-name="${safeFile.Name}" data-i18n-title="common.insert">
+                    <button class="insert-file-btn" aria-label="${window.i18n ? window.i18n.t('common.insert') : 'Insert'} ${safeFile.Name}" title="${window.i18n ? window.i18n.t('common.insert') : 'Insert into editor'}" data-url="${safeFile.URL}" data-is-image="${isImage}" data-name="${safeFile.Name}" data-i18n-title="common.insert">
                         <i class="fa fa-plus"></i>
                         <span data-i18n="common.insert">${window.i18n ? window.i18n.t('common.insert') : 'Insert'}</span>
                     </button>
-                    <button class="view-file-btn" title="${window.i18n ? window.i18n.t('common.view') : 'View file'}" data-url="${safeFile.URL}" data-i18n-title="common.view">
+                    <button class="view-file-btn" aria-label="${window.i18n ? window.i18n.t('common.view') : 'View'} ${safeFile.Name}" title="${window.i18n ? window.i18n.t('common.view') : 'View file'}" data-url="${safeFile.URL}" data-i18n-title="common.view">
                         <i class="fa fa-eye"></i>
                         <span data-i18n="common.view">${window.i18n ? window.i18n.t('common.view') : 'View'}</span>
                     </button>
-                    <button class="rename-file-btn" title="${window.i18n ? window.i18n.t('common.rename') : 'Rename file'}" data-path="${filePath}" data-name="${safeFile.Name}" data-i18n-title="common.rename">
+                    ${isImage && window.ImageEditor && window.ImageEditor.canEdit(safeFile.Name) ? `<button class="annotate-file-btn" aria-label="${window.i18n ? window.i18n.t('image_editor.annotate') : 'Annotate'} ${safeFile.Name}" title="${window.i18n ? window.i18n.t('image_editor.annotate_tooltip') : 'Crop and annotate a copy of the image'}" data-url="${safeFile.URL}" data-name="${safeFile.Name}" data-i18n-title="image_editor.annotate_tooltip">
+                        <i class="fa fa-paint-brush"></i>
+                        <span data-i18n="image_editor.annotate">${window.i18n ? window.i18n.t('image_editor.annotate') : 'Annotate'}</span>
+                    </button>` : ''}
+                    <button class="rename-file-btn" aria-label="${window.i18n ? window.i18n.t('common.rename') : 'Rename'} ${safeFile.Name}" title="${window.i18n ? window.i18n.t('common.rename') : 'Rename file'}" data-path="${filePath}" data-name="${safeFile.Name}" data-i18n-title="common.rename">
                         <i class="fa fa-pencil"></i>
                         <span data-i18n="common.rename">${window.i18n ? window.i18n.t('common.rename') : 'Rename'}</span>
                     </button>
-                    <button class="delete-file-btn" title="${window.i18n ? window.i18n.t('common.delete') : 'Delete file'}" data-path="${filePath}" data-i18n-title="common.delete">
+                    <button class="delete-file-btn" aria-label="${window.i18n ? window.i18n.t('common.delete') : 'Delete'} ${safeFile.Name}" title="${window.i18n ? window.i18n.t('common.delete') : 'Delete file'}" data-path="${filePath}" data-i18n-title="common.delete">
                         <i class="fa fa-trash"></i>
                         <span data-i18n="common.delete">${window.i18n ? window.i18n.t('common.delete') : 'Delete'}</span>
                     </button>
@@ -161,6 +167,7 @@ function renderFilesList(files, mentionedFiles) {
     }).join('');
 
     filesList.innerHTML = html;
+    filesList.setAttribute('role', 'list');
 
     // Add event listeners for file actions
     filesList.querySelectorAll('.delete-file-btn').forEach(button => {
@@ -232,11 +239,15 @@ function renderFilesList(files, mentionedFiles) {
                 // Hide input, show text
                 input.style.display = 'none';
                 input.closest('.file-name').querySelector('.name-text').style.display = 'block';
+                focusRenameButton(input);
             } else if (e.key === 'Escape') {
-                // Cancel on Escape
+                // Cancel on Escape, without closing the dialog
+                e.preventDefault();
+                e.stopPropagation();
                 input.value = input.closest('.file-name').getAttribute('data-current-name');
                 input.style.display = 'none';
                 input.closest('.file-name').querySelector('.name-text').style.display = 'block';
+                focusRenameButton(input);
             }
         });
 
@@ -252,6 +263,13 @@ function renderFilesList(files, mentionedFiles) {
         });
     });
 
+    // Add event listeners for annotate buttons
+    filesList.querySelectorAll('.annotate-file-btn').forEach(button => {
+        button.addEventListener('click', () => {
+            window.ImageEditor.open(button.getAttribute('data-url'), button.getAttribute('data-name'));
+        });
+    });
+
     // Add event listeners for insert buttons
     filesList.querySelectorAll('.insert-file-btn').forEach(button => {
         button.addEventListener('click', () => {
@@ -327,6 +345,14 @@ async function loadDocumentFiles() {
 }
 
 // Helper function to update file attachments section
+// Give the focus back to the rename button of the file being renamed, if the
+// list wasn't reloaded meanwhile
+function focusRenameButton(input) {
+    const item = input.closest('.file-item');
+    const button = item && document.contains(item) ? item.querySelector('.rename-file-btn') : null;
+    if (button) button.focus();
+}
+
 function updateFileAttachments(files) {
     const fileAttachmentsSection = document.querySelector('.file-attachments-section');
     const fileAttachmentsList = document.querySelector('.file-attachments-list');
@@ -0,0 +1,82 @@
+/**
+ * Structured Forms
+ * Submits :::form id::: shortcodes to the forms API and redirects to the created page
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function() {
+        document.querySelectorAll('form.wiki-form').forEach(initForm);
+    });
+
+    /**
+     * Attach the submit handler to a rendered form
+     */
+    function initForm(form) {
+        form.addEventListener('submit', async function(e) {
+            e.preventDefault();
+
+            const formId = form.dataset.formId;
+            const status = form.querySelector('.wiki-form-status');
+            const button = form.querySelector('button[type="submit"]');
+
+            clearErrors(form);
+
+            // Collect values, checkboxes are sent as "true"/"false"
+            const values = {};
+            form.querySelectorAll('input[name], textarea[name], select[name]').forEach(el => {
+                values[el.name] = el.type === 'checkbox' ? String(el.checked) : el.value;
+            });
+
+            if (button) button.disabled = true;
+
+            try {
+                const response = await fetch(`/api/forms/${encodeURIComponent(formId)}/submit`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify(values)
+                });
+                const data = await response.json();
+
+                if (response.ok && data.success) {
+                    window.location.href = data.url;
+                    return;
+                }
+
+                if (data.errors) {
+                    showErrors(form, data.errors);
+                }
+                if (status) status.textContent = data.message || 'Submission failed';
+            } catch (error) {
+                console.error('Error submitting form:', error);
+                if (status) status.textContent = 'Submission failed';
+            } finally {
+                if (button) button.disabled = false;
+            }
+        });
+    }
+
+    /**
+     * Display per-field validation errors returned by the server
+     */
+    function showErrors(form, errors) {
+        Object.keys(errors).forEach(name => {
+            const group = form.querySelector(`.form-group[data-field="${CSS.escape(name)}"]`);
+            if (!group) return;
+            group.classList.add('has-error');
+            const target = group.querySelector('.field-error');
+            if (target) target.textContent = errors[name];
+        });
+    }
+
+    /**
+     * Reset validation state before a new submission
+     */
+    function clearErrors(form) {
+        form.querySelectorAll('.form-group.has-error').forEach(group => group.classList.remove('has-error'));
+        form.querySelectorAll('.field-error').forEach(el => el.textContent = '');
+        const status = form.querySelector('.wiki-form-status');
+        if (status) status.textContent = '';
+    }
+})();
@@ -0,0 +1,392 @@
+/**
+ * Gantt and timeline blocks
+ * Draws the tasks of ```gantt and ```timeline blocks, scheduled by the server,
+ * as an SVG chart: bars with their progress, milestones, arrows from the
+ * tasks they depend on and a line on today. Charts can be zoomed and saved
+ * as PNG images.
+ */
+
+(function() {
+    'use strict';
+
+    const svgNS = 'http://www.w3.org/2000/svg';
+    const dayMs = 86400000;
+    const rowHeight = 30;
+    const headerHeight = 46;
+    const labelWidth = 200;
+    const minDayWidth = 1.5;
+    const maxDayWidth = 60;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // Decodes the chart of a block, base64url encoded JSON
+    function decode(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const binary = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+        const bytes = Uint8Array.from(binary, char => char.charCodeAt(0));
+        return JSON.parse(new TextDecoder().decode(bytes));
+    }
+
+    // Days since 1970 of a YYYY-MM-DD date
+    function day(date) {
+        const [year, month, dayOfMonth] = date.split('-').map(Number);
+        return Date.UTC(year, month - 1, dayOfMonth) / dayMs;
+    }
+
+    function today() {
+        const now = new Date();
+        return Date.UTC(now.getFullYear(), now.getMonth(), now.getDate()) / dayMs;
+    }
+
+    function formatter(options) {
+        return new Intl.DateTimeFormat(document.documentElement.lang || undefined, Object.assign({ timeZone: 'UTC' }, options));
+    }
+
+    function element(name, attributes, parent) {
+        const node = document.createElementNS(svgNS, name);
+        Object.entries(attributes || {}).forEach(([key, value]) => node.setAttribute(key, value));
+        if (parent) parent.appendChild(node);
+        return node;
+    }
+
+    class GanttChart {
+        constructor(container, chart) {
+            this.container = container;
+            this.chart = chart;
+            this.tasks = chart.tasks.map(task => Object.assign({}, task, { from: day(task.start), to: day(task.end) }));
+            this.first = Math.min(...this.tasks.map(task => task.from)) - 1;
+            this.last = Math.max(...this.tasks.map(task => task.to)) + 1;
+            this.days = this.last - this.first;
+
+            this.rows = [];
+            let group = null;
+            this.tasks.forEach(task => {
+                if (task.group && task.group !== group) {
+                    this.rows.push({ group: task.group });
+                }
+                group = task.group || group;
+                this.rows.push({ task: task });
+                task.row = this.rows.length - 1;
+            });
+
+            this.build();
+            this.dayWidth = this.fitWidth();
+            this.render();
+        }
+
+        build() {
+            const toolbar = document.createElement('div');
+            toolbar.className = 'gantt-toolbar';
+            if (this.chart.title) {
+                const title = document.createElement('span');
+                title.className = 'gantt-title';
+                title.textContent = this.chart.title;
+                toolbar.appendChild(title);
+            }
+            [
+                ['fa-search-plus', t('gantt.zoom_in', 'Zoom in'), () => this.zoom(1.5)],
+                ['fa-search-minus', t('gantt.zoom_out', 'Zoom out'), () => this.zoom(1 / 1.5)],
+                ['fa-arrows-h', t('gantt.fit', 'Fit to width'), () => this.zoom(this.fitWidth() / this.dayWidth)],
+                ['fa-download', t('gantt.export_png', 'Save as PNG'), () => this.exportPNG()]
+            ].forEach(([icon, label, action]) => {
+                const button = document.createElement('button');
+                button.type = 'button';
+                button.className = 'gantt-button';
+                button.title = label;
+                button.setAttribute('aria-label', label);
+                button.innerHTML = '<i class="fa ' + icon + '"></i>';
+                button.addEventListener('click', action);
+                toolbar.appendChild(button);
+            });
+
+            this.scroller = document.createElement('div');
+            this.scroller.className = 'gantt-scroller';
+            this.container.insertBefore(this.scroller, this.container.firstChild);
+            this.container.insertBefore(toolbar, this.scroller);
+            this.container.classList.add('gantt-ready');
+        }
+
+        fitWidth() {
+            const width = (this.container.clientWidth || 800) - labelWidth - 2;
+            return Math.max(minDayWidth, Math.min(maxDayWidth, width / this.days));
+        }
+
+        zoom(factor) {
+            this.dayWidth = Math.max(minDayWidth, Math.min(maxDayWidth, this.dayWidth * factor));
+            this.render();
+        }
+
+        x(dayNumber) {
+            return labelWidth + (dayNumber - this.first) * this.dayWidth;
+        }
+
+        // Colors are written on the elements rather than in style sheets, so
+        // saved images look like the page
+        colors() {
+            const style = getComputedStyle(this.container);
+            const read = (name, fallback) => style.getPropertyValue(name).trim() || fallback;
+            return {
+                text: style.color || '#333',
+                muted: read('--gantt-muted', '#888'),
+                grid: read('--gantt-grid', 'rgba(128, 128, 128, 0.25)'),
+                bar: read('--gantt-bar', '#4a90d9'),
+                done: read('--gantt-done', '#2a6aad'),
+                milestone: read('--gantt-milestone', '#d9822b'),
+                arrow: read('--gantt-arrow', '#999'),
+                today: read('--gantt-today', '#d33'),
+                group: read('--gantt-group', 'rgba(128, 128, 128, 0.12)'),
+                background: getComputedStyle(document.body).backgroundColor || '#fff'
+            };
+        }
+
+        render() {
+            const colors = this.colors();
+            const width = Math.ceil(this.x(this.last)) + 1;
+            const height = headerHeight + this.rows.length * rowHeight + 1;
+            const svg = element('svg', {
+                xmlns: svgNS,
+                width: width,
+                height: height,
+                viewBox: '0 0 ' + width + ' ' + height,
+                class: 'gantt-svg',
+                'font-family': getComputedStyle(this.container).fontFamily,
+                'font-size': 12
+            });
+            const titleText = this.chart.title || t('gantt.chart', 'Gantt chart');
+            element('title', {}, svg).textContent = titleText;
+
+            this.renderAxis(svg, colors, width, height);
+            this.renderRows(svg, colors, width);
+            this.renderArrows(svg, colors);
+            this.renderToday(svg, colors, height);
+
+            this.scroller.textContent = '';
+            this.scroller.appendChild(svg);
+            this.svg = svg;
+        }
+
+        renderAxis(svg, colors, width, height) {
+            const axis = element('g', { class: 'gantt-axis' }, svg);
+            const months = formatter({ month: 'short', year: 'numeric' });
+            const years = formatter({ year: 'numeric' });
+            const dayLabel = formatter({ day: 'numeric' });
+            const weekLabel = formatter({ day: 'numeric', month: 'short' });
+
+            element('line', { x1: 0, x2: width, y1: headerHeight - 0.5, y2: headerHeight - 0.5, stroke: colors.grid }, axis);
+
+            for (let d = this.first; d <= this.last; d++) {
+                const date = new Date(d * dayMs);
+                const x = this.x(d);
+                const firstOfMonth = date.getUTCDate() === 1;
+                const firstOfYear = firstOfMonth && date.getUTCMonth() === 0;
+
+                // Top row: months, or years when zoomed far out
+                const monthly = this.dayWidth >= 3;
+                if ((monthly && (firstOfMonth || d === this.first)) || (!monthly && (firstOfYear || d === this.first))) {
+                    element('line', { x1: x, x2: x, y1: 0, y2: height, stroke: colors.grid }, axis);
+                    const label = element('text', { x: x + 4, y: 16, fill: colors.text, 'font-weight': 'bold' }, axis);
+                    label.textContent = (monthly ? months : years).format(date);
+                }
+
+                // Bottom row: days, Mondays or months
+                let tick = null;
+                if (this.dayWidth >= 18) {
+                    tick = dayLabel.format(date);
+                } else if (this.dayWidth >= 4) {
+                    tick = date.getUTCDay() === 1 ? weekLabel.format(date) : null;
+                } else if (!monthly && firstOfMonth) {
+                    tick = formatter({ month: 'narrow' }).format(date);
+                }
+                if (tick !== null) {
+                    element('line', { x1: x, x2: x, y1: headerHeight - 8, y2: headerHeight, stroke: colors.muted }, axis);
+                    const label = element('text', { x: x + 3, y: headerHeight - 10, fill: colors.muted, 'font-size': 11 }, axis);
+                    label.textContent = tick;
+                }
+                // Weekends are shaded when days are wide enough to see them
+                if (this.dayWidth >= 8 && (date.getUTCDay() === 0 || date.getUTCDay() === 6) && d < this.last) {
+                    element('rect', { x: x, y: headerHeight, width: this.dayWidth, height: height - headerHeight, fill: colors.group, opacity: 0.5 }, axis);
+                }
+            }
+        }
+
+        renderRows(svg, colors, width) {
+            const rows = element('g', { class: 'gantt-rows' }, svg);
+            const dates = formatter({ day: 'numeric', month: 'short', year: 'numeric' });
+
+            this.rows.forEach((row, index) => {
+                const y = headerHeight + index * rowHeight;
+                if (row.group) {
+                    element('rect', { x: 0, y: y, width: width, height: rowHeight, fill: colors.group }, rows);
+                    const label = element('text', { x: 8, y: y + rowHeight / 2 + 4, fill: colors.text, 'font-weight': 'bold' }, rows);
+                    label.textContent = row.group;
+                    return;
+                }
+
+                const task = row.task;
+                const g = element('g', { class: 'gantt-task', tabindex: 0, 'data-id': task.id }, rows);
+                element('line', { x1: 0, x2: width, y1: y + rowHeight - 0.5, y2: y + rowHeight - 0.5, stroke: colors.grid }, g);
+
+                const name = element('text', { x: task.group ? 18 : 8, y: y + rowHeight / 2 + 4, fill: colors.text }, g);
+                name.textContent = task.name.length > 28 ? task.name.slice(0, 27) + '…' : task.name;
+
+                const tooltip = task.milestone
+                    ? task.name + '\n' + dates.format(new Date(task.from * dayMs))
+                    : task.name + '\n' + dates.format(new Date(task.from * dayMs)) + ' – ' + dates.format(new Date((task.to - 1) * dayMs)) +
+                        ' (' + (task.to - task.from) + ' ' + t('gantt.days', 'days') + ')' +
+                        (task.progress ? '\n' + task.progress + '% ' + t('gantt.done', 'done') : '');
+                element('title', {}, g).textContent = tooltip;
+
+                const middle = y + rowHeight / 2;
+                if (task.milestone) {
+                    const x = this.x(task.from);
+                    const size = 7;
+                    element('path', {
+                        d: 'M' + x + ' ' + (middle - size) + 'L' + (x + size) + ' ' + middle + 'L' + x + ' ' + (middle + size) + 'L' + (x - size) + ' ' + middle + 'Z',
+                        fill: colors.milestone,
+                        class: 'gantt-bar'
+                    }, g);
+                    return;
+                }
+
+                const x = this.x(task.from);
+                const barWidth = Math.max(2, (task.to - task.from) * this.dayWidth);
+                element('rect', { x: x, y: y + 7, width: barWidth, height: rowHeight - 14, rx: 3, fill: colors.bar, class: 'gantt-bar' }, g);
+                if (task.progress) {
+                    element('rect', { x: x, y: y + 7, width: barWidth * task.progress / 100, height: rowHeight - 14, rx: 3, fill: colors.done }, g);
+                }
+            });
+
+            // Hovering or focusing a task highlights it and the tasks it depends on
+            rows.querySelectorAll('.gantt-task').forEach(g => {
+                const highlight = on => {
+                    const task = this.tasks.find(item => item.id === g.dataset.id);
+                    const ids = [task.id].concat(task.depends || []);
+                    rows.querySelectorAll('.gantt-task').forEach(other => {
+                        other.classList.toggle('gantt-highlight', on && ids.includes(other.dataset.id));
+                    });
+                    svg.querySelectorAll('.gantt-arrow').forEach(arrow => {
+                        arrow.classList.toggle('gantt-highlight', on && arrow.dataset.to === task.id);
+                    });
+                };
+                g.addEventListener('mouseenter', () => highlight(true));
+                g.addEventListener('mouseleave', () => highlight(false));
+                g.addEventListener('focus', () => highlight(true));
+                g.addEventListener('blur', () => highlight(false));
+            });
+        }
+
+        renderArrows(svg, colors) {
+            const arrows = element('g', { class: 'gantt-arrows' }, svg);
+            const defs = element('defs', {}, svg);
+            const marker = element('marker', { id: 'gantt-arrowhead-' + this.id(), markerWidth: 6, markerHeight: 6, refX: 5, refY: 3, orient: 'auto' }, defs);
+            element('path', { d: 'M0 0L6 3L0 6Z', fill: colors.arrow }, marker);
+
+            this.tasks.forEach(task => {
+                (task.depends || []).forEach(id => {
+                    const from = this.tasks.find(item => item.id === id);
+                    if (!from) return;
+                    const x1 = this.x(from.to) + (from.milestone ? 7 : 0);
+                    const y1 = headerHeight + from.row * rowHeight + rowHeight / 2;
+                    const x2 = this.x(task.from) - (task.milestone ? 7 : 0);
+                    const y2 = headerHeight + task.row * rowHeight + rowHeight / 2;
+                    // Arrows to tasks starting before the end of the one they
+                    // depend on go back along the line between the rows
+                    const d = x2 - x1 >= 12
+                        ? 'M' + x1 + ' ' + y1 + 'H' + (x1 + 6) + 'V' + y2 + 'H' + (x2 - 1)
+                        : 'M' + x1 + ' ' + y1 + 'H' + (x1 + 6) + 'V' + (y2 - rowHeight / 2) + 'H' + (x2 - 8) + 'V' + y2 + 'H' + (x2 - 1);
+                    const path = element('path', {
+                        d: d,
+                        fill: 'none',
+                        stroke: colors.arrow,
+                        'stroke-width': 1.2,
+                        'marker-end': 'url(#gantt-arrowhead-' + this.id() + ')',
+                        class: 'gantt-arrow'
+                    }, arrows);
+                    path.dataset.to = task.id;
+                });
+            });
+        }
+
+        renderToday(svg, colors, height) {
+            const now = today();
+            if (now < this.first || now > this.last) return;
+            const x = this.x(now) + this.dayWidth / 2;
+            const g = element('g', { class: 'gantt-today' }, svg);
+            element('line', { x1: x, x2: x, y1: headerHeight - 4, y2: height, stroke: colors.today, 'stroke-width': 1.5, 'stroke-dasharray': '4 3' }, g);
+            const label = element('text', { x: x + 3, y: height - 4, fill: colors.today, 'font-size': 11 }, g);
+            label.textContent = t('gantt.today', 'Today');
+        }
+
+        // A number telling the charts of the page apart, for the ids of markers
+        id() {
+            if (!this.container.dataset.ganttId) {
+                this.container.dataset.ganttId = String(++GanttChart.count);
+            }
+            return this.container.dataset.ganttId;
+        }
+
+        exportPNG() {
+            const svg = this.svg.cloneNode(true);
+            const width = Number(svg.getAttribute('width'));
+            const height = Number(svg.getAttribute('height'));
+            const background = element('rect', { x: 0, y: 0, width: width, height: height, fill: this.colors().background });
+            svg.insertBefore(background, svg.firstChild);
+
+            const source = new XMLSerializer().serializeToString(svg);
+            const url = URL.createObjectURL(new Blob([source], { type: 'image/svg+xml;charset=utf-8' }));
+            const image = new Image();
+            image.onload = () => {
+                const scale = Math.max(2, window.devicePixelRatio || 1);
+                const canvas = document.createElement('canvas');
+                canvas.width = width * scale;
+                canvas.height = height * scale;
+                const context = canvas.getContext('2d');
+                context.scale(scale, scale);
+                context.drawImage(image, 0, 0);
+                URL.revokeObjectURL(url);
+                canvas.toBlob(blob => {
+                    const link = document.createElement('a');
+                    link.href = URL.createObjectURL(blob);
+                    link.download = (this.chart.title || 'gantt').replace(/[^\w.-]+/g, '-').replace(/^-+|-+$/g, '') + '.png';
+                    document.body.appendChild(link);
+                    link.click();
+                    link.remove();
+                    setTimeout(() => URL.revokeObjectURL(link.href), 1000);
+                }, 'image/png');
+            };
+            image.onerror = () => {
+                URL.revokeObjectURL(url);
+                console.error('Failed to export the gantt chart');
+            };
+            image.src = url;
+        }
+    }
+    GanttChart.count = 0;
+
+    function init(root) {
+        (root || document).querySelectorAll('.gantt-chart[data-gantt]').forEach(container => {
+            if (container.classList.contains('gantt-ready')) return;
+            try {
+                const chart = decode(container.dataset.gantt);
+                new GanttChart(container, chart);
+                const fallback = container.querySelector('.gantt-fallback');
+                if (fallback) fallback.hidden = true;
+            } catch (error) {
+                console.error('Failed to draw gantt chart:', error);
+            }
+        });
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', () => init(document));
+    } else {
+        init(document);
+    }
+
+    window.GanttCharts = {
+        render: init
+    };
+})();
@@ -0,0 +1,611 @@
+/**
+ * Map and GeoJSON blocks
+ * Draws the maps of ```map and ```geojson blocks: XYZ tiles of the configured
+ * server under the markers, lines and areas of the block and of its attached
+ * GeoJSON files. Maps can be dragged, zoomed with the buttons, the keyboard,
+ * a double click or, once focused, the mouse wheel.
+ */
+
+(function() {
+    'use strict';
+
+    const script = document.currentScript;
+    const tileSize = 256;
+    const defaultHeight = 360;
+    const subdomains = ['a', 'b', 'c'];
+    const svgNS = 'http://www.w3.org/2000/svg';
+
+    let settings = { tileUrl: '', attribution: '', maxZoom: 19 };
+    try {
+        settings = Object.assign(settings, JSON.parse(script.dataset.config || '{}'));
+    } catch (error) {
+        console.error('Invalid map configuration:', error);
+    }
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // Decodes the settings of a block, base64url encoded JSON
+    function decode(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const binary = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+        const bytes = Uint8Array.from(binary, char => char.charCodeAt(0));
+        return JSON.parse(new TextDecoder().decode(bytes));
+    }
+
+    // Web Mercator: the position of a longitude and latitude in pixels of the
+    // world at a zoom level
+    function project(lon, lat, zoom) {
+        const size = tileSize * Math.pow(2, zoom);
+        const clamped = Math.max(-85.05112878, Math.min(85.05112878, lat));
+        const sin = Math.sin(clamped * Math.PI / 180);
+        return {
+            x: (lon + 180) / 360 * size,
+            y: (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * size
+        };
+    }
+
+    function unproject(x, y, zoom) {
+        const size = tileSize * Math.pow(2, zoom);
+        const n = Math.PI - 2 * Math.PI * y / size;
+        return {
+            lon: x / size * 360 - 180,
+            lat: 180 / Math.PI * Math.atan(0.5 * (Math.exp(n) - Math.exp(-n)))
+        };
+    }
+
+    // Collects the features of a GeoJSON object: feature collections,
+    // features and bare geometries
+    function features(geojson) {
+        if (!geojson || typeof geojson !== 'object') return [];
+        if (geojson.type === 'FeatureCollection') {
+            return (geojson.features || []).flatMap(features);
+        }
+        if (geojson.type === 'Feature') {
+            return geojson.geometry ? [{ geometry: geojson.geometry, properties: geojson.properties || {} }] : [];
+        }
+        return geojson.type ? [{ geometry: geojson, properties: {} }] : [];
+    }
+
+    // Splits a geometry into points, lines and polygons
+    function parts(geometry, out) {
+        const coordinates = geometry.coordinates;
+        switch (geometry.type) {
+            case 'Point':
+                out.points.push(coordinates);
+                break;
+            case 'MultiPoint':
+                out.points.push(...coordinates);
+                break;
+            case 'LineString':
+                out.lines.push(coordinates);
+                break;
+            case 'MultiLineString':
+                out.lines.push(...coordinates);
+                break;
+            case 'Polygon':
+                out.polygons.push(coordinates);
+                break;
+            case 'MultiPolygon':
+                out.polygons.push(...coordinates);
+                break;
+            case 'GeometryCollection':
+                (geometry.geometries || []).forEach(child => parts(child, out));
+                break;
+        }
+        return out;
+    }
+
+    // Returns the label of a feature, from its simplestyle or common properties
+    function featureTitle(properties) {
+        return properties.title || properties.name || properties.label || '';
+    }
+
+    class GeoMap {
+        constructor(container, block) {
+            this.container = container;
+            this.block = block;
+            this.items = [];
+            this.tiles = new Map();
+            this.zoom = 2;
+            this.center = { lon: 0, lat: 0 };
+            this.maxZoom = Math.min(22, settings.maxZoom || 19);
+
+            this.build();
+            this.addMarkers(block.markers || []);
+            (block.geojson || []).forEach(geojson => this.addGeoJSON(geojson));
+            this.fit();
+            this.loadSources(block.sources || []);
+        }
+
+        build() {
+            const container = this.container;
+            this.fallback = container.querySelector('.geo-map-fallback');
+
+            this.view = document.createElement('div');
+            this.view.className = 'geo-map-view';
+            this.view.tabIndex = 0;
+            this.view.setAttribute('role', 'application');
+            this.view.setAttribute('aria-label', t('map.label', 'Map'));
+            this.view.style.height = (this.block.height || defaultHeight) + 'px';
+
+            this.tileLayer = document.createElement('div');
+            this.tileLayer.className = 'geo-map-tiles';
+            this.svg = document.createElementNS(svgNS, 'svg');
+            this.svg.setAttribute('class', 'geo-map-shapes');
+            this.markerLayer = document.createElement('div');
+            this.markerLayer.className = 'geo-map-markers';
+
+            this.popup = document.createElement('div');
+            this.popup.className = 'geo-map-popup';
+            this.popup.hidden = true;
+
+            const controls = document.createElement('div');
+            controls.className = 'geo-map-controls';
+            [
+                ['+', t('map.zoom_in', 'Zoom in'), () => this.zoomBy(1)],
+                ['−', t('map.zoom_out', 'Zoom out'), () => this.zoomBy(-1)],
+                ['⤢', t('map.fit', 'Show everything'), () => this.fit()]
+            ].forEach(([text, label, action]) => {
+                const button = document.createElement('button');
+                button.type = 'button';
+                button.textContent = text;
+                button.title = label;
+                button.setAttribute('aria-label', label);
+                button.addEventListener('click', event => {
+                    event.stopPropagation();
+                    action();
+                });
+                controls.appendChild(button);
+            });
+
+            this.message = document.createElement('div');
+            this.message.className = 'geo-map-message';
+            this.message.hidden = true;
+
+            this.view.append(this.tileLayer, this.svg, this.markerLayer, this.popup, controls, this.message);
+            if (settings.tileUrl && settings.attribution) {
+                const attribution = document.createElement('div');
+                attribution.className = 'geo-map-attribution';
+                attribution.textContent = settings.attribution;
+                this.view.appendChild(attribution);
+            }
+            container.insertBefore(this.view, container.firstChild);
+            container.classList.add('geo-map-ready');
+
+            this.bindEvents();
+            if ('ResizeObserver' in window) {
+                new ResizeObserver(() => this.render()).observe(this.view);
+            }
+        }
+
+        bindEvents() {
+            const view = this.view;
+            let drag = null;
+
+            view.addEventListener('pointerdown', event => {
+                if (event.button !== 0 || event.target.closest('.geo-map-controls, .geo-map-popup')) return;
+                view.focus({ preventScroll: true });
+                // Captured pointers report the map as their target, the clicked element is kept
+                drag = { x: event.clientX, y: event.clientY, center: this.centerPixel(), moved: false, target: event.target };
+                view.setPointerCapture(event.pointerId);
+            });
+            view.addEventListener('pointermove', event => {
+                if (!drag) return;
+                const dx = event.clientX - drag.x;
+                const dy = event.clientY - drag.y;
+                if (!drag.moved && Math.abs(dx) + Math.abs(dy) < 3) return;
+                drag.moved = true;
+                view.classList.add('geo-map-dragging');
+                this.setCenterPixel(drag.center.x - dx, drag.center.y - dy);
+            });
+            const end = event => {
+                if (!drag) return;
+                view.classList.remove('geo-map-dragging');
+                const { moved, target } = drag;
+                drag = null;
+                if (!moved && event.type === 'pointerup') {
+                    this.select(event, target);
+                }
+            };
+            view.addEventListener('pointerup', end);
+            view.addEventListener('pointercancel', end);
+
+            view.addEventListener('dblclick', event => {
+                event.preventDefault();
+                this.zoomBy(event.shiftKey ? -1 : 1, this.pointerOffset(event));
+            });
+
+            // The wheel scrolls the page until the map is focused
+            view.addEventListener('wheel', event => {
+                if (document.activeElement !== view) return;
+                event.preventDefault();
+                this.zoomBy(event.deltaY < 0 ? 1 : -1, this.pointerOffset(event));
+            }, { passive: false });
+
+            view.addEventListener('keydown', event => {
+                const step = 80;
+                const center = this.centerPixel();
+                switch (event.key) {
+                    case 'ArrowLeft': this.setCenterPixel(center.x - step, center.y); break;
+                    case 'ArrowRight': this.setCenterPixel(center.x + step, center.y); break;
+                    case 'ArrowUp': this.setCenterPixel(center.x, center.y - step); break;
+                    case 'ArrowDown': this.setCenterPixel(center.x, center.y + step); break;
+                    case '+': case '=': this.zoomBy(1); break;
+                    case '-': case '_': this.zoomBy(-1); break;
+                    case '0': this.fit(); break;
+                    case 'Escape': this.popup.hidden = true; break;
+                    default: return;
+                }
+                event.preventDefault();
+            });
+        }
+
+        size() {
+            return { width: this.view.clientWidth || 600, height: this.view.clientHeight || defaultHeight };
+        }
+
+        centerPixel() {
+            return project(this.center.lon, this.center.lat, this.zoom);
+        }
+
+        setCenterPixel(x, y) {
+            this.center = unproject(x, y, this.zoom);
+            this.render();
+        }
+
+        pointerOffset(event) {
+            const rect = this.view.getBoundingClientRect();
+            return { x: event.clientX - rect.left, y: event.clientY - rect.top };
+        }
+
+        // Zooms by a number of levels, keeping the point under the pointer, or
+        // the center, in place
+        zoomBy(levels, offset) {
+            const zoom = Math.max(1, Math.min(this.maxZoom, this.zoom + levels));
+            if (zoom === this.zoom) return;
+            const size = this.size();
+            const anchor = offset || { x: size.width / 2, y: size.height / 2 };
+            const center = this.centerPixel();
+            const point = { x: center.x - size.width / 2 + anchor.x, y: center.y - size.height / 2 + anchor.y };
+            const scale = Math.pow(2, zoom - this.zoom);
+            this.zoom = zoom;
+            this.setCenterPixel(point.x * scale + (center.x - point.x), point.y * scale + (center.y - point.y));
+        }
+
+        addMarkers(markers) {
+            markers.forEach(marker => {
+                this.items.push({ kind: 'point', coordinates: [marker.lon, marker.lat], properties: marker.label ? { title: marker.label } : {} });
+            });
+        }
+
+        addGeoJSON(geojson) {
+            features(geojson).forEach(feature => {
+                const found = parts(feature.geometry, { points: [], lines: [], polygons: [] });
+                found.points.forEach(point => this.items.push({ kind: 'point', coordinates: point, properties: feature.properties }));
+                found.lines.forEach(line => this.items.push({ kind: 'line', coordinates: line, properties: feature.properties }));
+                found.polygons.forEach(rings => this.items.push({ kind: 'polygon', coordinates: rings, properties: feature.properties }));
+            });
+            this.invalidateMarkers();
+        }
+
+        loadSources(sources) {
+            if (sources.length === 0) {
+                this.done();
+                return;
+            }
+            Promise.all(sources.map(source => fetch(source, { credentials: 'same-origin' })
+                .then(response => {
+                    if (!response.ok) throw new Error(response.status + ' ' + response.statusText);
+                    return response.json();
+                })
+                .then(geojson => this.addGeoJSON(geojson))
+                .catch(error => {
+                    const name = decodeURIComponent(source.split('/').pop());
+                    this.showMessage(t('map.load_failed', 'Could not load') + ' ' + name + ': ' + error.message);
+                })
+            )).then(() => {
+                if (!this.block.center && !this.block.zoom) {
+                    this.fit();
+                } else {
+                    this.render();
+                }
+                this.done();
+            });
+        }
+
+        // Hides the list shown until the map was drawn
+        done() {
+            if (this.fallback && this.message.hidden) {
+                this.fallback.hidden = true;
+            }
+        }
+
+        showMessage(text) {
+            this.message.hidden = false;
+            this.message.textContent = this.message.textContent ? this.message.textContent + '\n' + text : text;
+        }
+
+        bounds() {
+            let bounds = null;
+            const extend = position => {
+                const [lon, lat] = position;
+                if (typeof lon !== 'number' || typeof lat !== 'number') return;
+                if (!bounds) {
+                    bounds = { west: lon, east: lon, south: lat, north: lat };
+                    return;
+                }
+                bounds.west = Math.min(bounds.west, lon);
+                bounds.east = Math.max(bounds.east, lon);
+                bounds.south = Math.min(bounds.south, lat);
+                bounds.north = Math.max(bounds.north, lat);
+            };
+            this.items.forEach(item => {
+                if (item.kind === 'point') extend(item.coordinates);
+                if (item.kind === 'line') item.coordinates.forEach(extend);
+                if (item.kind === 'polygon') item.coordinates.forEach(ring => ring.forEach(extend));
+            });
+            return bounds;
+        }
+
+        // Shows the center and zoom of the block, everything it holds otherwise
+        fit() {
+            const bounds = this.bounds();
+            const size = this.size();
+            const padding = 40;
+
+            if (this.block.center) {
+                this.center = { lat: this.block.center[0], lon: this.block.center[1] };
+            } else if (bounds) {
+                const northWest = project(bounds.west, bounds.north, 0);
+                const southEast = project(bounds.east, bounds.south, 0);
+                this.center = unproject((northWest.x + southEast.x) / 2, (northWest.y + southEast.y) / 2, 0);
+            }
+
+            if (this.block.zoom) {
+                this.zoom = Math.min(this.maxZoom, this.block.zoom);
+            } else if (bounds) {
+                const northWest = project(bounds.west, bounds.north, 0);
+                const southEast = project(bounds.east, bounds.south, 0);
+                const width = Math.max(southEast.x - northWest.x, 1e-9);
+                const height = Math.max(southEast.y - northWest.y, 1e-9);
+                const zoom = Math.floor(Math.log2(Math.min((size.width - padding * 2) / width, (size.height - padding * 2) / height)));
+                // A single place is shown at street level rather than the highest zoom
+                this.zoom = Math.max(1, Math.min(this.maxZoom, 16, zoom));
+            }
+            this.render();
+        }
+
+        tileAddress(x, y, zoom) {
+            return settings.tileUrl
+                .replace('{z}', zoom)
+                .replace('{x}', x)
+                .replace('{y}', y)
+                .replace('{s}', subdomains[Math.abs(x + y) % subdomains.length])
+                .replace('{r}', window.devicePixelRatio > 1 ? '@2x' : '');
+        }
+
+        render() {
+            const size = this.size();
+            const center = this.centerPixel();
+            const left = center.x - size.width / 2;
+            const top = center.y - size.height / 2;
+            this.origin = { x: left, y: top };
+
+            this.renderTiles(left, top, size);
+            this.renderShapes(left, top, size);
+            this.renderMarkers(left, top);
+            if (!this.popup.hidden && this.popup.anchor) {
+                this.placePopup(this.popup.anchor);
+            }
+        }
+
+        renderTiles(left, top, size) {
+            if (!settings.tileUrl) return;
+            const count = Math.pow(2, this.zoom);
+            const wanted = new Set();
+            for (let ty = Math.floor(top / tileSize); ty <= Math.floor((top + size.height) / tileSize); ty++) {
+                if (ty < 0 || ty >= count) continue;
+                for (let tx = Math.floor(left / tileSize); tx <= Math.floor((left + size.width) / tileSize); tx++) {
+                    const key = this.zoom + '/' + tx + '/' + ty;
+                    wanted.add(key);
+                    let tile = this.tiles.get(key);
+                    if (!tile) {
+                        tile = document.createElement('img');
+                        tile.className = 'geo-map-tile';
+                        tile.alt = '';
+                        tile.draggable = false;
+                        tile.addEventListener('error', () => tile.classList.add('geo-map-tile-missing'));
+                        // The world repeats east and west
+                        tile.src = this.tileAddress(((tx % count) + count) % count, ty, this.zoom);
+                        this.tiles.set(key, tile);
+                        this.tileLayer.appendChild(tile);
+                    }
+                    tile.style.transform = 'translate(' + Math.round(tx * tileSize - left) + 'px, ' + Math.round(ty * tileSize - top) + 'px)';
+                }
+            }
+            this.tiles.forEach((tile, key) => {
+                if (!wanted.has(key)) {
+                    tile.remove();
+                    this.tiles.delete(key);
+                }
+            });
+        }
+
+        screen(position, left, top) {
+            const point = project(position[0], position[1], this.zoom);
+            return [point.x - left, point.y - top];
+        }
+
+        renderShapes(left, top, size) {
+            this.svg.setAttribute('width', size.width);
+            this.svg.setAttribute('height', size.height);
+            this.svg.textContent = '';
+            const path = positions => positions.map((position, i) => {
+                const [x, y] = this.screen(position, left, top);
+                return (i === 0 ? 'M' : 'L') + x.toFixed(1) + ' ' + y.toFixed(1);
+            }).join('');
+
+            this.items.forEach((item, index) => {
+                if (item.kind === 'point') return;
+                const style = item.properties;
+                const element = document.createElementNS(svgNS, 'path');
+                element.setAttribute('class', 'geo-map-shape geo-map-' + item.kind);
+                element.dataset.index = index;
+                if (item.kind === 'line') {
+                    element.setAttribute('d', path(item.coordinates));
+                    element.setAttribute('fill', 'none');
+                } else {
+                    element.setAttribute('d', item.coordinates.map(ring => path(ring) + 'Z').join(''));
+                    element.setAttribute('fill-rule', 'evenodd');
+                    if (style.fill) element.style.fill = style.fill;
+                    if (style['fill-opacity'] !== undefined) element.style.fillOpacity = style['fill-opacity'];
+                }
+                if (style.stroke) element.style.stroke = style.stroke;
+                if (style['stroke-width'] !== undefined) element.style.strokeWidth = style['stroke-width'];
+                if (style['stroke-opacity'] !== undefined) element.style.strokeOpacity = style['stroke-opacity'];
+                const title = featureTitle(style);
+                if (title) {
+                    const tooltip = document.createElementNS(svgNS, 'title');
+                    tooltip.textContent = title;
+                    element.appendChild(tooltip);
+                }
+                this.svg.appendChild(element);
+            });
+        }
+
+        renderMarkers(left, top) {
+            if (!this.markers) {
+                this.markers = [];
+                this.items.forEach((item, index) => {
+                    if (item.kind !== 'point') return;
+                    const marker = document.createElement('button');
+                    marker.type = 'button';
+                    marker.className = 'geo-map-marker';
+                    marker.dataset.index = index;
+                    const title = featureTitle(item.properties);
+                    marker.title = title;
+                    marker.setAttribute('aria-label', title || t('map.marker', 'Marker'));
+                    // Enter and space open the popup of focused markers
+                    marker.addEventListener('click', event => {
+                        if (event.detail === 0) this.select(event, marker);
+                    });
+                    if (item.properties['marker-color']) {
+                        marker.style.setProperty('--geo-map-marker', item.properties['marker-color']);
+                    }
+                    this.markerLayer.appendChild(marker);
+                    this.markers.push({ element: marker, item: item });
+                });
+            }
+            this.markers.forEach(marker => {
+                const [x, y] = this.screen(marker.item.coordinates, left, top);
+                marker.element.style.transform = 'translate(' + Math.round(x) + 'px, ' + Math.round(y) + 'px)';
+            });
+        }
+
+        // Markers are drawn again once attached files added points
+        invalidateMarkers() {
+            this.markerLayer.textContent = '';
+            this.markers = null;
+        }
+
+        // Shows the properties of the marker or shape clicked
+        select(event, clicked) {
+            const target = clicked.closest('[data-index]');
+            if (!target) {
+                this.popup.hidden = true;
+                return;
+            }
+            const item = this.items[Number(target.dataset.index)];
+            const properties = item.properties || {};
+            this.popup.textContent = '';
+
+            const title = featureTitle(properties);
+            if (title) {
+                const heading = document.createElement('strong');
+                heading.textContent = title;
+                this.popup.appendChild(heading);
+            }
+            if (properties.description) {
+                const description = document.createElement('p');
+                description.textContent = properties.description;
+                this.popup.appendChild(description);
+            }
+            const hidden = ['title', 'name', 'label', 'description', 'marker-color', 'marker-size', 'marker-symbol', 'stroke', 'stroke-width', 'stroke-opacity', 'fill', 'fill-opacity'];
+            const rows = Object.keys(properties).filter(key => !hidden.includes(key) && properties[key] !== null && typeof properties[key] !== 'object');
+            if (rows.length > 0) {
+                const table = document.createElement('table');
+                rows.forEach(key => {
+                    const row = table.insertRow();
+                    row.insertCell().textContent = key;
+                    row.insertCell().textContent = String(properties[key]);
+                });
+                this.popup.appendChild(table);
+            }
+            if (item.kind === 'point') {
+                const position = document.createElement('div');
+                position.className = 'geo-map-position';
+                position.textContent = item.coordinates[1].toFixed(5) + ', ' + item.coordinates[0].toFixed(5);
+                this.popup.appendChild(position);
+            }
+
+            const offset = this.pointerOffset(event);
+            this.popup.anchor = item.kind === 'point'
+                ? { lon: item.coordinates[0], lat: item.coordinates[1] }
+                : unproject(this.origin.x + offset.x, this.origin.y + offset.y, this.zoom);
+            this.popup.hidden = false;
+            this.placePopup(this.popup.anchor);
+        }
+
+        placePopup(anchor) {
+            const point = project(anchor.lon, anchor.lat, this.zoom);
+            this.popup.style.left = Math.round(point.x - this.origin.x) + 'px';
+            this.popup.style.top = Math.round(point.y - this.origin.y) + 'px';
+        }
+    }
+
+    function load(container) {
+        if (container.dataset.mapLoaded) return;
+        container.dataset.mapLoaded = 'true';
+        try {
+            new GeoMap(container, decode(container.dataset.map));
+        } catch (error) {
+            console.error('Failed to draw map:', error);
+            const message = document.createElement('div');
+            message.className = 'geo-map-message';
+            message.textContent = t('map.invalid', 'The map could not be drawn') + ': ' + error.message;
+            container.insertBefore(message, container.firstChild);
+        }
+    }
+
+    function init() {
+        const containers = Array.from(document.querySelectorAll('.geo-map[data-map]'));
+        if (containers.length === 0) return;
+        if (!('IntersectionObserver' in window)) {
+            containers.forEach(load);
+            return;
+        }
+        const observer = new IntersectionObserver(entries => {
+            entries.forEach(entry => {
+                if (!entry.isIntersecting) return;
+                observer.unobserve(entry.target);
+                load(entry.target);
+            });
+        }, { rootMargin: '200px' });
+        containers.forEach(container => observer.observe(container));
+        window.addEventListener('beforeprint', () => containers.forEach(load));
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', init);
+    } else {
+        init();
+    }
+
+    window.GeoMap = {
+        project: project,
+        unproject: unproject
+    };
+})();
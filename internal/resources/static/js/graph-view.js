@@ -0,0 +1,489 @@
+/**
+ * Graph view
+ * Draws the pages of the wiki and the links between them from /api/graph, laid
+ * out by a small force simulation on a canvas. Pages are sized by their links
+ * and colored by their top directory; pages nothing links to are ringed.
+ * Hovering a page highlights its neighbours, clicking it opens it.
+ */
+
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.graph-dialog');
+    if (!dialog) return;
+
+    const canvas = dialog.querySelector('.graph-canvas');
+    const wrapper = dialog.querySelector('.graph-canvas-wrapper');
+    const tooltip = dialog.querySelector('.graph-tooltip');
+    const status = dialog.querySelector('.graph-status');
+    const list = dialog.querySelector('.graph-list');
+    const dirSelect = dialog.querySelector('.graph-dir');
+    const tagSelect = dialog.querySelector('.graph-tag');
+    const depthSelect = dialog.querySelector('.graph-depth');
+    const orphansBox = dialog.querySelector('.graph-orphans');
+    const context = canvas.getContext('2d');
+
+    // Colors of the top directories, taken in turn
+    const palette = ['#4e79a7', '#f28e2b', '#59a14f', '#b07aa1', '#76b7b2', '#edc948', '#ff9da7', '#9c755f', '#e15759', '#bab0ac'];
+
+    let nodes = [];
+    let links = [];
+    let byId = new Map();
+    let colors = new Map();
+    let view = { x: 0, y: 0, scale: 1 };
+    let hovered = null;
+    let drag = null;
+    let frame = 0;
+    let heat = 0;
+    let filtersLoaded = false;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // currentPage is the id of the page being read, empty for the homepage
+    function currentPage() {
+        return decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function fillSelect(select, values) {
+        values.forEach(value => select.appendChild(new Option(value, value)));
+    }
+
+    // ===== DATA =====
+
+    async function load() {
+        const params = new URLSearchParams();
+        if (dirSelect.value) params.set('dir', dirSelect.value);
+        if (tagSelect.value) params.set('tag', tagSelect.value);
+        if (depthSelect.value) {
+            params.set('center', currentPage());
+            params.set('depth', depthSelect.value);
+        }
+
+        showError('');
+        status.textContent = t('graph.loading', 'Loading...');
+        let graph;
+        try {
+            const response = await fetch('/api/graph?' + params.toString(), { credentials: 'same-origin' });
+            if (!response.ok) throw new Error(response.statusText || String(response.status));
+            graph = await response.json();
+        } catch (error) {
+            console.error('Error loading the graph:', error);
+            showError(t('graph.load_failed', 'The graph could not be loaded'));
+            status.textContent = '';
+            return;
+        }
+
+        if (!filtersLoaded) {
+            fillSelect(dirSelect, graph.dirs || []);
+            fillSelect(tagSelect, graph.tags || []);
+            filtersLoaded = true;
+        }
+        setGraph(graph);
+    }
+
+    // setGraph keeps the positions of the pages already shown, so changing a
+    // filter doesn't shuffle the whole graph
+    function setGraph(graph) {
+        const previous = byId;
+        const dirs = [];
+        let shown = graph.nodes || [];
+        if (orphansBox.checked) shown = shown.filter(node => node.in === 0);
+
+        nodes = shown.map((node, i) => {
+            const old = previous.get(node.id);
+            const angle = i * 2.399963;
+            const radius = 12 * Math.sqrt(i + 1);
+            const dir = node.id.includes('/') ? node.id.split('/')[0] : '';
+            if (!dirs.includes(dir)) dirs.push(dir);
+            return Object.assign({}, node, {
+                dir: dir,
+                x: old ? old.x : Math.cos(angle) * radius,
+                y: old ? old.y : Math.sin(angle) * radius,
+                vx: 0,
+                vy: 0,
+                radius: 4 + Math.sqrt(node.in + node.out) * 2
+            });
+        });
+        byId = new Map(nodes.map(node => [node.id, node]));
+        links = (graph.links || [])
+            .filter(link => byId.has(link.source) && byId.has(link.target) && link.source !== link.target)
+            .map(link => ({ source: byId.get(link.source), target: byId.get(link.target) }));
+        nodes.forEach(node => { node.neighbours = new Set(); });
+        links.forEach(link => {
+            link.source.neighbours.add(link.target);
+            link.target.neighbours.add(link.source);
+        });
+        dirs.sort().forEach(dir => {
+            if (!colors.has(dir)) colors.set(dir, palette[colors.size % palette.length]);
+        });
+
+        const orphans = nodes.filter(node => node.in === 0).length;
+        status.textContent = nodes.length === 0
+            ? t('graph.empty', 'No pages match these filters')
+            : t('graph.summary', '{pages} pages, {links} links, {orphans} without incoming links')
+                .replace('{pages}', nodes.length)
+                .replace('{links}', links.length)
+                .replace('{orphans}', orphans);
+        renderList();
+        hovered = null;
+        heat = 1;
+        fit();
+        start();
+    }
+
+    // renderList lists the pages for keyboards and screen readers
+    function renderList() {
+        list.innerHTML = '';
+        nodes.slice().sort((a, b) => a.title.localeCompare(b.title)).forEach(node => {
+            const item = document.createElement('li');
+            const link = document.createElement('a');
+            link.href = '/' + node.id;
+            link.textContent = node.title;
+            item.appendChild(link);
+            if (node.in === 0) {
+                item.className = 'graph-orphan';
+                item.append(' ', t('graph.orphan', '(no incoming links)'));
+            }
+            list.appendChild(item);
+        });
+    }
+
+    // ===== LAYOUT =====
+
+    // tick moves the pages one step: they push each other away, links pull
+    // them together and everything is drawn toward the center
+    function tick() {
+        const count = nodes.length;
+        for (let i = 0; i < count; i++) {
+            const a = nodes[i];
+            for (let j = i + 1; j < count; j++) {
+                const b = nodes[j];
+                let dx = a.x - b.x;
+                let dy = a.y - b.y;
+                let distance = dx * dx + dy * dy;
+                if (distance === 0) {
+                    dx = Math.random() - 0.5;
+                    dy = Math.random() - 0.5;
+                    distance = dx * dx + dy * dy;
+                }
+                if (distance > 250000) continue;
+                const force = 900 / distance;
+                a.vx += dx * force;
+                a.vy += dy * force;
+                b.vx -= dx * force;
+                b.vy -= dy * force;
+            }
+        }
+        links.forEach(link => {
+            const dx = link.target.x - link.source.x;
+            const dy = link.target.y - link.source.y;
+            const distance = Math.sqrt(dx * dx + dy * dy) || 1;
+            const force = (distance - 60) * 0.02 / distance;
+            link.source.vx += dx * force;
+            link.source.vy += dy * force;
+            link.target.vx -= dx * force;
+            link.target.vy -= dy * force;
+        });
+        nodes.forEach(node => {
+            node.vx -= node.x * 0.005;
+            node.vy -= node.y * 0.005;
+            if (drag && drag.node === node) {
+                node.vx = node.vy = 0;
+                return;
+            }
+            node.x += node.vx * heat;
+            node.y += node.vy * heat;
+            node.vx *= 0.6;
+            node.vy *= 0.6;
+        });
+        heat *= 0.985;
+    }
+
+    function start() {
+        if (!frame) frame = requestAnimationFrame(step);
+    }
+
+    function step() {
+        frame = 0;
+        if (!dialog.classList.contains('active')) return;
+        if (heat > 0.02) tick();
+        draw();
+        if (heat > 0.02 || drag) start();
+    }
+
+    // fit zooms to show every page
+    function fit() {
+        resize();
+        if (nodes.length === 0) return;
+        let minX = Infinity, minY = Infinity, maxX = -Infinity, maxY = -Infinity;
+        nodes.forEach(node => {
+            minX = Math.min(minX, node.x);
+            minY = Math.min(minY, node.y);
+            maxX = Math.max(maxX, node.x);
+            maxY = Math.max(maxY, node.y);
+        });
+        const width = canvas.clientWidth;
+        const height = canvas.clientHeight;
+        const scale = Math.min(2, width / (maxX - minX + 200), height / (maxY - minY + 200));
+        view = {
+            scale: scale,
+            x: width / 2 - (minX + maxX) / 2 * scale,
+            y: height / 2 - (minY + maxY) / 2 * scale
+        };
+    }
+
+    function resize() {
+        const ratio = window.devicePixelRatio || 1;
+        canvas.width = Math.max(1, Math.round(wrapper.clientWidth * ratio));
+        canvas.height = Math.max(1, Math.round(wrapper.clientHeight * ratio));
+    }
+
+    // ===== DRAWING =====
+
+    function draw() {
+        const ratio = window.devicePixelRatio || 1;
+        const style = getComputedStyle(dialog);
+        const text = style.getPropertyValue('--text-color').trim() || '#333';
+        const muted = style.getPropertyValue('--border-color').trim() || '#ccc';
+        const current = currentPage();
+
+        context.setTransform(1, 0, 0, 1, 0, 0);
+        context.clearRect(0, 0, canvas.width, canvas.height);
+        context.setTransform(ratio * view.scale, 0, 0, ratio * view.scale, ratio * view.x, ratio * view.y);
+
+        const near = node => !hovered || node === hovered || hovered.neighbours.has(node);
+
+        context.lineWidth = 1 / view.scale;
+        links.forEach(link => {
+            const highlighted = hovered && (link.source === hovered || link.target === hovered);
+            context.strokeStyle = highlighted ? text : muted;
+            context.globalAlpha = hovered && !highlighted ? 0.15 : 0.8;
+            context.beginPath();
+            context.moveTo(link.source.x, link.source.y);
+            context.lineTo(link.target.x, link.target.y);
+            context.stroke();
+            if (highlighted) drawArrow(link);
+        });
+
+        nodes.forEach(node => {
+            context.globalAlpha = near(node) ? 1 : 0.2;
+            context.fillStyle = colors.get(node.dir) || palette[0];
+            context.beginPath();
+            context.arc(node.x, node.y, node.radius, 0, Math.PI * 2);
+            context.fill();
+            if (node.in === 0) {
+                context.strokeStyle = '#e15759';
+                context.lineWidth = 2 / view.scale;
+                context.setLineDash([3 / view.scale, 2 / view.scale]);
+                context.beginPath();
+                context.arc(node.x, node.y, node.radius + 3 / view.scale, 0, Math.PI * 2);
+                context.stroke();
+                context.setLineDash([]);
+            }
+            if (node.id === current) {
+                context.strokeStyle = text;
+                context.lineWidth = 2 / view.scale;
+                context.beginPath();
+                context.arc(node.x, node.y, node.radius + 1, 0, Math.PI * 2);
+                context.stroke();
+            }
+        });
+
+        // Titles are written once they are large enough to read, and always
+        // around the hovered page
+        context.fillStyle = text;
+        context.font = 11 / view.scale + 'px sans-serif';
+        context.textAlign = 'center';
+        context.textBaseline = 'top';
+        nodes.forEach(node => {
+            if (hovered ? !near(node) : view.scale < 0.8) return;
+            context.globalAlpha = 1;
+            context.fillText(node.title, node.x, node.y + node.radius + 4 / view.scale);
+        });
+        context.globalAlpha = 1;
+    }
+
+    function drawArrow(link) {
+        const dx = link.target.x - link.source.x;
+        const dy = link.target.y - link.source.y;
+        const distance = Math.sqrt(dx * dx + dy * dy) || 1;
+        const x = link.target.x - dx / distance * (link.target.radius + 2);
+        const y = link.target.y - dy / distance * (link.target.radius + 2);
+        const angle = Math.atan2(dy, dx);
+        const size = 6 / view.scale;
+        context.fillStyle = context.strokeStyle;
+        context.beginPath();
+        context.moveTo(x, y);
+        context.lineTo(x - size * Math.cos(angle - 0.4), y - size * Math.sin(angle - 0.4));
+        context.lineTo(x - size * Math.cos(angle + 0.4), y - size * Math.sin(angle + 0.4));
+        context.closePath();
+        context.fill();
+    }
+
+    // ===== INTERACTION =====
+
+    function graphPoint(event) {
+        const rect = canvas.getBoundingClientRect();
+        return {
+            x: (event.clientX - rect.left - view.x) / view.scale,
+            y: (event.clientY - rect.top - view.y) / view.scale
+        };
+    }
+
+    function nodeAt(point) {
+        for (let i = nodes.length - 1; i >= 0; i--) {
+            const node = nodes[i];
+            const dx = node.x - point.x;
+            const dy = node.y - point.y;
+            const reach = node.radius + 4 / view.scale;
+            if (dx * dx + dy * dy <= reach * reach) return node;
+        }
+        return null;
+    }
+
+    function showTooltip(node, event) {
+        if (!node) {
+            tooltip.hidden = true;
+            return;
+        }
+        const rect = wrapper.getBoundingClientRect();
+        tooltip.textContent = node.title + ' — ' + t('graph.link_counts', '{in} in, {out} out')
+            .replace('{in}', node.in)
+            .replace('{out}', node.out);
+        tooltip.style.left = (event.clientX - rect.left + 12) + 'px';
+        tooltip.style.top = (event.clientY - rect.top + 12) + 'px';
+        tooltip.hidden = false;
+    }
+
+    canvas.addEventListener('pointerdown', event => {
+        const node = nodeAt(graphPoint(event));
+        drag = { node: node, x: event.clientX, y: event.clientY, moved: false, view: Object.assign({}, view) };
+        canvas.setPointerCapture(event.pointerId);
+        if (node) {
+            heat = Math.max(heat, 0.3);
+            start();
+        }
+    });
+
+    canvas.addEventListener('pointermove', event => {
+        if (drag) {
+            const dx = event.clientX - drag.x;
+            const dy = event.clientY - drag.y;
+            if (Math.abs(dx) + Math.abs(dy) > 3) drag.moved = true;
+            if (drag.node) {
+                const point = graphPoint(event);
+                drag.node.x = point.x;
+                drag.node.y = point.y;
+                heat = Math.max(heat, 0.3);
+            } else {
+                view.x = drag.view.x + dx;
+                view.y = drag.view.y + dy;
+            }
+            start();
+            return;
+        }
+        const node = nodeAt(graphPoint(event));
+        canvas.style.cursor = node ? 'pointer' : 'grab';
+        showTooltip(node, event);
+        if (node !== hovered) {
+            hovered = node;
+            start();
+        }
+    });
+
+    canvas.addEventListener('pointerup', () => {
+        if (drag && drag.node && !drag.moved) {
+            window.location.href = '/' + drag.node.id;
+        }
+        drag = null;
+    });
+
+    canvas.addEventListener('pointerleave', () => {
+        if (drag) return;
+        hovered = null;
+        tooltip.hidden = true;
+        start();
+    });
+
+    canvas.addEventListener('wheel', event => {
+        event.preventDefault();
+        const rect = canvas.getBoundingClientRect();
+        const x = event.clientX - rect.left;
+        const y = event.clientY - rect.top;
+        const scale = Math.min(8, Math.max(0.1, view.scale * Math.exp(-event.deltaY * 0.0015)));
+        view.x = x - (x - view.x) * scale / view.scale;
+        view.y = y - (y - view.y) * scale / view.scale;
+        view.scale = scale;
+        start();
+    }, { passive: false });
+
+    canvas.addEventListener('keydown', event => {
+        const pan = 40;
+        switch (event.key) {
+            case '+': case '=': view.scale = Math.min(8, view.scale * 1.2); break;
+            case '-': view.scale = Math.max(0.1, view.scale / 1.2); break;
+            case '0': fit(); break;
+            case 'ArrowLeft': view.x += pan; break;
+            case 'ArrowRight': view.x -= pan; break;
+            case 'ArrowUp': view.y += pan; break;
+            case 'ArrowDown': view.y -= pan; break;
+            default: return;
+        }
+        event.preventDefault();
+        start();
+    });
+
+    [dirSelect, tagSelect, depthSelect].forEach(select => select.addEventListener('change', load));
+    orphansBox.addEventListener('change', load);
+
+    window.addEventListener('resize', () => {
+        if (!dialog.classList.contains('active')) return;
+        resize();
+        start();
+    });
+
+    // ===== DIALOG =====
+
+    function openDialog() {
+        dialog.classList.add('active');
+        load();
+        canvas.focus();
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+        tooltip.hidden = true;
+        drag = null;
+    }
+
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('keydown', event => {
+        if (event.key !== 'Escape') return;
+        event.stopPropagation();
+        closeDialog();
+    });
+
+    if (window.CommandPalette) {
+        window.CommandPalette.register({
+            id: 'graph-view',
+            title: () => t('graph.open', 'Show the graph of pages'),
+            section: () => t('palette.section_navigation', 'Navigation'),
+            keywords: 'graph links map network orphans backlinks',
+            icon: 'fa-share-alt',
+            run: openDialog
+        });
+    }
+
+    window.GraphView = {
+        open: openDialog
+    };
+})();
@@ -0,0 +1,373 @@
+/**
+ * Image editor
+ * Crops image attachments and draws arrows, boxes, text labels and blurred
+ * regions on them, then uploads the result as a new attachment of the page.
+ * The original is kept. Blurred regions are pixelated in the saved image, so
+ * what they hide can't be recovered from it.
+ */
+
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.image-editor-dialog');
+    if (!dialog) return;
+
+    const canvas = dialog.querySelector('.image-editor-canvas');
+    const ctx = canvas.getContext('2d');
+    const colorInput = dialog.querySelector('.image-editor-color');
+    const widthSelect = dialog.querySelector('.image-editor-width');
+    const nameInput = document.getElementById('imageEditorName');
+    const errorMessage = dialog.querySelector('.error-message');
+    const form = dialog.querySelector('.image-editor-form');
+
+    // Formats the editor can read and write back; others, like SVG, are not raster images
+    const editable = ['png', 'jpg', 'jpeg', 'gif', 'webp'];
+    // Size of the blocks blurred regions are pixelated into, relative to the image
+    const blurBlocks = 80;
+
+    let base = null;      // Canvas holding the image as cropped so far
+    let shapes = [];      // Annotations drawn on it, in order
+    let history = [];     // Earlier states, for undo
+    let tool = 'arrow';
+    let drag = null;      // Shape being drawn
+    let sourceName = '';
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function showError(message) {
+        errorMessage.textContent = message;
+        errorMessage.style.display = message ? 'block' : 'none';
+    }
+
+    function extension(name) {
+        return name.includes('.') ? name.split('.').pop().toLowerCase() : '';
+    }
+
+    // The name of the annotated copy: JPEG photos stay JPEG, the rest become PNG
+    function copyName(name) {
+        const ext = extension(name);
+        const stem = ext ? name.slice(0, -(ext.length + 1)) : name;
+        return stem + '-annotated.' + (ext === 'jpg' || ext === 'jpeg' ? 'jpg' : 'png');
+    }
+
+    function cloneCanvas(source, x, y, width, height) {
+        const copy = document.createElement('canvas');
+        copy.width = width;
+        copy.height = height;
+        copy.getContext('2d').drawImage(source, x, y, width, height, 0, 0, width, height);
+        return copy;
+    }
+
+    function remember() {
+        history.push({ base: base, shapes: shapes.slice() });
+    }
+
+    // Pixelates a region of the canvas
+    function pixelate(x, y, width, height) {
+        if (width < 1 || height < 1) return;
+        const block = Math.max(4, Math.round(Math.max(canvas.width, canvas.height) / blurBlocks));
+        const small = document.createElement('canvas');
+        small.width = Math.max(1, Math.ceil(width / block));
+        small.height = Math.max(1, Math.ceil(height / block));
+        const smallCtx = small.getContext('2d');
+        smallCtx.drawImage(canvas, x, y, width, height, 0, 0, small.width, small.height);
+        ctx.save();
+        ctx.imageSmoothingEnabled = false;
+        ctx.drawImage(small, 0, 0, small.width, small.height, x, y, width, height);
+        ctx.restore();
+    }
+
+    function drawArrow(shape) {
+        const angle = Math.atan2(shape.y2 - shape.y1, shape.x2 - shape.x1);
+        const head = shape.width * 4 + 6;
+        ctx.beginPath();
+        ctx.moveTo(shape.x1, shape.y1);
+        ctx.lineTo(shape.x2 - Math.cos(angle) * head * 0.6, shape.y2 - Math.sin(angle) * head * 0.6);
+        ctx.stroke();
+        ctx.beginPath();
+        ctx.moveTo(shape.x2, shape.y2);
+        ctx.lineTo(shape.x2 - head * Math.cos(angle - Math.PI / 7), shape.y2 - head * Math.sin(angle - Math.PI / 7));
+        ctx.lineTo(shape.x2 - head * Math.cos(angle + Math.PI / 7), shape.y2 - head * Math.sin(angle + Math.PI / 7));
+        ctx.closePath();
+        ctx.fill();
+    }
+
+    function drawText(shape) {
+        const size = shape.width * 4 + 12;
+        ctx.font = 'bold ' + size + 'px system-ui, -apple-system, sans-serif';
+        ctx.textBaseline = 'top';
+        // A light outline keeps labels readable on any background
+        ctx.lineWidth = Math.max(2, size / 6);
+        ctx.strokeStyle = 'rgba(255, 255, 255, 0.9)';
+        ctx.lineJoin = 'round';
+        ctx.strokeText(shape.text, shape.x1, shape.y1);
+        ctx.fillText(shape.text, shape.x1, shape.y1);
+    }
+
+    function rect(shape) {
+        return {
+            x: Math.round(Math.min(shape.x1, shape.x2)),
+            y: Math.round(Math.min(shape.y1, shape.y2)),
+            width: Math.round(Math.abs(shape.x2 - shape.x1)),
+            height: Math.round(Math.abs(shape.y2 - shape.y1))
+        };
+    }
+
+    function drawShape(shape) {
+        ctx.save();
+        ctx.strokeStyle = shape.color;
+        ctx.fillStyle = shape.color;
+        ctx.lineWidth = shape.width;
+        ctx.lineCap = 'round';
+        const r = rect(shape);
+        switch (shape.type) {
+        case 'arrow':
+            drawArrow(shape);
+            break;
+        case 'box':
+            ctx.strokeRect(r.x, r.y, r.width, r.height);
+            break;
+        case 'text':
+            drawText(shape);
+            break;
+        case 'blur':
+            pixelate(r.x, r.y, r.width, r.height);
+            break;
+        case 'crop':
+            // The area left out is dimmed while the crop is drawn
+            ctx.fillStyle = 'rgba(0, 0, 0, 0.45)';
+            ctx.beginPath();
+            ctx.rect(0, 0, canvas.width, canvas.height);
+            ctx.rect(r.x, r.y + r.height, r.width, -r.height);
+            ctx.fill('evenodd');
+            ctx.setLineDash([6, 4]);
+            ctx.lineWidth = 1;
+            ctx.strokeStyle = '#fff';
+            ctx.strokeRect(r.x + 0.5, r.y + 0.5, r.width, r.height);
+            break;
+        }
+        ctx.restore();
+    }
+
+    function render() {
+        canvas.width = base.width;
+        canvas.height = base.height;
+        ctx.drawImage(base, 0, 0);
+        shapes.forEach(drawShape);
+        if (drag) drawShape(drag);
+    }
+
+    // Maps a pointer event to image coordinates, the canvas being scaled to fit
+    function point(event) {
+        const box = canvas.getBoundingClientRect();
+        return {
+            x: Math.max(0, Math.min(canvas.width, (event.clientX - box.left) * canvas.width / box.width)),
+            y: Math.max(0, Math.min(canvas.height, (event.clientY - box.top) * canvas.height / box.height))
+        };
+    }
+
+    function newShape(p) {
+        return { type: tool, x1: p.x, y1: p.y, x2: p.x, y2: p.y, color: colorInput.value, width: parseInt(widthSelect.value, 10) };
+    }
+
+    function pointerDown(event) {
+        if (!base || event.button > 0) return;
+        const p = point(event);
+        if (tool === 'text') {
+            const text = prompt(t('image_editor.text_prompt', 'Text of the label'));
+            if (!text) return;
+            remember();
+            const shape = newShape(p);
+            shape.text = text;
+            shapes.push(shape);
+            render();
+            return;
+        }
+        drag = newShape(p);
+        canvas.setPointerCapture(event.pointerId);
+    }
+
+    function pointerMove(event) {
+        if (!drag) return;
+        const p = point(event);
+        drag.x2 = p.x;
+        drag.y2 = p.y;
+        render();
+    }
+
+    function pointerUp() {
+        if (!drag) return;
+        const shape = drag;
+        drag = null;
+        const r = rect(shape);
+        if (Math.max(r.width, r.height) < 4) {
+            render();
+            return;
+        }
+        remember();
+        if (shape.type === 'crop') {
+            // Cropping keeps what is drawn so far and starts again from the result
+            shapes.forEach(drawShape);
+            base = cloneCanvas(canvas, r.x, r.y, r.width, r.height);
+            shapes = [];
+            selectTool('arrow');
+        } else {
+            shapes.push(shape);
+        }
+        render();
+    }
+
+    function undo() {
+        const state = history.pop();
+        if (!state) return;
+        base = state.base;
+        shapes = state.shapes;
+        render();
+    }
+
+    function selectTool(name) {
+        tool = name;
+        dialog.querySelectorAll('.image-tool').forEach(button => {
+            const active = button.dataset.tool === name;
+            button.classList.toggle('active', active);
+            button.setAttribute('aria-pressed', active ? 'true' : 'false');
+        });
+    }
+
+    async function open(url, name) {
+        showError('');
+        sourceName = name;
+        nameInput.value = copyName(name);
+        shapes = [];
+        history = [];
+        selectTool('arrow');
+
+        const image = new Image();
+        image.onload = () => {
+            base = document.createElement('canvas');
+            base.width = image.naturalWidth;
+            base.height = image.naturalHeight;
+            base.getContext('2d').drawImage(image, 0, 0);
+            render();
+            dialog.classList.add('active');
+            canvas.focus();
+        };
+        image.onerror = () => {
+            const message = t('image_editor.load_failed', 'Failed to load the image');
+            if (window.showMessageDialog) {
+                window.showMessageDialog(t('image_editor.title', 'Annotate image'), message);
+            } else {
+                console.error(message);
+            }
+        };
+        image.src = url + (url.includes('?') ? '&' : '?') + 'v=' + Date.now();
+    }
+
+    function close() {
+        dialog.classList.remove('active');
+        base = null;
+        shapes = [];
+        history = [];
+    }
+
+    // Adds a number to name when the page has a file called like that already,
+    // so the copy never replaces another attachment
+    async function freeName(name) {
+        let files = [];
+        try {
+            const resp = await fetch('/api/files/list/' + getCurrentDocPath());
+            const data = await resp.json();
+            files = (data.files || []).map(file => (typeof file === 'string' ? file : (file.Name || file.name || '')).toLowerCase());
+        } catch (error) {
+            files = [];
+        }
+        const ext = extension(name);
+        const stem = ext ? name.slice(0, -(ext.length + 1)) : name;
+        let candidate = name;
+        for (let n = 2; files.includes(candidate.toLowerCase()); n++) {
+            candidate = stem + '-' + n + (ext ? '.' + ext : '');
+        }
+        return candidate;
+    }
+
+    async function save(event) {
+        event.preventDefault();
+        if (!base) return;
+        showError('');
+
+        let name = nameInput.value.trim().replace(/\s+/g, '_');
+        let ext = extension(name);
+        if (ext !== 'png' && ext !== 'jpg' && ext !== 'jpeg') {
+            name += '.png';
+            ext = 'png';
+        }
+        const type = ext === 'png' ? 'image/png' : 'image/jpeg';
+        if (name.toLowerCase() === sourceName.toLowerCase()) {
+            showError(t('image_editor.same_name', 'Pick another name, the original image is kept'));
+            return;
+        }
+        name = await freeName(name);
+
+        drag = null;
+        render();
+        const blob = await new Promise(resolve => canvas.toBlob(resolve, type, 0.92));
+        if (!blob) {
+            showError(t('image_editor.save_failed', 'Failed to save the image'));
+            return;
+        }
+
+        const formData = new FormData();
+        formData.append('file', new File([blob], name, { type: type }));
+        formData.append('docPath', getCurrentDocPath());
+        const submit = form.querySelector('button[type="submit"]');
+        submit.disabled = true;
+        try {
+            const resp = await fetch('/api/files/upload', { method: 'POST', body: formData });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+
+            close();
+            if (window.FileUtilities && window.FileUtilities.loadDocumentFiles) {
+                window.FileUtilities.loadDocumentFiles();
+            }
+            if (window.CommandPalette) {
+                window.CommandPalette.notify(t('image_editor.saved', 'Annotated copy saved as') + ' ' + name);
+            }
+        } catch (error) {
+            console.error('Error saving the annotated image:', error);
+            showError(t('image_editor.save_failed', 'Failed to save the image') + ': ' + error.message);
+        } finally {
+            submit.disabled = false;
+        }
+    }
+
+    dialog.querySelectorAll('.image-tool').forEach(button => {
+        button.addEventListener('click', () => selectTool(button.dataset.tool));
+    });
+    dialog.querySelector('.image-editor-undo').addEventListener('click', undo);
+    canvas.addEventListener('pointerdown', pointerDown);
+    canvas.addEventListener('pointermove', pointerMove);
+    canvas.addEventListener('pointerup', pointerUp);
+    canvas.addEventListener('pointercancel', pointerUp);
+    form.addEventListener('submit', save);
+    dialog.querySelector('.close-dialog').addEventListener('click', close);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', close);
+    dialog.addEventListener('keydown', event => {
+        if (event.key === 'Escape') {
+            event.stopPropagation();
+            close();
+        } else if ((event.ctrlKey || event.metaKey) && event.key === 'z' && event.target.tagName !== 'INPUT') {
+            event.preventDefault();
+            undo();
+        }
+    });
+
+    window.ImageEditor = {
+        open: open,
+        // Whether an attachment can be opened in the editor
+        canEdit: name => editable.includes(extension(name))
+    };
+})();
@@ -9,6 +9,7 @@ document.addEventListener('DOMContentLoaded', function() {
     // Import form elements
     const importForm = document.getElementById('importForm');
     const importZipFile = document.getElementById('importZipFile');
+    const importFormat = document.getElementById('importFormat');
     const importButton = document.getElementById('importButton');
     const cancelImportButton = document.getElementById('cancelImportButton');
     const importProgressContainer = document.querySelector('.import-progress-container');
@@ -27,6 +28,13 @@ document.addEventListener('DOMContentLoaded', function() {
         cancelImportButton.addEventListener('click', resetImportForm);
     }
 
+    if (importFormat && importZipFile) {
+        // Formats exported as a single file accept it without a ZIP archive
+        importFormat.addEventListener('change', function() {
+            importZipFile.accept = acceptedExtensions().join(',');
+        });
+    }
+
     if (importZipFile) {
         importZipFile.addEventListener('change', function() {
             // Enable/disable import button based on file selection
@@ -36,6 +44,15 @@ document.addEventListener('DOMContentLoaded', function() {
         });
     }
 
+    /**
+     * Get the file extensions the selected format can be imported from
+     * @returns {string[]} Extensions such as ".zip"
+     */
+    function acceptedExtensions() {
+        const option = importFormat ? importFormat.selectedOptions[0] : null;
+        return (option && option.dataset.accept ? option.dataset.accept : '.zip').split(',');
+    }
+
     /**
      * Handle import form submission
      * @param {Event} e - Form submit event
@@ -52,7 +69,7 @@ document.addEventListener('DOMContentLoaded', function() {
         const file = importZipFile.files[0];
 
         // Validate file type
-        if (!file.name.toLowerCase().endsWith('.zip')) {
+        if (!acceptedExtensions().some(ext => file.name.toLowerCase().endsWith(ext))) {
             showImportError('Please select a valid ZIP file');
             return;
         }
@@ -60,6 +77,9 @@ document.addEventListener('DOMContentLoaded', function() {
         // Create form data
         const formData = new FormData();
         formData.append('zipFile', file);
+        if (importFormat) {
+            formData.append('format', importFormat.value);
+        }
 
         try {
             // Show progress UI
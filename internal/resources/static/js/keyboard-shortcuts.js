@@ -31,6 +31,11 @@ const SHORTCUT_DEFINITIONS = {
         other: { ctrl: true, shift: true, key: 'f' },
         description: 'Focus the search box'
     },
+    'openCommandPalette': {
+        mac: { cmd: true, key: 'k' },
+        other: { ctrl: true, key: 'k' },
+        description: 'Open the command palette'
+    },
 
     // Formatting shortcuts
     'formatBold': {
@@ -184,6 +189,27 @@ function matchesShortcut(event, shortcut) {
            (eventKey === targetKey || eventKey === platformShortcut.key);
 }
 
+// Helper function to describe a shortcut for display, like "⌘E" or "Ctrl+E"
+function shortcutLabel(actionName) {
+    const shortcut = SHORTCUT_DEFINITIONS[actionName];
+    const platformShortcut = shortcut && shortcut[isMac ? 'mac' : 'other'];
+    if (!platformShortcut) return '';
+
+    const key = platformShortcut.key.length === 1 ? platformShortcut.key.toUpperCase() : platformShortcut.key;
+    if (isMac) {
+        return (platformShortcut.ctrl ? '⌃' : '') +
+               (platformShortcut.option ? '⌥' : '') +
+               (platformShortcut.shift ? '⇧' : '') +
+               (platformShortcut.cmd ? '⌘' : '') + key;
+    }
+    const parts = [];
+    if (platformShortcut.ctrl) parts.push('Ctrl');
+    if (platformShortcut.alt) parts.push('Alt');
+    if (platformShortcut.shift) parts.push('Shift');
+    parts.push(key);
+    return parts.join('+');
+}
+
 // Helper function to get shortcut action from event
 function getShortcutAction(event) {
     for (const [actionName, shortcut] of Object.entries(SHORTCUT_DEFINITIONS)) {
@@ -533,6 +559,15 @@ function handleKeyDown(e) {
                 }
                 return;
 
+            case 'openCommandPalette':
+                // In the editor Ctrl+K quotes the line, Ctrl+Shift+K opens the palette
+                if (e.defaultPrevented) return;
+                e.preventDefault();
+                if (window.CommandPalette) {
+                    window.CommandPalette.toggle();
+                }
+                return;
+
             case 'togglePreview':
                 e.preventDefault();
                 if (mainContent && mainContent.classList.contains('editing')) {
@@ -703,6 +738,7 @@ window.KeyboardShortcuts = {
     handleKeyDown,
     handleEscapeKey,
     exitEditMode,
+    shortcutLabel,
     registerFormattingCommands,
     registerAllCodeMirrorShortcuts  // Export this so it can be called after table editor loads
 };
@@ -0,0 +1,49 @@
+/**
+ * Language Menu
+ * Opens the list of languages the page is available in, and lets editors
+ * confirm machine translations
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const confirmButton = document.querySelector('.translation-confirm');
+        if (confirmButton) {
+            confirmButton.addEventListener('click', async function() {
+                try {
+                    const resp = await fetch('/api/translations/confirm', {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ path: window.location.pathname, language: confirmButton.dataset.language })
+                    });
+                    const result = await resp.json();
+                    if (!resp.ok || !result.success) {
+                        window.DialogSystem.showMessageDialog('Error', result.message || 'Failed to confirm the translation');
+                        return;
+                    }
+                    confirmButton.closest('.translation-banner').remove();
+                } catch (error) {
+                    console.error('Translation error:', error);
+                }
+            });
+        }
+
+        const menu = document.querySelector('.language-menu');
+        if (!menu) return;
+
+        const button = menu.querySelector('.language-button');
+        const dropdown = menu.querySelector('.language-dropdown');
+
+        button.addEventListener('click', function(e) {
+            e.stopPropagation();
+            dropdown.hidden = !dropdown.hidden;
+        });
+
+        document.addEventListener('click', function(e) {
+            if (!menu.contains(e.target)) {
+                dropdown.hidden = true;
+            }
+        });
+    });
+})();
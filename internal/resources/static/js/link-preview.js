@@ -0,0 +1,185 @@
+// Link previews: hovering a link to another page of the wiki shows a card with
+// its title, excerpt, first image and when it last changed
+(function() {
+    'use strict';
+
+    const content = document.querySelector('.markdown-content');
+    if (!content || !window.matchMedia('(hover: hover)').matches) return;
+
+    const showDelay = 500;
+    const hideDelay = 300;
+
+    // Paths that are not pages, like attachments and the API
+    const skippedPaths = /^\/(api|static|debug|ebook|embed|favicon|login|logout|logo|manifest|offline|present|print|s|sitemap|sw)(\/|$|\.)/;
+
+    const previews = new Map(); // Fetch promises by page path
+    let card = null;
+    let currentLink = null;
+    let showTimer = null;
+    let hideTimer = null;
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        if (!text || text === key) text = fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    // pagePath returns the path of the wiki page a link points to, or null
+    function pagePath(link) {
+        if (link.closest('.link-preview') || link.hasAttribute('download')) return null;
+        let url;
+        try {
+            url = new URL(link.href, window.location.href);
+        } catch (e) {
+            return null;
+        }
+        if (url.origin !== window.location.origin) return null;
+        const path = decodeURIComponent(url.pathname).replace(/\/+$/, '') || '/';
+        if (skippedPaths.test(path) || /\.[a-z0-9]+$/i.test(path)) return null;
+        // Anchors within the current page
+        const current = decodeURIComponent(window.location.pathname).replace(/\/+$/, '') || '/';
+        if (path === current) return null;
+        return path;
+    }
+
+    function fetchPreview(path) {
+        if (!previews.has(path)) {
+            const request = fetch('/api/preview?path=' + encodeURIComponent(path), { credentials: 'same-origin' })
+                .then(response => response.ok ? response.json() : null)
+                .catch(() => null);
+            previews.set(path, request);
+        }
+        return previews.get(path);
+    }
+
+    function formatDate(value) {
+        const date = new Date(value);
+        if (isNaN(date)) return '';
+        return date.toLocaleDateString(document.documentElement.lang || undefined, {
+            year: 'numeric', month: 'short', day: 'numeric'
+        });
+    }
+
+    function buildCard(preview) {
+        const element = document.createElement('div');
+        element.className = 'link-preview';
+        element.setAttribute('role', 'tooltip');
+
+        if (preview.image) {
+            const image = document.createElement('img');
+            image.className = 'link-preview-image';
+            image.src = preview.image;
+            image.alt = '';
+            image.loading = 'lazy';
+            image.addEventListener('error', () => image.remove());
+            element.appendChild(image);
+        }
+
+        const body = document.createElement('div');
+        body.className = 'link-preview-body';
+        body.dir = 'auto';
+
+        const title = document.createElement('div');
+        title.className = 'link-preview-title';
+        title.textContent = preview.title;
+        body.appendChild(title);
+
+        if (preview.excerpt) {
+            const text = document.createElement('p');
+            text.className = 'link-preview-excerpt';
+            text.textContent = preview.excerpt;
+            body.appendChild(text);
+        }
+
+        const modified = formatDate(preview.modified);
+        if (modified) {
+            const meta = document.createElement('div');
+            meta.className = 'link-preview-modified';
+            meta.textContent = t('preview.modified', 'Updated {{date}}', { date: modified });
+            body.appendChild(meta);
+        }
+
+        element.appendChild(body);
+        element.addEventListener('mouseenter', () => clearTimeout(hideTimer));
+        element.addEventListener('mouseleave', scheduleHide);
+        return element;
+    }
+
+    // position places the card below the link, or above it near the bottom
+    function position(element, link) {
+        const rect = link.getBoundingClientRect();
+        const width = element.offsetWidth;
+        const height = element.offsetHeight;
+        let left = rect.left + window.scrollX;
+        left = Math.min(left, window.scrollX + document.documentElement.clientWidth - width - 8);
+        left = Math.max(left, window.scrollX + 8);
+        let top = rect.bottom + window.scrollY + 6;
+        if (rect.bottom + height + 12 > window.innerHeight && rect.top > height + 12) {
+            top = rect.top + window.scrollY - height - 6;
+        }
+        element.style.left = left + 'px';
+        element.style.top = top + 'px';
+    }
+
+    function hide() {
+        clearTimeout(showTimer);
+        clearTimeout(hideTimer);
+        if (card) {
+            card.remove();
+            card = null;
+        }
+        if (currentLink) {
+            currentLink.removeAttribute('aria-describedby');
+            currentLink = null;
+        }
+    }
+
+    function scheduleHide() {
+        clearTimeout(showTimer);
+        clearTimeout(hideTimer);
+        hideTimer = setTimeout(hide, hideDelay);
+    }
+
+    function show(link, path) {
+        fetchPreview(path).then(preview => {
+            if (!preview || currentLink !== link) return;
+            if (card) card.remove();
+            card = buildCard(preview);
+            card.id = 'link-preview';
+            document.body.appendChild(card);
+            position(card, link);
+            link.setAttribute('aria-describedby', card.id);
+        });
+    }
+
+    content.addEventListener('mouseover', event => {
+        const link = event.target.closest('a[href]');
+        if (!link || !content.contains(link)) return;
+        if (link === currentLink) {
+            clearTimeout(hideTimer);
+            return;
+        }
+        const path = pagePath(link);
+        if (!path || content.closest('.editing')) return;
+
+        hide();
+        currentLink = link;
+        showTimer = setTimeout(() => show(link, path), showDelay);
+    });
+
+    content.addEventListener('mouseout', event => {
+        const link = event.target.closest('a[href]');
+        if (link && link === currentLink && !link.contains(event.relatedTarget)) {
+            scheduleHide();
+        }
+    });
+
+    document.addEventListener('keydown', event => {
+        if (event.key === 'Escape') hide();
+    });
+    window.addEventListener('scroll', hide, { passive: true });
+    content.addEventListener('click', hide);
+})();
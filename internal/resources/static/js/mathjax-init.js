@@ -17,7 +17,7 @@ window.MathJax = {
 document.addEventListener('DOMContentLoaded', function() {
     // Listen for custom event that indicates content has been updated
     document.addEventListener('content-updated', function(e) {
-        if (typeof MathJax !== 'undefined' && e.detail && e.detail.element) {
+        if (typeof MathJax !== 'undefined' && e.detail && e.detail.element && !e.detail.element.closest('.tex2jax_ignore')) {
             try {
                 MathJax.typesetPromise([e.detail.element]);
             } catch (error) {
@@ -0,0 +1,52 @@
+/**
+ * Mermaid settings of the wiki
+ * Themes, security level and external diagram plugins chosen in the configuration,
+ * shared by every view that renders diagrams
+ */
+
+(function() {
+    'use strict';
+
+    let settings = {};
+    try {
+        settings = JSON.parse((document.currentScript && document.currentScript.dataset.config) || '{}');
+    } catch (error) {
+        console.error('Invalid Mermaid settings:', error);
+    }
+
+    let ready = null;
+
+    // Load the plugins once and register their diagrams, before anything renders
+    function loadPlugins() {
+        if (ready) return ready;
+        const plugins = settings.plugins || [];
+        if (plugins.length === 0 || typeof mermaid === 'undefined' || !mermaid.registerExternalDiagrams) {
+            ready = Promise.resolve();
+            return ready;
+        }
+        ready = Promise.all(plugins.map(url => import(url)
+            .then(module => module.default)
+            .catch(error => {
+                console.error('Failed to load the Mermaid plugin ' + url + ':', error);
+                return null;
+            })))
+            .then(diagrams => mermaid.registerExternalDiagrams(diagrams.filter(Boolean)))
+            .catch(error => console.error('Failed to register Mermaid plugins:', error));
+        return ready;
+    }
+
+    window.MermaidConfig = {
+        // Options for mermaid.initialize with the light or dark theme, merged
+        // with those of the view
+        options: function(dark, overrides) {
+            return Object.assign({
+                theme: dark ? (settings.darkTheme || 'dark') : (settings.theme || 'default'),
+                securityLevel: settings.securityLevel || 'strict',
+                maxTextSize: settings.maxTextSize || 15000,
+                fontFamily: 'system-ui, -apple-system, sans-serif'
+            }, overrides);
+        },
+        // Resolves once the plugins are registered
+        ready: loadPlugins
+    };
+})();
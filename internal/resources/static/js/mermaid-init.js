@@ -19,14 +19,11 @@ window.MermaidHandler = {
       // Store original sources before mermaid processes them
       this._storeOriginalSources();
 
-      // Configure mermaid for main document
-      mermaid.initialize({
-        startOnLoad: true,
-        theme: isDarkMode ? 'dark' : 'default',
-        securityLevel: 'strict',
-        maxTextSize: 15000,
-        fontFamily: 'system-ui, -apple-system, sans-serif'
-      });
+      // Configure mermaid for main document, rendering once the plugins of
+      // the configuration are registered
+      mermaid.initialize(window.MermaidConfig.options(isDarkMode, { startOnLoad: false }));
+      window.MermaidConfig.ready().then(() => mermaid.run({ querySelector: '.mermaid' }))
+        .catch(error => console.error('Failed to render Mermaid diagrams:', error));
 
       console.log('Mermaid initialized for main document');
 
@@ -51,13 +48,9 @@ window.MermaidHandler = {
       const isDarkMode = document.documentElement.getAttribute('data-theme') === 'dark';
 
       // Initialize with same settings as main document
-      mermaid.initialize({
-        startOnLoad: false, // Don't start on load - we'll process diagrams manually
-        theme: isDarkMode ? 'dark' : 'default',
-        securityLevel: 'strict',
-        maxTextSize: 15000,
-        fontFamily: 'system-ui, -apple-system, sans-serif'
-      });
+      mermaid.initialize(window.MermaidConfig.options(isDarkMode, {
+        startOnLoad: false // Don't start on load - we'll process diagrams manually
+      }));
 
       // Find all mermaid diagrams in the container
       const diagrams = container.querySelectorAll('.mermaid');
@@ -123,13 +116,7 @@ window.MermaidHandler = {
 
     try {
       // Update mermaid configuration with new theme
-      mermaid.initialize({
-        startOnLoad: false,
-        theme: newTheme === 'dark' ? 'dark' : 'default',
-        securityLevel: 'strict',
-        maxTextSize: 15000,
-        fontFamily: 'system-ui, -apple-system, sans-serif'
-      });
+      mermaid.initialize(window.MermaidConfig.options(newTheme === 'dark', { startOnLoad: false }));
 
       // Find all mermaid diagrams and re-render them
       const diagrams = document.querySelectorAll('.mermaid');
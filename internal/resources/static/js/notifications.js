@@ -0,0 +1,76 @@
+/**
+ * Notifications
+ * Shows the notifications of the logged-in user (e.g. pages due for review) in the toolbar
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const menu = document.querySelector('.notifications-menu');
+        if (!menu) return;
+
+        const button = menu.querySelector('.notifications-button');
+        const counter = menu.querySelector('.notifications-count');
+        const dropdown = menu.querySelector('.notifications-dropdown');
+        const emptyMessage = dropdown.querySelector('.notifications-empty').textContent;
+
+        loadNotifications();
+
+        button.addEventListener('click', async function(e) {
+            e.stopPropagation();
+            dropdown.hidden = !dropdown.hidden;
+
+            // Opening the list marks everything as read
+            if (!dropdown.hidden && !counter.hidden) {
+                await fetch('/api/notifications', { method: 'POST' });
+                counter.hidden = true;
+            }
+        });
+
+        document.addEventListener('click', function(e) {
+            if (!menu.contains(e.target)) {
+                dropdown.hidden = true;
+            }
+        });
+
+        async function loadNotifications() {
+            try {
+                const response = await fetch('/api/notifications');
+                if (!response.ok) return;
+                const data = await response.json();
+                render(data.notifications || [], data.unread || 0);
+            } catch (error) {
+                console.error('Failed to load notifications:', error);
+            }
+        }
+
+        function render(notifications, unread) {
+            counter.textContent = unread > 99 ? '99+' : String(unread);
+            counter.hidden = unread === 0;
+
+            dropdown.innerHTML = '';
+            if (notifications.length === 0) {
+                const empty = document.createElement('div');
+                empty.className = 'notifications-empty';
+                empty.textContent = emptyMessage;
+                dropdown.appendChild(empty);
+                return;
+            }
+
+            notifications.forEach(n => {
+                const item = document.createElement(n.link ? 'a' : 'div');
+                item.className = 'notification-item' + (n.read ? '' : ' unread');
+                if (n.link) item.href = n.link;
+
+                const message = document.createElement('div');
+                message.textContent = n.message;
+                const time = document.createElement('small');
+                time.textContent = new Date(n.time).toLocaleString();
+
+                item.append(message, time);
+                dropdown.appendChild(item);
+            });
+        }
+    });
+})();
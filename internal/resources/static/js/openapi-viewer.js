@@ -0,0 +1,717 @@
+/**
+ * OpenAPI blocks
+ * Draws the API documentation of ```openapi blocks from their OpenAPI 3 or
+ * Swagger 2 document: operations grouped by tag with their parameters, request
+ * bodies and responses, the schemas of the document with generated examples,
+ * and a form sending requests to the servers of the API from the browser.
+ */
+
+(function() {
+    'use strict';
+
+    const methods = ['get', 'put', 'post', 'delete', 'options', 'head', 'patch', 'trace'];
+    const maxDepth = 8;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    // Decodes the document of a block, base64url encoded JSON
+    function decode(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const binary = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+        const bytes = Uint8Array.from(binary, char => char.charCodeAt(0));
+        return JSON.parse(new TextDecoder().decode(bytes));
+    }
+
+    function element(tag, className, text) {
+        const node = document.createElement(tag);
+        if (className) node.className = className;
+        if (text !== undefined && text !== null) node.textContent = text;
+        return node;
+    }
+
+    // Descriptions are CommonMark in OpenAPI; they are shown as text, with
+    // their paragraphs and line breaks
+    function description(text, className) {
+        return element('div', 'openapi-description' + (className ? ' ' + className : ''), String(text));
+    }
+
+    class Viewer {
+        constructor(container, spec) {
+            this.container = container;
+            this.spec = spec;
+            this.swagger = typeof spec.swagger === 'string';
+            this.servers = this.serverURLs();
+            this.server = this.servers[0];
+            this.operations = [];
+            this.render();
+        }
+
+        // Follows the local $ref of an object, such as #/components/schemas/Pet
+        deref(object) {
+            const visited = new Set();
+            let current = object;
+            while (current && typeof current === 'object' && typeof current.$ref === 'string') {
+                if (visited.has(current.$ref)) return {};
+                visited.add(current.$ref);
+                current = this.pointer(current.$ref);
+            }
+            return current || {};
+        }
+
+        pointer(ref) {
+            if (!ref.startsWith('#/')) return null;
+            return ref.slice(2).split('/').reduce((node, part) => {
+                if (node === null || typeof node !== 'object') return null;
+                return node[decodeURIComponent(part).replace(/~1/g, '/').replace(/~0/g, '~')];
+            }, this.spec);
+        }
+
+        refName(object) {
+            return object && typeof object.$ref === 'string' ? object.$ref.split('/').pop() : '';
+        }
+
+        // The base URLs requests are sent to, with server variables at their defaults
+        serverURLs() {
+            if (this.swagger) {
+                const host = this.spec.host || window.location.host;
+                const schemes = this.spec.schemes && this.spec.schemes.length
+                    ? this.spec.schemes : [window.location.protocol.replace(':', '')];
+                return schemes.map(scheme => scheme + '://' + host + (this.spec.basePath || ''));
+            }
+            const urls = (this.spec.servers || []).map(server => {
+                let url = String(server.url || '');
+                Object.entries(server.variables || {}).forEach(([name, variable]) => {
+                    url = url.split('{' + name + '}').join(variable && variable.default !== undefined ? variable.default : '');
+                });
+                return url.replace(/\/$/, '');
+            });
+            return urls.length ? urls : [''];
+        }
+
+        render() {
+            const info = this.spec.info || {};
+            this.container.textContent = '';
+            this.container.classList.add('openapi-ready');
+
+            const header = element('div', 'openapi-header');
+            const title = element('div', 'openapi-title', info.title || 'API');
+            if (info.version) title.appendChild(element('span', 'openapi-version', info.version));
+            title.appendChild(element('span', 'openapi-spec-version', this.swagger ? 'Swagger ' + this.spec.swagger : 'OpenAPI ' + this.spec.openapi));
+            header.appendChild(title);
+            if (info.description) header.appendChild(description(info.description));
+            if (this.container.dataset.source) {
+                const download = element('a', 'openapi-download', t('openapi.download', 'Download the document'));
+                download.href = this.container.dataset.source;
+                download.setAttribute('download', '');
+                header.appendChild(download);
+            }
+            this.container.appendChild(header);
+            this.container.appendChild(this.renderToolbar());
+
+            this.list = element('div', 'openapi-operations');
+            this.renderOperations();
+            this.container.appendChild(this.list);
+
+            const models = this.renderModels();
+            if (models) this.container.appendChild(models);
+        }
+
+        renderToolbar() {
+            const toolbar = element('div', 'openapi-toolbar');
+
+            const filter = element('input', 'openapi-filter');
+            filter.type = 'search';
+            filter.placeholder = t('openapi.filter', 'Filter operations');
+            filter.setAttribute('aria-label', filter.placeholder);
+            filter.addEventListener('input', () => this.filter(filter.value));
+            toolbar.appendChild(filter);
+
+            if (this.servers.length > 1 || this.servers[0]) {
+                const label = element('label', 'openapi-server');
+                label.appendChild(element('span', '', t('openapi.server', 'Server')));
+                const select = element('select');
+                this.servers.forEach(url => {
+                    const option = element('option', '', url || window.location.origin);
+                    option.value = url;
+                    select.appendChild(option);
+                });
+                select.addEventListener('change', () => { this.server = select.value; });
+                label.appendChild(select);
+                toolbar.appendChild(label);
+            }
+
+            const expand = element('button', 'openapi-button', t('openapi.expand_all', 'Expand all'));
+            expand.type = 'button';
+            expand.addEventListener('click', () => this.toggleAll(true));
+            const collapse = element('button', 'openapi-button', t('openapi.collapse_all', 'Collapse all'));
+            collapse.type = 'button';
+            collapse.addEventListener('click', () => this.toggleAll(false));
+            toolbar.appendChild(expand);
+            toolbar.appendChild(collapse);
+            return toolbar;
+        }
+
+        // The operations of the document grouped by their first tag, in the
+        // order of the tags of the document and then of the paths
+        groups() {
+            const groups = new Map();
+            (this.spec.tags || []).forEach(tag => {
+                if (tag && tag.name) groups.set(tag.name, { tag: tag, entries: [] });
+            });
+            const other = t('openapi.other', 'Other');
+            Object.entries(this.spec.paths || {}).forEach(([path, item]) => {
+                const pathItem = this.deref(item);
+                methods.forEach(method => {
+                    const operation = pathItem[method];
+                    if (!operation || typeof operation !== 'object') return;
+                    const name = (operation.tags && operation.tags[0]) || other;
+                    if (!groups.has(name)) groups.set(name, { tag: { name: name }, entries: [] });
+                    groups.get(name).entries.push({ path: path, method: method, operation: operation, pathItem: pathItem });
+                });
+            });
+            return Array.from(groups.values()).filter(group => group.entries.length > 0);
+        }
+
+        renderOperations() {
+            const groups = this.groups();
+            if (groups.length === 0) {
+                this.list.appendChild(element('p', 'openapi-empty', t('openapi.no_operations', 'This API has no operations.')));
+                return;
+            }
+            groups.forEach(group => {
+                const section = element('section', 'openapi-tag');
+                section.appendChild(element('div', 'openapi-tag-name', group.tag.name));
+                if (group.tag.description) section.appendChild(description(group.tag.description));
+                group.entries.forEach(entry => {
+                    const details = this.renderOperation(entry);
+                    section.appendChild(details);
+                    this.operations.push({ details: details, section: section, entry: entry });
+                });
+                this.list.appendChild(section);
+            });
+        }
+
+        renderOperation(entry) {
+            const operation = entry.operation;
+            const details = element('details', 'openapi-operation openapi-' + entry.method);
+            if (operation.deprecated) details.classList.add('openapi-deprecated');
+            const summary = element('summary');
+            summary.appendChild(element('span', 'openapi-method', entry.method.toUpperCase()));
+            summary.appendChild(element('code', 'openapi-path', entry.path));
+            if (operation.summary) summary.appendChild(element('span', 'openapi-summary', operation.summary));
+            if (operation.deprecated) summary.appendChild(element('span', 'openapi-badge', t('openapi.deprecated', 'Deprecated')));
+            details.appendChild(summary);
+
+            // The content of an operation is drawn the first time it is opened
+            details.addEventListener('toggle', () => {
+                if (details.open && !details.dataset.rendered) {
+                    details.dataset.rendered = 'true';
+                    details.appendChild(this.renderOperationBody(entry));
+                }
+            });
+            return details;
+        }
+
+        // The parameters of an operation, with those of its path it doesn't override
+        parameters(entry) {
+            const parameters = new Map();
+            (entry.pathItem.parameters || []).concat(entry.operation.parameters || []).forEach(item => {
+                const parameter = this.deref(item);
+                if (parameter.name) parameters.set(parameter.in + ':' + parameter.name, parameter);
+            });
+            return Array.from(parameters.values());
+        }
+
+        // The request body of an operation: its media types and their schemas.
+        // Swagger 2 gives it as a body parameter, or as form parameters.
+        requestBody(entry, parameters) {
+            if (!this.swagger) {
+                const body = this.deref(entry.operation.requestBody);
+                if (!body.content) return null;
+                return {
+                    description: body.description,
+                    required: body.required,
+                    content: Object.entries(body.content).map(([type, media]) => ({ type: type, schema: (media || {}).schema, example: this.mediaExample(media) }))
+                };
+            }
+            const consumes = entry.operation.consumes || this.spec.consumes || ['application/json'];
+            const bodyParameter = parameters.find(parameter => parameter.in === 'body');
+            if (bodyParameter) {
+                return {
+                    description: bodyParameter.description,
+                    required: bodyParameter.required,
+                    content: consumes.map(type => ({ type: type, schema: bodyParameter.schema }))
+                };
+            }
+            const form = parameters.filter(parameter => parameter.in === 'formData');
+            if (form.length === 0) return null;
+            const schema = { type: 'object', properties: {}, required: [] };
+            form.forEach(parameter => {
+                schema.properties[parameter.name] = Object.assign({ description: parameter.description }, parameter);
+                if (parameter.required) schema.required.push(parameter.name);
+            });
+            return { content: [{ type: consumes.find(type => type.includes('form')) || 'application/x-www-form-urlencoded', schema: schema }] };
+        }
+
+        mediaExample(media) {
+            if (!media) return undefined;
+            if (media.example !== undefined) return media.example;
+            const examples = Object.values(media.examples || {}).map(example => this.deref(example));
+            return examples.length && examples[0].value !== undefined ? examples[0].value : undefined;
+        }
+
+        renderOperationBody(entry) {
+            const operation = entry.operation;
+            const body = element('div', 'openapi-operation-body');
+            if (operation.description) body.appendChild(description(operation.description));
+            if (operation.operationId) {
+                const id = element('div', 'openapi-operation-id', 'operationId: ');
+                id.appendChild(element('code', '', operation.operationId));
+                body.appendChild(id);
+            }
+
+            const parameters = this.parameters(entry);
+            const listed = parameters.filter(parameter => parameter.in !== 'body' && parameter.in !== 'formData');
+            if (listed.length > 0) {
+                body.appendChild(element('div', 'openapi-section', t('openapi.parameters', 'Parameters')));
+                body.appendChild(this.renderParameters(listed));
+            }
+
+            const requestBody = this.requestBody(entry, parameters);
+            if (requestBody) {
+                const heading = element('div', 'openapi-section', t('openapi.request_body', 'Request body'));
+                if (requestBody.required) heading.appendChild(element('span', 'openapi-required', ' *'));
+                body.appendChild(heading);
+                if (requestBody.description) body.appendChild(description(requestBody.description));
+                requestBody.content.forEach(media => body.appendChild(this.renderMedia(media)));
+            }
+
+            const responses = Object.entries(operation.responses || {});
+            if (responses.length > 0) {
+                body.appendChild(element('div', 'openapi-section', t('openapi.responses', 'Responses')));
+                body.appendChild(this.renderResponses(responses, entry));
+            }
+
+            body.appendChild(this.renderTryIt(entry, listed, requestBody));
+            return body;
+        }
+
+        renderParameters(parameters) {
+            const table = element('table', 'openapi-parameters');
+            const head = element('tr');
+            [t('openapi.name', 'Name'), t('openapi.location', 'In'), t('openapi.type', 'Type'), t('openapi.description', 'Description')]
+                .forEach(text => head.appendChild(element('th', '', text)));
+            table.appendChild(element('thead')).appendChild(head);
+            const rows = element('tbody');
+            parameters.forEach(parameter => {
+                const row = element('tr');
+                const name = element('td');
+                name.appendChild(element('code', '', parameter.name));
+                if (parameter.required) name.appendChild(element('span', 'openapi-required', ' *'));
+                if (parameter.deprecated) name.appendChild(element('span', 'openapi-badge', t('openapi.deprecated', 'Deprecated')));
+                row.appendChild(name);
+                row.appendChild(element('td', '', parameter.in));
+                row.appendChild(element('td', '', this.typeName(parameter.schema || parameter)));
+                const cell = element('td');
+                if (parameter.description) cell.appendChild(description(parameter.description));
+                const schema = this.deref(parameter.schema || parameter);
+                if (Array.isArray(schema.enum)) cell.appendChild(element('div', 'openapi-enum', schema.enum.map(value => JSON.stringify(value)).join(', ')));
+                row.appendChild(cell);
+                rows.appendChild(row);
+            });
+            table.appendChild(rows);
+            return table;
+        }
+
+        renderResponses(responses, entry) {
+            const list = element('div', 'openapi-responses');
+            const produces = entry.operation.produces || this.spec.produces || ['application/json'];
+            responses.forEach(([status, item]) => {
+                const response = this.deref(item);
+                const block = element('div', 'openapi-response');
+                const line = element('div', 'openapi-response-line');
+                line.appendChild(element('span', 'openapi-status openapi-status-' + String(status).charAt(0), status));
+                if (response.description) line.appendChild(element('span', 'openapi-response-description', response.description));
+                block.appendChild(line);
+                if (this.swagger) {
+                    if (response.schema) produces.forEach(type => block.appendChild(this.renderMedia({ type: type, schema: response.schema, example: (response.examples || {})[type] })));
+                } else {
+                    Object.entries(response.content || {}).forEach(([type, media]) => {
+                        block.appendChild(this.renderMedia({ type: type, schema: (media || {}).schema, example: this.mediaExample(media) }));
+                    });
+                }
+                list.appendChild(block);
+            });
+            return list;
+        }
+
+        // A media type of a body: its schema and an example, written in the
+        // document or made from the schema
+        renderMedia(media) {
+            const block = element('div', 'openapi-media');
+            block.appendChild(element('code', 'openapi-media-type', media.type));
+            if (!media.schema) return block;
+            block.appendChild(this.renderSchema(media.schema, 0, new Set()));
+            const example = media.example !== undefined ? media.example : this.example(media.schema, 0, new Set());
+            if (example !== undefined) {
+                const details = element('details', 'openapi-example');
+                details.appendChild(element('summary', '', t('openapi.example', 'Example')));
+                details.appendChild(element('pre', '', typeof example === 'string' ? example : JSON.stringify(example, null, 2)));
+                block.appendChild(details);
+            }
+            return block;
+        }
+
+        typeName(schemaOrRef) {
+            const name = this.refName(schemaOrRef);
+            const schema = this.deref(schemaOrRef);
+            if (schema.type === 'array' || (!schema.type && schema.items)) {
+                return this.typeName(schema.items || {}) + '[]';
+            }
+            let type = name || (Array.isArray(schema.type) ? schema.type.join(' | ') : schema.type) || '';
+            if (!type && schema.oneOf) type = 'oneOf';
+            if (!type && schema.anyOf) type = 'anyOf';
+            if (!type && (schema.properties || schema.allOf)) type = 'object';
+            if (schema.format && !name) type += ' (' + schema.format + ')';
+            if (schema.nullable) type += ' | null';
+            return type || 'any';
+        }
+
+        // The properties of a schema, merging those of its allOf schemas
+        properties(schema, seen) {
+            const properties = {};
+            let required = Array.isArray(schema.required) ? schema.required.slice() : [];
+            (schema.allOf || []).forEach(part => {
+                const ref = part && part.$ref;
+                if (ref && seen.has(ref)) return;
+                const merged = this.properties(this.deref(part), ref ? new Set(seen).add(ref) : seen);
+                Object.assign(properties, merged.properties);
+                required = required.concat(merged.required);
+            });
+            Object.assign(properties, schema.properties || {});
+            return { properties: properties, required: required };
+        }
+
+        // A tree of the properties of a schema. Schemas met again deeper in
+        // the tree, like the children of a node, are only named.
+        renderSchema(schemaOrRef, depth, seen) {
+            const ref = schemaOrRef && schemaOrRef.$ref;
+            const schema = this.deref(schemaOrRef);
+            const tree = element('div', 'openapi-schema');
+            const type = element('div', 'openapi-schema-type', this.typeName(schemaOrRef));
+            tree.appendChild(type);
+            if ((ref && seen.has(ref)) || depth >= maxDepth) return tree;
+            const inner = ref ? new Set(seen).add(ref) : seen;
+
+            const target = schema.type === 'array' || (!schema.type && schema.items) ? this.deref(schema.items || {}) : schema;
+            const targetRef = schema.items && schema.items.$ref;
+            if (targetRef && inner.has(targetRef)) return tree;
+            const nested = targetRef ? new Set(inner).add(targetRef) : inner;
+
+            if (Array.isArray(target.enum)) tree.appendChild(element('div', 'openapi-enum', target.enum.map(value => JSON.stringify(value)).join(', ')));
+            ['oneOf', 'anyOf'].forEach(key => {
+                if (!Array.isArray(target[key])) return;
+                const list = element('ul', 'openapi-variants');
+                target[key].forEach(variant => {
+                    const item = element('li');
+                    item.appendChild(element('span', 'openapi-variant-label', key));
+                    item.appendChild(this.renderSchema(variant, depth + 1, nested));
+                    list.appendChild(item);
+                });
+                tree.appendChild(list);
+            });
+
+            const merged = this.properties(target, nested);
+            const names = Object.keys(merged.properties);
+            if (names.length > 0) {
+                const list = element('ul', 'openapi-properties');
+                names.forEach(name => {
+                    const property = merged.properties[name];
+                    const resolved = this.deref(property);
+                    const item = element('li');
+                    item.appendChild(element('code', 'openapi-property', name));
+                    if (merged.required.includes(name)) item.appendChild(element('span', 'openapi-required', ' *'));
+                    if (resolved.readOnly) item.appendChild(element('span', 'openapi-badge', 'read-only'));
+                    if (resolved.writeOnly) item.appendChild(element('span', 'openapi-badge', 'write-only'));
+                    if (resolved.deprecated) item.appendChild(element('span', 'openapi-badge', t('openapi.deprecated', 'Deprecated')));
+                    if (resolved.description) item.appendChild(description(resolved.description, 'openapi-property-description'));
+                    item.appendChild(this.renderSchema(property, depth + 1, nested));
+                    list.appendChild(item);
+                });
+                tree.appendChild(list);
+            }
+            return tree;
+        }
+
+        // An example value of a schema: its example or default, or one made
+        // from its type
+        example(schemaOrRef, depth, seen) {
+            const ref = schemaOrRef && schemaOrRef.$ref;
+            if ((ref && seen.has(ref)) || depth >= maxDepth) return undefined;
+            const inner = ref ? new Set(seen).add(ref) : seen;
+            const schema = this.deref(schemaOrRef);
+            if (schema.example !== undefined) return schema.example;
+            if (Array.isArray(schema.examples) && schema.examples.length) return schema.examples[0];
+            if (schema.default !== undefined) return schema.default;
+            if (Array.isArray(schema.enum) && schema.enum.length) return schema.enum[0];
+            const variants = schema.oneOf || schema.anyOf;
+            if (Array.isArray(variants) && variants.length) return this.example(variants[0], depth + 1, inner);
+
+            const type = Array.isArray(schema.type) ? schema.type.find(item => item !== 'null') : schema.type;
+            if (type === 'array' || (!type && schema.items)) {
+                const item = this.example(schema.items || {}, depth + 1, inner);
+                return item === undefined ? [] : [item];
+            }
+            if (type === 'object' || (!type && (schema.properties || schema.allOf))) {
+                const merged = this.properties(schema, inner);
+                const value = {};
+                Object.entries(merged.properties).forEach(([name, property]) => {
+                    const example = this.example(property, depth + 1, inner);
+                    if (example !== undefined) value[name] = example;
+                });
+                return value;
+            }
+            switch (type) {
+            case 'string':
+                return {
+                    'date-time': new Date(0).toISOString(), 'date': '1970-01-01', 'email': 'user@example.com',
+                    'uuid': '3fa85f64-5717-4562-b3fc-2c963f66afa6', 'uri': 'https://example.com', 'password': '********'
+                }[schema.format] || 'string';
+            case 'integer':
+                return schema.minimum !== undefined ? schema.minimum : 0;
+            case 'number':
+                return schema.minimum !== undefined ? schema.minimum : 0;
+            case 'boolean':
+                return true;
+            }
+            return undefined;
+        }
+
+        // The security schemes an operation uses that the form can fill in
+        securitySchemes(entry) {
+            const requirements = entry.operation.security || this.spec.security || [];
+            const definitions = this.swagger ? (this.spec.securityDefinitions || {})
+                : ((this.spec.components || {}).securitySchemes || {});
+            const schemes = [];
+            requirements.forEach(requirement => Object.keys(requirement || {}).forEach(name => {
+                const scheme = this.deref(definitions[name]);
+                if (schemes.some(item => item.name === name)) return;
+                if (scheme.type === 'apiKey' && (scheme.in === 'header' || scheme.in === 'query')) {
+                    schemes.push({ name: name, kind: 'apiKey', in: scheme.in, key: scheme.name });
+                } else if ((scheme.type === 'http' && /^bearer$/i.test(scheme.scheme || '')) || scheme.type === 'oauth2' || scheme.type === 'openIdConnect') {
+                    schemes.push({ name: name, kind: 'bearer' });
+                } else if ((scheme.type === 'http' && /^basic$/i.test(scheme.scheme || '')) || scheme.type === 'basic') {
+                    schemes.push({ name: name, kind: 'basic' });
+                }
+            }));
+            return schemes;
+        }
+
+        // The form sending a request to the API from the browser. Values of
+        // credentials are only kept in the form.
+        renderTryIt(entry, parameters, requestBody) {
+            const details = element('details', 'openapi-try');
+            details.appendChild(element('summary', '', t('openapi.try_it', 'Try it out')));
+            const form = element('form', 'openapi-try-form');
+            const inputs = [];
+
+            const field = (labelText, input, hint) => {
+                const label = element('label', 'openapi-field');
+                label.appendChild(element('span', 'openapi-field-label', labelText));
+                label.appendChild(input);
+                if (hint) label.appendChild(element('span', 'openapi-field-hint', hint));
+                form.appendChild(label);
+            };
+
+            this.securitySchemes(entry).forEach(scheme => {
+                const input = element('input');
+                input.type = 'password';
+                input.autocomplete = 'off';
+                const hint = scheme.kind === 'basic' ? 'user:password' : scheme.kind === 'bearer' ? 'Bearer' : scheme.in + ' ' + scheme.key;
+                field(t('openapi.authorization', 'Authorization') + ': ' + scheme.name, input, hint);
+                inputs.push({ scheme: scheme, input: input });
+            });
+
+            parameters.filter(parameter => parameter.in !== 'cookie').forEach(parameter => {
+                const schema = this.deref(parameter.schema || parameter);
+                let input;
+                if (Array.isArray(schema.enum)) {
+                    input = element('select');
+                    if (!parameter.required) input.appendChild(element('option', '', ''));
+                    schema.enum.forEach(value => {
+                        const option = element('option', '', String(value));
+                        option.value = String(value);
+                        input.appendChild(option);
+                    });
+                } else {
+                    input = element('input');
+                    input.type = 'text';
+                    const example = parameter.example !== undefined ? parameter.example : schema.example !== undefined ? schema.example : schema.default;
+                    if (example !== undefined) input.value = String(example);
+                }
+                input.required = !!parameter.required;
+                field(parameter.name + (parameter.required ? ' *' : ''), input, parameter.in);
+                inputs.push({ parameter: parameter, input: input });
+            });
+
+            let bodyInput = null;
+            let typeSelect = null;
+            if (requestBody && requestBody.content.length > 0) {
+                typeSelect = element('select');
+                requestBody.content.forEach(media => {
+                    const option = element('option', '', media.type);
+                    option.value = media.type;
+                    typeSelect.appendChild(option);
+                });
+                field(t('openapi.content_type', 'Content type'), typeSelect);
+                bodyInput = element('textarea', 'openapi-try-body');
+                bodyInput.rows = 8;
+                bodyInput.spellcheck = false;
+                const fill = () => {
+                    const media = requestBody.content.find(item => item.type === typeSelect.value) || requestBody.content[0];
+                    const example = media.example !== undefined ? media.example : (media.schema ? this.example(media.schema, 0, new Set()) : undefined);
+                    bodyInput.value = example === undefined ? '' : typeof example === 'string' ? example : JSON.stringify(example, null, 2);
+                };
+                typeSelect.addEventListener('change', fill);
+                fill();
+                field(t('openapi.request_body', 'Request body'), bodyInput);
+            }
+
+            const send = element('button', 'openapi-button openapi-send', t('openapi.send', 'Send'));
+            send.type = 'submit';
+            form.appendChild(send);
+            const result = element('div', 'openapi-try-result');
+            result.setAttribute('aria-live', 'polite');
+            form.appendChild(result);
+
+            form.addEventListener('submit', event => {
+                event.preventDefault();
+                this.send(entry, inputs, typeSelect, bodyInput, result, send);
+            });
+            details.appendChild(form);
+            return details;
+        }
+
+        async send(entry, inputs, typeSelect, bodyInput, result, button) {
+            let path = entry.path;
+            const query = new URLSearchParams();
+            const headers = new Headers();
+            inputs.forEach(item => {
+                const value = item.input.value;
+                if (value === '') return;
+                if (item.scheme) {
+                    const scheme = item.scheme;
+                    if (scheme.kind === 'bearer') headers.set('Authorization', 'Bearer ' + value);
+                    else if (scheme.kind === 'basic') headers.set('Authorization', 'Basic ' + btoa(value));
+                    else if (scheme.in === 'header') headers.set(scheme.key, value);
+                    else query.append(scheme.key, value);
+                    return;
+                }
+                const parameter = item.parameter;
+                if (parameter.in === 'path') path = path.split('{' + parameter.name + '}').join(encodeURIComponent(value));
+                else if (parameter.in === 'query') query.append(parameter.name, value);
+                else if (parameter.in === 'header') headers.set(parameter.name, value);
+            });
+
+            const options = { method: entry.method.toUpperCase(), headers: headers };
+            if (bodyInput && bodyInput.value !== '' && !['GET', 'HEAD'].includes(options.method)) {
+                headers.set('Content-Type', typeSelect.value);
+                options.body = bodyInput.value;
+            }
+            const search = query.toString();
+            const url = this.server + path + (search ? '?' + search : '');
+
+            result.textContent = '';
+            button.disabled = true;
+            const started = performance.now();
+            try {
+                const response = await fetch(url, options);
+                let text = await response.text();
+                try {
+                    text = JSON.stringify(JSON.parse(text), null, 2);
+                } catch (error) {
+                    // Not JSON, shown as it is
+                }
+                const line = element('div', 'openapi-response-line');
+                line.appendChild(element('span', 'openapi-status openapi-status-' + String(response.status).charAt(0), String(response.status)));
+                line.appendChild(element('span', 'openapi-response-description', response.statusText + ' · ' + Math.round(performance.now() - started) + ' ms'));
+                result.appendChild(element('code', 'openapi-request-url', options.method + ' ' + url));
+                result.appendChild(line);
+                if (text) result.appendChild(element('pre', '', text));
+            } catch (error) {
+                result.appendChild(element('code', 'openapi-request-url', options.method + ' ' + url));
+                result.appendChild(element('div', 'openapi-try-error',
+                    t('openapi.request_failed', 'The request failed. The API must allow requests from this wiki (CORS), and the wiki from the API (connect sources of the security headers).') + ' ' + error.message));
+            } finally {
+                button.disabled = false;
+            }
+        }
+
+        renderModels() {
+            const schemas = this.swagger ? this.spec.definitions : (this.spec.components || {}).schemas;
+            const names = Object.keys(schemas || {});
+            if (names.length === 0) return null;
+            const section = element('details', 'openapi-models');
+            section.appendChild(element('summary', 'openapi-tag-name', t('openapi.models', 'Schemas')));
+            const prefix = this.swagger ? '#/definitions/' : '#/components/schemas/';
+            names.forEach(name => {
+                const model = element('details', 'openapi-model');
+                model.appendChild(element('summary', '', name));
+                model.addEventListener('toggle', () => {
+                    if (!model.open || model.dataset.rendered) return;
+                    model.dataset.rendered = 'true';
+                    const schema = schemas[name];
+                    if (schema && schema.description) model.appendChild(description(schema.description));
+                    model.appendChild(this.renderSchema({ $ref: prefix + name.replace(/~/g, '~0').replace(/\//g, '~1') }, 0, new Set()));
+                });
+                section.appendChild(model);
+            });
+            return section;
+        }
+
+        filter(text) {
+            const words = text.toLowerCase().split(/\s+/).filter(Boolean);
+            const visible = new Map();
+            this.operations.forEach(item => {
+                const entry = item.entry;
+                const haystack = [entry.method, entry.path, entry.operation.summary || '', entry.operation.operationId || '',
+                    (entry.operation.tags || []).join(' ')].join(' ').toLowerCase();
+                const match = words.every(word => haystack.includes(word));
+                item.details.hidden = !match;
+                visible.set(item.section, (visible.get(item.section) || false) || match);
+            });
+            visible.forEach((match, section) => { section.hidden = !match; });
+        }
+
+        toggleAll(open) {
+            this.operations.forEach(item => {
+                if (!item.details.hidden) item.details.open = open;
+            });
+        }
+    }
+
+    function load(container) {
+        if (container.dataset.openapiLoaded) return;
+        container.dataset.openapiLoaded = 'true';
+        try {
+            new Viewer(container, decode(container.dataset.spec));
+        } catch (error) {
+            console.error('Failed to draw the API documentation:', error);
+            const message = element('div', 'openapi-error', t('openapi.invalid', 'The API documentation could not be drawn') + ': ' + error.message);
+            container.insertBefore(message, container.firstChild);
+        }
+    }
+
+    function init() {
+        document.querySelectorAll('.openapi[data-spec]').forEach(load);
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', init);
+    } else {
+        init();
+    }
+})();
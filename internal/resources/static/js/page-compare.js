@@ -0,0 +1,191 @@
+/**
+ * Compare pages
+ * Shows the differences between any two pages, to consolidate duplicated
+ * documents or check environment-specific runbooks against each other: their
+ * rendered blocks marked as removed or added, and the changed lines of their
+ * markdown.
+ */
+
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.compare-dialog');
+    if (!dialog) return;
+
+    const form = dialog.querySelector('.compare-form');
+    const oldInput = dialog.querySelector('#compareOld');
+    const newInput = dialog.querySelector('#compareNew');
+    const pageList = dialog.querySelector('#comparePages');
+    const result = dialog.querySelector('.compare-result');
+    const summary = dialog.querySelector('.compare-summary');
+    const rendered = dialog.querySelector('.compare-rendered');
+    const source = dialog.querySelector('.compare-source');
+    let pagesLoaded = false;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/\/+$/, '') || '/';
+    }
+
+    // loadPages suggests the pages the user may see in the page fields
+    async function loadPages() {
+        if (pagesLoaded) return;
+        pagesLoaded = true;
+        try {
+            const response = await fetch('/api/graph', { credentials: 'same-origin' });
+            if (!response.ok) return;
+            const graph = await response.json();
+            (graph.nodes || []).forEach(node => {
+                const option = document.createElement('option');
+                option.value = '/' + node.id;
+                option.label = node.title;
+                pageList.appendChild(option);
+            });
+        } catch (error) {
+            console.error('Error loading the pages to compare:', error);
+        }
+    }
+
+    function showView(view) {
+        dialog.querySelectorAll('.compare-tab').forEach(tab => {
+            const active = tab.dataset.view === view;
+            tab.classList.toggle('active', active);
+            tab.setAttribute('aria-selected', active ? 'true' : 'false');
+        });
+        rendered.hidden = view !== 'rendered';
+        source.hidden = view !== 'source';
+    }
+
+    // renderSource lists the changed lines of the markdown with the unchanged
+    // lines around them, numbered in both pages
+    function renderSource(hunks) {
+        source.innerHTML = '';
+        const marks = { equal: ' ', delete: '-', insert: '+' };
+        hunks.forEach(hunk => {
+            const table = document.createElement('table');
+            table.className = 'compare-hunk';
+            const caption = document.createElement('caption');
+            caption.textContent = `@@ -${hunk.oldStart},${hunk.oldLines} +${hunk.newStart},${hunk.newLines} @@`;
+            table.appendChild(caption);
+            hunk.lines.forEach(line => {
+                const row = table.insertRow();
+                row.className = 'compare-line compare-' + line.kind;
+                row.insertCell().textContent = line.old || '';
+                row.insertCell().textContent = line.new || '';
+                const text = row.insertCell();
+                text.textContent = marks[line.kind] + ' ' + line.text;
+                text.dir = 'auto';
+            });
+            source.appendChild(table);
+        });
+    }
+
+    // enhance runs the renderers of diagrams, math and code on the rendered
+    // comparison, as on a page
+    function enhance(element) {
+        if (typeof Prism !== 'undefined') {
+            element.querySelectorAll('pre code').forEach(block => Prism.highlightElement(block));
+        }
+        if (typeof MathJax !== 'undefined' && MathJax.typesetPromise) {
+            MathJax.typesetPromise([element]).catch(error => console.error('MathJax error:', error));
+        }
+        if (typeof mermaid !== 'undefined' && window.MermaidHandler) {
+            window.MermaidHandler.initVersionPreview(element);
+        }
+        if (window.ABCNotation) {
+            window.ABCNotation.render(element);
+        }
+    }
+
+    async function compare() {
+        showError('');
+        const params = new URLSearchParams({ old: oldInput.value.trim(), new: newInput.value.trim() });
+        try {
+            const response = await fetch('/api/compare?' + params.toString(), { credentials: 'same-origin' });
+            const data = await response.json();
+            if (!response.ok) {
+                throw new Error(data.message + (data.error ? ': ' + data.error : ''));
+            }
+
+            const title = page => `${page.title} (${page.path})`;
+            if (data.hunks.length === 0) {
+                summary.textContent = t('compare.identical', 'The pages are identical');
+            } else {
+                summary.textContent = t('compare.summary', '{old} → {new}: {inserted} lines added, {deleted} removed')
+                    .replace('{old}', title(data.old))
+                    .replace('{new}', title(data.new))
+                    .replace('{inserted}', data.inserted)
+                    .replace('{deleted}', data.deleted);
+            }
+            rendered.innerHTML = data.rendered;
+            renderSource(data.hunks);
+            result.hidden = false;
+            enhance(rendered);
+        } catch (error) {
+            console.error('Error comparing pages:', error);
+            showError(t('compare.failed', 'The pages could not be compared') + ': ' + error.message);
+            result.hidden = true;
+        }
+    }
+
+    function openDialog(oldPath, newPath) {
+        oldInput.value = oldPath || currentPath();
+        newInput.value = newPath || '';
+        result.hidden = true;
+        showError('');
+        dialog.classList.add('active');
+        loadPages();
+        if (oldPath && newPath) {
+            compare();
+        } else {
+            newInput.focus();
+        }
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    form.addEventListener('submit', event => {
+        event.preventDefault();
+        compare();
+    });
+    dialog.querySelector('.compare-swap').addEventListener('click', () => {
+        [oldInput.value, newInput.value] = [newInput.value, oldInput.value];
+        if (!result.hidden) compare();
+    });
+    dialog.querySelectorAll('.compare-tab').forEach(tab => {
+        tab.addEventListener('click', () => showView(tab.dataset.view));
+    });
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('keydown', event => {
+        if (event.key !== 'Escape') return;
+        event.stopPropagation();
+        closeDialog();
+    });
+
+    if (window.CommandPalette) {
+        window.CommandPalette.register({
+            id: 'compare-pages',
+            title: () => t('compare.open', 'Compare with another page'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'diff compare differences duplicate consolidate',
+            icon: 'fa-exchange',
+            run: () => openDialog()
+        });
+    }
+
+    window.PageCompare = {
+        open: openDialog
+    };
+})();
@@ -0,0 +1,233 @@
+/**
+ * Pages blocks
+ * Looks up the pages matching a ```pages block in the page metadata API when
+ * the page is viewed, and shows them as a table or a list. The lists are
+ * refreshed when the reader comes back to the page, so index pages keep
+ * themselves up to date. Computed columns and groups are worked out by the
+ * server.
+ */
+
+(function() {
+    'use strict';
+
+    // Refresh the lists this often while the page is shown
+    const refreshInterval = 5 * 60 * 1000;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    const columnTitles = {
+        title: () => t('pages_query.title', 'Page'),
+        path: () => t('pages_query.path', 'Path'),
+        modified: () => t('pages_query.modified', 'Modified'),
+        last_editor: () => t('pages_query.last_editor', 'Last edited by'),
+        owners: () => t('pages_query.owners', 'Owners'),
+        tags: () => t('pages_query.tags', 'Tags'),
+        review_every: () => t('pages_query.review_every', 'Review every'),
+        last_reviewed: () => t('pages_query.last_reviewed', 'Last reviewed'),
+        review_due: () => t('pages_query.review_due', 'Review due')
+    };
+
+    function formatDate(value) {
+        return value ? new Date(value).toLocaleDateString() : '';
+    }
+
+    // Frontmatter values as text: lists joined, dates without midnight
+    function formatValue(value) {
+        if (value === null || value === undefined) return '';
+        if (Array.isArray(value)) return value.map(formatValue).join(', ');
+        if (typeof value === 'object') return JSON.stringify(value);
+        const text = String(value);
+        return /^\d{4}-\d{2}-\d{2}T00:00:00Z$/.test(text) ? text.slice(0, 10) : text;
+    }
+
+    function link(href, text, className) {
+        const anchor = document.createElement('a');
+        anchor.href = href;
+        anchor.textContent = text;
+        if (className) anchor.className = className;
+        return anchor;
+    }
+
+    // Computed true/false values as a badge
+    function badge(value) {
+        const span = document.createElement('span');
+        span.className = 'pages-query-badge ' + (value ? 'pages-query-yes' : 'pages-query-no');
+        span.textContent = value ? t('pages_query.yes', 'Yes') : t('pages_query.no', 'No');
+        return span;
+    }
+
+    // Computed numbers with at most two decimals
+    function formatComputed(value) {
+        if (typeof value === 'boolean') return badge(value);
+        if (typeof value === 'number') return String(Math.round(value * 100) / 100);
+        return formatValue(value);
+    }
+
+    // The content of a column for a page, a node or text
+    function cell(page, column) {
+        if (page.computed && column in page.computed) {
+            return formatComputed(page.computed[column]);
+        }
+        const review = page.review || {};
+        switch (column) {
+            case 'title':
+                return link(page.path, page.title);
+            case 'path':
+                return page.path;
+            case 'modified':
+                return formatDate(page.modified);
+            case 'last_editor': {
+                if (!page.lastEditor) return '';
+                const user = link('/?user=' + encodeURIComponent(page.lastEditor), page.lastEditor, 'user-link');
+                user.dataset.user = page.lastEditor;
+                return user;
+            }
+            case 'owners':
+                return page.owners.join(', ');
+            case 'tags':
+                return page.tags.join(', ');
+            case 'review_every':
+                return review.every || '';
+            case 'last_reviewed':
+                return formatDate(review.lastReviewed);
+            case 'review_due': {
+                if (!review.due) return '';
+                const due = document.createElement('span');
+                due.textContent = formatDate(review.due);
+                if (review.overdue) due.className = 'pages-query-overdue';
+                return due;
+            }
+        }
+        const text = formatValue(page.frontmatter[column]);
+        return /^https?:\/\/\S+$/.test(text) ? link(text, text) : text;
+    }
+
+    // The columns of a block, a JSON list or, in pages rendered before
+    // computed columns, names separated by commas
+    function parseColumns(value) {
+        try {
+            return JSON.parse(value);
+        } catch (error) {
+            return value.split(',').filter(Boolean);
+        }
+    }
+
+    // Consecutive pages of the same group, in the order the server sorted them
+    function groupPages(pages) {
+        const groups = [];
+        pages.forEach(page => {
+            const name = page.group || '';
+            if (groups.length === 0 || groups[groups.length - 1].name !== name) {
+                groups.push({ name, pages: [] });
+            }
+            groups[groups.length - 1].pages.push(page);
+        });
+        return groups;
+    }
+
+    function renderTable(pages, columns) {
+        const table = document.createElement('table');
+        const head = table.createTHead().insertRow();
+        columns.forEach(column => {
+            const heading = document.createElement('th');
+            heading.textContent = columnTitles[column] ? columnTitles[column]() : column;
+            head.appendChild(heading);
+        });
+        const body = table.createTBody();
+        pages.forEach(page => {
+            const row = body.insertRow();
+            columns.forEach(column => row.insertCell().append(cell(page, column)));
+        });
+        return table;
+    }
+
+    // A list of page titles, followed by the other columns
+    function renderList(pages, columns) {
+        const list = document.createElement('ul');
+        list.className = 'pages-query-list';
+        pages.forEach(page => {
+            const item = document.createElement('li');
+            item.appendChild(link(page.path, page.title));
+            const details = columns
+                .filter(column => column !== 'title')
+                .map(column => cell(page, column))
+                .filter(value => value !== '');
+            if (details.length > 0) {
+                const meta = document.createElement('span');
+                meta.className = 'pages-query-meta';
+                details.forEach((value, i) => {
+                    if (i > 0) meta.append(' · ');
+                    meta.append(value);
+                });
+                item.appendChild(meta);
+            }
+            list.appendChild(item);
+        });
+        return list;
+    }
+
+    async function load(container) {
+        const columns = parseColumns(container.dataset.columns);
+        let data;
+        try {
+            const response = await fetch('/api/pages/metadata?' + container.dataset.query, { credentials: 'same-origin' });
+            data = await response.json().catch(() => ({}));
+            if (!response.ok) throw new Error(data.error || data.message || response.statusText);
+        } catch (error) {
+            const message = document.createElement('p');
+            message.className = 'pages-query-empty';
+            message.textContent = t('pages_query.failed', 'The pages could not be loaded') + ': ' + error.message;
+            container.replaceChildren(message);
+            return;
+        }
+
+        if (data.pages.length === 0) {
+            const empty = document.createElement('p');
+            empty.className = 'pages-query-empty';
+            empty.textContent = t('pages_query.empty', 'No matching pages');
+            container.replaceChildren(empty);
+            return;
+        }
+        const renderPages = container.dataset.format === 'list' ? renderList : renderTable;
+        if (container.dataset.grouped) {
+            container.replaceChildren();
+            groupPages(data.pages).forEach(group => {
+                const heading = document.createElement('h4');
+                heading.className = 'pages-query-group';
+                heading.textContent = group.name || t('pages_query.no_group', 'No value');
+                container.append(heading, renderPages(group.pages, columns));
+            });
+        } else {
+            container.replaceChildren(renderPages(data.pages, columns));
+        }
+        if (data.total > data.count) {
+            const more = document.createElement('p');
+            more.className = 'pages-query-more';
+            more.textContent = t('pages_query.more', '{shown} of {total} pages')
+                .replace('{shown}', data.count)
+                .replace('{total}', data.total);
+            container.appendChild(more);
+        }
+    }
+
+    function render(root) {
+        (root || document).querySelectorAll('.pages-query[data-query]').forEach(load);
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', () => render(document));
+    } else {
+        render(document);
+    }
+
+    function refresh() {
+        if (document.visibilityState === 'visible') render(document);
+    }
+    document.addEventListener('visibilitychange', refresh);
+    setInterval(refresh, refreshInterval);
+
+    window.PagesQuery = { render };
+})();
@@ -0,0 +1,255 @@
+// Passkeys (WebAuthn): passwordless login, second factor and passkey management
+(function() {
+    'use strict';
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function supported() {
+        return !!(window.PublicKeyCredential && navigator.credentials);
+    }
+
+    // base64url <-> ArrayBuffer, WebAuthn options and responses use base64url on the wire
+    function toBuffer(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const padded = base64 + '==='.slice((base64.length + 3) % 4);
+        return Uint8Array.from(atob(padded), c => c.charCodeAt(0)).buffer;
+    }
+
+    function toBase64url(buffer) {
+        if (!buffer) return '';
+        let binary = '';
+        new Uint8Array(buffer).forEach(b => { binary += String.fromCharCode(b); });
+        return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    function decodeDescriptors(list) {
+        return (list || []).map(item => ({ type: item.type, id: toBuffer(item.id) }));
+    }
+
+    async function postJSON(url, body) {
+        return fetch(url, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body || {})
+        });
+    }
+
+    // Ask the authenticator to sign a login challenge and send the result to the server
+    async function assert(options) {
+        const publicKey = Object.assign({}, options.publicKey, {
+            challenge: toBuffer(options.publicKey.challenge),
+            allowCredentials: decodeDescriptors(options.publicKey.allowCredentials)
+        });
+        const credential = await navigator.credentials.get({ publicKey });
+
+        return postJSON('/api/passkeys/login/finish', {
+            id: credential.id,
+            clientDataJSON: toBase64url(credential.response.clientDataJSON),
+            authenticatorData: toBase64url(credential.response.authenticatorData),
+            signature: toBase64url(credential.response.signature),
+            userHandle: toBase64url(credential.response.userHandle)
+        });
+    }
+
+    // Log in with a passkey only, optionally restricted to the passkeys of a user
+    async function login(username, keepLoggedIn) {
+        const resp = await postJSON('/api/passkeys/login/begin', { username: username || '', keepLoggedIn: !!keepLoggedIn });
+        if (!resp.ok) return resp;
+        return assert(await resp.json());
+    }
+
+    // Complete the response of a password login: confirms it with a passkey when the
+    // server asks for one, and registers the passkey an admin must use before they
+    // get logged in
+    async function completeLogin(response) {
+        let data = null;
+        try {
+            data = await response.clone().json();
+        } catch (e) {
+            return response;
+        }
+
+        if (data && data.passkeyRequired && data.options) {
+            if (!supported()) return response;
+            return assert(data.options);
+        }
+        if (data && data.passkeySetupRequired) {
+            if (!supported()) return response;
+            return register(t('passkeys.default_name', 'Passkey'));
+        }
+        return response;
+    }
+
+    // Register a new passkey for the current user
+    async function register(name) {
+        const resp = await postJSON('/api/passkeys/register/begin');
+        if (!resp.ok) return resp;
+        const options = await resp.json();
+
+        const publicKey = Object.assign({}, options.publicKey, {
+            challenge: toBuffer(options.publicKey.challenge),
+            user: Object.assign({}, options.publicKey.user, { id: toBuffer(options.publicKey.user.id) }),
+            excludeCredentials: decodeDescriptors(options.publicKey.excludeCredentials)
+        });
+        const credential = await navigator.credentials.create({ publicKey });
+
+        return postJSON('/api/passkeys/register/finish', {
+            name: name,
+            response: {
+                id: credential.id,
+                clientDataJSON: toBase64url(credential.response.clientDataJSON),
+                attestationObject: toBase64url(credential.response.attestationObject)
+            }
+        });
+    }
+
+    async function errorText(resp, fallback) {
+        try {
+            const data = await resp.json();
+            return data.error || data.message || fallback;
+        } catch (e) {
+            return fallback;
+        }
+    }
+
+    // ---------- Passkeys dialog ----------
+
+    const dialog = document.querySelector('.passkeys-dialog');
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message || '';
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    async function loadPasskeys() {
+        const list = dialog.querySelector('.passkeys-list');
+        const empty = dialog.querySelector('.passkeys-empty');
+        list.innerHTML = '';
+
+        const resp = await fetch('/api/passkeys');
+        if (!resp.ok) {
+            showError(await errorText(resp, 'Failed to load passkeys'));
+            return;
+        }
+        const data = await resp.json();
+        empty.hidden = data.passkeys.length > 0;
+
+        data.passkeys.forEach(passkey => {
+            const item = document.createElement('li');
+            item.className = 'passkey-item';
+
+            const info = document.createElement('div');
+            const name = document.createElement('strong');
+            name.textContent = passkey.name;
+            const meta = document.createElement('small');
+            meta.textContent = t('passkeys.added', 'Added') + ' ' + new Date(passkey.createdAt).toLocaleDateString() +
+                (passkey.lastUsedAt ? ' · ' + t('passkeys.last_used', 'Last used') + ' ' + new Date(passkey.lastUsedAt).toLocaleString() : '');
+            info.appendChild(name);
+            info.appendChild(meta);
+
+            const remove = document.createElement('button');
+            remove.type = 'button';
+            remove.className = 'dialog-button';
+            remove.title = t('passkeys.remove', 'Remove');
+            remove.innerHTML = '<i class="fa fa-trash"></i>';
+            remove.addEventListener('click', async () => {
+                const resp = await fetch('/api/passkeys/' + encodeURIComponent(passkey.id), { method: 'DELETE' });
+                if (!resp.ok) {
+                    showError(await errorText(resp, 'Failed to remove passkey'));
+                    return;
+                }
+                loadPasskeys();
+            });
+
+            item.appendChild(info);
+            item.appendChild(remove);
+            list.appendChild(item);
+        });
+    }
+
+    function openDialog() {
+        if (!dialog) return;
+        showError('');
+        dialog.querySelector('.passkeys-form').hidden = !supported();
+        dialog.querySelector('.passkeys-unsupported').hidden = supported();
+        dialog.classList.add('active');
+        loadPasskeys();
+    }
+
+    function closeDialog() {
+        if (dialog) dialog.classList.remove('active');
+    }
+
+    if (dialog) {
+        dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+        dialog.addEventListener('click', e => {
+            if (e.target === dialog) closeDialog();
+        });
+
+        dialog.querySelector('.passkeys-form').addEventListener('submit', async e => {
+            e.preventDefault();
+            showError('');
+            const nameInput = document.getElementById('passkeyName');
+            try {
+                const resp = await register(nameInput.value.trim());
+                if (!resp.ok) {
+                    showError(await errorText(resp, 'Failed to register passkey'));
+                    return;
+                }
+                nameInput.value = '';
+                loadPasskeys();
+            } catch (err) {
+                // The user cancelled or the authenticator refused
+                showError(err.message);
+            }
+        });
+
+        document.querySelectorAll('.passkeys-button').forEach(button => {
+            button.addEventListener('click', openDialog);
+        });
+    }
+
+    // ---------- Passkey login buttons ----------
+
+    document.querySelectorAll('.passkey-login-button').forEach(button => {
+        if (!supported()) return;
+        button.hidden = false;
+
+        button.addEventListener('click', async () => {
+            const container = button.closest('.login-container');
+            const error = container.querySelector('.error-message');
+            const username = container.querySelector('input[name="username"]');
+            const keepLoggedIn = container.querySelector('input[name="keepLoggedIn"]');
+
+            try {
+                const resp = await login(username ? username.value.trim() : '', keepLoggedIn && keepLoggedIn.checked);
+                if (!resp.ok) {
+                    error.textContent = await errorText(resp, t('passkeys.login_failed', 'Passkey login failed'));
+                    error.style.display = 'block';
+                    return;
+                }
+
+                const dest = new URLSearchParams(window.location.search).get('redirect');
+                if (window.location.pathname === '/login') {
+                    window.location.href = dest && dest.startsWith('/') ? dest : '/';
+                } else {
+                    window.location.reload();
+                }
+            } catch (err) {
+                error.textContent = err.message;
+                error.style.display = 'block';
+            }
+        });
+    });
+
+    window.Passkeys = {
+        supported,
+        login,
+        completeLogin,
+        register,
+        openDialog
+    };
+})();
@@ -0,0 +1,133 @@
+/**
+ * Presentation Mode
+ * Shows a page as slides. Keys: arrows/space/PageUp/PageDown to navigate, Home/End,
+ * F for fullscreen, S to open the speaker view, Esc to leave.
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', async function() {
+        const slides = Array.from(document.querySelectorAll('.present-slide'));
+        const isSpeaker = document.body.classList.contains('speaker-view');
+        const counter = document.querySelector('.present-counter');
+        const progress = document.querySelector('.present-progress-bar');
+        const exitLink = document.querySelector('.present-exit');
+        let current = 0;
+
+        // Keep the main and speaker windows on the same slide
+        const channel = 'BroadcastChannel' in window
+            ? new BroadcastChannel('wiki-present:' + document.body.dataset.path)
+            : null;
+
+        function show(index, broadcast) {
+            current = Math.max(0, Math.min(slides.length - 1, index));
+            slides.forEach((slide, i) => slide.classList.toggle('active', i === current));
+
+            counter.textContent = (current + 1) + ' / ' + slides.length;
+            progress.style.width = ((current + 1) / slides.length * 100) + '%';
+            history.replaceState(null, '', '#/' + (current + 1));
+
+            if (isSpeaker) {
+                updateSpeakerPanel();
+            }
+            if (broadcast && channel) {
+                channel.postMessage({ slide: current });
+            }
+        }
+
+        function updateSpeakerPanel() {
+            const notes = slides[current].querySelector('.present-notes');
+            document.querySelector('.speaker-notes-text').textContent = notes ? notes.textContent : '';
+
+            const preview = document.querySelector('.speaker-next-preview');
+            preview.innerHTML = '';
+            if (current + 1 < slides.length) {
+                const next = slides[current + 1].cloneNode(true);
+                next.querySelectorAll('.present-notes').forEach(el => el.remove());
+                preview.append(...next.childNodes);
+            }
+        }
+
+        if (channel) {
+            channel.onmessage = (event) => {
+                if (typeof event.data.slide === 'number') {
+                    show(event.data.slide, false);
+                }
+            };
+        }
+
+        document.addEventListener('keydown', (event) => {
+            if (event.altKey || event.ctrlKey || event.metaKey) {
+                return;
+            }
+            switch (event.key) {
+                case 'ArrowRight':
+                case 'ArrowDown':
+                case 'PageDown':
+                case ' ':
+                    show(current + 1, true);
+                    break;
+                case 'ArrowLeft':
+                case 'ArrowUp':
+                case 'PageUp':
+                    show(current - 1, true);
+                    break;
+                case 'Home':
+                    show(0, true);
+                    break;
+                case 'End':
+                    show(slides.length - 1, true);
+                    break;
+                case 'f':
+                case 'F':
+                    if (document.fullscreenElement) {
+                        document.exitFullscreen();
+                    } else {
+                        document.documentElement.requestFullscreen().catch(() => {});
+                    }
+                    break;
+                case 's':
+                case 'S':
+                    if (!isSpeaker) {
+                        window.open(location.pathname + '?speaker=1#/' + (current + 1), 'wiki-speaker-view', 'width=1000,height=700');
+                    }
+                    break;
+                case 'Escape':
+                    if (!document.fullscreenElement) {
+                        window.location.href = exitLink.getAttribute('href');
+                    }
+                    break;
+                default:
+                    return;
+            }
+            event.preventDefault();
+        });
+
+        document.querySelector('.present-prev').addEventListener('click', () => show(current - 1, true));
+        document.querySelector('.present-next').addEventListener('click', () => show(current + 1, true));
+
+        // Start on the slide from the URL hash (#/3)
+        const match = location.hash.match(/^#\/(\d+)$/);
+        show(match ? parseInt(match[1], 10) - 1 : 0, false);
+
+        if (isSpeaker) {
+            const timer = document.querySelector('.speaker-timer');
+            const started = Date.now();
+            setInterval(() => {
+                const seconds = Math.floor((Date.now() - started) / 1000);
+                timer.textContent = String(Math.floor(seconds / 60)).padStart(2, '0') + ':' + String(seconds % 60).padStart(2, '0');
+            }, 1000);
+        }
+
+        if (typeof mermaid !== 'undefined' && document.querySelector('.mermaid')) {
+            try {
+                mermaid.initialize(window.MermaidConfig.options(false, { startOnLoad: false }));
+                await window.MermaidConfig.ready();
+                await mermaid.run({ querySelector: '.present-deck .mermaid' });
+            } catch (error) {
+                console.error('Failed to render Mermaid diagrams:', error);
+            }
+        }
+    });
+})();
@@ -0,0 +1,41 @@
+/**
+ * Print View
+ * Renders diagrams to inline SVG and optionally opens the print dialog (?autoprint=1)
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', async function() {
+        const printButton = document.querySelector('.print-now');
+        if (printButton) {
+            printButton.addEventListener('click', () => window.print());
+        }
+
+        // Render mermaid diagrams with the light theme so they print well
+        if (typeof mermaid !== 'undefined' && document.querySelector('.mermaid')) {
+            try {
+                mermaid.initialize(window.MermaidConfig.options(false, { startOnLoad: false }));
+                await window.MermaidConfig.ready();
+                await mermaid.run({ querySelector: '.mermaid' });
+            } catch (error) {
+                console.error('Failed to render Mermaid diagrams:', error);
+            }
+        }
+
+        // Wait for MathJax typesetting before printing
+        if (window.MathJax && MathJax.startup && MathJax.startup.promise) {
+            try {
+                await MathJax.startup.promise;
+            } catch (error) {
+                console.error('MathJax failed:', error);
+            }
+        }
+
+        document.body.classList.add('print-ready');
+
+        if (document.body.dataset.autoprint === 'true') {
+            window.print();
+        }
+    });
+})();
@@ -0,0 +1,107 @@
+/**
+ * Offline Reading
+ * Registers the service worker, shows a banner while the connection is down and
+ * lists the pages saved for offline reading.
+ */
+(function() {
+    'use strict';
+
+    const PAGE_CACHE = 'wiki-pages';
+
+    // registerServiceWorker installs the worker, or removes it and the saved pages
+    // when offline reading is disabled
+    function registerServiceWorker() {
+        if (!('serviceWorker' in navigator)) {
+            return;
+        }
+        const meta = document.querySelector('meta[name="offline-reading"]');
+        if (meta && meta.content === 'false') {
+            navigator.serviceWorker.getRegistrations().then(function(registrations) {
+                registrations.forEach(function(registration) { registration.unregister(); });
+            });
+            clearSavedPages();
+            return;
+        }
+        navigator.serviceWorker.register('/sw.js').catch(function(error) {
+            console.error('Service worker registration failed:', error);
+        });
+    }
+
+    // savedPages returns the URL and title of every saved page, most recent first
+    async function savedPages() {
+        if (!('caches' in window) || !(await caches.has(PAGE_CACHE))) {
+            return [];
+        }
+        const cache = await caches.open(PAGE_CACHE);
+        const requests = await cache.keys();
+        const pages = await Promise.all(requests.map(async function(request) {
+            const response = await cache.match(request);
+            const html = response ? await response.text() : '';
+            const doc = new DOMParser().parseFromString(html, 'text/html');
+            const url = new URL(request.url);
+            return {
+                url: url.pathname + url.search,
+                title: doc.title || decodeURIComponent(url.pathname)
+            };
+        }));
+        return pages.reverse();
+    }
+
+    // clearSavedPages removes the saved pages, e.g. on logout so the next user of
+    // the browser can't read them
+    async function clearSavedPages() {
+        if ('caches' in window) {
+            await caches.delete(PAGE_CACHE);
+        }
+    }
+
+    // updateBanner shows the offline banner while the browser has no connection
+    function updateBanner() {
+        const banner = document.getElementById('offline-banner');
+        if (banner) {
+            banner.hidden = navigator.onLine;
+        }
+    }
+
+    // renderSavedPages fills the list on the offline page
+    async function renderSavedPages(list) {
+        const pages = await savedPages();
+        list.textContent = '';
+        if (pages.length === 0) {
+            const item = document.createElement('li');
+            item.className = 'offline-pages-empty';
+            item.textContent = list.dataset.empty;
+            list.appendChild(item);
+            return;
+        }
+        pages.forEach(function(page) {
+            const item = document.createElement('li');
+            const link = document.createElement('a');
+            link.href = page.url;
+            link.textContent = page.title;
+            item.appendChild(link);
+            list.appendChild(item);
+        });
+    }
+
+    document.addEventListener('DOMContentLoaded', function() {
+        registerServiceWorker();
+        updateBanner();
+        window.addEventListener('online', updateBanner);
+        window.addEventListener('offline', updateBanner);
+
+        const list = document.getElementById('offline-pages');
+        if (list) {
+            renderSavedPages(list);
+        }
+        const retry = document.getElementById('offline-retry');
+        if (retry) {
+            retry.addEventListener('click', function() { window.location.reload(); });
+        }
+    });
+
+    window.OfflinePages = {
+        list: savedPages,
+        clear: clearSavedPages
+    };
+})();
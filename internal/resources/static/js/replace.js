@@ -0,0 +1,220 @@
+// Find and replace: searches a string or regular expression in all pages,
+// previews the matches and replaces the selected ones
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.replace-dialog');
+    if (!dialog) return;
+
+    const searchForm = dialog.querySelector('.replace-search-form');
+    const applyForm = dialog.querySelector('.replace-apply-form');
+    const results = dialog.querySelector('.replace-results');
+    const pagesList = dialog.querySelector('.replace-pages');
+    const selectAll = document.getElementById('replaceSelectAll');
+    let lastQuery = null; // The query of the listed matches
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function readQuery() {
+        return {
+            find: document.getElementById('replaceFind').value,
+            replace: document.getElementById('replaceWith').value,
+            regex: document.getElementById('replaceRegex').checked,
+            caseSensitive: document.getElementById('replaceCaseSensitive').checked
+        };
+    }
+
+    function openDialog() {
+        window.Auth.checkUserRole('editor').then(canEdit => {
+            if (!canEdit) {
+                window.Auth.showPermissionError('editor');
+                return;
+            }
+            showError('');
+            results.hidden = true;
+            dialog.classList.add('active');
+            setTimeout(() => document.getElementById('replaceFind').focus(), 100);
+        });
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    // occurrenceItem shows a match in its line, with the text replacing it
+    function occurrenceItem(page, occurrence) {
+        const item = document.createElement('li');
+        const label = document.createElement('label');
+        const checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.checked = true;
+        checkbox.className = 'replace-occurrence';
+        checkbox.dataset.path = page.path;
+        checkbox.dataset.offset = occurrence.offset;
+
+        const line = document.createElement('span');
+        line.className = 'replace-line';
+        line.textContent = occurrence.line;
+
+        const context = document.createElement('span');
+        context.className = 'replace-context';
+        const removed = document.createElement('del');
+        removed.textContent = occurrence.match;
+        const added = document.createElement('ins');
+        added.textContent = occurrence.replacement;
+        context.append(occurrence.before, removed, added, occurrence.after);
+
+        label.append(checkbox, line, context);
+        item.appendChild(label);
+        return item;
+    }
+
+    function renderResults(data) {
+        pagesList.textContent = '';
+        (data.pages || []).forEach(page => {
+            const entry = document.createElement('li');
+            const header = document.createElement('div');
+            header.className = 'replace-page';
+            const pageCheckbox = document.createElement('input');
+            pageCheckbox.type = 'checkbox';
+            pageCheckbox.checked = true;
+            const link = document.createElement('a');
+            link.href = page.path;
+            link.target = '_blank';
+            link.textContent = page.title;
+            const path = document.createElement('small');
+            path.textContent = page.path;
+            header.append(pageCheckbox, link, path);
+
+            const occurrences = document.createElement('ul');
+            page.occurrences.forEach(occurrence => occurrences.appendChild(occurrenceItem(page, occurrence)));
+            pageCheckbox.addEventListener('change', () => {
+                occurrences.querySelectorAll('.replace-occurrence').forEach(box => {
+                    box.checked = pageCheckbox.checked;
+                });
+            });
+
+            entry.append(header, occurrences);
+            pagesList.appendChild(entry);
+        });
+
+        let count = data.total === 0
+            ? t('replace.no_matches', 'No matches found')
+            : t('replace.matches', '{{count}} matches in {{pages}} pages', { count: data.total, pages: data.pages.length });
+        if (data.truncated) {
+            count += '. ' + t('replace.truncated', 'Only the first {{count}} matches are shown', { count: data.total });
+        }
+        dialog.querySelector('.replace-count').textContent = count;
+        selectAll.checked = true;
+        applyForm.hidden = data.total === 0;
+        results.hidden = false;
+    }
+
+    async function search(e) {
+        e.preventDefault();
+        showError('');
+        const query = readQuery();
+        try {
+            const resp = await fetch('/api/replace/search', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(Object.assign({ path: document.getElementById('replaceScope').value.trim() }, query))
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error || data.message || 'Search failed');
+                return;
+            }
+            lastQuery = query;
+            renderResults(data);
+        } catch (error) {
+            console.error('Find and replace error:', error);
+            showError('Search failed');
+        }
+    }
+
+    async function apply(e) {
+        e.preventDefault();
+        showError('');
+
+        // Group the selected occurrences by page
+        const selections = new Map();
+        pagesList.querySelectorAll('.replace-occurrence:checked').forEach(box => {
+            if (!selections.has(box.dataset.path)) selections.set(box.dataset.path, []);
+            selections.get(box.dataset.path).push(parseInt(box.dataset.offset, 10));
+        });
+        if (selections.size === 0) {
+            showError(t('replace.nothing_selected', 'Select at least one match to replace'));
+            return;
+        }
+
+        try {
+            const resp = await fetch('/api/replace/apply', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(Object.assign({}, lastQuery, {
+                    summary: document.getElementById('replaceSummary').value.trim(),
+                    selections: Array.from(selections, ([path, offsets]) => ({ path, offsets }))
+                }))
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.error || data.message || 'Replace failed');
+                return;
+            }
+
+            const pages = data.pages.filter(page => page.replaced > 0).length;
+            const skipped = data.pages.reduce((sum, page) => sum + page.skipped, 0);
+            const failed = data.pages.filter(page => page.error);
+            let message = t('replace.done', '{{count}} occurrences replaced in {{pages}} pages', { count: data.replaced, pages });
+            if (skipped > 0) {
+                message += '. ' + t('replace.skipped', '{{count}} occurrences changed since the search and were skipped', { count: skipped });
+            }
+            failed.forEach(page => {
+                message += `. ${page.path}: ${page.error}`;
+            });
+            closeDialog();
+            window.DialogSystem.showMessageDialog(t('replace.done_title', 'Replacement Done'), message);
+        } catch (error) {
+            console.error('Find and replace error:', error);
+            showError('Replace failed');
+        }
+    }
+
+    searchForm.addEventListener('submit', search);
+    applyForm.addEventListener('submit', apply);
+    selectAll.addEventListener('change', () => {
+        pagesList.querySelectorAll('input[type="checkbox"]').forEach(box => {
+            box.checked = selectAll.checked;
+        });
+    });
+    // Changing the search makes the listed matches stale
+    searchForm.addEventListener('input', () => {
+        results.hidden = true;
+    });
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('click', e => {
+        if (e.target === dialog) closeDialog();
+    });
+
+    const button = document.querySelector('.replace-button');
+    if (button) button.addEventListener('click', openDialog);
+
+    window.FindReplace = {
+        open: openDialog,
+        close: closeDialog
+    };
+})();
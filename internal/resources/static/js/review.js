@@ -0,0 +1,35 @@
+/**
+ * Review Reminders
+ * Handles the "Mark as reviewed" button of the overdue review banner
+ */
+
+(function() {
+    'use strict';
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const button = document.querySelector('.review-mark-button');
+        if (!button) return;
+
+        button.addEventListener('click', async function() {
+            const path = (button.dataset.path || '').replace(/^\/+/, '');
+            const encodedPath = path.split('/').map(encodeURIComponent).join('/');
+
+            button.disabled = true;
+            try {
+                const response = await fetch('/api/review/' + encodedPath, { method: 'POST' });
+                const data = await response.json();
+                if (!response.ok || !data.success) {
+                    throw new Error(data.error || data.message || 'Request failed');
+                }
+                window.location.reload();
+            } catch (error) {
+                button.disabled = false;
+                if (typeof window.showMessageDialog === 'function') {
+                    window.showMessageDialog('Error', error.message);
+                } else {
+                    console.error('Failed to mark page as reviewed:', error);
+                }
+            }
+        });
+    });
+})();
@@ -130,6 +130,7 @@ const WikiSearch = (function() {
                 <div class="search-result-item">
                     <a href="${result.path}" class="search-result-title">${highlightedTitle}</a>
                     <div class="search-result-path">${result.path}</div>
+                    ${result.summary ? `<div class="search-result-summary" dir="auto">${escapeSummary(result.summary)}</div>` : ''}
                     <div class="search-result-excerpt">${highlightedExcerpt}</div>
                 </div>
             `;
@@ -138,6 +139,13 @@ const WikiSearch = (function() {
         searchResultsContent.innerHTML = html;
     }
 
+    // escapeSummary escapes the page excerpt, which is plain text
+    function escapeSummary(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+
     // Hide search results function for keyboard shortcuts
     function hideSearchResults() {
         if (searchResults) {
@@ -0,0 +1,111 @@
+/**
+ * Service Worker
+ * Keeps visited pages and static assets for reading the wiki offline.
+ * Served at /sw.js by the server, which prepends WIKI_VERSION and OFFLINE_READING.
+ */
+
+const PAGE_CACHE = 'wiki-pages';
+const ASSET_CACHE = 'wiki-assets-' + WIKI_VERSION;
+const OFFLINE_URL = '/offline';
+const MAX_PAGES = 200;
+
+// Requests that must always reach the server
+const NETWORK_ONLY = ['/api/', '/debug/', '/login', '/sw.js', '/manifest.webmanifest'];
+
+self.addEventListener('install', function(event) {
+    event.waitUntil(precacheOfflinePage().then(function() { return self.skipWaiting(); }));
+});
+
+// precacheOfflinePage saves the offline page with the stylesheets and scripts it uses
+async function precacheOfflinePage() {
+    const cache = await caches.open(ASSET_CACHE);
+    const response = await fetch(OFFLINE_URL);
+    if (!response.ok) {
+        return;
+    }
+    const html = await response.clone().text();
+    await cache.put(OFFLINE_URL, response);
+    const assets = Array.from(html.matchAll(/(?:href|src)="(\/static\/[^"]+)"/g), function(match) { return match[1]; });
+    await cache.addAll(assets);
+}
+
+self.addEventListener('activate', function(event) {
+    event.waitUntil(
+        caches.keys().then(function(names) {
+            return Promise.all(names.map(function(name) {
+                // Assets of older versions, or everything when offline reading was turned off
+                const stale = name.startsWith('wiki-assets-') && name !== ASSET_CACHE;
+                if (stale || (!OFFLINE_READING && name.startsWith('wiki-'))) {
+                    return caches.delete(name);
+                }
+            }));
+        }).then(function() { return self.clients.claim(); })
+    );
+});
+
+self.addEventListener('fetch', function(event) {
+    const request = event.request;
+    if (!OFFLINE_READING || request.method !== 'GET') {
+        return;
+    }
+    const url = new URL(request.url);
+    if (url.origin !== self.location.origin || NETWORK_ONLY.some(function(prefix) { return url.pathname.startsWith(prefix); })) {
+        return;
+    }
+
+    if (url.pathname === OFFLINE_URL) {
+        event.respondWith(caches.match(OFFLINE_URL, { cacheName: ASSET_CACHE }).then(function(cached) {
+            return cached || fetch(request);
+        }));
+    } else if (url.pathname.startsWith('/static/')) {
+        event.respondWith(cacheFirst(request));
+    } else if (request.mode === 'navigate') {
+        event.respondWith(networkFirst(request));
+    }
+});
+
+// cacheFirst serves static assets from the cache. Their addresses change with
+// their content, so a cached copy never goes stale.
+async function cacheFirst(request) {
+    const cache = await caches.open(ASSET_CACHE);
+    const cached = await cache.match(request);
+    if (cached) {
+        return cached;
+    }
+    const response = await fetch(request);
+    if (response.ok && response.type === 'basic') {
+        cache.put(request, response.clone());
+    }
+    return response;
+}
+
+// networkFirst loads pages from the server and saves a copy, falling back to the
+// saved copy, or to the offline page, when the server can't be reached
+async function networkFirst(request) {
+    try {
+        const response = await fetch(request);
+        const type = response.headers.get('Content-Type') || '';
+        // Login redirects and error pages are not worth keeping
+        if (response.status === 200 && !response.redirected && type.startsWith('text/html')) {
+            savePage(request, response.clone());
+        }
+        return response;
+    } catch (error) {
+        const cached = await caches.match(request, { cacheName: PAGE_CACHE });
+        if (cached) {
+            return cached;
+        }
+        const offline = await caches.match(OFFLINE_URL, { cacheName: ASSET_CACHE });
+        return offline || Response.error();
+    }
+}
+
+// savePage stores a page and drops the least recently saved ones above MAX_PAGES
+async function savePage(request, response) {
+    const cache = await caches.open(PAGE_CACHE);
+    await cache.put(request, response);
+    const keys = await cache.keys();
+    for (let i = 0; i < keys.length - MAX_PAGES; i++) {
+        await cache.delete(keys[i]);
+    }
+}
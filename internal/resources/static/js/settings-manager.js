@@ -17,6 +17,14 @@ document.addEventListener('DOMContentLoaded', function() {
     const generalSettingsForm = document.getElementById('wikiSettingsForm');
     const contentSettingsForm = document.getElementById('contentSettingsForm');
     const securitySettingsForm = document.getElementById('securitySettingsForm');
+    const variablesSettingsForm = document.getElementById('variablesSettingsForm');
+    const dictionarySettingsForm = document.getElementById('dictionarySettingsForm');
+    const typographySettingsForm = document.getElementById('typographySettingsForm');
+    const ownersSettingsForm = document.getElementById('ownersSettingsForm');
+    const schemasSettingsForm = document.getElementById('schemasSettingsForm');
+    const permissionsSettingsForm = document.getElementById('permissionsSettingsForm');
+    const networkSettingsForm = document.getElementById('networkSettingsForm');
+    const federationSettingsForm = document.getElementById('federationSettingsForm');
     const settingsErrorMessage = settingsDialog.querySelector('.error-message');
     const tabButtons = document.querySelectorAll('.tab-button');
     const tabPanes = document.querySelectorAll('.tab-pane');
@@ -119,6 +127,9 @@ document.addEventListener('DOMContentLoaded', function() {
             enable_link_embedding: document.getElementById('wikiEnableLinkEmbedding').checked,
             hide_attachments: document.getElementById('wikiHideAttachments').checked,
             disable_content_max_width: document.getElementById('wikiDisableContentMaxWidth').checked,
+            high_contrast: document.getElementById('wikiHighContrast').checked,
+            underline_links: document.getElementById('wikiUnderlineLinks').checked,
+            user_dashboards: document.getElementById('wikiUserDashboards').checked,
             max_versions: parseInt(document.getElementById('wikiMaxVersions').value, 10) || 0,
             max_upload_size: parseInt(document.getElementById('wikiMaxUploadSize').value, 10) || 20,
             language: document.getElementById('wikiLanguage').value
@@ -273,6 +284,9 @@ document.addEventListener('DOMContentLoaded', function() {
             document.getElementById('wikiEnableLinkEmbedding').checked = settings.enable_link_embedding || false;
             document.getElementById('wikiHideAttachments').checked = settings.hide_attachments || false;
             document.getElementById('wikiDisableContentMaxWidth').checked = settings.disable_content_max_width || false;
+            document.getElementById('wikiHighContrast').checked = settings.high_contrast || false;
+            document.getElementById('wikiUnderlineLinks').checked = settings.underline_links || false;
+            document.getElementById('wikiUserDashboards').checked = settings.user_dashboards || false;
 
             // Handle max_versions specifically to account for 0 value
             document.getElementById('wikiMaxVersions').value = settings.max_versions !== undefined ? settings.max_versions : 10;
@@ -315,6 +329,43 @@ document.addEventListener('DOMContentLoaded', function() {
                 firstTabPane.classList.add('active');
             }
 
+            // Load the variables registry
+            loadVariables();
+
+            // Load the custom dictionary
+            loadDictionary();
+
+            // Load the typography settings
+            loadTypography();
+
+            // Load the ownership rules
+            loadOwners();
+
+            // Load the metadata schemas
+            loadSchemas();
+
+            // Load the permission rules and their effective tree
+            loadPermissionRules();
+
+            // Load the network access rules
+            loadNetworkRules();
+            loadFederation();
+
+            // Load the announcements
+            loadAnnouncements();
+
+            // Load the redirects
+            loadRedirects();
+
+            // Load the background jobs
+            loadJobs();
+
+            // Load the runtime diagnostics
+            loadDiagnostics();
+
+            // Load the edits of every user
+            loadContributors();
+
             // Another request for security
             try {
                 const secResp = await fetch('/api/settings/security');
@@ -325,6 +376,13 @@ document.addEventListener('DOMContentLoaded', function() {
                     document.getElementById('loginBanWindow').value = sec.login_ban.window_seconds;
                     document.getElementById('loginBanInitial').value = sec.login_ban.initial_ban_seconds;
                     document.getElementById('loginBanMax').value = sec.login_ban.max_ban_seconds;
+                    if (sec.passkeys) {
+                        document.getElementById('passkeysRequireForAdmins').checked = sec.passkeys.require_for_admins;
+                    }
+                    if (sec.sanitizer) {
+                        document.getElementById('sanitizerTrustedEditors').checked = sec.sanitizer.trusted_editors;
+                        document.getElementById('sanitizerAllowElements').value = sec.sanitizer.allow_elements || '';
+                    }
                 }
             } catch (e) {}
         } catch (error) {
@@ -457,6 +515,9 @@ document.addEventListener('DOMContentLoaded', function() {
                         <button class="edit-user-btn" title="Edit user" data-username="${user.username}" data-user='${JSON.stringify({role: role, is_admin: user.is_admin})}'>
                             <i class="fa fa-pencil"></i>
                         </button>
+                        <button class="export-user-btn" title="${window.i18n ? window.i18n.t('users.export_data') : 'Export user data'}" data-username="${user.username}">
+                            <i class="fa fa-download"></i>
+                        </button>
                         ${!isCurrentUser ? `
                         <button class="delete-user-btn" title="Delete user" data-username="${user.username}">
                             <i class="fa fa-trash"></i>
@@ -478,6 +539,13 @@ document.addEventListener('DOMContentLoaded', function() {
             });
         });
 
+        usersList.querySelectorAll('.export-user-btn').forEach(button => {
+            button.addEventListener('click', () => {
+                const username = button.getAttribute('data-username');
+                window.location.href = `/api/users/export?username=${encodeURIComponent(username)}`;
+            });
+        });
+
         usersList.querySelectorAll('.delete-user-btn').forEach(button => {
             button.addEventListener('click', () => {
                 const username = button.getAttribute('data-username');
@@ -657,6 +725,15 @@ document.addEventListener('DOMContentLoaded', function() {
                         throw new Error(errorData?.message || 'Failed to delete user');
                     }
 
+                    // Rules naming the user would apply to a new account of the same name
+                    const data = await response.json().catch(() => null);
+                    const warnings = data?.erased?.warnings || [];
+                    if (warnings.length > 0) {
+                        const message = (window.i18n ? window.i18n.t('delete_user.references') : 'These settings still name the deleted user:') +
+                            ' ' + warnings.join(', ');
+                        window.DialogSystem.showMessageDialog(title, message);
+                    }
+
                     // Reload users list
                     loadUsers();
                     // Reset form in case the deleted user was being edited
@@ -706,6 +783,13 @@ document.addEventListener('DOMContentLoaded', function() {
                 window_seconds: parseInt(document.getElementById('loginBanWindow').value, 10) || 30,
                 initial_ban_seconds: parseInt(document.getElementById('loginBanInitial').value, 10) || 60,
                 max_ban_seconds: parseInt(document.getElementById('loginBanMax').value, 10) || 86400
+            },
+            passkeys: {
+                require_for_admins: document.getElementById('passkeysRequireForAdmins').checked
+            },
+            sanitizer: {
+                trusted_editors: document.getElementById('sanitizerTrustedEditors').checked,
+                allow_elements: document.getElementById('sanitizerAllowElements').value.trim()
             }
         };
 
@@ -719,7 +803,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 // reload to apply new policy without restart
                 window.location.reload();
             } else {
-                settingsErrorMessage.textContent = 'Failed to save security settings';
+                settingsErrorMessage.textContent = (await resp.text()).trim() || 'Failed to save security settings';
                 settingsErrorMessage.style.display = 'block';
             }
         } catch (e) {
@@ -729,9 +813,1096 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    // Function to load the variables registry
+    async function loadVariables() {
+        try {
+            const resp = await fetch('/api/settings/variables');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('variablesContent').value = data.content || '';
+            }
+        } catch (e) {
+            console.error('Error loading variables:', e);
+        }
+    }
+
+    // Function to save the variables registry
+    async function saveVariables() {
+        try {
+            const resp = await fetch('/api/settings/variables', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('variablesContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save variables';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving variables';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (variablesSettingsForm) {
+        variablesSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveVariables();
+        });
+    }
+
+    // Function to load the typography settings
+    async function loadTypography() {
+        try {
+            const resp = await fetch('/api/settings/typography');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('typographyContent').value = data.content || '';
+            }
+        } catch (e) {
+            console.error('Error loading typography settings:', e);
+        }
+    }
+
+    // Function to save the typography settings
+    async function saveTypography() {
+        try {
+            const resp = await fetch('/api/settings/typography', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('typographyContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save typography settings';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving typography settings';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (typographySettingsForm) {
+        typographySettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveTypography();
+        });
+    }
+
+    // Function to load the custom dictionary
+    async function loadDictionary() {
+        try {
+            const resp = await fetch('/api/settings/dictionary');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('dictionaryContent').value = data.content || '';
+                const languages = data.languages || [];
+                document.getElementById('dictionaryLanguages').textContent =
+                    languages.length ? `(${languages.join(', ')})` : '';
+            }
+        } catch (e) {
+            console.error('Error loading dictionary:', e);
+        }
+    }
+
+    // Function to save the custom dictionary
+    async function saveDictionary() {
+        try {
+            const resp = await fetch('/api/settings/dictionary', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('dictionaryContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save dictionary';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving dictionary';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (dictionarySettingsForm) {
+        dictionarySettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveDictionary();
+        });
+    }
+
+    // Function to load the ownership rules
+    async function loadOwners() {
+        try {
+            const resp = await fetch('/api/settings/owners');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('ownersContent').value = data.content || '';
+            }
+        } catch (e) {
+            console.error('Error loading owners:', e);
+        }
+    }
+
+    // Function to save the ownership rules
+    async function saveOwners() {
+        try {
+            const resp = await fetch('/api/settings/owners', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('ownersContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save owners';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving owners';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (ownersSettingsForm) {
+        ownersSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveOwners();
+        });
+    }
+
+    // Function to load the metadata schemas
+    async function loadSchemas() {
+        const report = document.getElementById('schemasReport');
+        if (report) report.hidden = true;
+        try {
+            const resp = await fetch('/api/settings/schemas');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('schemasContent').value = data.content || '';
+            }
+        } catch (e) {
+            console.error('Error loading schemas:', e);
+        }
+    }
+
+    // Function to save the metadata schemas
+    async function saveSchemas() {
+        try {
+            const resp = await fetch('/api/settings/schemas', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('schemasContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save schemas';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving schemas';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    // Function to list the pages that don't match the saved schemas
+    async function loadSchemaReport() {
+        const report = document.getElementById('schemasReport');
+        const t = (key, fallback) => window.i18n ? window.i18n.t(key) : fallback;
+        report.hidden = false;
+        report.innerHTML = '';
+        try {
+            const resp = await fetch('/api/settings/schemas/report');
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                report.textContent = data.error || data.message || t('settings.schemas_report_failed', 'Failed to check the pages');
+                return;
+            }
+            if (data.pages.length === 0) {
+                report.textContent = t('settings.schemas_report_ok', 'All pages match their schemas');
+                return;
+            }
+            data.pages.forEach(page => {
+                const link = document.createElement('a');
+                link.href = page.path;
+                link.textContent = page.title;
+                const problems = document.createElement('ul');
+                page.problems.forEach(problem => {
+                    const item = document.createElement('li');
+                    item.textContent = problem.message;
+                    problems.appendChild(item);
+                });
+                report.append(link, problems);
+            });
+        } catch (e) {
+            console.error('Error checking schemas:', e);
+            report.textContent = t('settings.schemas_report_failed', 'Failed to check the pages');
+        }
+    }
+
+    if (schemasSettingsForm) {
+        schemasSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveSchemas();
+        });
+        document.getElementById('schemasReportButton').addEventListener('click', loadSchemaReport);
+    }
+
+    // Function to load the permission rules
+    async function loadPermissionRules() {
+        try {
+            const resp = await fetch('/api/settings/permissions');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('permissionsContent').value = data.content || '';
+            }
+        } catch (e) {
+            console.error('Error loading permissions:', e);
+        }
+        loadPermissionTree();
+    }
+
+    // Function to load the effective permission tree, simulating a user when one is entered
+    async function loadPermissionTree() {
+        const container = document.getElementById('permissionsTree');
+        if (!container) return;
+
+        const user = document.getElementById('permissionsSimulateUser').value.trim();
+        let url = '/api/settings/permissions/tree';
+        if (user) {
+            url += '?user=' + encodeURIComponent(user);
+        }
+
+        try {
+            const resp = await fetch(url);
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load permissions';
+                return;
+            }
+            container.appendChild(renderPermissionNode(data.tree));
+        } catch (e) {
+            console.error('Error loading permission tree:', e);
+        }
+    }
+
+    // Function to render one directory of the permission tree and its children
+    function renderPermissionNode(node) {
+        const list = document.createElement('ul');
+        const item = document.createElement('li');
+        const row = document.createElement('div');
+        row.className = 'permission-node' + (node.hasRule ? ' has-rule' : '');
+
+        const name = document.createElement('span');
+        name.className = 'permission-name';
+        name.textContent = node.name;
+        row.appendChild(name);
+
+        row.appendChild(renderPermissionList('view', node.access.view, node.access.viewFrom, node.path));
+        row.appendChild(renderPermissionList('edit', node.access.edit, node.access.editFrom, node.path));
+
+        if (node.canView !== undefined) {
+            const result = document.createElement('span');
+            result.className = 'permission-result';
+            result.innerHTML = '<i class="fa ' + (node.canView ? 'fa-eye' : 'fa-eye-slash') + '"></i> ' +
+                '<i class="fa ' + (node.canEdit ? 'fa-pencil' : 'fa-lock') + '"></i>';
+            result.classList.toggle('denied', !node.canView);
+            row.appendChild(result);
+        }
+
+        item.appendChild(row);
+        (node.children || []).forEach(child => item.appendChild(renderPermissionNode(child)));
+        list.appendChild(item);
+        return list;
+    }
+
+    // Function to render the view or edit list of a directory, marking inherited lists
+    function renderPermissionList(kind, principals, from, path) {
+        const span = document.createElement('span');
+        span.className = 'permission-list permission-' + kind;
+        const value = principals ? principals.join(', ') : '*';
+        span.textContent = kind + ': ' + value;
+        if (from && from !== path) {
+            span.classList.add('inherited');
+            span.title = from;
+        } else if (from) {
+            span.classList.add('override');
+        }
+        return span;
+    }
+
+    // Function to save the permission rules
+    async function savePermissionRules() {
+        try {
+            const resp = await fetch('/api/settings/permissions', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('permissionsContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error || data.message || 'Failed to save permissions';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving permissions';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (permissionsSettingsForm) {
+        permissionsSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            savePermissionRules();
+        });
+        document.getElementById('permissionsSimulateButton').addEventListener('click', loadPermissionTree);
+        document.getElementById('permissionsSimulateUser').addEventListener('keydown', function(e) {
+            if (e.key === 'Enter') {
+                e.preventDefault();
+                loadPermissionTree();
+            }
+        });
+    }
+
+    // Function to load the network access rules and show the address the server sees
+    async function loadNetworkRules() {
+        try {
+            const resp = await fetch('/api/settings/network');
+            if (resp.ok) {
+                const data = await resp.json();
+                document.getElementById('networkContent').value = data.content || '';
+                document.getElementById('networkClientIP').textContent = data.clientIP || '-';
+            }
+        } catch (e) {
+            console.error('Error loading network rules:', e);
+        }
+    }
+
+    // Function to save the network access rules
+    async function saveNetworkRules() {
+        try {
+            const resp = await fetch('/api/settings/network', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('networkContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to save network rules');
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving network rules';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (networkSettingsForm) {
+        networkSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveNetworkRules();
+        });
+    }
+
+    // Function to load the federation settings and the state of each source
+    async function loadFederation() {
+        const container = document.getElementById('federationSources');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/settings/federation');
+            const data = await resp.json();
+            if (!resp.ok || !data.success) return;
+            document.getElementById('federationContent').value = data.content || '';
+            container.innerHTML = '';
+            (data.sources || []).forEach(source => container.appendChild(renderFederationSource(source)));
+        } catch (e) {
+            console.error('Error loading federation settings:', e);
+        }
+    }
+
+    // Function to render one synced source with a button to sync it now
+    function renderFederationSource(source) {
+        const t = key => window.i18n ? window.i18n.t(key) : key;
+        const row = document.createElement('div');
+        row.className = 'job-item';
+
+        const info = document.createElement('div');
+        info.className = 'job-info';
+        const name = document.createElement('span');
+        name.className = 'job-type';
+        name.textContent = source.name + ' → ' + source.target;
+        const details = document.createElement('span');
+        details.className = 'job-details';
+        details.textContent = source.remote;
+        if (source.state.lastSync) {
+            details.textContent += ' · ' + t('settings.federation_last_sync') + ' ' + new Date(source.state.lastSync).toLocaleString() +
+                ' · ' + source.state.pages + ' ' + t('settings.federation_pages');
+        }
+        info.append(name, details);
+        if (source.state.error) {
+            const error = document.createElement('div');
+            error.className = 'job-error';
+            error.textContent = source.state.error;
+            info.appendChild(error);
+        }
+        row.appendChild(info);
+
+        const actions = document.createElement('div');
+        actions.className = 'job-actions';
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'dialog-button';
+        button.textContent = t('settings.federation_sync_now');
+        button.addEventListener('click', async () => {
+            try {
+                const resp = await fetch('/api/settings/federation', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ source: source.name })
+                });
+                const data = await resp.json();
+                if (resp.ok && data.success) {
+                    button.disabled = true;
+                    button.textContent = t('settings.federation_queued');
+                } else {
+                    settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to queue sync');
+                    settingsErrorMessage.style.display = 'block';
+                }
+            } catch (e) {
+                console.error('Error queueing sync:', e);
+            }
+        });
+        actions.appendChild(button);
+        row.appendChild(actions);
+        return row;
+    }
+
+    // Function to save the federation settings
+    async function saveFederation() {
+        try {
+            const resp = await fetch('/api/settings/federation', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content: document.getElementById('federationContent').value })
+            });
+            const data = await resp.json();
+            if (resp.ok && data.success) {
+                hideSettingsDialog();
+            } else {
+                settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to save federation settings');
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error(e);
+            settingsErrorMessage.textContent = 'Error saving federation settings';
+            settingsErrorMessage.style.display = 'block';
+        }
+    }
+
+    if (federationSettingsForm) {
+        federationSettingsForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveFederation();
+        });
+    }
+
+    // Function to load the background jobs
+    async function loadJobs() {
+        const container = document.getElementById('jobsList');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/settings/jobs');
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load jobs';
+                return;
+            }
+            if (!data.jobs || data.jobs.length === 0) {
+                const empty = document.createElement('div');
+                empty.className = 'empty-message';
+                empty.textContent = window.i18n ? window.i18n.t('settings.jobs_empty') : 'No background jobs';
+                container.appendChild(empty);
+                return;
+            }
+            data.jobs.forEach(job => container.appendChild(renderJob(job)));
+        } catch (e) {
+            console.error('Error loading jobs:', e);
+        }
+    }
+
+    // Function to render one background job with its actions
+    function renderJob(job) {
+        const t = key => window.i18n ? window.i18n.t(key) : key;
+        const row = document.createElement('div');
+        row.className = 'job-item';
+
+        const info = document.createElement('div');
+        info.className = 'job-info';
+        const status = document.createElement('span');
+        status.className = 'job-status job-' + job.status;
+        status.textContent = t('settings.jobs_status_' + job.status);
+        const type = document.createElement('span');
+        type.className = 'job-type';
+        type.textContent = job.type;
+        const details = document.createElement('span');
+        details.className = 'job-details';
+        details.textContent = new Date(job.createdAt).toLocaleString() + ' · ' + t('settings.jobs_attempts') + ' ' + job.attempts;
+        info.append(status, type, details);
+        if (job.lastError) {
+            const error = document.createElement('div');
+            error.className = 'job-error';
+            error.textContent = job.lastError;
+            info.appendChild(error);
+        }
+        row.appendChild(info);
+
+        const actions = document.createElement('div');
+        actions.className = 'job-actions';
+        if (job.status === 'failed') {
+            actions.appendChild(jobButton(t('settings.jobs_retry'), 'retry', job.id));
+        }
+        if (job.status !== 'running') {
+            actions.appendChild(jobButton(t('settings.jobs_delete'), 'delete', job.id));
+        }
+        row.appendChild(actions);
+        return row;
+    }
+
+    // Function to create a button running an action on a job
+    function jobButton(label, action, id) {
+        const button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'dialog-button';
+        button.textContent = label;
+        button.addEventListener('click', async () => {
+            try {
+                const resp = await fetch('/api/settings/jobs', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ action: action, id: id })
+                });
+                const data = await resp.json();
+                if (!resp.ok || !data.success) {
+                    settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to update job');
+                    settingsErrorMessage.style.display = 'block';
+                }
+            } catch (e) {
+                console.error('Error updating job:', e);
+            }
+            loadJobs();
+        });
+        return button;
+    }
+
+    const refreshJobsBtn = document.getElementById('refreshJobsBtn');
+    if (refreshJobsBtn) {
+        refreshJobsBtn.addEventListener('click', loadJobs);
+    }
+
+    // Function to load the contributors report: the edits of every user per month
+    async function loadContributors() {
+        const container = document.getElementById('contributorsReport');
+        const monthsSelect = document.getElementById('contributorsMonths');
+        if (!container || !monthsSelect) return;
+        const t = (key, fallback) => window.i18n ? window.i18n.t(key) : fallback;
+
+        try {
+            const resp = await fetch('/api/settings/contributors?months=' + encodeURIComponent(monthsSelect.value));
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load contributors';
+                return;
+            }
+            const report = data.report;
+            if (!report.contributors || report.contributors.length === 0) {
+                const empty = document.createElement('div');
+                empty.className = 'empty-message';
+                empty.textContent = t('settings.contributors_empty', 'No recorded edits');
+                container.appendChild(empty);
+                return;
+            }
+
+            const most = Math.max(1, ...report.contributors.map(c => Math.max(...c.perMonth)));
+            const table = document.createElement('table');
+            table.className = 'contributors-table';
+            const head = table.createTHead().insertRow();
+            [
+                t('settings.contributors_user', 'User'),
+                t('settings.contributors_edits', 'Edits'),
+                t('settings.contributors_pages', 'Pages'),
+                t('settings.contributors_created', 'Created'),
+                t('settings.contributors_per_month', 'Per month'),
+                t('settings.contributors_total', 'All time'),
+                t('settings.contributors_last', 'Last edit')
+            ].forEach(label => {
+                const cell = document.createElement('th');
+                cell.textContent = label;
+                head.appendChild(cell);
+            });
+
+            const body = table.createTBody();
+            report.contributors.forEach(contributor => {
+                const row = body.insertRow();
+                const user = document.createElement('a');
+                user.className = 'user-link';
+                user.href = '/?user=' + encodeURIComponent(contributor.user);
+                user.dataset.user = contributor.user;
+                user.textContent = contributor.user;
+                row.insertCell().appendChild(user);
+                row.insertCell().textContent = contributor.minor
+                    ? `${contributor.edits} (${contributor.minor} ${t('history.minor', 'minor')})`
+                    : String(contributor.edits);
+                row.insertCell().textContent = contributor.pages;
+                row.insertCell().textContent = contributor.created;
+
+                // Bars of the edits in each month, oldest first
+                const bars = document.createElement('div');
+                bars.className = 'contributors-months';
+                contributor.perMonth.forEach((count, i) => {
+                    const bar = document.createElement('span');
+                    bar.className = count ? '' : 'empty';
+                    bar.style.height = Math.max(4, Math.round(count / most * 100)) + '%';
+                    bar.title = `${report.months[i]}: ${count}`;
+                    bars.appendChild(bar);
+                });
+                row.insertCell().appendChild(bars);
+
+                row.insertCell().textContent = contributor.total;
+                row.insertCell().textContent = new Date(contributor.last).toLocaleDateString();
+            });
+            container.appendChild(table);
+        } catch (e) {
+            console.error('Error loading contributors:', e);
+        }
+    }
+
+    const refreshContributorsBtn = document.getElementById('refreshContributorsBtn');
+    if (refreshContributorsBtn) {
+        refreshContributorsBtn.addEventListener('click', loadContributors);
+    }
+    const contributorsMonths = document.getElementById('contributorsMonths');
+    if (contributorsMonths) {
+        contributorsMonths.addEventListener('change', loadContributors);
+    }
+
+    // Function to load the runtime diagnostics
+    async function loadDiagnostics() {
+        const container = document.getElementById('diagnosticsReport');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/settings/diagnostics');
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load diagnostics';
+                return;
+            }
+
+            const t = key => window.i18n ? window.i18n.t(key) : key;
+            const runtime = data.diagnostics.runtime;
+            container.appendChild(diagnosticsTable(t('settings.diagnostics_runtime'), [
+                ['Go', runtime.goVersion + ' · GOMAXPROCS ' + runtime.gomaxprocs],
+                [t('settings.diagnostics_uptime'), runtime.uptime],
+                [t('settings.diagnostics_goroutines'), runtime.goroutines],
+                [t('settings.diagnostics_heap'), formatBytes(runtime.heapAlloc) + ' / ' + formatBytes(runtime.heapSys)],
+                [t('settings.diagnostics_stacks'), formatBytes(runtime.stackInuse)],
+                [t('settings.diagnostics_total'), formatBytes(runtime.sys)],
+                [t('settings.diagnostics_gc'), runtime.numGC + (runtime.lastGC ? ' · ' + new Date(runtime.lastGC).toLocaleString() : '')]
+            ]));
+
+            const renders = data.renders;
+            container.appendChild(diagnosticsTable(t('settings.diagnostics_renders'), [
+                [t('settings.diagnostics_workers'), renders.workers],
+                [t('settings.diagnostics_limit'), renders.maxConcurrent || '-'],
+                [t('settings.diagnostics_running'), renders.running],
+                [t('settings.diagnostics_waiting'), renders.waiting]
+            ]));
+
+            const services = data.services || [];
+            if (services.length > 0) {
+                container.appendChild(diagnosticsTable(t('settings.diagnostics_services'), services.map(s => {
+                    let value = t('settings.diagnostics_state_' + s.state.replace('-', '_')) + ' · ' +
+                        s.calls + ' ' + t('settings.diagnostics_calls') + ' · ' + s.errors + ' ' + t('settings.diagnostics_errors');
+                    if (s.lastError) {
+                        value += ' · ' + s.lastError;
+                    }
+                    return [s.name, value];
+                })));
+            }
+
+            container.appendChild(diagnosticsTable(t('settings.diagnostics_memory'),
+                (data.diagnostics.subsystems || []).map(s => [s.name, s.items + ' · ~' + formatBytes(s.bytes)])));
+
+            if (data.pprof) {
+                const link = document.createElement('a');
+                link.href = '/debug/pprof/';
+                link.target = '_blank';
+                link.textContent = t('settings.diagnostics_pprof');
+                container.appendChild(link);
+            }
+        } catch (e) {
+            console.error('Error loading diagnostics:', e);
+        }
+    }
+
+    // Function to build a titled table of label and value rows
+    function diagnosticsTable(title, rows) {
+        const section = document.createElement('div');
+        section.className = 'diagnostics-section';
+        const heading = document.createElement('h4');
+        heading.textContent = title;
+        section.appendChild(heading);
+
+        const table = document.createElement('table');
+        rows.forEach(([label, value]) => {
+            const row = table.insertRow();
+            row.insertCell().textContent = label;
+            row.insertCell().textContent = value;
+        });
+        section.appendChild(table);
+        return section;
+    }
+
+    // Function to format a number of bytes for display
+    function formatBytes(bytes) {
+        const units = ['B', 'KB', 'MB', 'GB'];
+        let value = bytes;
+        let unit = 0;
+        while (value >= 1024 && unit < units.length - 1) {
+            value /= 1024;
+            unit++;
+        }
+        return (unit === 0 ? value : value.toFixed(1)) + ' ' + units[unit];
+    }
+
+    const refreshDiagnosticsBtn = document.getElementById('refreshDiagnosticsBtn');
+    if (refreshDiagnosticsBtn) {
+        refreshDiagnosticsBtn.addEventListener('click', loadDiagnostics);
+    }
+
+    // Function to load the announcements
+    async function loadAnnouncements() {
+        const container = document.getElementById('announcementsList');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/settings/announcements');
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load announcements';
+                return;
+            }
+            if (!data.announcements || data.announcements.length === 0) {
+                const empty = document.createElement('div');
+                empty.className = 'empty-message';
+                empty.textContent = window.i18n ? window.i18n.t('announcements.empty') : 'No announcements';
+                container.appendChild(empty);
+                return;
+            }
+            data.announcements.forEach(announcement => container.appendChild(renderAnnouncement(announcement)));
+        } catch (e) {
+            console.error('Error loading announcements:', e);
+        }
+    }
+
+    // Function to render one announcement with its actions
+    function renderAnnouncement(announcement) {
+        const t = key => window.i18n ? window.i18n.t(key) : key;
+        const row = document.createElement('div');
+        row.className = 'job-item announcement-item';
+
+        const info = document.createElement('div');
+        info.className = 'job-info';
+        const level = document.createElement('span');
+        level.className = 'job-status' + (announcement.level === 'critical' ? ' job-failed' : announcement.level === 'info' ? ' job-running' : '');
+        level.textContent = t('announcements.level_' + announcement.level);
+        const message = document.createElement('span');
+        message.className = 'job-type';
+        message.textContent = announcement.message.length > 120 ? announcement.message.slice(0, 120) + '…' : announcement.message;
+        const details = document.createElement('span');
+        details.className = 'job-details';
+        const parts = [announcement.path ? '/' + announcement.path : t('announcements.everywhere')];
+        const now = new Date();
+        if (announcement.end && new Date(announcement.end) <= now) {
+            parts.push(t('announcements.ended'));
+        } else if (announcement.start && new Date(announcement.start) > now) {
+            parts.push(t('announcements.scheduled') + ' ' + new Date(announcement.start).toLocaleString());
+        }
+        if (announcement.end && new Date(announcement.end) > now) {
+            parts.push(t('announcements.until') + ' ' + new Date(announcement.end).toLocaleString());
+        }
+        details.textContent = parts.join(' · ');
+        info.append(level, message, details);
+        row.appendChild(info);
+
+        const actions = document.createElement('div');
+        actions.className = 'job-actions';
+        const edit = document.createElement('button');
+        edit.type = 'button';
+        edit.className = 'dialog-button';
+        edit.textContent = t('common.edit');
+        edit.addEventListener('click', () => fillAnnouncementForm(announcement));
+        const remove = document.createElement('button');
+        remove.type = 'button';
+        remove.className = 'dialog-button';
+        remove.textContent = t('settings.jobs_delete');
+        remove.addEventListener('click', () => deleteAnnouncement(announcement.id));
+        actions.append(edit, remove);
+        row.appendChild(actions);
+        return row;
+    }
+
+    // datetime-local inputs hold local times without a time zone
+    function toLocalInput(iso) {
+        if (!iso) return '';
+        const date = new Date(iso);
+        return new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+    }
+
+    function fromLocalInput(value) {
+        return value ? new Date(value).toISOString() : null;
+    }
+
+    // Function to edit an announcement, or to write a new one without argument
+    function fillAnnouncementForm(announcement) {
+        const t = key => window.i18n ? window.i18n.t(key) : key;
+        announcement = announcement || { level: 'info', dismissible: true };
+        document.getElementById('announcementId').value = announcement.id || '';
+        document.getElementById('announcementLevel').value = announcement.level;
+        document.getElementById('announcementPath').value = announcement.path ? '/' + announcement.path : '';
+        document.getElementById('announcementStart').value = toLocalInput(announcement.start);
+        document.getElementById('announcementEnd').value = toLocalInput(announcement.end);
+        document.getElementById('announcementMessage').value = announcement.message || '';
+        document.getElementById('announcementDismissible').checked = !!announcement.dismissible;
+        document.getElementById('saveAnnouncementBtn').textContent = announcement.id ? t('announcements.update') : t('announcements.add');
+        if (announcement.id) {
+            document.getElementById('announcementMessage').focus();
+        }
+    }
+
+    // Function to save the announcement of the form
+    async function saveAnnouncement() {
+        const announcement = {
+            id: document.getElementById('announcementId').value,
+            level: document.getElementById('announcementLevel').value,
+            path: document.getElementById('announcementPath').value,
+            start: fromLocalInput(document.getElementById('announcementStart').value),
+            end: fromLocalInput(document.getElementById('announcementEnd').value),
+            message: document.getElementById('announcementMessage').value,
+            dismissible: document.getElementById('announcementDismissible').checked
+        };
+        try {
+            const resp = await fetch('/api/settings/announcements', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(announcement)
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to save announcement');
+                settingsErrorMessage.style.display = 'block';
+                return;
+            }
+            settingsErrorMessage.style.display = 'none';
+            fillAnnouncementForm();
+            loadAnnouncements();
+        } catch (e) {
+            console.error('Error saving announcement:', e);
+        }
+    }
+
+    // Function to delete an announcement
+    async function deleteAnnouncement(id) {
+        try {
+            const resp = await fetch('/api/settings/announcements?id=' + encodeURIComponent(id), { method: 'DELETE' });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                settingsErrorMessage.textContent = data.message || 'Failed to delete announcement';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error('Error deleting announcement:', e);
+        }
+        if (document.getElementById('announcementId').value === id) {
+            fillAnnouncementForm();
+        }
+        loadAnnouncements();
+    }
+
+    const announcementForm = document.getElementById('announcementForm');
+    if (announcementForm) {
+        announcementForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveAnnouncement();
+        });
+        document.getElementById('clearAnnouncementBtn').addEventListener('click', () => fillAnnouncementForm());
+    }
+
+    // Function to load the redirects
+    async function loadRedirects() {
+        const container = document.getElementById('redirectsList');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/settings/redirects');
+            const data = await resp.json();
+            container.innerHTML = '';
+            if (!resp.ok || !data.success) {
+                container.textContent = data.error || data.message || 'Failed to load redirects';
+                return;
+            }
+            if (!data.redirects || data.redirects.length === 0) {
+                const empty = document.createElement('div');
+                empty.className = 'empty-message';
+                empty.textContent = window.i18n ? window.i18n.t('redirects.empty') : 'No redirects';
+                container.appendChild(empty);
+                return;
+            }
+            data.redirects.forEach(redirect => container.appendChild(renderRedirect(redirect)));
+        } catch (e) {
+            console.error('Error loading redirects:', e);
+        }
+    }
+
+    // Function to render one redirect with its hits and actions
+    function renderRedirect(redirect) {
+        const t = key => window.i18n ? window.i18n.t(key) : key;
+        const row = document.createElement('div');
+        row.className = 'job-item';
+
+        const info = document.createElement('div');
+        info.className = 'job-info';
+        const status = document.createElement('span');
+        status.className = 'job-status' + (redirect.error ? ' job-failed' : '');
+        status.textContent = redirect.error ? t('redirects.broken') : redirect.page ? t('redirects.page') : t('redirects.manual');
+        const paths = document.createElement('span');
+        paths.className = 'job-type';
+        paths.textContent = redirect.from + ' → ' + redirect.to;
+        const details = document.createElement('span');
+        details.className = 'job-details';
+        const parts = [redirect.hits + ' ' + t('redirects.hits')];
+        if (redirect.lastHit) {
+            parts.push(t('redirects.last_hit') + ' ' + new Date(redirect.lastHit).toLocaleString());
+        }
+        if (redirect.error) {
+            parts.push(redirect.error);
+        } else if (redirect.final && redirect.final !== redirect.to) {
+            parts.push(t('redirects.final') + ' ' + redirect.final);
+        }
+        details.textContent = parts.join(' · ');
+        info.append(status, paths, details);
+        row.appendChild(info);
+
+        const actions = document.createElement('div');
+        actions.className = 'job-actions';
+        if (redirect.page) {
+            const open = document.createElement('a');
+            open.className = 'dialog-button';
+            open.href = redirect.from + '?redirect=no';
+            open.textContent = t('redirects.open_page');
+            actions.appendChild(open);
+        } else {
+            const remove = document.createElement('button');
+            remove.type = 'button';
+            remove.className = 'dialog-button';
+            remove.textContent = t('settings.jobs_delete');
+            remove.addEventListener('click', () => deleteRedirect(redirect.from));
+            actions.appendChild(remove);
+        }
+        row.appendChild(actions);
+        return row;
+    }
+
+    // Function to save the redirect of the form
+    async function saveRedirect() {
+        try {
+            const resp = await fetch('/api/settings/redirects', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    from: document.getElementById('redirectFrom').value,
+                    to: document.getElementById('redirectTo').value
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                settingsErrorMessage.textContent = data.error ? data.message + ': ' + data.error : (data.message || 'Failed to save redirect');
+                settingsErrorMessage.style.display = 'block';
+                return;
+            }
+            settingsErrorMessage.style.display = 'none';
+            document.getElementById('redirectForm').reset();
+            loadRedirects();
+        } catch (e) {
+            console.error('Error saving redirect:', e);
+        }
+    }
+
+    // Function to delete a redirect
+    async function deleteRedirect(from) {
+        try {
+            const resp = await fetch('/api/settings/redirects?from=' + encodeURIComponent(from), { method: 'DELETE' });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                settingsErrorMessage.textContent = data.message || 'Failed to delete redirect';
+                settingsErrorMessage.style.display = 'block';
+            }
+        } catch (e) {
+            console.error('Error deleting redirect:', e);
+        }
+        loadRedirects();
+    }
+
+    const redirectForm = document.getElementById('redirectForm');
+    if (redirectForm) {
+        redirectForm.addEventListener('submit', function(e) {
+            e.preventDefault();
+            saveRedirect();
+        });
+    }
+
+    // Open the settings dialog on the tab with the given id, like "users-tab"
+    async function openSettingsTab(tabId) {
+        await loadSettings();
+        const tabButton = settingsDialog.querySelector(`.settings-tabs .tab-button[data-tab="${tabId}"]`);
+        if (tabButton && settingsDialog.classList.contains('active')) {
+            tabButton.click();
+        }
+    }
+
     // Make functions available globally
     window.SettingsManager = {
         hideSettingsDialog,
+        openSettingsTab,
         fetchMaxUploadSize,
         loadSettings,
         maxFileUploadSizeMB: () => maxFileUploadSizeMB,
@@ -0,0 +1,204 @@
+// Share dialog: creates links giving people without an account read-only
+// access to the current page, and lists and revokes the existing ones
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.share-dialog');
+    const button = document.querySelector('.share-page');
+    if (!dialog || !button) return;
+
+    const form = document.getElementById('shareForm');
+    const linkInput = document.getElementById('shareLink');
+    const permalinkInput = document.getElementById('sharePermalink');
+    const qrBox = dialog.querySelector('.share-qr');
+    const list = dialog.querySelector('.share-list');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function describe(share) {
+        const parts = [t('share.created', 'Created') + ' ' + new Date(share.created).toLocaleDateString()];
+        if (share.revoked) {
+            parts.push(t('share.revoked', 'Revoked'));
+        } else if (share.expires) {
+            parts.push((share.active ? t('share.expires', 'Expires') : t('share.expired', 'Expired')) + ' ' + new Date(share.expires).toLocaleDateString());
+        }
+        if (share.password) parts.push(t('share.protected', 'Password'));
+        if (share.attachments) parts.push(t('share.with_attachments', 'Attachments'));
+        parts.push(share.views + ' ' + t('share.views', 'views'));
+        return parts.join(' · ');
+    }
+
+    async function loadShares() {
+        list.innerHTML = '';
+        try {
+            const resp = await fetch('/api/shares?path=' + encodeURIComponent(currentPath()));
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('share.load_failed', 'Failed to load share links'));
+                return;
+            }
+            if (data.shares.length === 0) {
+                const empty = document.createElement('li');
+                empty.textContent = t('share.none', 'This page has not been shared yet.');
+                list.appendChild(empty);
+            }
+            data.shares.forEach(share => list.appendChild(shareItem(share)));
+        } catch (error) {
+            console.error('Error loading share links:', error);
+            showError(t('share.load_failed', 'Failed to load share links'));
+        }
+    }
+
+    function shareItem(share) {
+        const item = document.createElement('li');
+        item.className = 'share-item' + (share.active ? '' : ' inactive');
+
+        const info = document.createElement('div');
+        const link = document.createElement('code');
+        link.textContent = share.url;
+        const meta = document.createElement('small');
+        meta.textContent = describe(share);
+        info.appendChild(link);
+        info.appendChild(meta);
+
+        // Latest accesses first
+        if (share.accesses.length > 0) {
+            const log = document.createElement('details');
+            const summary = document.createElement('summary');
+            summary.textContent = t('share.access_log', 'Access log');
+            log.appendChild(summary);
+            const entries = document.createElement('ul');
+            share.accesses.slice().reverse().forEach(access => {
+                const entry = document.createElement('li');
+                entry.textContent = new Date(access.time).toLocaleString() + ' · ' + access.ip;
+                entry.title = access.userAgent || '';
+                entries.appendChild(entry);
+            });
+            log.appendChild(entries);
+            info.appendChild(log);
+        }
+        item.appendChild(info);
+
+        if (share.active) {
+            const qr = document.createElement('button');
+            qr.type = 'button';
+            qr.className = 'dialog-button';
+            qr.title = t('share.qr_tooltip', 'Show a QR code of this link, to print it');
+            qr.innerHTML = '<i class="fa fa-qrcode"></i>';
+            qr.addEventListener('click', () => showQR('/api/qr?share=' + encodeURIComponent(share.id), share.url));
+            item.appendChild(qr);
+
+            const revoke = document.createElement('button');
+            revoke.type = 'button';
+            revoke.className = 'dialog-button';
+            revoke.textContent = t('share.revoke', 'Revoke');
+            revoke.addEventListener('click', async () => {
+                const resp = await fetch('/api/shares/' + encodeURIComponent(share.id), { method: 'DELETE' });
+                const data = await resp.json();
+                if (!resp.ok || !data.success) {
+                    showError(data.message || t('share.revoke_failed', 'Failed to revoke the share link'));
+                    return;
+                }
+                loadShares();
+            });
+            item.appendChild(revoke);
+        }
+        return item;
+    }
+
+    // The short link of the page, which keeps working when the page moves
+    async function loadPermalink() {
+        permalinkInput.value = '';
+        try {
+            const resp = await fetch('/api/permalink?path=' + encodeURIComponent(currentPath()));
+            const data = await resp.json();
+            if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+            permalinkInput.value = data.url;
+        } catch (error) {
+            console.error('Error loading the permalink:', error);
+            permalinkInput.placeholder = t('share.permalink_failed', 'Failed to load the permanent link');
+        }
+    }
+
+    // Shows the QR code drawn by the server for a link, with a download of a
+    // larger version for printing
+    function showQR(src, link) {
+        qrBox.querySelector('img').src = src + '&size=384';
+        qrBox.querySelector('.share-qr-link').textContent = link;
+        qrBox.querySelector('.share-qr-download').href = src + '&size=1024&download=1';
+        qrBox.hidden = false;
+    }
+
+    function openDialog() {
+        showError('');
+        form.reset();
+        linkInput.value = '';
+        qrBox.hidden = true;
+        dialog.classList.add('active');
+        loadPermalink();
+        loadShares();
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    async function createLink(e) {
+        e.preventDefault();
+        showError('');
+        try {
+            const resp = await fetch('/api/shares', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    path: currentPath(),
+                    days: parseInt(document.getElementById('shareExpiry').value, 10),
+                    password: document.getElementById('sharePassword').value,
+                    attachments: document.getElementById('shareAttachments').checked
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('share.failed', 'Failed to create the share link'));
+                return;
+            }
+            document.getElementById('sharePassword').value = '';
+            linkInput.value = data.url;
+            linkInput.select();
+            loadShares();
+        } catch (error) {
+            console.error('Error creating share link:', error);
+            showError(t('share.failed', 'Failed to create the share link'));
+        }
+    }
+
+    function copyInput(input) {
+        if (!input.value) return;
+        navigator.clipboard.writeText(input.value).catch(() => {
+            input.select();
+            document.execCommand('copy');
+        });
+    }
+
+    button.addEventListener('click', openDialog);
+    form.addEventListener('submit', createLink);
+    dialog.querySelector('.copy-share-link').addEventListener('click', () => copyInput(linkInput));
+    dialog.querySelector('.copy-permalink').addEventListener('click', () => copyInput(permalinkInput));
+    dialog.querySelector('.show-permalink-qr').addEventListener('click', () => {
+        showQR('/api/qr?permalink=1&path=' + encodeURIComponent(currentPath()), permalinkInput.value);
+    });
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+})();
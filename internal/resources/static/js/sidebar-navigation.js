@@ -1,5 +1,6 @@
 // Sidebar Navigation Module for Wiki-Go
-// Handles sidebar functionality, hamburger menu toggle, and touch gestures
+// Handles sidebar functionality, hamburger menu toggle, touch gestures, and
+// expanding directories whose children are loaded on demand
 (function() {
     'use strict';
 
@@ -23,6 +24,7 @@
         initHamburgerMenu();
         initClickOutside();
         initSidebarLinks();
+        initTreeToggles();
         initTouchGestures();
 
         // Scroll current document into view in sidebar
@@ -132,6 +134,81 @@
         });
     }
 
+    // Clicking a directory's arrow expands or collapses it instead of opening the
+    // page. Children of collapsed directories aren't part of the page and are
+    // fetched the first time the directory is expanded.
+    function initTreeToggles() {
+        const navItems = document.querySelector('.nav-items');
+        if (!navItems) return;
+
+        // Capture phase, so the link handlers don't see arrow clicks
+        navItems.addEventListener('click', function(e) {
+            const arrow = e.target.closest('.nav-arrow');
+            if (!arrow) return;
+            e.preventDefault();
+            e.stopPropagation();
+            toggleTreeItem(arrow.closest('.nav-item'));
+        }, true);
+    }
+
+    async function toggleTreeItem(item) {
+        if (!item) return;
+
+        const open = item.classList.contains('expanded') ||
+            (item.classList.contains('active') && !item.classList.contains('collapsed'));
+        if (open) {
+            item.classList.remove('expanded');
+            item.classList.add('collapsed');
+            return;
+        }
+
+        item.classList.remove('collapsed');
+        item.classList.add('expanded');
+        if (item.querySelector(':scope > .nav-children') || item.dataset.loading) return;
+
+        item.dataset.loading = 'true';
+        try {
+            const response = await fetch('/api/tree?path=' + encodeURIComponent(item.dataset.path));
+            if (!response.ok) throw new Error('HTTP ' + response.status);
+            const children = await response.json();
+
+            const container = document.createElement('div');
+            container.className = 'nav-children';
+            container.style.paddingLeft = '16px';
+            children.forEach(child => container.appendChild(createTreeItem(child)));
+            item.appendChild(container);
+        } catch (error) {
+            console.error('Error loading directory:', error);
+            item.classList.remove('expanded');
+        } finally {
+            delete item.dataset.loading;
+        }
+    }
+
+    // Build a sidebar entry like the ones rendered by the nav-items template
+    function createTreeItem(child) {
+        const item = document.createElement('div');
+        item.className = 'nav-item directory';
+        item.dataset.path = child.path;
+
+        const link = document.createElement('a');
+        link.href = child.path;
+        link.textContent = child.title;
+        link.addEventListener('click', function() {
+            if (window.innerWidth <= 768) {
+                closeSidebar();
+            }
+        });
+        if (child.hasChildren) {
+            const arrow = document.createElement('span');
+            arrow.className = 'nav-arrow';
+            arrow.setAttribute('aria-hidden', 'true');
+            link.appendChild(arrow);
+        }
+        item.appendChild(link);
+        return item;
+    }
+
     // Touch gestures for mobile
     function initTouchGestures() {
         // Handle touch start
@@ -171,6 +248,7 @@
         sidebar.classList.toggle('active');
         body.classList.toggle('sidebar-active');
         content.classList.toggle('sidebar-active');
+        hamburger.setAttribute('aria-expanded', sidebar.classList.contains('active') ? 'true' : 'false');
     }
 
     // Open sidebar
@@ -181,6 +259,7 @@
         sidebar.classList.add('active');
         body.classList.add('sidebar-active');
         content.classList.add('sidebar-active');
+        hamburger.setAttribute('aria-expanded', sidebar.classList.contains('active') ? 'true' : 'false');
     }
 
     // Close sidebar
@@ -191,6 +270,7 @@
         sidebar.classList.remove('active');
         body.classList.remove('sidebar-active');
         content.classList.remove('sidebar-active');
+        hamburger.setAttribute('aria-expanded', sidebar.classList.contains('active') ? 'true' : 'false');
     }
 
     // Expose functions to global scope
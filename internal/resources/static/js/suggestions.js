@@ -0,0 +1,311 @@
+/**
+ * Suggested changes
+ * Readers who may not edit a page suggest changes to its markdown; the editors
+ * of the page review them hunk by hunk, accepting some and leaving the others,
+ * or reject them. Authors can withdraw their own pending suggestions.
+ */
+
+(function() {
+    'use strict';
+
+    const suggestButton = document.querySelector('.suggest-changes');
+    const reviewButton = document.querySelector('.page-suggestions');
+    const suggestDialog = document.querySelector('.suggest-dialog');
+    const reviewDialog = document.querySelector('.suggestions-dialog');
+    if (!suggestButton || !reviewButton || !suggestDialog || !reviewDialog) return;
+
+    const contentInput = suggestDialog.querySelector('#suggestContent');
+    const summaryInput = suggestDialog.querySelector('#suggestSummary');
+    const list = reviewDialog.querySelector('.suggestions-list');
+    const review = reviewDialog.querySelector('.suggestion-review');
+    const header = reviewDialog.querySelector('.suggestion-header');
+    const hunksBox = reviewDialog.querySelector('.suggestion-hunks');
+    const acceptButton = reviewDialog.querySelector('.suggestion-accept');
+    const rejectButton = reviewDialog.querySelector('.suggestion-reject');
+    const withdrawButton = reviewDialog.querySelector('.suggestion-withdraw');
+
+    let base = '';
+    let pending = [];
+    let current = null;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function showError(dialog, message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    async function request(url, options) {
+        const response = await fetch(url, Object.assign({ credentials: 'same-origin' }, options));
+        const data = await response.json().catch(() => ({}));
+        if (!response.ok) {
+            throw new Error((data.message || response.statusText) + (data.error ? ': ' + data.error : ''));
+        }
+        return data;
+    }
+
+    function formatDate(value) {
+        return new Date(value).toLocaleString();
+    }
+
+    // ===== TOOLBAR =====
+
+    // refresh shows the suggest button to those who may suggest changes, and
+    // the pending suggestions to reviewers and to their authors
+    async function refresh() {
+        let data;
+        try {
+            data = await request('/api/suggestions?path=' + encodeURIComponent(currentPath()));
+        } catch (error) {
+            return;
+        }
+        pending = data.suggestions || [];
+        suggestButton.hidden = !data.canSuggest;
+        reviewButton.hidden = pending.length === 0;
+        reviewButton.querySelector('.suggestions-count').textContent = pending.length ? String(pending.length) : '';
+    }
+
+    // ===== SUGGESTING =====
+
+    async function openSuggest() {
+        showError(suggestDialog, '');
+        try {
+            const response = await fetch('/api/suggestions/source?path=' + encodeURIComponent(currentPath()), { credentials: 'same-origin' });
+            if (!response.ok) throw new Error(response.statusText);
+            base = await response.text();
+        } catch (error) {
+            window.DialogSystem.showMessageDialog(t('suggestions.failed', 'Suggestions are not available'), error.message);
+            return;
+        }
+        contentInput.value = base;
+        summaryInput.value = '';
+        suggestDialog.classList.add('active');
+        contentInput.focus();
+    }
+
+    function closeSuggest() {
+        suggestDialog.classList.remove('active');
+    }
+
+    async function submitSuggestion(event) {
+        event.preventDefault();
+        showError(suggestDialog, '');
+        try {
+            await request('/api/suggestions', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    path: currentPath(),
+                    base: base,
+                    content: contentInput.value,
+                    summary: summaryInput.value
+                })
+            });
+        } catch (error) {
+            showError(suggestDialog, error.message);
+            return;
+        }
+        closeSuggest();
+        window.DialogSystem.showMessageDialog(
+            t('suggestions.sent_title', 'Suggestion sent'),
+            t('suggestions.sent', 'The editors of the page were told about your suggestion.')
+        );
+        refresh();
+    }
+
+    // ===== REVIEWING =====
+
+    function renderList() {
+        list.innerHTML = '';
+        review.hidden = true;
+        list.hidden = false;
+        if (pending.length === 0) {
+            const empty = document.createElement('li');
+            empty.className = 'suggestions-empty';
+            empty.textContent = t('suggestions.empty', 'No pending suggestions');
+            list.appendChild(empty);
+            return;
+        }
+        pending.forEach(suggestion => {
+            const item = document.createElement('li');
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'suggestions-item';
+            const title = document.createElement('strong');
+            title.textContent = suggestion.summary || t('suggestions.no_summary', 'No summary');
+            const meta = document.createElement('small');
+            meta.textContent = `${suggestion.author} · ${formatDate(suggestion.created)} · +${suggestion.inserted} −${suggestion.deleted}`;
+            button.append(title, meta);
+            button.addEventListener('click', () => openSuggestion(suggestion.id));
+            item.appendChild(button);
+            list.appendChild(item);
+        });
+    }
+
+    function renderHunk(hunk, index, selectable) {
+        const box = document.createElement('div');
+        box.className = 'suggestion-hunk' + (hunk.applies ? '' : ' conflict');
+
+        const label = document.createElement('label');
+        label.className = 'suggestion-hunk-header';
+        if (selectable) {
+            const checkbox = document.createElement('input');
+            checkbox.type = 'checkbox';
+            checkbox.value = index;
+            checkbox.checked = hunk.applies;
+            checkbox.disabled = !hunk.applies;
+            label.appendChild(checkbox);
+        }
+        const text = t('suggestions.change', 'Change {n}, line {line}')
+            .replace('{n}', index + 1)
+            .replace('{line}', hunk.oldStart);
+        label.append(' ' + text);
+        if (!hunk.applies) {
+            const warning = document.createElement('span');
+            warning.className = 'suggestion-conflict';
+            warning.textContent = t('suggestions.conflict', 'The page changed here since, this change no longer applies');
+            label.append(' ', warning);
+        }
+        box.appendChild(label);
+
+        const table = document.createElement('table');
+        table.className = 'compare-hunk';
+        const marks = { equal: ' ', delete: '-', insert: '+' };
+        hunk.lines.forEach(line => {
+            const row = table.insertRow();
+            row.className = 'compare-line compare-' + line.kind;
+            row.insertCell().textContent = line.old || '';
+            row.insertCell().textContent = line.new || '';
+            const cell = row.insertCell();
+            cell.textContent = marks[line.kind] + ' ' + line.text;
+            cell.dir = 'auto';
+        });
+        box.appendChild(table);
+        return box;
+    }
+
+    async function openSuggestion(id) {
+        showError(reviewDialog, '');
+        let data;
+        try {
+            data = await request('/api/suggestions/' + encodeURIComponent(id));
+        } catch (error) {
+            showError(reviewDialog, error.message);
+            return;
+        }
+        current = data;
+
+        const summary = data.suggestion;
+        header.innerHTML = '';
+        const title = document.createElement('h3');
+        title.textContent = summary.summary || t('suggestions.no_summary', 'No summary');
+        const meta = document.createElement('p');
+        meta.className = 'form-help';
+        meta.textContent = `${summary.author} · ${formatDate(summary.created)}`;
+        header.append(title, meta);
+        if (data.status !== 'pending') {
+            const status = document.createElement('p');
+            status.className = 'suggestion-status';
+            status.textContent = t('suggestions.status_' + data.status, data.status) + (data.reviewer ? ' · ' + data.reviewer : '');
+            header.appendChild(status);
+        }
+
+        hunksBox.innerHTML = '';
+        data.hunks.forEach((hunk, index) => hunksBox.appendChild(renderHunk(hunk, index, data.canReview)));
+
+        acceptButton.hidden = !data.canReview;
+        rejectButton.hidden = !data.canReview;
+        withdrawButton.hidden = !data.canWithdraw;
+        list.hidden = true;
+        review.hidden = false;
+        reviewDialog.classList.add('active');
+    }
+
+    async function act(action) {
+        if (!current) return;
+        const hunks = Array.from(hunksBox.querySelectorAll('input[type="checkbox"]:checked')).map(box => Number(box.value));
+        showError(reviewDialog, '');
+        try {
+            await request('/api/suggestions/' + encodeURIComponent(current.suggestion.id), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ action: action, hunks: hunks })
+            });
+        } catch (error) {
+            showError(reviewDialog, error.message);
+            return;
+        }
+        if (action === 'accept') {
+            window.location.href = window.location.pathname;
+            return;
+        }
+        await refresh();
+        renderList();
+    }
+
+    async function openReview() {
+        showError(reviewDialog, '');
+        await refresh();
+        renderList();
+        reviewDialog.classList.add('active');
+    }
+
+    function closeReview() {
+        reviewDialog.classList.remove('active');
+    }
+
+    // ===== EVENTS =====
+
+    suggestButton.addEventListener('click', openSuggest);
+    reviewButton.addEventListener('click', openReview);
+    suggestDialog.querySelector('.suggest-form').addEventListener('submit', submitSuggestion);
+    suggestDialog.querySelector('.cancel-dialog').addEventListener('click', closeSuggest);
+    suggestDialog.querySelector('.close-dialog').addEventListener('click', closeSuggest);
+    reviewDialog.querySelector('.close-dialog').addEventListener('click', closeReview);
+    reviewDialog.querySelector('.suggestion-back').addEventListener('click', renderList);
+    acceptButton.addEventListener('click', () => act('accept'));
+    rejectButton.addEventListener('click', () => act('reject'));
+    withdrawButton.addEventListener('click', () => act('withdraw'));
+    [suggestDialog, reviewDialog].forEach(dialog => {
+        dialog.addEventListener('keydown', event => {
+            if (event.key !== 'Escape') return;
+            event.stopPropagation();
+            dialog.classList.remove('active');
+        });
+    });
+
+    if (window.CommandPalette) {
+        window.CommandPalette.register({
+            id: 'suggest-changes',
+            title: () => t('suggestions.suggest_tooltip', 'Suggest changes to this page'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'suggest propose change edit review',
+            icon: 'fa-commenting-o',
+            when: () => !suggestButton.hidden,
+            run: openSuggest
+        });
+        window.CommandPalette.register({
+            id: 'review-suggestions',
+            title: () => t('suggestions.tooltip', 'Review the suggested changes'),
+            section: () => t('palette.section_page', 'Page'),
+            keywords: 'suggestions review pending accept reject',
+            icon: 'fa-inbox',
+            when: () => !reviewButton.hidden,
+            run: openReview
+        });
+    }
+
+    // Notifications link to the suggestion they are about
+    const linked = new URLSearchParams(window.location.search).get('suggestion');
+    refresh().then(() => {
+        if (linked) openSuggestion(linked);
+    });
+})();
@@ -25,6 +25,17 @@ window.addEventListener('afterprint', function() {
     } else if (window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches) {
         document.documentElement.setAttribute('data-theme', 'dark');
     }
+
+    // High contrast: the reader's choice, else the wiki default or the system preference
+    var savedContrast = localStorage.getItem('contrast');
+    var defaultMeta = document.querySelector('meta[name="high-contrast"]');
+    var highContrast = savedContrast
+        ? savedContrast === 'high'
+        : (defaultMeta && defaultMeta.getAttribute('content') === 'true') ||
+          (window.matchMedia && window.matchMedia('(prefers-contrast: more)').matches);
+    if (highContrast) {
+        document.documentElement.setAttribute('data-contrast', 'high');
+    }
 })();
 
 document.addEventListener('DOMContentLoaded', function() {
@@ -35,6 +46,7 @@ document.addEventListener('DOMContentLoaded', function() {
     const root = document.documentElement;
     const lightIcon = document.querySelector('.light-icon');
     const darkIcon = document.querySelector('.dark-icon');
+    const contrastToggle = document.querySelector('.sidebar-footer-buttons .contrast-toggle');
 
     // Initialize theme
     initializeTheme();
@@ -44,6 +56,12 @@ document.addEventListener('DOMContentLoaded', function() {
         themeToggle.addEventListener('click', toggleTheme);
     }
 
+    // Add high contrast toggle event listener
+    if (contrastToggle) {
+        contrastToggle.addEventListener('click', toggleContrast);
+    }
+    updateContrastUI();
+
     // Apply system preference changes
     window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', handleSystemThemeChange);
 
@@ -101,17 +119,17 @@ document.addEventListener('DOMContentLoaded', function() {
         if (theme === 'dark') {
             if (lightIcon) lightIcon.style.display = 'none';
             if (darkIcon) darkIcon.style.display = 'block';
-            // Use dark theme for syntax highlighting
+            // Use dark theme for syntax highlighting, unless the directory of the page fixed one
             const prismTheme = document.getElementById('prism-theme');
-            if (prismTheme) {
+            if (prismTheme && !prismTheme.dataset.fixed) {
                 prismTheme.href = '/static/libs/prism-1.30.0/prism-tomorrow.min.css';
             }
         } else {
             if (lightIcon) lightIcon.style.display = 'block';
             if (darkIcon) darkIcon.style.display = 'none';
-            // Use light theme for syntax highlighting
+            // Use light theme for syntax highlighting, unless the directory of the page fixed one
             const prismTheme = document.getElementById('prism-theme');
-            if (prismTheme) {
+            if (prismTheme && !prismTheme.dataset.fixed) {
                 prismTheme.href = '/static/libs/prism-1.30.0/prism.min.css';
             }
         }
@@ -123,6 +141,29 @@ document.addEventListener('DOMContentLoaded', function() {
         document.dispatchEvent(themeChangeEvent);
     }
 
+    /**
+     * Toggle the high-contrast colors on top of the light or dark theme
+     */
+    function toggleContrast() {
+        const highContrast = root.getAttribute('data-contrast') !== 'high';
+        if (highContrast) {
+            root.setAttribute('data-contrast', 'high');
+        } else {
+            root.removeAttribute('data-contrast');
+        }
+        localStorage.setItem('contrast', highContrast ? 'high' : 'normal');
+        updateContrastUI();
+    }
+
+    /**
+     * Update the pressed state of the high contrast toggle
+     */
+    function updateContrastUI() {
+        if (contrastToggle) {
+            contrastToggle.setAttribute('aria-pressed', root.getAttribute('data-contrast') === 'high' ? 'true' : 'false');
+        }
+    }
+
     /**
      * Handle system theme preference changes
      * @param {MediaQueryListEvent} e - Media query change event
@@ -143,6 +184,10 @@ document.addEventListener('DOMContentLoaded', function() {
         },
         setTheme: applyTheme,
         toggleTheme: toggleTheme,
-        updateUI: updateThemeUI
+        updateUI: updateThemeUI,
+        isHighContrast: function() {
+            return root.getAttribute('data-contrast') === 'high';
+        },
+        toggleContrast: toggleContrast
     };
 });
\ No newline at end of file
@@ -0,0 +1,371 @@
+// Translation dashboard: lists the missing, outdated and machine translations
+// of the pages the user may edit, assigns them to translators and edits a
+// translation next to its source page
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.translations-dialog');
+    if (!dialog) return;
+
+    const overview = dialog.querySelector('.translations-overview');
+    const editorView = dialog.querySelector('.translations-editor');
+    const tableBody = dialog.querySelector('.translations-table tbody');
+    const languageFilter = document.getElementById('translationsLanguage');
+    const stateFilter = document.getElementById('translationsState');
+    const mineFilter = document.getElementById('translationsMine');
+    const sourceArea = document.getElementById('translationsSource');
+    const targetArea = document.getElementById('translationsTarget');
+    let data = null;      // The last loaded dashboard
+    let editing = null;   // The task being translated
+    let loadedTarget = ''; // Content of the translation when it was opened
+    let machineFilled = false; // The translation was pre-filled by machine translation
+
+    function t(key, fallback, values = {}) {
+        let text = window.i18n ? window.i18n.t(key) : fallback;
+        Object.keys(values).forEach(name => {
+            text = text.replace(`{{${name}}}`, values[name]);
+        });
+        return text;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    // pageURL returns the source or save endpoint of a page, for a translation when lang is set
+    function pageURL(endpoint, path, lang) {
+        const url = `/api/${endpoint}${path === '/' ? '/' : encodeURI(path)}`;
+        return lang ? `${url}?lang=${encodeURIComponent(lang)}` : url;
+    }
+
+    function openDialog() {
+        window.Auth.checkUserRole('editor').then(canEdit => {
+            if (!canEdit) {
+                window.Auth.showPermissionError('editor');
+                return;
+            }
+            showError('');
+            showOverview();
+            dialog.classList.add('active');
+            load();
+        });
+    }
+
+    function closeDialog() {
+        confirmDiscard(() => {
+            editing = null;
+            dialog.classList.remove('active');
+        });
+    }
+
+    // confirmDiscard runs callback, after asking when the open translation has unsaved changes
+    function confirmDiscard(callback) {
+        if (!editing || targetArea.value === loadedTarget) {
+            callback();
+            return;
+        }
+        window.DialogSystem.showConfirmDialog(
+            t('translations.discard_title', 'Unsaved Translation'),
+            t('translations.discard_message', 'Discard the changes to this translation?'),
+            confirmed => {
+                if (confirmed) callback();
+            }
+        );
+    }
+
+    async function load() {
+        try {
+            const resp = await fetch('/api/translations');
+            const result = await resp.json();
+            if (!resp.ok || !result.success) {
+                showError(result.message || 'Failed to load translations');
+                return;
+            }
+            data = result;
+            fillLanguages();
+            render();
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Failed to load translations');
+        }
+    }
+
+    // fillLanguages lists the configured languages and those of existing translations
+    function fillLanguages() {
+        const selected = languageFilter.value;
+        const languages = new Set(data.languages || []);
+        data.tasks.forEach(task => languages.add(task.language));
+        while (languageFilter.options.length > 1) {
+            languageFilter.remove(1);
+        }
+        Array.from(languages).sort().forEach(lang => {
+            languageFilter.add(new Option(lang, lang));
+        });
+        languageFilter.value = languages.has(selected) ? selected : '';
+    }
+
+    function stateLabel(state) {
+        switch (state) {
+            case 'missing':
+                return t('translations.state_missing', 'Missing');
+            case 'machine':
+                return t('translations.state_machine', 'Machine translated');
+            default:
+                return t('translations.state_outdated', 'Outdated');
+        }
+    }
+
+    function assigneeSelect(task) {
+        const select = document.createElement('select');
+        select.setAttribute('aria-label', t('translations.assignee', 'Translator'));
+        select.add(new Option(t('translations.unassigned', 'Unassigned'), ''));
+        const translators = new Set(data.translators || []);
+        if (task.assignee) translators.add(task.assignee);
+        translators.forEach(name => select.add(new Option(name, name)));
+        select.value = task.assignee || '';
+        select.addEventListener('change', () => assign(task, select));
+        return select;
+    }
+
+    function render() {
+        const lang = languageFilter.value;
+        const state = stateFilter.value;
+        const tasks = data.tasks.filter(task =>
+            (!lang || task.language === lang) &&
+            (!state || task.state === state) &&
+            (!mineFilter.checked || task.assignee === data.user));
+
+        tableBody.textContent = '';
+        tasks.forEach(task => {
+            const row = document.createElement('tr');
+
+            const page = document.createElement('td');
+            const link = document.createElement('a');
+            link.href = task.path;
+            link.target = '_blank';
+            link.textContent = task.title || task.path;
+            const path = document.createElement('small');
+            path.textContent = task.path;
+            page.append(link, path);
+
+            const language = document.createElement('td');
+            language.textContent = task.language;
+
+            const status = document.createElement('td');
+            const badge = document.createElement('span');
+            badge.className = `translation-state ${task.state}`;
+            badge.textContent = stateLabel(task.state);
+            status.appendChild(badge);
+            if (task.state === 'outdated' && task.translated) {
+                const since = document.createElement('small');
+                since.textContent = t('translations.translated_on', 'Translated {{date}}', {
+                    date: new Date(task.translated).toLocaleDateString()
+                });
+                status.appendChild(since);
+            }
+
+            const assignee = document.createElement('td');
+            assignee.appendChild(assigneeSelect(task));
+
+            const actions = document.createElement('td');
+            const translate = document.createElement('button');
+            translate.type = 'button';
+            translate.className = 'dialog-button';
+            translate.textContent = t('translations.translate_button', 'Translate');
+            translate.addEventListener('click', () => openEditor(task));
+            actions.appendChild(translate);
+
+            row.append(page, language, status, assignee, actions);
+            tableBody.appendChild(row);
+        });
+
+        dialog.querySelector('.translations-summary').textContent = tasks.length === 0
+            ? t('translations.nothing_pending', 'All pages are translated and up to date')
+            : t('translations.pending_count', '{{count}} translations to do', { count: tasks.length });
+    }
+
+    async function assign(task, select) {
+        showError('');
+        try {
+            const resp = await fetch('/api/translations/assign', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: task.path, language: task.language, assignee: select.value })
+            });
+            const result = await resp.json();
+            if (!resp.ok || !result.success) {
+                showError(result.message || 'Failed to assign the translation');
+                select.value = task.assignee || '';
+                return;
+            }
+            task.assignee = select.value;
+            if (mineFilter.checked) render();
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Failed to assign the translation');
+            select.value = task.assignee || '';
+        }
+    }
+
+    function showOverview() {
+        editing = null;
+        editorView.hidden = true;
+        overview.hidden = false;
+    }
+
+    async function openEditor(task) {
+        showError('');
+        try {
+            // Missing translations start from the source page
+            const [source, target] = await Promise.all([
+                fetch(pageURL('source', task.path)),
+                fetch(pageURL('source', task.path, task.language))
+            ]);
+            if (!source.ok || !target.ok) {
+                showError('Failed to load the page');
+                return;
+            }
+            sourceArea.value = await source.text();
+            loadedTarget = await target.text();
+            targetArea.value = loadedTarget;
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Failed to load the page');
+            return;
+        }
+
+        editing = task;
+        machineFilled = false;
+        dialog.querySelector('.translations-confirm').hidden = task.state !== 'machine';
+        const link = dialog.querySelector('.translations-editor-page');
+        link.href = `${task.path}?lang=${encodeURIComponent(task.language)}`;
+        link.textContent = task.title || task.path;
+        dialog.querySelector('.translations-editor-state').textContent = stateLabel(task.state);
+        dialog.querySelector('.translations-source-language').textContent = `(${data.source})`;
+        dialog.querySelector('.translations-target-language').textContent = `(${task.language})`;
+        targetArea.setAttribute('lang', task.language);
+        overview.hidden = true;
+        editorView.hidden = false;
+        sourceArea.scrollTop = 0;
+        targetArea.scrollTop = 0;
+        targetArea.focus();
+    }
+
+    // save saves the translation and returns to the list, resolving to whether it was saved
+    async function save() {
+        if (!editing) return false;
+        showError('');
+        try {
+            const url = pageURL('save', editing.path, editing.language) + (machineFilled ? '&machine=1' : '');
+            const resp = await fetch(url, {
+                method: 'POST',
+                headers: { 'Content-Type': 'text/plain' },
+                body: targetArea.value
+            });
+            const result = await resp.json();
+            if (!resp.ok || !result.success) {
+                let message = result.message || 'Failed to save the translation';
+                (result.issues || []).forEach(issue => {
+                    message += `. ${issue.line ? issue.line + ': ' : ''}${issue.message}`;
+                });
+                showError(message);
+                return false;
+            }
+            showOverview();
+            load();
+            return true;
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Failed to save the translation');
+            return false;
+        }
+    }
+
+    // machineTranslate replaces the translation with a machine translation of the source
+    async function machineTranslate() {
+        if (!editing) return;
+        showError('');
+        const button = dialog.querySelector('.translations-machine');
+        button.disabled = true;
+        try {
+            const resp = await fetch('/api/translations/machine', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: editing.path, language: editing.language })
+            });
+            const result = await resp.json();
+            if (!resp.ok || !result.success) {
+                showError(result.error ? `${result.message}: ${result.error}` : (result.message || 'Machine translation failed'));
+                return;
+            }
+            targetArea.value = result.content;
+            machineFilled = true;
+            targetArea.focus();
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Machine translation failed');
+        } finally {
+            button.disabled = false;
+        }
+    }
+
+    // confirmReviewed records that the machine translation was reviewed, saving changes to it first
+    async function confirmReviewed() {
+        if (!editing) return;
+        const task = editing;
+        if (targetArea.value !== loadedTarget && !(await save())) return;
+        showError('');
+        try {
+            const resp = await fetch('/api/translations/confirm', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: task.path, language: task.language })
+            });
+            const result = await resp.json();
+            if (!resp.ok || !result.success) {
+                showError(result.message || 'Failed to confirm the translation');
+                return;
+            }
+            showOverview();
+            load();
+        } catch (error) {
+            console.error('Translations error:', error);
+            showError('Failed to confirm the translation');
+        }
+    }
+
+    // Keep both panes at the same relative position
+    let syncing = false;
+    function syncScroll(from, to) {
+        if (syncing) return;
+        syncing = true;
+        const range = from.scrollHeight - from.clientHeight;
+        to.scrollTop = range > 0 ? (from.scrollTop / range) * (to.scrollHeight - to.clientHeight) : 0;
+        requestAnimationFrame(() => {
+            syncing = false;
+        });
+    }
+    sourceArea.addEventListener('scroll', () => syncScroll(sourceArea, targetArea));
+    targetArea.addEventListener('scroll', () => syncScroll(targetArea, sourceArea));
+
+    [languageFilter, stateFilter, mineFilter].forEach(filter => filter.addEventListener('change', render));
+    dialog.querySelector('.translations-save').addEventListener('click', save);
+    dialog.querySelector('.translations-confirm').addEventListener('click', confirmReviewed);
+    const machineButton = dialog.querySelector('.translations-machine');
+    if (machineButton) machineButton.addEventListener('click', machineTranslate);
+    dialog.querySelector('.translations-back').addEventListener('click', () => confirmDiscard(showOverview));
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.addEventListener('click', e => {
+        if (e.target === dialog) closeDialog();
+    });
+
+    const button = document.querySelector('.translations-button');
+    if (button) button.addEventListener('click', openDialog);
+
+    window.TranslationDashboard = {
+        open: openDialog,
+        close: closeDialog
+    };
+})();
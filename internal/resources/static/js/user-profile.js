@@ -0,0 +1,157 @@
+/**
+ * User activity
+ * User names link to the activity of the user: their recent edits, the pages
+ * they created and their comments, on the pages the reader may see. A link to
+ * /?user=NAME opens it directly.
+ */
+
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.profile-dialog');
+    if (!dialog) return;
+
+    function t(key, fallback) {
+        const text = window.i18n ? window.i18n.t(key) : fallback;
+        return text && text !== key ? text : fallback;
+    }
+
+    function formatDate(value) {
+        return new Date(value).toLocaleString();
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function showList(name) {
+        dialog.querySelectorAll('.profile-tab').forEach(tab => {
+            const active = tab.dataset.list === name;
+            tab.classList.toggle('active', active);
+            tab.setAttribute('aria-selected', active ? 'true' : 'false');
+        });
+        dialog.querySelectorAll('.profile-list').forEach(list => {
+            list.hidden = list.dataset.list !== name;
+        });
+    }
+
+    // One page of a list: its title, when, and the summary or excerpt
+    function renderEntry(entry, detail, minor) {
+        const item = document.createElement('li');
+        const link = document.createElement('a');
+        link.href = entry.path;
+        link.textContent = entry.title;
+        const meta = document.createElement('span');
+        meta.className = 'profile-meta';
+        meta.textContent = formatDate(entry.time) + (minor ? ' · ' + t('history.minor', 'minor') : '');
+        item.append(link, meta);
+        if (detail) {
+            const text = document.createElement('span');
+            text.className = 'profile-detail';
+            text.dir = 'auto';
+            text.textContent = detail;
+            item.appendChild(text);
+        }
+        return item;
+    }
+
+    function renderList(name, entries, total, emptyText, render) {
+        const list = dialog.querySelector(`.profile-list[data-list="${name}"]`);
+        list.innerHTML = '';
+        dialog.querySelector(`.profile-tab[data-list="${name}"] .profile-count`).textContent = `(${total})`;
+        if (entries.length === 0) {
+            const empty = document.createElement('li');
+            empty.className = 'profile-empty';
+            empty.textContent = emptyText;
+            list.appendChild(empty);
+            return;
+        }
+        entries.forEach(entry => list.appendChild(render(entry)));
+    }
+
+    function renderProfile(profile) {
+        dialog.querySelector('.profile-name').textContent = profile.user;
+
+        const totals = profile.totals;
+        let summary = t('profile.summary', '{edits} edits on {pages} pages, {created} pages created, {comments} comments')
+            .replace('{edits}', totals.edits)
+            .replace('{pages}', totals.pages)
+            .replace('{created}', totals.created)
+            .replace('{comments}', totals.comments);
+        if (profile.last) {
+            summary += ' · ' + t('profile.last_edit', 'last edit {date}').replace('{date}', formatDate(profile.last));
+        }
+        dialog.querySelector('.profile-summary').textContent = summary;
+
+        renderList('edits', profile.edits, totals.edits, t('profile.no_edits', 'No edits'),
+            edit => renderEntry(edit, edit.summary, edit.minor));
+        renderList('created', profile.created, totals.created, t('profile.no_created', 'No pages created'),
+            page => renderEntry(page, page.summary, false));
+        renderList('comments', profile.comments, totals.comments, t('profile.no_comments', 'No comments'),
+            comment => renderEntry(comment, comment.excerpt, false));
+    }
+
+    // Open the activity of a user, of the logged in user without a name
+    async function open(name) {
+        showError('');
+        let data;
+        try {
+            const response = await fetch('/api/profile/' + encodeURIComponent(name || ''), { credentials: 'same-origin' });
+            data = await response.json().catch(() => ({}));
+            if (!response.ok) throw new Error(data.message || response.statusText);
+        } catch (error) {
+            window.DialogSystem.showMessageDialog(t('profile.failed', 'The activity could not be loaded'), error.message);
+            return;
+        }
+        renderProfile(data.profile);
+        showList('edits');
+        dialog.classList.add('active');
+        dialog.querySelector('.profile-tab.active').focus();
+    }
+
+    function close() {
+        dialog.classList.remove('active');
+    }
+
+    dialog.querySelectorAll('.profile-tab').forEach(tab => {
+        tab.addEventListener('click', () => showList(tab.dataset.list));
+    });
+    dialog.querySelector('.close-dialog').addEventListener('click', close);
+    dialog.addEventListener('keydown', event => {
+        if (event.key !== 'Escape') return;
+        event.stopPropagation();
+        close();
+    });
+
+    // User names anywhere on the page open the activity instead of navigating
+    document.addEventListener('click', event => {
+        const link = event.target.closest('a.user-link[data-user]');
+        if (!link || event.ctrlKey || event.metaKey || event.shiftKey) return;
+        event.preventDefault();
+        open(link.dataset.user);
+    });
+
+    function loggedIn() {
+        const role = document.querySelector('meta[name="user-role"]');
+        return role !== null && role.content !== '';
+    }
+
+    if (window.CommandPalette) {
+        window.CommandPalette.register({
+            id: 'my-activity',
+            title: () => t('profile.mine', 'My activity'),
+            section: () => t('palette.section_account', 'Account'),
+            keywords: 'profile activity edits contributions comments me',
+            icon: 'fa-user-circle-o',
+            when: loggedIn,
+            run: () => open('')
+        });
+    }
+
+    const linked = new URLSearchParams(window.location.search).get('user');
+    if (linked) open(linked);
+
+    window.UserProfile = { open };
+})();
@@ -92,37 +92,72 @@
 
             // Render the versions list
             console.log("Number of versions found:", data.versions ? data.versions.length : 0);
-            renderVersionsList(data.versions);
+            renderVersionsList(data.versions, data.current);
         } catch (error) {
             console.error('Error loading document versions:', error);
             versionList.innerHTML = `<div class="error-message">Failed to load versions: ${error.message}</div>`;
         }
     }
 
+    function escapeText(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML.replace(/"/g, '&quot;');
+    }
+
+    // Format a version's timestamp (format: yyyymmddhhmmss) as a local date
+    function formatTimestamp(timestamp) {
+        const year = timestamp.substring(0, 4);
+        const month = timestamp.substring(4, 6);
+        const day = timestamp.substring(6, 8);
+        const hour = timestamp.substring(8, 10);
+        const minute = timestamp.substring(10, 12);
+        const second = timestamp.substring(12, 14);
+
+        const date = new Date(`${year}-${month}-${day}T${hour}:${minute}:${second}`);
+        return date.toLocaleString();
+    }
+
+    // Who made a version, and the summary they gave of their edit
+    function versionDetails(version) {
+        let html = '';
+        if (version.author || version.minor) {
+            const minor = window.i18n ? window.i18n.t('history.minor') : 'minor';
+            html += `<div class="version-author">${version.author ? `<a class="user-link" href="/?user=${encodeURIComponent(version.author)}" data-user="${escapeText(version.author)}">${escapeText(version.author)}</a>` : ''}${version.minor ? ` <span class="version-minor" data-i18n="history.minor">${minor}</span>` : ''}</div>`;
+        }
+        if (version.summary) {
+            html += `<div class="version-summary" dir="auto">${escapeText(version.summary)}</div>`;
+        }
+        return html;
+    }
+
     // Render the list of document versions
-    function renderVersionsList(versions) {
+    function renderVersionsList(versions, current) {
         if (!versions || versions.length === 0) {
             versionList.innerHTML = `<div class="empty-message">${window.i18n ? window.i18n.t('history.no_versions') : 'No previous versions found'}</div>`;
             return;
         }
 
+        let currentHtml = '';
+        if (current) {
+            currentHtml = `
+                <div class="version-item version-current">
+                    <div class="version-info">
+                        <div class="version-date"><span data-i18n="history.current">${window.i18n ? window.i18n.t('history.current') : 'Current version'}</span> · ${formatTimestamp(current.timestamp)}</div>
+                        ${versionDetails(current)}
+                    </div>
+                </div>
+            `;
+        }
+
         const html = versions.map(version => {
-            // Create a Date object from the version's timestamp (format: yyyymmddhhmmss)
-            const timestamp = version.timestamp;
-            const year = timestamp.substring(0, 4);
-            const month = timestamp.substring(4, 6);
-            const day = timestamp.substring(6, 8);
-            const hour = timestamp.substring(8, 10);
-            const minute = timestamp.substring(10, 12);
-            const second = timestamp.substring(12, 14);
-
-            const date = new Date(`${year}-${month}-${day}T${hour}:${minute}:${second}`);
-            const formattedDate = date.toLocaleString();
+            const formattedDate = formatTimestamp(version.timestamp);
 
             return `
                 <div class="version-item" data-version="${version.timestamp}">
                     <div class="version-info">
                         <div class="version-date">${formattedDate}</div>
+                        ${versionDetails(version)}
                     </div>
                     <div class="version-actions">
                         <button class="preview-version-btn" title="${window.i18n ? window.i18n.t('history.preview_button') : 'Preview this version'}" data-i18n-title="history.preview_button">
@@ -138,7 +173,7 @@
             `;
         }).join('');
 
-        versionList.innerHTML = html;
+        versionList.innerHTML = currentHtml + html;
 
         // Add event listeners for version actions
         versionList.querySelectorAll('.preview-version-btn').forEach(button => {
@@ -243,6 +278,11 @@
                     console.error('Mermaid handler error:', mermaidError);
                 }
             }
+
+            // Render ABC music notation in the preview content
+            if (window.ABCNotation) {
+                window.ABCNotation.render(targetElement);
+            }
         } catch (error) {
             console.error('Error loading version preview:', error);
             targetElement.innerHTML = `<div class="error-message">Failed to load preview: ${error.message}</div>`;
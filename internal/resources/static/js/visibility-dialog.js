@@ -0,0 +1,93 @@
+// Visibility dialog: sets whether the current page is public, internal,
+// restricted to some users and groups, or unlisted
+(function() {
+    'use strict';
+
+    const dialog = document.querySelector('.visibility-dialog');
+    const button = document.querySelector('.page-visibility');
+    if (!dialog || !button) return;
+
+    const form = document.getElementById('visibilityForm');
+    const principals = dialog.querySelector('.visibility-principals');
+    const viewInput = document.getElementById('visibilityView');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function showError(message) {
+        const error = dialog.querySelector('.error-message');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function selected() {
+        const checked = form.querySelector('input[name="visibility"]:checked');
+        return checked ? checked.value : '';
+    }
+
+    function updatePrincipals() {
+        principals.hidden = selected() !== 'restricted';
+    }
+
+    async function openDialog() {
+        showError('');
+        form.reset();
+        try {
+            const resp = await fetch('/api/visibility?path=' + encodeURIComponent(currentPath()));
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('visibility.load_failed', 'Failed to load the visibility'));
+            } else {
+                const option = form.querySelector('input[name="visibility"][value="' + data.visibility + '"]');
+                if (option) option.checked = true;
+                viewInput.value = data.visibility === 'restricted' && data.view ? data.view.join(', ') : '';
+                form.querySelector('button[type="submit"]').disabled = !data.canChange;
+            }
+        } catch (error) {
+            console.error('Error loading visibility:', error);
+            showError(t('visibility.load_failed', 'Failed to load the visibility'));
+        }
+        updatePrincipals();
+        dialog.classList.add('active');
+    }
+
+    function closeDialog() {
+        dialog.classList.remove('active');
+    }
+
+    async function save(e) {
+        e.preventDefault();
+        showError('');
+        try {
+            const resp = await fetch('/api/visibility', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    path: currentPath(),
+                    visibility: selected(),
+                    view: viewInput.value.split(',').map(p => p.trim()).filter(Boolean)
+                })
+            });
+            const data = await resp.json();
+            if (!resp.ok || !data.success) {
+                showError(data.message || t('visibility.failed', 'Failed to change the visibility'));
+                return;
+            }
+            window.location.reload();
+        } catch (error) {
+            console.error('Error changing visibility:', error);
+            showError(t('visibility.failed', 'Failed to change the visibility'));
+        }
+    }
+
+    button.addEventListener('click', openDialog);
+    form.addEventListener('submit', save);
+    form.addEventListener('change', updatePrincipals);
+    dialog.querySelector('.close-dialog').addEventListener('click', closeDialog);
+    dialog.querySelector('.cancel-dialog').addEventListener('click', closeDialog);
+})();
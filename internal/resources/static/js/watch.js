@@ -0,0 +1,60 @@
+// Watch button: users watching a page are notified when somebody else changes
+// it or one of its subpages, and find it on their dashboard
+(function() {
+    'use strict';
+
+    const button = document.querySelector('.page-watch');
+    if (!button) return;
+
+    const icon = button.querySelector('.fa');
+    const label = button.querySelector('.button-text');
+
+    function t(key, fallback) {
+        return window.i18n ? window.i18n.t(key) : fallback;
+    }
+
+    function currentPath() {
+        return decodeURIComponent(window.location.pathname).replace(/^\/+|\/+$/g, '');
+    }
+
+    function update(watching) {
+        button.setAttribute('aria-pressed', watching ? 'true' : 'false');
+        button.classList.toggle('active', watching);
+        icon.className = 'fa ' + (watching ? 'fa-bell' : 'fa-bell-o');
+        label.textContent = watching ? t('watch.unwatch', 'Unwatch') : t('watch.button', 'Watch');
+        button.title = watching ? t('watch.unwatch_tooltip', 'Stop watching this page') : t('watch.tooltip', 'Get notified when this page or its subpages change');
+    }
+
+    async function request(options) {
+        const query = options ? '' : '?path=' + encodeURIComponent(currentPath());
+        const resp = await fetch('/api/watch' + query, options);
+        const data = await resp.json();
+        if (!resp.ok || !data.success) throw new Error(data.message || resp.statusText);
+        return data.watching;
+    }
+
+    async function toggle() {
+        const watching = button.getAttribute('aria-pressed') !== 'true';
+        button.disabled = true;
+        try {
+            update(await request({
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ path: currentPath(), watch: watching })
+            }));
+            if (window.CommandPalette) {
+                window.CommandPalette.notify(watching ? t('watch.started', 'You are watching this page') : t('watch.stopped', 'You stopped watching this page'));
+            }
+        } catch (error) {
+            console.error('Error changing the watch list:', error);
+            if (window.DialogSystem) {
+                window.DialogSystem.showMessageDialog(t('watch.title', 'Watch'), t('watch.failed', 'Failed to change the watch list'));
+            }
+        } finally {
+            button.disabled = false;
+        }
+    }
+
+    button.addEventListener('click', toggle);
+    request().then(update).catch(error => console.error('Error loading the watch list:', error));
+})();
@@ -0,0 +1,254 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/store"
+)
+
+// DateFormat is the format of last_reviewed dates
+const DateFormat = "2006-01-02"
+
+// stateFile remembers which overdue pages owners were already notified about
+const stateFile = "review_state.json"
+
+// Status is the review state of a single page
+type Status struct {
+	Path         string    `json:"path"` // URL path of the page
+	Title        string    `json:"title"`
+	Owners       []string  `json:"owners"`
+	ReviewEvery  string    `json:"reviewEvery"`
+	LastReviewed time.Time `json:"lastReviewed"`
+	Due          time.Time `json:"due"`
+	Overdue      bool      `json:"overdue"`
+}
+
+var stateMutex store.DataFile
+
+// ParseInterval parses a review interval like 30d, 12w, 6m or 1y. A plain number
+// is a number of days.
+func ParseInterval(interval string) (years, months, days int, err error) {
+	interval = strings.ToLower(strings.TrimSpace(interval))
+	if interval == "" {
+		return 0, 0, 0, fmt.Errorf("empty review interval")
+	}
+
+	unit := interval[len(interval)-1]
+	number := interval
+	if unit < '0' || unit > '9' {
+		number = interval[:len(interval)-1]
+	} else {
+		unit = 'd'
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || n <= 0 {
+		return 0, 0, 0, fmt.Errorf("invalid review interval %q", interval)
+	}
+
+	switch unit {
+	case 'd':
+		return 0, 0, n, nil
+	case 'w':
+		return 0, 0, n * 7, nil
+	case 'm':
+		return 0, n, 0, nil
+	case 'y':
+		return n, 0, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("invalid review interval unit in %q", interval)
+}
+
+// Check returns the review status of a document, or nil if the document has no
+// review_every setting. Without last_reviewed the modification time is used.
+func Check(content string, modTime time.Time, now time.Time) *Status {
+	metadata, _, ok := frontmatter.Parse(content)
+	if !ok || metadata.ReviewEvery == "" {
+		return nil
+	}
+
+	years, months, days, err := ParseInterval(metadata.ReviewEvery)
+	if err != nil {
+		return nil
+	}
+
+	lastReviewed := modTime
+	if metadata.LastReviewed != "" {
+		if t, err := time.ParseInLocation(DateFormat, metadata.LastReviewed, now.Location()); err == nil {
+			lastReviewed = t
+		}
+	}
+
+	due := lastReviewed.AddDate(years, months, days)
+	return &Status{
+		Owners:       metadata.Owners,
+		ReviewEvery:  metadata.ReviewEvery,
+		LastReviewed: lastReviewed,
+		Due:          due,
+		Overdue:      now.After(due),
+	}
+}
+
+// CheckFile returns the review status of the document stored at docFile
+func CheckFile(docFile string, now time.Time) *Status {
+	info, err := os.Stat(docFile)
+	if err != nil {
+		return nil
+	}
+	content, err := encryption.ReadFile(docFile)
+	if err != nil {
+		return nil
+	}
+	return Check(string(content), info.ModTime(), now)
+}
+
+// Scan returns the review status of every page with a review interval, ordered by
+// due date. The home page is included with the path "/".
+func Scan(cfg *config.Config, now time.Time) ([]Status, error) {
+	var result []Status
+
+	if status := CheckFile(filepath.Join(cfg.Wiki.RootDir, "pages", "home", "document.md"), now); status != nil {
+		status.Path = "/"
+		status.Title = "Home"
+		result = append(result, *status)
+	}
+
+	documentsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	err := filepath.WalkDir(documentsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != "document.md" {
+			return nil
+		}
+
+		status := CheckFile(path, now)
+		if status == nil {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		rel, err := filepath.Rel(documentsDir, dir)
+		if err != nil {
+			return nil
+		}
+		status.Path = "/" + filepath.ToSlash(rel)
+		status.Title = documentTitle(path)
+		result = append(result, *status)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the ownership rules to pages without owners in their frontmatter
+	owners, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].Owners = owners.Owners(result[i].Path, result[i].Owners)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Due.Before(result[j].Due)
+	})
+	return result, nil
+}
+
+// documentTitle returns the first H1 of a document, or its directory name
+func documentTitle(docFile string) string {
+	content, err := encryption.ReadFile(docFile)
+	if err == nil {
+		_, body, _ := frontmatter.Parse(string(content))
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				return strings.TrimSpace(line[2:])
+			}
+		}
+	}
+	return filepath.Base(filepath.Dir(docFile))
+}
+
+// MarkReviewed sets last_reviewed of a document to today
+func MarkReviewed(content string, now time.Time) (string, error) {
+	return frontmatter.SetField(content, "last_reviewed", now.Format(DateFormat))
+}
+
+// NotifyOverdue notifies the owners of overdue pages. Owners are notified once
+// per due date, so a page is reported again only after its next review is missed.
+func NotifyOverdue(cfg *config.Config, now time.Time) error {
+	statuses, err := Scan(cfg, now)
+	if err != nil {
+		return err
+	}
+
+	owners, err := ownership.Load(cfg.Wiki.RootDir)
+	if err != nil {
+		return err
+	}
+
+	unlock := stateMutex.Lock(stateFile)
+	defer unlock()
+
+	statePath := filepath.Join(cfg.Wiki.RootDir, stateFile)
+	notified := make(map[string]string)
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &notified)
+	}
+
+	changed := false
+	for _, status := range statuses {
+		due := status.Due.Format(DateFormat)
+		if !status.Overdue || notified[status.Path] == due {
+			continue
+		}
+
+		message := fmt.Sprintf("%s is overdue for review (due %s)", status.Title, due)
+		for _, user := range owners.Users(status.Owners, cfg.Users) {
+			if err := notifications.Add(cfg.Wiki.RootDir, user, message, status.Path); err != nil {
+				log.Printf("Error notifying %s about review of %s: %v", user, status.Path, err)
+			}
+		}
+		notified[status.Path] = due
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(notified, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(statePath, data, 0644)
+}
+
+// StartScheduler checks for overdue pages once at startup and then every interval
+func StartScheduler(cfg *config.Config, interval time.Duration) {
+	go func() {
+		for {
+			if err := NotifyOverdue(cfg, time.Now()); err != nil {
+				log.Printf("Error checking pages due for review: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -10,9 +10,14 @@ import (
 	"path/filepath"
 	"strings"
 	"wiki-go/internal/auth"
+	"wiki-go/internal/compression"
 	"wiki-go/internal/config"
 	"wiki-go/internal/handlers"
+	"wiki-go/internal/netaccess"
 	"wiki-go/internal/resources"
+	"wiki-go/internal/secheaders"
+	"wiki-go/internal/static"
+	"wiki-go/internal/utils"
 )
 
 // addCacheControlHeaders adds appropriate Cache-Control headers based on file type
@@ -46,48 +51,20 @@ func addCacheControlHeaders(w http.ResponseWriter, filename string) {
 	}
 }
 
-// CSPMiddleware adds Content Security Policy headers to all responses
-func CSPMiddleware(next http.Handler) http.Handler {
+// NavigationCacheMiddleware drops the cached navigation tree after requests that
+// may have added, moved, renamed or deleted pages
+func NavigationCacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set a less restrictive Content Security Policy that allows inline scripts and styles
-		csp := []string{
-			// Default to allowing same-origin resources and inline scripts/styles
-			"default-src 'self' 'unsafe-inline' 'unsafe-eval'",
-			// Allow inline styles and styles from same origin
-			"style-src 'self' 'unsafe-inline'",
-			// Allow scripts from same origin and inline scripts
-			"script-src 'self' 'unsafe-inline' 'unsafe-eval'",
-			// Images from same origin and data: URLs (for embedded images)
-			"img-src 'self' data: https://*.ytimg.com https://*.vimeocdn.com",
-			// Connect only to same origin
-			"connect-src 'self'",
-			// Fonts from same origin
-			"font-src 'self'",
-			// Allow object/embed only from same origin
-			"object-src 'self'",
-			// Media only from same origin
-			"media-src 'self' https://*.youtube.com https://*.vimeo.com",
-			// Allow frames from YouTube and Vimeo for video embeds
-			"frame-src 'self' https://*.youtube.com https://*.vimeo.com",
-			// Form submissions only to same origin
-			"form-action 'self'",
-			// Base URI restricted to same origin
-			"base-uri 'self'",
+		next.ServeHTTP(w, r)
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			utils.InvalidateNavigation()
 		}
+	})
+}
 
-		// Set the CSP header - using Content-Security-Policy-Report-Only first to avoid breaking things
-		// This will report violations but not enforce them
-		w.Header().Set("Content-Security-Policy-Report-Only", strings.Join(csp, "; "))
-
-		// Once you've fixed all the violations, you can switch to enforcing mode:
-		// w.Header().Set("Content-Security-Policy", strings.Join(csp, "; "))
-
-		// Add other security headers
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-
+// PreloadMiddleware asks browsers to preload resources every page needs
+func PreloadMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Add preload header for emojis.json to avoid AJAX loading
 		// This works by telling the browser to preload this resource before it's needed
 		if strings.HasSuffix(r.URL.Path, ".html") || r.URL.Path == "/" || strings.HasSuffix(r.URL.Path, "/") {
@@ -99,52 +76,6 @@ func CSPMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-/*
-// Example of how to implement nonce-based CSP (for future reference)
-func CSPMiddlewareWithNonce(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Generate a random nonce for this request
-		nonce := generateNonce()
-
-		// Store the nonce in the request context so templates can access it
-		ctx := context.WithValue(r.Context(), "csp-nonce", nonce)
-		r = r.WithContext(ctx)
-
-		// Set a strict Content Security Policy with nonce
-		csp := []string{
-			// Default to only allowing same-origin resources
-			"default-src 'self'",
-			// Allow styles from same origin and inline styles with nonce
-			fmt.Sprintf("style-src 'self' 'nonce-%s'", nonce),
-			// Allow scripts from same origin and inline scripts with nonce
-			fmt.Sprintf("script-src 'self' https://cdn.jsdelivr.net 'nonce-%s'", nonce),
-			// Images from same origin and data: URLs
-			"img-src 'self' data:",
-			// Other directives...
-		}
-
-		// Set the CSP header
-		w.Header().Set("Content-Security-Policy", strings.Join(csp, "; "))
-
-		// Add other security headers
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
-}
-
-// Generate a random nonce for CSP
-func generateNonce() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return base64.StdEncoding.EncodeToString(b)
-}
-*/
-
 // Helper function to check if a file exists
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -204,6 +135,13 @@ func SetupRoutes(cfg *config.Config) {
 		}
 	}
 
+	// Routes only admins reach, which are also in the admin group of the
+	// network access rules
+	adminRoute := func(pattern string, handler http.HandlerFunc) {
+		netaccess.RegisterAdmin(pattern)
+		mux.HandleFunc(pattern, adminMiddleware(handler))
+	}
+
 	editorMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if !auth.RequireRole(r, "editor") {
@@ -234,8 +172,20 @@ func SetupRoutes(cfg *config.Config) {
 			return
 		}
 
-		// Add appropriate cache headers for static files
-		addCacheControlHeaders(w, filename)
+		// Fingerprinted addresses from the templates name the file and its content
+		if file, current, ok := static.ResolveAsset(cfg.Wiki.RootDir, filename); ok && !fileExists(filepath.Join(cfg.Wiki.RootDir, "static", filename)) {
+			filename = file
+			r.URL.Path = "/static/" + file
+			if current {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				// An old address, serve the current content without caching it under it
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		} else {
+			// Add appropriate cache headers for static files
+			addCacheControlHeaders(w, filename)
+		}
 
 		// Check if this is a favicon file
 		isFaviconFile := false
@@ -261,7 +211,10 @@ func SetupRoutes(cfg *config.Config) {
 			return
 		}
 
-		// Fall back to embedded static files
+		// Fall back to embedded static files, compressed once and kept in memory
+		if cfg.Server.Compression && compression.ServeAsset(w, r, resources.GetStaticFS(), filename) {
+			return
+		}
 		http.StripPrefix("/static/", http.FileServer(resources.GetFileSystem())).ServeHTTP(w, r)
 	})
 
@@ -295,10 +248,27 @@ func SetupRoutes(cfg *config.Config) {
 	mux.HandleFunc("/api/check-auth", handlers.CheckAuthHandler)
 	mux.HandleFunc("/api/logout", handlers.LogoutHandler)
 	mux.HandleFunc("/api/check-default-password", handlers.CheckDefaultPasswordHandler)
+
+	// Passkeys (WebAuthn) - login is public, managing passkeys requires a session
+	mux.HandleFunc("/api/passkeys", handlers.PasskeysHandler)
+	mux.HandleFunc("/api/passkeys/", handlers.PasskeysHandler)
+	mux.HandleFunc("/api/passkeys/register/begin", handlers.PasskeyRegisterBeginHandler)
+	mux.HandleFunc("/api/passkeys/register/finish", handlers.PasskeyRegisterFinishHandler)
+	mux.HandleFunc("/api/passkeys/login/begin", handlers.PasskeyLoginBeginHandler)
+	mux.HandleFunc("/api/passkeys/login/finish", handlers.PasskeyLoginFinishHandler)
 	mux.HandleFunc("/api/document/create", handlers.CreateDocumentHandler)
 	mux.HandleFunc("/api/document/", handlers.DocumentHandler)
 	mux.HandleFunc("/api/source/", handlers.SourceHandler)
+	mux.HandleFunc("/api/raw/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.RawPageHandler(w, r, cfg)
+	})
 	mux.HandleFunc("/api/save/", handlers.SaveHandler)
+	mux.HandleFunc("/api/section/", handlers.SectionHandler)
+	mux.HandleFunc("/api/merge", editorMiddleware(handlers.MergeHandler))
 
 	// File API Routes
 	mux.HandleFunc("/api/files/upload", func(w http.ResponseWriter, r *http.Request) {
@@ -326,17 +296,153 @@ func SetupRoutes(cfg *config.Config) {
 	mux.HandleFunc("/api/comments/delete/", handlers.DeleteCommentHandler)
 	mux.HandleFunc("/api/comments/", handlers.GetCommentsHandler)
 
+	// Structured forms API - role checks are done per form
+	mux.HandleFunc("/api/forms", handlers.FormsHandler)
+	mux.HandleFunc("/api/forms/", handlers.FormHandler)
+
 	// Search handler with wrapper to include config
 	mux.HandleFunc("/api/search", func(w http.ResponseWriter, r *http.Request) {
 		handlers.SearchHandler(w, r, cfg)
 	})
 
+	// Sidebar children, loaded when a directory is expanded
+	mux.HandleFunc("/api/tree", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.TreeHandler(w, r)
+	})
+
+	// Changes suggested by users who may not edit a page, reviewed by its editors
+	mux.HandleFunc("/api/suggestions", handlers.SuggestionsHandler)
+	mux.HandleFunc("/api/suggestions/source", handlers.SuggestionSourceHandler)
+	mux.HandleFunc("/api/suggestions/", handlers.SuggestionHandler)
+
+	// Differences between any two pages
+	mux.HandleFunc("/api/compare", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.CompareHandler(w, r)
+	})
+
+	// Links between the pages, for the graph view
+	mux.HandleFunc("/api/graph", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.GraphHandler(w, r)
+	})
+
+	// Metadata of the pages, for dashboards and compliance reports
+	mux.HandleFunc("/api/pages/metadata", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.PagesMetadataHandler(w, r)
+	})
+
+	// QR codes of links to pages, for printed documents and labels
+	mux.HandleFunc("/api/qr", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.QRCodeHandler(w, r)
+	})
+
+	// Images of the link cards of public pages, fetched by chat apps without logging in
+	mux.HandleFunc("/api/social-image/", handlers.SocialImageHandler)
+
+	// Page previews shown when hovering links
+	mux.HandleFunc("/api/preview", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.PreviewHandler(w, r)
+	})
+
+	// Page statistics API
+	mux.HandleFunc("/api/stats/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.PageStatsHandler(w, r)
+	})
+
+	// Activity of a user: their recent edits, created pages and comments
+	profileHandler := func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.ProfileHandler(w, r)
+	}
+	mux.HandleFunc("/api/profile", profileHandler)
+	mux.HandleFunc("/api/profile/", profileHandler)
+
+	// Review reminders
+	mux.HandleFunc("/api/review", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.ReviewHandler(w, r)
+	})
+	mux.HandleFunc("/api/review/", editorMiddleware(handlers.MarkReviewedHandler))
+	mux.HandleFunc("/api/notifications", handlers.NotificationsHandler)
+	mux.HandleFunc("/api/preferences", handlers.PreferencesHandler)
+
+	// Home page dashboard, watched, favorite and pinned pages, announcements
+	mux.HandleFunc("/api/dashboard", handlers.DashboardHandler)
+	mux.HandleFunc("/api/watch", handlers.WatchHandler)
+	mux.HandleFunc("/api/favorites", handlers.FavoritesHandler)
+	adminRoute("/api/pins", handlers.PinsHandler)
+	mux.HandleFunc("/api/announcements/dismiss", handlers.DismissAnnouncementHandler)
+
+	// Page ownership
+	mux.HandleFunc("/api/owners/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handlers.OwnersHandler(w, r)
+	})
+
 	// Settings API - Admin only
-	mux.HandleFunc("/api/settings/wiki", adminMiddleware(handlers.WikiSettingsHandler))
-	mux.HandleFunc("/api/settings/security", adminMiddleware(handlers.SecuritySettingsHandler))
+	adminRoute("/api/settings/wiki", handlers.WikiSettingsHandler)
+	adminRoute("/api/settings/security", handlers.SecuritySettingsHandler)
+	adminRoute("/api/settings/variables", handlers.VariablesHandler)
+	adminRoute("/api/settings/dictionary", handlers.DictionaryHandler)
+	adminRoute("/api/settings/typography", handlers.TypographySettingsHandler)
+	adminRoute("/api/settings/owners", handlers.OwnersSettingsHandler)
+	adminRoute("/api/settings/schemas", handlers.SchemasSettingsHandler)
+	adminRoute("/api/settings/schemas/report", handlers.SchemaReportHandler)
+	adminRoute("/api/settings/permissions", handlers.PermissionsSettingsHandler)
+	adminRoute("/api/settings/permissions/tree", handlers.PermissionsTreeHandler)
+	mux.HandleFunc("/api/visibility", editorMiddleware(handlers.VisibilityHandler))
+	mux.HandleFunc("/api/archive", editorMiddleware(handlers.ArchiveHandler))
+	adminRoute("/api/settings/network", handlers.NetworkSettingsHandler)
+	adminRoute("/api/settings/jobs", handlers.JobsHandler)
+	adminRoute("/api/settings/contributors", handlers.ContributorsHandler)
+	adminRoute("/api/settings/federation", handlers.FederationSettingsHandler)
+	adminRoute("/api/settings/diagnostics", handlers.DiagnosticsHandler)
+	adminRoute("/api/settings/redirects", handlers.RedirectsHandler)
+	adminRoute("/api/settings/announcements", handlers.AnnouncementsHandler)
+
+	// Runtime profiles, when enabled in the configuration - Admin only
+	adminRoute("/debug/pprof/", handlers.PprofHandler)
 
 	// User Management API - Admin only
-	mux.HandleFunc("/api/users", adminMiddleware(handlers.UsersHandler))
+	adminRoute("/api/users", handlers.UsersHandler)
+	adminRoute("/api/users/export", handlers.UserExportHandler)
+	mux.HandleFunc("/api/account/export", handlers.AccountExportHandler)
 
 	// Version history API - Editor or Admin
 	mux.HandleFunc("/api/versions/", editorMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -348,6 +454,20 @@ func SetupRoutes(cfg *config.Config) {
 		handlers.MoveDocumentHandler(w, r, cfg)
 	}))
 
+	// Document and directory copy API - Editor or Admin
+	mux.HandleFunc("/api/document/copy", editorMiddleware(handlers.CopyDocumentHandler))
+
+	// Bulk page operations API - Editor or Admin
+	mux.HandleFunc("/api/bulk", editorMiddleware(handlers.BulkHandler))
+	mux.HandleFunc("/api/bulk/status", editorMiddleware(handlers.BulkStatusHandler))
+
+	// Architecture Decision Records API - Editor or Admin
+	mux.HandleFunc("/api/adr", editorMiddleware(handlers.ADRHandler))
+	mux.HandleFunc("/api/adr/", editorMiddleware(handlers.ADRStatusHandler))
+
+	// Directory export pulled by other wikis - Token or page permissions
+	mux.HandleFunc("/api/federation/export", handlers.FederationExportHandler)
+
 	// Markdown rendering API - No auth required
 	mux.HandleFunc("/api/render-markdown", handlers.RenderMarkdownHandler)
 
@@ -370,7 +490,54 @@ func SetupRoutes(cfg *config.Config) {
 		handlers.ListDocumentsHandler(w, r, cfg)
 	})
 
-	// Import API - Admin only
+	// Editor autocompletion
+	mux.HandleFunc("/api/suggest/pages", editorMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.SuggestPagesHandler(w, r, cfg)
+	}))
+	mux.HandleFunc("/api/suggest/attachments", editorMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlers.SuggestAttachmentsHandler(w, r, cfg)
+	}))
+	mux.HandleFunc("/api/suggest/snippets", editorMiddleware(handlers.SuggestSnippetsHandler))
+
+	// Embedded draw.io and Excalidraw editors of diagram attachments
+	mux.HandleFunc("/api/diagram/save", editorMiddleware(handlers.DiagramSaveHandler))
+	mux.HandleFunc("/diagram/excalidraw", handlers.ExcalidrawEditorHandler)
+
+	// Terminal recordings of other sites, fetched for the player of pages
+	mux.HandleFunc("/api/cast", handlers.CastProxyHandler)
+
+	// Style checks of drafts
+	mux.HandleFunc("/api/lint", editorMiddleware(handlers.LintHandler))
+
+	// Spell checking of drafts
+	mux.HandleFunc("/api/spellcheck", editorMiddleware(handlers.SpellcheckHandler))
+	mux.HandleFunc("/api/spellcheck/suggestions", editorMiddleware(handlers.SpellSuggestionsHandler))
+
+	// Conversion of pasted rich text and Word documents
+	mux.HandleFunc("/api/paste", editorMiddleware(handlers.PasteHandler))
+	mux.HandleFunc("/api/paste/docx", editorMiddleware(handlers.PasteDocxHandler))
+
+	// Import and export of other formats with pandoc
+	mux.HandleFunc("/api/pandoc/import", editorMiddleware(handlers.PandocImportHandler))
+	mux.HandleFunc("/api/pandoc/export", handlers.PandocExportHandler)
+
+	// Find and replace across the wiki
+	mux.HandleFunc("/api/replace/search", editorMiddleware(handlers.ReplaceSearchHandler))
+	mux.HandleFunc("/api/replace/apply", editorMiddleware(handlers.ReplaceApplyHandler))
+
+	// Translation dashboard
+	mux.HandleFunc("/api/translations", editorMiddleware(handlers.TranslationsHandler))
+	mux.HandleFunc("/api/translations/assign", editorMiddleware(handlers.AssignTranslationHandler))
+	mux.HandleFunc("/api/translations/machine", editorMiddleware(handlers.MachineTranslateHandler))
+	mux.HandleFunc("/api/translations/confirm", editorMiddleware(handlers.ConfirmTranslationHandler))
+	mux.HandleFunc("/api/ai", editorMiddleware(handlers.AIHandler))
+	adminRoute("/api/ai/log", handlers.AILogHandler)
+	adminRoute("/api/ai/index", handlers.SemanticIndexHandler)
+	mux.HandleFunc("/api/ask", handlers.AskHandler)
+
+	// Import API - Admin only, checked by the handlers
+	netaccess.RegisterAdmin("/api/import")
+	netaccess.RegisterAdmin("/api/import/status/")
 	mux.HandleFunc("/api/import", func(w http.ResponseWriter, r *http.Request) {
 		handlers.ImportHandler(w, r, cfg)
 	})
@@ -388,6 +555,80 @@ func SetupRoutes(cfg *config.Config) {
 		handlers.SitemapHandler(w, r, cfg)
 	})
 
+	// Calendar feed of the dates in pages. Calendar apps can't log in, so the
+	// handler also accepts calendar tokens.
+	mux.HandleFunc("/calendar.ics", handlers.CalendarFeedHandler)
+	mux.HandleFunc("/api/calendar/link", handlers.CalendarLinkHandler)
+
+	// Installable app and offline reading. These hold nothing private, so they are
+	// served without login even on private wikis.
+	mux.HandleFunc("/manifest.webmanifest", handlers.ManifestHandler)
+	mux.HandleFunc("/sw.js", handlers.ServiceWorkerHandler)
+	mux.HandleFunc("/offline", handlers.OfflineHandler)
+
+	// Print view
+	printHandler := func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		handlers.PrintHandler(w, r, cfg)
+	}
+	mux.HandleFunc("/print", printHandler)
+	mux.HandleFunc("/print/", printHandler)
+
+	// Label cards of pages for label printers
+	labelHandler := func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		handlers.LabelHandler(w, r, cfg)
+	}
+	mux.HandleFunc("/label", labelHandler)
+	mux.HandleFunc("/label/", labelHandler)
+
+	// Pages shown in frames on other sites, with oEmbed discovery. Access is
+	// checked by the handlers, as embed tokens replace the login.
+	mux.HandleFunc("/embed", func(w http.ResponseWriter, r *http.Request) {
+		handlers.EmbedHandler(w, r, cfg)
+	})
+	mux.HandleFunc("/embed/", func(w http.ResponseWriter, r *http.Request) {
+		handlers.EmbedHandler(w, r, cfg)
+	})
+	mux.HandleFunc("/api/oembed", handlers.OEmbedHandler)
+	mux.HandleFunc("/api/embed/token", editorMiddleware(handlers.EmbedTokenHandler))
+
+	// Share links: read-only access to a single page without an account, even
+	// on private wikis. The handler checks the link instead of a login.
+	mux.HandleFunc("/s/", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ShareViewHandler(w, r, cfg)
+	})
+	mux.HandleFunc("/api/shares", editorMiddleware(handlers.SharesHandler))
+	mux.HandleFunc("/api/permalink", editorMiddleware(handlers.PermalinkHandler))
+	mux.HandleFunc("/api/shares/", editorMiddleware(handlers.SharesHandler))
+
+	// E-book export of a directory tree
+	ebookHandler := func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		handlers.EbookHandler(w, r, cfg)
+	}
+	mux.HandleFunc("/ebook", ebookHandler)
+	mux.HandleFunc("/ebook/", ebookHandler)
+	mux.HandleFunc("/api/ebook", handlers.EbookExportHandler)
+
+	// Presentation mode
+	mux.HandleFunc("/present/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth.RequireAuth(r, cfg) {
+			http.Redirect(w, r, "/login?redirect="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		handlers.PresentHandler(w, r, cfg)
+	})
+
 	// Utility API endpoints
 	mux.HandleFunc("/api/utils/slugify", handlers.SlugifyHandler)
 
@@ -412,6 +653,12 @@ func SetupRoutes(cfg *config.Config) {
 			return
 		}
 
+		// Raw markdown or JSON of a page, for external tools
+		if handlers.RawRequest(r, cfg) {
+			handlers.RawPageHandler(w, r, cfg)
+			return
+		}
+
 		// If the URL path is just /, serve the home page
 		if r.URL.Path == "/" {
 			handlers.HomeHandler(w, r, cfg)
@@ -422,8 +669,11 @@ func SetupRoutes(cfg *config.Config) {
 		handlers.PageHandler(w, r, cfg)
 	})
 
-	// Apply middleware to all routes
-	handler := CSPMiddleware(mux)
+	// Apply middleware to all routes: security headers, then network access rules
+	handler := secheaders.Middleware(netaccess.Middleware(PreloadMiddleware(NavigationCacheMiddleware(auth.ProxyAuthMiddleware(mux, cfg))), cfg.Wiki.RootDir), cfg)
+	if cfg.Server.Compression {
+		handler = compression.Middleware(handler)
+	}
 
 	// Set the handler for the default ServeMux
 	http.Handle("/", handler)
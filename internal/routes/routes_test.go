@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/netaccess"
+)
+
+// adminPatterns returns the patterns of routes.go wrapped in adminMiddleware
+// or registered with adminRoute and netaccess.RegisterAdmin, and the number of
+// routes wrapped in adminMiddleware directly
+func adminPatterns(t *testing.T) ([]string, int) {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	literal := func(expr ast.Expr) string {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			value, _ := strconv.Unquote(lit.Value)
+			return value
+		}
+		return ""
+	}
+	name := func(expr ast.Expr) string {
+		switch fn := expr.(type) {
+		case *ast.Ident:
+			return fn.Name
+		case *ast.SelectorExpr:
+			if pkg, ok := fn.X.(*ast.Ident); ok {
+				return pkg.Name + "." + fn.Sel.Name
+			}
+		}
+		return ""
+	}
+
+	var patterns []string
+	direct := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		switch name(call.Fun) {
+		case "adminRoute", "netaccess.RegisterAdmin":
+			if pattern := literal(call.Args[0]); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		case "mux.HandleFunc", "mux.Handle":
+			if len(call.Args) == 2 {
+				// The pattern of adminRoute itself isn't a literal
+				wrapped, ok := call.Args[1].(*ast.CallExpr)
+				if pattern := literal(call.Args[0]); ok && pattern != "" && name(wrapped.Fun) == "adminMiddleware" {
+					patterns = append(patterns, pattern)
+					direct++
+				}
+			}
+		}
+		return true
+	})
+	return patterns, direct
+}
+
+func TestAdminRoutesInAdminGroup(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Wiki.RootDir = t.TempDir()
+	SetupRoutes(cfg)
+
+	patterns, direct := adminPatterns(t)
+	if direct > 0 {
+		t.Errorf("%d routes use adminMiddleware directly, register them with adminRoute", direct)
+	}
+	for _, want := range []string{"/api/settings/wiki", "/api/users", "/api/import", "/api/pins", "/api/ai/log", "/api/ai/index", "/debug/pprof/"} {
+		if !slices.Contains(patterns, want) {
+			t.Errorf("admin routes %v, want them to include %s", patterns, want)
+		}
+	}
+
+	for _, pattern := range patterns {
+		paths := []string{pattern}
+		if strings.HasSuffix(pattern, "/") {
+			paths = append(paths, pattern+"heap")
+		}
+		for _, path := range paths {
+			for _, method := range []string{"GET", "POST"} {
+				groups := netaccess.Groups(httptest.NewRequest(method, path, nil))
+				if !slices.Contains(groups, netaccess.GroupAdmin) {
+					t.Errorf("Groups(%s %s) = %v, want the admin group", method, path, groups)
+				}
+			}
+		}
+	}
+
+	for _, path := range []string{"/", "/docs/page", "/api/search", "/api/settingsx", "/api/users/me"} {
+		if groups := netaccess.Groups(httptest.NewRequest("GET", path, nil)); slices.Contains(groups, netaccess.GroupAdmin) {
+			t.Errorf("Groups(GET %s) = %v, want no admin group", path, groups)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package safehttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"wiki-go/internal/breaker"
+)
+
+// Policy restricts the outbound requests the wiki makes on behalf of page content
+type Policy struct {
+	AllowedHosts []string      // Hosts requests may go to, including redirects. Empty allows none.
+	AllowPrivate bool          // Allow loopback, private and link-local addresses
+	MaxBytes     int64         // Maximum response size
+	Timeout      time.Duration // Timeout of the whole request
+	Header       http.Header   // Headers sent with the request, e.g. Authorization
+
+	// Breaker, when set, retries failed requests up to Attempts times and stops
+	// requests to a service that keeps failing
+	Breaker  *breaker.Breaker
+	Attempts int
+}
+
+// ErrTooLarge is returned for responses above the size limit
+var ErrTooLarge = errors.New("response exceeds the size limit")
+
+// ErrNotAllowed is returned for requests, redirects and addresses the policy refuses
+var ErrNotAllowed = errors.New("not allowed")
+
+// CheckURL reports whether the policy allows requesting u
+func (p Policy) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q is %w", u.Scheme, ErrNotAllowed)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		if strings.EqualFold(strings.TrimSpace(allowed), host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is %w", host, ErrNotAllowed)
+}
+
+// serverError is a 5xx response, retried and counted as a failure by the breaker
+type serverError struct {
+	status int
+}
+
+func (e serverError) Error() string {
+	return fmt.Sprintf("server responded with status %d", e.status)
+}
+
+// Get fetches rawURL under the policy and returns the status and body of the response
+func (p Policy) Get(rawURL string) (int, []byte, error) {
+	return p.send(http.MethodGet, rawURL, "", nil)
+}
+
+// Post sends body to rawURL under the policy and returns the status and body of the response
+func (p Policy) Post(rawURL, contentType string, body []byte) (int, []byte, error) {
+	return p.send(http.MethodPost, rawURL, contentType, body)
+}
+
+// send makes a request under the policy, retried through the breaker when set
+func (p Policy) send(method, rawURL, contentType string, body []byte) (int, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := p.CheckURL(u); err != nil {
+		return 0, nil, err
+	}
+	if p.Breaker == nil {
+		return p.do(method, u, contentType, body)
+	}
+
+	var status int
+	var response []byte
+	err = p.Breaker.Do(p.Attempts, func() error {
+		var err error
+		status, response, err = p.do(method, u, contentType, body)
+		switch {
+		case errors.Is(err, ErrTooLarge), errors.Is(err, ErrNotAllowed):
+			return breaker.Permanent(err)
+		case err != nil:
+			return err
+		case status >= 500:
+			return serverError{status}
+		}
+		return nil
+	})
+
+	// After the last attempt the server's error response is returned like any other
+	var server serverError
+	if errors.As(err, &server) {
+		return status, response, nil
+	}
+	return status, response, err
+}
+
+// do makes a single request
+func (p Policy) do(method string, u *url.URL, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, values := range p.Header {
+		req.Header[name] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read one byte more than allowed to tell a full response from a truncated one
+	response, err := io.ReadAll(io.LimitReader(resp.Body, p.MaxBytes+1))
+	if err != nil {
+		return 0, nil, err
+	}
+	if int64(len(response)) > p.MaxBytes {
+		return 0, nil, ErrTooLarge
+	}
+	return resp.StatusCode, response, nil
+}
+
+// client returns an HTTP client that checks every redirect against the policy and
+// refuses to connect to private addresses after DNS resolution, so a host name
+// can't be pointed at the internal network
+func (p Policy) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: p.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if p.AllowPrivate {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || IsPrivate(ip) {
+				return fmt.Errorf("connecting to %s is %w", host, ErrNotAllowed)
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSHandshakeTimeout:   p.Timeout,
+		ResponseHeaderTimeout: p.Timeout,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   p.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+// IsPrivate reports whether ip belongs to the local machine or an internal network
+func IsPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
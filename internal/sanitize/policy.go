@@ -0,0 +1,242 @@
+package sanitize
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Policy lists the elements allowed in page HTML and the attributes allowed on each
+type Policy struct {
+	elements map[string]map[string]bool
+	// frameSources are the origins frames may be embedded from, as in the
+	// frame-src directive of the Content-Security-Policy
+	frameSources []string
+	// inputs is set when the admin allowed inputs, by default only the
+	// disabled checkboxes of task lists are kept
+	inputs bool
+}
+
+// globalAttributes are allowed on every allowed element, along with data-* and aria-*
+var globalAttributes = []string{"class", "id", "title", "lang", "dir", "style", "role", "hidden", "tabindex"}
+
+// urlAttributes hold URLs and are checked against the allowed schemes
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true, "poster": true, "xlink:href": true,
+}
+
+// plainElements are allowed with the global attributes only
+var plainElements = []string{
+	// Text and structure
+	"p", "div", "span", "br", "hr", "section", "article", "aside", "header", "footer", "main", "nav",
+	"center", "h1", "h2", "h3", "h4", "h5", "h6", "b", "strong", "i", "em", "u", "s", "strike", "mark",
+	"small", "sub", "sup", "kbd", "samp", "var", "code", "pre", "abbr", "dfn", "cite", "wbr", "ruby",
+	"rt", "rp", "summary", "figure", "figcaption",
+	// Lists and tables
+	"ul", "dl", "dt", "dd", "table", "caption", "thead", "tbody", "tfoot", "tr",
+	// Media and forms
+	"picture", "fieldset", "legend",
+}
+
+// defaultElements are the elements Markdown and the wiki extensions produce that
+// need attributes of their own
+var defaultElements = map[string][]string{
+	"a":          {"href", "target", "rel", "name", "download"},
+	"q":          {"cite"},
+	"blockquote": {"cite"},
+	"del":        {"cite", "datetime"},
+	"ins":        {"cite", "datetime"},
+	"time":       {"datetime"},
+	"details":    {"open"},
+	"ol":         {"start", "type", "reversed"},
+	"li":         {"value"},
+	"th":         {"colspan", "rowspan", "align", "scope"},
+	"td":         {"colspan", "rowspan", "align"},
+	"colgroup":   {"span"},
+	"col":        {"span"},
+
+	"img":    {"src", "alt", "width", "height", "loading"},
+	"video":  {"src", "controls", "autoplay", "loop", "muted", "poster", "preload", "width", "height", "playsinline"},
+	"audio":  {"src", "controls", "autoplay", "loop", "muted", "preload"},
+	"source": {"src", "type", "media"},
+	"track":  {"src", "kind", "label", "srclang", "default"},
+	"iframe": {"src", "width", "height", "frameborder", "allow", "allowfullscreen", "loading", "referrerpolicy"},
+
+	// Form controls can make a page collect passwords, only the checkboxes of
+	// task lists are kept. Structured forms are restored after sanitizing.
+	"label": {"for"},
+	"input": {"type", "checked", "disabled"},
+}
+
+// defaultFrameSources are the players of the YouTube and Vimeo extensions
+var defaultFrameSources = []string{"https://www.youtube.com", "https://www.youtube-nocookie.com", "https://player.vimeo.com"}
+
+// styleProperties are the CSS properties allowed in style attributes. Nothing
+// can position an element over the page or load resources.
+var styleProperties = map[string]bool{
+	"color": true, "background-color": true, "text-align": true, "vertical-align": true,
+	"font-weight": true, "font-style": true, "text-decoration": true, "white-space": true,
+	"list-style-type": true, "display": true, "width": true, "height": true, "max-width": true,
+	"max-height": true, "min-width": true, "min-height": true, "margin": true, "margin-top": true,
+	"margin-right": true, "margin-bottom": true, "margin-left": true, "padding": true,
+	"padding-top": true, "padding-right": true, "padding-bottom": true, "padding-left": true,
+	"border": true, "border-color": true, "border-style": true, "border-width": true,
+	"border-collapse": true, "border-radius": true,
+}
+
+// svgElements are allowed so PlantUML diagrams rendered inline keep working
+var svgElements = []string{
+	"svg", "g", "path", "rect", "circle", "ellipse", "line", "polyline", "polygon", "text", "tspan",
+	"textpath", "defs", "use", "symbol", "marker", "lineargradient", "radialgradient", "stop",
+	"clippath", "mask", "pattern", "desc",
+}
+
+var svgAttributes = []string{
+	"xmlns", "xmlns:xlink", "version", "viewbox", "preserveaspectratio", "width", "height", "x", "y",
+	"x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "d", "points", "transform", "fill",
+	"fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-opacity", "stroke-dasharray",
+	"stroke-linecap", "stroke-linejoin", "stroke-miterlimit", "opacity", "font-family", "font-size",
+	"font-style", "font-weight", "text-anchor", "dominant-baseline", "textlength", "lengthadjust",
+	"dx", "dy", "rotate", "offset", "stop-color", "stop-opacity", "gradientunits", "gradienttransform",
+	"markerwidth", "markerheight", "markerunits", "refx", "refy", "orient", "clip-path", "clip-rule",
+	"mask", "patternunits", "href", "xlink:href", "visibility", "display", "zoomandpan",
+	"contentstyletype", "contentscripttype",
+}
+
+// DefaultPolicy returns the policy allowing the HTML the wiki itself produces
+func DefaultPolicy() *Policy {
+	p := &Policy{elements: make(map[string]map[string]bool), frameSources: defaultFrameSources}
+	for _, element := range plainElements {
+		p.allow(element, nil)
+	}
+	for element, attributes := range defaultElements {
+		p.allow(element, attributes)
+	}
+	for _, element := range svgElements {
+		p.allow(element, svgAttributes)
+	}
+	// Titles are SVG elements, but HTML uses a global attribute of the same name
+	p.allow("title", nil)
+	return p
+}
+
+// allow adds an element with attributes to the policy
+func (p *Policy) allow(element string, attributes []string) {
+	allowed := p.elements[element]
+	if allowed == nil {
+		allowed = make(map[string]bool)
+		p.elements[element] = allowed
+	}
+	for _, attribute := range attributes {
+		allowed[strings.ToLower(attribute)] = true
+	}
+}
+
+var elementSpec = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*)(?:\[([a-zA-Z0-9:|_-]*)\])?$`)
+
+// AllowElements adds the elements of a comma-separated spec to the policy, each
+// with an optional |-separated attribute list: "abbr, iframe[src|width|height]".
+// Script and event handler attributes can't be allowed.
+func (p *Policy) AllowElements(spec string) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match := elementSpec.FindStringSubmatch(entry)
+		if match == nil {
+			return fmt.Errorf("invalid element %q, use name or name[attr|attr]", entry)
+		}
+
+		element := strings.ToLower(match[1])
+		if dropWithContent[element] {
+			return fmt.Errorf("element %q can't be allowed", element)
+		}
+		var attributes []string
+		if match[2] != "" {
+			for _, attribute := range strings.Split(match[2], "|") {
+				if strings.HasPrefix(strings.ToLower(attribute), "on") {
+					return fmt.Errorf("event handler attribute %q can't be allowed", attribute)
+				}
+				attributes = append(attributes, attribute)
+			}
+		}
+		p.allow(element, attributes)
+		if element == "input" {
+			p.inputs = true
+		}
+	}
+	return nil
+}
+
+// AllowFrameSources adds space-separated origins frames may be embedded from,
+// such as the frame_sources of the security headers: "https://*.example.com"
+func (p *Policy) AllowFrameSources(sources string) {
+	p.frameSources = append(append([]string{}, p.frameSources...), strings.Fields(sources)...)
+}
+
+// allowsFrame reports whether an iframe may load src. Relative URLs stay on the
+// wiki, other URLs must match one of the frame sources.
+func (p *Policy) allowsFrame(src string) bool {
+	// Browsers drop whitespace and read backslashes as slashes, /\evil.com
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		if r == '\\' {
+			return '/'
+		}
+		return r
+	}, src)
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" && !strings.HasPrefix(cleaned, "//") {
+		return true
+	}
+	for _, source := range p.frameSources {
+		if matchesSource(u, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSource reports whether u matches a CSP source expression with a
+// scheme and host, the host may start with a *. wildcard
+func matchesSource(u *url.URL, source string) bool {
+	allowed, err := url.Parse(source)
+	if err != nil || allowed.Host == "" {
+		return false
+	}
+	if u.Scheme != "" && !strings.EqualFold(u.Scheme, allowed.Scheme) {
+		return false
+	}
+	if u.Scheme == "" && allowed.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	pattern := strings.ToLower(allowed.Host)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// allowsAttribute reports whether attribute may be kept on element
+func (p *Policy) allowsAttribute(element, attribute string) bool {
+	if strings.HasPrefix(attribute, "on") {
+		return false
+	}
+	if strings.HasPrefix(attribute, "data-") || strings.HasPrefix(attribute, "aria-") {
+		return true
+	}
+	for _, global := range globalAttributes {
+		if attribute == global {
+			return true
+		}
+	}
+	return p.elements[element][attribute]
+}
@@ -0,0 +1,330 @@
+package sanitize
+
+import (
+	"html"
+	"strings"
+)
+
+// dropWithContent are removed together with everything inside them
+var dropWithContent = map[string]bool{
+	"script": true, "style": true, "template": true, "noscript": true, "object": true,
+	"embed": true, "applet": true, "frameset": true, "frame": true, "foreignobject": true,
+}
+
+// voidDropped are the dropped elements without content or end tag
+var voidDropped = map[string]bool{"embed": true, "frame": true}
+
+// safeSchemes are the URL schemes allowed in links and media
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true, "tel": true}
+
+// Sanitize returns html with every element, attribute and URL the policy doesn't
+// allow removed. The text of removed elements is kept, except for scripts, styles
+// and embedded objects, which are dropped entirely.
+func (p *Policy) Sanitize(input string) string {
+	var out strings.Builder
+	out.Grow(len(input))
+
+	for i := 0; i < len(input); {
+		lt := strings.IndexByte(input[i:], '<')
+		if lt < 0 {
+			out.WriteString(input[i:])
+			break
+		}
+		out.WriteString(input[i : i+lt])
+		i += lt
+		rest := input[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			// Comments are dropped, placeholders have been restored at this point
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return out.String()
+			}
+			i += 4 + end + 3
+
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			// Doctypes, CDATA and XML declarations
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return out.String()
+			}
+			i += end + 1
+
+		case strings.HasPrefix(rest, "</"):
+			name, length := parseEndTag(rest)
+			if length == 0 {
+				out.WriteString("&lt;")
+				i++
+				continue
+			}
+			if p.elements[name] != nil {
+				out.WriteString("</" + name + ">")
+			}
+			i += length
+
+		default:
+			tag, length := parseStartTag(rest)
+			if length == 0 {
+				out.WriteString("&lt;")
+				i++
+				continue
+			}
+			i += length
+
+			if dropWithContent[tag.name] {
+				if !tag.selfClosing && !voidDropped[tag.name] {
+					i += skipContent(input[i:], tag.name)
+				}
+				continue
+			}
+			if tag.name == "input" && !p.inputs && !disabledCheckbox(tag) {
+				continue
+			}
+			if p.elements[tag.name] != nil {
+				out.WriteString(p.render(tag))
+			}
+		}
+	}
+
+	return out.String()
+}
+
+type attribute struct {
+	name  string
+	value string
+	bare  bool // Attribute without a value, e.g. checked
+}
+
+type startTag struct {
+	name        string
+	attributes  []attribute
+	selfClosing bool
+}
+
+// render writes an allowed start tag with the attributes the policy allows
+func (p *Policy) render(tag startTag) string {
+	var sb strings.Builder
+	sb.WriteString("<" + tag.name)
+	for _, attr := range tag.attributes {
+		if !p.allowsAttribute(tag.name, attr.name) {
+			continue
+		}
+		if urlAttributes[attr.name] && !safeURL(tag.name, attr.value) {
+			continue
+		}
+		if tag.name == "iframe" && attr.name == "src" && !p.allowsFrame(attr.value) {
+			continue
+		}
+		if attr.name == "style" {
+			if attr.value = filterStyle(attr.value); attr.value == "" {
+				continue
+			}
+		}
+		if attr.bare {
+			sb.WriteString(" " + attr.name)
+			continue
+		}
+		sb.WriteString(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+	}
+	if tag.selfClosing {
+		sb.WriteString(" /")
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// parseStartTag parses the start tag at the beginning of s and returns its length,
+// zero when s doesn't start with a tag
+func parseStartTag(s string) (startTag, int) {
+	var tag startTag
+	i := 1
+	start := i
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return tag, 0
+	}
+	tag.name = strings.ToLower(s[start:i])
+
+	for i < len(s) {
+		// Skip whitespace and stray slashes between attributes
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' {
+				tag.selfClosing = true
+			}
+			i++
+		}
+		if i >= len(s) {
+			return tag, 0
+		}
+		if s[i] == '>' {
+			return tag, i + 1
+		}
+
+		// Attribute name
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		attr := attribute{name: strings.ToLower(s[start:i]), bare: true}
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i >= len(s) {
+				return tag, 0
+			}
+			var raw string
+			if quote := s[i]; quote == '"' || quote == '\'' {
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return tag, 0
+				}
+				raw = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				raw = s[start:i]
+			}
+			attr.value = html.UnescapeString(raw)
+			attr.bare = false
+		}
+		if attr.name != "" {
+			tag.attributes = append(tag.attributes, attr)
+		}
+	}
+	return tag, 0
+}
+
+// parseEndTag parses the end tag at the beginning of s and returns its lowercase
+// name and length, zero when s doesn't start with an end tag
+func parseEndTag(s string) (string, int) {
+	i := 2
+	start := i
+	for i < len(s) && isNameChar(s[i]) {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return "", 0
+	}
+	end := strings.IndexByte(s[i:], '>')
+	if end < 0 {
+		return "", 0
+	}
+	return strings.ToLower(s[start:i]), i + end + 1
+}
+
+// skipContent returns the length of s up to and including the end tag of name
+func skipContent(s, name string) int {
+	lower := strings.ToLower(s)
+	closing := "</" + name
+	for offset := 0; ; {
+		idx := strings.Index(lower[offset:], closing)
+		if idx < 0 {
+			return len(s)
+		}
+		idx += offset
+		after := idx + len(closing)
+		if after >= len(s) || s[after] == '>' || isSpace(s[after]) || s[after] == '/' {
+			end := strings.IndexByte(s[after:], '>')
+			if end < 0 {
+				return len(s)
+			}
+			return after + end + 1
+		}
+		offset = after
+	}
+}
+
+// safeURL reports whether a URL attribute value uses an allowed scheme. Relative
+// URLs are always allowed, data: URLs only for raster images.
+func safeURL(element, value string) bool {
+	// Browsers ignore control characters and whitespace in schemes ("java\tscript:")
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+
+	colon := strings.IndexByte(cleaned, ':')
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		return true
+	}
+
+	scheme := strings.ToLower(cleaned[:colon])
+	if safeSchemes[scheme] {
+		return true
+	}
+	if scheme == "data" && element == "img" {
+		mime := strings.ToLower(cleaned[colon+1:])
+		return strings.HasPrefix(mime, "image/") && !strings.HasPrefix(mime, "image/svg")
+	}
+	return false
+}
+
+// disabledCheckbox reports whether an input is a checkbox of a task list
+func disabledCheckbox(tag startTag) bool {
+	checkbox, disabled := false, false
+	for _, attr := range tag.attributes {
+		switch attr.name {
+		case "type":
+			checkbox = strings.EqualFold(strings.TrimSpace(attr.value), "checkbox")
+		case "disabled":
+			disabled = true
+		}
+	}
+	return checkbox && disabled
+}
+
+// filterStyle returns the declarations of an inline style setting one of the
+// allowed properties to a safe value, empty when none is left
+func filterStyle(value string) string {
+	var kept []string
+	for _, declaration := range strings.Split(value, ";") {
+		property, propertyValue, ok := strings.Cut(declaration, ":")
+		if !ok {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		propertyValue = strings.TrimSpace(propertyValue)
+		if styleProperties[property] && propertyValue != "" && safeStyle(propertyValue) {
+			kept = append(kept, property+": "+propertyValue)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
+
+// safeStyle rejects style values that can run code in old browsers or load
+// URLs. CSS escapes and comments could hide either, so they are rejected too.
+func safeStyle(value string) bool {
+	lower := strings.ToLower(value)
+	for _, bad := range []string{"expression", "javascript:", "vbscript:", "behavior", "-moz-binding", "@import", "url(", "image-set(", "\\", "/*"} {
+		if strings.Contains(lower, bad) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || (c >= '0' && c <= '9') || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
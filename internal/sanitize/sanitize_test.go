@@ -0,0 +1,221 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		absent []string // Checked instead of want when it is empty
+	}{
+		// Scripts and event handlers
+		{name: "Script elements", input: `<p>a<script>alert(1)</script>b</p>`, want: `<p>ab</p>`},
+		{name: "Uppercase script elements", input: `<SCRIPT>alert(1)</SCRIPT >x`, want: `x`},
+		{name: "Event handlers", input: `<img src="a.png" onerror="alert(1)">`, want: `<img src="a.png">`},
+		{name: "Event handlers after slashes", input: `<img/src="a.png"/onerror=alert(1)>`, want: `<img src="a.png">`},
+		{name: "Unknown elements keep their text", input: `<marquee>text</marquee>`, want: `text`},
+		{name: "Unclosed tags are text", input: `<img src=x onerror=alert(1)`, want: `&lt;img src=x onerror=alert(1)`},
+		{name: "Stray brackets are escaped", input: `a < b <3`, want: `a &lt; b &lt;3`},
+
+		// javascript: URLs
+		{name: "javascript: links", input: `<a href="javascript:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Mixed case schemes", input: `<a href="JaVaScRiPt:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Decimal entity encoded schemes", input: `<a href="&#106;avascript:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Hex entity encoded schemes", input: `<a href="&#x6A;&#x61;vascript&#x3A;alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Entities without semicolons", input: `<a href="&#106&#97vascript:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Named entity colons", input: `<a href="javascript&colon;alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Tabs in schemes", input: "<a href=\"java\tscript:alert(1)\">x</a>", want: `<a>x</a>`},
+		{name: "Encoded newlines in schemes", input: `<a href="java&#x0A;script:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "Leading control characters", input: `<a href="&#x01; javascript:alert(1)">x</a>`, want: `<a>x</a>`},
+		{name: "vbscript: URLs", input: `<a href='vbscript:msgbox(1)'>x</a>`, want: `<a>x</a>`},
+		{name: "data: links", input: `<a href="data:text/html,<script>alert(1)</script>">x</a>`, want: `<a>x</a>`},
+		{name: "SVG data: images", input: `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, want: `<img>`},
+		{name: "Raster data: images", input: `<img src="data:image/png;base64,iVBORw0KGgo=">`, want: `<img src="data:image/png;base64,iVBORw0KGgo=">`},
+		{name: "Safe links", input: `<a href="https://example.com/?a=1&amp;b=2">x</a>`, want: `<a href="https://example.com/?a=1&amp;b=2">x</a>`},
+		{name: "Relative links with colons", input: `<a href="/docs/a:b">x</a>`, want: `<a href="/docs/a:b">x</a>`},
+
+		// Parsing differentials: what the sanitizer reads as text or an
+		// attribute must not become markup in the browser
+		{
+			name:   "Closing noscript in an attribute",
+			input:  `<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+			absent: []string{"onerror", "<noscript"},
+		},
+		{
+			name:   "Markup in titles",
+			input:  `<title><img src=x onerror=alert(1)></title>`,
+			absent: []string{"onerror"},
+		},
+		{
+			name:   "Closing style in a comment",
+			input:  `<style><!--</style><img src=x onerror=alert(1)>--></style>`,
+			absent: []string{"onerror", "<style"},
+		},
+		{
+			name:   "Markup in comments",
+			input:  `<!--<img src=x onerror=alert(1)>-->ok`,
+			want:   `ok`,
+			absent: []string{"onerror"},
+		},
+		{
+			name:   "Comments ending in attributes",
+			input:  `<!-- <a title="--><img src=x onerror=alert(1)>"> -->`,
+			absent: []string{"onerror"},
+		},
+		{
+			name:  "Unterminated comments",
+			input: `ok<!--<img src=x onerror=alert(1)>`,
+			want:  `ok`,
+		},
+		{
+			name:   "MathML namespace confusion",
+			input:  `<math><mtext><table><mglyph><style><img src=x onerror=alert(1)></style></mglyph></table></mtext></math>`,
+			absent: []string{"onerror", "<style", "<math"},
+		},
+		{
+			name:  "Templates",
+			input: `<template><img src=x onerror=alert(1)></template>ok`,
+			want:  `ok`,
+		},
+		{
+			name:  "Quotes in attribute values are escaped",
+			input: `<p title='a" onmouseover="alert(1)'>x</p>`,
+			want:  `<p title="a&#34; onmouseover=&#34;alert(1)">x</p>`,
+		},
+
+		// SVG
+		{name: "SVG links to javascript:", input: `<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`, absent: []string{"javascript"}},
+		{name: "SVG use of javascript:", input: `<svg><use xlink:href="javascript:alert(1)"/></svg>`, want: `<svg><use /></svg>`},
+		{name: "SVG use of data: documents", input: `<svg><use href="data:image/svg+xml,&lt;svg onload=alert(1)&gt;"/></svg>`, want: `<svg><use /></svg>`},
+		{name: "SVG scripts", input: `<svg><script>alert(1)</script><circle r="1"/></svg>`, want: `<svg><circle r="1" /></svg>`},
+		{name: "SVG onload", input: `<svg onload="alert(1)"><rect width="1"/></svg>`, want: `<svg><rect width="1" /></svg>`},
+		{name: "SVG animations setting links", input: `<svg><animate attributeName="href" to="javascript:alert(1)"/></svg>`, want: `<svg></svg>`},
+		{name: "SVG foreign objects", input: `<svg><foreignObject><iframe src="https://evil.example"></iframe></foreignObject></svg>`, want: `<svg></svg>`},
+		{name: "SVG fragments", input: `<svg><use xlink:href="#icon"/></svg>`, want: `<svg><use xlink:href="#icon" /></svg>`},
+
+		// Frames
+		{name: "Frames of other origins", input: `<iframe src="https://evil.com"></iframe>`, want: `<iframe></iframe>`},
+		{name: "Frames of look-alike hosts", input: `<iframe src="https://www.youtube.com.evil.com/embed/x"></iframe>`, want: `<iframe></iframe>`},
+		{name: "Frames with user info", input: `<iframe src="https://www.youtube.com@evil.com/"></iframe>`, want: `<iframe></iframe>`},
+		{name: "Protocol-relative frames", input: `<iframe src="//evil.com/"></iframe>`, want: `<iframe></iframe>`},
+		{name: "Backslashed frames", input: `<iframe src="/\evil.com/"></iframe>`, want: `<iframe></iframe>`},
+		{name: "javascript: frames", input: `<iframe src="javascript:alert(1)"></iframe>`, want: `<iframe></iframe>`},
+		{name: "YouTube frames", input: `<iframe src="https://www.youtube.com/embed/abc" allowfullscreen></iframe>`, want: `<iframe src="https://www.youtube.com/embed/abc" allowfullscreen></iframe>`},
+		{name: "Vimeo frames", input: `<iframe src="https://player.vimeo.com/video/1"></iframe>`, want: `<iframe src="https://player.vimeo.com/video/1"></iframe>`},
+		{name: "Frames of the wiki", input: `<iframe src="/docs/page?embed=1"></iframe>`, want: `<iframe src="/docs/page?embed=1"></iframe>`},
+		{name: "Frame srcdoc", input: `<iframe srcdoc="<script>alert(1)</script>"></iframe>`, want: `<iframe></iframe>`},
+
+		// Forms
+		{name: "Forms", input: `<form action="https://evil.com"><p>Log in</p></form>`, want: `<p>Log in</p>`},
+		{name: "Password inputs", input: `<input type="password" name="password">`, want: ``},
+		{name: "Text areas", input: `<textarea name="a">text</textarea>`, want: `text`},
+		{name: "Buttons", input: `<button formaction="https://evil.com">Go</button>`, want: `Go`},
+		{name: "Selects", input: `<select name="a"><option>b</option></select>`, want: `b`},
+		{name: "Enabled checkboxes", input: `<input type="checkbox" name="a">`, want: ``},
+		{
+			name:  "Task list checkboxes",
+			input: `<input type="checkbox" class="task-checkbox" checked disabled>`,
+			want:  `<input type="checkbox" class="task-checkbox" checked disabled>`,
+		},
+
+		// Styles
+		{name: "Positioned overlays", input: `<div style="position:fixed;top:0;left:0;width:100%;z-index:9999">x</div>`, want: `<div style="width: 100%">x</div>`},
+		{name: "Style URLs", input: `<p style="background-color: red; background: url(https://evil.com/x)">x</p>`, want: `<p style="background-color: red">x</p>`},
+		{name: "Style expressions", input: `<p style="width: expression(alert(1))">x</p>`, want: `<p>x</p>`},
+		{name: "Style escapes", input: `<p style="color: \65 xpression(alert(1))">x</p>`, want: `<p>x</p>`},
+		{name: "Style comments", input: `<p style="color: red/**/; width: ex/**/pression(alert(1))">x</p>`, want: `<p>x</p>`},
+		{name: "Table alignment", input: `<td style="text-align:center">x</td>`, want: `<td style="text-align: center">x</td>`},
+		{name: "Task list items", input: `<li style="list-style-type: none;">x</li>`, want: `<li style="list-style-type: none">x</li>`},
+		{name: "Styles with entities", input: `<p style="color: red; position&#58; fixed">x</p>`, want: `<p style="color: red">x</p>`},
+
+		// Elements dropped with their content
+		{name: "Objects", input: `<object data="x.swf"><param name="a"></object>ok`, want: `ok`},
+		{name: "Embeds", input: `<embed src="x.swf">ok`, want: `ok`},
+		{name: "Base elements", input: `<base href="https://evil.com/">ok`, want: `ok`},
+		{name: "Meta refreshes", input: `<meta http-equiv="refresh" content="0;url=https://evil.com">ok`, want: `ok`},
+		{name: "Link elements", input: `<link rel="stylesheet" href="https://evil.com/x.css">ok`, want: `ok`},
+	}
+
+	policy := DefaultPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Sanitize(tt.input)
+			if (tt.want != "" || tt.absent == nil) && got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(strings.ToLower(got), unwanted) {
+					t.Errorf("Sanitize(%q) = %q, don't want %q", tt.input, got, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowFrameSources(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.AllowFrameSources("https://maps.example.com https://*.intranet.example")
+
+	tests := []struct {
+		src     string
+		allowed bool
+	}{
+		{src: "https://maps.example.com/embed?q=1", allowed: true},
+		{src: "http://maps.example.com/embed", allowed: false},
+		{src: "https://evil.example.com/", allowed: false},
+		{src: "https://wiki.intranet.example/page", allowed: true},
+		{src: "https://intranet.example/page", allowed: false},
+		{src: "https://evilintranet.example/page", allowed: false},
+		{src: "//maps.example.com/embed", allowed: true},
+		{src: "https://www.youtube.com/embed/x", allowed: true},
+	}
+
+	for _, tt := range tests {
+		got := policy.Sanitize(`<iframe src="` + tt.src + `"></iframe>`)
+		if allowed := strings.Contains(got, "src="); allowed != tt.allowed {
+			t.Errorf("iframe src %q kept = %v, want %v (%q)", tt.src, allowed, tt.allowed, got)
+		}
+	}
+
+	// The sources of a policy don't leak into the others
+	if got := DefaultPolicy().Sanitize(`<iframe src="https://maps.example.com/"></iframe>`); got != `<iframe></iframe>` {
+		t.Errorf("default policy Sanitize() = %q, want the frame source dropped", got)
+	}
+}
+
+func TestAllowElements(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "Elements", spec: "marquee", input: `<marquee onclick="x">a</marquee>`, want: `<marquee>a</marquee>`},
+		{name: "Attributes", spec: "abbr[data|x-title]", input: `<abbr x-title="a">b</abbr>`, want: `<abbr x-title="a">b</abbr>`},
+		{name: "Form controls", spec: "form, input[type|name]", input: `<form><input type="text" name="q"></form>`, want: `<form><input type="text" name="q"></form>`},
+		{name: "Scripts can't be allowed", spec: "script", wantErr: true},
+		{name: "Event handlers can't be allowed", spec: "img[onerror]", wantErr: true},
+		{name: "Invalid specs", spec: "img[src", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultPolicy()
+			err := policy.AllowElements(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AllowElements(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := policy.Sanitize(tt.input); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package schemas
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+)
+
+// Types of schema fields
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeURL     = "url"
+	TypeList    = "list"
+)
+
+// Field describes a frontmatter key of the pages a schema applies to
+type Field struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type,omitempty" json:"type,omitempty"` // string when empty
+	Required bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Enum     []string `yaml:"enum,omitempty" json:"enum,omitempty"`       // Allowed values, of each item for lists
+	Pattern  string   `yaml:"pattern,omitempty" json:"pattern,omitempty"` // Regular expression values must match
+}
+
+// Schema sets the fields of the pages matching a path. A path covers the page
+// itself and everything below it; * and ? wildcards match within a path segment.
+type Schema struct {
+	Path   string  `yaml:"path"`
+	Fields []Field `yaml:"fields"`
+}
+
+// File holds the schemas of the wiki. Every schema matching a page applies;
+// a field defined again by a later schema replaces the earlier definition.
+type File struct {
+	Schemas []Schema `yaml:"schemas"`
+}
+
+// Problem is a frontmatter field that doesn't meet its schema
+type Problem struct {
+	Field   string `json:"field"`
+	Line    int    `json:"line"` // 1-based line in the markdown source
+	Message string `json:"message"`
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// cache avoids re-reading the schemas on every save
+var cache filecache.Cache[*File]
+
+// FilePath returns the location of the schemas
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "schemas.yaml")
+}
+
+// Load returns the current schemas, none when the file does not exist
+func Load(rootDir string) (*File, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML schemas
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	for i, schema := range file.Schemas {
+		if strings.TrimSpace(schema.Path) == "" {
+			return nil, fmt.Errorf("schema %d has no path", i+1)
+		}
+		if _, err := path.Match(strings.Trim(schema.Path, "/"), ""); err != nil {
+			return nil, fmt.Errorf("schema %d has an invalid path pattern %q", i+1, schema.Path)
+		}
+		seen := make(map[string]bool)
+		for _, field := range schema.Fields {
+			if !namePattern.MatchString(field.Name) {
+				return nil, fmt.Errorf("schema %d has an invalid field name %q", i+1, field.Name)
+			}
+			if seen[field.Name] {
+				return nil, fmt.Errorf("schema %d defines field %q twice", i+1, field.Name)
+			}
+			seen[field.Name] = true
+
+			switch field.Type {
+			case "", TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeDate, TypeURL, TypeList:
+			default:
+				return nil, fmt.Errorf("unknown type %q for field %q", field.Type, field.Name)
+			}
+			if field.Pattern != "" {
+				if _, err := regexp.Compile(field.Pattern); err != nil {
+					return nil, fmt.Errorf("invalid pattern for field %q: %w", field.Name, err)
+				}
+			}
+			for _, value := range field.Enum {
+				if message := checkValue(field, value); message != "" {
+					return nil, fmt.Errorf("allowed value %q of field %q: %s", value, field.Name, message)
+				}
+			}
+		}
+	}
+
+	return &file, nil
+}
+
+// ReadRaw returns the schemas as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the schemas file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// Fields returns the fields the schemas set for a page, in the order they are
+// first defined. An empty path refers to the home page.
+func (f *File) Fields(docPath string) []Field {
+	docPath = strings.Trim(docPath, "/")
+	var fields []Field
+	index := make(map[string]int)
+	for _, schema := range f.Schemas {
+		if !matchPath(strings.Trim(schema.Path, "/"), docPath) {
+			continue
+		}
+		for _, field := range schema.Fields {
+			if i, ok := index[field.Name]; ok {
+				fields[i] = field
+				continue
+			}
+			index[field.Name] = len(fields)
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// matchPath reports whether a schema path covers docPath. The pattern must
+// match the leading segments of the path, so a directory schema covers its
+// whole subtree.
+func matchPath(pattern, docPath string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(docPath, "/")
+	if docPath == "" || len(pathParts) < len(patternParts) {
+		return false
+	}
+
+	for i, part := range patternParts {
+		if ok, _ := path.Match(part, pathParts[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the frontmatter of a page against the fields of its schemas
+func Validate(fields []Field, content string) []Problem {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// Frontmatter starts on the line after the opening ---
+	values := make(map[string]*yaml.Node)
+	lines := make(map[string]int)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if strings.HasPrefix(content, "---\n") {
+		end := strings.Index(content[4:], "\n---")
+		if end == -1 {
+			return []Problem{{Line: 1, Message: "The frontmatter is not closed with ---"}}
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(content[4:4+end]), &doc); err != nil {
+			return []Problem{{Line: 1, Message: "The frontmatter is not valid YAML"}}
+		}
+		if len(doc.Content) > 0 {
+			mapping := doc.Content[0]
+			if mapping.Kind != yaml.MappingNode {
+				return []Problem{{Line: 2, Message: "The frontmatter is not a list of fields"}}
+			}
+			for i := 0; i+1 < len(mapping.Content); i += 2 {
+				values[mapping.Content[i].Value] = mapping.Content[i+1]
+				lines[mapping.Content[i].Value] = mapping.Content[i].Line + 1
+			}
+		}
+	}
+
+	var problems []Problem
+	for _, field := range fields {
+		node, line := values[field.Name], lines[field.Name]
+		if line == 0 {
+			line = 1
+		}
+		if empty(node) {
+			if field.Required {
+				problems = append(problems, Problem{Field: field.Name, Line: line, Message: fmt.Sprintf("Missing required field %q", field.Name)})
+			}
+			continue
+		}
+
+		if field.Type == TypeList {
+			if node.Kind != yaml.SequenceNode {
+				problems = append(problems, Problem{Field: field.Name, Line: line, Message: fmt.Sprintf("Field %q must be a list", field.Name)})
+				continue
+			}
+			for _, item := range node.Content {
+				if message := checkNode(field, item); message != "" {
+					problems = append(problems, Problem{Field: field.Name, Line: item.Line + 1, Message: fmt.Sprintf("Field %q: %s", field.Name, message)})
+				}
+			}
+			continue
+		}
+		if message := checkNode(field, node); message != "" {
+			problems = append(problems, Problem{Field: field.Name, Line: line, Message: fmt.Sprintf("Field %q: %s", field.Name, message)})
+		}
+	}
+	return problems
+}
+
+// empty reports whether a field is left out, null or blank
+func empty(node *yaml.Node) bool {
+	if node == nil {
+		return true
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.ShortTag() == "!!null" || strings.TrimSpace(node.Value) == ""
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
+
+// checkNode checks a single value, or an item of a list, returning what is
+// wrong with it
+func checkNode(field Field, node *yaml.Node) string {
+	if node.Kind != yaml.ScalarNode {
+		return "must be a single value"
+	}
+	if field.Type == TypeBoolean && node.ShortTag() != "!!bool" {
+		return "must be true or false"
+	}
+	return checkValue(field, node.Value)
+}
+
+// checkValue checks a value written as text against the type, allowed values
+// and pattern of a field
+func checkValue(field Field, value string) string {
+	switch field.Type {
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case TypeInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be a whole number"
+		}
+	case TypeBoolean:
+		if value != "true" && value != "false" {
+			return "must be true or false"
+		}
+	case TypeDate:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case TypeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an http or https URL"
+		}
+	}
+
+	if len(field.Enum) > 0 {
+		allowed := false
+		for _, option := range field.Enum {
+			if option == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("%q is not one of %s", value, strings.Join(field.Enum, ", "))
+		}
+	}
+	if field.Pattern != "" {
+		if re, err := regexp.Compile(field.Pattern); err == nil && !re.MatchString(value) {
+			return fmt.Sprintf("%q does not match %s", value, field.Pattern)
+		}
+	}
+	return ""
+}
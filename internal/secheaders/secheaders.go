@@ -0,0 +1,255 @@
+package secheaders
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"wiki-go/internal/config"
+)
+
+// CSP modes of the security.headers.csp setting
+const (
+	ModeEnforce    = "enforce"
+	ModeReportOnly = "report-only"
+	ModeOff        = "off"
+)
+
+type contextKey struct{}
+
+// Nonce returns the CSP nonce of a request, to be set on the inline scripts the
+// wiki renders. It is empty when the middleware did not run.
+func Nonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(contextKey{}).(string)
+	return nonce
+}
+
+// Middleware sets the Content Security Policy and the other security headers
+// configured in cfg.Security.Headers. Settings are read on every request, so
+// changes apply without a restart.
+func Middleware(next http.Handler, cfg *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := cfg.Security.Headers
+
+		nonce, err := newNonce()
+		if err != nil {
+			log.Printf("Security headers: error generating CSP nonce: %v", err)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, nonce))
+
+		policy := Policy(cfg, nonce)
+		switch strings.ToLower(headers.CSP) {
+		case ModeOff:
+		case ModeReportOnly:
+			w.Header().Set("Content-Security-Policy-Report-Only", policy)
+		default:
+			w.Header().Set("Content-Security-Policy", policy)
+		}
+
+		if headers.HSTSMaxAge > 0 && isHTTPS(r) {
+			w.Header().Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", headers.HSTSMaxAge))
+		}
+		if headers.FrameOptions != "" {
+			w.Header().Set("X-Frame-Options", strings.ToUpper(headers.FrameOptions))
+		}
+		if headers.ReferrerPolicy != "" {
+			w.Header().Set("Referrer-Policy", headers.ReferrerPolicy)
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Policy builds the Content Security Policy for a response. Scripts only load
+// from the wiki itself or carry the nonce; inline styles stay allowed because
+// MathJax, Mermaid and the editor set them at runtime.
+func Policy(cfg *config.Config, nonce string) string {
+	headers := cfg.Security.Headers
+
+	scripts := "'self'"
+	if nonce != "" {
+		scripts += " 'nonce-" + nonce + "'"
+	}
+	for _, origin := range mermaidOrigins(cfg) {
+		scripts += " " + origin
+	}
+	if origin := musicOrigin(cfg); origin != "" && !strings.Contains(scripts, " "+origin) {
+		scripts += " " + origin
+	}
+	frames := append([]string{"'self'"}, strings.Fields(headers.FrameSources)...)
+	if origin := drawioOrigin(cfg); origin != "" {
+		frames = append(frames, origin)
+	}
+
+	images := []string{"'self'", "data:", "blob:"}
+	images = append(images, strings.Fields(headers.ImageSources)...)
+	if origin := plantUMLOrigin(cfg); origin != "" {
+		images = append(images, origin)
+	}
+	if origin := mapTileOrigin(cfg); origin != "" {
+		images = append(images, origin)
+	}
+
+	ancestors := "'self'"
+	if strings.EqualFold(headers.FrameOptions, "DENY") {
+		ancestors = "'none'"
+	}
+
+	directives := []string{
+		"default-src 'self'",
+		"script-src " + scripts,
+		"style-src 'self' 'unsafe-inline'",
+		"img-src " + strings.Join(images, " "),
+		"media-src " + strings.Join(append([]string{"'self'", "blob:"}, strings.Fields(headers.MediaSources)...), " "),
+		"frame-src " + strings.Join(frames, " "),
+		"connect-src " + strings.Join(append([]string{"'self'"}, strings.Fields(headers.ConnectSources)...), " "),
+		"font-src 'self' data:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors " + ancestors,
+	}
+	return strings.Join(directives, "; ")
+}
+
+// AllowFraming lets the sites of security.headers.embed_ancestors show a response
+// in a frame. It is used by the chrome-less /embed views, which hold no controls
+// that could be abused by framing.
+func AllowFraming(w http.ResponseWriter, cfg *config.Config) {
+	ancestors := strings.TrimSpace(cfg.Security.Headers.EmbedAncestors)
+	if ancestors == "" {
+		ancestors = "'none'"
+	}
+	if ancestors != "'none'" {
+		w.Header().Del("X-Frame-Options")
+	}
+	for _, name := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		policy := w.Header().Get(name)
+		if policy == "" {
+			continue
+		}
+		directives := strings.Split(policy, "; ")
+		for i, directive := range directives {
+			if strings.HasPrefix(directive, "frame-ancestors ") {
+				directives[i] = "frame-ancestors " + ancestors
+			}
+		}
+		w.Header().Set(name, strings.Join(directives, "; "))
+	}
+}
+
+// AllowSources adds sources to directives of the policy of a response, for
+// pages that load from other sites the rest of the wiki doesn't
+func AllowSources(w http.ResponseWriter, directives []string, sources ...string) {
+	if len(sources) == 0 {
+		return
+	}
+	for _, name := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		policy := w.Header().Get(name)
+		if policy == "" {
+			continue
+		}
+		parts := strings.Split(policy, "; ")
+		for i, part := range parts {
+			for _, directive := range directives {
+				if strings.HasPrefix(part, directive+" ") {
+					parts[i] = part + " " + strings.Join(sources, " ")
+				}
+			}
+		}
+		w.Header().Set(name, strings.Join(parts, "; "))
+	}
+}
+
+// plantUMLOrigin returns the origin of the PlantUML server, so diagrams it serves can be shown
+func plantUMLOrigin(cfg *config.Config) string {
+	if !cfg.Extensions.PlantUML.Enable || cfg.Extensions.PlantUML.ServerURL == "" {
+		return ""
+	}
+	u, err := url.Parse(cfg.Extensions.PlantUML.ServerURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// mapTileOrigin returns the origin of the tile server of maps, any subdomain
+// of it when the tiles are spread over several with {s}
+func mapTileOrigin(cfg *config.Config) string {
+	u, err := url.Parse(strings.Replace(cfg.Extensions.Maps.TileURL, "{s}", "*", 1))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// musicOrigin returns the origin of the abcjs bundle when it is loaded from
+// another site
+func musicOrigin(cfg *config.Config) string {
+	if !cfg.Extensions.Music.Enable {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(cfg.Extensions.Music.Script))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// mermaidOrigins returns the origins of the Mermaid bundle and plugins when
+// they are loaded from other sites
+func mermaidOrigins(cfg *config.Config) []string {
+	var origins []string
+	seen := map[string]bool{}
+	for _, script := range append([]string{cfg.Extensions.Mermaid.Script}, strings.Split(cfg.Extensions.Mermaid.Plugins, ",")...) {
+		u, err := url.Parse(strings.TrimSpace(script))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			continue
+		}
+		origin := u.Scheme + "://" + u.Host
+		if !seen[origin] {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// drawioOrigin returns the origin of the draw.io editor, shown in a frame when
+// diagrams can be edited
+func drawioOrigin(cfg *config.Config) string {
+	if !cfg.Extensions.Diagrams.Enable {
+		return ""
+	}
+	return Origin(cfg.Extensions.Diagrams.DrawioURL)
+}
+
+// Origin returns the origin of an http or https address, "" for others
+func Origin(address string) string {
+	u, err := url.Parse(strings.TrimSpace(address))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// isHTTPS reports whether the browser reached the wiki over HTTPS, directly or
+// through a TLS-terminating proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// newNonce returns a random base64 nonce
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
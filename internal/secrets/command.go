@@ -0,0 +1,63 @@
+package secrets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Usage describes the secret command line
+const Usage = `Usage:
+  wiki-go secret set NAME     Store a secret, reading its value from standard input
+  wiki-go secret delete NAME  Remove a secret
+  wiki-go secret list         List the names of the stored secrets
+
+Reference secrets in config.yaml as ${secret:NAME}.`
+
+// RunCommand runs the "secret" command line with its arguments
+func RunCommand(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) != 2 {
+			return errors.New(Usage)
+		}
+		// Read the value from standard input so it doesn't end up in the shell history
+		value, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		value = strings.TrimRight(value, "\r\n")
+		if value == "" {
+			return errors.New("no value given on standard input")
+		}
+		if err := Set(args[1], value); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Secret %s saved\n", args[1])
+	case "delete":
+		if len(args) != 2 {
+			return errors.New(Usage)
+		}
+		if err := Delete(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Secret %s deleted\n", args[1])
+	case "list":
+		names, err := Names()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(out, name)
+		}
+	default:
+		return errors.New(Usage)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FilePath is where the encrypted secrets are stored
+const FilePath = "data/secrets.yaml"
+
+// KeyFilePath holds the encryption key when it isn't provided through KeyEnv
+const KeyFilePath = "data/secrets.key"
+
+// KeyEnv is the environment variable that can hold the base64 encoded 32-byte key
+const KeyEnv = "WIKI_SECRETS_KEY"
+
+// valuePrefix marks values encrypted with AES-256-GCM, followed by base64(nonce + ciphertext)
+const valuePrefix = "v1:"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ValidName reports whether name can be used as a secret name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Load returns all stored secrets, decrypted. A missing secrets file holds no secrets.
+func Load() (map[string]string, error) {
+	stored, err := readFile()
+	if err != nil || len(stored) == 0 {
+		return map[string]string{}, err
+	}
+
+	key, err := loadKey(false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(stored))
+	for name, encrypted := range stored {
+		value, err := decrypt(gcm, name, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %q: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// Names returns the names of the stored secrets in order
+func Names() ([]string, error) {
+	stored, err := readFile()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(stored))
+	for name := range stored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Set encrypts and stores a secret, replacing any previous value. A key file is
+// generated on first use when no key is set in the environment.
+func Set(name, value string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	stored, err := readFile()
+	if err != nil {
+		return err
+	}
+
+	// Only generate a key for an empty file, existing values need the key they were encrypted with
+	key, err := loadKey(len(stored) == 0)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	stored[name] = valuePrefix + base64.StdEncoding.EncodeToString(sealed)
+	return writeFile(stored)
+}
+
+// Delete removes a secret
+func Delete(name string) error {
+	stored, err := readFile()
+	if err != nil {
+		return err
+	}
+	if _, ok := stored[name]; !ok {
+		return fmt.Errorf("secret %q doesn't exist", name)
+	}
+	delete(stored, name)
+	return writeFile(stored)
+}
+
+// readFile returns the encrypted values by name
+func readFile() (map[string]string, error) {
+	stored := make(map[string]string)
+	data, err := os.ReadFile(FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stored, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid secrets file: %w", err)
+	}
+	return stored, nil
+}
+
+func writeFile(stored map[string]string) error {
+	data, err := yaml.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(FilePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath, data, 0600)
+}
+
+// loadKey returns the key from the environment or the key file, generating the
+// key file when create is set and no key exists yet
+func loadKey(create bool) ([]byte, error) {
+	if encoded := strings.TrimSpace(os.Getenv(KeyEnv)); encoded != "" {
+		return decodeKey(encoded, KeyEnv)
+	}
+
+	data, err := os.ReadFile(KeyFilePath)
+	if err == nil {
+		return decodeKey(strings.TrimSpace(string(data)), KeyFilePath)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !create {
+		return nil, fmt.Errorf("no secrets key: set %s or restore %s", KeyEnv, KeyFilePath)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(KeyFilePath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(KeyFilePath, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func decodeKey(encoded, source string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must hold a base64 encoded 32-byte key", source)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decrypt opens a value sealed by Set. The name is authenticated along with the
+// value, so encrypted values can't be swapped between secrets.
+func decrypt(gcm cipher.AEAD, name, encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, valuePrefix) {
+		return "", errors.New("unknown format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, valuePrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("malformed value")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", errors.New("wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
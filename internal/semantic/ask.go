@@ -0,0 +1,67 @@
+package semantic
+
+import (
+	"fmt"
+	"strings"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/config"
+)
+
+// askSections is the number of sections given to the model to answer from
+const askSections = 6
+
+// minScore leaves out sections too far from the question to help
+const minScore = 0.2
+
+// askPrompt tells the model to answer from the sections only and cite them
+const askPrompt = "You answer questions about a wiki using only the numbered sections of its pages you are given. " +
+	"Cite the sections your answer is based on with their number in brackets, like [1] or [2][3]. " +
+	"If the sections don't answer the question, say that the wiki doesn't seem to cover it. " +
+	"Answer in the language of the question, in a few short paragraphs of markdown."
+
+// Source is a section an answer was made from
+type Source struct {
+	Number int `json:"number"` // Number the answer cites the section with
+	Match
+	URL string `json:"url"`
+}
+
+// Answer is the answer to a question with the sections it cites
+type Answer struct {
+	Text       string        `json:"answer"`
+	Sources    []Source      `json:"sources"`
+	Completion ai.Completion `json:"-"`
+}
+
+// Ask answers a question from the sections of the pages allowed returns true for
+func Ask(cfg *config.Config, question string, allowed func(path string) bool) (Answer, error) {
+	matches, err := Search(cfg, question, askSections, allowed)
+	if err != nil {
+		return Answer{}, err
+	}
+
+	var sources []Source
+	var context strings.Builder
+	for _, match := range matches {
+		if match.Score < minScore {
+			continue
+		}
+		source := Source{Number: len(sources) + 1, Match: match, URL: match.URL()}
+		sources = append(sources, source)
+		fmt.Fprintf(&context, "[%d] %s", source.Number, match.Title)
+		if match.Heading != "" && match.Heading != match.Title {
+			fmt.Fprintf(&context, " > %s", match.Heading)
+		}
+		fmt.Fprintf(&context, "\n%s\n\n", match.Text)
+	}
+	if len(sources) == 0 {
+		return Answer{Sources: []Source{}}, nil
+	}
+
+	completion, err := ai.NewClient(cfg).Complete(askPrompt, fmt.Sprintf("Sections:\n\n%s\nQuestion: %s", context.String(), question))
+	if err != nil {
+		return Answer{Sources: sources}, err
+	}
+	return Answer{Text: completion.Text, Sources: sources, Completion: completion}, nil
+}
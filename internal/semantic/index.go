@@ -0,0 +1,503 @@
+// Package semantic keeps an index of the sections of every page with the
+// embedding vectors of their text, to find the sections answering a question
+// by meaning rather than by the words it uses. The index is updated in the
+// background as pages change.
+package semantic
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/config"
+	"wiki-go/internal/diagnostics"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/goldext"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/store"
+	"wiki-go/internal/utils"
+)
+
+// indexFile holds the index in the data directory
+const indexFile = "semantic-index.json"
+
+// indexVersion changes when pages are indexed differently, older indexes are
+// made again. Version 2 leaves out conditional content guests can't see.
+const indexVersion = 2
+
+// embedBatch is the number of sections embedded in one request
+const embedBatch = 16
+
+// ErrDisabled is returned when semantic search isn't enabled
+var ErrDisabled = errors.New("semantic search is not enabled")
+
+// Vector is an embedding, stored as base64 of little-endian float32 values
+type Vector []float32
+
+func (v Vector) MarshalJSON() ([]byte, error) {
+	data := make([]byte, 4*len(v))
+	for i, value := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(data))
+}
+
+func (v *Vector) UnmarshalJSON(b []byte) error {
+	var encoded string
+	if err := json.Unmarshal(b, &encoded); err != nil {
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	if len(data)%4 != 0 {
+		return errors.New("invalid vector")
+	}
+	*v = make(Vector, len(data)/4)
+	for i := range *v {
+		(*v)[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return nil
+}
+
+// Section is an indexed part of a page
+type Section struct {
+	Heading string `json:"heading,omitempty"`
+	Anchor  string `json:"anchor,omitempty"`
+	Text    string `json:"text"`
+	Vector  Vector `json:"vector"`
+}
+
+// page is the indexed state of a page
+type page struct {
+	Title    string    `json:"title"`
+	Hash     string    `json:"hash"`
+	Modified time.Time `json:"modified"`
+	Size     int64     `json:"size"`
+	Sections []Section `json:"sections"`
+}
+
+// index is the stored index, pages by path ("/" for the homepage)
+type index struct {
+	Version int              `json:"version"`
+	Model   string           `json:"model"`
+	Updated time.Time        `json:"updated"`
+	Pages   map[string]*page `json:"pages"`
+}
+
+// Match is a section found for a query
+type Match struct {
+	Path    string  `json:"path"`
+	Title   string  `json:"title"`
+	Heading string  `json:"heading,omitempty"`
+	Anchor  string  `json:"anchor,omitempty"`
+	Text    string  `json:"-"`
+	Score   float64 `json:"score"`
+}
+
+// URL returns the link to the section
+func (m Match) URL() string {
+	if m.Anchor == "" {
+		return m.Path
+	}
+	return m.Path + "#" + m.Anchor
+}
+
+// Status describes the index
+type Status struct {
+	Pages     int       `json:"pages"`
+	Sections  int       `json:"sections"`
+	Updated   time.Time `json:"updated,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// sharedName names the lock and generation of the index file, when several
+// instances of the wiki share it
+const sharedName = "semantic-index"
+
+var (
+	mu         sync.RWMutex // Guards current, generation and lastError
+	current    *index       // Loaded index, nil until first used
+	generation int64        // Generation of the index file current was loaded or saved at
+	lastError  string
+	syncMu     sync.Mutex // Only one sync runs at a time
+)
+
+func init() {
+	diagnostics.Register("semantic index", func() (int, int64) {
+		mu.RLock()
+		defer mu.RUnlock()
+		if current == nil {
+			return 0, 0
+		}
+		count, size := 0, int64(0)
+		for path, p := range current.Pages {
+			for _, s := range p.Sections {
+				count++
+				size += int64(len(path) + len(s.Heading) + len(s.Anchor) + len(s.Text) + 4*len(s.Vector) + 64)
+			}
+		}
+		return count, size
+	})
+}
+
+// Enabled reports whether pages are indexed for semantic search
+func Enabled(cfg *config.Config) bool {
+	return cfg.Extensions.AI.Enable && cfg.Extensions.AI.SemanticSearch
+}
+
+// CurrentStatus returns the size of the index and the error of the last sync
+func CurrentStatus(cfg *config.Config) Status {
+	idx, _ := loaded(cfg.Wiki.RootDir)
+	mu.RLock()
+	defer mu.RUnlock()
+	status := Status{LastError: lastError}
+	if idx != nil {
+		status.Pages = len(idx.Pages)
+		status.Updated = idx.Updated
+		for _, p := range idx.Pages {
+			status.Sections += len(p.Sections)
+		}
+	}
+	return status
+}
+
+// loaded returns the index, reading it from disk the first time
+func loaded(rootDir string) (*index, error) {
+	mu.RLock()
+	idx := current
+	mu.RUnlock()
+	if idx != nil {
+		return idx, nil
+	}
+
+	idx = &index{Pages: make(map[string]*page)}
+	data, err := os.ReadFile(filepath.Join(rootDir, indexFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, idx); err != nil {
+			log.Printf("Warning: semantic index is damaged, pages are indexed again: %v", err)
+			idx = &index{Pages: make(map[string]*page)}
+		}
+		if idx.Pages == nil {
+			idx.Pages = make(map[string]*page)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = idx
+	}
+	return current, nil
+}
+
+// Refresh drops the loaded index when another instance saved the index file
+// since, so it is read again when next used, and reports whether it did
+func Refresh() bool {
+	if !store.Shared() {
+		return false
+	}
+	g, err := store.Current.Generation(sharedName)
+	if err != nil {
+		log.Printf("Warning: failed to read the semantic index generation: %v", err)
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if g == generation {
+		return false
+	}
+	current = nil
+	generation = g
+	return true
+}
+
+// Sync indexes the pages that changed since the last sync and drops the pages
+// that no longer exist, returning the number of pages indexed again. Pages of
+// encrypted directories are never sent to the embedding model.
+func Sync(cfg *config.Config) (int, error) {
+	if !Enabled(cfg) {
+		return 0, ErrDisabled
+	}
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	// Instances sharing the data directory take turns, and start from the
+	// index saved by the last one
+	if store.Shared() {
+		unlock, err := store.Current.Lock(sharedName)
+		if err != nil {
+			return 0, fmt.Errorf("waiting for another instance to index pages: %w", err)
+		}
+		defer unlock()
+		Refresh()
+	}
+
+	changed, err := update(cfg)
+	mu.Lock()
+	lastError = ""
+	if err != nil {
+		lastError = err.Error()
+	}
+	mu.Unlock()
+	return changed, err
+}
+
+// update runs a sync, the caller holds syncMu
+func update(cfg *config.Config) (int, error) {
+	rootDir := cfg.Wiki.RootDir
+	old, err := loaded(rootDir)
+	if err != nil {
+		return 0, err
+	}
+	client := ai.NewEmbeddingClient(cfg)
+
+	// Work on a copy, searches keep using the current index meanwhile
+	mu.RLock()
+	next := &index{Version: indexVersion, Model: client.Model, Pages: make(map[string]*page, len(old.Pages))}
+	if old.Model == client.Model && old.Version == indexVersion {
+		for path, p := range old.Pages {
+			next.Pages[path] = p
+		}
+	}
+	mu.RUnlock()
+
+	seen := make(map[string]bool)
+	changed := 0
+	var syncErr error
+	visit := func(dir, path string) {
+		file := filepath.Join(dir, "document.md")
+		info, err := os.Stat(file)
+		if err != nil || encryption.Encrypted(file) {
+			return
+		}
+		seen[path] = true
+		if p := next.Pages[path]; p != nil && p.Modified.Equal(info.ModTime()) && p.Size == info.Size() {
+			return
+		}
+		if syncErr != nil {
+			// Pages left after a failure are indexed by the next sync
+			return
+		}
+
+		content, err := encryption.ReadFile(file)
+		if err != nil {
+			syncErr = err
+			return
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if p := next.Pages[path]; p != nil && p.Hash == hash {
+			updated := *p
+			updated.Modified, updated.Size = info.ModTime(), info.Size()
+			next.Pages[path] = &updated
+			return
+		}
+
+		// Sections are given to the model for any user who asks, so the index
+		// only holds what every reader of the page sees
+		markdown := goldext.FilterAudience(string(content), permissions.Subject{})
+		p := &page{
+			Title:    utils.GetDocumentTitle(dir),
+			Hash:     hash,
+			Modified: info.ModTime(),
+			Size:     info.Size(),
+		}
+		if err := embed(client, p, splitSections(markdown)); err != nil {
+			syncErr = fmt.Errorf("indexing %s: %w", path, err)
+			return
+		}
+		next.Pages[path] = p
+		changed++
+	}
+
+	visit(filepath.Join(rootDir, "pages", "home"), "/")
+	docsDir := filepath.Join(rootDir, cfg.Wiki.DocumentsDir)
+	err = filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() || path == docsDir {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(docsDir, path)
+		if err != nil {
+			return err
+		}
+		visit(path, "/"+filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return changed, err
+	}
+
+	removed := 0
+	for path := range next.Pages {
+		if !seen[path] {
+			delete(next.Pages, path)
+			removed++
+		}
+	}
+	if changed > 0 || removed > 0 || old.Model != next.Model || old.Version != next.Version {
+		next.Updated = time.Now()
+		if err := save(rootDir, next); err != nil {
+			return changed, err
+		}
+		if store.Shared() {
+			if g, err := store.Current.Bump(sharedName); err != nil {
+				log.Printf("Warning: failed to announce the semantic index change: %v", err)
+			} else {
+				mu.Lock()
+				generation = g
+				mu.Unlock()
+			}
+		}
+	} else {
+		next.Updated = old.Updated
+	}
+
+	mu.Lock()
+	current = next
+	mu.Unlock()
+	return changed, syncErr
+}
+
+// embed sets the sections of a page with their vectors. The title and heading
+// are embedded with the text, so sections are found by what they are about.
+func embed(client ai.Client, p *page, sections []section) error {
+	p.Sections = make([]Section, 0, len(sections))
+	for start := 0; start < len(sections); start += embedBatch {
+		end := min(start+embedBatch, len(sections))
+		texts := make([]string, 0, end-start)
+		for _, s := range sections[start:end] {
+			context := p.Title
+			if s.Heading != "" && s.Heading != p.Title {
+				context += " > " + s.Heading
+			}
+			texts = append(texts, context+"\n\n"+s.Text)
+		}
+		vectors, err := client.Embed(texts)
+		if err != nil {
+			return err
+		}
+		for i, s := range sections[start:end] {
+			p.Sections = append(p.Sections, Section{
+				Heading: s.Heading,
+				Anchor:  s.Anchor,
+				Text:    s.Text,
+				Vector:  normalize(vectors[i]),
+			})
+		}
+	}
+	return nil
+}
+
+// save writes the index
+func save(rootDir string, idx *index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(rootDir, indexFile)
+	if err := os.WriteFile(file+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// Search returns the sections closest to the query, best first. allowed
+// filters the pages the user may read.
+func Search(cfg *config.Config, query string, limit int, allowed func(path string) bool) ([]Match, error) {
+	if !Enabled(cfg) {
+		return nil, ErrDisabled
+	}
+	idx, err := loaded(cfg.Wiki.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := ai.NewEmbeddingClient(cfg).Embed([]string{query})
+	if err != nil {
+		return nil, err
+	}
+	target := normalize(vectors[0])
+
+	mu.RLock()
+	defer mu.RUnlock()
+	var matches []Match
+	for path, p := range idx.Pages {
+		if !allowed(path) {
+			continue
+		}
+		for _, s := range p.Sections {
+			if len(s.Vector) != len(target) {
+				continue
+			}
+			matches = append(matches, Match{
+				Path:    path,
+				Title:   p.Title,
+				Heading: s.Heading,
+				Anchor:  s.Anchor,
+				Text:    s.Text,
+				Score:   dot(s.Vector, target),
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// normalize scales a vector to length 1, so the dot product of two vectors is
+// their cosine similarity
+func normalize(v []float32) Vector {
+	var sum float64
+	for _, value := range v {
+		sum += float64(value) * float64(value)
+	}
+	norm := math.Sqrt(sum)
+	result := make(Vector, len(v))
+	if norm == 0 {
+		return result
+	}
+	for i, value := range v {
+		result[i] = float32(float64(value) / norm)
+	}
+	return result
+}
+
+func dot(a, b Vector) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,209 @@
+package semantic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+// fakeAI answers embedding requests with the same vector for every text and
+// records the texts embedded and the prompts of completion requests
+type fakeAI struct {
+	mu       sync.Mutex
+	embedded []string
+	prompts  []string
+}
+
+func (f *fakeAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Input    []string `json:"input"`
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/embeddings":
+		f.embedded = append(f.embedded, request.Input...)
+		data := make([]map[string]any, len(request.Input))
+		for i := range request.Input {
+			data[i] = map[string]any{"index": i, "embedding": []float32{1, 0}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	case "/chat/completions":
+		for _, message := range request.Messages {
+			f.prompts = append(f.prompts, message.Content)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": "See [1]."}}},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRestrictedContentNeverReachesThePrompt(t *testing.T) {
+	fake := &fakeAI{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	rootDir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Wiki.RootDir = rootDir
+	cfg.Wiki.DocumentsDir = "documents"
+	cfg.Extensions.AI.Enable = true
+	cfg.Extensions.AI.SemanticSearch = true
+	cfg.Extensions.AI.ServerURL = server.URL
+	cfg.Extensions.AI.EmbeddingModel = "test-embedding"
+
+	page := strings.Join([]string{
+		"# Runbook",
+		"",
+		"Restart the service from the dashboard.",
+		"",
+		":::if role=admin,editor",
+		"The root password is hunter2.",
+		":::else",
+		"Ask an admin for access.",
+		":::",
+		"",
+		"## Escalation",
+		"",
+		":::if group=oncall",
+		"Call the pager number 555-0100.",
+		":::",
+		"",
+		":::if audience=internal",
+		"The VPN endpoint is vpn.internal.example.",
+		"",
+		"## Internal notes",
+		"",
+		"Everything under this heading is internal.",
+		":::",
+		"",
+		"Open a ticket with support.",
+	}, "\n")
+	dir := filepath.Join(rootDir, "documents", "runbook")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "document.md"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current = nil
+	defer func() { current = nil }()
+	if _, err := Sync(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := Ask(cfg, "How do I get into the server?", func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.prompts) == 0 {
+		t.Fatal("no completion request was made")
+	}
+
+	sent := strings.Join(append(append([]string{}, fake.embedded...), fake.prompts...), "\n")
+	for _, source := range answer.Sources {
+		sent += "\n" + source.Text
+	}
+	for _, restricted := range []string{"hunter2", "555-0100", "vpn.internal.example", "Internal notes", "under this heading"} {
+		if strings.Contains(sent, restricted) {
+			t.Errorf("restricted text %q was sent to the model or shown as a source", restricted)
+		}
+	}
+	for _, public := range []string{"Restart the service", "Ask an admin for access", "Open a ticket"} {
+		if !strings.Contains(strings.Join(fake.prompts, "\n"), public) {
+			t.Errorf("prompt %q, want it to contain %q", fake.prompts, public)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(rootDir, indexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("the index file holds restricted text")
+	}
+}
+
+func TestOlderIndexesAreMadeAgain(t *testing.T) {
+	server := httptest.NewServer(&fakeAI{})
+	defer server.Close()
+
+	// The page is unchanged since an older version indexed it in full
+	rootDir := t.TempDir()
+	dir := filepath.Join(rootDir, "documents", "runbook")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("# Runbook\n\n:::if role=admin\nThe root password is hunter2.\n:::\n")
+	file := filepath.Join(dir, "document.md")
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	old := index{Model: "test-embedding", Pages: map[string]*page{
+		"/runbook": {
+			Title:    "Runbook",
+			Hash:     hex.EncodeToString(sum[:]),
+			Modified: info.ModTime(),
+			Size:     info.Size(),
+			Sections: []Section{{Text: "The root password is hunter2."}},
+		},
+	}}
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, indexFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Wiki.RootDir = rootDir
+	cfg.Wiki.DocumentsDir = "documents"
+	cfg.Extensions.AI.Enable = true
+	cfg.Extensions.AI.SemanticSearch = true
+	cfg.Extensions.AI.ServerURL = server.URL
+	cfg.Extensions.AI.EmbeddingModel = "test-embedding"
+
+	current = nil
+	defer func() { current = nil }()
+	changed, err := Sync(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("Sync() indexed %d pages, want the page indexed again", changed)
+	}
+	data, err = os.ReadFile(filepath.Join(rootDir, indexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("index of an older version kept its sections: %s", data)
+	}
+}
@@ -0,0 +1,42 @@
+package semantic
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"wiki-go/internal/config"
+)
+
+// changeDelay is how long the indexer waits after a change, so a series of
+// saves is indexed once
+const changeDelay = 5 * time.Second
+
+var changes = make(chan struct{}, 1)
+
+// Changed tells the indexer that pages changed
+func Changed() {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// StartIndexer syncs the index at startup, after pages changed and every
+// interval, which also picks up changes made outside the wiki
+func StartIndexer(cfg *config.Config, interval time.Duration) {
+	go func() {
+		for {
+			if changed, err := Sync(cfg); err != nil && !errors.Is(err, ErrDisabled) {
+				log.Printf("Error updating semantic index: %v", err)
+			} else if changed > 0 {
+				log.Printf("Semantic index: indexed %d pages", changed)
+			}
+			select {
+			case <-changes:
+				time.Sleep(changeDelay)
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package semantic
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"wiki-go/internal/frontmatter"
+	"wiki-go/internal/goldext"
+)
+
+// maxSection is the largest text embedded at once, longer sections are split
+// between paragraphs
+const maxSection = 2000
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.+?)(?:\s*\{#([^}]+)\})?\s*#*$`)
+
+// Inline code and link targets are left out of heading ids
+var (
+	headingCode = regexp.MustCompile("`[^`]+`")
+	headingLink = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+)
+
+// section is a part of a page under one heading
+type section struct {
+	Heading string
+	Anchor  string
+	Text    string
+}
+
+// splitSections splits the markdown of a page at its headings. Anchors match
+// the ids the headings get when the page is rendered.
+func splitSections(markdown string) []section {
+	if _, body, ok := frontmatter.Parse(markdown); ok {
+		markdown = body
+	}
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	var sections []section
+	current := section{}
+	var text strings.Builder
+	used := make(map[string]int)
+	flush := func() {
+		current.Text = strings.TrimSpace(text.String())
+		if current.Text != "" {
+			sections = append(sections, splitLong(current)...)
+		}
+		text.Reset()
+	}
+
+	inCode := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		if !inCode {
+			if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+				flush()
+				anchor := m[3]
+				if anchor == "" {
+					anchor = goldext.HeadingID(headingLink.ReplaceAllString(headingCode.ReplaceAllString(m[2], ""), "$1"))
+				}
+				// Repeated headings get numbered ids, like in the table of contents
+				if n := used[anchor]; n > 0 {
+					used[anchor] = n + 1
+					anchor = anchor + "-" + strconv.Itoa(n)
+				} else {
+					used[anchor] = 1
+				}
+				current = section{Heading: m[2], Anchor: anchor}
+				continue
+			}
+		}
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+	flush()
+	return sections
+}
+
+// splitLong splits a section longer than maxSection between paragraphs
+func splitLong(s section) []section {
+	if len(s.Text) <= maxSection {
+		return []section{s}
+	}
+	var parts []section
+	var part strings.Builder
+	for _, paragraph := range strings.Split(s.Text, "\n\n") {
+		if part.Len() > 0 && part.Len()+len(paragraph) > maxSection {
+			parts = append(parts, section{Heading: s.Heading, Anchor: s.Anchor, Text: part.String()})
+			part.Reset()
+		}
+		if part.Len() > 0 {
+			part.WriteString("\n\n")
+		}
+		// Paragraphs longer than a section are cut
+		if len(paragraph) > maxSection {
+			paragraph = truncate(paragraph, maxSection)
+		}
+		part.WriteString(paragraph)
+	}
+	if part.Len() > 0 {
+		parts = append(parts, section{Heading: s.Heading, Anchor: s.Anchor, Text: part.String()})
+	}
+	return parts
+}
+
+// truncate cuts text to at most n bytes without splitting a character
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
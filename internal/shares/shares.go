@@ -0,0 +1,224 @@
+// Package shares manages share links, which let people without an account read
+// a single page of a private wiki
+package shares
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"wiki-go/internal/crypto"
+	"wiki-go/internal/store"
+)
+
+// sharesFile is stored in the data directory
+const sharesFile = "shares.json"
+
+// maxAccesses is the number of accesses kept in the log of a share
+const maxAccesses = 200
+
+// ErrNotFound is returned for share links that don't exist
+var ErrNotFound = errors.New("share link not found")
+
+// Access is a view of a shared page
+type Access struct {
+	Time      time.Time `json:"time"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent,omitempty"`
+}
+
+// Share is a link granting read-only access to a page
+type Share struct {
+	ID           string     `json:"id"`
+	Path         string     `json:"path"`
+	Attachments  bool       `json:"attachments"` // Attachments of the page can be downloaded too
+	PasswordHash string     `json:"passwordHash,omitempty"`
+	CreatedBy    string     `json:"createdBy"`
+	Created      time.Time  `json:"created"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	Revoked      *time.Time `json:"revoked,omitempty"`
+	Views        int        `json:"views"`
+	Accesses     []Access   `json:"accesses"` // Latest accesses, newest last
+}
+
+// Active reports whether the link still grants access
+func (s Share) Active() bool {
+	return s.Revoked == nil && (s.Expires == nil || time.Now().Before(*s.Expires))
+}
+
+// HasPassword reports whether the link asks for a password
+func (s Share) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// CheckPassword reports whether password opens the link
+func (s Share) CheckPassword(password string) bool {
+	return !s.HasPassword() || crypto.CheckPasswordHash(password, s.PasswordHash)
+}
+
+// mu guards changes of the share links. They are read without it, since the
+// file is replaced at once.
+var mu store.DataFile
+
+// Create stores a new share link for path. A zero expires creates a link that
+// doesn't expire, an empty password one that doesn't ask for a password.
+func Create(rootDir, path, createdBy, password string, attachments bool, expires time.Time) (Share, error) {
+	unlock := mu.Lock(sharesFile)
+	defer unlock()
+
+	id := make([]byte, 18)
+	if _, err := rand.Read(id); err != nil {
+		return Share{}, err
+	}
+	share := Share{
+		ID:          base64.RawURLEncoding.EncodeToString(id),
+		Path:        path,
+		Attachments: attachments,
+		CreatedBy:   createdBy,
+		Created:     time.Now(),
+		Accesses:    []Access{},
+	}
+	if !expires.IsZero() {
+		share.Expires = &expires
+	}
+	if password != "" {
+		hash, err := crypto.HashPassword(password)
+		if err != nil {
+			return Share{}, err
+		}
+		share.PasswordHash = hash
+	}
+
+	all, err := load(rootDir)
+	if err != nil {
+		return Share{}, err
+	}
+	all = append(all, share)
+	return share, save(rootDir, all)
+}
+
+// List returns the share links of path, all links when path is nil, newest first
+func List(rootDir string, path *string) ([]Share, error) {
+	all, err := load(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Share, 0, len(all))
+	for _, share := range all {
+		if path == nil || share.Path == *path {
+			list = append(list, share)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.After(list[j].Created)
+	})
+	return list, nil
+}
+
+// Get returns the share link with id
+func Get(rootDir, id string) (Share, error) {
+	all, err := load(rootDir)
+	if err != nil {
+		return Share{}, err
+	}
+	for _, share := range all {
+		if share.ID == id {
+			return share, nil
+		}
+	}
+	return Share{}, ErrNotFound
+}
+
+// Revoke ends the access granted by a share link. The link is kept with its
+// access log.
+func Revoke(rootDir, id string) error {
+	return update(rootDir, id, func(share *Share) {
+		if share.Revoked == nil {
+			now := time.Now()
+			share.Revoked = &now
+		}
+	})
+}
+
+// RecordAccess adds a view to the access log of a share link
+func RecordAccess(rootDir, id string, access Access) error {
+	return update(rootDir, id, func(share *Share) {
+		share.Views++
+		share.Accesses = append(share.Accesses, access)
+		if len(share.Accesses) > maxAccesses {
+			share.Accesses = share.Accesses[len(share.Accesses)-maxAccesses:]
+		}
+	})
+}
+
+// Reattribute credits the share links created by user to another name. It
+// returns the number of changed links.
+func Reattribute(rootDir, user, to string) (int, error) {
+	unlock := mu.Lock(sharesFile)
+	defer unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for i := range all {
+		if all[i].CreatedBy == user {
+			all[i].CreatedBy = to
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, save(rootDir, all)
+}
+
+// update changes the share link with id
+func update(rootDir, id string, change func(*Share)) error {
+	unlock := mu.Lock(sharesFile)
+	defer unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	for i := range all {
+		if all[i].ID == id {
+			change(&all[i])
+			return save(rootDir, all)
+		}
+	}
+	return ErrNotFound
+}
+
+func load(rootDir string) ([]Share, error) {
+	data, err := os.ReadFile(filepath.Join(rootDir, sharesFile))
+	if os.IsNotExist(err) {
+		return []Share{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []Share
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func save(rootDir string, all []Share) error {
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(rootDir, sharesFile), data, 0600)
+}
@@ -0,0 +1,131 @@
+// Package signing issues tokens that grant access without a login, such as
+// embed tokens. Tokens carry their claims, signed with HMAC-SHA256 by a key
+// generated on first use, so they need no storage on the server.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFile is the file in the data directory holding the signing key. Deleting it
+// and restarting the wiki revokes every token issued so far.
+const KeyFile = "signing.key"
+
+// ErrInvalid is returned for tokens that are malformed, forged, issued for
+// another purpose or expired
+var ErrInvalid = errors.New("invalid token")
+
+// Claims are the common fields of every token
+type Claims struct {
+	Purpose string `json:"u"`
+	Expires int64  `json:"e,omitempty"` // Unix time, 0 for tokens that don't expire
+}
+
+// Expired reports whether the token has expired
+func (c Claims) Expired() bool {
+	return c.Expires != 0 && time.Now().Unix() > c.Expires
+}
+
+var (
+	mu   sync.Mutex
+	keys = make(map[string][]byte)
+)
+
+// Sign returns a token holding claims, which must embed Claims
+func Sign(rootDir string, claims interface{}) (string, error) {
+	key, err := loadKey(rootDir)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(key, encoded)), nil
+}
+
+// Verify checks a token for purpose and decodes its claims
+func Verify(rootDir, token, purpose string, claims interface{}) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalid
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalid
+	}
+	key, err := loadKey(rootDir)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sum, mac(key, encoded)) {
+		return ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalid
+	}
+	var common Claims
+	if err := json.Unmarshal(payload, &common); err != nil || common.Purpose != purpose || common.Expired() {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func mac(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// loadKey returns the signing key of the wiki at rootDir, generating it when
+// there is none yet
+func loadKey(rootDir string) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if key, ok := keys[rootDir]; ok {
+		return key, nil
+	}
+
+	path := filepath.Join(rootDir, KeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, errors.New(path + " must hold a base64 encoded key of at least 32 bytes")
+		}
+		keys[rootDir] = key
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	keys[rootDir] = key
+	return key, nil
+}
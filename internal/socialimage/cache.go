@@ -0,0 +1,97 @@
+package socialimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/resources"
+)
+
+// cacheDir holds the drawn images in the data directory
+const cacheDir = "social-images"
+
+// layoutVersion changes whenever Render draws differently, so cached images
+// are drawn again
+const layoutVersion = "1"
+
+var mu sync.Mutex // Only one image is drawn at a time
+
+// Get returns the preview image of the page at path, drawing it when its card
+// or the logo changed since it was cached
+func Get(rootDir, path string, card Card) ([]byte, time.Time, error) {
+	logo, logoVersion := logoFile(rootDir)
+
+	pathSum := sha256.Sum256([]byte(path))
+	prefix := hex.EncodeToString(pathSum[:8])
+	cardSum := sha256.Sum256([]byte(strings.Join([]string{
+		layoutVersion, logoVersion, card.Color, card.SiteName, card.Title, strings.Join(card.Breadcrumb, "\x00"),
+	}, "\x01")))
+	file := filepath.Join(rootDir, cacheDir, prefix+"-"+hex.EncodeToString(cardSum[:8])+".png")
+
+	if data, err := os.ReadFile(file); err == nil {
+		if info, err := os.Stat(file); err == nil {
+			return data, info.ModTime(), nil
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	data, err := Render(card, loadLogo(logo))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, time.Time{}, err
+	}
+	// Images of earlier titles of the page are no longer linked
+	if old, err := filepath.Glob(filepath.Join(rootDir, cacheDir, prefix+"-*.png")); err == nil {
+		for _, name := range old {
+			os.Remove(name)
+		}
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, time.Now(), nil
+}
+
+// logoFile returns the logo of the wiki, the uploaded logo.png or favicon.png,
+// with a version changing with the file. The file is empty for the embedded
+// favicon.
+func logoFile(rootDir string) (string, string) {
+	for _, name := range []string{"logo.png", "favicon.png"} {
+		file := filepath.Join(rootDir, "static", name)
+		if info, err := os.Stat(file); err == nil {
+			return file, fmt.Sprintf("%s-%d-%d", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return "", "embedded"
+}
+
+// loadLogo decodes the logo returned by logoFile, nil when it can't be read
+func loadLogo(file string) image.Image {
+	var f io.ReadCloser
+	var err error
+	if file != "" {
+		f, err = os.Open(file)
+	} else {
+		f, err = resources.GetStaticFS().Open("favicon.png")
+	}
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	logo, _, err := image.Decode(f)
+	if err != nil {
+		return nil
+	}
+	return logo
+}
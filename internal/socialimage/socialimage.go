@@ -0,0 +1,281 @@
+// Package socialimage draws the preview images of pages shown by chat apps and
+// social sites when a link is shared: the title of the page, its breadcrumb
+// and the logo and name of the wiki on a background in the wiki color.
+package socialimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gosimple/unidecode"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of the images, the 1.91:1 ratio Open Graph and Twitter cards expect
+const (
+	Width  = 1200
+	Height = 630
+)
+
+const (
+	margin    = 80
+	logoSize  = 72
+	maxLines  = 3
+	separator = " › "
+)
+
+// Card is the content of a preview image
+type Card struct {
+	Title      string
+	Breadcrumb []string // Titles of the parent pages, outermost first
+	SiteName   string
+	Color      string // Background color, like "#0066cc"
+}
+
+var (
+	fontsOnce     sync.Once
+	boldFont      *sfnt.Font
+	regularFont   *sfnt.Font
+	fontsErr      error
+	titleSizes    = []float64{76, 64, 54}
+	defaultColor  = color.RGBA{0x00, 0x66, 0xcc, 0xff}
+	textColor     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	mutedColor    = color.NRGBA{0xff, 0xff, 0xff, 0xc0}
+	ellipsis      = "…"
+	ellipsisASCII = "..."
+)
+
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		if boldFont, fontsErr = opentype.Parse(gobold.TTF); fontsErr != nil {
+			return
+		}
+		regularFont, fontsErr = opentype.Parse(goregular.TTF)
+	})
+	return fontsErr
+}
+
+// Render draws the preview image of a card as PNG. logo may be nil.
+func Render(card Card, logo image.Image) ([]byte, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	background(img, parseColor(card.Color))
+
+	// Breadcrumb above the title
+	top := margin
+	if len(card.Breadcrumb) > 0 {
+		face, err := newFace(regularFont, 30)
+		if err != nil {
+			return nil, err
+		}
+		text := fitLine(face, printable(regularFont, strings.Join(card.Breadcrumb, separator)), Width-2*margin)
+		drawText(img, face, text, margin, top+30, mutedColor)
+		face.Close()
+		top += 30 + 36
+	}
+
+	// Title in the largest size fitting in maxLines lines
+	title := printable(boldFont, card.Title)
+	var lines []string
+	var titleFace font.Face
+	for i, size := range titleSizes {
+		face, err := newFace(boldFont, size)
+		if err != nil {
+			return nil, err
+		}
+		lines = wrap(face, title, Width-2*margin)
+		if len(lines) <= maxLines || i == len(titleSizes)-1 {
+			titleFace = face
+			break
+		}
+		face.Close()
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = fitLine(titleFace, lines[maxLines-1]+" "+ellipsis, Width-2*margin)
+	}
+	metrics := titleFace.Metrics()
+	lineHeight := (metrics.Ascent + metrics.Descent).Ceil() + 8
+	y := top + metrics.Ascent.Ceil()
+	for _, line := range lines {
+		drawText(img, titleFace, line, margin, y, textColor)
+		y += lineHeight
+	}
+	titleFace.Close()
+
+	// Logo and name of the wiki at the bottom
+	x := margin
+	bottom := Height - margin
+	if logo != nil {
+		rect := image.Rect(x, bottom-logoSize, x+logoSize, bottom)
+		draw.CatmullRom.Scale(img, fitRect(rect, logo.Bounds()), logo, logo.Bounds(), draw.Over, nil)
+		x += logoSize + 24
+	}
+	if name := printable(regularFont, card.SiteName); name != "" {
+		face, err := newFace(regularFont, 34)
+		if err != nil {
+			return nil, err
+		}
+		ascent := face.Metrics().Ascent.Ceil()
+		drawText(img, face, fitLine(face, name, Width-margin-x), x, bottom-logoSize/2+ascent/2-2, textColor)
+		face.Close()
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newFace(f *sfnt.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// background fills img with a diagonal gradient from base to a darker shade
+func background(img *image.RGBA, base color.RGBA) {
+	dark := color.RGBA{base.R / 2, base.G / 2, base.B / 2, 0xff}
+	total := float64(Width + Height)
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			t := float64(x+y) / total
+			img.SetRGBA(x, y, color.RGBA{
+				R: mix(base.R, dark.R, t),
+				G: mix(base.G, dark.G, t),
+				B: mix(base.B, dark.B, t),
+				A: 0xff,
+			})
+		}
+	}
+}
+
+func mix(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// parseColor reads a #rrggbb or #rgb color, the wiki blue when it isn't one
+func parseColor(value string) color.RGBA {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return defaultColor
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return defaultColor
+	}
+	return color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}
+}
+
+// fitRect returns the largest rectangle with the ratio of src centered in dst
+func fitRect(dst, src image.Rectangle) image.Rectangle {
+	w, h := src.Dx(), src.Dy()
+	if w == 0 || h == 0 {
+		return dst
+	}
+	if w > h {
+		h = dst.Dy() * h / w
+		w = dst.Dx()
+	} else {
+		w = dst.Dx() * w / h
+		h = dst.Dy()
+	}
+	x := dst.Min.X + (dst.Dx()-w)/2
+	y := dst.Min.Y + (dst.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// printable returns text as the font can draw it. The Go fonts cover Latin,
+// Greek and Cyrillic, other scripts and emoji are transliterated.
+func printable(f *sfnt.Font, text string) string {
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if r == ' ' {
+			continue
+		}
+		if index, err := f.GlyphIndex(&buf, r); err != nil || index == 0 {
+			return strings.Join(strings.Fields(unidecode.Unidecode(text)), " ")
+		}
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// wrap breaks text into lines no wider than width, cutting words longer
+// than a line
+func wrap(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		for font.MeasureString(face, word).Ceil() > width {
+			cut := fitLine(face, word, width)
+			cut = strings.TrimSuffix(strings.TrimSuffix(cut, ellipsis), ellipsisASCII)
+			if cut == "" {
+				break
+			}
+			lines = append(lines, cut)
+			word = strings.TrimPrefix(word, cut)
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fitLine shortens text with an ellipsis until it is no wider than width
+func fitLine(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Ceil() <= width {
+		return text
+	}
+	mark := ellipsis
+	if _, ok := face.GlyphAdvance('…'); !ok {
+		mark = ellipsisASCII
+	}
+	runes := []rune(strings.TrimSuffix(text, " "+ellipsis))
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + mark
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func drawText(img *image.RGBA, face font.Face, text string, x, y int, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
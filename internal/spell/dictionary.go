@@ -0,0 +1,367 @@
+package spell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Dictionary is a word list with affix rules, read from a hunspell .dic and
+// .aff pair. Compound words and twofold affixes are not supported.
+type Dictionary struct {
+	words     map[string][]string // Stem to its affix flags
+	prefixes  map[string][]*affix // Keyed by the text the prefix adds
+	suffixes  map[string][]*affix // Keyed by the text the suffix adds
+	forbidden string              // Flag of words that are always wrong
+	needAffix string              // Flag of stems that are only valid with an affix
+	try       string              // Characters tried when suggesting, most common first
+}
+
+// affix is a PFX or SFX rule of the .aff file
+type affix struct {
+	flag  string
+	strip string
+	add   string
+	cond  *regexp.Regexp
+	cross bool // Can be combined with an affix of the other kind
+}
+
+// Encodings the .aff SET option may name, besides UTF-8
+var encodings = map[string]encoding.Encoding{
+	"ISO8859-1":  charmap.ISO8859_1,
+	"ISO8859-2":  charmap.ISO8859_2,
+	"ISO8859-3":  charmap.ISO8859_3,
+	"ISO8859-4":  charmap.ISO8859_4,
+	"ISO8859-5":  charmap.ISO8859_5,
+	"ISO8859-6":  charmap.ISO8859_6,
+	"ISO8859-7":  charmap.ISO8859_7,
+	"ISO8859-8":  charmap.ISO8859_8,
+	"ISO8859-9":  charmap.ISO8859_9,
+	"ISO8859-10": charmap.ISO8859_10,
+	"ISO8859-13": charmap.ISO8859_13,
+	"ISO8859-14": charmap.ISO8859_14,
+	"ISO8859-15": charmap.ISO8859_15,
+	"KOI8-R":     charmap.KOI8R,
+	"KOI8-U":     charmap.KOI8U,
+	"CP1251":     charmap.Windows1251,
+}
+
+// LoadDictionary reads the hunspell dictionary made of affPath and dicPath
+func LoadDictionary(affPath, dicPath string) (*Dictionary, error) {
+	aff, err := os.ReadFile(affPath)
+	if err != nil {
+		return nil, err
+	}
+	dic, err := os.ReadFile(dicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encoding is set in the .aff file and applies to both files
+	if set := affOption(aff, "SET"); set != "" && !strings.EqualFold(set, "UTF-8") {
+		enc, ok := encodings[strings.ToUpper(set)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported dictionary encoding %s", set)
+		}
+		if aff, err = enc.NewDecoder().Bytes(aff); err != nil {
+			return nil, err
+		}
+		if dic, err = enc.NewDecoder().Bytes(dic); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &Dictionary{
+		words:    make(map[string][]string),
+		prefixes: make(map[string][]*affix),
+		suffixes: make(map[string][]*affix),
+	}
+	flagType := affOption(aff, "FLAG")
+	aliases, err := d.parseAff(bytes.NewReader(aff), flagType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", affPath, err)
+	}
+	d.parseDic(bytes.NewReader(dic), flagType, aliases)
+	return d, nil
+}
+
+// affOption returns the value of a single-value option of an .aff file
+func affOption(aff []byte, name string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(aff))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == name {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// parseAff reads the affix rules and options, returning the flag aliases (AF)
+func (d *Dictionary) parseAff(r io.Reader, flagType string) ([][]string, error) {
+	var aliases [][]string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var header *affix // Header of the affix class being read
+	remaining := 0    // Rules left in that class
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "TRY":
+			if len(fields) > 1 {
+				d.try = fields[1]
+			}
+		case "FORBIDDENWORD":
+			if len(fields) > 1 {
+				d.forbidden = fields[1]
+			}
+		case "NEEDAFFIX":
+			if len(fields) > 1 {
+				d.needAffix = fields[1]
+			}
+		case "AF":
+			// The first AF line holds the number of aliases
+			if len(fields) < 2 {
+				continue
+			}
+			if aliases == nil {
+				aliases = [][]string{}
+				continue
+			}
+			aliases = append(aliases, parseFlags(fields[1], flagType))
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid %s line %q", fields[0], scanner.Text())
+			}
+			if remaining == 0 || header == nil || header.flag != fields[1] {
+				count, err := strconv.Atoi(fields[3])
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s header %q", fields[0], scanner.Text())
+				}
+				header = &affix{flag: fields[1], cross: fields[2] == "Y"}
+				remaining = count
+				continue
+			}
+			remaining--
+			if err := d.addAffix(fields, header); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return aliases, scanner.Err()
+}
+
+// addAffix adds a rule line of the class started by header
+func (d *Dictionary) addAffix(fields []string, header *affix) error {
+	prefix := fields[0] == "PFX"
+	rule := &affix{flag: header.flag, cross: header.cross}
+	if fields[2] != "0" {
+		rule.strip = fields[2]
+	}
+	// Continuation flags after the added text are ignored
+	if add, _, _ := strings.Cut(fields[3], "/"); add != "0" {
+		rule.add = add
+	}
+	condition := "."
+	if len(fields) > 4 {
+		condition = fields[4]
+	}
+	pattern := conditionPattern(condition)
+	if prefix {
+		pattern = "^(?:" + pattern + ")"
+	} else {
+		pattern = "(?:" + pattern + ")$"
+	}
+	cond, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid affix condition %q: %w", condition, err)
+	}
+	rule.cond = cond
+
+	if prefix {
+		d.prefixes[rule.add] = append(d.prefixes[rule.add], rule)
+	} else {
+		d.suffixes[rule.add] = append(d.suffixes[rule.add], rule)
+	}
+	return nil
+}
+
+// conditionPattern converts an affix condition, made of characters, . and
+// [...] classes, to a regular expression
+func conditionPattern(condition string) string {
+	var b strings.Builder
+	inClass := false
+	for i, c := range condition {
+		switch {
+		case c == '[' && !inClass:
+			inClass = true
+			b.WriteRune(c)
+		case c == ']' && inClass:
+			inClass = false
+			b.WriteRune(c)
+		case c == '^' && inClass && i > 0 && condition[i-1] == '[':
+			b.WriteRune(c)
+		case c == '.' && !inClass:
+			b.WriteRune(c)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// parseFlags splits a flag list according to the FLAG option of the .aff file
+func parseFlags(flags, flagType string) []string {
+	var parsed []string
+	switch flagType {
+	case "long":
+		runes := []rune(flags)
+		for i := 0; i+1 < len(runes); i += 2 {
+			parsed = append(parsed, string(runes[i:i+2]))
+		}
+	case "num":
+		for _, flag := range strings.Split(flags, ",") {
+			if flag = strings.TrimSpace(flag); flag != "" {
+				parsed = append(parsed, flag)
+			}
+		}
+	default:
+		for _, c := range flags {
+			parsed = append(parsed, string(c))
+		}
+	}
+	return parsed
+}
+
+// parseDic reads the words, skipping the count on the first line
+func (d *Dictionary) parseDic(r io.Reader, flagType string, aliases [][]string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			first = false
+			if _, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+				continue
+			}
+		}
+		// Morphological fields follow the word after whitespace
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		word, flags, _ := strings.Cut(fields[0], "/")
+		var parsed []string
+		if flags != "" {
+			if index, err := strconv.Atoi(flags); err == nil && len(aliases) > 0 {
+				if index > 0 && index <= len(aliases) {
+					parsed = aliases[index-1]
+				}
+			} else {
+				parsed = parseFlags(flags, flagType)
+			}
+		}
+		d.words[word] = append(d.words[word], parsed...)
+	}
+}
+
+// hasFlag reports whether the stem is in the dictionary with flag
+func (d *Dictionary) hasFlag(stem, flag string) bool {
+	flags, ok := d.words[stem]
+	if !ok || d.isForbidden(flags) {
+		return false
+	}
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dictionary) isForbidden(flags []string) bool {
+	for _, f := range flags {
+		if d.forbidden != "" && f == d.forbidden {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports whether word, taken with its exact case, is correct
+func (d *Dictionary) Check(word string) bool {
+	if flags, ok := d.words[word]; ok {
+		if d.isForbidden(flags) {
+			return false
+		}
+		needAffix := false
+		for _, f := range flags {
+			needAffix = needAffix || (d.needAffix != "" && f == d.needAffix)
+		}
+		if !needAffix {
+			return true
+		}
+	}
+
+	for i := 0; i <= len(word); i++ {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		// Suffixes adding word[i:]
+		for _, sfx := range d.suffixes[word[i:]] {
+			stem := word[:i] + sfx.strip
+			if stem == "" || !sfx.cond.MatchString(stem) {
+				continue
+			}
+			if d.hasFlag(stem, sfx.flag) {
+				return true
+			}
+			if sfx.cross && d.checkPrefixed(stem, sfx.flag) {
+				return true
+			}
+		}
+		// Prefixes adding word[:i]
+		for _, pfx := range d.prefixes[word[:i]] {
+			stem := pfx.strip + word[i:]
+			if stem != "" && pfx.cond.MatchString(stem) && d.hasFlag(stem, pfx.flag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkPrefixed reports whether word is a stem with a cross product prefix
+// that also takes the suffix flag
+func (d *Dictionary) checkPrefixed(word, suffixFlag string) bool {
+	for i := 0; i <= len(word); i++ {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		for _, pfx := range d.prefixes[word[:i]] {
+			if !pfx.cross {
+				continue
+			}
+			stem := pfx.strip + word[i:]
+			if stem != "" && pfx.cond.MatchString(stem) && d.hasFlag(stem, pfx.flag) && d.hasFlag(stem, suffixFlag) {
+				return true
+			}
+		}
+	}
+	return false
+}
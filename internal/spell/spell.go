@@ -0,0 +1,404 @@
+package spell
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Misspelling is a word of a page that is not in the dictionary
+type Misspelling struct {
+	Word   string `json:"word"`
+	Line   int    `json:"line"`   // 1-based line in the markdown source
+	Ch     int    `json:"ch"`     // Column in UTF-16 code units, as counted by the editor
+	Length int    `json:"length"` // Length in UTF-16 code units
+}
+
+// Checker checks words against a language dictionary and the custom
+// dictionary of project terms
+type Checker struct {
+	dictionary *Dictionary
+	custom     map[string]bool
+}
+
+// LanguagePattern matches valid dictionary names, e.g. en, en_US or pt-BR
+var LanguagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(?:[_-][A-Za-z0-9]+)*$`)
+
+// ErrNoDictionary is returned when no dictionary is installed for a language
+var ErrNoDictionary = errors.New("no dictionary for this language")
+
+// cache keeps the loaded dictionaries, which are slow to parse
+var cache struct {
+	sync.Mutex
+	dictionaries map[string]cachedDictionary // Keyed by .dic path
+	customPath   string
+	customTime   time.Time
+	custom       map[string]bool
+}
+
+type cachedDictionary struct {
+	modTime    time.Time
+	dictionary *Dictionary
+}
+
+// Dir returns the directory holding the hunspell dictionaries
+func Dir(rootDir string) string {
+	return filepath.Join(rootDir, "dictionaries")
+}
+
+// CustomPath returns the location of the custom dictionary, one word per line
+func CustomPath(rootDir string) string {
+	return filepath.Join(Dir(rootDir), "custom.txt")
+}
+
+// dictionaryName returns the installed dictionary for lang, trying en_US for
+// en-US and the first regional dictionary such as de_DE for de
+func dictionaryName(rootDir, lang string) (string, bool) {
+	dir := Dir(rootDir)
+	exists := func(name string) bool {
+		_, errDic := os.Stat(filepath.Join(dir, name+".dic"))
+		_, errAff := os.Stat(filepath.Join(dir, name+".aff"))
+		return errDic == nil && errAff == nil
+	}
+
+	name := strings.ReplaceAll(lang, "-", "_")
+	if exists(name) {
+		return name, true
+	}
+	base, _, _ := strings.Cut(name, "_")
+	if exists(base) {
+		return base, true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, base+"_*.dic"))
+	sort.Strings(matches)
+	for _, match := range matches {
+		if candidate := strings.TrimSuffix(filepath.Base(match), ".dic"); exists(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// Languages returns the names of the installed dictionaries
+func Languages(rootDir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(Dir(rootDir), "*.dic"))
+	languages := []string{}
+	for _, match := range matches {
+		name := strings.TrimSuffix(filepath.Base(match), ".dic")
+		if _, err := os.Stat(strings.TrimSuffix(match, ".dic") + ".aff"); err == nil {
+			languages = append(languages, name)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// NewChecker returns a checker for lang, using the dictionaries kept in rootDir
+func NewChecker(rootDir, lang string) (*Checker, error) {
+	if !LanguagePattern.MatchString(lang) {
+		return nil, ErrNoDictionary
+	}
+	name, ok := dictionaryName(rootDir, lang)
+	if !ok {
+		return nil, ErrNoDictionary
+	}
+	dicPath := filepath.Join(Dir(rootDir), name+".dic")
+	info, err := os.Stat(dicPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.dictionaries == nil {
+		cache.dictionaries = make(map[string]cachedDictionary)
+	}
+	cached, ok := cache.dictionaries[dicPath]
+	if !ok || !cached.modTime.Equal(info.ModTime()) {
+		dictionary, err := LoadDictionary(filepath.Join(Dir(rootDir), name+".aff"), dicPath)
+		if err != nil {
+			return nil, err
+		}
+		cached = cachedDictionary{modTime: info.ModTime(), dictionary: dictionary}
+		cache.dictionaries[dicPath] = cached
+	}
+
+	custom, err := loadCustom(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{dictionary: cached.dictionary, custom: custom}, nil
+}
+
+// loadCustom returns the custom dictionary words, the cache lock must be held
+func loadCustom(rootDir string) (map[string]bool, error) {
+	path := CustomPath(rootDir)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cache.customPath == path && cache.customTime.Equal(info.ModTime()) && cache.custom != nil {
+		return cache.custom, nil
+	}
+
+	content, err := ReadCustom(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	custom := make(map[string]bool)
+	for _, word := range CustomWords(content) {
+		custom[word] = true
+	}
+	cache.customPath = path
+	cache.customTime = info.ModTime()
+	cache.custom = custom
+	return custom, nil
+}
+
+// CustomWords returns the words of a custom dictionary, skipping blank lines
+// and # comments
+func CustomWords(content string) []string {
+	var words []string
+	for _, line := range strings.Split(content, "\n") {
+		word := strings.TrimSpace(line)
+		if word != "" && !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// ReadCustom returns the custom dictionary as written by the admins
+func ReadCustom(rootDir string) (string, error) {
+	data, err := os.ReadFile(CustomPath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveCustom writes the custom dictionary. Entries must be single words.
+func SaveCustom(rootDir, content string) error {
+	for _, word := range CustomWords(content) {
+		if strings.ContainsFunc(word, unicode.IsSpace) {
+			return errors.New("each line of the dictionary must hold a single word")
+		}
+	}
+	if err := os.MkdirAll(Dir(rootDir), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(CustomPath(rootDir), []byte(content), 0644)
+}
+
+// AddCustom appends word to the custom dictionary unless it is already there
+func AddCustom(rootDir, word string) error {
+	word = strings.TrimSpace(word)
+	if word == "" || strings.ContainsFunc(word, unicode.IsSpace) {
+		return errors.New("a single word is required")
+	}
+	content, err := ReadCustom(rootDir)
+	if err != nil {
+		return err
+	}
+	for _, existing := range CustomWords(content) {
+		if existing == word {
+			return nil
+		}
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return SaveCustom(rootDir, content+word+"\n")
+}
+
+// known reports whether word is in the dictionary or the custom words, with
+// its exact case
+func (c *Checker) known(word string) bool {
+	return c.custom[word] || c.dictionary.Check(word)
+}
+
+// Correct reports whether word is spelled correctly. Like hunspell, a word
+// written in lower case may also be capitalized or written in capitals.
+func (c *Checker) Correct(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	if c.known(word) {
+		return true
+	}
+	lower := strings.ToLower(word)
+	switch {
+	case word == strings.ToUpper(word):
+		return c.known(lower) || c.known(capitalize(lower))
+	case word == capitalize(lower):
+		return c.known(lower)
+	}
+	return false
+}
+
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// Suggest returns up to limit correct words one edit away from word
+func (c *Checker) Suggest(word string, limit int) []string {
+	word = strings.ReplaceAll(word, "’", "'")
+	letters := c.dictionary.try
+	if letters == "" {
+		letters = "esianrtolcdugmphbyfvkwzxjq"
+	}
+	try := []rune(strings.ToLower(letters))
+	runes := []rune(word)
+
+	var candidates []string
+	// Swapped letters and wrong letters are the most common typos
+	for i := 0; i+1 < len(runes); i++ {
+		swapped := append([]rune{}, runes...)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		candidates = append(candidates, string(swapped))
+	}
+	for i := range runes {
+		for _, r := range try {
+			if r != runes[i] {
+				candidates = append(candidates, string(runes[:i])+string(r)+string(runes[i+1:]))
+			}
+		}
+	}
+	for i := range runes {
+		candidates = append(candidates, string(runes[:i])+string(runes[i+1:]))
+	}
+	for i := 0; i <= len(runes); i++ {
+		for _, r := range try {
+			candidates = append(candidates, string(runes[:i])+string(r)+string(runes[i:]))
+		}
+	}
+
+	suggestions := []string{}
+	seen := map[string]bool{word: true}
+	for _, candidate := range candidates {
+		if seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		if candidate != "" && c.Correct(candidate) {
+			suggestions = append(suggestions, candidate)
+			if len(suggestions) == limit {
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+var (
+	fenceRegex = regexp.MustCompile("^\\s{0,3}(`{3,}|~{3,})")
+	// Text that isn't prose: inline code, links targets, autolinks, URLs,
+	// e-mail addresses, HTML tags and shortcodes
+	skipRegex = regexp.MustCompile("`+[^`]*`+|\\]\\([^)]*\\)|<[^>]*>|[a-zA-Z][a-zA-Z0-9+.-]*://\\S+|\\S+@\\S+\\.\\w+|:::[^:]*:::")
+	wordRegex = regexp.MustCompile(`[\p{L}\p{M}]+(?:['’][\p{L}\p{M}]+)*`)
+)
+
+// Check returns the misspelled words of markdown, leaving out frontmatter,
+// code, addresses and words that look like identifiers
+func (c *Checker) Check(markdown string) []Misspelling {
+	misspellings := []Misspelling{}
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	start := 0
+
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	for i := start; i < len(lines); i++ {
+		text := lines[i]
+		if match := fenceRegex.FindStringSubmatch(text); match != nil {
+			switch {
+			case fence == "":
+				fence = match[1]
+			case strings.HasPrefix(match[1], fence):
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		// Blank out what isn't prose, keeping the positions of the words
+		text = skipRegex.ReplaceAllStringFunc(text, func(skipped string) string {
+			return strings.Repeat(" ", len(skipped))
+		})
+		for _, loc := range wordRegex.FindAllStringIndex(text, -1) {
+			word := text[loc[0]:loc[1]]
+			if utf8.RuneCountInString(word) < 2 || identifierAround(text, loc[0], loc[1]) || mixedCase(word) {
+				continue
+			}
+			if !c.Correct(word) {
+				misspellings = append(misspellings, Misspelling{
+					Word:   word,
+					Line:   i + 1,
+					Ch:     utf16Len(lines[i][:loc[0]]),
+					Length: utf16Len(word),
+				})
+			}
+		}
+	}
+	return misspellings
+}
+
+// identifierAround reports whether the word at text[start:end] is part of a
+// longer token such as a file name, a version or snake_case name
+func identifierAround(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsDigit(r) || r == '_' || r == '/' || r == '\\' {
+			return true
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsDigit(r) || r == '_' || r == '/' || r == '\\' {
+			return true
+		}
+		if r == '.' && end+1 < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[end+1:])
+			return unicode.IsLetter(next)
+		}
+	}
+	return false
+}
+
+// mixedCase reports whether word has capitals after lower case letters, like
+// camelCase names
+func mixedCase(word string) bool {
+	lowerSeen := false
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			lowerSeen = true
+		} else if unicode.IsUpper(r) && lowerSeen {
+			return true
+		}
+	}
+	return false
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
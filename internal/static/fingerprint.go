@@ -0,0 +1,109 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wiki-go/internal/resources"
+)
+
+// hashLength is the number of hex digits of the content hash put in asset names
+const hashLength = 10
+
+// fingerprint is the hash of a static file. Custom files are hashed again when
+// they change.
+type fingerprint struct {
+	hash    string
+	custom  bool
+	modTime time.Time
+	size    int64
+}
+
+var fingerprints = struct {
+	sync.Mutex
+	hashes map[string]fingerprint
+}{hashes: make(map[string]fingerprint)}
+
+// AssetURL returns the address of a static file with a hash of its content in
+// its name, e.g. "/static/js/search.1a2b3c4d5e.js" for "js/search.js". The
+// address changes whenever the file does, so browsers may cache it for good.
+// Files that don't exist get their plain address.
+func AssetURL(dataDir, name string) string {
+	hash := assetHash(dataDir, name)
+	if hash == "" {
+		return "/static/" + name
+	}
+	ext := path.Ext(name)
+	return "/static/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// ResolveAsset returns the static file a fingerprinted name refers to, and
+// whether the hash in the name is the one of its current content. ok is false
+// when name isn't fingerprinted.
+func ResolveAsset(dataDir, name string) (file string, current bool, ok bool) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	hash := strings.TrimPrefix(path.Ext(stem), ".")
+	if len(hash) != hashLength || !isHex(hash) {
+		return "", false, false
+	}
+	file = strings.TrimSuffix(stem, "."+hash) + ext
+	currentHash := assetHash(dataDir, file)
+	if currentHash == "" {
+		return "", false, false
+	}
+	return file, currentHash == hash, true
+}
+
+// assetHash returns the hash of the static file served for name, checking
+// data/static first, or "" when there is no such file
+func assetHash(dataDir, name string) string {
+	customPath := filepath.Join(dataDir, "static", filepath.FromSlash(name))
+	info, err := os.Stat(customPath)
+	custom := err == nil && !info.IsDir()
+
+	fingerprints.Lock()
+	cached, found := fingerprints.hashes[name]
+	fingerprints.Unlock()
+	if found && cached.custom == custom &&
+		(!custom || (cached.modTime.Equal(info.ModTime()) && cached.size == info.Size())) {
+		return cached.hash
+	}
+
+	var data []byte
+	if custom {
+		data, err = os.ReadFile(customPath)
+	} else {
+		data, err = fs.ReadFile(resources.GetStaticFS(), name)
+	}
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	entry := fingerprint{hash: hex.EncodeToString(sum[:])[:hashLength], custom: custom}
+	if custom {
+		entry.modTime = info.ModTime()
+		entry.size = info.Size()
+	}
+
+	fingerprints.Lock()
+	fingerprints.hashes[name] = entry
+	fingerprints.Unlock()
+	return entry.hash
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
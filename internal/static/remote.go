@@ -0,0 +1,99 @@
+package static
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/resources"
+)
+
+// RemoteAsset is a file browsers would load from another site
+type RemoteAsset struct {
+	Source string // Where the address was found, like templates/base.html or extensions.mermaid.script
+	URL    string
+}
+
+var (
+	// remoteTagRegex matches the addresses of files loaded by HTML elements,
+	// links to other sites are left alone
+	remoteTagRegex = regexp.MustCompile(`(?i)<(?:script|link|img|iframe|source|video|audio|embed|object)\b[^>]*?\s(?:src|href|data)\s*=\s*["']?((?:https?:)?//[^"'\s>]+)`)
+	// remoteCSSRegex matches the addresses of fonts, images and imported style sheets
+	remoteCSSRegex = regexp.MustCompile(`(?i)(?:url\(\s*["']?|@import\s+["'])((?:https?:)?//[^"')\s]+)`)
+)
+
+// RemoteAssets returns the assets browsers would load from other sites: those
+// of the built-in and custom templates and style sheets, and the diagram
+// servers, editors, scripts and map tiles of the configuration
+func RemoteAssets(cfg *config.Config) []RemoteAsset {
+	var found []RemoteAsset
+
+	scan := func(source, content string) {
+		regex := remoteCSSRegex
+		if strings.HasSuffix(source, ".html") {
+			regex = remoteTagRegex
+		}
+		for _, match := range regex.FindAllStringSubmatch(content, -1) {
+			found = append(found, RemoteAsset{Source: source, URL: match[1]})
+		}
+	}
+	scanFS := func(prefix string, fsys fs.FS) {
+		fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+			if ext := filepath.Ext(name); ext != ".css" && ext != ".html" {
+				return nil
+			}
+			if data, err := fs.ReadFile(fsys, name); err == nil {
+				scan(prefix+name, string(data))
+			}
+			return nil
+		})
+	}
+
+	scanFS("templates/", mustSub(resources.GetTemplatesFS(), "templates"))
+	scanFS("static/", resources.GetStaticFS())
+	scanFS(filepath.ToSlash(filepath.Join(cfg.Wiki.RootDir, "static"))+"/", os.DirFS(filepath.Join(cfg.Wiki.RootDir, "static")))
+	if template := cfg.Extensions.Labels.Template; template != "" {
+		file := filepath.Join(cfg.Wiki.RootDir, filepath.Clean("/"+template))
+		if data, err := os.ReadFile(file); err == nil {
+			scan(filepath.ToSlash(file), string(data))
+		}
+	}
+
+	addURL := func(source, address string) {
+		if u, err := url.Parse(strings.TrimSpace(address)); err == nil && u.Host != "" {
+			found = append(found, RemoteAsset{Source: source, URL: strings.TrimSpace(address)})
+		}
+	}
+	if cfg.Extensions.PlantUML.Enable {
+		addURL("extensions.plantuml.server_url", cfg.Extensions.PlantUML.ServerURL)
+	}
+	addURL("extensions.mermaid.script", cfg.Extensions.Mermaid.Script)
+	for _, plugin := range strings.Split(cfg.Extensions.Mermaid.Plugins, ",") {
+		addURL("extensions.mermaid.plugins", plugin)
+	}
+	if cfg.Extensions.Diagrams.Enable {
+		addURL("extensions.diagrams.drawio_url", cfg.Extensions.Diagrams.DrawioURL)
+		addURL("extensions.diagrams.excalidraw_cdn", cfg.Extensions.Diagrams.ExcalidrawCDN)
+	}
+	if cfg.Extensions.Music.Enable {
+		addURL("extensions.music.script", cfg.Extensions.Music.Script)
+	}
+	addURL("extensions.maps.tile_url", strings.Replace(cfg.Extensions.Maps.TileURL, "{s}", "a", 1))
+	return found
+}
+
+// mustSub returns the subdirectory dir of fsys, fsys itself when it has none
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return fsys
+	}
+	return sub
+}
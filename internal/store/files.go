@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DataFile guards a file of the data directory that is read, changed and
+// written back, such as shares.json. Changes made on this instance take turns,
+// and when the store is shared so do those of the other instances, so none of
+// them overwrites the changes of another. The zero value is ready to use.
+type DataFile struct {
+	mu sync.Mutex
+}
+
+// Lock waits until the file called name may be changed and returns the
+// function releasing it. When the shared lock can't be taken, changes are
+// still serialized on this instance.
+func (f *DataFile) Lock(name string) func() {
+	f.mu.Lock()
+	if !Shared() {
+		return f.mu.Unlock
+	}
+	unlock, err := Current.Lock("file:" + name)
+	if err != nil {
+		log.Printf("Warning: failed to lock %s: %v", name, err)
+		return f.mu.Unlock
+	}
+	return func() {
+		unlock()
+		f.mu.Unlock()
+	}
+}
+
+// WriteFile writes a data file to a temporary file renamed over the old one,
+// so instances reading it never see it half written
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFile := file.Name()
+	defer os.Remove(tempFile)
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shares.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("file = %q, %v, want %q", data, err, "new")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the written one", len(entries))
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "file.json"), []byte("x"), 0644); err == nil {
+		t.Error("WriteFile() = nil in a missing directory")
+	}
+}
+
+func TestDataFileLock(t *testing.T) {
+	redis, err := OpenRedis("redis://" + unreachableAddr + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := Current
+	Current = redis
+	t.Cleanup(func() {
+		Current = previous
+		redis.Close()
+	})
+
+	// Another instance changing the file holds the shared lock
+	other, err := Current.Lock("file:shares.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file DataFile
+	acquired := make(chan func())
+	go func() { acquired <- file.Lock("shares.json") }()
+	select {
+	case <-acquired:
+		t.Fatal("Lock() returned while another instance held the file")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	other()
+	select {
+	case unlock := <-acquired:
+		unlock()
+	case <-time.After(time.Second):
+		t.Fatal("Lock() didn't return after the file was released")
+	}
+
+	// Other files are independent
+	unlock := file.Lock("redirects.json")
+	unlock()
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"wiki-go/internal/ban"
+
+	_ "github.com/lib/pq"
+)
+
+// migrations create and update the schema. Each one runs once, in order, and
+// new migrations are only ever appended.
+var migrations = []string{
+	`CREATE TABLE sessions (
+		token      TEXT PRIMARY KEY,
+		username   TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		groups     TEXT NOT NULL DEFAULT '[]',
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX sessions_expires_at ON sessions (expires_at);`,
+
+	`CREATE TABLE login_attempts (
+		key        TEXT PRIMARY KEY,
+		fails      INTEGER NOT NULL,
+		first_fail BIGINT NOT NULL,
+		ban_until  BIGINT NOT NULL,
+		ban_len    BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`,
+
+	`CREATE TABLE generations (
+		name  TEXT PRIMARY KEY,
+		value BIGINT NOT NULL
+	);`,
+}
+
+// migrationLock is the advisory lock held while migrating, so instances starting
+// at the same time don't run the same migration twice
+const migrationLock = 7710274
+
+// attemptRetention is how long login attempts are kept after their last update
+const attemptRetention = 7 * 24 * time.Hour
+
+// Postgres keeps sessions, login attempts and the generations of shared state in
+// a PostgreSQL database shared by all instances of the wiki. Its locks are
+// advisory locks, released by the database when an instance dies holding one.
+type Postgres struct {
+	db   *sql.DB
+	stop chan struct{}
+}
+
+// OpenPostgres connects to the database at url and brings its schema up to date
+func OpenPostgres(url string) (*Postgres, error) {
+	if url == "" {
+		return nil, errors.New("database.url is required for the postgres driver")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	p := &Postgres{db: db, stop: make(chan struct{})}
+	go p.cleanup(time.Hour)
+	return p, nil
+}
+
+// migrate applies the migrations the database hasn't seen yet
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for version := current + 1; version <= len(migrations); version++ {
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return err
+		}
+		log.Printf("Applied database migration %d", version)
+	}
+	return tx.Commit()
+}
+
+// cleanup periodically removes expired sessions and old login attempts
+func (p *Postgres) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if _, err := p.db.Exec(`DELETE FROM sessions WHERE expires_at < now()`); err != nil {
+				log.Printf("Warning: failed to remove expired sessions: %v", err)
+			}
+			if _, err := p.db.Exec(`DELETE FROM login_attempts WHERE updated_at < $1`, time.Now().Add(-attemptRetention)); err != nil {
+				log.Printf("Warning: failed to remove old login attempts: %v", err)
+			}
+		}
+	}
+}
+
+func (p *Postgres) GetSession(token string) (*Session, error) {
+	var session Session
+	var groups string
+	err := p.db.QueryRow(
+		`SELECT username, role, groups, created_at, expires_at FROM sessions WHERE token = $1 AND expires_at > now()`,
+		token,
+	).Scan(&session.Username, &session.Role, &groups, &session.CreatedAt, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(groups), &session.Groups); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (p *Postgres) SaveSession(token string, session Session) error {
+	groups, err := json.Marshal(session.Groups)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(
+		`INSERT INTO sessions (token, username, role, groups, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (token) DO UPDATE SET username = $2, role = $3, groups = $4, created_at = $5, expires_at = $6`,
+		token, session.Username, session.Role, string(groups), session.CreatedAt, session.ExpiresAt,
+	)
+	return err
+}
+
+func (p *Postgres) DeleteSession(token string) error {
+	_, err := p.db.Exec(`DELETE FROM sessions WHERE token = $1`, token)
+	return err
+}
+
+func (p *Postgres) DeleteUserSessions(username string) error {
+	_, err := p.db.Exec(`DELETE FROM sessions WHERE username = $1`, username)
+	return err
+}
+
+// LoadAttempt implements ban.Backend
+func (p *Postgres) LoadAttempt(key string) (*ban.Attempt, error) {
+	var at ban.Attempt
+	err := p.db.QueryRow(
+		`SELECT fails, first_fail, ban_until, ban_len FROM login_attempts WHERE key = $1`, key,
+	).Scan(&at.Fails, &at.FirstFail, &at.BanUntil, &at.BanLen)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
+
+// SaveAttempt implements ban.Backend
+func (p *Postgres) SaveAttempt(key string, at ban.Attempt) error {
+	_, err := p.db.Exec(
+		`INSERT INTO login_attempts (key, fails, first_fail, ban_until, ban_len, updated_at) VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (key) DO UPDATE SET fails = $2, first_fail = $3, ban_until = $4, ban_len = $5, updated_at = now()`,
+		key, at.Fails, at.FirstFail, at.BanUntil, at.BanLen,
+	)
+	return err
+}
+
+// DeleteAttempt implements ban.Backend
+func (p *Postgres) DeleteAttempt(key string) error {
+	_, err := p.db.Exec(`DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+func (p *Postgres) Lock(name string) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	// Advisory locks belong to a connection, which is kept until the lock is released
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := lockKey(name)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			log.Printf("Warning: failed to release lock %s: %v", name, err)
+			// Closing the connection releases the lock anyway
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+func (p *Postgres) Generation(name string) (int64, error) {
+	var generation int64
+	err := p.db.QueryRow(`SELECT value FROM generations WHERE name = $1`, name).Scan(&generation)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (p *Postgres) Bump(name string) (int64, error) {
+	var generation int64
+	err := p.db.QueryRow(
+		`INSERT INTO generations (name, value) VALUES ($1, 1)
+		ON CONFLICT (name) DO UPDATE SET value = generations.value + 1 RETURNING value`, name,
+	).Scan(&generation)
+	return generation, err
+}
+
+// lockKey returns the advisory lock key of the lock called name
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("wiki-go:" + name))
+	return int64(hash.Sum64())
+}
+
+func (p *Postgres) Close() error {
+	close(p.stop)
+	return p.db.Close()
+}
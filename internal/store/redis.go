@@ -0,0 +1,383 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"wiki-go/internal/ban"
+	"wiki-go/internal/breaker"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every Redis command, so a slow server can't hold up requests
+const redisTimeout = 2 * time.Second
+
+// redisRetryAfter is how long Redis is skipped after an error before it's tried again
+const redisRetryAfter = 30 * time.Second
+
+// errRedisDown is returned while Redis is skipped after an error
+var errRedisDown = errors.New("redis is unavailable")
+
+// Key prefixes of the values stored in Redis
+const (
+	sessionPrefix    = "wiki:session:"
+	attemptPrefix    = "wiki:login_attempt:"
+	lockPrefix       = "wiki:lock:"
+	generationPrefix = "wiki:generation:"
+)
+
+// redisLockTTL is how long a lock outlives an instance that died holding it. Locks
+// held longer are extended while they're held.
+const redisLockTTL = 15 * time.Second
+
+// redisLockRetry is how often a lock held by another instance is tried again
+const redisLockRetry = 50 * time.Millisecond
+
+// releaseScript deletes a lock only while it's still held with the given token,
+// so a lock that expired and was taken by another instance is left alone
+var releaseScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+
+// extendScript extends a lock only while it's still held with the given token
+var extendScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+
+// Redis keeps sessions, login attempts, locks and the generations of shared state
+// in Redis. When Redis can't be reached it falls back to memory, so the wiki keeps
+// working on its own until Redis is back: sessions created meanwhile only exist on
+// the instance that created them, and locks only exclude the requests of one instance.
+type Redis struct {
+	client   *redis.Client
+	fallback *Memory
+	breaker  *breaker.Breaker
+}
+
+// OpenRedis connects to the Redis server at url, e.g. "redis://:password@redis:6379/0".
+// An unreachable server isn't an error, the store degrades to memory until it answers.
+func OpenRedis(url string) (*Redis, error) {
+	if url == "" {
+		return nil, errors.New("database.url is required for the redis driver")
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	options.DialTimeout = redisTimeout
+	options.ReadTimeout = redisTimeout
+	options.WriteTimeout = redisTimeout
+
+	r := &Redis{
+		client:   redis.NewClient(options),
+		fallback: NewMemory(),
+		breaker:  breaker.New("redis", breaker.Options{Threshold: 1, Cooldown: redisRetryAfter}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if r.available() {
+		if err := r.client.Ping(ctx).Err(); err != nil {
+			r.failed(err)
+		} else {
+			r.succeeded()
+		}
+	}
+	return r, nil
+}
+
+// available reports whether Redis should be tried, it's skipped for a while after
+// an error so an outage doesn't slow down every request. Each call that returns
+// true is followed by succeeded or failed.
+func (r *Redis) available() bool {
+	return r.breaker.Allow()
+}
+
+// succeeded records a command that reached Redis
+func (r *Redis) succeeded() {
+	r.breaker.Success()
+}
+
+// failed logs a Redis error and skips Redis until it's retried
+func (r *Redis) failed(err error) {
+	r.breaker.Failure(err)
+	log.Printf("Warning: Redis unavailable, using in-memory state for %s: %v", redisRetryAfter, err)
+}
+
+// redisSession is the stored form of a session
+type redisSession struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Groups    []string  `json:"groups,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (r *Redis) GetSession(token string) (*Session, error) {
+	if !r.available() {
+		return r.fallback.GetSession(token)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, sessionPrefix+token).Bytes()
+	if err != nil && err != redis.Nil {
+		r.failed(err)
+		return r.fallback.GetSession(token)
+	}
+	r.succeeded()
+	if err == redis.Nil {
+		// Sessions created during an outage only exist in memory
+		return r.fallback.GetSession(token)
+	}
+
+	var stored redisSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, nil
+	}
+	session := Session(stored)
+	return &session, nil
+}
+
+func (r *Redis) SaveSession(token string, session Session) error {
+	data, err := json.Marshal(redisSession(session))
+	if err != nil {
+		return err
+	}
+	if !r.available() {
+		return r.fallback.SaveSession(token, session)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Set(ctx, sessionPrefix+token, data, time.Until(session.ExpiresAt)).Err(); err != nil {
+		r.failed(err)
+		return r.fallback.SaveSession(token, session)
+	}
+	r.succeeded()
+	return nil
+}
+
+func (r *Redis) DeleteSession(token string) error {
+	r.fallback.DeleteSession(token)
+	if !r.available() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Del(ctx, sessionPrefix+token).Err(); err != nil {
+		r.failed(err)
+		return nil
+	}
+	r.succeeded()
+	return nil
+}
+
+func (r *Redis) DeleteUserSessions(username string) error {
+	r.fallback.DeleteUserSessions(username)
+	if !r.available() {
+		return errRedisDown
+	}
+
+	// Sessions are keyed by token, so all of them are scanned
+	ctx, cancel := context.WithTimeout(context.Background(), 10*redisTimeout)
+	defer cancel()
+	iter := r.client.Scan(ctx, 0, sessionPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := r.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		var stored redisSession
+		if json.Unmarshal(data, &stored) == nil && stored.Username == username {
+			if err := r.client.Del(ctx, iter.Val()).Err(); err != nil {
+				r.failed(err)
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		r.failed(err)
+		return err
+	}
+	r.succeeded()
+	return nil
+}
+
+// LoadAttempt implements ban.Backend. Errors make the ban list use its local copy.
+func (r *Redis) LoadAttempt(key string) (*ban.Attempt, error) {
+	if !r.available() {
+		return nil, errRedisDown
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, attemptPrefix+key).Bytes()
+	if err != nil && err != redis.Nil {
+		r.failed(err)
+		return nil, err
+	}
+	r.succeeded()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	var at ban.Attempt
+	if err := json.Unmarshal(data, &at); err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
+
+// SaveAttempt implements ban.Backend
+func (r *Redis) SaveAttempt(key string, at ban.Attempt) error {
+	data, err := json.Marshal(at)
+	if err != nil {
+		return err
+	}
+	if !r.available() {
+		return errRedisDown
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Set(ctx, attemptPrefix+key, data, attemptRetention).Err(); err != nil {
+		r.failed(err)
+		return err
+	}
+	r.succeeded()
+	return nil
+}
+
+// DeleteAttempt implements ban.Backend
+func (r *Redis) DeleteAttempt(key string) error {
+	if !r.available() {
+		return errRedisDown
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Del(ctx, attemptPrefix+key).Err(); err != nil {
+		r.failed(err)
+		return err
+	}
+	r.succeeded()
+	return nil
+}
+
+func (r *Redis) Lock(name string) (func(), error) {
+	if !r.available() {
+		return r.fallback.Lock(name)
+	}
+	token, err := newLockToken()
+	if err != nil {
+		r.succeeded()
+		return nil, err
+	}
+
+	key := lockPrefix + name
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		taken, err := r.client.SetNX(ctx, key, token, redisLockTTL).Result()
+		cancel()
+		if err != nil {
+			r.failed(err)
+			return r.fallback.Lock(name)
+		}
+		if taken {
+			break
+		}
+		if time.Now().After(deadline) {
+			r.succeeded()
+			return nil, fmt.Errorf("lock %s is held by another instance", name)
+		}
+		time.Sleep(redisLockRetry)
+	}
+	r.succeeded()
+
+	// Extend the lock while it's held, so long work doesn't lose it
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(redisLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+				err := extendScript.Run(ctx, r.client, []string{key}, token, redisLockTTL.Milliseconds()).Err()
+				cancel()
+				if err != nil {
+					log.Printf("Warning: failed to extend lock %s: %v", name, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+			defer cancel()
+			// A lock that can't be released expires on its own
+			if err := releaseScript.Run(ctx, r.client, []string{key}, token).Err(); err != nil {
+				log.Printf("Warning: failed to release lock %s: %v", name, err)
+			}
+		})
+	}, nil
+}
+
+// newLockToken returns a random token identifying the holder of a lock
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (r *Redis) Generation(name string) (int64, error) {
+	if !r.available() {
+		return r.fallback.Generation(name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	generation, err := r.client.Get(ctx, generationPrefix+name).Int64()
+	if err != nil && err != redis.Nil {
+		r.failed(err)
+		return r.fallback.Generation(name)
+	}
+	r.succeeded()
+	return generation, nil
+}
+
+func (r *Redis) Bump(name string) (int64, error) {
+	if !r.available() {
+		return r.fallback.Bump(name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	generation, err := r.client.Incr(ctx, generationPrefix+name).Result()
+	if err != nil {
+		r.failed(err)
+		return r.fallback.Bump(name)
+	}
+	r.succeeded()
+	return generation, nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
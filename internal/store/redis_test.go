@@ -0,0 +1,111 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"wiki-go/internal/ban"
+	"wiki-go/internal/breaker"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableAddr is a local port nothing listens on
+const unreachableAddr = "127.0.0.1:1"
+
+// unreachableRedis returns stores whose server never answers: one that skips
+// Redis after the first error, as OpenRedis does, and one that tries it again on
+// every call, like after each cooldown
+func unreachableRedis(t *testing.T) map[string]*Redis {
+	t.Helper()
+	skipping, err := OpenRedis("redis://" + unreachableAddr + "/0")
+	if err != nil {
+		t.Fatalf("OpenRedis() = %v, an unreachable server must not be an error", err)
+	}
+	retrying := &Redis{
+		client:   redis.NewClient(&redis.Options{Addr: unreachableAddr, MaxRetries: -1, DialTimeout: redisTimeout}),
+		fallback: NewMemory(),
+		breaker:  breaker.New("redis test", breaker.Options{Threshold: 1, Cooldown: time.Nanosecond}),
+	}
+	stores := map[string]*Redis{"Skipping": skipping, "Retrying": retrying}
+	t.Cleanup(func() {
+		for _, r := range stores {
+			r.Close()
+		}
+	})
+	return stores
+}
+
+func TestRedisFallbackSessions(t *testing.T) {
+	for name, r := range unreachableRedis(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			session := Session{Username: "ann", Role: "admin", Groups: []string{"ops"}, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			if err := r.SaveSession("ann-laptop", session); err != nil {
+				t.Fatalf("SaveSession() = %v while Redis is down", err)
+			}
+			r.SaveSession("ann-phone", session)
+			r.SaveSession("bob", Session{Username: "bob", Role: "editor", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+			got, err := r.GetSession("ann-laptop")
+			if err != nil || got == nil || got.Username != "ann" || got.Groups[0] != "ops" {
+				t.Fatalf("GetSession() = %+v, %v, want the session kept in memory", got, err)
+			}
+			if got, err := r.GetSession("unknown"); err != nil || got != nil {
+				t.Errorf("GetSession() = %+v, %v for an unknown token", got, err)
+			}
+
+			if err := r.DeleteSession("ann-laptop"); err != nil {
+				t.Errorf("DeleteSession() = %v while Redis is down", err)
+			}
+			if got, _ := r.GetSession("ann-laptop"); got != nil {
+				t.Errorf("GetSession() = %+v after deleting it", got)
+			}
+
+			// Sessions kept in Redis can't be removed, so logging out everywhere
+			// reports the error, after removing those kept in memory
+			if err := r.DeleteUserSessions("ann"); err == nil {
+				t.Error("DeleteUserSessions() succeeded while Redis is down")
+			}
+			if got, _ := r.GetSession("ann-phone"); got != nil {
+				t.Errorf("GetSession() = %+v after logging ann out everywhere", got)
+			}
+			if got, _ := r.GetSession("bob"); got == nil {
+				t.Error("logging ann out removed the session of bob")
+			}
+		})
+	}
+}
+
+func TestRedisFallbackLoginAttempts(t *testing.T) {
+	for name, r := range unreachableRedis(t) {
+		t.Run(name, func(t *testing.T) {
+			// Errors make the ban list use its local copy
+			if at, err := r.LoadAttempt("10.0.0.1"); err == nil || at != nil {
+				t.Errorf("LoadAttempt() = %+v, %v while Redis is down, want an error", at, err)
+			}
+			if err := r.SaveAttempt("10.0.0.1", ban.Attempt{Fails: 1}); err == nil {
+				t.Error("SaveAttempt() succeeded while Redis is down")
+			}
+			if err := r.DeleteAttempt("10.0.0.1"); err == nil {
+				t.Error("DeleteAttempt() succeeded while Redis is down")
+			}
+		})
+	}
+}
+
+func TestRedisFallbackLocks(t *testing.T) {
+	for name, r := range unreachableRedis(t) {
+		t.Run(name, func(t *testing.T) {
+			testLocks(t, r)
+		})
+	}
+}
+
+func TestRedisFallbackGenerations(t *testing.T) {
+	for name, r := range unreachableRedis(t) {
+		t.Run(name, func(t *testing.T) {
+			testGenerations(t, r)
+		})
+	}
+}
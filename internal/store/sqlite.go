@@ -0,0 +1,250 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wiki-go/internal/ban"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteMigrations create and update the schema of SQLite databases. Each one
+// runs once, in order, and new migrations are only ever appended.
+var sqliteMigrations = []string{
+	`CREATE TABLE sessions (
+		token      TEXT PRIMARY KEY,
+		username   TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		groups     TEXT NOT NULL DEFAULT '[]',
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+	CREATE INDEX sessions_username ON sessions (username);
+	CREATE INDEX sessions_expires_at ON sessions (expires_at);`,
+
+	`CREATE TABLE login_attempts (
+		key        TEXT PRIMARY KEY,
+		fails      INTEGER NOT NULL,
+		first_fail INTEGER NOT NULL,
+		ban_until  INTEGER NOT NULL,
+		ban_len    INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);`,
+
+	`CREATE TABLE generations (
+		name  TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);`,
+}
+
+// sqliteFile is the database file used when database.url is empty, relative to
+// the data directory
+const sqliteFile = "wiki.db"
+
+// SQLite keeps sessions and login attempts in an SQLite database file, so they
+// survive restarts of a single instance. The file must not be shared between
+// instances, SQLite locking isn't reliable on network file systems.
+type SQLite struct {
+	db    *sql.DB
+	stop  chan struct{}
+	locks localLocks // The database only serves this instance
+}
+
+// OpenSQLite opens the database file at path, creating it when it doesn't exist,
+// and brings its schema up to date
+func OpenSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// Writers wait for each other instead of failing, and transactions take the
+	// write lock when they begin so they can't deadlock upgrading it
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", path, err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		log.Printf("Warning: failed to restrict the permissions of %s: %v", path, err)
+	}
+
+	s := &SQLite{db: db, stop: make(chan struct{})}
+	go s.cleanup(time.Hour)
+	return s, nil
+}
+
+// migrateSQLite applies the migrations the database hasn't seen yet
+func migrateSQLite(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	if current > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this version of the wiki", current)
+	}
+	for version := current + 1; version <= len(sqliteMigrations); version++ {
+		if _, err := tx.Exec(sqliteMigrations[version-1]); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().Unix()); err != nil {
+			return err
+		}
+		log.Printf("Applied database migration %d", version)
+	}
+	return tx.Commit()
+}
+
+// cleanup periodically removes expired sessions and old login attempts
+func (s *SQLite) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.removeExpired(time.Now())
+		}
+	}
+}
+
+// removeExpired removes the sessions expired and the login attempts last updated
+// before the retention at now
+func (s *SQLite) removeExpired(now time.Time) {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.Unix()); err != nil {
+		log.Printf("Warning: failed to remove expired sessions: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM login_attempts WHERE updated_at < ?`, now.Add(-attemptRetention).Unix()); err != nil {
+		log.Printf("Warning: failed to remove old login attempts: %v", err)
+	}
+}
+
+func (s *SQLite) GetSession(token string) (*Session, error) {
+	var session Session
+	var groups string
+	var createdAt, expiresAt int64
+	err := s.db.QueryRow(
+		`SELECT username, role, groups, created_at, expires_at FROM sessions WHERE token = ? AND expires_at > ?`,
+		token, time.Now().Unix(),
+	).Scan(&session.Username, &session.Role, &groups, &createdAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(groups), &session.Groups); err != nil {
+		return nil, err
+	}
+	session.CreatedAt = time.Unix(createdAt, 0)
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+	return &session, nil
+}
+
+func (s *SQLite) SaveSession(token string, session Session) error {
+	groups, err := json.Marshal(session.Groups)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO sessions (token, username, role, groups, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (token) DO UPDATE SET username = excluded.username, role = excluded.role, groups = excluded.groups,
+			created_at = excluded.created_at, expires_at = excluded.expires_at`,
+		token, session.Username, session.Role, string(groups), session.CreatedAt.Unix(), session.ExpiresAt.Unix(),
+	)
+	return err
+}
+
+func (s *SQLite) DeleteSession(token string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+func (s *SQLite) DeleteUserSessions(username string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE username = ?`, username)
+	return err
+}
+
+// LoadAttempt implements ban.Backend
+func (s *SQLite) LoadAttempt(key string) (*ban.Attempt, error) {
+	var at ban.Attempt
+	err := s.db.QueryRow(
+		`SELECT fails, first_fail, ban_until, ban_len FROM login_attempts WHERE key = ?`, key,
+	).Scan(&at.Fails, &at.FirstFail, &at.BanUntil, &at.BanLen)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
+
+// SaveAttempt implements ban.Backend
+func (s *SQLite) SaveAttempt(key string, at ban.Attempt) error {
+	_, err := s.db.Exec(
+		`INSERT INTO login_attempts (key, fails, first_fail, ban_until, ban_len, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET fails = excluded.fails, first_fail = excluded.first_fail,
+			ban_until = excluded.ban_until, ban_len = excluded.ban_len, updated_at = excluded.updated_at`,
+		key, at.Fails, at.FirstFail, at.BanUntil, at.BanLen, time.Now().Unix(),
+	)
+	return err
+}
+
+// DeleteAttempt implements ban.Backend
+func (s *SQLite) DeleteAttempt(key string) error {
+	_, err := s.db.Exec(`DELETE FROM login_attempts WHERE key = ?`, key)
+	return err
+}
+
+func (s *SQLite) Lock(name string) (func(), error) {
+	return s.locks.lock(name), nil
+}
+
+func (s *SQLite) Generation(name string) (int64, error) {
+	var generation int64
+	err := s.db.QueryRow(`SELECT value FROM generations WHERE name = ?`, name).Scan(&generation)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (s *SQLite) Bump(name string) (int64, error) {
+	var generation int64
+	err := s.db.QueryRow(
+		`INSERT INTO generations (name, value) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET value = value + 1 RETURNING value`, name,
+	).Scan(&generation)
+	return generation, err
+}
+
+func (s *SQLite) Close() error {
+	close(s.stop)
+	return s.db.Close()
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"wiki-go/internal/ban"
+	"wiki-go/internal/config"
+)
+
+func openTestSQLite(t *testing.T, path string) *SQLite {
+	t.Helper()
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	s := openTestSQLite(t, filepath.Join(t.TempDir(), "wiki.db"))
+	defer s.Close()
+
+	now := time.Now().Truncate(time.Second)
+	sessions := map[string]Session{
+		"ann-laptop": {Username: "ann", Role: "admin", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		"ann-phone":  {Username: "ann", Role: "admin", Groups: []string{"ops"}, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		"bob":        {Username: "bob", Role: "editor", Groups: []string{"docs", "support"}, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		"expired":    {Username: "bob", Role: "editor", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	}
+	for token, session := range sessions {
+		if err := s.SaveSession(token, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		token    string
+		expected *Session
+	}{
+		{token: "bob", expected: &Session{Username: "bob", Role: "editor", Groups: []string{"docs", "support"}, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{token: "ann-laptop", expected: &Session{Username: "ann", Role: "admin", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}},
+		{token: "expired"},
+		{token: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			got, err := s.GetSession(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetSession(%q) = %+v, want %+v", tt.token, got, tt.expected)
+			}
+		})
+	}
+
+	// Saving a session again replaces it
+	renewed := Session{Username: "bob", Role: "viewer", CreatedAt: now, ExpiresAt: now.Add(2 * time.Hour)}
+	if err := s.SaveSession("bob", renewed); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetSession("bob"); got == nil || got.Role != "viewer" || !got.ExpiresAt.Equal(renewed.ExpiresAt) {
+		t.Errorf("GetSession(bob) = %+v after saving it again, want %+v", got, renewed)
+	}
+
+	if err := s.DeleteSession("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetSession("bob"); got != nil {
+		t.Errorf("GetSession(bob) = %+v after deleting it", got)
+	}
+
+	if err := s.DeleteUserSessions("ann"); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"ann-laptop", "ann-phone"} {
+		if got, _ := s.GetSession(token); got != nil {
+			t.Errorf("GetSession(%q) = %+v after logging ann out everywhere", token, got)
+		}
+	}
+}
+
+func TestSQLiteLoginAttempts(t *testing.T) {
+	s := openTestSQLite(t, filepath.Join(t.TempDir(), "wiki.db"))
+	defer s.Close()
+
+	if at, err := s.LoadAttempt("10.0.0.1"); err != nil || at != nil {
+		t.Fatalf("LoadAttempt() = %+v, %v for an unknown key", at, err)
+	}
+
+	attempt := ban.Attempt{Fails: 3, FirstFail: 100, BanUntil: 200, BanLen: 60}
+	if err := s.SaveAttempt("10.0.0.1", attempt); err != nil {
+		t.Fatal(err)
+	}
+	attempt.Fails = 4
+	if err := s.SaveAttempt("10.0.0.1", attempt); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := s.LoadAttempt("10.0.0.1"); err != nil || at == nil || *at != attempt {
+		t.Errorf("LoadAttempt() = %+v, %v, want %+v", at, err, attempt)
+	}
+
+	if err := s.DeleteAttempt("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if at, _ := s.LoadAttempt("10.0.0.1"); at != nil {
+		t.Errorf("LoadAttempt() = %+v after deleting it", at)
+	}
+}
+
+func TestSQLiteLocks(t *testing.T) {
+	s := openTestSQLite(t, filepath.Join(t.TempDir(), "wiki.db"))
+	defer s.Close()
+	testLocks(t, s)
+}
+
+func TestSQLiteGenerations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wiki.db")
+	s := openTestSQLite(t, path)
+	testGenerations(t, s)
+	s.Close()
+
+	s = openTestSQLite(t, path)
+	defer s.Close()
+	if g, err := s.Generation("config"); err != nil || g != 3 {
+		t.Errorf("Generation() = %d, %v after a restart, want 3", g, err)
+	}
+}
+
+func TestSQLiteRemoveExpired(t *testing.T) {
+	s := openTestSQLite(t, filepath.Join(t.TempDir(), "wiki.db"))
+	defer s.Close()
+
+	now := time.Now()
+	s.SaveSession("old", Session{Username: "ann", ExpiresAt: now.Add(-time.Minute)})
+	s.SaveSession("current", Session{Username: "ann", ExpiresAt: now.Add(time.Hour)})
+	s.SaveAttempt("10.0.0.1", ban.Attempt{Fails: 1})
+
+	s.removeExpired(now.Add(attemptRetention + time.Minute))
+
+	var sessions, attempts int
+	s.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&sessions)
+	s.db.QueryRow(`SELECT COUNT(*) FROM login_attempts`).Scan(&attempts)
+	if sessions != 0 || attempts != 0 {
+		t.Errorf("%d sessions and %d login attempts left, want none", sessions, attempts)
+	}
+}
+
+func TestSQLiteKeepsStateAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "wiki.db")
+	s := openTestSQLite(t, path)
+	session := Session{Username: "ann", Role: "admin", CreatedAt: time.Now().Truncate(time.Second), ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Second)}
+	if err := s.SaveSession("token", session); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Opening the database again doesn't apply the migrations twice
+	s = openTestSQLite(t, path)
+	defer s.Close()
+	got, err := s.GetSession("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Username != "ann" || !got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("GetSession() = %+v after a restart, want %+v", got, session)
+	}
+	var version int
+	s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if version != len(sqliteMigrations) {
+		t.Errorf("schema version = %d, want %d", version, len(sqliteMigrations))
+	}
+}
+
+func TestSQLiteRefusesNewerSchemas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wiki.db")
+	s := openTestSQLite(t, path)
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, 0)`, len(sqliteMigrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if s, err := OpenSQLite(path); err == nil {
+		s.Close()
+		t.Error("OpenSQLite() opened a database migrated by a newer version")
+	}
+}
+
+func TestOpenSQLiteDefaultPath(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Wiki.RootDir = t.TempDir()
+	cfg.Database.Driver = "sqlite"
+
+	st, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, ok := st.(*SQLite); !ok {
+		t.Fatalf("Open() = %T, want *SQLite", st)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Wiki.RootDir, sqliteFile)); err != nil {
+		t.Errorf("no database in the data directory: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/diagnostics"
+)
+
+// Session represents a user session
+type Session struct {
+	Username  string
+	Role      string   // User role: "admin", "editor", or "viewer"
+	Groups    []string // Groups reported by an authenticating proxy
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Store keeps the state that isn't stored in files, so several instances of the
+// wiki can share it when they use the same database. Instances also keep copies
+// of shared files in memory, like the configuration or the semantic index: they
+// take turns changing them with Lock, and each change bumps a generation the
+// other instances compare to the one of their copy.
+type Store interface {
+	// GetSession returns the session for token, nil when it doesn't exist or has expired
+	GetSession(token string) (*Session, error)
+	SaveSession(token string, session Session) error
+	DeleteSession(token string) error
+	// DeleteUserSessions logs a user out everywhere
+	DeleteUserSessions(username string) error
+	// Lock waits for the lock called name, held by one instance at a time, and
+	// returns the function releasing it
+	Lock(name string) (func(), error)
+	// Generation returns the number of changes of the state called name
+	Generation(name string) (int64, error)
+	// Bump records a change of the state called name and returns its new generation
+	Bump(name string) (int64, error)
+	Close() error
+}
+
+// lockTimeout is how long Lock waits for a lock held by another instance
+const lockTimeout = 30 * time.Second
+
+// Shared reports whether the current store is shared by several instances, which
+// then have to coordinate their changes of shared files
+func Shared() bool {
+	switch Current.(type) {
+	case *Postgres, *Redis:
+		return true
+	}
+	return false
+}
+
+// Current is the store used by the wiki, set up at startup by Open
+var Current Store = NewMemory()
+
+func init() {
+	diagnostics.Register("sessions", func() (int, int64) {
+		// Only sessions kept in this process use its memory
+		switch s := Current.(type) {
+		case *Memory:
+			return s.usage()
+		case *Redis:
+			return s.fallback.usage()
+		}
+		return 0, 0
+	})
+}
+
+// Open returns the store configured in cfg.Database
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.Database.Driver {
+	case "", "memory":
+		return NewMemory(), nil
+	case "sqlite":
+		path := cfg.Database.URL
+		if path == "" {
+			path = filepath.Join(cfg.Wiki.RootDir, sqliteFile)
+		}
+		return OpenSQLite(path)
+	case "postgres":
+		return OpenPostgres(cfg.Database.URL)
+	case "redis":
+		return OpenRedis(cfg.Database.URL)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Database.Driver)
+	}
+}
+
+// Memory keeps sessions in memory, for a single instance of the wiki
+type Memory struct {
+	mu          sync.RWMutex
+	sessions    map[string]Session
+	generations map[string]int64
+	locks       localLocks
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{sessions: make(map[string]Session), generations: make(map[string]int64)}
+}
+
+func (m *Memory) GetSession(token string) (*Session, error) {
+	m.mu.RLock()
+	session, exists := m.sessions[token]
+	m.mu.RUnlock()
+
+	if !exists || time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *Memory) SaveSession(token string, session Session) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Drop expired sessions, logins are rare enough for a full sweep
+	for t, s := range m.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(m.sessions, t)
+		}
+	}
+	m.sessions[token] = session
+	return nil
+}
+
+func (m *Memory) DeleteSession(token string) error {
+	m.mu.Lock()
+	delete(m.sessions, token)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) DeleteUserSessions(username string) error {
+	m.mu.Lock()
+	for token, session := range m.sessions {
+		if session.Username == username {
+			delete(m.sessions, token)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// usage returns the number of sessions and their estimated size
+func (m *Memory) usage() (int, int64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	size := int64(0)
+	for token, session := range m.sessions {
+		size += int64(len(token) + len(session.Username) + len(session.Role) + 96)
+		for _, group := range session.Groups {
+			size += int64(len(group) + 16)
+		}
+	}
+	return len(m.sessions), size
+}
+
+func (m *Memory) Lock(name string) (func(), error) {
+	return m.locks.lock(name), nil
+}
+
+func (m *Memory) Generation(name string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.generations[name], nil
+}
+
+func (m *Memory) Bump(name string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generations[name]++
+	return m.generations[name], nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// localLocks are named locks held within this process. The zero value is ready to use.
+type localLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock waits for the lock called name and returns the function releasing it
+func (l *localLocks) lock(name string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[name] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
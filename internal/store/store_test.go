@@ -0,0 +1,73 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+// testLocks checks that a lock is held by one holder at a time
+func testLocks(t *testing.T, s Store) {
+	t.Helper()
+	unlock, err := s.Lock("config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other locks are independent
+	other, err := s.Lock("navigation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockAgain, err := s.Lock("config")
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
+		}
+		close(acquired)
+		unlockAgain()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Lock() returned while the lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock() didn't return after the lock was released")
+	}
+}
+
+// testGenerations checks that generations start at 0 and count the bumps
+func testGenerations(t *testing.T, s Store) {
+	t.Helper()
+	if g, err := s.Generation("config"); err != nil || g != 0 {
+		t.Fatalf("Generation() = %d, %v before any change, want 0", g, err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		if g, err := s.Bump("config"); err != nil || g != want {
+			t.Fatalf("Bump() = %d, %v, want %d", g, err, want)
+		}
+	}
+	if g, err := s.Generation("config"); err != nil || g != 3 {
+		t.Errorf("Generation() = %d, %v, want 3", g, err)
+	}
+	if g, err := s.Generation("navigation"); err != nil || g != 0 {
+		t.Errorf("Generation() = %d, %v for another name, want 0", g, err)
+	}
+}
+
+func TestMemoryLocks(t *testing.T) {
+	testLocks(t, NewMemory())
+}
+
+func TestMemoryGenerations(t *testing.T) {
+	testGenerations(t, NewMemory())
+}
@@ -0,0 +1,263 @@
+// Package suggestions keeps the changes proposed to pages by users who may not
+// edit them. Reviewers accept or reject each hunk of a suggestion, and the
+// accepted hunks are applied to the page as it is at that time.
+package suggestions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/store"
+	"wiki-go/internal/textdiff"
+)
+
+// Unchanged lines kept around the changes of a hunk, which also locate the
+// hunk in the page when it changed since the suggestion was made
+const Context = 3
+
+// States of a suggestion
+const (
+	Pending   = "pending"
+	Accepted  = "accepted"
+	Rejected  = "rejected"
+	Withdrawn = "withdrawn"
+)
+
+// ErrNotFound is returned for suggestions that don't exist
+var ErrNotFound = errors.New("suggestion not found")
+
+// ErrClosed is returned when a suggestion was already reviewed or withdrawn
+var ErrClosed = errors.New("suggestion is no longer pending")
+
+var (
+	mu      store.DataFile // Guards closing suggestions
+	idRegex = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// Suggestion is a proposed change of a page: its content when the change was
+// made and the content proposed instead
+type Suggestion struct {
+	ID       string     `json:"id"`
+	Path     string     `json:"path"` // Page path without slashes around it, "" for the home page
+	Author   string     `json:"author"`
+	Summary  string     `json:"summary"`
+	Created  time.Time  `json:"created"`
+	Base     string     `json:"base"`
+	Proposed string     `json:"proposed"`
+	Status   string     `json:"status"`
+	Reviewer string     `json:"reviewer,omitempty"`
+	Reviewed *time.Time `json:"reviewed,omitempty"`
+	Accepted []int      `json:"accepted,omitempty"` // Indexes of the accepted hunks
+}
+
+// Hunks returns the changes of the suggestion
+func (s Suggestion) Hunks() []textdiff.Hunk {
+	lines := textdiff.Lines(textdiff.SplitLines(s.Base), textdiff.SplitLines(s.Proposed))
+	return textdiff.Hunks(lines, Context)
+}
+
+// dir returns the directory holding the suggestions
+func dir(rootDir string) string {
+	return filepath.Join(rootDir, "suggestions")
+}
+
+// file returns the file of the suggestion with id
+func file(rootDir, id string) string {
+	return filepath.Join(dir(rootDir), id+".json")
+}
+
+// Create stores a pending suggestion of author to change the page at path from
+// base to proposed
+func Create(rootDir, path, author, summary, base, proposed string) (Suggestion, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Suggestion{}, err
+	}
+	suggestion := Suggestion{
+		ID:       hex.EncodeToString(id),
+		Path:     strings.Trim(path, "/"),
+		Author:   author,
+		Summary:  summary,
+		Created:  time.Now(),
+		Base:     base,
+		Proposed: proposed,
+		Status:   Pending,
+	}
+	return suggestion, save(rootDir, suggestion)
+}
+
+// Get returns the suggestion with id
+func Get(rootDir, id string) (Suggestion, error) {
+	return load(rootDir, id)
+}
+
+// List returns the pending suggestions for the page at path, oldest first
+func List(rootDir, path string) ([]Suggestion, error) {
+	entries, err := os.ReadDir(dir(rootDir))
+	if os.IsNotExist(err) {
+		return []Suggestion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.Trim(path, "/")
+	list := []Suggestion{}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !idRegex.MatchString(id) {
+			continue
+		}
+		suggestion, err := load(rootDir, id)
+		if err != nil {
+			return nil, err
+		}
+		if suggestion.Status == Pending && suggestion.Path == path {
+			list = append(list, suggestion)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Close records the outcome of a pending suggestion: accepted with the indexes
+// of its accepted hunks, rejected by a reviewer, or withdrawn by its author
+func Close(rootDir, id, status, reviewer string, accepted []int) (Suggestion, error) {
+	unlock := mu.Lock("suggestions/" + id)
+	defer unlock()
+
+	suggestion, err := load(rootDir, id)
+	if err != nil {
+		return suggestion, err
+	}
+	if suggestion.Status != Pending {
+		return suggestion, ErrClosed
+	}
+	now := time.Now()
+	suggestion.Status = status
+	suggestion.Reviewer = reviewer
+	suggestion.Reviewed = &now
+	suggestion.Accepted = accepted
+	return suggestion, save(rootDir, suggestion)
+}
+
+// Apply applies the hunks of a suggestion with the given indexes to content,
+// the page as it is now. Hunks are found where they were when the page didn't
+// change since, or nearby, like patch does; a hunk whose lines are gone can't
+// be applied.
+func Apply(content string, hunks []textdiff.Hunk, indexes []int) (string, error) {
+	lines := textdiff.SplitLines(content)
+	selected := append([]int(nil), indexes...)
+	sort.Sort(sort.Reverse(sort.IntSlice(selected)))
+
+	// Hunks are applied from the end so the line numbers of the ones before
+	// them stay valid
+	previous := -1
+	for _, index := range selected {
+		if index < 0 || index >= len(hunks) {
+			return "", fmt.Errorf("there is no change %d", index+1)
+		}
+		if index == previous {
+			continue
+		}
+		previous = index
+
+		hunk := hunks[index]
+		var old, replacement []string
+		for _, line := range hunk.Lines {
+			if line.Kind != textdiff.Insert {
+				old = append(old, line.Text)
+			}
+			if line.Kind != textdiff.Delete {
+				replacement = append(replacement, line.Text)
+			}
+		}
+		start := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			start = hunk.OldStart
+		}
+		at := locate(lines, old, start)
+		if at < 0 {
+			return "", fmt.Errorf("change %d no longer applies, the page changed there", index+1)
+		}
+		updated := append([]string{}, lines[:at]...)
+		updated = append(updated, replacement...)
+		lines = append(updated, lines[at+len(old):]...)
+	}
+
+	result := strings.Join(lines, "\n")
+	if len(lines) > 0 && (strings.HasSuffix(content, "\n") || content == "") {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// locate returns where the lines want are in lines, the closest to start, or
+// -1 when they aren't there
+func locate(lines, want []string, start int) int {
+	matches := func(at int) bool {
+		if at < 0 || at+len(want) > len(lines) {
+			return false
+		}
+		for i, line := range want {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; offset <= len(lines); offset++ {
+		if matches(start - offset) {
+			return start - offset
+		}
+		if matches(start + offset) {
+			return start + offset
+		}
+	}
+	return -1
+}
+
+// load reads the suggestion with id
+func load(rootDir, id string) (Suggestion, error) {
+	var suggestion Suggestion
+	if !idRegex.MatchString(id) {
+		return suggestion, ErrNotFound
+	}
+	data, err := encryption.ReadFile(file(rootDir, id))
+	if os.IsNotExist(err) {
+		return suggestion, ErrNotFound
+	}
+	if err != nil {
+		return suggestion, err
+	}
+	err = json.Unmarshal(data, &suggestion)
+	return suggestion, err
+}
+
+// save stores a suggestion, encrypted when its page is: it holds the
+// content of the page
+func save(rootDir string, suggestion Suggestion) error {
+	if err := os.MkdirAll(dir(rootDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(suggestion, "", "  ")
+	if err != nil {
+		return err
+	}
+	if encryption.PageEncrypted(suggestion.Path) {
+		return encryption.WriteSealedFile(file(rootDir, suggestion.ID), data, 0600)
+	}
+	return store.WriteFile(file(rootDir, suggestion.ID), data, 0600)
+}
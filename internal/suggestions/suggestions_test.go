@@ -0,0 +1,69 @@
+package suggestions
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+)
+
+func TestSuggestionsOfEncryptedPages(t *testing.T) {
+	rootDir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Wiki.RootDir = rootDir
+	cfg.Wiki.DocumentsDir = "documents"
+	cfg.Security.Encryption.Directories = "hr"
+	cfg.Security.Encryption.Key = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	if err := encryption.Configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer encryption.Configure(&config.Config{})
+
+	tests := []struct {
+		name   string
+		path   string
+		sealed bool
+	}{
+		{name: "Pages of encrypted directories", path: "hr/salaries", sealed: true},
+		{name: "Encrypted directories themselves", path: "hr", sealed: true},
+		{name: "Other pages", path: "docs/setup", sealed: false},
+		{name: "Home page", path: "", sealed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestion, err := Create(rootDir, tt.path, "alice", "Raise", "Salary: 100\n", "Salary: 200\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(file(rootDir, suggestion.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("suggestion file permissions = %o, want 600", perm)
+			}
+			data, err := os.ReadFile(file(rootDir, suggestion.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encryption.Sealed(data) != tt.sealed {
+				t.Errorf("suggestion file sealed = %v, want %v", encryption.Sealed(data), tt.sealed)
+			}
+			if tt.sealed && strings.Contains(string(data), "Salary") {
+				t.Errorf("encrypted suggestion file holds the page content in plain text")
+			}
+
+			got, err := Get(rootDir, suggestion.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Base != "Salary: 100\n" || got.Proposed != "Salary: 200\n" {
+				t.Errorf("Get() = %q -> %q, want the suggested change", got.Base, got.Proposed)
+			}
+		})
+	}
+}
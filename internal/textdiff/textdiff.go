@@ -0,0 +1,378 @@
+// Package textdiff compares texts line by line, for comparing pages and
+// reviewing proposed changes
+package textdiff
+
+import "strings"
+
+// Beyond this many line pairs left after trimming the common start and end,
+// the remaining lines are shown as replaced instead of being compared
+const maxCells = 4000000
+
+// Kind tells whether a line is in both texts, only in the old one or only in
+// the new one
+type Kind string
+
+const (
+	Equal  Kind = "equal"
+	Delete Kind = "delete"
+	Insert Kind = "insert"
+)
+
+// Line is a line of a comparison, with its 1-based line numbers in the old and
+// new text, 0 when it isn't in one of them
+type Line struct {
+	Kind Kind   `json:"kind"`
+	Text string `json:"text"`
+	Old  int    `json:"old,omitempty"`
+	New  int    `json:"new,omitempty"`
+}
+
+// Hunk is a run of changes with the unchanged lines around them
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Lines    []Line `json:"lines"`
+}
+
+// SplitLines returns the lines of text, without a last empty line for a
+// trailing newline
+func SplitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Lines compares the lines of an old and a new text and returns every line of both, deleted
+// lines before the inserted lines replacing them
+func Lines(before, after []string) []Line {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Kind: Equal, Text: before[i], Old: i + 1, New: i + 1})
+	}
+	lines = append(lines, compareMiddle(before[prefix:len(before)-suffix], after[prefix:len(after)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		o, n := len(before)-i, len(after)-i
+		lines = append(lines, Line{Kind: Equal, Text: before[o], Old: o + 1, New: n + 1})
+	}
+	return lines
+}
+
+// compareMiddle compares the lines between the common start and end with the
+// longest common subsequence. The offsets are the lines before them.
+func compareMiddle(before, after []string, oldOffset, newOffset int) []Line {
+	var lines []Line
+	replace := func() {
+		for i, text := range before {
+			lines = append(lines, Line{Kind: Delete, Text: text, Old: oldOffset + i + 1})
+		}
+		for i, text := range after {
+			lines = append(lines, Line{Kind: Insert, Text: text, New: newOffset + i + 1})
+		}
+	}
+	if len(before) == 0 || len(after) == 0 || len(before)*len(after) > maxCells {
+		replace()
+		return lines
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// before[i:] and after[j:]
+	width := len(after) + 1
+	common := make([]int32, (len(before)+1)*width)
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i*width+j] = common[(i+1)*width+j+1] + 1
+			} else if common[(i+1)*width+j] >= common[i*width+j+1] {
+				common[i*width+j] = common[(i+1)*width+j]
+			} else {
+				common[i*width+j] = common[i*width+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	var deleted, inserted []Line
+	flush := func() {
+		lines = append(lines, deleted...)
+		lines = append(lines, inserted...)
+		deleted, inserted = nil, nil
+	}
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			flush()
+			lines = append(lines, Line{Kind: Equal, Text: before[i], Old: oldOffset + i + 1, New: newOffset + j + 1})
+			i++
+			j++
+		case j == len(after) || (i < len(before) && common[(i+1)*width+j] >= common[i*width+j+1]):
+			deleted = append(deleted, Line{Kind: Delete, Text: before[i], Old: oldOffset + i + 1})
+			i++
+		default:
+			inserted = append(inserted, Line{Kind: Insert, Text: after[j], New: newOffset + j + 1})
+			j++
+		}
+	}
+	flush()
+	return lines
+}
+
+// Hunks groups the changed lines of a comparison with up to context unchanged
+// lines around them. Changes closer than twice the context share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	for start := 0; start < len(lines); {
+		if lines[start].Kind == Equal {
+			start++
+			continue
+		}
+
+		// Extend the hunk while the next change is near enough
+		end := start
+		for end < len(lines) {
+			if lines[end].Kind != Equal {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].Kind == Equal {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		for from < start && lines[from].Kind != Equal {
+			from++
+		}
+		to := end + context
+		if to > len(lines) {
+			to = len(lines)
+		}
+		hunk := Hunk{Lines: append([]Line(nil), lines[from:to]...)}
+		hunk.OldStart, hunk.NewStart = startLines(lines[:to], from)
+		for _, line := range hunk.Lines {
+			if line.Kind != Insert {
+				hunk.OldLines++
+			}
+			if line.Kind != Delete {
+				hunk.NewLines++
+			}
+		}
+		hunks = append(hunks, hunk)
+		start = to
+	}
+	return hunks
+}
+
+// startLines returns the first old and new line numbers of the lines from
+// index on, or as in unified diffs the line before them when they have no line
+// of that text
+func startLines(lines []Line, index int) (int, int) {
+	oldStart, newStart := 0, 0
+	for _, line := range lines[:index] {
+		if line.Old > 0 {
+			oldStart = line.Old
+		}
+		if line.New > 0 {
+			newStart = line.New
+		}
+	}
+	oldFound, newFound := false, false
+	for _, line := range lines[index:] {
+		if !oldFound && line.Old > 0 {
+			oldStart, oldFound = line.Old, true
+		}
+		if !newFound && line.New > 0 {
+			newStart, newFound = line.New, true
+		}
+		if oldFound && newFound {
+			break
+		}
+	}
+	return oldStart, newStart
+}
+
+// Changes counts the inserted and deleted lines of a comparison
+func Changes(lines []Line) (inserted, deleted int) {
+	for _, line := range lines {
+		switch line.Kind {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// Chunk is a part of a three-way merge: lines both sides agree on, or a
+// conflict between the changes of the two sides
+type Chunk struct {
+	Conflict bool     `json:"conflict"`
+	Lines    []string `json:"lines,omitempty"`  // Merged lines, when there is no conflict
+	Base     []string `json:"base,omitempty"`   // Lines of the common ancestor in conflict
+	Theirs   []string `json:"theirs,omitempty"` // Lines of the other side in conflict
+	Mine     []string `json:"mine,omitempty"`   // Lines of this side in conflict
+	Start    int      `json:"start,omitempty"`  // 1-based line of the conflict in the common ancestor
+}
+
+// change replaces the lines start to end of the common ancestor with lines
+type change struct {
+	start, end int
+	lines      []string
+}
+
+// changes returns the changes a comparison makes to its old text
+func changes(lines []Line) []change {
+	var result []change
+	at := 0
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			at++
+			i++
+			continue
+		}
+		c := change{start: at, end: at}
+		for ; i < len(lines) && lines[i].Kind != Equal; i++ {
+			if lines[i].Kind == Delete {
+				c.end++
+			} else {
+				c.lines = append(c.lines, lines[i].Text)
+			}
+		}
+		at = c.end
+		result = append(result, c)
+	}
+	return result
+}
+
+// applyChanges returns the lines from to to of base with the changes made to
+// them
+func applyChanges(base []string, list []change, from, to int) []string {
+	result := []string{}
+	at := from
+	for _, c := range list {
+		result = append(result, base[at:c.start]...)
+		result = append(result, c.lines...)
+		at = c.end
+	}
+	return append(result, base[at:to]...)
+}
+
+// Merge merges the changes two sides made to their common ancestor base.
+// Changes of one side, and the same change made by both, are merged; changes
+// of both sides to the same or adjacent lines are conflicts.
+func Merge(base, theirs, mine []string) []Chunk {
+	theirChanges := changes(Lines(base, theirs))
+	myChanges := changes(Lines(base, mine))
+
+	var chunks []Chunk
+	clean := func(lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		if n := len(chunks); n > 0 && !chunks[n-1].Conflict {
+			chunks[n-1].Lines = append(chunks[n-1].Lines, lines...)
+			return
+		}
+		chunks = append(chunks, Chunk{Lines: append([]string{}, lines...)})
+	}
+
+	at, t, m := 0, 0, 0
+	for t < len(theirChanges) || m < len(myChanges) {
+		// Start a group with the first change of either side, and add the
+		// changes of both sides touching it
+		var start int
+		if m == len(myChanges) || (t < len(theirChanges) && theirChanges[t].start <= myChanges[m].start) {
+			start = theirChanges[t].start
+		} else {
+			start = myChanges[m].start
+		}
+		end := start
+		fromT, fromM := t, m
+	group:
+		for {
+			switch {
+			case t < len(theirChanges) && theirChanges[t].start <= end:
+				if theirChanges[t].end > end {
+					end = theirChanges[t].end
+				}
+				t++
+			case m < len(myChanges) && myChanges[m].start <= end:
+				if myChanges[m].end > end {
+					end = myChanges[m].end
+				}
+				m++
+			default:
+				break group
+			}
+		}
+		clean(base[at:start])
+		at = end
+		theirLines := applyChanges(base, theirChanges[fromT:t], start, end)
+		myLines := applyChanges(base, myChanges[fromM:m], start, end)
+		switch {
+		case fromM == m:
+			clean(theirLines)
+		case fromT == t:
+			clean(myLines)
+		case equalLines(theirLines, myLines):
+			clean(myLines)
+		default:
+			chunks = append(chunks, Chunk{
+				Conflict: true,
+				Base:     append([]string{}, base[start:end]...),
+				Theirs:   theirLines,
+				Mine:     myLines,
+				Start:    start + 1,
+			})
+		}
+	}
+	clean(base[at:])
+	return chunks
+}
+
+// Merged returns the lines of a merge without conflicts, and false when it has
+// conflicts
+func Merged(chunks []Chunk) ([]string, bool) {
+	var lines []string
+	for _, chunk := range chunks {
+		if chunk.Conflict {
+			return nil, false
+		}
+		lines = append(lines, chunk.Lines...)
+	}
+	return lines, true
+}
+
+// equalLines reports whether two lists of lines are the same
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
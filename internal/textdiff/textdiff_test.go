@@ -0,0 +1,224 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{text: "", expected: nil},
+		{text: "a", expected: []string{"a"}},
+		{text: "a\nb\n", expected: []string{"a", "b"}},
+		{text: "a\r\nb", expected: []string{"a", "b"}},
+		{text: "a\n\n", expected: []string{"a", ""}},
+	}
+	for _, tt := range tests {
+		if got := SplitLines(tt.text); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := Lines(SplitLines("a\nb\nc"), SplitLines("a\nB\nc\nd"))
+	expected := []Line{
+		{Kind: Equal, Text: "a", Old: 1, New: 1},
+		{Kind: Delete, Text: "b", Old: 2},
+		{Kind: Insert, Text: "B", New: 2},
+		{Kind: Equal, Text: "c", Old: 3, New: 3},
+		{Kind: Insert, Text: "d", New: 4},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Lines() = %+v, want %+v", got, expected)
+	}
+	if inserted, deleted := Changes(got); inserted != 2 || deleted != 1 {
+		t.Errorf("Changes() = %d, %d, want 2, 1", inserted, deleted)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	lines := Lines(SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10"), SplitLines("1\nX\n3\n4\n5\n6\n7\n8\nY\n10"))
+
+	tests := []struct {
+		name     string
+		lines    []Line
+		context  int
+		expected []Hunk
+	}{
+		{
+			name:    "Distant changes get their own hunks",
+			lines:   lines,
+			context: 1,
+			expected: []Hunk{
+				{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3, Lines: []Line{
+					{Kind: Equal, Text: "1", Old: 1, New: 1},
+					{Kind: Delete, Text: "2", Old: 2},
+					{Kind: Insert, Text: "X", New: 2},
+					{Kind: Equal, Text: "3", Old: 3, New: 3},
+				}},
+				{OldStart: 8, OldLines: 3, NewStart: 8, NewLines: 3, Lines: []Line{
+					{Kind: Equal, Text: "8", Old: 8, New: 8},
+					{Kind: Delete, Text: "9", Old: 9},
+					{Kind: Insert, Text: "Y", New: 9},
+					{Kind: Equal, Text: "10", Old: 10, New: 10},
+				}},
+			},
+		},
+		{
+			name:    "Close changes share a hunk",
+			lines:   lines,
+			context: 3,
+			expected: []Hunk{
+				{OldStart: 1, OldLines: 10, NewStart: 1, NewLines: 10, Lines: lines},
+			},
+		},
+		{
+			name:    "No changes",
+			lines:   Lines(SplitLines("a"), SplitLines("a")),
+			context: 3,
+		},
+		{
+			name:    "New text",
+			lines:   Lines(nil, SplitLines("a\nb")),
+			context: 3,
+			expected: []Hunk{
+				{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2, Lines: []Line{
+					{Kind: Insert, Text: "a", New: 1},
+					{Kind: Insert, Text: "b", New: 2},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hunks(tt.lines, tt.context); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Hunks() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		theirs   string
+		mine     string
+		expected []Chunk
+		merged   []string // Lines of the merge, nil when it has conflicts
+	}{
+		{
+			name:     "Changes to different lines",
+			base:     "a\nb\nc\nd\ne",
+			theirs:   "A\nb\nc\nd\ne",
+			mine:     "a\nb\nc\nd\nE",
+			expected: []Chunk{{Lines: []string{"A", "b", "c", "d", "E"}}},
+			merged:   []string{"A", "b", "c", "d", "E"},
+		},
+		{
+			name:     "Same change on both sides",
+			base:     "a\nb\nc",
+			theirs:   "a\nX\nc",
+			mine:     "a\nX\nc",
+			expected: []Chunk{{Lines: []string{"a", "X", "c"}}},
+			merged:   []string{"a", "X", "c"},
+		},
+		{
+			name:     "Deletion and addition elsewhere",
+			base:     "a\nb\nc",
+			theirs:   "a\nc",
+			mine:     "a\nb\nc\nd",
+			expected: []Chunk{{Lines: []string{"a", "c", "d"}}},
+			merged:   []string{"a", "c", "d"},
+		},
+		{
+			name:   "Different changes to the same line",
+			base:   "a\nb\nc",
+			theirs: "a\nX\nc",
+			mine:   "a\nY\nc",
+			expected: []Chunk{
+				{Lines: []string{"a"}},
+				{Conflict: true, Base: []string{"b"}, Theirs: []string{"X"}, Mine: []string{"Y"}, Start: 2},
+				{Lines: []string{"c"}},
+			},
+		},
+		{
+			name:   "Changes to adjacent lines",
+			base:   "a\nb\nc\nd",
+			theirs: "a\nX\nc\nd",
+			mine:   "a\nb\nY\nd",
+			expected: []Chunk{
+				{Lines: []string{"a"}},
+				{Conflict: true, Base: []string{"b", "c"}, Theirs: []string{"X", "c"}, Mine: []string{"b", "Y"}, Start: 2},
+				{Lines: []string{"d"}},
+			},
+		},
+		{
+			name:   "Deleted on one side, changed on the other",
+			base:   "a\nb\nc",
+			theirs: "a\nc",
+			mine:   "a\nB\nc",
+			expected: []Chunk{
+				{Lines: []string{"a"}},
+				{Conflict: true, Base: []string{"b"}, Theirs: []string{}, Mine: []string{"B"}, Start: 2},
+				{Lines: []string{"c"}},
+			},
+		},
+		{
+			name:   "Both sides add at the end",
+			base:   "a\nb",
+			theirs: "a\nb\nt",
+			mine:   "a\nb\nm",
+			expected: []Chunk{
+				{Lines: []string{"a", "b"}},
+				{Conflict: true, Base: []string{}, Theirs: []string{"t"}, Mine: []string{"m"}, Start: 3},
+			},
+		},
+		{
+			name:   "Both sides write an empty page",
+			base:   "",
+			theirs: "t",
+			mine:   "m",
+			expected: []Chunk{
+				{Conflict: true, Base: []string{}, Theirs: []string{"t"}, Mine: []string{"m"}, Start: 1},
+			},
+		},
+		{
+			name:   "No changes",
+			base:   "",
+			theirs: "",
+			mine:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := Merge(SplitLines(tt.base), SplitLines(tt.theirs), SplitLines(tt.mine))
+			if !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Merge() = %+v, want %+v", chunks, tt.expected)
+			}
+			lines, ok := Merged(chunks)
+			if ok != (tt.merged != nil || tt.expected == nil) {
+				t.Errorf("Merged() ok = %v", ok)
+			}
+			if !reflect.DeepEqual(lines, tt.merged) {
+				t.Errorf("Merged() = %q, want %q", lines, tt.merged)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsItsInputs(t *testing.T) {
+	base := SplitLines("a\nb\nc")
+	theirs := SplitLines("a\nX\nc")
+	mine := SplitLines("a\nY\nc")
+	chunks := Merge(base, theirs, mine)
+	chunks[1].Theirs[0] = "changed"
+	chunks[1].Base[0] = "changed"
+	if theirs[1] != "X" || base[1] != "b" {
+		t.Errorf("changing the conflict changed the merged texts: %q %q", base, theirs)
+	}
+}
@@ -0,0 +1,319 @@
+package translations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/breaker"
+	"wiki-go/internal/config"
+	"wiki-go/internal/safehttp"
+)
+
+// Machine translation pre-fills translations of pages. Providers are
+// registered in providers by the name used in the configuration.
+
+// Translator translates text with a machine translation service
+type Translator interface {
+	// Translate translates markdown written in from into to
+	Translate(text, from, to string) (string, error)
+}
+
+// MachineOptions configure a machine translation provider
+type MachineOptions struct {
+	ServerURL string // API endpoint, empty for the public service of the provider
+	APIKey    string
+	Model     string // Model of OpenAI-compatible endpoints
+	Timeout   time.Duration
+}
+
+// providers create the translator of each provider, by name
+var providers = map[string]func(MachineOptions) Translator{
+	"deepl":          func(o MachineOptions) Translator { return deepL{o} },
+	"libretranslate": func(o MachineOptions) Translator { return libreTranslate{o} },
+	"openai":         func(o MachineOptions) Translator { return openAI{o} },
+}
+
+// ErrNoProvider is returned when machine translation isn't configured
+var ErrNoProvider = errors.New("machine translation is not configured")
+
+// maxChunk is the size of the parts pages are sent to the provider in
+const maxChunk = 4000
+
+// maxResponse is the largest response accepted from a provider
+const maxResponse = 8 << 20
+
+var machineBreaker = breaker.New("machine translation", breaker.Options{Threshold: 3, Cooldown: 30 * time.Second, MaxConcurrent: 4})
+
+// MachineEnabled reports whether a machine translation provider is configured
+func MachineEnabled(cfg *config.Config) bool {
+	_, ok := providers[machineProvider(cfg)]
+	return ok
+}
+
+// NewTranslator returns the translator of the configured provider
+func NewTranslator(cfg *config.Config) (Translator, error) {
+	provider := machineProvider(cfg)
+	if provider == "" {
+		return nil, ErrNoProvider
+	}
+	create, ok := providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown machine translation provider %q", provider)
+	}
+	settings := cfg.Extensions.MachineTranslation
+	timeout := time.Duration(settings.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	return create(MachineOptions{
+		ServerURL: strings.TrimSpace(settings.ServerURL),
+		APIKey:    strings.TrimSpace(settings.APIKey),
+		Model:     strings.TrimSpace(settings.Model),
+		Timeout:   timeout,
+	}), nil
+}
+
+func machineProvider(cfg *config.Config) string {
+	return strings.ToLower(strings.TrimSpace(cfg.Extensions.MachineTranslation.Provider))
+}
+
+// TranslatePage translates the markdown of a page. Frontmatter and fenced
+// code blocks are kept as they are, the rest is sent a few paragraphs at a time.
+func TranslatePage(t Translator, markdown, from, to string) (string, error) {
+	var out strings.Builder
+	for _, segment := range splitPage(markdown) {
+		text := strings.TrimSpace(segment.text)
+		if segment.keep || text == "" {
+			out.WriteString(segment.text)
+			continue
+		}
+		translated, err := t.Translate(text, from, to)
+		if err != nil {
+			return "", err
+		}
+		// Keep the blank lines around the translated paragraphs
+		start := strings.Index(segment.text, text)
+		out.WriteString(segment.text[:start])
+		out.WriteString(strings.TrimSpace(translated))
+		out.WriteString(segment.text[start+len(text):])
+	}
+	return out.String(), nil
+}
+
+// segment is a part of a page, translated unless keep is set
+type segment struct {
+	text string
+	keep bool
+}
+
+// splitPage splits markdown into frontmatter and code blocks, which are kept,
+// and runs of paragraphs of up to maxChunk bytes
+func splitPage(markdown string) []segment {
+	lines := strings.SplitAfter(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	var segments []segment
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, segment{text: current.String()})
+			current.Reset()
+		}
+	}
+
+	i := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for j := 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "---" {
+				segments = append(segments, segment{text: strings.Join(lines[:j+1], ""), keep: true})
+				i = j + 1
+				break
+			}
+		}
+	}
+
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence := trimmed[:3]
+			flush()
+			end := len(lines) - 1
+			for j := i + 1; j < len(lines); j++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+					end = j
+					break
+				}
+			}
+			segments = append(segments, segment{text: strings.Join(lines[i:end+1], ""), keep: true})
+			i = end
+			continue
+		}
+		// Chunks end between paragraphs
+		if trimmed == "" && current.Len() >= maxChunk {
+			flush()
+		}
+		current.WriteString(lines[i])
+	}
+	flush()
+	return segments
+}
+
+// post sends a JSON request to a provider and decodes its JSON response
+func post(options MachineOptions, endpoint string, header map[string][]string, request, response any) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid machine translation URL: %w", err)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// The endpoint is set up by the admin, self-hosted services often run next to the wiki
+	header["Accept"] = []string{"application/json"}
+	policy := safehttp.Policy{
+		AllowedHosts: []string{u.Hostname()},
+		AllowPrivate: true,
+		MaxBytes:     maxResponse,
+		Timeout:      options.Timeout,
+		Header:       header,
+		Breaker:      machineBreaker,
+		Attempts:     2,
+	}
+	status, data, err := policy.Post(endpoint, "application/json", body)
+	if err != nil {
+		return fmt.Errorf("machine translation: %w", err)
+	}
+	if status != 200 {
+		var failure struct {
+			Error   any    `json:"error"`
+			Message string `json:"message"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &failure) == nil {
+			switch e := failure.Error.(type) {
+			case string:
+				message = e
+			case map[string]any:
+				if m, ok := e["message"].(string); ok {
+					message = m
+				}
+			default:
+				if failure.Message != "" {
+					message = failure.Message
+				}
+			}
+		}
+		return fmt.Errorf("machine translation responded with status %d: %s", status, message)
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("machine translation: invalid response: %w", err)
+	}
+	return nil
+}
+
+// baseLanguage returns the language of a tag without its region, e.g. "pt" for "pt-BR"
+func baseLanguage(lang string) string {
+	return strings.ToLower(strings.SplitN(lang, "-", 2)[0])
+}
+
+// deepL translates with the DeepL API
+type deepL struct {
+	options MachineOptions
+}
+
+func (d deepL) Translate(text, from, to string) (string, error) {
+	endpoint := d.options.ServerURL
+	if endpoint == "" {
+		// Keys of free accounts end in ":fx" and have their own endpoint
+		endpoint = "https://api.deepl.com"
+		if strings.HasSuffix(d.options.APIKey, ":fx") {
+			endpoint = "https://api-free.deepl.com"
+		}
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/") + "/v2/translate"
+
+	// Targets need a variant for English and Portuguese, sources never take one
+	target := strings.ToUpper(to)
+	switch target {
+	case "EN":
+		target = "EN-US"
+	case "PT":
+		target = "PT-PT"
+	}
+	request := map[string]any{
+		"text":                []string{text},
+		"source_lang":         strings.ToUpper(baseLanguage(from)),
+		"target_lang":         target,
+		"preserve_formatting": true,
+	}
+	var response struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	header := map[string][]string{"Authorization": {"DeepL-Auth-Key " + d.options.APIKey}}
+	if err := post(d.options, endpoint, header, request, &response); err != nil {
+		return "", err
+	}
+	if len(response.Translations) == 0 {
+		return "", errors.New("machine translation returned no text")
+	}
+	return response.Translations[0].Text, nil
+}
+
+// libreTranslate translates with a LibreTranslate server
+type libreTranslate struct {
+	options MachineOptions
+}
+
+func (l libreTranslate) Translate(text, from, to string) (string, error) {
+	endpoint := l.options.ServerURL
+	if endpoint == "" {
+		endpoint = "https://libretranslate.com"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/") + "/translate"
+
+	request := map[string]any{
+		"q":      text,
+		"source": baseLanguage(from),
+		"target": baseLanguage(to),
+		"format": "text",
+	}
+	if l.options.APIKey != "" {
+		request["api_key"] = l.options.APIKey
+	}
+	var response struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := post(l.options, endpoint, map[string][]string{}, request, &response); err != nil {
+		return "", err
+	}
+	return response.TranslatedText, nil
+}
+
+// openAI translates with a chat completion model of an OpenAI-compatible endpoint
+type openAI struct {
+	options MachineOptions
+}
+
+func (o openAI) Translate(text, from, to string) (string, error) {
+	prompt := fmt.Sprintf("Translate the markdown you are given from the language %q into %q. "+
+		"Keep the markdown syntax, links, HTML tags, code and template placeholders unchanged. "+
+		"Answer with the translation only.", from, to)
+	client := ai.Client{
+		ServerURL: o.options.ServerURL,
+		APIKey:    o.options.APIKey,
+		Model:     o.options.Model,
+		Timeout:   o.options.Timeout,
+		Breaker:   machineBreaker,
+	}
+	completion, err := client.Complete(prompt, text)
+	if err != nil {
+		return "", fmt.Errorf("machine translation: %w", err)
+	}
+	return completion.Text, nil
+}
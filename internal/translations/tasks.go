@@ -0,0 +1,128 @@
+package translations
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+)
+
+// States of a pending translation
+const (
+	StateMissing  = "missing"  // The page isn't translated into the language
+	StateOutdated = "outdated" // The source page changed since the translation was saved
+	StateMachine  = "machine"  // The translation was made by machine and awaits review
+)
+
+// Task is a translation of a page that is missing or outdated
+type Task struct {
+	Path       string    `json:"path"` // Path of the page, "/" for the homepage
+	Title      string    `json:"title,omitempty"`
+	Language   string    `json:"language"`
+	State      string    `json:"state"`
+	Assignee   string    `json:"assignee,omitempty"`
+	Translated time.Time `json:"translated,omitzero"` // When the translation was last saved
+	Changed    time.Time `json:"changed"`             // When the source page was last changed
+}
+
+// Pending returns the missing, outdated and unreviewed machine translations of
+// every page, sorted by path and language. Pages are expected in each
+// configured language, and translations into other languages are checked too.
+func Pending(cfg *config.Config) ([]Task, error) {
+	configured := Configured(cfg)
+	var tasks []Task
+
+	check := func(dir, path, relativePath string) error {
+		info, err := os.Stat(filepath.Join(dir, "document.md"))
+		if err != nil {
+			return nil
+		}
+		source, err := encryption.ReadFile(filepath.Join(dir, "document.md"))
+		if err != nil {
+			return err
+		}
+		hash := sourceHash(source)
+
+		records, err := load(cfg.Wiki.RootDir, relativePath)
+		if err != nil {
+			return err
+		}
+
+		languages := append([]string{}, configured...)
+		existing := make(map[string]bool)
+		for _, lang := range List(dir) {
+			existing[lang] = true
+			if !contains(languages, lang) {
+				languages = append(languages, lang)
+			}
+		}
+
+		for _, lang := range languages {
+			rec := records[lang]
+			task := Task{Path: path, Language: lang, Assignee: rec.Assignee, Translated: rec.Time, Changed: info.ModTime()}
+			switch {
+			case !existing[lang]:
+				task.State = StateMissing
+			case rec.SourceHash != hash:
+				task.State = StateOutdated
+			case rec.Machine:
+				task.State = StateMachine
+			default:
+				continue
+			}
+			tasks = append(tasks, task)
+		}
+		return nil
+	}
+
+	if err := check(filepath.Join(cfg.Wiki.RootDir, "pages", "home"), "/", "pages/home"); err != nil {
+		return nil, err
+	}
+
+	docsDir := filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir)
+	err := filepath.WalkDir(docsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() || path == docsDir {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(docsDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		return check(path, "/"+rel, "documents/"+rel)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Path != tasks[j].Path {
+			return tasks[i].Path < tasks[j].Path
+		}
+		return tasks[i].Language < tasks[j].Language
+	})
+	return tasks, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
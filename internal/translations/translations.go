@@ -0,0 +1,280 @@
+// Package translations manages the translated variants of pages. A page is
+// written in the default language of the wiki as document.md, its translations
+// are kept next to it as document.<lang>.md (e.g. document.de.md).
+//
+// When a translation is saved, the hash of the source page it was translated
+// from is recorded next to the versions of the page. A translation is outdated
+// once the source page changes after it.
+package translations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/store"
+)
+
+// statusFile is stored next to the history of a page
+const statusFile = "translations.json"
+
+// languagePattern matches language tags such as "de", "fil" or "pt-BR"
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+var statusMutex store.DataFile
+
+// record is the recorded state of a translation
+type record struct {
+	SourceHash string    `json:"sourceHash,omitempty"` // Hash of the source page the translation was made from
+	User       string    `json:"user,omitempty"`
+	Time       time.Time `json:"time,omitzero"`
+	Assignee   string    `json:"assignee,omitempty"` // Translator asked to create or update the translation
+	Machine    bool      `json:"machine,omitempty"`  // Made by machine translation and not confirmed by a person yet
+}
+
+// Variant is a translation of a page
+type Variant struct {
+	Language string    `json:"language"`
+	User     string    `json:"user,omitempty"` // Who last saved the translation, empty when it was never tracked
+	Time     time.Time `json:"time,omitzero"`
+	Outdated bool      `json:"outdated"` // The source page changed since the translation was saved
+	Machine  bool      `json:"machine"`  // Machine translated and not confirmed yet
+}
+
+// Valid reports whether lang is a language tag variants can be named with
+func Valid(lang string) bool {
+	return languagePattern.MatchString(lang)
+}
+
+// FileName returns the name of the variant of a page in lang, document.md for
+// the source language
+func FileName(cfg *config.Config, lang string) string {
+	if lang == "" || lang == Source(cfg) {
+		return "document.md"
+	}
+	return "document." + lang + ".md"
+}
+
+// LanguageOf returns the language of a variant file name such as document.de.md
+func LanguageOf(name string) (string, bool) {
+	if name == "document.md" || !strings.HasPrefix(name, "document.") || !strings.HasSuffix(name, ".md") {
+		return "", false
+	}
+	lang := strings.TrimSuffix(strings.TrimPrefix(name, "document."), ".md")
+	return lang, Valid(lang)
+}
+
+// IsVariant reports whether name is the file of a translated variant
+func IsVariant(name string) bool {
+	_, ok := LanguageOf(name)
+	return ok
+}
+
+// Source returns the language pages are written in
+func Source(cfg *config.Config) string {
+	if lang := strings.TrimSpace(cfg.Wiki.Language); lang != "" {
+		return lang
+	}
+	return "en"
+}
+
+// Configured returns the languages pages can be translated into, without the
+// source language
+func Configured(cfg *config.Config) []string {
+	var list []string
+	seen := map[string]bool{Source(cfg): true}
+	for _, lang := range strings.Split(cfg.Wiki.ContentLanguages, ",") {
+		lang = strings.TrimSpace(lang)
+		if !Valid(lang) || seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		list = append(list, lang)
+	}
+	return list
+}
+
+// List returns the languages a page in dir has been translated into, sorted
+func List(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var list []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if lang, ok := LanguageOf(entry.Name()); ok {
+			list = append(list, lang)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Status returns the translations of the page in dir and whether each is up to
+// date with the source page. relativePath is the same path used for versioning.
+// Translations saved before tracking started are reported as outdated.
+func Status(rootDir, relativePath, dir string) ([]Variant, error) {
+	langs := List(dir)
+	if len(langs) == 0 {
+		return nil, nil
+	}
+	source, err := encryption.ReadFile(filepath.Join(dir, "document.md"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	hash := sourceHash(source)
+
+	records, err := load(rootDir, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]Variant, 0, len(langs))
+	for _, lang := range langs {
+		variant := Variant{Language: lang, Outdated: true}
+		if rec, ok := records[lang]; ok && rec.SourceHash != "" {
+			variant.User, variant.Time, variant.Machine = rec.User, rec.Time, rec.Machine
+			variant.Outdated = rec.SourceHash != hash
+		}
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// Record marks the translation of a page into lang as made by user from the
+// current source content. machine marks it as machine translated, which it
+// stays, across later edits, until it is confirmed.
+func Record(rootDir, relativePath, lang, user string, source []byte, machine bool) error {
+	unlock := statusMutex.Lock(filepath.Join(relativePath, statusFile))
+	defer unlock()
+
+	records, err := load(rootDir, relativePath)
+	if err != nil {
+		return err
+	}
+	rec := records[lang]
+	rec.SourceHash, rec.User, rec.Time = sourceHash(source), user, time.Now()
+	rec.Machine = rec.Machine || machine
+	records[lang] = rec
+	return save(rootDir, relativePath, records)
+}
+
+// Confirm records that a person reviewed the machine translation of a page
+// into lang
+func Confirm(rootDir, relativePath, lang string) error {
+	unlock := statusMutex.Lock(filepath.Join(relativePath, statusFile))
+	defer unlock()
+
+	records, err := load(rootDir, relativePath)
+	if err != nil {
+		return err
+	}
+	rec, ok := records[lang]
+	if !ok || !rec.Machine {
+		return nil
+	}
+	rec.Machine = false
+	records[lang] = rec
+	return save(rootDir, relativePath, records)
+}
+
+// Assign asks assignee to create or update the translation of a page into
+// lang, an empty assignee removes the assignment
+func Assign(rootDir, relativePath, lang, assignee string) error {
+	unlock := statusMutex.Lock(filepath.Join(relativePath, statusFile))
+	defer unlock()
+
+	records, err := load(rootDir, relativePath)
+	if err != nil {
+		return err
+	}
+	rec := records[lang]
+	rec.Assignee = assignee
+	if rec == (record{}) {
+		delete(records, lang)
+	} else {
+		records[lang] = rec
+	}
+	return save(rootDir, relativePath, records)
+}
+
+// Match picks the language of an Accept-Language header best served by the
+// available languages, empty when none is acceptable
+func Match(acceptLanguage string, available []string) string {
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if value, err := strconv.ParseFloat(q, 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		if tag == "" || quality <= bestQuality {
+			continue
+		}
+		for _, lang := range available {
+			// "de-AT" is served by "de", and "de" by "de-AT" when it's the only German
+			l := strings.ToLower(lang)
+			if l == tag || strings.SplitN(l, "-", 2)[0] == strings.SplitN(tag, "-", 2)[0] {
+				best, bestQuality = lang, quality
+				if l == tag {
+					break
+				}
+			}
+		}
+	}
+	return best
+}
+
+// load reads the translation records of a page
+func load(rootDir, relativePath string) (map[string]record, error) {
+	records := make(map[string]record)
+	data, err := os.ReadFile(filepath.Join(rootDir, "versions", relativePath, statusFile))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// save writes the translation records of a page, the caller holds its lock
+func save(rootDir, relativePath string, records map[string]record) error {
+	dir := filepath.Join(rootDir, "versions", relativePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(filepath.Join(dir, statusFile), data, 0644)
+}
+
+// sourceHash identifies a version of the source page. Line endings and
+// trailing whitespace are ignored so saving without changes keeps translations current.
+func sourceHash(source []byte) string {
+	text := strings.ReplaceAll(string(source), "\r\n", "\n")
+	sum := sha256.Sum256([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(sum[:8])
+}
@@ -5,6 +5,9 @@ import (
 	"time"
 	"wiki-go/internal/comments"
 	"wiki-go/internal/config"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/review"
+	"wiki-go/internal/translations"
 )
 
 // Role constants are now defined in the roles package
@@ -16,9 +19,18 @@ type NavItem struct {
 	IsDir          bool
 	Children       []*NavItem
 	IsActive       bool
+	HasChildren    bool   // Set on sidebar copies, whose children may not be loaded
 	DocumentLayout string // Layout type from frontmatter
 }
 
+// Pagination describes the pages of a long listing
+type Pagination struct {
+	Page    int    // Current page, starting at 1
+	Pages   int    // Number of pages
+	PrevURL string // Empty on the first page
+	NextURL string // Empty on the last page
+}
+
 // BreadcrumbItem represents an item in the breadcrumb trail
 type BreadcrumbItem struct {
 	Title  string
@@ -26,11 +38,76 @@ type BreadcrumbItem struct {
 	IsLast bool
 }
 
+// SocialMeta holds the Open Graph and Twitter card tags of a page
+type SocialMeta struct {
+	Title       string
+	Description string
+	URL         string // Absolute URL of the page
+	Image       string // Absolute URL of the card image, empty for none
+	Type        string // Open Graph type: "website" for the homepage, "article" otherwise
+	SiteName    string
+	Card        string // Twitter card: "summary_large_image" with a page image, "summary" otherwise
+	Modified    string // RFC 3339 time the page was last changed
+}
+
+// Announcement is a banner shown above the page
+type Announcement struct {
+	ID          string
+	Level       string        // info, warning or critical
+	HTML        template.HTML // Rendered message
+	Dismissible bool
+}
+
+// ArchiveInfo tells that a page is archived, shown as a banner above it
+type ArchiveInfo struct {
+	Path      string    // Archived page, the page itself or one of its parents
+	By        string    // User who archived it
+	At        time.Time // When it was archived
+	Inherited bool      // The page is archived along with a parent
+}
+
+// Dashboard is the home page as a list of widgets
+type Dashboard struct {
+	Widgets      []DashboardWidget
+	HasContent   bool // A widget shows the home page document
+	Personal     bool // The reader arranged the dashboard for themselves
+	CanCustomize bool // The reader may arrange a dashboard of their own
+}
+
+// DashboardWidget is a widget of the dashboard with its entries
+type DashboardWidget struct {
+	Type  string
+	Title string // Title set in the layout, empty for the default title of the type
+	Width string // full or half
+	Pages []DashboardPage
+	Tags  []DashboardTag
+}
+
+// DashboardPage is a page listed by a widget
+type DashboardPage struct {
+	Title    string
+	Path     string
+	Modified time.Time // Zero when the widget doesn't show times
+	User     string    // Last editor, when known
+	Summary  string    // Summary of the last edit, when one was given
+	Minor    bool      // The last edit was a minor one
+}
+
+// DashboardTag is a tag of the tag cloud
+type DashboardTag struct {
+	Name  string
+	Count int // Pages with the tag
+	Size  int // 1 for the least used tags to 5 for the most used
+}
+
 // PageData represents the data passed to the template
 type PageData struct {
 	Navigation         *NavItem
+	Favorites          []*NavItem     // Pages the user starred, shown above the navigation
+	Announcements      []Announcement // Banners shown above the page
 	Content            template.HTML
 	DirContent         template.HTML
+	DirPagination      *Pagination // Set when the directory listing has several pages
 	Breadcrumbs        []BreadcrumbItem
 	Config             *config.Config
 	LastModified       time.Time
@@ -44,4 +121,31 @@ type PageData struct {
 	UserRole           string             // User role: "admin", "editor", or "viewer"
 	DocPath            string             // Document path for API calls
 	DocumentLayout     string             // Document layout type from frontmatter (e.g., "kanban")
+	Stats              *pagestats.Stats   // Word count, reading time and history of the document
+	Review             *review.Status     // Review status, nil if the page has no review interval
+	Owners             []string           // Owners of the page (usernames and @groups)
+	SyncedFrom         string             // Remote the page is pulled from, empty for local pages
+	Tags               []string           // Tags of the page from its frontmatter
+	Visibility         string             // Visibility level of the page: public, internal, restricted or unlisted
+	CSPNonce           string             // Nonce allowing the inline scripts of the page
+	Description        string             // Excerpt of the page for the description meta tags
+	Social             *SocialMeta        // Link card tags, nil unless guests may read the page
+	Draft              bool               // The page is marked as a draft in its frontmatter
+	Archived           *ArchiveInfo       // Set when the page is archived
+	RedirectsTo        string             // Target of a redirect page shown with ?redirect=no, or whose redirects loop
+	RedirectedFrom     string             // Page the reader was redirected from
+	Dashboard          *Dashboard         // Widgets of the home page, nil on other pages
+	SyntaxTheme        string             // Stylesheet of code blocks set by the directory, empty to follow the page theme
+	NoMath             bool               // The directory turned math typesetting off
+
+	// Translations of the page
+	SourceLanguage        string                 // Language pages are written in
+	ContentLanguage       string                 // Language the page content is shown in
+	ContentDirection      string                 // Direction of the page content, "rtl" or "ltr"
+	MissingLanguage       string                 // Language asked for when the page has no such translation
+	Translations          []translations.Variant // Translated variants of the page
+	TranslationOutdated   bool                   // The shown translation is behind the source page
+	TranslationMachine    bool                   // The shown translation was machine translated and isn't confirmed yet
+	MachineTranslation    bool                   // Editors can pre-fill translations with machine translation
+	UntranslatedLanguages []string               // Configured languages without a translation, offered to editors
 }
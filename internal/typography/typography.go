@@ -0,0 +1,126 @@
+package typography
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+)
+
+// Settings are the typographic options of rendered pages and the text
+// substitutions admins set up, such as (c) to © or the casing of product names
+type Settings struct {
+	SmartQuotes   bool           `yaml:"smart_quotes"` // "Quotes" and 'quotes' become curly quotes
+	Dashes        bool           `yaml:"dashes"`       // -- and --- become en and em dashes
+	Ellipses      *bool          `yaml:"ellipses"`     // ... becomes …, on unless set to false
+	Substitutions []Substitution `yaml:"substitutions"`
+
+	compiled []*regexp.Regexp // Pattern of each substitution
+}
+
+// Substitution replaces a piece of text wherever it appears outside of code
+type Substitution struct {
+	Find       string `yaml:"find"`
+	Replace    string `yaml:"replace"`
+	Word       bool   `yaml:"word"`        // Only whole words are replaced
+	IgnoreCase bool   `yaml:"ignore_case"` // Matches any casing, e.g. to fix "Github" and "github"
+}
+
+// cache avoids re-reading the settings file on every render
+var cache filecache.Cache[*Settings]
+
+// FilePath returns the location of the typography settings
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "typography.yaml")
+}
+
+// Load returns the current settings, the defaults when the file does not exist
+func Load(rootDir string) (*Settings, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &Settings{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML settings
+func Parse(data []byte) (*Settings, error) {
+	var settings Settings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	for i, substitution := range settings.Substitutions {
+		if substitution.Find == "" {
+			return nil, fmt.Errorf("substitution %d has nothing to find", i+1)
+		}
+		settings.compiled = append(settings.compiled, substitution.pattern())
+	}
+
+	return &settings, nil
+}
+
+// ReadRaw returns the settings file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the settings file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// UseEllipses reports whether ... is replaced with …
+func (s *Settings) UseEllipses() bool {
+	return s.Ellipses == nil || *s.Ellipses
+}
+
+// Apply makes the substitutions in a piece of HTML text, which holds no tags
+func (s *Settings) Apply(text string) string {
+	for i, pattern := range s.compiled {
+		text = pattern.ReplaceAllLiteralString(text, html.EscapeString(s.Substitutions[i].Replace))
+	}
+	return text
+}
+
+// HasSubstitutions reports whether there is any text to replace
+func (s *Settings) HasSubstitutions() bool {
+	return len(s.compiled) > 0
+}
+
+// pattern returns the regular expression matching the text to find, as it is
+// written in HTML
+func (s Substitution) pattern() *regexp.Regexp {
+	expr := regexp.QuoteMeta(html.EscapeString(s.Find))
+	if s.Word {
+		if isWordChar(s.Find[0]) {
+			expr = `\b` + expr
+		}
+		if isWordChar(s.Find[len(s.Find)-1]) {
+			expr += `\b`
+		}
+	}
+	if s.IgnoreCase {
+		expr = `(?i)` + expr
+	}
+	return regexp.MustCompile(expr)
+}
+
+func isWordChar(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,354 @@
+// Package userdata collects and erases what the wiki stores about a user, to
+// answer data export and account deletion requests. Erasing keeps the history
+// of pages intact: revisions, uploads and comments are credited to DeletedUser
+// instead of being removed.
+package userdata
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/ai"
+	"wiki-go/internal/announcements"
+	"wiki-go/internal/calendar"
+	"wiki-go/internal/comments"
+	"wiki-go/internal/config"
+	"wiki-go/internal/dashboard"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/favorites"
+	"wiki-go/internal/notifications"
+	"wiki-go/internal/ownership"
+	"wiki-go/internal/pagestats"
+	"wiki-go/internal/permissions"
+	"wiki-go/internal/preferences"
+	"wiki-go/internal/shares"
+	"wiki-go/internal/watch"
+	"wiki-go/internal/webauthn"
+)
+
+// DeletedUser is the name revisions, uploads and comments of deleted accounts
+// are credited to
+const DeletedUser = "deleted user"
+
+// Report counts the data of a user found or changed
+type Report struct {
+	Revisions  int      `json:"revisions"`
+	Uploads    int      `json:"uploads"`
+	Comments   int      `json:"comments"`
+	Shares     int      `json:"shares"`
+	AIRequests int      `json:"aiRequests"`
+	Warnings   []string `json:"warnings,omitempty"` // Settings still naming the user, to be edited by an admin
+}
+
+// profile is the account of a user as exported
+type profile struct {
+	Username string    `json:"username"`
+	Role     string    `json:"role,omitempty"`
+	Exported time.Time `json:"exported"`
+}
+
+// passkey is a passkey as exported, without its key
+type passkey struct {
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// revision is an edit as exported
+type revision struct {
+	Page    string    `json:"page"`
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary,omitempty"`
+}
+
+// upload is an uploaded attachment as exported
+type upload struct {
+	Page     string    `json:"page"`
+	Time     time.Time `json:"time"`
+	File     string    `json:"file"`
+	Archived string    `json:"archived,omitempty"` // Location in the archive, when the file still exists
+}
+
+// comment is a comment as exported
+type comment struct {
+	Page    string    `json:"page"`
+	Time    time.Time `json:"time"`
+	Content string    `json:"content"`
+}
+
+// Export writes a zip archive with the data of username to out: the account,
+// preferences, notifications, watched and favorite pages, dismissed
+// announcements, dashboard and passkeys, the revisions they made, the files they uploaded, their comments
+// and the share links they created.
+func Export(cfg *config.Config, username string, out io.Writer) (Report, error) {
+	var report Report
+	archive := zip.NewWriter(out)
+	add := func(name string, v interface{}) error {
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	account := profile{Username: username, Role: permissions.UserRole(cfg, username), Exported: time.Now()}
+	if err := add("account.json", account); err != nil {
+		return report, err
+	}
+
+	prefs, err := preferences.Load(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	if err := add("preferences.json", prefs); err != nil {
+		return report, err
+	}
+
+	list, err := notifications.List(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	if err := add("notifications.json", list); err != nil {
+		return report, err
+	}
+
+	watched, err := watch.Pages(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	if err := add("watched-pages.json", watched); err != nil {
+		return report, err
+	}
+
+	starred, err := favorites.Pages(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	if err := add("favorites.json", starred); err != nil {
+		return report, err
+	}
+
+	dismissed, err := announcements.Dismissed(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	if err := add("dismissed-announcements.json", dismissed); err != nil {
+		return report, err
+	}
+
+	if layout, found, err := dashboard.LoadUser(cfg.Wiki.RootDir, username); err != nil {
+		return report, err
+	} else if found {
+		if err := add("dashboard.json", layout); err != nil {
+			return report, err
+		}
+	}
+
+	credentials, err := webauthn.List(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	keys := []passkey{}
+	for _, credential := range credentials {
+		keys = append(keys, passkey{Name: credential.Name, CreatedAt: credential.CreatedAt, LastUsedAt: credential.LastUsedAt})
+	}
+	if err := add("passkeys.json", keys); err != nil {
+		return report, err
+	}
+
+	activity, err := pagestats.UserActivity(cfg.Wiki.RootDir, username)
+	if err != nil {
+		return report, err
+	}
+	revisions, uploads := []revision{}, []upload{}
+	archived := make(map[string]bool)
+	for _, a := range activity {
+		page := pagePath(a.Path)
+		for _, edit := range a.Edits {
+			revisions = append(revisions, revision{Page: page, Time: edit.Time, Summary: edit.Summary})
+		}
+		for _, u := range a.Uploads {
+			entry := upload{Page: page, Time: u.Time, File: u.File}
+			name := "uploads/" + strings.TrimPrefix(page+"/", "/") + filepath.Base(u.File)
+			if archived[name] {
+				// Uploaded again, the archive holds the current file once
+				entry.Archived = name
+			} else if data, err := encryption.ReadFile(filepath.Join(documentDir(cfg, a.Path), filepath.Base(u.File))); err == nil {
+				entry.Archived = name
+				archived[name] = true
+				w, err := archive.Create(name)
+				if err != nil {
+					return report, err
+				}
+				if _, err := w.Write(data); err != nil {
+					return report, err
+				}
+			}
+			uploads = append(uploads, entry)
+		}
+	}
+	report.Revisions, report.Uploads = len(revisions), len(uploads)
+	if err := add("revisions.json", revisions); err != nil {
+		return report, err
+	}
+	if err := add("uploads.json", uploads); err != nil {
+		return report, err
+	}
+
+	authored, err := comments.ByAuthor(username)
+	if err != nil {
+		return report, err
+	}
+	written := []comment{}
+	for _, c := range authored {
+		written = append(written, comment{Page: "/" + c.DocumentPath, Time: time.Unix(c.TimestampUnix, 0).UTC(), Content: c.Content})
+	}
+	report.Comments = len(written)
+	if err := add("comments.json", written); err != nil {
+		return report, err
+	}
+
+	all, err := shares.List(cfg.Wiki.RootDir, nil)
+	if err != nil {
+		return report, err
+	}
+	created := []shares.Share{}
+	for _, share := range all {
+		if share.CreatedBy == username {
+			share.PasswordHash = ""
+			created = append(created, share)
+		}
+	}
+	report.Shares = len(created)
+	if err := add("shares.json", created); err != nil {
+		return report, err
+	}
+
+	requests, err := ai.Recent(cfg.Wiki.RootDir, username, 0)
+	if err != nil {
+		return report, err
+	}
+	report.AIRequests = len(requests)
+	if err := add("ai-requests.json", requests); err != nil {
+		return report, err
+	}
+
+	return report, archive.Close()
+}
+
+// Erase removes the personal data of username: preferences, notifications,
+// watched and favorite pages, dismissed announcements, dashboard and passkeys
+// are deleted, revisions, uploads, share links and logged AI requests are
+// credited to DeletedUser. Comments are credited to DeletedUser as well, or
+// deleted when deleteComments is set. The account itself and its sessions are
+// left to the caller.
+func Erase(cfg *config.Config, username string, deleteComments bool) (Report, error) {
+	var report Report
+	rootDir := cfg.Wiki.RootDir
+
+	if err := preferences.Delete(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := notifications.Delete(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := watch.Delete(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := favorites.Delete(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := announcements.DeleteUser(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := dashboard.DeleteUser(rootDir, username); err != nil {
+		return report, err
+	}
+	if err := webauthn.RemoveAll(rootDir, username); err != nil {
+		return report, err
+	}
+	if _, err := calendar.RevokeTokens(rootDir, username); err != nil {
+		return report, err
+	}
+
+	var err error
+	if report.Revisions, report.Uploads, err = pagestats.Reattribute(rootDir, username, DeletedUser); err != nil {
+		return report, err
+	}
+	to := DeletedUser
+	if deleteComments {
+		to = ""
+	}
+	if report.Comments, err = comments.Reattribute(username, to); err != nil {
+		return report, err
+	}
+	if report.Shares, err = shares.Reattribute(rootDir, username, DeletedUser); err != nil {
+		return report, err
+	}
+	if report.AIRequests, err = ai.Reattribute(rootDir, username, DeletedUser); err != nil {
+		return report, err
+	}
+
+	report.Warnings = references(rootDir, username)
+	return report, nil
+}
+
+// references lists the permission and ownership rules naming username, which
+// would apply to a new account of the same name
+func references(rootDir, username string) []string {
+	var warnings []string
+	if file, err := permissions.Load(rootDir); err == nil {
+		for _, rule := range file.Rules {
+			if contains(rule.View, username) || contains(rule.Edit, username) {
+				warnings = append(warnings, "permissions of /"+strings.Trim(rule.Path, "/"))
+			}
+		}
+	}
+	if file, err := ownership.Load(rootDir); err == nil {
+		for name, members := range file.Groups {
+			if contains(members, username) {
+				warnings = append(warnings, "owner group @"+name)
+			}
+		}
+		for _, rule := range file.Rules {
+			if contains(rule.Owners, username) {
+				warnings = append(warnings, "owners of "+rule.Path)
+			}
+		}
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pagePath turns a versioning path such as "documents/guide/intro" into the
+// path of the page, "/" for the homepage
+func pagePath(relativePath string) string {
+	if relativePath == "pages/home" {
+		return "/"
+	}
+	return "/" + strings.TrimPrefix(relativePath, "documents/")
+}
+
+// documentDir returns the directory of the page with a versioning path
+func documentDir(cfg *config.Config, relativePath string) string {
+	if strings.HasPrefix(relativePath, "pages/") {
+		return filepath.Join(cfg.Wiki.RootDir, filepath.FromSlash(relativePath))
+	}
+	return filepath.Join(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir, filepath.FromSlash(strings.TrimPrefix(relativePath, "documents/")))
+}
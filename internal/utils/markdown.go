@@ -2,11 +2,17 @@ package utils
 
 import (
 	"bytes"
-	"os"
+	"log"
 	"path/filepath"
 	"strings"
+	"sync"
+	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
 	"wiki-go/internal/frontmatter"
 	"wiki-go/internal/goldext"
+	"wiki-go/internal/markup"
+	"wiki-go/internal/sanitize"
+	"wiki-go/internal/typography"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
@@ -17,7 +23,7 @@ import (
 // RenderMarkdownFile reads a markdown file and returns its HTML representation
 func RenderMarkdownFile(filePath string) ([]byte, error) {
 	// Read the markdown file
-	mdContent, err := os.ReadFile(filePath)
+	mdContent, err := encryption.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -44,8 +50,70 @@ func RenderMarkdown(md string) []byte {
 	return RenderMarkdownWithPath(md, "")
 }
 
-// RenderMarkdownWithPath converts markdown text to HTML with the current document path
+// RenderMarkdownWithPath converts markdown text to HTML with the current document path.
+// Unless editors are trusted with raw HTML, the result is sanitized. Pages
+// converted from AsciiDoc or reStructuredText always are.
 func RenderMarkdownWithPath(md string, docPath string) []byte {
+	rendered := string(renderMarkdownHTML(md, docPath))
+	if config.Cfg == nil || !config.Cfg.Security.Sanitizer.TrustedEditors || isMarkupPage(md) {
+		rendered = sanitizePolicy().Sanitize(rendered)
+	}
+	return []byte(goldext.RestoreForms(rendered))
+}
+
+// isMarkupPage reports whether a page is written in AsciiDoc or
+// reStructuredText. Their passthrough blocks and links come from wherever the
+// page was imported from, which editors may not have written or reviewed.
+func isMarkupPage(md string) bool {
+	metadata, _, hasFrontmatter := frontmatter.Parse(md)
+	return hasFrontmatter && markup.Format(metadata.Format) != ""
+}
+
+// RenderUntrustedMarkdown converts markdown written by any visitor, such as
+// comments, to HTML. It is always sanitized, even when editors are trusted.
+func RenderUntrustedMarkdown(md string) []byte {
+	return []byte(goldext.RestoreForms(sanitizePolicy().Sanitize(string(renderMarkdownHTML(md, "")))))
+}
+
+// policyCache holds the sanitizer policy built from the configured allowlist
+// and frame sources
+var policyCache struct {
+	sync.Mutex
+	spec    string
+	sources string
+	policy  *sanitize.Policy
+}
+
+// sanitizePolicy returns the sanitizer policy with the elements allowed in the
+// configuration, and frames of the sources the security headers allow. An
+// invalid allowlist is logged and ignored.
+func sanitizePolicy() *sanitize.Policy {
+	spec, sources := "", ""
+	if config.Cfg != nil {
+		spec = config.Cfg.Security.Sanitizer.AllowElements
+		sources = config.Cfg.Security.Headers.FrameSources
+	}
+
+	policyCache.Lock()
+	defer policyCache.Unlock()
+
+	if policyCache.policy != nil && policyCache.spec == spec && policyCache.sources == sources {
+		return policyCache.policy
+	}
+	policy := sanitize.DefaultPolicy()
+	if err := policy.AllowElements(spec); err != nil {
+		log.Printf("Sanitizer: ignoring allowed elements: %v", err)
+		policy = sanitize.DefaultPolicy()
+	}
+	policy.AllowFrameSources(sources)
+	policyCache.spec = spec
+	policyCache.sources = sources
+	policyCache.policy = policy
+	return policy
+}
+
+// renderMarkdownHTML converts markdown text to HTML, including the raw HTML it contains
+func renderMarkdownHTML(md string, docPath string) []byte {
 	// Check for frontmatter
 	metadata, contentWithoutFrontmatter, hasFrontmatter := frontmatter.Parse(md)
 
@@ -55,18 +123,10 @@ func RenderMarkdownWithPath(md string, docPath string) []byte {
 		var preprocessors []frontmatter.PreprocessorFunc
 		var postProcessors []frontmatter.PostProcessorFunc
 
-		// Add all goldext preprocessors (frontmatter will be a no-op since it's already processed)
-		for _, preprocessor := range goldext.RegisteredPreprocessors {
-			if preprocessor != nil {
-				// Create a closure that captures the docPath for kanban rendering
-				capturedPreprocessor := preprocessor
-				capturedDocPath := docPath
-				wrappedPreprocessor := func(md string, _ string) string {
-					return capturedPreprocessor(md, capturedDocPath)
-				}
-				preprocessors = append(preprocessors, wrappedPreprocessor)
-			}
-		}
+		// Add all goldext preprocessors the directory uses (frontmatter will be a no-op since it's already processed)
+		preprocessors = append(preprocessors, func(md string, _ string) string {
+			return goldext.ProcessMarkdown(md, docPath)
+		})
 
 		// Add post-processors for mermaid and direction blocks
 		postProcessors = append(postProcessors, func(html string) string {
@@ -96,6 +156,24 @@ func RenderMarkdownWithPath(md string, docPath string) []byte {
 		md = contentWithoutFrontmatter
 	}
 
+	// Pages written in AsciiDoc or reStructuredText keep their source as it
+	// was written, and are rendered as the markdown made of it
+	if format := markup.Format(metadata.Format); hasFrontmatter && format != "" {
+		md = markupToMarkdown(format, md)
+	}
+
+	// Number the headings of pages that ask for it, or of the directories that
+	// do, before the table of contents is built
+	settings := goldext.DirectorySettings(docPath)
+	numbering := settings.Numbering != nil && *settings.Numbering
+	if hasFrontmatter && metadata.Numbering != nil {
+		numbering = *metadata.Numbering
+	}
+	if numbering {
+		md = goldext.NumberHeadings(md)
+	}
+	typography := settings.Enabled("typography", true)
+
 	// Apply any custom extensions via pre-processing
 	md = goldext.ProcessMarkdown(md, docPath)
 
@@ -110,6 +188,8 @@ func RenderMarkdownWithPath(md string, docPath string) []byte {
 			extension.Footnote,       // Enable footnotes
 			extension.DefinitionList, // Enable definition lists
 			extension.GFM,            // GitHub Flavored Markdown
+			goldext.Bidi,             // Per-block text direction
+			typographer(typography),  // Smart quotes, dashes and ellipses
 			// MathJax is now handled via client-side JavaScript
 		),
 		// Parser options
@@ -134,8 +214,21 @@ func RenderMarkdownWithPath(md string, docPath string) []byte {
 		return errMsg
 	}
 
+	htmlResult := buf.String()
+
+	// Post-process: Make the admin's text substitutions, outside of code
+	if typography {
+		htmlResult = goldext.ApplySubstitutions(htmlResult)
+	}
+
+	// Post-process: Highlight glossary terms unless the page opted out.
+	// This runs before the placeholders are restored so diagram sources are left untouched.
+	if !(hasFrontmatter && metadata.Glossary != nil && !*metadata.Glossary) {
+		htmlResult = goldext.ApplyGlossary(htmlResult, docPath)
+	}
+
 	// Post-process: Restore Mermaid blocks that were replaced with placeholders
-	htmlResult := goldext.RestoreMermaidBlocks(buf.String())
+	htmlResult = goldext.RestoreMermaidBlocks(htmlResult)
 
 	// Post-process: Restore PlantUML blocks that were replaced with placeholders
 	htmlResult = goldext.RestorePlantUMLBlocks(htmlResult)
@@ -147,3 +240,46 @@ func RenderMarkdownWithPath(md string, docPath string) []byte {
 	// Return the post-processed HTML
 	return []byte(htmlResult)
 }
+
+// typographer returns the Goldmark typographer set up with the smart quotes,
+// dashes and ellipses enabled in the typography settings, or with none of them
+// on pages whose directory turned typography off. Code is never changed.
+func typographer(enabled bool) goldmark.Extender {
+	settings, err := typography.Load(config.Cfg.Wiki.RootDir)
+	if err != nil {
+		log.Printf("Error loading typography settings: %v", err)
+		settings = &typography.Settings{}
+	}
+	if !enabled {
+		settings = &typography.Settings{Ellipses: &enabled}
+	}
+
+	// Substitutions set to nil are turned off
+	substitutions := map[extension.TypographicPunctuation][]byte{
+		extension.LeftSingleQuote:  nil,
+		extension.RightSingleQuote: nil,
+		extension.LeftDoubleQuote:  nil,
+		extension.RightDoubleQuote: nil,
+		extension.Apostrophe:       nil,
+		extension.EnDash:           nil,
+		extension.EmDash:           nil,
+		extension.Ellipsis:         nil,
+		extension.LeftAngleQuote:   nil,
+		extension.RightAngleQuote:  nil,
+	}
+	if settings.SmartQuotes {
+		substitutions[extension.LeftSingleQuote] = []byte("&lsquo;")
+		substitutions[extension.RightSingleQuote] = []byte("&rsquo;")
+		substitutions[extension.LeftDoubleQuote] = []byte("&ldquo;")
+		substitutions[extension.RightDoubleQuote] = []byte("&rdquo;")
+		substitutions[extension.Apostrophe] = []byte("&rsquo;")
+	}
+	if settings.Dashes {
+		substitutions[extension.EnDash] = []byte("&ndash;")
+		substitutions[extension.EmDash] = []byte("&mdash;")
+	}
+	if settings.UseEllipses() {
+		substitutions[extension.Ellipsis] = []byte("&hellip;")
+	}
+	return extension.NewTypographer(extension.WithTypographicSubstitutions(substitutions))
+}
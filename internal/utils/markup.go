@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"log"
+	"sync"
+	"time"
+
+	"wiki-go/internal/config"
+	"wiki-go/internal/markup"
+	"wiki-go/internal/pandoc"
+)
+
+// maxMarkupCache is the number of pandoc conversions kept before the cache is
+// emptied
+const maxMarkupCache = 256
+
+// markupCache holds the markdown pandoc made of pages, by a hash of their
+// format and source, so pandoc only runs again once a page is edited
+var markupCache struct {
+	sync.Mutex
+	entries map[[sha256.Size]byte]string
+}
+
+// markupToMarkdown converts a page written in AsciiDoc or reStructuredText to
+// markdown, with pandoc when it is enabled and the built-in converters otherwise
+func markupToMarkdown(format, source string) string {
+	if config.Cfg == nil || !config.Cfg.Extensions.Pandoc.Enable {
+		return markup.ToMarkdown(format, source)
+	}
+
+	key := sha256.Sum256([]byte(format + "\x00" + source))
+	markupCache.Lock()
+	md, ok := markupCache.entries[key]
+	markupCache.Unlock()
+	if ok {
+		return md
+	}
+
+	timeout := config.Cfg.Extensions.Pandoc.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 60
+	}
+	converter := pandoc.Converter{
+		Command:   config.Cfg.Extensions.Pandoc.Command,
+		ServerURL: config.Cfg.Extensions.Pandoc.ServerURL,
+		Timeout:   time.Duration(timeout) * time.Second,
+	}
+	md, _, err := converter.ToMarkdown("page"+markup.Extension(format), []byte(source))
+	if err != nil {
+		log.Printf("Pandoc: rendering a %s page with the built-in converter: %v", format, err)
+		return markup.ToMarkdown(format, source)
+	}
+
+	markupCache.Lock()
+	if markupCache.entries == nil || len(markupCache.entries) >= maxMarkupCache {
+		markupCache.entries = make(map[[sha256.Size]byte]string)
+	}
+	markupCache.entries[key] = md
+	markupCache.Unlock()
+
+	return md
+}
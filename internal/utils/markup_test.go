@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"wiki-go/internal/config"
+)
+
+func TestMarkupPagesAreSanitized(t *testing.T) {
+	previous := config.Cfg
+	config.Cfg = &config.Config{}
+	config.Cfg.Wiki.RootDir = t.TempDir()
+	// Even editors trusted with raw HTML don't vouch for what came with an import
+	config.Cfg.Security.Sanitizer.TrustedEditors = true
+	t.Cleanup(func() { config.Cfg = previous })
+
+	tests := []struct {
+		name string
+		page string
+		kept string
+	}{
+		{
+			name: "AsciiDoc passthrough",
+			page: "---\nformat: asciidoc\n---\n++++\n<b>bold</b><script>alert(1)</script><img src=\"a.png\" onerror=\"alert(1)\">\n++++",
+			kept: "<b>bold</b>",
+		},
+		{
+			name: "AsciiDoc link",
+			page: "---\nformat: asciidoc\n---\nlink:javascript:alert(1)[Click] and link:https://example.com[Example]",
+			kept: `href="https://example.com"`,
+		},
+		{
+			name: "reStructuredText raw HTML",
+			page: "---\nformat: rst\n---\n.. raw:: html\n\n   <b>bold</b><script>alert(1)</script><img src=\"a.png\" onerror=\"alert(1)\">",
+			kept: "<b>bold</b>",
+		},
+		{
+			name: "reStructuredText link",
+			page: "---\nformat: rst\n---\n`Click <javascript:alert(1)>`_ and `Example <https://example.com>`_",
+			kept: `href="https://example.com"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := string(RenderMarkdownWithPath(tt.page, ""))
+			for _, unsafe := range []string{"<script", "onerror", `href="javascript:`, `src="javascript:`} {
+				if strings.Contains(html, unsafe) {
+					t.Errorf("rendered page contains %q: %s", unsafe, html)
+				}
+			}
+			if !strings.Contains(html, tt.kept) {
+				t.Errorf("rendered page lost %q: %s", tt.kept, html)
+			}
+		})
+	}
+
+	// Markdown pages are rendered as the trusted editors wrote them
+	if html := string(RenderMarkdownWithPath("<span onclick=\"go()\">x</span>", "")); !strings.Contains(html, "onclick") {
+		t.Errorf("markdown page was sanitized with trusted editors: %s", html)
+	}
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"wiki-go/internal/diagnostics"
+	"wiki-go/internal/store"
+	"wiki-go/internal/types"
+)
+
+// navCacheTTL is how long the cached tree is used before it's rebuilt, so pages
+// added outside the wiki (e.g. by git) show up without a restart. It only applies
+// when the documents directory isn't watched.
+const navCacheTTL = time.Minute
+
+// navigationName names the generation of the documents, when several instances
+// share the data directory
+const navigationName = "navigation"
+
+var navCache struct {
+	sync.Mutex
+	root    *types.NavItem
+	dir     string
+	builtAt time.Time
+
+	// Generation of the documents the tree was built at, when they are shared
+	generation int64
+
+	// Set while WatchNavigation keeps the tree up to date
+	watcher *navWatcher
+}
+
+func init() {
+	diagnostics.Register("navigation", navigationUsage)
+}
+
+// navigationUsage returns the number of items in the cached tree and their size
+func navigationUsage() (int, int64) {
+	navCache.Lock()
+	root := navCache.root
+	navCache.Unlock()
+
+	items, size := 0, int64(0)
+	var count func(item *types.NavItem)
+	count = func(item *types.NavItem) {
+		items++
+		size += int64(unsafe.Sizeof(*item)) + int64(len(item.Title)+len(item.Path)+8*cap(item.Children))
+		for _, child := range item.Children {
+			count(child)
+		}
+	}
+	if root != nil {
+		count(root)
+	}
+	return items, size
+}
+
+// CachedNavigation returns the full navigation tree, building it only when it was
+// invalidated or has expired. The tree is shared, callers must not modify it.
+func CachedNavigation(rootDir string, documentsDir string) (*types.NavItem, error) {
+	dir := filepath.Join(rootDir, documentsDir)
+
+	// Holding the lock while building means concurrent requests wait for one walk
+	// of the documents instead of each doing their own
+	navCache.Lock()
+	defer navCache.Unlock()
+	if w := navCache.watcher; w != nil && w.docsPath == dir {
+		// Changes still waiting for the debounce are applied right away
+		w.apply()
+		if navCache.root != nil {
+			return navCache.root, nil
+		}
+	} else if navCache.root != nil && navCache.dir == dir && time.Since(navCache.builtAt) < navCacheTTL {
+		return navCache.root, nil
+	}
+
+	root, err := BuildNavigation(rootDir, documentsDir)
+	if err != nil {
+		return nil, err
+	}
+	navCache.root = root
+	navCache.dir = dir
+	navCache.builtAt = time.Now()
+	return root, nil
+}
+
+// InvalidateNavigation drops the cached tree, the next request rebuilds it. While
+// the documents directory is watched the tree is kept, the watcher sees the change.
+// Instances sharing the data directory aren't notified of changes made by each
+// other on network volumes, so they are told to rebuild their tree.
+func InvalidateNavigation() {
+	navCache.Lock()
+	if navCache.watcher == nil {
+		navCache.root = nil
+	}
+	navCache.Unlock()
+
+	if !store.Shared() {
+		return
+	}
+	g, err := store.Current.Bump(navigationName)
+	if err != nil {
+		log.Printf("Warning: failed to announce the navigation change: %v", err)
+		return
+	}
+	navCache.Lock()
+	if g != navCache.generation+1 {
+		// Another instance changed documents too
+		navCache.root = nil
+	}
+	navCache.generation = g
+	navCache.Unlock()
+}
+
+// RefreshNavigation drops the cached tree when another instance changed documents
+// since it was built, and reports whether it did
+func RefreshNavigation() bool {
+	if !store.Shared() {
+		return false
+	}
+	g, err := store.Current.Generation(navigationName)
+	if err != nil {
+		log.Printf("Warning: failed to read the navigation generation: %v", err)
+		return false
+	}
+	navCache.Lock()
+	defer navCache.Unlock()
+	if g == navCache.generation {
+		return false
+	}
+	navCache.root = nil
+	navCache.generation = g
+	return true
+}
+
+// NavigationFor returns a copy of the tree holding what the sidebar shows for
+// currentPath: the top level and the children of every directory on the way to
+// the current page. Other directories are collapsed, their children are loaded
+// on demand. Items for which allowed returns false are left out, a nil allowed
+// keeps everything.
+func NavigationFor(root *types.NavItem, currentPath string, allowed func(path string) bool) *types.NavItem {
+	if root == nil {
+		return nil
+	}
+
+	currentPath = strings.TrimSuffix(currentPath, "/")
+	if currentPath == "" {
+		currentPath = "/"
+	}
+
+	var prune func(item *types.NavItem) *types.NavItem
+	prune = func(item *types.NavItem) *types.NavItem {
+		copied := *item
+		copied.Children = nil
+		copied.IsActive = isOnPath(item.Path, currentPath)
+
+		children := NavChildren(item, allowed)
+		copied.HasChildren = len(children) > 0
+		if item == root || copied.IsActive {
+			copied.Children = make([]*types.NavItem, 0, len(children))
+			for _, child := range children {
+				copied.Children = append(copied.Children, prune(child))
+			}
+		}
+		return &copied
+	}
+	return prune(root)
+}
+
+// NavChildren returns the children of item that allowed accepts
+func NavChildren(item *types.NavItem, allowed func(path string) bool) []*types.NavItem {
+	if allowed == nil {
+		return item.Children
+	}
+	children := make([]*types.NavItem, 0, len(item.Children))
+	for _, child := range item.Children {
+		if allowed(child.Path) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// isOnPath reports whether itemPath is currentPath or one of its parents
+func isOnPath(itemPath, currentPath string) bool {
+	if itemPath == "/" || itemPath == currentPath {
+		return true
+	}
+	return strings.HasPrefix(currentPath, itemPath+"/")
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"wiki-go/internal/encryption"
 	"wiki-go/internal/goldext"
 	"wiki-go/internal/types"
 
@@ -25,12 +26,11 @@ type NavItem struct {
 // GetDocumentTitle extracts the first H1 title from document.md
 func GetDocumentTitle(dirPath string) string {
 	docPath := filepath.Join(dirPath, "document.md")
-	file, err := os.Open(docPath)
+	file, err := encryption.Open(docPath)
 	if err != nil {
 		// If no document.md or can't read it, use directory name
 		return FormatDirName(filepath.Base(dirPath))
 	}
-	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
@@ -83,7 +83,14 @@ func BuildNavigation(rootDir string, documentsDir string) (*types.NavItem, error
 		}
 	}
 
-	err := filepath.Walk(docsPath, func(path string, info os.FileInfo, err error) error {
+	err := walkNavigation(root, rootDir, docsPath, docsPath)
+	return root, err
+}
+
+// walkNavigation adds the directories below start, and start itself unless it is
+// the documents directory, to the tree under root
+func walkNavigation(root *types.NavItem, rootDir, docsPath, start string) error {
+	return filepath.Walk(start, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -151,8 +158,6 @@ func BuildNavigation(rootDir string, documentsDir string) (*types.NavItem, error
 
 		return nil
 	})
-
-	return root, err
 }
 
 // FindNavItem finds a navigation item by its path
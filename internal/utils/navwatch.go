@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wiki-go/internal/types"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// navDebounce is how long the watcher waits for more changes before updating the
+// tree, so a git pull touching many pages updates it once
+const navDebounce = 250 * time.Millisecond
+
+// navMaxPending is the number of changed directories above which the whole tree
+// is rebuilt instead of updating the directories one by one
+const navMaxPending = 1000
+
+// navWatcher keeps the cached navigation tree up to date with the documents
+// directory. Its fields are guarded by the navCache lock.
+type navWatcher struct {
+	watcher  *fsnotify.Watcher
+	rootDir  string
+	docsPath string
+
+	dirs    map[string]bool // Directories whose subtree is read again
+	titles  map[string]bool // Directories whose document.md changed
+	rescan  bool            // Set when the whole tree has to be rebuilt
+	pending bool
+}
+
+// WatchNavigation watches the documents directory and updates the cached
+// navigation tree as directories and titles change, including changes made
+// outside the wiki. Without it the tree is rebuilt after every change.
+func WatchNavigation(rootDir string, documentsDir string) error {
+	docsPath := filepath.Join(rootDir, documentsDir)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	w := &navWatcher{
+		watcher:  watcher,
+		rootDir:  rootDir,
+		docsPath: docsPath,
+		dirs:     make(map[string]bool),
+		titles:   make(map[string]bool),
+	}
+	if err := w.watchTree(docsPath); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	navCache.Lock()
+	navCache.watcher = w
+	navCache.root = nil
+	navCache.Unlock()
+
+	go w.run()
+	return nil
+}
+
+// watchTree adds a watch on dir and every directory below it
+func (w *navWatcher) watchTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Directories removed while walking are picked up by their own events
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+// run collects events and applies them once no new ones arrived for navDebounce
+func (w *navWatcher) run() {
+	timer := time.NewTimer(navDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.record(event)
+			timer.Reset(navDebounce)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been lost, e.g. when the event queue overflowed
+			log.Printf("Warning: watching documents failed, rebuilding navigation: %v", err)
+			navCache.Lock()
+			w.rescan = true
+			w.pending = true
+			navCache.Unlock()
+			timer.Reset(navDebounce)
+
+		case <-timer.C:
+			navCache.Lock()
+			w.apply()
+			navCache.Unlock()
+		}
+	}
+}
+
+// record notes the directory an event changes
+func (w *navWatcher) record(event fsnotify.Event) {
+	rel, err := filepath.Rel(w.docsPath, event.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return
+		}
+	}
+
+	if filepath.Base(rel) == "document.md" {
+		dir := filepath.ToSlash(filepath.Dir(rel))
+		if dir == "." || !event.Has(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) {
+			return
+		}
+		navCache.Lock()
+		w.titles[dir] = true
+		w.pending = true
+		navCache.Unlock()
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(event.Name)
+		if err != nil || !info.IsDir() {
+			return
+		}
+		// Directories created inside it before the watch was added send no events,
+		// they are found when its subtree is read
+		if err := w.watchTree(event.Name); err != nil {
+			log.Printf("Warning: failed to watch %s: %v", event.Name, err)
+		}
+	case event.Has(fsnotify.Remove | fsnotify.Rename):
+		// A renamed directory is created again under its new name
+		w.watcher.Remove(event.Name)
+	default:
+		return
+	}
+
+	navCache.Lock()
+	w.dirs[rel] = true
+	w.pending = true
+	if len(w.dirs) > navMaxPending {
+		w.rescan = true
+	}
+	navCache.Unlock()
+}
+
+// apply updates the cached tree with the recorded changes. The caller holds the
+// navCache lock.
+func (w *navWatcher) apply() {
+	if !w.pending {
+		return
+	}
+	dirs, titles, rescan := w.dirs, w.titles, w.rescan
+	w.dirs = make(map[string]bool)
+	w.titles = make(map[string]bool)
+	w.rescan = false
+	w.pending = false
+
+	if rescan {
+		if err := w.watchTree(w.docsPath); err != nil {
+			log.Printf("Warning: failed to watch documents: %v", err)
+		}
+		navCache.root = nil
+		return
+	}
+	root := navCache.root
+	if root == nil {
+		// Nothing cached yet, the next request builds the tree
+		return
+	}
+
+	// Parents first, so subtrees already read with their parent are skipped
+	changed := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		changed = append(changed, dir)
+	}
+	sort.Strings(changed)
+	var done []string
+	for _, dir := range changed {
+		if coveredBy(dir, done) {
+			continue
+		}
+		root = w.refreshDir(root, dir)
+		done = append(done, dir)
+	}
+	for dir := range titles {
+		if coveredBy(dir, done) || FindNavItem(root, "/"+ToURLPath(dir)) == nil {
+			continue
+		}
+		root = updateNavItem(root, w.docsPath, dir, func(item *types.NavItem) *types.NavItem {
+			updated := *item
+			updated.Title = GetDocumentTitle(filepath.Join(w.docsPath, filepath.FromSlash(dir)))
+			return &updated
+		})
+	}
+	navCache.root = root
+}
+
+// refreshDir reads the subtree of dir again, or removes it when it's gone
+func (w *navWatcher) refreshDir(root *types.NavItem, dir string) *types.NavItem {
+	fsPath := filepath.Join(w.docsPath, filepath.FromSlash(dir))
+	info, err := os.Stat(fsPath)
+	exists := err == nil && info.IsDir()
+	if !exists && FindNavItem(root, "/"+ToURLPath(dir)) == nil {
+		// A file or a directory that was never in the tree
+		return root
+	}
+
+	var subtree *types.NavItem
+	if exists {
+		scratch := &types.NavItem{Path: "/", IsDir: true}
+		if err := walkNavigation(scratch, w.rootDir, w.docsPath, fsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: failed to read %s: %v", fsPath, err)
+		}
+		subtree = FindNavItem(scratch, "/"+ToURLPath(dir))
+	}
+	return updateNavItem(root, w.docsPath, dir, func(*types.NavItem) *types.NavItem { return subtree })
+}
+
+// updateNavItem returns a copy of root in which the item of the directory dir is
+// replaced by what update returns, or removed when it returns nil. Only the items
+// on the way to dir are copied, so readers of the old tree are not affected.
+func updateNavItem(root *types.NavItem, docsPath, dir string, update func(item *types.NavItem) *types.NavItem) *types.NavItem {
+	parts := strings.Split(dir, "/")
+
+	var walk func(item *types.NavItem, depth int) *types.NavItem
+	walk = func(item *types.NavItem, depth int) *types.NavItem {
+		urlPath := "/" + ToURLPath(strings.Join(parts[:depth+1], "/"))
+		index := sort.Search(len(item.Children), func(i int) bool {
+			return item.Children[i].Path >= urlPath
+		})
+		var child *types.NavItem
+		if index < len(item.Children) && item.Children[index].Path == urlPath {
+			child = item.Children[index]
+		} else {
+			// Children are usually in walk order, fall back to a scan
+			index = -1
+			for i, c := range item.Children {
+				if c.Path == urlPath {
+					child, index = c, i
+					break
+				}
+			}
+		}
+
+		var updated *types.NavItem
+		if depth == len(parts)-1 {
+			updated = update(child)
+		} else {
+			if child == nil {
+				// A parent the tree doesn't know yet
+				child = &types.NavItem{
+					Title:    GetDocumentTitle(filepath.Join(docsPath, filepath.FromSlash(strings.Join(parts[:depth+1], "/")))),
+					Path:     urlPath,
+					IsDir:    true,
+					Children: make([]*types.NavItem, 0),
+				}
+			}
+			updated = walk(child, depth+1)
+		}
+
+		copied := *item
+		copied.Children = make([]*types.NavItem, 0, len(item.Children)+1)
+		switch {
+		case index >= 0 && updated != nil:
+			copied.Children = append(copied.Children, item.Children...)
+			copied.Children[index] = updated
+		case index >= 0:
+			copied.Children = append(copied.Children, item.Children[:index]...)
+			copied.Children = append(copied.Children, item.Children[index+1:]...)
+		case updated != nil:
+			// Insert in name order, like the directory walk
+			at := sort.Search(len(item.Children), func(i int) bool {
+				return item.Children[i].Path > urlPath
+			})
+			copied.Children = append(copied.Children, item.Children[:at]...)
+			copied.Children = append(copied.Children, updated)
+			copied.Children = append(copied.Children, item.Children[at:]...)
+		default:
+			return item
+		}
+		return &copied
+	}
+	return walk(root, 0)
+}
+
+// coveredBy reports whether dir is one of dirs or below one of them
+func coveredBy(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		if dir == d || strings.HasPrefix(dir, d+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrRenderBusy is returned by AcquireRender when too many renders are running or waiting
+var ErrRenderBusy = errors.New("too many documents are being rendered")
+
+// RenderStats describes the render pool
+type RenderStats struct {
+	Workers       int `json:"workers"`
+	MaxConcurrent int `json:"maxConcurrent"` // 0 when unlimited
+	Running       int `json:"running"`
+	Waiting       int `json:"waiting"`
+}
+
+var renderPool = struct {
+	sync.Mutex
+	slots         chan struct{}
+	maxConcurrent int
+	inFlight      int
+}{slots: make(chan struct{}, runtime.NumCPU())}
+
+// ConfigureRenders sets the number of documents rendered at the same time, one per
+// CPU when workers is 0, and the number of renders that may run or wait before
+// AcquireRender refuses new ones, unlimited when maxConcurrent is 0
+func ConfigureRenders(workers, maxConcurrent int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	renderPool.Lock()
+	defer renderPool.Unlock()
+	renderPool.slots = make(chan struct{}, workers)
+	renderPool.maxConcurrent = maxConcurrent
+}
+
+// AcquireRender waits for a free render worker and returns the function giving
+// it back. It fails right away with ErrRenderBusy when the pool is full.
+func AcquireRender() (release func(), err error) {
+	renderPool.Lock()
+	if renderPool.maxConcurrent > 0 && renderPool.inFlight >= renderPool.maxConcurrent {
+		renderPool.Unlock()
+		return nil, ErrRenderBusy
+	}
+	renderPool.inFlight++
+	slots := renderPool.slots
+	renderPool.Unlock()
+
+	slots <- struct{}{}
+	return func() {
+		<-slots
+		renderPool.Lock()
+		renderPool.inFlight--
+		renderPool.Unlock()
+	}, nil
+}
+
+// RenderPoolStats returns the current state of the render pool
+func RenderPoolStats() RenderStats {
+	renderPool.Lock()
+	defer renderPool.Unlock()
+	stats := RenderStats{
+		Workers:       cap(renderPool.slots),
+		MaxConcurrent: renderPool.maxConcurrent,
+		Running:       len(renderPool.slots),
+	}
+	// Renders started before the pool was reconfigured aren't counted as waiting
+	if waiting := renderPool.inFlight - stats.Running; waiting > 0 {
+		stats.Waiting = waiting
+	}
+	return stats
+}
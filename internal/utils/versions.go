@@ -6,6 +6,9 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
+
+	"wiki-go/internal/encryption"
 )
 
 // CleanupOldVersions removes old versions if the number of versions exceeds maxVersions
@@ -60,3 +63,86 @@ func CleanupOldVersions(versionDir string, maxVersions int) {
 		}
 	}
 }
+
+// SaveVersion stores the current content of docPath as a timestamped version before it
+// is overwritten. relativePath mirrors the document location below the versions
+// directory (e.g. "documents/guide/intro" or "pages/home").
+func SaveVersion(rootDir, relativePath, docPath string, maxVersions int) {
+	if maxVersions <= 0 {
+		return
+	}
+
+	currentContent, err := encryption.ReadFile(docPath)
+	if err != nil || len(currentContent) == 0 {
+		return
+	}
+
+	// Create timestamp for version filename
+	timestamp := time.Now().Format("20060102150405") // Format: yyyymmddhhmmss
+
+	versionDir := filepath.Join(rootDir, "versions", relativePath)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		log.Printf("Error creating versions directory %s: %v", versionDir, err)
+		return
+	}
+
+	versionPath := filepath.Join(versionDir, timestamp+".md")
+	if err := encryption.WriteFile(versionPath, currentContent, 0644); err != nil {
+		log.Printf("Error saving version %s: %v", versionPath, err)
+		return
+	}
+	log.Printf("Created version: %s", versionPath)
+
+	CleanupOldVersions(versionDir, maxVersions)
+}
+
+// AttachmentVersionsDir is the directory below the versions of a document that
+// keeps the earlier versions of its attachments, one directory per file. The
+// dot keeps it apart from the versions of child documents.
+const AttachmentVersionsDir = ".attachments"
+
+// SaveAttachmentVersion stores the current content of the attachment at filePath
+// as a timestamped version before it is overwritten, keeping the newest
+// maxVersions. relativePath is that of the document it is attached to.
+func SaveAttachmentVersion(rootDir, relativePath, filePath string, maxVersions int) {
+	if maxVersions <= 0 {
+		return
+	}
+
+	currentContent, err := encryption.ReadFile(filePath)
+	if err != nil || len(currentContent) == 0 {
+		return
+	}
+
+	name := filepath.Base(filePath)
+	versionDir := filepath.Join(rootDir, "versions", relativePath, AttachmentVersionsDir, name)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		log.Printf("Error creating attachment versions directory %s: %v", versionDir, err)
+		return
+	}
+
+	timestamp := time.Now().Format("20060102150405")
+	versionPath := filepath.Join(versionDir, timestamp+filepath.Ext(name))
+	if err := encryption.WriteFile(versionPath, currentContent, 0644); err != nil {
+		log.Printf("Error saving attachment version %s: %v", versionPath, err)
+		return
+	}
+
+	files, err := os.ReadDir(versionDir)
+	if err != nil {
+		return
+	}
+	var versions []string
+	for _, file := range files {
+		if !file.IsDir() {
+			versions = append(versions, file.Name())
+		}
+	}
+	sort.Strings(versions)
+	for len(versions) > maxVersions {
+		if err := os.Remove(filepath.Join(versionDir, versions[0])); err != nil {
+			log.Printf("Error deleting old attachment version %s: %v", versions[0], err)
+		}
+		versions = versions[1:]
+	}
+}
@@ -0,0 +1,117 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"wiki-go/internal/filecache"
+)
+
+// Registry holds the site-wide variables and the per-space overrides
+type Registry struct {
+	Global map[string]string            `yaml:"global"`
+	Spaces map[string]map[string]string `yaml:"spaces"` // Keyed by document path prefix, e.g. "guides/api"
+}
+
+// NamePattern matches valid variable names
+var NamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// cache avoids re-reading the registry file on every render
+var cache filecache.Cache[*Registry]
+
+// FilePath returns the location of the variables registry
+func FilePath(rootDir string) string {
+	return filepath.Join(rootDir, "variables.yaml")
+}
+
+// Load returns the current registry, an empty one when the file does not exist
+func Load(rootDir string) (*Registry, error) {
+	value, err := cache.Load(FilePath(rootDir), Parse)
+	if os.IsNotExist(err) {
+		return &Registry{}, nil
+	}
+	return value, err
+}
+
+// Parse parses and validates the YAML registry
+func Parse(data []byte) (*Registry, error) {
+	var registry Registry
+	if err := yaml.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	for name := range registry.Global {
+		if !NamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	for space, vars := range registry.Spaces {
+		if strings.Trim(space, "/ ") == "" {
+			return nil, errors.New("space path cannot be empty, use global instead")
+		}
+		for name := range vars {
+			if !NamePattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid variable name %q in space %q", name, space)
+			}
+		}
+	}
+
+	return &registry, nil
+}
+
+// ReadRaw returns the registry file content as written by the admin
+func ReadRaw(rootDir string) (string, error) {
+	data, err := os.ReadFile(FilePath(rootDir))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// SaveRaw validates the YAML content and writes it to the registry file
+func SaveRaw(rootDir, content string) error {
+	if _, err := Parse([]byte(content)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(FilePath(rootDir), []byte(content), 0644)
+}
+
+// Resolve returns the variables visible from a document. Space variables override
+// global ones, and deeper spaces override their parents.
+func (r *Registry) Resolve(docPath string) map[string]string {
+	resolved := make(map[string]string, len(r.Global))
+	for name, value := range r.Global {
+		resolved[name] = value
+	}
+
+	docPath = strings.Trim(docPath, "/")
+
+	// Apply matching spaces from the shallowest to the deepest
+	var matches []string
+	for space := range r.Spaces {
+		prefix := strings.Trim(space, "/")
+		if docPath == prefix || strings.HasPrefix(docPath, prefix+"/") {
+			matches = append(matches, space)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return len(strings.Trim(matches[i], "/")) < len(strings.Trim(matches[j], "/"))
+	})
+	for _, space := range matches {
+		for name, value := range r.Spaces[space] {
+			resolved[name] = value
+		}
+	}
+
+	return resolved
+}
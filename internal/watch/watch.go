@@ -0,0 +1,182 @@
+// Package watch keeps the pages each user watches. Watchers are notified when
+// somebody else changes a watched page or one of its subpages.
+package watch
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"wiki-go/internal/store"
+)
+
+// List is the watch list of a user
+type List struct {
+	User  string   `json:"user"`
+	Pages []string `json:"pages"` // Page paths without slashes around them, "" for the home page
+}
+
+var (
+	mutex          store.DataFile // Guards changes; lists are read without it, since files are replaced at once
+	unsafeFileChar = regexp.MustCompile(`[^a-zA-Z0-9_.@-]`)
+)
+
+// dir returns the directory holding the watch lists
+func dir(rootDir string) string {
+	return filepath.Join(rootDir, "watches")
+}
+
+// userFile returns the file holding the watch list of a user
+func userFile(rootDir, user string) string {
+	return filepath.Join(dir(rootDir), unsafeFileChar.ReplaceAllString(user, "_")+".json")
+}
+
+// Clean returns path the way it is stored in watch lists
+func Clean(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// Pages returns the pages user watches, sorted
+func Pages(rootDir, user string) ([]string, error) {
+	list, err := load(userFile(rootDir, user))
+	if err != nil {
+		return nil, err
+	}
+	return list.Pages, nil
+}
+
+// Watching reports whether user watches the page at path itself
+func Watching(rootDir, user, path string) (bool, error) {
+	pages, err := Pages(rootDir, user)
+	if err != nil {
+		return false, err
+	}
+	path = Clean(path)
+	for _, page := range pages {
+		if page == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Set adds the page at path to the watch list of user, or removes it when
+// watching is false
+func Set(rootDir, user, path string, watching bool) error {
+	unlock := mutex.Lock("watches/" + user)
+	defer unlock()
+
+	file := userFile(rootDir, user)
+	list, err := load(file)
+	if err != nil {
+		return err
+	}
+	list.User = user
+	path = Clean(path)
+
+	pages := list.Pages[:0]
+	for _, page := range list.Pages {
+		if page != path {
+			pages = append(pages, page)
+		}
+	}
+	if watching {
+		pages = append(pages, path)
+	}
+	sort.Strings(pages)
+	list.Pages = pages
+
+	if len(list.Pages) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return save(file, list)
+}
+
+// Watchers returns the users watching the page at path or one of the pages
+// above it, sorted
+func Watchers(rootDir, path string) ([]string, error) {
+	entries, err := os.ReadDir(dir(rootDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path = Clean(path)
+	var users []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		list, err := load(filepath.Join(dir(rootDir), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range list.Pages {
+			if covers(page, path) {
+				users = append(users, list.User)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// covers reports whether watching page includes changes to path. Watching the
+// home page only covers the home page itself.
+func covers(page, path string) bool {
+	if page == "" {
+		return path == ""
+	}
+	return path == page || strings.HasPrefix(path, page+"/")
+}
+
+// Delete removes the watch list of user
+func Delete(rootDir, user string) error {
+	unlock := mutex.Lock("watches/" + user)
+	defer unlock()
+
+	if err := os.Remove(userFile(rootDir, user)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load reads a stored watch list, an empty one when there is none
+func load(file string) (List, error) {
+	list := List{Pages: []string{}}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return list, err
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return list, err
+	}
+	if list.Pages == nil {
+		list.Pages = []string{}
+	}
+	return list, nil
+}
+
+// save stores a watch list
+func save(file string, list List) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(file, data, 0644)
+}
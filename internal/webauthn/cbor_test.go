@@ -0,0 +1,170 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// cborHead encodes the head of a CBOR item with the smallest argument size
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
+
+// encodeCBOR encodes the values used by the tests: integers, byte and text
+// strings, arrays and maps with integer or text keys
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		// Sorted keys keep the encoding stable
+		keys := make([][]byte, 0, len(v))
+		encoded := make(map[string][]byte, len(v))
+		for key, item := range v {
+			k := encodeCBOR(key)
+			keys = append(keys, k)
+			encoded[string(k)] = encodeCBOR(item)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		out := cborHead(5, uint64(len(v)))
+		for _, k := range keys {
+			out = append(append(out, k...), encoded[string(k)]...)
+		}
+		return out
+	}
+	panic("encodeCBOR: unsupported value")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected interface{}
+		rest     []byte
+	}{
+		{name: "Small integer", input: []byte{0x17}, expected: int64(23)},
+		{name: "One byte integer", input: []byte{0x18, 0xff}, expected: int64(255)},
+		{name: "Eight byte integer", input: []byte{0x1b, 0, 0, 0, 1, 0, 0, 0, 0}, expected: int64(1 << 32)},
+		{name: "Negative integer", input: []byte{0x26}, expected: int64(-7)},
+		{name: "Two byte negative integer", input: []byte{0x39, 0x01, 0x00}, expected: int64(-257)},
+		{name: "Byte string", input: []byte{0x43, 1, 2, 3}, expected: []byte{1, 2, 3}},
+		{name: "Text string", input: []byte{0x64, 'f', 'm', 't', 's'}, expected: "fmts"},
+		{name: "Array", input: []byte{0x82, 0x01, 0x61, 'a'}, expected: []interface{}{int64(1), "a"}},
+		{
+			name:     "Map",
+			input:    encodeCBOR(map[interface{}]interface{}{"fmt": "none", 3: -7}),
+			expected: map[interface{}]interface{}{"fmt": "none", int64(3): int64(-7)},
+		},
+		{name: "Tags are skipped", input: []byte{0xc2, 0x41, 0x05}, expected: []byte{5}},
+		{name: "Booleans", input: []byte{0xf5}, expected: true},
+		{name: "Null", input: []byte{0xf6}, expected: nil},
+		{name: "Half float", input: []byte{0xf9, 0x3c, 0x00}, expected: nil},
+		{name: "Remaining bytes", input: []byte{0x01, 0x02, 0x03}, expected: int64(1), rest: []byte{0x02, 0x03}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeCBOR(tt.input)
+			if err != nil {
+				t.Fatalf("decodeCBOR(%x) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("decodeCBOR(%x) = %#v, want %#v", tt.input, got, tt.expected)
+			}
+			if len(rest) != len(tt.rest) || !bytes.Equal(rest, tt.rest) {
+				t.Errorf("decodeCBOR(%x) left %x, want %x", tt.input, rest, tt.rest)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformedCBOR(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, maxCBORDepth+2)
+	deep = append(deep, 0x01)
+
+	tests := []struct {
+		name      string
+		input     []byte
+		truncated bool
+	}{
+		{name: "Empty", input: nil, truncated: true},
+		{name: "Truncated argument", input: []byte{0x19, 0x01}, truncated: true},
+		{name: "Truncated byte string", input: []byte{0x45, 1, 2}, truncated: true},
+		{name: "Byte string longer than the input", input: []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, truncated: true},
+		{name: "Array longer than the input", input: []byte{0x9b, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0x01}, truncated: true},
+		{name: "Map longer than the input", input: []byte{0xba, 0xff, 0xff, 0xff, 0xff}, truncated: true},
+		{name: "Map missing a value", input: []byte{0xa1, 0x01}, truncated: true},
+		{name: "Truncated float", input: []byte{0xfb, 0, 0}, truncated: true},
+		{name: "Tag without a value", input: []byte{0xc2}, truncated: true},
+		{name: "Integer overflow", input: []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "Negative integer overflow", input: []byte{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "Indefinite length", input: []byte{0x5f, 0x41, 0x01, 0xff}},
+		{name: "Reserved additional information", input: []byte{0x1c}},
+		{name: "Unsupported simple value", input: []byte{0xf0}},
+		{name: "Byte string map key", input: []byte{0xa1, 0x41, 0x01, 0x01}},
+		{name: "Array map key", input: []byte{0xa1, 0x80, 0x01}},
+		{name: "Nesting too deep", input: deep},
+		{name: "Tags nested too deep", input: append(bytes.Repeat([]byte{0xc2}, maxCBORDepth+2), 0x01)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeCBOR(tt.input)
+			if err == nil {
+				t.Fatalf("decodeCBOR(%x) succeeded, want an error", tt.input)
+			}
+			if tt.truncated && !errors.Is(err, errCBORTruncated) {
+				t.Errorf("decodeCBOR(%x) = %v, want %v", tt.input, err, errCBORTruncated)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORNeverPanics(t *testing.T) {
+	// Every prefix of a valid attestation object, and every single byte flip of it
+	object := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": bytes.Repeat([]byte{0xa5}, 64),
+		"extra":    []interface{}{1, -2, "three", []byte{4}},
+	})
+	for i := 0; i <= len(object); i++ {
+		decodeCBOR(object[:i])
+	}
+	for i := range object {
+		for _, b := range []byte{0x00, 0x1f, 0x5f, 0x9f, 0xbf, 0xdf, 0xff} {
+			mutated := append([]byte(nil), object...)
+			mutated[i] = b
+			decodeCBOR(mutated)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wiki-go/internal/store"
+)
+
+// ChallengeTimeout is how long a registration or login ceremony stays valid
+const ChallengeTimeout = 5 * time.Minute
+
+// Credential is a passkey registered by a user
+type Credential struct {
+	ID         string    `json:"id"`        // base64url credential ID
+	PublicKey  []byte    `json:"publicKey"` // COSE encoded public key
+	Algorithm  int       `json:"algorithm"`
+	SignCount  uint32    `json:"signCount"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Ceremony is a pending registration or login waiting for the browser response
+type Ceremony struct {
+	Username     string // User registering a credential, or the only user allowed to log in
+	KeepLoggedIn bool
+	expires      time.Time
+}
+
+var (
+	storeMutex sync.Mutex
+
+	ceremonyMutex sync.Mutex
+	ceremonies    = make(map[string]Ceremony)
+	ceremonyFile  store.DataFile // Guards the ceremonies shared with other instances
+)
+
+// savedCeremony is a ceremony kept in the data directory
+type savedCeremony struct {
+	Username     string    `json:"username"`
+	KeepLoggedIn bool      `json:"keepLoggedIn,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+// ErrNotFound is returned for unknown credentials
+var ErrNotFound = errors.New("passkey not found")
+
+// filePath returns the location of the stored passkeys
+func filePath(rootDir string) string {
+	return filepath.Join(rootDir, "passkeys.json")
+}
+
+// load reads the passkeys of every user
+func load(rootDir string) (map[string][]Credential, error) {
+	data, err := os.ReadFile(filePath(rootDir))
+	if os.IsNotExist(err) {
+		return map[string][]Credential{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all := map[string][]Credential{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// save writes the passkeys of every user. The file is replaced at once, so
+// other instances reading it never see it half written.
+func save(rootDir string, all map[string][]Credential) error {
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	file := filePath(rootDir)
+	if err := os.WriteFile(file+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// lockStore is held while passkeys are changed and returns the function
+// releasing it. Instances sharing the data directory take turns, so none of
+// them overwrites the changes of another.
+func lockStore() func() {
+	storeMutex.Lock()
+	if !store.Shared() {
+		return storeMutex.Unlock
+	}
+	unlock, err := store.Current.Lock("passkeys")
+	if err != nil {
+		// Changes are still serialized on this instance
+		log.Printf("Warning: failed to lock the passkeys: %v", err)
+		return storeMutex.Unlock
+	}
+	return func() {
+		unlock()
+		storeMutex.Unlock()
+	}
+}
+
+// List returns the passkeys of a user
+func List(rootDir, username string) ([]Credential, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return all[username], nil
+}
+
+// Add stores a new passkey for a user
+func Add(rootDir, username string, credential Credential) error {
+	defer lockStore()()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	for _, credentials := range all {
+		for _, existing := range credentials {
+			if existing.ID == credential.ID {
+				return errors.New("passkey is already registered")
+			}
+		}
+	}
+
+	credential.CreatedAt = time.Now()
+	all[username] = append(all[username], credential)
+	return save(rootDir, all)
+}
+
+// Remove deletes a passkey of a user
+func Remove(rootDir, username, id string) error {
+	defer lockStore()()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+
+	credentials := all[username]
+	for i, credential := range credentials {
+		if credential.ID == id {
+			all[username] = append(credentials[:i], credentials[i+1:]...)
+			if len(all[username]) == 0 {
+				delete(all, username)
+			}
+			return save(rootDir, all)
+		}
+	}
+	return ErrNotFound
+}
+
+// RemoveAll deletes every passkey of a user
+func RemoveAll(rootDir, username string) error {
+	defer lockStore()()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	if _, ok := all[username]; !ok {
+		return nil
+	}
+	delete(all, username)
+	return save(rootDir, all)
+}
+
+// Find returns the owner and data of a passkey by its ID
+func Find(rootDir, id string) (string, *Credential, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return "", nil, err
+	}
+	for username, credentials := range all {
+		for _, credential := range credentials {
+			if credential.ID == id {
+				return username, &credential, nil
+			}
+		}
+	}
+	return "", nil, ErrNotFound
+}
+
+// MarkUsed records a successful login with a passkey and its new signature counter
+func MarkUsed(rootDir, username, id string, signCount uint32) error {
+	defer lockStore()()
+
+	all, err := load(rootDir)
+	if err != nil {
+		return err
+	}
+	for i, credential := range all[username] {
+		if credential.ID == id {
+			all[username][i].SignCount = signCount
+			all[username][i].LastUsedAt = time.Now()
+			return save(rootDir, all)
+		}
+	}
+	return ErrNotFound
+}
+
+// ceremoniesPath returns the location of the pending ceremonies. They are only
+// saved when several instances serve the wiki, as the browser may answer
+// another instance than the one that started the ceremony.
+func ceremoniesPath(rootDir string) string {
+	return filepath.Join(rootDir, "passkey-ceremonies.json")
+}
+
+// StartCeremony remembers a challenge until the browser answers it
+func StartCeremony(rootDir, challenge string, ceremony Ceremony) error {
+	now := time.Now()
+	if store.Shared() {
+		unlock := ceremonyFile.Lock("passkey-ceremonies.json")
+		defer unlock()
+
+		saved, err := loadCeremonies(rootDir)
+		if err != nil {
+			return err
+		}
+		for key, pending := range saved {
+			if now.After(pending.Expires) {
+				delete(saved, key)
+			}
+		}
+		saved[challenge] = savedCeremony{Username: ceremony.Username, KeepLoggedIn: ceremony.KeepLoggedIn, Expires: now.Add(ChallengeTimeout)}
+		return saveCeremonies(rootDir, saved)
+	}
+
+	ceremonyMutex.Lock()
+	defer ceremonyMutex.Unlock()
+
+	for key, pending := range ceremonies {
+		if now.After(pending.expires) {
+			delete(ceremonies, key)
+		}
+	}
+
+	ceremony.expires = now.Add(ChallengeTimeout)
+	ceremonies[challenge] = ceremony
+	return nil
+}
+
+// FinishCeremony returns and forgets the ceremony of a challenge. Every challenge
+// can only be used once.
+func FinishCeremony(rootDir, challenge string) (Ceremony, bool) {
+	if store.Shared() {
+		unlock := ceremonyFile.Lock("passkey-ceremonies.json")
+		defer unlock()
+
+		saved, err := loadCeremonies(rootDir)
+		if err != nil {
+			log.Printf("Error loading passkey ceremonies: %v", err)
+			return Ceremony{}, false
+		}
+		pending, ok := saved[challenge]
+		if !ok {
+			return Ceremony{}, false
+		}
+		delete(saved, challenge)
+		if err := saveCeremonies(rootDir, saved); err != nil {
+			// The challenge could be used again
+			log.Printf("Error saving passkey ceremonies: %v", err)
+			return Ceremony{}, false
+		}
+		if time.Now().After(pending.Expires) {
+			return Ceremony{}, false
+		}
+		return Ceremony{Username: pending.Username, KeepLoggedIn: pending.KeepLoggedIn, expires: pending.Expires}, true
+	}
+
+	ceremonyMutex.Lock()
+	defer ceremonyMutex.Unlock()
+
+	ceremony, ok := ceremonies[challenge]
+	delete(ceremonies, challenge)
+	if !ok || time.Now().After(ceremony.expires) {
+		return Ceremony{}, false
+	}
+	return ceremony, true
+}
+
+// loadCeremonies reads the ceremonies shared with other instances by challenge
+func loadCeremonies(rootDir string) (map[string]savedCeremony, error) {
+	saved := make(map[string]savedCeremony)
+	data, err := os.ReadFile(ceremoniesPath(rootDir))
+	if os.IsNotExist(err) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// saveCeremonies writes the ceremonies shared with other instances. Only the
+// server reads the file: it holds the challenges.
+func saveCeremonies(rootDir string, saved map[string]savedCeremony) error {
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFile(ceremoniesPath(rootDir), data, 0600)
+}
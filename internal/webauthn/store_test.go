@@ -0,0 +1,45 @@
+package webauthn
+
+import (
+	"os"
+	"testing"
+
+	"wiki-go/internal/store"
+)
+
+func TestCeremonies(t *testing.T) {
+	redis, err := store.OpenRedis("redis://127.0.0.1:1/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redis.Close()
+
+	stores := map[string]store.Store{"Local": store.NewMemory(), "Shared": redis}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			previous := store.Current
+			store.Current = s
+			defer func() { store.Current = previous }()
+
+			rootDir := t.TempDir()
+			if err := StartCeremony(rootDir, "challenge", Ceremony{Username: "ann", KeepLoggedIn: true}); err != nil {
+				t.Fatalf("StartCeremony() = %v", err)
+			}
+			_, statErr := os.Stat(ceremoniesPath(rootDir))
+			if saved := statErr == nil; saved != store.Shared() {
+				t.Errorf("ceremony saved in the data directory = %v, want %v", saved, store.Shared())
+			}
+
+			if _, ok := FinishCeremony(rootDir, "other"); ok {
+				t.Error("FinishCeremony() = true for an unknown challenge")
+			}
+			ceremony, ok := FinishCeremony(rootDir, "challenge")
+			if !ok || ceremony.Username != "ann" || !ceremony.KeepLoggedIn {
+				t.Errorf("FinishCeremony() = %+v, %v, want the ceremony of ann", ceremony, ok)
+			}
+			if _, ok := FinishCeremony(rootDir, "challenge"); ok {
+				t.Error("FinishCeremony() = true for a challenge already used")
+			}
+		})
+	}
+}
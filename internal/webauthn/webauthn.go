@@ -0,0 +1,374 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// COSE algorithm identifiers of the supported credential keys
+const (
+	AlgES256 = -7   // ECDSA with P-256 and SHA-256
+	AlgEdDSA = -8   // Ed25519
+	AlgRS256 = -257 // RSASSA-PKCS1-v1_5 with SHA-256
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent   = 0x01
+	flagAttestedData  = 0x40
+	authDataMinLength = 37
+)
+
+// Relying party of a ceremony: the wiki as seen by the browser
+type RelyingParty struct {
+	ID     string // Host name the credentials are scoped to
+	Origin string // Expected origin, e.g. https://wiki.example.com
+}
+
+// ErrNoServerURL is returned when the public URL of the wiki isn't configured
+var ErrNoServerURL = errors.New("passkeys need the public URL of the wiki in server.url")
+
+// NewRelyingParty returns the relying party of the wiki served at serverURL,
+// its configured public URL. The request isn't used, its Host header and
+// forwarded headers are set by the client.
+func NewRelyingParty(serverURL string) (RelyingParty, error) {
+	serverURL = strings.TrimSpace(serverURL)
+	if serverURL == "" {
+		return RelyingParty{}, ErrNoServerURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" || u.User != nil {
+		return RelyingParty{}, fmt.Errorf("invalid server.url %q, use e.g. https://wiki.example.com", serverURL)
+	}
+	// Browsers leave the default port out of origins
+	host := strings.ToLower(u.Host)
+	if (u.Scheme == "https" && u.Port() == "443") || (u.Scheme == "http" && u.Port() == "80") {
+		host = strings.TrimSuffix(host, ":"+u.Port())
+	}
+	return RelyingParty{
+		ID:     strings.ToLower(u.Hostname()),
+		Origin: u.Scheme + "://" + host,
+	}, nil
+}
+
+// AttestationResponse is the response of navigator.credentials.create()
+type AttestationResponse struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`    // base64url
+	AttestationObject string `json:"attestationObject"` // base64url
+}
+
+// AssertionResponse is the response of navigator.credentials.get()
+type AssertionResponse struct {
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"clientDataJSON"`    // base64url
+	AuthenticatorData string `json:"authenticatorData"` // base64url
+	Signature         string `json:"signature"`         // base64url
+	UserHandle        string `json:"userHandle"`        // base64url, may be empty
+}
+
+// clientData is the part of the client data checked by the server
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData is the parsed authenticator data of a response
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte // Only set in attestations
+	PublicKey    []byte // COSE encoded, only set in attestations
+}
+
+// Encoding is the base64url encoding without padding used by WebAuthn
+var Encoding = base64.RawURLEncoding
+
+// NewChallenge returns a new random challenge, base64url encoded
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Encoding.EncodeToString(b), nil
+}
+
+// VerifyRegistration checks a registration response for challenge and returns
+// the new credential. The attestation statement is not verified, the wiki asks
+// for "none" attestation and trusts any authenticator.
+func VerifyRegistration(rp RelyingParty, challenge string, resp AttestationResponse) (*Credential, error) {
+	clientDataJSON, err := decodeField("clientDataJSON", resp.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyClientData(clientDataJSON, "webauthn.create", challenge, rp.Origin); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := decodeField("attestationObject", resp.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyAuthenticatorData(authData, rp.ID); err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, errors.New("response contains no credential")
+	}
+
+	algorithm, _, err := parsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        Encoding.EncodeToString(authData.CredentialID),
+		PublicKey: authData.PublicKey,
+		Algorithm: algorithm,
+		SignCount: authData.SignCount,
+	}, nil
+}
+
+// VerifyAssertion checks a login response for challenge against the stored
+// credential and returns the new signature counter
+func VerifyAssertion(rp RelyingParty, challenge string, credential Credential, resp AssertionResponse) (uint32, error) {
+	clientDataJSON, err := decodeField("clientDataJSON", resp.ClientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyClientData(clientDataJSON, "webauthn.get", challenge, rp.Origin); err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decodeField("authenticatorData", resp.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err := verifyAuthenticatorData(authData, rp.ID); err != nil {
+		return 0, err
+	}
+
+	signature, err := decodeField("signature", resp.Signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// The signature covers the authenticator data and the hash of the client data
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := verifySignature(credential.PublicKey, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// A counter that doesn't increase hints at a cloned authenticator. Authenticators
+	// without a counter always report zero.
+	if authData.SignCount != 0 || credential.SignCount != 0 {
+		if authData.SignCount <= credential.SignCount {
+			return 0, errors.New("signature counter did not increase, the authenticator may be cloned")
+		}
+	}
+
+	return authData.SignCount, nil
+}
+
+// decodeField decodes a base64url field of a response
+func decodeField(name, value string) ([]byte, error) {
+	data, err := Encoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return data, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin of the client data
+func verifyClientData(data []byte, ceremony, challenge, origin string) error {
+	var client clientData
+	if err := json.Unmarshal(data, &client); err != nil {
+		return errors.New("invalid client data")
+	}
+	if client.Type != ceremony {
+		return fmt.Errorf("unexpected ceremony type %q", client.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(client.Challenge), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if client.Origin != origin {
+		return fmt.Errorf("unexpected origin %q", client.Origin)
+	}
+	return nil
+}
+
+// verifyAuthenticatorData checks that the response is for this wiki and that the user was present
+func verifyAuthenticatorData(authData *authenticatorData, rpID string) error {
+	expected := sha256.Sum256([]byte(rpID))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, expected[:]) != 1 {
+		return errors.New("credential belongs to another site")
+	}
+	if authData.Flags&flagUserPresent == 0 {
+		return errors.New("user presence was not confirmed")
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses the binary authenticator data
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < authDataMinLength {
+		return nil, errors.New("authenticator data too short")
+	}
+
+	authData := &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.Flags&flagAttestedData == 0 {
+		return authData, nil
+	}
+
+	// Attested credential data: AAGUID (16), credential ID length (2), ID, COSE key
+	rest := data[authDataMinLength:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data too short")
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return nil, errors.New("credential ID truncated")
+	}
+	authData.CredentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	authData.PublicKey = rest[:len(rest)-len(after)]
+	return authData, nil
+}
+
+// parsePublicKey decodes a COSE key into its algorithm and public key
+func parsePublicKey(coseKey []byte) (int, crypto.PublicKey, error) {
+	decoded, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, errors.New("invalid public key")
+	}
+
+	keyType, _ := key[int64(1)].(int64)
+	algorithm, _ := key[int64(3)].(int64)
+	param := func(label int64) []byte {
+		value, _ := key[label].([]byte)
+		return value
+	}
+
+	switch {
+	case keyType == 2 && algorithm == AlgES256:
+		x, y := param(-2), param(-3)
+		if curve, _ := key[int64(-1)].(int64); curve != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("unsupported EC2 key")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return 0, nil, errors.New("invalid EC2 key")
+		}
+		return AlgES256, publicKey, nil
+
+	case keyType == 1 && algorithm == AlgEdDSA:
+		x := param(-2)
+		if curve, _ := key[int64(-1)].(int64); curve != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("unsupported OKP key")
+		}
+		return AlgEdDSA, ed25519.PublicKey(x), nil
+
+	case keyType == 3 && algorithm == AlgRS256:
+		n, e := param(-1), param(-2)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, errors.New("unsupported RSA key")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return AlgRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+	}
+
+	return 0, nil, fmt.Errorf("unsupported key type %d with algorithm %d", keyType, algorithm)
+}
+
+// verifySignature checks signature over data with a COSE public key
+func verifySignature(coseKey, data, signature []byte) error {
+	_, publicKey, err := parsePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		hash := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key, hash[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		hash := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	}
+
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ResponseChallenge returns the challenge a response answers, used to look up its ceremony
+func ResponseChallenge(clientDataJSON string) (string, error) {
+	data, err := decodeField("clientDataJSON", clientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	var client clientData
+	if err := json.Unmarshal(data, &client); err != nil || client.Challenge == "" {
+		return "", errors.New("invalid client data")
+	}
+	return client.Challenge, nil
+}
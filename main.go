@@ -4,18 +4,39 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"wiki-go/internal/config"
+	"wiki-go/internal/encryption"
+	"wiki-go/internal/federation"
 	"wiki-go/internal/handlers"
+	"wiki-go/internal/jobs"
 	"wiki-go/internal/migration"
+	"wiki-go/internal/review"
 	"wiki-go/internal/routes"
+	"wiki-go/internal/secrets"
+	"wiki-go/internal/semantic"
 	"wiki-go/internal/static"
+	"wiki-go/internal/store"
+	"wiki-go/internal/utils"
+
+	"github.com/quic-go/quic-go/http3"
 
 	// Import goldext package for its initialization side effects
 	_ "wiki-go/internal/goldext"
 )
 
 func main() {
+	// Manage the encrypted secrets referenced from config.yaml
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		if err := secrets.RunCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Migrate user roles from old IsAdmin to new role-based system
 	if err := migration.MigrateUserRoles(config.ConfigFilePath); err != nil {
 		log.Fatal("Error migrating user roles:", err)
@@ -30,6 +51,23 @@ func main() {
 	// Put global config
 	config.Cfg = cfg
 
+	// Encrypt the pages of the directories kept encrypted on disk
+	if err := encryption.Configure(cfg); err != nil {
+		log.Fatal("Error loading encryption key:", err)
+	}
+	if changed, err := encryption.Migrate(cfg); err != nil {
+		log.Fatal("Error encrypting documents:", err)
+	} else if changed > 0 {
+		log.Printf("Encrypted or decrypted %d files to match security.encryption.directories", changed)
+	}
+
+	// Open the store for sessions, login attempts and the locks shared by instances
+	st, err := store.Open(cfg)
+	if err != nil {
+		log.Fatal("Error opening database:", err)
+	}
+	store.Current = st
+
 	// Ensure the homepage exists
 	if err := handlers.EnsureHomepageExists(cfg); err != nil {
 		log.Fatal("Error creating homepage:", err)
@@ -40,23 +78,117 @@ func main() {
 		log.Fatal("Error copying static assets:", err)
 	}
 
+	// Air-gapped wikis must not depend on other sites, report what still does
+	if cfg.Wiki.AirGapped {
+		remote := static.RemoteAssets(cfg)
+		for _, asset := range remote {
+			log.Printf("Warning: air_gapped is set, but %s loads %s from another site", asset.Source, asset.URL)
+		}
+		if len(remote) == 0 {
+			log.Printf("Air-gapped mode: no assets are loaded from other sites")
+		}
+	}
+
 	// Update handlers with config
 	handlers.InitHandlers(cfg)
 
 	// Setup all routes
 	routes.SetupRoutes(cfg)
 
+	// Run background jobs, including those left over from the last run
+	if err := jobs.Start(cfg.Wiki.RootDir, cfg.Jobs.Workers, cfg.Jobs.MaxAttempts); err != nil {
+		log.Fatal("Error starting job queue:", err)
+	}
+
+	// Limit the documents rendered at the same time
+	utils.ConfigureRenders(cfg.Server.RenderWorkers, cfg.Server.MaxConcurrentRenders)
+
+	// Keep the navigation tree up to date without walking all documents
+	if err := utils.WatchNavigation(cfg.Wiki.RootDir, cfg.Wiki.DocumentsDir); err != nil {
+		log.Printf("Warning: can't watch documents, the navigation is rebuilt after changes: %v", err)
+	}
+
+	// Notify page owners about pages that are overdue for review
+	review.StartScheduler(cfg, time.Hour)
+
+	// Pull the directories synced from other wikis when they are due
+	federation.StartScheduler(cfg.Wiki.RootDir, time.Minute)
+
+	// Index pages for "Ask the wiki" as they change
+	semantic.StartIndexer(cfg, 15*time.Minute)
+
+	// Instances sharing the database take turns changing the configuration and
+	// pick up the changes made by each other
+	if store.Shared() {
+		config.Share(st)
+		config.OnReload(func() { handlers.InitLoginBan(cfg) })
+		go refreshShared(sharedRefreshInterval)
+	}
+
 	// Start the server
+	if err := serve(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// sharedRefreshInterval is how often an instance looks for the changes made by
+// the other instances sharing its database
+const sharedRefreshInterval = 5 * time.Second
+
+// refreshShared reloads the configuration, the navigation tree and the semantic
+// index after other instances changed them
+func refreshShared(interval time.Duration) {
+	for range time.Tick(interval) {
+		if config.Refresh() {
+			log.Printf("Reloaded the configuration changed by another instance")
+		}
+		utils.RefreshNavigation()
+		semantic.Refresh()
+	}
+}
+
+// serve runs the HTTP server with the protocols enabled in the config. HTTP/3
+// runs next to it on the UDP port; browsers find it through the Alt-Svc header.
+func serve(cfg *config.Config) error {
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	if cfg.Server.SSL && cfg.Server.SSLCert != "" && cfg.Server.SSLKey != "" {
-		fmt.Printf("HTTPS server starting on %s...\n", addr)
-		if err := http.ListenAndServeTLS(addr, cfg.Server.SSLCert, cfg.Server.SSLKey, nil); err != nil {
-			log.Fatal(err)
+	tlsEnabled := cfg.Server.SSL && cfg.Server.SSLCert != "" && cfg.Server.SSLKey != ""
+
+	server := &http.Server{Addr: addr, Protocols: new(http.Protocols)}
+	server.Protocols.SetHTTP1(true)
+	if cfg.Server.HTTP2 {
+		server.Protocols.SetHTTP2(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if !tlsEnabled {
+		if cfg.Server.HTTP3 {
+			log.Printf("Warning: HTTP/3 requires ssl, serving HTTP/1.1 and HTTP/2 only")
 		}
-	} else {
 		fmt.Printf("HTTP server starting on %s...\n", addr)
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Fatal(err)
+		return server.ListenAndServe()
+	}
+
+	if cfg.Server.HTTP3 {
+		port := cfg.Server.HTTP3Port
+		if port == 0 {
+			port = cfg.Server.Port
 		}
+		quicServer := &http3.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, port),
+			Handler: http.DefaultServeMux,
+		}
+		server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			quicServer.SetQUICHeaders(w.Header())
+			http.DefaultServeMux.ServeHTTP(w, r)
+		})
+		go func() {
+			fmt.Printf("HTTP/3 server starting on %s (UDP)...\n", quicServer.Addr)
+			if err := quicServer.ListenAndServeTLS(cfg.Server.SSLCert, cfg.Server.SSLKey); err != nil {
+				log.Printf("Warning: HTTP/3 server stopped: %v", err)
+			}
+		}()
 	}
+
+	fmt.Printf("HTTPS server starting on %s...\n", addr)
+	return server.ListenAndServeTLS(cfg.Server.SSLCert, cfg.Server.SSLKey)
 }